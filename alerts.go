@@ -0,0 +1,89 @@
+package voxa
+
+import (
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/rules"
+)
+
+// AlertRule raises an alert when a stream says any of its keywords or
+// matches any of its patterns; see Config.Alerts. Keywords are phrases
+// matched case-insensitively on whole words, with any spaces or
+// punctuation between their words. Patterns are RE2 regular expressions
+// matched case-insensitively anywhere in the transcript.
+type AlertRule struct {
+	// Name identifies the rule in its alerts; rules need distinct names.
+	Name     string
+	Keywords []string
+	Patterns []string
+	// Partials also matches partial segments, so the alert goes out as
+	// soon as the phrase is recognized instead of once the utterance
+	// ends. Otherwise only final segments are matched.
+	Partials bool
+	// Actions receive the EventAlert events of the rule, such as a webhook
+	// sink, an MQTT bridge or AlertLogger.
+	Actions []EventSink
+}
+
+// Alert is an AlertRule matching a segment.
+type Alert struct {
+	// Rule is the name of the rule.
+	Rule string
+	// Match is the text it matched, as transcribed.
+	Match string
+}
+
+// AlertLogger returns an action logging every alert it receives to log,
+// at the warning level.
+func AlertLogger(log Logger) EventSink {
+	return alertLogger{logging.OrNop(log)}
+}
+
+type alertLogger struct{ log Logger }
+
+func (l alertLogger) Publish(ev Event) {
+	if ev.Type != EventAlert {
+		return
+	}
+	l.log.Warn("alert", "rule", ev.Alert.Rule, "match", ev.Alert.Match, "session", ev.SessionID,
+		"utterance", ev.Segment.UtteranceID, "final", ev.Segment.Final)
+}
+
+// newAlerts compiles the rules of Config.Alerts.
+func newAlerts(rs []AlertRule) (*rules.Engine, error) {
+	defs := make([]rules.Rule, len(rs))
+	for i, r := range rs {
+		defs[i] = rules.Rule{Name: r.Name, Keywords: r.Keywords, Patterns: r.Patterns, Partials: r.Partials}
+	}
+	return rules.New(defs)
+}
+
+// alert raises the alerts of seg: once per rule and utterance, so a rule
+// matching a partial does not fire again on the revisions and final that
+// follow. It is called on the segment path of s, after the transcript
+// stages.
+func (s *Stream) alert(seg *Segment) {
+	if s.rules == nil {
+		return
+	}
+	if seg.UtteranceID != s.alerted.utterance {
+		s.alerted.utterance = seg.UtteranceID
+		clear(s.alerted.rules)
+	}
+	for _, m := range s.rules.Match(seg.Text, seg.Final) {
+		if s.alerted.rules[m.Rule] {
+			continue
+		}
+		if s.alerted.rules == nil {
+			s.alerted.rules = map[int]bool{}
+		}
+		s.alerted.rules[m.Rule] = true
+		r := &s.alerts[m.Rule]
+		s.metrics.Alert(r.Name)
+		s.log.Debug("alert", "rule", r.Name, "utterance", seg.UtteranceID)
+		ev := s.event(Event{Type: EventAlert, Alert: &Alert{Rule: r.Name, Match: m.Text}, Segment: seg})
+		for _, a := range r.Actions {
+			a.Publish(ev)
+		}
+		s.bus.publish(ev)
+	}
+}
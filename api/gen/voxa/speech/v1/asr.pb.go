@@ -0,0 +1,853 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v5.26.1
+// source: voxa/speech/v1/asr.proto
+
+package speechv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ControlType is the type of control message.
+type ControlType int32
+
+const (
+	// The control type is unspecified.
+	ControlType_CONTROL_TYPE_UNSPECIFIED ControlType = 0
+	// FLUSH: Forces finalize the transcript buffer.
+	ControlType_FLUSH ControlType = 1
+	// RESET: Clear the current transcript and start a new one.
+	ControlType_RESET ControlType = 2
+	// END: Forces finalize and to close the stream.
+	ControlType_END ControlType = 3
+)
+
+// Enum value maps for ControlType.
+var (
+	ControlType_name = map[int32]string{
+		0: "CONTROL_TYPE_UNSPECIFIED",
+		1: "FLUSH",
+		2: "RESET",
+		3: "END",
+	}
+	ControlType_value = map[string]int32{
+		"CONTROL_TYPE_UNSPECIFIED": 0,
+		"FLUSH":                    1,
+		"RESET":                    2,
+		"END":                      3,
+	}
+)
+
+func (x ControlType) Enum() *ControlType {
+	p := new(ControlType)
+	*p = x
+	return p
+}
+
+func (x ControlType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ControlType) Descriptor() protoreflect.EnumDescriptor {
+	return file_voxa_speech_v1_asr_proto_enumTypes[0].Descriptor()
+}
+
+func (ControlType) Type() protoreflect.EnumType {
+	return &file_voxa_speech_v1_asr_proto_enumTypes[0]
+}
+
+func (x ControlType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ControlType.Descriptor instead.
+func (ControlType) EnumDescriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_asr_proto_rawDescGZIP(), []int{0}
+}
+
+// ResponseType is the type of response.
+type ResponseType int32
+
+const (
+	// The response type is unspecified.
+	ResponseType_RESPONSE_TYPE_UNSPECIFIED ResponseType = 0
+	// The partial transcript.
+	ResponseType_PARTIAL ResponseType = 1
+	// The final transcript.
+	ResponseType_FINAL ResponseType = 2
+	// The control acknowledgment.
+	ResponseType_CONTROL_ACK ResponseType = 3
+	// The error.
+	ResponseType_ERROR ResponseType = 4
+)
+
+// Enum value maps for ResponseType.
+var (
+	ResponseType_name = map[int32]string{
+		0: "RESPONSE_TYPE_UNSPECIFIED",
+		1: "PARTIAL",
+		2: "FINAL",
+		3: "CONTROL_ACK",
+		4: "ERROR",
+	}
+	ResponseType_value = map[string]int32{
+		"RESPONSE_TYPE_UNSPECIFIED": 0,
+		"PARTIAL":                   1,
+		"FINAL":                     2,
+		"CONTROL_ACK":               3,
+		"ERROR":                     4,
+	}
+)
+
+func (x ResponseType) Enum() *ResponseType {
+	p := new(ResponseType)
+	*p = x
+	return p
+}
+
+func (x ResponseType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ResponseType) Descriptor() protoreflect.EnumDescriptor {
+	return file_voxa_speech_v1_asr_proto_enumTypes[1].Descriptor()
+}
+
+func (ResponseType) Type() protoreflect.EnumType {
+	return &file_voxa_speech_v1_asr_proto_enumTypes[1]
+}
+
+func (x ResponseType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ResponseType.Descriptor instead.
+func (ResponseType) EnumDescriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_asr_proto_rawDescGZIP(), []int{1}
+}
+
+// ========================= Requests =========================
+type StreamingRecognizeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Utterance ID.
+	UtteranceId string `protobuf:"bytes,1,opt,name=utterance_id,json=utteranceId,proto3" json:"utterance_id,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*StreamingRecognizeRequest_Control
+	//	*StreamingRecognizeRequest_Audio
+	Payload       isStreamingRecognizeRequest_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamingRecognizeRequest) Reset() {
+	*x = StreamingRecognizeRequest{}
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamingRecognizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamingRecognizeRequest) ProtoMessage() {}
+
+func (x *StreamingRecognizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamingRecognizeRequest.ProtoReflect.Descriptor instead.
+func (*StreamingRecognizeRequest) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_asr_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StreamingRecognizeRequest) GetUtteranceId() string {
+	if x != nil {
+		return x.UtteranceId
+	}
+	return ""
+}
+
+func (x *StreamingRecognizeRequest) GetPayload() isStreamingRecognizeRequest_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *StreamingRecognizeRequest) GetControl() *ASRControl {
+	if x != nil {
+		if x, ok := x.Payload.(*StreamingRecognizeRequest_Control); ok {
+			return x.Control
+		}
+	}
+	return nil
+}
+
+func (x *StreamingRecognizeRequest) GetAudio() *AudioChunk {
+	if x != nil {
+		if x, ok := x.Payload.(*StreamingRecognizeRequest_Audio); ok {
+			return x.Audio
+		}
+	}
+	return nil
+}
+
+type isStreamingRecognizeRequest_Payload interface {
+	isStreamingRecognizeRequest_Payload()
+}
+
+type StreamingRecognizeRequest_Control struct {
+	// Control messages
+	Control *ASRControl `protobuf:"bytes,10,opt,name=control,proto3,oneof"`
+}
+
+type StreamingRecognizeRequest_Audio struct {
+	// Audio chunks
+	Audio *AudioChunk `protobuf:"bytes,11,opt,name=audio,proto3,oneof"`
+}
+
+func (*StreamingRecognizeRequest_Control) isStreamingRecognizeRequest_Payload() {}
+
+func (*StreamingRecognizeRequest_Audio) isStreamingRecognizeRequest_Payload() {}
+
+// ASRControl is a control message for the ASR engine.
+type ASRControl struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The type of control message.
+	Type          ControlType `protobuf:"varint,1,opt,name=type,proto3,enum=voxa.speech.v1.ControlType" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ASRControl) Reset() {
+	*x = ASRControl{}
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ASRControl) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ASRControl) ProtoMessage() {}
+
+func (x *ASRControl) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ASRControl.ProtoReflect.Descriptor instead.
+func (*ASRControl) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_asr_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ASRControl) GetType() ControlType {
+	if x != nil {
+		return x.Type
+	}
+	return ControlType_CONTROL_TYPE_UNSPECIFIED
+}
+
+// ========================= Responses =========================
+type StreamingRecognizeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The utterance ID.
+	UtteranceId string `protobuf:"bytes,1,opt,name=utterance_id,json=utteranceId,proto3" json:"utterance_id,omitempty"`
+	// The type of response.
+	Type ResponseType `protobuf:"varint,2,opt,name=type,proto3,enum=voxa.speech.v1.ResponseType" json:"type,omitempty"`
+	// Types that are valid to be assigned to Result:
+	//
+	//	*StreamingRecognizeResponse_PartialTranscript
+	//	*StreamingRecognizeResponse_FinalTranscript
+	//	*StreamingRecognizeResponse_ControlAck
+	//	*StreamingRecognizeResponse_Error
+	Result        isStreamingRecognizeResponse_Result `protobuf_oneof:"result"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamingRecognizeResponse) Reset() {
+	*x = StreamingRecognizeResponse{}
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamingRecognizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamingRecognizeResponse) ProtoMessage() {}
+
+func (x *StreamingRecognizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamingRecognizeResponse.ProtoReflect.Descriptor instead.
+func (*StreamingRecognizeResponse) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_asr_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StreamingRecognizeResponse) GetUtteranceId() string {
+	if x != nil {
+		return x.UtteranceId
+	}
+	return ""
+}
+
+func (x *StreamingRecognizeResponse) GetType() ResponseType {
+	if x != nil {
+		return x.Type
+	}
+	return ResponseType_RESPONSE_TYPE_UNSPECIFIED
+}
+
+func (x *StreamingRecognizeResponse) GetResult() isStreamingRecognizeResponse_Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *StreamingRecognizeResponse) GetPartialTranscript() *Transcript {
+	if x != nil {
+		if x, ok := x.Result.(*StreamingRecognizeResponse_PartialTranscript); ok {
+			return x.PartialTranscript
+		}
+	}
+	return nil
+}
+
+func (x *StreamingRecognizeResponse) GetFinalTranscript() *Transcript {
+	if x != nil {
+		if x, ok := x.Result.(*StreamingRecognizeResponse_FinalTranscript); ok {
+			return x.FinalTranscript
+		}
+	}
+	return nil
+}
+
+func (x *StreamingRecognizeResponse) GetControlAck() *ControlAck {
+	if x != nil {
+		if x, ok := x.Result.(*StreamingRecognizeResponse_ControlAck); ok {
+			return x.ControlAck
+		}
+	}
+	return nil
+}
+
+func (x *StreamingRecognizeResponse) GetError() *AsrError {
+	if x != nil {
+		if x, ok := x.Result.(*StreamingRecognizeResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+type isStreamingRecognizeResponse_Result interface {
+	isStreamingRecognizeResponse_Result()
+}
+
+type StreamingRecognizeResponse_PartialTranscript struct {
+	// The partial transcript.
+	PartialTranscript *Transcript `protobuf:"bytes,10,opt,name=partial_transcript,json=partialTranscript,proto3,oneof"`
+}
+
+type StreamingRecognizeResponse_FinalTranscript struct {
+	// The final transcript.
+	FinalTranscript *Transcript `protobuf:"bytes,11,opt,name=final_transcript,json=finalTranscript,proto3,oneof"`
+}
+
+type StreamingRecognizeResponse_ControlAck struct {
+	// The control acknowledgment.
+	ControlAck *ControlAck `protobuf:"bytes,12,opt,name=control_ack,json=controlAck,proto3,oneof"`
+}
+
+type StreamingRecognizeResponse_Error struct {
+	// The error.
+	Error *AsrError `protobuf:"bytes,13,opt,name=error,proto3,oneof"`
+}
+
+func (*StreamingRecognizeResponse_PartialTranscript) isStreamingRecognizeResponse_Result() {}
+
+func (*StreamingRecognizeResponse_FinalTranscript) isStreamingRecognizeResponse_Result() {}
+
+func (*StreamingRecognizeResponse_ControlAck) isStreamingRecognizeResponse_Result() {}
+
+func (*StreamingRecognizeResponse_Error) isStreamingRecognizeResponse_Result() {}
+
+// Transcript is a transcript of the audio.
+type Transcript struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The recognized text.
+	Text string `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	// Stability of a partial hypothesis in [0, 1]; 1 means it will not change.
+	// Finals always report 1. Zero means the engine did not estimate it.
+	Stability float32 `protobuf:"fixed32,2,opt,name=stability,proto3" json:"stability,omitempty"`
+	// Per-word alignment, when the engine produces it.
+	Words []*Word `protobuf:"bytes,3,rep,name=words,proto3" json:"words,omitempty"`
+	// Span of the utterance. Times are offsets from the start of the audio
+	// sent on this stream.
+	Start *durationpb.Duration `protobuf:"bytes,4,opt,name=start,proto3" json:"start,omitempty"`
+	End   *durationpb.Duration `protobuf:"bytes,5,opt,name=end,proto3" json:"end,omitempty"`
+	// Confidence of the whole hypothesis in [0, 1]; zero if unknown.
+	Confidence    float32 `protobuf:"fixed32,6,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transcript) Reset() {
+	*x = Transcript{}
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transcript) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transcript) ProtoMessage() {}
+
+func (x *Transcript) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transcript.ProtoReflect.Descriptor instead.
+func (*Transcript) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_asr_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Transcript) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Transcript) GetStability() float32 {
+	if x != nil {
+		return x.Stability
+	}
+	return 0
+}
+
+func (x *Transcript) GetWords() []*Word {
+	if x != nil {
+		return x.Words
+	}
+	return nil
+}
+
+func (x *Transcript) GetStart() *durationpb.Duration {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *Transcript) GetEnd() *durationpb.Duration {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *Transcript) GetConfidence() float32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+// Word is one recognized word with its alignment.
+type Word struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The word as it appears in the transcript text.
+	Text string `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	// Span of the word, as offsets from the start of the stream's audio.
+	Start *durationpb.Duration `protobuf:"bytes,2,opt,name=start,proto3" json:"start,omitempty"`
+	End   *durationpb.Duration `protobuf:"bytes,3,opt,name=end,proto3" json:"end,omitempty"`
+	// Confidence in [0, 1]; zero if unknown.
+	Confidence    float32 `protobuf:"fixed32,4,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Word) Reset() {
+	*x = Word{}
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Word) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Word) ProtoMessage() {}
+
+func (x *Word) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Word.ProtoReflect.Descriptor instead.
+func (*Word) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_asr_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Word) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Word) GetStart() *durationpb.Duration {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *Word) GetEnd() *durationpb.Duration {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *Word) GetConfidence() float32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+// ControlAck is an acknowledgment of a control message.
+type ControlAck struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The type of control message.
+	Type ControlType `protobuf:"varint,1,opt,name=type,proto3,enum=voxa.speech.v1.ControlType" json:"type,omitempty"`
+	// The message.
+	Message       string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ControlAck) Reset() {
+	*x = ControlAck{}
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ControlAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ControlAck) ProtoMessage() {}
+
+func (x *ControlAck) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ControlAck.ProtoReflect.Descriptor instead.
+func (*ControlAck) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_asr_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ControlAck) GetType() ControlType {
+	if x != nil {
+		return x.Type
+	}
+	return ControlType_CONTROL_TYPE_UNSPECIFIED
+}
+
+func (x *ControlAck) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// AsrError is an error response.
+// Map to gRPC status codes where possible.
+type AsrError struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The error code.
+	Code int32 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	// The error message.
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// The error details.
+	Details       string `protobuf:"bytes,3,opt,name=details,proto3" json:"details,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AsrError) Reset() {
+	*x = AsrError{}
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AsrError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AsrError) ProtoMessage() {}
+
+func (x *AsrError) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_asr_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AsrError.ProtoReflect.Descriptor instead.
+func (*AsrError) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_asr_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AsrError) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *AsrError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *AsrError) GetDetails() string {
+	if x != nil {
+		return x.Details
+	}
+	return ""
+}
+
+var File_voxa_speech_v1_asr_proto protoreflect.FileDescriptor
+
+const file_voxa_speech_v1_asr_proto_rawDesc = "" +
+	"\n" +
+	"\x18voxa/speech/v1/asr.proto\x12\x0evoxa.speech.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1avoxa/speech/v1/audio.proto\"\xb5\x01\n" +
+	"\x19StreamingRecognizeRequest\x12!\n" +
+	"\futterance_id\x18\x01 \x01(\tR\vutteranceId\x126\n" +
+	"\acontrol\x18\n" +
+	" \x01(\v2\x1a.voxa.speech.v1.ASRControlH\x00R\acontrol\x122\n" +
+	"\x05audio\x18\v \x01(\v2\x1a.voxa.speech.v1.AudioChunkH\x00R\x05audioB\t\n" +
+	"\apayload\"=\n" +
+	"\n" +
+	"ASRControl\x12/\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1b.voxa.speech.v1.ControlTypeR\x04type\"\x82\x03\n" +
+	"\x1aStreamingRecognizeResponse\x12!\n" +
+	"\futterance_id\x18\x01 \x01(\tR\vutteranceId\x120\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x1c.voxa.speech.v1.ResponseTypeR\x04type\x12K\n" +
+	"\x12partial_transcript\x18\n" +
+	" \x01(\v2\x1a.voxa.speech.v1.TranscriptH\x00R\x11partialTranscript\x12G\n" +
+	"\x10final_transcript\x18\v \x01(\v2\x1a.voxa.speech.v1.TranscriptH\x00R\x0ffinalTranscript\x12=\n" +
+	"\vcontrol_ack\x18\f \x01(\v2\x1a.voxa.speech.v1.ControlAckH\x00R\n" +
+	"controlAck\x120\n" +
+	"\x05error\x18\r \x01(\v2\x18.voxa.speech.v1.AsrErrorH\x00R\x05errorB\b\n" +
+	"\x06result\"\xe8\x01\n" +
+	"\n" +
+	"Transcript\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12\x1c\n" +
+	"\tstability\x18\x02 \x01(\x02R\tstability\x12*\n" +
+	"\x05words\x18\x03 \x03(\v2\x14.voxa.speech.v1.WordR\x05words\x12/\n" +
+	"\x05start\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x05start\x12+\n" +
+	"\x03end\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x03end\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x06 \x01(\x02R\n" +
+	"confidence\"\x98\x01\n" +
+	"\x04Word\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12/\n" +
+	"\x05start\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x05start\x12+\n" +
+	"\x03end\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x03end\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x04 \x01(\x02R\n" +
+	"confidence\"W\n" +
+	"\n" +
+	"ControlAck\x12/\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1b.voxa.speech.v1.ControlTypeR\x04type\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"R\n" +
+	"\bAsrError\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x18\n" +
+	"\adetails\x18\x03 \x01(\tR\adetails*J\n" +
+	"\vControlType\x12\x1c\n" +
+	"\x18CONTROL_TYPE_UNSPECIFIED\x10\x00\x12\t\n" +
+	"\x05FLUSH\x10\x01\x12\t\n" +
+	"\x05RESET\x10\x02\x12\a\n" +
+	"\x03END\x10\x03*a\n" +
+	"\fResponseType\x12\x1d\n" +
+	"\x19RESPONSE_TYPE_UNSPECIFIED\x10\x00\x12\v\n" +
+	"\aPARTIAL\x10\x01\x12\t\n" +
+	"\x05FINAL\x10\x02\x12\x0f\n" +
+	"\vCONTROL_ACK\x10\x03\x12\t\n" +
+	"\x05ERROR\x10\x042v\n" +
+	"\x03Asr\x12o\n" +
+	"\x12StreamingRecognize\x12).voxa.speech.v1.StreamingRecognizeRequest\x1a*.voxa.speech.v1.StreamingRecognizeResponse(\x010\x01BZ\n" +
+	"\x12com.voxa.speech.v1B\bAsrProtoP\x01Z8github.com/jmarc101/voxa/api/gen/voxa/speech/v1;speechv1b\x06proto3"
+
+var (
+	file_voxa_speech_v1_asr_proto_rawDescOnce sync.Once
+	file_voxa_speech_v1_asr_proto_rawDescData []byte
+)
+
+func file_voxa_speech_v1_asr_proto_rawDescGZIP() []byte {
+	file_voxa_speech_v1_asr_proto_rawDescOnce.Do(func() {
+		file_voxa_speech_v1_asr_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_voxa_speech_v1_asr_proto_rawDesc), len(file_voxa_speech_v1_asr_proto_rawDesc)))
+	})
+	return file_voxa_speech_v1_asr_proto_rawDescData
+}
+
+var file_voxa_speech_v1_asr_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_voxa_speech_v1_asr_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_voxa_speech_v1_asr_proto_goTypes = []any{
+	(ControlType)(0),                   // 0: voxa.speech.v1.ControlType
+	(ResponseType)(0),                  // 1: voxa.speech.v1.ResponseType
+	(*StreamingRecognizeRequest)(nil),  // 2: voxa.speech.v1.StreamingRecognizeRequest
+	(*ASRControl)(nil),                 // 3: voxa.speech.v1.ASRControl
+	(*StreamingRecognizeResponse)(nil), // 4: voxa.speech.v1.StreamingRecognizeResponse
+	(*Transcript)(nil),                 // 5: voxa.speech.v1.Transcript
+	(*Word)(nil),                       // 6: voxa.speech.v1.Word
+	(*ControlAck)(nil),                 // 7: voxa.speech.v1.ControlAck
+	(*AsrError)(nil),                   // 8: voxa.speech.v1.AsrError
+	(*AudioChunk)(nil),                 // 9: voxa.speech.v1.AudioChunk
+	(*durationpb.Duration)(nil),        // 10: google.protobuf.Duration
+}
+var file_voxa_speech_v1_asr_proto_depIdxs = []int32{
+	3,  // 0: voxa.speech.v1.StreamingRecognizeRequest.control:type_name -> voxa.speech.v1.ASRControl
+	9,  // 1: voxa.speech.v1.StreamingRecognizeRequest.audio:type_name -> voxa.speech.v1.AudioChunk
+	0,  // 2: voxa.speech.v1.ASRControl.type:type_name -> voxa.speech.v1.ControlType
+	1,  // 3: voxa.speech.v1.StreamingRecognizeResponse.type:type_name -> voxa.speech.v1.ResponseType
+	5,  // 4: voxa.speech.v1.StreamingRecognizeResponse.partial_transcript:type_name -> voxa.speech.v1.Transcript
+	5,  // 5: voxa.speech.v1.StreamingRecognizeResponse.final_transcript:type_name -> voxa.speech.v1.Transcript
+	7,  // 6: voxa.speech.v1.StreamingRecognizeResponse.control_ack:type_name -> voxa.speech.v1.ControlAck
+	8,  // 7: voxa.speech.v1.StreamingRecognizeResponse.error:type_name -> voxa.speech.v1.AsrError
+	6,  // 8: voxa.speech.v1.Transcript.words:type_name -> voxa.speech.v1.Word
+	10, // 9: voxa.speech.v1.Transcript.start:type_name -> google.protobuf.Duration
+	10, // 10: voxa.speech.v1.Transcript.end:type_name -> google.protobuf.Duration
+	10, // 11: voxa.speech.v1.Word.start:type_name -> google.protobuf.Duration
+	10, // 12: voxa.speech.v1.Word.end:type_name -> google.protobuf.Duration
+	0,  // 13: voxa.speech.v1.ControlAck.type:type_name -> voxa.speech.v1.ControlType
+	2,  // 14: voxa.speech.v1.Asr.StreamingRecognize:input_type -> voxa.speech.v1.StreamingRecognizeRequest
+	4,  // 15: voxa.speech.v1.Asr.StreamingRecognize:output_type -> voxa.speech.v1.StreamingRecognizeResponse
+	15, // [15:16] is the sub-list for method output_type
+	14, // [14:15] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_voxa_speech_v1_asr_proto_init() }
+func file_voxa_speech_v1_asr_proto_init() {
+	if File_voxa_speech_v1_asr_proto != nil {
+		return
+	}
+	file_voxa_speech_v1_audio_proto_init()
+	file_voxa_speech_v1_asr_proto_msgTypes[0].OneofWrappers = []any{
+		(*StreamingRecognizeRequest_Control)(nil),
+		(*StreamingRecognizeRequest_Audio)(nil),
+	}
+	file_voxa_speech_v1_asr_proto_msgTypes[2].OneofWrappers = []any{
+		(*StreamingRecognizeResponse_PartialTranscript)(nil),
+		(*StreamingRecognizeResponse_FinalTranscript)(nil),
+		(*StreamingRecognizeResponse_ControlAck)(nil),
+		(*StreamingRecognizeResponse_Error)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_voxa_speech_v1_asr_proto_rawDesc), len(file_voxa_speech_v1_asr_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_voxa_speech_v1_asr_proto_goTypes,
+		DependencyIndexes: file_voxa_speech_v1_asr_proto_depIdxs,
+		EnumInfos:         file_voxa_speech_v1_asr_proto_enumTypes,
+		MessageInfos:      file_voxa_speech_v1_asr_proto_msgTypes,
+	}.Build()
+	File_voxa_speech_v1_asr_proto = out.File
+	file_voxa_speech_v1_asr_proto_goTypes = nil
+	file_voxa_speech_v1_asr_proto_depIdxs = nil
+}
@@ -0,0 +1,123 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.26.1
+// source: voxa/speech/v1/asr.proto
+
+package speechv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Asr_StreamingRecognize_FullMethodName = "/voxa.speech.v1.Asr/StreamingRecognize"
+)
+
+// AsrClient is the client API for Asr service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Ultra-minimal PoC: bi‑di streaming ASR with only audio chunks and basic control.
+// Assumptions: PCM16, mono, fixed sample rate known to both sides out of band.
+type AsrClient interface {
+	// StreamingRecognize is a streaming RPC that allows the client to send audio chunks and receive transcripts.
+	StreamingRecognize(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamingRecognizeRequest, StreamingRecognizeResponse], error)
+}
+
+type asrClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAsrClient(cc grpc.ClientConnInterface) AsrClient {
+	return &asrClient{cc}
+}
+
+func (c *asrClient) StreamingRecognize(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamingRecognizeRequest, StreamingRecognizeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Asr_ServiceDesc.Streams[0], Asr_StreamingRecognize_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamingRecognizeRequest, StreamingRecognizeResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Asr_StreamingRecognizeClient = grpc.BidiStreamingClient[StreamingRecognizeRequest, StreamingRecognizeResponse]
+
+// AsrServer is the server API for Asr service.
+// All implementations must embed UnimplementedAsrServer
+// for forward compatibility.
+//
+// Ultra-minimal PoC: bi‑di streaming ASR with only audio chunks and basic control.
+// Assumptions: PCM16, mono, fixed sample rate known to both sides out of band.
+type AsrServer interface {
+	// StreamingRecognize is a streaming RPC that allows the client to send audio chunks and receive transcripts.
+	StreamingRecognize(grpc.BidiStreamingServer[StreamingRecognizeRequest, StreamingRecognizeResponse]) error
+	mustEmbedUnimplementedAsrServer()
+}
+
+// UnimplementedAsrServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAsrServer struct{}
+
+func (UnimplementedAsrServer) StreamingRecognize(grpc.BidiStreamingServer[StreamingRecognizeRequest, StreamingRecognizeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamingRecognize not implemented")
+}
+func (UnimplementedAsrServer) mustEmbedUnimplementedAsrServer() {}
+func (UnimplementedAsrServer) testEmbeddedByValue()             {}
+
+// UnsafeAsrServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AsrServer will
+// result in compilation errors.
+type UnsafeAsrServer interface {
+	mustEmbedUnimplementedAsrServer()
+}
+
+func RegisterAsrServer(s grpc.ServiceRegistrar, srv AsrServer) {
+	// If the following call pancis, it indicates UnimplementedAsrServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Asr_ServiceDesc, srv)
+}
+
+func _Asr_StreamingRecognize_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AsrServer).StreamingRecognize(&grpc.GenericServerStream[StreamingRecognizeRequest, StreamingRecognizeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Asr_StreamingRecognizeServer = grpc.BidiStreamingServer[StreamingRecognizeRequest, StreamingRecognizeResponse]
+
+// Asr_ServiceDesc is the grpc.ServiceDesc for Asr service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Asr_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "voxa.speech.v1.Asr",
+	HandlerType: (*AsrServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamingRecognize",
+			Handler:       _Asr_StreamingRecognize_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "voxa/speech/v1/asr.proto",
+}
@@ -0,0 +1,219 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v5.26.1
+// source: voxa/speech/v1/audio.proto
+
+package speechv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// AudioChunk is a chunk of audio data.
+type AudioChunk struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The utterance ID.
+	UtteranceId string `protobuf:"bytes,1,opt,name=utterance_id,json=utteranceId,proto3" json:"utterance_id,omitempty"`
+	// The sequence number.
+	Seq int64 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	// The audio payload for this frame.
+	Data []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	// The emit time.
+	EmitTime      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=emit_time,json=emitTime,proto3" json:"emit_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AudioChunk) Reset() {
+	*x = AudioChunk{}
+	mi := &file_voxa_speech_v1_audio_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AudioChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AudioChunk) ProtoMessage() {}
+
+func (x *AudioChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_audio_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AudioChunk.ProtoReflect.Descriptor instead.
+func (*AudioChunk) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_audio_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AudioChunk) GetUtteranceId() string {
+	if x != nil {
+		return x.UtteranceId
+	}
+	return ""
+}
+
+func (x *AudioChunk) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *AudioChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *AudioChunk) GetEmitTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EmitTime
+	}
+	return nil
+}
+
+// AudioData is a complete audio buffer.
+type AudioData struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The utterance ID.
+	UtteranceId string `protobuf:"bytes,1,opt,name=utterance_id,json=utteranceId,proto3" json:"utterance_id,omitempty"`
+	// The audio buffer.
+	Data          []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AudioData) Reset() {
+	*x = AudioData{}
+	mi := &file_voxa_speech_v1_audio_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AudioData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AudioData) ProtoMessage() {}
+
+func (x *AudioData) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_audio_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AudioData.ProtoReflect.Descriptor instead.
+func (*AudioData) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_audio_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AudioData) GetUtteranceId() string {
+	if x != nil {
+		return x.UtteranceId
+	}
+	return ""
+}
+
+func (x *AudioData) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_voxa_speech_v1_audio_proto protoreflect.FileDescriptor
+
+const file_voxa_speech_v1_audio_proto_rawDesc = "" +
+	"\n" +
+	"\x1avoxa/speech/v1/audio.proto\x12\x0evoxa.speech.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8e\x01\n" +
+	"\n" +
+	"AudioChunk\x12!\n" +
+	"\futterance_id\x18\x01 \x01(\tR\vutteranceId\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x03R\x03seq\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x127\n" +
+	"\temit_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\bemitTime\"B\n" +
+	"\tAudioData\x12!\n" +
+	"\futterance_id\x18\x01 \x01(\tR\vutteranceId\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04dataB\\\n" +
+	"\x12com.voxa.speech.v1B\n" +
+	"AudioProtoP\x01Z8github.com/jmarc101/voxa/api/gen/voxa/speech/v1;speechv1b\x06proto3"
+
+var (
+	file_voxa_speech_v1_audio_proto_rawDescOnce sync.Once
+	file_voxa_speech_v1_audio_proto_rawDescData []byte
+)
+
+func file_voxa_speech_v1_audio_proto_rawDescGZIP() []byte {
+	file_voxa_speech_v1_audio_proto_rawDescOnce.Do(func() {
+		file_voxa_speech_v1_audio_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_voxa_speech_v1_audio_proto_rawDesc), len(file_voxa_speech_v1_audio_proto_rawDesc)))
+	})
+	return file_voxa_speech_v1_audio_proto_rawDescData
+}
+
+var file_voxa_speech_v1_audio_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_voxa_speech_v1_audio_proto_goTypes = []any{
+	(*AudioChunk)(nil),            // 0: voxa.speech.v1.AudioChunk
+	(*AudioData)(nil),             // 1: voxa.speech.v1.AudioData
+	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+}
+var file_voxa_speech_v1_audio_proto_depIdxs = []int32{
+	2, // 0: voxa.speech.v1.AudioChunk.emit_time:type_name -> google.protobuf.Timestamp
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_voxa_speech_v1_audio_proto_init() }
+func file_voxa_speech_v1_audio_proto_init() {
+	if File_voxa_speech_v1_audio_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_voxa_speech_v1_audio_proto_rawDesc), len(file_voxa_speech_v1_audio_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_voxa_speech_v1_audio_proto_goTypes,
+		DependencyIndexes: file_voxa_speech_v1_audio_proto_depIdxs,
+		MessageInfos:      file_voxa_speech_v1_audio_proto_msgTypes,
+	}.Build()
+	File_voxa_speech_v1_audio_proto = out.File
+	file_voxa_speech_v1_audio_proto_goTypes = nil
+	file_voxa_speech_v1_audio_proto_depIdxs = nil
+}
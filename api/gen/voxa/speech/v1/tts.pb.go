@@ -0,0 +1,146 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v5.26.1
+// source: voxa/speech/v1/tts.proto
+
+package speechv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SynthesisRequest is a request to synthesize text.
+type SynthesisRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Utterance ID for correlating logs/playback.
+	UtteranceId string `protobuf:"bytes,1,opt,name=utterance_id,json=utteranceId,proto3" json:"utterance_id,omitempty"`
+	// Text to synthesize (plain text for PoC; SSML/voice params can be added later).
+	Text          string `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SynthesisRequest) Reset() {
+	*x = SynthesisRequest{}
+	mi := &file_voxa_speech_v1_tts_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SynthesisRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SynthesisRequest) ProtoMessage() {}
+
+func (x *SynthesisRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_speech_v1_tts_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SynthesisRequest.ProtoReflect.Descriptor instead.
+func (*SynthesisRequest) Descriptor() ([]byte, []int) {
+	return file_voxa_speech_v1_tts_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SynthesisRequest) GetUtteranceId() string {
+	if x != nil {
+		return x.UtteranceId
+	}
+	return ""
+}
+
+func (x *SynthesisRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+var File_voxa_speech_v1_tts_proto protoreflect.FileDescriptor
+
+const file_voxa_speech_v1_tts_proto_rawDesc = "" +
+	"\n" +
+	"\x18voxa/speech/v1/tts.proto\x12\x0evoxa.speech.v1\x1a\x1avoxa/speech/v1/audio.proto\"I\n" +
+	"\x10SynthesisRequest\x12!\n" +
+	"\futterance_id\x18\x01 \x01(\tR\vutteranceId\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text2\xa3\x01\n" +
+	"\x03Tts\x12L\n" +
+	"\n" +
+	"Synthesize\x12 .voxa.speech.v1.SynthesisRequest\x1a\x1a.voxa.speech.v1.AudioChunk0\x01\x12N\n" +
+	"\x0fSynthesizeUnary\x12 .voxa.speech.v1.SynthesisRequest\x1a\x19.voxa.speech.v1.AudioDataBZ\n" +
+	"\x12com.voxa.speech.v1B\bTtsProtoP\x01Z8github.com/jmarc101/voxa/api/gen/voxa/speech/v1;speechv1b\x06proto3"
+
+var (
+	file_voxa_speech_v1_tts_proto_rawDescOnce sync.Once
+	file_voxa_speech_v1_tts_proto_rawDescData []byte
+)
+
+func file_voxa_speech_v1_tts_proto_rawDescGZIP() []byte {
+	file_voxa_speech_v1_tts_proto_rawDescOnce.Do(func() {
+		file_voxa_speech_v1_tts_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_voxa_speech_v1_tts_proto_rawDesc), len(file_voxa_speech_v1_tts_proto_rawDesc)))
+	})
+	return file_voxa_speech_v1_tts_proto_rawDescData
+}
+
+var file_voxa_speech_v1_tts_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_voxa_speech_v1_tts_proto_goTypes = []any{
+	(*SynthesisRequest)(nil), // 0: voxa.speech.v1.SynthesisRequest
+	(*AudioChunk)(nil),       // 1: voxa.speech.v1.AudioChunk
+	(*AudioData)(nil),        // 2: voxa.speech.v1.AudioData
+}
+var file_voxa_speech_v1_tts_proto_depIdxs = []int32{
+	0, // 0: voxa.speech.v1.Tts.Synthesize:input_type -> voxa.speech.v1.SynthesisRequest
+	0, // 1: voxa.speech.v1.Tts.SynthesizeUnary:input_type -> voxa.speech.v1.SynthesisRequest
+	1, // 2: voxa.speech.v1.Tts.Synthesize:output_type -> voxa.speech.v1.AudioChunk
+	2, // 3: voxa.speech.v1.Tts.SynthesizeUnary:output_type -> voxa.speech.v1.AudioData
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_voxa_speech_v1_tts_proto_init() }
+func file_voxa_speech_v1_tts_proto_init() {
+	if File_voxa_speech_v1_tts_proto != nil {
+		return
+	}
+	file_voxa_speech_v1_audio_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_voxa_speech_v1_tts_proto_rawDesc), len(file_voxa_speech_v1_tts_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_voxa_speech_v1_tts_proto_goTypes,
+		DependencyIndexes: file_voxa_speech_v1_tts_proto_depIdxs,
+		MessageInfos:      file_voxa_speech_v1_tts_proto_msgTypes,
+	}.Build()
+	File_voxa_speech_v1_tts_proto = out.File
+	file_voxa_speech_v1_tts_proto_goTypes = nil
+	file_voxa_speech_v1_tts_proto_depIdxs = nil
+}
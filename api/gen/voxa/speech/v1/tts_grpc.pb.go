@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.26.1
+// source: voxa/speech/v1/tts.proto
+
+package speechv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Tts_Synthesize_FullMethodName      = "/voxa.speech.v1.Tts/Synthesize"
+	Tts_SynthesizeUnary_FullMethodName = "/voxa.speech.v1.Tts/SynthesizeUnary"
+)
+
+// TtsClient is the client API for Tts service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Text-to-speech service.
+// Assumptions: PCM16, mono, fixed sample rate known out of band.
+type TtsClient interface {
+	// Synthesize: stream audio chunks as soon as they are synthesized.
+	Synthesize(ctx context.Context, in *SynthesisRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AudioChunk], error)
+	// SynthesizeUnary: returns the whole audio buffer.
+	SynthesizeUnary(ctx context.Context, in *SynthesisRequest, opts ...grpc.CallOption) (*AudioData, error)
+}
+
+type ttsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTtsClient(cc grpc.ClientConnInterface) TtsClient {
+	return &ttsClient{cc}
+}
+
+func (c *ttsClient) Synthesize(ctx context.Context, in *SynthesisRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AudioChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Tts_ServiceDesc.Streams[0], Tts_Synthesize_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SynthesisRequest, AudioChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Tts_SynthesizeClient = grpc.ServerStreamingClient[AudioChunk]
+
+func (c *ttsClient) SynthesizeUnary(ctx context.Context, in *SynthesisRequest, opts ...grpc.CallOption) (*AudioData, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AudioData)
+	err := c.cc.Invoke(ctx, Tts_SynthesizeUnary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TtsServer is the server API for Tts service.
+// All implementations must embed UnimplementedTtsServer
+// for forward compatibility.
+//
+// Text-to-speech service.
+// Assumptions: PCM16, mono, fixed sample rate known out of band.
+type TtsServer interface {
+	// Synthesize: stream audio chunks as soon as they are synthesized.
+	Synthesize(*SynthesisRequest, grpc.ServerStreamingServer[AudioChunk]) error
+	// SynthesizeUnary: returns the whole audio buffer.
+	SynthesizeUnary(context.Context, *SynthesisRequest) (*AudioData, error)
+	mustEmbedUnimplementedTtsServer()
+}
+
+// UnimplementedTtsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTtsServer struct{}
+
+func (UnimplementedTtsServer) Synthesize(*SynthesisRequest, grpc.ServerStreamingServer[AudioChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Synthesize not implemented")
+}
+func (UnimplementedTtsServer) SynthesizeUnary(context.Context, *SynthesisRequest) (*AudioData, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SynthesizeUnary not implemented")
+}
+func (UnimplementedTtsServer) mustEmbedUnimplementedTtsServer() {}
+func (UnimplementedTtsServer) testEmbeddedByValue()             {}
+
+// UnsafeTtsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TtsServer will
+// result in compilation errors.
+type UnsafeTtsServer interface {
+	mustEmbedUnimplementedTtsServer()
+}
+
+func RegisterTtsServer(s grpc.ServiceRegistrar, srv TtsServer) {
+	// If the following call pancis, it indicates UnimplementedTtsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Tts_ServiceDesc, srv)
+}
+
+func _Tts_Synthesize_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SynthesisRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TtsServer).Synthesize(m, &grpc.GenericServerStream[SynthesisRequest, AudioChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Tts_SynthesizeServer = grpc.ServerStreamingServer[AudioChunk]
+
+func _Tts_SynthesizeUnary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SynthesisRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TtsServer).SynthesizeUnary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tts_SynthesizeUnary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TtsServer).SynthesizeUnary(ctx, req.(*SynthesisRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Tts_ServiceDesc is the grpc.ServiceDesc for Tts service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Tts_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "voxa.speech.v1.Tts",
+	HandlerType: (*TtsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SynthesizeUnary",
+			Handler:    _Tts_SynthesizeUnary_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Synthesize",
+			Handler:       _Tts_Synthesize_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "voxa/speech/v1/tts.proto",
+}
@@ -0,0 +1,610 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v5.26.1
+// source: voxa/voxad/v1/events.proto
+
+package voxadv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// EventType is the type of an Event.
+type EventType int32
+
+const (
+	// The event type is unspecified.
+	EventType_EVENT_TYPE_UNSPECIFIED EventType = 0
+	// A wake word was heard.
+	EventType_WAKE_WORD EventType = 1
+	// A final segment, once translated, parsed for intents and stored.
+	EventType_FINAL EventType = 2
+	// An intent recognized in a final segment, published before it.
+	EventType_INTENT EventType = 3
+	// A partial segment.
+	EventType_PARTIAL EventType = 4
+	// The session started.
+	EventType_SESSION_START EventType = 5
+	// The session ended, after its last segment.
+	EventType_SESSION_END EventType = 6
+	// An alert rule matched a segment.
+	EventType_ALERT EventType = 7
+	// A telephone keypad key was pressed.
+	EventType_DTMF EventType = 8
+	// Answering machine detection decided who answered a call.
+	EventType_AMD EventType = 9
+	// The transcription of the session was deferred, every recognizer
+	// provider being down.
+	EventType_DEFERRED EventType = 10
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0:  "EVENT_TYPE_UNSPECIFIED",
+		1:  "WAKE_WORD",
+		2:  "FINAL",
+		3:  "INTENT",
+		4:  "PARTIAL",
+		5:  "SESSION_START",
+		6:  "SESSION_END",
+		7:  "ALERT",
+		8:  "DTMF",
+		9:  "AMD",
+		10: "DEFERRED",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_TYPE_UNSPECIFIED": 0,
+		"WAKE_WORD":              1,
+		"FINAL":                  2,
+		"INTENT":                 3,
+		"PARTIAL":                4,
+		"SESSION_START":          5,
+		"SESSION_END":            6,
+		"ALERT":                  7,
+		"DTMF":                   8,
+		"AMD":                    9,
+		"DEFERRED":               10,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_voxa_voxad_v1_events_proto_enumTypes[0].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_voxa_voxad_v1_events_proto_enumTypes[0]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_events_proto_rawDescGZIP(), []int{0}
+}
+
+// ========================= Events =========================
+// Event is something that happened in a session, as published to message
+// brokers by the event sinks. Events of one session share its ID as their
+// key and are published in order.
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The type of event; it says which of the fields below are set.
+	Type EventType `protobuf:"varint,1,opt,name=type,proto3,enum=voxa.voxad.v1.EventType" json:"type,omitempty"`
+	// The session the event belongs to.
+	SessionId string `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// When the event happened.
+	Time *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	// Set for WAKE_WORD.
+	WakeWord *WakeWord `protobuf:"bytes,4,opt,name=wake_word,json=wakeWord,proto3" json:"wake_word,omitempty"`
+	// Set for PARTIAL and FINAL, and for INTENT and ALERT to the segment
+	// the intent was recognized in or the rule matched.
+	Segment *Segment `protobuf:"bytes,5,opt,name=segment,proto3" json:"segment,omitempty"`
+	// Set for INTENT.
+	Intent *Intent `protobuf:"bytes,6,opt,name=intent,proto3" json:"intent,omitempty"`
+	// Set for SESSION_END if the session failed, and for DEFERRED to why
+	// the recognizer is down.
+	Error string `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	// Set for ALERT.
+	Alert *Alert `protobuf:"bytes,8,opt,name=alert,proto3" json:"alert,omitempty"`
+	// Set for DTMF.
+	Dtmf *Dtmf `protobuf:"bytes,9,opt,name=dtmf,proto3" json:"dtmf,omitempty"`
+	// Set for AMD.
+	Amd           *Amd `protobuf:"bytes,10,opt,name=amd,proto3" json:"amd,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_events_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Event) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_EVENT_TYPE_UNSPECIFIED
+}
+
+func (x *Event) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Event) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Event) GetWakeWord() *WakeWord {
+	if x != nil {
+		return x.WakeWord
+	}
+	return nil
+}
+
+func (x *Event) GetSegment() *Segment {
+	if x != nil {
+		return x.Segment
+	}
+	return nil
+}
+
+func (x *Event) GetIntent() *Intent {
+	if x != nil {
+		return x.Intent
+	}
+	return nil
+}
+
+func (x *Event) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Event) GetAlert() *Alert {
+	if x != nil {
+		return x.Alert
+	}
+	return nil
+}
+
+func (x *Event) GetDtmf() *Dtmf {
+	if x != nil {
+		return x.Dtmf
+	}
+	return nil
+}
+
+func (x *Event) GetAmd() *Amd {
+	if x != nil {
+		return x.Amd
+	}
+	return nil
+}
+
+// WakeWord is a detected wake word.
+type WakeWord struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The phrase heard.
+	Phrase string `protobuf:"bytes,1,opt,name=phrase,proto3" json:"phrase,omitempty"`
+	// Stream time at which the phrase ended.
+	Offset *durationpb.Duration `protobuf:"bytes,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Match quality in [0, 1].
+	Score         float64 `protobuf:"fixed64,3,opt,name=score,proto3" json:"score,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WakeWord) Reset() {
+	*x = WakeWord{}
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WakeWord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WakeWord) ProtoMessage() {}
+
+func (x *WakeWord) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WakeWord.ProtoReflect.Descriptor instead.
+func (*WakeWord) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_events_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WakeWord) GetPhrase() string {
+	if x != nil {
+		return x.Phrase
+	}
+	return ""
+}
+
+func (x *WakeWord) GetOffset() *durationpb.Duration {
+	if x != nil {
+		return x.Offset
+	}
+	return nil
+}
+
+func (x *WakeWord) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+// Dtmf is a telephone keypad key pressed, detected from its tones.
+type Dtmf struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// "0" to "9", "*", "#", or "A" to "D".
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// Stream time at which the tone started.
+	Offset        *durationpb.Duration `protobuf:"bytes,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Dtmf) Reset() {
+	*x = Dtmf{}
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Dtmf) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Dtmf) ProtoMessage() {}
+
+func (x *Dtmf) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Dtmf.ProtoReflect.Descriptor instead.
+func (*Dtmf) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_events_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Dtmf) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Dtmf) GetOffset() *durationpb.Duration {
+	if x != nil {
+		return x.Offset
+	}
+	return nil
+}
+
+// Amd is who answered a call, as answering machine detection decided.
+type Amd struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// "human", "machine", "music" or "unknown".
+	Result string `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	// What decided: "after_greeting_silence", "initial_silence",
+	// "long_greeting", "max_words", "music" or "timeout".
+	Reason string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Stream time at which the decision was made.
+	Offset *durationpb.Duration `protobuf:"bytes,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	// The words heard before it.
+	Words         int32 `protobuf:"varint,4,opt,name=words,proto3" json:"words,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Amd) Reset() {
+	*x = Amd{}
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Amd) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Amd) ProtoMessage() {}
+
+func (x *Amd) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Amd.ProtoReflect.Descriptor instead.
+func (*Amd) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_events_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Amd) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+func (x *Amd) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Amd) GetOffset() *durationpb.Duration {
+	if x != nil {
+		return x.Offset
+	}
+	return nil
+}
+
+func (x *Amd) GetWords() int32 {
+	if x != nil {
+		return x.Words
+	}
+	return 0
+}
+
+// Alert is an alert rule matching a segment.
+type Alert struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name of the rule.
+	Rule string `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	// The text it matched, as transcribed.
+	Match         string `protobuf:"bytes,2,opt,name=match,proto3" json:"match,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_events_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alert.ProtoReflect.Descriptor instead.
+func (*Alert) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_events_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Alert) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *Alert) GetMatch() string {
+	if x != nil {
+		return x.Match
+	}
+	return ""
+}
+
+var File_voxa_voxad_v1_events_proto protoreflect.FileDescriptor
+
+const file_voxa_voxad_v1_events_proto_rawDesc = "" +
+	"\n" +
+	"\x1avoxa/voxad/v1/events.proto\x12\rvoxa.voxad.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x19voxa/voxad/v1/voxad.proto\"\xac\x03\n" +
+	"\x05Event\x12,\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x18.voxa.voxad.v1.EventTypeR\x04type\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x12.\n" +
+	"\x04time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x124\n" +
+	"\twake_word\x18\x04 \x01(\v2\x17.voxa.voxad.v1.WakeWordR\bwakeWord\x120\n" +
+	"\asegment\x18\x05 \x01(\v2\x16.voxa.voxad.v1.SegmentR\asegment\x12-\n" +
+	"\x06intent\x18\x06 \x01(\v2\x15.voxa.voxad.v1.IntentR\x06intent\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\x12*\n" +
+	"\x05alert\x18\b \x01(\v2\x14.voxa.voxad.v1.AlertR\x05alert\x12'\n" +
+	"\x04dtmf\x18\t \x01(\v2\x13.voxa.voxad.v1.DtmfR\x04dtmf\x12$\n" +
+	"\x03amd\x18\n" +
+	" \x01(\v2\x12.voxa.voxad.v1.AmdR\x03amd\"k\n" +
+	"\bWakeWord\x12\x16\n" +
+	"\x06phrase\x18\x01 \x01(\tR\x06phrase\x121\n" +
+	"\x06offset\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x06offset\x12\x14\n" +
+	"\x05score\x18\x03 \x01(\x01R\x05score\"K\n" +
+	"\x04Dtmf\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x121\n" +
+	"\x06offset\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x06offset\"~\n" +
+	"\x03Amd\x12\x16\n" +
+	"\x06result\x18\x01 \x01(\tR\x06result\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x121\n" +
+	"\x06offset\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x06offset\x12\x14\n" +
+	"\x05words\x18\x04 \x01(\x05R\x05words\"1\n" +
+	"\x05Alert\x12\x12\n" +
+	"\x04rule\x18\x01 \x01(\tR\x04rule\x12\x14\n" +
+	"\x05match\x18\x02 \x01(\tR\x05match*\xaa\x01\n" +
+	"\tEventType\x12\x1a\n" +
+	"\x16EVENT_TYPE_UNSPECIFIED\x10\x00\x12\r\n" +
+	"\tWAKE_WORD\x10\x01\x12\t\n" +
+	"\x05FINAL\x10\x02\x12\n" +
+	"\n" +
+	"\x06INTENT\x10\x03\x12\v\n" +
+	"\aPARTIAL\x10\x04\x12\x11\n" +
+	"\rSESSION_START\x10\x05\x12\x0f\n" +
+	"\vSESSION_END\x10\x06\x12\t\n" +
+	"\x05ALERT\x10\a\x12\b\n" +
+	"\x04DTMF\x10\b\x12\a\n" +
+	"\x03AMD\x10\t\x12\f\n" +
+	"\bDEFERRED\x10\n" +
+	"BZ\n" +
+	"\x11com.voxa.voxad.v1B\vEventsProtoP\x01Z6github.com/jmarc101/voxa/api/gen/voxa/voxad/v1;voxadv1b\x06proto3"
+
+var (
+	file_voxa_voxad_v1_events_proto_rawDescOnce sync.Once
+	file_voxa_voxad_v1_events_proto_rawDescData []byte
+)
+
+func file_voxa_voxad_v1_events_proto_rawDescGZIP() []byte {
+	file_voxa_voxad_v1_events_proto_rawDescOnce.Do(func() {
+		file_voxa_voxad_v1_events_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_voxa_voxad_v1_events_proto_rawDesc), len(file_voxa_voxad_v1_events_proto_rawDesc)))
+	})
+	return file_voxa_voxad_v1_events_proto_rawDescData
+}
+
+var file_voxa_voxad_v1_events_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_voxa_voxad_v1_events_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_voxa_voxad_v1_events_proto_goTypes = []any{
+	(EventType)(0),                // 0: voxa.voxad.v1.EventType
+	(*Event)(nil),                 // 1: voxa.voxad.v1.Event
+	(*WakeWord)(nil),              // 2: voxa.voxad.v1.WakeWord
+	(*Dtmf)(nil),                  // 3: voxa.voxad.v1.Dtmf
+	(*Amd)(nil),                   // 4: voxa.voxad.v1.Amd
+	(*Alert)(nil),                 // 5: voxa.voxad.v1.Alert
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+	(*Segment)(nil),               // 7: voxa.voxad.v1.Segment
+	(*Intent)(nil),                // 8: voxa.voxad.v1.Intent
+	(*durationpb.Duration)(nil),   // 9: google.protobuf.Duration
+}
+var file_voxa_voxad_v1_events_proto_depIdxs = []int32{
+	0,  // 0: voxa.voxad.v1.Event.type:type_name -> voxa.voxad.v1.EventType
+	6,  // 1: voxa.voxad.v1.Event.time:type_name -> google.protobuf.Timestamp
+	2,  // 2: voxa.voxad.v1.Event.wake_word:type_name -> voxa.voxad.v1.WakeWord
+	7,  // 3: voxa.voxad.v1.Event.segment:type_name -> voxa.voxad.v1.Segment
+	8,  // 4: voxa.voxad.v1.Event.intent:type_name -> voxa.voxad.v1.Intent
+	5,  // 5: voxa.voxad.v1.Event.alert:type_name -> voxa.voxad.v1.Alert
+	3,  // 6: voxa.voxad.v1.Event.dtmf:type_name -> voxa.voxad.v1.Dtmf
+	4,  // 7: voxa.voxad.v1.Event.amd:type_name -> voxa.voxad.v1.Amd
+	9,  // 8: voxa.voxad.v1.WakeWord.offset:type_name -> google.protobuf.Duration
+	9,  // 9: voxa.voxad.v1.Dtmf.offset:type_name -> google.protobuf.Duration
+	9,  // 10: voxa.voxad.v1.Amd.offset:type_name -> google.protobuf.Duration
+	11, // [11:11] is the sub-list for method output_type
+	11, // [11:11] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_voxa_voxad_v1_events_proto_init() }
+func file_voxa_voxad_v1_events_proto_init() {
+	if File_voxa_voxad_v1_events_proto != nil {
+		return
+	}
+	file_voxa_voxad_v1_voxad_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_voxa_voxad_v1_events_proto_rawDesc), len(file_voxa_voxad_v1_events_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_voxa_voxad_v1_events_proto_goTypes,
+		DependencyIndexes: file_voxa_voxad_v1_events_proto_depIdxs,
+		EnumInfos:         file_voxa_voxad_v1_events_proto_enumTypes,
+		MessageInfos:      file_voxa_voxad_v1_events_proto_msgTypes,
+	}.Build()
+	File_voxa_voxad_v1_events_proto = out.File
+	file_voxa_voxad_v1_events_proto_goTypes = nil
+	file_voxa_voxad_v1_events_proto_depIdxs = nil
+}
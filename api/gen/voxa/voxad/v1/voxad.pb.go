@@ -0,0 +1,2533 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v5.26.1
+// source: voxa/voxad/v1/voxad.proto
+
+package voxadv1
+
+import (
+	v1 "github.com/jmarc101/voxa/api/gen/voxa/speech/v1"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Priority ranks a session's recognition against the other sessions'.
+type Priority int32
+
+const (
+	// Normal priority.
+	Priority_PRIORITY_UNSPECIFIED Priority = 0
+	// Someone is waiting on the transcript, as with a voice assistant; decoded
+	// ahead of the others.
+	Priority_PRIORITY_INTERACTIVE Priority = 1
+	// Offline work, such as re-transcription, decoded once no other session
+	// is waiting.
+	Priority_PRIORITY_BATCH Priority = 2
+)
+
+// Enum value maps for Priority.
+var (
+	Priority_name = map[int32]string{
+		0: "PRIORITY_UNSPECIFIED",
+		1: "PRIORITY_INTERACTIVE",
+		2: "PRIORITY_BATCH",
+	}
+	Priority_value = map[string]int32{
+		"PRIORITY_UNSPECIFIED": 0,
+		"PRIORITY_INTERACTIVE": 1,
+		"PRIORITY_BATCH":       2,
+	}
+)
+
+func (x Priority) Enum() *Priority {
+	p := new(Priority)
+	*p = x
+	return p
+}
+
+func (x Priority) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Priority) Descriptor() protoreflect.EnumDescriptor {
+	return file_voxa_voxad_v1_voxad_proto_enumTypes[0].Descriptor()
+}
+
+func (Priority) Type() protoreflect.EnumType {
+	return &file_voxa_voxad_v1_voxad_proto_enumTypes[0]
+}
+
+func (x Priority) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Priority.Descriptor instead.
+func (Priority) EnumDescriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{0}
+}
+
+// VadEventType is the type of voice activity transition.
+type VadEventType int32
+
+const (
+	// The event type is unspecified.
+	VadEventType_VAD_EVENT_TYPE_UNSPECIFIED VadEventType = 0
+	// Speech started.
+	VadEventType_SPEECH_START VadEventType = 1
+	// Speech ended.
+	VadEventType_SPEECH_END VadEventType = 2
+)
+
+// Enum value maps for VadEventType.
+var (
+	VadEventType_name = map[int32]string{
+		0: "VAD_EVENT_TYPE_UNSPECIFIED",
+		1: "SPEECH_START",
+		2: "SPEECH_END",
+	}
+	VadEventType_value = map[string]int32{
+		"VAD_EVENT_TYPE_UNSPECIFIED": 0,
+		"SPEECH_START":               1,
+		"SPEECH_END":                 2,
+	}
+)
+
+func (x VadEventType) Enum() *VadEventType {
+	p := new(VadEventType)
+	*p = x
+	return p
+}
+
+func (x VadEventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (VadEventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_voxa_voxad_v1_voxad_proto_enumTypes[1].Descriptor()
+}
+
+func (VadEventType) Type() protoreflect.EnumType {
+	return &file_voxa_voxad_v1_voxad_proto_enumTypes[1]
+}
+
+func (x VadEventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use VadEventType.Descriptor instead.
+func (VadEventType) EnumDescriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{1}
+}
+
+// ========================= Transcribe =========================
+type TranscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*TranscribeRequest_Config
+	//	*TranscribeRequest_Audio
+	//	*TranscribeRequest_Control
+	Payload       isTranscribeRequest_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscribeRequest) Reset() {
+	*x = TranscribeRequest{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscribeRequest) ProtoMessage() {}
+
+func (x *TranscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscribeRequest.ProtoReflect.Descriptor instead.
+func (*TranscribeRequest) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *TranscribeRequest) GetPayload() isTranscribeRequest_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *TranscribeRequest) GetConfig() *TranscribeConfig {
+	if x != nil {
+		if x, ok := x.Payload.(*TranscribeRequest_Config); ok {
+			return x.Config
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeRequest) GetAudio() *v1.AudioChunk {
+	if x != nil {
+		if x, ok := x.Payload.(*TranscribeRequest_Audio); ok {
+			return x.Audio
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeRequest) GetControl() v1.ControlType {
+	if x != nil {
+		if x, ok := x.Payload.(*TranscribeRequest_Control); ok {
+			return x.Control
+		}
+	}
+	return v1.ControlType(0)
+}
+
+type isTranscribeRequest_Payload interface {
+	isTranscribeRequest_Payload()
+}
+
+type TranscribeRequest_Config struct {
+	// Session configuration. Only valid as the first message.
+	Config *TranscribeConfig `protobuf:"bytes,1,opt,name=config,proto3,oneof"`
+}
+
+type TranscribeRequest_Audio struct {
+	// Audio chunk.
+	Audio *v1.AudioChunk `protobuf:"bytes,2,opt,name=audio,proto3,oneof"`
+}
+
+type TranscribeRequest_Control struct {
+	// Control message. FLUSH finalizes the current utterance, END closes
+	// the session once the remaining results have been sent.
+	Control v1.ControlType `protobuf:"varint,3,opt,name=control,proto3,enum=voxa.speech.v1.ControlType,oneof"`
+}
+
+func (*TranscribeRequest_Config) isTranscribeRequest_Payload() {}
+
+func (*TranscribeRequest_Audio) isTranscribeRequest_Payload() {}
+
+func (*TranscribeRequest_Control) isTranscribeRequest_Payload() {}
+
+// TranscribeConfig opens a transcription session.
+type TranscribeConfig struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Client-chosen session ID. The server assigns one when empty.
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Sample rate of the audio, in Hz.
+	SampleRate int32 `protobuf:"varint,2,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	// Gate audio on voice activity and finalize utterances on silence.
+	Vad bool `protobuf:"varint,3,opt,name=vad,proto3" json:"vad,omitempty"`
+	// Words and phrases the audio is likely to contain, such as product
+	// names, in addition to the server's vocabulary.
+	Phrases []*Phrase `protobuf:"bytes,4,rep,name=phrases,proto3" json:"phrases,omitempty"`
+	// How the session's recognition is scheduled against the others on a
+	// saturated server. API keys may cap it, lowering higher requests.
+	Priority Priority `protobuf:"varint,5,opt,name=priority,proto3,enum=voxa.voxad.v1.Priority" json:"priority,omitempty"`
+	// ISO 639-1 code of the language spoken, in place of the server's
+	// recognizer language, for recognizers that take one. API keys may
+	// restrict which languages their sessions ask for.
+	Language string `protobuf:"bytes,6,opt,name=language,proto3" json:"language,omitempty"`
+	// Model of the recognizer's provider to transcribe with instead of the
+	// server's. API keys list the models their sessions may ask for.
+	Model string `protobuf:"bytes,7,opt,name=model,proto3" json:"model,omitempty"`
+	// Where utterances end, in place of the server's endpointing.
+	Endpointing   *Endpointing `protobuf:"bytes,8,opt,name=endpointing,proto3" json:"endpointing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscribeConfig) Reset() {
+	*x = TranscribeConfig{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscribeConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscribeConfig) ProtoMessage() {}
+
+func (x *TranscribeConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscribeConfig.ProtoReflect.Descriptor instead.
+func (*TranscribeConfig) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TranscribeConfig) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *TranscribeConfig) GetSampleRate() int32 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *TranscribeConfig) GetVad() bool {
+	if x != nil {
+		return x.Vad
+	}
+	return false
+}
+
+func (x *TranscribeConfig) GetPhrases() []*Phrase {
+	if x != nil {
+		return x.Phrases
+	}
+	return nil
+}
+
+func (x *TranscribeConfig) GetPriority() Priority {
+	if x != nil {
+		return x.Priority
+	}
+	return Priority_PRIORITY_UNSPECIFIED
+}
+
+func (x *TranscribeConfig) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *TranscribeConfig) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *TranscribeConfig) GetEndpointing() *Endpointing {
+	if x != nil {
+		return x.Endpointing
+	}
+	return nil
+}
+
+// Endpointing decides where utterances end, whatever the recognizer.
+type Endpointing struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The pause that ends an utterance; the server's default when unset.
+	MinSilence *durationpb.Duration `protobuf:"bytes,1,opt,name=min_silence,json=minSilence,proto3" json:"min_silence,omitempty"`
+	// The shorter pause ending utterances whose pitch fell at the end, as
+	// at the end of statements. Must be below min_silence.
+	FinishSilence *durationpb.Duration `protobuf:"bytes,2,opt,name=finish_silence,json=finishSilence,proto3" json:"finish_silence,omitempty"`
+	// Utterances this long end at their next pause.
+	MaxUtterance  *durationpb.Duration `protobuf:"bytes,3,opt,name=max_utterance,json=maxUtterance,proto3" json:"max_utterance,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Endpointing) Reset() {
+	*x = Endpointing{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Endpointing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Endpointing) ProtoMessage() {}
+
+func (x *Endpointing) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Endpointing.ProtoReflect.Descriptor instead.
+func (*Endpointing) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Endpointing) GetMinSilence() *durationpb.Duration {
+	if x != nil {
+		return x.MinSilence
+	}
+	return nil
+}
+
+func (x *Endpointing) GetFinishSilence() *durationpb.Duration {
+	if x != nil {
+		return x.FinishSilence
+	}
+	return nil
+}
+
+func (x *Endpointing) GetMaxUtterance() *durationpb.Duration {
+	if x != nil {
+		return x.MaxUtterance
+	}
+	return nil
+}
+
+// Phrase is a word or phrase recognition should favour.
+type Phrase struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Text  string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	// How strongly the phrase is favoured relative to the others; zero
+	// means 1.
+	Boost         float32 `protobuf:"fixed32,2,opt,name=boost,proto3" json:"boost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Phrase) Reset() {
+	*x = Phrase{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Phrase) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Phrase) ProtoMessage() {}
+
+func (x *Phrase) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Phrase.ProtoReflect.Descriptor instead.
+func (*Phrase) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Phrase) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Phrase) GetBoost() float32 {
+	if x != nil {
+		return x.Boost
+	}
+	return 0
+}
+
+type TranscribeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The session ID.
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*TranscribeResponse_Started
+	//	*TranscribeResponse_Segment
+	//	*TranscribeResponse_Vad
+	//	*TranscribeResponse_Intent
+	//	*TranscribeResponse_Language
+	//	*TranscribeResponse_Deferred
+	Event         isTranscribeResponse_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscribeResponse) Reset() {
+	*x = TranscribeResponse{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscribeResponse) ProtoMessage() {}
+
+func (x *TranscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscribeResponse.ProtoReflect.Descriptor instead.
+func (*TranscribeResponse) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *TranscribeResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *TranscribeResponse) GetEvent() isTranscribeResponse_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetStarted() *SessionStarted {
+	if x != nil {
+		if x, ok := x.Event.(*TranscribeResponse_Started); ok {
+			return x.Started
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetSegment() *Segment {
+	if x != nil {
+		if x, ok := x.Event.(*TranscribeResponse_Segment); ok {
+			return x.Segment
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetVad() *VadEvent {
+	if x != nil {
+		if x, ok := x.Event.(*TranscribeResponse_Vad); ok {
+			return x.Vad
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetIntent() *Intent {
+	if x != nil {
+		if x, ok := x.Event.(*TranscribeResponse_Intent); ok {
+			return x.Intent
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetLanguage() *LanguageDetected {
+	if x != nil {
+		if x, ok := x.Event.(*TranscribeResponse_Language); ok {
+			return x.Language
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetDeferred() *TranscriptionDeferred {
+	if x != nil {
+		if x, ok := x.Event.(*TranscribeResponse_Deferred); ok {
+			return x.Deferred
+		}
+	}
+	return nil
+}
+
+type isTranscribeResponse_Event interface {
+	isTranscribeResponse_Event()
+}
+
+type TranscribeResponse_Started struct {
+	// Sent once, before any other event.
+	Started *SessionStarted `protobuf:"bytes,10,opt,name=started,proto3,oneof"`
+}
+
+type TranscribeResponse_Segment struct {
+	// A partial or final transcript.
+	Segment *Segment `protobuf:"bytes,11,opt,name=segment,proto3,oneof"`
+}
+
+type TranscribeResponse_Vad struct {
+	// A voice activity transition.
+	Vad *VadEvent `protobuf:"bytes,12,opt,name=vad,proto3,oneof"`
+}
+
+type TranscribeResponse_Intent struct {
+	// An intent recognized in a final segment, sent before the segment.
+	Intent *Intent `protobuf:"bytes,13,opt,name=intent,proto3,oneof"`
+}
+
+type TranscribeResponse_Language struct {
+	// The language identified for the session.
+	Language *LanguageDetected `protobuf:"bytes,14,opt,name=language,proto3,oneof"`
+}
+
+type TranscribeResponse_Deferred struct {
+	// The transcription of the session was deferred.
+	Deferred *TranscriptionDeferred `protobuf:"bytes,15,opt,name=deferred,proto3,oneof"`
+}
+
+func (*TranscribeResponse_Started) isTranscribeResponse_Event() {}
+
+func (*TranscribeResponse_Segment) isTranscribeResponse_Event() {}
+
+func (*TranscribeResponse_Vad) isTranscribeResponse_Event() {}
+
+func (*TranscribeResponse_Intent) isTranscribeResponse_Event() {}
+
+func (*TranscribeResponse_Language) isTranscribeResponse_Event() {}
+
+func (*TranscribeResponse_Deferred) isTranscribeResponse_Event() {}
+
+// SessionStarted acknowledges the config message.
+type SessionStarted struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The session ID, as chosen by the client or assigned by the server.
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// The routing token of the node serving the session, when voxad runs as
+	// a cluster. Clients reconnecting send it as voxa-route metadata so the
+	// load balancer picks the same node.
+	Route string `protobuf:"bytes,2,opt,name=route,proto3" json:"route,omitempty"`
+	// Where a resumed session picks up, as an offset from the start of the
+	// session audio: the end of the last final segment delivered before the
+	// stream was cut off. Clients resend their audio from there. Zero for a
+	// new session.
+	Resume        *durationpb.Duration `protobuf:"bytes,3,opt,name=resume,proto3" json:"resume,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionStarted) Reset() {
+	*x = SessionStarted{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionStarted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionStarted) ProtoMessage() {}
+
+func (x *SessionStarted) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionStarted.ProtoReflect.Descriptor instead.
+func (*SessionStarted) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SessionStarted) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *SessionStarted) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *SessionStarted) GetResume() *durationpb.Duration {
+	if x != nil {
+		return x.Resume
+	}
+	return nil
+}
+
+// Segment is a recognized span of speech. Partial segments replace earlier
+// ones with the same utterance ID.
+type Segment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The utterance ID.
+	UtteranceId string `protobuf:"bytes,1,opt,name=utterance_id,json=utteranceId,proto3" json:"utterance_id,omitempty"`
+	// Revision of this hypothesis within the utterance.
+	Revision int32 `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	// The full hypothesis text.
+	Text string `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	// Stability in [0, 1]; finals report 1.
+	Stability float32 `protobuf:"fixed32,4,opt,name=stability,proto3" json:"stability,omitempty"`
+	// Whether this is the committed transcript of the utterance.
+	Final bool `protobuf:"varint,5,opt,name=final,proto3" json:"final,omitempty"`
+	// Speaker label ("S1", "S2", ...) when diarization is enabled, or the
+	// speaker's name once they match an enrolled voiceprint.
+	Speaker string `protobuf:"bytes,6,opt,name=speaker,proto3" json:"speaker,omitempty"`
+	// Span of the utterance as offsets from the start of the session audio.
+	Start *durationpb.Duration `protobuf:"bytes,7,opt,name=start,proto3" json:"start,omitempty"`
+	End   *durationpb.Duration `protobuf:"bytes,8,opt,name=end,proto3" json:"end,omitempty"`
+	// Confidence of the hypothesis in [0, 1]; zero if unknown.
+	Confidence float32 `protobuf:"fixed32,9,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	// Per-word alignment, on the same clock as start and end.
+	Words []*v1.Word `protobuf:"bytes,10,rep,name=words,proto3" json:"words,omitempty"`
+	// Translations of a final segment by language code, when the server
+	// translates transcripts.
+	Translations map[string]string `protobuf:"bytes,11,rep,name=translations,proto3" json:"translations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// ISO 639-1 code of the spoken language, when known.
+	Language string `protobuf:"bytes,12,opt,name=language,proto3" json:"language,omitempty"`
+	// The STT provider that recognized the segment, when the server fails
+	// over between several.
+	Provider string `protobuf:"bytes,13,opt,name=provider,proto3" json:"provider,omitempty"`
+	// Spans of the text replaced by markers such as "[SSN]", when the server
+	// redacts personal data.
+	Redactions []*Redaction `protobuf:"bytes,14,rep,name=redactions,proto3" json:"redactions,omitempty"`
+	// Tone of a final segment, when the server analyzes sentiment.
+	Sentiment     *Sentiment `protobuf:"bytes,15,opt,name=sentiment,proto3" json:"sentiment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Segment) Reset() {
+	*x = Segment{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Segment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Segment) ProtoMessage() {}
+
+func (x *Segment) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Segment.ProtoReflect.Descriptor instead.
+func (*Segment) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Segment) GetUtteranceId() string {
+	if x != nil {
+		return x.UtteranceId
+	}
+	return ""
+}
+
+func (x *Segment) GetRevision() int32 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *Segment) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Segment) GetStability() float32 {
+	if x != nil {
+		return x.Stability
+	}
+	return 0
+}
+
+func (x *Segment) GetFinal() bool {
+	if x != nil {
+		return x.Final
+	}
+	return false
+}
+
+func (x *Segment) GetSpeaker() string {
+	if x != nil {
+		return x.Speaker
+	}
+	return ""
+}
+
+func (x *Segment) GetStart() *durationpb.Duration {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *Segment) GetEnd() *durationpb.Duration {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *Segment) GetConfidence() float32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *Segment) GetWords() []*v1.Word {
+	if x != nil {
+		return x.Words
+	}
+	return nil
+}
+
+func (x *Segment) GetTranslations() map[string]string {
+	if x != nil {
+		return x.Translations
+	}
+	return nil
+}
+
+func (x *Segment) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *Segment) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *Segment) GetRedactions() []*Redaction {
+	if x != nil {
+		return x.Redactions
+	}
+	return nil
+}
+
+func (x *Segment) GetSentiment() *Sentiment {
+	if x != nil {
+		return x.Sentiment
+	}
+	return nil
+}
+
+// Sentiment is the tone of a segment.
+type Sentiment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// "positive", "negative" or "neutral".
+	Label string `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
+	// Polarity of the text in [-1, 1], negative to positive.
+	Score float32 `protobuf:"fixed32,2,opt,name=score,proto3" json:"score,omitempty"`
+	// When the server analyzes the voice too: "angry", "happy", "sad",
+	// "calm" or "neutral", and how aroused the voice sounds in (0, 1), 0.5
+	// being the speaker's usual.
+	Emotion       string  `protobuf:"bytes,3,opt,name=emotion,proto3" json:"emotion,omitempty"`
+	Arousal       float32 `protobuf:"fixed32,4,opt,name=arousal,proto3" json:"arousal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sentiment) Reset() {
+	*x = Sentiment{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sentiment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sentiment) ProtoMessage() {}
+
+func (x *Sentiment) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sentiment.ProtoReflect.Descriptor instead.
+func (*Sentiment) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Sentiment) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Sentiment) GetScore() float32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Sentiment) GetEmotion() string {
+	if x != nil {
+		return x.Emotion
+	}
+	return ""
+}
+
+func (x *Sentiment) GetArousal() float32 {
+	if x != nil {
+		return x.Arousal
+	}
+	return 0
+}
+
+// Redaction is a span of a segment's text that was redacted.
+type Redaction struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The kind of data removed, e.g. "credit_card".
+	Entity string `protobuf:"bytes,1,opt,name=entity,proto3" json:"entity,omitempty"`
+	// Byte offsets of the removed span in the text before redaction.
+	Start int32 `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	End   int32 `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	// Byte offset in the segment text of the marker that replaced the span.
+	At            int32 `protobuf:"varint,4,opt,name=at,proto3" json:"at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Redaction) Reset() {
+	*x = Redaction{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Redaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Redaction) ProtoMessage() {}
+
+func (x *Redaction) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Redaction.ProtoReflect.Descriptor instead.
+func (*Redaction) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Redaction) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *Redaction) GetStart() int32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Redaction) GetEnd() int32 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *Redaction) GetAt() int32 {
+	if x != nil {
+		return x.At
+	}
+	return 0
+}
+
+// VadEvent is a speech start or end.
+type VadEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The type of transition.
+	Type VadEventType `protobuf:"varint,1,opt,name=type,proto3,enum=voxa.voxad.v1.VadEventType" json:"type,omitempty"`
+	// Stream time of the transition.
+	Offset        *durationpb.Duration `protobuf:"bytes,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VadEvent) Reset() {
+	*x = VadEvent{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VadEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VadEvent) ProtoMessage() {}
+
+func (x *VadEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VadEvent.ProtoReflect.Descriptor instead.
+func (*VadEvent) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *VadEvent) GetType() VadEventType {
+	if x != nil {
+		return x.Type
+	}
+	return VadEventType_VAD_EVENT_TYPE_UNSPECIFIED
+}
+
+func (x *VadEvent) GetOffset() *durationpb.Duration {
+	if x != nil {
+		return x.Offset
+	}
+	return nil
+}
+
+// LanguageDetected reports the outcome of language identification.
+type LanguageDetected struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The language the session is recognized in from now on; empty if
+	// identification failed without a fallback.
+	Language string `protobuf:"bytes,1,opt,name=language,proto3" json:"language,omitempty"`
+	// Confidence of the best guess in [0, 1].
+	Confidence float32 `protobuf:"fixed32,2,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	// Whether language is the server's fallback rather than the best guess.
+	Fallback bool `protobuf:"varint,3,opt,name=fallback,proto3" json:"fallback,omitempty"`
+	// Why identification failed, if it did.
+	Error         string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LanguageDetected) Reset() {
+	*x = LanguageDetected{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LanguageDetected) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LanguageDetected) ProtoMessage() {}
+
+func (x *LanguageDetected) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LanguageDetected.ProtoReflect.Descriptor instead.
+func (*LanguageDetected) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *LanguageDetected) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *LanguageDetected) GetConfidence() float32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *LanguageDetected) GetFallback() bool {
+	if x != nil {
+		return x.Fallback
+	}
+	return false
+}
+
+func (x *LanguageDetected) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// TranscriptionDeferred reports that every recognizer provider is down, in
+// degraded mode. The session carries on without segments: its audio is
+// archived and transcribed once the providers are back, as a new version
+// of its transcript.
+type TranscriptionDeferred struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Why the recognizer is down.
+	Error         string `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscriptionDeferred) Reset() {
+	*x = TranscriptionDeferred{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscriptionDeferred) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscriptionDeferred) ProtoMessage() {}
+
+func (x *TranscriptionDeferred) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscriptionDeferred.ProtoReflect.Descriptor instead.
+func (*TranscriptionDeferred) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *TranscriptionDeferred) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Intent is what an utterance asks for.
+type Intent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The intent name, as defined in the server's intent definitions.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Slot values by slot name.
+	Slots map[string]string `protobuf:"bytes,2,rep,name=slots,proto3" json:"slots,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Confidence in [0, 1].
+	Confidence float32 `protobuf:"fixed32,3,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	// The utterance text the intent was recognized in.
+	Text          string `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Intent) Reset() {
+	*x = Intent{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Intent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Intent) ProtoMessage() {}
+
+func (x *Intent) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Intent.ProtoReflect.Descriptor instead.
+func (*Intent) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Intent) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Intent) GetSlots() map[string]string {
+	if x != nil {
+		return x.Slots
+	}
+	return nil
+}
+
+func (x *Intent) GetConfidence() float32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *Intent) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+// ========================= Synthesize =========================
+// SynthesizeRequest is one phrase to speak.
+type SynthesizeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Utterance ID for correlating the audio with the text.
+	UtteranceId string `protobuf:"bytes,1,opt,name=utterance_id,json=utteranceId,proto3" json:"utterance_id,omitempty"`
+	// Text to synthesize.
+	Text string `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	// If set, the ID of a Transcribe session, with VAD, listening to the
+	// user this is spoken to: speech it detects interrupts the audio, a
+	// barge-in. The client should stop playing at once.
+	BargeInSession string `protobuf:"bytes,3,opt,name=barge_in_session,json=bargeInSession,proto3" json:"barge_in_session,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SynthesizeRequest) Reset() {
+	*x = SynthesizeRequest{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SynthesizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SynthesizeRequest) ProtoMessage() {}
+
+func (x *SynthesizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SynthesizeRequest.ProtoReflect.Descriptor instead.
+func (*SynthesizeRequest) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SynthesizeRequest) GetUtteranceId() string {
+	if x != nil {
+		return x.UtteranceId
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetBargeInSession() string {
+	if x != nil {
+		return x.BargeInSession
+	}
+	return ""
+}
+
+type SynthesizeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// A chunk of synthesized audio.
+	Audio *v1.AudioChunk `protobuf:"bytes,1,opt,name=audio,proto3" json:"audio,omitempty"`
+	// Set on the last response of an utterance cut short by barge-in, whose
+	// audio chunk carries no data. The next request is spoken as usual.
+	Interrupted   bool `protobuf:"varint,2,opt,name=interrupted,proto3" json:"interrupted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SynthesizeResponse) Reset() {
+	*x = SynthesizeResponse{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SynthesizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SynthesizeResponse) ProtoMessage() {}
+
+func (x *SynthesizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SynthesizeResponse.ProtoReflect.Descriptor instead.
+func (*SynthesizeResponse) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SynthesizeResponse) GetAudio() *v1.AudioChunk {
+	if x != nil {
+		return x.Audio
+	}
+	return nil
+}
+
+func (x *SynthesizeResponse) GetInterrupted() bool {
+	if x != nil {
+		return x.Interrupted
+	}
+	return false
+}
+
+// ========================= Transcripts =========================
+type ListTranscriptsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// If set, only sessions started before it are listed, for paging.
+	Before *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=before,proto3" json:"before,omitempty"`
+	// Maximum number of sessions; the server default (100) when zero.
+	Limit         int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTranscriptsRequest) Reset() {
+	*x = ListTranscriptsRequest{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTranscriptsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTranscriptsRequest) ProtoMessage() {}
+
+func (x *ListTranscriptsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTranscriptsRequest.ProtoReflect.Descriptor instead.
+func (*ListTranscriptsRequest) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ListTranscriptsRequest) GetBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Before
+	}
+	return nil
+}
+
+func (x *ListTranscriptsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListTranscriptsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sessions      []*StoredSession       `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTranscriptsResponse) Reset() {
+	*x = ListTranscriptsResponse{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTranscriptsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTranscriptsResponse) ProtoMessage() {}
+
+func (x *ListTranscriptsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTranscriptsResponse.ProtoReflect.Descriptor instead.
+func (*ListTranscriptsResponse) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ListTranscriptsResponse) GetSessions() []*StoredSession {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+// StoredSession is a session recorded by the server.
+type StoredSession struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The session ID.
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// When the session started.
+	Started *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=started,proto3" json:"started,omitempty"`
+	// When the session ended; unset while it is running.
+	Ended *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=ended,proto3" json:"ended,omitempty"`
+	// Number of segments of the live transcript.
+	Segments int32 `protobuf:"varint,4,opt,name=segments,proto3" json:"segments,omitempty"`
+	// Number of versions made again from the archived audio.
+	Versions int32 `protobuf:"varint,5,opt,name=versions,proto3" json:"versions,omitempty"`
+	// The name of the API key the session was opened with, if any.
+	Tenant string `protobuf:"bytes,6,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	// What transports recorded about the session, such as the consent of a
+	// caller to being recorded: see the Twilio notice of voxad.
+	Metadata      map[string]string `protobuf:"bytes,7,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StoredSession) Reset() {
+	*x = StoredSession{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StoredSession) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoredSession) ProtoMessage() {}
+
+func (x *StoredSession) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoredSession.ProtoReflect.Descriptor instead.
+func (*StoredSession) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *StoredSession) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *StoredSession) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *StoredSession) GetEnded() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Ended
+	}
+	return nil
+}
+
+func (x *StoredSession) GetSegments() int32 {
+	if x != nil {
+		return x.Segments
+	}
+	return 0
+}
+
+func (x *StoredSession) GetVersions() int32 {
+	if x != nil {
+		return x.Versions
+	}
+	return 0
+}
+
+func (x *StoredSession) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+func (x *StoredSession) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type GetTranscriptRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The session ID.
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// The version of the transcript; 0 for the one made live.
+	Version       int32 `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTranscriptRequest) Reset() {
+	*x = GetTranscriptRequest{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTranscriptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTranscriptRequest) ProtoMessage() {}
+
+func (x *GetTranscriptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTranscriptRequest.ProtoReflect.Descriptor instead.
+func (*GetTranscriptRequest) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *GetTranscriptRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *GetTranscriptRequest) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+// Transcript is a stored session and the final segments of one version of
+// its transcript, in order.
+type Transcript struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Session  *StoredSession         `protobuf:"bytes,1,opt,name=session,proto3" json:"session,omitempty"`
+	Segments []*Segment             `protobuf:"bytes,2,rep,name=segments,proto3" json:"segments,omitempty"`
+	// The version of the segments.
+	Version int32 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	// The versions made again from the archived audio, oldest first.
+	Versions []*TranscriptVersion `protobuf:"bytes,4,rep,name=versions,proto3" json:"versions,omitempty"`
+	// The summary of the session, once it has been summarized.
+	Summary       *TranscriptSummary `protobuf:"bytes,5,opt,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transcript) Reset() {
+	*x = Transcript{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transcript) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transcript) ProtoMessage() {}
+
+func (x *Transcript) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transcript.ProtoReflect.Descriptor instead.
+func (*Transcript) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Transcript) GetSession() *StoredSession {
+	if x != nil {
+		return x.Session
+	}
+	return nil
+}
+
+func (x *Transcript) GetSegments() []*Segment {
+	if x != nil {
+		return x.Segments
+	}
+	return nil
+}
+
+func (x *Transcript) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Transcript) GetVersions() []*TranscriptVersion {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+func (x *Transcript) GetSummary() *TranscriptSummary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+// TranscriptSummary is the summary of a stored session.
+type TranscriptSummary struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Text  string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	// The follow-ups agreed on in the session.
+	ActionItems []string `protobuf:"bytes,2,rep,name=action_items,json=actionItems,proto3" json:"action_items,omitempty"`
+	// How it was made, e.g. the model.
+	Label   string                 `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`
+	Created *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created,proto3" json:"created,omitempty"`
+	// The segments of the transcript summarized.
+	Segments      int32 `protobuf:"varint,5,opt,name=segments,proto3" json:"segments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscriptSummary) Reset() {
+	*x = TranscriptSummary{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscriptSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscriptSummary) ProtoMessage() {}
+
+func (x *TranscriptSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscriptSummary.ProtoReflect.Descriptor instead.
+func (*TranscriptSummary) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *TranscriptSummary) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *TranscriptSummary) GetActionItems() []string {
+	if x != nil {
+		return x.ActionItems
+	}
+	return nil
+}
+
+func (x *TranscriptSummary) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *TranscriptSummary) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *TranscriptSummary) GetSegments() int32 {
+	if x != nil {
+		return x.Segments
+	}
+	return 0
+}
+
+// TranscriptVersion is a transcript made again from archived audio.
+type TranscriptVersion struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The version number, from 1.
+	Version int32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// The STT provider that made it.
+	Provider string `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	// How it was made, e.g. the model.
+	Label         string                 `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`
+	Created       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created,proto3" json:"created,omitempty"`
+	Segments      int32                  `protobuf:"varint,5,opt,name=segments,proto3" json:"segments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscriptVersion) Reset() {
+	*x = TranscriptVersion{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscriptVersion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscriptVersion) ProtoMessage() {}
+
+func (x *TranscriptVersion) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscriptVersion.ProtoReflect.Descriptor instead.
+func (*TranscriptVersion) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *TranscriptVersion) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *TranscriptVersion) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *TranscriptVersion) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *TranscriptVersion) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *TranscriptVersion) GetSegments() int32 {
+	if x != nil {
+		return x.Segments
+	}
+	return 0
+}
+
+type SearchTranscriptsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Words that must all appear in a segment, in any order and case. A
+	// "quoted phrase" must appear as written; a word ending in * matches the
+	// words it starts.
+	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// If set, only this session is searched.
+	SessionId string `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// If set, only segments stored in [since, until) are searched.
+	Since *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=since,proto3" json:"since,omitempty"`
+	Until *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=until,proto3" json:"until,omitempty"`
+	// Maximum number of hits; the server default (100) when zero.
+	Limit         int32 `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchTranscriptsRequest) Reset() {
+	*x = SearchTranscriptsRequest{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchTranscriptsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchTranscriptsRequest) ProtoMessage() {}
+
+func (x *SearchTranscriptsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchTranscriptsRequest.ProtoReflect.Descriptor instead.
+func (*SearchTranscriptsRequest) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *SearchTranscriptsRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchTranscriptsRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *SearchTranscriptsRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *SearchTranscriptsRequest) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+func (x *SearchTranscriptsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type SearchTranscriptsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hits          []*SearchHit           `protobuf:"bytes,1,rep,name=hits,proto3" json:"hits,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchTranscriptsResponse) Reset() {
+	*x = SearchTranscriptsResponse{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchTranscriptsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchTranscriptsResponse) ProtoMessage() {}
+
+func (x *SearchTranscriptsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchTranscriptsResponse.ProtoReflect.Descriptor instead.
+func (*SearchTranscriptsResponse) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *SearchTranscriptsResponse) GetHits() []*SearchHit {
+	if x != nil {
+		return x.Hits
+	}
+	return nil
+}
+
+// SearchHit is a stored segment matching a search. Its start and end place
+// it in the session's recording.
+type SearchHit struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The session the segment belongs to.
+	SessionId string   `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Segment   *Segment `protobuf:"bytes,2,opt,name=segment,proto3" json:"segment,omitempty"`
+	// The text around the match, with matching words between ** marks.
+	Snippet string `protobuf:"bytes,3,opt,name=snippet,proto3" json:"snippet,omitempty"`
+	// Relevance; higher is better, comparable within one response.
+	Score float64 `protobuf:"fixed64,4,opt,name=score,proto3" json:"score,omitempty"`
+	// When the segment was stored.
+	Added *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=added,proto3" json:"added,omitempty"`
+	// The version of the transcript the segment belongs to; 0 for the live
+	// one.
+	Version       int32 `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchHit) Reset() {
+	*x = SearchHit{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchHit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchHit) ProtoMessage() {}
+
+func (x *SearchHit) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchHit.ProtoReflect.Descriptor instead.
+func (*SearchHit) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *SearchHit) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *SearchHit) GetSegment() *Segment {
+	if x != nil {
+		return x.Segment
+	}
+	return nil
+}
+
+func (x *SearchHit) GetSnippet() string {
+	if x != nil {
+		return x.Snippet
+	}
+	return ""
+}
+
+func (x *SearchHit) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *SearchHit) GetAdded() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Added
+	}
+	return nil
+}
+
+func (x *SearchHit) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type DeleteSessionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The session ID.
+	SessionId     string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteSessionRequest) Reset() {
+	*x = DeleteSessionRequest{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSessionRequest) ProtoMessage() {}
+
+func (x *DeleteSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSessionRequest.ProtoReflect.Descriptor instead.
+func (*DeleteSessionRequest) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *DeleteSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type DeleteSessionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteSessionResponse) Reset() {
+	*x = DeleteSessionResponse{}
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSessionResponse) ProtoMessage() {}
+
+func (x *DeleteSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_voxa_voxad_v1_voxad_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSessionResponse.ProtoReflect.Descriptor instead.
+func (*DeleteSessionResponse) Descriptor() ([]byte, []int) {
+	return file_voxa_voxad_v1_voxad_proto_rawDescGZIP(), []int{26}
+}
+
+var File_voxa_voxad_v1_voxad_proto protoreflect.FileDescriptor
+
+const file_voxa_voxad_v1_voxad_proto_rawDesc = "" +
+	"\n" +
+	"\x19voxa/voxad/v1/voxad.proto\x12\rvoxa.voxad.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x18voxa/speech/v1/asr.proto\x1a\x1avoxa/speech/v1/audio.proto\"\xc6\x01\n" +
+	"\x11TranscribeRequest\x129\n" +
+	"\x06config\x18\x01 \x01(\v2\x1f.voxa.voxad.v1.TranscribeConfigH\x00R\x06config\x122\n" +
+	"\x05audio\x18\x02 \x01(\v2\x1a.voxa.speech.v1.AudioChunkH\x00R\x05audio\x127\n" +
+	"\acontrol\x18\x03 \x01(\x0e2\x1b.voxa.speech.v1.ControlTypeH\x00R\acontrolB\t\n" +
+	"\apayload\"\xba\x02\n" +
+	"\x10TranscribeConfig\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1f\n" +
+	"\vsample_rate\x18\x02 \x01(\x05R\n" +
+	"sampleRate\x12\x10\n" +
+	"\x03vad\x18\x03 \x01(\bR\x03vad\x12/\n" +
+	"\aphrases\x18\x04 \x03(\v2\x15.voxa.voxad.v1.PhraseR\aphrases\x123\n" +
+	"\bpriority\x18\x05 \x01(\x0e2\x17.voxa.voxad.v1.PriorityR\bpriority\x12\x1a\n" +
+	"\blanguage\x18\x06 \x01(\tR\blanguage\x12\x14\n" +
+	"\x05model\x18\a \x01(\tR\x05model\x12<\n" +
+	"\vendpointing\x18\b \x01(\v2\x1a.voxa.voxad.v1.EndpointingR\vendpointing\"\xcb\x01\n" +
+	"\vEndpointing\x12:\n" +
+	"\vmin_silence\x18\x01 \x01(\v2\x19.google.protobuf.DurationR\n" +
+	"minSilence\x12@\n" +
+	"\x0efinish_silence\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\rfinishSilence\x12>\n" +
+	"\rmax_utterance\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\fmaxUtterance\"2\n" +
+	"\x06Phrase\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12\x14\n" +
+	"\x05boost\x18\x02 \x01(\x02R\x05boost\"\x8c\x03\n" +
+	"\x12TranscribeResponse\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x129\n" +
+	"\astarted\x18\n" +
+	" \x01(\v2\x1d.voxa.voxad.v1.SessionStartedH\x00R\astarted\x122\n" +
+	"\asegment\x18\v \x01(\v2\x16.voxa.voxad.v1.SegmentH\x00R\asegment\x12+\n" +
+	"\x03vad\x18\f \x01(\v2\x17.voxa.voxad.v1.VadEventH\x00R\x03vad\x12/\n" +
+	"\x06intent\x18\r \x01(\v2\x15.voxa.voxad.v1.IntentH\x00R\x06intent\x12=\n" +
+	"\blanguage\x18\x0e \x01(\v2\x1f.voxa.voxad.v1.LanguageDetectedH\x00R\blanguage\x12B\n" +
+	"\bdeferred\x18\x0f \x01(\v2$.voxa.voxad.v1.TranscriptionDeferredH\x00R\bdeferredB\a\n" +
+	"\x05event\"x\n" +
+	"\x0eSessionStarted\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x14\n" +
+	"\x05route\x18\x02 \x01(\tR\x05route\x121\n" +
+	"\x06resume\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x06resume\"\x8d\x05\n" +
+	"\aSegment\x12!\n" +
+	"\futterance_id\x18\x01 \x01(\tR\vutteranceId\x12\x1a\n" +
+	"\brevision\x18\x02 \x01(\x05R\brevision\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\x12\x1c\n" +
+	"\tstability\x18\x04 \x01(\x02R\tstability\x12\x14\n" +
+	"\x05final\x18\x05 \x01(\bR\x05final\x12\x18\n" +
+	"\aspeaker\x18\x06 \x01(\tR\aspeaker\x12/\n" +
+	"\x05start\x18\a \x01(\v2\x19.google.protobuf.DurationR\x05start\x12+\n" +
+	"\x03end\x18\b \x01(\v2\x19.google.protobuf.DurationR\x03end\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\t \x01(\x02R\n" +
+	"confidence\x12*\n" +
+	"\x05words\x18\n" +
+	" \x03(\v2\x14.voxa.speech.v1.WordR\x05words\x12L\n" +
+	"\ftranslations\x18\v \x03(\v2(.voxa.voxad.v1.Segment.TranslationsEntryR\ftranslations\x12\x1a\n" +
+	"\blanguage\x18\f \x01(\tR\blanguage\x12\x1a\n" +
+	"\bprovider\x18\r \x01(\tR\bprovider\x128\n" +
+	"\n" +
+	"redactions\x18\x0e \x03(\v2\x18.voxa.voxad.v1.RedactionR\n" +
+	"redactions\x126\n" +
+	"\tsentiment\x18\x0f \x01(\v2\x18.voxa.voxad.v1.SentimentR\tsentiment\x1a?\n" +
+	"\x11TranslationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"k\n" +
+	"\tSentiment\x12\x14\n" +
+	"\x05label\x18\x01 \x01(\tR\x05label\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x02R\x05score\x12\x18\n" +
+	"\aemotion\x18\x03 \x01(\tR\aemotion\x12\x18\n" +
+	"\aarousal\x18\x04 \x01(\x02R\aarousal\"[\n" +
+	"\tRedaction\x12\x16\n" +
+	"\x06entity\x18\x01 \x01(\tR\x06entity\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\x05R\x05start\x12\x10\n" +
+	"\x03end\x18\x03 \x01(\x05R\x03end\x12\x0e\n" +
+	"\x02at\x18\x04 \x01(\x05R\x02at\"n\n" +
+	"\bVadEvent\x12/\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1b.voxa.voxad.v1.VadEventTypeR\x04type\x121\n" +
+	"\x06offset\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x06offset\"\x80\x01\n" +
+	"\x10LanguageDetected\x12\x1a\n" +
+	"\blanguage\x18\x01 \x01(\tR\blanguage\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x02 \x01(\x02R\n" +
+	"confidence\x12\x1a\n" +
+	"\bfallback\x18\x03 \x01(\bR\bfallback\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"-\n" +
+	"\x15TranscriptionDeferred\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\"\xc2\x01\n" +
+	"\x06Intent\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x126\n" +
+	"\x05slots\x18\x02 \x03(\v2 .voxa.voxad.v1.Intent.SlotsEntryR\x05slots\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x03 \x01(\x02R\n" +
+	"confidence\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x1a8\n" +
+	"\n" +
+	"SlotsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"t\n" +
+	"\x11SynthesizeRequest\x12!\n" +
+	"\futterance_id\x18\x01 \x01(\tR\vutteranceId\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12(\n" +
+	"\x10barge_in_session\x18\x03 \x01(\tR\x0ebargeInSession\"h\n" +
+	"\x12SynthesizeResponse\x120\n" +
+	"\x05audio\x18\x01 \x01(\v2\x1a.voxa.speech.v1.AudioChunkR\x05audio\x12 \n" +
+	"\vinterrupted\x18\x02 \x01(\bR\vinterrupted\"b\n" +
+	"\x16ListTranscriptsRequest\x122\n" +
+	"\x06before\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x06before\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\"S\n" +
+	"\x17ListTranscriptsResponse\x128\n" +
+	"\bsessions\x18\x01 \x03(\v2\x1c.voxa.voxad.v1.StoredSessionR\bsessions\"\xeb\x02\n" +
+	"\rStoredSession\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x124\n" +
+	"\astarted\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x120\n" +
+	"\x05ended\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05ended\x12\x1a\n" +
+	"\bsegments\x18\x04 \x01(\x05R\bsegments\x12\x1a\n" +
+	"\bversions\x18\x05 \x01(\x05R\bversions\x12\x16\n" +
+	"\x06tenant\x18\x06 \x01(\tR\x06tenant\x12F\n" +
+	"\bmetadata\x18\a \x03(\v2*.voxa.voxad.v1.StoredSession.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"O\n" +
+	"\x14GetTranscriptRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\x05R\aversion\"\x8c\x02\n" +
+	"\n" +
+	"Transcript\x126\n" +
+	"\asession\x18\x01 \x01(\v2\x1c.voxa.voxad.v1.StoredSessionR\asession\x122\n" +
+	"\bsegments\x18\x02 \x03(\v2\x16.voxa.voxad.v1.SegmentR\bsegments\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x05R\aversion\x12<\n" +
+	"\bversions\x18\x04 \x03(\v2 .voxa.voxad.v1.TranscriptVersionR\bversions\x12:\n" +
+	"\asummary\x18\x05 \x01(\v2 .voxa.voxad.v1.TranscriptSummaryR\asummary\"\xb2\x01\n" +
+	"\x11TranscriptSummary\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12!\n" +
+	"\faction_items\x18\x02 \x03(\tR\vactionItems\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\x124\n" +
+	"\acreated\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\acreated\x12\x1a\n" +
+	"\bsegments\x18\x05 \x01(\x05R\bsegments\"\xb1\x01\n" +
+	"\x11TranscriptVersion\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x1a\n" +
+	"\bprovider\x18\x02 \x01(\tR\bprovider\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\x124\n" +
+	"\acreated\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\acreated\x12\x1a\n" +
+	"\bsegments\x18\x05 \x01(\x05R\bsegments\"\xc9\x01\n" +
+	"\x18SearchTranscriptsRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x120\n" +
+	"\x05since\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x05until\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\"I\n" +
+	"\x19SearchTranscriptsResponse\x12,\n" +
+	"\x04hits\x18\x01 \x03(\v2\x18.voxa.voxad.v1.SearchHitR\x04hits\"\xd8\x01\n" +
+	"\tSearchHit\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x120\n" +
+	"\asegment\x18\x02 \x01(\v2\x16.voxa.voxad.v1.SegmentR\asegment\x12\x18\n" +
+	"\asnippet\x18\x03 \x01(\tR\asnippet\x12\x14\n" +
+	"\x05score\x18\x04 \x01(\x01R\x05score\x120\n" +
+	"\x05added\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x05added\x12\x18\n" +
+	"\aversion\x18\x06 \x01(\x05R\aversion\"5\n" +
+	"\x14DeleteSessionRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\x17\n" +
+	"\x15DeleteSessionResponse*R\n" +
+	"\bPriority\x12\x18\n" +
+	"\x14PRIORITY_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14PRIORITY_INTERACTIVE\x10\x01\x12\x12\n" +
+	"\x0ePRIORITY_BATCH\x10\x02*P\n" +
+	"\fVadEventType\x12\x1e\n" +
+	"\x1aVAD_EVENT_TYPE_UNSPECIFIED\x10\x00\x12\x10\n" +
+	"\fSPEECH_START\x10\x01\x12\x0e\n" +
+	"\n" +
+	"SPEECH_END\x10\x022\xac\x04\n" +
+	"\x05Voxad\x12U\n" +
+	"\n" +
+	"Transcribe\x12 .voxa.voxad.v1.TranscribeRequest\x1a!.voxa.voxad.v1.TranscribeResponse(\x010\x01\x12U\n" +
+	"\n" +
+	"Synthesize\x12 .voxa.voxad.v1.SynthesizeRequest\x1a!.voxa.voxad.v1.SynthesizeResponse(\x010\x01\x12`\n" +
+	"\x0fListTranscripts\x12%.voxa.voxad.v1.ListTranscriptsRequest\x1a&.voxa.voxad.v1.ListTranscriptsResponse\x12O\n" +
+	"\rGetTranscript\x12#.voxa.voxad.v1.GetTranscriptRequest\x1a\x19.voxa.voxad.v1.Transcript\x12f\n" +
+	"\x11SearchTranscripts\x12'.voxa.voxad.v1.SearchTranscriptsRequest\x1a(.voxa.voxad.v1.SearchTranscriptsResponse\x12Z\n" +
+	"\rDeleteSession\x12#.voxa.voxad.v1.DeleteSessionRequest\x1a$.voxa.voxad.v1.DeleteSessionResponseBY\n" +
+	"\x11com.voxa.voxad.v1B\n" +
+	"VoxadProtoP\x01Z6github.com/jmarc101/voxa/api/gen/voxa/voxad/v1;voxadv1b\x06proto3"
+
+var (
+	file_voxa_voxad_v1_voxad_proto_rawDescOnce sync.Once
+	file_voxa_voxad_v1_voxad_proto_rawDescData []byte
+)
+
+func file_voxa_voxad_v1_voxad_proto_rawDescGZIP() []byte {
+	file_voxa_voxad_v1_voxad_proto_rawDescOnce.Do(func() {
+		file_voxa_voxad_v1_voxad_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_voxa_voxad_v1_voxad_proto_rawDesc), len(file_voxa_voxad_v1_voxad_proto_rawDesc)))
+	})
+	return file_voxa_voxad_v1_voxad_proto_rawDescData
+}
+
+var file_voxa_voxad_v1_voxad_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_voxa_voxad_v1_voxad_proto_msgTypes = make([]protoimpl.MessageInfo, 30)
+var file_voxa_voxad_v1_voxad_proto_goTypes = []any{
+	(Priority)(0),                     // 0: voxa.voxad.v1.Priority
+	(VadEventType)(0),                 // 1: voxa.voxad.v1.VadEventType
+	(*TranscribeRequest)(nil),         // 2: voxa.voxad.v1.TranscribeRequest
+	(*TranscribeConfig)(nil),          // 3: voxa.voxad.v1.TranscribeConfig
+	(*Endpointing)(nil),               // 4: voxa.voxad.v1.Endpointing
+	(*Phrase)(nil),                    // 5: voxa.voxad.v1.Phrase
+	(*TranscribeResponse)(nil),        // 6: voxa.voxad.v1.TranscribeResponse
+	(*SessionStarted)(nil),            // 7: voxa.voxad.v1.SessionStarted
+	(*Segment)(nil),                   // 8: voxa.voxad.v1.Segment
+	(*Sentiment)(nil),                 // 9: voxa.voxad.v1.Sentiment
+	(*Redaction)(nil),                 // 10: voxa.voxad.v1.Redaction
+	(*VadEvent)(nil),                  // 11: voxa.voxad.v1.VadEvent
+	(*LanguageDetected)(nil),          // 12: voxa.voxad.v1.LanguageDetected
+	(*TranscriptionDeferred)(nil),     // 13: voxa.voxad.v1.TranscriptionDeferred
+	(*Intent)(nil),                    // 14: voxa.voxad.v1.Intent
+	(*SynthesizeRequest)(nil),         // 15: voxa.voxad.v1.SynthesizeRequest
+	(*SynthesizeResponse)(nil),        // 16: voxa.voxad.v1.SynthesizeResponse
+	(*ListTranscriptsRequest)(nil),    // 17: voxa.voxad.v1.ListTranscriptsRequest
+	(*ListTranscriptsResponse)(nil),   // 18: voxa.voxad.v1.ListTranscriptsResponse
+	(*StoredSession)(nil),             // 19: voxa.voxad.v1.StoredSession
+	(*GetTranscriptRequest)(nil),      // 20: voxa.voxad.v1.GetTranscriptRequest
+	(*Transcript)(nil),                // 21: voxa.voxad.v1.Transcript
+	(*TranscriptSummary)(nil),         // 22: voxa.voxad.v1.TranscriptSummary
+	(*TranscriptVersion)(nil),         // 23: voxa.voxad.v1.TranscriptVersion
+	(*SearchTranscriptsRequest)(nil),  // 24: voxa.voxad.v1.SearchTranscriptsRequest
+	(*SearchTranscriptsResponse)(nil), // 25: voxa.voxad.v1.SearchTranscriptsResponse
+	(*SearchHit)(nil),                 // 26: voxa.voxad.v1.SearchHit
+	(*DeleteSessionRequest)(nil),      // 27: voxa.voxad.v1.DeleteSessionRequest
+	(*DeleteSessionResponse)(nil),     // 28: voxa.voxad.v1.DeleteSessionResponse
+	nil,                               // 29: voxa.voxad.v1.Segment.TranslationsEntry
+	nil,                               // 30: voxa.voxad.v1.Intent.SlotsEntry
+	nil,                               // 31: voxa.voxad.v1.StoredSession.MetadataEntry
+	(*v1.AudioChunk)(nil),             // 32: voxa.speech.v1.AudioChunk
+	(v1.ControlType)(0),               // 33: voxa.speech.v1.ControlType
+	(*durationpb.Duration)(nil),       // 34: google.protobuf.Duration
+	(*v1.Word)(nil),                   // 35: voxa.speech.v1.Word
+	(*timestamppb.Timestamp)(nil),     // 36: google.protobuf.Timestamp
+}
+var file_voxa_voxad_v1_voxad_proto_depIdxs = []int32{
+	3,  // 0: voxa.voxad.v1.TranscribeRequest.config:type_name -> voxa.voxad.v1.TranscribeConfig
+	32, // 1: voxa.voxad.v1.TranscribeRequest.audio:type_name -> voxa.speech.v1.AudioChunk
+	33, // 2: voxa.voxad.v1.TranscribeRequest.control:type_name -> voxa.speech.v1.ControlType
+	5,  // 3: voxa.voxad.v1.TranscribeConfig.phrases:type_name -> voxa.voxad.v1.Phrase
+	0,  // 4: voxa.voxad.v1.TranscribeConfig.priority:type_name -> voxa.voxad.v1.Priority
+	4,  // 5: voxa.voxad.v1.TranscribeConfig.endpointing:type_name -> voxa.voxad.v1.Endpointing
+	34, // 6: voxa.voxad.v1.Endpointing.min_silence:type_name -> google.protobuf.Duration
+	34, // 7: voxa.voxad.v1.Endpointing.finish_silence:type_name -> google.protobuf.Duration
+	34, // 8: voxa.voxad.v1.Endpointing.max_utterance:type_name -> google.protobuf.Duration
+	7,  // 9: voxa.voxad.v1.TranscribeResponse.started:type_name -> voxa.voxad.v1.SessionStarted
+	8,  // 10: voxa.voxad.v1.TranscribeResponse.segment:type_name -> voxa.voxad.v1.Segment
+	11, // 11: voxa.voxad.v1.TranscribeResponse.vad:type_name -> voxa.voxad.v1.VadEvent
+	14, // 12: voxa.voxad.v1.TranscribeResponse.intent:type_name -> voxa.voxad.v1.Intent
+	12, // 13: voxa.voxad.v1.TranscribeResponse.language:type_name -> voxa.voxad.v1.LanguageDetected
+	13, // 14: voxa.voxad.v1.TranscribeResponse.deferred:type_name -> voxa.voxad.v1.TranscriptionDeferred
+	34, // 15: voxa.voxad.v1.SessionStarted.resume:type_name -> google.protobuf.Duration
+	34, // 16: voxa.voxad.v1.Segment.start:type_name -> google.protobuf.Duration
+	34, // 17: voxa.voxad.v1.Segment.end:type_name -> google.protobuf.Duration
+	35, // 18: voxa.voxad.v1.Segment.words:type_name -> voxa.speech.v1.Word
+	29, // 19: voxa.voxad.v1.Segment.translations:type_name -> voxa.voxad.v1.Segment.TranslationsEntry
+	10, // 20: voxa.voxad.v1.Segment.redactions:type_name -> voxa.voxad.v1.Redaction
+	9,  // 21: voxa.voxad.v1.Segment.sentiment:type_name -> voxa.voxad.v1.Sentiment
+	1,  // 22: voxa.voxad.v1.VadEvent.type:type_name -> voxa.voxad.v1.VadEventType
+	34, // 23: voxa.voxad.v1.VadEvent.offset:type_name -> google.protobuf.Duration
+	30, // 24: voxa.voxad.v1.Intent.slots:type_name -> voxa.voxad.v1.Intent.SlotsEntry
+	32, // 25: voxa.voxad.v1.SynthesizeResponse.audio:type_name -> voxa.speech.v1.AudioChunk
+	36, // 26: voxa.voxad.v1.ListTranscriptsRequest.before:type_name -> google.protobuf.Timestamp
+	19, // 27: voxa.voxad.v1.ListTranscriptsResponse.sessions:type_name -> voxa.voxad.v1.StoredSession
+	36, // 28: voxa.voxad.v1.StoredSession.started:type_name -> google.protobuf.Timestamp
+	36, // 29: voxa.voxad.v1.StoredSession.ended:type_name -> google.protobuf.Timestamp
+	31, // 30: voxa.voxad.v1.StoredSession.metadata:type_name -> voxa.voxad.v1.StoredSession.MetadataEntry
+	19, // 31: voxa.voxad.v1.Transcript.session:type_name -> voxa.voxad.v1.StoredSession
+	8,  // 32: voxa.voxad.v1.Transcript.segments:type_name -> voxa.voxad.v1.Segment
+	23, // 33: voxa.voxad.v1.Transcript.versions:type_name -> voxa.voxad.v1.TranscriptVersion
+	22, // 34: voxa.voxad.v1.Transcript.summary:type_name -> voxa.voxad.v1.TranscriptSummary
+	36, // 35: voxa.voxad.v1.TranscriptSummary.created:type_name -> google.protobuf.Timestamp
+	36, // 36: voxa.voxad.v1.TranscriptVersion.created:type_name -> google.protobuf.Timestamp
+	36, // 37: voxa.voxad.v1.SearchTranscriptsRequest.since:type_name -> google.protobuf.Timestamp
+	36, // 38: voxa.voxad.v1.SearchTranscriptsRequest.until:type_name -> google.protobuf.Timestamp
+	26, // 39: voxa.voxad.v1.SearchTranscriptsResponse.hits:type_name -> voxa.voxad.v1.SearchHit
+	8,  // 40: voxa.voxad.v1.SearchHit.segment:type_name -> voxa.voxad.v1.Segment
+	36, // 41: voxa.voxad.v1.SearchHit.added:type_name -> google.protobuf.Timestamp
+	2,  // 42: voxa.voxad.v1.Voxad.Transcribe:input_type -> voxa.voxad.v1.TranscribeRequest
+	15, // 43: voxa.voxad.v1.Voxad.Synthesize:input_type -> voxa.voxad.v1.SynthesizeRequest
+	17, // 44: voxa.voxad.v1.Voxad.ListTranscripts:input_type -> voxa.voxad.v1.ListTranscriptsRequest
+	20, // 45: voxa.voxad.v1.Voxad.GetTranscript:input_type -> voxa.voxad.v1.GetTranscriptRequest
+	24, // 46: voxa.voxad.v1.Voxad.SearchTranscripts:input_type -> voxa.voxad.v1.SearchTranscriptsRequest
+	27, // 47: voxa.voxad.v1.Voxad.DeleteSession:input_type -> voxa.voxad.v1.DeleteSessionRequest
+	6,  // 48: voxa.voxad.v1.Voxad.Transcribe:output_type -> voxa.voxad.v1.TranscribeResponse
+	16, // 49: voxa.voxad.v1.Voxad.Synthesize:output_type -> voxa.voxad.v1.SynthesizeResponse
+	18, // 50: voxa.voxad.v1.Voxad.ListTranscripts:output_type -> voxa.voxad.v1.ListTranscriptsResponse
+	21, // 51: voxa.voxad.v1.Voxad.GetTranscript:output_type -> voxa.voxad.v1.Transcript
+	25, // 52: voxa.voxad.v1.Voxad.SearchTranscripts:output_type -> voxa.voxad.v1.SearchTranscriptsResponse
+	28, // 53: voxa.voxad.v1.Voxad.DeleteSession:output_type -> voxa.voxad.v1.DeleteSessionResponse
+	48, // [48:54] is the sub-list for method output_type
+	42, // [42:48] is the sub-list for method input_type
+	42, // [42:42] is the sub-list for extension type_name
+	42, // [42:42] is the sub-list for extension extendee
+	0,  // [0:42] is the sub-list for field type_name
+}
+
+func init() { file_voxa_voxad_v1_voxad_proto_init() }
+func file_voxa_voxad_v1_voxad_proto_init() {
+	if File_voxa_voxad_v1_voxad_proto != nil {
+		return
+	}
+	file_voxa_voxad_v1_voxad_proto_msgTypes[0].OneofWrappers = []any{
+		(*TranscribeRequest_Config)(nil),
+		(*TranscribeRequest_Audio)(nil),
+		(*TranscribeRequest_Control)(nil),
+	}
+	file_voxa_voxad_v1_voxad_proto_msgTypes[4].OneofWrappers = []any{
+		(*TranscribeResponse_Started)(nil),
+		(*TranscribeResponse_Segment)(nil),
+		(*TranscribeResponse_Vad)(nil),
+		(*TranscribeResponse_Intent)(nil),
+		(*TranscribeResponse_Language)(nil),
+		(*TranscribeResponse_Deferred)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_voxa_voxad_v1_voxad_proto_rawDesc), len(file_voxa_voxad_v1_voxad_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   30,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_voxa_voxad_v1_voxad_proto_goTypes,
+		DependencyIndexes: file_voxa_voxad_v1_voxad_proto_depIdxs,
+		EnumInfos:         file_voxa_voxad_v1_voxad_proto_enumTypes,
+		MessageInfos:      file_voxa_voxad_v1_voxad_proto_msgTypes,
+	}.Build()
+	File_voxa_voxad_v1_voxad_proto = out.File
+	file_voxa_voxad_v1_voxad_proto_goTypes = nil
+	file_voxa_voxad_v1_voxad_proto_depIdxs = nil
+}
@@ -0,0 +1,342 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.26.1
+// source: voxa/voxad/v1/voxad.proto
+
+package voxadv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Voxad_Transcribe_FullMethodName        = "/voxa.voxad.v1.Voxad/Transcribe"
+	Voxad_Synthesize_FullMethodName        = "/voxa.voxad.v1.Voxad/Synthesize"
+	Voxad_ListTranscripts_FullMethodName   = "/voxa.voxad.v1.Voxad/ListTranscripts"
+	Voxad_GetTranscript_FullMethodName     = "/voxa.voxad.v1.Voxad/GetTranscript"
+	Voxad_SearchTranscripts_FullMethodName = "/voxa.voxad.v1.Voxad/SearchTranscripts"
+	Voxad_DeleteSession_FullMethodName     = "/voxa.voxad.v1.Voxad/DeleteSession"
+)
+
+// VoxadClient is the client API for Voxad service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Voxad exposes the full voxa pipeline (VAD, wake word, recognition,
+// synthesis) to remote clients. Every Transcribe and Synthesize call is one
+// session. Audio is PCM16 mono at the sample rate announced in the config
+// message. Sessions run on the server profile named by voxa-profile
+// metadata, if sent, or else on that of the API key.
+type VoxadClient interface {
+	// Transcribe streams audio in and transcript events out.
+	// The first request must carry a TranscribeConfig.
+	Transcribe(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[TranscribeRequest, TranscribeResponse], error)
+	// Synthesize streams text in and synthesized audio out. Each request is
+	// spoken in order once the previous one has been fully synthesized.
+	Synthesize(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SynthesizeRequest, SynthesizeResponse], error)
+	// ListTranscripts lists the stored sessions, most recently started
+	// first. It fails with FAILED_PRECONDITION unless the server stores
+	// transcripts.
+	ListTranscripts(ctx context.Context, in *ListTranscriptsRequest, opts ...grpc.CallOption) (*ListTranscriptsResponse, error)
+	// GetTranscript returns a stored session with its final segments.
+	GetTranscript(ctx context.Context, in *GetTranscriptRequest, opts ...grpc.CallOption) (*Transcript, error)
+	// SearchTranscripts returns the stored segments matching a full-text
+	// query, most relevant first.
+	SearchTranscripts(ctx context.Context, in *SearchTranscriptsRequest, opts ...grpc.CallOption) (*SearchTranscriptsResponse, error)
+	// DeleteSession forgets a stored session: its transcripts, summary,
+	// archived audio and conversation state. It fails with NOT_FOUND for
+	// unknown sessions, and for those opened with another API key unless
+	// the caller's is an admin key, and with FAILED_PRECONDITION for
+	// running ones.
+	DeleteSession(ctx context.Context, in *DeleteSessionRequest, opts ...grpc.CallOption) (*DeleteSessionResponse, error)
+}
+
+type voxadClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVoxadClient(cc grpc.ClientConnInterface) VoxadClient {
+	return &voxadClient{cc}
+}
+
+func (c *voxadClient) Transcribe(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[TranscribeRequest, TranscribeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Voxad_ServiceDesc.Streams[0], Voxad_Transcribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TranscribeRequest, TranscribeResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Voxad_TranscribeClient = grpc.BidiStreamingClient[TranscribeRequest, TranscribeResponse]
+
+func (c *voxadClient) Synthesize(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SynthesizeRequest, SynthesizeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Voxad_ServiceDesc.Streams[1], Voxad_Synthesize_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SynthesizeRequest, SynthesizeResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Voxad_SynthesizeClient = grpc.BidiStreamingClient[SynthesizeRequest, SynthesizeResponse]
+
+func (c *voxadClient) ListTranscripts(ctx context.Context, in *ListTranscriptsRequest, opts ...grpc.CallOption) (*ListTranscriptsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTranscriptsResponse)
+	err := c.cc.Invoke(ctx, Voxad_ListTranscripts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voxadClient) GetTranscript(ctx context.Context, in *GetTranscriptRequest, opts ...grpc.CallOption) (*Transcript, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Transcript)
+	err := c.cc.Invoke(ctx, Voxad_GetTranscript_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voxadClient) SearchTranscripts(ctx context.Context, in *SearchTranscriptsRequest, opts ...grpc.CallOption) (*SearchTranscriptsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchTranscriptsResponse)
+	err := c.cc.Invoke(ctx, Voxad_SearchTranscripts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voxadClient) DeleteSession(ctx context.Context, in *DeleteSessionRequest, opts ...grpc.CallOption) (*DeleteSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteSessionResponse)
+	err := c.cc.Invoke(ctx, Voxad_DeleteSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VoxadServer is the server API for Voxad service.
+// All implementations must embed UnimplementedVoxadServer
+// for forward compatibility.
+//
+// Voxad exposes the full voxa pipeline (VAD, wake word, recognition,
+// synthesis) to remote clients. Every Transcribe and Synthesize call is one
+// session. Audio is PCM16 mono at the sample rate announced in the config
+// message. Sessions run on the server profile named by voxa-profile
+// metadata, if sent, or else on that of the API key.
+type VoxadServer interface {
+	// Transcribe streams audio in and transcript events out.
+	// The first request must carry a TranscribeConfig.
+	Transcribe(grpc.BidiStreamingServer[TranscribeRequest, TranscribeResponse]) error
+	// Synthesize streams text in and synthesized audio out. Each request is
+	// spoken in order once the previous one has been fully synthesized.
+	Synthesize(grpc.BidiStreamingServer[SynthesizeRequest, SynthesizeResponse]) error
+	// ListTranscripts lists the stored sessions, most recently started
+	// first. It fails with FAILED_PRECONDITION unless the server stores
+	// transcripts.
+	ListTranscripts(context.Context, *ListTranscriptsRequest) (*ListTranscriptsResponse, error)
+	// GetTranscript returns a stored session with its final segments.
+	GetTranscript(context.Context, *GetTranscriptRequest) (*Transcript, error)
+	// SearchTranscripts returns the stored segments matching a full-text
+	// query, most relevant first.
+	SearchTranscripts(context.Context, *SearchTranscriptsRequest) (*SearchTranscriptsResponse, error)
+	// DeleteSession forgets a stored session: its transcripts, summary,
+	// archived audio and conversation state. It fails with NOT_FOUND for
+	// unknown sessions, and for those opened with another API key unless
+	// the caller's is an admin key, and with FAILED_PRECONDITION for
+	// running ones.
+	DeleteSession(context.Context, *DeleteSessionRequest) (*DeleteSessionResponse, error)
+	mustEmbedUnimplementedVoxadServer()
+}
+
+// UnimplementedVoxadServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedVoxadServer struct{}
+
+func (UnimplementedVoxadServer) Transcribe(grpc.BidiStreamingServer[TranscribeRequest, TranscribeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Transcribe not implemented")
+}
+func (UnimplementedVoxadServer) Synthesize(grpc.BidiStreamingServer[SynthesizeRequest, SynthesizeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Synthesize not implemented")
+}
+func (UnimplementedVoxadServer) ListTranscripts(context.Context, *ListTranscriptsRequest) (*ListTranscriptsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTranscripts not implemented")
+}
+func (UnimplementedVoxadServer) GetTranscript(context.Context, *GetTranscriptRequest) (*Transcript, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTranscript not implemented")
+}
+func (UnimplementedVoxadServer) SearchTranscripts(context.Context, *SearchTranscriptsRequest) (*SearchTranscriptsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchTranscripts not implemented")
+}
+func (UnimplementedVoxadServer) DeleteSession(context.Context, *DeleteSessionRequest) (*DeleteSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSession not implemented")
+}
+func (UnimplementedVoxadServer) mustEmbedUnimplementedVoxadServer() {}
+func (UnimplementedVoxadServer) testEmbeddedByValue()               {}
+
+// UnsafeVoxadServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VoxadServer will
+// result in compilation errors.
+type UnsafeVoxadServer interface {
+	mustEmbedUnimplementedVoxadServer()
+}
+
+func RegisterVoxadServer(s grpc.ServiceRegistrar, srv VoxadServer) {
+	// If the following call pancis, it indicates UnimplementedVoxadServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Voxad_ServiceDesc, srv)
+}
+
+func _Voxad_Transcribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(VoxadServer).Transcribe(&grpc.GenericServerStream[TranscribeRequest, TranscribeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Voxad_TranscribeServer = grpc.BidiStreamingServer[TranscribeRequest, TranscribeResponse]
+
+func _Voxad_Synthesize_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(VoxadServer).Synthesize(&grpc.GenericServerStream[SynthesizeRequest, SynthesizeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Voxad_SynthesizeServer = grpc.BidiStreamingServer[SynthesizeRequest, SynthesizeResponse]
+
+func _Voxad_ListTranscripts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTranscriptsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoxadServer).ListTranscripts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Voxad_ListTranscripts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoxadServer).ListTranscripts(ctx, req.(*ListTranscriptsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Voxad_GetTranscript_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTranscriptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoxadServer).GetTranscript(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Voxad_GetTranscript_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoxadServer).GetTranscript(ctx, req.(*GetTranscriptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Voxad_SearchTranscripts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchTranscriptsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoxadServer).SearchTranscripts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Voxad_SearchTranscripts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoxadServer).SearchTranscripts(ctx, req.(*SearchTranscriptsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Voxad_DeleteSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoxadServer).DeleteSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Voxad_DeleteSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoxadServer).DeleteSession(ctx, req.(*DeleteSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Voxad_ServiceDesc is the grpc.ServiceDesc for Voxad service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Voxad_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "voxa.voxad.v1.Voxad",
+	HandlerType: (*VoxadServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListTranscripts",
+			Handler:    _Voxad_ListTranscripts_Handler,
+		},
+		{
+			MethodName: "GetTranscript",
+			Handler:    _Voxad_GetTranscript_Handler,
+		},
+		{
+			MethodName: "SearchTranscripts",
+			Handler:    _Voxad_SearchTranscripts_Handler,
+		},
+		{
+			MethodName: "DeleteSession",
+			Handler:    _Voxad_DeleteSession_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Transcribe",
+			Handler:       _Voxad_Transcribe_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Synthesize",
+			Handler:       _Voxad_Synthesize_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "voxa/voxad/v1/voxad.proto",
+}
@@ -0,0 +1,97 @@
+package voxa
+
+import (
+	"context"
+
+	"github.com/jmarc101/voxa/internal/archive"
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// ArchiveConfig configures the recording of stream audio; see
+// Config.Archive.
+type ArchiveConfig = archive.Config
+
+// ArchiveFormat is the encoding of archived audio.
+type ArchiveFormat = archive.Format
+
+// Archive formats.
+const (
+	ArchiveFLAC = archive.FLAC
+	ArchiveWAV  = archive.WAV
+	ArchiveOpus = archive.Opus
+)
+
+// ParseArchiveFormat parses an ArchiveFormat name: flac, wav or opus.
+func ParseArchiveFormat(s string) (ArchiveFormat, error) { return archive.ParseFormat(s) }
+
+// DefaultArchiveName is the name template of archives whose config sets
+// none.
+const DefaultArchiveName = archive.DefaultName
+
+// ArchivedPart describes an uploaded part of the recording of a stream;
+// see ArchiveConfig.OnStored.
+type ArchivedPart = archive.Stored
+
+// ArchiveStorage keeps archived audio.
+type ArchiveStorage = archive.Storage
+
+// ArchiveS3Config locates an S3-compatible bucket for archives.
+type ArchiveS3Config = archive.S3Config
+
+// NewArchiveDir returns an ArchiveStorage writing files under root, which
+// is created if needed.
+func NewArchiveDir(root string) (ArchiveStorage, error) { return archive.NewDir(root) }
+
+// NewArchiveS3 returns an ArchiveStorage uploading to an S3-compatible
+// bucket.
+func NewArchiveS3(cfg ArchiveS3Config) (ArchiveStorage, error) { return archive.NewS3(cfg) }
+
+// newArchiver opens the archiver of cfg, counting its failures in the
+// pipeline metrics and listing the archives in ts if set.
+func newArchiver(cfg ArchiveConfig, ts TranscriptStore, m *Metrics, log Logger) (*archive.Archiver, error) {
+	if cfg.Logger == nil {
+		cfg.Logger = log
+	}
+	onError := cfg.OnError
+	cfg.OnError = func(err error) {
+		m.Error("archive")
+		if onError != nil {
+			onError(err)
+		}
+	}
+	if ts != nil {
+		onStored := cfg.OnStored
+		cfg.OnStored = func(st archive.Stored) {
+			a := StoredArchive{Name: st.Name, Stream: st.Stream, Part: st.Part, Started: st.Started, Duration: st.Duration}
+			if err := ts.AddArchive(context.Background(), st.Session, a); err != nil {
+				m.Error("store")
+				log.Warn("storing archive failed", "name", st.Name, "error", err)
+			}
+			if onStored != nil {
+				onStored(st)
+			}
+		}
+	}
+	return archive.New(cfg)
+}
+
+// record tees fr, as written to s, to the archive. A failing archive stops
+// recording the stream, not the stream itself; the archiver has logged the
+// failure.
+func (s *Stream) record(fr audio.Frame) {
+	if s.tape == nil {
+		return
+	}
+	if err := s.tape.Write(fr); err != nil {
+		s.tape = nil
+	}
+}
+
+// closeTape ends the recording of s and queues its last part for upload.
+func (s *Stream) closeTape() {
+	if s.tape == nil {
+		return
+	}
+	_ = s.tape.Close()
+	s.tape = nil
+}
@@ -0,0 +1,159 @@
+package voxa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/budget"
+)
+
+// StageBudget is the latency budget of a stage; see Config.Budgets.
+type StageBudget = budget.Config
+
+// BudgetAction selects what a stage over its latency budget does.
+type BudgetAction = budget.Action
+
+// Budget actions.
+const (
+	// BudgetObserve only logs and counts the breach.
+	BudgetObserve = budget.Observe
+	// BudgetSkip bypasses the stage until it is back within its budget:
+	// audio passes through unchanged, segments are not enriched. Only the
+	// stages of OptionalStages can be skipped.
+	BudgetSkip = budget.Skip
+	// BudgetShed makes NewStream refuse new streams with ErrOverBudget
+	// until the stage is back within its budget.
+	BudgetShed = budget.Shed
+)
+
+// DefaultBudgetCooldown is how long a stage stays in breach of its budget
+// after its average latency was last over it, for budgets that set no
+// Cooldown.
+const DefaultBudgetCooldown = budget.DefaultCooldown
+
+// OptionalStages are the stages BudgetSkip applies to, which enrich audio
+// or transcripts that are still correct without them.
+var OptionalStages = budget.Optional
+
+// ErrOverBudget is returned by NewStream while a stage shedding load is over
+// its latency budget.
+var ErrOverBudget = errors.New("voxa: stage over its latency budget")
+
+// ParseBudgetAction returns the action named "observe", "skip" or "shed".
+func ParseBudgetAction(s string) (BudgetAction, error) {
+	return budget.ParseAction(s)
+}
+
+// builtinStages are the stage names of a pipeline's budgets, besides its
+// plugins.
+var builtinStages = []string{
+	"beamform", "convert", "aec", "dtmf", "denoise", "amd", "agc", "wakeword", "vad", "langid", "diarize", "prosody",
+	"vocabulary", "punctuation", "normalization", "profanity", "redaction",
+	"sentiment", "translation", "intents", "transcripts", "turns",
+}
+
+// newBudgets returns the trackers of the budgets of cfg, by stage.
+func newBudgets(cfg Config) (map[string]*budget.Tracker, error) {
+	if len(cfg.Budgets) == 0 {
+		return nil, nil
+	}
+	known := slices.Clone(builtinStages)
+	for _, pc := range cfg.AudioPlugins {
+		known = append(known, pc.Name)
+	}
+	for _, pc := range cfg.TranscriptPlugins {
+		known = append(known, pc.Name)
+	}
+	trackers := make(map[string]*budget.Tracker, len(cfg.Budgets))
+	for name, b := range cfg.Budgets {
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("voxa: budget of unknown stage %q", name)
+		}
+		if err := b.Validate(name); err != nil {
+			return nil, fmt.Errorf("voxa: %w", err)
+		}
+		trackers[name] = budget.New(b)
+	}
+	return trackers, nil
+}
+
+// shedding returns the first stage, by name, refusing new streams over
+// its budget.
+func (p *Pipeline) shedding() (string, bool) {
+	for _, name := range slices.Sorted(maps.Keys(p.budgets)) {
+		if p.budgets[name].Shed() {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// budgeted wraps the audio stage st of s, measured under name, to hold it
+// to its budget, if it has one.
+func (s *Stream) budgeted(name string, st audio.Stage) audio.Stage {
+	t := s.budgets[name]
+	if t == nil {
+		return st
+	}
+	return &budgetStage{Stage: st, name: name, budget: t, s: s}
+}
+
+// budgetStage is an audio stage with a latency budget.
+type budgetStage struct {
+	audio.Stage
+	name   string
+	budget *budget.Tracker
+	s      *Stream
+	pass   [1]audio.Frame
+}
+
+func (b *budgetStage) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if b.budget.Skip() {
+		b.s.metrics.Skipped(b.name)
+		b.pass[0] = fr
+		return b.pass[:], nil
+	}
+	start := time.Now()
+	out, err := b.Stage.Process(fr)
+	b.s.measured(b.name, b.budget, time.Since(start))
+	return out, err
+}
+
+// step runs fn, the step name of the handling of a segment, and records
+// its latency: in span spanName if set, measured against its budget, or
+// skipped over it.
+func (s *Stream) step(ctx context.Context, name, spanName string, fn func(ctx context.Context) error) error {
+	t := s.budgets[name]
+	if t.Skip() {
+		s.metrics.Skipped(name)
+		return nil
+	}
+	start := time.Now()
+	var err error
+	if spanName != "" {
+		err = span(ctx, s.trace.tracer, spanName, fn)
+	} else {
+		err = fn(ctx)
+	}
+	d := time.Since(start)
+	s.metrics.SegmentStage(name, d)
+	s.measured(name, t, d)
+	return err
+}
+
+// measured records that stage name took d against its budget t, reporting
+// the start of a breach.
+func (s *Stream) measured(name string, t *budget.Tracker, d time.Duration) {
+	if !t.Observe(d) {
+		return
+	}
+	s.metrics.Breach(name)
+	b := t.Config()
+	s.log.Warn("stage over latency budget", "stage", name, "average", t.Average(), "budget", b.Latency,
+		"action", b.Action.String())
+}
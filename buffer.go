@@ -0,0 +1,174 @@
+package voxa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/metrics"
+	"github.com/jmarc101/voxa/internal/queue"
+)
+
+// OverflowPolicy selects what a full buffer does with new audio.
+type OverflowPolicy = queue.Policy
+
+// Overflow policies.
+const (
+	// OverflowBlock makes the writer wait, pushing back on the source.
+	OverflowBlock = queue.Block
+	// OverflowDropOldest discards the oldest queued frame, keeping latency
+	// bounded at the cost of gaps in the audio.
+	OverflowDropOldest = queue.DropOldest
+	// OverflowDropNewest discards the frame being written.
+	OverflowDropNewest = queue.DropNewest
+)
+
+// ParseOverflowPolicy returns the policy named "block", "drop-oldest" or
+// "drop-newest".
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	return queue.ParsePolicy(s)
+}
+
+// BufferConfig bounds the audio queued ahead of a stream's stages.
+type BufferConfig struct {
+	// Frames is how many written frames may wait. Defaults to 50, a second
+	// of 20ms frames.
+	Frames int
+	// Overflow applies to writes into a full buffer. Defaults to
+	// OverflowBlock. Flushes are never dropped.
+	Overflow OverflowPolicy
+}
+
+// maxBatch bounds the inputs drain takes at once, 200ms of 20ms frames, so
+// an overflowing buffer that drops audio still drops all but the freshest.
+const maxBatch = 10
+
+// input is a write or a flush queued in a stream's buffer. Frames come
+// from the frame pool and go back once processed.
+type input struct {
+	frame audio.Frame
+	flush bool
+}
+
+// buffer decouples the writer of a stream from its stages. The writer
+// queues inputs in a lock-free ring; drain takes up to maxBatch at once
+// and processes them in order, the frames between two flushes in one pass
+// through the stages, until the buffer is closed or processing fails.
+type buffer struct {
+	q       *queue.Ring[input]
+	drained chan struct{}
+	batch   []input       // taken by drain, reused
+	frames  []audio.Frame // of the batch up to a flush
+
+	mu  sync.Mutex
+	err error // why drain stopped early
+}
+
+func newBuffer(cfg BufferConfig, m *metrics.Metrics) (*buffer, error) {
+	if cfg.Frames == 0 {
+		cfg.Frames = 50
+	}
+	q, err := queue.NewRing(queue.Options[input]{
+		Name:    "input",
+		Size:    cfg.Frames,
+		Policy:  cfg.Overflow,
+		Keep:    func(in input) bool { return in.flush },
+		Release: func(in input) { in.frame.Release() },
+		Metrics: m,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("voxa: %w", err)
+	}
+	return &buffer{q: q, drained: make(chan struct{}), batch: make([]input, 0, min(cfg.Frames, maxBatch))}, nil
+}
+
+// push queues in. It returns the error that stopped processing, if any,
+// or ctx's once it is done.
+func (b *buffer) push(ctx context.Context, in input) error {
+	if err := b.failed(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := b.q.Push(ctx, in)
+	if errors.Is(err, queue.ErrClosed) {
+		if ferr := b.failed(); ferr != nil {
+			return ferr
+		}
+	}
+	return err
+}
+
+func (b *buffer) failed() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// drain runs the queued inputs through s until the buffer is closed and
+// empty, s's context is done, or one fails.
+func (b *buffer) drain(s *Stream) {
+	defer close(b.drained)
+	for {
+		batch, ok := b.q.PopBatch(s.ctx, b.batch[:0])
+		if !ok {
+			return
+		}
+		if err := b.process(s, batch); err != nil {
+			s.log.Error("buffered audio processing failed", "error", err)
+			b.mu.Lock()
+			b.err = err
+			b.mu.Unlock()
+			b.q.Close()
+			b.q.Discard()
+			return
+		}
+	}
+}
+
+// process runs a batch of inputs through s, releasing its frames.
+func (b *buffer) process(s *Stream, batch []input) error {
+	defer func() {
+		for i := range batch {
+			batch[i].frame.Release()
+			batch[i] = input{}
+		}
+	}()
+	b.frames = b.frames[:0]
+	for _, in := range batch {
+		if !in.flush {
+			b.frames = append(b.frames, in.frame)
+			continue
+		}
+		if err := b.run(s); err != nil {
+			return err
+		}
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+	return b.run(s)
+}
+
+// run passes the frames gathered so far through s.
+func (b *buffer) run(s *Stream) error {
+	if len(b.frames) == 0 {
+		return nil
+	}
+	err := s.run(b.frames, s.stages)
+	clear(b.frames)
+	b.frames = b.frames[:0]
+	return err
+}
+
+// close waits for the queued inputs to be processed. It returns the error
+// that stopped processing, if any.
+func (b *buffer) close() error {
+	b.q.Close()
+	<-b.drained
+	b.q.Discard() // left behind when the context ended
+	return b.failed()
+}
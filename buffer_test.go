@@ -0,0 +1,89 @@
+package voxa
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// frame returns an input of a frame whose one sample is n, or a flush for a
+// negative n.
+func frame(n int) input {
+	if n < 0 {
+		return input{flush: true}
+	}
+	return input{frame: audio.Frame{Data: []int16{int16(n)}}}
+}
+
+// queued returns what b holds, frames as their sample and flushes as -1.
+func queued(t *testing.T, b *buffer) []int {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	batch, _ := b.q.PopBatch(ctx, nil)
+	var got []int
+	for _, in := range batch {
+		if in.flush {
+			got = append(got, -1)
+		} else {
+			got = append(got, int(in.frame.Data[0]))
+		}
+	}
+	return got
+}
+
+func TestBufferOverflow(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		policy  OverflowPolicy
+		pushes  []int // -1 flushes
+		want    []int
+		dropped int
+		blocked bool // the last push waits for room
+	}{
+		{"block", OverflowBlock, []int{1, 2, 3}, []int{1, 2}, 0, true},
+		{"drop oldest", OverflowDropOldest, []int{1, 2, 3, 4}, []int{3, 4}, 2, false},
+		{"drop newest", OverflowDropNewest, []int{1, 2, 3, 4}, []int{1, 2}, 2, false},
+		{"drop oldest keeps a flush", OverflowDropOldest, []int{1, -1, 2}, []int{-1, 2}, 1, false},
+		{"drop oldest behind a flush", OverflowDropOldest, []int{-1, 1, 2}, []int{-1, 1}, 0, true},
+		{"drop newest keeps a flush", OverflowDropNewest, []int{1, 2, -1}, []int{1, 2}, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMetrics()
+			b, err := newBuffer(BufferConfig{Frames: 2, Overflow: tc.policy}, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, n := range tc.pushes {
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				err := b.push(ctx, frame(n))
+				cancel()
+				if last := i == len(tc.pushes)-1; last && tc.blocked {
+					if !errors.Is(err, context.DeadlineExceeded) {
+						t.Fatalf("push into a full buffer: %v, want it waiting", err)
+					}
+				} else if err != nil {
+					t.Fatalf("push %d: %v", i+1, err)
+				}
+			}
+			if got := b.q.Dropped(); got != tc.dropped {
+				t.Errorf("dropped %d, want %d", got, tc.dropped)
+			}
+			if got := queued(t, b); !slices.Equal(got, tc.want) {
+				t.Errorf("queued\n got %v\nwant %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBufferConfig(t *testing.T) {
+	for _, cfg := range []BufferConfig{{Frames: -1}, {Overflow: OverflowDropNewest + 1}} {
+		if _, err := newBuffer(cfg, nil); err == nil {
+			t.Errorf("newBuffer(%+v): no error", cfg)
+		}
+	}
+}
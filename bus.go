@@ -0,0 +1,209 @@
+package voxa
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jmarc101/voxa/internal/metrics"
+	"github.com/jmarc101/voxa/internal/queue"
+)
+
+// subscriptionQueue bounds the events waiting for a subscriber.
+const subscriptionQueue = 1024
+
+// noWait is a done context, for pushes that must not wait.
+var noWait = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// Subscription is a handler receiving the events of a pipeline; see
+// Pipeline.Subscribe.
+type Subscription struct {
+	bus    *bus
+	typ    EventType // zero for all
+	h      func(Event)
+	q      *queue.Queue[Event]
+	lost   atomic.Int64 // events that had to be kept, dropped all the same
+	warned atomic.Bool
+	closed atomic.Bool
+}
+
+// bus fans the events of the streams of a pipeline out to its
+// subscribers.
+type bus struct {
+	log     Logger
+	metrics *metrics.Metrics
+	n       atomic.Int32 // subscribers, checked before events are made
+
+	mu   sync.RWMutex
+	subs []*Subscription
+}
+
+// Subscribe calls h with the events of type t of every stream of p, such
+// as EventFinal for the final segments, as they happen. It replaces the
+// callbacks of the stages and of StreamOptions for applications that
+// handle the events of all their streams in one place.
+//
+// h runs on a goroutine of the subscription, one event at a time, in the
+// order the events were published, so a slow handler never holds up the
+// streams: up to 1024 events wait for it, and when more come, the oldest
+// partial segments and VAD transitions waiting are dropped, then, if the
+// handler is that far behind, the new events. Dropped counts them. What
+// the events point to is h's to keep but not to modify, as other
+// subscribers share it.
+func (p *Pipeline) Subscribe(t EventType, h func(Event)) *Subscription {
+	return p.bus.subscribe(t, h)
+}
+
+// SubscribeAll is Subscribe for the events of every type.
+func (p *Pipeline) SubscribeAll(h func(Event)) *Subscription {
+	return p.bus.subscribe(0, h)
+}
+
+func (b *bus) subscribe(t EventType, h func(Event)) *Subscription {
+	sub := &Subscription{bus: b, typ: t, h: h}
+	sub.q, _ = queue.New(queue.Options[Event]{
+		Name:   "subscription",
+		Size:   subscriptionQueue,
+		Policy: queue.DropOldest,
+		Keep: func(ev Event) bool {
+			return ev.Type != EventPartial && ev.Type != EventVAD
+		},
+		Release: func(ev Event) { sub.dropped(ev) },
+		Metrics: b.metrics,
+	})
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.n.Add(1)
+	b.mu.Unlock()
+	go sub.run()
+	return sub
+}
+
+// active reports whether b has subscribers. A nil bus has none.
+func (b *bus) active() bool {
+	return b != nil && b.n.Load() > 0
+}
+
+// publish hands a copy of ev to the subscribers to its type.
+func (b *bus) publish(ev Event) {
+	if !b.active() {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	copied := false
+	for _, sub := range b.subs {
+		if sub.typ != 0 && sub.typ != ev.Type {
+			continue
+		}
+		if !copied {
+			ev, copied = ev.clone(), true
+		}
+		if err := sub.q.Push(noWait, ev); err != nil && !errors.Is(err, queue.ErrClosed) {
+			sub.lost.Add(1)
+			sub.dropped(ev)
+		}
+	}
+}
+
+// close stops the subscriptions once they have handled the events
+// waiting.
+func (b *bus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sub := range b.subs {
+		sub.q.Close()
+	}
+	b.subs = nil
+	b.n.Store(0)
+}
+
+func (sub *Subscription) run() {
+	for {
+		ev, ok := sub.q.Pop(context.Background())
+		if !ok || sub.closed.Load() {
+			return
+		}
+		sub.h(ev)
+	}
+}
+
+// dropped logs the first event dropped for sub falling behind.
+func (sub *Subscription) dropped(ev Event) {
+	if sub.warned.CompareAndSwap(false, true) {
+		sub.bus.log.Warn("subscriber falling behind, events dropped", "event", ev.Type, "session", ev.SessionID)
+	}
+}
+
+// Dropped returns how many events were dropped for the handler falling
+// behind.
+func (sub *Subscription) Dropped() int {
+	return sub.q.Dropped() + int(sub.lost.Load())
+}
+
+// Close unsubscribes the handler and drops the events waiting for it. The
+// handler is not called again once the call it may be in returns; Close
+// does not wait for it, so the handler may close its own subscription.
+func (sub *Subscription) Close() {
+	if !sub.closed.CompareAndSwap(false, true) {
+		return
+	}
+	b := sub.bus
+	b.mu.Lock()
+	if i := slices.Index(b.subs, sub); i >= 0 {
+		b.subs = slices.Delete(b.subs, i, i+1)
+		b.n.Add(-1)
+	}
+	b.mu.Unlock()
+	sub.q.Close()
+	sub.q.Discard()
+}
+
+// clone copies what ev points to, which is only valid while it is
+// published.
+func (ev Event) clone() Event {
+	if d := ev.WakeWord; d != nil {
+		c := *d
+		ev.WakeWord = &c
+	}
+	if seg := ev.Segment; seg != nil {
+		c := *seg
+		c.Words = slices.Clone(c.Words)
+		c.Translations = maps.Clone(c.Translations)
+		c.Redactions = slices.Clone(c.Redactions)
+		if st := c.Sentiment; st != nil {
+			sc := *st
+			c.Sentiment = &sc
+		}
+		ev.Segment = &c
+	}
+	if in := ev.Intent; in != nil {
+		c := *in
+		c.Slots = maps.Clone(c.Slots)
+		ev.Intent = &c
+	}
+	if a := ev.Alert; a != nil {
+		c := *a
+		ev.Alert = &c
+	}
+	if d := ev.DTMF; d != nil {
+		c := *d
+		ev.DTMF = &c
+	}
+	if d := ev.AMD; d != nil {
+		c := *d
+		ev.AMD = &c
+	}
+	if v := ev.VAD; v != nil {
+		c := *v
+		ev.VAD = &c
+	}
+	return ev
+}
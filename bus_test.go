@@ -0,0 +1,79 @@
+package voxa_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+)
+
+func TestSubscribeFanOut(t *testing.T) {
+	p := newChatty(t, voxa.Config{})
+	got := [2]chan voxa.Event{make(chan voxa.Event, 4), make(chan voxa.Event, 4)}
+	for _, ch := range got {
+		sub := p.SubscribeAll(func(ev voxa.Event) {
+			if ev.Type != voxa.EventPartial {
+				ch <- ev
+			}
+		})
+		defer sub.Close()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := p.NewStream(ctx, voxa.AudioFormat{SampleRate: 16000, Channels: 1}, voxa.StreamOptions{SessionID: "fan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for range s.Results() {
+	}
+	for i, ch := range got {
+		for _, want := range []voxa.EventType{voxa.EventSessionStart, voxa.EventSessionEnd} {
+			select {
+			case ev := <-ch:
+				if ev.Type != want || ev.SessionID != "fan" {
+					t.Fatalf("subscriber %d got %s of %q, want %s of fan", i, ev.Type, ev.SessionID, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("subscriber %d got no %s", i, want)
+			}
+		}
+	}
+}
+
+func TestSlowSubscriberDropsPartials(t *testing.T) {
+	p := newChatty(t, voxa.Config{})
+	release := make(chan struct{})
+	defer close(release)
+	slow := p.Subscribe(voxa.EventPartial, func(voxa.Event) { <-release })
+	defer slow.Close()
+	ended := make(chan voxa.Event, 1)
+	end := p.Subscribe(voxa.EventSessionEnd, func(ev voxa.Event) { ended <- ev })
+	defer end.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := p.NewStream(ctx, voxa.AudioFormat{SampleRate: 16000, Channels: 1}, voxa.StreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stream keeps up with the recognizer while the handler is stuck.
+	deadline := time.After(5 * time.Second)
+	for n := 0; slow.Dropped() == 0; n++ {
+		select {
+		case <-s.Results():
+		case <-deadline:
+			t.Fatalf("no partial dropped after %d delivered", n)
+		}
+	}
+	cancel()
+	for range s.Results() {
+	}
+	select {
+	case ev := <-ended:
+		if ev.SessionID != s.SessionID() {
+			t.Fatalf("session_end of %q, want %q", ev.SessionID, s.SessionID())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a slow subscriber held up the others")
+	}
+}
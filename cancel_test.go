@@ -0,0 +1,181 @@
+package voxa_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// chatty is a recognizer that keeps emitting partials until its context
+// ends or it is closed, like a backend in the middle of a long utterance.
+type chatty struct {
+	results chan stt.Segment
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func (c *chatty) Write(p []byte) (int, error) { return len(p), nil }
+func (c *chatty) Results() <-chan stt.Segment { return c.results }
+func (c *chatty) Flush() error                { return nil }
+func (c *chatty) Close() error                { c.once.Do(func() { close(c.closed) }); return nil }
+func (c *chatty) Err() error                  { return nil }
+
+func (c *chatty) run(ctx context.Context) {
+	defer close(c.results)
+	for {
+		select {
+		case c.results <- stt.Segment{Text: "and then"}:
+		case <-ctx.Done():
+			return
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+type chattyProvider struct{}
+
+func (chattyProvider) NewStream(ctx context.Context, _ stt.StreamConfig) (stt.StreamingRecognizer, error) {
+	c := &chatty{results: make(chan stt.Segment), closed: make(chan struct{})}
+	go c.run(ctx)
+	return c, nil
+}
+
+func init() {
+	stt.Register("test-chatty", func(stt.Config) (stt.Provider, error) { return chattyProvider{}, nil })
+}
+
+// endless is a source that never runs out.
+type endless struct{ chunk []int16 }
+
+func (endless) Format() audio.Format { return audio.Format{SampleRate: 16000, Channels: 1} }
+
+func (e endless) ReadFrame() (audio.Frame, error) {
+	return audio.Frame{Format: e.Format(), Data: e.chunk}.Clone(), nil
+}
+
+func newChatty(t *testing.T, cfg voxa.Config) *voxa.Pipeline {
+	t.Helper()
+	cfg.Recognizer.Provider = "test-chatty"
+	p, err := voxa.NewPipeline(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// closes fails the test unless ch is closed within a second.
+func closes[T any](t *testing.T, ch <-chan T) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("not closed after cancel")
+		}
+	}
+}
+
+func TestCancelMidStream(t *testing.T) {
+	for _, bc := range []struct {
+		name string
+		cfg  voxa.Config
+	}{
+		{"plain", voxa.Config{}},
+		{"buffered", voxa.Config{Buffer: &voxa.BufferConfig{}}},
+	} {
+		t.Run(bc.name, func(t *testing.T) {
+			p := newChatty(t, bc.cfg)
+			ctx, cancel := context.WithCancel(context.Background())
+			s, err := p.NewStream(ctx, audio.Format{SampleRate: 16000, Channels: 1}, voxa.StreamOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Write(pcm()); err != nil {
+				t.Fatal(err)
+			}
+			<-s.Results() // recognition is under way
+
+			// Nobody reads Results any more: cancelling must still end
+			// the stream.
+			cancel()
+			time.Sleep(10 * time.Millisecond)
+			closes(t, s.Results())
+			if err := s.Err(); !errors.Is(err, context.Canceled) {
+				t.Errorf("Err = %v, want context.Canceled", err)
+			}
+			if _, err := s.Write(pcm()); !errors.Is(err, context.Canceled) {
+				t.Errorf("Write after cancel = %v, want context.Canceled", err)
+			}
+			if err := s.Flush(); !errors.Is(err, context.Canceled) {
+				t.Errorf("Flush after cancel = %v, want context.Canceled", err)
+			}
+			_ = s.Close()
+		})
+	}
+}
+
+func TestCancelBlockedWrite(t *testing.T) {
+	// With a one-frame buffer that blocks, the writer keeps waiting for
+	// room.
+	p := newChatty(t, voxa.Config{
+		Buffer: &voxa.BufferConfig{Frames: 1, Overflow: voxa.OverflowBlock},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := p.NewStream(ctx, audio.Format{SampleRate: 16000, Channels: 1}, voxa.StreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := s.Write(pcm()); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Write = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Write still blocked after cancel")
+	}
+	closes(t, s.Results())
+	_ = s.Close()
+}
+
+func TestCancelRun(t *testing.T) {
+	p := newChatty(t, voxa.Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	src := endless{chunk: audio.DecodePCM16(nil, pcm())}
+
+	errc := make(chan error, 1)
+	var once sync.Once
+	go func() {
+		errc <- p.Run(ctx, src, func(voxa.Segment) { once.Do(cancel) })
+	}()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run still going after cancel")
+	}
+}
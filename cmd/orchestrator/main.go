@@ -1,7 +1,183 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/capture"
+	"github.com/jmarc101/voxa/internal/clients/asr"
+	"github.com/jmarc101/voxa/internal/logging"
+)
 
 func main() {
-	fmt.Println("Orchestrator")
+	provider := flag.String("stt", asr.ProviderName, "STT provider name")
+	asrAddr := flag.String("asr", asr.DefaultAddr, "ASR sidecar address")
+	sttOpts := flag.String("stt-opts", "", "comma-separated key=value options for the STT provider, e.g. model=ggml-base.en.bin")
+	wavPath := flag.String("wav", "assets/audio/hello_world.wav", "audio file (WAV, FLAC or MP3) to stream instead of the mic")
+	useMic := flag.Bool("mic", false, "stream from the microphone instead of -wav")
+	device := flag.String("device", "", "capture device ID or name for -mic; empty selects the default input")
+	listDevices := flag.Bool("devices", false, "list capture devices and exit")
+	useVAD := flag.Bool("vad", true, "gate audio on voice activity and end utterances on silence")
+	diarize := flag.Bool("diarize", false, "label utterances with their speaker")
+	denoise := flag.Float64("denoise", 0, "noise suppression strength in (0, 1]; 0 disables")
+	subs := flag.String("subs", "", "write the transcript to this .srt or .vtt file")
+	logLevel := flag.String("log-level", "info", "least severe log level written: debug, info, warn or error")
+	flag.Parse()
+
+	logger, err := logging.New(os.Stderr, *logLevel, "text")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "orchestrator:", err)
+		os.Exit(2)
+	}
+	fatal := func(err error) {
+		logger.Error("orchestrator failed", "error", err)
+		os.Exit(1)
+	}
+
+	if *listDevices {
+		if err := printDevices(); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg := voxa.Config{
+		Recognizer: voxa.RecognizerConfig{
+			Provider: *provider,
+			Options:  map[string]string{"addr": *asrAddr},
+		},
+		Logger: logger,
+	}
+	for _, kv := range strings.Split(*sttOpts, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			cfg.Recognizer.Options[k] = v
+		}
+	}
+	if *useVAD {
+		cfg.VAD = &voxa.VADConfig{}
+	}
+	if *denoise > 0 {
+		cfg.Denoise = &voxa.DenoiseConfig{Strength: *denoise}
+	}
+	if *diarize {
+		cfg.Diarization = &voxa.DiarizationConfig{}
+	}
+	src, err := openSource(*useMic, *device, *wavPath, logger)
+	if err != nil {
+		fatal(err)
+	}
+	defer src.Close()
+	// Interrupting ends a microphone session normally.
+	if err := run(ctx, cfg, src, *subs); err != nil && !errors.Is(err, context.Canceled) {
+		fatal(err)
+	}
+}
+
+// source is an audio input the orchestrator can stream from.
+type source interface {
+	audio.Reader
+	io.Closer
+}
+
+// openSource opens the microphone or the audio file.
+func openSource(mic bool, device, wavPath string, logger voxa.Logger) (source, error) {
+	if !mic {
+		return audio.Open(wavPath)
+	}
+	return capture.Open(capture.Config{
+		Device: device,
+		OnDeviceChange: func(available bool) {
+			if available {
+				logger.Info("capture device back", "device", device)
+			} else {
+				logger.Warn("capture device lost, waiting for it", "device", device)
+			}
+		},
+	})
+}
+
+// printDevices lists the audio devices, marking the default input with *
+// and the default output with +.
+func printDevices() error {
+	devs, err := capture.ListDevices()
+	if err != nil {
+		return err
+	}
+	for _, d := range devs {
+		mark := []byte("  ")
+		if d.DefaultInput {
+			mark[0] = '*'
+		}
+		if d.DefaultOutput {
+			mark[1] = '+'
+		}
+		fmt.Printf("%s %-9s %-40q in:%d out:%d rates:%v  %s\n",
+			mark, d.Backend, d.ID, d.InputChannels, d.OutputChannels, d.SampleRates, d.Name)
+	}
+	return nil
+}
+
+// run streams src through the pipeline and prints hypotheses as they
+// arrive: partials are redrawn in place, finals are committed on their own
+// line. If subsPath is set the finals are also saved as subtitles.
+func run(ctx context.Context, cfg voxa.Config, src audio.Reader, subsPath string) error {
+	p, err := voxa.NewPipeline(cfg)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	var finals []voxa.Segment
+	err = p.Run(ctx, src, func(seg voxa.Segment) {
+		who := ""
+		if seg.Speaker != "" {
+			who = seg.Speaker + ": "
+		}
+		if seg.Final {
+			finals = append(finals, seg)
+			fmt.Printf("\r\033[K[final] %s%s\n", who, seg.Text)
+			return
+		}
+		fmt.Printf("\r\033[K[%.2f] %s%s", seg.Stability, who, seg.Text)
+	})
+	if subsPath == "" || (err != nil && !errors.Is(err, context.Canceled)) {
+		return err
+	}
+	if serr := writeSubtitles(subsPath, finals, cfg.Diarization != nil); serr != nil {
+		return serr
+	}
+	return err
+}
+
+// writeSubtitles saves segs as SubRip or WebVTT, picked by the extension.
+func writeSubtitles(path string, segs []voxa.Segment, speakers bool) error {
+	write := voxa.WriteSRT
+	switch ext := filepath.Ext(path); ext {
+	case ".srt":
+	case ".vtt":
+		write = voxa.WriteVTT
+	default:
+		return fmt.Errorf("unknown subtitle format %q", ext)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, segs, voxa.SubtitleOptions{Speakers: speakers}); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
 }
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/server"
+)
+
+// clip is a recording bench replays, decoded to mono.
+type clip struct {
+	path  string
+	audio audio.Frame
+}
+
+// timing is the latencies of one session.
+type timing struct {
+	firstPartial, final []time.Duration
+	// endToEnd runs from the end of the audio to the server closing the
+	// session, after the last result.
+	endToEnd time.Duration
+	audio    time.Duration
+	dropped  int
+}
+
+// benchStats gathers the timings of every session of a run.
+type benchStats struct {
+	mu           sync.Mutex
+	firstPartial []time.Duration
+	final        []time.Duration
+	endToEnd     []time.Duration
+	audio        time.Duration
+	dropped      int
+	sessions     int
+	errors       map[string]int
+}
+
+func (s *benchStats) add(t timing, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions++
+	if err != nil {
+		s.errors[err.Error()]++
+		return
+	}
+	s.firstPartial = append(s.firstPartial, t.firstPartial...)
+	s.final = append(s.final, t.final...)
+	s.endToEnd = append(s.endToEnd, t.endToEnd)
+	s.audio += t.audio
+	s.dropped += t.dropped
+}
+
+func (s *benchStats) failed() int {
+	n := 0
+	for _, c := range s.errors {
+		n += c
+	}
+	return n
+}
+
+// latencies summarizes a set of latencies, in milliseconds as -json
+// writes them.
+type latencies struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+func summarize(ds []time.Duration) latencies {
+	if len(ds) == 0 {
+		return latencies{}
+	}
+	ds = slices.Clone(ds)
+	slices.Sort(ds)
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	// Nearest rank.
+	at := func(p float64) float64 {
+		i := int(p*float64(len(ds))+0.999999) - 1
+		return ms(ds[max(0, min(i, len(ds)-1))])
+	}
+	return latencies{len(ds), at(0.50), at(0.90), at(0.95), at(0.99), ms(ds[len(ds)-1])}
+}
+
+func bench(ctx context.Context, args []string) error {
+	fl := flag.NewFlagSet("bench", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), `usage: voxa bench [flags] <files or directories...>
+
+Replays recordings as concurrent streams against the WebSocket endpoint of a
+voxad, each recording a session, and reports the latency percentiles of:
+
+  first-partial  from sending the start of an utterance to its first result
+  final          from sending the end of an utterance to its final result
+  end-to-end     from sending the end of a recording to the session closing
+                 after its last result
+
+Every stream replays the recordings in turn, from a different one, until
+-duration has elapsed, or once through all of them without it. Interrupting
+stops the run and reports what was measured.`)
+		fl.PrintDefaults()
+	}
+	addr := fl.String("server", "localhost:7080", "HTTP address of the voxad, or a ws:// or wss:// URL of its transcribe endpoint")
+	key := fl.String("key", "", "API key of the voxad")
+	streams := fl.Int("streams", 10, "concurrent streams")
+	duration := fl.Duration("duration", 0, "keep starting sessions for this long")
+	ramp := fl.Duration("ramp", 0, "spread the start of the streams over this long")
+	speed := fl.Float64("speed", 1, "send audio at this multiple of real time; 0 sends it as fast as the server takes it")
+	chunk := fl.Duration("chunk", 100*time.Millisecond, "audio per message")
+	useVAD := fl.Bool("vad", true, "ask the server for voice activity gating, which ends utterances at pauses")
+	asJSON := fl.Bool("json", false, "write the report as JSON")
+	_ = fl.Parse(args)
+	if fl.NArg() == 0 {
+		fl.Usage()
+		os.Exit(2)
+	}
+	switch {
+	case *streams <= 0:
+		return errors.New("-streams must be positive")
+	case *speed < 0:
+		return errors.New("-speed must not be negative")
+	case *chunk < 10*time.Millisecond || *chunk > time.Second:
+		return errors.New("-chunk must be between 10ms and 1s")
+	}
+	target := benchURL(*addr)
+	header := http.Header{}
+	if *key != "" {
+		header.Set("Authorization", "Bearer "+*key)
+	}
+
+	todo, err := collect(fl.Args(), "")
+	if err != nil {
+		return err
+	}
+	if len(todo) == 0 {
+		return errors.New("no audio files")
+	}
+	clips := make([]clip, 0, len(todo))
+	for _, j := range todo {
+		fr, err := loadClip(j.path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", j.path, err)
+		}
+		clips = append(clips, clip{path: j.path, audio: fr})
+	}
+
+	rc := replay{url: target, header: header, speed: *speed, chunk: *chunk, vad: *useVAD}
+	stats := &benchStats{errors: map[string]int{}}
+	var deadline time.Time
+	began := time.Now()
+	if *duration > 0 {
+		deadline = began.Add(*duration)
+	}
+	var wg sync.WaitGroup
+	for i := range *streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if *ramp > 0 && *streams > 1 {
+				select {
+				case <-time.After(*ramp * time.Duration(i) / time.Duration(*streams-1)):
+				case <-ctx.Done():
+					return
+				}
+			}
+			for n := 0; ; n++ {
+				if ctx.Err() != nil || (deadline.IsZero() && n == len(clips)) || (!deadline.IsZero() && time.Now().After(deadline)) {
+					return
+				}
+				t, err := rc.session(ctx, clips[(i+n)%len(clips)])
+				if ctx.Err() != nil {
+					return // cut short, not measured
+				}
+				stats.add(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(began)
+
+	if err := writeBench(os.Stdout, stats, *streams, elapsed, *asJSON); err != nil {
+		return err
+	}
+	if n := stats.failed(); n > 0 {
+		return fmt.Errorf("%d of %d sessions failed", n, stats.sessions)
+	}
+	return nil
+}
+
+// benchURL returns the transcribe endpoint of the voxad at addr.
+func benchURL(addr string) string {
+	switch {
+	case strings.HasPrefix(addr, "ws://"), strings.HasPrefix(addr, "wss://"):
+		return addr
+	case strings.HasPrefix(addr, "https://"):
+		addr = "wss://" + strings.TrimPrefix(addr, "https://")
+	case strings.HasPrefix(addr, "http://"):
+		addr = "ws://" + strings.TrimPrefix(addr, "http://")
+	default:
+		addr = "ws://" + addr
+	}
+	return strings.TrimSuffix(addr, "/") + "/v1/transcribe"
+}
+
+// loadClip decodes the recording at path into one mono frame.
+func loadClip(path string) (audio.Frame, error) {
+	f, err := audio.Open(path)
+	if err != nil {
+		return audio.Frame{}, err
+	}
+	defer f.Close()
+	format := f.Format()
+	fr := audio.Frame{Format: format}
+	for {
+		part, err := f.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return audio.Frame{}, err
+		}
+		fr.Data = append(fr.Data, part.Data...)
+		part.Release()
+	}
+	if format.Channels != 1 {
+		return audio.Resample(fr, audio.Format{SampleRate: format.SampleRate, Channels: 1}, audio.QualityMedium)
+	}
+	return fr, nil
+}
+
+// replay runs bench sessions.
+type replay struct {
+	url    string
+	header http.Header
+	speed  float64
+	chunk  time.Duration
+	vad    bool
+}
+
+// sent is when the audio up to a time into the recording was sent.
+type sent struct {
+	upTo time.Duration
+	at   time.Time
+}
+
+// session replays c as one session and times its results.
+func (r replay) session(ctx context.Context, c clip) (timing, error) {
+	conn, _, err := websocket.Dial(ctx, r.url, &websocket.DialOptions{HTTPHeader: r.header})
+	if err != nil {
+		return timing{}, err
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(1 << 20)
+
+	format := c.audio.Format
+	if err := writeControl(ctx, conn, server.ClientMessage{Type: server.MsgStart, SampleRate: format.SampleRate, VAD: r.vad}); err != nil {
+		return timing{}, err
+	}
+	var started server.ServerMessage
+	if err := readServer(ctx, conn, &started); err != nil {
+		return timing{}, err
+	}
+	if started.Type != server.MsgStarted {
+		return timing{}, fmt.Errorf("server: %s", started.Error)
+	}
+
+	var (
+		mu     sync.Mutex
+		marks  []sent
+		ended  time.Time
+		t      = timing{audio: c.audio.Duration()}
+		failed error
+	)
+	// sentAt returns when the audio at d was sent, or the end of the audio
+	// for times past it.
+	sentAt := func(d time.Duration) time.Time {
+		i := sort.Search(len(marks), func(i int) bool { return marks[i].upTo >= d })
+		if i == len(marks) {
+			if !ended.IsZero() {
+				return ended
+			}
+			i = len(marks) - 1
+		}
+		return marks[i].at
+	}
+	results := make(chan time.Time, 1)
+	go func() {
+		seen := map[string]bool{}
+		for {
+			var m server.ServerMessage
+			err := readServer(ctx, conn, &m)
+			now := time.Now()
+			if err != nil {
+				mu.Lock()
+				if websocket.CloseStatus(err) != websocket.StatusNormalClosure && failed == nil {
+					failed = err
+				}
+				mu.Unlock()
+				results <- now
+				return
+			}
+			mu.Lock()
+			t.dropped += m.Dropped
+			switch {
+			case m.Type == server.MsgError:
+				failed = fmt.Errorf("server: %s", m.Error)
+			case m.Type == server.MsgSegment && m.Segment != nil && len(marks) > 0:
+				seg := m.Segment
+				if !seen[seg.UtteranceID] {
+					seen[seg.UtteranceID] = true
+					at := sentAt(time.Duration(seg.StartMS) * time.Millisecond)
+					t.firstPartial = append(t.firstPartial, max(0, now.Sub(at)))
+				}
+				if seg.Final {
+					at := sentAt(time.Duration(seg.EndMS) * time.Millisecond)
+					t.final = append(t.final, max(0, now.Sub(at)))
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	step := format.Samples(r.chunk)
+	begin := time.Now()
+	for pos := 0; pos < len(c.audio.Data); pos += step {
+		end := min(pos+step, len(c.audio.Data))
+		if r.speed > 0 {
+			due := begin.Add(time.Duration(float64(format.Duration(pos)) / r.speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return timing{}, ctx.Err()
+				}
+			}
+		}
+		if err := conn.Write(ctx, websocket.MessageBinary, audio.AppendPCM16(nil, c.audio.Data[pos:end])); err != nil {
+			return timing{}, err
+		}
+		mu.Lock()
+		marks = append(marks, sent{format.Duration(end), time.Now()})
+		mu.Unlock()
+	}
+	if err := writeControl(ctx, conn, server.ClientMessage{Type: server.MsgEnd}); err != nil {
+		return timing{}, err
+	}
+	mu.Lock()
+	ended = time.Now()
+	mu.Unlock()
+
+	closed := <-results
+	mu.Lock()
+	defer mu.Unlock()
+	if failed != nil {
+		return timing{}, failed
+	}
+	t.endToEnd = closed.Sub(ended)
+	return t, nil
+}
+
+func writeControl(ctx context.Context, conn *websocket.Conn, m server.ClientMessage) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return conn.Write(ctx, websocket.MessageText, b)
+}
+
+func readServer(ctx context.Context, conn *websocket.Conn, m *server.ServerMessage) error {
+	typ, data, err := conn.Read(ctx)
+	if err != nil {
+		return err
+	}
+	if typ != websocket.MessageText {
+		return errors.New("unexpected binary message")
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("bad server message: %w", err)
+	}
+	return nil
+}
+
+// writeBench writes the report of a run.
+func writeBench(w io.Writer, s *benchStats, streams int, elapsed time.Duration, asJSON bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Realtime is the audio the server transcribed per second of the run,
+	// in seconds.
+	realtime := 0.0
+	if elapsed > 0 {
+		realtime = s.audio.Seconds() / elapsed.Seconds()
+	}
+	rows := []struct {
+		name string
+		l    latencies
+	}{
+		{"first-partial", summarize(s.firstPartial)},
+		{"final", summarize(s.final)},
+		{"end-to-end", summarize(s.endToEnd)},
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Streams      int            `json:"streams"`
+			Sessions     int            `json:"sessions"`
+			Failed       int            `json:"failed"`
+			Errors       map[string]int `json:"errors,omitempty"`
+			ElapsedS     float64        `json:"elapsed_s"`
+			AudioS       float64        `json:"audio_s"`
+			Realtime     float64        `json:"realtime"`
+			Dropped      int            `json:"dropped_partials"`
+			FirstPartial latencies      `json:"first_partial"`
+			Final        latencies      `json:"final"`
+			EndToEnd     latencies      `json:"end_to_end"`
+		}{streams, s.sessions, s.failed(), s.errors, elapsed.Seconds(), s.audio.Seconds(), realtime, s.dropped,
+			rows[0].l, rows[1].l, rows[2].l})
+	}
+	fmt.Fprintf(w, "%d streams, %d sessions (%d failed) in %s: %s of audio, %.1fx real time, %d partials dropped\n\n",
+		streams, s.sessions, s.failed(), elapsed.Round(time.Millisecond), s.audio.Round(time.Second), realtime, s.dropped)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "latency (ms)\tcount\tp50\tp90\tp95\tp99\tmax\t")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\t\n", r.name, r.l.Count, r.l.P50, r.l.P90, r.l.P95, r.l.P99, r.l.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(s.errors) > 0 {
+		msgs := make([]string, 0, len(s.errors))
+		for m := range s.errors {
+			msgs = append(msgs, m)
+		}
+		sort.Strings(msgs)
+		fmt.Fprintln(w, "\nerrors:")
+		for _, m := range msgs {
+			fmt.Fprintf(w, "  %dx %s\n", s.errors[m], m)
+		}
+	}
+	return nil
+}
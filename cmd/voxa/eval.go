@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/eval"
+	"github.com/jmarc101/voxa/internal/itn"
+)
+
+// transcriptExts are the transcripts eval reads, in order of preference
+// when a hypothesis exists in several.
+var transcriptExts = []string{".json", ".txt"}
+
+// scored is the score of one transcript, as -json writes it.
+type scored struct {
+	Reference  string      `json:"reference"`
+	Hypothesis string      `json:"hypothesis,omitempty"`
+	Words      int         `json:"words"`
+	WER        float64     `json:"wer"`
+	CER        float64     `json:"cer"`
+	Errors     eval.Errors `json:"errors"`
+	Alignment  []alignedJS `json:"alignment,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type alignedJS struct {
+	Op  string `json:"op"`
+	Ref string `json:"ref,omitempty"`
+	Hyp string `json:"hyp,omitempty"`
+}
+
+func evalCmd(ctx context.Context, args []string) error {
+	fl := flag.NewFlagSet("eval", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), `usage: voxa eval [flags] <reference> <hypothesis>
+
+Scores hypothesis transcripts against references by word and character
+error rate. Both are files, or directories whose transcripts are paired
+by path without extension: every reference scores against the hypothesis
+at its path in the other. Transcripts are plain text or the JSON of
+voxa transcribe -format json.`)
+		fl.PrintDefaults()
+	}
+	align := fl.Bool("align", false, "print the alignment of every transcript")
+	width := fl.Int("width", 100, "columns of the alignment lines")
+	asJSON := fl.Bool("json", false, "write the scores as JSON")
+	keepCase := fl.Bool("case", false, "compare case-sensitively")
+	keepPunct := fl.Bool("punctuation", false, "compare punctuation")
+	normalize := fl.Bool("normalize", false, "write numbers, dates and amounts of both transcripts in written form first")
+	locale := fl.String("locale", "en-US", "locale of -normalize")
+	_ = fl.Parse(args)
+	if fl.NArg() != 2 {
+		fl.Usage()
+		os.Exit(2)
+	}
+	opts := eval.Options{KeepCase: *keepCase, KeepPunctuation: *keepPunct}
+	if *normalize {
+		n, err := itn.New(itn.Config{Locale: *locale})
+		if err != nil {
+			return err
+		}
+		opts.Normalizer = n
+	}
+	pairs, err := pairTranscripts(fl.Arg(0), fl.Arg(1))
+	if err != nil {
+		return err
+	}
+	if len(pairs) == 0 {
+		return errors.New("no reference transcripts")
+	}
+
+	var (
+		total   eval.Result
+		results []scored
+		failed  int
+	)
+	for _, p := range pairs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sc := scored{Reference: p[0], Hypothesis: p[1]}
+		res, err := scoreFiles(p[0], p[1], opts)
+		if err != nil {
+			failed++
+			sc.Error = err.Error()
+		} else {
+			sc.Words, sc.WER, sc.CER, sc.Errors = res.Words, res.WER(), res.CER(), res.WordErrors
+			if *align {
+				for _, a := range res.Alignment {
+					sc.Alignment = append(sc.Alignment, alignedJS{Op: a.Op.String(), Ref: a.Ref, Hyp: a.Hyp})
+				}
+			}
+			total.Add(res)
+		}
+		results = append(results, sc)
+		if *align && !*asJSON && err == nil {
+			fmt.Printf("%s\n", p[0])
+			if err := eval.WriteAlignment(os.Stdout, res.Alignment, *width); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Files []scored `json:"files"`
+			Words int      `json:"words"`
+			WER   float64  `json:"wer"`
+			CER   float64  `json:"cer"`
+		}{results, total.Words, total.WER(), total.CER()})
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "words\tWER\tCER\tsub\tdel\tins\t  reference")
+		for _, sc := range results {
+			if sc.Error != "" {
+				fmt.Fprintf(tw, "\t\t\t\t\t\t  %s: %s\n", sc.Reference, sc.Error)
+				continue
+			}
+			fmt.Fprintf(tw, "%d\t%.2f%%\t%.2f%%\t%d\t%d\t%d\t  %s\n", sc.Words, 100*sc.WER, 100*sc.CER,
+				sc.Errors.Substitutions, sc.Errors.Deletions, sc.Errors.Insertions, sc.Reference)
+		}
+		if len(results) > 1 {
+			e := total.WordErrors
+			fmt.Fprintf(tw, "%d\t%.2f%%\t%.2f%%\t%d\t%d\t%d\t  total\n", total.Words, 100*total.WER(), 100*total.CER(),
+				e.Substitutions, e.Deletions, e.Insertions)
+		}
+		err = tw.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d transcripts could not be scored", failed, len(pairs))
+	}
+	return nil
+}
+
+// pairTranscripts pairs the reference transcripts under ref with their
+// hypotheses under hyp. Hypotheses missing are paired with an empty path.
+func pairTranscripts(ref, hyp string) ([][2]string, error) {
+	fi, err := os.Stat(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return [][2]string{{ref, hyp}}, nil
+	}
+	var pairs [][2]string
+	err = filepath.WalkDir(ref, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isTranscript(path) || strings.HasSuffix(path, reviewExt) {
+			return err
+		}
+		rel, err := filepath.Rel(ref, path)
+		if err != nil {
+			return err
+		}
+		base := filepath.Join(hyp, strings.TrimSuffix(rel, filepath.Ext(rel)))
+		found := ""
+		for _, ext := range transcriptExts {
+			if _, err := os.Stat(base + ext); err == nil {
+				found = base + ext
+				break
+			}
+		}
+		pairs = append(pairs, [2]string{path, found})
+		return nil
+	})
+	return pairs, err
+}
+
+func isTranscript(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range transcriptExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreFiles scores the transcript at hyp against the one at ref.
+func scoreFiles(ref, hyp string, opts eval.Options) (eval.Result, error) {
+	if hyp == "" {
+		return eval.Result{}, errors.New("no hypothesis")
+	}
+	r, err := readTranscript(ref)
+	if err != nil {
+		return eval.Result{}, err
+	}
+	h, err := readTranscript(hyp)
+	if err != nil {
+		return eval.Result{}, err
+	}
+	return eval.Compare(r, h, opts)
+}
+
+// readTranscript returns the text of the transcript at path.
+func readTranscript(path string) (string, error) {
+	if strings.ToLower(filepath.Ext(path)) != ".json" {
+		b, err := os.ReadFile(path)
+		return string(b), err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	segs, err := voxa.ReadJSON(f)
+	if err != nil {
+		return "", err
+	}
+	texts := make([]string, len(segs))
+	for i, seg := range segs {
+		texts[i] = seg.Text
+	}
+	return strings.Join(texts, " "), nil
+}
@@ -0,0 +1,78 @@
+// Command voxa is the voxa command-line tool.
+//
+// Usage:
+//
+//	voxa transcribe [flags] <files or directories...>
+//	voxa transcribe -resume <manifest> [flags] [files or directories...]
+//	voxa transcribe [flags] -
+//	voxa review [flags] <review files...>
+//	voxa eval [flags] <reference> <hypothesis>
+//	voxa bench [flags] <files or directories...>
+//	voxa search [flags] <query>
+//	voxa reprocess [flags] [session IDs...]
+//	voxa pipeline validate [flags] <config file>
+//	voxa models <pull|list|verify> [flags] [models...]
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+const usage = `usage: voxa <command> [flags] [args]
+
+commands:
+  transcribe   transcribe audio files and write transcripts next to them, or
+               stdin (-) to NDJSON or protobuf on stdout, as in
+               arecord | voxa transcribe -
+  review       merge the corrections of review files into their transcripts
+  eval         score transcripts against references by word error rate
+  bench        replay recordings as concurrent streams against a voxad
+  search       search stored transcripts for words and phrases
+  reprocess    transcribe archived session audio again into new versions
+  pipeline     validate a voxad config and draw its stage graph
+  models       download, pin and verify the models of local backends
+
+Run "voxa <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "transcribe":
+		err = transcribe(ctx, args)
+	case "review":
+		err = reviewCmd(ctx, args)
+	case "eval":
+		err = evalCmd(ctx, args)
+	case "bench":
+		err = bench(ctx, args)
+	case "search":
+		err = search(ctx, args)
+	case "reprocess":
+		err = reprocessCmd(ctx, args)
+	case "pipeline":
+		err = pipelineCmd(ctx, args)
+	case "models":
+		err = modelsCmd(ctx, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "voxa: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "voxa %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+)
+
+// File states of a manifest.
+const (
+	filePending = "pending"
+	fileDone    = "done"
+	fileFailed  = "failed"
+)
+
+// manifest records the progress of a transcription run, written as JSON
+// after every file and every window of a long one, so that a run started
+// again with the same -resume file skips the files done and picks long
+// files up at their last window.
+type manifest struct {
+	path string
+	mu   sync.Mutex
+
+	// Formats and Long are the settings of the run, which runs resuming it
+	// keep.
+	Formats []string      `json:"formats"`
+	Long    *longSettings `json:"long,omitempty"`
+	Files   []*fileState  `json:"files"`
+}
+
+type longSettings struct {
+	Window  string `json:"window"`
+	Overlap string `json:"overlap"`
+}
+
+// fileState is the progress of one file.
+type fileState struct {
+	Path       string `json:"path"`
+	Out        string `json:"out"`
+	State      string `json:"state"`
+	Utterances int    `json:"utterances,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Windows are the windows of a long file transcribed so far, until it
+	// is done.
+	Windows []voxa.LongFormCheckpoint `json:"windows,omitempty"`
+}
+
+// loadManifest reads the manifest at path, or returns an empty one if
+// there is none yet.
+func loadManifest(path string) (*manifest, error) {
+	m := &manifest{path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// longConfig returns the long-form settings of the manifest.
+func (m *manifest) longConfig() (*voxa.LongFormConfig, error) {
+	if m.Long == nil {
+		return nil, nil
+	}
+	window, err := time.ParseDuration(m.Long.Window)
+	if err != nil {
+		return nil, err
+	}
+	overlap, err := time.ParseDuration(m.Long.Overlap)
+	if err != nil {
+		return nil, err
+	}
+	return &voxa.LongFormConfig{Window: window, Overlap: overlap}, nil
+}
+
+// add lists the jobs the manifest does not know of yet.
+func (m *manifest) add(jobs []job) {
+	known := map[string]bool{}
+	for _, f := range m.Files {
+		known[f.Path] = true
+	}
+	for _, j := range jobs {
+		if !known[j.path] {
+			m.Files = append(m.Files, &fileState{Path: j.path, Out: j.out, State: filePending})
+			known[j.path] = true
+		}
+	}
+}
+
+// unfinished returns the jobs of the files not done.
+func (m *manifest) unfinished() []job {
+	var todo []job
+	for _, f := range m.Files {
+		if f.State != fileDone {
+			todo = append(todo, job{path: f.Path, out: f.Out})
+		}
+	}
+	return todo
+}
+
+func (m *manifest) file(path string) *fileState {
+	for _, f := range m.Files {
+		if f.Path == path {
+			return f
+		}
+	}
+	return nil
+}
+
+// checkpoints returns the windows of path transcribed so far.
+func (m *manifest) checkpoints(path string) []voxa.LongFormCheckpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.file(path).Windows
+}
+
+// checkpoint records a window of path, and saves the manifest.
+func (m *manifest) checkpoint(path string, cp voxa.LongFormCheckpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.file(path)
+	f.Windows = append(f.Windows, cp)
+	return m.save()
+}
+
+// finish records the outcome of path, and saves the manifest. Done files
+// drop their windows: their transcripts are written.
+func (m *manifest) finish(path string, utterances int, err error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.file(path)
+	if err != nil {
+		f.State, f.Error = fileFailed, err.Error()
+	} else {
+		f.State, f.Error, f.Utterances, f.Windows = fileDone, "", utterances, nil
+	}
+	return m.save()
+}
+
+// write saves the manifest.
+func (m *manifest) write() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.save()
+}
+
+func (m *manifest) save() error {
+	return writeFile(m.path, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	})
+}
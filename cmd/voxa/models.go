@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/jmarc101/voxa/internal/models"
+	"github.com/jmarc101/voxa/internal/simd"
+)
+
+const modelsUsage = `usage: voxa models <pull|list|verify> [flags] [models...]
+
+Manages the model files of the local backends, in $VOXA_MODELS or the voxa
+cache directory. Backends given a model option naming an installed model,
+such as model: ggml-base.en, load its pinned version; with quantization: auto,
+that of its quantized variants, such as ggml-base.en-q8_0, which suits the CPU
+best.
+
+  pull    download name or name@version, verify it and pin that version
+  list    list the models of the manifest and those installed
+  verify  check the installed models, all unless named, against the lock`
+
+func modelsCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, modelsUsage)
+		os.Exit(2)
+	}
+	fl := flag.NewFlagSet("models "+args[0], flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), modelsUsage)
+		fl.PrintDefaults()
+	}
+	dir := fl.String("dir", "", "model directory (default $VOXA_MODELS or the user cache directory)")
+	manifest := fl.String("manifest", "", "JSON manifest adding models to, or overriding, the built-in ones")
+	_ = fl.Parse(args[1:])
+
+	m := models.Builtin
+	if *manifest != "" {
+		extra, err := models.LoadManifest(*manifest)
+		if err != nil {
+			return err
+		}
+		m = m.Merge(extra)
+	}
+	store := models.Open(*dir)
+	switch args[0] {
+	case "pull":
+		if fl.NArg() == 0 {
+			fl.Usage()
+			os.Exit(2)
+		}
+		return pullModels(ctx, store, m, fl.Args())
+	case "list":
+		return listModels(store, m)
+	case "verify":
+		return verifyModels(store, fl.Args())
+	default:
+		fmt.Fprintln(os.Stderr, modelsUsage)
+		os.Exit(2)
+	}
+	return nil
+}
+
+func pullModels(ctx context.Context, store *models.Store, m *models.Manifest, refs []string) error {
+	for _, ref := range refs {
+		mod, err := m.Find(ref)
+		if err != nil {
+			return err
+		}
+		last := -1
+		progress := func(file string, done, total int64) {
+			pct := 0
+			if total > 0 {
+				pct = int(done * 100 / total)
+			}
+			if pct/10 != last/10 {
+				last = pct
+				fmt.Fprintf(os.Stderr, "%s: %s %d%% (%d MB)\n", mod.Ref(), file, pct, done>>20)
+			}
+		}
+		if err := store.Pull(ctx, mod, progress); err != nil {
+			return err
+		}
+		path, err := store.Path(mod.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\t%s\n", mod.Ref(), path)
+	}
+	return nil
+}
+
+func listModels(store *models.Store, m *models.Manifest) error {
+	lock, err := store.Lock()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "cpu: %s (%s)\n", simd.Best(), simd.Detect())
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVERSION\tBACKEND\tINSTALLED\tDESCRIPTION")
+	for _, mod := range m.Models {
+		installed := "-"
+		if l, ok := lock.Models[mod.Name]; ok && l.Version == mod.Version {
+			installed = "pinned"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", mod.Name, mod.Version, mod.Backend, installed, mod.Description)
+	}
+	for _, name := range slices.Sorted(maps.Keys(lock.Models)) {
+		l := lock.Models[name]
+		if _, err := m.Find(name + "@" + l.Version); err != nil {
+			fmt.Fprintf(tw, "%s\t%s\t%s\tpinned\t(not in the manifest)\n", name, l.Version, l.Backend)
+		}
+	}
+	return tw.Flush()
+}
+
+func verifyModels(store *models.Store, names []string) error {
+	if len(names) == 0 {
+		lock, err := store.Lock()
+		if err != nil {
+			return err
+		}
+		names = slices.Sorted(maps.Keys(lock.Models))
+	}
+	failed := 0
+	for _, name := range names {
+		if err := store.Verify(name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s\tok\n", name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d models failed verification", failed, len(names))
+	}
+	return nil
+}
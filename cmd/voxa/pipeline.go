@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/config"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+func pipelineCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: voxa pipeline validate [flags] <config file>")
+		os.Exit(2)
+	}
+	return validate(ctx, args[1:])
+}
+
+// formats is a flag of source formats, rate/channels each.
+type formats []voxa.AudioFormat
+
+func (fs *formats) String() string {
+	s := make([]string, len(*fs))
+	for i, f := range *fs {
+		s[i] = fmt.Sprintf("%d/%d", f.SampleRate, f.Channels)
+	}
+	return strings.Join(s, ",")
+}
+
+func (fs *formats) Set(v string) error {
+	for _, v := range strings.Split(v, ",") {
+		rate, ch, ok := strings.Cut(strings.TrimSpace(v), "/")
+		if !ok {
+			ch = "1"
+		}
+		r, err1 := strconv.Atoi(rate)
+		c, err2 := strconv.Atoi(ch)
+		if err1 != nil || err2 != nil || r <= 0 || c <= 0 {
+			return fmt.Errorf("bad format %q, want rate/channels", v)
+		}
+		*fs = append(*fs, voxa.AudioFormat{SampleRate: r, Channels: c})
+	}
+	return nil
+}
+
+func validate(_ context.Context, args []string) error {
+	fl := flag.NewFlagSet("pipeline validate", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), `usage: voxa pipeline validate [flags] <config file>
+
+Builds the pipeline of a voxad config file without serving it, checks that
+every stage can take the audio of each source format, and prints the
+stage graph. Stores are opened in memory and the recognizer is not sent
+any audio. Without -format, sources are 16 kHz mono, with 8 kHz mono for
+Twilio and the capture channels of devices.input_channels. The pipelines
+of the profiles are checked too, and -profile prints the graph of one of
+them rather than the default.`)
+		fl.PrintDefaults()
+	}
+	var srcs formats
+	fl.Var(&srcs, "format", "source format as rate/channels, e.g. 8000/1; repeatable or comma-separated")
+	dot := fl.Bool("dot", false, "write the stage graph in Graphviz DOT")
+	mermaid := fl.Bool("mermaid", false, "write the stage graph as a Mermaid flowchart")
+	profile := fl.String("profile", "", "print the stage graph of this profile")
+	logLevel := fl.String("log-level", "warn", "least severe log level written: debug, info, warn or error")
+	_ = fl.Parse(args)
+	if fl.NArg() != 1 || (*dot && *mermaid) {
+		fl.Usage()
+		os.Exit(2)
+	}
+	logger, err := logging.New(os.Stderr, *logLevel, "text")
+	if err != nil {
+		return err
+	}
+
+	f, err := config.Load(fl.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(srcs) == 0 {
+		srcs = formats{{SampleRate: 16000, Channels: 1}}
+		if f.Server.Twilio != nil {
+			srcs = append(srcs, voxa.AudioFormat{SampleRate: 8000, Channels: 1})
+		}
+		if n := f.Devices.InputChannels; n > 1 {
+			srcs = append(srcs, voxa.AudioFormat{SampleRate: 16000, Channels: n})
+		}
+	}
+	cfg, err := f.DryRunConfig()
+	if err != nil {
+		return err
+	}
+	if c, ok := cfg.Transcripts.(io.Closer); ok {
+		defer c.Close()
+	}
+	cfg.Logger = logger
+	p, err := voxa.NewPipeline(cfg)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	if _, ok := f.Profiles[*profile]; *profile != "" && !ok {
+		return fmt.Errorf("no profile %q", *profile)
+	}
+
+	var graphs []voxa.StageGraph
+	var errs []error
+	check := func(pl *voxa.Pipeline, prefix string, keep bool) {
+		for _, src := range srcs {
+			g, err := pl.Graph(src)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", prefix, formatName(src), err))
+				continue
+			}
+			if keep {
+				graphs = append(graphs, g)
+			}
+		}
+	}
+	check(p, "", *profile == "")
+	for _, name := range slices.Sorted(maps.Keys(f.Profiles)) {
+		pc, err := f.ProfileConfig(name, cfg)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		pc.Logger = logger
+		pp, err := voxa.NewPipeline(pc)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		defer pp.Close()
+		check(pp, "profile "+name+": ", name == *profile)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	switch {
+	case *dot:
+		writeDOT(os.Stdout, graphs)
+	case *mermaid:
+		writeMermaid(os.Stdout, graphs)
+	default:
+		for _, g := range graphs {
+			fmt.Printf("%s: %s\n", formatName(g.Source), strings.Join(graphNodes(g), " -> "))
+		}
+		fmt.Println("ok")
+	}
+	return nil
+}
+
+// formatName describes f, as "16kHz mono".
+func formatName(f voxa.AudioFormat) string {
+	rate := strconv.Itoa(f.SampleRate) + "Hz"
+	if f.SampleRate%1000 == 0 {
+		rate = strconv.Itoa(f.SampleRate/1000) + "kHz"
+	}
+	switch f.Channels {
+	case 1:
+		return rate + " mono"
+	case 2:
+		return rate + " stereo"
+	}
+	return fmt.Sprintf("%s %dch", rate, f.Channels)
+}
+
+// graphEdge is an edge of a stage graph, from node From to node From+1.
+type graphEdge struct {
+	From  int
+	Label string
+}
+
+// graphNodes returns the nodes of g in order: the source, the audio
+// stages, the recognizer, then the transcript and final stages.
+func graphNodes(g voxa.StageGraph) []string {
+	nodes := []string{"source"}
+	for _, st := range g.Audio {
+		nodes = append(nodes, st.Name)
+	}
+	nodes = append(nodes, g.Recognizer.Name)
+	nodes = append(nodes, g.Transcript...)
+	return append(nodes, g.Final...)
+}
+
+// graphEdges returns the edges between the nodes of graphNodes, labelled
+// with the audio they carry, or the segments past the recognizer.
+func graphEdges(g voxa.StageGraph) []graphEdge {
+	var edges []graphEdge
+	in := g.Source
+	for i, st := range g.Audio {
+		edges = append(edges, graphEdge{From: i, Label: formatName(in)})
+		in = st.Out
+	}
+	edges = append(edges, graphEdge{From: len(g.Audio), Label: formatName(in)})
+	n := len(g.Audio) + 1
+	for i := range g.Transcript {
+		edges = append(edges, graphEdge{From: n + i, Label: "segments"})
+	}
+	n += len(g.Transcript)
+	for i := range g.Final {
+		edges = append(edges, graphEdge{From: n + i, Label: "finals"})
+	}
+	return edges
+}
+
+func writeDOT(w io.Writer, graphs []voxa.StageGraph) {
+	fmt.Fprintln(w, "digraph voxa {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for gi, g := range graphs {
+		fmt.Fprintf(w, "\tsubgraph cluster_%d {\n", gi)
+		fmt.Fprintf(w, "\t\tlabel=%q;\n", formatName(g.Source))
+		for i, name := range graphNodes(g) {
+			shape := ""
+			switch {
+			case i == 0:
+				shape = ", shape=ellipse"
+			case i == len(g.Audio)+1:
+				shape = ", style=bold"
+			}
+			fmt.Fprintf(w, "\t\tn%d_%d [label=%q%s];\n", gi, i, name, shape)
+		}
+		for _, e := range graphEdges(g) {
+			fmt.Fprintf(w, "\t\tn%d_%d -> n%d_%d [label=%q];\n", gi, e.From, gi, e.From+1, e.Label)
+		}
+		fmt.Fprintln(w, "\t}")
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeMermaid(w io.Writer, graphs []voxa.StageGraph) {
+	fmt.Fprintln(w, "flowchart LR")
+	for gi, g := range graphs {
+		fmt.Fprintf(w, "\tsubgraph g%d [\"%s\"]\n", gi, formatName(g.Source))
+		for i, name := range graphNodes(g) {
+			open, end := "[", "]"
+			switch {
+			case i == 0:
+				open, end = "([", "])"
+			case i == len(g.Audio)+1:
+				open, end = "[[", "]]"
+			}
+			fmt.Fprintf(w, "\t\tn%d_%d%s\"%s\"%s\n", gi, i, open, name, end)
+		}
+		for _, e := range graphEdges(g) {
+			fmt.Fprintf(w, "\t\tn%d_%d -->|%s| n%d_%d\n", gi, e.From, e.Label, gi, e.From+1)
+		}
+		fmt.Fprintln(w, "\tend")
+	}
+}
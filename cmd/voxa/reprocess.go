@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/clients/asr"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/reprocess"
+)
+
+func reprocessCmd(ctx context.Context, args []string) error {
+	fl := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), `usage: voxa reprocess [flags] [session IDs...]
+
+Transcribes the archived audio of stored sessions again and stores the
+result as a new version of each transcript. Sessions are selected by
+-since and -until, or named as arguments.`)
+		fl.PrintDefaults()
+	}
+	db := fl.String("db", "", "SQLite transcript database file, or the PostgreSQL database at a postgres:// URL")
+	archiveFrom := fl.String("archive", "", "directory the audio was archived under, or the bucket at an s3://bucket/prefix URL")
+	since := fl.String("since", "", "reprocess sessions started at or after this RFC 3339 time")
+	until := fl.String("until", "", "reprocess sessions started before this RFC 3339 time")
+	provider := fl.String("stt", asr.ProviderName, "STT provider name")
+	asrAddr := fl.String("asr", asr.DefaultAddr, "ASR sidecar address")
+	sttOpts := fl.String("stt-opts", "", "comma-separated key=value options for the STT provider, e.g. model=ggml-large-v3.bin")
+	label := fl.String("label", "", "description stored with the new versions, e.g. the model (default: -stt-opts)")
+	jobs := fl.Int("jobs", 1, "sessions transcribed in parallel")
+	useVAD := fl.Bool("vad", true, "split utterances on silence")
+	diarize := fl.Bool("diarize", false, "label utterances with their speaker")
+	priority := fl.String("priority", "batch", "recognizer scheduling priority of the streams against other clients of the recognizer: interactive, normal or batch")
+	every := fl.Duration("progress", 5*time.Second, "how often progress is reported")
+	logLevel := fl.String("log-level", "info", "least severe log level written: debug, info, warn or error")
+	_ = fl.Parse(args)
+	if *db == "" || *archiveFrom == "" || (fl.NArg() == 0 && *since == "" && *until == "") {
+		fl.Usage()
+		os.Exit(2)
+	}
+	if *jobs < 1 {
+		return errors.New("-jobs must be at least 1")
+	}
+	if *every <= 0 {
+		return errors.New("-progress must be positive")
+	}
+	var q reprocess.Query
+	var err error
+	if q.Since, err = parseTime("-since", *since); err != nil {
+		return err
+	}
+	if q.Until, err = parseTime("-until", *until); err != nil {
+		return err
+	}
+	q.Sessions = fl.Args()
+	logger, err := logging.New(os.Stderr, *logLevel, "text")
+	if err != nil {
+		return err
+	}
+
+	ts, err := openStore(*db)
+	if err != nil {
+		return err
+	}
+	if c, ok := ts.(io.Closer); ok {
+		defer c.Close()
+	}
+	var storage voxa.ArchiveStorage
+	if bucket, ok := strings.CutPrefix(*archiveFrom, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(bucket, "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		storage, err = voxa.NewArchiveS3(voxa.ArchiveS3Config{Bucket: bucket, Prefix: prefix})
+	} else {
+		if _, err := os.Stat(*archiveFrom); err != nil {
+			return err
+		}
+		storage, err = voxa.NewArchiveDir(*archiveFrom)
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg := voxa.Config{
+		Recognizer: voxa.RecognizerConfig{
+			Provider: *provider,
+			Options:  map[string]string{"addr": *asrAddr},
+		},
+		Logger: logger,
+	}
+	if cfg.Priority, err = voxa.ParsePriority(*priority); err != nil {
+		return err
+	}
+	for _, kv := range strings.Split(*sttOpts, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			cfg.Recognizer.Options[k] = v
+		}
+	}
+	if *useVAD {
+		cfg.VAD = &voxa.VADConfig{}
+	}
+	if *diarize {
+		cfg.Diarization = &voxa.DiarizationConfig{}
+	}
+	p, err := voxa.NewPipeline(cfg)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	if *label == "" {
+		*label = *sttOpts
+	}
+
+	queue, err := reprocess.New(reprocess.Config{
+		Transcripts: ts,
+		Archive:     storage,
+		Pipeline:    p,
+		Provider:    *provider,
+		Label:       *label,
+		Workers:     *jobs,
+		Logger:      logger,
+	})
+	if err != nil {
+		return err
+	}
+	defer queue.Close()
+	job, err := queue.Submit(q)
+	if err != nil {
+		return err
+	}
+
+	tick := time.NewTicker(*every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			job.Cancel()
+			<-job.Done()
+			return ctx.Err()
+		case <-tick.C:
+			report(job.Progress())
+		case <-job.Done():
+			pr := job.Progress()
+			report(pr)
+			switch {
+			case pr.Err != nil:
+				return pr.Err
+			case pr.Failed > 0:
+				return fmt.Errorf("%d of %d sessions failed", pr.Failed, pr.Sessions)
+			}
+			return nil
+		}
+	}
+}
+
+// report prints a progress line to stderr.
+func report(pr reprocess.Progress) {
+	msg := fmt.Sprintf("%s: %d of %d sessions", pr.State, pr.Done+pr.Skipped+pr.Failed, pr.Sessions)
+	if pr.Skipped > 0 {
+		msg += fmt.Sprintf(", %d without audio", pr.Skipped)
+	}
+	if pr.Failed > 0 {
+		msg += fmt.Sprintf(", %d failed", pr.Failed)
+	}
+	if pr.Audio > 0 {
+		msg += fmt.Sprintf("; %s of %s of audio (%.0f%%)",
+			pr.Processed.Round(time.Second), pr.Audio.Round(time.Second), 100*pr.Processed.Seconds()/pr.Audio.Seconds())
+	}
+	end := pr.Ended
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if !pr.Started.IsZero() {
+		msg += fmt.Sprintf(" in %s", end.Sub(pr.Started).Round(time.Second))
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// reviewExt is the extension of the review outputs of voxa transcribe.
+const reviewExt = ".review.json"
+
+func reviewCmd(ctx context.Context, args []string) error {
+	fl := flag.NewFlagSet("review", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), `usage: voxa review [flags] <review files...>
+
+Merges the corrections made in review files, as written by
+"voxa transcribe -format review", into the transcripts next to them: the
+JSON transcript, which must exist, and the txt, srt and vtt transcripts
+found, which are written again from it.`)
+		fl.PrintDefaults()
+	}
+	speakers := fl.Bool("speakers", false, "label utterances with their speaker in the transcripts written again")
+	translation := fl.String("translation", "", "language of the translation added to the txt, srt and vtt transcripts written again")
+	logLevel := fl.String("log-level", "info", "least severe log level written: debug, info, warn or error")
+	_ = fl.Parse(args)
+	if fl.NArg() == 0 {
+		fl.Usage()
+		os.Exit(2)
+	}
+	logger, err := logging.New(os.Stderr, *logLevel, "text")
+	if err != nil {
+		return err
+	}
+	opts := outputs{subs: voxa.SubtitleOptions{Speakers: *speakers, Translation: *translation}}
+	failed := 0
+	for _, path := range fl.Args() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := mergeReview(path, opts)
+		if err != nil {
+			failed++
+			logger.Error("merging review failed", "file", path, "error", err)
+			continue
+		}
+		logger.Info("review merged", "file", path, "corrected", n)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d reviews failed", failed, fl.NArg())
+	}
+	return nil
+}
+
+// mergeReview merges the review at path into its transcripts, returning
+// the number of segments corrected.
+func mergeReview(path string, opts outputs) (int, error) {
+	out, ok := strings.CutSuffix(path, reviewExt)
+	if !ok {
+		return 0, fmt.Errorf("not a %s review file", reviewExt)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	rv, err := voxa.ReadReview(f)
+	_ = f.Close()
+	if err != nil {
+		return 0, err
+	}
+	if f, err = os.Open(out + ".json"); err != nil {
+		return 0, err
+	}
+	segs, err := voxa.ReadJSON(f)
+	_ = f.Close()
+	if err != nil {
+		return 0, err
+	}
+	segs, n, err := voxa.ApplyReview(segs, rv)
+	if err != nil {
+		return 0, err
+	}
+	formats := []string{"json"}
+	for _, name := range []string{"txt", "srt", "vtt"} {
+		if _, err := os.Stat(out + writers[name].ext); err == nil {
+			formats = append(formats, name)
+		}
+	}
+	return n, writeOutputs(out, formats, segs, opts)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/server"
+)
+
+func search(ctx context.Context, args []string) error {
+	fl := flag.NewFlagSet("search", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), `usage: voxa search [flags] <query>
+
+Words must all appear in a segment; "quoted phrases" must appear as written
+and refund* matches any word starting with refund.`)
+		fl.PrintDefaults()
+	}
+	db := fl.String("db", "", "search this SQLite transcript database file, or the PostgreSQL database at a postgres:// URL")
+	addr := fl.String("server", "", "search the transcripts of the voxad serving HTTP at this address, e.g. localhost:7080")
+	session := fl.String("session", "", "search only this session")
+	since := fl.String("since", "", "search segments stored at or after this RFC 3339 time")
+	until := fl.String("until", "", "search segments stored before this RFC 3339 time")
+	limit := fl.Int("limit", 20, "most hits printed")
+	asJSON := fl.Bool("json", false, "print the hits as JSON")
+	_ = fl.Parse(args)
+	if fl.NArg() == 0 || (*db == "") == (*addr == "") {
+		fl.Usage()
+		os.Exit(2)
+	}
+	q := voxa.TranscriptSearch{Text: strings.Join(fl.Args(), " "), Session: *session, Limit: *limit}
+	var err error
+	if q.Since, err = parseTime("-since", *since); err != nil {
+		return err
+	}
+	if q.Until, err = parseTime("-until", *until); err != nil {
+		return err
+	}
+
+	var hits []server.WireHit
+	if *addr != "" {
+		hits, err = searchServer(ctx, *addr, q)
+	} else {
+		hits, err = searchStore(ctx, *db, q)
+	}
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(server.WireSearchResults{Hits: hits})
+	}
+	if len(hits) == 0 {
+		fmt.Fprintln(os.Stderr, "no matches")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, h := range hits {
+		speaker := h.Segment.Speaker
+		if speaker == "" {
+			speaker = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", h.SessionID, offset(h.Segment.StartMS), speaker, h.Snippet)
+	}
+	return tw.Flush()
+}
+
+// searchStore searches a transcript database directly.
+func searchStore(ctx context.Context, db string, q voxa.TranscriptSearch) ([]server.WireHit, error) {
+	ts, err := openStore(db)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := ts.(io.Closer); ok {
+		defer c.Close()
+	}
+	found, err := ts.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return server.WireHits(found), nil
+}
+
+// openStore opens the transcript database of a -db flag, which must exist.
+func openStore(db string) (voxa.TranscriptStore, error) {
+	if strings.HasPrefix(db, "postgres://") || strings.HasPrefix(db, "postgresql://") {
+		return voxa.NewPostgresTranscriptStore(db)
+	}
+	if _, err := os.Stat(db); err != nil {
+		return nil, err // rather than creating an empty database
+	}
+	return voxa.NewSQLiteTranscriptStore(db)
+}
+
+// searchServer searches through the /v1/search endpoint of a voxad.
+func searchServer(ctx context.Context, addr string, q voxa.TranscriptSearch) ([]server.WireHit, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	v := url.Values{"q": {q.Text}, "limit": {strconv.Itoa(q.Limit)}}
+	if q.Session != "" {
+		v.Set("session", q.Session)
+	}
+	if !q.Since.IsZero() {
+		v.Set("since", q.Since.Format(time.RFC3339Nano))
+	}
+	if !q.Until.IsZero() {
+		v.Set("until", q.Until.Format(time.RFC3339Nano))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/search?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var res server.WireSearchResults
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("bad response: %w", err)
+	}
+	return res.Hits, nil
+}
+
+func parseTime(flag, v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, errors.New(flag + " must be an RFC 3339 time, like 2024-05-01T09:00:00Z")
+	}
+	return t, nil
+}
+
+// offset formats a time into the recording as h:mm:ss.d.
+func offset(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	return fmt.Sprintf("%d:%02d:%02d.%d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, ms%1000/100)
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/clients/asr"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/longform"
+)
+
+// audioExts are the extensions picked up when walking directories. Files
+// named on the command line are tried whatever their extension.
+var audioExts = map[string]bool{".wav": true, ".mp3": true, ".flac": true, ".opus": true, ".ogg": true}
+
+// writers maps the -format names to transcript writers and file extensions.
+var writers = map[string]struct {
+	ext   string
+	write func(io.Writer, []voxa.Segment, outputs) error
+}{
+	"txt":      {".txt", func(w io.Writer, segs []voxa.Segment, o outputs) error { return voxa.WriteText(w, segs, o.subs) }},
+	"srt":      {".srt", func(w io.Writer, segs []voxa.Segment, o outputs) error { return voxa.WriteSRT(w, segs, o.subs) }},
+	"vtt":      {".vtt", func(w io.Writer, segs []voxa.Segment, o outputs) error { return voxa.WriteVTT(w, segs, o.subs) }},
+	"json":     {".json", func(w io.Writer, segs []voxa.Segment, _ outputs) error { return voxa.WriteJSON(w, segs) }},
+	"ndjson":   {".ndjson", func(w io.Writer, segs []voxa.Segment, _ outputs) error { return voxa.WriteNDJSON(w, segs) }},
+	"protobuf": {".pb", func(w io.Writer, segs []voxa.Segment, _ outputs) error { return voxa.WriteProto(w, segs) }},
+	"review": {reviewExt, func(w io.Writer, segs []voxa.Segment, o outputs) error {
+		return voxa.WriteReview(w, segs, o.review)
+	}},
+}
+
+// outputs holds the options of the writers.
+type outputs struct {
+	subs   voxa.SubtitleOptions
+	review voxa.ReviewOptions
+}
+
+// job is one file to transcribe and where its transcripts go, without
+// extension.
+type job struct {
+	path, out string
+}
+
+func transcribe(ctx context.Context, args []string) error {
+	fl := flag.NewFlagSet("transcribe", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "usage: voxa transcribe [flags] <files or directories...>\n       voxa transcribe -resume <manifest> [flags] [files or directories...]\n       voxa transcribe [flags] -   (audio on stdin, segments on stdout as NDJSON or protobuf)")
+		fl.PrintDefaults()
+	}
+	provider := fl.String("stt", asr.ProviderName, "STT provider name")
+	asrAddr := fl.String("asr", asr.DefaultAddr, "ASR sidecar address")
+	sttOpts := fl.String("stt-opts", "", "comma-separated key=value options for the STT provider, e.g. model=ggml-base.en.bin")
+	jobs := fl.Int("jobs", runtime.NumCPU(), "files transcribed in parallel")
+	formats := fl.String("format", "txt", "comma-separated outputs: txt, json, ndjson, protobuf, srt, vtt, or review for the segments to check by ear, merged back by voxa review; with -, ndjson or protobuf")
+	threshold := fl.Float64("review-threshold", 0.6, "confidence under which segments are flagged in review outputs")
+	outDir := fl.String("out", "", "directory for transcripts (default: next to each input)")
+	force := fl.Bool("force", false, "transcribe files whose transcripts already exist")
+	useVAD := fl.Bool("vad", true, "split utterances on silence")
+	diarize := fl.Bool("diarize", false, "label utterances with their speaker")
+	denoise := fl.Float64("denoise", 0, "noise suppression strength in (0, 1]; 0 disables")
+	detectLang := fl.Bool("detect-language", false, "identify the spoken language of every file")
+	fallbackLang := fl.String("fallback-language", "", "language used when -detect-language is not confident")
+	translate := fl.String("translate", "", "comma-separated languages to translate into; the first is added to txt, srt and vtt outputs")
+	translateFrom := fl.String("translate-from", "", "spoken language for -translate (default: detect)")
+	translateOpts := fl.String("translate-opts", "", "comma-separated key=value options for the translation provider, e.g. endpoint=http://localhost:5000")
+	long := fl.Bool("long", false, "transcribe every file in overlapping windows cut in its pauses, in parallel, for recordings of hours")
+	window := fl.Duration("window", longform.DefaultWindow, "length of the windows of -long")
+	overlap := fl.Duration("overlap", longform.DefaultOverlap, "audio the windows of -long share with their neighbours")
+	windowJobs := fl.Int("window-jobs", longform.DefaultWorkers, "windows of a file transcribed in parallel with -long")
+	tracks := fl.String("tracks", "", "transcribe every channel of the files on its own and merge them, labelling the speakers with these comma-separated names in channel order, e.g. agent,customer for a stereo call recording")
+	priority := fl.String("priority", "batch", "recognizer scheduling priority of the streams against other clients of the recognizer: interactive, normal or batch")
+	resume := fl.String("resume", "", "JSON manifest recording the progress of the run, created if missing; a run started again with it skips the files done and resumes -long files at their last window")
+	logLevel := fl.String("log-level", "info", "least severe log level written: debug, info, warn or error")
+	pcm := fl.String("pcm", "", "with -, read stdin as headerless PCM16 little-endian at rate[:channels], e.g. 16000 or 48000:2 (default: detect a WAV, FLAC, MP3 or Ogg file)")
+	partials := fl.Bool("partials", false, "with -, write partial segments too")
+	_ = fl.Parse(args)
+	if fl.NArg() == 0 && *resume == "" {
+		fl.Usage()
+		os.Exit(2)
+	}
+	stdin := slices.Contains(fl.Args(), "-")
+	switch {
+	case stdin && fl.NArg() > 1:
+		return errors.New("- cannot be combined with other inputs")
+	case stdin && (*resume != "" || *long || *tracks != ""):
+		return errors.New("- cannot be combined with -resume, -long or -tracks")
+	case *tracks != "" && (*long || *diarize):
+		return errors.New("-tracks cannot be combined with -long or -diarize")
+	case !stdin && (*pcm != "" || *partials):
+		return errors.New("-pcm and -partials only apply to -")
+	}
+	if *jobs < 1 {
+		return errors.New("-jobs must be at least 1")
+	}
+	if *threshold <= 0 || *threshold > 1 {
+		return errors.New("-review-threshold must be in (0, 1]")
+	}
+	logger, err := logging.New(os.Stderr, *logLevel, "text")
+	if err != nil {
+		return err
+	}
+	if stdin {
+		// The segments of - are streamed, as ndjson unless -format says.
+		fl.Visit(func(f *flag.Flag) {
+			if f.Name == "format" && *formats != "ndjson" && *formats != "protobuf" {
+				err = fmt.Errorf("- writes ndjson or protobuf, not %q", *formats)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if *formats == "txt" {
+			*formats = "ndjson"
+		}
+	}
+	var outs []string
+	for _, f := range strings.Split(*formats, ",") {
+		if _, ok := writers[f]; !ok {
+			return fmt.Errorf("unknown format %q", f)
+		}
+		outs = append(outs, f)
+	}
+	var lf *voxa.LongFormConfig
+	if *long {
+		lf = &voxa.LongFormConfig{Window: *window, Overlap: *overlap}
+	}
+	var tc *voxa.TracksConfig
+	if *tracks != "" {
+		tc = &voxa.TracksConfig{Labels: strings.Split(*tracks, ",")}
+	}
+
+	cfg := voxa.Config{
+		Recognizer: voxa.RecognizerConfig{
+			Provider: *provider,
+			Options:  map[string]string{"addr": *asrAddr},
+		},
+		Logger: logger,
+	}
+	if cfg.Priority, err = voxa.ParsePriority(*priority); err != nil {
+		return err
+	}
+	for _, kv := range strings.Split(*sttOpts, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			cfg.Recognizer.Options[k] = v
+		}
+	}
+	if *useVAD {
+		cfg.VAD = &voxa.VADConfig{}
+	}
+	if *denoise > 0 {
+		cfg.Denoise = &voxa.DenoiseConfig{Strength: *denoise}
+	}
+	if *diarize {
+		cfg.Diarization = &voxa.DiarizationConfig{}
+	}
+	if *detectLang {
+		cfg.LanguageID = &voxa.LanguageIDConfig{Fallback: *fallbackLang}
+	}
+	opts := outputs{
+		subs:   voxa.SubtitleOptions{Speakers: *diarize || tc != nil},
+		review: voxa.ReviewOptions{Threshold: float32(*threshold)},
+	}
+	if *translate != "" {
+		cfg.Translation = &voxa.TranslationConfig{
+			Source:  *translateFrom,
+			Targets: strings.Split(*translate, ","),
+			Options: map[string]string{},
+		}
+		for _, kv := range strings.Split(*translateOpts, ",") {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				cfg.Translation.Options[k] = v
+			}
+		}
+		opts.subs.Translation = cfg.Translation.Targets[0]
+	}
+	if stdin {
+		var format *voxa.AudioFormat
+		if *pcm != "" {
+			f, err := voxa.ParseAudioFormat(*pcm)
+			if err != nil {
+				return err
+			}
+			format = &f
+		}
+		p, err := voxa.NewPipeline(cfg)
+		if err != nil {
+			return err
+		}
+		defer p.Close()
+		return p.Pipe(ctx, os.Stdout, os.Stdin, voxa.PipeOptions{
+			Format:   format,
+			Partials: *partials,
+			Protobuf: *formats == "protobuf",
+		})
+	}
+
+	todo, err := collect(fl.Args(), *outDir)
+	if err != nil {
+		return err
+	}
+	var m *manifest
+	if *resume != "" {
+		if m, err = loadManifest(*resume); err != nil {
+			return fmt.Errorf("reading manifest: %w", err)
+		}
+		if len(m.Files) == 0 {
+			m.Formats = outs
+			if lf != nil {
+				m.Long = &longSettings{Window: lf.Window.String(), Overlap: lf.Overlap.String()}
+			}
+		} else {
+			// Resumed: the run keeps its settings.
+			outs = m.Formats
+			if lf, err = m.longConfig(); err != nil {
+				return fmt.Errorf("reading manifest: %w", err)
+			}
+			logger.Info("resuming", "manifest", *resume, "formats", strings.Join(outs, ","), "long", lf != nil)
+		}
+		for _, f := range outs {
+			if _, ok := writers[f]; !ok {
+				return fmt.Errorf("manifest: unknown format %q", f)
+			}
+		}
+		m.add(todo)
+		if len(m.Files) == 0 {
+			logger.Info("nothing to transcribe")
+			return nil
+		}
+		if err := m.write(); err != nil {
+			return err
+		}
+		todo = m.unfinished()
+	}
+	if lf != nil {
+		lf.Workers = *windowJobs
+	}
+	if !*force {
+		todo = pending(todo, outs)
+	}
+	if len(todo) == 0 {
+		logger.Info("nothing to transcribe")
+		return nil
+	}
+
+	p, err := voxa.NewPipeline(cfg)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	ch := make(chan job)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	for range min(*jobs, len(todo)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				n, err := transcribeFile(ctx, p, j, outs, opts, longConfig(lf, m, j.path, logger), tc)
+				if m != nil && ctx.Err() == nil {
+					if merr := m.finish(j.path, n, err); merr != nil {
+						logger.Warn("saving manifest failed", "error", merr)
+					}
+				}
+				mu.Lock()
+				if err != nil {
+					failed++
+					logger.Error("transcription failed", "file", j.path, "error", err)
+				} else {
+					logger.Info("transcribed", "file", j.path, "utterances", n)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, j := range todo {
+		select {
+		case ch <- j:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(todo))
+	}
+	return nil
+}
+
+// collect expands the arguments into jobs. Directories are walked for
+// audio files; with outDir set, their layout is mirrored under it.
+func collect(args []string, outDir string) ([]job, error) {
+	var todo []job
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			todo = append(todo, job{path: arg, out: output(arg, filepath.Base(arg), outDir)})
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !audioExts[strings.ToLower(filepath.Ext(path))] {
+				return err
+			}
+			rel, err := filepath.Rel(arg, path)
+			if err != nil {
+				return err
+			}
+			todo = append(todo, job{path: path, out: output(path, rel, outDir)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return todo, nil
+}
+
+// output returns the transcript path of an input, without extension.
+func output(path, rel, outDir string) string {
+	if outDir == "" {
+		return strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	return filepath.Join(outDir, strings.TrimSuffix(rel, filepath.Ext(rel)))
+}
+
+// pending drops the jobs whose transcripts all exist already.
+func pending(todo []job, formats []string) []job {
+	var out []job
+	for _, j := range todo {
+		for _, f := range formats {
+			if _, err := os.Stat(j.out + writers[f].ext); err != nil {
+				out = append(out, j)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// longConfig returns the long-form settings of the file at path, lf
+// resuming from and checkpointing to m if set.
+func longConfig(lf *voxa.LongFormConfig, m *manifest, path string, logger logging.Logger) *voxa.LongFormConfig {
+	if lf == nil || m == nil {
+		return lf
+	}
+	c := *lf
+	c.Resume = m.checkpoints(path)
+	c.OnCheckpoint = func(cp voxa.LongFormCheckpoint) {
+		if err := m.checkpoint(path, cp); err != nil {
+			logger.Warn("saving manifest failed", "error", err)
+		}
+	}
+	return &c
+}
+
+// transcribeFile runs one file through the pipeline, in windows with lf or
+// a track per channel with tc, and writes its transcripts. It returns the
+// number of utterances.
+func transcribeFile(ctx context.Context, p *voxa.Pipeline, j job, formats []string, opts outputs, lf *voxa.LongFormConfig, tc *voxa.TracksConfig) (int, error) {
+	f, err := audio.Open(j.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var finals []voxa.Segment
+	switch {
+	case lf != nil:
+		finals, err = p.TranscribeLong(ctx, f, *lf)
+	case tc != nil:
+		finals, err = p.TranscribeTracks(ctx, f, *tc)
+	default:
+		err = p.Run(ctx, f, func(seg voxa.Segment) {
+			if seg.Final {
+				finals = append(finals, seg)
+			}
+		})
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.out), 0o755); err != nil {
+		return 0, err
+	}
+	opts.review.Audio = j.path
+	if err := writeOutputs(j.out, formats, finals, opts); err != nil {
+		return 0, err
+	}
+	return len(finals), nil
+}
+
+// writeOutputs writes the transcript segs in formats, at out with their
+// extensions.
+func writeOutputs(out string, formats []string, segs []voxa.Segment, opts outputs) error {
+	for _, name := range formats {
+		w := writers[name]
+		if err := writeFile(out+w.ext, func(f io.Writer) error {
+			return w.write(f, segs, opts)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes path through a temporary file, so an interrupted run
+// never leaves a truncated transcript that a later run would skip.
+func writeFile(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,1024 @@
+// Command voxad runs the voxa pipeline as a gRPC service so other processes
+// can stream audio to it and get transcripts and synthesized speech back.
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/auth"
+	"github.com/jmarc101/voxa/internal/clients/asr"
+	ttsclient "github.com/jmarc101/voxa/internal/clients/tts"
+	"github.com/jmarc101/voxa/internal/config"
+	"github.com/jmarc101/voxa/internal/cost"
+	"github.com/jmarc101/voxa/internal/ingest/rtp"
+	"github.com/jmarc101/voxa/internal/ingest/webrtc"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/reprocess"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/server"
+	"github.com/jmarc101/voxa/internal/session"
+	"github.com/jmarc101/voxa/internal/simd"
+	"github.com/jmarc101/voxa/internal/sink"
+	"github.com/jmarc101/voxa/internal/sink/kafka"
+	"github.com/jmarc101/voxa/internal/sink/mqtt"
+	"github.com/jmarc101/voxa/internal/sink/nats"
+	"github.com/jmarc101/voxa/internal/sink/webhook"
+)
+
+func main() {
+	configFile := flag.String("config", "", "YAML or TOML deployment file, used instead of the other flags and reloaded on SIGHUP")
+	listen := flag.String("listen", config.DefaultGRPC, "gRPC listen address")
+	drainTimeout := flag.Duration("drain-timeout", config.DefaultDrainTimeout, "on SIGTERM, how long running sessions get to finish their utterance and end before they are terminated")
+	httpListen := flag.String("http", ":7080", "HTTP/WebSocket listen address (empty disables)")
+	origins := flag.String("ws-origins", "", "comma-separated extra origins allowed to open WebSockets")
+	diarize := flag.Bool("diarize", false, "label transcript segments with their speaker")
+	speakers := flag.String("speakers", "", "with -diarize, name the speakers matching the voiceprints enrolled in this JSON file, created on the first enrollment at /v1/speakers/{name}")
+	denoise := flag.Float64("denoise", 0, "noise suppression strength in (0, 1]; 0 disables")
+	detectDTMF := flag.Bool("dtmf", false, "detect the keypad keys pressed on phone calls from their tones, publishing dtmf events")
+	detectAMD := flag.Bool("amd", false, "tell whether a person, an answering machine or hold music answered every call, publishing amd events")
+	gain := flag.Bool("agc", false, "bring speech to a steady level with automatic gain control")
+	beamform := flag.String("beamform", "", "mix multi-channel sources down to mono with this strategy: delay_sum, loudest, channel or average (empty averages them)")
+	provider := flag.String("stt", asr.ProviderName, "STT provider name")
+	asrAddr := flag.String("asr", asr.DefaultAddr, "ASR sidecar address")
+	sttOpts := flag.String("stt-opts", "", "comma-separated key=value options for the STT provider, e.g. model=ggml-base.en.bin")
+	sttRetry := flag.String("stt-retry", "", "comma-separated key=value retry and circuit breaker settings for every STT provider, e.g. attempts=3,threshold=5,cooldown=30s")
+	sttFallback := flag.String("stt-fallback", "", "comma-separated STT providers streams fail over to, in order")
+	sttFallbackOpts := flag.String("stt-fallback-opts", "", "comma-separated provider.key=value options for the -stt-fallback providers")
+	sttSLO := flag.Duration("stt-slo", 0, "fail over to the next -stt-fallback provider when transcripts lag the audio by more than this (0 disables)")
+	ttsProvider := flag.String("tts-provider", ttsclient.ProviderName, "TTS provider name (empty disables synthesis)")
+	ttsAddr := flag.String("tts", ttsclient.DefaultAddr, "TTS sidecar address")
+	ttsOpts := flag.String("tts-opts", "", "comma-separated key=value options for the TTS provider")
+	ttsRetry := flag.String("tts-retry", "", "comma-separated key=value retry and circuit breaker settings for every TTS provider")
+	ttsFallback := flag.String("tts-fallback", "", "comma-separated TTS providers synthesis fails over to, in order")
+	ttsFallbackOpts := flag.String("tts-fallback-opts", "", "comma-separated provider.key=value options for the -tts-fallback providers")
+	ttsSentences := flag.Bool("tts-sentences", false, "synthesize text one sentence at a time, so audio starts with the first sentence")
+	ttsNormalize := flag.String("tts-normalize", "", "read the numbers, units, dates and abbreviations of synthesized text aloud, by the rules of this locale: en-US, en-GB, fr-FR or es-ES (empty disables)")
+	detectLang := flag.Bool("detect-language", false, "identify the spoken language at the start of every session")
+	fallbackLang := flag.String("fallback-language", "", "language used when -detect-language is not confident")
+	translate := flag.String("translate", "", "comma-separated languages to translate final transcripts into")
+	translateFrom := flag.String("translate-from", "", "spoken language for -translate (default: detect)")
+	translateOpts := flag.String("translate-opts", "", "comma-separated key=value options for the translation provider")
+	vocabulary := flag.String("vocabulary", "", "bias recognition toward the phrases in this file, one per line, each optionally followed by a tab and its boost; transcripts of recognizers that take no phrases are corrected against them")
+	punctuation := flag.Bool("punctuate", false, "restore the punctuation, capitalization and sentence boundaries of unpunctuated transcripts, as local models produce them")
+	analyzeSentiment := flag.Bool("sentiment", false, "tag final transcript segments with their sentiment")
+	emotion := flag.Bool("emotion", false, "with -sentiment, also analyze the voice of every utterance for its emotion")
+	normalize := flag.String("normalize", "", "write the numbers, dates, times, amounts and percentages of transcripts in written form, by the rules of this locale: en-US, en-GB, fr-FR or es-ES (empty disables)")
+	profanity := flag.String("profanity", "", "filter profanity out of transcripts: mask, drop or tag (empty disables)")
+	redactPII := flag.String("redact", "", "comma-separated personal data to redact from transcripts: credit_card, ssn, phone, email, or all")
+	transcripts := flag.String("transcripts", "", "persist transcripts in this SQLite database file, or the PostgreSQL database at a postgres:// URL")
+	summarizeURL := flag.String("summarize", "", "with -transcripts, summarize every session when it ends with the model behind this OpenAI-compatible chat completions endpoint, e.g. https://api.openai.com/v1 or http://localhost:11434/v1 for Ollama (empty disables); $OPENAI_API_KEY, if set, authenticates")
+	summaryModel := flag.String("summary-model", "gpt-4o-mini", "model -summarize asks")
+	archiveTo := flag.String("archive", "", "record session audio under this directory, or in the bucket at an s3://bucket/prefix URL")
+	archiveFormat := flag.String("archive-format", "flac", "encoding of archived audio: flac, wav or opus")
+	archiveRotate := flag.Duration("archive-rotate", 0, "start a new archive file after this much audio (0 keeps one per stream)")
+	intents := flag.String("intents", "", "JSON file of intent definitions to recognize in final transcripts")
+	buffer := flag.Int("buffer", 0, "audio frames queued per session ahead of the pipeline stages (0 processes writes synchronously)")
+	overflow := flag.String("overflow", "block", "what a full -buffer does with new audio: block, drop-oldest or drop-newest")
+	rtpListen := flag.String("rtp", "", "UDP address to receive phone calls on as RTP, with RTCP on the same or the next port (empty disables)")
+	twilioCallback := flag.String("twilio-callback", "", "serve Twilio Media Streams at /v1/twilio, posting transcripts to this URL (empty disables); $TWILIO_AUTH_TOKEN, if set, checks request signatures")
+	webRTC := flag.Bool("webrtc", false, "accept browser audio over WebRTC, negotiated with WHIP at /v1/whip on the HTTP listener")
+	webRTCUDP := flag.String("webrtc-udp", "", "UDP address all WebRTC connections share (empty gives each its own ports)")
+	iceServers := flag.String("ice-servers", "", "comma-separated STUN and TURN URLs for WebRTC, e.g. stun:stun.l.google.com:19302")
+	mqttBroker := flag.String("mqtt", "", "MQTT broker URL to publish events to and take TTS requests from, e.g. tcp://localhost:1883 (empty disables); $MQTT_PASSWORD, if set, logs in")
+	webhookURL := flag.String("webhook", "", "URL to POST final transcripts and intents to (empty disables); $VOXA_WEBHOOK_SECRET, if set, signs them")
+	webhookSpool := flag.String("webhook-spool", "", "directory undelivered -webhook events are kept in across restarts")
+	sse := flag.Bool("sse", false, "serve live session events as server-sent events at /v1/sessions/{id}/events on the HTTP listener")
+	kafkaBrokers := flag.String("kafka", "", "comma-separated Kafka brokers to publish session and segment events to (empty disables)")
+	kafkaTopic := flag.String("kafka-topic", "voxa.events", "Kafka topic of -kafka events")
+	natsURL := flag.String("nats", "", "NATS server URL to publish session and segment events to JetStream (empty disables)")
+	natsStream := flag.String("nats-stream", "", "JetStream stream to create for -nats events if missing")
+	eventEncoding := flag.String("event-encoding", "json", "encoding of -kafka and -nats events: json or protobuf")
+	clusterURL := flag.String("cluster", "", "redis:// URL of the store shared by the nodes of a voxad cluster behind a load balancer (empty runs standalone)")
+	node := flag.String("node", "", "name of this -cluster node, routing its clients back to it (defaults to the host name)")
+	otlp := flag.String("otlp", "", "OTLP/HTTP collector address to export traces to, e.g. localhost:4318 (empty disables)")
+	metrics := flag.Bool("metrics", false, "serve Prometheus metrics at /metrics on the HTTP listener")
+	pprofListen := flag.String("pprof", "", "address to serve the Go profiler on at /debug/pprof/, unauthenticated, e.g. localhost:6060 (empty disables)")
+	ui := flag.Bool("ui", false, "serve a page at /ui/ on the HTTP listener to try transcription with a microphone and watch the sessions")
+	logLevel := flag.String("log-level", "info", "least severe log level written: debug, info, warn or error")
+	logFormat := flag.String("log-format", "text", "log format: text or json")
+	flag.Parse()
+
+	var f *config.File
+	if *configFile != "" {
+		if flag.NFlag() > 1 {
+			fmt.Fprintln(os.Stderr, "voxad: -config cannot be combined with other flags")
+			os.Exit(2)
+		}
+		var err error
+		if f, err = config.Load(*configFile); err != nil {
+			fmt.Fprintln(os.Stderr, "voxad:", err)
+			os.Exit(2)
+		}
+	} else {
+		// The flags describe a deployment too, checked the same way.
+		f = &config.File{
+			Server: config.Server{
+				GRPC:         *listen,
+				DrainTimeout: *drainTimeout,
+				HTTP:         *httpListen,
+				Metrics:      *metrics,
+				OTLP:         *otlp,
+				Pprof:        *pprofListen,
+				UI:           *ui,
+				RTP:          *rtpListen,
+			},
+			Logging: config.Logging{Level: *logLevel, Format: *logFormat},
+			Recognizer: config.Recognizer{
+				Provider:   *provider,
+				Options:    config.Options{"addr": *asrAddr},
+				LatencySLO: *sttSLO,
+			},
+			Stages:  config.Stages{VAD: &config.VAD{}},
+			Intents: *intents,
+		}
+		if *twilioCallback != "" {
+			f.Server.Twilio = &config.Twilio{Callback: *twilioCallback}
+		}
+		if *webRTC {
+			f.Server.WebRTC = &config.WebRTC{UDP: *webRTCUDP}
+			if *iceServers != "" {
+				f.Server.WebRTC.ICEServers = strings.Split(*iceServers, ",")
+			}
+		}
+		if *mqttBroker != "" {
+			f.Server.MQTT = &config.MQTT{Broker: *mqttBroker}
+		}
+		if *webhookURL != "" {
+			f.Server.Webhook = &config.Webhook{URL: *webhookURL, Spool: *webhookSpool}
+		}
+		if *sse {
+			f.Server.SSE = &config.SSE{}
+		}
+		if *kafkaBrokers != "" {
+			f.Server.Kafka = &config.Kafka{Brokers: strings.Split(*kafkaBrokers, ","), Topic: *kafkaTopic, Encoding: *eventEncoding}
+		}
+		if *natsURL != "" {
+			f.Server.NATS = &config.NATS{URL: *natsURL, Stream: *natsStream, Encoding: *eventEncoding}
+		}
+		if *clusterURL != "" {
+			f.Server.Cluster = &config.Cluster{Node: *node, URL: *clusterURL}
+		}
+		if *origins != "" {
+			f.Server.Origins = strings.Split(*origins, ",")
+		}
+		parseOptions(f.Recognizer.Options, *sttOpts)
+		var err error
+		if f.Recognizer.Retry, err = parseRetry(*sttRetry, *sttFallback); err != nil {
+			fmt.Fprintln(os.Stderr, "voxad: -stt-retry:", err)
+			os.Exit(2)
+		}
+		f.Recognizer.Fallbacks = parseFallbacks(*sttFallback, *sttFallbackOpts)
+		if *ttsProvider != "" {
+			f.Synthesizer = &config.Synthesizer{
+				Provider:  *ttsProvider,
+				Options:   config.Options{"addr": *ttsAddr},
+				Fallbacks: parseFallbacks(*ttsFallback, *ttsFallbackOpts),
+				Sentences: *ttsSentences,
+			}
+			if *ttsNormalize != "" {
+				f.Synthesizer.Normalization = &config.SpeechNormalization{Locale: *ttsNormalize}
+			}
+			parseOptions(f.Synthesizer.Options, *ttsOpts)
+			if f.Synthesizer.Retry, err = parseRetry(*ttsRetry, *ttsFallback); err != nil {
+				fmt.Fprintln(os.Stderr, "voxad: -tts-retry:", err)
+				os.Exit(2)
+			}
+		}
+		if *denoise > 0 {
+			f.Stages.Denoise = &config.Denoise{Strength: *denoise}
+		}
+		if *detectDTMF {
+			f.Stages.DTMF = &config.DTMF{}
+		}
+		if *detectAMD {
+			f.Stages.AnsweringMachine = &config.AnsweringMachine{}
+		}
+		if *gain {
+			f.Stages.GainControl = &config.GainControl{}
+		}
+		if *beamform != "" {
+			f.Stages.Beamforming = &config.Beamforming{Strategy: *beamform}
+		}
+		if *diarize {
+			f.Stages.Diarization = &config.Diarization{}
+			if *speakers != "" {
+				f.Stages.Diarization.Speakers = &config.Speakers{File: *speakers}
+			}
+		}
+		if *detectLang {
+			f.Stages.LanguageID = &config.LanguageID{Fallback: *fallbackLang}
+		}
+		if *translate != "" {
+			f.Stages.Translation = &config.Translation{
+				Source:  *translateFrom,
+				Targets: strings.Split(*translate, ","),
+				Options: config.Options{},
+			}
+			parseOptions(f.Stages.Translation.Options, *translateOpts)
+		}
+		if *vocabulary != "" {
+			f.Stages.Vocabulary = &config.Vocabulary{File: *vocabulary}
+		}
+		if *punctuation {
+			f.Stages.Punctuation = &config.Punctuation{}
+		}
+		if *analyzeSentiment {
+			f.Stages.Sentiment = &config.Sentiment{Prosody: *emotion}
+		}
+		if *normalize != "" {
+			f.Stages.Normalization = &config.Normalization{Locale: *normalize}
+		}
+		if *profanity != "" {
+			f.Stages.Profanity = &config.Profanity{Mode: *profanity}
+		}
+		if *redactPII != "" {
+			f.Stages.Redaction = &config.Redaction{}
+			if *redactPII != "all" {
+				f.Stages.Redaction.Entities = strings.Split(*redactPII, ",")
+			}
+		}
+		if *transcripts != "" {
+			f.Transcripts = &config.Transcripts{Store: "sqlite", URL: *transcripts}
+			if strings.HasPrefix(*transcripts, "postgres://") || strings.HasPrefix(*transcripts, "postgresql://") {
+				f.Transcripts.Store = "postgres"
+			}
+			if *summarizeURL != "" {
+				f.Transcripts.Summary = &config.Summary{Options: config.Options{"endpoint": *summarizeURL, "model": *summaryModel}}
+			}
+		}
+		if *archiveTo != "" {
+			f.Archive = &config.Archive{Dir: *archiveTo, Format: *archiveFormat, Rotate: *archiveRotate}
+			if bucket, ok := strings.CutPrefix(*archiveTo, "s3://"); ok {
+				bucket, prefix, _ := strings.Cut(bucket, "/")
+				f.Archive.Dir, f.Archive.S3 = "", &config.S3{Bucket: bucket, Prefix: prefix}
+			}
+		}
+		if *buffer > 0 {
+			f.Buffer = &config.Buffer{Frames: *buffer, Overflow: *overflow}
+		}
+		if err := f.Validate(); err != nil {
+			fmt.Fprintln(os.Stderr, "voxad:", err)
+			os.Exit(2)
+		}
+	}
+
+	logger, err := logging.New(os.Stderr, f.Logging.Level, f.Logging.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "voxad:", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A second signal kills a draining server.
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := run(ctx, *configFile, f, logger); err != nil {
+		logger.Error("voxad failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run serves the validated deployment f until ctx is done. With a config
+// file at path, the file is reloaded on SIGHUP and, if f.Watch is set,
+// whenever it changes. The backends are reopened on SIGHUP without one,
+// and whenever a model is pulled.
+func run(ctx context.Context, path string, f *config.File, logger *slog.Logger) error {
+	logger.Info("cpu detected", "simd", simd.Best(), "features", simd.Detect().String())
+	if f.Server.OTLP != "" {
+		shutdown, err := setupTracing(ctx, f.Server.OTLP, logger)
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+	}
+	var m *voxa.Metrics
+	if f.Server.Metrics {
+		m = voxa.NewMetrics()
+	}
+	var sinks []voxa.EventSink
+	var bridge *mqtt.Bridge
+	if f.Server.MQTT != nil {
+		var err error
+		if bridge, err = newMQTT(*f.Server.MQTT, logger); err != nil {
+			return err
+		}
+		// Closed after the backends, which publish to it.
+		defer bridge.Close()
+		sinks = append(sinks, bridge)
+	}
+	if w := f.Server.Webhook; w != nil {
+		hook, err := newWebhook(*w, logger)
+		if err != nil {
+			return err
+		}
+		defer hook.Close()
+		sinks = append(sinks, hook)
+	}
+	if k := f.Server.Kafka; k != nil {
+		ks, err := newKafka(*k, logger)
+		if err != nil {
+			return err
+		}
+		defer ks.Close()
+		sinks = append(sinks, ks)
+	}
+	if n := f.Server.NATS; n != nil {
+		ns, err := newNATS(*n, logger)
+		if err != nil {
+			return err
+		}
+		defer ns.Close()
+		sinks = append(sinks, ns)
+	}
+	var hub *server.EventHub
+	if e := f.Server.SSE; e != nil {
+		var err error
+		if hub, err = server.NewEventHub(server.EventHubConfig{Buffer: e.Buffer, Retention: e.Retention, Logger: logger}); err != nil {
+			return err
+		}
+		sinks = append(sinks, hub)
+	}
+	def, profiles, closeBackends, err := openBackends(f, sinks, logger, m)
+	if err != nil {
+		return err
+	}
+	defer func() { closeBackends() }()
+
+	srv := server.New(def.Pipeline, def.Synthesizer, profiles)
+	if bridge != nil {
+		bridge.Speak(srv.Synthesizer())
+	}
+	if c := f.Server.Cluster; c != nil {
+		cluster, store, err := newCluster(*c, logger)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		srv.Join(cluster)
+		logger.Info("joined cluster", "node", c.Node)
+	}
+
+	var authn *auth.Authenticator
+	if f.Server.Auth != nil {
+		if authn, err = newAuthenticator(*f.Server.Auth); err != nil {
+			return err
+		}
+	}
+	var ledger *cost.Ledger
+	if c := f.Server.Costs; c != nil {
+		if ledger, err = cost.New(c.Config()); err != nil {
+			return err
+		}
+		srv.Bill(ledger)
+	}
+	// protect requires an API key of h, if keys are configured.
+	protect := func(h http.Handler) http.Handler {
+		if authn == nil {
+			return h
+		}
+		return authn.HTTP(h)
+	}
+
+	lis, err := net.Listen("tcp", f.Server.GRPC)
+	if err != nil {
+		return err
+	}
+	var opts []grpc.ServerOption
+	if authn != nil {
+		opts = append(opts, grpc.ChainUnaryInterceptor(authn.UnaryInterceptor()), grpc.ChainStreamInterceptor(authn.StreamInterceptor()))
+	}
+	g := grpc.NewServer(opts...)
+	srv.Register(g)
+
+	var hs *http.Server
+	var wr *webrtc.Server
+	if f.Server.HTTP != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/v1/transcribe", protect(srv.WebSocketHandler(f.Server.Origins)))
+		mux.Handle("/v1/transcripts", protect(srv.TranscriptsHandler()))
+		mux.Handle("/v1/transcripts/", protect(srv.TranscriptsHandler()))
+		mux.Handle("/v1/search", protect(srv.SearchHandler()))
+		mux.Handle("/v1/speakers", protect(srv.SpeakersHandler()))
+		mux.Handle("/v1/speakers/", protect(srv.SpeakersHandler()))
+		if t := f.Server.Twilio; t != nil {
+			h, err := srv.TwilioHandler(twilioConfig(t))
+			if err != nil {
+				return err
+			}
+			mux.Handle("/v1/twilio", h)
+		}
+		if f.Server.WebRTC != nil {
+			if wr, err = newWebRTC(*f.Server.WebRTC, srv, logger); err != nil {
+				return err
+			}
+			mux.Handle("/v1/whip", protect(wr))
+			mux.Handle("/v1/whip/", protect(wr))
+		}
+		if hub != nil {
+			mux.Handle("/v1/sessions/", protect(hub))
+		}
+		if authn != nil {
+			mux.Handle("/v1/admin/", srv.AdminHandler(authn))
+		}
+		if m != nil {
+			mux.Handle("/metrics", metricsHandler(m, authn, ledger))
+		}
+		if f.Server.UI {
+			mux.Handle("/ui/", srv.UIHandler(authn))
+		}
+		hs = &http.Server{Addr: f.Server.HTTP, Handler: mux}
+		go func() {
+			logger.Info("serving HTTP", "addr", f.Server.HTTP, "metrics", m != nil, "ui", f.Server.UI)
+			if err := hs.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("http server failed", "error", err)
+			}
+		}()
+	}
+
+	var ps *http.Server
+	if f.Server.Pprof != "" {
+		ps = servePprof(f.Server.Pprof, logger)
+	}
+
+	var rs *rtp.Server
+	if f.Server.RTP != "" {
+		if rs, err = serveRTP(f.Server, srv, logger); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		var calls []io.Closer
+		if rs != nil {
+			calls = append(calls, rs)
+		}
+		if wr != nil {
+			calls = append(calls, wr)
+		}
+		drain(srv.Sessions(), f.Server.DrainTimeout, calls, logger)
+		if hs != nil {
+			sctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+			if hs.Shutdown(sctx) != nil {
+				_ = hs.Close() // event streams of sessions that never started
+			}
+			cancel()
+		}
+		if ps != nil {
+			_ = ps.Close()
+		}
+		g.GracefulStop()
+	}()
+	rctx, cancel := context.WithCancel(ctx)
+	r := &reloader{path: path, f: f, srv: srv, sinks: sinks, log: logger, metrics: m, close: closeBackends}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.run(rctx)
+	}()
+	// The reloader owns the backends being served from now on.
+	closeBackends = func() {
+		cancel()
+		<-done
+		r.close()
+	}
+	logger.Info("serving gRPC", "addr", lis.Addr().String())
+	return g.Serve(lis)
+}
+
+// closeTimeout bounds the wait for sessions and requests to end once they
+// have been told to.
+const closeTimeout = 5 * time.Second
+
+// drain stops sessions from starting and gives those running up to timeout
+// to reach an utterance boundary and end. The RTP and WebRTC calls left are
+// then hung up, transcribing the audio they received, and the other
+// sessions terminated. The storage and event sinks are flushed after, as
+// the backends and sinks close.
+func drain(sessions *server.Sessions, timeout time.Duration, calls []io.Closer, logger *slog.Logger) {
+	logger.Info("draining", "sessions", sessions.Len(), "timeout", timeout)
+	sessions.Drain()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	drained := sessions.Wait(ctx) == nil
+	for _, c := range calls {
+		_ = c.Close()
+	}
+	if drained {
+		logger.Info("drained")
+		return
+	}
+	logger.Warn("drain timed out, terminating sessions", "sessions", sessions.CancelAll())
+	ctx, cancel = context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	if sessions.Wait(ctx) != nil {
+		logger.Warn("sessions still running", "sessions", sessions.Len())
+	}
+}
+
+// twilioConfig configures the Twilio handler from t and the environment.
+func twilioConfig(t *config.Twilio) server.TwilioConfig {
+	cfg := server.TwilioConfig{
+		Callback:  t.Callback,
+		AuthToken: os.Getenv("TWILIO_AUTH_TOKEN"),
+		URL:       t.URL,
+		Partials:  t.Partials,
+	}
+	if t.Retry != nil {
+		cfg.Retry = resilience.Config(*t.Retry)
+	}
+	if n := t.Notice; n != nil {
+		cfg.Notice = &server.TwilioNotice{Text: n.Text, File: n.File, Timeout: n.Timeout}
+	}
+	return cfg
+}
+
+// newAuthenticator checks API keys against cfg.
+func newAuthenticator(cfg config.Auth) (*auth.Authenticator, error) {
+	var ac auth.Config
+	for _, k := range cfg.Keys {
+		ac.Keys = append(ac.Keys, auth.KeyConfig(k))
+	}
+	return auth.New(ac)
+}
+
+// newCluster makes a node of the cluster sharing the redis store at
+// cfg.URL.
+func newCluster(cfg config.Cluster, logger *slog.Logger) (*server.Cluster, *session.Redis, error) {
+	store, err := session.NewRedis(cfg.URL, "voxa:stream:")
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := server.NewCluster(server.ClusterConfig{
+		Node:         cfg.Node,
+		Leases:       store,
+		Store:        store,
+		LeaseTTL:     cfg.LeaseTTL,
+		ResumeWindow: cfg.ResumeWindow,
+		Logger:       logger,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, store, nil
+}
+
+// newMQTT connects the MQTT bridge of cfg, logging in with $MQTT_PASSWORD.
+func newMQTT(cfg config.MQTT, logger *slog.Logger) (*mqtt.Bridge, error) {
+	return mqtt.New(mqtt.Config{
+		Broker:   cfg.Broker,
+		ClientID: cfg.ClientID,
+		Username: cfg.Username,
+		Password: os.Getenv("MQTT_PASSWORD"),
+		Prefix:   cfg.Prefix,
+		QoS:      byte(cfg.QoS),
+		Encode:   server.EventMessage,
+		Logger:   logger,
+	})
+}
+
+// newWebhook starts the webhook sink of cfg, signing with
+// $VOXA_WEBHOOK_SECRET.
+func newWebhook(cfg config.Webhook, logger *slog.Logger) (*webhook.Sink, error) {
+	wc := webhook.Config{
+		URL:       cfg.URL,
+		Secret:    os.Getenv("VOXA_WEBHOOK_SECRET"),
+		Encode:    server.EventMessage,
+		QueueSize: cfg.QueueSize,
+		Spool:     cfg.Spool,
+		Logger:    logger,
+	}
+	var err error
+	if wc.Events, err = parseEvents(cfg.Events); err != nil {
+		return nil, err
+	}
+	if cfg.Retry != nil {
+		wc.Retry = resilience.Config(*cfg.Retry)
+	}
+	return webhook.New(wc)
+}
+
+// newKafka starts the Kafka sink of cfg.
+func newKafka(cfg config.Kafka, logger *slog.Logger) (*kafka.Sink, error) {
+	events, err := parseEvents(cfg.Events)
+	if err != nil {
+		return nil, err
+	}
+	encode, contentType := eventEncoding(cfg.Encoding)
+	return kafka.New(kafka.Config{
+		Brokers:     cfg.Brokers,
+		Topic:       cfg.Topic,
+		Events:      events,
+		Encode:      encode,
+		ContentType: contentType,
+		QueueSize:   cfg.QueueSize,
+		Logger:      logger,
+	})
+}
+
+// newNATS connects the NATS sink of cfg.
+func newNATS(cfg config.NATS, logger *slog.Logger) (*nats.Sink, error) {
+	events, err := parseEvents(cfg.Events)
+	if err != nil {
+		return nil, err
+	}
+	encode, contentType := eventEncoding(cfg.Encoding)
+	return nats.New(nats.Config{
+		URL:         cfg.URL,
+		Prefix:      cfg.Prefix,
+		Stream:      cfg.Stream,
+		Events:      events,
+		Encode:      encode,
+		ContentType: contentType,
+		QueueSize:   cfg.QueueSize,
+		Logger:      logger,
+	})
+}
+
+// parseEvents reads the event types of a sink.
+func parseEvents(names []string) ([]voxa.EventType, error) {
+	var events []voxa.EventType
+	for _, name := range names {
+		t, err := voxa.ParseEventType(name)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, t)
+	}
+	return events, nil
+}
+
+// eventEncoding returns the encoder and content type of a validated
+// encoding name.
+func eventEncoding(name string) (func(voxa.Event) ([]byte, error), string) {
+	if name == "protobuf" {
+		return server.EventProto, sink.Protobuf
+	}
+	return server.EventMessage, sink.JSON
+}
+
+// newWebRTC makes the WebRTC endpoint, listening on cfg.UDP if set.
+func newWebRTC(cfg config.WebRTC, srv *server.Server, logger *slog.Logger) (*webrtc.Server, error) {
+	wc := webrtc.Config{
+		Open:       srv.RTPOpener("webrtc"),
+		Encode:     server.SegmentMessage,
+		ICEServers: cfg.ICEServers,
+		MaxPeers:   cfg.MaxPeers,
+		Logger:     logger,
+	}
+	if cfg.UDP != "" {
+		conn, err := net.ListenPacket("udp", cfg.UDP)
+		if err != nil {
+			return nil, err
+		}
+		wc.UDP = conn
+		logger.Info("serving WebRTC", "udp", conn.LocalAddr().String())
+	}
+	return webrtc.New(wc)
+}
+
+// serveRTP receives phone calls at addr, and their RTCP there or on the
+// next port up.
+func serveRTP(cfg config.Server, srv *server.Server, logger *slog.Logger) (*rtp.Server, error) {
+	rs, err := rtp.New(rtp.Config{Open: srv.RTPOpener("rtp"), Opus: cfg.RTPOpus, Logger: logger})
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenPacket("udp", cfg.RTP)
+	if err != nil {
+		return nil, err
+	}
+	conns := []net.PacketConn{conn}
+	if a := conn.LocalAddr().(*net.UDPAddr); a.Port < 65535 {
+		rtcp, err := net.ListenUDP("udp", &net.UDPAddr{IP: a.IP, Port: a.Port + 1, Zone: a.Zone})
+		if err != nil {
+			logger.Warn("no RTCP port; RTCP must share the RTP port", "error", err)
+		} else {
+			conns = append(conns, rtcp)
+		}
+	}
+	for _, c := range conns {
+		go func() {
+			if err := rs.Serve(c); err != nil {
+				logger.Error("rtp server failed", "addr", c.LocalAddr().String(), "error", err)
+			}
+		}()
+	}
+	logger.Info("serving RTP", "addr", conn.LocalAddr().String())
+	return rs, nil
+}
+
+// openBackends instantiates the pipeline and synthesizer of f, and those
+// of each of its profiles, which update m if set and publish to sinks. The
+// returned function closes them.
+func openBackends(f *config.File, sinks []voxa.EventSink, logger *slog.Logger, m *voxa.Metrics) (server.Profile, map[string]server.Profile, func(), error) {
+	cfg, err := f.PipelineConfig()
+	if err != nil {
+		return server.Profile{}, nil, nil, err
+	}
+	cfg.Sinks = sinks
+	cfg.Logger = logger
+	cfg.Metrics = m
+	closeAlerts, err := alertActions(f, cfg.Alerts, sinks, logger)
+	var opened []server.Profile
+	var backlogs []func()
+	closeAll := func() {
+		for _, stop := range backlogs {
+			stop()
+		}
+		for _, b := range opened {
+			_ = b.Pipeline.Close()
+			if c, ok := b.Synthesizer.(io.Closer); ok {
+				_ = c.Close()
+			}
+		}
+		closeAlerts()
+		if c, ok := cfg.Transcripts.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+	if err != nil {
+		closeAll()
+		return server.Profile{}, nil, nil, err
+	}
+	// open adds the backends of pf, running on pc, to opened.
+	open := func(pf *config.File, pc voxa.Config, logger *slog.Logger) (server.Profile, error) {
+		p, err := voxa.NewPipeline(pc)
+		if err != nil {
+			return server.Profile{}, err
+		}
+		b := server.Profile{Pipeline: p}
+		if sc := pf.SynthesizerConfig(); sc != nil {
+			sc.Logger = logging.With(logger, "tts", sc.Provider)
+			if b.Synthesizer, err = voxa.NewSynthesizer(*sc); err != nil {
+				_ = p.Close()
+				return server.Profile{}, err
+			}
+		}
+		opened = append(opened, b)
+		return b, nil
+	}
+	// backlog runs the backlog of the sessions of profile name deferred in
+	// degraded mode, on a pipeline of its own, until closeAll.
+	backlog := func(name string, logger *slog.Logger) error {
+		if cfg.Degraded == nil {
+			return nil
+		}
+		bc, err := f.BacklogConfig(name)
+		if err != nil {
+			return err
+		}
+		bc.Logger = logger
+		p, err := voxa.NewPipeline(bc)
+		if err != nil {
+			return err
+		}
+		q, err := reprocess.New(reprocess.Config{
+			Transcripts: cfg.Transcripts,
+			Archive:     cfg.Archive.Storage,
+			Pipeline:    p,
+			Provider:    bc.Recognizer.Provider,
+			Label:       "deferred",
+			Workers:     f.Degraded.Workers,
+			Logger:      logger,
+		})
+		if err != nil {
+			_ = p.Close()
+			return err
+		}
+		b, err := reprocess.NewBacklog(reprocess.BacklogConfig{
+			Name:     cmp.Or(name, voxa.DefaultBacklog),
+			Queue:    q,
+			Interval: f.Degraded.Interval,
+			Window:   f.Degraded.Window,
+			Logger:   logger,
+		})
+		if err != nil {
+			_ = q.Close()
+			_ = p.Close()
+			return err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			b.Run(ctx)
+		}()
+		backlogs = append(backlogs, func() {
+			cancel()
+			<-done
+			_ = q.Close()
+			_ = p.Close()
+		})
+		return nil
+	}
+	def, err := open(f, cfg, logger)
+	if err == nil {
+		err = backlog("", logger)
+	}
+	if err != nil {
+		closeAll()
+		return server.Profile{}, nil, nil, err
+	}
+	profiles := map[string]server.Profile{}
+	for _, name := range slices.Sorted(maps.Keys(f.Profiles)) {
+		pc, err := f.ProfileConfig(name, cfg)
+		if err != nil {
+			closeAll()
+			return server.Profile{}, nil, nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		plog := logger.With("profile", name)
+		pc.Logger = plog
+		if profiles[name], err = open(f.Profile(name), pc, plog); err == nil {
+			err = backlog(name, plog)
+		}
+		if err != nil {
+			closeAll()
+			return server.Profile{}, nil, nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	return def, profiles, closeAll, nil
+}
+
+// alertActions sets the actions of the alert rules of f, in rules, running
+// a webhook sink per webhook action and publishing mqtt actions through the
+// MQTT bridge among sinks. The returned function closes the webhooks, even
+// on error.
+func alertActions(f *config.File, rules []voxa.AlertRule, sinks []voxa.EventSink, logger *slog.Logger) (func(), error) {
+	var bridge voxa.EventSink
+	for _, s := range sinks {
+		if b, ok := s.(*mqtt.Bridge); ok {
+			bridge = b
+		}
+	}
+	var hooks []*webhook.Sink
+	closeHooks := func() {
+		for _, h := range hooks {
+			_ = h.Close()
+		}
+	}
+	for i, a := range f.Alerts {
+		for _, act := range a.Actions {
+			switch act.Type {
+			case "log":
+				rules[i].Actions = append(rules[i].Actions, voxa.AlertLogger(logger))
+			case "webhook":
+				wc := config.Webhook{URL: act.URL, Events: []string{voxa.EventAlert.String()}}
+				if w := f.Server.Webhook; w != nil {
+					wc.Retry = w.Retry
+				}
+				hook, err := newWebhook(wc, logger)
+				if err != nil {
+					return closeHooks, fmt.Errorf("alert %s: %w", a.Name, err)
+				}
+				hooks = append(hooks, hook)
+				rules[i].Actions = append(rules[i].Actions, hook)
+			case "mqtt":
+				if bridge == nil {
+					return closeHooks, fmt.Errorf("alert %s: mqtt action needs server.mqtt", a.Name)
+				}
+				rules[i].Actions = append(rules[i].Actions, bridge)
+			}
+		}
+	}
+	return closeHooks, nil
+}
+
+// servePprof serves the profiles of net/http/pprof at addr: CPU, heap,
+// goroutines, blocking and the execution tracer, as go tool pprof reads
+// them. The package registers them on http.DefaultServeMux too, which
+// voxad never serves.
+func servePprof(addr string, logger *slog.Logger) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	ps := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		logger.Info("serving pprof", "addr", addr)
+		if err := ps.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("pprof server failed", "error", err)
+		}
+	}()
+	return ps
+}
+
+// metricsHandler serves the pipeline metrics along with the Go runtime and
+// process collectors, and the API key usage of authn and the costs of
+// ledger if set.
+func metricsHandler(m *voxa.Metrics, authn *auth.Authenticator, ledger *cost.Ledger) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m, collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	if authn != nil {
+		reg.MustRegister(authn)
+	}
+	if ledger != nil {
+		reg.MustRegister(ledger)
+	}
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+// setupTracing exports traces to the OTLP collector at addr, such as
+// Jaeger, and accepts W3C trace context from clients. The returned function
+// flushes the spans still buffered.
+func setupTracing(ctx context.Context, addr string, logger *slog.Logger) (shutdown func(), err error) {
+	exp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(addr), otlptracehttp.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("otlp: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", "voxad")))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			logger.Warn("flush traces", "error", err)
+		}
+	}, nil
+}
+
+// parseOptions adds the comma-separated key=value pairs in s to opts.
+func parseOptions(opts map[string]string, s string) {
+	for _, kv := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			opts[k] = v
+		}
+	}
+}
+
+// parseRetry reads a -stt-retry or -tts-retry flag. Backends are not
+// retried unless it is set or a fallback is.
+func parseRetry(s, fallback string) (*config.Retry, error) {
+	if s == "" && fallback == "" {
+		return nil, nil
+	}
+	opts := map[string]string{}
+	parseOptions(opts, s)
+	cfg, err := voxa.ParseResilienceConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	r := config.Retry(cfg)
+	return &r, nil
+}
+
+// parseFallbacks returns the comma-separated providers in names, in order,
+// with their options from the provider.key=value pairs in opts.
+func parseFallbacks(names, opts string) []config.Fallback {
+	if names == "" {
+		return nil
+	}
+	all := map[string]string{}
+	parseOptions(all, opts)
+	var fbs []config.Fallback
+	for _, name := range strings.Split(names, ",") {
+		fb := config.Fallback{Provider: name, Options: config.Options{}}
+		for k, v := range all {
+			if key, ok := strings.CutPrefix(k, name+"."); ok {
+				fb.Options[key] = v
+			}
+		}
+		fbs = append(fbs, fb)
+	}
+	return fbs
+}
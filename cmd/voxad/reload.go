@@ -0,0 +1,107 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/config"
+	"github.com/jmarc101/voxa/internal/models"
+	"github.com/jmarc101/voxa/internal/server"
+)
+
+// reloader re-reads the config file on SIGHUP, or when it changes if
+// File.Watch is set, and moves new sessions onto the backends it describes
+// while running sessions finish on the old ones. The listeners, logging,
+// metrics, tracing and the watch interval are set up once, so changes to
+// them wait for a restart.
+//
+// The backends are also reopened when a model is pulled into the default
+// store, with or without a config file, so that new sessions run on the
+// version the lock now pins. Models left unchanged are shared by the old
+// and new backends rather than loaded twice, and the old version is
+// unloaded once the last session on it ends; see models.Cache.
+type reloader struct {
+	path    string       // of the config file, empty with flags
+	f       *config.File // deployment being served
+	srv     *server.Server
+	sinks   []voxa.EventSink // outlive reloads
+	log     *slog.Logger
+	metrics *voxa.Metrics
+	close   func() // closes the backends of f
+}
+
+// run reloads the file until ctx is done.
+func (r *reloader) run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	watch := func(path string, every time.Duration) <-chan struct{} {
+		changed := make(chan struct{}, 1)
+		go config.Watch(ctx, path, every, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+		return changed
+	}
+	var changed <-chan struct{} // nil without a watch
+	if r.path != "" && r.f.Watch > 0 {
+		changed = watch(r.path, r.f.Watch)
+	}
+	pulled := watch(models.Open("").LockPath(), cmp.Or(r.f.Watch, modelsWatch))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload("sighup")
+		case <-changed:
+			r.reload("watch")
+		case <-pulled:
+			r.reload("models")
+		}
+	}
+}
+
+// modelsWatch is how often the models lock is checked without File.Watch.
+const modelsWatch = 5 * time.Second
+
+// reload switches to the deployment currently in the file, or reopens the
+// backends of the flags. A file that does not load, or backends that fail
+// to start, leave the running deployment in place.
+func (r *reloader) reload(trigger string) {
+	f := r.f
+	if r.path != "" {
+		var err error
+		if f, err = config.Load(r.path); err != nil {
+			r.log.Error("config reload failed, keeping the running configuration", "trigger", trigger, "error", err)
+			return
+		}
+		if !reflect.DeepEqual(f.Server, r.f.Server) || f.Logging != r.f.Logging || f.Watch != r.f.Watch {
+			r.log.Warn("config reload: server, logging and watch changes take effect on restart", "trigger", trigger)
+		}
+		f.Server, f.Logging, f.Watch = r.f.Server, r.f.Logging, r.f.Watch
+	}
+	def, profiles, closeBackends, err := openBackends(f, r.sinks, r.log, r.metrics)
+	if err != nil {
+		r.log.Error("config reload failed, keeping the running configuration", "trigger", trigger, "error", err)
+		return
+	}
+	drained := r.srv.Reload(def.Pipeline, def.Synthesizer, profiles)
+	closeOld := r.close
+	r.f, r.close = f, closeBackends
+	r.log.Info("config reloaded", "trigger", trigger, "sessions", r.srv.Sessions().Len())
+	go func() {
+		<-drained
+		closeOld()
+		r.log.Info("previous configuration drained", "trigger", trigger)
+	}()
+}
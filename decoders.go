@@ -0,0 +1,8 @@
+package voxa
+
+import (
+	// Audio file formats for audio.Open, beyond the built-in WAV.
+	_ "github.com/jmarc101/voxa/internal/audio/flac"
+	_ "github.com/jmarc101/voxa/internal/audio/mp3"
+	_ "github.com/jmarc101/voxa/internal/audio/opus"
+)
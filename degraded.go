@@ -0,0 +1,191 @@
+package voxa
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// DeferredKey is the session metadata key marking a session whose
+// transcription was deferred in degraded mode, set to the name of the
+// backlog it was deferred to; see DegradedConfig. Transcribing the session
+// again with the reprocess package removes it.
+const DeferredKey = "deferred"
+
+// DefaultBacklog is the backlog sessions are deferred to when
+// DegradedConfig.Backlog is empty.
+const DefaultBacklog = "default"
+
+// DegradedConfig configures degraded mode: when the recognizer fails for
+// good, its providers all being down, or a stream cannot open one, the
+// stream carries on without segments instead of ending. Its audio is still
+// archived, the session is marked with DeferredKey for the reprocess
+// package to transcribe once the providers are back, and an EventDeferred
+// is published.
+type DegradedConfig struct {
+	// Backlog names the backlog the sessions are deferred to, for the
+	// pipelines of several configurations sharing one store. Defaults to
+	// DefaultBacklog.
+	Backlog string
+	// OnDeferred is called with the recognizer failure when the
+	// transcription of a stream is deferred, whichever stream it is.
+	OnDeferred func(error)
+}
+
+// CheckRecognizer opens a stream on the recognizer and closes it without
+// audio, returning the error it could not be opened or closed with, such
+// as for the reprocess package to tell the providers are back before
+// transcribing the sessions deferred in degraded mode.
+func (p *Pipeline) CheckRecognizer(ctx context.Context) error {
+	rec, err := p.rec.NewStream(ctx, stt.StreamConfig{
+		SampleRate: p.recognizerFormat(audio.Format{SampleRate: 16000, Channels: 1}).SampleRate,
+		Logger:     p.cfg.Recognizer.Logger,
+		Priority:   PriorityBatch,
+	})
+	if err != nil {
+		return err
+	}
+	err = rec.Close()
+	for range rec.Results() {
+	}
+	return err
+}
+
+// deferring is the recognizer stream of a stream in degraded mode. Once
+// the recognizer has failed, or if it could not be opened, it takes the
+// audio without transcribing it, and its results stay open until Close so
+// the stream keeps going.
+type deferring struct {
+	rec     stt.StreamingRecognizer // nil if it could not be opened
+	open    error                   // why rec could not be opened
+	ctx     context.Context
+	results chan stt.Segment
+	closed  chan struct{}
+	close   sync.Once
+
+	onDefer func(error)
+	once    sync.Once // calls onDefer
+
+	mu     sync.Mutex
+	failed bool
+}
+
+// newDeferring wraps rec, or stands in for it when it could not be opened
+// with open. Its results only flow once start has been called.
+func newDeferring(ctx context.Context, rec stt.StreamingRecognizer, open error) *deferring {
+	return &deferring{rec: rec, open: open, ctx: ctx, results: make(chan stt.Segment), closed: make(chan struct{}), failed: rec == nil}
+}
+
+// start relays the results of the recognizer, calling onDefer once when
+// it fails.
+func (d *deferring) start(onDefer func(error)) {
+	d.onDefer = onDefer
+	go d.forward()
+}
+
+func (d *deferring) forward() {
+	defer close(d.results)
+	if d.rec == nil {
+		d.fail(d.open)
+	} else {
+		for seg := range d.rec.Results() {
+			d.results <- seg
+		}
+		err := d.rec.Err()
+		if err == nil || d.ctx.Err() != nil || errors.Is(err, context.Canceled) {
+			return
+		}
+		d.fail(err)
+	}
+	select {
+	case <-d.closed:
+	case <-d.ctx.Done():
+	}
+}
+
+// fail defers the transcription for err, once.
+func (d *deferring) fail(err error) {
+	d.mu.Lock()
+	d.failed = true
+	d.mu.Unlock()
+	d.once.Do(func() {
+		if d.onDefer != nil {
+			d.onDefer(err)
+		}
+	})
+}
+
+func (d *deferring) deferred() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.failed
+}
+
+func (d *deferring) Write(p []byte) (int, error) {
+	if d.deferred() {
+		return len(p), nil
+	}
+	if _, err := d.rec.Write(p); err != nil {
+		d.fail(err)
+	}
+	return len(p), nil
+}
+
+func (d *deferring) Results() <-chan stt.Segment { return d.results }
+
+func (d *deferring) Flush() error {
+	if d.deferred() {
+		return nil
+	}
+	if err := d.rec.Flush(); err != nil {
+		d.fail(err)
+	}
+	return nil
+}
+
+func (d *deferring) Close() error {
+	d.close.Do(func() { close(d.closed) })
+	if d.rec == nil {
+		return nil
+	}
+	err := d.rec.Close()
+	if d.deferred() {
+		return nil
+	}
+	return err
+}
+
+// Err returns the error the recognizer ended with, unless the
+// transcription was deferred for it.
+func (d *deferring) Err() error {
+	if d.rec == nil || d.deferred() {
+		return nil
+	}
+	return d.rec.Err()
+}
+
+// SetLanguage implements stt.LanguageSetter for recognizers that support
+// it.
+func (d *deferring) SetLanguage(lang string) error {
+	if ls, ok := d.rec.(stt.LanguageSetter); ok && !d.deferred() {
+		return ls.SetLanguage(lang)
+	}
+	return nil
+}
+
+// deferred leaves the transcription of s to the backlog of
+// Config.Degraded, its recognizer having failed with err.
+func (s *Stream) deferred(backlog string, err error) {
+	s.metrics.Deferred()
+	s.log.Warn("recognizer down, transcription deferred", "backlog", backlog, "error", err)
+	if err := s.SetMetadata(map[string]string{DeferredKey: backlog}); err != nil {
+		s.log.Warn("storing transcript failed", "error", err)
+	}
+	s.publish(Event{Type: EventDeferred, Err: err})
+	if s.onDeferred != nil {
+		s.onDeferred(err)
+	}
+}
@@ -0,0 +1,78 @@
+package voxa_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/stttest"
+)
+
+func TestDegradedDefersTranscription(t *testing.T) {
+	down := errors.New("every provider down")
+	rec := stttest.New(stttest.Script{stttest.Fail(300*time.Millisecond, down)})
+	ts, err := voxa.NewSQLiteTranscriptStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage, err := voxa.NewArchiveDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deferred []error
+	p, err := voxa.NewPipeline(voxa.Config{
+		Recognizer:  rec.Config(),
+		Transcripts: ts,
+		Archive:     &voxa.ArchiveConfig{Storage: storage, Format: voxa.ArchiveWAV},
+		Degraded:    &voxa.DegradedConfig{OnDeferred: func(err error) { deferred = append(deferred, err) }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.NewStream(context.Background(), audio.Format{SampleRate: 16000, Channels: 1}, voxa.StreamOptions{SessionID: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		chunk := make([]byte, 2*1600)
+		for range 20 {
+			if _, err := s.Write(chunk); err != nil {
+				t.Error(err)
+			}
+		}
+		_ = s.Close()
+	}()
+	for range s.Results() {
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("deferred stream failed: %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(deferred) != 1 || !errors.Is(deferred[0], down) {
+		t.Fatalf("deferred %v, want once for %v", deferred, down)
+	}
+	ctx := context.Background()
+	sessions, err := ts.Sessions(ctx, voxa.TranscriptQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Metadata[voxa.DeferredKey] != voxa.DefaultBacklog {
+		t.Fatalf("sessions %+v, want a marked deferred", sessions)
+	}
+	archives, err := ts.Archives(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archived time.Duration
+	for _, a := range archives {
+		archived += a.Duration
+	}
+	if archived != 2*time.Second {
+		t.Errorf("archived %v of audio, want all 2s", archived)
+	}
+}
@@ -0,0 +1,100 @@
+package voxa
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/capture"
+	"github.com/jmarc101/voxa/internal/audio/playback"
+)
+
+// AudioDevice is a capture or playback device.
+type AudioDevice = capture.Device
+
+// ListAudioDevices lists the audio devices of the compiled-in capture
+// backends.
+func ListAudioDevices() ([]AudioDevice, error) {
+	return capture.ListDevices()
+}
+
+// Listen captures from the configured input device and streams it through
+// the pipeline until ctx is done, calling fn for every segment in order.
+// The device is opened in the recognizer's format, with
+// Config.InputChannels, so no conversion stage is needed but the
+// beamformer of microphone arrays. Unplugging the device pauses the stream until it returns.
+// Cancelling ctx releases the device promptly and returns ctx's error.
+func (p *Pipeline) Listen(ctx context.Context, fn func(Segment)) error {
+	format := p.recognizerFormat(audio.Format{SampleRate: 16000, Channels: 1})
+	format.Channels = p.channels
+	mic, err := capture.Open(capture.Config{
+		Device:  p.input,
+		Format:  format,
+		Metrics: p.cfg.Metrics,
+	})
+	if err != nil {
+		return fmt.Errorf("voxa: %w", err)
+	}
+	defer mic.Close()
+	// Closing the device on cancellation releases it at once and wakes a
+	// read waiting for audio or for an unplugged device to return.
+	stop := context.AfterFunc(ctx, func() { _ = mic.Close() })
+	defer stop()
+	return p.Run(ctx, mic, fn)
+}
+
+// OutputDevice returns the playback device selected by Config.OutputDevice.
+// It reports false when none was pinned, meaning the system default.
+// OpenPlayer plays on it.
+func (p *Pipeline) OutputDevice() (AudioDevice, bool) {
+	if p.output == nil {
+		return AudioDevice{}, false
+	}
+	return *p.output, true
+}
+
+// Player plays audio on an output device, in the order it is queued, with
+// a volume control and completion callbacks; see OpenPlayer.
+type Player = playback.Player
+
+// PlayerConfig configures a Player.
+type PlayerConfig = playback.Config
+
+// PlaybackItem is audio queued on a Player, to await or stop.
+type PlaybackItem = playback.Item
+
+// Errors of PlaybackItem.
+var (
+	ErrPlaybackStopped = playback.ErrStopped
+	ErrPlayerClosed    = playback.ErrClosed
+)
+
+// OpenPlayer opens a Player on the device selected by Config.OutputDevice,
+// or on the system default, in the format cfg asks for. cfg.Backend and
+// cfg.Device are set from the device. With Config.EchoCancellation, the
+// audio it plays is cancelled out of every stream.
+func (p *Pipeline) OpenPlayer(cfg PlayerConfig) (*Player, error) {
+	if p.output != nil {
+		cfg.Backend, cfg.Device = p.output.Backend, p.output.ID
+	}
+	if p.echo != nil {
+		if on := cfg.OnPlay; on != nil {
+			cfg.OnPlay = func(fr audio.Frame) {
+				p.echo.Write(fr)
+				on(fr)
+			}
+		} else {
+			cfg.OnPlay = p.echo.Write
+		}
+	}
+	return playback.Open(cfg)
+}
+
+// EchoReference hands the canceller of Config.EchoCancellation audio the
+// application plays itself rather than through OpenPlayer, as it is
+// handed to the speaker. It does nothing without echo cancellation.
+func (p *Pipeline) EchoReference(fr audio.Frame) {
+	if p.echo != nil {
+		p.echo.Write(fr)
+	}
+}
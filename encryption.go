@@ -0,0 +1,53 @@
+package voxa
+
+import (
+	"github.com/jmarc101/voxa/internal/archive"
+	"github.com/jmarc101/voxa/internal/envelope"
+	"github.com/jmarc101/voxa/internal/store"
+)
+
+// EncryptionConfig configures the envelope encryption of audio archives
+// and transcripts at rest.
+type EncryptionConfig = envelope.Config
+
+// Encrypter encrypts data at rest under data keys wrapped by a
+// KeyProvider.
+type Encrypter = envelope.Envelope
+
+// KeyProvider holds the keys wrapping data keys: a local keyring, a Vault
+// transit key or an AWS KMS key.
+type KeyProvider = envelope.KeyProvider
+
+// KeyProviderFactory builds a key provider; see RegisterKeyProvider.
+type KeyProviderFactory = envelope.Factory
+
+// DefaultDataKeyLifetime is how long a data key encrypts new data when
+// EncryptionConfig.DataKeyLifetime is unset.
+const DefaultDataKeyLifetime = envelope.DefaultDataKeyLifetime
+
+// ErrEncryptedTranscriptSearch is returned by the Search of an encrypted
+// TranscriptStore.
+var ErrEncryptedTranscriptSearch = store.ErrEncryptedSearch
+
+// NewEncrypter returns an Encrypter using the key provider of cfg.
+func NewEncrypter(cfg EncryptionConfig) (*Encrypter, error) { return envelope.New(cfg) }
+
+// RegisterKeyProvider makes a key provider available under name; see
+// EncryptionConfig.Provider. It panics if name is already taken.
+func RegisterKeyProvider(name string, factory KeyProviderFactory) {
+	envelope.Register(name, factory)
+}
+
+// KeyProviders returns the sorted names of the registered key providers.
+func KeyProviders() []string { return envelope.Providers() }
+
+// EncryptArchive returns an ArchiveStorage encrypting archives with enc
+// before storing them in s, and reading back those stored in the clear.
+func EncryptArchive(s ArchiveStorage, enc *Encrypter) ArchiveStorage { return archive.Encrypt(s, enc) }
+
+// EncryptTranscripts returns a TranscriptStore encrypting the text of
+// segments and summaries with enc before storing it in ts, which can then
+// no longer be searched. It closes ts when closed.
+func EncryptTranscripts(ts TranscriptStore, enc *Encrypter) TranscriptStore {
+	return store.Encrypt(ts, enc)
+}
@@ -0,0 +1,98 @@
+package voxa_test
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/stttest"
+)
+
+// voice returns PCM16 of a buzz at the pitches f0 gives over time, zero
+// being silence.
+func voice(d time.Duration, f0 func(time.Duration) float64) []byte {
+	n := int(d * 16000 / time.Second)
+	b := make([]byte, 2*n)
+	var ph float64
+	for i := range n {
+		if f := f0(time.Duration(i) * time.Second / 16000); f > 0 {
+			ph += f / 16000
+			binary.LittleEndian.PutUint16(b[2*i:], uint16(int16(6000*(ph-math.Floor(ph)-0.5))))
+		}
+	}
+	return b
+}
+
+func TestEndpointing(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	buzz := func(on bool) float64 {
+		if on {
+			return 150
+		}
+		return 0
+	}
+	for _, tc := range []struct {
+		name   string
+		f0     func(time.Duration) float64
+		reason string
+		at     time.Duration
+	}{
+		{"pause", func(at time.Duration) float64 { return buzz(at < 2*time.Second) }, "silence", 2 * time.Second},
+		{"falling pitch", func(at time.Duration) float64 {
+			switch {
+			case at < ms(1500):
+				return 200
+			case at < 2*time.Second:
+				return 140
+			}
+			return 0
+		}, "prosody", 2 * time.Second},
+		{"too long", func(at time.Duration) float64 {
+			// Words of 800ms, 100ms apart.
+			return buzz(at%ms(900) < ms(800))
+		}, "max_length", ms(3560)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := stttest.New(nil)
+			p, err := voxa.NewPipeline(voxa.Config{
+				Recognizer:  rec.Config(),
+				Endpointing: &voxa.EndpointingConfig{MinSilence: ms(700), FinishSilence: ms(300), MaxUtterance: 3 * time.Second},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			var got []voxa.Endpoint
+			s, err := p.NewStream(context.Background(), audio.Format{SampleRate: 16000, Channels: 1}, voxa.StreamOptions{
+				OnEndpoint: func(ep voxa.Endpoint) { got = append(got, ep) },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				for range s.Results() {
+				}
+			}()
+			pcm := voice(ms(3700), tc.f0)
+			for len(pcm) > 0 { // in 20ms frames
+				if _, err := s.Write(pcm[:640]); err != nil {
+					t.Fatal(err)
+				}
+				pcm = pcm[640:]
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0].Reason != tc.reason || got[0].Offset != tc.at {
+				t.Fatalf("got endpoints %+v, want one for %s at %v", got, tc.reason, tc.at)
+			}
+			if n := rec.Streams()[0].Flushes(); n < 1 {
+				t.Errorf("recognizer flushed %d times, want at least once", n)
+			}
+		})
+	}
+}
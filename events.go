@@ -0,0 +1,147 @@
+package voxa
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType int
+
+const (
+	// EventWakeWord is a wake word heard.
+	EventWakeWord EventType = iota + 1
+	// EventFinal is a final segment, once translated, parsed for intents
+	// and stored.
+	EventFinal
+	// EventIntent is an intent recognized in a final segment, published
+	// before the segment.
+	EventIntent
+	// EventPartial is a partial segment.
+	EventPartial
+	// EventSessionStart is a stream opened.
+	EventSessionStart
+	// EventSessionEnd is a stream ended, once its last segment has been
+	// handled.
+	EventSessionEnd
+	// EventAlert is an alert rule matching a segment, published to the
+	// actions of the rule and to subscribers rather than to Config.Sinks.
+	EventAlert
+	// EventDTMF is a telephone keypad key pressed; see Config.DTMF.
+	EventDTMF
+	// EventAMD is who answered a call; see Config.AnsweringMachine.
+	EventAMD
+	// EventVAD is a VAD transition, published to subscribers rather than
+	// to Config.Sinks.
+	EventVAD
+	// EventError is a segment a transcript plugin or the final processing
+	// failed on, which ends the stream, published to subscribers rather
+	// than to Config.Sinks.
+	EventError
+	// EventDeferred is the transcription of a stream deferred, its
+	// recognizer being down; see Config.Degraded.
+	EventDeferred
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventWakeWord:
+		return "wake_word"
+	case EventFinal:
+		return "final"
+	case EventIntent:
+		return "intent"
+	case EventPartial:
+		return "partial"
+	case EventSessionStart:
+		return "session_start"
+	case EventSessionEnd:
+		return "session_end"
+	case EventAlert:
+		return "alert"
+	case EventDTMF:
+		return "dtmf"
+	case EventAMD:
+		return "amd"
+	case EventVAD:
+		return "vad"
+	case EventError:
+		return "error"
+	case EventDeferred:
+		return "deferred"
+	}
+	return "EventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// ParseEventType parses an EventType name: wake_word, final, intent,
+// partial, session_start, session_end, alert, dtmf, amd, vad, error or
+// deferred.
+func ParseEventType(s string) (EventType, error) {
+	for t := EventWakeWord; t <= EventDeferred; t++ {
+		if s == t.String() {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("voxa: unknown event type %q, want wake_word, final, intent, partial, session_start, session_end, alert, dtmf, amd, vad, error or deferred", s)
+}
+
+// Event is something that happened on a stream, as published to
+// Config.Sinks and to the subscribers of the pipeline.
+type Event struct {
+	Type      EventType
+	SessionID string
+	Time      time.Time
+	// Tenant is set for EventSessionStart to StreamOptions.Tenant.
+	Tenant string
+	// WakeWord is set for EventWakeWord.
+	WakeWord *WakeWordDetection
+	// Segment is set for EventFinal and EventPartial, for EventIntent
+	// and EventAlert to the segment the intent was recognized in or the
+	// rule matched, and for EventError to the segment that failed.
+	Segment *Segment
+	// Intent is set for EventIntent.
+	Intent *Intent
+	// Alert is set for EventAlert.
+	Alert *Alert
+	// DTMF is set for EventDTMF.
+	DTMF *DTMFDigit
+	// AMD is set for EventAMD.
+	AMD *AMDDecision
+	// VAD is set for EventVAD.
+	VAD *VADEvent
+	// Err is set for EventError, for EventDeferred to the recognizer
+	// failure, and for EventSessionEnd to the error that ended the
+	// stream, as Stream.Err reports it, if any.
+	Err error
+}
+
+// EventSink receives the events of every stream of a pipeline, such as to
+// forward them to a message broker. Publish is called on the audio and
+// segment paths of streams, concurrently, so it must not block: sinks
+// queue events and deliver them in the background, dropping or spilling
+// them when they fall behind. What the event points to is only valid during
+// the call, so sinks encode or copy it.
+type EventSink interface {
+	Publish(Event)
+}
+
+// publish hands ev, from s, to the sinks and the subscribers.
+func (s *Stream) publish(ev Event) {
+	if len(s.sinks) == 0 && !s.bus.active() {
+		return
+	}
+	ev = s.event(ev)
+	if ev.Type != EventVAD && ev.Type != EventError {
+		for _, sink := range s.sinks {
+			sink.Publish(ev)
+		}
+	}
+	s.bus.publish(ev)
+}
+
+// event returns ev stamped with the session of s and the time.
+func (s *Stream) event(ev Event) Event {
+	ev.SessionID, ev.Time = s.session, time.Now()
+	return ev
+}
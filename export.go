@@ -0,0 +1,76 @@
+package voxa
+
+import (
+	"io"
+
+	"github.com/jmarc101/voxa/internal/export"
+)
+
+// SubtitleOptions controls how transcripts are split into subtitle cues.
+type SubtitleOptions = export.Options
+
+// WriteSRT writes the final segments of a transcript as a SubRip file.
+func WriteSRT(w io.Writer, segs []Segment, opts SubtitleOptions) error {
+	return export.WriteSRT(w, segs, opts)
+}
+
+// WriteVTT writes the final segments of a transcript as a WebVTT file.
+func WriteVTT(w io.Writer, segs []Segment, opts SubtitleOptions) error {
+	return export.WriteVTT(w, segs, opts)
+}
+
+// WriteText writes the final segments of a transcript as plain text, one
+// utterance per line.
+func WriteText(w io.Writer, segs []Segment, opts SubtitleOptions) error {
+	return export.WriteText(w, segs, opts)
+}
+
+// WriteJSON writes the final segments of a transcript as JSON.
+func WriteJSON(w io.Writer, segs []Segment) error {
+	return export.WriteJSON(w, segs)
+}
+
+// WriteNDJSON writes the final segments of a transcript as NDJSON, one
+// SegmentLine per line.
+func WriteNDJSON(w io.Writer, segs []Segment) error {
+	return export.WriteNDJSON(w, segs)
+}
+
+// WriteProto writes the final segments of a transcript as a binary
+// voxa.voxad.v1 Transcript protobuf message.
+func WriteProto(w io.Writer, segs []Segment) error {
+	return export.WriteProto(w, segs)
+}
+
+// ReadJSON reads back a transcript written by WriteJSON.
+func ReadJSON(r io.Reader) ([]Segment, error) {
+	return export.ReadJSON(r)
+}
+
+// ReviewOptions selects the segments of a transcript flagged for human
+// review: those under a confidence threshold.
+type ReviewOptions = export.ReviewOptions
+
+// Review lists the segments of a transcript flagged for review, with the
+// clips of the recording to listen to and the reviewers' corrections.
+type Review = export.Review
+
+// ReviewItem is a segment flagged for review.
+type ReviewItem = export.ReviewItem
+
+// WriteReview writes the low-confidence final segments of a transcript as
+// a JSON Review for people to correct.
+func WriteReview(w io.Writer, segs []Segment, opts ReviewOptions) error {
+	return export.WriteReview(w, segs, opts)
+}
+
+// ReadReview reads a Review written by WriteReview and corrected.
+func ReadReview(r io.Reader) (Review, error) {
+	return export.ReadReview(r)
+}
+
+// ApplyReview merges the corrections of rv into a transcript, returning it
+// with the number of segments corrected.
+func ApplyReview(segs []Segment, rv Review) ([]Segment, int, error) {
+	return export.ApplyReview(segs, rv)
+}
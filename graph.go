@@ -0,0 +1,94 @@
+package voxa
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/beam"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// StageGraph is the processing a pipeline applies to audio from a source,
+// in order: the audio stages, the recognizer, the transcript stages every
+// segment goes through, and the steps finals go through after them.
+type StageGraph struct {
+	Source     AudioFormat
+	Audio      []GraphStage
+	Recognizer GraphStage
+	Transcript []string
+	Final      []string
+}
+
+// GraphStage is a stage of a StageGraph, with the format of the audio it
+// takes and the one it passes on.
+type GraphStage struct {
+	Name    string
+	In, Out AudioFormat
+}
+
+// Graph builds the stages a stream would for audio in format, without
+// opening a recognizer stream or processing any audio, and returns their
+// graph. It fails where NewStream would on a stage that cannot take the
+// format, so configurations can be checked before they serve traffic.
+func (p *Pipeline) Graph(format AudioFormat) (StageGraph, error) {
+	if format.SampleRate <= 0 || format.Channels <= 0 {
+		return StageGraph{}, fmt.Errorf("voxa: bad format %+v", format)
+	}
+	g := StageGraph{Source: format}
+	target, cur := p.recognizerFormat(format), format
+	if p.cfg.Beamforming != nil && format.Channels > 1 {
+		b, err := beam.New(*p.cfg.Beamforming, format)
+		if err != nil {
+			return StageGraph{}, fmt.Errorf("voxa: beamform: %w", err)
+		}
+		g.Audio = append(g.Audio, GraphStage{Name: "beamform", In: cur, Out: b.Format()})
+		cur = b.Format()
+	}
+	if target != cur {
+		if _, err := audio.NewConverter(cur, target, p.cfg.ResampleQuality); err != nil {
+			return StageGraph{}, fmt.Errorf("voxa: convert: %w", err)
+		}
+		g.Audio = append(g.Audio, GraphStage{Name: "convert", In: cur, Out: target})
+	}
+
+	// The stages only start work once audio flows; a cancelled context
+	// stops any they start regardless.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := &Stream{ctx: ctx, log: logging.Nop(), format: format, trace: &utterances{tracer: p.tracer(), parent: ctx}}
+	_, names, err := p.stages(s, target, StreamOptions{})
+	s.closeEcho()
+	if err != nil {
+		return StageGraph{}, err
+	}
+	for _, name := range names {
+		g.Audio = append(g.Audio, GraphStage{Name: name, In: target, Out: target})
+	}
+	g.Recognizer = GraphStage{Name: p.cfg.Recognizer.Provider, In: target}
+
+	if p.correct && p.cfg.Vocabulary != nil && len(p.cfg.Vocabulary.Phrases) > 0 {
+		g.Transcript = append(g.Transcript, "vocabulary")
+	}
+	for _, t := range p.post {
+		g.Transcript = append(g.Transcript, t.name)
+	}
+	if p.rules != nil {
+		g.Transcript = append(g.Transcript, "alerts")
+	}
+	for _, st := range []struct {
+		name string
+		on   bool
+	}{
+		{"sentiment", p.sent != nil},
+		{"translation", p.trans != nil},
+		{"intents", p.cfg.Intents != nil},
+		{"transcripts", p.cfg.Transcripts != nil},
+		{"turns", p.sessions != nil && p.cfg.OnTurn != nil},
+	} {
+		if st.on {
+			g.Final = append(g.Final, st.name)
+		}
+	}
+	return g, nil
+}
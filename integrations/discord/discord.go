@@ -0,0 +1,248 @@
+// Package discord transcribes Discord voice channels, one stream per
+// speaker.
+//
+// Discord sends the voice of every member of a channel as an Opus stream
+// of its own, told apart by its RTP synchronization source (SSRC), and
+// says whose it is in speaking events, which may come after the first
+// packets. A Listener puts the two together: the packets of a source are
+// held until its user is known, then every speaker's packets are jitter
+// buffered, decoded and transcribed on a pipeline stream of their own, so
+// transcripts come out per user. A speaker's stream ends after Config.Idle
+// without their voice, and a new one starts when they speak again.
+//
+// Join connects a Listener to a voice channel with discordgo, whose voice
+// connection does the Discord side: the gateway, UDP and decryption. A
+// Listener can be fed by any other voice client with Speaking and Packet.
+//
+// Decoding Opus needs voxa built with libopus (-tags opus).
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/opus"
+	"github.com/jmarc101/voxa/internal/ingest/rtp"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// maxHeld bounds the packets held for a source whose user is not known
+// yet: a second of 20ms frames.
+const maxHeld = 50
+
+// Speaker is the voice of a user, transcribed on one stream.
+type Speaker struct {
+	UserID string
+	SSRC   uint32
+	// SessionID names the pipeline stream, after the channel, the user
+	// and the time the stream started.
+	SessionID string
+	Started   time.Time
+}
+
+// Config configures a Listener.
+type Config struct {
+	// Pipeline transcribes the speakers.
+	Pipeline *voxa.Pipeline
+	// Options are the stream options of every speaker; SessionID is set
+	// per speaker.
+	Options voxa.StreamOptions
+	// Idle ends the stream of a speaker who has not spoken for this long.
+	// Defaults to 10 seconds.
+	Idle time.Duration
+	// Ignore, if set, reports users not to transcribe, such as bots.
+	Ignore func(userID string) bool
+	// OnSegment, if set, is called for every segment of every speaker,
+	// from one goroutine per speaker.
+	OnSegment func(Speaker, voxa.Segment)
+	// OnEnd, if set, is called once the stream of a speaker has ended,
+	// with the error that ended it, if any.
+	OnEnd func(Speaker, error)
+	// Logger receives speaker starts, ends and failures. Nil discards
+	// them.
+	Logger voxa.Logger
+}
+
+// Packet is an Opus packet of a voice channel.
+type Packet struct {
+	SSRC      uint32
+	Sequence  uint16
+	Timestamp uint32
+	Opus      []byte
+}
+
+// Listener transcribes the speakers of one voice channel. It is safe for
+// concurrent use.
+type Listener struct {
+	cfg     Config
+	channel string
+	log     logging.Logger
+	calls   *rtp.Server
+
+	mu       sync.Mutex
+	users    map[uint32]string     // by SSRC, from speaking events
+	held     map[uint32][]Packet   // by SSRC, of unknown users
+	tracks   map[uint32]*rtp.Track // by SSRC, of the streams running
+	speakers map[string]Speaker    // by session ID
+	closed   bool
+}
+
+// NewListener validates cfg. channelID names the sessions of the channel.
+func NewListener(channelID string, cfg Config) (*Listener, error) {
+	switch {
+	case cfg.Pipeline == nil:
+		return nil, errors.New("discord: no pipeline")
+	case cfg.Idle < 0:
+		return nil, fmt.Errorf("discord: negative idle %v", cfg.Idle)
+	}
+	// Fail now rather than on every speaker without libopus.
+	dec, err := opus.NewDecoder(audio.Format{SampleRate: 48000, Channels: 2})
+	if err != nil {
+		return nil, fmt.Errorf("discord: %w", err)
+	}
+	_ = dec.Close()
+	l := &Listener{
+		cfg:      cfg,
+		channel:  channelID,
+		log:      logging.With(logging.OrNop(cfg.Logger), "channel", channelID),
+		users:    map[uint32]string{},
+		held:     map[uint32][]Packet{},
+		tracks:   map[uint32]*rtp.Track{},
+		speakers: map[string]Speaker{},
+	}
+	calls, err := rtp.New(rtp.Config{
+		Open:      l.open,
+		Idle:      cfg.Idle,
+		OnSegment: l.segment,
+		OnEnd:     l.ended,
+		Logger:    l.log,
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.calls = calls
+	return l, nil
+}
+
+// Speaking records that ssrc carries the voice of userID, as the speaking
+// events of the voice gateway say, and plays the packets held for it.
+func (l *Listener) Speaking(ssrc uint32, userID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed || l.users[ssrc] == userID {
+		return
+	}
+	if t := l.tracks[ssrc]; t != nil {
+		// The source was handed to another user.
+		_ = t.Close()
+		delete(l.tracks, ssrc)
+	}
+	l.users[ssrc] = userID
+	held := l.held[ssrc]
+	delete(l.held, ssrc)
+	for _, p := range held {
+		l.play(p)
+	}
+}
+
+// Packet transcribes p, holding it until the user of its source is known.
+func (l *Listener) Packet(p Packet) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	if _, known := l.users[p.SSRC]; !known {
+		if len(l.held[p.SSRC]) < maxHeld {
+			p.Opus = append([]byte(nil), p.Opus...)
+			l.held[p.SSRC] = append(l.held[p.SSRC], p)
+		}
+		return
+	}
+	l.play(p)
+}
+
+// play writes p to the stream of its speaker, starting it if need be.
+// l.mu is held.
+func (l *Listener) play(p Packet) {
+	user := l.users[p.SSRC]
+	if l.cfg.Ignore != nil && l.cfg.Ignore(user) {
+		return
+	}
+	t := l.tracks[p.SSRC]
+	if t == nil {
+		sp := Speaker{
+			UserID:    user,
+			SSRC:      p.SSRC,
+			SessionID: fmt.Sprintf("discord-%s-%s-%d", l.channel, user, time.Now().UnixMilli()),
+			Started:   time.Now(),
+		}
+		var err error
+		t, err = l.calls.Track(rtp.Call{SSRC: sp.SSRC, Codec: rtp.Opus, SessionID: sp.SessionID, Started: sp.Started}, 0)
+		if err != nil {
+			l.log.Warn("speaker not transcribed", "user", user, "error", err)
+			return
+		}
+		l.tracks[p.SSRC] = t
+		l.speakers[sp.SessionID] = sp
+	}
+	t.WritePayload(p.Sequence, p.Timestamp, p.Opus)
+}
+
+// Speakers lists the speakers being transcribed.
+func (l *Listener) Speakers() []Speaker {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	speakers := make([]Speaker, 0, len(l.speakers))
+	for _, sp := range l.speakers {
+		speakers = append(speakers, sp)
+	}
+	return speakers
+}
+
+// Close ends the streams of all speakers and waits for them to finish
+// transcribing what they received.
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	l.held = map[uint32][]Packet{}
+	l.mu.Unlock()
+	return l.calls.Close()
+}
+
+func (l *Listener) open(ctx context.Context, c rtp.Call, f audio.Format) (*voxa.Stream, func(), error) {
+	opts := l.cfg.Options
+	opts.SessionID = c.SessionID
+	s, err := l.cfg.Pipeline.NewStream(ctx, f, opts)
+	return s, nil, err
+}
+
+func (l *Listener) speaker(sessionID string) Speaker {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.speakers[sessionID]
+}
+
+func (l *Listener) segment(c rtp.Call, seg voxa.Segment) {
+	if l.cfg.OnSegment != nil {
+		l.cfg.OnSegment(l.speaker(c.SessionID), seg)
+	}
+}
+
+func (l *Listener) ended(c rtp.Call, _ rtp.Stats, err error) {
+	l.mu.Lock()
+	sp := l.speakers[c.SessionID]
+	delete(l.speakers, c.SessionID)
+	if t := l.tracks[c.SSRC]; t != nil && t.Call().SessionID == c.SessionID {
+		delete(l.tracks, c.SSRC)
+	}
+	l.mu.Unlock()
+	if l.cfg.OnEnd != nil {
+		l.cfg.OnEnd(sp, err)
+	}
+}
@@ -0,0 +1,77 @@
+package discord
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Channel is a voice channel joined to be transcribed.
+type Channel struct {
+	vc   *discordgo.VoiceConnection
+	l    *Listener
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// Join joins a voice channel, muted, with the gateway session s, which
+// needs the guild voice states intent, and transcribes its speakers until
+// the channel is closed.
+func Join(s *discordgo.Session, guildID, channelID string, cfg Config) (*Channel, error) {
+	l, err := NewListener(channelID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	vc, err := s.ChannelVoiceJoin(guildID, channelID, true, false)
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	if vc.OpusRecv == nil {
+		_ = vc.Disconnect()
+		_ = l.Close()
+		return nil, errors.New("discord: voice connection receives no audio")
+	}
+	vc.AddHandler(func(_ *discordgo.VoiceConnection, u *discordgo.VoiceSpeakingUpdate) {
+		l.Speaking(uint32(u.SSRC), u.UserID)
+	})
+	c := &Channel{vc: vc, l: l, done: make(chan struct{})}
+	c.wg.Add(1)
+	go c.receive()
+	return c, nil
+}
+
+func (c *Channel) receive() {
+	defer c.wg.Done()
+	for {
+		select {
+		case p, ok := <-c.vc.OpusRecv:
+			if !ok {
+				return
+			}
+			c.l.Packet(Packet{SSRC: p.SSRC, Sequence: p.Sequence, Timestamp: p.Timestamp, Opus: p.Opus})
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Listener returns the listener of the channel.
+func (c *Channel) Listener() *Listener { return c.l }
+
+// Close leaves the channel and waits for its speakers to finish
+// transcribing what they said.
+func (c *Channel) Close() error {
+	var err error
+	c.once.Do(func() {
+		close(c.done)
+		c.wg.Wait()
+		err = c.vc.Disconnect()
+		if cerr := c.l.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
@@ -0,0 +1,97 @@
+package voxa
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jmarc101/voxa/internal/nlu"
+	"github.com/jmarc101/voxa/internal/sentiment"
+)
+
+// Intent is what an utterance asks for, with its slots.
+type Intent = nlu.Intent
+
+// IntentParser recognizes intents in final transcripts.
+type IntentParser = nlu.Parser
+
+// IntentDefinition declares an intent and the patterns that trigger it.
+type IntentDefinition = nlu.Definition
+
+// NewIntentPatterns compiles rule-based intent definitions; see
+// nlu.Patterns for the pattern syntax.
+func NewIntentPatterns(defs []IntentDefinition) (IntentParser, error) {
+	p, err := nlu.NewPatterns(defs)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadIntents reads intent definitions from a JSON array.
+func LoadIntents(r io.Reader) ([]IntentDefinition, error) {
+	return nlu.Load(r)
+}
+
+// final processes a final segment of s before it is delivered:
+// translation, intent recognition, storage, the event sinks, then the turn
+// hook. ctx carries the
+// utterance span.
+func (p *Pipeline) final(ctx context.Context, s *Stream, seg *Segment) error {
+	if p.sent != nil {
+		var pr *sentiment.Prosody
+		if s.prosody != nil {
+			if v, ok := s.prosody.Take(); ok {
+				pr = &v
+			}
+		}
+		_ = s.step(ctx, "sentiment", "voxa.sentiment", func(ctx context.Context) error {
+			p.sent.Analyze(ctx, seg, pr)
+			return nil
+		})
+	}
+	if p.trans != nil {
+		_ = s.step(ctx, "translation", "voxa.translate", func(ctx context.Context) error {
+			p.trans.Translate(ctx, seg)
+			return nil
+		})
+	}
+	if p.cfg.Intents != nil {
+		err := s.step(ctx, "intents", "voxa.nlu", func(ctx context.Context) error {
+			in, ok, err := p.cfg.Intents.Parse(ctx, seg.Text)
+			if err != nil {
+				return fmt.Errorf("voxa: intents: %w", err)
+			}
+			if ok {
+				trace.SpanFromContext(ctx).SetAttributes(attribute.String("voxa.intent", in.Name))
+				if p.cfg.OnIntent != nil {
+					p.cfg.OnIntent(ctx, in)
+				}
+				if s.onIntent != nil {
+					s.onIntent(in)
+				}
+				s.publish(Event{Type: EventIntent, Intent: &in, Segment: seg})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if p.cfg.Transcripts != nil {
+		_ = s.step(ctx, "transcripts", "voxa.store", func(ctx context.Context) error {
+			p.storeSegment(ctx, s, *seg)
+			return nil
+		})
+	}
+	s.publish(Event{Type: EventFinal, Segment: seg})
+	if p.sessions == nil || p.cfg.OnTurn == nil {
+		return nil
+	}
+	return s.step(ctx, "turns", "voxa.turn", func(ctx context.Context) error {
+		return p.turn(ctx, s, *seg)
+	})
+}
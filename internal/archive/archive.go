@@ -0,0 +1,402 @@
+// Package archive records the audio of sessions as it is transcribed, so
+// the transcripts can be made again later, with better models.
+//
+// A Recorder encodes the audio written to one stream, as received, into a
+// spool file: WAV, FLAC or Ogg Opus. When the stream closes, or the file
+// reaches the rotation length, the file is uploaded to a Storage, a
+// directory or an S3-compatible bucket, under a name made from the
+// session ID and the time, and recording goes on in a new part. Uploads
+// run in the background and are retried; Archiver.Close waits for them.
+// Config.OnStored learns of each upload, so a transcript store can list
+// the audio of a session.
+package archive
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// Format is the encoding of archived audio.
+type Format int
+
+const (
+	// FLAC is lossless and about half the size of WAV for speech.
+	FLAC Format = iota + 1
+	// WAV is uncompressed PCM16.
+	WAV
+	// Opus is lossy, about a tenth of the size of FLAC, in an Ogg
+	// container. It needs voxa built with libopus (-tags opus).
+	Opus
+)
+
+func (f Format) String() string {
+	switch f {
+	case FLAC:
+		return "flac"
+	case WAV:
+		return "wav"
+	case Opus:
+		return "opus"
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// ParseFormat parses a Format name: flac, wav or opus.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range []Format{FLAC, WAV, Opus} {
+		if s == f.String() {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("archive: unknown format %q, want flac, wav or opus", s)
+}
+
+// DefaultName is the name template of archives whose Config sets none.
+const DefaultName = "{date}/{session}/{time}-{part}.{ext}"
+
+// Config configures an Archiver.
+type Config struct {
+	// Storage receives the archives.
+	Storage Storage
+	// Format defaults to FLAC.
+	Format Format
+	// Rotate, if set, ends a file once it holds this much audio and goes
+	// on in the next part, so long sessions are uploaded as they run.
+	Rotate time.Duration
+	// Name is the template of archive names, a slash-separated path in
+	// which {session} is replaced by the session ID, {date} and {time} by
+	// the UTC date (2006-01-02) and time (150405) the part started, {part}
+	// by the part number from 001, and {ext} by the format's extension.
+	// Defaults to DefaultName.
+	Name string
+	// Bitrate is the Opus bitrate in bits per second; 0 lets the encoder
+	// pick.
+	Bitrate int
+	// Spool is the directory files are recorded in until they are
+	// uploaded. Defaults to the system temporary directory.
+	Spool string
+	// Retry tunes how uploads are retried.
+	Retry resilience.Config
+	// OnError, if set, is called for every recording or upload that
+	// failed, after the failure is logged.
+	OnError func(error)
+	// OnStored, if set, is called for every part once it is uploaded, so
+	// it can be found again.
+	OnStored func(Stored)
+	// Logger receives upload failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// Stored describes an uploaded part of the recording of a stream.
+type Stored struct {
+	// Session is the session ID given to Record.
+	Session string
+	// Stream is an ID Record makes up to tell apart the streams of a
+	// session.
+	Stream string
+	// Part numbers the parts of the stream from 1.
+	Part int
+	// Name is the name of the archive in the Storage.
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+}
+
+// Archiver records streams into a Storage. It is safe for concurrent use.
+type Archiver struct {
+	cfg   Config
+	log   logging.Logger
+	retry *resilience.Policy
+
+	uploads sync.WaitGroup
+	mu      sync.Mutex
+	closed  bool
+}
+
+// New validates cfg.
+func New(cfg Config) (*Archiver, error) {
+	if cfg.Storage == nil {
+		return nil, errors.New("archive: no storage")
+	}
+	if cfg.Format == 0 {
+		cfg.Format = FLAC
+	}
+	if cfg.Name == "" {
+		cfg.Name = DefaultName
+	}
+	switch {
+	case cfg.Format < FLAC || cfg.Format > Opus:
+		return nil, fmt.Errorf("archive: unknown format %v", cfg.Format)
+	case cfg.Rotate < 0:
+		return nil, fmt.Errorf("archive: negative rotation %v", cfg.Rotate)
+	case cfg.Rotate > 0 && !strings.Contains(cfg.Name, "{part}"):
+		return nil, fmt.Errorf("archive: name %q must contain {part} to rotate", cfg.Name)
+	case !strings.Contains(cfg.Name, "{session}"):
+		return nil, fmt.Errorf("archive: name %q must contain {session}", cfg.Name)
+	}
+	if cfg.Format == Opus {
+		if err := probeOpus(cfg.Bitrate); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Spool == "" {
+		cfg.Spool = os.TempDir()
+	}
+	log := logging.OrNop(cfg.Logger)
+	retry, err := resilience.New(cfg.Retry, log)
+	if err != nil {
+		return nil, err
+	}
+	return &Archiver{cfg: cfg, log: log, retry: retry}, nil
+}
+
+// Record starts recording a stream of audio in format f for session. No
+// file is created until audio is written.
+func (a *Archiver) Record(session string, f audio.Format) (*Recorder, error) {
+	if f.SampleRate <= 0 || f.Channels <= 0 {
+		return nil, fmt.Errorf("archive: bad format %+v", f)
+	}
+	r := &Recorder{a: a, session: session, stream: newStreamID(), format: f}
+	if a.cfg.Rotate > 0 {
+		r.limit = f.Samples(a.cfg.Rotate)
+	}
+	return r, nil
+}
+
+// Close waits for the uploads in progress, after which the archiver takes
+// no more.
+func (a *Archiver) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+	a.uploads.Wait()
+	return nil
+}
+
+// name renders the name template for part n of session, started at t.
+func (a *Archiver) name(session string, n int, t time.Time) string {
+	t = t.UTC()
+	return strings.NewReplacer(
+		"{session}", session,
+		"{date}", t.Format("2006-01-02"),
+		"{time}", t.Format("150405"),
+		"{part}", fmt.Sprintf("%03d", n),
+		"{ext}", a.cfg.Format.String(),
+	).Replace(a.cfg.Name)
+}
+
+// upload stores the finished spool file f in the background, then removes
+// it.
+func (a *Archiver) upload(f *os.File, st Stored) {
+	name := st.Name
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		a.fail(fmt.Errorf("archive: %s: archiver closed", name))
+		discard(f)
+		return
+	}
+	a.uploads.Add(1)
+	go func() {
+		defer a.uploads.Done()
+		defer discard(f)
+		size, err := f.Seek(0, io.SeekEnd)
+		if err == nil {
+			err = a.retry.Do(context.Background(), func() error {
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				return a.cfg.Storage.Put(context.Background(), name, f, size)
+			})
+		}
+		if err != nil {
+			a.fail(fmt.Errorf("archive: upload %s: %w", name, err))
+			return
+		}
+		a.log.Debug("archived audio", "name", name, "bytes", size)
+		if a.cfg.OnStored != nil {
+			a.cfg.OnStored(st)
+		}
+	}()
+}
+
+func (a *Archiver) fail(err error) {
+	a.log.Warn("archiving audio failed", "error", err)
+	if a.cfg.OnError != nil {
+		a.cfg.OnError(err)
+	}
+}
+
+// Recorder records one stream. Its methods must be called from one
+// goroutine.
+type Recorder struct {
+	a       *Archiver
+	session string
+	stream  string
+	format  audio.Format
+	limit   int // samples per channel in a part; 0 without rotation
+
+	part    int       // number of the current part, from 1
+	started time.Time // of the current part
+	file    *os.File  // spool file of the current part, nil between parts
+	out     *spool
+	enc     encoder
+	samples int // per channel in the current part
+	err     error
+}
+
+// Write records fr, which must be in the format given to Record. After a
+// failure, which discards the part being recorded, Write returns the same
+// error and records nothing more.
+func (r *Recorder) Write(fr audio.Frame) error {
+	if r.err != nil {
+		return r.err
+	}
+	if fr.Format != r.format {
+		return r.abort(fmt.Errorf("archive: frame format %+v, stream %+v", fr.Format, r.format))
+	}
+	data := fr.Data
+	for len(data) >= r.format.Channels {
+		if r.file == nil {
+			if err := r.open(); err != nil {
+				return r.abort(err)
+			}
+		}
+		n := len(data) / r.format.Channels
+		if r.limit > 0 {
+			n = min(n, r.limit-r.samples)
+		}
+		if err := r.enc.write(data[:n*r.format.Channels]); err != nil {
+			return r.abort(fmt.Errorf("archive: %w", err))
+		}
+		r.samples += n
+		data = data[n*r.format.Channels:]
+		if r.limit > 0 && r.samples >= r.limit {
+			if err := r.finish(); err != nil {
+				return r.abort(err)
+			}
+		}
+	}
+	return nil
+}
+
+// Close ends the part being recorded and uploads it.
+func (r *Recorder) Close() error {
+	if r.err != nil || r.file == nil {
+		return nil
+	}
+	if err := r.finish(); err != nil {
+		_ = r.abort(err)
+		return err
+	}
+	r.err = errors.New("archive: recorder closed")
+	return nil
+}
+
+func (r *Recorder) open() error {
+	f, err := os.CreateTemp(r.a.cfg.Spool, "voxa-archive-*."+r.a.cfg.Format.String())
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	r.out = &spool{f: f, w: bufio.NewWriterSize(f, 64<<10)}
+	if r.enc, err = newEncoder(r.a.cfg.Format, r.out, r.format, r.a.cfg.Bitrate); err != nil {
+		discard(f)
+		return fmt.Errorf("archive: %w", err)
+	}
+	r.file, r.part, r.started, r.samples = f, r.part+1, time.Now(), 0
+	return nil
+}
+
+// finish completes the current part and hands it to the uploader.
+func (r *Recorder) finish() error {
+	if err := r.enc.close(); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	if err := r.out.w.Flush(); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	r.a.upload(r.file, Stored{
+		Session:  r.session,
+		Stream:   r.stream,
+		Part:     r.part,
+		Name:     r.a.name(safeName(r.session), r.part, r.started),
+		Started:  r.started,
+		Duration: r.format.Duration(r.samples),
+	})
+	r.file, r.out, r.enc = nil, nil, nil
+	return nil
+}
+
+// abort discards the current part and stops recording.
+func (r *Recorder) abort(err error) error {
+	if r.enc != nil {
+		_ = r.enc.close()
+	}
+	if r.file != nil {
+		discard(r.file)
+	}
+	r.file, r.out, r.enc = nil, nil, nil
+	r.err = err
+	r.a.fail(err)
+	return err
+}
+
+// spool buffers writes to a spool file. Encoders that patch headers seek
+// it, which flushes the buffer first.
+type spool struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+func (s *spool) Write(p []byte) (int, error) { return s.w.Write(p) }
+
+func (s *spool) WriteByte(c byte) error { return s.w.WriteByte(c) }
+
+func (s *spool) Seek(offset int64, whence int) (int64, error) {
+	if err := s.w.Flush(); err != nil {
+		return 0, err
+	}
+	return s.f.Seek(offset, whence)
+}
+
+// newStreamID returns 8 random hex digits.
+func newStreamID() string {
+	return fmt.Sprintf("%08x", rand.Uint32())
+}
+
+func discard(f *os.File) {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// safeName makes a session ID usable as a path element: anything but
+// letters, digits, dots, dashes and underscores becomes an underscore, and
+// a leading dot is escaped too.
+func safeName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_':
+		case c == '.' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
@@ -0,0 +1,297 @@
+package archive
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/opus"
+)
+
+// encoder writes interleaved PCM16 to a spool file in one format.
+type encoder interface {
+	write(samples []int16) error
+	// close completes the file; the spool stays open.
+	close() error
+}
+
+func newEncoder(fm Format, w *spool, f audio.Format, bitrate int) (encoder, error) {
+	switch fm {
+	case WAV:
+		return newWAVEncoder(w, f)
+	case FLAC:
+		return newFLACEncoder(w, f)
+	case Opus:
+		return newOpusEncoder(w, f, bitrate)
+	}
+	return nil, fmt.Errorf("unknown format %v", fm)
+}
+
+// wavEncoder writes a RIFF/WAVE file, patching its sizes on close.
+type wavEncoder struct {
+	w    *spool
+	size int64 // bytes of samples written
+	buf  []byte
+}
+
+func newWAVEncoder(w *spool, f audio.Format) (*wavEncoder, error) {
+	var h [44]byte
+	copy(h[0:], "RIFF")
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(h[22:], uint16(f.Channels))
+	binary.LittleEndian.PutUint32(h[24:], uint32(f.SampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(f.SampleRate*f.Channels*2))
+	binary.LittleEndian.PutUint16(h[32:], uint16(f.Channels*2))
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	if _, err := w.Write(h[:]); err != nil {
+		return nil, err
+	}
+	return &wavEncoder{w: w}, nil
+}
+
+func (e *wavEncoder) write(samples []int16) error {
+	e.buf = audio.AppendPCM16(e.buf[:0], samples)
+	_, err := e.w.Write(e.buf)
+	e.size += int64(len(e.buf))
+	return err
+}
+
+func (e *wavEncoder) close() error {
+	if e.size > 1<<32-1-36 {
+		return errors.New("wav: file over 4GiB; set a rotation")
+	}
+	var b [4]byte
+	for _, patch := range []struct{ at, v int64 }{{4, 36 + e.size}, {40, e.size}} {
+		if _, err := e.w.Seek(patch.at, io.SeekStart); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(b[:], uint32(patch.v))
+		if _, err := e.w.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	_, err := e.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// flacBlock is the number of samples per channel in a FLAC frame.
+const flacBlock = 4096
+
+// flacEncoder writes FLAC frames of flacBlock samples with fixed
+// prediction, and the stream length and checksum on close.
+type flacEncoder struct {
+	enc      *flac.Encoder
+	channels int
+	rate     int
+	pending  []int16
+}
+
+func newFLACEncoder(w *spool, f audio.Format) (*flacEncoder, error) {
+	if f.Channels > 8 {
+		return nil, fmt.Errorf("flac: %d channels, at most 8", f.Channels)
+	}
+	enc, err := flac.NewEncoder(w, &meta.StreamInfo{
+		BlockSizeMin:  flacBlock,
+		BlockSizeMax:  flacBlock,
+		SampleRate:    uint32(f.SampleRate),
+		NChannels:     uint8(f.Channels),
+		BitsPerSample: 16,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("flac: %w", err)
+	}
+	return &flacEncoder{enc: enc, channels: f.Channels, rate: f.SampleRate}, nil
+}
+
+func (e *flacEncoder) write(samples []int16) error {
+	e.pending = append(e.pending, samples...)
+	step := flacBlock * e.channels
+	var err error
+	for len(e.pending) >= step && err == nil {
+		err = e.frame(e.pending[:step])
+		e.pending = e.pending[step:]
+	}
+	e.pending = append(e.pending[:0:0], e.pending...)
+	return err
+}
+
+func (e *flacEncoder) frame(samples []int16) error {
+	n := len(samples) / e.channels
+	f := &frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         uint16(n),
+			SampleRate:        uint32(e.rate),
+			Channels:          frame.Channels(e.channels - 1), // ChannelsMono, ChannelsLR...
+			BitsPerSample:     16,
+		},
+		Subframes: make([]*frame.Subframe, e.channels),
+	}
+	for ch := range f.Subframes {
+		s := make([]int32, n)
+		for i := range s {
+			s[i] = int32(samples[i*e.channels+ch])
+		}
+		// The encoder replaces verbatim subframes by the best fixed
+		// predictor.
+		f.Subframes[ch] = &frame.Subframe{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: s, NSamples: n}
+	}
+	if err := e.enc.WriteFrame(f); err != nil {
+		return fmt.Errorf("flac: %w", err)
+	}
+	return nil
+}
+
+func (e *flacEncoder) close() error {
+	if len(e.pending) > 0 {
+		if err := e.frame(e.pending); err != nil {
+			return err
+		}
+		e.pending = nil
+	}
+	// The spool is not an io.Closer, so this only rewrites the header.
+	if err := e.enc.Close(); err != nil {
+		return fmt.Errorf("flac: %w", err)
+	}
+	return nil
+}
+
+// opusRates are the sample rates Opus encodes; others are resampled to
+// the next one up.
+var opusRates = []int{8000, 12000, 16000, 24000, 48000}
+
+// opusPacket is the duration of an Opus packet.
+const opusPacket = 20 * time.Millisecond
+
+// opusEncoder writes Ogg Opus (RFC 7845), converting sources Opus cannot
+// encode directly.
+type opusEncoder struct {
+	enc     *opus.Encoder
+	conv    *audio.Converter
+	from    audio.Format
+	ogg     *opus.OggWriter
+	in      int64 // samples per channel written, at the source rate
+	packets int64
+}
+
+func opusFormat(f audio.Format) audio.Format {
+	out := audio.Format{SampleRate: opusRates[len(opusRates)-1], Channels: min(f.Channels, 2)}
+	if f.Channels > 2 {
+		out.Channels = 1
+	}
+	for _, r := range opusRates {
+		if r >= f.SampleRate {
+			out.SampleRate = r
+			break
+		}
+	}
+	return out
+}
+
+// probeOpus checks that voxa was built with the Opus codec.
+func probeOpus(bitrate int) error {
+	enc, err := opus.NewEncoder(opus.EncoderConfig{
+		Format:        audio.Format{SampleRate: 48000, Channels: 1},
+		Bitrate:       bitrate,
+		FrameDuration: opusPacket,
+	})
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return enc.Close()
+}
+
+func newOpusEncoder(w *spool, f audio.Format, bitrate int) (*opusEncoder, error) {
+	to := opusFormat(f)
+	e := &opusEncoder{from: f, ogg: opus.NewOggWriter(w)}
+	if to != f {
+		c, err := audio.NewConverter(f, to, audio.QualityMedium)
+		if err != nil {
+			return nil, err
+		}
+		e.conv = c
+	}
+	enc, err := opus.NewEncoder(opus.EncoderConfig{Format: to, Bitrate: bitrate, FrameDuration: opusPacket})
+	if err != nil {
+		return nil, err
+	}
+	e.enc = enc
+	if err := e.ogg.Headers(to.Channels, f.SampleRate); err != nil {
+		_ = enc.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *opusEncoder) write(samples []int16) error {
+	fr := audio.Frame{Format: e.from, Data: samples, Offset: e.from.Duration(int(e.in))}
+	e.in += int64(fr.Len())
+	if e.conv == nil {
+		return e.encode(fr)
+	}
+	frames, err := e.conv.Process(fr)
+	if err != nil {
+		return err
+	}
+	for _, f := range frames {
+		if err := e.encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *opusEncoder) encode(fr audio.Frame) error {
+	pkts, err := e.enc.Encode(fr)
+	if err != nil {
+		return err
+	}
+	for _, p := range pkts {
+		if err := e.packet(p, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packet adds a packet to the stream. Granule positions count 48kHz
+// samples; the last one stops at the end of the input, so players drop
+// the padding of the final packet.
+func (e *opusEncoder) packet(p []byte, last bool) error {
+	e.packets++
+	granule := opus.OggPreSkip + e.packets*int64(opusPacket/(time.Second/48000))
+	if last {
+		granule = min(granule, opus.OggPreSkip+e.in*48000/int64(e.from.SampleRate))
+	}
+	return e.ogg.Packet(p, granule, last)
+}
+
+func (e *opusEncoder) close() error {
+	defer e.enc.Close()
+	if e.conv != nil {
+		for _, f := range e.conv.Flush() {
+			if err := e.encode(f); err != nil {
+				return err
+			}
+		}
+	}
+	p, err := e.enc.Flush()
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		// The stream must still end with a page marked as last.
+		return e.ogg.End(opus.OggPreSkip + e.in*48000/int64(e.from.SampleRate))
+	}
+	return e.packet(p, true)
+}
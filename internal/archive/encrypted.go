@@ -0,0 +1,60 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"io"
+
+	"github.com/jmarc101/voxa/internal/envelope"
+)
+
+// Encrypted is a Storage keeping archives encrypted in another.
+type Encrypted struct {
+	storage Storage
+	env     *envelope.Envelope
+}
+
+// Encrypt returns a Storage encrypting archives with env before storing
+// them in s. Archives stored before encryption was turned on are still
+// read back, as they are.
+func Encrypt(s Storage, env *envelope.Envelope) *Encrypted {
+	return &Encrypted{storage: s, env: env}
+}
+
+// Put implements Storage.
+func (e *Encrypted) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	er, n, err := e.env.Encrypt(ctx, r, size)
+	if err != nil {
+		return err
+	}
+	return e.storage.Put(ctx, name, er, n)
+}
+
+// Open implements Storage.
+func (e *Encrypted) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	rc, err := e.storage.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(rc)
+	if head, _ := br.Peek(4); !envelope.IsEncrypted(head) {
+		return readCloser{br, rc}, nil
+	}
+	r, err := e.env.Decrypt(ctx, br)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return readCloser{r, rc}, nil
+}
+
+// Delete implements Storage.
+func (e *Encrypted) Delete(ctx context.Context, name string) error {
+	return e.storage.Delete(ctx, name)
+}
+
+// readCloser reads from a reader over what it closes.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,191 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/awssig"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// S3Config locates an S3-compatible bucket.
+type S3Config struct {
+	// Bucket receives the archives.
+	Bucket string
+	// Prefix is prepended to archive names, e.g. "audio/".
+	Prefix string
+	// Region defaults to $AWS_REGION, then us-east-1.
+	Region string
+	// Endpoint is the URL of an S3-compatible service such as MinIO,
+	// whose buckets are addressed by path. Defaults to $AWS_ENDPOINT_URL,
+	// then to AWS, whose buckets are addressed by host name.
+	Endpoint string
+	// AccessKey, SecretKey and SessionToken default to
+	// $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN.
+	AccessKey, SecretKey, SessionToken string
+	// Timeout bounds an upload. Defaults to 5 minutes.
+	Timeout time.Duration
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// S3 is a Storage in an S3-compatible bucket. Objects are uploaded with a
+// single signed PUT, so an archive can be up to 5GiB, and read back with a
+// GET.
+type S3 struct {
+	cfg  S3Config
+	base *url.URL // of the bucket
+}
+
+// NewS3 validates cfg, filling in the settings it leaves to the
+// environment.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("archive: s3: no bucket")
+	}
+	env := func(v *string, name string) {
+		if *v == "" {
+			*v = os.Getenv(name)
+		}
+	}
+	env(&cfg.Region, "AWS_REGION")
+	env(&cfg.Endpoint, "AWS_ENDPOINT_URL")
+	creds := awssig.Credentials{AccessKey: cfg.AccessKey, SecretKey: cfg.SecretKey, SessionToken: cfg.SessionToken}
+	creds.FromEnv()
+	cfg.AccessKey, cfg.SecretKey, cfg.SessionToken = creds.AccessKey, creds.SecretKey, creds.SessionToken
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("archive: s3: no credentials; set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	var base *url.URL
+	if cfg.Endpoint == "" {
+		base = &url.URL{Scheme: "https", Host: cfg.Bucket + ".s3." + cfg.Region + ".amazonaws.com", Path: "/"}
+	} else {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("archive: s3: bad endpoint %q", cfg.Endpoint)
+		}
+		base = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: strings.TrimSuffix(u.Path, "/") + "/" + cfg.Bucket + "/"}
+	}
+	return &S3{cfg: cfg, base: base}, nil
+}
+
+// Put implements Storage.
+func (s *S3) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	clean, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.url(clean), io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType(clean))
+	s.sign(req, "UNSIGNED-PAYLOAD", time.Now())
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// Open implements Storage. The timeout does not apply, as the object is
+// read as the caller goes.
+func (s *S3) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	clean, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(clean), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, awssig.EmptySHA256, time.Now())
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(body))}
+	}
+	return resp.Body, nil
+}
+
+// Delete implements Storage. S3 answers deletes of missing objects as it
+// does others.
+func (s *S3) Delete(ctx context.Context, name string) error {
+	clean, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.url(clean), nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, awssig.EmptySHA256, time.Now())
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// url returns the URL of the object of an archive name.
+func (s *S3) url(name string) string {
+	u := *s.base
+	key := strings.TrimPrefix(s.cfg.Prefix+name, "/")
+	u.Path += key
+	u.RawPath = awssig.EscapePath(s.base.Path) + awssig.EscapePath(key)
+	return u.String()
+}
+
+// sign adds an AWS Signature Version 4 to req, for a body with the given
+// SHA-256 (or UNSIGNED-PAYLOAD).
+func (s *S3) sign(req *http.Request, payload string, t time.Time) {
+	creds := awssig.Credentials{AccessKey: s.cfg.AccessKey, SecretKey: s.cfg.SecretKey, SessionToken: s.cfg.SessionToken}
+	awssig.Sign(req, creds, s.cfg.Region, "s3", payload, t)
+}
+
+func contentType(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".flac"):
+		return "audio/flac"
+	case strings.HasSuffix(name, ".wav"):
+		return "audio/wav"
+	case strings.HasSuffix(name, ".opus"):
+		return "audio/ogg"
+	}
+	return "application/octet-stream"
+}
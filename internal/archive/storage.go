@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage keeps archives. Implementations must be safe for concurrent use.
+type Storage interface {
+	// Put stores the size bytes of r under name, a slash-separated
+	// relative path, replacing any archive of that name.
+	Put(ctx context.Context, name string, r io.Reader, size int64) error
+	// Open reads back the archive stored under name.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// Delete removes the archive stored under name. Deleting a missing
+	// archive is not an error.
+	Delete(ctx context.Context, name string) error
+}
+
+// Dir is a Storage in a local directory, named archives being files under
+// it.
+type Dir struct {
+	root string
+}
+
+// NewDir returns a Storage writing under root, which is created if needed.
+func NewDir(root string) (*Dir, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	return &Dir{root: root}, nil
+}
+
+// Put implements Storage. The file only appears once complete.
+func (d *Dir) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	clean, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(d.root, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".voxa-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	n, err := io.Copy(tmp, r)
+	if err == nil && n != size {
+		err = fmt.Errorf("wrote %d of %d bytes", n, size)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// Open implements Storage.
+func (d *Dir) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	clean, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(d.root, filepath.FromSlash(clean)))
+}
+
+// Delete implements Storage. Directories left empty are kept.
+func (d *Dir) Delete(ctx context.Context, name string) error {
+	clean, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(d.root, filepath.FromSlash(clean))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// cleanName rejects names that would leave the storage root.
+func cleanName(name string) (string, error) {
+	clean := path.Clean(name)
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errors.New("archive: bad name " + name)
+	}
+	return clean, nil
+}
@@ -0,0 +1,265 @@
+// Package aec is an acoustic echo canceller: it removes from microphone
+// audio the sound the same device is playing, such as a voice assistant's
+// own replies, so that they are not transcribed and the user can speak
+// over them.
+//
+// The audio played is the reference, written to a Reference as it is
+// handed to the speaker. Every Canceller, a stage on the capture path of
+// one stream, models the echo path from the speaker to the microphone with
+// an adaptive NLMS filter spanning Config.Tail and subtracts its estimate
+// of the echo. A Geigel double-talk detector freezes adaptation while the
+// user speaks, so the filter does not learn their voice, and the residual
+// echo left while only the device speaks is attenuated further.
+//
+// The reference and the microphone are matched in lockstep: every captured
+// sample consumes one reference sample, or silence if none is waiting.
+// Both sides run on the device clock, so the echo trails its reference by
+// the output and input latencies of the device, which Config.Delay and
+// Config.Tail must cover together.
+package aec
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// Defaults.
+const (
+	DefaultTail = 150 * time.Millisecond
+	// MaxTail bounds Tail: the filter costs two multiplications per tap
+	// and sample.
+	MaxTail = 500 * time.Millisecond
+	// MaxDelay bounds Delay.
+	MaxDelay = time.Second
+)
+
+// Config tunes a canceller.
+type Config struct {
+	// Tail is the length of the echo path modelled: the delay of the echo
+	// and its reverberation in the room. Defaults to DefaultTail.
+	Tail time.Duration
+	// Delay is a bulk delay of the echo the filter need not model, such as
+	// the known latency of a sound server; Tail starts after it.
+	Delay time.Duration
+}
+
+func (c *Config) setDefaults() error {
+	if c.Tail == 0 {
+		c.Tail = DefaultTail
+	}
+	switch {
+	case c.Tail < 0 || c.Tail > MaxTail:
+		return fmt.Errorf("aec: tail %v out of (0, %v]", c.Tail, MaxTail)
+	case c.Delay < 0 || c.Delay > MaxDelay:
+		return fmt.Errorf("aec: delay %v out of [0, %v]", c.Delay, MaxDelay)
+	}
+	return nil
+}
+
+// Adaptation and detection parameters.
+const (
+	step = 0.4 // NLMS step size
+	// A captured sample louder than geigel times the recent peak of the
+	// reference means the user is speaking too.
+	geigel      = 0.6
+	hangover    = 40 * time.Millisecond // double talk lasts at least this
+	farActive   = 1e-3                  // reference peak of a playing device, about -60 dBFS
+	residual    = 0.25                  // gain on the residual echo, -12 dB
+	maxBacklog  = time.Second           // reference held while capture lags
+	regularizer = 1e-6
+)
+
+// Reference carries the audio played on a device to the cancellers of the
+// streams capturing from it. It is safe for concurrent use.
+type Reference struct {
+	mu         sync.Mutex
+	cancellers map[*Canceller]bool
+}
+
+// NewReference returns a Reference with no canceller attached.
+func NewReference() *Reference {
+	return &Reference{cancellers: map[*Canceller]bool{}}
+}
+
+// Write adds fr to the reference, as it is handed to the speaker. fr's
+// samples are not kept.
+func (r *Reference) Write(fr audio.Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.cancellers {
+		c.reference(fr)
+	}
+}
+
+// Canceller is the echo cancellation stage of one mono stream.
+type Canceller struct {
+	ref  *Reference
+	rate int
+
+	// The reference waiting to be matched with captured audio, in the
+	// stream's format. It is written by Reference.Write, under mu.
+	mu      sync.Mutex
+	pending []float64
+	conv    *audio.Converter
+	backlog int
+
+	w       []float64 // filter taps, w[0] weighs the newest reference sample
+	hist    []float64 // reference history, twice over, see push
+	pos     int
+	delay   int
+	energy  float64 // of the reference in the filter window
+	peak    float64 // decaying peak of the reference
+	decay   float64 // of peak, per sample
+	talk    int     // samples of double talk left
+	hold    int     // hangover, in samples
+	scratch []float64
+	pass    [1]audio.Frame
+}
+
+// New creates a canceller for mono audio at sampleRate, attached to ref
+// until Close.
+func New(cfg Config, sampleRate int, ref *Reference) (*Canceller, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("aec: bad sample rate %d", sampleRate)
+	}
+	f := audio.Format{SampleRate: sampleRate, Channels: 1}
+	taps, delay := f.Samples(cfg.Tail), f.Samples(cfg.Delay)
+	c := &Canceller{
+		ref:     ref,
+		rate:    sampleRate,
+		backlog: f.Samples(maxBacklog),
+		w:       make([]float64, taps),
+		hist:    make([]float64, 2*(taps+delay)),
+		delay:   delay,
+		hold:    f.Samples(hangover),
+		// The peak halves over the tail, so it spans the echo it predicts.
+		decay: math.Pow(0.5, 1/float64(taps)),
+	}
+	ref.mu.Lock()
+	ref.cancellers[c] = true
+	ref.mu.Unlock()
+	return c, nil
+}
+
+// Close detaches c from its reference.
+func (c *Canceller) Close() {
+	c.ref.mu.Lock()
+	delete(c.ref.cancellers, c)
+	c.ref.mu.Unlock()
+}
+
+// reference queues the samples of fr, converted to the stream's format.
+func (c *Canceller) reference(fr audio.Frame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	want := audio.Format{SampleRate: c.rate, Channels: 1}
+	frames := []audio.Frame{fr}
+	if fr.Format != want {
+		if c.conv == nil || c.conv.From() != fr.Format {
+			conv, err := audio.NewConverter(fr.Format, want, audio.QualityLow)
+			if err != nil {
+				return // formats no converter handles carry no usable reference
+			}
+			c.conv = conv
+		}
+		frames, _ = c.conv.Process(fr)
+	}
+	for _, f := range frames {
+		for _, v := range f.Data {
+			c.pending = append(c.pending, float64(v)/32768)
+		}
+	}
+	// Capture has stalled, or is not running: keep the latest reference.
+	if n := len(c.pending) - c.backlog; n > 0 {
+		c.pending = c.pending[:copy(c.pending, c.pending[n:])]
+	}
+}
+
+// take moves the next n reference samples to the scratch buffer, padding
+// with silence.
+func (c *Canceller) take(n int) []float64 {
+	if cap(c.scratch) < n {
+		c.scratch = make([]float64, n)
+	}
+	c.scratch = c.scratch[:n]
+	clear(c.scratch)
+	c.mu.Lock()
+	m := copy(c.scratch, c.pending)
+	c.pending = c.pending[:copy(c.pending, c.pending[m:])]
+	c.mu.Unlock()
+	return c.scratch
+}
+
+// Process cancels the echo in fr, in place, and returns it.
+func (c *Canceller) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if fr.Format.Channels != 1 || fr.Format.SampleRate != c.rate {
+		return nil, fmt.Errorf("aec: need mono audio at %d Hz, got %+v", c.rate, fr.Format)
+	}
+	ref := c.take(len(fr.Data))
+	for i, v := range fr.Data {
+		c.push(ref[i])
+		fr.Data[i] = toPCM(c.cancel(float64(v) / 32768))
+	}
+	c.pass[0] = fr
+	return c.pass[:], nil
+}
+
+// push adds a reference sample to the history. The history is kept twice
+// in a ring, so that the newest len(hist)/2 samples are always contiguous
+// from pos, newest first; the filter window starts delay samples in.
+func (c *Canceller) push(x float64) {
+	n := len(c.hist) / 2
+	c.pos--
+	if c.pos < 0 {
+		c.pos = n - 1
+	}
+	out := c.hist[c.pos+n] // leaves the window
+	c.hist[c.pos], c.hist[c.pos+n] = x, x
+	in := c.hist[c.pos+c.delay] // enters it
+	c.energy = max(0, c.energy+in*in-out*out)
+	c.peak = max(math.Abs(in), c.peak*c.decay)
+}
+
+// cancel returns d, a captured sample, without its echo estimate, and
+// adapts the filter.
+func (c *Canceller) cancel(d float64) float64 {
+	if c.peak < farActive {
+		return d // nothing has played for a while: no echo to cancel
+	}
+	x := c.hist[c.pos+c.delay : c.pos+c.delay+len(c.w)]
+	var y float64
+	for k, w := range c.w {
+		y += w * x[k]
+	}
+	e := d - y
+	if math.Abs(d) > geigel*c.peak {
+		c.talk = c.hold
+	}
+	if c.talk > 0 {
+		c.talk--
+		return e
+	}
+	g := step * e / (c.energy + regularizer)
+	for k := range c.w {
+		c.w[k] += g * x[k]
+	}
+	return e * residual
+}
+
+func toPCM(v float64) int16 {
+	v *= 32768
+	switch {
+	case v > math.MaxInt16:
+		return math.MaxInt16
+	case v < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
@@ -0,0 +1,259 @@
+// Package agc is an automatic gain control stage: it brings speech to a
+// steady level, so that quiet talkers are not dropped by voice activity
+// detection and loud ones do not clip the recognizer's input.
+//
+// The level of the stream is measured over the last few hundred
+// milliseconds, as plain RMS or as loudness K-weighted per ITU-R BS.1770
+// (LUFS), and the gain moves toward the one reaching Config.Target: down
+// at the attack rate, up at the slower release rate. Audio below the gate,
+// silence and room tone, holds the gain rather than being boosted to the
+// target. A peak limiter after the gain keeps every sample under
+// Config.Ceiling.
+package agc
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// Defaults.
+const (
+	DefaultTarget  = -20.0 // dBFS, or LUFS
+	DefaultMaxGain = 30.0  // dB
+	DefaultGate    = -50.0 // dBFS, or LUFS
+	DefaultCeiling = -1.0  // dBFS
+	DefaultAttack  = 20 * time.Millisecond
+	DefaultRelease = 500 * time.Millisecond
+)
+
+// Config tunes the gain control. Levels are in dB relative to full scale.
+type Config struct {
+	// Target is the level speech is brought to: its RMS level, or its
+	// loudness in LUFS with Loudness. Defaults to DefaultTarget.
+	Target float64
+	// Loudness measures levels as K-weighted loudness, which follows how
+	// loud speech sounds rather than its energy, instead of RMS.
+	Loudness bool
+	// MaxGain bounds the gain, in dB, so that distant noise is not raised
+	// to the level of speech. Defaults to DefaultMaxGain.
+	MaxGain float64
+	// Gate is the level under which the gain holds still. Defaults to
+	// DefaultGate.
+	Gate float64
+	// Attack is how fast the gain falls on louder audio, and Release how
+	// fast it rises again on quieter audio: the time constants of the
+	// gain. They default to DefaultAttack and DefaultRelease.
+	Attack, Release time.Duration
+	// Ceiling is the peak level the limiter keeps samples under. Defaults
+	// to DefaultCeiling.
+	Ceiling float64
+}
+
+func (c *Config) setDefaults() error {
+	if c.Target == 0 {
+		c.Target = DefaultTarget
+	}
+	if c.MaxGain == 0 {
+		c.MaxGain = DefaultMaxGain
+	}
+	if c.Gate == 0 {
+		c.Gate = DefaultGate
+	}
+	if c.Attack == 0 {
+		c.Attack = DefaultAttack
+	}
+	if c.Release == 0 {
+		c.Release = DefaultRelease
+	}
+	if c.Ceiling == 0 {
+		c.Ceiling = DefaultCeiling
+	}
+	switch {
+	case c.Target >= 0 || c.Target < -60:
+		return fmt.Errorf("agc: target %v dB out of [-60, 0)", c.Target)
+	case c.MaxGain < 0 || c.MaxGain > 60:
+		return fmt.Errorf("agc: max gain %v dB out of (0, 60]", c.MaxGain)
+	case c.Gate >= c.Target:
+		return fmt.Errorf("agc: gate %v dB not under the target %v dB", c.Gate, c.Target)
+	case c.Attack < 0 || c.Release < 0:
+		return fmt.Errorf("agc: negative attack %v or release %v", c.Attack, c.Release)
+	case c.Ceiling > 0 || c.Ceiling < c.Target:
+		return fmt.Errorf("agc: ceiling %v dB out of [target, 0]", c.Ceiling)
+	}
+	return nil
+}
+
+// Measurement parameters.
+const (
+	window       = 400 * time.Millisecond // of the level, BS.1770's momentary loudness
+	blockLength  = 10 * time.Millisecond  // the target gain is updated once per block
+	limitRelease = 50 * time.Millisecond
+)
+
+// Control is the gain control stage of one mono stream.
+type Control struct {
+	target, gate, maxGain float64 // dB
+	ceiling               float64 // linear
+	offset                float64 // dB added to the mean square level, -0.691 for LUFS
+
+	weight        []biquad // K-weighting, with Loudness
+	meanSquare    float64
+	avg           float64 // per sample, of meanSquare
+	block, filled int
+
+	gain, want      float64 // linear: applied, and aimed at
+	attack, release float64 // per-sample smoothing of gain
+	env, limRel     float64 // limiter envelope and its release
+
+	pass [1]audio.Frame
+}
+
+// New creates a gain control for mono audio at sampleRate.
+func New(cfg Config, sampleRate int) (*Control, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("agc: bad sample rate %d", sampleRate)
+	}
+	f := audio.Format{SampleRate: sampleRate, Channels: 1}
+	c := &Control{
+		target:  cfg.Target,
+		gate:    cfg.Gate,
+		maxGain: cfg.MaxGain,
+		ceiling: math.Pow(10, cfg.Ceiling/20),
+		avg:     coefficient(f, window),
+		block:   max(1, f.Samples(blockLength)),
+		gain:    1,
+		want:    1,
+		attack:  coefficient(f, cfg.Attack),
+		release: coefficient(f, cfg.Release),
+		limRel:  1 - coefficient(f, limitRelease),
+	}
+	if cfg.Loudness {
+		c.weight = kWeighting(float64(sampleRate))
+		c.offset = -0.691
+	}
+	return c, nil
+}
+
+// coefficient returns the per-sample smoothing factor of an exponential
+// average with time constant d; 1 follows at once.
+func coefficient(f audio.Format, d time.Duration) float64 {
+	n := f.Samples(d)
+	if n <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-1/float64(n))
+}
+
+// Gain returns the gain applied, in dB, before the limiter.
+func (c *Control) Gain() float64 {
+	return 20 * math.Log10(c.gain)
+}
+
+// Process applies the gain to fr, in place, and returns it.
+func (c *Control) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if fr.Format.Channels != 1 {
+		return nil, fmt.Errorf("agc: need mono audio, got %d channels", fr.Format.Channels)
+	}
+	for i, v := range fr.Data {
+		x := float64(v) / 32768
+		w := x
+		for k := range c.weight {
+			w = c.weight[k].filter(w)
+		}
+		c.meanSquare += c.avg * (w*w - c.meanSquare)
+		if c.filled++; c.filled == c.block {
+			c.filled = 0
+			c.aim()
+		}
+		if c.want < c.gain {
+			c.gain += c.attack * (c.want - c.gain)
+		} else {
+			c.gain += c.release * (c.want - c.gain)
+		}
+		fr.Data[i] = c.limit(x * c.gain)
+	}
+	c.pass[0] = fr
+	return c.pass[:], nil
+}
+
+// aim sets the gain reaching the target from the level measured, unless
+// the stream is below the gate.
+func (c *Control) aim() {
+	level := 10*math.Log10(c.meanSquare+1e-12) + c.offset
+	if level < c.gate {
+		return
+	}
+	db := min(c.target-level, c.maxGain)
+	c.want = math.Pow(10, db/20)
+}
+
+// limit brings y under the ceiling, with an instant attack and a short
+// release so that a peak does not duck the audio around it for long.
+func (c *Control) limit(y float64) int16 {
+	c.env = max(math.Abs(y), c.env*c.limRel)
+	if c.env > c.ceiling {
+		y *= c.ceiling / c.env
+	}
+	return toPCM(y)
+}
+
+func toPCM(v float64) int16 {
+	v *= 32768
+	switch {
+	case v > math.MaxInt16:
+		return math.MaxInt16
+	case v < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
+
+// biquad is a second-order IIR filter section, in direct form I.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1, f.y2, f.y1 = f.x1, x, f.y1, y
+	return y
+}
+
+// kWeighting returns the K-weighting filter of BS.1770 at rate: a high
+// shelf modelling the head, then a high-pass. The coefficients are those
+// of the standard at 48kHz, derived for any rate.
+func kWeighting(rate float64) []biquad {
+	const (
+		shelfFreq = 1681.974450955533
+		shelfGain = 3.999843853973347
+		shelfQ    = 0.7071752369554196
+		passFreq  = 38.13547087602444
+		passQ     = 0.5003270373238773
+	)
+	k := math.Tan(math.Pi * shelfFreq / rate)
+	vh := math.Pow(10, shelfGain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/shelfQ + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/shelfQ + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/shelfQ + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/shelfQ + k*k) / a0,
+	}
+	k = math.Tan(math.Pi * passFreq / rate)
+	a0 = 1 + k/passQ + k*k
+	pass := biquad{
+		b0: 1, b1: -2, b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/passQ + k*k) / a0,
+	}
+	return []biquad{shelf, pass}
+}
@@ -0,0 +1,267 @@
+// Package amd tells, from the first seconds of an answered call, whether a
+// person, an answering machine or hold music picked up, so an outbound
+// dialer engages its assistant only with people.
+//
+// The detector follows the cadence of the audio, in 10ms frames against a
+// silence level, as answering machine detection in PBXes does: a person
+// answers with a short greeting, "Hello?", then waits in silence for a
+// reply, while a machine plays a long greeting of many words, or stays
+// silent before it. Sustained sound whose energy does not dip between
+// syllables, as it does in speech, is taken for music. One decision is
+// made per stream, after which the audio passes by untouched.
+package amd
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// Result is who answered.
+type Result int
+
+const (
+	// Unknown is a call the detector could not decide on in time.
+	Unknown Result = iota
+	// Human is a person.
+	Human
+	// Machine is an answering machine or voicemail.
+	Machine
+	// Music is hold music, or a queue's.
+	Music
+)
+
+func (r Result) String() string {
+	switch r {
+	case Human:
+		return "human"
+	case Machine:
+		return "machine"
+	case Music:
+		return "music"
+	}
+	return "unknown"
+}
+
+// Decision is what the detector made of a call.
+type Decision struct {
+	Result Result
+	// Reason is what decided: after_greeting_silence, initial_silence,
+	// long_greeting, max_words, music or timeout.
+	Reason string
+	// Offset is the stream time at which the decision was made.
+	Offset time.Duration
+	// Words counts the words heard before it.
+	Words int
+}
+
+// Defaults, those of common PBX answering machine detection.
+const (
+	DefaultInitialSilence       = 2500 * time.Millisecond
+	DefaultGreeting             = 1500 * time.Millisecond
+	DefaultAfterGreetingSilence = 800 * time.Millisecond
+	DefaultTotalTime            = 5 * time.Second
+	DefaultMinWord              = 100 * time.Millisecond
+	DefaultBetweenWords         = 50 * time.Millisecond
+	DefaultMaxWords             = 3
+	DefaultSilenceLevel         = -42.0 // dBFS
+)
+
+// Config tunes the detector.
+type Config struct {
+	// InitialSilence is how long silence may last before the first word
+	// before the call is taken for a machine. Defaults to
+	// DefaultInitialSilence.
+	InitialSilence time.Duration
+	// Greeting is how long a person's greeting lasts at most. Defaults to
+	// DefaultGreeting.
+	Greeting time.Duration
+	// AfterGreetingSilence is the silence after a greeting that marks a
+	// person waiting for a reply. Defaults to DefaultAfterGreetingSilence.
+	AfterGreetingSilence time.Duration
+	// TotalTime bounds the analysis: undecided calls are Unknown then.
+	// Defaults to DefaultTotalTime.
+	TotalTime time.Duration
+	// MinWord is the shortest sound counted as a word, and BetweenWords
+	// the shortest silence separating two. They default to DefaultMinWord
+	// and DefaultBetweenWords.
+	MinWord, BetweenWords time.Duration
+	// MaxWords is the number of words a greeting reaching makes the call
+	// a machine's. Defaults to DefaultMaxWords.
+	MaxWords int
+	// SilenceLevel is the RMS level, in dBFS, under which audio is
+	// silence. Defaults to DefaultSilenceLevel.
+	SilenceLevel float64
+	// OnDecision, if set, is called synchronously with the decision.
+	OnDecision func(Decision)
+}
+
+func (c *Config) setDefaults() error {
+	for _, d := range []struct {
+		v   *time.Duration
+		def time.Duration
+	}{
+		{&c.InitialSilence, DefaultInitialSilence},
+		{&c.Greeting, DefaultGreeting},
+		{&c.AfterGreetingSilence, DefaultAfterGreetingSilence},
+		{&c.TotalTime, DefaultTotalTime},
+		{&c.MinWord, DefaultMinWord},
+		{&c.BetweenWords, DefaultBetweenWords},
+	} {
+		if *d.v == 0 {
+			*d.v = d.def
+		}
+		if *d.v < 0 {
+			return fmt.Errorf("amd: negative duration %v", *d.v)
+		}
+	}
+	if c.MaxWords == 0 {
+		c.MaxWords = DefaultMaxWords
+	}
+	if c.SilenceLevel == 0 {
+		c.SilenceLevel = DefaultSilenceLevel
+	}
+	switch {
+	case c.MaxWords < 0:
+		return fmt.Errorf("amd: negative max words %d", c.MaxWords)
+	case c.SilenceLevel >= 0 || c.SilenceLevel < -80:
+		return fmt.Errorf("amd: silence level %v dBFS out of [-80, 0)", c.SilenceLevel)
+	case c.TotalTime < c.Greeting:
+		return fmt.Errorf("amd: total time %v shorter than the greeting %v", c.TotalTime, c.Greeting)
+	}
+	return nil
+}
+
+// Music detection parameters.
+const (
+	frameLength = 10 * time.Millisecond
+	// Speech dips under half its mean energy between syllables in a good
+	// share of its 40ms blocks, music much less: the low short-time energy
+	// ratio of speech/music discrimination.
+	musicBlock = 4 // frames
+	lowEnergy  = 0.5
+	maxLSTER   = 0.15
+)
+
+// Detector is the answering machine detection stage of one mono stream.
+type Detector struct {
+	cfg   Config
+	n     int     // frame, in samples
+	level float64 // mean square under which a frame is silent
+	frame float64 // mean square of the frame being filled
+	fill  int
+	at    time.Duration // stream time of the end of the frame
+
+	elapsed  time.Duration
+	started  bool          // a word has started
+	voice    time.Duration // current run of sound
+	silence  time.Duration // current run of silence
+	greeting time.Duration // since the first word started
+	words    int
+	inWord   bool
+	energies []float64 // of the blocks since the first sound, for music
+	done     bool
+	pass     [1]audio.Frame
+}
+
+// New creates a detector for mono audio at sampleRate.
+func New(cfg Config, sampleRate int) (*Detector, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("amd: bad sample rate %d", sampleRate)
+	}
+	f := audio.Format{SampleRate: sampleRate, Channels: 1}
+	return &Detector{
+		cfg:   cfg,
+		n:     max(1, f.Samples(frameLength)),
+		level: math.Pow(10, cfg.SilenceLevel/10),
+	}, nil
+}
+
+// Process analyses fr, until the decision, and returns it.
+func (d *Detector) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if fr.Format.Channels != 1 {
+		return nil, fmt.Errorf("amd: need mono audio, got %d channels", fr.Format.Channels)
+	}
+	for i := 0; i < len(fr.Data) && !d.done; i++ {
+		x := float64(fr.Data[i]) / 32768
+		d.frame += x * x
+		if d.fill++; d.fill == d.n {
+			d.at = fr.Offset + fr.Format.Duration(i+1)
+			d.analyse(d.frame / float64(d.n))
+			d.frame, d.fill = 0, 0
+		}
+	}
+	d.pass[0] = fr
+	return d.pass[:], nil
+}
+
+// analyse advances the cadence by a frame of mean square ms.
+func (d *Detector) analyse(ms float64) {
+	d.elapsed += frameLength
+	if d.started {
+		if d.greeting%(musicBlock*frameLength) == 0 {
+			d.energies = append(d.energies, 0)
+		}
+		d.energies[len(d.energies)-1] += ms
+		d.greeting += frameLength
+	}
+	if ms < d.level {
+		d.silence += frameLength
+		if d.silence >= d.cfg.BetweenWords {
+			d.inWord, d.voice = false, 0
+		}
+		switch {
+		case !d.started && d.silence >= d.cfg.InitialSilence:
+			d.decide(Machine, "initial_silence")
+		case d.words > 0 && d.silence >= d.cfg.AfterGreetingSilence:
+			d.decide(Human, "after_greeting_silence")
+		}
+	} else {
+		d.silence = 0
+		d.voice += frameLength
+		d.started = true
+		if !d.inWord && d.voice >= d.cfg.MinWord {
+			d.inWord = true
+			d.words++
+		}
+		switch {
+		case d.greeting >= d.cfg.Greeting && d.music():
+			d.decide(Music, "music")
+		case d.greeting >= d.cfg.Greeting:
+			d.decide(Machine, "long_greeting")
+		case d.words >= d.cfg.MaxWords:
+			d.decide(Machine, "max_words")
+		}
+	}
+	if !d.done && d.elapsed >= d.cfg.TotalTime {
+		d.decide(Unknown, "timeout")
+	}
+}
+
+// music reports whether the sound since the first word is music.
+func (d *Detector) music() bool {
+	var mean float64
+	for _, e := range d.energies {
+		mean += e
+	}
+	mean /= float64(len(d.energies))
+	low := 0
+	for _, e := range d.energies {
+		if e < lowEnergy*mean {
+			low++
+		}
+	}
+	return float64(low) < maxLSTER*float64(len(d.energies))
+}
+
+func (d *Detector) decide(r Result, reason string) {
+	d.done, d.energies = true, nil
+	if d.cfg.OnDecision != nil {
+		d.cfg.OnDecision(Decision{Result: r, Reason: reason, Offset: d.at, Words: d.words})
+	}
+}
@@ -0,0 +1,105 @@
+// Package audio holds the PCM frame type shared by the capture, file and
+// streaming paths, plus readers that produce it.
+//
+// Internally all audio is signed 16-bit PCM, interleaved when there is more
+// than one channel. On the wire (gRPC AudioChunk) the same samples travel as
+// little-endian bytes.
+package audio
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// FrameDuration is the default frame size on the streaming path.
+const FrameDuration = 20 * time.Millisecond
+
+// Format describes a PCM16 stream.
+type Format struct {
+	// SampleRate in Hz.
+	SampleRate int
+	// Channels is the number of interleaved channels.
+	Channels int
+}
+
+// Samples returns the number of samples per channel covering d.
+func (f Format) Samples(d time.Duration) int {
+	return int(int64(f.SampleRate) * int64(d) / int64(time.Second))
+}
+
+// Duration returns how long n samples per channel last.
+func (f Format) Duration(n int) time.Duration {
+	if f.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(int64(n) * int64(time.Second) / int64(f.SampleRate))
+}
+
+// Frame is a chunk of interleaved PCM16 audio.
+type Frame struct {
+	Format Format
+	// Data holds interleaved samples; len(Data) is a multiple of Channels.
+	Data []int16
+	// Offset is the stream time of the first sample.
+	Offset time.Duration
+}
+
+// Len returns the number of samples per channel.
+func (f Frame) Len() int {
+	if f.Format.Channels == 0 {
+		return 0
+	}
+	return len(f.Data) / f.Format.Channels
+}
+
+// Duration returns how long the frame lasts.
+func (f Frame) Duration() time.Duration {
+	return f.Format.Duration(f.Len())
+}
+
+// Bytes encodes the frame as little-endian PCM16.
+func (f Frame) Bytes() []byte {
+	return AppendPCM16(make([]byte, 0, 2*len(f.Data)), f.Data)
+}
+
+// AppendPCM16 appends samples to b as little-endian PCM16.
+func AppendPCM16(b []byte, samples []int16) []byte {
+	for _, s := range samples {
+		b = binary.LittleEndian.AppendUint16(b, uint16(s))
+	}
+	return b
+}
+
+// DecodePCM16 decodes little-endian PCM16 bytes into dst, growing it as
+// needed. A trailing odd byte is ignored. Pass a buffer from GetSamples to
+// decode without allocating.
+func DecodePCM16(dst []int16, b []byte) []int16 {
+	n := len(b) / 2
+	if cap(dst) < n {
+		dst = make([]int16, n)
+	}
+	dst = dst[:n]
+	for i := range dst {
+		dst[i] = int16(binary.LittleEndian.Uint16(b[2*i:]))
+	}
+	return dst
+}
+
+// Reader produces frames from a source until it returns io.EOF. The frames
+// belong to the caller, who may Release them once done.
+type Reader interface {
+	Format() Format
+	ReadFrame() (Frame, error)
+}
+
+// Stage is one step of the audio path between the source and the
+// recognizer. It may pass, drop, delay or rewrite frames.
+//
+// So that the path can recycle buffers, a stage must not keep fr's samples
+// after Process returns; stages that hold audio back copy it. The frames
+// returned, and the slice holding them, are only valid until the next call.
+type Stage interface {
+	// Process consumes fr and returns the frames to forward downstream,
+	// which may be none.
+	Process(fr Frame) ([]Frame, error)
+}
@@ -0,0 +1,333 @@
+// Package beam turns the channels of a microphone array into the single
+// mono stream a recognizer takes.
+//
+// The default strategy is delay-and-sum beamforming: the delay with which
+// the talker's voice reaches every microphone is estimated from the audio
+// itself, by GCC-PHAT cross-correlation against the first channel while
+// someone speaks, and the channels are summed once aligned. The voice adds
+// up coherently while diffuse noise and reverberation do not, which gains
+// up to 10·log10(n) dB of signal-to-noise ratio on n microphones. The
+// steering follows the talker as they move, without the geometry of the
+// array being known. Simpler strategies select the loudest channel, a
+// fixed one, or average them all.
+package beam
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"slices"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/dsp"
+)
+
+// Strategies.
+const (
+	// DelaySum aligns the channels on the talker and sums them.
+	DelaySum = "delay_sum"
+	// Loudest selects the channel with the most energy, switching when
+	// another becomes clearly louder.
+	Loudest = "loudest"
+	// Channel selects the first channel of Config.Channels.
+	Channel = "channel"
+	// Average sums the channels without aligning them.
+	Average = "average"
+)
+
+// Bounds.
+const (
+	// MaxChannels bounds the channels of a source.
+	MaxChannels = 32
+	// DefaultMaxDelay is enough for arrays up to 30cm across.
+	DefaultMaxDelay = time.Millisecond
+	// MaxDelay bounds Config.MaxDelay: 3m of sound travel.
+	MaxDelay = 10 * time.Millisecond
+)
+
+// Config tunes a beamformer.
+type Config struct {
+	// Strategy is DelaySum, Loudest, Channel or Average. Defaults to
+	// DelaySum.
+	Strategy string
+	// Channels selects the channels of the source used, by index; arrays
+	// often carry a playback loopback or a vendor-processed channel
+	// alongside the raw microphones. Defaults to all of them.
+	Channels []int
+	// MaxDelay bounds the delay between two microphones DelaySum looks
+	// for, the time sound takes to cross the array. It is also the delay
+	// the strategy adds. Defaults to DefaultMaxDelay.
+	MaxDelay time.Duration
+}
+
+func (c *Config) setDefaults() error {
+	if c.Strategy == "" {
+		c.Strategy = DelaySum
+	}
+	if c.MaxDelay == 0 {
+		c.MaxDelay = DefaultMaxDelay
+	}
+	switch c.Strategy {
+	case DelaySum, Loudest, Channel, Average:
+	default:
+		return fmt.Errorf("beam: unknown strategy %q", c.Strategy)
+	}
+	if c.MaxDelay < 0 || c.MaxDelay > MaxDelay {
+		return fmt.Errorf("beam: max delay %v out of (0, %v]", c.MaxDelay, MaxDelay)
+	}
+	for i, ch := range c.Channels {
+		if ch < 0 || ch >= MaxChannels {
+			return fmt.Errorf("beam: channel %d out of [0, %d)", ch, MaxChannels)
+		}
+		if slices.Contains(c.Channels[:i], ch) {
+			return fmt.Errorf("beam: channel %d selected twice", ch)
+		}
+	}
+	return nil
+}
+
+// Analysis parameters.
+const (
+	block = 32 * time.Millisecond // of the delay estimates
+	// A block is speech worth steering on when it is this much louder
+	// than the noise floor, and its correlation peak this strong.
+	snr       = 2.0
+	coherence = 0.15
+	noiseRise = 1.01                   // per block, of the noise floor
+	smoothing = 200 * time.Millisecond // channel energies, for Loudest
+	switchAt  = 2.0                    // energy ratio, 3 dB
+)
+
+// Beamformer is the stage mixing a multi-channel stream down to mono.
+type Beamformer struct {
+	strategy string
+	in       audio.Format
+	chans    []int
+
+	// DelaySum. Every channel is kept lag samples past and ahead of the
+	// output, so it can be shifted either way by its delay.
+	lag    int
+	delays []int       // of every channel behind the first, in [-lag, lag]
+	votes  []int       // the delay last estimated, which a second block must confirm
+	hist   [][]float64 // per channel: 2·lag samples of history, then the frame
+	acc    [][]float64 // per channel: the block being gathered
+	spec   [][]complex128
+	xcorr  []complex128
+	noise  float64
+
+	// Loudest.
+	power []float64
+	cur   int
+	alpha float64 // per sample
+
+	mix  []float64
+	out  []int16
+	pass [1]audio.Frame
+}
+
+// New creates a beamformer for audio in format in. Its output is mono at
+// the same rate.
+func New(cfg Config, in audio.Format) (*Beamformer, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	if in.SampleRate <= 0 || in.Channels <= 0 || in.Channels > MaxChannels {
+		return nil, fmt.Errorf("beam: bad format %+v", in)
+	}
+	chans := slices.Clone(cfg.Channels)
+	if len(chans) == 0 {
+		for ch := range in.Channels {
+			chans = append(chans, ch)
+		}
+	}
+	for _, ch := range chans {
+		if ch >= in.Channels {
+			return nil, fmt.Errorf("beam: channel %d of a %d-channel source", ch, in.Channels)
+		}
+	}
+	if cfg.Strategy == Channel {
+		chans = chans[:1]
+	}
+	b := &Beamformer{strategy: cfg.Strategy, in: in, chans: chans}
+	mono := audio.Format{SampleRate: in.SampleRate, Channels: 1}
+	switch cfg.Strategy {
+	case DelaySum:
+		b.lag = max(1, mono.Samples(cfg.MaxDelay))
+		n := mono.Samples(block)
+		size := dsp.NextPow2(2 * n)
+		b.delays = make([]int, len(chans))
+		b.votes = make([]int, len(chans))
+		b.hist = make([][]float64, len(chans))
+		b.acc = make([][]float64, len(chans))
+		b.spec = make([][]complex128, len(chans))
+		for i := range chans {
+			b.hist[i] = make([]float64, 2*b.lag)
+			b.acc[i] = make([]float64, 0, n)
+			b.spec[i] = make([]complex128, size)
+		}
+		b.xcorr = make([]complex128, size)
+	case Loudest:
+		b.power = make([]float64, len(chans))
+		b.alpha = 1 - math.Exp(-1/float64(mono.Samples(smoothing)))
+	}
+	return b, nil
+}
+
+// Format returns the format of the audio b outputs.
+func (b *Beamformer) Format() audio.Format {
+	return audio.Format{SampleRate: b.in.SampleRate, Channels: 1}
+}
+
+// Latency returns the delay b adds.
+func (b *Beamformer) Latency() time.Duration {
+	return b.Format().Duration(b.lag)
+}
+
+// Delays returns the delay DelaySum estimates for every channel it uses,
+// in samples behind the first one.
+func (b *Beamformer) Delays() []int {
+	return slices.Clone(b.delays)
+}
+
+// Process mixes fr down to mono.
+func (b *Beamformer) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if fr.Format != b.in {
+		return nil, fmt.Errorf("beam: need %+v audio, got %+v", b.in, fr.Format)
+	}
+	n := fr.Len()
+	if cap(b.mix) < n {
+		b.mix = make([]float64, n)
+	}
+	b.mix = b.mix[:n]
+	clear(b.mix)
+	switch b.strategy {
+	case DelaySum:
+		b.delaySum(fr.Data, n)
+	case Loudest:
+		b.loudest(fr.Data, n)
+	default:
+		for _, ch := range b.chans {
+			for t := range n {
+				b.mix[t] += float64(fr.Data[t*b.in.Channels+ch]) / 32768
+			}
+		}
+		scale(b.mix, len(b.chans))
+	}
+	b.out = dsp.PCM16(b.out, b.mix)
+	b.pass[0] = audio.Frame{Format: b.Format(), Data: b.out, Offset: fr.Offset}
+	return b.pass[:], nil
+}
+
+// delaySum mixes n samples of data into b.mix, each channel shifted by its
+// delay, and updates the delays from every full block.
+func (b *Beamformer) delaySum(data []int16, n int) {
+	for t := range n {
+		for i, ch := range b.chans {
+			v := float64(data[t*b.in.Channels+ch]) / 32768
+			b.hist[i] = append(b.hist[i], v)
+			b.acc[i] = append(b.acc[i], v)
+		}
+		if len(b.acc[0]) == cap(b.acc[0]) {
+			b.estimate()
+		}
+	}
+	for i := range b.chans {
+		h := b.hist[i][b.lag+b.delays[i]:]
+		for t := range n {
+			b.mix[t] += h[t]
+		}
+		// Keep the last 2·lag samples for the next frame.
+		b.hist[i] = b.hist[i][:copy(b.hist[i], b.hist[i][n:])]
+	}
+	scale(b.mix, len(b.chans))
+}
+
+// estimate updates the delays from the block gathered, if it is speech,
+// and starts the next one. A delay changes once two blocks in a row agree
+// on it, so a stray reflection does not swing the beam.
+func (b *Beamformer) estimate() {
+	defer func() {
+		for i := range b.acc {
+			b.acc[i] = b.acc[i][:0]
+		}
+	}()
+	level := dsp.RMS(b.acc[0])
+	if b.noise == 0 || level < b.noise {
+		b.noise = max(level, 1e-5)
+	} else {
+		b.noise *= noiseRise
+	}
+	if level < snr*b.noise {
+		return
+	}
+	for i, x := range b.acc {
+		s := b.spec[i]
+		clear(s)
+		for t, v := range x {
+			s[t] = complex(v, 0)
+		}
+		dsp.FFT(s)
+	}
+	ref := b.spec[0]
+	for i := 1; i < len(b.spec); i++ {
+		// GCC-PHAT: the cross-spectrum whitened to its phase, whose inverse
+		// peaks sharply at the delay whatever the spectrum of the voice.
+		for k, x := range b.spec[i] {
+			c := x * cmplx.Conj(ref[k])
+			if m := cmplx.Abs(c); m > 1e-12 {
+				c /= complex(m, 0)
+			}
+			b.xcorr[k] = c
+		}
+		dsp.IFFT(b.xcorr)
+		best, peak := 0, math.Inf(-1)
+		for d := -b.lag; d <= b.lag; d++ {
+			k := d
+			if k < 0 {
+				k += len(b.xcorr)
+			}
+			if v := real(b.xcorr[k]); v > peak {
+				best, peak = d, v
+			}
+		}
+		if peak < coherence {
+			continue
+		}
+		if best == b.votes[i] {
+			b.delays[i] = best
+		}
+		b.votes[i] = best
+	}
+}
+
+// loudest copies the channel with the most energy into b.mix.
+func (b *Beamformer) loudest(data []int16, n int) {
+	for i, ch := range b.chans {
+		p := b.power[i]
+		for t := range n {
+			v := float64(data[t*b.in.Channels+ch]) / 32768
+			p += b.alpha * (v*v - p)
+		}
+		b.power[i] = p
+	}
+	for i, p := range b.power {
+		if p > switchAt*b.power[b.cur] {
+			b.cur = i
+		}
+	}
+	ch := b.chans[b.cur]
+	for t := range n {
+		b.mix[t] = float64(data[t*b.in.Channels+ch]) / 32768
+	}
+}
+
+func scale(x []float64, n int) {
+	if n <= 1 {
+		return
+	}
+	g := 1 / float64(n)
+	for i := range x {
+		x[i] *= g
+	}
+}
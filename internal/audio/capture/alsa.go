@@ -0,0 +1,183 @@
+//go:build cgo && linux && alsa
+
+package capture
+
+/*
+#cgo pkg-config: alsa
+#include <stdlib.h>
+#include <alsa/asoundlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+func init() { register(alsa{}) }
+
+func alsaError(op string, code C.int) error {
+	return fmt.Errorf("%s: %s", op, C.GoString(C.snd_strerror(code)))
+}
+
+// alsa is the ALSA backend. Devices are opened through the plug layer,
+// which converts rates and channels itself, so the requested format is
+// always granted.
+type alsa struct{}
+
+func (alsa) name() string { return "alsa" }
+
+func (a alsa) devices() ([]Device, error) {
+	var hints *unsafe.Pointer
+	iface := C.CString("pcm")
+	defer C.free(unsafe.Pointer(iface))
+	if rc := C.snd_device_name_hint(-1, iface, &hints); rc < 0 {
+		return nil, alsaError("list devices", rc)
+	}
+	defer C.snd_device_name_free_hint(hints)
+
+	var devs []Device
+	for p := hints; *p != nil; p = (*unsafe.Pointer)(unsafe.Add(unsafe.Pointer(p), unsafe.Sizeof(*p))) {
+		name := hint(*p, "NAME")
+		if name == "" || name == "null" {
+			continue
+		}
+		desc := strings.Join(strings.Fields(hint(*p, "DESC")), " ")
+		if desc == "" {
+			desc = name
+		}
+		d := Device{
+			ID:            name,
+			Name:          desc,
+			Backend:       a.name(),
+			DefaultInput:  name == "default",
+			DefaultOutput: name == "default",
+		}
+		// IOID is unset for devices that work both ways.
+		ioid := hint(*p, "IOID")
+		var rates []int
+		if ioid != "Output" {
+			d.InputChannels, rates = probe(name, C.SND_PCM_STREAM_CAPTURE)
+		}
+		if ioid != "Input" {
+			var out []int
+			d.OutputChannels, out = probe(name, C.SND_PCM_STREAM_PLAYBACK)
+			rates = append(rates, out...)
+		}
+		slices.Sort(rates)
+		d.SampleRates = slices.Compact(rates)
+		devs = append(devs, d)
+	}
+	return devs, nil
+}
+
+// probe opens the device without blocking and reports its maximum channel
+// count and supported standard rates in one direction. Busy or missing
+// devices report nothing.
+func probe(name string, dir C.snd_pcm_stream_t) (int, []int) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	var pcm *C.snd_pcm_t
+	if C.snd_pcm_open(&pcm, cname, dir, C.SND_PCM_NONBLOCK) < 0 {
+		return 0, nil
+	}
+	defer C.snd_pcm_close(pcm)
+	var params *C.snd_pcm_hw_params_t
+	if C.snd_pcm_hw_params_malloc(&params) < 0 {
+		return 0, nil
+	}
+	defer C.snd_pcm_hw_params_free(params)
+	if C.snd_pcm_hw_params_any(pcm, params) < 0 {
+		return 0, nil
+	}
+	var channels C.uint
+	if C.snd_pcm_hw_params_get_channels_max(params, &channels) < 0 {
+		return 0, nil
+	}
+	var rates []int
+	for _, r := range standardRates {
+		if C.snd_pcm_hw_params_test_rate(pcm, params, C.uint(r), 0) == 0 {
+			rates = append(rates, r)
+		}
+	}
+	// Plug devices report absurd maxima; cap them at something real.
+	return int(min(channels, 32)), rates
+}
+
+// hint reads one field of a device name hint.
+func hint(h unsafe.Pointer, id string) string {
+	cid := C.CString(id)
+	defer C.free(unsafe.Pointer(cid))
+	v := C.snd_device_name_get_hint(h, cid)
+	if v == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(v))
+	return C.GoString(v)
+}
+
+// latency is the capture buffer ALSA is asked for.
+const latency = 100 * time.Millisecond
+
+func (a alsa) open(id string, want audio.Format, frame time.Duration) (input, audio.Format, error) {
+	if id == "" {
+		id = "default"
+	}
+	cid := C.CString(id)
+	defer C.free(unsafe.Pointer(cid))
+	var pcm *C.snd_pcm_t
+	if rc := C.snd_pcm_open(&pcm, cid, C.SND_PCM_STREAM_CAPTURE, 0); rc < 0 {
+		return nil, audio.Format{}, alsaError("open "+id, rc)
+	}
+	rc := C.snd_pcm_set_params(pcm, C.SND_PCM_FORMAT_S16_LE, C.SND_PCM_ACCESS_RW_INTERLEAVED,
+		C.uint(want.Channels), C.uint(want.SampleRate), 1, C.uint(latency/time.Microsecond))
+	if rc < 0 {
+		C.snd_pcm_close(pcm)
+		return nil, audio.Format{}, alsaError("configure "+id, rc)
+	}
+	return &alsaInput{pcm: pcm, channels: want.Channels}, want, nil
+}
+
+type alsaInput struct {
+	pcm      *C.snd_pcm_t
+	channels int
+}
+
+func (in *alsaInput) read(buf []int16) error {
+	for n := len(buf) / in.channels; n > 0; {
+		got := C.snd_pcm_readi(in.pcm, unsafe.Pointer(&buf[len(buf)-n*in.channels]), C.snd_pcm_uframes_t(n))
+		if got >= 0 {
+			n -= int(got)
+			continue
+		}
+		switch syscall.Errno(-got) {
+		case syscall.ENODEV, syscall.EBADFD, syscall.EIO:
+			return errLost
+		default:
+			// Overruns (EPIPE) and suspends (ESTRPIPE) lose some audio,
+			// but the stream goes on.
+			if rc := C.snd_pcm_recover(in.pcm, C.int(got), 1); rc < 0 {
+				return alsaError("read", rc)
+			}
+		}
+	}
+	return nil
+}
+
+func (in *alsaInput) close() error {
+	if in.pcm == nil {
+		return nil
+	}
+	rc := C.snd_pcm_close(in.pcm)
+	in.pcm = nil
+	if rc < 0 {
+		return alsaError("close", rc)
+	}
+	return nil
+}
@@ -0,0 +1,463 @@
+// Package capture reads audio from a microphone.
+//
+// Two native backends are available, each bound through cgo and enabled by
+// a build tag: PortAudio (`-tags portaudio`, macOS, Windows and Linux) and
+// ALSA (`-tags alsa`, Linux only). When both are built in PortAudio is
+// preferred and ALSA is the fallback. Without either tag Open returns
+// ErrNoBackend.
+//
+// A Mic delivers frames in the format it was asked for, converting from
+// the device's own format if the hardware cannot provide it. It survives
+// the device being unplugged: capture resumes once the device (or, for the
+// default device, any new default) comes back, and frame offsets skip the
+// time the device was gone so they stay on the wall clock.
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/metrics"
+	"github.com/jmarc101/voxa/internal/queue"
+)
+
+var (
+	// ErrNoBackend is returned when voxa was built without a capture
+	// backend.
+	ErrNoBackend = errors.New("capture: built without a capture backend (rebuild with -tags portaudio or -tags alsa)")
+	// ErrDeviceLost is returned by ReadFrame when the device disappeared
+	// and the Mic was configured not to wait for it.
+	ErrDeviceLost = errors.New("capture: device lost")
+)
+
+// errLost is what backends return from read when the device went away.
+var errLost = errors.New("device lost")
+
+// Device is an audio device.
+type Device struct {
+	// ID selects the device in Config. It is stable across unplugging as
+	// long as the device keeps its name.
+	ID string
+	// Name is a human-readable description.
+	Name string
+	// Backend that found the device.
+	Backend string
+	// InputChannels and OutputChannels are the most channels the device
+	// captures and plays; zero if it lacks that direction. Both are zero
+	// when the device could not be probed, for instance while it is busy.
+	InputChannels, OutputChannels int
+	// SampleRates lists the standard rates the device supports, ascending.
+	// It is empty if the device could not be probed.
+	SampleRates []int
+	// DefaultInput and DefaultOutput mark the system defaults.
+	DefaultInput, DefaultOutput bool
+}
+
+// standardRates are the sample rates devices are probed for.
+var standardRates = []int{8000, 11025, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+
+// FindDevice looks a device up by ID or by name: an exact ID, then an exact
+// name ignoring case, then a name containing query if only one does. With
+// output set only playback devices are considered, otherwise only capture
+// devices. An empty query selects the default device.
+func FindDevice(query string, output bool) (Device, error) {
+	devs, err := ListDevices()
+	if err != nil {
+		return Device{}, err
+	}
+	if d, ok := find(devs, query, output); ok {
+		return d, nil
+	}
+	return Device{}, fmt.Errorf("capture: no %s device %q", direction(output), query)
+}
+
+func direction(output bool) string {
+	if output {
+		return "output"
+	}
+	return "input"
+}
+
+func find(devs []Device, query string, output bool) (Device, bool) {
+	var usable []Device
+	for _, d := range devs {
+		// Devices that could not be probed may still work.
+		unknown := d.InputChannels == 0 && d.OutputChannels == 0
+		if unknown || (output && d.OutputChannels > 0) || (!output && d.InputChannels > 0) {
+			usable = append(usable, d)
+		}
+	}
+	if query == "" {
+		for _, d := range usable {
+			if (output && d.DefaultOutput) || (!output && d.DefaultInput) {
+				return d, true
+			}
+		}
+		return Device{}, false
+	}
+	for _, d := range usable {
+		if d.ID == query {
+			return d, true
+		}
+	}
+	for _, d := range usable {
+		if strings.EqualFold(d.Name, query) {
+			return d, true
+		}
+	}
+	var match []Device
+	q := strings.ToLower(query)
+	for _, d := range usable {
+		if strings.Contains(strings.ToLower(d.Name), q) {
+			match = append(match, d)
+		}
+	}
+	if len(match) == 1 {
+		return match[0], true
+	}
+	return Device{}, false
+}
+
+// backend is a native audio API.
+type backend interface {
+	name() string
+	devices() ([]Device, error)
+	// open starts capturing from device id ("" for the default) in want,
+	// or in the closest format the device supports, which it returns.
+	// Names are resolved to IDs before open is called.
+	open(id string, want audio.Format, frame time.Duration) (input, audio.Format, error)
+}
+
+// input is an open capture stream.
+type input interface {
+	// read blocks until buf is full. It returns errLost if the device is
+	// gone.
+	read(buf []int16) error
+	close() error
+}
+
+// backends lists the compiled-in backends by preference. Each backend file
+// adds itself from init.
+var backends []backend
+
+// preference orders backends regardless of file initialisation order.
+var preference = []string{"portaudio", "alsa"}
+
+func register(b backend) {
+	backends = append(backends, b)
+	sort.SliceStable(backends, func(i, j int) bool {
+		return slices.Index(preference, backends[i].name()) < slices.Index(preference, backends[j].name())
+	})
+}
+
+func lookup(name string) (backend, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackend
+	}
+	if name == "" {
+		return backends[0], nil
+	}
+	for _, b := range backends {
+		if b.name() == name {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("capture: backend %q not built in", name)
+}
+
+// Backends returns the names of the compiled-in backends by preference.
+func Backends() []string {
+	names := make([]string, len(backends))
+	for i, b := range backends {
+		names[i] = b.name()
+	}
+	return names
+}
+
+// ListDevices lists the audio devices of every compiled-in backend.
+func ListDevices() ([]Device, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackend
+	}
+	var all []Device
+	for _, b := range backends {
+		devs, err := b.devices()
+		if err != nil {
+			return nil, fmt.Errorf("capture: %s: %w", b.name(), err)
+		}
+		all = append(all, devs...)
+	}
+	return all, nil
+}
+
+// Config configures a Mic. Zero values select the defaults.
+type Config struct {
+	// Backend selects "portaudio" or "alsa". Defaults to the first one
+	// built in.
+	Backend string
+	// Device is the ID or name of the device to open, as accepted by
+	// FindDevice. Defaults to the system default input.
+	Device string
+	// Format of the frames returned. Defaults to 16kHz mono, the
+	// pipeline's native format.
+	Format audio.Format
+	// FrameDuration of each frame. Defaults to audio.FrameDuration.
+	FrameDuration time.Duration
+	// Buffer is how much audio is queued while the reader falls behind.
+	// Defaults to 1s.
+	Buffer time.Duration
+	// Overflow is what happens to audio captured while the buffer is full.
+	// Defaults to queue.DropOldest, which keeps latency bounded; with
+	// queue.Block capture pauses and the device itself overruns.
+	Overflow queue.Policy
+	// Metrics, if set, receives the buffer depth and the dropped frames,
+	// as the "capture" queue.
+	Metrics *metrics.Metrics
+	// FailOnUnplug makes ReadFrame return ErrDeviceLost when the device
+	// disappears instead of waiting for it to come back.
+	FailOnUnplug bool
+	// OnDeviceChange, if set, is called with false when the device is lost
+	// and with true when capture resumes.
+	OnDeviceChange func(available bool)
+}
+
+func (c *Config) setDefaults() error {
+	if c.Format == (audio.Format{}) {
+		c.Format = audio.Format{SampleRate: 16000, Channels: 1}
+	}
+	if c.FrameDuration == 0 {
+		c.FrameDuration = audio.FrameDuration
+	}
+	if c.Buffer == 0 {
+		c.Buffer = time.Second
+	}
+	if c.Overflow == 0 {
+		c.Overflow = queue.DropOldest
+	}
+	switch {
+	case c.Format.SampleRate <= 0 || c.Format.Channels <= 0:
+		return fmt.Errorf("capture: bad format %+v", c.Format)
+	case c.Format.Samples(c.FrameDuration) == 0:
+		return fmt.Errorf("capture: frame duration %v too short", c.FrameDuration)
+	case c.Buffer < c.FrameDuration:
+		return errors.New("capture: buffer shorter than a frame")
+	}
+	return nil
+}
+
+// reopenInterval is how often a lost device is looked for.
+const reopenInterval = 500 * time.Millisecond
+
+// Mic is an open microphone. It implements audio.Reader; capture runs in
+// the background from Open until Close.
+type Mic struct {
+	cfg     Config
+	backend backend
+	frames  *queue.Ring[audio.Frame]
+	stop    context.Context // done once Close is called
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu     sync.Mutex
+	err    error // why capture stopped, nil after Close
+	closed bool
+}
+
+// Open starts capturing.
+func Open(cfg Config) (*Mic, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	b, err := lookup(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+	frames, err := queue.NewRing(queue.Options[audio.Frame]{
+		Name:    "capture",
+		Size:    int(cfg.Buffer / cfg.FrameDuration),
+		Policy:  cfg.Overflow,
+		Release: audio.Frame.Release,
+		Metrics: cfg.Metrics,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	m := &Mic{cfg: cfg, backend: b, frames: frames, done: make(chan struct{})}
+	m.stop, m.cancel = context.WithCancel(context.Background())
+	src, err := m.open(0)
+	if err != nil {
+		m.cancel()
+		return nil, err
+	}
+	go m.capture(src)
+	return m, nil
+}
+
+// source is an open input with the conversion its format needs.
+type source struct {
+	in     input
+	format audio.Format
+	conv   *audio.Converter
+	base   time.Duration // stream time of the first sample
+	read   int           // samples per channel read so far
+}
+
+func (m *Mic) open(base time.Duration) (*source, error) {
+	id := m.cfg.Device
+	if id != "" {
+		// A device the backend does not list may still be openable by ID,
+		// for instance an ALSA PCM definition.
+		if devs, err := m.backend.devices(); err == nil {
+			if d, ok := find(devs, id, false); ok {
+				id = d.ID
+			}
+		}
+	}
+	in, f, err := m.backend.open(id, m.cfg.Format, m.cfg.FrameDuration)
+	if err != nil {
+		return nil, fmt.Errorf("capture: %s: %w", m.backend.name(), err)
+	}
+	src := &source{in: in, format: f, base: base}
+	if f != m.cfg.Format {
+		if src.conv, err = audio.NewConverter(f, m.cfg.Format, audio.QualityMedium); err != nil {
+			_ = in.close()
+			return nil, fmt.Errorf("capture: %w", err)
+		}
+	}
+	return src, nil
+}
+
+// capture reads from the device until Close, reopening it after unplugs.
+func (m *Mic) capture(src *source) {
+	defer close(m.done)
+	start := time.Now()
+	for {
+		if m.stop.Err() != nil {
+			_ = src.in.close()
+			m.fail(nil)
+			return
+		}
+		buf := audio.GetSamples(src.format.Samples(m.cfg.FrameDuration) * src.format.Channels)
+		err := src.in.read(buf)
+		if err == nil {
+			m.emit(src, buf)
+			continue
+		}
+		_ = src.in.close()
+		if !errors.Is(err, errLost) {
+			m.fail(fmt.Errorf("capture: %s: %w", m.backend.name(), err))
+			return
+		}
+		if m.cfg.FailOnUnplug {
+			m.fail(ErrDeviceLost)
+			return
+		}
+		m.notify(false)
+		if src = m.reopen(start); src == nil {
+			m.fail(nil)
+			return
+		}
+		m.notify(true)
+	}
+}
+
+// reopen waits for the device to come back. It returns nil once the Mic is
+// closed.
+func (m *Mic) reopen(start time.Time) *source {
+	t := time.NewTicker(reopenInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.stop.Done():
+			return nil
+		case <-t.C:
+		}
+		if src, err := m.open(time.Since(start)); err == nil {
+			return src
+		}
+	}
+}
+
+// emit converts one buffer of device audio and queues it.
+func (m *Mic) emit(src *source, buf []int16) {
+	fr := audio.Frame{Format: src.format, Data: buf, Offset: src.base + src.format.Duration(src.read)}
+	src.read += fr.Len()
+	if src.conv == nil {
+		m.push(fr)
+		return
+	}
+	// The converter only fails on a format mismatch, which open rules out.
+	out, _ := src.conv.Process(fr)
+	fr.Release()
+	for _, f := range out {
+		m.push(f.Clone())
+	}
+}
+
+// push queues fr, releasing it if the Mic is closed.
+func (m *Mic) push(fr audio.Frame) {
+	if m.frames.Push(m.stop, fr) != nil {
+		fr.Release()
+	}
+}
+
+func (m *Mic) notify(available bool) {
+	if m.cfg.OnDeviceChange != nil {
+		m.cfg.OnDeviceChange(available)
+	}
+}
+
+// fail ends capture; a nil err means the Mic was closed.
+func (m *Mic) fail(err error) {
+	m.mu.Lock()
+	if m.err == nil {
+		m.err = err
+	}
+	m.mu.Unlock()
+	m.frames.Close()
+}
+
+// Format returns the format of the frames returned.
+func (m *Mic) Format() audio.Format { return m.cfg.Format }
+
+// ReadFrame returns the next frame, blocking until it has been captured.
+// Frames queued before a Close or a failure are still returned first; then
+// it returns io.EOF after Close, or the error that stopped capture.
+func (m *Mic) ReadFrame() (audio.Frame, error) {
+	fr, ok := m.frames.Pop(context.Background())
+	if ok {
+		return fr, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err == nil {
+		return audio.Frame{}, io.EOF
+	}
+	return audio.Frame{}, m.err
+}
+
+// Dropped returns how many frames were discarded because ReadFrame was not
+// called fast enough.
+func (m *Mic) Dropped() int { return m.frames.Dropped() }
+
+// Close stops capturing and releases the device.
+func (m *Mic) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mu.Unlock()
+	m.cancel()
+	<-m.done
+	return nil
+}
@@ -0,0 +1,200 @@
+//go:build cgo && portaudio
+
+package capture
+
+/*
+#cgo pkg-config: portaudio-2.0
+#include <portaudio.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+func init() { register(&portAudio{}) }
+
+func paError(op string, code C.PaError) error {
+	return fmt.Errorf("%s: %s", op, C.GoString(C.Pa_GetErrorText(code)))
+}
+
+// portAudio is the PortAudio backend. PortAudio only enumerates devices
+// when it is initialised, so the library is re-initialised whenever no
+// stream is open, which lets devices plugged in later show up.
+type portAudio struct {
+	mu      sync.Mutex
+	inited  bool
+	streams int // open streams
+}
+
+func (*portAudio) name() string { return "portaudio" }
+
+// refresh (re)initialises the library. Callers hold mu.
+func (p *portAudio) refresh() error {
+	if p.inited {
+		if p.streams > 0 {
+			return nil
+		}
+		C.Pa_Terminate()
+		p.inited = false
+	}
+	if rc := C.Pa_Initialize(); rc != C.paNoError {
+		return paError("initialize", rc)
+	}
+	p.inited = true
+	return nil
+}
+
+func (p *portAudio) devices() ([]Device, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+	return p.list(), nil
+}
+
+// list enumerates the devices. Callers hold mu.
+func (p *portAudio) list() []Device {
+	defIn, defOut := C.Pa_GetDefaultInputDevice(), C.Pa_GetDefaultOutputDevice()
+	var devs []Device
+	for i := C.PaDeviceIndex(0); i < C.PaDeviceIndex(C.Pa_GetDeviceCount()); i++ {
+		info := C.Pa_GetDeviceInfo(i)
+		if info == nil {
+			continue
+		}
+		name := deviceName(info)
+		devs = append(devs, Device{
+			ID:             name,
+			Name:           name,
+			Backend:        p.name(),
+			InputChannels:  int(info.maxInputChannels),
+			OutputChannels: int(info.maxOutputChannels),
+			SampleRates:    rates(i, info),
+			DefaultInput:   i == defIn,
+			DefaultOutput:  i == defOut,
+		})
+	}
+	return devs
+}
+
+// rates probes the standard rates the device supports in either direction.
+func rates(dev C.PaDeviceIndex, info *C.PaDeviceInfo) []int {
+	var rs []int
+	for _, r := range standardRates {
+		in := C.PaStreamParameters{device: dev, channelCount: 1, sampleFormat: C.paInt16}
+		if info.maxInputChannels > 0 && C.Pa_IsFormatSupported(&in, nil, C.double(r)) == C.paFormatIsSupported {
+			rs = append(rs, r)
+			continue
+		}
+		if info.maxOutputChannels > 0 && C.Pa_IsFormatSupported(nil, &in, C.double(r)) == C.paFormatIsSupported {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// deviceName qualifies the device name with its host API, since the same
+// device usually shows up once per API.
+func deviceName(info *C.PaDeviceInfo) string {
+	name := C.GoString(info.name)
+	if host := C.Pa_GetHostApiInfo(info.hostApi); host != nil {
+		name = C.GoString(host.name) + ": " + name
+	}
+	return name
+}
+
+// index finds the input device with the given ID. Callers hold mu.
+func (p *portAudio) index(id string) (C.PaDeviceIndex, error) {
+	if id == "" {
+		if i := C.Pa_GetDefaultInputDevice(); i != C.paNoDevice {
+			return i, nil
+		}
+		return 0, errors.New("no default input device")
+	}
+	for i := C.PaDeviceIndex(0); i < C.PaDeviceIndex(C.Pa_GetDeviceCount()); i++ {
+		info := C.Pa_GetDeviceInfo(i)
+		if info != nil && info.maxInputChannels > 0 && deviceName(info) == id {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no input device %q", id)
+}
+
+func (p *portAudio) open(id string, want audio.Format, frame time.Duration) (input, audio.Format, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.refresh(); err != nil {
+		return nil, audio.Format{}, err
+	}
+	dev, err := p.index(id)
+	if err != nil {
+		return nil, audio.Format{}, err
+	}
+	info := C.Pa_GetDeviceInfo(dev)
+	f := audio.Format{SampleRate: want.SampleRate, Channels: min(want.Channels, int(info.maxInputChannels))}
+	params := C.PaStreamParameters{
+		device:           dev,
+		channelCount:     C.int(f.Channels),
+		sampleFormat:     C.paInt16,
+		suggestedLatency: info.defaultLowInputLatency,
+	}
+	// Not every device resamples; fall back to its own rate.
+	if C.Pa_IsFormatSupported(&params, nil, C.double(f.SampleRate)) != C.paFormatIsSupported {
+		f.SampleRate = int(info.defaultSampleRate)
+	}
+	var stream unsafe.Pointer
+	rc := C.Pa_OpenStream(&stream, &params, nil, C.double(f.SampleRate),
+		C.ulong(f.Samples(frame)), C.paNoFlag, nil, nil)
+	if rc != C.paNoError {
+		return nil, audio.Format{}, paError("open stream", rc)
+	}
+	if rc := C.Pa_StartStream(stream); rc != C.paNoError {
+		C.Pa_CloseStream(stream)
+		return nil, audio.Format{}, paError("start stream", rc)
+	}
+	p.streams++
+	return &paInput{p: p, stream: stream, channels: f.Channels}, f, nil
+}
+
+type paInput struct {
+	p        *portAudio
+	stream   unsafe.Pointer
+	channels int
+}
+
+func (in *paInput) read(buf []int16) error {
+	n := len(buf) / in.channels
+	rc := C.Pa_ReadStream(in.stream, unsafe.Pointer(&buf[0]), C.ulong(n))
+	switch rc {
+	case C.paNoError, C.paInputOverflowed:
+		// An overflow lost some audio, but the stream goes on.
+		return nil
+	case C.paDeviceUnavailable, C.paUnanticipatedHostError, C.paTimedOut,
+		C.paStreamIsStopped, C.paBadStreamPtr:
+		return errLost
+	}
+	return paError("read", rc)
+}
+
+func (in *paInput) close() error {
+	if in.stream == nil {
+		return nil
+	}
+	C.Pa_AbortStream(in.stream)
+	rc := C.Pa_CloseStream(in.stream)
+	in.stream = nil
+	in.p.mu.Lock()
+	in.p.streams--
+	in.p.mu.Unlock()
+	if rc != C.paNoError {
+		return paError("close stream", rc)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Decoder reads one audio file format. Formats are recognized by content,
+// not by file extension.
+type Decoder struct {
+	// Name of the format, e.g. "flac".
+	Name string
+	// Match reports whether header, the first bytes of a stream, starts a
+	// stream in this format. It holds up to 64 bytes, fewer for short
+	// streams.
+	Match func(header []byte) bool
+	// NewReader decodes r from its first byte. The Reader may implement
+	// io.Closer to release decoder resources.
+	NewReader func(r io.Reader) (Reader, error)
+}
+
+// headerSize is how much of a stream decoders get to match against.
+const headerSize = 64
+
+var (
+	decodersMu sync.RWMutex
+	decoders   []Decoder
+)
+
+// RegisterDecoder makes a file format available to Open and NewReader.
+// It is meant to be called from the decoder package's init function.
+func RegisterDecoder(d Decoder) {
+	if d.Match == nil || d.NewReader == nil {
+		panic("audio: RegisterDecoder with nil func")
+	}
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders = append(decoders, d)
+}
+
+func init() {
+	RegisterDecoder(Decoder{
+		Name: "wav",
+		Match: func(h []byte) bool {
+			return len(h) >= 12 && string(h[0:4]) == "RIFF" && string(h[8:12]) == "WAVE"
+		},
+		NewReader: func(r io.Reader) (Reader, error) { return NewWAVReader(r) },
+	})
+}
+
+// NewReader detects the format of r and returns a reader decoding it.
+func NewReader(r io.Reader) (Reader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok || br.Size() < headerSize {
+		br = bufio.NewReader(r)
+	}
+	header, err := br.Peek(headerSize)
+	if err != nil && len(header) == 0 {
+		return nil, fmt.Errorf("audio: read header: %w", err)
+	}
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	var names []string
+	for _, d := range decoders {
+		if d.Match(header) {
+			return d.NewReader(br)
+		}
+		names = append(names, d.Name)
+	}
+	return nil, fmt.Errorf("%w: unrecognized audio format (decoders: %v)", ErrUnsupported, names)
+}
+
+// File is an audio file opened for frame-by-frame reading.
+type File struct {
+	Reader
+	f *os.File
+}
+
+// Open opens the audio file at path, in any format with a registered
+// decoder. WAV is built in; importing the voxa package also registers FLAC
+// and MP3.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("audio: open %s: %w", path, err)
+	}
+	return &File{Reader: r, f: f}, nil
+}
+
+// Close closes the decoder and the underlying file.
+func (f *File) Close() error {
+	if c, ok := f.Reader.(io.Closer); ok {
+		_ = c.Close()
+	}
+	return f.f.Close()
+}
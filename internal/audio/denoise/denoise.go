@@ -0,0 +1,200 @@
+// Package denoise is a real-time noise suppression stage based on spectral
+// subtraction.
+//
+// Audio is analysed in overlapping 20ms windows (10ms hop). The noise
+// spectrum is tracked continuously, following the spectral minima so speech
+// does not leak into the estimate, and every bin is attenuated by a Wiener
+// style gain computed against it. Gains are smoothed over time, which keeps
+// the "musical noise" of plain subtraction down. The stage is suited to
+// stationary noise: fans, hum, road and room tone.
+package denoise
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/dsp"
+)
+
+// Analysis parameters.
+const (
+	hopDuration = 10 * time.Millisecond
+	// Latency is the delay the stage adds: one analysis window.
+	Latency = 2 * hopDuration
+)
+
+// Config tunes the suppressor.
+type Config struct {
+	// Strength in (0, 1] sets how hard noise is removed: higher values
+	// over-subtract more and allow deeper attenuation (down to -30 dB), at
+	// the cost of more speech distortion. Defaults to 0.5.
+	Strength float64
+}
+
+func (c *Config) setDefaults() error {
+	if c.Strength == 0 {
+		c.Strength = 0.5
+	}
+	if c.Strength < 0 || c.Strength > 1 {
+		return fmt.Errorf("denoise: strength %v out of (0, 1]", c.Strength)
+	}
+	return nil
+}
+
+// Suppressor is the noise suppression stage for one mono stream.
+type Suppressor struct {
+	oversub float64 // noise over-subtraction factor
+	floor   float64 // minimum gain
+
+	hop, n int
+	win    []float64 // sqrt-Hann, for analysis and synthesis
+	buf    []complex128
+
+	in  []float64 // input not yet consumed by a full window
+	ola []float64 // overlap-add accumulator, one window long
+	out []float64 // denoised samples waiting to be returned
+
+	psd    []float64 // smoothed power per bin
+	noise  []float64 // minimum-tracked power per bin
+	gain   []float64 // smoothed gain per bin
+	frames int       // analysis frames seen
+
+	pass [1]audio.Frame // returned by Process, reused
+}
+
+// New creates a suppressor for mono audio at sampleRate.
+func New(cfg Config, sampleRate int) (*Suppressor, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("denoise: bad sample rate %d", sampleRate)
+	}
+	hop := int(int64(sampleRate) * int64(hopDuration) / int64(time.Second))
+	n := 2 * hop
+	s := &Suppressor{
+		oversub: 1 + 3*cfg.Strength,
+		floor:   math.Pow(10, (-6-24*cfg.Strength)/20),
+		hop:     hop,
+		n:       n,
+		win:     make([]float64, n),
+		buf:     make([]complex128, dsp.NextPow2(n)),
+		in:      make([]float64, n-hop, 4*n),
+		ola:     make([]float64, n),
+		out:     make([]float64, hop, 4*n),
+	}
+	// A periodic sqrt-Hann window applied twice sums to one at 50% overlap.
+	for i := range s.win {
+		s.win[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n)))
+	}
+	bins := len(s.buf)/2 + 1
+	s.psd = make([]float64, bins)
+	s.noise = make([]float64, bins)
+	s.gain = make([]float64, bins)
+	for k := range s.gain {
+		s.gain[k] = 1
+	}
+	return s, nil
+}
+
+// Process denoises fr in place and returns it. The returned samples lag the
+// input by Latency.
+func (s *Suppressor) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if fr.Format.Channels != 1 {
+		return nil, fmt.Errorf("denoise: need mono audio, got %d channels", fr.Format.Channels)
+	}
+	for _, v := range fr.Data {
+		s.in = append(s.in, float64(v)/32768)
+	}
+	for len(s.in) >= s.n {
+		s.analyse(s.in[:s.n])
+		s.in = s.in[:copy(s.in, s.in[s.hop:])]
+	}
+	// The output queue starts one hop ahead, so it always covers the frame.
+	for i := range fr.Data {
+		fr.Data[i] = toPCM(s.out[i])
+	}
+	s.out = s.out[:copy(s.out, s.out[len(fr.Data):])]
+	s.pass[0] = fr
+	return s.pass[:], nil
+}
+
+// analyse denoises one window and overlap-adds it into the output.
+func (s *Suppressor) analyse(x []float64) {
+	for i := range s.buf {
+		s.buf[i] = 0
+	}
+	for i, v := range x {
+		s.buf[i] = complex(v*s.win[i], 0)
+	}
+	dsp.FFT(s.buf)
+
+	s.suppress()
+
+	dsp.IFFT(s.buf)
+	for i := 0; i < s.n; i++ {
+		s.ola[i] += real(s.buf[i]) * s.win[i]
+	}
+	s.out = append(s.out, s.ola[:s.hop]...)
+	copy(s.ola, s.ola[s.hop:])
+	for i := s.n - s.hop; i < s.n; i++ {
+		s.ola[i] = 0
+	}
+}
+
+// Noise tracking follows the minimum of the smoothed power spectrum: the
+// estimate falls quickly to a lower power and creeps up slowly otherwise,
+// approximating a running minimum over about a second. The minimum of a
+// fluctuating spectrum underestimates its mean, hence the bias correction.
+const (
+	psdSmooth  = 0.7
+	noiseFall  = 0.7
+	noiseRise  = 1.01
+	noiseBias  = 2.0
+	initFrames = 25 // frames averaged for the initial estimate
+	gainSmooth = 0.5
+)
+
+func (s *Suppressor) suppress() {
+	n := len(s.buf)
+	s.frames++
+	for k := range s.noise {
+		re, im := real(s.buf[k]), imag(s.buf[k])
+		p := re*re + im*im
+		if s.frames == 1 {
+			s.psd[k] = p
+		}
+		s.psd[k] = psdSmooth*s.psd[k] + (1-psdSmooth)*p
+		switch {
+		case s.frames <= initFrames:
+			s.noise[k] += (s.psd[k]/noiseBias - s.noise[k]) / float64(s.frames)
+		case s.psd[k] < s.noise[k]:
+			s.noise[k] = noiseFall*s.noise[k] + (1-noiseFall)*s.psd[k]
+		default:
+			s.noise[k] = math.Min(s.noise[k]*noiseRise, s.psd[k])
+		}
+
+		g := s.floor
+		if p > 0 {
+			g = math.Max(s.floor, 1-s.oversub*noiseBias*s.noise[k]/s.psd[k])
+		}
+		s.gain[k] = gainSmooth*s.gain[k] + (1-gainSmooth)*g
+		s.buf[k] *= complex(s.gain[k], 0)
+		if k > 0 && k < n/2 {
+			s.buf[n-k] = complex(real(s.buf[k]), -imag(s.buf[k]))
+		}
+	}
+}
+
+func toPCM(v float64) int16 {
+	v *= 32768
+	switch {
+	case v > math.MaxInt16:
+		return math.MaxInt16
+	case v < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
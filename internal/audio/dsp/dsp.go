@@ -0,0 +1,169 @@
+// Package dsp has the small signal-processing kernels used by the audio
+// stages: windowing, an in-place FFT and level measurements.
+package dsp
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// Hann returns a symmetric Hann window of length n.
+func Hann(n int) []float64 {
+	w := make([]float64, n)
+	if n == 1 {
+		w[0] = 1
+		return w
+	}
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+	return w
+}
+
+// NextPow2 returns the smallest power of two >= n.
+func NextPow2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// FFT computes the discrete Fourier transform of x in place. len(x) must be
+// a power of two.
+func FFT(x []complex128) {
+	fft(x, false)
+}
+
+// IFFT computes the inverse transform of x in place, including the 1/n
+// scaling. len(x) must be a power of two.
+func IFFT(x []complex128) {
+	fft(x, true)
+	scale := complex(1/float64(len(x)), 0)
+	for i := range x {
+		x[i] *= scale
+	}
+}
+
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	if n&(n-1) != 0 {
+		panic("dsp: FFT length must be a power of two")
+	}
+	// Bit-reversal permutation.
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Rect(1, sign*2*math.Pi/float64(size)) // principal root
+		for start := 0; start < n; start += size {
+			twiddle := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*twiddle
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				twiddle *= w
+			}
+		}
+	}
+}
+
+// PowerSpectrum returns |X[k]|² for k in [0, n/2] of the Hann-windowed
+// signal, zero-padded to the next power of two.
+func PowerSpectrum(x []float64) []float64 {
+	var s Spectrum
+	return s.Power(x)
+}
+
+// Spectrum computes power spectra like PowerSpectrum, reusing its buffers
+// and window across calls of the same length.
+type Spectrum struct {
+	buf []complex128
+	win []float64
+	ps  []float64
+}
+
+// Power returns the power spectrum of x. The result is only valid until
+// the next call.
+func (s *Spectrum) Power(x []float64) []float64 {
+	n := NextPow2(len(x))
+	if len(s.buf) != n {
+		s.buf = make([]complex128, n)
+		s.ps = make([]float64, n/2+1)
+	}
+	if len(s.win) != len(x) {
+		s.win = Hann(len(x))
+	}
+	for i, v := range x {
+		s.buf[i] = complex(v*s.win[i], 0)
+	}
+	clear(s.buf[len(x):])
+	FFT(s.buf)
+	for k := range s.ps {
+		re, im := real(s.buf[k]), imag(s.buf[k])
+		s.ps[k] = re*re + im*im
+	}
+	return s.ps
+}
+
+// Float converts PCM16 samples to float64 in [-1, 1).
+func Float(dst []float64, src []int16) []float64 {
+	if cap(dst) < len(src) {
+		dst = make([]float64, len(src))
+	}
+	dst = dst[:len(src)]
+	for i, s := range src {
+		dst[i] = float64(s) / 32768
+	}
+	return dst
+}
+
+// PCM16 converts float samples back to PCM16, clipping to full scale.
+func PCM16(dst []int16, src []float64) []int16 {
+	if cap(dst) < len(src) {
+		dst = make([]int16, len(src))
+	}
+	dst = dst[:len(src)]
+	for i, v := range src {
+		v *= 32768
+		switch {
+		case v > math.MaxInt16:
+			v = math.MaxInt16
+		case v < math.MinInt16:
+			v = math.MinInt16
+		}
+		dst[i] = int16(math.Round(v))
+	}
+	return dst
+}
+
+// RMS returns the root-mean-square level of x.
+func RMS(x []float64) float64 {
+	if len(x) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
+
+// DBFS converts a linear level in [0, 1] to decibels relative to full scale.
+// Silence maps to -120 dBFS rather than -Inf.
+func DBFS(level float64) float64 {
+	if level < 1e-6 {
+		return -120
+	}
+	return 20 * math.Log10(level)
+}
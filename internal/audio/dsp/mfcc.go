@@ -0,0 +1,137 @@
+package dsp
+
+import (
+	"math"
+	"time"
+)
+
+// MFCCConfig configures MFCC extraction. Zero values select the usual
+// speech defaults.
+type MFCCConfig struct {
+	SampleRate int
+	// Window and Hop default to 25ms and 10ms.
+	Window, Hop time.Duration
+	// Filters is the number of mel bands; defaults to 26.
+	Filters int
+	// Coefficients kept after the DCT, excluding c0; defaults to 12.
+	Coefficients int
+}
+
+// MFCC extracts mel-frequency cepstral coefficients from a sample stream.
+// Samples can be pushed in arbitrary chunks; a feature vector is produced
+// for every Hop once a full Window is available.
+type MFCC struct {
+	cfg     MFCCConfig
+	win     []float64
+	hop     int
+	fft     []complex128
+	filters [][]float64 // mel filterbank, one row per band over FFT bins
+	pending []float64
+	prev    float64 // last sample, for pre-emphasis
+	logE    []float64
+}
+
+// NewMFCC creates an extractor.
+func NewMFCC(cfg MFCCConfig) *MFCC {
+	if cfg.Window == 0 {
+		cfg.Window = 25 * time.Millisecond
+	}
+	if cfg.Hop == 0 {
+		cfg.Hop = 10 * time.Millisecond
+	}
+	if cfg.Filters == 0 {
+		cfg.Filters = 26
+	}
+	if cfg.Coefficients == 0 {
+		cfg.Coefficients = 12
+	}
+	n := int(int64(cfg.SampleRate) * int64(cfg.Window) / int64(time.Second))
+	m := &MFCC{
+		cfg:  cfg,
+		win:  Hann(n),
+		hop:  int(int64(cfg.SampleRate) * int64(cfg.Hop) / int64(time.Second)),
+		fft:  make([]complex128, NextPow2(n)),
+		logE: make([]float64, cfg.Filters),
+	}
+	m.filters = melFilterbank(cfg.Filters, len(m.fft), cfg.SampleRate)
+	return m
+}
+
+// Dim returns the length of each feature vector.
+func (m *MFCC) Dim() int { return m.cfg.Coefficients }
+
+// Push appends samples and returns the feature vectors that became
+// available.
+func (m *MFCC) Push(samples []float64) [][]float64 {
+	for _, s := range samples {
+		m.pending = append(m.pending, s-0.97*m.prev)
+		m.prev = s
+	}
+	var out [][]float64
+	for len(m.pending) >= len(m.win) {
+		out = append(out, m.frame(m.pending[:len(m.win)]))
+		m.pending = m.pending[m.hop:]
+	}
+	m.pending = append(m.pending[:0:0], m.pending...)
+	return out
+}
+
+func (m *MFCC) frame(x []float64) []float64 {
+	for i := range m.fft {
+		m.fft[i] = 0
+	}
+	for i, v := range x {
+		m.fft[i] = complex(v*m.win[i], 0)
+	}
+	FFT(m.fft)
+	for b, f := range m.filters {
+		var e float64
+		for k, w := range f {
+			if w == 0 {
+				continue
+			}
+			re, im := real(m.fft[k]), imag(m.fft[k])
+			e += w * (re*re + im*im)
+		}
+		m.logE[b] = math.Log(e + 1e-10)
+	}
+	// DCT-II, skipping c0 (overall loudness).
+	c := make([]float64, m.cfg.Coefficients)
+	nf := float64(len(m.logE))
+	for i := range c {
+		var sum float64
+		for b, e := range m.logE {
+			sum += e * math.Cos(math.Pi*float64(i+1)*(float64(b)+0.5)/nf)
+		}
+		c[i] = sum
+	}
+	return c
+}
+
+func hzToMel(f float64) float64 { return 2595 * math.Log10(1+f/700) }
+func melToHz(m float64) float64 { return 700 * (math.Pow(10, m/2595) - 1) }
+
+// melFilterbank builds triangular filters over the bins of an n-point FFT.
+func melFilterbank(filters, n, rate int) [][]float64 {
+	hi := math.Min(8000, float64(rate)/2)
+	lo := 20.0
+	mlo, mhi := hzToMel(lo), hzToMel(hi)
+	bins := make([]int, filters+2)
+	for i := range bins {
+		hz := melToHz(mlo + (mhi-mlo)*float64(i)/float64(filters+1))
+		bins[i] = int(math.Floor(float64(n+1) * hz / float64(rate)))
+	}
+	fb := make([][]float64, filters)
+	for f := range fb {
+		row := make([]float64, n/2+1)
+		l, c, r := bins[f], bins[f+1], bins[f+2]
+		for k := l; k < c && k < len(row); k++ {
+			row[k] = float64(k-l) / float64(max(c-l, 1))
+		}
+		for k := c; k < r && k < len(row); k++ {
+			row[k] = float64(r-k) / float64(max(r-c, 1))
+		}
+		fb[f] = row
+	}
+	return fb
+}
@@ -0,0 +1,60 @@
+package dsp
+
+import "math"
+
+// Pitch search range, covering adult and child voices.
+const (
+	MinPitch = 60.0
+	MaxPitch = 400.0
+)
+
+// Pitch estimates the fundamental frequency of x, sampled at rate Hz, from
+// the peak of its normalized autocorrelation. x should hold at least two
+// periods of MinPitch (about 35ms). It reports false for unvoiced or too
+// quiet input.
+func Pitch(x []float64, rate int) (float64, bool) {
+	// Voice pitch needs no more than 8kHz sampling; skip samples above
+	// that to keep the search cheap.
+	step := max(1, rate/8000)
+	n := len(x) / step
+	minLag := int(float64(rate) / MaxPitch / float64(step))
+	maxLag := int(float64(rate) / MinPitch / float64(step))
+	if n <= maxLag || minLag < 1 {
+		return 0, false
+	}
+	var e0 float64
+	for i := 0; i < n; i++ {
+		v := x[i*step]
+		e0 += v * v
+	}
+	if e0/float64(n) < 1e-5 { // below about -50 dBFS
+		return 0, false
+	}
+	r := make([]float64, maxLag+1)
+	best := 0.0
+	for lag := minLag; lag <= maxLag; lag++ {
+		var s, e1 float64
+		for i := 0; i+lag < n; i++ {
+			s += x[i*step] * x[(i+lag)*step]
+			e1 += x[(i+lag)*step] * x[(i+lag)*step]
+		}
+		if e1 == 0 {
+			continue
+		}
+		// Normalize by the overlapping energy so long lags are not
+		// penalized for their shorter overlap.
+		r[lag] = s / math.Sqrt(e0*e1)
+		best = max(best, r[lag])
+	}
+	if best < 0.5 {
+		return 0, false
+	}
+	// Multiples of the period correlate almost as well as the period
+	// itself; take the shortest lag near the peak to avoid octave errors.
+	for lag := minLag; lag <= maxLag; lag++ {
+		if r[lag] >= 0.9*best && (lag == maxLag || r[lag] >= r[lag+1]) {
+			return float64(rate) / float64(lag*step), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,235 @@
+// Package dtmf detects the keys pressed on a telephone keypad from the
+// dual tones they send in-band (ITU-T Q.23), for IVR menus.
+//
+// Audio is analysed in blocks of 25.6ms, the classic 205 samples at 8kHz,
+// overlapping by half, with a Goertzel filter on each of the eight DTMF
+// frequencies. A block holds a key when one frequency of each group
+// dominates it, within the twist allowed between them, and a key is
+// pressed once enough blocks in a row hold it for a tone of
+// Config.MinDuration. It is released by a pause, so holding a key reports
+// it once. Speech and music rarely put most of their energy in two exact
+// frequencies, which keeps false detections down.
+package dtmf
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// Digit is a key pressed.
+type Digit struct {
+	// Key is "0" to "9", "*", "#", or "A" to "D".
+	Key string
+	// Offset is the stream time at which the tone started.
+	Offset time.Duration
+}
+
+// Defaults.
+const (
+	DefaultMinDuration = 40 * time.Millisecond
+	DefaultMinLevel    = -36.0 // dBFS
+)
+
+// Config tunes the detector.
+type Config struct {
+	// MinDuration is how long a tone must last to count, at least one
+	// block. Defaults to DefaultMinDuration, the shortest Q.24 allows.
+	MinDuration time.Duration
+	// MinLevel is the RMS level, in dBFS, under which audio holds no tone.
+	// Defaults to DefaultMinLevel.
+	MinLevel float64
+	// OnDigit, if set, is called synchronously for every key pressed.
+	OnDigit func(Digit)
+}
+
+func (c *Config) setDefaults() error {
+	if c.MinDuration == 0 {
+		c.MinDuration = DefaultMinDuration
+	}
+	if c.MinLevel == 0 {
+		c.MinLevel = DefaultMinLevel
+	}
+	switch {
+	case c.MinDuration < 0 || c.MinDuration > time.Second:
+		return fmt.Errorf("dtmf: min duration %v out of (0, 1s]", c.MinDuration)
+	case c.MinLevel >= 0 || c.MinLevel < -60:
+		return fmt.Errorf("dtmf: min level %v dBFS out of [-60, 0)", c.MinLevel)
+	}
+	return nil
+}
+
+// The keypad: rows by low frequency, columns by high frequency.
+var (
+	rows = [4]float64{697, 770, 852, 941}
+	cols = [4]float64{1209, 1336, 1477, 1633}
+	keys = [4][4]string{
+		{"1", "2", "3", "A"},
+		{"4", "5", "6", "B"},
+		{"7", "8", "9", "C"},
+		{"*", "0", "#", "D"},
+	}
+)
+
+// Validation parameters.
+const (
+	blockLength = 205 * time.Second / 8000
+	hop         = blockLength / 2
+	// The two tones must hold this share of the energy of the block.
+	purity = 0.6
+	// Q.24 allows the high tone up to 8dB over the low one, and 4dB under.
+	maxTwist     = 8.0 // dB
+	maxReverse   = 4.0 // dB
+	groupRejectB = 6.0 // dB, between the strongest tone of a group and the next
+	// Blocks without the key for a release, 25.6ms; Q.24's shortest pause
+	// is 40ms.
+	releaseBlocks = 2
+)
+
+// Detector is the DTMF detection stage of one mono stream. It passes the
+// audio through unchanged.
+type Detector struct {
+	cfg     Config
+	rate    int
+	n       int        // block, in samples
+	coeff   [8]float64 // Goertzel, rows then columns
+	blocks  [2]block   // half a block apart
+	minMS   float64    // mean square of MinLevel
+	need    int        // blocks for MinDuration
+	twist   [2]float64 // allowed column/row power ratios
+	reject  float64
+	cand    string // key of the last blocks
+	count   int    // blocks cand has held
+	since   time.Duration
+	pressed string // key reported and not released
+	misses  int
+	pass    [1]audio.Frame
+}
+
+// block is a block being analysed.
+type block struct {
+	s1, s2 [8]float64 // Goertzel state
+	energy float64
+	filled int
+	start  time.Duration
+}
+
+// New creates a detector for mono audio at sampleRate, which must be able
+// to carry the tones: 4kHz or more.
+func New(cfg Config, sampleRate int) (*Detector, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	if sampleRate < 4000 {
+		return nil, fmt.Errorf("dtmf: sample rate %d too low for keypad tones", sampleRate)
+	}
+	f := audio.Format{SampleRate: sampleRate, Channels: 1}
+	d := &Detector{
+		cfg:    cfg,
+		rate:   sampleRate,
+		n:      f.Samples(blockLength),
+		minMS:  math.Pow(10, cfg.MinLevel/10),
+		twist:  [2]float64{math.Pow(10, -maxReverse/10), math.Pow(10, maxTwist/10)},
+		reject: math.Pow(10, groupRejectB/10),
+	}
+	// A block the tone covers for at least purity of it holds its key: the
+	// blocks starting within cfg.MinDuration-(2·purity-1)·blockLength of
+	// one another, of which a tone of MinDuration fills that many in a row.
+	span := cfg.MinDuration.Seconds() - (2*purity-1)*blockLength.Seconds()
+	d.need = max(1, int(span/hop.Seconds()))
+	d.blocks[1].filled = d.n / 2
+	for i, freq := range append(rows[:], cols[:]...) {
+		d.coeff[i] = 2 * math.Cos(2*math.Pi*freq/float64(sampleRate))
+	}
+	return d, nil
+}
+
+// Process analyses fr and returns it.
+func (d *Detector) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if fr.Format.Channels != 1 || fr.Format.SampleRate != d.rate {
+		return nil, fmt.Errorf("dtmf: need mono audio at %d Hz, got %+v", d.rate, fr.Format)
+	}
+	for i, v := range fr.Data {
+		x := float64(v) / 32768
+		for j := range d.blocks {
+			b := &d.blocks[j]
+			if b.filled == 0 {
+				b.start = fr.Offset + fr.Format.Duration(i)
+			}
+			b.energy += x * x
+			for k, c := range d.coeff {
+				s := x + c*b.s1[k] - b.s2[k]
+				b.s2[k], b.s1[k] = b.s1[k], s
+			}
+			if b.filled++; b.filled == d.n {
+				d.update(d.key(b), b.start)
+				*b = block{}
+			}
+		}
+	}
+	d.pass[0] = fr
+	return d.pass[:], nil
+}
+
+// key returns the key b holds, if any.
+func (d *Detector) key(b *block) string {
+	if b.energy/float64(d.n) < d.minMS {
+		return ""
+	}
+	var power [8]float64
+	norm := 2 / (float64(d.n) * b.energy) // a pure tone at a bin scores 1
+	for k, c := range d.coeff {
+		power[k] = (b.s1[k]*b.s1[k] + b.s2[k]*b.s2[k] - c*b.s1[k]*b.s2[k]) * norm
+	}
+	r, rok := d.strongest(power[:4])
+	c, cok := d.strongest(power[4:])
+	if !rok || !cok {
+		return ""
+	}
+	low, high := power[r], power[4+c]
+	if low+high < purity || high < d.twist[0]*low || high > d.twist[1]*low {
+		return ""
+	}
+	return keys[r][c]
+}
+
+// strongest returns the strongest tone of a group, reporting whether it
+// clearly dominates the others.
+func (d *Detector) strongest(group []float64) (int, bool) {
+	best := 0
+	for i, p := range group {
+		if p > group[best] {
+			best = i
+		}
+	}
+	for i, p := range group {
+		if i != best && p*d.reject > group[best] {
+			return best, false
+		}
+	}
+	return best, true
+}
+
+// update updates the key state with the key of the block just analysed,
+// which started at start.
+func (d *Detector) update(key string, start time.Duration) {
+	if key == "" {
+		if d.misses++; d.misses >= releaseBlocks {
+			d.pressed, d.cand, d.count = "", "", 0
+		}
+		return
+	}
+	d.misses = 0
+	if key != d.cand {
+		d.cand, d.count, d.since = key, 0, start
+	}
+	d.count++
+	if d.count >= d.need && key != d.pressed {
+		d.pressed = key
+		if d.cfg.OnDigit != nil {
+			d.cfg.OnDigit(Digit{Key: key, Offset: d.since})
+		}
+	}
+}
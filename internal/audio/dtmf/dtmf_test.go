@@ -0,0 +1,208 @@
+package dtmf
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+const ms = time.Millisecond
+
+// signal builds mono test audio one piece after the other.
+type signal struct {
+	rate int
+	data []int16
+}
+
+// now returns the stream time of the end of the audio so far.
+func (s *signal) now() time.Duration {
+	return audio.Format{SampleRate: s.rate, Channels: 1}.Duration(len(s.data))
+}
+
+// tones appends d of the sum of sines at freqs, with peak amplitudes amps
+// as fractions of full scale.
+func (s *signal) tones(d time.Duration, freqs, amps []float64) {
+	n := audio.Format{SampleRate: s.rate, Channels: 1}.Samples(d)
+	for i := range n {
+		var v float64
+		for k, f := range freqs {
+			v += amps[k] * math.Sin(2*math.Pi*f*float64(i)/float64(s.rate))
+		}
+		s.data = append(s.data, int16(v*32767))
+	}
+}
+
+// key appends d of the tone of key, the high tone twist dB over the low.
+func (s *signal) key(key string, d time.Duration, twist float64) {
+	for r := range keys {
+		for c := range keys[r] {
+			if keys[r][c] == key {
+				s.tones(d, []float64{rows[r], cols[c]}, []float64{0.2, 0.2 * math.Pow(10, twist/20)})
+				return
+			}
+		}
+	}
+	panic("no key " + key)
+}
+
+func (s *signal) silence(d time.Duration) { s.tones(d, nil, nil) }
+
+// detect runs the audio through a detector in 20ms frames and returns the
+// digits reported.
+func detect(t *testing.T, cfg Config, s *signal) []Digit {
+	t.Helper()
+	var got []Digit
+	cfg.OnDigit = func(dg Digit) { got = append(got, dg) }
+	d, err := New(cfg, s.rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := audio.Format{SampleRate: s.rate, Channels: 1}
+	step := f.Samples(20 * ms)
+	for i := 0; i < len(s.data); i += step {
+		fr := audio.Frame{Format: f, Data: s.data[i:min(i+step, len(s.data))], Offset: f.Duration(i)}
+		out, err := d.Process(fr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 || len(out[0].Data) != len(fr.Data) {
+			t.Fatal("audio not passed through")
+		}
+	}
+	return got
+}
+
+func TestKeys(t *testing.T) {
+	all := []string{"1", "2", "3", "A", "4", "5", "6", "B", "7", "8", "9", "C", "*", "0", "#", "D"}
+	for _, rate := range []int{8000, 16000} {
+		s := &signal{rate: rate}
+		var starts []time.Duration
+		for _, k := range all {
+			s.silence(60 * ms)
+			starts = append(starts, s.now())
+			s.key(k, 70*ms, 0)
+		}
+		s.silence(60 * ms)
+		got := detect(t, Config{}, s)
+		if len(got) != len(all) {
+			t.Fatalf("%d Hz: got %v, want the 16 keys", rate, got)
+		}
+		for i, dg := range got {
+			if dg.Key != all[i] {
+				t.Errorf("%d Hz: digit %d is %q, want %q", rate, i, dg.Key, all[i])
+			}
+			// The first block holding the tone starts up to one hop before
+			// it, with purity of it in the tone.
+			if diff := dg.Offset - starts[i]; diff < -hop || diff > hop {
+				t.Errorf("%d Hz: %q at %v, want within %v of %v", rate, dg.Key, dg.Offset, hop, starts[i])
+			}
+		}
+	}
+}
+
+func TestHeldAndRepeated(t *testing.T) {
+	for _, tc := range []struct {
+		pause time.Duration
+		want  int
+	}{
+		{10 * ms, 1}, // a dropout, not a release
+		{25 * ms, 2}, // releaseBlocks blocks without the key
+		{40 * ms, 2}, // the shortest pause Q.24 allows
+	} {
+		for _, rate := range []int{8000, 16000} {
+			s := &signal{rate: rate}
+			s.silence(50 * ms)
+			s.key("5", time.Second, 0)
+			s.silence(tc.pause)
+			s.key("5", 100*ms, 0)
+			s.silence(50 * ms)
+			got := detect(t, Config{}, s)
+			if len(got) != tc.want {
+				t.Errorf("%d Hz: held 5 with a %v pause gave %v, want %d digits", rate, tc.pause, got, tc.want)
+			}
+			for _, dg := range got {
+				if dg.Key != "5" {
+					t.Errorf("%d Hz: got %q, want 5", rate, dg.Key)
+				}
+			}
+		}
+	}
+}
+
+func TestMinDuration(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  Config
+		tone time.Duration
+		want int
+	}{
+		{Config{}, DefaultMinDuration, 1},
+		{Config{}, 20 * ms, 0},
+		{Config{MinDuration: 100 * ms}, 110 * ms, 1},
+		{Config{MinDuration: 100 * ms}, 60 * ms, 0},
+	} {
+		for _, rate := range []int{8000, 16000} {
+			s := &signal{rate: rate}
+			s.silence(50 * ms)
+			s.key("9", tc.tone, 0)
+			s.silence(50 * ms)
+			if got := detect(t, tc.cfg, s); len(got) != tc.want {
+				t.Errorf("%d Hz, min duration %v: %v tone gave %v, want %d digits", rate, tc.cfg.MinDuration, tc.tone, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		audio func(*signal)
+		want  int
+	}{
+		{"twist within 8dB", func(s *signal) { s.key("1", 80*ms, 7) }, 1},
+		{"twist beyond 8dB", func(s *signal) { s.key("1", 80*ms, 9.5) }, 0},
+		{"reverse twist within 4dB", func(s *signal) { s.key("1", 80*ms, -3) }, 1},
+		{"reverse twist beyond 4dB", func(s *signal) { s.key("1", 80*ms, -5.5) }, 0},
+		{"too quiet", func(s *signal) {
+			s.tones(80*ms, []float64{697, 1209}, []float64{0.01, 0.01})
+		}, 0},
+		{"low tone alone", func(s *signal) { s.tones(80*ms, []float64{697}, []float64{0.5}) }, 0},
+		{"two rows", func(s *signal) {
+			s.tones(80*ms, []float64{697, 770, 1209}, []float64{0.3, 0.25, 0.3})
+		}, 0},
+		{"off frequencies", func(s *signal) { s.tones(80*ms, []float64{640, 1100}, []float64{0.3, 0.3}) }, 0},
+		{"speech", func(s *signal) {
+			// A voice gliding from 110 to 160Hz, its harmonics sweeping
+			// through the keypad frequencies.
+			for i := range 200 {
+				f0 := 110 + 50*float64(i)/200
+				var freqs, amps []float64
+				for h := 1.0; h*f0 < 3500; h++ {
+					freqs = append(freqs, h*f0)
+					amps = append(amps, 0.4/h)
+				}
+				s.tones(10*ms, freqs, amps)
+			}
+		}, 0},
+		{"noise", func(s *signal) {
+			r := rand.New(rand.NewPCG(1, 2))
+			for range s.rate * 2 {
+				s.data = append(s.data, int16(r.NormFloat64()*3000))
+			}
+		}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, rate := range []int{8000, 16000} {
+				s := &signal{rate: rate}
+				s.silence(50 * ms)
+				tc.audio(s)
+				s.silence(50 * ms)
+				if got := detect(t, Config{}, s); len(got) != tc.want {
+					t.Errorf("%d Hz: got %v, want %d digits", rate, got, tc.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Package endpoint decides where utterances end, as an audio.Stage the
+// audio passes through unchanged.
+//
+// Speech is told from silence by a vad.Detector. An utterance ends after
+// MinSilence of silence, or after the shorter FinishSilence when the pitch
+// fell over its last words, as it does at the end of statements, where a
+// writer would put a full stop. Utterances reaching MaxUtterance are cut at
+// their next short pause, so that recognizers never get more audio at once
+// than they decode well. Every end is reported to Config.OnEndpoint, whose
+// caller finalizes the recognizer there, so when utterances end does not
+// depend on the recognizer.
+package endpoint
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/dsp"
+	"github.com/jmarc101/voxa/internal/audio/vad"
+)
+
+// Config configures an Endpointer.
+type Config struct {
+	// MinSilence is the pause that ends an utterance. Defaults to
+	// DefaultMinSilence.
+	MinSilence time.Duration
+	// FinishSilence, if set, is the shorter pause ending utterances whose
+	// pitch fell at the end, so that finished sentences are answered
+	// sooner than those trailing off mid-thought. It must be below
+	// MinSilence.
+	FinishSilence time.Duration
+	// MaxUtterance, if set, bounds utterances: one that long ends at its
+	// next pause, however short, and is cut a second later at the latest.
+	MaxUtterance time.Duration
+	// OnEndpoint, if set, is called synchronously for every endpoint.
+	OnEndpoint func(Endpoint)
+}
+
+// DefaultMinSilence is the default MinSilence, the VAD's hangover.
+const DefaultMinSilence = 500 * time.Millisecond
+
+// Reasons for an utterance to end.
+const (
+	// Silence is MinSilence of silence.
+	Silence = "silence"
+	// Prosody is FinishSilence of silence after a falling pitch.
+	Prosody = "prosody"
+	// MaxLength is an utterance reaching MaxUtterance.
+	MaxLength = "max_length"
+)
+
+// Endpoint is the end of an utterance.
+type Endpoint struct {
+	// Reason is Silence, Prosody or MaxLength.
+	Reason string
+	// Offset is the stream time at which the utterance ended: where the
+	// pause ending it began, or where it was cut.
+	Offset time.Duration
+	// Length is how long the utterance lasted, from the start of speech.
+	Length time.Duration
+}
+
+const (
+	// cutPause is the pause an utterance past MaxUtterance is cut at.
+	cutPause = 60 * time.Millisecond
+	// maxGrace is how long past MaxUtterance a cut waits for a pause.
+	maxGrace = time.Second
+	// pitchWindow is the audio pitch is estimated on, two periods of the
+	// lowest voices.
+	pitchWindow = 40 * time.Millisecond
+	// The pitch of the last words of an utterance is compared with that of
+	// the words before them; a fall of fallSemitones finishes it.
+	lastWords     = 250 * time.Millisecond
+	wordsBefore   = 500 * time.Millisecond
+	fallSemitones = 2.0
+)
+
+// pitch is the pitch of a voiced frame, in semitones.
+type pitch struct {
+	at     time.Duration
+	semits float64
+}
+
+// Endpointer detects the ends of utterances in a mono stream. It is not
+// safe for concurrent use.
+type Endpointer struct {
+	cfg Config
+	det *vad.Detector
+
+	speech  bool          // an utterance is under way
+	start   time.Duration // of the utterance
+	paused  bool          // the detector ended speech; the pause is timed
+	end     time.Duration // where the pause began
+	falling bool          // the pitch fell before the pause
+	pause   time.Duration // current unvoiced run, for cuts at MaxUtterance
+	buf     []float64
+	tail    []float64 // the last pitchWindow of audio
+	pitches []pitch   // of the utterance's last voiced frames
+	pass    [1]audio.Frame
+}
+
+var _ audio.Stage = (*Endpointer)(nil)
+
+// New creates an Endpointer classifying speech with the Aggressiveness,
+// MinSpeech and Model of detect, the pipeline's VAD settings.
+func New(cfg Config, detect vad.Config) (*Endpointer, error) {
+	switch {
+	case cfg.MinSilence < 0:
+		return nil, errors.New("endpoint: min silence must not be negative")
+	case cfg.FinishSilence < 0:
+		return nil, errors.New("endpoint: finish silence must not be negative")
+	case cfg.MaxUtterance < 0:
+		return nil, errors.New("endpoint: max utterance must not be negative")
+	}
+	if cfg.MinSilence == 0 {
+		cfg.MinSilence = DefaultMinSilence
+	}
+	if cfg.FinishSilence >= cfg.MinSilence {
+		return nil, fmt.Errorf("endpoint: finish silence %v must be below min silence %v", cfg.FinishSilence, cfg.MinSilence)
+	}
+	e := &Endpointer{cfg: cfg}
+	// The detector ends speech at the shortest pause that may end an
+	// utterance, the endpointer timing the rest of it.
+	detect.Hangover = cmp.Or(cfg.FinishSilence, cfg.MinSilence)
+	detect.PreRoll = audio.FrameDuration
+	detect.OnEvent = e.onVAD
+	det, err := vad.New(detect)
+	if err != nil {
+		return nil, err
+	}
+	e.det = det
+	return e, nil
+}
+
+// Process looks for endpoints in fr and passes it through unchanged.
+func (e *Endpointer) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if fr.Format.Channels != 1 {
+		return nil, fmt.Errorf("endpoint: need mono audio, got %d channels", fr.Format.Channels)
+	}
+	if _, err := e.det.Process(fr); err != nil {
+		return nil, err
+	}
+	end := fr.Offset + fr.Duration()
+	if e.cfg.FinishSilence > 0 {
+		e.track(fr)
+	}
+	switch {
+	case e.paused:
+		silence := end - e.end
+		if silence >= e.cfg.MinSilence {
+			e.emit(Silence, e.end)
+		} else if e.falling && silence >= e.cfg.FinishSilence {
+			e.emit(Prosody, e.end)
+		}
+	case e.speech && e.cfg.MaxUtterance > 0:
+		if e.det.Voiced() {
+			e.pause = 0
+		} else {
+			e.pause += fr.Duration()
+		}
+		if n := end - e.start; n >= e.cfg.MaxUtterance && (e.pause >= cutPause || n >= e.cfg.MaxUtterance+maxGrace) {
+			e.emit(MaxLength, end)
+			// Speech goes on, in a new utterance.
+			e.speech, e.start = true, end
+		}
+	}
+	e.pass[0] = fr
+	return e.pass[:], nil
+}
+
+func (e *Endpointer) onVAD(ev vad.Event) {
+	switch ev.Type {
+	case vad.SpeechStart:
+		if !e.speech {
+			e.speech, e.start = true, ev.Offset
+		}
+		e.paused, e.pause = false, 0
+	case vad.SpeechEnd:
+		e.paused, e.end = true, ev.Offset
+		e.falling = e.cfg.FinishSilence > 0 && e.fell(ev.Offset)
+	}
+}
+
+// track records the pitch of fr while speaking.
+func (e *Endpointer) track(fr audio.Frame) {
+	rate := fr.Format.SampleRate
+	e.buf = dsp.Float(e.buf, fr.Data)
+	e.tail = append(e.tail, e.buf...)
+	n := int(int64(rate) * int64(pitchWindow) / int64(time.Second))
+	if len(e.tail) < n {
+		return
+	}
+	e.tail = e.tail[len(e.tail)-n:]
+	if !e.det.Speaking() {
+		return
+	}
+	if f0, ok := dsp.Pitch(e.tail, rate); ok {
+		e.pitches = append(e.pitches, pitch{at: fr.Offset, semits: 12 * math.Log2(f0)})
+	}
+	// Speech ends FinishSilence into the pause, which is then compared
+	// with what went before.
+	keep, from := 0, fr.Offset-e.cfg.FinishSilence-lastWords-wordsBefore
+	for keep < len(e.pitches) && e.pitches[keep].at < from {
+		keep++
+	}
+	e.pitches = e.pitches[keep:]
+}
+
+// fell reports whether the pitch fell over the last words before the
+// pause at end.
+func (e *Endpointer) fell(end time.Duration) bool {
+	var last, before, nl, nb float64
+	for _, p := range e.pitches {
+		switch {
+		case p.at >= end-lastWords:
+			last += p.semits
+			nl++
+		case p.at >= end-lastWords-wordsBefore:
+			before += p.semits
+			nb++
+		}
+	}
+	// Three frames or more on either side, for octave errors and creaky
+	// voice not to decide.
+	if nl < 3 || nb < 3 {
+		return false
+	}
+	return before/nb-last/nl >= fallSemitones
+}
+
+func (e *Endpointer) emit(reason string, at time.Duration) {
+	ep := Endpoint{Reason: reason, Offset: at, Length: at - e.start}
+	e.speech, e.paused, e.falling, e.pause = false, false, false, 0
+	e.pitches = e.pitches[:0]
+	if e.cfg.OnEndpoint != nil {
+		e.cfg.OnEndpoint(ep)
+	}
+}
@@ -0,0 +1,109 @@
+// Package flac decodes FLAC files for audio.Open. It registers itself on
+// import.
+//
+// Samples deeper than 16 bits are truncated to 16; decoding is pure Go.
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mewkiz/flac"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+func init() {
+	audio.RegisterDecoder(audio.Decoder{
+		Name:      "flac",
+		Match:     func(h []byte) bool { return len(h) >= 4 && string(h[:4]) == "fLaC" },
+		NewReader: func(r io.Reader) (audio.Reader, error) { return NewReader(r) },
+	})
+}
+
+// Reader reads a FLAC stream in audio.FrameDuration frames.
+type Reader struct {
+	stream *flac.Stream
+	format audio.Format
+	shift  int     // bits to drop (positive) or add (negative) per sample
+	buf    []int16 // decoded interleaved samples not yet returned
+	offset int     // samples per channel returned so far
+	eof    bool
+}
+
+// NewReader parses the FLAC header from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, fmt.Errorf("flac: %w", err)
+	}
+	info := stream.Info
+	if info.NChannels < 1 || info.SampleRate < 1 {
+		return nil, fmt.Errorf("%w: flac with %d channels at %d Hz", audio.ErrUnsupported, info.NChannels, info.SampleRate)
+	}
+	return &Reader{
+		stream: stream,
+		format: audio.Format{SampleRate: int(info.SampleRate), Channels: int(info.NChannels)},
+		shift:  int(info.BitsPerSample) - 16,
+	}, nil
+}
+
+// Format returns the stream format from the header.
+func (r *Reader) Format() audio.Format { return r.format }
+
+// ReadFrame returns the next FrameDuration of audio. The last frame may be
+// shorter. It returns io.EOF at the end of the stream.
+func (r *Reader) ReadFrame() (audio.Frame, error) {
+	want := r.format.Samples(audio.FrameDuration) * r.format.Channels
+	for len(r.buf) < want && !r.eof {
+		if err := r.decode(); err != nil {
+			return audio.Frame{}, err
+		}
+	}
+	n := min(want, len(r.buf))
+	if n == 0 {
+		return audio.Frame{}, io.EOF
+	}
+	fr := audio.Frame{
+		Format: r.format,
+		Data:   r.buf[:n],
+		Offset: r.format.Duration(r.offset),
+	}.Clone()
+	r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+	r.offset += fr.Len()
+	return fr, nil
+}
+
+// decode appends the next FLAC frame to buf.
+func (r *Reader) decode() error {
+	f, err := r.stream.ParseNext()
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		// A truncated file ends at its last complete frame.
+		r.eof = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("flac: %w", err)
+	}
+	if len(f.Subframes) != r.format.Channels {
+		return fmt.Errorf("flac: frame has %d channels, stream %d", len(f.Subframes), r.format.Channels)
+	}
+	for i := range f.Subframes[0].Samples {
+		for _, sub := range f.Subframes {
+			v := sub.Samples[i]
+			if r.shift > 0 {
+				v >>= r.shift
+			} else {
+				v <<= -r.shift
+			}
+			r.buf = append(r.buf, int16(v))
+		}
+	}
+	return nil
+}
+
+// Close releases the decoder.
+func (r *Reader) Close() error {
+	return r.stream.Close()
+}
@@ -0,0 +1,73 @@
+// Package g711 decodes ITU-T G.711 µ-law and A-law, the 8kHz companded
+// audio of telephony, to linear PCM16, and encodes µ-law.
+package g711
+
+// The 8-bit codes expand to 14 (µ-law) and 13 (A-law) bits of linear PCM;
+// these tables hold them scaled to 16 bits.
+var ulawTable, alawTable = func() (u, a [256]int16) {
+	for i := range 256 {
+		u[i], a[i] = ulaw(byte(i)), alaw(byte(i))
+	}
+	return u, a
+}()
+
+func ulaw(b byte) int16 {
+	b = ^b
+	t := (int16(b&0x0f)<<3 + 0x84) << ((b & 0x70) >> 4)
+	if b&0x80 != 0 {
+		return 0x84 - t
+	}
+	return t - 0x84
+}
+
+func alaw(b byte) int16 {
+	b ^= 0x55
+	t := int16(b&0x0f)<<4 + 8
+	if seg := (b & 0x70) >> 4; seg > 0 {
+		t = (t + 0x100) << (seg - 1)
+	}
+	if b&0x80 != 0 {
+		return t
+	}
+	return -t
+}
+
+// DecodeULaw appends the samples of the µ-law codes in src to dst.
+func DecodeULaw(dst []int16, src []byte) []int16 {
+	for _, b := range src {
+		dst = append(dst, ulawTable[b])
+	}
+	return dst
+}
+
+// DecodeALaw appends the samples of the A-law codes in src to dst.
+func DecodeALaw(dst []int16, src []byte) []int16 {
+	for _, b := range src {
+		dst = append(dst, alawTable[b])
+	}
+	return dst
+}
+
+// EncodeULaw appends the µ-law codes of the samples in src to dst.
+func EncodeULaw(dst []byte, src []int16) []byte {
+	for _, v := range src {
+		dst = append(dst, toULaw(v))
+	}
+	return dst
+}
+
+// toULaw compands v as G.711 does: biased, its segment is that of its
+// highest set bit, with the 4 bits after it kept.
+func toULaw(v int16) byte {
+	const bias, clip = 0x84, 32635
+	x, sign := int(v), byte(0)
+	if x < 0 {
+		x, sign = -x, 0x80
+	}
+	x = min(x, clip) + bias
+	seg := byte(0)
+	for t := x >> 8; t > 0 && seg < 7; t >>= 1 {
+		seg++
+	}
+	return ^(sign | seg<<4 | byte(x>>(seg+3))&0x0f)
+}
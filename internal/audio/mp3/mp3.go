@@ -0,0 +1,82 @@
+// Package mp3 decodes MPEG-1/2 Layer III files for audio.Open. It
+// registers itself on import.
+//
+// Decoding is pure Go. Audio is always returned as stereo at the file's
+// sample rate, mono files included.
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+func init() {
+	audio.RegisterDecoder(audio.Decoder{
+		Name:      "mp3",
+		Match:     match,
+		NewReader: func(r io.Reader) (audio.Reader, error) { return NewReader(r) },
+	})
+}
+
+// match recognizes an ID3v2 tag or an MPEG audio frame header: an 11-bit
+// sync word followed by layer III and a valid bitrate and sample rate.
+func match(h []byte) bool {
+	if len(h) >= 3 && string(h[:3]) == "ID3" {
+		return true
+	}
+	if len(h) < 4 || h[0] != 0xFF || h[1]&0xE0 != 0xE0 {
+		return false
+	}
+	layer, bitrate, rate := h[1]>>1&3, h[2]>>4, h[2]>>2&3
+	return layer == 1 && bitrate != 0 && bitrate != 15 && rate != 3
+}
+
+// Reader reads an MP3 stream in audio.FrameDuration frames.
+type Reader struct {
+	dec    *mp3.Decoder
+	format audio.Format
+	buf    []byte
+	offset int // samples per channel returned so far
+}
+
+// NewReader decodes the MP3 stream in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	dec, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("mp3: %w", err)
+	}
+	return &Reader{dec: dec, format: audio.Format{SampleRate: dec.SampleRate(), Channels: 2}}, nil
+}
+
+// Format returns the stream format.
+func (r *Reader) Format() audio.Format { return r.format }
+
+// ReadFrame returns the next FrameDuration of audio. The last frame may be
+// shorter. It returns io.EOF at the end of the stream.
+func (r *Reader) ReadFrame() (audio.Frame, error) {
+	size := 2 * r.format.Channels * r.format.Samples(audio.FrameDuration)
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+	b := r.buf[:size]
+	n, err := io.ReadFull(r.dec, b)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return audio.Frame{}, fmt.Errorf("mp3: %w", err)
+	}
+	n -= n % (2 * r.format.Channels)
+	if n == 0 {
+		return audio.Frame{}, io.EOF
+	}
+	fr := audio.Frame{
+		Format: r.format,
+		Data:   audio.DecodePCM16(audio.GetSamples(n/2), b[:n]),
+		Offset: r.format.Duration(r.offset),
+	}
+	r.offset += fr.Len()
+	return fr, nil
+}
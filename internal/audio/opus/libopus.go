@@ -0,0 +1,95 @@
+//go:build cgo && opus
+
+package opus
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+
+// opus_encoder_ctl is variadic, which cgo cannot call directly.
+static int voxa_opus_set_bitrate(OpusEncoder *enc, opus_int32 bps) {
+	return opus_encoder_ctl(enc, OPUS_SET_BITRATE(bps));
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+func opusError(op string, code C.int) error {
+	return fmt.Errorf("opus: %s: %s", op, C.GoString(C.opus_strerror(code)))
+}
+
+type libEncoder struct {
+	enc *C.OpusEncoder
+}
+
+func newEncoder(f audio.Format, app Application) (encoder, error) {
+	var cerr C.int
+	enc := C.opus_encoder_create(C.opus_int32(f.SampleRate), C.int(f.Channels), C.int(app), &cerr)
+	if cerr != C.OPUS_OK {
+		return nil, opusError("create encoder", cerr)
+	}
+	return &libEncoder{enc: enc}, nil
+}
+
+func (e *libEncoder) encode(pcm []int16, samples int, out []byte) (int, error) {
+	n := C.opus_encode(e.enc,
+		(*C.opus_int16)(unsafe.Pointer(&pcm[0])), C.int(samples),
+		(*C.uchar)(unsafe.Pointer(&out[0])), C.opus_int32(len(out)))
+	if n < 0 {
+		return 0, opusError("encode", n)
+	}
+	return int(n), nil
+}
+
+func (e *libEncoder) setBitrate(bps int) error {
+	if rc := C.voxa_opus_set_bitrate(e.enc, C.opus_int32(bps)); rc != C.OPUS_OK {
+		return opusError("set bitrate", rc)
+	}
+	return nil
+}
+
+func (e *libEncoder) close() {
+	if e.enc != nil {
+		C.opus_encoder_destroy(e.enc)
+		e.enc = nil
+	}
+}
+
+type libDecoder struct {
+	dec *C.OpusDecoder
+}
+
+func newDecoder(f audio.Format) (decoder, error) {
+	var cerr C.int
+	dec := C.opus_decoder_create(C.opus_int32(f.SampleRate), C.int(f.Channels), &cerr)
+	if cerr != C.OPUS_OK {
+		return nil, opusError("create decoder", cerr)
+	}
+	return &libDecoder{dec: dec}, nil
+}
+
+func (d *libDecoder) decode(packet []byte, pcm []int16, maxSamples int) (int, error) {
+	var data *C.uchar
+	if len(packet) > 0 {
+		data = (*C.uchar)(unsafe.Pointer(&packet[0]))
+	}
+	n := C.opus_decode(d.dec, data, C.opus_int32(len(packet)),
+		(*C.opus_int16)(unsafe.Pointer(&pcm[0])), C.int(maxSamples), 0)
+	if n < 0 {
+		return 0, opusError("decode", n)
+	}
+	return int(n), nil
+}
+
+func (d *libDecoder) close() {
+	if d.dec != nil {
+		C.opus_decoder_destroy(d.dec)
+		d.dec = nil
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(cgo && opus)
+
+package opus
+
+import "github.com/jmarc101/voxa/internal/audio"
+
+func newEncoder(audio.Format, Application) (encoder, error) { return nil, ErrNoCodec }
+
+func newDecoder(audio.Format) (decoder, error) { return nil, ErrNoCodec }
@@ -0,0 +1,326 @@
+package opus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+func init() {
+	audio.RegisterDecoder(audio.Decoder{
+		Name: "opus",
+		Match: func(h []byte) bool {
+			// The first page holds only the identification header.
+			return len(h) >= 36 && string(h[:4]) == "OggS" && string(h[28:36]) == "OpusHead"
+		},
+		NewReader: func(r io.Reader) (audio.Reader, error) { return NewOggReader(r) },
+	})
+}
+
+// OggPreSkip is the number of 48kHz samples OggWriter streams ask players
+// to drop at their start: the encoder's look-ahead.
+const OggPreSkip = 312
+
+// oggPagePackets bounds the packets of a page, about a second of audio.
+const oggPagePackets = 50
+
+// OggWriter writes one logical Ogg bitstream (RFC 3533) of Opus packets,
+// as RFC 7845 maps them. Packets never span pages.
+type OggWriter struct {
+	w       io.Writer
+	serial  uint32
+	seq     uint32
+	lacing  []byte // segment table of the page being filled
+	body    []byte
+	packets int
+	granule int64 // of the last packet in the page
+}
+
+// NewOggWriter returns a writer of a stream with a random serial number.
+func NewOggWriter(w io.Writer) *OggWriter {
+	return &OggWriter{w: w, serial: rand.Uint32()}
+}
+
+// Headers writes the identification and comment headers, each on its own
+// page as RFC 7845 requires. It must be called first.
+func (o *OggWriter) Headers(channels, inputRate int) error {
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1 // version
+	head[9] = byte(channels)
+	binary.LittleEndian.PutUint16(head[10:], OggPreSkip)
+	binary.LittleEndian.PutUint32(head[12:], uint32(inputRate))
+	// Output gain and channel mapping family 0 stay zero.
+	o.add(head)
+	if err := o.flush(0x02, 0); err != nil { // beginning of stream
+		return err
+	}
+	const vendor = "voxa"
+	tags := make([]byte, 8+4+len(vendor)+4)
+	copy(tags, "OpusTags")
+	binary.LittleEndian.PutUint32(tags[8:], uint32(len(vendor)))
+	copy(tags[12:], vendor)
+	o.add(tags)
+	return o.flush(0, 0)
+}
+
+// Packet adds an audio packet ending at granule, a position in 48kHz
+// samples counting the pre-skip, and ends the stream if last.
+func (o *OggWriter) Packet(p []byte, granule int64, last bool) error {
+	if len(o.lacing)+len(p)/255+1 > 255 {
+		if err := o.flush(0, o.granule); err != nil {
+			return err
+		}
+	}
+	o.add(p)
+	o.granule = granule
+	switch {
+	case last:
+		return o.flush(0x04, granule)
+	case o.packets >= oggPagePackets:
+		return o.flush(0, granule)
+	}
+	return nil
+}
+
+// End ends the stream at granule, with the pending packets if any.
+func (o *OggWriter) End(granule int64) error {
+	return o.flush(0x04, granule)
+}
+
+func (o *OggWriter) add(p []byte) {
+	for n := len(p); ; n -= 255 {
+		if n < 255 {
+			o.lacing = append(o.lacing, byte(n))
+			break
+		}
+		o.lacing = append(o.lacing, 255)
+	}
+	o.body = append(o.body, p...)
+	o.packets++
+}
+
+// flush writes the pending packets as a page.
+func (o *OggWriter) flush(flags byte, granule int64) error {
+	page := make([]byte, 27, 27+len(o.lacing)+len(o.body))
+	copy(page, "OggS")
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:], o.serial)
+	binary.LittleEndian.PutUint32(page[18:], o.seq)
+	page[26] = byte(len(o.lacing))
+	page = append(append(page, o.lacing...), o.body...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+	o.seq++
+	o.lacing, o.body, o.packets = o.lacing[:0], o.body[:0], 0
+	_, err := o.w.Write(page)
+	return err
+}
+
+// oggCRCTable is the CRC-32 of Ogg: polynomial 0x04c11db7, unreflected,
+// no initial or final inversion.
+var oggCRCTable = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// OggReader decodes the first logical Opus stream of an Ogg file (RFC
+// 7845), dropping the pre-skip at its start and the padding at its end.
+// Streams with more than two channels are not supported.
+type OggReader struct {
+	r       *bufio.Reader
+	dec     *Decoder
+	format  audio.Format
+	serial  uint32
+	found   bool     // whether the stream's first page was read
+	scale   int64    // 48kHz samples per sample of format
+	skip    int64    // 48kHz samples to drop at the start
+	end     int64    // granule of the last page; -1 until it is read
+	pos     int64    // 48kHz samples decoded so far
+	packets [][]byte // complete packets of the current page not yet decoded
+	partial []byte   // packet continued on the next page
+	buf     []int16  // decoded interleaved samples not yet returned
+	offset  int      // samples per channel returned so far
+	eof     bool
+}
+
+// NewOggReader parses the Opus headers from r. The audio is decoded at the
+// input sample rate the header records if Opus supports it, else at 48kHz.
+func NewOggReader(r io.Reader) (*OggReader, error) {
+	o := &OggReader{r: bufio.NewReader(r), end: -1}
+	head, err := o.packet()
+	if err != nil {
+		return nil, fmt.Errorf("opus: ogg: %w", err)
+	}
+	if len(head) < 19 || string(head[:8]) != "OpusHead" || head[8]>>4 != 0 {
+		return nil, errors.New("opus: ogg: bad identification header")
+	}
+	channels, rate := int(head[9]), int(binary.LittleEndian.Uint32(head[12:]))
+	if head[18] != 0 || channels < 1 || channels > 2 {
+		return nil, fmt.Errorf("%w: ogg opus with %d channels, mapping family %d", audio.ErrUnsupported, channels, head[18])
+	}
+	o.format = audio.Format{SampleRate: 48000, Channels: channels}
+	switch rate {
+	case 8000, 12000, 16000, 24000:
+		o.format.SampleRate = rate
+	}
+	o.scale = int64(48000 / o.format.SampleRate)
+	o.skip = int64(binary.LittleEndian.Uint16(head[10:]))
+	tags, err := o.packet()
+	if err != nil {
+		return nil, fmt.Errorf("opus: ogg: %w", err)
+	}
+	if len(tags) < 8 || string(tags[:8]) != "OpusTags" {
+		return nil, errors.New("opus: ogg: bad comment header")
+	}
+	if o.dec, err = NewDecoder(o.format); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Format returns the format the stream is decoded in.
+func (o *OggReader) Format() audio.Format { return o.format }
+
+// ReadFrame returns the next FrameDuration of audio. The last frame may be
+// shorter. It returns io.EOF at the end of the stream.
+func (o *OggReader) ReadFrame() (audio.Frame, error) {
+	want := o.format.Samples(audio.FrameDuration) * o.format.Channels
+	for len(o.buf) < want && !o.eof {
+		if err := o.decode(); err != nil {
+			return audio.Frame{}, err
+		}
+	}
+	n := min(want, len(o.buf))
+	if n == 0 {
+		return audio.Frame{}, io.EOF
+	}
+	fr := audio.Frame{
+		Format: o.format,
+		Data:   o.buf[:n],
+		Offset: o.format.Duration(o.offset),
+	}.Clone()
+	o.buf = o.buf[:copy(o.buf, o.buf[n:])]
+	o.offset += fr.Len()
+	return fr, nil
+}
+
+// decode appends the audio of the next packet to buf, keeping what lies
+// between the pre-skip and the end of the stream.
+func (o *OggReader) decode() error {
+	p, err := o.packet()
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		// A truncated file ends at its last complete packet.
+		o.eof = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opus: ogg: %w", err)
+	}
+	fr, err := o.dec.Decode(p)
+	if err != nil {
+		return err
+	}
+	from, to := o.pos, o.pos+int64(fr.Len())*o.scale
+	o.pos = to
+	lo, hi := max(from, o.skip), to
+	if o.end >= 0 {
+		hi = min(hi, o.end)
+	}
+	if lo < hi {
+		ch := int64(o.format.Channels)
+		o.buf = append(o.buf, fr.Data[(lo-from)/o.scale*ch:(hi-from)/o.scale*ch]...)
+	}
+	return nil
+}
+
+// packet returns the next packet of the stream.
+func (o *OggReader) packet() ([]byte, error) {
+	for len(o.packets) == 0 {
+		if err := o.page(); err != nil {
+			return nil, err
+		}
+	}
+	p := o.packets[0]
+	o.packets = o.packets[1:]
+	return p, nil
+}
+
+// page reads the next page of the stream into packets, skipping pages of
+// other streams.
+func (o *OggReader) page() error {
+	var h [27]byte
+	if _, err := io.ReadFull(o.r, h[:]); err != nil {
+		return err
+	}
+	if string(h[:4]) != "OggS" || h[4] != 0 {
+		return errors.New("bad page header")
+	}
+	lacing := make([]byte, h[26])
+	if _, err := io.ReadFull(o.r, lacing); err != nil {
+		return err
+	}
+	size := 0
+	for _, n := range lacing {
+		size += int(n)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(o.r, body); err != nil {
+		return err
+	}
+	crc := binary.LittleEndian.Uint32(h[22:])
+	binary.LittleEndian.PutUint32(h[22:], 0)
+	if oggCRC(append(append(h[:], lacing...), body...)) != crc {
+		return errors.New("page checksum mismatch")
+	}
+	flags, serial := h[5], binary.LittleEndian.Uint32(h[14:])
+	switch {
+	case flags&0x02 != 0 && !o.found:
+		o.serial, o.found = serial, true
+	case !o.found || serial != o.serial:
+		return nil
+	}
+	if flags&0x01 == 0 {
+		o.partial = nil // a continuation whose start was lost
+	}
+	for _, n := range lacing {
+		o.partial = append(o.partial, body[:n]...)
+		body = body[n:]
+		if n < 255 {
+			o.packets = append(o.packets, o.partial)
+			o.partial = nil
+		}
+	}
+	if flags&0x04 != 0 {
+		o.end = int64(binary.LittleEndian.Uint64(h[6:]))
+	}
+	return nil
+}
+
+// Close releases the decoder.
+func (o *OggReader) Close() error {
+	return o.dec.Close()
+}
@@ -0,0 +1,291 @@
+// Package opus converts between Opus packets and audio.Frame.
+//
+// The codec itself is libopus, bound through cgo. Build with `-tags opus`
+// (and libopus development headers installed) to enable it; without the tag
+// NewEncoder and NewDecoder return ErrNoCodec. Packet inspection (TOC
+// parsing) is pure Go and always available.
+//
+// OggWriter and OggReader carry packets in Ogg files (RFC 7845); importing
+// the package registers OggReader with audio.Open.
+package opus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// ErrNoCodec is returned when voxa was built without libopus.
+var ErrNoCodec = errors.New("opus: built without libopus (rebuild with -tags opus)")
+
+// ErrInvalidPacket is returned for packets with a malformed TOC.
+var ErrInvalidPacket = errors.New("opus: invalid packet")
+
+// maxPacketDuration is the longest audio a single Opus packet can carry.
+const maxPacketDuration = 120 * time.Millisecond
+
+// maxPacketSize is the recommended upper bound for an encoded packet.
+const maxPacketSize = 4000
+
+// Application selects the encoder tuning. Values match libopus.
+type Application int
+
+const (
+	// AppVoIP favours speech intelligibility.
+	AppVoIP Application = 2048
+	// AppAudio favours fidelity for music and mixed content.
+	AppAudio Application = 2049
+	// AppLowDelay minimises algorithmic delay.
+	AppLowDelay Application = 2051
+)
+
+// EncoderConfig configures an Encoder.
+type EncoderConfig struct {
+	// Format of the input frames. Opus supports 8, 12, 16, 24 and 48 kHz,
+	// mono or stereo.
+	Format audio.Format
+	// Bitrate in bits per second; 0 lets libopus pick.
+	Bitrate int
+	// FrameDuration of each packet: 2.5, 5, 10, 20, 40 or 60 ms.
+	// Defaults to audio.FrameDuration.
+	FrameDuration time.Duration
+	// Application defaults to AppVoIP.
+	Application Application
+}
+
+func (c *EncoderConfig) validate() error {
+	if err := validateFormat(c.Format); err != nil {
+		return err
+	}
+	if c.FrameDuration == 0 {
+		c.FrameDuration = audio.FrameDuration
+	}
+	switch c.FrameDuration {
+	case 2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond,
+		20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond:
+	default:
+		return fmt.Errorf("opus: unsupported frame duration %v", c.FrameDuration)
+	}
+	if c.Bitrate != 0 && (c.Bitrate < 6000 || c.Bitrate > 510000) {
+		return fmt.Errorf("opus: bitrate %d out of range [6000, 510000]", c.Bitrate)
+	}
+	if c.Application == 0 {
+		c.Application = AppVoIP
+	}
+	return nil
+}
+
+func validateFormat(f audio.Format) error {
+	switch f.SampleRate {
+	case 8000, 12000, 16000, 24000, 48000:
+	default:
+		return fmt.Errorf("opus: unsupported sample rate %d", f.SampleRate)
+	}
+	if f.Channels != 1 && f.Channels != 2 {
+		return fmt.Errorf("opus: unsupported channel count %d", f.Channels)
+	}
+	return nil
+}
+
+// Encoder turns PCM frames into Opus packets of a fixed duration. Input
+// frames may be any length; samples are buffered until a full packet is
+// available.
+type Encoder struct {
+	cfg     EncoderConfig
+	impl    encoder
+	samples int // samples per channel in one packet
+	pending []int16
+	out     []byte
+}
+
+// NewEncoder creates an Encoder.
+func NewEncoder(cfg EncoderConfig) (*Encoder, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	impl, err := newEncoder(cfg.Format, cfg.Application)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Bitrate != 0 {
+		if err := impl.setBitrate(cfg.Bitrate); err != nil {
+			impl.close()
+			return nil, err
+		}
+	}
+	return &Encoder{
+		cfg:     cfg,
+		impl:    impl,
+		samples: cfg.Format.Samples(cfg.FrameDuration),
+		out:     make([]byte, maxPacketSize),
+	}, nil
+}
+
+// SetBitrate changes the target bitrate for subsequent packets.
+func (e *Encoder) SetBitrate(bps int) error {
+	if bps < 6000 || bps > 510000 {
+		return fmt.Errorf("opus: bitrate %d out of range [6000, 510000]", bps)
+	}
+	e.cfg.Bitrate = bps
+	return e.impl.setBitrate(bps)
+}
+
+// Encode buffers fr and returns every complete packet now available.
+func (e *Encoder) Encode(fr audio.Frame) ([][]byte, error) {
+	if fr.Format != e.cfg.Format {
+		return nil, fmt.Errorf("opus: frame format %+v does not match encoder %+v", fr.Format, e.cfg.Format)
+	}
+	e.pending = append(e.pending, fr.Data...)
+	step := e.samples * e.cfg.Format.Channels
+	var pkts [][]byte
+	for len(e.pending) >= step {
+		pkt, err := e.encode(e.pending[:step])
+		if err != nil {
+			return pkts, err
+		}
+		pkts = append(pkts, pkt)
+		e.pending = e.pending[step:]
+	}
+	// Compact so the backing array does not grow without bound.
+	e.pending = append(e.pending[:0:0], e.pending...)
+	return pkts, nil
+}
+
+// Flush encodes any buffered samples, padded with silence to a full packet.
+// It returns nil when nothing is pending.
+func (e *Encoder) Flush() ([]byte, error) {
+	if len(e.pending) == 0 {
+		return nil, nil
+	}
+	pcm := make([]int16, e.samples*e.cfg.Format.Channels)
+	copy(pcm, e.pending)
+	e.pending = e.pending[:0]
+	return e.encode(pcm)
+}
+
+func (e *Encoder) encode(pcm []int16) ([]byte, error) {
+	n, err := e.impl.encode(pcm, e.samples, e.out)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.out[:n]...), nil
+}
+
+// Close releases the codec state.
+func (e *Encoder) Close() error {
+	e.impl.close()
+	return nil
+}
+
+// Decoder turns Opus packets into PCM frames.
+type Decoder struct {
+	format audio.Format
+	impl   decoder
+	pcm    []int16
+	offset int // samples per channel decoded so far
+	last   int // samples per channel in the previous packet, for concealment
+}
+
+// NewDecoder creates a Decoder producing frames in the given format. Opus
+// can decode any packet to any supported rate and channel count.
+func NewDecoder(format audio.Format) (*Decoder, error) {
+	if err := validateFormat(format); err != nil {
+		return nil, err
+	}
+	impl, err := newDecoder(format)
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{
+		format: format,
+		impl:   impl,
+		pcm:    make([]int16, format.Samples(maxPacketDuration)*format.Channels),
+		last:   format.Samples(audio.FrameDuration),
+	}, nil
+}
+
+// Decode decodes one packet. A nil packet signals a lost packet: the decoder
+// conceals it with audio matching the previous packet's duration.
+func (d *Decoder) Decode(packet []byte) (audio.Frame, error) {
+	want := len(d.pcm) / d.format.Channels
+	if packet == nil {
+		want = d.last
+	}
+	n, err := d.impl.decode(packet, d.pcm, want)
+	if err != nil {
+		return audio.Frame{}, err
+	}
+	fr := audio.Frame{
+		Format: d.format,
+		Data:   d.pcm[:n*d.format.Channels],
+		Offset: d.format.Duration(d.offset),
+	}.Clone()
+	d.offset += n
+	if packet != nil {
+		d.last = n
+	}
+	return fr, nil
+}
+
+// Close releases the codec state.
+func (d *Decoder) Close() error {
+	d.impl.close()
+	return nil
+}
+
+// encoder and decoder are implemented by the libopus binding.
+type encoder interface {
+	encode(pcm []int16, samples int, out []byte) (int, error)
+	setBitrate(bps int) error
+	close()
+}
+
+type decoder interface {
+	// decode writes at most maxSamples per channel into pcm and returns the
+	// number decoded. A nil packet requests packet-loss concealment.
+	decode(packet []byte, pcm []int16, maxSamples int) (int, error)
+	close()
+}
+
+// PacketDuration parses the TOC of an Opus packet (RFC 6716 §3.1) and
+// returns how much audio it carries.
+func PacketDuration(packet []byte) (time.Duration, error) {
+	if len(packet) == 0 {
+		return 0, ErrInvalidPacket
+	}
+	toc := packet[0]
+	cfg := toc >> 3
+	var frame time.Duration
+	switch {
+	case cfg < 12: // SILK-only
+		frame = [...]time.Duration{10, 20, 40, 60}[cfg%4] * time.Millisecond
+	case cfg < 16: // Hybrid
+		frame = [...]time.Duration{10, 20}[cfg%2] * time.Millisecond
+	default: // CELT-only
+		frame = [...]time.Duration{2500, 5000, 10000, 20000}[cfg%4] * time.Microsecond
+	}
+	var count int
+	switch toc & 0x3 {
+	case 0:
+		count = 1
+	case 1, 2:
+		count = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0, ErrInvalidPacket
+		}
+		count = int(packet[1] & 0x3f)
+	}
+	d := time.Duration(count) * frame
+	if count == 0 || d > maxPacketDuration {
+		return 0, ErrInvalidPacket
+	}
+	return d, nil
+}
+
+// PacketStereo reports whether the packet's TOC signals stereo.
+func PacketStereo(packet []byte) bool {
+	return len(packet) > 0 && packet[0]&0x4 != 0
+}
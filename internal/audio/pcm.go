@@ -0,0 +1,79 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PCMReader reads headerless little-endian PCM16 in FrameDuration frames,
+// as arecord -t raw and ffmpeg -f s16le write it.
+type PCMReader struct {
+	r      io.Reader
+	format Format
+	offset int // samples per channel read so far
+	buf    []byte
+	eof    bool
+}
+
+// NewPCMReader reads r as PCM16 in format f.
+func NewPCMReader(r io.Reader, f Format) (*PCMReader, error) {
+	if f.SampleRate < 1 || f.Channels < 1 {
+		return nil, fmt.Errorf("%w: %d channels at %d Hz", ErrUnsupported, f.Channels, f.SampleRate)
+	}
+	return &PCMReader{r: r, format: f}, nil
+}
+
+// Format returns the format the reader was given.
+func (p *PCMReader) Format() Format { return p.format }
+
+// ReadFrame returns the next FrameDuration of audio, waiting for all of it
+// to arrive. The last frame may be shorter; a trailing partial sample is
+// dropped. It returns io.EOF once r is exhausted.
+func (p *PCMReader) ReadFrame() (Frame, error) {
+	if p.eof {
+		return Frame{}, io.EOF
+	}
+	size := 2 * p.format.Channels * p.format.Samples(FrameDuration)
+	if cap(p.buf) < size {
+		p.buf = make([]byte, size)
+	}
+	b := p.buf[:size]
+	n, err := io.ReadFull(p.r, b)
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+		p.eof = true
+	case err != nil:
+		return Frame{}, err
+	}
+	n -= n % (2 * p.format.Channels)
+	if n == 0 {
+		return Frame{}, io.EOF
+	}
+	fr := Frame{
+		Format: p.format,
+		Data:   DecodePCM16(GetSamples(n/2), b[:n]),
+		Offset: p.format.Duration(p.offset),
+	}
+	p.offset += fr.Len()
+	return fr, nil
+}
+
+// ParseFormat parses a format written rate or rate:channels, such as 16000
+// or 48000:2; channels default to 1.
+func ParseFormat(s string) (Format, error) {
+	rate, channels, ok := strings.Cut(s, ":")
+	f := Format{Channels: 1}
+	var err error
+	if f.SampleRate, err = strconv.Atoi(rate); err != nil || f.SampleRate < 1 {
+		return Format{}, fmt.Errorf("audio: bad sample rate in %q", s)
+	}
+	if ok {
+		if f.Channels, err = strconv.Atoi(channels); err != nil || f.Channels < 1 {
+			return Format{}, fmt.Errorf("audio: bad channel count in %q", s)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,115 @@
+//go:build cgo && linux && alsa
+
+package playback
+
+/*
+#cgo pkg-config: alsa
+#include <stdlib.h>
+#include <alsa/asoundlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+func init() { register(alsa{}) }
+
+func alsaError(op string, code C.int) error {
+	return fmt.Errorf("%s: %s", op, C.GoString(C.snd_strerror(code)))
+}
+
+// alsa is the ALSA backend. Devices are opened through the plug layer,
+// which converts rates and channels itself, so the requested format is
+// always granted.
+type alsa struct{}
+
+func (alsa) name() string { return "alsa" }
+
+// latency is the playback buffer ALSA is asked for, and so the most a stop
+// can lag behind without drop.
+const latency = 100 * time.Millisecond
+
+func (alsa) open(id string, want audio.Format, frame time.Duration) (output, audio.Format, error) {
+	if id == "" {
+		id = "default"
+	}
+	cid := C.CString(id)
+	defer C.free(unsafe.Pointer(cid))
+	var pcm *C.snd_pcm_t
+	if rc := C.snd_pcm_open(&pcm, cid, C.SND_PCM_STREAM_PLAYBACK, 0); rc < 0 {
+		return nil, audio.Format{}, alsaError("open "+id, rc)
+	}
+	rc := C.snd_pcm_set_params(pcm, C.SND_PCM_FORMAT_S16_LE, C.SND_PCM_ACCESS_RW_INTERLEAVED,
+		C.uint(want.Channels), C.uint(want.SampleRate), 1, C.uint(max(latency, 2*frame)/time.Microsecond))
+	if rc < 0 {
+		C.snd_pcm_close(pcm)
+		return nil, audio.Format{}, alsaError("configure "+id, rc)
+	}
+	return &alsaOutput{pcm: pcm, channels: want.Channels}, want, nil
+}
+
+type alsaOutput struct {
+	pcm      *C.snd_pcm_t
+	channels int
+}
+
+func (out *alsaOutput) write(buf []int16) error {
+	for n := len(buf) / out.channels; n > 0; {
+		got := C.snd_pcm_writei(out.pcm, unsafe.Pointer(&buf[len(buf)-n*out.channels]), C.snd_pcm_uframes_t(n))
+		if got >= 0 {
+			n -= int(got)
+			continue
+		}
+		switch syscall.Errno(-got) {
+		case syscall.ENODEV, syscall.EBADFD, syscall.EIO:
+			return errLost
+		default:
+			// Underruns (EPIPE), such as between items, and suspends
+			// (ESTRPIPE) play a moment of silence, but the stream goes on.
+			if rc := C.snd_pcm_recover(out.pcm, C.int(got), 1); rc < 0 {
+				return alsaError("write", rc)
+			}
+		}
+	}
+	return nil
+}
+
+// drain waits for the buffer to play out and readies the device for more.
+func (out *alsaOutput) drain() error {
+	if rc := C.snd_pcm_drain(out.pcm); rc < 0 {
+		return alsaError("drain", rc)
+	}
+	if rc := C.snd_pcm_prepare(out.pcm); rc < 0 {
+		return alsaError("prepare", rc)
+	}
+	return nil
+}
+
+// drop discards the buffer and readies the device for more.
+func (out *alsaOutput) drop() error {
+	if rc := C.snd_pcm_drop(out.pcm); rc < 0 {
+		return alsaError("drop", rc)
+	}
+	if rc := C.snd_pcm_prepare(out.pcm); rc < 0 {
+		return alsaError("prepare", rc)
+	}
+	return nil
+}
+
+func (out *alsaOutput) close() error {
+	if out.pcm == nil {
+		return nil
+	}
+	rc := C.snd_pcm_close(out.pcm)
+	out.pcm = nil
+	if rc < 0 {
+		return alsaError("close", rc)
+	}
+	return nil
+}
@@ -0,0 +1,536 @@
+// Package playback plays audio on a speaker, such as the replies of a
+// voice assistant running on the device it listens on.
+//
+// The backends are those of package capture, bound through cgo and enabled
+// by the same build tags: PortAudio (`-tags portaudio`), which plays
+// through CoreAudio on macOS, WASAPI on Windows and ALSA or PulseAudio on
+// Linux, and ALSA itself (`-tags alsa`, Linux only). When both are built
+// in PortAudio is preferred. Without either tag Open returns ErrNoBackend.
+//
+// A Player plays the audio queued on it in order, converted to the format
+// the device was opened in and scaled by its volume. Each Play returns an
+// Item to await, stop or attach completion callbacks to. Stopping discards
+// what the device has buffered, so audio cut short, as on barge-in, goes
+// silent at once.
+package playback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/capture"
+)
+
+var (
+	// ErrNoBackend is returned when voxa was built without a playback
+	// backend.
+	ErrNoBackend = errors.New("playback: built without a playback backend (rebuild with -tags portaudio or -tags alsa)")
+	// ErrStopped is the error of items stopped before they were played to
+	// the end.
+	ErrStopped = errors.New("playback: stopped")
+	// ErrClosed is the error of items queued on a closed Player.
+	ErrClosed = errors.New("playback: player closed")
+	// ErrDeviceLost is the error of an item whose device disappeared while
+	// it played. The next item opens the device again.
+	ErrDeviceLost = errors.New("playback: device lost")
+)
+
+// errLost is what backends return from write when the device went away.
+var errLost = errors.New("device lost")
+
+// backend is a native audio API.
+type backend interface {
+	name() string
+	// open starts playing on device id ("" for the default) in want, or in
+	// the closest format the device supports, which it returns.
+	open(id string, want audio.Format, frame time.Duration) (output, audio.Format, error)
+}
+
+// output is an open playback stream.
+type output interface {
+	// write blocks until buf has been handed to the device. It returns
+	// errLost if the device is gone.
+	write(buf []int16) error
+	// drain blocks until the audio handed to the device has been played.
+	drain() error
+	// drop discards the audio handed to the device but not played yet.
+	drop() error
+	close() error
+}
+
+// backends lists the compiled-in backends by preference. Each backend file
+// adds itself from init.
+var backends []backend
+
+// preference orders backends regardless of file initialisation order.
+var preference = []string{"portaudio", "alsa"}
+
+func register(b backend) {
+	backends = append(backends, b)
+	sort.SliceStable(backends, func(i, j int) bool {
+		return slices.Index(preference, backends[i].name()) < slices.Index(preference, backends[j].name())
+	})
+}
+
+func lookup(name string) (backend, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackend
+	}
+	if name == "" {
+		return backends[0], nil
+	}
+	for _, b := range backends {
+		if b.name() == name {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("playback: backend %q not built in", name)
+}
+
+// Backends returns the names of the compiled-in backends by preference.
+func Backends() []string {
+	names := make([]string, len(backends))
+	for i, b := range backends {
+		names[i] = b.name()
+	}
+	return names
+}
+
+// Config configures a Player. Zero values select the defaults.
+type Config struct {
+	// Backend selects "portaudio" or "alsa". Defaults to the first one
+	// built in.
+	Backend string
+	// Device is the ID or name of the output device, as accepted by
+	// capture.FindDevice. Defaults to the system default output.
+	Device string
+	// Format is the format the device is opened in; audio in others is
+	// converted. Defaults to 48kHz mono, which every device plays.
+	Format audio.Format
+	// FrameDuration is how much audio is handed to the device at a time,
+	// and so how late a stop can be heard. Defaults to
+	// audio.FrameDuration.
+	FrameDuration time.Duration
+	// OnPlay, if set, is called with every frame as it is handed to the
+	// device, volume applied, e.g. to feed an echo canceller. It must not
+	// keep the frame's samples.
+	OnPlay func(audio.Frame)
+}
+
+func (c *Config) setDefaults() error {
+	if c.Format == (audio.Format{}) {
+		c.Format = audio.Format{SampleRate: 48000, Channels: 1}
+	}
+	if c.FrameDuration == 0 {
+		c.FrameDuration = audio.FrameDuration
+	}
+	switch {
+	case c.Format.SampleRate <= 0 || c.Format.Channels <= 0:
+		return fmt.Errorf("playback: bad format %+v", c.Format)
+	case c.Format.Samples(c.FrameDuration) == 0:
+		return fmt.Errorf("playback: frame duration %v too short", c.FrameDuration)
+	}
+	return nil
+}
+
+// Player plays audio on an output device. It is safe for concurrent use.
+type Player struct {
+	cfg     Config
+	backend backend
+	id      string // resolved Config.Device
+	volume  atomic.Uint64
+	wake    chan struct{} // signalled when an item is queued, under mu
+	done    chan struct{} // closed when the pump returns
+
+	mu     sync.Mutex
+	format audio.Format // of the device
+	queue  []*Item
+	cur    *Item
+	closed bool
+
+	out output // owned by the pump; nil while the device is lost
+}
+
+// Open opens the output device and starts the Player, with nothing queued
+// and the volume at 1.
+func Open(cfg Config) (*Player, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	b, err := lookup(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+	p := &Player{cfg: cfg, backend: b, id: cfg.Device, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	if p.id != "" {
+		// A device the backend does not list may still be openable by ID,
+		// for instance an ALSA PCM definition.
+		if d, err := capture.FindDevice(p.id, true); err == nil && d.Backend == b.name() {
+			p.id = d.ID
+		}
+	}
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+	p.volume.Store(math.Float64bits(1))
+	go p.pump()
+	return p, nil
+}
+
+// open (re)opens the device.
+func (p *Player) open() error {
+	out, f, err := p.backend.open(p.id, p.cfg.Format, p.cfg.FrameDuration)
+	if err != nil {
+		return fmt.Errorf("playback: %s: %w", p.backend.name(), err)
+	}
+	p.mu.Lock()
+	p.out, p.format = out, f
+	p.mu.Unlock()
+	return nil
+}
+
+// Format returns the format the device plays in.
+func (p *Player) Format() audio.Format {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.format
+}
+
+// Volume returns the gain applied to the audio played, in [0, 1].
+func (p *Player) Volume() float64 { return math.Float64frombits(p.volume.Load()) }
+
+// SetVolume sets the gain applied to the audio played from now on, clamped
+// to [0, 1]: 0 mutes, 1 plays the audio as it is.
+func (p *Player) SetVolume(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	p.volume.Store(math.Float64bits(min(max(v, 0), 1)))
+}
+
+// Item is audio queued on a Player.
+type Item struct {
+	p    *Player
+	r    audio.Reader
+	stop atomic.Bool
+	done chan struct{}
+
+	mu  sync.Mutex
+	err error
+	fns []func(error)
+}
+
+// Play queues the audio of r, to be played once what was queued before it
+// has. r is read as it plays, so it may be a synthesis stream still being
+// synthesized. If r is an io.Closer, it is closed once played or stopped.
+func (p *Player) Play(r audio.Reader) *Item {
+	it := &Item{p: p, r: r, done: make(chan struct{})}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		it.finish(ErrClosed)
+		return it
+	}
+	p.queue = append(p.queue, it)
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	p.mu.Unlock()
+	return it
+}
+
+// Stop stops the item playing and discards every one queued.
+func (p *Player) Stop() {
+	p.mu.Lock()
+	queued, cur := p.queue, p.cur
+	p.queue = nil
+	p.mu.Unlock()
+	for _, it := range queued {
+		it.discard(ErrStopped)
+	}
+	if cur != nil {
+		cur.interrupt()
+	}
+}
+
+// Playing reports whether an item is playing or queued.
+func (p *Player) Playing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cur != nil || len(p.queue) > 0
+}
+
+// Close stops playback, failing the items queued with ErrClosed, and
+// releases the device.
+func (p *Player) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	queued, cur := p.queue, p.cur
+	p.queue = nil
+	close(p.wake)
+	p.mu.Unlock()
+	for _, it := range queued {
+		it.discard(ErrClosed)
+	}
+	if cur != nil {
+		cur.interrupt()
+	}
+	<-p.done
+	if p.out != nil {
+		return p.out.close()
+	}
+	return nil
+}
+
+// next waits for the next item to play. It returns nil once the Player is
+// closed.
+func (p *Player) next() *Item {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil
+		}
+		if len(p.queue) > 0 {
+			it := p.queue[0]
+			p.queue = p.queue[1:]
+			p.cur = it
+			p.mu.Unlock()
+			return it
+		}
+		p.mu.Unlock()
+		if _, ok := <-p.wake; !ok {
+			return nil
+		}
+	}
+}
+
+// pump plays the queued items one after another until Close.
+func (p *Player) pump() {
+	defer close(p.done)
+	for {
+		it := p.next()
+		if it == nil {
+			return
+		}
+		err := p.play(it)
+		p.mu.Lock()
+		p.cur = nil
+		last := len(p.queue) == 0
+		p.mu.Unlock()
+		// Wait for the device to play the end of the item out before
+		// reporting it done, without leaving gaps between queued items.
+		if err == nil && last && p.out != nil {
+			if derr := p.out.drain(); derr != nil {
+				err = p.lost(derr)
+			}
+		}
+		it.finish(err)
+	}
+}
+
+// play plays one item on the device.
+func (p *Player) play(it *Item) (err error) {
+	defer func() {
+		if c, ok := it.r.(io.Closer); ok {
+			_ = c.Close()
+		}
+		if err != nil && p.out != nil {
+			// Cut short: silence what the device still holds at once.
+			_ = p.out.drop()
+		}
+	}()
+	if p.out == nil {
+		if err := p.open(); err != nil {
+			return err
+		}
+	}
+	var conv *audio.Converter
+	if f := it.r.Format(); f != p.format {
+		if conv, err = audio.NewConverter(f, p.format, audio.QualityMedium); err != nil {
+			return fmt.Errorf("playback: %w", err)
+		}
+	}
+	var buf []int16
+	for {
+		if it.stop.Load() {
+			return ErrStopped
+		}
+		fr, err := it.r.ReadFrame()
+		if it.stop.Load() {
+			return ErrStopped
+		}
+		eof := errors.Is(err, io.EOF)
+		if err != nil && !eof {
+			return err
+		}
+		frames := []audio.Frame{fr}
+		switch {
+		case conv != nil && eof:
+			frames = conv.Flush()
+		case conv != nil:
+			if frames, err = conv.Process(fr); err != nil {
+				return fmt.Errorf("playback: %w", err)
+			}
+		case eof:
+			return nil
+		}
+		for _, f := range frames {
+			if buf, err = p.write(it, f.Data, buf); err != nil {
+				return err
+			}
+		}
+		if eof {
+			return nil
+		}
+	}
+}
+
+// write hands samples to the device a frame at a time, scaled by the
+// volume, checking between frames whether it has been stopped. buf is
+// scratch space, returned for reuse.
+func (p *Player) write(it *Item, samples, buf []int16) ([]int16, error) {
+	chunk := p.format.Samples(p.cfg.FrameDuration) * p.format.Channels
+	for len(samples) > 0 {
+		if it.stop.Load() {
+			return buf, ErrStopped
+		}
+		n := min(chunk, len(samples))
+		buf = gain(buf[:0], samples[:n], p.Volume())
+		if p.cfg.OnPlay != nil {
+			p.cfg.OnPlay(audio.Frame{Format: p.format, Data: buf})
+		}
+		if err := p.out.write(buf); err != nil {
+			return buf, p.lost(err)
+		}
+		samples = samples[n:]
+	}
+	return buf, nil
+}
+
+// lost maps a device failure to the error of the item playing, closing
+// the device if it is gone so the next item opens it again.
+func (p *Player) lost(err error) error {
+	if !errors.Is(err, errLost) {
+		return fmt.Errorf("playback: %s: %w", p.backend.name(), err)
+	}
+	_ = p.out.close()
+	p.out = nil
+	return ErrDeviceLost
+}
+
+// gain appends src scaled by v to dst.
+func gain(dst, src []int16, v float64) []int16 {
+	if v == 1 {
+		return append(dst, src...)
+	}
+	for _, s := range src {
+		dst = append(dst, int16(math.Round(float64(s)*v)))
+	}
+	return dst
+}
+
+func (it *Item) interrupt() {
+	it.stop.Store(true)
+	// Closing unblocks a read waiting on synthesis.
+	if c, ok := it.r.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// discard finishes it, unplayed, with err.
+func (it *Item) discard(err error) {
+	if c, ok := it.r.(io.Closer); ok {
+		_ = c.Close()
+	}
+	it.finish(err)
+}
+
+// finish records the outcome of it and runs its callbacks.
+func (it *Item) finish(err error) {
+	it.mu.Lock()
+	it.err = err
+	fns := it.fns
+	it.fns = nil
+	close(it.done)
+	it.mu.Unlock()
+	for _, fn := range fns {
+		fn(err)
+	}
+}
+
+// Stop stops it: removed from the queue if it has not started playing,
+// cut short if it has.
+func (it *Item) Stop() {
+	p := it.p
+	p.mu.Lock()
+	queued := false
+	if i := slices.Index(p.queue, it); i >= 0 {
+		p.queue = slices.Delete(p.queue, i, i+1)
+		queued = true
+	}
+	p.mu.Unlock()
+	if queued {
+		it.discard(ErrStopped)
+		return
+	}
+	select {
+	case <-it.done:
+	default:
+		it.interrupt()
+	}
+}
+
+// Done returns a channel closed once it has been played, stopped or has
+// failed.
+func (it *Item) Done() <-chan struct{} { return it.done }
+
+// Err returns, once it is done, nil if it was played to the end,
+// ErrStopped if it was stopped, ErrClosed if its Player was closed, or what
+// it failed with, such as its reader's error or ErrDeviceLost.
+func (it *Item) Err() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.err
+}
+
+// Wait waits for it to be done and returns Err, or ctx's error if ctx is
+// done first; the item plays on.
+func (it *Item) Wait(ctx context.Context) error {
+	select {
+	case <-it.done:
+		return it.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnDone calls fn with the outcome of it once it is done, from the
+// goroutine that finishes it, or at once if it is done already.
+func (it *Item) OnDone(fn func(error)) {
+	it.mu.Lock()
+	select {
+	case <-it.done:
+		err := it.err
+		it.mu.Unlock()
+		fn(err)
+		return
+	default:
+	}
+	it.fns = append(it.fns, fn)
+	it.mu.Unlock()
+}
@@ -0,0 +1,153 @@
+//go:build cgo && portaudio
+
+package playback
+
+/*
+#cgo pkg-config: portaudio-2.0
+#include <portaudio.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+func init() { register(portAudio{}) }
+
+func paError(op string, code C.PaError) error {
+	return fmt.Errorf("%s: %s", op, C.GoString(C.Pa_GetErrorText(code)))
+}
+
+// portAudio is the PortAudio backend. Every output initialises the
+// library, which counts initialisations, and terminates it when closed,
+// so it coexists with the capture backend.
+type portAudio struct{}
+
+func (portAudio) name() string { return "portaudio" }
+
+// deviceName qualifies the device name with its host API, as package
+// capture names devices.
+func deviceName(info *C.PaDeviceInfo) string {
+	name := C.GoString(info.name)
+	if host := C.Pa_GetHostApiInfo(info.hostApi); host != nil {
+		name = C.GoString(host.name) + ": " + name
+	}
+	return name
+}
+
+// index finds the output device with the given ID.
+func index(id string) (C.PaDeviceIndex, error) {
+	if id == "" {
+		if i := C.Pa_GetDefaultOutputDevice(); i != C.paNoDevice {
+			return i, nil
+		}
+		return 0, errors.New("no default output device")
+	}
+	for i := C.PaDeviceIndex(0); i < C.PaDeviceIndex(C.Pa_GetDeviceCount()); i++ {
+		info := C.Pa_GetDeviceInfo(i)
+		if info != nil && info.maxOutputChannels > 0 && deviceName(info) == id {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no output device %q", id)
+}
+
+func (portAudio) open(id string, want audio.Format, frame time.Duration) (output, audio.Format, error) {
+	if rc := C.Pa_Initialize(); rc != C.paNoError {
+		return nil, audio.Format{}, paError("initialize", rc)
+	}
+	fail := func(err error) (output, audio.Format, error) {
+		C.Pa_Terminate()
+		return nil, audio.Format{}, err
+	}
+	dev, err := index(id)
+	if err != nil {
+		return fail(err)
+	}
+	info := C.Pa_GetDeviceInfo(dev)
+	f := audio.Format{SampleRate: want.SampleRate, Channels: min(want.Channels, int(info.maxOutputChannels))}
+	params := C.PaStreamParameters{
+		device:           dev,
+		channelCount:     C.int(f.Channels),
+		sampleFormat:     C.paInt16,
+		suggestedLatency: info.defaultLowOutputLatency,
+	}
+	// Not every device resamples; fall back to its own rate.
+	if C.Pa_IsFormatSupported(nil, &params, C.double(f.SampleRate)) != C.paFormatIsSupported {
+		f.SampleRate = int(info.defaultSampleRate)
+	}
+	var stream unsafe.Pointer
+	rc := C.Pa_OpenStream(&stream, nil, &params, C.double(f.SampleRate),
+		C.ulong(f.Samples(frame)), C.paNoFlag, nil, nil)
+	if rc != C.paNoError {
+		return fail(paError("open stream", rc))
+	}
+	if rc := C.Pa_StartStream(stream); rc != C.paNoError {
+		C.Pa_CloseStream(stream)
+		return fail(paError("start stream", rc))
+	}
+	return &paOutput{stream: stream, channels: f.Channels}, f, nil
+}
+
+type paOutput struct {
+	stream   unsafe.Pointer
+	channels int
+}
+
+func (out *paOutput) write(buf []int16) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	rc := C.Pa_WriteStream(out.stream, unsafe.Pointer(&buf[0]), C.ulong(len(buf)/out.channels))
+	switch rc {
+	case C.paNoError, C.paOutputUnderflowed:
+		// An underflow played a moment of silence, but the stream goes on.
+		return nil
+	case C.paDeviceUnavailable, C.paUnanticipatedHostError, C.paTimedOut,
+		C.paStreamIsStopped, C.paBadStreamPtr:
+		return errLost
+	}
+	return paError("write", rc)
+}
+
+// drain stops the stream, which plays out its buffers first, and starts it
+// again.
+func (out *paOutput) drain() error {
+	if rc := C.Pa_StopStream(out.stream); rc != C.paNoError {
+		return paError("stop stream", rc)
+	}
+	if rc := C.Pa_StartStream(out.stream); rc != C.paNoError {
+		return paError("start stream", rc)
+	}
+	return nil
+}
+
+// drop aborts the stream, discarding its buffers, and starts it again.
+func (out *paOutput) drop() error {
+	if rc := C.Pa_AbortStream(out.stream); rc != C.paNoError {
+		return paError("abort stream", rc)
+	}
+	if rc := C.Pa_StartStream(out.stream); rc != C.paNoError {
+		return paError("start stream", rc)
+	}
+	return nil
+}
+
+func (out *paOutput) close() error {
+	if out.stream == nil {
+		return nil
+	}
+	C.Pa_AbortStream(out.stream)
+	rc := C.Pa_CloseStream(out.stream)
+	out.stream = nil
+	C.Pa_Terminate()
+	if rc != C.paNoError {
+		return paError("close stream", rc)
+	}
+	return nil
+}
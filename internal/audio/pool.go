@@ -0,0 +1,59 @@
+package audio
+
+import "sync"
+
+// The frame pool recycles sample buffers so the streaming path does not
+// allocate a new one per chunk. Buffers travel in holders, themselves
+// pooled, so that handing a slice back does not allocate either.
+var (
+	samplePool = sync.Pool{New: func() any { return new([]int16) }}
+	holderPool = sync.Pool{New: func() any { return new([]int16) }}
+)
+
+// GetSamples returns a buffer of n samples from the frame pool. Its
+// contents are undefined.
+func GetSamples(n int) []int16 {
+	h := samplePool.Get().(*[]int16)
+	s := *h
+	*h = nil
+	holderPool.Put(h)
+	if cap(s) < n {
+		return make([]int16, n)
+	}
+	return s[:n]
+}
+
+// PutSamples hands s back to the frame pool. s must not be used afterwards.
+func PutSamples(s []int16) {
+	if cap(s) == 0 {
+		return
+	}
+	h := holderPool.Get().(*[]int16)
+	*h = s[:0]
+	samplePool.Put(h)
+}
+
+// Clone returns a copy of f whose samples come from the frame pool.
+func (f Frame) Clone() Frame {
+	c := f
+	c.Data = GetSamples(len(f.Data))
+	copy(c.Data, f.Data)
+	return c
+}
+
+// Channel returns channel i of f as a mono frame whose samples come from
+// the frame pool.
+func (f Frame) Channel(i int) Frame {
+	c := f
+	c.Format.Channels = 1
+	c.Data = GetSamples(f.Len())
+	for j := range c.Data {
+		c.Data[j] = f.Data[j*f.Format.Channels+i]
+	}
+	return c
+}
+
+// Release hands f's samples back to the frame pool. Only the owner of a
+// frame may release it, once nothing refers to its samples any more;
+// releasing is optional, as unreleased buffers are garbage collected.
+func (f Frame) Release() { PutSamples(f.Data) }
@@ -0,0 +1,49 @@
+package audio
+
+import "testing"
+
+// BenchmarkDecodePCM16 compares decoding every chunk into a new buffer
+// with decoding into buffers recycled through the frame pool.
+func BenchmarkDecodePCM16(b *testing.B) {
+	chunk := make([]byte, 640) // 20ms at 16kHz
+	b.Run("alloc", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			fr := Frame{Data: DecodePCM16(nil, chunk)}
+			sink = fr.Data
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			fr := Frame{Data: DecodePCM16(GetSamples(len(chunk)/2), chunk)}
+			sink = fr.Data
+			fr.Release()
+		}
+	})
+}
+
+// BenchmarkFramePool measures taking frames from the pool and handing
+// them back, as stages clone the frames they keep, from as many
+// goroutines as there are streams.
+func BenchmarkFramePool(b *testing.B) {
+	fr := Frame{Format: Format{SampleRate: 16000, Channels: 1}, Data: make([]int16, 320)}
+	b.Run("clone", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			c := fr.Clone()
+			sink = c.Data
+			c.Release()
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				fr.Clone().Release()
+			}
+		})
+	})
+}
+
+var sink []int16
@@ -0,0 +1,297 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Quality trades resampling accuracy for CPU time.
+type Quality int
+
+const (
+	// QualityMedium keeps aliasing below about -80 dB; it is the default.
+	QualityMedium Quality = iota
+	// QualityLow is cheaper, for constrained devices.
+	QualityLow
+	// QualityHigh keeps aliasing below about -100 dB.
+	QualityHigh
+)
+
+// Kernel parameters per quality: zero crossings of the windowed sinc on each
+// side, the Kaiser window beta, and the passband edge as a fraction of the
+// output Nyquist frequency.
+var qualities = map[Quality]struct {
+	zeros   int
+	beta    float64
+	rolloff float64
+}{
+	QualityLow:    {8, 6, 0.90},
+	QualityMedium: {16, 8, 0.94},
+	QualityHigh:   {32, 10, 0.97},
+}
+
+// maxKernel bounds the precomputed polyphase table, which grows with the
+// numerator of the reduced rate ratio.
+const maxKernel = 1 << 22
+
+// Converter is a Stage that converts audio from one format to another. The
+// sample rate is changed with a band-limited (Kaiser-windowed sinc)
+// polyphase resampler, the same design as soxr and libsamplerate's sinc
+// converters; channels are down-mixed by averaging or up-mixed by copying
+// a mono source.
+//
+// The resampler keeps a short history across frames, so one Converter
+// must only see one stream. Flush returns the tail at the end of a stream.
+type Converter struct {
+	from, to Format
+
+	up, down int         // rate ratio to.SampleRate/from.SampleRate = up/down
+	taps     int         // kernel length per phase
+	kernel   [][]float64 // kernel[phase][tap]
+
+	hist   [][]float64 // per-channel input, hist[c][0] is sample base
+	base   int64       // absolute input index of hist[c][0]
+	out    int64       // absolute index of the next output sample
+	origin time.Duration
+	begun  bool
+
+	buf  []int16  // samples of the last output frame, reused
+	outs [1]Frame // holds the last output frame, reused
+}
+
+// NewConverter creates a converter from one format to another.
+func NewConverter(from, to Format, q Quality) (*Converter, error) {
+	if from.SampleRate <= 0 || to.SampleRate <= 0 {
+		return nil, fmt.Errorf("audio: convert %+v to %+v: sample rates must be positive", from, to)
+	}
+	if from.Channels != to.Channels && from.Channels != 1 && to.Channels != 1 {
+		return nil, fmt.Errorf("audio: cannot convert %d channels to %d", from.Channels, to.Channels)
+	}
+	params, ok := qualities[q]
+	if !ok {
+		return nil, fmt.Errorf("audio: unknown resampler quality %d", q)
+	}
+	g := gcd(from.SampleRate, to.SampleRate)
+	c := &Converter{from: from, to: to, up: to.SampleRate / g, down: from.SampleRate / g}
+
+	// The anti-aliasing cutoff, relative to the input Nyquist frequency,
+	// drops with the output rate when downsampling.
+	cutoff := params.rolloff * min(1, float64(c.up)/float64(c.down))
+	half := int(math.Ceil(float64(params.zeros) / cutoff))
+	c.taps = 2 * half
+	if c.up != c.down {
+		if c.up*c.taps > maxKernel {
+			return nil, fmt.Errorf("audio: resampling %d Hz to %d Hz needs too large a kernel", from.SampleRate, to.SampleRate)
+		}
+		c.kernel = make([][]float64, c.up)
+		for p := range c.kernel {
+			frac := float64(p) / float64(c.up)
+			row := make([]float64, c.taps)
+			for m := range row {
+				// Tap m weighs input sample floor(t) - half + 1 + m.
+				x := float64(m-half+1) - frac
+				row[m] = cutoff * sinc(cutoff*x) * kaiser(x/float64(half), params.beta)
+			}
+			c.kernel[p] = row
+		}
+	}
+	c.reset()
+	return c, nil
+}
+
+func (c *Converter) reset() {
+	// Pad with silence so the first outputs see a full kernel.
+	pad := c.taps/2 - 1
+	c.hist = make([][]float64, c.to.Channels)
+	for ch := range c.hist {
+		c.hist[ch] = make([]float64, pad, pad+4096)
+	}
+	c.base = -int64(pad)
+	c.out = 0
+	c.begun = false
+}
+
+// From returns the input format.
+func (c *Converter) From() Format { return c.from }
+
+// To returns the output format.
+func (c *Converter) To() Format { return c.to }
+
+// Process converts fr. Because of the resampler's look-ahead the output
+// trails the input by half a kernel; the frame may even be empty. The
+// output is only valid until the next call to Process or Flush.
+func (c *Converter) Process(fr Frame) ([]Frame, error) {
+	if fr.Format != c.from {
+		return nil, fmt.Errorf("audio: converter expects %+v, got %+v", c.from, fr.Format)
+	}
+	if !c.begun {
+		c.origin, c.begun = fr.Offset, true
+	}
+	c.push(fr)
+	if out := c.drain(); out.Len() > 0 {
+		c.outs[0] = out
+		return c.outs[:], nil
+	}
+	return nil, nil
+}
+
+// Flush returns the remaining output at the end of a stream and resets the
+// converter for a new one.
+func (c *Converter) Flush() []Frame {
+	if !c.begun {
+		return nil
+	}
+	// Enough silence for the kernel to move past the last input sample.
+	tail := int64(len(c.hist[0])) + c.base
+	for ch := range c.hist {
+		c.hist[ch] = append(c.hist[ch], make([]float64, c.taps)...)
+	}
+	out := c.drainUntil(tail)
+	c.reset()
+	if out.Len() > 0 {
+		c.outs[0] = out
+		return c.outs[:]
+	}
+	return nil
+}
+
+// push appends fr to the history, mixing channels as needed.
+func (c *Converter) push(fr Frame) {
+	n := fr.Len()
+	in := c.from.Channels
+	for ch := range c.hist {
+		h := c.hist[ch]
+		for i := 0; i < n; i++ {
+			var v float64
+			switch {
+			case in == c.to.Channels:
+				v = float64(fr.Data[i*in+ch])
+			case c.to.Channels == 1: // down-mix
+				for k := 0; k < in; k++ {
+					v += float64(fr.Data[i*in+k])
+				}
+				v /= float64(in)
+			default: // mono source, up-mix
+				v = float64(fr.Data[i])
+			}
+			h = append(h, v)
+		}
+		c.hist[ch] = h
+	}
+}
+
+func (c *Converter) drain() Frame {
+	return c.drainUntil(math.MaxInt64)
+}
+
+// drainUntil produces every output sample the history allows whose input
+// position is below limit.
+func (c *Converter) drainUntil(limit int64) Frame {
+	out := Frame{Format: c.to, Data: c.buf[:0], Offset: c.origin + c.to.Duration(int(c.out))}
+	avail := c.base + int64(len(c.hist[0])) // one past the last input sample
+	half := int64(c.taps / 2)
+	for {
+		num := c.out * int64(c.down)
+		i, p := num/int64(c.up), num%int64(c.up)
+		if i >= limit || (c.kernel != nil && i+half >= avail) || (c.kernel == nil && i >= avail) {
+			break
+		}
+		for ch := range c.hist {
+			var v float64
+			if c.kernel == nil {
+				v = c.hist[ch][i-c.base]
+			} else {
+				h := c.hist[ch][i-half+1-c.base : i+half+1-c.base]
+				for m, k := range c.kernel[p] {
+					v += k * h[m]
+				}
+			}
+			out.Data = append(out.Data, clip16(v))
+		}
+		c.out++
+	}
+	// Keep only the history the next output still needs.
+	next := c.out * int64(c.down) / int64(c.up)
+	if drop := next - half + 1 - c.base; drop > 0 && c.kernel != nil {
+		c.trim(drop)
+	} else if c.kernel == nil && next > c.base {
+		c.trim(next - c.base)
+	}
+	c.buf = out.Data
+	return out
+}
+
+func (c *Converter) trim(n int64) {
+	for ch := range c.hist {
+		h := c.hist[ch]
+		c.hist[ch] = h[:copy(h, h[n:])]
+	}
+	c.base += n
+}
+
+// Resample converts a whole clip in one call.
+func Resample(fr Frame, to Format, q Quality) (Frame, error) {
+	c, err := NewConverter(fr.Format, to, q)
+	if err != nil {
+		return Frame{}, err
+	}
+	out, err := c.Process(fr)
+	if err != nil {
+		return Frame{}, err
+	}
+	res := Frame{Format: to, Offset: fr.Offset}
+	for _, f := range out {
+		res.Data = append(res.Data, f.Data...)
+	}
+	for _, f := range c.Flush() {
+		res.Data = append(res.Data, f.Data...)
+	}
+	return res, nil
+}
+
+func clip16(v float64) int16 {
+	switch {
+	case v >= math.MaxInt16:
+		return math.MaxInt16
+	case v <= math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// kaiser evaluates a Kaiser window over [-1, 1].
+func kaiser(x, beta float64) float64 {
+	if x < -1 || x > 1 {
+		return 0
+	}
+	return bessel0(beta*math.Sqrt(1-x*x)) / bessel0(beta)
+}
+
+// bessel0 is the zeroth-order modified Bessel function of the first kind.
+func bessel0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,316 @@
+// Package silence trims the silence around speech and splits long
+// recordings into utterances by voice activity, outside the pipeline, as
+// when preparing datasets.
+//
+// Speech is told from silence by a vad.Detector, whose Hangover is the
+// pause that ends an utterance. Utterances keep Pad of audio around their
+// speech, so that their first and last sounds are not clipped; the padding
+// of two utterances close together is shared, not repeated. Utterances
+// longer than MaxLength are cut at their longest pause past its half, the
+// padding of neither side reaching over the cut.
+package silence
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/vad"
+)
+
+// DefaultPad is the default Config.Pad.
+const DefaultPad = 200 * time.Millisecond
+
+// ErrNoSpeech is returned by Trim for audio without speech.
+var ErrNoSpeech = errors.New("silence: no speech")
+
+// Config configures Trim and Splitter.
+type Config struct {
+	// VAD tells speech from silence; its OnEvent is not called.
+	VAD vad.Config
+	// Pad is the audio kept before and after speech. Defaults to
+	// DefaultPad.
+	Pad time.Duration
+	// MinLength, if set, makes Splitter drop utterances with less speech,
+	// such as coughs and clicks.
+	MinLength time.Duration
+	// MaxLength, if set, bounds the speech of the utterances of Splitter:
+	// one reaching it is cut at its longest pause past MaxLength/2, or at
+	// MaxLength if it has none.
+	MaxLength time.Duration
+}
+
+func (c *Config) setDefaults() error {
+	if c.Pad == 0 {
+		c.Pad = DefaultPad
+	}
+	switch {
+	case c.Pad < 0:
+		return fmt.Errorf("silence: negative pad %v", c.Pad)
+	case c.MinLength < 0:
+		return fmt.Errorf("silence: negative min length %v", c.MinLength)
+	case c.MaxLength < 0:
+		return fmt.Errorf("silence: negative max length %v", c.MaxLength)
+	case c.MaxLength > 0 && c.MaxLength < c.MinLength:
+		return fmt.Errorf("silence: max length %v below min length %v", c.MaxLength, c.MinLength)
+	}
+	return nil
+}
+
+// detector returns a detector configured by cfg, whose events are
+// appended to *events.
+func detector(cfg vad.Config, events *[]vad.Event) (*vad.Detector, error) {
+	cfg.OnEvent = func(ev vad.Event) { *events = append(*events, ev) }
+	det, err := vad.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("silence: %w", err)
+	}
+	return det, nil
+}
+
+// Trim returns clip from Pad before its first speech to Pad after its
+// last, or ErrNoSpeech. The frame returned shares the data of clip; its
+// Offset is where it starts, on the clock of clip.
+func Trim(clip audio.Frame, cfg Config) (audio.Frame, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return audio.Frame{}, err
+	}
+	var events []vad.Event
+	det, err := detector(cfg.VAD, &events)
+	if err != nil {
+		return audio.Frame{}, err
+	}
+	f, ch, n := clip.Format, clip.Format.Channels, clip.Len()
+	first, last := -1, -1
+	for at, step := 0, f.Samples(audio.FrameDuration); at < n; at += step {
+		end := min(at+step, n)
+		fr := audio.Frame{Format: f, Data: clip.Data[at*ch : end*ch], Offset: f.Duration(at)}
+		if _, err := det.Process(fr); err != nil {
+			return audio.Frame{}, fmt.Errorf("silence: %w", err)
+		}
+		for _, ev := range events {
+			switch ev.Type {
+			case vad.SpeechStart:
+				if first < 0 {
+					first = f.Samples(ev.Offset)
+				}
+			case vad.SpeechEnd:
+				last = f.Samples(ev.Offset)
+			}
+		}
+		events = events[:0]
+	}
+	if first < 0 {
+		return audio.Frame{}, ErrNoSpeech
+	}
+	if det.Speaking() {
+		last = n
+	}
+	pad := f.Samples(cfg.Pad)
+	from, to := max(0, first-pad), min(n, last+pad)
+	return audio.Frame{Format: f, Data: clip.Data[from*ch : to*ch], Offset: clip.Offset + f.Duration(from)}, nil
+}
+
+// span is the speech of an utterance, in samples of the source.
+type span struct {
+	start, end int
+	cut        bool // end is a cut, not a pause
+	after      bool // start is a cut
+}
+
+// pause is an unvoiced run within speech.
+type pause struct{ start, end int }
+
+// Splitter splits a recording into utterances, reading it once. It is not
+// safe for concurrent use.
+type Splitter struct {
+	cfg    Config
+	src    audio.Reader
+	format audio.Format
+	det    *vad.Detector
+	events []vad.Event
+
+	pcm    []int16 // interleaved, from base
+	base   int     // samples per channel before pcm
+	pos    int     // samples per channel read
+	start  int     // of the speech under way, or -1
+	after  bool    // the speech under way follows a cut
+	voiced int     // start of the voiced run under way, or -1
+	silent int     // start of the unvoiced run under way, or -1
+	pauses []pause // of the speech under way
+	spans  []span  // ended, waiting for the audio after them
+	done   int     // end of the last utterance returned
+	eof    bool
+}
+
+// NewSplitter returns a splitter reading src.
+func NewSplitter(src audio.Reader, cfg Config) (*Splitter, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	format := src.Format()
+	if format.SampleRate <= 0 || format.Channels <= 0 {
+		return nil, fmt.Errorf("silence: bad format %+v", format)
+	}
+	s := &Splitter{cfg: cfg, src: src, format: format, start: -1, voiced: -1, silent: -1}
+	det, err := detector(cfg.VAD, &s.events)
+	if err != nil {
+		return nil, err
+	}
+	s.det = det
+	return s, nil
+}
+
+// Next returns the next utterance, or io.EOF after the last. The audio
+// returned belongs to the caller; its Offset is where it starts in the
+// source.
+func (s *Splitter) Next() (audio.Frame, error) {
+	for {
+		if len(s.spans) > 0 {
+			sp := s.spans[0]
+			after := s.samples(s.cfg.Pad)
+			if sp.cut {
+				after = 0
+			}
+			if s.eof || s.pos >= sp.end+after {
+				s.spans = s.spans[1:]
+				if !sp.cut && !sp.after && sp.end-sp.start < s.samples(s.cfg.MinLength) {
+					continue
+				}
+				return s.emit(sp, after), nil
+			}
+		}
+		if s.eof {
+			return audio.Frame{}, io.EOF
+		}
+		if err := s.read(); err != nil {
+			return audio.Frame{}, err
+		}
+	}
+}
+
+// read appends the next frame of the source and runs the VAD over it.
+func (s *Splitter) read() error {
+	fr, err := s.src.ReadFrame()
+	if err == io.EOF {
+		s.eof = true
+		if s.start >= 0 {
+			s.spans = append(s.spans, span{start: s.start, end: s.pos, after: s.after})
+			s.start = -1
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fr.Release()
+	if fr.Format != s.format {
+		return fmt.Errorf("silence: frame in %+v from a %+v source", fr.Format, s.format)
+	}
+	s.pcm = append(s.pcm, fr.Data...)
+	at := s.pos
+	fr.Offset = s.format.Duration(at)
+	s.pos += fr.Len()
+	if _, err := s.det.Process(fr); err != nil {
+		return fmt.Errorf("silence: %w", err)
+	}
+	for _, ev := range s.events {
+		switch ev.Type {
+		case vad.SpeechStart:
+			s.start, s.after, s.pauses = s.format.Samples(ev.Offset), false, s.pauses[:0]
+		case vad.SpeechEnd:
+			s.spans = append(s.spans, span{start: s.start, end: s.format.Samples(ev.Offset), after: s.after})
+			s.start = -1
+		}
+	}
+	s.events = s.events[:0]
+	if s.det.Voiced() {
+		if s.voiced < 0 {
+			s.voiced = at
+		}
+		if s.silent >= 0 && s.start >= 0 && s.silent > s.start {
+			s.pauses = append(s.pauses, pause{s.silent, at})
+		}
+		s.silent = -1
+	} else {
+		s.voiced = -1
+		if s.silent < 0 {
+			s.silent = at
+		}
+	}
+	if s.start >= 0 && s.cfg.MaxLength > 0 && s.pos-s.start >= s.samples(s.cfg.MaxLength) {
+		s.cut()
+	}
+	s.drop()
+	return nil
+}
+
+// cut ends the speech under way, grown to MaxLength, in the middle of its
+// longest pause past its half, the latest of equal ones, or where it is.
+func (s *Splitter) cut() {
+	pauses := s.pauses
+	if s.silent > s.start {
+		pauses = append(pauses[:len(pauses):len(pauses)], pause{s.silent, s.pos})
+	}
+	at, length := s.pos, -1
+	for _, p := range pauses {
+		mid := (p.start + p.end) / 2
+		if mid < s.start+s.samples(s.cfg.MaxLength)/2 {
+			continue
+		}
+		if n := p.end - p.start; n >= length {
+			at, length = mid, n
+		}
+	}
+	s.spans = append(s.spans, span{start: s.start, end: at, cut: true, after: s.after})
+	s.start, s.after = at, true
+	i := 0
+	for i < len(s.pauses) && s.pauses[i].start < at {
+		i++
+	}
+	s.pauses = s.pauses[:copy(s.pauses, s.pauses[i:])]
+}
+
+// emit returns the utterance of sp, with after samples of audio past its
+// end.
+func (s *Splitter) emit(sp span, after int) audio.Frame {
+	before := s.samples(s.cfg.Pad)
+	if sp.after {
+		before = 0
+	}
+	to := min(s.pos, sp.end+after)
+	if s.voiced > sp.end {
+		to = min(to, s.voiced) // the padding stops at the next speech
+	}
+	from := min(max(s.done, sp.start-before, 0), to)
+	ch := s.format.Channels
+	data := make([]int16, (to-from)*ch)
+	copy(data, s.pcm[(from-s.base)*ch:(to-s.base)*ch])
+	s.done = to
+	s.drop()
+	return audio.Frame{Format: s.format, Data: data, Offset: s.format.Duration(from)}
+}
+
+// drop forgets the audio no utterance can start in any more.
+func (s *Splitter) drop() {
+	keep := s.pos
+	if s.voiced >= 0 {
+		keep = min(keep, s.voiced)
+	}
+	if s.start >= 0 {
+		keep = min(keep, s.start)
+	}
+	if len(s.spans) > 0 {
+		keep = min(keep, s.spans[0].start)
+	}
+	keep = max(keep-s.samples(s.cfg.Pad), s.done)
+	if keep > s.base {
+		ch := s.format.Channels
+		s.pcm = s.pcm[:copy(s.pcm, s.pcm[(keep-s.base)*ch:])]
+		s.base = keep
+	}
+}
+
+func (s *Splitter) samples(d time.Duration) int { return s.format.Samples(d) }
@@ -0,0 +1,95 @@
+package silence
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+var format = audio.Format{SampleRate: 16000, Channels: 1}
+
+// clip returns d of a buzz where on is set, over faint noise.
+func clip(d time.Duration, on func(time.Duration) bool) audio.Frame {
+	data := make([]int16, format.Samples(d))
+	var ph float64
+	for i := range data {
+		if on(format.Duration(i)) {
+			ph += 150.0 / 16000
+			data[i] = int16(6000 * (ph - math.Floor(ph) - 0.5))
+		} else {
+			data[i] = int16((i*7919)%61 - 30)
+		}
+	}
+	return audio.Frame{Format: format, Data: data}
+}
+
+// frames reads a clip in 20ms frames, copies the splitter releases.
+type frames struct{ rest []int16 }
+
+func (r *frames) Format() audio.Format { return format }
+
+func (r *frames) ReadFrame() (audio.Frame, error) {
+	if len(r.rest) == 0 {
+		return audio.Frame{}, io.EOF
+	}
+	n := min(len(r.rest), 320)
+	fr := audio.Frame{Format: format, Data: append([]int16(nil), r.rest[:n]...)}
+	r.rest = r.rest[n:]
+	return fr, nil
+}
+
+func TestTrimAndSplit(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	// A second of speech, a click, then five words of 800ms 200ms apart.
+	c := clip(10*time.Second, func(at time.Duration) bool {
+		return at >= ms(1000) && at < ms(2000) || at >= ms(3000) && at < ms(3040) ||
+			at >= ms(4000) && at < ms(9000) && at%time.Second < ms(800)
+	})
+	got, err := Trim(c, Config{})
+	if err != nil || got.Offset != ms(800) || got.Duration() != ms(8200) {
+		t.Errorf("trimmed to %v+%v, %v; want 800ms+8.2s", got.Offset, got.Duration(), err)
+	}
+	if _, err := Trim(clip(time.Second, func(time.Duration) bool { return false }), Config{}); !errors.Is(err, ErrNoSpeech) {
+		t.Errorf("trimming silence: got %v, want ErrNoSpeech", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		want [][2]time.Duration
+	}{
+		{"pauses", Config{}, [][2]time.Duration{{ms(800), ms(2200)}, {ms(3800), ms(9000)}}},
+		{"max length", Config{MaxLength: ms(2500)}, [][2]time.Duration{
+			{ms(800), ms(2200)}, {ms(3800), ms(5900)}, {ms(5900), ms(7900)}, {ms(7900), ms(9000)}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewSplitter(&frames{rest: c.Data}, tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var spans [][2]time.Duration
+			for {
+				fr, err := s.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				spans = append(spans, [2]time.Duration{fr.Offset, fr.Offset + fr.Duration()})
+			}
+			if len(spans) != len(tc.want) {
+				t.Fatalf("got utterances %v, want %v", spans, tc.want)
+			}
+			for i := range spans {
+				if spans[i] != tc.want[i] {
+					t.Fatalf("got utterances %v, want %v", spans, tc.want)
+				}
+			}
+		})
+	}
+}
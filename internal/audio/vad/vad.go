@@ -0,0 +1,315 @@
+// Package vad implements voice activity detection as an audio.Stage.
+//
+// Each frame is classified with WebRTC-style heuristics: its energy relative
+// to an adaptive noise floor, the share of energy in the speech band and the
+// spectral flatness (noise is flat, voiced speech is peaky). A small state
+// machine turns the per-frame decisions into speech-start and speech-end
+// events and gates audio so only speech (plus pre-roll) reaches the
+// recognizer. A Model, such as an ONNX one, may classify the frames in
+// place of the heuristics.
+package vad
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/dsp"
+)
+
+// Aggressiveness trades missed speech for rejected noise, like WebRTC's
+// modes 0 (least aggressive) to 3 (most aggressive).
+type Aggressiveness int
+
+// Config configures a Detector. Zero values select the defaults from
+// internal/audio/streaming_asr.md.
+type Config struct {
+	// Aggressiveness in [0, 3]. Defaults to 2.
+	Aggressiveness Aggressiveness
+	// MinSpeech is the voiced run needed to declare speech. Defaults to 120ms.
+	MinSpeech time.Duration
+	// Hangover is the silence needed to end speech. Defaults to 500ms.
+	Hangover time.Duration
+	// PreRoll is the audio kept from before speech start so the first
+	// phoneme is not clipped. Defaults to 300ms.
+	PreRoll time.Duration
+	// OnEvent, if set, is called synchronously for every transition.
+	OnEvent func(Event)
+	// Model, if set, classifies frames with a trained model rather than
+	// the heuristics, Aggressiveness being ignored.
+	Model Model
+}
+
+// Model classifies speech with a trained model.
+type Model interface {
+	// NewClassifier returns a classifier of one stream of audio in format.
+	NewClassifier(format audio.Format) (Classifier, error)
+}
+
+// Classifier classifies the frames of one stream.
+type Classifier interface {
+	// Speech reports whether fr is speech.
+	Speech(fr audio.Frame) (bool, error)
+}
+
+func (c *Config) setDefaults() error {
+	if c.Aggressiveness < 0 || c.Aggressiveness > 3 {
+		return fmt.Errorf("vad: aggressiveness %d out of range [0, 3]", c.Aggressiveness)
+	}
+	if c.MinSpeech == 0 {
+		c.MinSpeech = 120 * time.Millisecond
+	}
+	if c.Hangover == 0 {
+		c.Hangover = 500 * time.Millisecond
+	}
+	if c.PreRoll == 0 {
+		c.PreRoll = 300 * time.Millisecond
+	}
+	return nil
+}
+
+// EventType is the kind of VAD transition.
+type EventType int
+
+const (
+	// SpeechStart fires after MinSpeech of voiced audio.
+	SpeechStart EventType = iota + 1
+	// SpeechEnd fires after Hangover of silence following speech.
+	SpeechEnd
+)
+
+func (t EventType) String() string {
+	switch t {
+	case SpeechStart:
+		return "speech-start"
+	case SpeechEnd:
+		return "speech-end"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is a VAD transition.
+type Event struct {
+	Type EventType
+	// Offset is the stream time at which speech started or ended.
+	Offset time.Duration
+}
+
+// thresholds per aggressiveness level.
+type thresholds struct {
+	snr       float64 // dB above the noise floor
+	floor     float64 // absolute minimum level, dBFS
+	bandRatio float64 // minimum share of energy in 300–4000 Hz
+	flatness  float64 // maximum spectral flatness
+}
+
+var levels = [4]thresholds{
+	{snr: 6, floor: -55, bandRatio: 0.35, flatness: 0.60},
+	{snr: 8, floor: -50, bandRatio: 0.45, flatness: 0.50},
+	{snr: 10, floor: -45, bandRatio: 0.55, flatness: 0.40},
+	{snr: 13, floor: -40, bandRatio: 0.65, flatness: 0.30},
+}
+
+// Detector gates audio on voice activity. It is not safe for concurrent use.
+type Detector struct {
+	cfg Config
+	th  thresholds
+
+	noise    float64 // noise floor estimate, dBFS
+	speaking bool
+	last     bool          // classification of the last frame processed
+	voiced   time.Duration // current voiced run
+	silent   time.Duration // current silence run while speaking
+	preroll  []audio.Frame // copies, from the frame pool
+	pending  []audio.Frame // copies of a voiced run not yet confirmed
+	lent     []audio.Frame // copies returned by the last Process
+	out      []audio.Frame
+	buf      []float64
+	spec     dsp.Spectrum
+	cls      Classifier // of Config.Model, made on the first frame
+	err      error      // of the classifier
+}
+
+var _ audio.Stage = (*Detector)(nil)
+
+// New creates a Detector.
+func New(cfg Config) (*Detector, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	return &Detector{cfg: cfg, th: levels[cfg.Aggressiveness], noise: -70}, nil
+}
+
+// Speaking reports whether the detector is inside a speech segment.
+func (d *Detector) Speaking() bool { return d.speaking }
+
+// Voiced reports whether the last frame processed was classified as speech,
+// inside a speech segment or not.
+func (d *Detector) Voiced() bool { return d.last }
+
+// Process classifies fr and returns the frames to forward: nothing during
+// silence, the pre-roll plus the voiced run when speech starts, and every
+// frame while speaking or within the hangover. SpeechEnd is emitted before
+// Process returns, after every frame of the utterance has been forwarded.
+func (d *Detector) Process(fr audio.Frame) ([]audio.Frame, error) {
+	for _, f := range d.lent {
+		f.Release()
+	}
+	d.lent = d.lent[:0]
+	voiced := d.IsSpeech(fr)
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.last = voiced
+	dur := fr.Duration()
+
+	if d.speaking {
+		if voiced {
+			d.silent = 0
+		} else {
+			d.silent += dur
+		}
+		if d.silent >= d.cfg.Hangover {
+			d.speaking = false
+			d.voiced = 0
+			d.emit(Event{Type: SpeechEnd, Offset: fr.Offset + dur - d.silent})
+			d.keep(fr.Clone())
+			return nil, nil
+		}
+		d.out = append(d.out[:0], fr)
+		return d.out, nil
+	}
+
+	if !voiced {
+		d.voiced = 0
+		for _, p := range d.pending {
+			d.keep(p)
+		}
+		d.pending = d.pending[:0]
+		d.keep(fr.Clone())
+		return nil, nil
+	}
+
+	d.voiced += dur
+	d.pending = append(d.pending, fr.Clone())
+	if d.voiced < d.cfg.MinSpeech {
+		return nil, nil
+	}
+
+	d.speaking = true
+	d.silent = 0
+	start := d.pending[0].Offset
+	d.emit(Event{Type: SpeechStart, Offset: start})
+	d.lent = append(append(d.lent, d.preroll...), d.pending...)
+	d.preroll = d.preroll[:0]
+	d.pending = d.pending[:0]
+	d.out = append(d.out[:0], d.lent...)
+	return d.out, nil
+}
+
+// IsSpeech classifies a single frame without changing the gate state,
+// other than adapting the noise floor or the state of the model.
+func (d *Detector) IsSpeech(fr audio.Frame) bool {
+	if d.cfg.Model != nil {
+		return d.classify(fr)
+	}
+	d.buf = monoFloat(d.buf, fr)
+	level := dsp.DBFS(dsp.RMS(d.buf))
+
+	// Track the noise floor: follow drops quickly, rises slowly.
+	if level < d.noise {
+		d.noise += 0.5 * (level - d.noise)
+	} else if !d.speaking {
+		d.noise += 0.02 * (level - d.noise)
+	}
+
+	if level < d.th.floor || level-d.noise < d.th.snr {
+		return false
+	}
+	ratio, flat := spectralFeatures(&d.spec, d.buf, fr.Format.SampleRate)
+	return ratio >= d.th.bandRatio || flat <= d.th.flatness
+}
+
+// classify classifies fr with the model, failing the detector on errors.
+func (d *Detector) classify(fr audio.Frame) bool {
+	if d.err != nil {
+		return false
+	}
+	if d.cls == nil {
+		if d.cls, d.err = d.cfg.Model.NewClassifier(fr.Format); d.err != nil {
+			d.err = fmt.Errorf("vad: %w", d.err)
+			return false
+		}
+	}
+	voiced, err := d.cls.Speech(fr)
+	if err != nil {
+		d.err = fmt.Errorf("vad: %w", err)
+	}
+	return voiced
+}
+
+// keep adds fr, a copy the detector owns, to the pre-roll.
+func (d *Detector) keep(fr audio.Frame) {
+	d.preroll = append(d.preroll, fr)
+	var total time.Duration
+	for i := len(d.preroll) - 1; i >= 0; i-- {
+		total += d.preroll[i].Duration()
+		if total > d.cfg.PreRoll {
+			for _, f := range d.preroll[:i+1] {
+				f.Release()
+			}
+			d.preroll = append(d.preroll[:0], d.preroll[i+1:]...)
+			return
+		}
+	}
+}
+
+func (d *Detector) emit(ev Event) {
+	if d.cfg.OnEvent != nil {
+		d.cfg.OnEvent(ev)
+	}
+}
+
+// monoFloat downmixes fr to mono float samples.
+func monoFloat(dst []float64, fr audio.Frame) []float64 {
+	ch := fr.Format.Channels
+	if ch <= 1 {
+		return dsp.Float(dst, fr.Data)
+	}
+	n := fr.Len()
+	if cap(dst) < n {
+		dst = make([]float64, n)
+	}
+	dst = dst[:n]
+	for i := range dst {
+		var sum float64
+		for c := 0; c < ch; c++ {
+			sum += float64(fr.Data[i*ch+c])
+		}
+		dst[i] = sum / float64(ch) / 32768
+	}
+	return dst
+}
+
+// spectralFeatures returns the share of energy in the 300–4000 Hz speech
+// band and the spectral flatness of x.
+func spectralFeatures(spec *dsp.Spectrum, x []float64, rate int) (bandRatio, flatness float64) {
+	ps := spec.Power(x)
+	binHz := float64(rate) / float64(2*(len(ps)-1))
+	var total, band, logSum float64
+	for k, p := range ps[1:] {
+		f := float64(k+1) * binHz
+		total += p
+		if f >= 300 && f <= 4000 {
+			band += p
+		}
+		logSum += math.Log(p + 1e-12)
+	}
+	n := float64(len(ps) - 1)
+	if total == 0 {
+		return 0, 1
+	}
+	flatness = math.Exp(logSum/n) / (total / n)
+	return band / total, flatness
+}
@@ -0,0 +1,48 @@
+package vad
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// BenchmarkDetector measures classifying and gating 20ms frames of 16kHz
+// audio with the heuristics, in silence and in speech.
+func BenchmarkDetector(b *testing.B) {
+	format := audio.Format{SampleRate: 16000, Channels: 1}
+	silence := make([]int16, 320)
+	for i := range silence {
+		silence[i] = int16((i*7919)%61 - 30)
+	}
+	// A buzz with the harmonics of a voice.
+	speech := make([]int16, 320)
+	for i := range speech {
+		var v float64
+		for h := 1; h <= 10; h++ {
+			v += math.Sin(2*math.Pi*150*float64(h*i)/16000) / float64(h)
+		}
+		speech[i] = int16(4000 * v)
+	}
+	for _, bc := range []struct {
+		name string
+		data []int16
+	}{{"silence", silence}, {"speech", speech}} {
+		b.Run(bc.name, func(b *testing.B) {
+			d, err := New(Config{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			fr := audio.Frame{Format: format, Data: bc.data}
+			b.SetBytes(int64(2 * len(fr.Data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := d.Process(fr); err != nil {
+					b.Fatal(err)
+				}
+				fr.Offset += audio.FrameDuration
+			}
+		})
+	}
+}
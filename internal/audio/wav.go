@@ -0,0 +1,131 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrUnsupported is returned for audio encodings the reader cannot decode.
+var ErrUnsupported = errors.New("audio: unsupported format")
+
+// WAVReader reads 16-bit PCM RIFF/WAVE streams in FrameDuration frames.
+type WAVReader struct {
+	r      io.Reader
+	format Format
+	left   int64 // bytes left in the data chunk
+	offset int   // samples per channel read so far
+	buf    []byte
+}
+
+// NewWAVReader parses the WAV header from r and positions it at the first
+// sample.
+func NewWAVReader(r io.Reader) (*WAVReader, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("read riff header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%w: not a RIFF/WAVE stream", ErrUnsupported)
+	}
+
+	w := &WAVReader{r: r}
+	var haveFmt bool
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, fmt.Errorf("read chunk header: %w", err)
+		}
+		id, size := string(hdr[0:4]), int64(binary.LittleEndian.Uint32(hdr[4:8]))
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("%w: short fmt chunk", ErrUnsupported)
+			}
+			body := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("read fmt chunk: %w", err)
+			}
+			tag := binary.LittleEndian.Uint16(body[0:2])
+			bits := binary.LittleEndian.Uint16(body[14:16])
+			if tag == 0xFFFE && size >= 26 { // WAVE_FORMAT_EXTENSIBLE
+				tag = binary.LittleEndian.Uint16(body[24:26])
+			}
+			if tag != 1 || bits != 16 {
+				return nil, fmt.Errorf("%w: wav format tag %d, %d bits (want PCM16)", ErrUnsupported, tag, bits)
+			}
+			w.format = Format{
+				Channels:   int(binary.LittleEndian.Uint16(body[2:4])),
+				SampleRate: int(binary.LittleEndian.Uint32(body[4:8])),
+			}
+			if w.format.Channels < 1 || w.format.SampleRate < 1 {
+				return nil, fmt.Errorf("%w: %d channels at %d Hz", ErrUnsupported, w.format.Channels, w.format.SampleRate)
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return nil, fmt.Errorf("%w: data chunk before fmt", ErrUnsupported)
+			}
+			w.left = size
+			return w, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("skip %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+// Format returns the stream format from the header.
+func (w *WAVReader) Format() Format { return w.format }
+
+// ReadFrame returns the next FrameDuration of audio. The last frame may be
+// shorter. It returns io.EOF after the data chunk is exhausted.
+func (w *WAVReader) ReadFrame() (Frame, error) {
+	if w.left <= 0 {
+		return Frame{}, io.EOF
+	}
+	size := int64(2 * w.format.Channels * w.format.Samples(FrameDuration))
+	if size > w.left {
+		size = w.left
+	}
+	if int64(cap(w.buf)) < size {
+		w.buf = make([]byte, size)
+	}
+	b := w.buf[:size]
+	n, err := io.ReadFull(w.r, b)
+	w.left -= int64(n)
+	if err != nil {
+		if n == 0 {
+			return Frame{}, io.EOF
+		}
+		w.left = 0 // truncated file: return what we got
+	}
+	n -= n % (2 * w.format.Channels)
+	fr := Frame{
+		Format: w.format,
+		Data:   DecodePCM16(GetSamples(n/2), b[:n]),
+		Offset: w.format.Duration(w.offset),
+	}
+	w.offset += fr.Len()
+	return fr, nil
+}
+
+// AppendWAV appends a RIFF/WAVE file of samples in format f to b.
+func AppendWAV(b []byte, f Format, samples []int16) []byte {
+	size := uint32(2 * len(samples))
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, 36+size)
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1) // PCM
+	b = binary.LittleEndian.AppendUint16(b, uint16(f.Channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(f.SampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(f.SampleRate*f.Channels*2))
+	b = binary.LittleEndian.AppendUint16(b, uint16(f.Channels*2))
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, size)
+	return AppendPCM16(b, samples)
+}
@@ -0,0 +1,436 @@
+// Package auth authenticates voxad clients by API key and holds every key
+// to its quotas: a request rate and a number of concurrent sessions.
+//
+// Clients present their key as a bearer token (Authorization: Bearer
+// KEY), in an X-API-Key header or, where browsers cannot set headers as for
+// WebSockets and EventSource, in the api_key query parameter. Over gRPC the
+// key goes in the authorization or x-api-key metadata. Keys are configured
+// by the hex SHA-256 of their value, so the deployment file holds no
+// secrets:
+//
+//	printf %s "$KEY" | sha256sum
+//
+// An Authenticator accounts the usage of every key; it is a
+// prometheus.Collector exporting it, and Usage returns it for the admin
+// API.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Errors reported to clients.
+var (
+	ErrUnauthenticated = errors.New("auth: missing or unknown API key")
+	ErrForbidden       = errors.New("auth: API key is not an admin key")
+	ErrRateLimited     = errors.New("auth: API key rate limit exceeded")
+	ErrSessionQuota    = errors.New("auth: API key concurrent session quota exceeded")
+	ErrNotAllowed      = errors.New("auth: not allowed for the API key")
+)
+
+// KeyConfig configures an API key.
+type KeyConfig struct {
+	// Name identifies the key, or its tenant, in usage and logs.
+	Name string
+	// SHA256 is the hex SHA-256 of the key.
+	SHA256 string
+	// Admin allows the key to use the admin API.
+	Admin bool
+	// Rate is the requests, RPCs and streams opened, per second the key
+	// may make on average. 0 is unlimited.
+	Rate float64
+	// Burst is how many requests may be made at once above Rate. Defaults
+	// to Rate, rounded up.
+	Burst int
+	// Sessions bounds the concurrent sessions of the key. 0 is unlimited.
+	Sessions int
+	// MaxPriority is the highest recognition priority the sessions of the
+	// key get, as parsed by stt.ParsePriority; sessions asking for more
+	// are lowered to it. Defaults to normal, so that only the keys
+	// configured for it may jump the queue.
+	MaxPriority string
+	// Languages lists the languages the sessions of the key may ask to be
+	// recognized in, as ISO 639-1 codes. Empty allows any.
+	Languages []string
+	// Models lists the recognizer models the sessions of the key may ask
+	// for. Empty allows none, sessions using the server's.
+	Models []string
+	// Profiles lists the server profiles the sessions of the key may ask
+	// to run on. The first is the one they run on unless they ask; empty
+	// allows none, sessions running on the server's default.
+	Profiles []string
+}
+
+// Config configures an Authenticator.
+type Config struct {
+	Keys []KeyConfig
+}
+
+// Authenticator checks API keys. It is safe for concurrent use.
+type Authenticator struct {
+	keys map[[sha256.Size]byte]*Key
+	list []*Key // by name
+
+	mu              sync.Mutex
+	unauthenticated int64
+}
+
+var _ prometheus.Collector = (*Authenticator)(nil)
+
+// New validates cfg.
+func New(cfg Config) (*Authenticator, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("auth: no API keys")
+	}
+	a := &Authenticator{keys: map[[sha256.Size]byte]*Key{}}
+	names := map[string]bool{}
+	for i, kc := range cfg.Keys {
+		var sum [sha256.Size]byte
+		b, err := hex.DecodeString(kc.SHA256)
+		switch {
+		case kc.Name == "":
+			return nil, fmt.Errorf("auth: key %d: no name", i)
+		case names[kc.Name]:
+			return nil, fmt.Errorf("auth: key %q defined twice", kc.Name)
+		case err != nil || len(b) != len(sum):
+			return nil, fmt.Errorf("auth: key %q: sha256 is not a hex SHA-256", kc.Name)
+		case kc.Rate < 0 || kc.Burst < 0 || kc.Sessions < 0:
+			return nil, fmt.Errorf("auth: key %q: negative quota", kc.Name)
+		case slices.Contains(kc.Languages, "") || slices.Contains(kc.Models, "") || slices.Contains(kc.Profiles, ""):
+			return nil, fmt.Errorf("auth: key %q: empty language, model or profile", kc.Name)
+		}
+		prio, err := stt.ParsePriority(kc.MaxPriority)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q: %w", kc.Name, err)
+		}
+		copy(sum[:], b)
+		if _, dup := a.keys[sum]; dup {
+			return nil, fmt.Errorf("auth: key %q has the SHA-256 of another key", kc.Name)
+		}
+		if kc.Burst == 0 {
+			kc.Burst = max(1, int(math.Ceil(kc.Rate)))
+		}
+		names[kc.Name] = true
+		k := &Key{cfg: kc, prio: prio, tokens: float64(kc.Burst), last: time.Now()}
+		a.keys[sum] = k
+		a.list = append(a.list, k)
+	}
+	sort.Slice(a.list, func(i, j int) bool { return a.list[i].cfg.Name < a.list[j].cfg.Name })
+	return a, nil
+}
+
+// Authenticate returns the key whose value is secret, nil if there is
+// none.
+func (a *Authenticator) Authenticate(secret string) *Key {
+	if secret == "" {
+		return nil
+	}
+	// Looking up the digest leaks nothing about the keys through timing.
+	return a.keys[sha256.Sum256([]byte(secret))]
+}
+
+// authorize authenticates secret and takes a request from its rate. On
+// ErrRateLimited it also returns when to try again.
+func (a *Authenticator) authorize(secret string) (*Key, time.Duration, error) {
+	k := a.Authenticate(secret)
+	if k == nil {
+		a.mu.Lock()
+		a.unauthenticated++
+		a.mu.Unlock()
+		return nil, 0, ErrUnauthenticated
+	}
+	if wait := k.allow(); wait > 0 {
+		return nil, wait, ErrRateLimited
+	}
+	return k, 0, nil
+}
+
+// Key is an authenticated API key.
+type Key struct {
+	cfg  KeyConfig
+	prio stt.Priority
+
+	mu     sync.Mutex
+	tokens float64 // of the rate's bucket
+	last   time.Time
+	usage  Usage
+}
+
+// Name returns the name of the key.
+func (k *Key) Name() string { return k.cfg.Name }
+
+// Admin reports whether the key may use the admin API.
+func (k *Key) Admin() bool { return k.cfg.Admin }
+
+// Priority returns the priority a session of the key asking for p gets.
+func (k *Key) Priority(p stt.Priority) stt.Priority { return min(p, k.prio) }
+
+// AllowsLanguage reports whether the sessions of k may ask for lang.
+func (k *Key) AllowsLanguage(lang string) bool {
+	return len(k.cfg.Languages) == 0 || slices.ContainsFunc(k.cfg.Languages, func(l string) bool {
+		return strings.EqualFold(l, lang)
+	})
+}
+
+// AllowsModel reports whether the sessions of k may ask for model.
+func (k *Key) AllowsModel(model string) bool { return slices.Contains(k.cfg.Models, model) }
+
+// AllowsProfile reports whether the sessions of k may ask for profile.
+func (k *Key) AllowsProfile(profile string) bool { return slices.Contains(k.cfg.Profiles, profile) }
+
+// DefaultProfile returns the profile the sessions of k run on unless they
+// ask for another, empty for the server's default.
+func (k *Key) DefaultProfile() string {
+	if len(k.cfg.Profiles) == 0 {
+		return ""
+	}
+	return k.cfg.Profiles[0]
+}
+
+// allow takes a request from the rate of k, returning 0, or how long until
+// one is available if the bucket is empty.
+func (k *Key) allow() time.Duration {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if r := k.cfg.Rate; r > 0 {
+		now := time.Now()
+		k.tokens = min(float64(k.cfg.Burst), k.tokens+now.Sub(k.last).Seconds()*r)
+		k.last = now
+		if k.tokens < 1 {
+			k.usage.Throttled++
+			return time.Duration((1 - k.tokens) / r * float64(time.Second))
+		}
+		k.tokens--
+	}
+	k.usage.Requests++
+	return 0
+}
+
+// OpenSession takes one of the concurrent sessions of k, failing with
+// ErrSessionQuota if they are all in use. Call the returned function when
+// the session ends.
+func (k *Key) OpenSession() (end func(), err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.cfg.Sessions > 0 && k.usage.Sessions >= k.cfg.Sessions {
+		k.usage.SessionsRefused++
+		return nil, ErrSessionQuota
+	}
+	k.usage.Sessions++
+	k.usage.SessionsTotal++
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			k.mu.Lock()
+			defer k.mu.Unlock()
+			k.usage.Sessions--
+			k.usage.SessionSeconds += time.Since(start).Seconds()
+		})
+	}, nil
+}
+
+// Usage is the accounting of an API key since the server started.
+type Usage struct {
+	Key   string
+	Admin bool
+	// Requests counts the requests, RPCs and streams let through.
+	Requests int64
+	// Throttled counts the requests refused by the rate limit.
+	Throttled int64
+	// Sessions is the number of sessions open now.
+	Sessions int
+	// SessionsTotal counts the sessions opened.
+	SessionsTotal int64
+	// SessionsRefused counts the sessions refused by the quota.
+	SessionsRefused int64
+	// SessionSeconds sums the durations of the sessions ended.
+	SessionSeconds float64
+	// Limits of the key, 0 for none.
+	Rate        float64
+	Burst       int
+	MaxSessions int
+}
+
+// Usage returns the usage of every key, by name.
+func (a *Authenticator) Usage() []Usage {
+	out := make([]Usage, 0, len(a.list))
+	for _, k := range a.list {
+		k.mu.Lock()
+		u := k.usage
+		k.mu.Unlock()
+		u.Key, u.Admin = k.cfg.Name, k.cfg.Admin
+		u.Rate, u.MaxSessions = k.cfg.Rate, k.cfg.Sessions
+		if u.Rate > 0 {
+			u.Burst = k.cfg.Burst
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+// Metric descriptions.
+var (
+	requestsDesc = prometheus.NewDesc("voxa_auth_requests_total",
+		"Requests by API key, let through (ok) or refused by its rate limit (throttled).", []string{"key", "result"}, nil)
+	unauthenticatedDesc = prometheus.NewDesc("voxa_auth_unauthenticated_total",
+		"Requests refused for a missing or unknown API key.", nil, nil)
+	sessionsDesc = prometheus.NewDesc("voxa_auth_sessions_active",
+		"Sessions open by API key.", []string{"key"}, nil)
+	sessionsTotalDesc = prometheus.NewDesc("voxa_auth_sessions_total",
+		"Sessions by API key, opened (ok) or refused by its quota (refused).", []string{"key", "result"}, nil)
+	sessionSecondsDesc = prometheus.NewDesc("voxa_auth_session_seconds_total",
+		"Duration of the ended sessions by API key.", []string{"key"}, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (a *Authenticator) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{requestsDesc, unauthenticatedDesc, sessionsDesc, sessionsTotalDesc, sessionSecondsDesc} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (a *Authenticator) Collect(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	unauthenticated := a.unauthenticated
+	a.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(unauthenticatedDesc, prometheus.CounterValue, float64(unauthenticated))
+	for _, u := range a.Usage() {
+		ch <- prometheus.MustNewConstMetric(requestsDesc, prometheus.CounterValue, float64(u.Requests), u.Key, "ok")
+		ch <- prometheus.MustNewConstMetric(requestsDesc, prometheus.CounterValue, float64(u.Throttled), u.Key, "throttled")
+		ch <- prometheus.MustNewConstMetric(sessionsDesc, prometheus.GaugeValue, float64(u.Sessions), u.Key)
+		ch <- prometheus.MustNewConstMetric(sessionsTotalDesc, prometheus.CounterValue, float64(u.SessionsTotal), u.Key, "ok")
+		ch <- prometheus.MustNewConstMetric(sessionsTotalDesc, prometheus.CounterValue, float64(u.SessionsRefused), u.Key, "refused")
+		ch <- prometheus.MustNewConstMetric(sessionSecondsDesc, prometheus.CounterValue, u.SessionSeconds, u.Key)
+	}
+}
+
+type keyContext struct{}
+
+// NewContext returns ctx carrying the key of its request.
+func NewContext(ctx context.Context, k *Key) context.Context {
+	return context.WithValue(ctx, keyContext{}, k)
+}
+
+// FromContext returns the key of the request of ctx, nil if it was not
+// authenticated.
+func FromContext(ctx context.Context) *Key {
+	k, _ := ctx.Value(keyContext{}).(*Key)
+	return k
+}
+
+// HTTP authenticates the requests of next, which finds their key with
+// FromContext.
+func (a *Authenticator) HTTP(next http.Handler) http.Handler {
+	return a.http(next, false)
+}
+
+// AdminHTTP is HTTP for the handlers only admin keys may use.
+func (a *Authenticator) AdminHTTP(next http.Handler) http.Handler {
+	return a.http(next, true)
+}
+
+func (a *Authenticator) http(next http.Handler, admin bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k, wait, err := a.authorize(httpKey(r))
+		switch {
+		case errors.Is(err, ErrUnauthenticated):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="voxad"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case err != nil:
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		case admin && !k.Admin():
+			http.Error(w, ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), k)))
+	})
+}
+
+func httpKey(r *http.Request) string {
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	if v := r.Header.Get("X-API-Key"); v != "" {
+		return v
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// UnaryInterceptor authenticates unary RPCs.
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.rpc(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor authenticates streaming RPCs.
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.rpc(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &keyStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *Authenticator) rpc(ctx context.Context) (context.Context, error) {
+	k, _, err := a.authorize(rpcKey(ctx))
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return NewContext(ctx, k), nil
+}
+
+func rpcKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if v, ok := strings.CutPrefix(v, "Bearer "); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// keyStream is a server stream whose context carries its key.
+type keyStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *keyStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAuthenticator(t *testing.T, keys ...KeyConfig) *Authenticator {
+	t.Helper()
+	for i := range keys {
+		keys[i].SHA256 = hash(keys[i].Name + "-secret")
+	}
+	a, err := New(Config{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// elapse moves the rate of k d into the future.
+func elapse(k *Key, d time.Duration) {
+	k.mu.Lock()
+	k.last = k.last.Add(-d)
+	k.mu.Unlock()
+}
+
+func TestRate(t *testing.T) {
+	a := newAuthenticator(t, KeyConfig{Name: "a", Rate: 2, Burst: 3}, KeyConfig{Name: "b", Rate: 2})
+	allowed := func(name string, n int) {
+		t.Helper()
+		for i := range n {
+			if _, wait, err := a.authorize(name + "-secret"); err != nil {
+				t.Fatalf("%s request %d: %v (wait %v)", name, i+1, err, wait)
+			}
+		}
+	}
+	refused := func(name string, maxWait time.Duration) {
+		t.Helper()
+		k, wait, err := a.authorize(name + "-secret")
+		if !errors.Is(err, ErrRateLimited) || k != nil {
+			t.Fatalf("%s over its rate: %v, %v", name, k, err)
+		}
+		if wait <= 0 || wait > maxWait {
+			t.Errorf("%s over its rate: wait %v, want up to %v", name, wait, maxWait)
+		}
+	}
+	allowed("a", 3) // the burst
+	refused("a", 500*time.Millisecond)
+	allowed("b", 2) // its own bucket, Rate for a burst
+	refused("b", 500*time.Millisecond)
+
+	k := a.Authenticate("a-secret")
+	elapse(k, 500*time.Millisecond) // one request more
+	allowed("a", 1)
+	refused("a", 500*time.Millisecond)
+	elapse(k, 400*time.Millisecond) // not quite another
+	refused("a", 100*time.Millisecond)
+	elapse(k, time.Hour) // refilled to the burst, no more
+	allowed("a", 3)
+	refused("a", 500*time.Millisecond)
+
+	u := a.Usage()
+	if u[0].Key != "a" || u[0].Requests != 7 || u[0].Throttled != 4 || u[0].Rate != 2 || u[0].Burst != 3 {
+		t.Errorf("usage of a %+v, want 7 requests, 4 throttled at 2/s, burst 3", u[0])
+	}
+	if u[1].Key != "b" || u[1].Requests != 2 || u[1].Throttled != 1 || u[1].Burst != 2 {
+		t.Errorf("usage of b %+v, want 2 requests, 1 throttled, burst 2", u[1])
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	a := newAuthenticator(t, KeyConfig{Name: "a"})
+	for i := range 1000 {
+		if _, _, err := a.authorize("a-secret"); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	for i := range 100 {
+		if _, err := a.Authenticate("a-secret").OpenSession(); err != nil {
+			t.Fatalf("session %d: %v", i+1, err)
+		}
+	}
+	if u := a.Usage()[0]; u.Burst != 0 || u.MaxSessions != 0 || u.Sessions != 100 {
+		t.Errorf("usage %+v, want no limits and 100 sessions", u)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	a := newAuthenticator(t, KeyConfig{Name: "a", Sessions: 2}, KeyConfig{Name: "b", Sessions: 1})
+	k, other := a.Authenticate("a-secret"), a.Authenticate("b-secret")
+	end1, err := k.OpenSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.OpenSession(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.OpenSession(); !errors.Is(err, ErrSessionQuota) {
+		t.Fatalf("third session: %v, want %v", err, ErrSessionQuota)
+	}
+	if _, err := other.OpenSession(); err != nil {
+		t.Fatalf("session of another key: %v", err)
+	}
+	end1()
+	end1() // once only
+	if _, err := k.OpenSession(); err != nil {
+		t.Fatalf("session after one ended: %v", err)
+	}
+	if _, err := k.OpenSession(); !errors.Is(err, ErrSessionQuota) {
+		t.Fatalf("session over the quota again: %v, want %v", err, ErrSessionQuota)
+	}
+	u := a.Usage()[0]
+	if u.Sessions != 2 || u.SessionsTotal != 3 || u.SessionsRefused != 2 || u.MaxSessions != 2 {
+		t.Errorf("usage %+v, want 2 open of 2, 3 opened, 2 refused", u)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	// A rate too slow to refill during the test.
+	a := newAuthenticator(t, KeyConfig{Name: "a", Rate: 1e-6, Burst: 10, Sessions: 5})
+	k := a.Authenticate("a-secret")
+	// race runs fn on 100 goroutines at once.
+	race := func(fn func(i int)) {
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for i := range 100 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				fn(i)
+			}()
+		}
+		close(start)
+		wg.Wait()
+	}
+	count := func(errs []error, target error) (ok, refused int) {
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				ok++
+			case errors.Is(err, target):
+				refused++
+			default:
+				t.Error(err)
+			}
+		}
+		return ok, refused
+	}
+
+	errs := make([]error, 100)
+	race(func(i int) { _, _, errs[i] = a.authorize("a-secret") })
+	if ok, refused := count(errs, ErrRateLimited); ok != 10 || refused != 90 {
+		t.Errorf("%d requests let through, %d throttled; want 10, 90", ok, refused)
+	}
+
+	ends := make([]func(), 100)
+	race(func(i int) { ends[i], errs[i] = k.OpenSession() })
+	if ok, refused := count(errs, ErrSessionQuota); ok != 5 || refused != 95 {
+		t.Errorf("%d sessions opened, %d refused; want 5, 95", ok, refused)
+	}
+	var opened []func()
+	for _, end := range ends {
+		if end != nil {
+			opened = append(opened, end)
+		}
+	}
+	race(func(i int) {
+		if i < 2*len(opened) {
+			opened[i/2]() // twice each
+		}
+	})
+
+	if u := a.Usage()[0]; u.Requests != 10 || u.Throttled != 90 || u.Sessions != 0 || u.SessionsTotal != 5 || u.SessionsRefused != 95 {
+		t.Errorf("usage %+v, want 10 requests, 90 throttled, 5 sessions opened and ended, 95 refused", u)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	a := newAuthenticator(t, KeyConfig{Name: "admin", Admin: true}, KeyConfig{Name: "user", Rate: 1, Burst: 1})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).Name()))
+	})
+	for _, tc := range []struct {
+		name    string
+		admin   bool
+		request func(*http.Request)
+		status  int
+		body    string
+		header  string // the header required in the response
+	}{
+		{"bearer", false, func(r *http.Request) { r.Header.Set("Authorization", "Bearer user-secret") }, http.StatusOK, "user", ""},
+		{"rate used up", false, func(r *http.Request) { r.Header.Set("X-API-Key", "user-secret") }, http.StatusTooManyRequests,
+			ErrRateLimited.Error() + "\n", "Retry-After"},
+		{"query", false, func(r *http.Request) { r.URL.RawQuery = "api_key=admin-secret" }, http.StatusOK, "admin", ""},
+		{"no key", false, func(*http.Request) {}, http.StatusUnauthorized, ErrUnauthenticated.Error() + "\n", "WWW-Authenticate"},
+		{"unknown key", false, func(r *http.Request) { r.Header.Set("X-API-Key", "nope") }, http.StatusUnauthorized,
+			ErrUnauthenticated.Error() + "\n", "WWW-Authenticate"},
+		{"admin", true, func(r *http.Request) { r.Header.Set("X-API-Key", "admin-secret") }, http.StatusOK, "admin", ""},
+		{"not admin", true, func(r *http.Request) {
+			elapse(a.Authenticate("user-secret"), time.Second)
+			r.Header.Set("X-API-Key", "user-secret")
+		}, http.StatusForbidden, ErrForbidden.Error() + "\n", ""},
+	} {
+		h := a.HTTP(ok)
+		if tc.admin {
+			h = a.AdminHTTP(ok)
+		}
+		r := httptest.NewRequest(http.MethodGet, "/v1/sessions", nil)
+		tc.request(r)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf("%s: %d %q, want %d %q", tc.name, w.Code, w.Body.String(), tc.status, tc.body)
+		}
+		if tc.header != "" && w.Header().Get(tc.header) == "" {
+			t.Errorf("%s: no %s header", tc.name, tc.header)
+		}
+	}
+	if got := a.Usage()[1]; got.Requests != 2 || got.Throttled != 1 {
+		t.Errorf("usage of user %+v, want 2 requests, 1 throttled", got)
+	}
+}
+
+func TestRPC(t *testing.T) {
+	a := newAuthenticator(t, KeyConfig{Name: "a", Rate: 1, Burst: 1})
+	for _, tc := range []struct {
+		name string
+		md   metadata.MD
+		code codes.Code
+	}{
+		{"bearer", metadata.Pairs("authorization", "Bearer a-secret"), codes.OK},
+		{"rate used up", metadata.Pairs("x-api-key", "a-secret"), codes.ResourceExhausted},
+		{"no key", nil, codes.Unauthenticated},
+		{"unknown key", metadata.Pairs("x-api-key", "nope"), codes.Unauthenticated},
+	} {
+		ctx, err := a.rpc(metadata.NewIncomingContext(context.Background(), tc.md))
+		if status.Code(err) != tc.code {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.code)
+		}
+		if err == nil && FromContext(ctx).Name() != "a" {
+			t.Errorf("%s: key %v in the context", tc.name, FromContext(ctx))
+		}
+	}
+}
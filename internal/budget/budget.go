@@ -0,0 +1,158 @@
+// Package budget holds pipeline stages to latency budgets.
+//
+// A Tracker follows the average time a stage takes on one frame, or one
+// segment, over every stream of a pipeline. When the average goes over
+// the budget the stage is in breach for a cooldown, extended for as long
+// as it stays over, and its Action applies: the breach is only reported,
+// the stage is skipped, or new streams are refused. Skipped stages are not
+// measured, so they run again once the cooldown has passed and are judged
+// afresh. All methods are safe on a nil *Tracker, which has no budget.
+package budget
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultCooldown is the cooldown of configs that set none.
+const DefaultCooldown = 10 * time.Second
+
+// weight is the weight of a new measurement in the moving average.
+const weight = 8
+
+// Optional are the stages that may be skipped: those enriching audio or
+// transcripts that are still correct without them.
+var Optional = []string{"denoise", "agc", "prosody", "punctuation", "normalization", "sentiment", "translation"}
+
+// Action is what a breach of a budget does.
+type Action int
+
+const (
+	// Observe only counts and logs the breach.
+	Observe Action = iota
+	// Skip bypasses the stage during the breach; Optional stages only.
+	Skip
+	// Shed refuses new streams during the breach, so the streams already
+	// open recover.
+	Shed
+)
+
+var actions = []string{"observe", "skip", "shed"}
+
+func (a Action) String() string {
+	if a >= 0 && int(a) < len(actions) {
+		return actions[a]
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// ParseAction parses observe, skip or shed; empty is Observe.
+func ParseAction(s string) (Action, error) {
+	if s == "" {
+		return Observe, nil
+	}
+	if i := slices.Index(actions, s); i >= 0 {
+		return Action(i), nil
+	}
+	return 0, fmt.Errorf("budget: unknown action %q (want observe, skip or shed)", s)
+}
+
+// Config is the latency budget of a stage.
+type Config struct {
+	// Latency is the time the stage may take on average: per frame for
+	// an audio stage, per segment for the others.
+	Latency time.Duration
+	Action  Action
+	// Cooldown is how long a breach lasts after the average was last over
+	// Latency. Defaults to DefaultCooldown.
+	Cooldown time.Duration
+}
+
+// Validate checks the budget of stage.
+func (c Config) Validate(stage string) error {
+	switch {
+	case c.Latency <= 0:
+		return fmt.Errorf("budget: %s: latency must be positive", stage)
+	case c.Cooldown < 0:
+		return fmt.Errorf("budget: %s: negative cooldown %v", stage, c.Cooldown)
+	case c.Action < Observe || c.Action > Shed:
+		return fmt.Errorf("budget: %s: unknown action %v", stage, c.Action)
+	case c.Action == Skip && !slices.Contains(Optional, stage):
+		return fmt.Errorf("budget: %s cannot be skipped; only %v can", stage, Optional)
+	}
+	return nil
+}
+
+// Tracker follows a stage against its budget. The zero value is not
+// usable; see New.
+type Tracker struct {
+	cfg Config
+
+	mu    sync.Mutex
+	avg   time.Duration // moving average; zero before the first measurement
+	until time.Time     // end of the breach, zero outside one
+}
+
+// New returns a tracker of the budget cfg.
+func New(cfg Config) *Tracker {
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = DefaultCooldown
+	}
+	return &Tracker{cfg: cfg}
+}
+
+// Config returns the budget t tracks.
+func (t *Tracker) Config() Config { return t.cfg }
+
+// Observe records that the stage took d, and reports whether that started
+// a breach.
+func (t *Tracker) Observe(d time.Duration) bool {
+	if t == nil {
+		return false
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.until.IsZero() && now.After(t.until) {
+		t.avg, t.until = 0, time.Time{}
+	}
+	if t.avg == 0 {
+		t.avg = d
+	} else {
+		t.avg += (d - t.avg) / weight
+	}
+	if t.avg <= t.cfg.Latency {
+		return false
+	}
+	started := t.until.IsZero()
+	t.until = now.Add(t.cfg.Cooldown)
+	return started
+}
+
+// Average returns the moving average of the stage's latency.
+func (t *Tracker) Average() time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.avg
+}
+
+// Over reports whether the stage is in breach of its budget.
+func (t *Tracker) Over() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.until.IsZero() && time.Now().Before(t.until)
+}
+
+// Skip reports whether the stage is to be skipped now.
+func (t *Tracker) Skip() bool { return t != nil && t.cfg.Action == Skip && t.Over() }
+
+// Shed reports whether new streams are to be refused now.
+func (t *Tracker) Shed() bool { return t != nil && t.cfg.Action == Shed && t.Over() }
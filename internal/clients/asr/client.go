@@ -0,0 +1,340 @@
+// Package asr is the gRPC client for the Python ASR sidecar (services/asr).
+//
+// It adapts the voxa.speech.v1.Asr StreamingRecognize RPC to the
+// stt.StreamingRecognizer interface.
+package asr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	speechv1 "github.com/jmarc101/voxa/api/gen/voxa/speech/v1"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// DefaultAddr is where the ASR sidecar listens by default.
+const DefaultAddr = "localhost:7010"
+
+// ProviderName is the name the sidecar is registered under in the stt
+// provider registry. Its options are "addr" and "sample_rate".
+const ProviderName = "sidecar"
+
+// PriorityHeader is the gRPC metadata key a stream's stt.Priority is sent
+// under, for the sidecar to schedule its decodes by.
+const PriorityHeader = "voxa-priority"
+
+// SampleRate is the rate the sidecar's Whisper models expect.
+const SampleRate = 16000
+
+func init() {
+	stt.Register(ProviderName, func(cfg stt.Config) (stt.Provider, error) {
+		rate, err := strconv.Atoi(cfg.Option("sample_rate", strconv.Itoa(SampleRate)))
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("bad sample_rate %q", cfg.Options["sample_rate"])
+		}
+		c, err := Dial(cfg.Option("addr", DefaultAddr))
+		if err != nil {
+			return nil, err
+		}
+		c.rate = rate
+		c.log = logging.OrNop(cfg.Logger)
+		return c, nil
+	})
+}
+
+// Client opens recognition streams against the ASR sidecar.
+type Client struct {
+	conn *grpc.ClientConn
+	rpc  speechv1.AsrClient
+	rate int
+	log  logging.Logger
+}
+
+var (
+	_ stt.Provider       = (*Client)(nil)
+	_ stt.FormatRequirer = (*Client)(nil)
+)
+
+// Dial creates a client for the sidecar at addr. The connection is
+// established lazily on the first stream.
+func Dial(addr string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("asr: dial %s: %w", addr, err)
+	}
+	return &Client{conn: conn, rpc: speechv1.NewAsrClient(conn), rate: SampleRate, log: logging.Nop()}, nil
+}
+
+// RequiredFormat implements stt.FormatRequirer: the sidecar takes mono
+// audio at a fixed rate.
+func (c *Client) RequiredFormat() audio.Format {
+	return audio.Format{SampleRate: c.rate, Channels: 1}
+}
+
+// Close tears down the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// NewStream opens a StreamingRecognize RPC, sending the stream's priority
+// in PriorityHeader.
+func (c *Client) NewStream(ctx context.Context, cfg stt.StreamConfig) (stt.StreamingRecognizer, error) {
+	rpc, err := c.rpc.StreamingRecognize(metadata.AppendToOutgoingContext(ctx, PriorityHeader, cfg.Priority.String()))
+	if err != nil {
+		return nil, fmt.Errorf("asr: open stream: %w", err)
+	}
+	uid := cfg.UtteranceID
+	if uid == "" {
+		uid = newUtteranceID()
+	}
+	rate := cfg.SampleRate
+	if rate <= 0 {
+		rate = c.rate
+	}
+	log := cfg.Logger
+	if log == nil {
+		log = c.log
+	}
+	log.Debug("asr stream opened", "utterance", uid, "sample_rate", rate)
+	s := &stream{
+		ctx:     ctx,
+		rpc:     rpc,
+		log:     log,
+		utt:     uid,
+		format:  audio.Format{SampleRate: rate, Channels: 1},
+		spans:   make(map[string]*span),
+		results: make(chan stt.Segment, 16),
+	}
+	go s.recv()
+	return s, nil
+}
+
+// stream implements stt.StreamingRecognizer on top of one bi-di RPC.
+type stream struct {
+	ctx context.Context // of the RPC
+	rpc grpc.BidiStreamingClient[speechv1.StreamingRecognizeRequest, speechv1.StreamingRecognizeResponse]
+
+	format audio.Format
+	log    logging.Logger
+
+	mu     sync.Mutex // guards sends and the fields below
+	utt    string
+	seq    int64
+	closed bool
+	sent   int              // samples sent so far
+	spans  map[string]*span // audio span of every utterance not yet final
+
+	results chan stt.Segment
+	err     error
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, stt.ErrClosed
+	}
+	s.seq++
+	err := s.rpc.Send(&speechv1.StreamingRecognizeRequest{
+		UtteranceId: s.utt,
+		Payload: &speechv1.StreamingRecognizeRequest_Audio{Audio: &speechv1.AudioChunk{
+			UtteranceId: s.utt,
+			Seq:         s.seq,
+			Data:        p,
+			EmitTime:    timestamppb.Now(),
+		}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("asr: send audio: %w", err)
+	}
+	sp := s.spans[s.utt]
+	if sp == nil {
+		sp = &span{start: s.format.Duration(s.sent)}
+		s.spans[s.utt] = sp
+	}
+	s.sent += len(p) / 2
+	sp.end = s.format.Duration(s.sent)
+	return len(p), nil
+}
+
+// span is the stretch of stream audio an utterance covers.
+type span struct{ start, end time.Duration }
+
+// span returns the audio span of utterance uid; final forgets it.
+func (s *stream) span(uid string, final bool) span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := s.spans[uid]
+	if sp == nil {
+		return span{}
+	}
+	if final {
+		delete(s.spans, uid)
+	}
+	return *sp
+}
+
+func (s *stream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return stt.ErrClosed
+	}
+	if err := s.sendControl(speechv1.ControlType_FLUSH); err != nil {
+		return err
+	}
+	s.utt = newUtteranceID()
+	s.seq = 0
+	return nil
+}
+
+func (s *stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if err := s.sendControl(speechv1.ControlType_END); err != nil {
+		return err
+	}
+	return s.rpc.CloseSend()
+}
+
+func (s *stream) Results() <-chan stt.Segment { return s.results }
+
+func (s *stream) Err() error { return s.err }
+
+// sendControl must be called with s.mu held.
+func (s *stream) sendControl(t speechv1.ControlType) error {
+	err := s.rpc.Send(&speechv1.StreamingRecognizeRequest{
+		UtteranceId: s.utt,
+		Payload: &speechv1.StreamingRecognizeRequest_Control{
+			Control: &speechv1.ASRControl{Type: t},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("asr: send %s: %w", t, err)
+	}
+	return nil
+}
+
+// recv pumps responses into results until the server ends the RPC or its
+// context is done.
+func (s *stream) recv() {
+	defer close(s.results)
+
+	revs := make(map[string]int)
+	stab := make(map[string]*stt.Stabilizer)
+	for {
+		resp, err := s.rpc.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
+				s.err = fmt.Errorf("asr: recv: %w", err)
+				s.log.Error("asr stream failed", "error", err)
+				return
+			}
+			s.log.Debug("asr stream ended")
+			return
+		}
+
+		uid := resp.GetUtteranceId()
+		switch resp.GetType() {
+		case speechv1.ResponseType_PARTIAL:
+			t := resp.GetPartialTranscript()
+			st := stab[uid]
+			if st == nil {
+				st = &stt.Stabilizer{}
+				stab[uid] = st
+			}
+			score := st.Score(t.GetText())
+			if t.GetStability() > 0 {
+				score = t.GetStability()
+			}
+			revs[uid]++
+			seg := stt.Segment{
+				UtteranceID: uid,
+				Revision:    revs[uid],
+				Stability:   score,
+			}
+			if !s.send(s.fill(seg, t)) {
+				return
+			}
+		case speechv1.ResponseType_FINAL:
+			revs[uid]++
+			seg := stt.Segment{
+				UtteranceID: uid,
+				Revision:    revs[uid],
+				Stability:   1,
+				Final:       true,
+			}
+			s.log.Debug("asr final", "utterance", uid, "revisions", revs[uid])
+			if !s.send(s.fill(seg, resp.GetFinalTranscript())) {
+				return
+			}
+			delete(revs, uid)
+			delete(stab, uid)
+		case speechv1.ResponseType_ERROR:
+			e := resp.GetError()
+			s.err = status.Errorf(codes.Code(e.GetCode()), "asr: %s", e.GetMessage())
+			s.log.Error("asr sidecar error", "utterance", uid, "code", codes.Code(e.GetCode()).String(), "message", e.GetMessage())
+			return
+		}
+	}
+}
+
+// send delivers seg, unless the RPC's context ends first because nobody
+// reads any more.
+func (s *stream) send(seg stt.Segment) bool {
+	select {
+	case s.results <- seg:
+		return true
+	case <-s.ctx.Done():
+		s.log.Debug("asr stream cancelled")
+		return false
+	}
+}
+
+// fill copies the transcript into seg. When the sidecar does not report
+// the utterance span, it is taken from the audio sent for the utterance.
+func (s *stream) fill(seg stt.Segment, t *speechv1.Transcript) stt.Segment {
+	seg.Text = t.GetText()
+	seg.Confidence = t.GetConfidence()
+	sp := s.span(seg.UtteranceID, seg.Final)
+	seg.Start, seg.End = sp.start, sp.end
+	if t.GetEnd() != nil {
+		seg.Start, seg.End = t.GetStart().AsDuration(), t.GetEnd().AsDuration()
+	}
+	for _, w := range t.GetWords() {
+		seg.Words = append(seg.Words, stt.Word{
+			Text:       w.GetText(),
+			Start:      w.GetStart().AsDuration(),
+			End:        w.GetEnd().AsDuration(),
+			Confidence: w.GetConfidence(),
+		})
+	}
+	return seg
+}
+
+func newUtteranceID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
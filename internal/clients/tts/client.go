@@ -0,0 +1,143 @@
+// Package tts is the gRPC client for the Python TTS sidecar (services/tts).
+// It also registers the sidecar as the "sidecar" synthesis provider.
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	speechv1 "github.com/jmarc101/voxa/api/gen/voxa/speech/v1"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/tts"
+)
+
+// DefaultAddr is where the TTS sidecar listens by default.
+const DefaultAddr = "localhost:7020"
+
+// Client talks to the TTS sidecar.
+type Client struct {
+	conn *grpc.ClientConn
+	rpc  speechv1.TtsClient
+}
+
+// Dial creates a client for the sidecar at addr. The connection is
+// established lazily on the first call.
+func Dial(addr string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("tts: dial %s: %w", addr, err)
+	}
+	return &Client{conn: conn, rpc: speechv1.NewTtsClient(conn)}, nil
+}
+
+// Close tears down the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Synthesize speaks text and calls fn for every audio chunk as soon as the
+// sidecar produces it. Returning an error from fn aborts the call.
+func (c *Client) Synthesize(ctx context.Context, utteranceID, text string, fn func(*speechv1.AudioChunk) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.rpc.Synthesize(ctx, &speechv1.SynthesisRequest{UtteranceId: utteranceID, Text: text})
+	if err != nil {
+		return fmt.Errorf("tts: synthesize: %w", err)
+	}
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("tts: recv: %w", err)
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// ProviderName is the name the sidecar registers under in the tts registry.
+const ProviderName = "sidecar"
+
+// DefaultSampleRate is the rate the sidecar synthesizes at unless
+// configured otherwise (the proto leaves it out of band).
+const DefaultSampleRate = 22050
+
+func init() {
+	tts.Register(ProviderName, func(cfg tts.Config) (tts.Synthesizer, error) {
+		rate, err := strconv.Atoi(cfg.Option("sample_rate", strconv.Itoa(DefaultSampleRate)))
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("bad sample_rate %q", cfg.Options["sample_rate"])
+		}
+		c, err := Dial(cfg.Option("addr", DefaultAddr))
+		if err != nil {
+			return nil, err
+		}
+		s := NewSynthesizer(c, rate)
+		s.log = logging.OrNop(cfg.Logger)
+		return s, nil
+	})
+}
+
+// Synthesizer adapts a Client to tts.Synthesizer.
+type Synthesizer struct {
+	c      *Client
+	format audio.Format
+	log    logging.Logger
+}
+
+// NewSynthesizer wraps c; sampleRate is the rate the sidecar produces.
+func NewSynthesizer(c *Client, sampleRate int) *Synthesizer {
+	return &Synthesizer{c: c, format: audio.Format{SampleRate: sampleRate, Channels: 1}, log: logging.Nop()}
+}
+
+// Close closes the underlying client.
+func (s *Synthesizer) Close() error { return s.c.Close() }
+
+// Synthesize implements tts.Synthesizer. The sidecar takes plain text, so
+// SSML is rendered span by span.
+func (s *Synthesizer) Synthesize(ctx context.Context, req tts.Request) (tts.Stream, error) {
+	spans, err := tts.Spans(req.Text)
+	if err != nil {
+		return nil, err
+	}
+	return tts.Render(ctx, s.format, spans, func(ctx context.Context, text string) (tts.Stream, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		pr, pw := io.Pipe()
+		go func() {
+			start, chunks := time.Now(), 0
+			err := s.c.Synthesize(ctx, req.UtteranceID, text, func(chunk *speechv1.AudioChunk) error {
+				chunks++
+				_, err := pw.Write(chunk.GetData())
+				return err
+			})
+			s.log.Debug("tts sidecar synthesis", "utterance", req.UtteranceID, "chunks", chunks,
+				"took", time.Since(start), "error", err)
+			pw.CloseWithError(err)
+		}()
+		return tts.NewPCMStream(s.format, &cancelReader{pr, cancel}), nil
+	}), nil
+}
+
+// cancelReader aborts the RPC feeding the pipe when closed.
+type cancelReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *cancelReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
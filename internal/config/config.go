@@ -0,0 +1,2356 @@
+// Package config loads voxad deployments from YAML or TOML files.
+//
+// A file declares everything the command-line flags do and more: the
+// listeners, the STT and TTS providers with their options (models, voices,
+// sidecar addresses) and fallbacks, the audio stages to run, including
+// plugin stages, the capture devices and the session store. Load rejects unknown keys and checks the
+// values before anything is started, reporting every problem found with the
+// path of the offending key, so a deployment can be reviewed and shipped as
+// a single file.
+//
+//	recognizer:
+//	  provider: whisper
+//	  options:
+//	    model: /models/ggml-base.en.bin
+//	stages:
+//	  vad:
+//	    aggressiveness: 3
+package config
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/aec"
+	"github.com/jmarc101/voxa/internal/audio/agc"
+	"github.com/jmarc101/voxa/internal/audio/amd"
+	"github.com/jmarc101/voxa/internal/audio/beam"
+	"github.com/jmarc101/voxa/internal/audio/denoise"
+	"github.com/jmarc101/voxa/internal/audio/dtmf"
+	"github.com/jmarc101/voxa/internal/audio/endpoint"
+	"github.com/jmarc101/voxa/internal/audio/vad"
+	"github.com/jmarc101/voxa/internal/clients/asr"
+	"github.com/jmarc101/voxa/internal/cost"
+	"github.com/jmarc101/voxa/internal/diarize"
+	"github.com/jmarc101/voxa/internal/itn"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/onnx"
+	"github.com/jmarc101/voxa/internal/plugin"
+	"github.com/jmarc101/voxa/internal/profanity"
+	"github.com/jmarc101/voxa/internal/punctuate"
+	"github.com/jmarc101/voxa/internal/ratelimit"
+	"github.com/jmarc101/voxa/internal/redact"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/rules"
+	"github.com/jmarc101/voxa/internal/sentiment"
+	"github.com/jmarc101/voxa/internal/speaker"
+	"github.com/jmarc101/voxa/internal/stt"
+	"github.com/jmarc101/voxa/internal/summarize"
+	"github.com/jmarc101/voxa/internal/summarize/openai"
+	"github.com/jmarc101/voxa/internal/tn"
+	"github.com/jmarc101/voxa/internal/translate"
+	"github.com/jmarc101/voxa/internal/translate/libretranslate"
+	"github.com/jmarc101/voxa/internal/tts"
+	"github.com/jmarc101/voxa/internal/vocab"
+	"github.com/jmarc101/voxa/internal/wakeword"
+)
+
+// DefaultGRPC is the gRPC listen address of files that set none.
+const DefaultGRPC = ":7000"
+
+// DefaultDrainTimeout is the drain timeout of files that set none.
+const DefaultDrainTimeout = 30 * time.Second
+
+// File is a voxad deployment. Optional sections are pointers: a stage runs
+// only if its section is present, even if empty.
+type File struct {
+	Server     Server     `yaml:"server" toml:"server"`
+	Logging    Logging    `yaml:"logging" toml:"logging"`
+	Recognizer Recognizer `yaml:"recognizer" toml:"recognizer"`
+	// Synthesizer, if set, enables speech synthesis.
+	Synthesizer *Synthesizer `yaml:"synthesizer" toml:"synthesizer"`
+	Stages      Stages       `yaml:"stages" toml:"stages"`
+	// Profiles are variants of the recognizer, synthesizer and stages,
+	// for tenants with needs of their own, that sessions run on when their
+	// API key or client selects them; see Profile.
+	Profiles map[string]Profile `yaml:"profiles" toml:"profiles"`
+	Devices  Devices            `yaml:"devices" toml:"devices"`
+	Sessions *Sessions          `yaml:"sessions" toml:"sessions"`
+	// Transcripts, if set, persists the transcript of every session.
+	Transcripts *Transcripts `yaml:"transcripts" toml:"transcripts"`
+	// Archive, if set, records the audio of every session.
+	Archive *Archive `yaml:"archive" toml:"archive"`
+	// Encryption, if set, encrypts the archive and the transcripts at
+	// rest.
+	Encryption *Encryption `yaml:"encryption" toml:"encryption"`
+	// Retention, if set, deletes sessions once they are older than their
+	// tenant keeps them.
+	Retention *Retention `yaml:"retention" toml:"retention"`
+	// Degraded, if set with archive and transcripts, keeps sessions going
+	// while every recognizer provider is down, and transcribes them once
+	// they are back.
+	Degraded *Degraded `yaml:"degraded" toml:"degraded"`
+	// Intents is a JSON file of intent definitions to recognize in final
+	// transcripts; see voxa.LoadIntents.
+	Intents string `yaml:"intents" toml:"intents"`
+	// Alerts are keyword spotting rules raising alerts on live sessions.
+	Alerts []Alert `yaml:"alerts" toml:"alerts"`
+	Buffer *Buffer `yaml:"buffer" toml:"buffer"`
+	// Plugins are Go plugins to load, which register stages that Stages
+	// can name and lexicons synthesizer.normalization can; see
+	// voxa.OpenPlugin.
+	Plugins []string `yaml:"plugins" toml:"plugins"`
+	// Watch, if set, has voxad check the file for changes at this interval
+	// and reload it, as it does on SIGHUP.
+	Watch time.Duration `yaml:"watch" toml:"watch"`
+}
+
+// Profile is a named variant of the deployment, replacing the sections it
+// sets with its own: a recognizer with another provider or model, another
+// synthesizer or voice, or stages with their own vocabulary or redaction
+// rules. The listeners, devices, stores, intents and alerts stay those of
+// the file, shared by every profile.
+type Profile struct {
+	Recognizer  *Recognizer  `yaml:"recognizer" toml:"recognizer"`
+	Synthesizer *Synthesizer `yaml:"synthesizer" toml:"synthesizer"`
+	Stages      *Stages      `yaml:"stages" toml:"stages"`
+}
+
+// Server configures the listeners.
+type Server struct {
+	// GRPC is the gRPC listen address. Defaults to DefaultGRPC.
+	GRPC string `yaml:"grpc" toml:"grpc"`
+	// DrainTimeout bounds how long voxad waits on shutdown for running
+	// sessions to reach an utterance boundary and finish, before it
+	// terminates them. Defaults to DefaultDrainTimeout.
+	DrainTimeout time.Duration `yaml:"drain_timeout" toml:"drain_timeout"`
+	// HTTP is the HTTP/WebSocket listen address. Empty disables it.
+	HTTP string `yaml:"http" toml:"http"`
+	// Origins are extra origins allowed to open WebSockets.
+	Origins []string `yaml:"ws_origins" toml:"ws_origins"`
+	// Metrics serves Prometheus metrics at /metrics on the HTTP listener.
+	Metrics bool `yaml:"metrics" toml:"metrics"`
+	// UI serves a page at /ui/ on the HTTP listener to try the server with
+	// a microphone and watch its sessions.
+	UI bool `yaml:"ui" toml:"ui"`
+	// OTLP is the OTLP/HTTP collector address traces are exported to.
+	// Empty disables tracing.
+	OTLP string `yaml:"otlp" toml:"otlp"`
+	// Pprof is the address the Go profiler is served on, at /debug/pprof/.
+	// It has no authentication, so should stay on a loopback or private
+	// address. Empty disables it.
+	Pprof string `yaml:"pprof" toml:"pprof"`
+	// RTP is the UDP address phone calls are received on as RTP, with
+	// RTCP on the same port or the next one up. Empty disables it.
+	RTP string `yaml:"rtp" toml:"rtp"`
+	// RTPOpus is the payload type Opus is negotiated with. Defaults to
+	// 111.
+	RTPOpus uint8 `yaml:"rtp_opus" toml:"rtp_opus"`
+	// Twilio, if set, serves Twilio Media Streams at /v1/twilio on the
+	// HTTP listener.
+	Twilio *Twilio `yaml:"twilio" toml:"twilio"`
+	// WebRTC, if set, serves WHIP at /v1/whip on the HTTP listener.
+	WebRTC *WebRTC `yaml:"webrtc" toml:"webrtc"`
+	// MQTT, if set, bridges events and TTS requests to an MQTT broker.
+	MQTT *MQTT `yaml:"mqtt" toml:"mqtt"`
+	// Webhook, if set, POSTs events to a URL.
+	Webhook *Webhook `yaml:"webhook" toml:"webhook"`
+	// SSE, if set, serves the events of live sessions as server-sent events
+	// at /v1/sessions/{id}/events on the HTTP listener.
+	SSE *SSE `yaml:"sse" toml:"sse"`
+	// Kafka, if set, publishes events to a Kafka topic.
+	Kafka *Kafka `yaml:"kafka" toml:"kafka"`
+	// NATS, if set, publishes events to NATS JetStream.
+	NATS *NATS `yaml:"nats" toml:"nats"`
+	// Auth, if set, requires an API key of gRPC and HTTP clients and
+	// serves the admin API at /v1/admin/ on the HTTP listener. Twilio is
+	// still checked by its own signatures, and RTP is not authenticated.
+	Auth *Auth `yaml:"auth" toml:"auth"`
+	// Cluster, if set, makes voxad one node of several behind a load
+	// balancer, sharing live sessions through Redis.
+	Cluster *Cluster `yaml:"cluster" toml:"cluster"`
+	// Costs, if set, prices the provider usage of every session, charged
+	// to the session and its API key, exported as metrics and, with Auth,
+	// at /v1/admin/costs.
+	Costs *Costs `yaml:"costs" toml:"costs"`
+}
+
+// Costs is the price table usage is billed at; see cost.Config.
+type Costs struct {
+	Currency string `yaml:"currency" toml:"currency"`
+	// Prices are by provider name, as in recognizer.provider.
+	Prices map[string]Price `yaml:"prices" toml:"prices"`
+}
+
+// Price is what a provider charges.
+type Price struct {
+	AudioMinute       float64 `yaml:"audio_minute" toml:"audio_minute"`
+	MillionCharacters float64 `yaml:"million_characters" toml:"million_characters"`
+}
+
+// Config converts c.
+func (c *Costs) Config() cost.Config {
+	cfg := cost.Config{Currency: c.Currency, Prices: map[string]cost.Price{}}
+	for name, p := range c.Prices {
+		cfg.Prices[name] = cost.Price(p)
+	}
+	return cfg
+}
+
+// Cluster configures the node of a voxad cluster; see
+// server.ClusterConfig.
+type Cluster struct {
+	// Node names the node, and routes its clients back to it. Defaults to
+	// the host name.
+	Node string `yaml:"node" toml:"node"`
+	// URL locates the redis store shared by the nodes. Defaults to
+	// sessions.url.
+	URL          string        `yaml:"url" toml:"url"`
+	LeaseTTL     time.Duration `yaml:"lease_ttl" toml:"lease_ttl"`
+	ResumeWindow time.Duration `yaml:"resume_window" toml:"resume_window"`
+}
+
+// Auth configures the API keys clients authenticate with; see auth.Config.
+type Auth struct {
+	Keys []APIKey `yaml:"keys" toml:"keys"`
+}
+
+// APIKey is a client API key and its quotas; see auth.KeyConfig.
+type APIKey struct {
+	Name string `yaml:"name" toml:"name"`
+	// SHA256 is the hex SHA-256 of the key.
+	SHA256 string `yaml:"sha256" toml:"sha256"`
+	Admin  bool   `yaml:"admin" toml:"admin"`
+	// Rate is in requests per second; 0 is unlimited.
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+	// Sessions bounds the concurrent sessions; 0 is unlimited.
+	Sessions int `yaml:"sessions" toml:"sessions"`
+	// MaxPriority is interactive, normal (the default) or batch.
+	MaxPriority string `yaml:"max_priority" toml:"max_priority"`
+	// Languages the sessions may ask for; empty allows any.
+	Languages []string `yaml:"languages" toml:"languages"`
+	// Models the sessions may ask for; empty allows none.
+	Models []string `yaml:"models" toml:"models"`
+	// Profiles the sessions may ask for, the first being the one they run
+	// on unless they ask; empty allows none.
+	Profiles []string `yaml:"profiles" toml:"profiles"`
+}
+
+// SSE configures the server-sent events endpoint; see
+// server.EventHubConfig.
+type SSE struct {
+	// Buffer is how many events of a session are kept for resuming.
+	Buffer int `yaml:"buffer" toml:"buffer"`
+	// Retention is how long an ended session stays resumable.
+	Retention time.Duration `yaml:"retention" toml:"retention"`
+}
+
+// Kafka configures the Kafka sink; see kafka.Config.
+type Kafka struct {
+	Brokers []string `yaml:"brokers" toml:"brokers"`
+	Topic   string   `yaml:"topic" toml:"topic"`
+	// Encoding is json or protobuf. Defaults to json.
+	Encoding string `yaml:"encoding" toml:"encoding"`
+	// Events are the event types published; see voxa.ParseEventType.
+	// Defaults to all.
+	Events    []string `yaml:"events" toml:"events"`
+	QueueSize int      `yaml:"queue_size" toml:"queue_size"`
+}
+
+// NATS configures the NATS JetStream sink; see nats.Config.
+type NATS struct {
+	URL string `yaml:"url" toml:"url"`
+	// Prefix starts the subjects. Defaults to "voxa.events".
+	Prefix string `yaml:"prefix" toml:"prefix"`
+	// Stream, if set, is created for the subjects if missing.
+	Stream string `yaml:"stream" toml:"stream"`
+	// Encoding is json or protobuf. Defaults to json.
+	Encoding string `yaml:"encoding" toml:"encoding"`
+	// Events are the event types published; see voxa.ParseEventType.
+	// Defaults to all.
+	Events    []string `yaml:"events" toml:"events"`
+	QueueSize int      `yaml:"queue_size" toml:"queue_size"`
+}
+
+// Webhook configures the webhook sink; see webhook.Config. Requests are
+// signed with the secret in $VOXA_WEBHOOK_SECRET, if set.
+type Webhook struct {
+	URL string `yaml:"url" toml:"url"`
+	// Events are the event types delivered; see voxa.ParseEventType.
+	// Defaults to final and intent.
+	Events []string `yaml:"events" toml:"events"`
+	// Spool, if set, is a directory undelivered events are kept in across
+	// restarts.
+	Spool     string `yaml:"spool" toml:"spool"`
+	QueueSize int    `yaml:"queue_size" toml:"queue_size"`
+	Retry     *Retry `yaml:"retry" toml:"retry"`
+}
+
+// MQTT configures the MQTT bridge; see mqtt.Config. The broker password is
+// taken from $MQTT_PASSWORD, if set.
+type MQTT struct {
+	// Broker is a tcp://, ssl://, ws:// or wss:// URL.
+	Broker   string `yaml:"broker" toml:"broker"`
+	ClientID string `yaml:"client_id" toml:"client_id"`
+	Username string `yaml:"username" toml:"username"`
+	// Prefix is the first level of the topics. Defaults to "voxa".
+	Prefix string `yaml:"prefix" toml:"prefix"`
+	QoS    int    `yaml:"qos" toml:"qos"`
+}
+
+// WebRTC configures the WebRTC endpoint; see webrtc.Config.
+type WebRTC struct {
+	// ICEServers are STUN and TURN URLs, e.g. stun:stun.l.google.com:19302.
+	ICEServers []string `yaml:"ice_servers" toml:"ice_servers"`
+	// UDP, if set, is the one UDP address all connections share.
+	UDP string `yaml:"udp" toml:"udp"`
+	// MaxPeers, if set, bounds the connections open at once.
+	MaxPeers int `yaml:"max_peers" toml:"max_peers"`
+}
+
+// Twilio configures the Twilio Media Streams handler; see
+// server.TwilioConfig. Connections are checked against the auth token in
+// $TWILIO_AUTH_TOKEN, if set.
+type Twilio struct {
+	// Callback is the URL transcripts are posted to.
+	Callback string `yaml:"callback" toml:"callback"`
+	// URL is the URL given to Twilio, if a proxy rewrites it.
+	URL string `yaml:"url" toml:"url"`
+	// Partials posts partial segments too.
+	Partials bool   `yaml:"partials" toml:"partials"`
+	Retry    *Retry `yaml:"retry" toml:"retry"`
+	// Notice, if set, is a recording notice played to callers before
+	// their call is transcribed.
+	Notice *TwilioNotice `yaml:"notice" toml:"notice"`
+}
+
+// TwilioNotice is a recording notice; see server.TwilioNotice.
+type TwilioNotice struct {
+	// Text is spoken by the synthesizer.
+	Text string `yaml:"text" toml:"text"`
+	// File is an audio file played instead.
+	File    string        `yaml:"file" toml:"file"`
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// Logging configures the log output.
+type Logging struct {
+	// Level is the least severe level written: debug, info, warn or error.
+	// Defaults to info.
+	Level string `yaml:"level" toml:"level"`
+	// Format is text or json. Defaults to text.
+	Format string `yaml:"format" toml:"format"`
+}
+
+// Recognizer selects the STT provider.
+type Recognizer struct {
+	// Provider is a registered STT provider. Defaults to the ASR sidecar.
+	Provider string  `yaml:"provider" toml:"provider"`
+	Options  Options `yaml:"options" toml:"options"`
+	// Retry, if set, retries transient failures of the provider and of
+	// fallbacks that set none.
+	Retry *Retry `yaml:"retry" toml:"retry"`
+	// LatencySLO fails streams over to the next fallback when transcripts
+	// lag the audio by more than this. Zero disables it.
+	LatencySLO time.Duration `yaml:"latency_slo" toml:"latency_slo"`
+	// ReplayWindow is the most audio of an utterance replayed to the
+	// stream replacing a failed one, 30s unless set. Setting it retries
+	// failures with the defaults when Retry is not set.
+	ReplayWindow time.Duration `yaml:"replay_window" toml:"replay_window"`
+	// RateLimit, if set, holds the provider to the quotas of its account.
+	RateLimit *RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+	// Fallbacks take over, in order, from a failing provider.
+	Fallbacks []Fallback `yaml:"fallbacks" toml:"fallbacks"`
+}
+
+// Synthesizer selects the TTS provider.
+type Synthesizer struct {
+	// Provider is a registered TTS provider.
+	Provider string  `yaml:"provider" toml:"provider"`
+	Options  Options `yaml:"options" toml:"options"`
+	// Retry, if set, retries transient failures of the provider and of
+	// fallbacks that set none.
+	Retry *Retry `yaml:"retry" toml:"retry"`
+	// RateLimit, if set, holds the provider to the quotas of its account.
+	RateLimit *RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+	// Fallbacks take over, in order, from a failing provider.
+	Fallbacks []Fallback `yaml:"fallbacks" toml:"fallbacks"`
+	// Cache, if set, serves repeated phrases from a cache of the audio
+	// spoken.
+	Cache *SynthesisCache `yaml:"cache" toml:"cache"`
+	// Sentences synthesizes plain text one sentence at a time, so replies
+	// start playing once their first sentence is synthesized.
+	Sentences bool `yaml:"sentences" toml:"sentences"`
+	// Normalization, if set, has numbers, units, dates and abbreviations
+	// read aloud rather than spelled out.
+	Normalization *SpeechNormalization `yaml:"normalization" toml:"normalization"`
+}
+
+// SpeechNormalization configures the normalization of the text a
+// synthesizer speaks; see voxa.SpeechNormalizationConfig.
+type SpeechNormalization struct {
+	Locale string `yaml:"locale" toml:"locale"`
+	// Disable lists the entity classes left as written: cardinal,
+	// ordinal, decimal, percent, currency, measure, date, time or
+	// abbreviation.
+	Disable []string `yaml:"disable" toml:"disable"`
+	// Lexicons names lexicons registered by plugins; see
+	// voxa.RegisterLexicon.
+	Lexicons []string `yaml:"lexicons" toml:"lexicons"`
+	// Lexicon maps written forms to how they are spoken, overriding
+	// Lexicons.
+	Lexicon map[string]string `yaml:"lexicon" toml:"lexicon"`
+}
+
+// config converts c, which may be nil.
+func (c *SpeechNormalization) config() *voxa.SpeechNormalizationConfig {
+	if c == nil {
+		return nil
+	}
+	cfg := &voxa.SpeechNormalizationConfig{Locale: c.Locale, Lexicons: c.Lexicons, Lexicon: c.Lexicon}
+	for _, d := range c.Disable {
+		cfg.Disable = append(cfg.Disable, voxa.SpeechNormalizationClass(d))
+	}
+	return cfg
+}
+
+// SynthesisCache is the cache of a synthesizer; see
+// voxa.SynthesisCacheConfig.
+type SynthesisCache struct {
+	// MemoryMB bounds the audio cached in memory. Defaults to 64.
+	MemoryMB int           `yaml:"memory_mb" toml:"memory_mb"`
+	TTL      time.Duration `yaml:"ttl" toml:"ttl"`
+	// Dir keeps the audio on disk too, up to DirMB, which defaults to
+	// 1024.
+	Dir   string `yaml:"dir" toml:"dir"`
+	DirMB int    `yaml:"dir_mb" toml:"dir_mb"`
+	// S3, instead of Dir, keeps the audio in a bucket. Credentials come
+	// from $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and
+	// $AWS_SESSION_TOKEN.
+	S3 *S3 `yaml:"s3" toml:"s3"`
+}
+
+// config converts c, which may be nil.
+func (c *SynthesisCache) config() *voxa.SynthesisCacheConfig {
+	if c == nil {
+		return nil
+	}
+	cfg := &voxa.SynthesisCacheConfig{
+		MaxBytes:    int64(c.MemoryMB) << 20,
+		TTL:         c.TTL,
+		Dir:         c.Dir,
+		DirMaxBytes: int64(c.DirMB) << 20,
+	}
+	if c.S3 != nil {
+		cfg.S3 = &voxa.ArchiveS3Config{
+			Bucket:   c.S3.Bucket,
+			Prefix:   c.S3.Prefix,
+			Region:   c.S3.Region,
+			Endpoint: c.S3.Endpoint,
+		}
+	}
+	return cfg
+}
+
+// Fallback is a provider a failing one hands over to.
+type Fallback struct {
+	Provider string  `yaml:"provider" toml:"provider"`
+	Options  Options `yaml:"options" toml:"options"`
+	// Retry defaults to the primary provider's.
+	Retry *Retry `yaml:"retry" toml:"retry"`
+	// RateLimit is the fallback's own; it has none by default.
+	RateLimit *RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+}
+
+// Options are provider options. Values may be written as strings, numbers
+// or booleans; providers receive them as strings.
+type Options map[string]string
+
+// UnmarshalTOML implements toml.Unmarshaler, which unlike YAML does not
+// turn numbers and booleans into strings by itself.
+func (o *Options) UnmarshalTOML(v any) error {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("options must be a table, not %T", v)
+	}
+	*o = make(Options, len(m))
+	for k, v := range m {
+		switch v.(type) {
+		case string, int64, float64, bool:
+			(*o)[k] = fmt.Sprint(v)
+		default:
+			return fmt.Errorf("option %q must be a string, number or boolean", k)
+		}
+	}
+	return nil
+}
+
+// Retry tunes retries and the circuit breaker of a provider; see
+// resilience.Config, which it converts to.
+type Retry struct {
+	Attempts   int           `yaml:"attempts" toml:"attempts"`
+	Backoff    time.Duration `yaml:"backoff" toml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff" toml:"max_backoff"`
+	Jitter     float64       `yaml:"jitter" toml:"jitter"`
+	Threshold  int           `yaml:"threshold" toml:"threshold"`
+	Cooldown   time.Duration `yaml:"cooldown" toml:"cooldown"`
+}
+
+// RateLimit holds a provider to the request rate and concurrency of its
+// account; see ratelimit.Config, which it converts to. Providers with the
+// same key share the limits; the key defaults to the provider name.
+type RateLimit struct {
+	Key         string        `yaml:"key" toml:"key"`
+	QPS         float64       `yaml:"qps" toml:"qps"`
+	Burst       int           `yaml:"burst" toml:"burst"`
+	Concurrency int           `yaml:"concurrency" toml:"concurrency"`
+	MaxWait     time.Duration `yaml:"max_wait" toml:"max_wait"`
+}
+
+// Stages selects the audio stages. Zero values select the stages'
+// defaults.
+type Stages struct {
+	Beamforming      *Beamforming      `yaml:"beamforming" toml:"beamforming"`
+	EchoCancellation *EchoCancellation `yaml:"echo_cancellation" toml:"echo_cancellation"`
+	DTMF             *DTMF             `yaml:"dtmf" toml:"dtmf"`
+	Denoise          *Denoise          `yaml:"denoise" toml:"denoise"`
+	AnsweringMachine *AnsweringMachine `yaml:"answering_machine" toml:"answering_machine"`
+	GainControl      *GainControl      `yaml:"gain_control" toml:"gain_control"`
+	VAD              *VAD              `yaml:"vad" toml:"vad"`
+	Endpointing      *Endpointing      `yaml:"endpointing" toml:"endpointing"`
+	WakeWord         *WakeWord         `yaml:"wake_word" toml:"wake_word"`
+	Diarization      *Diarization      `yaml:"diarization" toml:"diarization"`
+	LanguageID       *LanguageID       `yaml:"language_id" toml:"language_id"`
+	Translation      *Translation      `yaml:"translation" toml:"translation"`
+	// Audio are custom audio stages, run after gain control; see
+	// voxa.Config.AudioPlugins.
+	Audio []Plugin `yaml:"audio" toml:"audio"`
+	// Transcript are custom transcript stages; see
+	// voxa.Config.TranscriptPlugins.
+	Transcript    []Plugin       `yaml:"transcript" toml:"transcript"`
+	Vocabulary    *Vocabulary    `yaml:"vocabulary" toml:"vocabulary"`
+	Punctuation   *Punctuation   `yaml:"punctuation" toml:"punctuation"`
+	Normalization *Normalization `yaml:"normalization" toml:"normalization"`
+	Profanity     *Profanity     `yaml:"profanity" toml:"profanity"`
+	Redaction     *Redaction     `yaml:"redaction" toml:"redaction"`
+	Sentiment     *Sentiment     `yaml:"sentiment" toml:"sentiment"`
+	// Budgets are latency budgets, by stage name; see voxa.Config.Budgets.
+	Budgets map[string]Budget `yaml:"budgets" toml:"budgets"`
+	// Resample is the quality of format conversion: low, medium or high.
+	// Defaults to medium.
+	Resample string `yaml:"resample_quality" toml:"resample_quality"`
+}
+
+// Plugin selects a registered plugin stage.
+type Plugin struct {
+	Name    string  `yaml:"name" toml:"name"`
+	Options Options `yaml:"options" toml:"options"`
+}
+
+// Beamforming configures the mix-down of multi-channel sources; see
+// voxa.BeamformConfig.
+type Beamforming struct {
+	// Strategy is delay_sum, loudest, channel or average. Defaults to
+	// delay_sum.
+	Strategy string        `yaml:"strategy" toml:"strategy"`
+	Channels []int         `yaml:"channels" toml:"channels"`
+	MaxDelay time.Duration `yaml:"max_delay" toml:"max_delay"`
+}
+
+func (b *Beamforming) config() beam.Config {
+	return beam.Config{Strategy: b.Strategy, Channels: b.Channels, MaxDelay: b.MaxDelay}
+}
+
+// EchoCancellation configures echo cancellation; see voxa.EchoConfig.
+type EchoCancellation struct {
+	Tail  time.Duration `yaml:"tail" toml:"tail"`
+	Delay time.Duration `yaml:"delay" toml:"delay"`
+}
+
+func (e *EchoCancellation) config() aec.Config {
+	return aec.Config{Tail: e.Tail, Delay: e.Delay}
+}
+
+// DTMF configures keypad tone detection; see voxa.DTMFConfig.
+type DTMF struct {
+	MinDuration time.Duration `yaml:"min_duration" toml:"min_duration"`
+	// MinLevel is in dBFS.
+	MinLevel float64 `yaml:"min_level" toml:"min_level"`
+}
+
+func (d *DTMF) config() dtmf.Config {
+	return dtmf.Config{MinDuration: d.MinDuration, MinLevel: d.MinLevel}
+}
+
+// Denoise configures noise suppression; see voxa.DenoiseConfig.
+type Denoise struct {
+	Strength float64 `yaml:"strength" toml:"strength"`
+}
+
+// AnsweringMachine configures answering machine detection; see
+// voxa.AMDConfig.
+type AnsweringMachine struct {
+	InitialSilence       time.Duration `yaml:"initial_silence" toml:"initial_silence"`
+	Greeting             time.Duration `yaml:"greeting" toml:"greeting"`
+	AfterGreetingSilence time.Duration `yaml:"after_greeting_silence" toml:"after_greeting_silence"`
+	TotalTime            time.Duration `yaml:"total_time" toml:"total_time"`
+	MinWord              time.Duration `yaml:"min_word" toml:"min_word"`
+	BetweenWords         time.Duration `yaml:"between_words" toml:"between_words"`
+	MaxWords             int           `yaml:"max_words" toml:"max_words"`
+	// SilenceLevel is in dBFS.
+	SilenceLevel float64 `yaml:"silence_level" toml:"silence_level"`
+}
+
+func (a *AnsweringMachine) config() amd.Config {
+	return amd.Config{
+		InitialSilence: a.InitialSilence, Greeting: a.Greeting, AfterGreetingSilence: a.AfterGreetingSilence,
+		TotalTime: a.TotalTime, MinWord: a.MinWord, BetweenWords: a.BetweenWords, MaxWords: a.MaxWords,
+		SilenceLevel: a.SilenceLevel,
+	}
+}
+
+// GainControl configures automatic gain control; see voxa.GainConfig.
+// Levels are in dBFS, or LUFS with loudness.
+type GainControl struct {
+	Target   float64       `yaml:"target" toml:"target"`
+	Loudness bool          `yaml:"loudness" toml:"loudness"`
+	MaxGain  float64       `yaml:"max_gain" toml:"max_gain"`
+	Gate     float64       `yaml:"gate" toml:"gate"`
+	Attack   time.Duration `yaml:"attack" toml:"attack"`
+	Release  time.Duration `yaml:"release" toml:"release"`
+	Ceiling  float64       `yaml:"ceiling" toml:"ceiling"`
+}
+
+func (g *GainControl) config() agc.Config {
+	return agc.Config{
+		Target: g.Target, Loudness: g.Loudness, MaxGain: g.MaxGain, Gate: g.Gate,
+		Attack: g.Attack, Release: g.Release, Ceiling: g.Ceiling,
+	}
+}
+
+// VAD configures voice activity detection; see voxa.VADConfig.
+type VAD struct {
+	Aggressiveness int           `yaml:"aggressiveness" toml:"aggressiveness"`
+	MinSpeech      time.Duration `yaml:"min_speech" toml:"min_speech"`
+	Hangover       time.Duration `yaml:"hangover" toml:"hangover"`
+	PreRoll        time.Duration `yaml:"pre_roll" toml:"pre_roll"`
+	// Model, if set, classifies speech with an ONNX model, such as Silero
+	// VAD, in place of the heuristics.
+	Model *ONNXModel `yaml:"model" toml:"model"`
+}
+
+// Endpointing configures where utterances end; see
+// voxa.EndpointingConfig.
+type Endpointing struct {
+	MinSilence    time.Duration `yaml:"min_silence" toml:"min_silence"`
+	FinishSilence time.Duration `yaml:"finish_silence" toml:"finish_silence"`
+	MaxUtterance  time.Duration `yaml:"max_utterance" toml:"max_utterance"`
+}
+
+// WakeWord configures the wake word gate; see voxa.WakeWordConfig.
+type WakeWord struct {
+	Words        []Word        `yaml:"words" toml:"words"`
+	ListenWindow time.Duration `yaml:"listen_window" toml:"listen_window"`
+	// Model, if set instead of Words, spots Model.Phrases with an ONNX
+	// model.
+	Model *ONNXModel `yaml:"model" toml:"model"`
+}
+
+// ONNXModel maps the tensors of an ONNX model; see onnx.Config.
+type ONNXModel struct {
+	// File is the .onnx file, or the name of a model installed with voxa
+	// models pull.
+	File string `yaml:"file" toml:"file"`
+	// Quantization selects an installed variant of File, such as int8, or
+	// auto for the one that suits the CPU best.
+	Quantization string `yaml:"quantization" toml:"quantization"`
+	Input        string `yaml:"input" toml:"input"`
+	Output       string `yaml:"output" toml:"output"`
+	SampleRate   int    `yaml:"sample_rate" toml:"sample_rate"`
+	Window       int    `yaml:"window" toml:"window"`
+	Hop          int    `yaml:"hop" toml:"hop"`
+	// Features is pcm or mfcc. Defaults to pcm.
+	Features  string           `yaml:"features" toml:"features"`
+	States    []ONNXState      `yaml:"states" toml:"states"`
+	Constants map[string]int64 `yaml:"constants" toml:"constants"`
+	Threads   int              `yaml:"threads" toml:"threads"`
+	// Threshold is the output score that detects speech or a wake word.
+	// Defaults to 0.5.
+	Threshold float64 `yaml:"threshold" toml:"threshold"`
+	// Phrases are the wake words the model scores, in the order of its
+	// output.
+	Phrases []string `yaml:"phrases" toml:"phrases"`
+}
+
+// ONNXState is a recurrent tensor of an ONNXModel.
+type ONNXState struct {
+	Input  string  `yaml:"input" toml:"input"`
+	Output string  `yaml:"output" toml:"output"`
+	Shape  []int64 `yaml:"shape" toml:"shape"`
+}
+
+// config converts m.
+func (m *ONNXModel) config() (onnx.Config, error) {
+	features, err := onnx.ParseFeatures(m.Features)
+	cfg := onnx.Config{
+		Model:        m.File,
+		Quantization: m.Quantization,
+		Input:        m.Input,
+		Output:       m.Output,
+		SampleRate:   m.SampleRate,
+		Window:       m.Window,
+		Hop:          m.Hop,
+		Features:     features,
+		Constants:    m.Constants,
+		Threads:      m.Threads,
+	}
+	for _, st := range m.States {
+		cfg.States = append(cfg.States, onnx.State(st))
+	}
+	if err == nil {
+		err = cfg.Validate()
+	}
+	return cfg, err
+}
+
+// Word is a wake phrase.
+type Word struct {
+	Phrase      string  `yaml:"phrase" toml:"phrase"`
+	Sensitivity float64 `yaml:"sensitivity" toml:"sensitivity"`
+	// Templates are recordings of the phrase, trimmed to it, at the sample
+	// rate audio reaches the pipeline in.
+	Templates []string `yaml:"templates" toml:"templates"`
+}
+
+// Diarization configures speaker diarization; see voxa.DiarizationConfig.
+type Diarization struct {
+	Window      time.Duration `yaml:"window" toml:"window"`
+	MinWindow   time.Duration `yaml:"min_window" toml:"min_window"`
+	Threshold   float64       `yaml:"threshold" toml:"threshold"`
+	MaxSpeakers int           `yaml:"max_speakers" toml:"max_speakers"`
+	// Speakers, if set, names the speakers matching an enrolled voiceprint;
+	// see voxa.Config.Speakers.
+	Speakers *Speakers `yaml:"speakers" toml:"speakers"`
+}
+
+// Speakers configures speaker identification; see voxa.SpeakersConfig.
+// Without a file, enrolled voiceprints are lost on restart.
+type Speakers struct {
+	File      string  `yaml:"file" toml:"file"`
+	Threshold float64 `yaml:"threshold" toml:"threshold"`
+}
+
+// LanguageID configures language identification by the recognizer; see
+// voxa.LanguageIDConfig.
+type LanguageID struct {
+	Window    time.Duration `yaml:"window" toml:"window"`
+	MinSpeech time.Duration `yaml:"min_speech" toml:"min_speech"`
+	Threshold float32       `yaml:"threshold" toml:"threshold"`
+	Fallback  string        `yaml:"fallback" toml:"fallback"`
+	Languages []string      `yaml:"languages" toml:"languages"`
+}
+
+// Translation configures transcript translation; see
+// voxa.TranslationConfig.
+type Translation struct {
+	// Provider is a registered translation provider. Defaults to
+	// LibreTranslate.
+	Provider string   `yaml:"provider" toml:"provider"`
+	Options  Options  `yaml:"options" toml:"options"`
+	Source   string   `yaml:"source" toml:"source"`
+	Targets  []string `yaml:"targets" toml:"targets"`
+	// RateLimit, if set, holds the provider to the quotas of its account.
+	RateLimit *RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+}
+
+// Vocabulary configures the custom vocabulary; see voxa.VocabularyConfig.
+type Vocabulary struct {
+	Phrases []Phrase `yaml:"phrases" toml:"phrases"`
+	// File lists more phrases, one per line, each optionally followed by a
+	// tab and its boost. Blank lines and lines starting with # are
+	// skipped.
+	File      string  `yaml:"file" toml:"file"`
+	Tolerance float64 `yaml:"tolerance" toml:"tolerance"`
+	Always    bool    `yaml:"always" toml:"always"`
+}
+
+// Phrase is a phrase of the vocabulary.
+type Phrase struct {
+	Text  string  `yaml:"text" toml:"text"`
+	Boost float32 `yaml:"boost" toml:"boost"`
+}
+
+func (c *Vocabulary) config() (voxa.VocabularyConfig, error) {
+	cfg := voxa.VocabularyConfig{Tolerance: c.Tolerance, Always: c.Always}
+	for _, p := range c.Phrases {
+		cfg.Phrases = append(cfg.Phrases, voxa.Phrase{Text: p.Text, Boost: p.Boost})
+	}
+	if c.File != "" {
+		ps, err := loadPhrases(c.File)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Phrases = append(cfg.Phrases, ps...)
+	}
+	return cfg, nil
+}
+
+// Punctuation configures punctuation restoration; see
+// voxa.PunctuationConfig.
+type Punctuation struct {
+	Language  string   `yaml:"language" toml:"language"`
+	Languages []string `yaml:"languages" toml:"languages"`
+	// Words are extra words written as given, by language.
+	Words map[string][]string `yaml:"words" toml:"words"`
+	Pause time.Duration       `yaml:"pause" toml:"pause"`
+	// Model is the http or https endpoint of a punctuation model for final
+	// segments; see punctuate.HTTPModel.
+	Model   string        `yaml:"model" toml:"model"`
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+func (c *Punctuation) config() (voxa.PunctuationConfig, error) {
+	cfg := voxa.PunctuationConfig{
+		Language:  c.Language,
+		Languages: c.Languages,
+		Words:     c.Words,
+		Pause:     c.Pause,
+		Timeout:   c.Timeout,
+	}
+	if c.Model != "" {
+		m, err := voxa.NewHTTPPunctuationModel(c.Model)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Model = m
+	}
+	return cfg, nil
+}
+
+// Normalization configures inverse text normalization; see
+// voxa.NormalizationConfig.
+type Normalization struct {
+	Locale string `yaml:"locale" toml:"locale"`
+	// Disable lists the entity classes left spelled out: cardinal,
+	// ordinal, decimal, percent, currency, date or time.
+	Disable []string `yaml:"disable" toml:"disable"`
+}
+
+func (c *Normalization) config() voxa.NormalizationConfig {
+	cfg := voxa.NormalizationConfig{Locale: c.Locale}
+	for _, d := range c.Disable {
+		cfg.Disable = append(cfg.Disable, voxa.NormalizationClass(d))
+	}
+	return cfg
+}
+
+// Sentiment configures sentiment analysis; see voxa.SentimentConfig.
+type Sentiment struct {
+	Language  string   `yaml:"language" toml:"language"`
+	Languages []string `yaml:"languages" toml:"languages"`
+	// Model is the http or https endpoint of a sentiment model; see
+	// sentiment.HTTPModel.
+	Model   string        `yaml:"model" toml:"model"`
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	// Prosody also analyzes the voice, for the emotion of segments.
+	Prosody bool `yaml:"prosody" toml:"prosody"`
+}
+
+func (c *Sentiment) config() (voxa.SentimentConfig, error) {
+	cfg := voxa.SentimentConfig{
+		Language:  c.Language,
+		Languages: c.Languages,
+		Timeout:   c.Timeout,
+		Prosody:   c.Prosody,
+	}
+	if c.Model != "" {
+		m, err := voxa.NewHTTPSentimentModel(c.Model)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Model = m
+	}
+	return cfg, nil
+}
+
+// Profanity configures the profanity filter; see voxa.ProfanityConfig.
+type Profanity struct {
+	// Mode is mask, drop or tag. Defaults to mask.
+	Mode     string   `yaml:"mode" toml:"mode"`
+	Patterns []string `yaml:"patterns" toml:"patterns"`
+	Allow    []string `yaml:"allow" toml:"allow"`
+}
+
+func (c *Profanity) config() (voxa.ProfanityConfig, error) {
+	cfg := voxa.ProfanityConfig{Patterns: c.Patterns, Allow: c.Allow}
+	if c.Mode == "" {
+		return cfg, nil
+	}
+	mode, err := voxa.ParseProfanityMode(c.Mode)
+	cfg.Mode = mode
+	return cfg, err
+}
+
+// Redaction configures the redaction of personal data; see
+// voxa.RedactionConfig.
+type Redaction struct {
+	// Entities are credit_card, ssn, phone or email. Empty selects all.
+	Entities []string `yaml:"entities" toml:"entities"`
+	// Patterns maps entity names of the deployment's own to regular
+	// expressions.
+	Patterns Options `yaml:"patterns" toml:"patterns"`
+}
+
+func (c *Redaction) config() voxa.RedactionConfig {
+	cfg := voxa.RedactionConfig{Patterns: c.Patterns}
+	for _, e := range c.Entities {
+		cfg.Entities = append(cfg.Entities, voxa.PIIEntity(e))
+	}
+	return cfg
+}
+
+// Devices pins the audio devices, by ID or name.
+type Devices struct {
+	Input  string `yaml:"input" toml:"input"`
+	Output string `yaml:"output" toml:"output"`
+	// InputChannels is how many channels are captured from the input;
+	// see voxa.Config.InputChannels.
+	InputChannels int `yaml:"input_channels" toml:"input_channels"`
+}
+
+// Sessions configures the session store.
+type Sessions struct {
+	// Store is memory or redis. Defaults to memory.
+	Store string `yaml:"store" toml:"store"`
+	// URL locates the redis store.
+	URL string `yaml:"url" toml:"url"`
+	// TTL is how long idle conversations are kept. Defaults to 30 minutes.
+	TTL time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+// Transcripts configures the transcript store.
+type Transcripts struct {
+	// Store is sqlite or postgres.
+	Store string `yaml:"store" toml:"store"`
+	// URL is the SQLite database file, or the postgres:// URL of the
+	// PostgreSQL database.
+	URL string `yaml:"url" toml:"url"`
+	// Summary, if set, summarizes every session when it ends.
+	Summary *Summary `yaml:"summary" toml:"summary"`
+}
+
+// Summary configures the summaries of completed sessions; see
+// voxa.SummaryConfig and voxa.SummarizerConfig.
+type Summary struct {
+	// Provider is a registered language model backend. Defaults to openai,
+	// whose options are endpoint, model, api_key, defaulting to
+	// $OPENAI_API_KEY, and temperature.
+	Provider     string        `yaml:"provider" toml:"provider"`
+	Options      Options       `yaml:"options" toml:"options"`
+	Instructions string        `yaml:"instructions" toml:"instructions"`
+	MaxChars     int           `yaml:"max_chars" toml:"max_chars"`
+	Timeout      time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+func (s *Summary) config() voxa.SummarizerConfig {
+	return voxa.SummarizerConfig{Provider: s.Provider, Options: s.Options, Instructions: s.Instructions, MaxChars: s.MaxChars}
+}
+
+// Archive configures audio archival; see voxa.ArchiveConfig. Archives go
+// to Dir or, if set, S3.
+type Archive struct {
+	// Dir is the directory archives are written under.
+	Dir string `yaml:"dir" toml:"dir"`
+	// S3 uploads archives to a bucket. Credentials come from
+	// $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN.
+	S3 *S3 `yaml:"s3" toml:"s3"`
+	// Format is flac, wav or opus. Defaults to flac.
+	Format string `yaml:"format" toml:"format"`
+	// Rotate, if set, starts a new file after this much audio.
+	Rotate time.Duration `yaml:"rotate" toml:"rotate"`
+	// Name is the template of archive names. Defaults to
+	// voxa.DefaultArchiveName.
+	Name string `yaml:"name" toml:"name"`
+	// Bitrate is the Opus bitrate in bits per second.
+	Bitrate int `yaml:"bitrate" toml:"bitrate"`
+	// Spool is where files are recorded before they are stored. Defaults
+	// to the system temporary directory.
+	Spool string `yaml:"spool" toml:"spool"`
+}
+
+// S3 locates an S3-compatible bucket; see voxa.ArchiveS3Config.
+type S3 struct {
+	Bucket string `yaml:"bucket" toml:"bucket"`
+	Prefix string `yaml:"prefix" toml:"prefix"`
+	Region string `yaml:"region" toml:"region"`
+	// Endpoint is the URL of a service other than AWS, such as MinIO.
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+}
+
+// config returns the archive configuration, opening its storage.
+func (a *Archive) config() (voxa.ArchiveConfig, error) {
+	cfg := voxa.ArchiveConfig{Rotate: a.Rotate, Name: a.Name, Bitrate: a.Bitrate, Spool: a.Spool}
+	var err error
+	if a.Format != "" {
+		if cfg.Format, err = voxa.ParseArchiveFormat(a.Format); err != nil {
+			return cfg, err
+		}
+	}
+	if a.S3 != nil {
+		cfg.Storage, err = voxa.NewArchiveS3(voxa.ArchiveS3Config{
+			Bucket:   a.S3.Bucket,
+			Prefix:   a.S3.Prefix,
+			Region:   a.S3.Region,
+			Endpoint: a.S3.Endpoint,
+		})
+	} else {
+		cfg.Storage, err = voxa.NewArchiveDir(a.Dir)
+	}
+	return cfg, err
+}
+
+// Encryption configures the encryption at rest of archives and
+// transcripts; see voxa.EncryptionConfig.
+type Encryption struct {
+	// Provider is the key provider: local, whose options are keys,
+	// defaulting to $VOXA_ENCRYPTION_KEYS, and current; vault, whose
+	// options are addr, token, mount and key; or awskms, whose options are
+	// key, region and endpoint.
+	Provider        string        `yaml:"provider" toml:"provider"`
+	Options         Options       `yaml:"options" toml:"options"`
+	DataKeyLifetime time.Duration `yaml:"data_key_lifetime" toml:"data_key_lifetime"`
+}
+
+func (e *Encryption) config() voxa.EncryptionConfig {
+	return voxa.EncryptionConfig{Provider: e.Provider, Options: e.Options, DataKeyLifetime: e.DataKeyLifetime}
+}
+
+// Retention sets how long sessions are kept; see voxa.RetentionConfig.
+type Retention struct {
+	// MaxAge is how long sessions are kept, unless their tenant is listed
+	// in Tenants. Zero keeps them.
+	MaxAge time.Duration `yaml:"max_age" toml:"max_age"`
+	// Tenants are the times the sessions of API keys are kept, by key
+	// name, zero keeping them.
+	Tenants  map[string]time.Duration `yaml:"tenants" toml:"tenants"`
+	Interval time.Duration            `yaml:"interval" toml:"interval"`
+}
+
+func (r *Retention) config() voxa.RetentionConfig {
+	return voxa.RetentionConfig{MaxAge: r.MaxAge, Tenants: r.Tenants, Interval: r.Interval}
+}
+
+// Degraded configures degraded mode; see voxa.DegradedConfig. The
+// sessions deferred are transcribed by a backlog per profile; see
+// reprocess.Backlog.
+type Degraded struct {
+	// Interval is how often the backlogs look for deferred sessions and
+	// check on the recognizer. Defaults to a minute.
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+	// Window is how long ago the sessions looked for may have started.
+	// Defaults to a week.
+	Window time.Duration `yaml:"window" toml:"window"`
+	// Workers is the number of deferred sessions of a backlog transcribed
+	// in parallel. Defaults to 1.
+	Workers int `yaml:"workers" toml:"workers"`
+}
+
+// Budget is the latency budget of a stage; see voxa.StageBudget.
+type Budget struct {
+	Latency time.Duration `yaml:"latency" toml:"latency"`
+	// Action is observe, skip or shed. Defaults to observe.
+	Action   string        `yaml:"action" toml:"action"`
+	Cooldown time.Duration `yaml:"cooldown" toml:"cooldown"`
+}
+
+func (b Budget) config() (voxa.StageBudget, error) {
+	action, err := voxa.ParseBudgetAction(b.Action)
+	if err != nil {
+		return voxa.StageBudget{}, err
+	}
+	return voxa.StageBudget{Latency: b.Latency, Action: action, Cooldown: b.Cooldown}, nil
+}
+
+// Buffer queues the audio of every stream; see voxa.BufferConfig.
+type Buffer struct {
+	Frames int `yaml:"frames" toml:"frames"`
+	// Overflow is block, drop-oldest or drop-newest. Defaults to block.
+	Overflow string `yaml:"overflow" toml:"overflow"`
+}
+
+// Alert is a keyword spotting rule; see voxa.AlertRule.
+type Alert struct {
+	Name     string   `yaml:"name" toml:"name"`
+	Keywords []string `yaml:"keywords" toml:"keywords"`
+	Patterns []string `yaml:"patterns" toml:"patterns"`
+	Partials bool     `yaml:"partials" toml:"partials"`
+	// Actions are where the alerts of the rule go; at least one is
+	// required.
+	Actions []Action `yaml:"actions" toml:"actions"`
+}
+
+// Action is where alerts go. PipelineConfig leaves actions to voxad, which
+// runs the sinks.
+type Action struct {
+	// Type is log, webhook or mqtt. Log writes a warning to the log; mqtt
+	// publishes to <prefix>/alert/<rule> through server.mqtt.
+	Type string `yaml:"type" toml:"type"`
+	// URL is where a webhook action POSTs alerts, signed as server.webhook
+	// is.
+	URL string `yaml:"url" toml:"url"`
+}
+
+// Error lists the problems of a file.
+type Error struct {
+	// Path is the file, if the deployment was loaded from one.
+	Path string
+	// Problems are the complaints, each prefixed with the key concerned.
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("config: ")
+	if e.Path != "" {
+		b.WriteString(e.Path + ": ")
+	}
+	if len(e.Problems) == 1 {
+		b.WriteString(e.Problems[0])
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d problems:", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n\t" + p)
+	}
+	return b.String()
+}
+
+// Load reads and validates the deployment in a file, YAML or TOML
+// according to its extension.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	var f File
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+			return nil, &Error{Path: path, Problems: yamlProblems(err)}
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), &f)
+		if err != nil {
+			return nil, &Error{Path: path, Problems: []string{err.Error()}}
+		}
+		if keys := md.Undecoded(); len(keys) > 0 {
+			e := &Error{Path: path}
+			for _, k := range keys {
+				e.Problems = append(e.Problems, fmt.Sprintf("%s: unknown key", k))
+			}
+			return nil, e
+		}
+	default:
+		return nil, fmt.Errorf("config: %s: unknown format %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err := f.Validate(); err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			e.Path = path
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+// unknownField matches the complaint of yaml.v3 about unknown keys, which
+// names Go types rather than sections.
+var unknownField = regexp.MustCompile(`^(line \d+: )field (\S+) not found in type \S+$`)
+
+// yamlProblems splits the problems yaml.v3 reports together.
+func yamlProblems(err error) []string {
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		ps := make([]string, len(te.Errors))
+		for i, e := range te.Errors {
+			ps[i] = unknownField.ReplaceAllString(e, "${1}unknown key $2")
+		}
+		return ps
+	}
+	return []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+}
+
+// problems collects the complaints of Validate.
+type problems []string
+
+func (p *problems) add(key, format string, args ...any) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+// check adds err, if any, under key, without the "pkg: " prefix of the
+// package that returned it.
+func (p *problems) check(key, pkg string, err error) {
+	if err != nil {
+		p.add(key, "%s", strings.TrimPrefix(err.Error(), pkg+": "))
+	}
+}
+
+// Validate fills in the defaults of f, loads its Go plugins and checks it,
+// returning an *Error listing every problem found. Load calls it;
+// deployments built in code should too before they are used.
+func (f *File) Validate() error {
+	if f.Server.GRPC == "" {
+		f.Server.GRPC = DefaultGRPC
+	}
+	if f.Server.DrainTimeout == 0 {
+		f.Server.DrainTimeout = DefaultDrainTimeout
+	}
+	if f.Logging.Level == "" {
+		f.Logging.Level = "info"
+	}
+	if f.Logging.Format == "" {
+		f.Logging.Format = "text"
+	}
+	if f.Recognizer.Provider == "" {
+		f.Recognizer.Provider = asr.ProviderName
+	}
+
+	var p problems
+	// Plugins may register the stages named below.
+	for i, path := range f.Plugins {
+		p.check(fmt.Sprintf("plugins[%d]", i), "plugin", plugin.Open(path))
+	}
+	if f.Server.Metrics && f.Server.HTTP == "" {
+		p.add("server.metrics", "needs server.http")
+	}
+	if f.Server.UI && f.Server.HTTP == "" {
+		p.add("server.ui", "needs server.http")
+	}
+	if a := f.Server.Pprof; a != "" {
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			p.add("server.pprof", "bad address %q", a)
+		} else if a == f.Server.HTTP || a == f.Server.GRPC {
+			p.add("server.pprof", "address %q already in use by another listener", a)
+		}
+	}
+	if f.Server.RTP != "" {
+		if _, err := net.ResolveUDPAddr("udp", f.Server.RTP); err != nil {
+			p.add("server.rtp", "bad address %q", f.Server.RTP)
+		}
+	}
+	if o := f.Server.RTPOpus; o != 0 && (o < 96 || o > 127) {
+		p.add("server.rtp_opus", "payload type %d is not dynamic (96-127)", o)
+	}
+	if t := f.Server.Twilio; t != nil {
+		if u, err := url.Parse(t.Callback); t.Callback == "" {
+			p.add("server.twilio.callback", "required")
+		} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.add("server.twilio.callback", "want an http or https URL, got %q", t.Callback)
+		}
+		if f.Server.HTTP == "" {
+			p.add("server.twilio", "needs server.http")
+		}
+		checkRetry(&p, "server.twilio.retry", t.Retry)
+		if n := t.Notice; n != nil {
+			switch {
+			case n.Text != "" && n.File != "":
+				p.add("server.twilio.notice", "set text or file, not both")
+			case n.File != "":
+				checkFile(&p, "server.twilio.notice.file", n.File)
+			case n.Text == "":
+				p.add("server.twilio.notice", "needs a text or a file")
+			case f.Synthesizer == nil:
+				p.add("server.twilio.notice.text", "needs a synthesizer")
+			}
+			if n.Timeout < 0 {
+				p.add("server.twilio.notice.timeout", "negative duration %v", n.Timeout)
+			}
+		}
+	}
+	if w := f.Server.WebRTC; w != nil {
+		if f.Server.HTTP == "" {
+			p.add("server.webrtc", "needs server.http")
+		}
+		for i, u := range w.ICEServers {
+			if !strings.HasPrefix(u, "stun:") && !strings.HasPrefix(u, "turn:") && !strings.HasPrefix(u, "turns:") {
+				p.add(fmt.Sprintf("server.webrtc.ice_servers[%d]", i), "want a stun:, turn: or turns: URL, got %q", u)
+			}
+		}
+		if w.UDP != "" {
+			if _, err := net.ResolveUDPAddr("udp", w.UDP); err != nil {
+				p.add("server.webrtc.udp", "bad address %q", w.UDP)
+			}
+		}
+		if w.MaxPeers < 0 {
+			p.add("server.webrtc.max_peers", "negative count %d", w.MaxPeers)
+		}
+	}
+	if m := f.Server.MQTT; m != nil {
+		if u, err := url.Parse(m.Broker); m.Broker == "" {
+			p.add("server.mqtt.broker", "required")
+		} else if err != nil || (u.Scheme != "tcp" && u.Scheme != "ssl" && u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			p.add("server.mqtt.broker", "want a tcp, ssl, ws or wss URL, got %q", m.Broker)
+		}
+		if strings.ContainsAny(m.Prefix, "+#") || strings.HasSuffix(m.Prefix, "/") {
+			p.add("server.mqtt.prefix", "invalid topic prefix %q", m.Prefix)
+		}
+		if m.QoS < 0 || m.QoS > 2 {
+			p.add("server.mqtt.qos", "want 0, 1 or 2, got %d", m.QoS)
+		}
+	}
+	if w := f.Server.Webhook; w != nil {
+		if u, err := url.Parse(w.URL); w.URL == "" {
+			p.add("server.webhook.url", "required")
+		} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.add("server.webhook.url", "want an http or https URL, got %q", w.URL)
+		}
+		checkEvents(&p, "server.webhook", "", w.Events, w.QueueSize)
+		checkRetry(&p, "server.webhook.retry", w.Retry)
+	}
+	if e := f.Server.SSE; e != nil {
+		if f.Server.HTTP == "" {
+			p.add("server.sse", "needs server.http")
+		}
+		if e.Buffer < 0 {
+			p.add("server.sse.buffer", "negative size %d", e.Buffer)
+		}
+		if e.Retention < 0 {
+			p.add("server.sse.retention", "negative duration %v", e.Retention)
+		}
+	}
+	if a := f.Server.Auth; a != nil {
+		if len(a.Keys) == 0 {
+			p.add("server.auth.keys", "required")
+		}
+		names := map[string]bool{}
+		for i, k := range a.Keys {
+			key := fmt.Sprintf("server.auth.keys[%d]", i)
+			switch {
+			case k.Name == "":
+				p.add(key+".name", "required")
+			case names[k.Name]:
+				p.add(key+".name", "duplicate key %q", k.Name)
+			}
+			names[k.Name] = true
+			if b, err := hex.DecodeString(k.SHA256); err != nil || len(b) != sha256.Size {
+				p.add(key+".sha256", "want the hex SHA-256 of the key")
+			}
+			if k.Rate < 0 {
+				p.add(key+".rate", "negative rate %v", k.Rate)
+			}
+			if k.Burst < 0 {
+				p.add(key+".burst", "negative size %d", k.Burst)
+			}
+			if k.Sessions < 0 {
+				p.add(key+".sessions", "negative count %d", k.Sessions)
+			}
+			if _, err := voxa.ParsePriority(k.MaxPriority); err != nil {
+				p.add(key+".max_priority", "%v", err)
+			}
+			if slices.Contains(k.Languages, "") {
+				p.add(key+".languages", "empty language")
+			}
+			if slices.Contains(k.Models, "") {
+				p.add(key+".models", "empty model")
+			}
+			for _, name := range k.Profiles {
+				if _, ok := f.Profiles[name]; !ok {
+					p.add(key+".profiles", "no profile %q", name)
+				}
+			}
+		}
+	}
+	if k := f.Server.Kafka; k != nil {
+		if len(k.Brokers) == 0 {
+			p.add("server.kafka.brokers", "required")
+		}
+		for i, b := range k.Brokers {
+			if _, _, err := net.SplitHostPort(b); err != nil {
+				p.add(fmt.Sprintf("server.kafka.brokers[%d]", i), "want host:port, got %q", b)
+			}
+		}
+		if k.Topic == "" {
+			p.add("server.kafka.topic", "required")
+		}
+		checkEvents(&p, "server.kafka", k.Encoding, k.Events, k.QueueSize)
+	}
+	if n := f.Server.NATS; n != nil {
+		if n.URL == "" {
+			p.add("server.nats.url", "required")
+		}
+		if strings.ContainsAny(n.Prefix, "*> \t") || strings.HasPrefix(n.Prefix, ".") || strings.HasSuffix(n.Prefix, ".") {
+			p.add("server.nats.prefix", "invalid subject prefix %q", n.Prefix)
+		}
+		checkEvents(&p, "server.nats", n.Encoding, n.Events, n.QueueSize)
+	}
+	if _, err := logging.New(io.Discard, f.Logging.Level, f.Logging.Format); err != nil {
+		p.check("logging", "logging", err)
+	}
+
+	checkBackends(&p, "", f.Recognizer, f.Synthesizer, f.Stages)
+	for _, name := range slices.Sorted(maps.Keys(f.Profiles)) {
+		pr, key := f.Profiles[name], "profiles."+name
+		if name == "" {
+			p.add(key, "empty name")
+		}
+		if name == voxa.DefaultBacklog && f.Degraded != nil {
+			p.add(key, "name taken by the backlog of the file in degraded mode")
+		}
+		if pr.Recognizer == nil && pr.Synthesizer == nil && pr.Stages == nil {
+			p.add(key, "overrides none of recognizer, synthesizer and stages")
+			continue
+		}
+		if r := pr.Recognizer; r != nil && r.Provider == "" {
+			r.Provider = asr.ProviderName
+		}
+		pf := f.Profile(name)
+		checkBackends(&p, key+".", pf.Recognizer, pf.Synthesizer, pf.Stages)
+	}
+
+	if f.Devices.InputChannels < 0 || f.Devices.InputChannels > beam.MaxChannels {
+		p.add("devices.input_channels", "%d out of [0, %d]", f.Devices.InputChannels, beam.MaxChannels)
+	}
+
+	if s := f.Sessions; s != nil {
+		switch s.Store {
+		case "", "memory":
+			if s.URL != "" {
+				p.add("sessions.url", "only used by the redis store")
+			}
+		case "redis":
+			if s.URL == "" {
+				p.add("sessions.url", "required by the redis store")
+			} else if _, err := voxa.NewRedisSessionStore(s.URL); err != nil {
+				p.check("sessions.url", "session", err)
+			}
+		default:
+			p.add("sessions.store", "unknown store %q, want memory or redis", s.Store)
+		}
+		if s.TTL < 0 {
+			p.add("sessions.ttl", "negative duration %v", s.TTL)
+		}
+	}
+	if t := f.Transcripts; t != nil {
+		switch t.Store {
+		case "sqlite":
+			if t.URL == "" {
+				p.add("transcripts.url", "the SQLite database file is required")
+			}
+		case "postgres":
+			if !strings.HasPrefix(t.URL, "postgres://") && !strings.HasPrefix(t.URL, "postgresql://") {
+				p.add("transcripts.url", "want a postgres:// URL, got %q", t.URL)
+			}
+		default:
+			p.add("transcripts.store", "unknown store %q, want sqlite or postgres", t.Store)
+		}
+		if sum := t.Summary; sum != nil {
+			if sum.Provider == "" {
+				sum.Provider = openai.ProviderName
+			}
+			checkProvider(&p, "transcripts.summary.provider", sum.Provider, summarize.Providers())
+			if sum.MaxChars < 0 {
+				p.add("transcripts.summary.max_chars", "negative count %d", sum.MaxChars)
+			} else if slices.Contains(summarize.Providers(), sum.Provider) {
+				_, err := voxa.NewSummarizer(sum.config())
+				p.check("transcripts.summary", "summarize", err)
+			}
+			if sum.Timeout < 0 {
+				p.add("transcripts.summary.timeout", "negative duration %v", sum.Timeout)
+			}
+		}
+	}
+	if a := f.Archive; a != nil {
+		switch {
+		case a.Dir == "" && a.S3 == nil:
+			p.add("archive", "set dir or s3")
+		case a.Dir != "" && a.S3 != nil:
+			p.add("archive", "set only one of dir and s3")
+		case a.S3 != nil && a.S3.Bucket == "":
+			p.add("archive.s3.bucket", "required")
+		}
+		if a.Format != "" {
+			_, err := voxa.ParseArchiveFormat(a.Format)
+			p.check("archive.format", "archive", err)
+		}
+		if a.Rotate < 0 {
+			p.add("archive.rotate", "negative duration %v", a.Rotate)
+		}
+	}
+	if r := f.Retention; r != nil {
+		if f.Transcripts == nil {
+			p.add("retention", "requires transcripts")
+		}
+		if r.MaxAge < 0 {
+			p.add("retention.max_age", "negative duration %v", r.MaxAge)
+		}
+		if r.Interval < 0 {
+			p.add("retention.interval", "negative duration %v", r.Interval)
+		}
+		for _, name := range slices.Sorted(maps.Keys(r.Tenants)) {
+			if age := r.Tenants[name]; age < 0 {
+				p.add("retention.tenants."+name, "negative duration %v", age)
+			}
+			if a := f.Server.Auth; a != nil && !slices.ContainsFunc(a.Keys, func(k APIKey) bool { return k.Name == name }) {
+				p.add("retention.tenants."+name, "no API key of that name")
+			}
+		}
+	}
+	if d := f.Degraded; d != nil {
+		if f.Archive == nil || f.Transcripts == nil {
+			p.add("degraded", "requires archive and transcripts")
+		}
+		if d.Interval < 0 {
+			p.add("degraded.interval", "negative duration %v", d.Interval)
+		}
+		if d.Window < 0 {
+			p.add("degraded.window", "negative duration %v", d.Window)
+		}
+		if d.Workers < 0 {
+			p.add("degraded.workers", "negative count %d", d.Workers)
+		}
+	}
+	if e := f.Encryption; e != nil {
+		if f.Archive == nil && f.Transcripts == nil {
+			p.add("encryption", "nothing to encrypt without archive or transcripts")
+		}
+		checkProvider(&p, "encryption.provider", e.Provider, voxa.KeyProviders())
+		if e.DataKeyLifetime < 0 {
+			p.add("encryption.data_key_lifetime", "negative duration %v", e.DataKeyLifetime)
+		} else if slices.Contains(voxa.KeyProviders(), e.Provider) {
+			_, err := voxa.NewEncrypter(e.config())
+			p.check("encryption", "envelope", err)
+		}
+	}
+	if f.Intents != "" {
+		checkFile(&p, "intents", f.Intents)
+	}
+	names := map[string]bool{}
+	for i, a := range f.Alerts {
+		key := fmt.Sprintf("alerts[%d]", i)
+		if a.Name != "" && names[a.Name] {
+			p.add(key+".name", "duplicate rule %q", a.Name)
+		}
+		names[a.Name] = true
+		_, err := rules.New([]rules.Rule{{Name: a.Name, Keywords: a.Keywords, Patterns: a.Patterns}})
+		p.check(key, "rules", err)
+		if len(a.Actions) == 0 {
+			p.add(key+".actions", "required")
+		}
+		for j, act := range a.Actions {
+			key := fmt.Sprintf("%s.actions[%d]", key, j)
+			switch act.Type {
+			case "log":
+			case "webhook":
+				if u, err := url.Parse(act.URL); act.URL == "" {
+					p.add(key+".url", "required")
+				} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					p.add(key+".url", "want an http or https URL, got %q", act.URL)
+				}
+			case "mqtt":
+				if f.Server.MQTT == nil {
+					p.add(key, "needs server.mqtt")
+				}
+			default:
+				p.add(key+".type", "unknown action %q, want log, webhook or mqtt", act.Type)
+			}
+			if act.URL != "" && act.Type != "webhook" {
+				p.add(key+".url", "only used by webhook actions")
+			}
+		}
+	}
+	if b := f.Buffer; b != nil {
+		if b.Frames < 0 {
+			p.add("buffer.frames", "negative frame count %d", b.Frames)
+		}
+		if b.Overflow == "" {
+			b.Overflow = voxa.OverflowBlock.String()
+		}
+		if _, err := voxa.ParseOverflowPolicy(b.Overflow); err != nil {
+			p.add("buffer.overflow", "%v", err)
+		}
+	}
+	if f.Server.DrainTimeout < 0 {
+		p.add("server.drain_timeout", "negative duration %v", f.Server.DrainTimeout)
+	}
+	if c := f.Server.Costs; c != nil {
+		for _, name := range slices.Sorted(maps.Keys(c.Prices)) {
+			if pr := c.Prices[name]; pr.AudioMinute < 0 || pr.MillionCharacters < 0 {
+				p.add("server.costs.prices."+name, "negative price")
+			}
+		}
+	}
+	if c := f.Server.Cluster; c != nil {
+		if c.Node == "" {
+			c.Node, _ = os.Hostname()
+		}
+		if c.Node == "" {
+			p.add("server.cluster.node", "required")
+		}
+		if s := f.Sessions; s != nil && s.Store != "redis" {
+			p.add("sessions.store", "not shared between the nodes of server.cluster, want redis")
+		} else if c.URL == "" && s != nil {
+			c.URL = s.URL
+		}
+		if c.URL == "" {
+			p.add("server.cluster.url", "required")
+		} else if _, err := voxa.NewRedisSessionStore(c.URL); err != nil {
+			p.check("server.cluster.url", "session", err)
+		}
+		if c.LeaseTTL < 0 {
+			p.add("server.cluster.lease_ttl", "negative duration %v", c.LeaseTTL)
+		}
+		if c.ResumeWindow < 0 {
+			p.add("server.cluster.resume_window", "negative duration %v", c.ResumeWindow)
+		}
+	}
+	if f.Watch < 0 {
+		p.add("watch", "negative duration %v", f.Watch)
+	}
+	if len(p) > 0 {
+		return &Error{Problems: p}
+	}
+	return nil
+}
+
+// checkBackends checks the recognizer, synthesizer and stages of the
+// deployment, or of a profile, their keys starting with prefix.
+func checkBackends(p *problems, prefix string, r Recognizer, s *Synthesizer, st Stages) {
+	checkProvider(p, prefix+"recognizer.provider", r.Provider, stt.Providers())
+	checkRetry(p, prefix+"recognizer.retry", r.Retry)
+	checkRateLimit(p, prefix+"recognizer.rate_limit", r.RateLimit)
+	if r.LatencySLO < 0 {
+		p.add(prefix+"recognizer.latency_slo", "negative duration %v", r.LatencySLO)
+	} else if r.LatencySLO > 0 && len(r.Fallbacks) == 0 {
+		p.add(prefix+"recognizer.latency_slo", "needs recognizer.fallbacks to fail over to")
+	}
+	if r.ReplayWindow < 0 {
+		p.add(prefix+"recognizer.replay_window", "negative duration %v", r.ReplayWindow)
+	}
+	for i, fb := range r.Fallbacks {
+		key := prefix + fmt.Sprintf("recognizer.fallbacks[%d]", i)
+		checkProvider(p, key+".provider", fb.Provider, stt.Providers())
+		checkRetry(p, key+".retry", fb.Retry)
+		checkRateLimit(p, key+".rate_limit", fb.RateLimit)
+	}
+	if s != nil {
+		checkProvider(p, prefix+"synthesizer.provider", s.Provider, tts.Providers())
+		checkRetry(p, prefix+"synthesizer.retry", s.Retry)
+		checkRateLimit(p, prefix+"synthesizer.rate_limit", s.RateLimit)
+		if c := s.Cache; c != nil {
+			p.check(prefix+"synthesizer.cache", "tts", c.config().Validate())
+			if c.S3 != nil && c.S3.Bucket == "" {
+				p.add(prefix+"synthesizer.cache.s3.bucket", "required")
+			}
+		}
+		if n := s.Normalization.config(); n != nil {
+			_, err := tn.New(*n)
+			p.check(prefix+"synthesizer.normalization", "tn", err)
+		}
+		for i, fb := range s.Fallbacks {
+			key := prefix + fmt.Sprintf("synthesizer.fallbacks[%d]", i)
+			checkProvider(p, key+".provider", fb.Provider, tts.Providers())
+			checkRetry(p, key+".retry", fb.Retry)
+			checkRateLimit(p, key+".rate_limit", fb.RateLimit)
+		}
+	}
+
+	if st.Beamforming != nil {
+		_, err := beam.New(st.Beamforming.config(), audio.Format{SampleRate: 16000, Channels: beam.MaxChannels})
+		p.check(prefix+"stages.beamforming", "beam", err)
+	}
+	if st.EchoCancellation != nil {
+		c, err := aec.New(st.EchoCancellation.config(), 16000, aec.NewReference())
+		if err == nil {
+			c.Close()
+		}
+		p.check(prefix+"stages.echo_cancellation", "aec", err)
+	}
+	if st.DTMF != nil {
+		_, err := dtmf.New(st.DTMF.config(), 16000)
+		p.check(prefix+"stages.dtmf", "dtmf", err)
+	}
+	if st.Denoise != nil {
+		_, err := denoise.New(denoise.Config{Strength: st.Denoise.Strength}, 16000)
+		p.check(prefix+"stages.denoise", "denoise", err)
+	}
+	if st.AnsweringMachine != nil {
+		_, err := amd.New(st.AnsweringMachine.config(), 16000)
+		p.check(prefix+"stages.answering_machine", "amd", err)
+	}
+	if st.GainControl != nil {
+		_, err := agc.New(st.GainControl.config(), 16000)
+		p.check(prefix+"stages.gain_control", "agc", err)
+	}
+	if st.VAD != nil {
+		if m := st.VAD.Model; m != nil {
+			checkONNXModel(p, prefix+"stages.vad.model", m)
+		}
+		_, err := vad.New(st.VAD.config())
+		p.check(prefix+"stages.vad", "vad", err)
+	}
+	if st.Endpointing != nil {
+		_, err := endpoint.New(st.Endpointing.config(), vad.Config{})
+		p.check(prefix+"stages.endpointing", "endpoint", err)
+	}
+	if st.WakeWord != nil {
+		if m := st.WakeWord.Model; m != nil {
+			checkONNXModel(p, prefix+"stages.wake_word.model", m)
+			if len(m.Phrases) == 0 {
+				p.add(prefix+"stages.wake_word.model.phrases", "no wake word")
+			}
+		} else if len(st.WakeWord.Words) == 0 {
+			p.add(prefix+"stages.wake_word.words", "no wake word")
+		}
+		if st.WakeWord.ListenWindow < 0 {
+			p.add(prefix+"stages.wake_word.listen_window", "negative duration %v", st.WakeWord.ListenWindow)
+		}
+		for i, w := range st.WakeWord.Words {
+			key := prefix + fmt.Sprintf("stages.wake_word.words[%d]", i)
+			if w.Phrase == "" {
+				p.add(key+".phrase", "empty phrase")
+			}
+			if w.Sensitivity < 0 || w.Sensitivity > 1 {
+				p.add(key+".sensitivity", "%v out of range (0, 1]", w.Sensitivity)
+			}
+			if len(w.Templates) == 0 {
+				p.add(key+".templates", "no recording of %q", w.Phrase)
+			}
+			for j, t := range w.Templates {
+				checkFile(p, fmt.Sprintf("%s.templates[%d]", key, j), t)
+			}
+		}
+	}
+	if st.Diarization != nil {
+		_, err := diarize.New(st.Diarization.config(), 16000)
+		p.check(prefix+"stages.diarization", "diarize", err)
+		if sp := st.Diarization.Speakers; sp != nil {
+			_, err := speaker.New(speaker.Config{Threshold: sp.Threshold})
+			p.check(prefix+"stages.diarization.speakers", "speaker", err)
+		}
+	}
+	if l := st.LanguageID; l != nil {
+		switch {
+		case l.Window < 0 || l.MinSpeech < 0:
+			p.add(prefix+"stages.language_id", "negative duration")
+		case l.Window > 0 && l.MinSpeech > l.Window:
+			p.add(prefix+"stages.language_id.min_speech", "%v exceeds window %v", l.MinSpeech, l.Window)
+		}
+		if l.Threshold < 0 || l.Threshold > 1 {
+			p.add(prefix+"stages.language_id.threshold", "%v out of [0, 1]", l.Threshold)
+		}
+	}
+	if t := st.Translation; t != nil {
+		if t.Provider == "" {
+			t.Provider = libretranslate.ProviderName
+		}
+		checkProvider(p, prefix+"stages.translation.provider", t.Provider, translate.Providers())
+		if len(t.Targets) == 0 {
+			p.add(prefix+"stages.translation.targets", "no target language")
+		}
+		checkRateLimit(p, prefix+"stages.translation.rate_limit", t.RateLimit)
+	}
+	for i, a := range st.Audio {
+		checkProvider(p, prefix+fmt.Sprintf("stages.audio[%d].name", i), a.Name, plugin.AudioNames())
+	}
+	for i, t := range st.Transcript {
+		checkProvider(p, prefix+fmt.Sprintf("stages.transcript[%d].name", i), t.Name, plugin.TranscriptNames())
+	}
+	if st.Vocabulary != nil {
+		if c, err := st.Vocabulary.config(); err != nil {
+			p.add(prefix+"stages.vocabulary.file", "%v", err)
+		} else {
+			_, err := vocab.New(c)
+			p.check(prefix+"stages.vocabulary", "vocab", err)
+		}
+	}
+	if st.Punctuation != nil {
+		if c, err := st.Punctuation.config(); err != nil {
+			p.check(prefix+"stages.punctuation.model", "punctuate", err)
+		} else {
+			_, err := punctuate.New(c)
+			p.check(prefix+"stages.punctuation", "punctuate", err)
+		}
+	}
+	if st.Normalization != nil {
+		_, err := itn.New(st.Normalization.config())
+		p.check(prefix+"stages.normalization", "itn", err)
+	}
+	if st.Profanity != nil {
+		if c, err := st.Profanity.config(); err != nil {
+			p.add(prefix+"stages.profanity.mode", "%v", err)
+		} else {
+			_, err := profanity.New(c)
+			p.check(prefix+"stages.profanity", "profanity", err)
+		}
+	}
+	if st.Redaction != nil {
+		_, err := redact.New(st.Redaction.config())
+		p.check(prefix+"stages.redaction", "redact", err)
+	}
+	if st.Sentiment != nil {
+		if c, err := st.Sentiment.config(); err != nil {
+			p.check(prefix+"stages.sentiment.model", "sentiment", err)
+		} else {
+			_, err := sentiment.New(c)
+			p.check(prefix+"stages.sentiment", "sentiment", err)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(st.Budgets)) {
+		if c, err := st.Budgets[name].config(); err != nil {
+			p.check(prefix+"stages.budgets."+name+".action", "budget", err)
+		} else {
+			p.check(prefix+"stages.budgets", "budget", c.Validate(name))
+		}
+	}
+	if _, ok := qualities[st.Resample]; !ok {
+		p.add(prefix+"stages.resample_quality", "unknown quality %q, want low, medium or high", st.Resample)
+	}
+}
+
+func checkProvider(p *problems, key, name string, registered []string) {
+	if name == "" {
+		p.add(key, "required")
+	} else if !slices.Contains(registered, name) {
+		p.add(key, "unknown %q (registered: %s)", name, strings.Join(registered, ", "))
+	}
+}
+
+func checkRetry(p *problems, key string, r *Retry) {
+	if r != nil {
+		_, err := resilience.New(resilience.Config(*r), nil)
+		p.check(key, "resilience", err)
+	}
+}
+
+func checkRateLimit(p *problems, key string, r *RateLimit) {
+	if r != nil {
+		p.check(key, "ratelimit", r.config().Validate())
+	}
+}
+
+// checkEvents checks the encoding, event types and queue size of the
+// event sink at key.
+func checkEvents(p *problems, key, encoding string, events []string, queueSize int) {
+	if encoding != "" && encoding != "json" && encoding != "protobuf" {
+		p.add(key+".encoding", "want json or protobuf, got %q", encoding)
+	}
+	for i, e := range events {
+		switch t, err := voxa.ParseEventType(e); {
+		case err != nil:
+			p.add(fmt.Sprintf("%s.events[%d]", key, i), "unknown event type %q", e)
+		case t == voxa.EventVAD || t == voxa.EventError:
+			p.add(fmt.Sprintf("%s.events[%d]", key, i), "%s events only go to subscribers", e)
+		}
+	}
+	if queueSize < 0 {
+		p.add(key+".queue_size", "negative size %d", queueSize)
+	}
+}
+
+func checkONNXModel(p *problems, key string, m *ONNXModel) {
+	_, err := m.config()
+	p.check(key, "onnx", err)
+	if m.Threshold < 0 || m.Threshold > 1 {
+		p.add(key+".threshold", "%v out of range [0, 1]", m.Threshold)
+	}
+}
+
+func checkFile(p *problems, key, path string) {
+	if _, err := os.Stat(path); err != nil {
+		p.add(key, "%v", err)
+	}
+}
+
+var qualities = map[string]audio.Quality{
+	"":       audio.QualityMedium,
+	"low":    audio.QualityLow,
+	"medium": audio.QualityMedium,
+	"high":   audio.QualityHigh,
+}
+
+func (v *VAD) config() vad.Config {
+	return vad.Config{
+		Aggressiveness: vad.Aggressiveness(v.Aggressiveness),
+		MinSpeech:      v.MinSpeech,
+		Hangover:       v.Hangover,
+		PreRoll:        v.PreRoll,
+	}
+}
+
+func (e *Endpointing) config() endpoint.Config {
+	return endpoint.Config{
+		MinSilence:    e.MinSilence,
+		FinishSilence: e.FinishSilence,
+		MaxUtterance:  e.MaxUtterance,
+	}
+}
+
+func (d *Diarization) config() diarize.Config {
+	return diarize.Config{
+		Window:      d.Window,
+		MinWindow:   d.MinWindow,
+		Threshold:   d.Threshold,
+		MaxSpeakers: d.MaxSpeakers,
+	}
+}
+
+// PipelineConfig returns the pipeline f declares, loading the intent
+// definitions, wake word recordings, session store and transcript store it
+// names. Logger, Metrics and callbacks are left to the caller, as is
+// closing the transcript store.
+func (f *File) PipelineConfig() (voxa.Config, error) {
+	r := f.Recognizer
+	cfg := voxa.Config{
+		Recognizer: voxa.RecognizerConfig{
+			Provider:     r.Provider,
+			Options:      r.Options,
+			Resilience:   r.Retry.config(),
+			LatencySLO:   r.LatencySLO,
+			ReplayWindow: r.ReplayWindow,
+			RateLimit:    r.RateLimit.config(),
+		},
+		ResampleQuality: qualities[f.Stages.Resample],
+		InputDevice:     f.Devices.Input,
+		OutputDevice:    f.Devices.Output,
+		InputChannels:   f.Devices.InputChannels,
+	}
+	for _, fb := range r.Fallbacks {
+		cfg.Recognizer.Fallbacks = append(cfg.Recognizer.Fallbacks, voxa.RecognizerConfig{
+			Provider:   fb.Provider,
+			Options:    fb.Options,
+			Resilience: cmp.Or(fb.Retry, r.Retry).config(),
+			LatencySLO: r.LatencySLO,
+			RateLimit:  fb.RateLimit.config(),
+		})
+	}
+
+	st := f.Stages
+	if st.Beamforming != nil {
+		c := st.Beamforming.config()
+		cfg.Beamforming = &c
+	}
+	if st.EchoCancellation != nil {
+		c := st.EchoCancellation.config()
+		cfg.EchoCancellation = &c
+	}
+	if st.DTMF != nil {
+		c := st.DTMF.config()
+		cfg.DTMF = &c
+	}
+	if st.Denoise != nil {
+		cfg.Denoise = &voxa.DenoiseConfig{Strength: st.Denoise.Strength}
+	}
+	if st.AnsweringMachine != nil {
+		c := st.AnsweringMachine.config()
+		cfg.AnsweringMachine = &c
+	}
+	if st.GainControl != nil {
+		c := st.GainControl.config()
+		cfg.GainControl = &c
+	}
+	if st.VAD != nil {
+		c := st.VAD.config()
+		if m := st.VAD.Model; m != nil {
+			mc, err := m.config()
+			if err == nil {
+				c.Model, err = onnx.NewVAD(mc, m.Threshold)
+			}
+			if err != nil {
+				return voxa.Config{}, err
+			}
+		}
+		cfg.VAD = &c
+	}
+	if st.Endpointing != nil {
+		c := st.Endpointing.config()
+		cfg.Endpointing = &c
+	}
+	if st.WakeWord != nil {
+		cfg.WakeWord = &voxa.WakeWordConfig{ListenWindow: st.WakeWord.ListenWindow}
+		if m := st.WakeWord.Model; m != nil {
+			mc, err := m.config()
+			if err == nil {
+				cfg.WakeWord.Model, err = onnx.NewWakeWord(mc, m.Phrases, m.Threshold)
+			}
+			if err != nil {
+				return voxa.Config{}, err
+			}
+		}
+		for _, w := range st.WakeWord.Words {
+			word := voxa.WakeWord{Phrase: w.Phrase, Sensitivity: w.Sensitivity}
+			for _, path := range w.Templates {
+				t, err := loadTemplate(path)
+				if err != nil {
+					return voxa.Config{}, err
+				}
+				word.Templates = append(word.Templates, t)
+			}
+			cfg.WakeWord.Words = append(cfg.WakeWord.Words, word)
+		}
+	}
+	for _, a := range st.Audio {
+		cfg.AudioPlugins = append(cfg.AudioPlugins, voxa.PluginConfig{Name: a.Name, Options: a.Options})
+	}
+	for _, t := range st.Transcript {
+		cfg.TranscriptPlugins = append(cfg.TranscriptPlugins, voxa.PluginConfig{Name: t.Name, Options: t.Options})
+	}
+	if st.Vocabulary != nil {
+		c, err := st.Vocabulary.config()
+		if err != nil {
+			return voxa.Config{}, err
+		}
+		cfg.Vocabulary = &c
+	}
+	if st.Punctuation != nil {
+		c, err := st.Punctuation.config()
+		if err != nil {
+			return voxa.Config{}, err
+		}
+		cfg.Punctuation = &c
+	}
+	if st.Sentiment != nil {
+		c, err := st.Sentiment.config()
+		if err != nil {
+			return voxa.Config{}, err
+		}
+		cfg.Sentiment = &c
+	}
+	for name, b := range st.Budgets {
+		c, err := b.config()
+		if err != nil {
+			return voxa.Config{}, err
+		}
+		if cfg.Budgets == nil {
+			cfg.Budgets = map[string]voxa.StageBudget{}
+		}
+		cfg.Budgets[name] = c
+	}
+	if st.Normalization != nil {
+		c := st.Normalization.config()
+		cfg.Normalization = &c
+	}
+	if st.Profanity != nil {
+		c, err := st.Profanity.config()
+		if err != nil {
+			return voxa.Config{}, err
+		}
+		cfg.Profanity = &c
+	}
+	if st.Redaction != nil {
+		c := st.Redaction.config()
+		cfg.Redaction = &c
+	}
+	if st.Diarization != nil {
+		c := st.Diarization.config()
+		cfg.Diarization = &c
+	}
+	if l := st.LanguageID; l != nil {
+		cfg.LanguageID = &voxa.LanguageIDConfig{
+			Window:    l.Window,
+			MinSpeech: l.MinSpeech,
+			Threshold: l.Threshold,
+			Fallback:  l.Fallback,
+			Languages: l.Languages,
+		}
+	}
+	if t := st.Translation; t != nil {
+		cfg.Translation = &voxa.TranslationConfig{
+			Provider:  t.Provider,
+			Options:   t.Options,
+			Source:    t.Source,
+			Targets:   t.Targets,
+			RateLimit: t.RateLimit.config(),
+		}
+	}
+
+	if s := f.Sessions; s != nil {
+		cfg.SessionTTL = s.TTL
+		if s.Store == "redis" {
+			store, err := voxa.NewRedisSessionStore(s.URL)
+			if err != nil {
+				return voxa.Config{}, err
+			}
+			cfg.Sessions = store
+		} else {
+			cfg.Sessions = voxa.NewMemorySessionStore()
+		}
+	}
+	if f.Intents != "" {
+		parser, err := loadIntents(f.Intents)
+		if err != nil {
+			return voxa.Config{}, err
+		}
+		cfg.Intents = parser
+	}
+	for _, a := range f.Alerts {
+		cfg.Alerts = append(cfg.Alerts, voxa.AlertRule{Name: a.Name, Keywords: a.Keywords, Patterns: a.Patterns, Partials: a.Partials})
+	}
+	if b := f.Buffer; b != nil {
+		policy, err := voxa.ParseOverflowPolicy(b.Overflow)
+		if err != nil {
+			return voxa.Config{}, err
+		}
+		cfg.Buffer = &voxa.BufferConfig{Frames: b.Frames, Overflow: policy}
+	}
+	var enc *voxa.Encrypter
+	if e := f.Encryption; e != nil {
+		var err error
+		if enc, err = voxa.NewEncrypter(e.config()); err != nil {
+			return voxa.Config{}, err
+		}
+	}
+	if a := f.Archive; a != nil {
+		c, err := a.config()
+		if err != nil {
+			return voxa.Config{}, err
+		}
+		if enc != nil {
+			c.Storage = voxa.EncryptArchive(c.Storage, enc)
+		}
+		cfg.Archive = &c
+	}
+	if d := st.Diarization; d != nil && d.Speakers != nil {
+		var err error
+		cfg.Speakers, err = voxa.NewSpeakerRegistry(voxa.SpeakersConfig{File: d.Speakers.File, Threshold: d.Speakers.Threshold})
+		if err != nil {
+			return voxa.Config{}, err
+		}
+	}
+	// Opened last, so no error leaves it open.
+	if t := f.Transcripts; t != nil {
+		if sum := t.Summary; sum != nil {
+			sz, err := voxa.NewSummarizer(sum.config())
+			if err != nil {
+				return voxa.Config{}, err
+			}
+			cfg.Summary = &voxa.SummaryConfig{Summarizer: sz, Label: cmp.Or(sum.Options["model"], sum.Provider), Timeout: sum.Timeout}
+		}
+		var err error
+		if t.Store == "postgres" {
+			cfg.Transcripts, err = voxa.NewPostgresTranscriptStore(t.URL)
+		} else {
+			cfg.Transcripts, err = voxa.NewSQLiteTranscriptStore(t.URL)
+		}
+		if err != nil {
+			return voxa.Config{}, err
+		}
+		if enc != nil {
+			cfg.Transcripts = voxa.EncryptTranscripts(cfg.Transcripts, enc)
+		}
+		if r := f.Retention; r != nil {
+			c := r.config()
+			cfg.Retention = &c
+		}
+		if f.Degraded != nil && cfg.Archive != nil {
+			cfg.Degraded = &voxa.DegradedConfig{}
+		}
+	}
+	return cfg, nil
+}
+
+// DryRunConfig is PipelineConfig with the stores f names kept off the
+// network and disk: sessions in memory, transcripts in an in-memory SQLite
+// database, and no archive. It is meant for checking f, as by voxa
+// pipeline validate; the caller still closes the transcript store.
+func (f *File) DryRunConfig() (voxa.Config, error) {
+	dry := *f
+	if s := f.Sessions; s != nil {
+		dry.Sessions = &Sessions{Store: "memory", TTL: s.TTL}
+	}
+	if t := f.Transcripts; t != nil {
+		dry.Transcripts = &Transcripts{Store: "sqlite", URL: ":memory:", Summary: t.Summary}
+	}
+	dry.Archive = nil
+	return dry.PipelineConfig()
+}
+
+// Profile returns f as the sessions of profile name see it: the sections
+// the profile sets in place of those of f, and no profiles. It returns nil
+// if f has no such profile.
+func (f *File) Profile(name string) *File {
+	pr, ok := f.Profiles[name]
+	if !ok {
+		return nil
+	}
+	pf := *f
+	pf.Profiles = nil
+	if pr.Recognizer != nil {
+		pf.Recognizer = *pr.Recognizer
+	}
+	if pr.Synthesizer != nil {
+		pf.Synthesizer = pr.Synthesizer
+	}
+	if pr.Stages != nil {
+		pf.Stages = *pr.Stages
+	}
+	return &pf
+}
+
+// ProfileConfig is PipelineConfig for the sessions of profile name. It
+// opens none of the stores: the sessions, transcripts, archive, intents,
+// alerts and speaker registry are those of base, the config PipelineConfig
+// returned for f, and retention is left to the pipeline of base. In
+// degraded mode, sessions are deferred to the backlog named after the
+// profile.
+func (f *File) ProfileConfig(name string, base voxa.Config) (voxa.Config, error) {
+	pf := f.Profile(name)
+	if pf == nil {
+		return voxa.Config{}, fmt.Errorf("config: no profile %q", name)
+	}
+	pf.Sessions, pf.Transcripts, pf.Archive, pf.Encryption, pf.Retention = nil, nil, nil, nil, nil
+	pf.Intents, pf.Alerts = "", nil
+	cfg, err := pf.PipelineConfig()
+	if err != nil {
+		return voxa.Config{}, err
+	}
+	cfg.Sessions, cfg.SessionTTL = base.Sessions, base.SessionTTL
+	cfg.Transcripts, cfg.Summary, cfg.Archive = base.Transcripts, base.Summary, base.Archive
+	cfg.Intents, cfg.Alerts = base.Intents, base.Alerts
+	if cfg.Speakers != nil && base.Speakers != nil {
+		cfg.Speakers = base.Speakers
+	}
+	if d := base.Degraded; d != nil {
+		c := *d
+		c.Backlog = name
+		cfg.Degraded = &c
+	}
+	return cfg, nil
+}
+
+// BacklogConfig is PipelineConfig for transcribing the sessions deferred
+// in degraded mode to the backlog of profile name, or of f if name is
+// empty: a pipeline opening none of the stores and storing nothing, for a
+// reprocess queue.
+func (f *File) BacklogConfig(name string) (voxa.Config, error) {
+	bf := f
+	if name != "" {
+		if bf = f.Profile(name); bf == nil {
+			return voxa.Config{}, fmt.Errorf("config: no profile %q", name)
+		}
+	}
+	c := *bf
+	c.Sessions, c.Transcripts, c.Archive, c.Encryption, c.Retention, c.Degraded = nil, nil, nil, nil, nil, nil
+	c.Intents, c.Alerts = "", nil
+	return c.PipelineConfig()
+}
+
+// SynthesizerConfig returns the synthesizer f declares, or nil if
+// synthesis is disabled. Logger is left to the caller.
+func (f *File) SynthesizerConfig() *voxa.SynthesizerConfig {
+	s := f.Synthesizer
+	if s == nil {
+		return nil
+	}
+	cfg := &voxa.SynthesizerConfig{
+		Provider:      s.Provider,
+		Options:       s.Options,
+		Resilience:    s.Retry.config(),
+		RateLimit:     s.RateLimit.config(),
+		Cache:         s.Cache.config(),
+		Sentences:     s.Sentences,
+		Normalization: s.Normalization.config(),
+	}
+	for _, fb := range s.Fallbacks {
+		cfg.Fallbacks = append(cfg.Fallbacks, voxa.SynthesizerConfig{
+			Provider:   fb.Provider,
+			Options:    fb.Options,
+			Resilience: cmp.Or(fb.Retry, s.Retry).config(),
+			RateLimit:  fb.RateLimit.config(),
+		})
+	}
+	return cfg
+}
+
+// config converts r, which may be nil.
+func (r *Retry) config() *resilience.Config {
+	if r == nil {
+		return nil
+	}
+	c := resilience.Config(*r)
+	return &c
+}
+
+// config converts r, which may be nil.
+func (r *RateLimit) config() *ratelimit.Config {
+	if r == nil {
+		return nil
+	}
+	c := ratelimit.Config(*r)
+	return &c
+}
+
+// loadTemplate enrolls a wake word recording.
+func loadTemplate(path string) (wakeword.Template, error) {
+	f, err := audio.Open(path)
+	if err != nil {
+		return wakeword.Template{}, err
+	}
+	defer f.Close()
+	t, err := wakeword.NewTemplate(f)
+	if err != nil {
+		return wakeword.Template{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// loadPhrases reads a phrase file; see Vocabulary.File.
+func loadPhrases(path string) ([]voxa.Phrase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []voxa.Phrase
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		text, boost, ok := strings.Cut(line, "\t")
+		p := voxa.Phrase{Text: strings.TrimSpace(text)}
+		if ok {
+			b, err := strconv.ParseFloat(strings.TrimSpace(boost), 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: bad boost %q", path, i+1, boost)
+			}
+			p.Boost = float32(b)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// loadIntents compiles the intent definitions in a JSON file.
+func loadIntents(path string) (voxa.IntentParser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	defs, err := voxa.LoadIntents(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return voxa.NewIntentPatterns(defs)
+}
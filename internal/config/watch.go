@@ -0,0 +1,31 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+)
+
+// Watch calls fn every time the contents of the file at path change,
+// checking at the given interval until ctx is done. Saving the file
+// without changing it, or a read failing while an editor replaces it, is
+// not a change.
+func Watch(ctx context.Context, path string, every time.Duration, fn func()) {
+	last, _ := os.ReadFile(path)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		fn()
+	}
+}
@@ -0,0 +1,266 @@
+// Package cost accounts provider usage, the audio seconds streamed to
+// recognizers and the characters synthesized, to sessions and API keys,
+// and prices it from a table by provider.
+//
+// A Meter accumulates the usage of one session. It travels in the context
+// the session's streams and syntheses are opened with: the provider
+// wrappers of stt and tts record on the Meter of their context, under
+// their provider name, what they send each backend, including the audio
+// replayed into a reconnected one. Once the session ends, a Ledger charges
+// its usage to the session and its key. A Ledger is a prometheus.Collector
+// exporting the totals by key and provider.
+package cost
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Price is what a provider charges; zero is free.
+type Price struct {
+	// AudioMinute is the price of a minute of audio recognized.
+	AudioMinute float64
+	// MillionCharacters is the price of a million characters synthesized.
+	MillionCharacters float64
+}
+
+// Config is the price table of a Ledger.
+type Config struct {
+	// Currency labels the costs, such as USD.
+	Currency string
+	// Prices are by provider name. Unlisted providers are free, their
+	// usage still counted.
+	Prices map[string]Price
+}
+
+// Validate checks for negative prices.
+func (c Config) Validate() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Prices)) {
+		if p := c.Prices[name]; p.AudioMinute < 0 || p.MillionCharacters < 0 {
+			return fmt.Errorf("cost: %s: negative price", name)
+		}
+	}
+	return nil
+}
+
+// Usage is the use of one provider.
+type Usage struct {
+	Provider string
+	// Audio is the audio streamed to the provider.
+	Audio time.Duration
+	// Characters counts the characters synthesized by the provider.
+	Characters int64
+}
+
+// Meter accumulates the usage of a session, by provider. It is safe for
+// concurrent use; all methods are safe on a nil *Meter, which records
+// nothing.
+type Meter struct {
+	mu    sync.Mutex
+	usage map[string]Usage
+}
+
+// NewMeter returns an empty meter.
+func NewMeter() *Meter { return &Meter{usage: map[string]Usage{}} }
+
+// Audio records d of audio streamed to provider.
+func (m *Meter) Audio(provider string, d time.Duration) {
+	m.add(Usage{Provider: provider, Audio: d})
+}
+
+// Characters records n characters synthesized by provider.
+func (m *Meter) Characters(provider string, n int) {
+	m.add(Usage{Provider: provider, Characters: int64(n)})
+}
+
+func (m *Meter) add(u Usage) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.usage[u.Provider]
+	t.Provider = u.Provider
+	t.Audio += u.Audio
+	t.Characters += u.Characters
+	m.usage[u.Provider] = t
+}
+
+// Usage returns the usage recorded, by provider name.
+func (m *Meter) Usage() []Usage {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Usage, 0, len(m.usage))
+	for _, name := range slices.Sorted(maps.Keys(m.usage)) {
+		out = append(out, m.usage[name])
+	}
+	return out
+}
+
+type meterContext struct{}
+
+// NewContext returns ctx carrying m, for the providers used in ctx to
+// record on.
+func NewContext(ctx context.Context, m *Meter) context.Context {
+	return context.WithValue(ctx, meterContext{}, m)
+}
+
+// FromContext returns the meter of ctx, nil if there is none.
+func FromContext(ctx context.Context) *Meter {
+	m, _ := ctx.Value(meterContext{}).(*Meter)
+	return m
+}
+
+// maxSessions bounds the ended sessions a Ledger keeps.
+const maxSessions = 1000
+
+// Charge is the usage of a provider, priced.
+type Charge struct {
+	Usage
+	Cost float64
+}
+
+// Account is the usage charged to an API key, or, with Session set, to one
+// session of it.
+type Account struct {
+	// Key is the API key, empty for sessions opened without one.
+	Key     string
+	Session string
+	// Ended is when the session ended.
+	Ended   time.Time
+	Charges []Charge
+	// Cost sums the costs of Charges.
+	Cost float64
+}
+
+// Ledger prices usage and keeps the totals of every key since the server
+// started, and the accounts of the latest sessions to end. It is safe for
+// concurrent use.
+type Ledger struct {
+	cfg Config
+
+	mu       sync.Mutex
+	keys     map[string]map[string]Usage // by key, then provider
+	sessions []Account                   // oldest first
+}
+
+var _ prometheus.Collector = (*Ledger)(nil)
+
+// New returns a ledger pricing usage per cfg.
+func New(cfg Config) (*Ledger, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Ledger{cfg: cfg, keys: map[string]map[string]Usage{}}, nil
+}
+
+// Currency returns the currency of the prices.
+func (l *Ledger) Currency() string { return l.cfg.Currency }
+
+// Price returns the charges of usage and their sum.
+func (l *Ledger) Price(usage []Usage) ([]Charge, float64) {
+	charges := make([]Charge, 0, len(usage))
+	total := 0.0
+	for _, u := range usage {
+		p := l.cfg.Prices[u.Provider]
+		c := u.Audio.Minutes()*p.AudioMinute + float64(u.Characters)/1e6*p.MillionCharacters
+		charges = append(charges, Charge{Usage: u, Cost: c})
+		total += c
+	}
+	return charges, total
+}
+
+// Charge adds the usage of session, opened with key, to the key's totals,
+// and returns the session's account.
+func (l *Ledger) Charge(key, session string, usage []Usage) Account {
+	charges, total := l.Price(usage)
+	acct := Account{Key: key, Session: session, Ended: time.Now(), Charges: charges, Cost: total}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	byProvider := l.keys[key]
+	if byProvider == nil {
+		byProvider = map[string]Usage{}
+		l.keys[key] = byProvider
+	}
+	for _, u := range usage {
+		t := byProvider[u.Provider]
+		t.Provider = u.Provider
+		t.Audio += u.Audio
+		t.Characters += u.Characters
+		byProvider[u.Provider] = t
+	}
+	if len(l.sessions) == maxSessions {
+		l.sessions = slices.Delete(l.sessions, 0, 1)
+	}
+	l.sessions = append(l.sessions, acct)
+	return acct
+}
+
+// Keys returns the accounts of every key charged, by key.
+func (l *Ledger) Keys() []Account {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Account, 0, len(l.keys))
+	for _, key := range slices.Sorted(maps.Keys(l.keys)) {
+		byProvider := l.keys[key]
+		usage := make([]Usage, 0, len(byProvider))
+		for _, name := range slices.Sorted(maps.Keys(byProvider)) {
+			usage = append(usage, byProvider[name])
+		}
+		charges, total := l.Price(usage)
+		out = append(out, Account{Key: key, Charges: charges, Cost: total})
+	}
+	return out
+}
+
+// Sessions returns the accounts of the latest sessions to end, of key
+// only unless it is empty, latest first.
+func (l *Ledger) Sessions(key string) []Account {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []Account
+	for _, a := range slices.Backward(l.sessions) {
+		if key == "" || a.Key == key {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Metric descriptions.
+var (
+	audioDesc = prometheus.NewDesc("voxa_usage_audio_seconds_total",
+		"Audio streamed to recognizers by API key and provider, over ended sessions.", []string{"key", "provider"}, nil)
+	charactersDesc = prometheus.NewDesc("voxa_usage_characters_total",
+		"Characters synthesized by API key and provider, over ended sessions.", []string{"key", "provider"}, nil)
+	costDesc = prometheus.NewDesc("voxa_usage_cost_total",
+		"Cost of the provider usage by API key and provider, over ended sessions.", []string{"key", "provider", "currency"}, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (l *Ledger) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{audioDesc, charactersDesc, costDesc} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (l *Ledger) Collect(ch chan<- prometheus.Metric) {
+	currency := l.cfg.Currency
+	for _, a := range l.Keys() {
+		for _, c := range a.Charges {
+			ch <- prometheus.MustNewConstMetric(audioDesc, prometheus.CounterValue, c.Audio.Seconds(), a.Key, c.Provider)
+			ch <- prometheus.MustNewConstMetric(charactersDesc, prometheus.CounterValue, float64(c.Characters), a.Key, c.Provider)
+			ch <- prometheus.MustNewConstMetric(costDesc, prometheus.CounterValue, c.Cost, a.Key, c.Provider, currency)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+// Package diarize answers "who spoke when" for a single audio stream.
+//
+// The Diarizer stage watches the speech frames on their way to the
+// recognizer. Every Window of speech is summarized as a speaker embedding:
+// the mean and spread of its MFCCs (the classic statistics-pooling baseline,
+// capturing the vocal tract) plus pitch statistics. Embeddings are clustered
+// online: one that is close enough to a known speaker's centroid joins it,
+// anything else starts a new speaker until MaxSpeakers is reached.
+//
+// The pipeline marks utterance boundaries with Cut, so every utterance gets
+// the speaker who talked the most during it.
+//
+// Speakers are labelled "S1", "S2" and so on in order of appearance. With
+// an Identifier, a speaker whose centroid matches an enrolled voiceprint is
+// labelled with its name instead; Embed computes voiceprints the same way.
+package diarize
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/dsp"
+)
+
+// Config tunes the diarizer. Zero values select the defaults.
+type Config struct {
+	// Window is how much speech one embedding covers. Longer windows give
+	// steadier embeddings but react later to a change of speaker. Defaults
+	// to 3s.
+	Window time.Duration
+	// MinWindow is the shortest leftover at an utterance boundary that is
+	// still embedded. Defaults to 1s.
+	MinWindow time.Duration
+	// Threshold is the largest embedding distance at which speech is
+	// attributed to a known speaker. Lower splits speakers more eagerly.
+	// Defaults to 1.
+	Threshold float64
+	// MaxSpeakers caps the number of distinct speakers. Defaults to 8.
+	MaxSpeakers int
+	// Identifier, if set, names the speakers it recognizes. It is asked
+	// again whenever a speaker's centroid moves, so a name may come, go or
+	// change as the speaker talks.
+	Identifier Identifier
+}
+
+// Identifier recognizes speakers by their embedding.
+type Identifier interface {
+	// Identify returns the name of the speaker of embedding, or false if
+	// it matches no one. It must not keep embedding.
+	Identify(embedding []float64) (name string, ok bool)
+}
+
+func (c *Config) setDefaults() error {
+	if c.Window == 0 {
+		c.Window = 3 * time.Second
+	}
+	if c.MinWindow == 0 {
+		c.MinWindow = time.Second
+	}
+	if c.Threshold == 0 {
+		c.Threshold = 1
+	}
+	if c.MaxSpeakers == 0 {
+		c.MaxSpeakers = 8
+	}
+	switch {
+	case c.Window < 200*time.Millisecond:
+		return fmt.Errorf("diarize: window %v too short", c.Window)
+	case c.MinWindow > c.Window:
+		return errors.New("diarize: min window longer than window")
+	case c.Threshold < 0:
+		return fmt.Errorf("diarize: negative threshold %v", c.Threshold)
+	case c.MaxSpeakers < 1:
+		return errors.New("diarize: max speakers must be positive")
+	}
+	return nil
+}
+
+// Turn is a stretch of the stream attributed to one speaker.
+type Turn struct {
+	Speaker    string
+	Start, End time.Duration
+}
+
+// Diarizer is the diarization stage. Process and Cut are called from the
+// audio path; the other methods may be called concurrently.
+type Diarizer struct {
+	cfg  Config
+	rate int
+	mfcc *dsp.MFCC
+	hop  time.Duration
+	buf  []float64
+	tail []float64      // last pitchWindow of samples
+	pass [1]audio.Frame // returned by Process, reused
+
+	feats   [][]float64   // features of the embedding window being filled
+	pitches []float64     // log pitch of its voiced frames
+	start   time.Duration // stream time of feats[0]
+	end     time.Duration // stream time after the last processed frame
+
+	mu        sync.Mutex
+	speakers  []speaker
+	turns     []Turn
+	utterance map[string]time.Duration // speech per speaker since the last Cut
+	closed    []string                 // dominant speakers of cut utterances not yet taken
+}
+
+type speaker struct {
+	label    string
+	name     string // set by the Identifier
+	centroid []float64
+	n        int
+}
+
+// New creates a diarizer for mono audio at sampleRate.
+func New(cfg Config, sampleRate int) (*Diarizer, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	if sampleRate <= 0 {
+		return nil, errors.New("diarize: sample rate must be positive")
+	}
+	mcfg := dsp.MFCCConfig{SampleRate: sampleRate, Coefficients: 19}
+	return &Diarizer{
+		cfg:       cfg,
+		rate:      sampleRate,
+		mfcc:      dsp.NewMFCC(mcfg),
+		hop:       10 * time.Millisecond,
+		utterance: make(map[string]time.Duration),
+	}, nil
+}
+
+// Process records fr and passes it through unchanged.
+func (d *Diarizer) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if fr.Format.Channels != 1 {
+		return nil, fmt.Errorf("diarize: need mono audio, got %d channels", fr.Format.Channels)
+	}
+	if len(d.feats) == 0 {
+		d.start = fr.Offset
+	}
+	d.push(fr.Data)
+	d.end = fr.Offset + fr.Duration()
+	if d.pending() >= d.cfg.Window {
+		d.embed()
+	}
+	d.pass[0] = fr
+	return d.pass[:], nil
+}
+
+// push extracts the features of mono samples.
+func (d *Diarizer) push(pcm []int16) {
+	d.buf = dsp.Float(d.buf, pcm)
+	d.feats = append(d.feats, d.mfcc.Push(d.buf)...)
+	d.tail = append(d.tail, d.buf...)
+	if n := int(int64(d.rate) * int64(pitchWindow) / int64(time.Second)); len(d.tail) >= n {
+		d.tail = d.tail[len(d.tail)-n:]
+		if f0, ok := dsp.Pitch(d.tail, d.rate); ok {
+			d.pitches = append(d.pitches, math.Log(f0))
+		}
+	}
+}
+
+// pending returns how much speech the features not yet embedded cover.
+func (d *Diarizer) pending() time.Duration {
+	return time.Duration(len(d.feats)) * d.hop
+}
+
+// ErrTooShort is returned by Embed for less than MinWindow of speech.
+var ErrTooShort = errors.New("diarize: too little speech to embed")
+
+// Embed returns the embedding of a speaker from their speech, mono PCM at
+// sampleRate: the mean of the embeddings of its windows, as the diarizer
+// computes them, so it can be compared with the centroids of live
+// speakers. Silence should be cut out beforehand. The leftover after the
+// last full window counts if it is at least MinWindow long.
+func Embed(cfg Config, pcm []int16, sampleRate int) ([]float64, error) {
+	d, err := New(cfg, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	var sum []float64
+	var total float64
+	add := func() {
+		e := embedding(d.feats, d.pitches)
+		w := float64(len(d.feats))
+		if sum == nil {
+			sum = make([]float64, len(e))
+		}
+		for i, v := range e {
+			sum[i] += w * v
+		}
+		total += w
+		d.feats, d.pitches = d.feats[:0], d.pitches[:0]
+	}
+	step := audio.Format{SampleRate: sampleRate, Channels: 1}.Samples(audio.FrameDuration)
+	for len(pcm) > 0 {
+		n := min(step, len(pcm))
+		d.push(pcm[:n])
+		pcm = pcm[n:]
+		if d.pending() >= d.cfg.Window {
+			add()
+		}
+	}
+	if len(d.feats) > 0 && d.pending() >= d.cfg.MinWindow {
+		add()
+	}
+	if total == 0 {
+		return nil, ErrTooShort
+	}
+	for i := range sum {
+		sum[i] /= total
+	}
+	return sum, nil
+}
+
+// Cut marks the end of an utterance. Utterances without any speech are
+// ignored, so Cut may be called redundantly.
+func (d *Diarizer) Cut() {
+	if d.pending() >= d.cfg.MinWindow {
+		d.embed()
+	}
+	d.feats, d.pitches, d.tail = d.feats[:0], d.pitches[:0], d.tail[:0]
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.utterance) == 0 {
+		return
+	}
+	d.closed = append(d.closed, dominant(d.utterance))
+	d.utterance = make(map[string]time.Duration)
+}
+
+// Take returns the speaker of the oldest utterance whose final transcript
+// has not been labelled yet and forgets it. If every cut utterance has been
+// taken it returns the current speaker without consuming anything, so
+// callers can label partials with Peek and finals with Take.
+func (d *Diarizer) Take() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.closed) > 0 {
+		s := d.closed[0]
+		d.closed = d.closed[1:]
+		return d.named(s)
+	}
+	return d.named(dominant(d.utterance))
+}
+
+// Peek returns the speaker of the oldest unlabelled utterance, or the
+// current speaker, without consuming it.
+func (d *Diarizer) Peek() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.closed) > 0 {
+		return d.named(d.closed[0])
+	}
+	return d.named(dominant(d.utterance))
+}
+
+// Turns returns the speaker timeline so far, with consecutive windows of
+// the same speaker merged. Speakers are named as they are identified now.
+func (d *Diarizer) Turns() []Turn {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	turns := append([]Turn(nil), d.turns...)
+	for i := range turns {
+		turns[i].Speaker = d.named(turns[i].Speaker)
+	}
+	return turns
+}
+
+// named returns the name of the speaker with label, if identified, or the
+// label. d.mu must be held.
+func (d *Diarizer) named(label string) string {
+	for _, s := range d.speakers {
+		if s.label == label && s.name != "" {
+			return s.name
+		}
+	}
+	return label
+}
+
+// Speakers returns the number of distinct speakers seen so far.
+func (d *Diarizer) Speakers() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.speakers)
+}
+
+// embed turns the pending features into an embedding and attributes their
+// time span to a speaker.
+func (d *Diarizer) embed() {
+	if len(d.feats) == 0 {
+		return
+	}
+	e := embedding(d.feats, d.pitches)
+	start, end := d.start, d.end
+	d.feats, d.pitches = d.feats[:0], d.pitches[:0]
+	d.start = end
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	label := d.assign(e)
+	d.utterance[label] += end - start
+	if n := len(d.turns); n > 0 && d.turns[n-1].Speaker == label && start-d.turns[n-1].End < d.cfg.Window {
+		d.turns[n-1].End = end
+		return
+	}
+	d.turns = append(d.turns, Turn{Speaker: label, Start: start, End: end})
+}
+
+// assign clusters e and returns the speaker label.
+func (d *Diarizer) assign(e []float64) string {
+	best, dist := -1, math.Inf(1)
+	for i, s := range d.speakers {
+		if dd := distance(e, s.centroid); dd < dist {
+			best, dist = i, dd
+		}
+	}
+	if best < 0 || (dist > d.cfg.Threshold && len(d.speakers) < d.cfg.MaxSpeakers) {
+		d.speakers = append(d.speakers, speaker{
+			label:    fmt.Sprintf("S%d", len(d.speakers)+1),
+			centroid: e,
+			n:        1,
+		})
+		s := &d.speakers[len(d.speakers)-1]
+		d.identify(s)
+		return s.label
+	}
+	s := &d.speakers[best]
+	s.n++
+	for i := range s.centroid {
+		s.centroid[i] += (e[i] - s.centroid[i]) / float64(s.n)
+	}
+	d.identify(s)
+	return s.label
+}
+
+// identify names s after the enrolled speaker its centroid matches, if any.
+func (d *Diarizer) identify(s *speaker) {
+	if d.cfg.Identifier == nil {
+		return
+	}
+	s.name = ""
+	if name, ok := d.cfg.Identifier.Identify(s.centroid); ok {
+		s.name = name
+	}
+}
+
+// pitchWindow is the span pitch is estimated over: two periods of the
+// lowest pitch searched.
+const pitchWindow = 40 * time.Millisecond
+
+// pitchWeight scales the pitch block against the unit-length MFCC block, so
+// a 10% pitch difference weighs about as much as very different spectra.
+const pitchWeight = 8
+
+// embedding pools feats into their per-dimension mean and standard
+// deviation, scaled to unit length, followed by the weighted mean and
+// deviation of the log pitch.
+func embedding(feats [][]float64, pitches []float64) []float64 {
+	dim := len(feats[0])
+	e := make([]float64, 2*dim, 2*dim+2)
+	for _, f := range feats {
+		for i, v := range f {
+			e[i] += v
+		}
+	}
+	n := float64(len(feats))
+	for i := 0; i < dim; i++ {
+		e[i] /= n
+	}
+	for _, f := range feats {
+		for i, v := range f {
+			dv := v - e[i]
+			e[dim+i] += dv * dv
+		}
+	}
+	for i := 0; i < dim; i++ {
+		e[dim+i] = math.Sqrt(e[dim+i] / n)
+	}
+	var norm float64
+	for _, v := range e {
+		norm += v * v
+	}
+	if norm = math.Sqrt(norm); norm > 0 {
+		for i := range e {
+			e[i] /= norm
+		}
+	}
+
+	// Unvoiced windows get a neutral 150Hz so they neither attract nor
+	// repel speakers much.
+	mean, std := math.Log(150), 0.0
+	if len(pitches) > 0 {
+		mean = 0
+		for _, p := range pitches {
+			mean += p
+		}
+		mean /= float64(len(pitches))
+		for _, p := range pitches {
+			std += (p - mean) * (p - mean)
+		}
+		std = math.Sqrt(std / float64(len(pitches)))
+	}
+	return append(e, pitchWeight*mean, pitchWeight*std)
+}
+
+func distance(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// dominant returns the speaker with the most speech in tally.
+func dominant(tally map[string]time.Duration) string {
+	var best string
+	var most time.Duration
+	for label, d := range tally {
+		if d > most || (d == most && label < best) {
+			best, most = label, d
+		}
+	}
+	return best
+}
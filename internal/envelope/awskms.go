@@ -0,0 +1,119 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/awssig"
+)
+
+func init() {
+	Register("awskms", func(cfg Config) (KeyProvider, error) {
+		return NewAWSKMS(AWSKMSConfig{
+			Key:      cfg.Option("key", ""),
+			Region:   cfg.Option("region", ""),
+			Endpoint: cfg.Option("endpoint", ""),
+		})
+	})
+}
+
+// AWSKMSConfig locates an AWS KMS key.
+type AWSKMSConfig struct {
+	// Key is the ID, ARN or alias of a symmetric KMS key.
+	Key string
+	// Region defaults to $AWS_REGION, then us-east-1.
+	Region string
+	// Endpoint is the URL of KMS. Defaults to that of Region.
+	Endpoint string
+	// Credentials default to $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY
+	// and $AWS_SESSION_TOKEN.
+	Credentials awssig.Credentials
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// AWSKMS is a KeyProvider wrapping data keys with an AWS KMS key. KMS
+// rotates the key material itself, keeping what it rotated out to decrypt
+// with.
+type AWSKMS struct {
+	cfg AWSKMSConfig
+}
+
+// NewAWSKMS validates cfg, filling in the settings it leaves to the
+// environment.
+func NewAWSKMS(cfg AWSKMSConfig) (*AWSKMS, error) {
+	if cfg.Key == "" {
+		return nil, errors.New("no key")
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_REGION")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Credentials.FromEnv()
+	if cfg.Credentials.AccessKey == "" || cfg.Credentials.SecretKey == "" {
+		return nil, errors.New("no credentials; set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://kms." + cfg.Region + ".amazonaws.com/"
+	} else if u, err := url.Parse(cfg.Endpoint); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("bad endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &AWSKMS{cfg: cfg}, nil
+}
+
+// Wrap implements KeyProvider. The key ID is the ARN KMS answers with.
+func (k *AWSKMS) Wrap(ctx context.Context, dek []byte) (string, []byte, error) {
+	var resp struct {
+		CiphertextBlob []byte
+		KeyId          string
+	}
+	if err := k.call(ctx, "Encrypt", map[string]any{"KeyId": k.cfg.Key, "Plaintext": dek}, &resp); err != nil {
+		return "", nil, err
+	}
+	return resp.KeyId, resp.CiphertextBlob, nil
+}
+
+// Unwrap implements KeyProvider.
+func (k *AWSKMS) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte
+	}
+	if err := k.call(ctx, "Decrypt", map[string]any{"KeyId": keyID, "CiphertextBlob": wrapped}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// call invokes a KMS action, decoding its reply into resp. Byte slices
+// travel in base64, as both encoding/json and KMS have them.
+func (k *AWSKMS) call(ctx context.Context, action string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, k.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	r.Header.Set("X-Amz-Target", "TrentService."+action)
+	sum := sha256.Sum256(body)
+	awssig.Sign(r, k.cfg.Credentials, k.cfg.Region, "kms", hex.EncodeToString(sum[:]), time.Now())
+	return do(k.cfg.Client, r, resp)
+}
@@ -0,0 +1,421 @@
+// Package envelope encrypts data at rest with envelope encryption.
+//
+// Data is encrypted with AES-256-GCM under a data key, and the data key
+// under a key encryption key that never leaves its KeyProvider: a local
+// keyring, a HashiCorp Vault transit key or an AWS KMS key. The data key
+// travels wrapped in the header of what it encrypted, so reading data back
+// takes one call to the provider, and a data key is used for
+// Config.DataKeyLifetime before the next is made.
+//
+// Rotating the key encryption key only changes what new data keys are
+// wrapped under: the provider keeps the keys it rotated out for the data
+// under them, and Rewrap moves data to the current key by wrapping its
+// data key again, leaving the encrypted data as it is.
+//
+// Data is encrypted in chunks of 64KiB, each sealed with its number and
+// whether it is the last, so that audio streams through in constant memory
+// and neither reordered, dropped nor truncated chunks go unnoticed.
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrCorrupt is returned when reading encrypted data that is malformed,
+// truncated or was tampered with.
+var ErrCorrupt = errors.New("envelope: corrupt or tampered data")
+
+// KeyProvider holds the key encryption keys. Implementations must be safe
+// for concurrent use.
+type KeyProvider interface {
+	// Wrap encrypts a data key under the current key encryption key,
+	// returning the ID of that key with the wrapped data key.
+	Wrap(ctx context.Context, dek []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypts a data key wrapped under key keyID.
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// DefaultDataKeyLifetime is the DataKeyLifetime of configs setting none.
+const DefaultDataKeyLifetime = time.Hour
+
+// Config configures an Envelope.
+type Config struct {
+	// Provider is the name the key provider was registered under: local,
+	// vault or awskms.
+	Provider string
+	// Options are provider-specific settings, e.g. "key".
+	Options map[string]string
+	// Keys, if set, is the key provider, Provider and Options being
+	// ignored.
+	Keys KeyProvider
+	// DataKeyLifetime is how long a data key encrypts new data before it
+	// is replaced. Defaults to DefaultDataKeyLifetime.
+	DataKeyLifetime time.Duration
+}
+
+// Option returns the named option or def when unset.
+func (c Config) Option(name, def string) string {
+	if v, ok := c.Options[name]; ok && v != "" {
+		return v
+	}
+	return def
+}
+
+const (
+	magic     = "VXE1"
+	chunkSize = 64 << 10
+	// prefixSize is the random part of the nonces of a message; the rest
+	// is the chunk number and the last-chunk flag.
+	prefixSize = 7
+	// maxUses bounds the messages a data key encrypts, for random nonce
+	// prefixes to stay clear of collisions.
+	maxUses = 1 << 20
+	// maxCached bounds the unwrapped data keys kept for decryption.
+	maxCached = 1024
+	tagSize   = 16
+)
+
+// dataKey is a data key with its header.
+type dataKey struct {
+	aead    cipher.AEAD
+	header  []byte // magic, key ID and wrapped data key
+	expires time.Time
+	uses    int
+}
+
+// Envelope encrypts and decrypts data. It is safe for concurrent use.
+type Envelope struct {
+	keys     KeyProvider
+	lifetime time.Duration
+
+	mu      sync.Mutex
+	current *dataKey
+	cache   map[string]cipher.AEAD // by key ID and wrapped data key
+}
+
+// New returns an Envelope using the key provider of cfg.
+func New(cfg Config) (*Envelope, error) {
+	if cfg.DataKeyLifetime < 0 {
+		return nil, errors.New("envelope: data key lifetime must not be negative")
+	}
+	keys := cfg.Keys
+	if keys == nil {
+		var err error
+		if keys, err = NewKeyProvider(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.DataKeyLifetime == 0 {
+		cfg.DataKeyLifetime = DefaultDataKeyLifetime
+	}
+	return &Envelope{keys: keys, lifetime: cfg.DataKeyLifetime, cache: make(map[string]cipher.AEAD)}, nil
+}
+
+// IsEncrypted reports whether b, the start of some data, is the start of
+// data an Envelope encrypted.
+func IsEncrypted(b []byte) bool { return bytes.HasPrefix(b, []byte(magic)) }
+
+func overhead(header int, size int64) int64 {
+	return int64(header+prefixSize) + (size/chunkSize+1)*tagSize
+}
+
+// Encrypt returns the encryption of the size bytes of r, with its size.
+func (e *Envelope) Encrypt(ctx context.Context, r io.Reader, size int64) (io.Reader, int64, error) {
+	dk, err := e.dataKey(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, 0, err
+	}
+	head := append(append([]byte(nil), dk.header...), prefix...)
+	w := &sealer{aead: dk.aead, prefix: prefix, r: r, buf: make([]byte, 0, chunkSize+tagSize)}
+	return io.MultiReader(bytes.NewReader(head), w), size + overhead(len(dk.header), size), nil
+}
+
+// Decrypt returns the decryption of r, which must have been encrypted by
+// an Envelope with the same key provider. Reading it fails with ErrCorrupt
+// on data that was changed.
+func (e *Envelope) Decrypt(ctx context.Context, r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	keyID, wrapped, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := io.ReadFull(br, prefix); err != nil {
+		return nil, ErrCorrupt
+	}
+	aead, err := e.unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return &opener{aead: aead, prefix: prefix, r: br, buf: make([]byte, chunkSize+tagSize)}, nil
+}
+
+// Seal encrypts b.
+func (e *Envelope) Seal(ctx context.Context, b []byte) ([]byte, error) {
+	r, size, err := e.Encrypt(ctx, bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, size)
+	return readAll(out, r)
+}
+
+// Open decrypts b, which Seal or Encrypt made.
+func (e *Envelope) Open(ctx context.Context, b []byte) ([]byte, error) {
+	r, err := e.Decrypt(ctx, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return readAll(make([]byte, 0, len(b)), r)
+}
+
+func readAll(b []byte, r io.Reader) ([]byte, error) {
+	buf := bytes.NewBuffer(b)
+	_, err := buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
+
+// Rewrap returns the encrypted data of r with its data key wrapped again,
+// under the key provider's current key; the data itself is copied as it
+// is. It returns the size of the result given size, the size of r.
+func (e *Envelope) Rewrap(ctx context.Context, r io.Reader, size int64) (io.Reader, int64, error) {
+	br := bufio.NewReader(r)
+	keyID, wrapped, err := readHeader(br)
+	if err != nil {
+		return nil, 0, err
+	}
+	dek, err := e.keys.Unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, 0, fmt.Errorf("envelope: unwrapping data key: %w", err)
+	}
+	header, err := e.wrap(ctx, dek)
+	clear(dek)
+	if err != nil {
+		return nil, 0, err
+	}
+	old := headerSize(keyID, wrapped)
+	return io.MultiReader(bytes.NewReader(header), br), size - int64(old) + int64(len(header)), nil
+}
+
+// dataKey returns the current data key for a new message, making a new
+// one once it expired or has encrypted maxUses messages.
+func (e *Envelope) dataKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if dk := e.current; dk != nil && time.Now().Before(dk.expires) && dk.uses < maxUses {
+		dk.uses++
+		return dk, nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+	header, err := e.wrap(ctx, dek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	e.current = &dataKey{aead: aead, header: header, expires: time.Now().Add(e.lifetime), uses: 1}
+	return e.current, nil
+}
+
+// wrap returns the header of data encrypted under dek.
+func (e *Envelope) wrap(ctx context.Context, dek []byte) ([]byte, error) {
+	keyID, wrapped, err := e.keys.Wrap(ctx, dek)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: wrapping data key: %w", err)
+	}
+	if len(keyID) > 255 || len(wrapped) > 65535 {
+		return nil, errors.New("envelope: key ID or wrapped data key too long")
+	}
+	h := make([]byte, 0, headerSize(keyID, wrapped))
+	h = append(h, magic...)
+	h = append(h, byte(len(keyID)))
+	h = append(h, keyID...)
+	h = binary.BigEndian.AppendUint16(h, uint16(len(wrapped)))
+	return append(h, wrapped...), nil
+}
+
+func headerSize(keyID string, wrapped []byte) int {
+	return len(magic) + 1 + len(keyID) + 2 + len(wrapped)
+}
+
+// unwrap returns the cipher of a wrapped data key, unwrapping it with the
+// key provider unless cached.
+func (e *Envelope) unwrap(ctx context.Context, keyID string, wrapped []byte) (cipher.AEAD, error) {
+	id := keyID + "\x00" + string(wrapped)
+	e.mu.Lock()
+	aead, ok := e.cache[id]
+	e.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+	dek, err := e.keys.Unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: unwrapping data key: %w", err)
+	}
+	defer clear(dek)
+	if aead, err = newAEAD(dek); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	if len(e.cache) >= maxCached {
+		clear(e.cache)
+	}
+	e.cache[id] = aead
+	e.mu.Unlock()
+	return aead, nil
+}
+
+func newAEAD(dek []byte) (cipher.AEAD, error) {
+	if len(dek) != 32 {
+		return nil, fmt.Errorf("envelope: data key of %d bytes, want 32", len(dek))
+	}
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readHeader reads the key ID and wrapped data key of encrypted data.
+func readHeader(r *bufio.Reader) (keyID string, wrapped []byte, err error) {
+	head := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(r, head); err != nil || !IsEncrypted(head) {
+		return "", nil, errors.New("envelope: not encrypted data")
+	}
+	id := make([]byte, head[len(magic)])
+	var n [2]byte
+	if _, err := io.ReadFull(r, id); err != nil {
+		return "", nil, ErrCorrupt
+	}
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return "", nil, ErrCorrupt
+	}
+	wrapped = make([]byte, binary.BigEndian.Uint16(n[:]))
+	if _, err := io.ReadFull(r, wrapped); err != nil {
+		return "", nil, ErrCorrupt
+	}
+	return string(id), wrapped, nil
+}
+
+// nonce returns the nonce of chunk i of a message.
+func nonce(dst, prefix []byte, i uint32, last bool) []byte {
+	dst = append(dst[:0], prefix...)
+	dst = binary.BigEndian.AppendUint32(dst, i)
+	if last {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+// sealer encrypts a reader chunk by chunk. The last chunk is always short,
+// if empty, so that a full chunk is never the last.
+type sealer struct {
+	aead   cipher.AEAD
+	prefix []byte
+	r      io.Reader
+	n      uint32
+	nonce  []byte
+	buf    []byte // ciphertext not yet read
+	off    int
+	done   bool
+}
+
+func (s *sealer) Read(p []byte) (int, error) {
+	if s.off == len(s.buf) {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.buf[s.off:])
+	s.off += n
+	return n, nil
+}
+
+func (s *sealer) next() error {
+	plain := s.buf[:chunkSize]
+	n, err := io.ReadFull(s.r, plain)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		s.done = true
+	case err != nil:
+		return err
+	}
+	s.nonce = nonce(s.nonce, s.prefix, s.n, s.done)
+	s.n++
+	s.buf, s.off = s.aead.Seal(plain[:0], s.nonce, plain[:n], []byte(magic)), 0
+	return nil
+}
+
+// opener decrypts what a sealer encrypted.
+type opener struct {
+	aead   cipher.AEAD
+	prefix []byte
+	r      io.Reader
+	n      uint32
+	nonce  []byte
+	buf    []byte
+	plain  []byte // not yet read
+	done   bool
+	err    error
+}
+
+func (o *opener) Read(p []byte) (int, error) {
+	for len(o.plain) == 0 {
+		if o.err != nil {
+			return 0, o.err
+		}
+		if o.done {
+			return 0, io.EOF
+		}
+		o.err = o.next()
+	}
+	n := copy(p, o.plain)
+	o.plain = o.plain[n:]
+	return n, nil
+}
+
+func (o *opener) next() error {
+	buf := o.buf[:chunkSize+tagSize]
+	n, err := io.ReadFull(o.r, buf)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		o.done = true
+	case err != nil:
+		return err
+	}
+	if n < tagSize {
+		return ErrCorrupt
+	}
+	o.nonce = nonce(o.nonce, o.prefix, o.n, o.done)
+	o.n++
+	plain, err := o.aead.Open(buf[:0], o.nonce, buf[:n], []byte(magic))
+	if err != nil {
+		return ErrCorrupt
+	}
+	o.plain = plain
+	return nil
+}
@@ -0,0 +1,91 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+)
+
+func key(id string) string {
+	k := make([]byte, 32)
+	rand.Read(k)
+	return id + ":" + base64.StdEncoding.EncodeToString(k)
+}
+
+func TestRoundTripAndTampering(t *testing.T) {
+	ctx := context.Background()
+	e, err := New(Config{Keys: must(NewKeyring(key("a"), ""))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 1, chunkSize - 1, chunkSize, 3*chunkSize + 17} {
+		plain := make([]byte, n)
+		rand.Read(plain)
+		r, size, err := e.Encrypt(ctx, bytes.NewReader(plain), int64(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(sealed)) != size {
+			t.Errorf("%d bytes: encrypted into %d, announced %d", n, len(sealed), size)
+		}
+		if got, err := e.Open(ctx, sealed); err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("%d bytes: round trip failed: %v", n, err)
+		}
+		flipped := bytes.Clone(sealed)
+		flipped[len(flipped)-1] ^= 1
+		for what, b := range map[string][]byte{
+			"flipped":   flipped,
+			"truncated": sealed[:len(sealed)-tagSize-1],
+		} {
+			if _, err := e.Open(ctx, b); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("%d bytes %s: got %v, want ErrCorrupt", n, what, err)
+			}
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := key("old"), key("new")
+	e1, _ := New(Config{Keys: must(NewKeyring(oldKey, ""))})
+	e2, _ := New(Config{Keys: must(NewKeyring(newKey+","+oldKey, ""))})
+	sealed, err := e1.Seal(ctx, []byte("call recording"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rotated-out key still reads what it wrapped.
+	if got, err := e2.Open(ctx, sealed); err != nil || string(got) != "call recording" {
+		t.Fatalf("reading after rotation: %q, %v", got, err)
+	}
+	r, size, err := e2.Rewrap(ctx, bytes.NewReader(sealed), int64(len(sealed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrapped, _ := io.ReadAll(r)
+	if int64(len(rewrapped)) != size {
+		t.Errorf("rewrapped into %d bytes, announced %d", len(rewrapped), size)
+	}
+	// Once rewrapped, the old key can go.
+	retired, _ := New(Config{Keys: must(NewKeyring(newKey, ""))})
+	if got, err := retired.Open(ctx, rewrapped); err != nil || string(got) != "call recording" {
+		t.Fatalf("reading after rewrap: %q, %v", got, err)
+	}
+	if _, err := retired.Open(ctx, sealed); err == nil {
+		t.Error("opened data under a retired key")
+	}
+}
+
+func must(k *Keyring, err error) *Keyring {
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
@@ -0,0 +1,100 @@
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func init() {
+	Register("local", func(cfg Config) (KeyProvider, error) {
+		return NewKeyring(cfg.Option("keys", os.Getenv("VOXA_ENCRYPTION_KEYS")), cfg.Option("current", ""))
+	})
+}
+
+// Keyring is a KeyProvider of AES-256 keys held in memory, for deployments
+// without a key management service.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring parses keys, comma-separated id:key pairs whose keys are 32
+// bytes in standard base64, as the local provider reads them from its
+// "keys" option or $VOXA_ENCRYPTION_KEYS. New data keys are wrapped under
+// key current, which defaults to the first; the others still unwrap the
+// data keys wrapped under them, so a key is rotated by putting a new one
+// first and retired once no data is left under it.
+func NewKeyring(keys, current string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for _, pair := range strings.Split(keys, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, b64, ok := strings.Cut(pair, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("key %q is not id:base64", pair)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("key %s listed twice", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key %s is not 32 bytes of base64", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if k.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+		if k.current == "" {
+			k.current = id
+		}
+	}
+	if len(k.keys) == 0 {
+		return nil, errors.New("no keys; set the keys option or VOXA_ENCRYPTION_KEYS")
+	}
+	if current != "" {
+		if _, ok := k.keys[current]; !ok {
+			return nil, fmt.Errorf("current key %s is not in the keyring", current)
+		}
+		k.current = current
+	}
+	return k, nil
+}
+
+// Wrap implements KeyProvider.
+func (k *Keyring) Wrap(ctx context.Context, dek []byte) (string, []byte, error) {
+	aead := k.keys[k.current]
+	wrapped := make([]byte, aead.NonceSize(), aead.NonceSize()+len(dek)+aead.Overhead())
+	if _, err := rand.Read(wrapped); err != nil {
+		return "", nil, err
+	}
+	return k.current, aead.Seal(wrapped, wrapped, dek, []byte(k.current)), nil
+}
+
+// Unwrap implements KeyProvider.
+func (k *Keyring) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key %s is not in the keyring", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	n := aead.NonceSize()
+	dek, err := aead.Open(nil, wrapped[:n], wrapped[n:], []byte(keyID))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return dek, nil
+}
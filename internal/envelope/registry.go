@@ -0,0 +1,57 @@
+package envelope
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory builds a key provider from its configuration.
+type Factory func(cfg Config) (KeyProvider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a key provider available under name. It is meant to be
+// called from an init function and panics if name is already taken or
+// factory is nil.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("envelope: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("envelope: Register called twice for provider " + name)
+	}
+	registry[name] = factory
+}
+
+// NewKeyProvider instantiates the key provider selected by cfg.Provider.
+func NewKeyProvider(cfg Config) (KeyProvider, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Provider]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("envelope: unknown key provider %q (registered: %v)", cfg.Provider, Providers())
+	}
+	k, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: %s: %w", cfg.Provider, err)
+	}
+	return k, nil
+}
+
+// Providers returns the sorted names of the registered key providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
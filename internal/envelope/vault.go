@@ -0,0 +1,138 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+func init() {
+	Register("vault", func(cfg Config) (KeyProvider, error) {
+		return NewVault(VaultConfig{
+			Addr:  cfg.Option("addr", ""),
+			Token: cfg.Option("token", ""),
+			Mount: cfg.Option("mount", ""),
+			Key:   cfg.Option("key", ""),
+		})
+	})
+}
+
+// VaultConfig locates a HashiCorp Vault transit key.
+type VaultConfig struct {
+	// Addr is the URL of Vault. Defaults to $VAULT_ADDR.
+	Addr string
+	// Token defaults to $VAULT_TOKEN.
+	Token string
+	// Mount is where the transit engine is mounted. Defaults to transit.
+	Mount string
+	// Key is the name of the transit key.
+	Key string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Vault is a KeyProvider wrapping data keys with a Vault transit key,
+// which Vault versions: data keys are wrapped under its latest version,
+// and rotating the key in Vault rotates them.
+type Vault struct {
+	cfg  VaultConfig
+	base string // of the transit mount
+}
+
+// NewVault validates cfg, filling in the settings it leaves to the
+// environment.
+func NewVault(cfg VaultConfig) (*Vault, error) {
+	if cfg.Addr == "" {
+		cfg.Addr = os.Getenv("VAULT_ADDR")
+	}
+	if cfg.Token == "" {
+		cfg.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "transit"
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	switch u, err := url.Parse(cfg.Addr); {
+	case cfg.Addr == "":
+		return nil, errors.New("no address; set the addr option or VAULT_ADDR")
+	case err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https"):
+		return nil, errors.New("bad address " + cfg.Addr)
+	case cfg.Token == "":
+		return nil, errors.New("no token; set the token option or VAULT_TOKEN")
+	case cfg.Key == "":
+		return nil, errors.New("no key")
+	}
+	return &Vault{cfg: cfg, base: strings.TrimSuffix(cfg.Addr, "/") + "/v1/" + strings.Trim(cfg.Mount, "/")}, nil
+}
+
+// Wrap implements KeyProvider. The wrapped data key is Vault's ciphertext,
+// which names the key version.
+func (v *Vault) Wrap(ctx context.Context, dek []byte) (string, []byte, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	err := v.call(ctx, "encrypt", v.cfg.Key, map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dek)}, &resp)
+	if err != nil {
+		return "", nil, err
+	}
+	return v.cfg.Key, []byte(resp.Data.Ciphertext), nil
+}
+
+// Unwrap implements KeyProvider.
+func (v *Vault) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := v.call(ctx, "decrypt", keyID, map[string]string{"ciphertext": string(wrapped)}, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+// call posts req to the transit endpoint op of key, decoding the reply
+// into resp.
+func (v *Vault) call(ctx context.Context, op, key string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, v.base+"/"+op+"/"+url.PathEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Vault-Token", v.cfg.Token)
+	return do(v.cfg.Client, r, resp)
+}
+
+// do sends r, decoding the JSON reply into resp.
+func do(c *http.Client, r *http.Request, resp any) error {
+	res, err := c.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return &resilience.HTTPError{StatusCode: res.StatusCode, Status: res.Status, Body: strings.TrimSpace(string(body))}
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
@@ -0,0 +1,324 @@
+// Package eval scores transcripts against references: the word error rate
+// (WER) and character error rate (CER) of a hypothesis, with the
+// alignment of its words to the reference's, to benchmark providers and
+// models on a test set before rolling them out.
+//
+// Both texts are normalized the same way before they are compared: case
+// and punctuation are dropped unless Options keep them, and an inverse
+// text normalizer can write numbers, dates and amounts in one form, so
+// "twenty five" and "25" match. The texts are then aligned by edit
+// distance, each error being a substitution, a deletion of a reference
+// word or an insertion of a hypothesis word. Rates are the errors over the
+// length of the reference, and can exceed 1 when the hypothesis inserts
+// many words.
+package eval
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jmarc101/voxa/internal/itn"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Options controls how texts are normalized before they are compared.
+type Options struct {
+	// KeepCase compares case-sensitively.
+	KeepCase bool
+	// KeepPunctuation keeps punctuation, which is otherwise dropped but
+	// for apostrophes and hyphens within words.
+	KeepPunctuation bool
+	// Normalizer, if set, rewrites both texts in written form first.
+	Normalizer *itn.Normalizer
+	// Language of the texts, for Normalizer.
+	Language string
+}
+
+// Op is the fate of a word in an alignment.
+type Op int
+
+const (
+	// Correct words are the same in both texts.
+	Correct Op = iota
+	// Substitution replaces a reference word.
+	Substitution
+	// Deletion misses a reference word.
+	Deletion
+	// Insertion adds a word the reference lacks.
+	Insertion
+)
+
+func (o Op) String() string {
+	switch o {
+	case Correct:
+		return "correct"
+	case Substitution:
+		return "substitution"
+	case Deletion:
+		return "deletion"
+	case Insertion:
+		return "insertion"
+	}
+	return fmt.Sprintf("Op(%d)", int(o))
+}
+
+// Pair aligns a reference word with a hypothesis word. Ref is empty for
+// insertions and Hyp for deletions.
+type Pair struct {
+	Op       Op
+	Ref, Hyp string
+}
+
+// Errors counts the edits turning a reference into a hypothesis.
+type Errors struct {
+	Substitutions int `json:"substitutions"`
+	Deletions     int `json:"deletions"`
+	Insertions    int `json:"insertions"`
+}
+
+// Total returns the number of edits.
+func (e Errors) Total() int { return e.Substitutions + e.Deletions + e.Insertions }
+
+// Result is the score of a hypothesis.
+type Result struct {
+	// Words and Chars are the length of the reference, in words and in
+	// characters, spaces between words included.
+	Words, Chars int
+	WordErrors   Errors
+	CharErrors   Errors
+	// Alignment aligns the words of both texts, in order.
+	Alignment []Pair
+}
+
+// WER returns the word error rate, 0 for an empty reference.
+func (r Result) WER() float64 { return rate(r.WordErrors, r.Words) }
+
+// CER returns the character error rate, 0 for an empty reference.
+func (r Result) CER() float64 { return rate(r.CharErrors, r.Chars) }
+
+func rate(e Errors, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return float64(e.Total()) / float64(n)
+}
+
+// Add adds the counts of o to r, for the rates over a test set, which
+// weigh every result by the length of its reference. The alignments are
+// not kept.
+func (r *Result) Add(o Result) {
+	r.Words += o.Words
+	r.Chars += o.Chars
+	r.WordErrors = add(r.WordErrors, o.WordErrors)
+	r.CharErrors = add(r.CharErrors, o.CharErrors)
+	r.Alignment = nil
+}
+
+func add(a, b Errors) Errors {
+	return Errors{a.Substitutions + b.Substitutions, a.Deletions + b.Deletions, a.Insertions + b.Insertions}
+}
+
+// Compare scores the hypothesis hyp against the reference ref.
+func Compare(ref, hyp string, opts Options) (Result, error) {
+	rw, err := Normalize(ref, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	hw, err := Normalize(hyp, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Words: len(rw)}
+	res.Alignment, res.WordErrors = align(rw, hw)
+	rc, hc := []rune(strings.Join(rw, " ")), []rune(strings.Join(hw, " "))
+	res.Chars = len(rc)
+	res.CharErrors = distance(rc, hc)
+	return res, nil
+}
+
+// Normalize returns the words of text as Compare compares them.
+func Normalize(text string, opts Options) ([]string, error) {
+	if opts.Normalizer != nil {
+		seg := stt.Segment{Text: text, Final: true, Language: opts.Language}
+		if err := opts.Normalizer.Process(context.Background(), &seg); err != nil {
+			return nil, fmt.Errorf("eval: %w", err)
+		}
+		text = seg.Text
+	}
+	var words []string
+	for _, f := range strings.Fields(text) {
+		if !opts.KeepPunctuation {
+			f = strings.Map(func(r rune) rune {
+				if unicode.IsPunct(r) && r != '\'' && r != '’' && r != '-' {
+					return -1
+				}
+				return r
+			}, f)
+			f = strings.Trim(f, "'’-")
+		}
+		if !opts.KeepCase {
+			f = strings.ToLower(f)
+		}
+		if f != "" {
+			words = append(words, f)
+		}
+	}
+	return words, nil
+}
+
+// Edits of the alignment table, by cost preference on ties: matches and
+// substitutions first, then deletions, so alignments are deterministic.
+const (
+	diag byte = iota
+	up        // deletion
+	left      // insertion
+)
+
+// align aligns hyp to ref by minimum edit distance.
+func align(ref, hyp []string) ([]Pair, Errors) {
+	n, m := len(ref), len(hyp)
+	back := make([]byte, (n+1)*(m+1))
+	prev, cur := make([]int, m+1), make([]int, m+1)
+	for j := range prev {
+		prev[j] = j
+		back[j] = left
+	}
+	for i := 1; i <= n; i++ {
+		cur[0] = i
+		back[i*(m+1)] = up
+		for j := 1; j <= m; j++ {
+			cost, dir := prev[j-1], diag
+			if ref[i-1] != hyp[j-1] {
+				cost++
+			}
+			if c := prev[j] + 1; c < cost {
+				cost, dir = c, up
+			}
+			if c := cur[j-1] + 1; c < cost {
+				cost, dir = c, left
+			}
+			cur[j] = cost
+			back[i*(m+1)+j] = dir
+		}
+		prev, cur = cur, prev
+	}
+	var pairs []Pair
+	var e Errors
+	for i, j := n, m; i > 0 || j > 0; {
+		switch back[i*(m+1)+j] {
+		case diag:
+			i, j = i-1, j-1
+			op := Correct
+			if ref[i] != hyp[j] {
+				op = Substitution
+				e.Substitutions++
+			}
+			pairs = append(pairs, Pair{Op: op, Ref: ref[i], Hyp: hyp[j]})
+		case up:
+			i--
+			e.Deletions++
+			pairs = append(pairs, Pair{Op: Deletion, Ref: ref[i]})
+		case left:
+			j--
+			e.Insertions++
+			pairs = append(pairs, Pair{Op: Insertion, Hyp: hyp[j]})
+		}
+	}
+	for l, r := 0, len(pairs)-1; l < r; l, r = l+1, r-1 {
+		pairs[l], pairs[r] = pairs[r], pairs[l]
+	}
+	return pairs, e
+}
+
+// cell is an entry of the character distance table: the cost and the
+// edits of the cheapest path to it.
+type cell struct {
+	cost int
+	e    Errors
+}
+
+// distance counts the edits turning ref into hyp, with the tie-breaking of
+// align but two rows of memory, as texts have many more characters than
+// words.
+func distance(ref, hyp []rune) Errors {
+	m := len(hyp)
+	prev, cur := make([]cell, m+1), make([]cell, m+1)
+	for j := range prev {
+		prev[j] = cell{j, Errors{Insertions: j}}
+	}
+	for i := 1; i <= len(ref); i++ {
+		cur[0] = cell{i, Errors{Deletions: i}}
+		for j := 1; j <= m; j++ {
+			c := prev[j-1]
+			if ref[i-1] != hyp[j-1] {
+				c.cost++
+				c.e.Substitutions++
+			}
+			if d := prev[j]; d.cost+1 < c.cost {
+				c = d
+				c.cost++
+				c.e.Deletions++
+			}
+			if l := cur[j-1]; l.cost+1 < c.cost {
+				c = l
+				c.cost++
+				c.e.Insertions++
+			}
+			cur[j] = c
+		}
+		prev, cur = cur, prev
+	}
+	return prev[m].e
+}
+
+// WriteAlignment writes an alignment as aligned REF and HYP lines, wrapped
+// at width columns, with a line marking every error by the initial of its
+// Op: S, D or I. Missing words are shown as asterisks.
+func WriteAlignment(w io.Writer, pairs []Pair, width int) error {
+	bw := bufio.NewWriter(w)
+	var ref, hyp, ops strings.Builder
+	flush := func() {
+		if ref.Len() == 0 {
+			return
+		}
+		fmt.Fprintf(bw, "REF: %s\nHYP: %s\n     %s\n", strings.TrimRight(ref.String(), " "),
+			strings.TrimRight(hyp.String(), " "), strings.TrimRight(ops.String(), " "))
+		ref.Reset()
+		hyp.Reset()
+		ops.Reset()
+	}
+	col := 0
+	for _, p := range pairs {
+		r, h := p.Ref, p.Hyp
+		n := max(utf8.RuneCountInString(r), utf8.RuneCountInString(h), 1)
+		if r == "" {
+			r = strings.Repeat("*", n)
+		}
+		if h == "" {
+			h = strings.Repeat("*", n)
+		}
+		if col > 0 && col+n > width {
+			flush()
+			col = 0
+		}
+		mark := " "
+		if p.Op != Correct {
+			mark = strings.ToUpper(p.Op.String()[:1])
+		}
+		ref.WriteString(pad(r, n) + " ")
+		hyp.WriteString(pad(h, n) + " ")
+		ops.WriteString(pad(mark, n) + " ")
+		col += n + 1
+	}
+	flush()
+	return bw.Flush()
+}
+
+func pad(s string, n int) string {
+	return s + strings.Repeat(" ", n-utf8.RuneCountInString(s))
+}
@@ -0,0 +1,116 @@
+package eval
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jmarc101/voxa/internal/itn"
+)
+
+func TestCompare(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		ref, hyp     string
+		words, chars Errors
+		wer          float64
+	}{
+		{"identical", "turn on the lights", "turn on the lights", Errors{}, Errors{}, 0},
+		{"empty reference", "", "turn on", Errors{Insertions: 2}, Errors{Insertions: 7}, 0},
+		{"empty hypothesis", "turn on the lights", "", Errors{Deletions: 4}, Errors{Deletions: 18}, 1},
+		{"both empty", "", "", Errors{}, Errors{}, 0},
+		{"all substitutions", "ab cd", "xy zw", Errors{Substitutions: 2}, Errors{Substitutions: 4}, 1},
+		{"insertions only", "turn on lights", "please turn on the lights now", Errors{Insertions: 3}, Errors{Insertions: 15}, 1},
+		{"deletions only", "please turn on the lights now", "turn on lights", Errors{Deletions: 3}, Errors{Deletions: 15}, 0.5},
+		{"mixed", "a b c d", "a x c", Errors{Substitutions: 1, Deletions: 1}, Errors{Substitutions: 1, Deletions: 2}, 0.5},
+		{"case and punctuation dropped", "Hello, World! It's 5-ish.", "hello world it's 5-ish", Errors{}, Errors{}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Compare(tc.ref, tc.hyp, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.WordErrors != tc.words {
+				t.Errorf("word errors %+v, want %+v", res.WordErrors, tc.words)
+			}
+			if res.CharErrors != tc.chars {
+				t.Errorf("char errors %+v, want %+v", res.CharErrors, tc.chars)
+			}
+			if res.WER() != tc.wer {
+				t.Errorf("WER %v, want %v", res.WER(), tc.wer)
+			}
+		})
+	}
+}
+
+func TestAlignment(t *testing.T) {
+	res, err := Compare("a b c d", "a x c e f", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ties go to substitutions from the end of the texts back.
+	want := []Pair{
+		{Correct, "a", "a"},
+		{Substitution, "b", "x"},
+		{Correct, "c", "c"},
+		{Insertion, "", "e"},
+		{Substitution, "d", "f"},
+	}
+	if !slices.Equal(res.Alignment, want) {
+		t.Fatalf("alignment\n got %v\nwant %v", res.Alignment, want)
+	}
+	var b strings.Builder
+	if err := WriteAlignment(&b, res.Alignment, 80); err != nil {
+		t.Fatal(err)
+	}
+	if want := "REF: a b c * d\nHYP: a x c e f\n       S   I S\n"; b.String() != want {
+		t.Errorf("WriteAlignment\n got %q\nwant %q", b.String(), want)
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	n, err := itn.New(itn.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		ref, hyp string
+		opts     Options
+		want     Errors
+	}{
+		{"case kept", "Hello world", "hello world", Options{KeepCase: true}, Errors{Substitutions: 1}},
+		{"punctuation kept", "hello, world.", "hello world", Options{KeepPunctuation: true}, Errors{Substitutions: 2}},
+		{"apostrophes kept", "don't stop", "dont stop", Options{}, Errors{Substitutions: 1}},
+		{"spelled out without ITN", "I paid $25 on March 3", "i paid twenty five dollars on march third", Options{},
+			Errors{Substitutions: 2, Insertions: 2}},
+		{"spelled out with ITN", "I paid $25 on March 3", "i paid twenty five dollars on march third", Options{Normalizer: n}, Errors{}},
+		{"wrong amount with ITN", "I paid $25 on March 3", "i paid twenty six dollars on march third", Options{Normalizer: n},
+			Errors{Substitutions: 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Compare(tc.ref, tc.hyp, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.WordErrors != tc.want {
+				t.Errorf("word errors %+v, want %+v (alignment %v)", res.WordErrors, tc.want, res.Alignment)
+			}
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	var total Result
+	for _, p := range [][2]string{{"a b c d", "a b c d"}, {"a b", "a"}} {
+		res, err := Compare(p[0], p[1], Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		total.Add(res)
+	}
+	// One deletion over six reference words, not the mean of 0 and 0.5.
+	if total.Words != 6 || total.WordErrors != (Errors{Deletions: 1}) || total.WER() != 1.0/6 {
+		t.Errorf("total %d words, %+v, WER %v; want 6, one deletion, 1/6", total.Words, total.WordErrors, total.WER())
+	}
+}
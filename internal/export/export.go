@@ -0,0 +1,383 @@
+// Package export writes transcripts as subtitle files (SubRip, WebVTT),
+// plain text, JSON, NDJSON or protobuf, segments as they come as NDJSON
+// or length-delimited protobuf, and review files listing the segments
+// recognized with low confidence for people to correct.
+//
+// For subtitles, final segments are split into cues that a viewer can read
+// comfortably: every cue holds at most MaxLines lines of at most
+// MaxLineLength characters and lasts at most MaxDuration. Cues never span
+// two utterances. Word timings are used to place cue boundaries when the
+// recognizer provides them; otherwise they are interpolated over the
+// segment by character count.
+//
+// Translated transcripts make bilingual captions: with Options.Translation
+// set, every cue also shows its share of the segment's translation, split
+// in proportion to the original text the cue holds.
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Options controls how transcripts are split into cues. Zero values select
+// the defaults, which follow common broadcast guidelines.
+type Options struct {
+	// MaxLineLength is the longest line, in characters. A single word
+	// longer than that gets a line of its own. Defaults to 42.
+	MaxLineLength int
+	// MaxLines is the most lines a cue holds. Defaults to 2.
+	MaxLines int
+	// MaxDuration is the longest a cue stays on screen. Defaults to 7s.
+	MaxDuration time.Duration
+	// MinDuration is the shortest a cue stays on screen; short cues are
+	// extended, but never into the next cue. Defaults to 1s.
+	MinDuration time.Duration
+	// SentenceBreak starts a new cue after every sentence, so cues do not
+	// straddle sentences.
+	SentenceBreak bool
+	// Speakers labels cues with the segment's speaker, when known: a
+	// "S1: " prefix in SRT and a voice tag in WebVTT.
+	Speakers bool
+	// Translation, if set, adds the segment's translation into this
+	// language (see stt.Segment.Translations) below the original text,
+	// wrapped to the same line length.
+	Translation string
+}
+
+func (o *Options) setDefaults() error {
+	if o.MaxLineLength == 0 {
+		o.MaxLineLength = 42
+	}
+	if o.MaxLines == 0 {
+		o.MaxLines = 2
+	}
+	if o.MaxDuration == 0 {
+		o.MaxDuration = 7 * time.Second
+	}
+	if o.MinDuration == 0 {
+		o.MinDuration = time.Second
+	}
+	switch {
+	case o.MaxLineLength < 0:
+		return fmt.Errorf("export: negative line length %d", o.MaxLineLength)
+	case o.MaxLines < 0:
+		return fmt.Errorf("export: negative line count %d", o.MaxLines)
+	case o.MinDuration < 0 || o.MaxDuration < 0:
+		return errors.New("export: negative cue duration")
+	}
+	return nil
+}
+
+// Cue is one subtitle.
+type Cue struct {
+	Start, End time.Duration
+	Lines      []string
+	Speaker    string
+	// Translation holds the lines of translated text, when requested.
+	Translation []string
+}
+
+// readingRate estimates speech length for segments without timings, in
+// characters per second.
+const readingRate = 15
+
+// Cues splits the final segments of a transcript into cues. Partial
+// segments are ignored, so the whole stream of a session can be passed.
+func Cues(segs []stt.Segment, opts Options) ([]Cue, error) {
+	if err := opts.setDefaults(); err != nil {
+		return nil, err
+	}
+	var cues []Cue
+	for _, seg := range finals(segs) {
+		cs := split(words(seg), seg.Speaker, opts)
+		if t := seg.Translations[opts.Translation]; opts.Translation != "" && t != "" {
+			translate(cs, t, opts.MaxLineLength)
+		}
+		cues = append(cues, cs...)
+	}
+	// Stretch cues that flash by too quickly, up to the next one.
+	for i := range cues {
+		end := cues[i].Start + opts.MinDuration
+		if i+1 < len(cues) {
+			end = min(end, cues[i+1].Start)
+		}
+		cues[i].End = max(cues[i].End, end)
+	}
+	return cues, nil
+}
+
+// finals returns the final segments with text, in time order.
+func finals(segs []stt.Segment) []stt.Segment {
+	var out []stt.Segment
+	for _, seg := range segs {
+		if seg.Final && strings.TrimSpace(seg.Text) != "" {
+			out = append(out, seg)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
+}
+
+// words returns the timed words of seg, interpolating their times from the
+// text if the recognizer did not align them.
+func words(seg stt.Segment) []stt.Word {
+	if len(seg.Words) > 0 {
+		return seg.Words
+	}
+	fields := strings.Fields(seg.Text)
+	chars := 0
+	for _, f := range fields {
+		chars += utf8.RuneCountInString(f) + 1
+	}
+	start, end := seg.Start, seg.End
+	if end <= start {
+		end = start + time.Duration(chars)*time.Second/readingRate
+	}
+	ws := make([]stt.Word, len(fields))
+	at := 0
+	for i, f := range fields {
+		n := utf8.RuneCountInString(f) + 1
+		ws[i] = stt.Word{
+			Text:  f,
+			Start: start + (end-start)*time.Duration(at)/time.Duration(chars),
+			End:   start + (end-start)*time.Duration(at+n-1)/time.Duration(chars),
+		}
+		at += n
+	}
+	return ws
+}
+
+// split groups the words of one utterance into cues.
+func split(ws []stt.Word, speaker string, opts Options) []Cue {
+	var (
+		cues []Cue
+		cur  *Cue
+	)
+	for _, w := range ws {
+		text := strings.TrimSpace(w.Text)
+		if text == "" {
+			continue
+		}
+		if cur != nil {
+			lines := wrap(cur.Lines, text, opts.MaxLineLength)
+			if len(lines) > opts.MaxLines || w.End-cur.Start > opts.MaxDuration {
+				cur = nil
+			} else {
+				cur.Lines, cur.End = lines, w.End
+			}
+		}
+		if cur == nil {
+			cues = append(cues, Cue{Start: w.Start, End: w.End, Lines: []string{text}, Speaker: speaker})
+			cur = &cues[len(cues)-1]
+		}
+		if opts.SentenceBreak && endsSentence(text) {
+			cur = nil
+		}
+	}
+	return cues
+}
+
+// wrap appends word to lines, starting a new line when it would grow past
+// width.
+func wrap(lines []string, word string, width int) []string {
+	out := append([]string(nil), lines...)
+	last := out[len(out)-1]
+	if utf8.RuneCountInString(last)+1+utf8.RuneCountInString(word) <= width {
+		out[len(out)-1] = last + " " + word
+		return out
+	}
+	return append(out, word)
+}
+
+// translate distributes the words of a translation over the cues of its
+// utterance, in proportion to the original characters each cue holds.
+func translate(cues []Cue, text string, width int) {
+	total := 0
+	for _, c := range cues {
+		for _, l := range c.Lines {
+			total += utf8.RuneCountInString(l)
+		}
+	}
+	ws := strings.Fields(text)
+	done, at := 0, 0
+	for i := range cues {
+		for _, l := range cues[i].Lines {
+			done += utf8.RuneCountInString(l)
+		}
+		n := len(ws) * done / max(total, 1)
+		if i == len(cues)-1 {
+			n = len(ws)
+		}
+		for _, w := range ws[at:n] {
+			if cues[i].Translation == nil {
+				cues[i].Translation = []string{w}
+			} else {
+				cues[i].Translation = wrap(cues[i].Translation, w, width)
+			}
+		}
+		at = max(at, n)
+	}
+}
+
+func endsSentence(word string) bool {
+	word = strings.TrimRight(word, `"')]»”’`)
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "?") || strings.HasSuffix(word, "!") ||
+		strings.HasSuffix(word, "…")
+}
+
+// WriteSRT writes the transcript as a SubRip file.
+func WriteSRT(w io.Writer, segs []stt.Segment, opts Options) error {
+	cues, err := Cues(segs, opts)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for i, c := range cues {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n", i+1, timestamp(c.Start, ','), timestamp(c.End, ','))
+		for j, line := range c.Lines {
+			if j == 0 && opts.Speakers && c.Speaker != "" {
+				line = c.Speaker + ": " + line
+			}
+			fmt.Fprintln(bw, line)
+		}
+		for _, line := range c.Translation {
+			fmt.Fprintln(bw, line)
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// WriteVTT writes the transcript as a WebVTT file.
+func WriteVTT(w io.Writer, segs []stt.Segment, opts Options) error {
+	cues, err := Cues(segs, opts)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, "WEBVTT\n\n")
+	for _, c := range cues {
+		fmt.Fprintf(bw, "%s --> %s\n", timestamp(c.Start, '.'), timestamp(c.End, '.'))
+		for j, line := range c.Lines {
+			line = vttEscaper.Replace(line)
+			if j == 0 && opts.Speakers && c.Speaker != "" {
+				line = "<v " + vttEscaper.Replace(c.Speaker) + ">" + line
+			}
+			fmt.Fprintln(bw, line)
+		}
+		for _, line := range c.Translation {
+			fmt.Fprintln(bw, "<i>"+vttEscaper.Replace(line)+"</i>")
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// vttEscaper escapes the characters WebVTT cue text reserves for markup.
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// timestamp formats d as HH:MM:SS followed by sep and milliseconds.
+func timestamp(d time.Duration, sep byte) string {
+	d = max(d, 0)
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// WriteText writes the transcript as plain text, one utterance per line,
+// followed by a line with its translation if requested. Cue options do not
+// apply, except Speakers and Translation.
+func WriteText(w io.Writer, segs []stt.Segment, opts Options) error {
+	bw := bufio.NewWriter(w)
+	for _, seg := range finals(segs) {
+		if opts.Speakers && seg.Speaker != "" {
+			fmt.Fprintf(bw, "%s: ", seg.Speaker)
+		}
+		fmt.Fprintln(bw, strings.Join(strings.Fields(seg.Text), " "))
+		if t := seg.Translations[opts.Translation]; opts.Translation != "" && t != "" {
+			fmt.Fprintf(bw, "[%s] %s\n", opts.Translation, strings.Join(strings.Fields(t), " "))
+		}
+	}
+	return bw.Flush()
+}
+
+// SchemaVersion is the version of the JSON and NDJSON schema, Transcript
+// and Line. Fields may be added within a version, never renamed, retyped
+// or removed; the protobuf outputs follow the schema of the voxa.voxad.v1
+// package likewise.
+const SchemaVersion = 1
+
+// Transcript is the JSON form of a transcript written by WriteJSON. Times
+// are in milliseconds.
+type Transcript struct {
+	// Version is the SchemaVersion the transcript was written with; zero
+	// for transcripts written before it was recorded.
+	Version  int       `json:"version"`
+	Segments []Segment `json:"segments"`
+}
+
+// Segment is one utterance of a JSON transcript.
+type Segment struct {
+	UtteranceID string  `json:"utterance_id"`
+	Text        string  `json:"text"`
+	Speaker     string  `json:"speaker,omitempty"`
+	StartMS     int64   `json:"start_ms"`
+	EndMS       int64   `json:"end_ms"`
+	Confidence  float32 `json:"confidence,omitempty"`
+	Words       []Word  `json:"words,omitempty"`
+	Language    string  `json:"language,omitempty"`
+	// Translations maps language codes to translated text.
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+// Word is one aligned word of a JSON transcript.
+type Word struct {
+	Text       string  `json:"text"`
+	StartMS    int64   `json:"start_ms"`
+	EndMS      int64   `json:"end_ms"`
+	Confidence float32 `json:"confidence,omitempty"`
+}
+
+// WriteJSON writes the final segments of a transcript as an indented
+// Transcript document. Cue options do not apply.
+func WriteJSON(w io.Writer, segs []stt.Segment) error {
+	t := Transcript{Version: SchemaVersion, Segments: []Segment{}}
+	for _, seg := range finals(segs) {
+		t.Segments = append(t.Segments, jsonSegment(seg))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+// jsonSegment converts seg for a JSON transcript.
+func jsonSegment(seg stt.Segment) Segment {
+	js := Segment{
+		UtteranceID:  seg.UtteranceID,
+		Text:         seg.Text,
+		Speaker:      seg.Speaker,
+		StartMS:      seg.Start.Milliseconds(),
+		EndMS:        seg.End.Milliseconds(),
+		Confidence:   seg.Confidence,
+		Language:     seg.Language,
+		Translations: seg.Translations,
+	}
+	for _, wd := range seg.Words {
+		js.Words = append(js.Words, Word{
+			Text:       wd.Text,
+			StartMS:    wd.Start.Milliseconds(),
+			EndMS:      wd.End.Milliseconds(),
+			Confidence: wd.Confidence,
+		})
+	}
+	return js
+}
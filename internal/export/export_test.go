@@ -0,0 +1,258 @@
+package export
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+func ms(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+
+// timed returns a final segment of text, its words each 400ms long after a
+// 100ms gap from start.
+func timed(start time.Duration, speaker, text string) stt.Segment {
+	seg := stt.Segment{Text: text, Speaker: speaker, Final: true, Start: start}
+	for i, w := range strings.Fields(text) {
+		seg.Words = append(seg.Words, stt.Word{Text: w, Start: start + ms(500*i), End: start + ms(500*i+400)})
+	}
+	seg.End = seg.Words[len(seg.Words)-1].End
+	return seg
+}
+
+func TestCues(t *testing.T) {
+	type cue struct {
+		start, end int // ms
+		lines      []string
+	}
+	for _, tc := range []struct {
+		name string
+		segs []stt.Segment
+		opts Options
+		want []cue
+	}{
+		{
+			name: "line length",
+			segs: []stt.Segment{timed(0, "", "one two three four five")},
+			opts: Options{MaxLineLength: 9},
+			want: []cue{
+				{0, 1400, []string{"one two", "three"}},
+				{1500, 2500, []string{"four five"}},
+			},
+		},
+		{
+			name: "longer word than a line",
+			segs: []stt.Segment{timed(0, "", "a extraordinarily b")},
+			opts: Options{MaxLineLength: 5},
+			want: []cue{
+				{0, 1000, []string{"a", "extraordinarily"}},
+				{1000, 2000, []string{"b"}},
+			},
+		},
+		{
+			name: "max duration",
+			segs: []stt.Segment{timed(0, "", "a b c d e f g")},
+			opts: Options{MaxDuration: 1500 * time.Millisecond},
+			want: []cue{
+				{0, 1400, []string{"a b c"}},
+				{1500, 2900, []string{"d e f"}},
+				{3000, 4000, []string{"g"}},
+			},
+		},
+		{
+			name: "sentence break",
+			segs: []stt.Segment{timed(0, "", "Yes. Go on! Well")},
+			opts: Options{SentenceBreak: true, MinDuration: ms(100)},
+			want: []cue{
+				{0, 400, []string{"Yes."}},
+				{500, 1400, []string{"Go on!"}},
+				{1500, 1900, []string{"Well"}},
+			},
+		},
+		{
+			name: "min duration up to the next cue",
+			segs: []stt.Segment{timed(0, "", "hi"), timed(ms(700), "", "there"), timed(ms(5000), "", "bye")},
+			want: []cue{
+				{0, 700, []string{"hi"}},
+				{700, 1700, []string{"there"}},
+				{5000, 6000, []string{"bye"}},
+			},
+		},
+		{
+			name: "utterances in time order, partials and empty ones left out",
+			segs: []stt.Segment{
+				timed(ms(3000), "", "second"),
+				{Text: "partial", Start: 0, End: ms(900)},
+				{Text: "  ", Final: true, Start: ms(1000), End: ms(2000)},
+				timed(0, "", "first"),
+			},
+			want: []cue{
+				{0, 1000, []string{"first"}},
+				{3000, 4000, []string{"second"}},
+			},
+		},
+		{
+			// 12 characters with the spaces over 1.2s.
+			name: "times interpolated without words",
+			segs: []stt.Segment{{Text: "ab cdefg hi", Final: true, Start: ms(1000), End: ms(2200)}},
+			opts: Options{MaxLineLength: 5, MaxLines: 1, MinDuration: ms(1)},
+			want: []cue{
+				{1000, 1200, []string{"ab"}},
+				{1300, 1800, []string{"cdefg"}},
+				{1900, 2100, []string{"hi"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cues, err := Cues(tc.segs, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []cue
+			for _, c := range cues {
+				got = append(got, cue{int(c.Start.Milliseconds()), int(c.End.Milliseconds()), c.Lines})
+			}
+			if !slices.EqualFunc(got, tc.want, func(a, b cue) bool {
+				return a.start == b.start && a.end == b.end && slices.Equal(a.lines, b.lines)
+			}) {
+				t.Errorf("cues\n got %v\nwant %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCuesOptions(t *testing.T) {
+	for _, opts := range []Options{{MaxLineLength: -1}, {MaxLines: -1}, {MaxDuration: -1}, {MinDuration: -1}} {
+		if _, err := Cues(nil, opts); err == nil {
+			t.Errorf("Cues with %+v: no error", opts)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	for _, tc := range []struct {
+		d        time.Duration
+		srt, vtt string
+	}{
+		{0, "00:00:00,000", "00:00:00.000"},
+		{ms(1234), "00:00:01,234", "00:00:01.234"},
+		{61*time.Minute + ms(5007), "01:01:05,007", "01:01:05.007"},
+		{100*time.Minute + 999*time.Microsecond, "01:40:00,000", "01:40:00.000"},
+		{100 * time.Hour, "100:00:00,000", "100:00:00.000"},
+		{-time.Second, "00:00:00,000", "00:00:00.000"},
+	} {
+		if got := timestamp(tc.d, ','); got != tc.srt {
+			t.Errorf("SRT timestamp(%v) = %q, want %q", tc.d, got, tc.srt)
+		}
+		if got := timestamp(tc.d, '.'); got != tc.vtt {
+			t.Errorf("VTT timestamp(%v) = %q, want %q", tc.d, got, tc.vtt)
+		}
+	}
+}
+
+// transcript has two speakers, cue text to escape in WebVTT and an
+// utterance past 99 minutes.
+var transcript = []stt.Segment{
+	timed(0, "S1", "Hello there, how are you doing today?"),
+	timed(ms(4000), "S2", "Fine <mostly> & you?"),
+	timed(101*time.Minute, "", "Late."),
+}
+
+func TestWriteSRT(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		segs []stt.Segment
+		opts Options
+		want string
+	}{
+		{"empty", nil, Options{}, ""},
+		{"partials only", []stt.Segment{{Text: "hel", End: time.Second}}, Options{}, ""},
+		{"transcript", transcript, Options{MaxLineLength: 20}, `1
+00:00:00,000 --> 00:00:03,400
+Hello there, how are
+you doing today?
+
+2
+00:00:04,000 --> 00:00:05,900
+Fine <mostly> & you?
+
+3
+01:41:00,000 --> 01:41:01,000
+Late.
+
+`},
+		{"speakers", transcript, Options{MaxLineLength: 20, Speakers: true}, `1
+00:00:00,000 --> 00:00:03,400
+S1: Hello there, how are
+you doing today?
+
+2
+00:00:04,000 --> 00:00:05,900
+S2: Fine <mostly> & you?
+
+3
+01:41:00,000 --> 01:41:01,000
+Late.
+
+`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteSRT(&b, tc.segs, tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tc.want {
+				t.Errorf("WriteSRT\n got %q\nwant %q", b.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteVTT(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		segs []stt.Segment
+		opts Options
+		want string
+	}{
+		{"empty", nil, Options{}, "WEBVTT\n\n"},
+		{"transcript", transcript, Options{MaxLineLength: 20}, `WEBVTT
+
+00:00:00.000 --> 00:00:03.400
+Hello there, how are
+you doing today?
+
+00:00:04.000 --> 00:00:05.900
+Fine &lt;mostly&gt; &amp; you?
+
+01:41:00.000 --> 01:41:01.000
+Late.
+
+`},
+		{"speakers", transcript, Options{MaxLineLength: 20, Speakers: true}, `WEBVTT
+
+00:00:00.000 --> 00:00:03.400
+<v S1>Hello there, how are
+you doing today?
+
+00:00:04.000 --> 00:00:05.900
+<v S2>Fine &lt;mostly&gt; &amp; you?
+
+01:41:00.000 --> 01:41:01.000
+Late.
+
+`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteVTT(&b, tc.segs, tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tc.want {
+				t.Errorf("WriteVTT\n got %q\nwant %q", b.String(), tc.want)
+			}
+		})
+	}
+}
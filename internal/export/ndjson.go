@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Line is a segment written by an NDJSONWriter: a Segment of a JSON
+// transcript with whether it is final and, for partials, its revision.
+type Line struct {
+	// Version is the SchemaVersion of the line.
+	Version int `json:"version"`
+	Segment
+	Final    bool `json:"final"`
+	Revision int  `json:"revision,omitempty"`
+}
+
+// NDJSONWriter writes segments as they come, one JSON Line per line, so
+// transcripts can be piped into jq or another program. It is safe for
+// concurrent use.
+type NDJSONWriter struct {
+	partials bool
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewNDJSONWriter writes to w the final segments, and with partials the
+// partial ones too.
+func NewNDJSONWriter(w io.Writer, partials bool) *NDJSONWriter {
+	return &NDJSONWriter{partials: partials, enc: json.NewEncoder(w)}
+}
+
+// Write writes seg, unless it is a partial and partials are left out. It
+// returns the first error writing failed with, after which nothing more
+// is written.
+func (n *NDJSONWriter) Write(seg stt.Segment) error {
+	if !seg.Final && !n.partials {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	line := Line{Version: SchemaVersion, Segment: jsonSegment(seg), Final: seg.Final}
+	if !seg.Final {
+		line.Revision = seg.Revision
+	}
+	n.err = n.enc.Encode(line)
+	return n.err
+}
+
+// Err returns the first error writing failed with.
+func (n *NDJSONWriter) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
+
+// WriteNDJSON writes the final segments of a transcript as NDJSON, one
+// Line each. Cue options do not apply.
+func WriteNDJSON(w io.Writer, segs []stt.Segment) error {
+	bw := bufio.NewWriter(w)
+	n := NewNDJSONWriter(bw, false)
+	for _, seg := range finals(segs) {
+		if err := n.Write(seg); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
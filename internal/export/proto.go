@@ -0,0 +1,115 @@
+package export
+
+import (
+	"io"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	speechv1 "github.com/jmarc101/voxa/api/gen/voxa/speech/v1"
+	voxadv1 "github.com/jmarc101/voxa/api/gen/voxa/voxad/v1"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// SegmentProto converts seg to the voxa.voxad.v1 Segment message that the
+// gRPC API, the event sinks and the protobuf outputs share.
+func SegmentProto(seg stt.Segment) *voxadv1.Segment {
+	pb := &voxadv1.Segment{
+		UtteranceId:  seg.UtteranceID,
+		Revision:     int32(seg.Revision),
+		Text:         seg.Text,
+		Stability:    seg.Stability,
+		Final:        seg.Final,
+		Speaker:      seg.Speaker,
+		Start:        durationpb.New(seg.Start),
+		End:          durationpb.New(seg.End),
+		Confidence:   seg.Confidence,
+		Translations: seg.Translations,
+		Language:     seg.Language,
+		Provider:     seg.Provider,
+	}
+	for _, w := range seg.Words {
+		pb.Words = append(pb.Words, &speechv1.Word{
+			Text:       w.Text,
+			Start:      durationpb.New(w.Start),
+			End:        durationpb.New(w.End),
+			Confidence: w.Confidence,
+		})
+	}
+	for _, r := range seg.Redactions {
+		pb.Redactions = append(pb.Redactions, &voxadv1.Redaction{
+			Entity: r.Entity,
+			Start:  int32(r.Start),
+			End:    int32(r.End),
+			At:     int32(r.At),
+		})
+	}
+	if st := seg.Sentiment; st != nil {
+		pb.Sentiment = &voxadv1.Sentiment{Label: st.Label, Score: st.Score, Emotion: st.Emotion, Arousal: st.Arousal}
+	}
+	return pb
+}
+
+// WriteProto writes the final segments of a transcript as one binary
+// voxa.voxad.v1 Transcript message, as the GetTranscript RPC returns it
+// but without a stored session. Cue options do not apply.
+func WriteProto(w io.Writer, segs []stt.Segment) error {
+	t := &voxadv1.Transcript{}
+	for _, seg := range finals(segs) {
+		t.Segments = append(t.Segments, SegmentProto(seg))
+	}
+	b, err := proto.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ProtoWriter writes segments as they come, as voxa.voxad.v1 Event
+// messages of type FINAL or PARTIAL, each preceded by its length as a
+// varint: the framing of protodelim, and of Java's writeDelimitedTo. It
+// is safe for concurrent use.
+type ProtoWriter struct {
+	partials bool
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewProtoWriter writes to w the final segments, and with partials the
+// partial ones too.
+func NewProtoWriter(w io.Writer, partials bool) *ProtoWriter {
+	return &ProtoWriter{partials: partials, w: w}
+}
+
+// Write writes seg, unless it is a partial and partials are left out. It
+// returns the first error writing failed with, after which nothing more
+// is written.
+func (p *ProtoWriter) Write(seg stt.Segment) error {
+	if !seg.Final && !p.partials {
+		return nil
+	}
+	ev := &voxadv1.Event{Type: voxadv1.EventType_PARTIAL, Time: timestamppb.Now(), Segment: SegmentProto(seg)}
+	if seg.Final {
+		ev.Type = voxadv1.EventType_FINAL
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	_, p.err = protodelim.MarshalTo(p.w, ev)
+	return p.err
+}
+
+// Err returns the first error writing failed with.
+func (p *ProtoWriter) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
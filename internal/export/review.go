@@ -0,0 +1,198 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// ReviewOptions selects the segments of a review file.
+type ReviewOptions struct {
+	// Threshold is the confidence under which a segment, or one of its
+	// words, is flagged for review. Defaults to 0.6. Segments and words of
+	// unknown confidence are never flagged.
+	Threshold float32
+	// Padding is the audio either side of a flagged segment its clip adds,
+	// so the reviewer hears it in context. Defaults to 500ms.
+	Padding time.Duration
+	// Audio names the recording the clips are cut from.
+	Audio string
+}
+
+func (o *ReviewOptions) setDefaults() error {
+	if o.Threshold == 0 {
+		o.Threshold = 0.6
+	}
+	if o.Padding == 0 {
+		o.Padding = 500 * time.Millisecond
+	}
+	switch {
+	case o.Threshold < 0 || o.Threshold > 1:
+		return fmt.Errorf("export: review threshold %v out of (0, 1]", o.Threshold)
+	case o.Padding < 0:
+		return fmt.Errorf("export: negative review padding %v", o.Padding)
+	}
+	return nil
+}
+
+// Review is the JSON review file written by WriteReview: the segments of a
+// transcript a person should check, with the clip of the recording to
+// listen to for each. Reviewers fill in the corrections, which
+// ApplyReview merges back. Times are in milliseconds.
+type Review struct {
+	Audio     string       `json:"audio,omitempty"`
+	Threshold float32      `json:"threshold"`
+	Items     []ReviewItem `json:"items"`
+}
+
+// ReviewItem is a segment flagged for review.
+type ReviewItem struct {
+	UtteranceID string  `json:"utterance_id"`
+	Text        string  `json:"text"`
+	Speaker     string  `json:"speaker,omitempty"`
+	Confidence  float32 `json:"confidence,omitempty"`
+	StartMS     int64   `json:"start_ms"`
+	EndMS       int64   `json:"end_ms"`
+	// ClipStartMS and ClipEndMS delimit the audio to listen to: the
+	// segment and the padding around it.
+	ClipStartMS int64 `json:"clip_start_ms"`
+	ClipEndMS   int64 `json:"clip_end_ms"`
+	// Words are the words of the segment under the threshold.
+	Words []Word `json:"words,omitempty"`
+	// Correction is the text as the reviewer corrects it: null keeps the
+	// transcript, and an empty string drops the segment.
+	Correction *string `json:"correction"`
+}
+
+// Flagged returns the final segments of a transcript opts flags for review,
+// in time order.
+func Flagged(segs []stt.Segment, opts ReviewOptions) ([]ReviewItem, error) {
+	if err := opts.setDefaults(); err != nil {
+		return nil, err
+	}
+	items := []ReviewItem{}
+	for _, seg := range finals(segs) {
+		var low []Word
+		for _, wd := range seg.Words {
+			if wd.Confidence > 0 && wd.Confidence < opts.Threshold {
+				low = append(low, Word{Text: wd.Text, StartMS: wd.Start.Milliseconds(), EndMS: wd.End.Milliseconds(), Confidence: wd.Confidence})
+			}
+		}
+		uncertain := seg.Confidence > 0 && seg.Confidence < opts.Threshold
+		if !uncertain && len(low) == 0 {
+			continue
+		}
+		items = append(items, ReviewItem{
+			UtteranceID: seg.UtteranceID,
+			Text:        seg.Text,
+			Speaker:     seg.Speaker,
+			Confidence:  seg.Confidence,
+			StartMS:     seg.Start.Milliseconds(),
+			EndMS:       seg.End.Milliseconds(),
+			ClipStartMS: max(0, seg.Start-opts.Padding).Milliseconds(),
+			ClipEndMS:   (seg.End + opts.Padding).Milliseconds(),
+			Words:       low,
+		})
+	}
+	return items, nil
+}
+
+// WriteReview writes the segments of a transcript flagged by opts as an
+// indented Review document.
+func WriteReview(w io.Writer, segs []stt.Segment, opts ReviewOptions) error {
+	items, err := Flagged(segs, opts)
+	if err != nil {
+		return err
+	}
+	_ = opts.setDefaults()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Review{Audio: opts.Audio, Threshold: opts.Threshold, Items: items})
+}
+
+// ReadReview reads a Review document.
+func ReadReview(r io.Reader) (Review, error) {
+	var rv Review
+	if err := json.NewDecoder(r).Decode(&rv); err != nil {
+		return Review{}, fmt.Errorf("export: reading review: %w", err)
+	}
+	return rv, nil
+}
+
+// ApplyReview merges the corrections of rv into the segments of a
+// transcript, matched by utterance, and returns the result with the number
+// of segments corrected. A corrected segment takes the reviewer's text
+// with full confidence and loses its word alignment, translations and
+// redactions, which no longer match it; one corrected to nothing is
+// dropped. Corrections of utterances the transcript lacks are an error.
+func ApplyReview(segs []stt.Segment, rv Review) ([]stt.Segment, int, error) {
+	fixes := map[string]string{}
+	for _, it := range rv.Items {
+		if it.Correction != nil {
+			fixes[it.UtteranceID] = strings.TrimSpace(*it.Correction)
+		}
+	}
+	out := make([]stt.Segment, 0, len(segs))
+	applied := map[string]bool{}
+	for _, seg := range segs {
+		text, ok := fixes[seg.UtteranceID]
+		if !ok || !seg.Final {
+			out = append(out, seg)
+			continue
+		}
+		applied[seg.UtteranceID] = true
+		if text == "" {
+			continue
+		}
+		seg.Text, seg.Confidence = text, 1
+		seg.Words, seg.Translations, seg.Redactions = nil, nil, nil
+		out = append(out, seg)
+	}
+	for id := range fixes {
+		if !applied[id] {
+			return nil, 0, fmt.Errorf("export: review corrects utterance %q, which the transcript lacks", id)
+		}
+	}
+	return out, len(applied), nil
+}
+
+// ReadJSON reads back a transcript written by WriteJSON, as final
+// segments.
+func ReadJSON(r io.Reader) ([]stt.Segment, error) {
+	var t Transcript
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, fmt.Errorf("export: reading transcript: %w", err)
+	}
+	if t.Version > SchemaVersion {
+		return nil, fmt.Errorf("export: transcript has schema version %d, newer than %d", t.Version, SchemaVersion)
+	}
+	segs := make([]stt.Segment, 0, len(t.Segments))
+	for _, js := range t.Segments {
+		seg := stt.Segment{
+			UtteranceID:  js.UtteranceID,
+			Text:         js.Text,
+			Stability:    1,
+			Final:        true,
+			Speaker:      js.Speaker,
+			Start:        time.Duration(js.StartMS) * time.Millisecond,
+			End:          time.Duration(js.EndMS) * time.Millisecond,
+			Confidence:   js.Confidence,
+			Language:     js.Language,
+			Translations: js.Translations,
+		}
+		for _, wd := range js.Words {
+			seg.Words = append(seg.Words, stt.Word{
+				Text:       wd.Text,
+				Start:      time.Duration(wd.StartMS) * time.Millisecond,
+				End:        time.Duration(wd.EndMS) * time.Millisecond,
+				Confidence: wd.Confidence,
+			})
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
@@ -0,0 +1,318 @@
+package rtp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/g711"
+	"github.com/jmarc101/voxa/internal/audio/opus"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// queueSize bounds the packets waiting for a call to take them.
+const queueSize = 256
+
+// maxGap is the longest timestamp jump filled with concealment, as when a
+// source stops sending during silence. Longer jumps are taken for a
+// timestamp reset, and the audio goes on without a gap.
+const maxGap = 30 * time.Second
+
+// call transcribes the audio of one source. A call that failed swallows
+// the packets of its source until the source goes quiet or says goodbye,
+// so it is not started again packet after packet.
+type call struct {
+	srv  *Server
+	info Call
+	in   chan packet
+	bye  chan struct{}
+	once sync.Once
+
+	packets, dropped int // guarded by srv.mu
+}
+
+func newCall(s *Server, info Call) *call {
+	return &call{srv: s, info: info, in: make(chan packet, queueSize), bye: make(chan struct{})}
+}
+
+// receive queues p, in encoding codec. srv.mu is held.
+func (c *call) receive(p packet, codec Codec) {
+	c.packets++
+	if codec != c.info.Codec {
+		c.dropped++
+		return
+	}
+	select {
+	case c.in <- p:
+	default:
+		c.dropped++
+	}
+}
+
+// hangUp ends the call once the packets received have been played.
+func (c *call) hangUp() { c.once.Do(func() { close(c.bye) }) }
+
+func (c *call) run() {
+	cfg := c.srv.cfg
+	log := logging.With(c.srv.log, "session", c.info.SessionID, "ssrc", fmt.Sprintf("%08x", c.info.SSRC))
+	log.Info("call started", "peer", c.info.Peer, "codec", c.info.Codec)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pl, err := c.open(ctx)
+	var audioLen time.Duration
+	// end closes the stream, as soon as the call fails so the session it
+	// holds is released.
+	end := func() {
+		if pl == nil {
+			return
+		}
+		if cerr := pl.close(); err == nil {
+			err = cerr
+		}
+		audioLen = pl.format.Duration(pl.written)
+		pl = nil
+	}
+	jb := newJitterBuffer(uint32(cfg.Delay.Seconds() * float64(clockRate(c.info.Codec))))
+	var ready []packet
+	play := func(ps []packet) {
+		for _, p := range ps {
+			if err != nil {
+				return
+			}
+			if err = pl.play(p); err != nil {
+				end()
+			}
+		}
+	}
+	idle := time.NewTimer(cfg.Idle)
+	defer idle.Stop()
+loop:
+	for {
+		select {
+		case p := <-c.in:
+			idle.Reset(cfg.Idle)
+			ready = jb.push(p, ready[:0])
+			play(ready)
+		case <-c.bye:
+			break loop
+		case <-idle.C:
+			break loop
+		}
+	}
+	for drained := false; !drained; {
+		select {
+		case p := <-c.in:
+			play(jb.push(p, ready[:0]))
+		default:
+			drained = true
+		}
+	}
+	play(jb.drain(ready[:0]))
+
+	end()
+	stats := Stats{Lost: jb.lost, Late: jb.late, Duplicate: jb.duplicate, Audio: audioLen}
+	c.srv.mu.Lock()
+	stats.Packets, stats.Dropped = c.packets, c.dropped
+	c.srv.mu.Unlock()
+	if err != nil {
+		log.Warn("call failed", "error", err)
+	}
+	log.Info("call ended", "audio", stats.Audio, "packets", stats.Packets, "lost", stats.Lost, "late", stats.Late, "dropped", stats.Dropped)
+	if cfg.OnEnd != nil {
+		cfg.OnEnd(c.info, stats, err)
+	}
+}
+
+// open starts the stream of the call.
+func (c *call) open(ctx context.Context) (*player, error) {
+	dec, err := newPayloadDecoder(c.info.Codec)
+	if err != nil {
+		return nil, err
+	}
+	s, done, err := c.srv.cfg.Open(ctx, c.info, dec.format())
+	if err != nil {
+		dec.close()
+		return nil, err
+	}
+	pl := &player{
+		s:      s,
+		done:   done,
+		dec:    dec,
+		format: dec.format(),
+		ratio:  clockRate(c.info.Codec) / dec.format().SampleRate,
+		recv:   make(chan struct{}),
+	}
+	go func() {
+		defer close(pl.recv)
+		for seg := range s.Results() {
+			if c.srv.cfg.OnSegment != nil {
+				c.srv.cfg.OnSegment(c.info, seg)
+			}
+		}
+	}()
+	return pl, nil
+}
+
+// player writes the packets of a call, in order, to its stream, filling gaps
+// in their timestamps with concealment.
+type player struct {
+	s      *voxa.Stream
+	done   func()
+	dec    payloadDecoder
+	format audio.Format
+	ratio  int // RTP clock ticks per sample
+	recv   chan struct{}
+
+	started  bool
+	expected uint32 // timestamp of the packet following the last played
+	written  int    // samples
+	pcm      []int16
+}
+
+func (pl *player) play(p packet) error {
+	if pl.started {
+		gap := int64(int32(p.timestamp-pl.expected)) / int64(pl.ratio)
+		if gap > 0 && gap < int64(pl.format.Samples(maxGap)) {
+			if err := pl.conceal(int(gap)); err != nil {
+				return err
+			}
+		}
+	}
+	var err error
+	if pl.pcm, err = pl.dec.decode(pl.pcm[:0], p.payload); err != nil {
+		// A corrupt payload is lost audio, not the end of the call.
+		pl.pcm = pl.pcm[:0]
+	}
+	pl.started, pl.expected = true, p.timestamp+uint32(len(pl.pcm)*pl.ratio)
+	return pl.write(pl.pcm)
+}
+
+// conceal writes n samples standing in for lost audio, a frame at a time.
+func (pl *player) conceal(n int) error {
+	step := pl.format.Samples(audio.FrameDuration)
+	for n > 0 {
+		k := min(n, step)
+		pl.pcm = pl.dec.conceal(pl.pcm[:0], k)
+		if err := pl.write(pl.pcm); err != nil {
+			return err
+		}
+		n -= k
+	}
+	return nil
+}
+
+func (pl *player) write(pcm []int16) error {
+	if len(pcm) == 0 {
+		return nil
+	}
+	fr := audio.Frame{Format: pl.format, Data: pcm, Offset: pl.format.Duration(pl.written)}
+	pl.written += len(pcm)
+	return pl.s.WriteFrame(fr)
+}
+
+// close closes the stream once its last segments are delivered.
+func (pl *player) close() error {
+	err := pl.s.Close()
+	<-pl.recv
+	if err == nil {
+		err = pl.s.Err()
+	}
+	pl.dec.close()
+	if pl.done != nil {
+		pl.done()
+	}
+	return err
+}
+
+// clockRate is the RTP timestamp rate of a codec.
+func clockRate(c Codec) int {
+	if c == Opus {
+		return 48000 // whatever the audio, RFC 7587 section 4.1
+	}
+	return 8000
+}
+
+// payloadDecoder turns the payloads of one codec into mono PCM16.
+type payloadDecoder interface {
+	format() audio.Format
+	decode(dst []int16, payload []byte) ([]int16, error)
+	// conceal appends n samples standing in for lost audio.
+	conceal(dst []int16, n int) []int16
+	close()
+}
+
+func newPayloadDecoder(c Codec) (payloadDecoder, error) {
+	switch c {
+	case PCMU:
+		return g711Decoder{g711.DecodeULaw}, nil
+	case PCMA:
+		return g711Decoder{g711.DecodeALaw}, nil
+	case Opus:
+		d, err := opus.NewDecoder(opusFormat)
+		if err != nil {
+			return nil, fmt.Errorf("rtp: %w", err)
+		}
+		return &opusDecoder{dec: d}, nil
+	}
+	return nil, fmt.Errorf("rtp: unknown codec %v", c)
+}
+
+// g711Decoder conceals losses with silence.
+type g711Decoder struct {
+	expand func(dst []int16, src []byte) []int16
+}
+
+func (g711Decoder) format() audio.Format { return audio.Format{SampleRate: 8000, Channels: 1} }
+
+func (d g711Decoder) decode(dst []int16, payload []byte) ([]int16, error) {
+	return d.expand(dst, payload), nil
+}
+
+func (g711Decoder) conceal(dst []int16, n int) []int16 { return append(dst, make([]int16, n)...) }
+
+func (g711Decoder) close() {}
+
+// opusFormat is what Opus calls are decoded to: the rate recognizers take,
+// downmixed.
+var opusFormat = audio.Format{SampleRate: 16000, Channels: 1}
+
+// maxConcealed is how much lost Opus audio is concealed by the codec; the
+// rest of a longer loss is silence.
+const maxConcealed = 120 * time.Millisecond
+
+// opusDecoder conceals losses with the codec's packet loss concealment.
+type opusDecoder struct {
+	dec       *opus.Decoder
+	concealed int // samples concealed since the last packet
+}
+
+func (*opusDecoder) format() audio.Format { return opusFormat }
+
+func (d *opusDecoder) decode(dst []int16, payload []byte) ([]int16, error) {
+	d.concealed = 0
+	fr, err := d.dec.Decode(payload)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, fr.Data...), nil
+}
+
+func (d *opusDecoder) conceal(dst []int16, n int) []int16 {
+	for n > 0 && d.concealed < opusFormat.Samples(maxConcealed) {
+		fr, err := d.dec.Decode(nil)
+		if err != nil || fr.Len() == 0 {
+			break
+		}
+		k := min(n, fr.Len())
+		dst = append(dst, fr.Data[:k]...)
+		n -= k
+		d.concealed += k
+	}
+	return append(dst, make([]int16, n)...)
+}
+
+func (d *opusDecoder) close() { _ = d.dec.Close() }
@@ -0,0 +1,97 @@
+package rtp
+
+// maxPending bounds the packets a jitter buffer holds, whatever their
+// timestamps say.
+const maxPending = 512
+
+// jitterBuffer puts the packets of a source back in sequence order. A
+// packet missing from the sequence is waited for until the packets queued
+// after it span depth, in RTP clock units; it is then given up as lost.
+// Packets older than those already released, and duplicates, are dropped.
+type jitterBuffer struct {
+	depth   uint32
+	ext     extender
+	pending map[int64]packet // by extended sequence number
+	next    int64            // of the next packet to release
+	started bool
+
+	late, duplicate, lost int
+}
+
+func newJitterBuffer(depth uint32) *jitterBuffer {
+	return &jitterBuffer{depth: depth, pending: map[int64]packet{}}
+}
+
+// push adds p and appends the packets now ready, in order, to out.
+func (j *jitterBuffer) push(p packet, out []packet) []packet {
+	seq := j.ext.extend(p.seq)
+	if !j.started {
+		j.started, j.next = true, seq
+	}
+	switch _, dup := j.pending[seq]; {
+	case seq < j.next:
+		j.late++
+		return out
+	case dup:
+		j.duplicate++
+		return out
+	}
+	j.pending[seq] = p
+	out = j.release(out)
+	for len(j.pending) > 0 && (len(j.pending) > maxPending || j.span() > j.depth) {
+		j.skip()
+		out = j.release(out)
+	}
+	return out
+}
+
+// drain appends all the packets held, in order, to out.
+func (j *jitterBuffer) drain(out []packet) []packet {
+	for len(j.pending) > 0 {
+		j.skip()
+		out = j.release(out)
+	}
+	return out
+}
+
+// release appends the packets following on from the last released.
+func (j *jitterBuffer) release(out []packet) []packet {
+	for {
+		p, ok := j.pending[j.next]
+		if !ok {
+			return out
+		}
+		delete(j.pending, j.next)
+		out = append(out, p)
+		j.next++
+	}
+}
+
+// skip gives up on the packets missing before the first one held.
+func (j *jitterBuffer) skip() {
+	first := int64(-1)
+	for seq := range j.pending {
+		if first < 0 || seq < first {
+			first = seq
+		}
+	}
+	j.lost += int(first - j.next)
+	j.next = first
+}
+
+// span is how far apart the timestamps of the packets held are.
+func (j *jitterBuffer) span() uint32 {
+	var lo, hi uint32
+	started := false
+	for _, p := range j.pending {
+		switch {
+		case !started:
+			lo, hi, started = p.timestamp, p.timestamp, true
+		case int32(p.timestamp-lo) < 0:
+			lo = p.timestamp
+		case int32(p.timestamp-hi) > 0:
+			hi = p.timestamp
+		}
+	}
+	return hi - lo
+}
@@ -0,0 +1,150 @@
+package rtp
+
+import (
+	"slices"
+	"testing"
+)
+
+// frame is the RTP clock units of 20ms at 8kHz, the timestamp step of the
+// packets pushed.
+const frame = 160
+
+func TestJitterBuffer(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		depth uint32
+		base  uint32 // timestamp of sequence number 0
+		// pushes are extended sequence numbers, sent as their low 16 bits
+		// with timestamps following on from base.
+		pushes []int64
+		want   [][]int64 // released after each push
+		drain  []int64
+
+		late, duplicate, lost int
+	}{
+		{
+			name:   "in order",
+			depth:  3 * frame,
+			pushes: []int64{1, 2, 3},
+			want:   [][]int64{{1}, {2}, {3}},
+		},
+		{
+			name:   "out of order",
+			depth:  3 * frame,
+			pushes: []int64{1, 3, 4, 2, 5},
+			want:   [][]int64{{1}, nil, nil, {2, 3, 4}, {5}},
+		},
+		{
+			name:      "duplicates and late packets",
+			depth:     3 * frame,
+			pushes:    []int64{1, 3, 3, 2, 1, 3, 4},
+			want:      [][]int64{{1}, nil, nil, {2, 3}, nil, nil, {4}},
+			late:      2,
+			duplicate: 1,
+		},
+		{
+			// The packets after the gap span 3 frames with 7, more with 8.
+			name:   "gap given up past depth",
+			depth:  3 * frame,
+			pushes: []int64{1, 4, 5, 6, 7, 8, 2},
+			want:   [][]int64{{1}, nil, nil, nil, nil, {4, 5, 6, 7, 8}, nil},
+			late:   1,
+			lost:   2,
+		},
+		{
+			name:   "sequence wrap",
+			depth:  3 * frame,
+			pushes: []int64{65534, 65535, 65537, 65536, 65538, 65535},
+			want:   [][]int64{{65534}, {65535}, nil, {65536, 65537}, {65538}},
+			late:   1,
+		},
+		{
+			name:   "gap across the sequence wrap",
+			depth:  3 * frame,
+			pushes: []int64{65535, 65537, 65538, 65539, 65540, 65541},
+			want:   [][]int64{{65535}, nil, nil, nil, nil, {65537, 65538, 65539, 65540, 65541}},
+			lost:   1,
+		},
+		{
+			// A span taken without the wrap would be nearly 1<<32 once
+			// the timestamps cross it, giving up on 2 straight away.
+			name:   "timestamp wrap",
+			depth:  3 * frame,
+			base:   1<<32 - 4*frame,
+			pushes: []int64{1, 3, 4, 5, 6, 2},
+			want:   [][]int64{{1}, nil, nil, nil, nil, {2, 3, 4, 5, 6}},
+		},
+		{
+			name:   "gap across the timestamp wrap",
+			depth:  3 * frame,
+			base:   1<<32 - 4*frame,
+			pushes: []int64{1, 3, 4, 5, 6, 7},
+			want:   [][]int64{{1}, nil, nil, nil, nil, {3, 4, 5, 6, 7}},
+			lost:   1,
+		},
+		{
+			name:   "drained in order",
+			depth:  10 * frame,
+			pushes: []int64{1, 5, 3},
+			want:   [][]int64{{1}, nil, nil},
+			drain:  []int64{3, 5},
+			lost:   2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			j := newJitterBuffer(tc.depth)
+			for i, n := range tc.pushes {
+				var want []int64
+				if i < len(tc.want) {
+					want = tc.want[i]
+				}
+				got := j.push(packet{seq: uint16(n), timestamp: tc.base + uint32(n)*frame}, nil)
+				if seqs := extended(got, n); !slices.Equal(seqs, want) {
+					t.Errorf("push %d released %v, want %v", n, seqs, want)
+				}
+			}
+			if got := extended(j.drain(nil), tc.pushes[len(tc.pushes)-1]); !slices.Equal(got, tc.drain) {
+				t.Errorf("drain released %v, want %v", got, tc.drain)
+			}
+			if j.late != tc.late || j.duplicate != tc.duplicate || j.lost != tc.lost {
+				t.Errorf("%d late, %d duplicate, %d lost; want %d, %d, %d",
+					j.late, j.duplicate, j.lost, tc.late, tc.duplicate, tc.lost)
+			}
+		})
+	}
+}
+
+func TestJitterBufferMaxPending(t *testing.T) {
+	// A depth the timestamps never reach, leaving it to maxPending.
+	j := newJitterBuffer(1 << 31)
+	j.push(packet{seq: 0}, nil)
+	for n := 2; n < 2+maxPending; n++ {
+		if got := j.push(packet{seq: uint16(n), timestamp: uint32(n)}, nil); len(got) != 0 {
+			t.Fatalf("push %d released %d packets with %d held", n, len(got), len(j.pending))
+		}
+	}
+	got := j.push(packet{seq: 2 + maxPending, timestamp: 2 + maxPending}, nil)
+	if len(got) != maxPending+1 || got[0].seq != 2 {
+		t.Fatalf("over maxPending released %d packets, want %d from 2", len(got), maxPending+1)
+	}
+	if j.lost != 1 || len(j.pending) != 0 {
+		t.Errorf("%d lost, %d held; want 1 lost, none held", j.lost, len(j.pending))
+	}
+}
+
+// extended returns the sequence numbers of ps, extended to those closest to
+// near.
+func extended(ps []packet, near int64) []int64 {
+	var seqs []int64
+	for _, p := range ps {
+		n := near&^0xffff | int64(p.seq)
+		switch {
+		case n < near-1<<15:
+			n += 1 << 16
+		case n > near+1<<15:
+			n -= 1 << 16
+		}
+		seqs = append(seqs, n)
+	}
+	return seqs
+}
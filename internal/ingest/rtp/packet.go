@@ -0,0 +1,109 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// packet is an RTP packet (RFC 3550, section 5.1). Its payload aliases the
+// datagram it was parsed from.
+type packet struct {
+	payloadType uint8
+	marker      bool
+	seq         uint16
+	timestamp   uint32
+	ssrc        uint32
+	payload     []byte
+}
+
+var errBadPacket = errors.New("rtp: malformed packet")
+
+// parsePacket parses an RTP packet, skipping its CSRC list, header
+// extension and padding.
+func parsePacket(b []byte) (packet, error) {
+	if len(b) < 12 || b[0]>>6 != 2 {
+		return packet{}, errBadPacket
+	}
+	p := packet{
+		payloadType: b[1] & 0x7f,
+		marker:      b[1]&0x80 != 0,
+		seq:         binary.BigEndian.Uint16(b[2:]),
+		timestamp:   binary.BigEndian.Uint32(b[4:]),
+		ssrc:        binary.BigEndian.Uint32(b[8:]),
+	}
+	n := 12 + 4*int(b[0]&0x0f)
+	if b[0]&0x10 != 0 { // header extension
+		if len(b) < n+4 {
+			return packet{}, errBadPacket
+		}
+		n += 4 + 4*int(binary.BigEndian.Uint16(b[n+2:]))
+	}
+	end := len(b)
+	if b[0]&0x20 != 0 { // padding, counted by the last byte
+		end -= int(b[len(b)-1])
+	}
+	if n > end {
+		return packet{}, errBadPacket
+	}
+	p.payload = b[n:end]
+	return p, nil
+}
+
+// isRTCP reports whether a datagram is RTCP rather than RTP, as when both
+// share a port (RFC 5761, section 4): RTCP packet types 192-223 fall in the
+// payload types RTP leaves unused.
+func isRTCP(b []byte) bool {
+	return len(b) >= 2 && b[1] >= 192 && b[1] <= 223
+}
+
+// rtcpBYE is the RTCP packet type of goodbyes (RFC 3550, section 6.6).
+const rtcpBYE = 203
+
+// parseBye returns the sources a compound RTCP packet says goodbye for.
+// Other packets in it, such as sender reports, are skipped.
+func parseBye(b []byte) ([]uint32, error) {
+	var gone []uint32
+	for len(b) > 0 {
+		if len(b) < 4 || b[0]>>6 != 2 {
+			return gone, errors.New("rtp: malformed RTCP packet")
+		}
+		size := 4 + 4*int(binary.BigEndian.Uint16(b[2:]))
+		if size > len(b) {
+			return gone, errors.New("rtp: truncated RTCP packet")
+		}
+		if b[1] == rtcpBYE {
+			count := int(b[0] & 0x1f)
+			for i := range count {
+				if off := 4 + 4*i; off+4 <= size {
+					gone = append(gone, binary.BigEndian.Uint32(b[off:]))
+				}
+			}
+		}
+		b = b[size:]
+	}
+	return gone, nil
+}
+
+// extend turns 16-bit sequence numbers into increasing 64-bit ones,
+// counting wrap-arounds (RFC 3550, appendix A.1).
+type extender struct {
+	started bool
+	max     int64 // highest extended sequence number seen
+}
+
+func (e *extender) extend(seq uint16) int64 {
+	if !e.started {
+		e.started, e.max = true, int64(seq)
+		return e.max
+	}
+	// The extended number closest to the highest one seen.
+	ext := e.max&^0xffff | int64(seq)
+	switch {
+	case ext < e.max-1<<15:
+		ext += 1 << 16
+	case ext > e.max+1<<15:
+		ext -= 1 << 16
+	}
+	e.max = max(e.max, ext)
+	return ext
+}
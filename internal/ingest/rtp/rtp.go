@@ -0,0 +1,391 @@
+// Package rtp transcribes phone calls sent as RTP (RFC 3550), as from a
+// SIP media server or gateway.
+//
+// A Server reads datagrams from one or more UDP sockets and runs a call
+// per synchronization source (SSRC): packets are put back in order by a
+// jitter buffer, decoded from G.711 µ-law or A-law, or Opus, and written to
+// a pipeline stream of their own. Lost packets are concealed so the audio
+// keeps its timing. A call ends when its source sends an RTCP BYE, or goes
+// quiet for Config.Idle. RTCP may share the RTP socket (RFC 5761) or come
+// on a socket of its own.
+//
+// Signaling is out of scope: the SIP side points media at the server's
+// address and negotiates the payload types Config expects. Transports that
+// receive RTP themselves, such as WebRTC, hand it to a Track instead.
+package rtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// Codec is the encoding of the audio of a call.
+type Codec int
+
+const (
+	// PCMU is G.711 µ-law at 8kHz, static payload type 0.
+	PCMU Codec = iota + 1
+	// PCMA is G.711 A-law at 8kHz, static payload type 8.
+	PCMA
+	// Opus (RFC 7587) uses a dynamic payload type; see Config.Opus. It
+	// needs voxa built with libopus (-tags opus).
+	Opus
+)
+
+func (c Codec) String() string {
+	switch c {
+	case PCMU:
+		return "PCMU"
+	case PCMA:
+		return "PCMA"
+	case Opus:
+		return "opus"
+	}
+	return "Codec(" + strconv.Itoa(int(c)) + ")"
+}
+
+// DefaultOpusPayloadType is the payload type of Opus when Config sets none,
+// the one most SIP endpoints offer.
+const DefaultOpusPayloadType = 111
+
+// Call describes a call: the audio of one RTP source.
+type Call struct {
+	// SSRC identifies the source within the RTP session.
+	SSRC uint32
+	// Peer is the address the first packet came from.
+	Peer net.Addr
+	// Codec is the encoding of the first packet; packets in another
+	// encoding are dropped.
+	Codec Codec
+	// SessionID names the pipeline stream of the call.
+	SessionID string
+	Started   time.Time
+}
+
+// Stats counts what happened to the packets of a call.
+type Stats struct {
+	// Packets counts the packets received.
+	Packets int
+	// Lost counts the packets the jitter buffer gave up waiting for, and
+	// Late those arriving after their turn, whether lost or played
+	// already. Duplicate counts those received twice while buffered, and
+	// Dropped those in an unexpected encoding or that the call could not
+	// keep up with.
+	Lost, Late, Duplicate, Dropped int
+	// Audio is the audio written to the stream, concealment included.
+	Audio time.Duration
+}
+
+// Opener opens the pipeline stream a call is transcribed on, in format f.
+// done, if not nil, is called once the stream has been closed.
+type Opener func(ctx context.Context, c Call, f audio.Format) (s *voxa.Stream, done func(), err error)
+
+// Pipeline returns an Opener of streams of p named after the session IDs
+// of their calls.
+func Pipeline(p *voxa.Pipeline) Opener {
+	return func(ctx context.Context, c Call, f audio.Format) (*voxa.Stream, func(), error) {
+		s, err := p.NewStream(ctx, f, voxa.StreamOptions{SessionID: c.SessionID})
+		return s, nil, err
+	}
+}
+
+// Config configures a Server.
+type Config struct {
+	// Open opens the stream of every call.
+	Open Opener
+	// Opus is the payload type Opus is negotiated with. Defaults to
+	// DefaultOpusPayloadType.
+	Opus uint8
+	// Delay is how long the jitter buffer waits for a missing packet.
+	// Defaults to 60ms.
+	Delay time.Duration
+	// Idle ends a call whose source sent nothing for this long. Defaults to
+	// 10 seconds.
+	Idle time.Duration
+	// MaxCalls, if set, bounds the calls running at once; packets of new
+	// sources are dropped beyond it.
+	MaxCalls int
+	// OnSegment, if set, is called for every segment of every call, from
+	// one goroutine per call.
+	OnSegment func(Call, voxa.Segment)
+	// OnEnd, if set, is called once a call has ended, with the error that
+	// ended it, if any.
+	OnEnd func(Call, Stats, error)
+	// Logger receives call starts, ends and failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// Server runs the calls of the RTP sources it reads from. It is safe for
+// concurrent use.
+type Server struct {
+	cfg Config
+	log logging.Logger
+
+	mu     sync.Mutex
+	calls  map[uint32]*call
+	tracks map[*call]bool
+	conns  map[net.PacketConn]bool
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New validates cfg.
+func New(cfg Config) (*Server, error) {
+	switch {
+	case cfg.Open == nil:
+		return nil, errors.New("rtp: no stream opener")
+	case cfg.Delay < 0, cfg.Idle < 0, cfg.MaxCalls < 0:
+		return nil, fmt.Errorf("rtp: negative delay %v, idle %v or max calls %d", cfg.Delay, cfg.Idle, cfg.MaxCalls)
+	case cfg.Opus != 0 && (cfg.Opus < 96 || cfg.Opus > 127):
+		return nil, fmt.Errorf("rtp: Opus payload type %d is not dynamic (96-127)", cfg.Opus)
+	}
+	if cfg.Opus == 0 {
+		cfg.Opus = DefaultOpusPayloadType
+	}
+	if cfg.Delay == 0 {
+		cfg.Delay = 60 * time.Millisecond
+	}
+	if cfg.Idle == 0 {
+		cfg.Idle = 10 * time.Second
+	}
+	return &Server{
+		cfg:    cfg,
+		log:    logging.OrNop(cfg.Logger),
+		calls:  map[uint32]*call{},
+		tracks: map[*call]bool{},
+		conns:  map[net.PacketConn]bool{},
+	}, nil
+}
+
+// Serve reads RTP and RTCP from conn until it fails or the server is
+// closed, which closes conn. It returns nil after Close.
+func (s *Server) Serve(conn net.PacketConn) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errors.New("rtp: server closed")
+	}
+	s.conns[conn] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return fmt.Errorf("rtp: %w", err)
+		}
+		s.handle(buf[:n], addr)
+	}
+}
+
+// Calls lists the calls running.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := make([]Call, 0, len(s.calls)+len(s.tracks))
+	for _, c := range s.calls {
+		calls = append(calls, c.info)
+	}
+	for c := range s.tracks {
+		calls = append(calls, c.info)
+	}
+	return calls
+}
+
+// Close stops reading, ends every call as if its source had said goodbye,
+// and waits for them to finish transcribing what they received.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	for _, c := range s.calls {
+		c.hangUp()
+	}
+	for c := range s.tracks {
+		c.hangUp()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// handle routes a datagram to its call, starting one for new sources.
+func (s *Server) handle(b []byte, addr net.Addr) {
+	if isRTCP(b) {
+		gone, err := parseBye(b)
+		if err != nil {
+			s.log.Debug("bad RTCP packet", "peer", addr, "error", err)
+		}
+		s.mu.Lock()
+		for _, ssrc := range gone {
+			if c := s.calls[ssrc]; c != nil {
+				c.hangUp()
+			}
+		}
+		s.mu.Unlock()
+		return
+	}
+	p, err := parsePacket(b)
+	if err != nil {
+		s.log.Debug("bad RTP packet", "peer", addr, "error", err)
+		return
+	}
+	codec := s.codec(p.payloadType)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.calls[p.ssrc]
+	if c == nil {
+		if s.closed || codec == 0 || s.full() {
+			return
+		}
+		c = s.start(p.ssrc, addr, codec)
+	}
+	// The payload is copied off the read buffer.
+	p.payload = append([]byte(nil), p.payload...)
+	c.receive(p, codec)
+}
+
+// codec returns the encoding of a payload type, 0 for encodings calls are
+// not transcribed from, such as comfort noise and DTMF events.
+func (s *Server) codec(pt uint8) Codec {
+	switch pt {
+	case 0:
+		return PCMU
+	case 8:
+		return PCMA
+	case s.cfg.Opus:
+		return Opus
+	}
+	return 0
+}
+
+// full reports whether MaxCalls are running. s.mu is held.
+func (s *Server) full() bool {
+	return s.cfg.MaxCalls > 0 && len(s.calls)+len(s.tracks) >= s.cfg.MaxCalls
+}
+
+// start runs a call for a new source. s.mu is held.
+func (s *Server) start(ssrc uint32, peer net.Addr, codec Codec) *call {
+	c := newCall(s, Call{SSRC: ssrc, Peer: peer, Codec: codec, SessionID: newSessionID(ssrc), Started: time.Now()})
+	s.calls[ssrc] = c
+	s.run(c, func() {
+		if s.calls[ssrc] == c {
+			delete(s.calls, ssrc)
+		}
+	})
+	return c
+}
+
+// run runs c, then calls forget with s.mu held. s.mu is held.
+func (s *Server) run(c *call, forget func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		c.run()
+		s.mu.Lock()
+		forget()
+		s.mu.Unlock()
+	}()
+}
+
+// Track starts a call for a source whose packets the caller receives
+// itself. Packets of payload type pt are decoded as c.Codec, and others
+// dropped; c.SessionID and c.Started default as for the calls of Serve.
+func (s *Server) Track(c Call, pt uint8) (*Track, error) {
+	if c.Codec < PCMU || c.Codec > Opus {
+		return nil, fmt.Errorf("rtp: unknown codec %v", c.Codec)
+	}
+	if c.SessionID == "" {
+		c.SessionID = newSessionID(c.SSRC)
+	}
+	if c.Started.IsZero() {
+		c.Started = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.closed:
+		return nil, errors.New("rtp: server closed")
+	case s.full():
+		return nil, fmt.Errorf("rtp: %d calls running already", s.cfg.MaxCalls)
+	}
+	cl := newCall(s, c)
+	s.tracks[cl] = true
+	s.run(cl, func() { delete(s.tracks, cl) })
+	return &Track{c: cl, pt: pt}, nil
+}
+
+// Track is a call fed by the caller, as from a WebRTC track. It is safe
+// for concurrent use.
+type Track struct {
+	c  *call
+	pt uint8
+}
+
+// Call describes the call.
+func (t *Track) Call() Call { return t.c.info }
+
+// Write hands the call the RTP packet in b, which it copies. Packets the
+// call cannot keep up with are dropped.
+func (t *Track) Write(b []byte) error {
+	p, err := parsePacket(b)
+	if err != nil {
+		return err
+	}
+	if p.payloadType == t.pt {
+		t.WritePayload(p.seq, p.timestamp, p.payload)
+	} else {
+		t.c.srv.mu.Lock()
+		t.c.receive(p, 0)
+		t.c.srv.mu.Unlock()
+	}
+	return nil
+}
+
+// WritePayload hands the call the payload of a packet whose header the
+// caller parsed itself, copying it.
+func (t *Track) WritePayload(seq uint16, timestamp uint32, payload []byte) {
+	p := packet{seq: seq, timestamp: timestamp, ssrc: t.c.info.SSRC, payload: append([]byte(nil), payload...)}
+	t.c.srv.mu.Lock()
+	t.c.receive(p, t.c.info.Codec)
+	t.c.srv.mu.Unlock()
+}
+
+// Close ends the call once the packets written have been played, without
+// waiting for it.
+func (t *Track) Close() error {
+	t.c.hangUp()
+	return nil
+}
+
+// newSessionID names the session of a call after its source and start, as
+// an SSRC may be reused by a later call.
+func newSessionID(ssrc uint32) string {
+	return fmt.Sprintf("rtp-%08x-%d", ssrc, time.Now().UnixMilli())
+}
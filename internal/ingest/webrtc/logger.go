@@ -0,0 +1,31 @@
+package webrtc
+
+import (
+	"fmt"
+
+	pionlog "github.com/pion/logging"
+
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// loggerFactory routes the logs of pion to a Logger. Pion's own info
+// messages are debug level here, and its errors, which it recovers from,
+// warnings.
+type loggerFactory struct{ log logging.Logger }
+
+func (f loggerFactory) NewLogger(scope string) pionlog.LeveledLogger {
+	return pionLogger{logging.With(f.log, "scope", "pion/"+scope)}
+}
+
+type pionLogger struct{ log logging.Logger }
+
+func (l pionLogger) Trace(string)                   {}
+func (l pionLogger) Tracef(string, ...any)          {}
+func (l pionLogger) Debug(msg string)               { l.log.Debug(msg) }
+func (l pionLogger) Debugf(format string, a ...any) { l.log.Debug(fmt.Sprintf(format, a...)) }
+func (l pionLogger) Info(msg string)                { l.log.Debug(msg) }
+func (l pionLogger) Infof(format string, a ...any)  { l.log.Debug(fmt.Sprintf(format, a...)) }
+func (l pionLogger) Warn(msg string)                { l.log.Warn(msg) }
+func (l pionLogger) Warnf(format string, a ...any)  { l.log.Warn(fmt.Sprintf(format, a...)) }
+func (l pionLogger) Error(msg string)               { l.log.Warn(msg) }
+func (l pionLogger) Errorf(format string, a ...any) { l.log.Warn(fmt.Sprintf(format, a...)) }
@@ -0,0 +1,494 @@
+// Package webrtc transcribes audio sent by browsers over WebRTC, which
+// brings their echo cancellation, noise suppression and Opus with it, at
+// lower latency than audio chunked over a WebSocket.
+//
+// A Server is an HTTP handler negotiating peer connections with WHIP, the
+// WebRTC-HTTP ingestion protocol (RFC 9725): an SDP offer POSTed as
+// application/sdp is answered with 201 Created, the answer, and the
+// location of the connection, which a DELETE ends. For a simpler exchange
+// the offer may be POSTed as JSON instead, {"type":"offer","sdp":...} as
+// RTCPeerConnection.localDescription serializes it, and is answered in
+// kind with the ID of the connection. Candidates are gathered before the
+// answer is sent; trickle ICE is not supported.
+//
+// The audio tracks of a connection are calls of an rtp.Server, jitter
+// buffered and decoded from Opus or, in builds without libopus, G.711. The
+// first track of connection ID is transcribed as session "webrtc-ID", any
+// later ones as "webrtc-ID-2" and up. If the peer opens a data channel,
+// the segments of its sessions are sent on it, encoded by Config.Encode.
+package webrtc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pion/interceptor"
+	pion "github.com/pion/webrtc/v4"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/opus"
+	"github.com/jmarc101/voxa/internal/ingest/rtp"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// maxOffer bounds the size of an SDP offer.
+const maxOffer = 64 << 10
+
+// Payload types the answer uses when the offer does not say.
+const (
+	opusPayloadType = 111
+	pcmuPayloadType = 0
+	pcmaPayloadType = 8
+)
+
+// Config configures a Server.
+type Config struct {
+	// Open opens the stream of every track.
+	Open rtp.Opener
+	// Encode, if set, encodes the segments of a session sent on the data
+	// channel of its peer.
+	Encode func(sessionID string, seg voxa.Segment) ([]byte, error)
+	// ICEServers are the STUN and TURN URLs candidates are gathered with,
+	// e.g. stun:stun.l.google.com:19302.
+	ICEServers []string
+	// UDP, if set, is the socket all connections share, so a single port
+	// need be opened to the server; Close closes it. Otherwise every
+	// connection listens on ports of its own.
+	UDP net.PacketConn
+	// MaxPeers, if set, bounds the connections open at once.
+	MaxPeers int
+	// Logger receives connection starts, ends and failures. Nil discards
+	// them.
+	Logger logging.Logger
+}
+
+// Server negotiates and runs peer connections. It is safe for concurrent
+// use.
+type Server struct {
+	cfg   Config
+	log   logging.Logger
+	api   *pion.API
+	mux   io.Closer // of Config.UDP, if set
+	calls *rtp.Server
+
+	mu        sync.Mutex
+	peers     map[string]*peer
+	bySession map[string]*peer
+	closed    bool
+}
+
+// New validates cfg.
+func New(cfg Config) (*Server, error) {
+	switch {
+	case cfg.Open == nil:
+		return nil, errors.New("webrtc: no stream opener")
+	case cfg.MaxPeers < 0:
+		return nil, fmt.Errorf("webrtc: negative max peers %d", cfg.MaxPeers)
+	}
+	for _, u := range cfg.ICEServers {
+		if !strings.HasPrefix(u, "stun:") && !strings.HasPrefix(u, "turn:") && !strings.HasPrefix(u, "turns:") {
+			return nil, fmt.Errorf("webrtc: ICE server %q is not a stun:, turn: or turns: URL", u)
+		}
+	}
+	log := logging.OrNop(cfg.Logger)
+	s := &Server{cfg: cfg, log: log, peers: map[string]*peer{}, bySession: map[string]*peer{}}
+
+	me := &pion.MediaEngine{}
+	codecs := []pion.RTPCodecParameters{
+		{RTPCodecCapability: pion.RTPCodecCapability{MimeType: pion.MimeTypePCMU, ClockRate: 8000, Channels: 1}, PayloadType: pcmuPayloadType},
+		{RTPCodecCapability: pion.RTPCodecCapability{MimeType: pion.MimeTypePCMA, ClockRate: 8000, Channels: 1}, PayloadType: pcmaPayloadType},
+	}
+	if opusSupported() {
+		opus := pion.RTPCodecParameters{
+			RTPCodecCapability: pion.RTPCodecCapability{MimeType: pion.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: "minptime=10;useinbandfec=1"},
+			PayloadType:        opusPayloadType,
+		}
+		codecs = append([]pion.RTPCodecParameters{opus}, codecs...)
+	} else {
+		log.Warn("built without libopus: WebRTC audio is negotiated as G.711")
+	}
+	for _, c := range codecs {
+		if err := me.RegisterCodec(c, pion.RTPCodecTypeAudio); err != nil {
+			return nil, fmt.Errorf("webrtc: %w", err)
+		}
+	}
+	ir := &interceptor.Registry{}
+	if err := pion.RegisterDefaultInterceptors(me, ir); err != nil {
+		return nil, fmt.Errorf("webrtc: %w", err)
+	}
+	se := pion.SettingEngine{LoggerFactory: loggerFactory{log}}
+	if cfg.UDP != nil {
+		mux := pion.NewICEUDPMux(loggerFactory{log}.NewLogger("ice"), cfg.UDP)
+		se.SetICEUDPMux(mux)
+		s.mux = mux
+	}
+	s.api = pion.NewAPI(pion.WithMediaEngine(me), pion.WithInterceptorRegistry(ir), pion.WithSettingEngine(se))
+
+	calls, err := rtp.New(rtp.Config{Open: cfg.Open, OnSegment: s.segment, OnEnd: s.ended, Logger: log})
+	if err != nil {
+		return nil, err
+	}
+	s.calls = calls
+	return s, nil
+}
+
+// opusSupported reports whether Opus can be decoded.
+func opusSupported() bool {
+	d, err := opus.NewDecoder(audio.Format{SampleRate: 16000, Channels: 1})
+	if err != nil {
+		return false
+	}
+	_ = d.Close()
+	return true
+}
+
+// Close ends all connections and waits for their tracks to finish
+// transcribing what they received.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	peers := make([]*peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	s.mu.Unlock()
+	for _, p := range peers {
+		s.hangUp(p)
+	}
+	err := s.calls.Close()
+	if s.mux != nil {
+		if cerr := s.mux.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ServeHTTP serves WHIP: POST offers a connection and DELETE, on the
+// location it was answered with, ends it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.post(w, r)
+	case http.MethodDelete:
+		s.delete(w, r)
+	default:
+		// Including PATCH: without trickle ICE, WHIP answers 405.
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// offerMessage is an offer or answer of the JSON exchange.
+type offerMessage struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+	SDP  string `json:"sdp"`
+}
+
+func (s *Server) post(w http.ResponseWriter, r *http.Request) {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct != "application/sdp" && ct != "application/json" {
+		http.Error(w, "want an application/sdp or application/json offer", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxOffer+1))
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case len(body) > maxOffer:
+		http.Error(w, "offer too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	offer := string(body)
+	if ct == "application/json" {
+		var m offerMessage
+		if err := json.Unmarshal(body, &m); err != nil || m.Type != "offer" {
+			http.Error(w, `want {"type":"offer","sdp":...}`, http.StatusBadRequest)
+			return
+		}
+		offer = m.SDP
+	}
+
+	p, answer, err := s.offer(r.Context(), offer, r.RemoteAddr)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if ct == "application/json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(offerMessage{ID: p.id, Type: "answer", SDP: answer})
+		return
+	}
+	w.Header().Set("Content-Type", "application/sdp")
+	w.Header().Set("Location", path.Join(r.URL.Path, p.id))
+	w.WriteHeader(http.StatusCreated)
+	_, _ = io.WriteString(w, answer)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	p := s.peers[path.Base(r.URL.Path)]
+	s.mu.Unlock()
+	if p == nil {
+		http.Error(w, "no such connection", http.StatusNotFound)
+		return
+	}
+	s.hangUp(p)
+	w.WriteHeader(http.StatusOK)
+}
+
+// errUnavailable is returned for offers the server cannot take now.
+var errUnavailable = errors.New("webrtc: server unavailable")
+
+// offer answers an SDP offer, starting its connection.
+func (s *Server) offer(ctx context.Context, offer, remote string) (*peer, string, error) {
+	s.mu.Lock()
+	switch {
+	case s.closed:
+		s.mu.Unlock()
+		return nil, "", fmt.Errorf("%w: closed", errUnavailable)
+	case s.cfg.MaxPeers > 0 && len(s.peers) >= s.cfg.MaxPeers:
+		s.mu.Unlock()
+		return nil, "", fmt.Errorf("%w: %d connections open already", errUnavailable, s.cfg.MaxPeers)
+	}
+	s.mu.Unlock()
+
+	conf := pion.Configuration{}
+	if len(s.cfg.ICEServers) > 0 {
+		conf.ICEServers = []pion.ICEServer{{URLs: s.cfg.ICEServers}}
+	}
+	pc, err := s.api.NewPeerConnection(conf)
+	if err != nil {
+		return nil, "", fmt.Errorf("webrtc: %w", err)
+	}
+	p := &peer{id: newID(), pc: pc, remote: peerAddr(remote)}
+	p.log = logging.With(s.log, "peer", p.id)
+	pc.OnTrack(func(t *pion.TrackRemote, _ *pion.RTPReceiver) { s.receive(p, t) })
+	pc.OnDataChannel(func(dc *pion.DataChannel) {
+		dc.OnOpen(func() { p.open(dc) })
+	})
+	pc.OnConnectionStateChange(func(st pion.PeerConnectionState) {
+		p.log.Debug("connection state", "state", st)
+		if st == pion.PeerConnectionStateFailed || st == pion.PeerConnectionStateClosed {
+			go s.hangUp(p)
+		}
+	})
+
+	answer, err := negotiate(ctx, pc, offer)
+	if err != nil {
+		_ = pc.Close()
+		return nil, "", err
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = pc.Close()
+		return nil, "", fmt.Errorf("%w: closed", errUnavailable)
+	}
+	s.peers[p.id] = p
+	s.mu.Unlock()
+	p.log.Info("connection started", "remote", remote)
+	return p, answer, nil
+}
+
+// negotiate sets the remote offer and returns the answer, once its
+// candidates are gathered.
+func negotiate(ctx context.Context, pc *pion.PeerConnection, offer string) (string, error) {
+	if err := pc.SetRemoteDescription(pion.SessionDescription{Type: pion.SDPTypeOffer, SDP: offer}); err != nil {
+		return "", fmt.Errorf("webrtc: bad offer: %w", err)
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return "", fmt.Errorf("webrtc: %w", err)
+	}
+	gathered := pion.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return "", fmt.Errorf("webrtc: %w", err)
+	}
+	select {
+	case <-gathered:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return pc.LocalDescription().SDP, nil
+}
+
+// hangUp closes the connection of p, which ends its tracks.
+func (s *Server) hangUp(p *peer) {
+	s.mu.Lock()
+	known := s.peers[p.id] == p
+	delete(s.peers, p.id)
+	s.mu.Unlock()
+	if !known {
+		return
+	}
+	if err := p.pc.Close(); err != nil {
+		p.log.Warn("closing connection", "error", err)
+	}
+	p.log.Info("connection ended")
+}
+
+// receive transcribes an audio track of p until it ends.
+func (s *Server) receive(p *peer, t *pion.TrackRemote) {
+	if t.Kind() != pion.RTPCodecTypeAudio {
+		return
+	}
+	var codec rtp.Codec
+	switch strings.ToLower(t.Codec().MimeType) {
+	case strings.ToLower(pion.MimeTypeOpus):
+		codec = rtp.Opus
+	case strings.ToLower(pion.MimeTypePCMU):
+		codec = rtp.PCMU
+	case strings.ToLower(pion.MimeTypePCMA):
+		codec = rtp.PCMA
+	default:
+		p.log.Warn("track in an unexpected codec", "codec", t.Codec().MimeType)
+		return
+	}
+	p.mu.Lock()
+	p.tracks++
+	id := "webrtc-" + p.id
+	if p.tracks > 1 {
+		id += fmt.Sprintf("-%d", p.tracks)
+	}
+	p.mu.Unlock()
+
+	s.mu.Lock()
+	s.bySession[id] = p
+	s.mu.Unlock()
+	tr, err := s.calls.Track(rtp.Call{SSRC: uint32(t.SSRC()), Peer: p.remote, Codec: codec, SessionID: id}, uint8(t.PayloadType()))
+	if err != nil {
+		p.log.Warn("track refused", "error", err)
+		s.mu.Lock()
+		delete(s.bySession, id)
+		s.mu.Unlock()
+		s.hangUp(p)
+		return
+	}
+	defer tr.Close()
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := t.Read(buf)
+		if err != nil {
+			return
+		}
+		if err := tr.Write(buf[:n]); err != nil {
+			p.log.Debug("bad RTP packet", "error", err)
+		}
+	}
+}
+
+// segment sends seg on the data channel of the connection of c.
+func (s *Server) segment(c rtp.Call, seg voxa.Segment) {
+	if s.cfg.Encode == nil {
+		return
+	}
+	s.mu.Lock()
+	p := s.bySession[c.SessionID]
+	s.mu.Unlock()
+	if p == nil {
+		return
+	}
+	b, err := s.cfg.Encode(c.SessionID, seg)
+	if err != nil {
+		p.log.Warn("encoding segment", "session", c.SessionID, "error", err)
+		return
+	}
+	p.send(b)
+}
+
+// ended forgets the session of a track, and ends the connection of a
+// track that failed, such as one whose session was terminated, so the peer
+// learns of it.
+func (s *Server) ended(c rtp.Call, _ rtp.Stats, err error) {
+	s.mu.Lock()
+	p := s.bySession[c.SessionID]
+	delete(s.bySession, c.SessionID)
+	s.mu.Unlock()
+	if p != nil && err != nil {
+		s.hangUp(p)
+	}
+}
+
+// peer is a peer connection.
+type peer struct {
+	id     string
+	pc     *pion.PeerConnection
+	remote net.Addr
+	log    logging.Logger
+
+	mu      sync.Mutex
+	dc      *pion.DataChannel
+	pending [][]byte // messages sent before dc opened
+	tracks  int
+}
+
+// maxPending bounds the messages held for a data channel not open yet, as
+// the first segments may come before it.
+const maxPending = 64
+
+// open sends the messages held on dc, and later ones as they come.
+func (p *peer) open(dc *pion.DataChannel) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dc != nil {
+		return // the first channel opened gets the messages
+	}
+	p.dc = dc
+	for _, b := range p.pending {
+		p.write(b)
+	}
+	p.pending = nil
+}
+
+func (p *peer) send(b []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.dc != nil:
+		p.write(b)
+	case len(p.pending) < maxPending:
+		p.pending = append(p.pending, b)
+	}
+}
+
+// write sends b on the data channel. p.mu is held.
+func (p *peer) write(b []byte) {
+	if err := p.dc.SendText(string(b)); err != nil {
+		p.log.Debug("sending on data channel", "error", err)
+	}
+}
+
+// peerAddr parses the remote address of an HTTP request.
+func peerAddr(remote string) net.Addr {
+	ap, err := netip.ParseAddrPort(remote)
+	if err != nil {
+		return &net.TCPAddr{}
+	}
+	return net.TCPAddrFromAddrPort(ap)
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,547 @@
+package itn
+
+import (
+	"strconv"
+	"strings"
+)
+
+// lookup returns the number word w, which may be a hyphenated compound such
+// as "twenty-five" or "quatre-vingt-dix".
+func (l *locale) lookup(w string) (numWord, bool) {
+	if nw, ok := l.words[w]; ok {
+		return nw, true
+	}
+	if !strings.Contains(w, "-") {
+		return numWord{}, false
+	}
+	var parts []string
+	for _, p := range strings.Split(w, "-") {
+		if k := len(parts) - 1; k >= 0 {
+			if _, ok := l.words[parts[k]+"-"+p]; ok {
+				parts[k] += "-" + p
+				continue
+			}
+		}
+		parts = append(parts, p)
+	}
+	v, n := l.cardinal(parts)
+	if n != len(parts) || len(parts) < 2 {
+		return numWord{}, false
+	}
+	switch {
+	case v < 10:
+		return numWord{unit, v}, true
+	case v < 100:
+		return numWord{teen, v}, true
+	case v < 1000:
+		return numWord{hundreds, v}, true
+	}
+	return numWord{}, false
+}
+
+// number returns the value of the number word w on its own: 100 for
+// "hundred".
+func (l *locale) number(w string) (int64, bool) {
+	nw, ok := l.lookup(w)
+	if !ok || nw.kind == conj || nw.kind == one {
+		return 0, false
+	}
+	return nw.value, true
+}
+
+// cardinal parses the longest cardinal number at the start of ws, returning
+// its value and how many words it spans, 0 if none.
+func (l *locale) cardinal(ws []string) (int64, int) {
+	var total, cur int64
+	var last kind
+	n := 0
+loop:
+	for j := 0; j < len(ws); j++ {
+		w, ok := l.lookup(ws[j])
+		if !ok {
+			break
+		}
+		switch w.kind {
+		case conj:
+			// A conjunction only counts when a number word follows.
+			if !l.conjAfter[last] {
+				break loop
+			}
+			continue
+		case one:
+			if last != 0 || j+1 >= len(ws) {
+				break loop
+			}
+			if next, ok := l.lookup(ws[j+1]); !ok || (next.kind != hundred && next.kind != scale) {
+				break loop
+			}
+			cur, w.kind = 1, unit
+		case unit:
+			if last == unit || last == teen {
+				break loop
+			}
+			cur += w.value
+		case teen:
+			if last == unit || last == teen || (last == tens && !l.tensTeen[cur%100]) {
+				break loop
+			}
+			cur += w.value
+		case tens:
+			if last == unit || last == teen || last == tens {
+				break loop
+			}
+			cur += w.value
+		case hundreds:
+			if last != 0 && last != scale {
+				break loop
+			}
+			cur += w.value
+		case hundred:
+			if last == hundred || last == hundreds {
+				break loop
+			}
+			if cur == 0 {
+				if !l.bareScale || last != 0 && last != scale {
+					break loop
+				}
+				cur = 1
+			}
+			cur *= 100
+		case scale:
+			if cur == 0 {
+				if !l.bareScale || last != 0 {
+					break loop
+				}
+				cur = 1
+			}
+			total += cur * w.value
+			cur = 0
+		}
+		last = w.kind
+		n = j + 1
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return total + cur, n
+}
+
+// pair parses a number from 10 to 99 of at most two words, as years and
+// minutes are read.
+func (l *locale) pair(ws []string) (int64, int) {
+	if len(ws) > 2 {
+		ws = ws[:2]
+	}
+	v, n := l.cardinal(ws)
+	if v < 10 || v > 99 {
+		return 0, 0
+	}
+	return v, n
+}
+
+// ordinalWord returns the value of an ordinal word, such as "third" or
+// "twenty-first".
+func (l *locale) ordinalWord(w string) (int64, bool) {
+	if v, ok := l.ordinals[w]; ok {
+		return v, true
+	}
+	if l.ordinal != nil {
+		if v, ok := l.ordinal(l, w); ok {
+			return v, true
+		}
+	}
+	if i := strings.LastIndex(w, "-"); i > 0 {
+		if o, ok := l.ordinals[w[i+1:]]; ok {
+			if c, n := l.cardinal(strings.Split(w[:i], "-")); n > 0 && n == strings.Count(w[:i], "-")+1 {
+				return ordinalValue(c, o)
+			}
+		}
+	}
+	return 0, false
+}
+
+// ordinalValue combines the cardinal c read before the ordinal o: "twenty
+// third" is 23, "two hundredth" 200.
+func ordinalValue(c, o int64) (int64, bool) {
+	switch {
+	case c == 0:
+		return o, true
+	case o >= 100:
+		return c * o, true
+	case c%100 == 0 || (c%10 == 0 && o < 10):
+		return c + o, true
+	}
+	return 0, false
+}
+
+// ordinalAt parses an ordinal number at the start of ws.
+func (l *locale) ordinalAt(ws []string) (int64, int) {
+	c, k := l.cardinal(ws)
+	j := k
+	if k > 0 && j+1 < len(ws) {
+		if w, ok := l.lookup(ws[j]); ok && w.kind == conj {
+			j++
+		}
+	}
+	if j >= len(ws) {
+		return 0, 0
+	}
+	o, ok := l.ordinalWord(ws[j])
+	if !ok {
+		return 0, 0
+	}
+	v, ok := ordinalValue(c, o)
+	if !ok {
+		return 0, 0
+	}
+	return v, j + 1
+}
+
+// format writes n with the digit groups of l.
+func (l *locale) format(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	if n < 10000 {
+		return s
+	}
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(l.group)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// decimalAt parses a decimal number such as "three point one four" at the
+// start of ws.
+func (l *locale) decimalAt(ws []string) (string, int) {
+	c, k := l.cardinal(ws)
+	if k == 0 || k >= len(ws) || ws[k] != l.point {
+		return "", 0
+	}
+	var digits strings.Builder
+	j := k + 1
+	for ; j < len(ws); j++ {
+		if w, ok := l.lookup(ws[j]); ok && w.kind == unit {
+			digits.WriteString(strconv.FormatInt(w.value, 10))
+		} else if ws[j] == "oh" && l.spokenYears {
+			digits.WriteByte('0')
+		} else {
+			break
+		}
+	}
+	if digits.Len() == 0 {
+		f, n := l.cardinal(ws[j:])
+		if n == 0 {
+			return "", 0
+		}
+		digits.WriteString(strconv.FormatInt(f, 10))
+		j += n
+	}
+	return l.format(c) + l.decimal + digits.String(), j
+}
+
+// amountAt parses a decimal or cardinal number at the start of ws,
+// preceded by a minus sign if signed.
+func (l *locale) amountAt(ws []string, signed bool) (string, int) {
+	sign, off := "", 0
+	if signed {
+		sign, off = signAt(l, ws)
+	}
+	if d, k := l.decimalAt(ws[off:]); k > 0 {
+		return sign + d, off + k
+	}
+	v, k := l.cardinal(ws[off:])
+	if k == 0 {
+		return "", 0
+	}
+	return sign + l.format(v), off + k
+}
+
+func matchCardinal(l *locale, ws []string, _ string) (int, string) {
+	sign, off := signAt(l, ws)
+	v, n := l.cardinal(ws[off:])
+	if n == 0 || (sign == "" && n == 1 && v < 10) {
+		return 0, ""
+	}
+	return off + n, sign + l.format(v)
+}
+
+func matchDecimal(l *locale, ws []string, _ string) (int, string) {
+	sign, off := signAt(l, ws)
+	d, n := l.decimalAt(ws[off:])
+	if n == 0 {
+		return 0, ""
+	}
+	return off + n, sign + d
+}
+
+// signAt returns the minus sign at the start of ws, and how many words it
+// spans.
+func signAt(l *locale, ws []string) (string, int) {
+	if len(ws) > 1 && l.minus[ws[0]] {
+		return "-", 1
+	}
+	return "", 0
+}
+
+func matchOrdinal(l *locale, ws []string, _ string) (int, string) {
+	v, n := l.ordinalAt(ws)
+	if n == 0 || (n == 1 && v < 10) {
+		return 0, ""
+	}
+	return n, l.suffix(v)
+}
+
+func matchPercent(l *locale, ws []string, _ string) (int, string) {
+	d, n := l.amountAt(ws, true)
+	if n == 0 || !hasWords(ws[n:], l.percent) {
+		return 0, ""
+	}
+	return n + len(l.percent), d + l.percentSep + "%"
+}
+
+// hasWords reports whether ws starts with want.
+func hasWords(ws, want []string) bool {
+	if len(ws) < len(want) {
+		return false
+	}
+	for i, w := range want {
+		if ws[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+func matchCurrency(l *locale, ws []string, _ string) (int, string) {
+	amount, n := l.amountAt(ws, false)
+	if n == 0 && len(ws) > 1 && ws[0] == "a" {
+		amount, n = "1", 1 // "a dollar"
+	}
+	if n == 0 || n >= len(ws) {
+		return 0, ""
+	}
+	cur, ok := l.currencies[ws[n]]
+	if !ok {
+		return 0, ""
+	}
+	n++
+	if cur.minor != nil && !strings.Contains(amount, l.decimal) {
+		j := n
+		withConj := j < len(ws) && ws[j] == l.minorConj
+		if withConj {
+			j++
+		}
+		if m, k := l.cardinal(ws[j:]); k > 0 && m > 0 && m < 100 {
+			j += k
+			named := j < len(ws) && cur.minor[ws[j]]
+			if named {
+				j++
+			}
+			if named || !withConj {
+				amount += l.decimal + pad2(m)
+				n = j
+			}
+		}
+	}
+	if l.symbolAfter {
+		return n, amount + l.percentSep + cur.symbol
+	}
+	return n, cur.symbol + amount
+}
+
+// yearAt parses a year at the start of ws: read as two pairs, "nineteen
+// ninety nine", where the locale does, or as a cardinal from 1000 to 2999.
+func (l *locale) yearAt(ws []string) (int64, int) {
+	y, n := l.spokenYear(ws)
+	if v, k := l.cardinal(ws); k > n && v >= 1000 && v <= 2999 {
+		return v, k
+	}
+	return y, n
+}
+
+// spokenYear parses a year read as two pairs: "twenty twenty four",
+// "nineteen oh five", "nineteen hundred".
+func (l *locale) spokenYear(ws []string) (int64, int) {
+	if !l.spokenYears {
+		return 0, 0
+	}
+	hi, n := l.pair(ws)
+	if n == 0 || n >= len(ws) {
+		return 0, 0
+	}
+	switch rest := ws[n:]; {
+	case rest[0] == "hundred":
+		return hi * 100, n + 1
+	case rest[0] == "oh" && len(rest) > 1:
+		if w, ok := l.lookup(rest[1]); ok && w.kind == unit && w.value > 0 {
+			return hi*100 + w.value, n + 2
+		}
+	default:
+		if lo, k := l.pair(rest); k > 0 {
+			return hi*100 + lo, n + k
+		}
+	}
+	return 0, 0
+}
+
+// dayAt parses the day of a month at the start of ws, an ordinal or, when
+// cardinals is set, a cardinal.
+func (l *locale) dayAt(ws []string, cardinals bool) (int64, int) {
+	v, n := l.ordinalAt(ws)
+	if n == 0 && cardinals {
+		v, n = l.cardinal(ws)
+	}
+	if n == 0 || v < 1 || v > 31 {
+		return 0, 0
+	}
+	return v, n
+}
+
+func matchDate(l *locale, ws []string, _ string) (int, string) {
+	best, text := 0, ""
+	try := func(n int, s string) {
+		if n > best {
+			best, text = n, s
+		}
+	}
+	year := func(ws []string) (int64, int) {
+		if l.dateOf != "" && l.datePattern == dayOfMonth {
+			if len(ws) < 2 || ws[0] != l.dateOf {
+				return 0, 0
+			}
+			y, n := l.yearAt(ws[1:])
+			if n == 0 {
+				return 0, 0
+			}
+			return y, n + 1
+		}
+		return l.yearAt(ws)
+	}
+	switch l.datePattern {
+	case monthFirst:
+		if y, n := l.spokenYear(ws); n > 0 && y >= 1100 && y <= 2099 {
+			try(n, strconv.FormatInt(y, 10))
+		}
+		if month, ok := l.months[first(ws)]; ok {
+			j := 1
+			if j < len(ws) && ws[j] == "the" {
+				j++
+			}
+			if d, k := l.dayAt(ws[j:], false); k > 0 {
+				j += k
+				y, k := year(ws[j:])
+				try(j+k, l.date(month, d, y))
+			}
+			if y, k := year(ws[1:]); k > 0 {
+				try(1+k, month+" "+strconv.FormatInt(y, 10))
+			}
+		}
+		// "the third of march"
+		j := 0
+		if first(ws) == "the" {
+			j++
+		}
+		if d, k := l.dayAt(ws[j:], false); k > 0 && j+k+1 < len(ws) && ws[j+k] == l.dateOf {
+			j += k + 1
+			if month, ok := l.months[ws[j]]; ok {
+				j++
+				y, k := year(ws[j:])
+				try(j+k, l.date(month, d, y))
+			}
+		}
+	case dayFirst, dayOfMonth:
+		d, j := l.dayAt(ws, true)
+		if j == 0 {
+			break
+		}
+		if l.datePattern == dayOfMonth {
+			if j >= len(ws) || ws[j] != l.dateOf {
+				break
+			}
+			j++
+		}
+		if month, ok := l.months[first(ws[j:])]; ok {
+			j++
+			y, k := year(ws[j:])
+			try(j+k, l.date(month, d, y))
+		}
+	}
+	return best, text
+}
+
+func first(ws []string) string {
+	if len(ws) == 0 {
+		return ""
+	}
+	return ws[0]
+}
+
+// ampm parses "am", "p.m" or "p m" at the start of ws.
+func ampm(ws []string) (string, int) {
+	switch first(ws) {
+	case "am", "a.m":
+		return "am", 1
+	case "pm", "p.m":
+		return "pm", 1
+	case "a", "p":
+		if len(ws) > 1 && ws[1] == "m" {
+			return ws[0] + "m", 2
+		}
+	}
+	return "", 0
+}
+
+func matchTime(l *locale, ws []string, prev string) (int, string) {
+	if l.clock == nil {
+		return 0, ""
+	}
+	h, n := l.cardinal(ws)
+	if n == 0 || n > 2 {
+		return 0, ""
+	}
+	if l.hours != nil {
+		// "quinze heures trente", "trois heures et demie"
+		if h > 23 || n >= len(ws) || !l.hours[ws[n]] {
+			return 0, ""
+		}
+		n++
+		minute := int64(-1)
+		if hasWords(ws[n:], []string{"et", "demie"}) {
+			minute, n = 30, n+2
+		} else if hasWords(ws[n:], []string{"et", "quart"}) {
+			minute, n = 15, n+2
+		} else if m, k := l.cardinal(ws[n:]); k > 0 && m > 0 && m < 60 {
+			minute, n = m, n+k
+		}
+		return n, l.clock(h, minute, "")
+	}
+	if h < 1 || h > 12 || n >= len(ws) {
+		return 0, ""
+	}
+	minute := int64(-1)
+	clock := false
+	switch rest := ws[n:]; {
+	case rest[0] == "o'clock":
+		minute, clock = 0, true
+		n++
+	case rest[0] == "oh" && len(rest) > 1:
+		if w, ok := l.lookup(rest[1]); ok && w.kind == unit && w.value > 0 {
+			minute = w.value
+			n += 2
+		}
+	default:
+		if m, k := l.pair(rest); k > 0 && m < 60 {
+			minute = m
+			n += k
+		}
+	}
+	mark, k := ampm(ws[n:])
+	n += k
+	if mark == "" && !clock && (minute < 0 || prev != "at") {
+		return 0, ""
+	}
+	return n, l.clock(h, minute, mark)
+}
@@ -0,0 +1,216 @@
+// Package itn applies inverse text normalization to transcripts: it writes
+// the numbers, dates, times, amounts of money and percentages recognizers
+// spell out the way people write them, so "twenty five dollars on march
+// third" reads "$25 on March 3".
+//
+// A Normalizer rewrites spoken forms by the rules of a locale, which fix the
+// number words, the separators of written numbers, currency symbols and the
+// order of dates: en-US writes "March 3, 2024" where en-GB writes "3 March
+// 2024". Each entity class can be turned off, leaving its spoken form as
+// is. Standalone numbers under ten stay spelled out, as style guides write
+// them and since "one" is as often a pronoun as a number; within a larger
+// entity, such as a date or an amount, they are written in digits.
+//
+// Timed words spanning an entity are merged into one word, from the start
+// of the first to the end of the last.
+package itn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jmarc101/voxa/internal/langtag"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// defaultLocale applies to segments that report no language.
+const defaultLocale = "en-US"
+
+// Class is an entity class.
+type Class string
+
+// Entity classes.
+const (
+	// Cardinal numbers: "two hundred and five" is "205".
+	Cardinal Class = "cardinal"
+	// Ordinal numbers: "twenty first" is "21st".
+	Ordinal Class = "ordinal"
+	// Decimal numbers: "three point one four" is "3.14".
+	Decimal Class = "decimal"
+	// Percent: "fifty percent" is "50%".
+	Percent Class = "percent"
+	// Currency: "twenty dollars and fifty cents" is "$20.50".
+	Currency Class = "currency"
+	// Date: dates and years, "march third" is "March 3".
+	Date Class = "date"
+	// Time: times of day, "three thirty pm" is "3:30 PM".
+	Time Class = "time"
+)
+
+// Classes returns every entity class.
+func Classes() []Class {
+	return []Class{Cardinal, Ordinal, Decimal, Percent, Currency, Date, Time}
+}
+
+// Config configures a Normalizer.
+type Config struct {
+	// Locale selects the rules of segments that report no language, or
+	// whose language is the locale's, among Locales. A bare language
+	// selects its default locale, "en" being "en-US". Defaults to "en-US".
+	// Segments in another language with rules use its default locale;
+	// those in languages without rules pass through unchanged.
+	Locale string
+	// Disable lists the entity classes left spelled out.
+	Disable []Class
+}
+
+// Normalizer writes spoken entities in written form. It is safe for
+// concurrent use.
+type Normalizer struct {
+	def   *locale
+	langs map[string]*locale // by ISO 639-1 code
+	off   map[Class]bool
+}
+
+// New validates cfg.
+func New(cfg Config) (*Normalizer, error) {
+	if cfg.Locale == "" {
+		cfg.Locale = defaultLocale
+	}
+	name := canonical(cfg.Locale)
+	build, ok := locales[name]
+	if !ok {
+		return nil, fmt.Errorf("itn: no rules for locale %q (have %v)", cfg.Locale, Locales())
+	}
+	n := &Normalizer{langs: map[string]*locale{}, off: map[Class]bool{}}
+	for _, c := range cfg.Disable {
+		if !known(c) {
+			return nil, fmt.Errorf("itn: unknown entity class %q (have %v)", c, Classes())
+		}
+		n.off[c] = true
+	}
+	if len(n.off) == len(Classes()) {
+		return nil, errors.New("itn: every entity class is disabled")
+	}
+	for lang, name := range byLanguage {
+		l := locales[name]()
+		n.langs[lang] = &l
+	}
+	l := build()
+	n.def = &l
+	n.langs[langtag.Base(name)] = n.def
+	return n, nil
+}
+
+func known(c Class) bool {
+	for _, k := range Classes() {
+		if c == k {
+			return true
+		}
+	}
+	return false
+}
+
+// canonical returns the name of a locale as Locales has it: "en-GB" for
+// "en_gb", and the default locale of a bare language.
+func canonical(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	lang, region, ok := strings.Cut(tag, "-")
+	if !ok {
+		if name, ok := byLanguage[strings.ToLower(lang)]; ok {
+			return name
+		}
+		return tag
+	}
+	return strings.ToLower(lang) + "-" + strings.ToUpper(region)
+}
+
+// Process writes the entities in the text and words of seg in written form.
+// It implements plugin.Transcript, so the normalizer runs like any other
+// transcript stage.
+func (n *Normalizer) Process(_ context.Context, seg *stt.Segment) error {
+	l := n.def
+	if seg.Language != "" {
+		if l = n.langs[langtag.Base(seg.Language)]; l == nil {
+			return nil
+		}
+	}
+	fields := strings.Fields(seg.Text)
+	spans, changed := n.normalize(l, fields)
+	if !changed {
+		return nil
+	}
+	stt.RewriteSpans(seg, spans)
+	return nil
+}
+
+// matchers find the entities of each class in words, returning how many
+// they span and their written form, or 0. prev is the word before, if any.
+var matchers = []struct {
+	class Class
+	match func(l *locale, words []string, prev string) (int, string)
+}{
+	{Date, matchDate},
+	{Time, matchTime},
+	{Currency, matchCurrency},
+	{Percent, matchPercent},
+	{Ordinal, matchOrdinal},
+	{Decimal, matchDecimal},
+	{Cardinal, matchCardinal},
+}
+
+// normalize rewrites fields by the rules of l. An entity never spans
+// punctuation: it ends at a word followed by some, and a word preceded by
+// some starts a new one.
+func (n *Normalizer) normalize(l *locale, fields []string) ([]stt.Span, bool) {
+	type word struct{ lead, core, trail string }
+	ws := make([]word, len(fields))
+	lower := make([]string, len(fields))
+	for i, f := range fields {
+		core := strings.TrimLeftFunc(f, isPunct)
+		lead := f[:len(f)-len(core)]
+		trimmed := strings.TrimRightFunc(core, isPunct)
+		ws[i] = word{lead, trimmed, core[len(trimmed):]}
+		lower[i] = strings.ToLower(trimmed)
+	}
+	var spans []stt.Span
+	changed := false
+	for i := 0; i < len(fields); {
+		end := i + 1
+		for end < len(fields) && ws[end-1].trail == "" && ws[end].lead == "" {
+			end++
+		}
+		prev := ""
+		if i > 0 && ws[i-1].trail == "" && ws[i].lead == "" {
+			prev = lower[i-1]
+		}
+		best, text := 0, ""
+		for _, m := range matchers {
+			if n.off[m.class] {
+				continue
+			}
+			if k, t := m.match(l, lower[i:end], prev); k > best {
+				best, text = k, t
+			}
+		}
+		if best == 0 {
+			spans = append(spans, stt.Span{N: 1, Text: fields[i]})
+			i++
+			continue
+		}
+		spans = append(spans, stt.Span{N: best, Text: ws[i].lead + text + ws[i+best-1].trail})
+		changed = true
+		i += best
+	}
+	return spans, changed
+}
+
+// isPunct reports whether c is punctuation around a word. Apostrophes and
+// hyphens within words, as in "o'clock", are not trimmed since they sit
+// between letters.
+func isPunct(c rune) bool {
+	return unicode.IsPunct(c) || c == '¿' || c == '¡'
+}
@@ -0,0 +1,227 @@
+package itn
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+func TestProcess(t *testing.T) {
+	normalizers := map[string]*Normalizer{}
+	for _, tc := range []struct {
+		locale  string
+		class   Class
+		in, out string
+	}{
+		{"en-US", Cardinal, "two hundred and five", "205"},
+		{"en-US", Cardinal, "twenty-five people", "25 people"},
+		{"en-US", Cardinal, "three thousand four hundred", "3400"},
+		{"en-US", Cardinal, "twelve thousand", "12,000"},
+		{"en-US", Cardinal, "a hundred and one", "101"},
+		{"en-US", Cardinal, "minus forty", "-40"},
+		{"en-US", Cardinal, "one of them", "one of them"},
+		{"en-US", Cardinal, "seven days", "seven days"},
+		{"en-US", Cardinal, "twenty, thirty", "20, 30"},
+		{"en-US", Ordinal, "the twenty first", "the 21st"},
+		{"en-US", Ordinal, "the hundredth time", "the 100th time"},
+		{"en-US", Ordinal, "the third one", "the third one"},
+		{"en-US", Decimal, "three point one four", "3.14"},
+		{"en-US", Decimal, "zero point five", "0.5"},
+		{"en-US", Percent, "fifty percent", "50%"},
+		{"en-US", Percent, "two point five percent", "2.5%"},
+		{"en-US", Currency, "twenty five dollars", "$25"},
+		{"en-US", Currency, "twenty dollars and fifty cents", "$20.50"},
+		{"en-US", Currency, "a dollar", "$1"},
+		{"en-US", Currency, "five pounds", "£5"},
+		{"en-US", Date, "march third", "March 3"},
+		{"en-US", Date, "march the third twenty twenty four", "March 3, 2024"},
+		{"en-US", Date, "the fourth of july", "July 4"},
+		{"en-US", Date, "in nineteen ninety nine", "in 1999"},
+		{"en-US", Date, "june two thousand and one", "June 2001"},
+		{"en-US", Time, "three thirty pm", "3:30 PM"},
+		{"en-US", Time, "at seven oh five", "at 7:05"},
+		{"en-US", Time, "six o'clock", "6:00"},
+		{"en-US", Time, "ten a m", "10 AM"},
+		{"en-GB", Date, "march third twenty twenty four", "3 March 2024"},
+		{"en-GB", Date, "the fourth of july", "4 July"},
+		{"en-GB", Time, "three thirty pm", "3:30 pm"},
+		{"en-GB", Currency, "ten pounds fifty", "£10.50"},
+		{"en-GB", Cardinal, "twelve thousand", "12,000"},
+		{"fr-FR", Cardinal, "quatre-vingt-dix-sept", "97"},
+		{"fr-FR", Cardinal, "soixante et onze", "71"},
+		{"fr-FR", Cardinal, "mille deux cents", "1200"},
+		{"fr-FR", Cardinal, "douze mille", "12\u202f000"},
+		{"fr-FR", Ordinal, "le vingtième siècle", "le 20e siècle"},
+		{"fr-FR", Cardinal, "un", "un"},
+		{"fr-FR", Decimal, "trois virgule cinq", "3,5"},
+		{"fr-FR", Percent, "cinquante pour cent", "50\u202f%"},
+		{"fr-FR", Currency, "vingt euros et cinquante centimes", "20,50\u202f€"},
+		{"fr-FR", Date, "trois mars deux mille vingt-quatre", "3 mars 2024"},
+		{"fr-FR", Date, "premier mai", "1er mai"},
+		{"fr-FR", Time, "quinze heures trente", "15\u202fh\u202f30"},
+		{"fr-FR", Time, "trois heures et demie", "3\u202fh\u202f30"},
+		{"es-ES", Cardinal, "doscientos treinta y cuatro", "234"},
+		{"es-ES", Cardinal, "mil quinientos", "1500"},
+		{"es-ES", Cardinal, "doce mil", "12.000"},
+		{"es-ES", Ordinal, "el décimo", "el 10.º"},
+		{"es-ES", Decimal, "tres coma cinco", "3,5"},
+		{"es-ES", Percent, "veinte por ciento", "20\u00a0%"},
+		{"es-ES", Currency, "diez euros con cincuenta céntimos", "10,50\u00a0€"},
+		{"es-ES", Date, "tres de marzo de dos mil veinticuatro", "3 de marzo de 2024"},
+		{"es-ES", Date, "primero de mayo", "1 de mayo"},
+	} {
+		n := normalizers[tc.locale]
+		if n == nil {
+			var err error
+			if n, err = New(Config{Locale: tc.locale}); err != nil {
+				t.Fatal(err)
+			}
+			normalizers[tc.locale] = n
+		}
+		seg := &stt.Segment{Text: tc.in}
+		if err := n.Process(context.Background(), seg); err != nil {
+			t.Fatal(err)
+		}
+		if seg.Text != tc.out {
+			t.Errorf("%s %s %q\n got %q\nwant %q", tc.locale, tc.class, tc.in, seg.Text, tc.out)
+		}
+		if tc.in == tc.out {
+			continue
+		}
+		// With the class off, the entity is not written the same way.
+		off, err := New(Config{Locale: tc.locale, Disable: []Class{tc.class}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seg = &stt.Segment{Text: tc.in}
+		if err := off.Process(context.Background(), seg); err != nil {
+			t.Fatal(err)
+		}
+		if seg.Text == tc.out {
+			t.Errorf("%s %s %q still written %q with %s disabled", tc.locale, tc.class, tc.in, seg.Text, tc.class)
+		}
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	n, err := New(Config{Locale: "en_gb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		lang, in, out string
+	}{
+		{"", "march third", "3 March"},
+		{"en-US", "march third", "3 March"}, // en is en-GB's
+		{"fr", "cinquante pour cent", "50\u202f%"},
+		{"es-MX", "veinte por ciento", "20\u00a0%"},
+		{"de", "zwanzig prozent", "zwanzig prozent"},
+	} {
+		seg := &stt.Segment{Text: tc.in, Language: tc.lang}
+		if err := n.Process(context.Background(), seg); err != nil {
+			t.Fatal(err)
+		}
+		if seg.Text != tc.out {
+			t.Errorf("%q in %q\n got %q\nwant %q", tc.in, tc.lang, seg.Text, tc.out)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{
+		{Locale: "de-DE"},
+		{Disable: []Class{"roman"}},
+		{Disable: Classes()},
+	} {
+		if _, err := New(cfg); err == nil || !strings.HasPrefix(err.Error(), "itn: ") {
+			t.Errorf("New(%+v) = %v, want an itn error", cfg, err)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	n, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	// timed returns the words of text, each 100ms long after a 100ms gap,
+	// with confidences from 0.9 down.
+	timed := func(text string) []stt.Word {
+		var words []stt.Word
+		for i, w := range strings.Fields(text) {
+			words = append(words, stt.Word{Text: w, Start: ms(200 * i), End: ms(200*i + 100), Confidence: 0.9 - 0.1*float32(i)})
+		}
+		return words
+	}
+	for _, tc := range []struct {
+		name  string
+		text  string
+		words []stt.Word
+		out   string
+		want  []stt.Word
+	}{
+		{
+			name:  "span merged",
+			text:  "paid twenty five dollars, thanks",
+			words: timed("paid twenty five dollars, thanks"),
+			out:   "paid $25, thanks",
+			want: []stt.Word{
+				{Text: "paid", Start: 0, End: ms(100), Confidence: 0.9},
+				{Text: "$25,", Start: ms(200), End: ms(700), Confidence: 0.7},
+				{Text: "thanks", Start: ms(800), End: ms(900), Confidence: 0.5},
+			},
+		},
+		{
+			name:  "one word span",
+			text:  "twenty-five people",
+			words: timed("twenty-five people"),
+			out:   "25 people",
+			want: []stt.Word{
+				{Text: "25", Start: 0, End: ms(100), Confidence: 0.9},
+				{Text: "people", Start: ms(200), End: ms(300), Confidence: 0.8},
+			},
+		},
+		{
+			name:  "words and fields differ",
+			text:  "march third",
+			words: timed("march the third"),
+			out:   "March 3",
+			want:  timed("march the third"),
+		},
+		{
+			name: "no words",
+			text: "march third",
+			out:  "March 3",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			orig := slices.Clone(tc.words)
+			seg := &stt.Segment{Text: tc.text, Words: tc.words}
+			if err := n.Process(context.Background(), seg); err != nil {
+				t.Fatal(err)
+			}
+			if seg.Text != tc.out {
+				t.Errorf("text %q, want %q", seg.Text, tc.out)
+			}
+			if len(seg.Words) != len(tc.want) {
+				t.Fatalf("words\n got %+v\nwant %+v", seg.Words, tc.want)
+			}
+			for i, w := range seg.Words {
+				want := tc.want[i]
+				if w.Text != want.Text || w.Start != want.Start || w.End != want.End || !near(w.Confidence, want.Confidence) {
+					t.Errorf("word %d\n got %+v\nwant %+v", i, w, want)
+				}
+			}
+			if !slices.Equal(tc.words, orig) {
+				t.Errorf("the recognizer's words were changed: %+v", tc.words)
+			}
+		})
+	}
+}
+
+func near(a, b float32) bool { return a-b < 1e-6 && b-a < 1e-6 }
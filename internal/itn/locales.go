@@ -0,0 +1,389 @@
+package itn
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kind is the grammatical role of a number word.
+type kind uint8
+
+const (
+	unit     kind = iota + 1 // 0 to 9
+	teen                     // a complete two-digit number, such as 10 to 19
+	tens                     // 20, 30 ... 90
+	hundreds                 // a multiple of 100 in one word: "doscientos"
+	hundred                  // multiplies by 100
+	scale                    // thousand and up
+	one                      // "a", as in "a hundred"
+	conj                     // joins number words: "and", "y", "et"
+)
+
+type numWord struct {
+	kind  kind
+	value int64
+}
+
+// currency is a currency the locale names.
+type currency struct {
+	symbol string
+	minor  map[string]bool // words of the subunit, such as "cents"
+}
+
+// locale is the rule set of one locale. Words are lowercase.
+type locale struct {
+	words map[string]numWord
+	// conjAfter lists the kinds a conjunction may follow.
+	conjAfter map[kind]bool
+	// tensTeen lists the tens a teen may follow, as in French
+	// "soixante-dix".
+	tensTeen map[int64]bool
+	// bareScale lets "hundred" and scales stand without a multiplier, as
+	// "mil" does.
+	bareScale bool
+	// ordinals maps ordinal words to their value.
+	ordinals map[string]int64
+	// ordinal, if set, parses ordinal words missing from ordinals.
+	ordinal func(l *locale, w string) (int64, bool)
+	suffix  func(n int64) string // written ordinal: "1st"
+	minus   map[string]bool
+	point   string // decimal point word
+	// group and decimal separate the digits of written numbers; groups
+	// are written from 10000 up.
+	group, decimal string
+	// percent are the words of "%", one or two.
+	percent []string
+	// percentSep goes between a number and "%", or a currency symbol
+	// written after it.
+	percentSep  string
+	currencies  map[string]currency
+	symbolAfter bool   // "25 €" rather than "€25"
+	minorConj   string // between the units and subunits: "and"
+	months      map[string]string
+	// date writes a date; year is 0 when not given.
+	date func(month string, day, year int64) string
+	// datePattern selects the spoken order of dates.
+	datePattern int
+	// dateOf is the word between day and month: "of", "de".
+	dateOf string
+	// hours is the word after the hour of a time, for locales that say
+	// one: "heures".
+	hours map[string]bool
+	// clock writes a time; minute is -1 when not given.
+	clock func(hour, minute int64, ampm string) string
+	// spokenYears enables years read as two pairs: "nineteen ninety".
+	spokenYears bool
+}
+
+// Date patterns.
+const (
+	// monthFirst is "march [the] third [twenty twenty four]" and "the third of march".
+	monthFirst = iota + 1
+	// dayFirst is "trois mars [deux mille vingt-quatre]".
+	dayFirst
+	// dayOfMonth is "tres de marzo [de dos mil veinticuatro]".
+	dayOfMonth
+)
+
+func words(kind kind, step int64, ws ...string) map[string]numWord {
+	m := make(map[string]numWord, len(ws))
+	for i, w := range ws {
+		if w != "" {
+			m[w] = numWord{kind, int64(i) * step}
+		}
+	}
+	return m
+}
+
+func merge(ms ...map[string]numWord) map[string]numWord {
+	out := map[string]numWord{}
+	for _, m := range ms {
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func names(ws ...string) map[string]string {
+	m := make(map[string]string, len(ws))
+	for _, w := range ws {
+		m[strings.ToLower(w)] = w
+	}
+	return m
+}
+
+func flags(ws ...string) map[string]bool {
+	m := make(map[string]bool, len(ws))
+	for _, w := range ws {
+		m[w] = true
+	}
+	return m
+}
+
+// monthOrder are the names of the months, as written, by language.
+var monthOrder = map[string][]string{
+	"en": {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	"fr": {"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	"es": {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+}
+
+func englishSuffix(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+		return s + "th"
+	case n%10 == 1:
+		return s + "st"
+	case n%10 == 2:
+		return s + "nd"
+	case n%10 == 3:
+		return s + "rd"
+	}
+	return s + "th"
+}
+
+func twelveHour(lowerCase bool) func(hour, minute int64, ampm string) string {
+	return func(hour, minute int64, ampm string) string {
+		s := strconv.FormatInt(hour, 10)
+		if minute >= 0 {
+			s += ":" + pad2(minute)
+		}
+		if ampm != "" {
+			if !lowerCase {
+				ampm = strings.ToUpper(ampm)
+			}
+			s += " " + ampm
+		}
+		return s
+	}
+}
+
+func pad2(n int64) string {
+	if n < 10 {
+		return "0" + strconv.FormatInt(n, 10)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+var english = func() locale {
+	return locale{
+		words: merge(
+			words(unit, 1, "zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine"),
+			words(teen, 1, "", "", "", "", "", "", "", "", "", "", "ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"),
+			words(tens, 10, "", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"),
+			map[string]numWord{
+				"hundred":  {hundred, 100},
+				"thousand": {scale, 1e3},
+				"million":  {scale, 1e6},
+				"billion":  {scale, 1e9},
+				"trillion": {scale, 1e12},
+				"a":        {one, 1},
+				"and":      {conj, 0},
+			},
+		),
+		conjAfter: map[kind]bool{hundred: true, scale: true},
+		ordinals: map[string]int64{
+			"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "sixth": 6,
+			"seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10, "eleventh": 11, "twelfth": 12,
+			"thirteenth": 13, "fourteenth": 14, "fifteenth": 15, "sixteenth": 16,
+			"seventeenth": 17, "eighteenth": 18, "nineteenth": 19, "twentieth": 20,
+			"thirtieth": 30, "fortieth": 40, "fiftieth": 50, "sixtieth": 60,
+			"seventieth": 70, "eightieth": 80, "ninetieth": 90,
+			"hundredth": 100, "thousandth": 1e3, "millionth": 1e6,
+		},
+		suffix:     englishSuffix,
+		minus:      flags("minus", "negative"),
+		point:      "point",
+		group:      ",",
+		decimal:    ".",
+		percent:    []string{"percent"},
+		percentSep: "",
+		currencies: map[string]currency{
+			"dollar": {"$", flags("cent", "cents")}, "dollars": {"$", flags("cent", "cents")},
+			"euro": {"€", flags("cent", "cents")}, "euros": {"€", flags("cent", "cents")},
+			"pound": {"£", flags("penny", "pence")}, "pounds": {"£", flags("penny", "pence")},
+			"yen": {"¥", nil},
+		},
+		minorConj:   "and",
+		months:      names(monthOrder["en"]...),
+		datePattern: monthFirst,
+		dateOf:      "of",
+		spokenYears: true,
+	}
+}
+
+var locales = map[string]func() locale{
+	"en-US": func() locale {
+		l := english()
+		l.date = func(month string, day, year int64) string {
+			s := month + " " + strconv.FormatInt(day, 10)
+			if year > 0 {
+				s += ", " + strconv.FormatInt(year, 10)
+			}
+			return s
+		}
+		l.clock = twelveHour(false)
+		return l
+	},
+	"en-GB": func() locale {
+		l := english()
+		l.date = func(month string, day, year int64) string {
+			s := strconv.FormatInt(day, 10) + " " + month
+			if year > 0 {
+				s += " " + strconv.FormatInt(year, 10)
+			}
+			return s
+		}
+		l.clock = twelveHour(true)
+		return l
+	},
+	"fr-FR": func() locale {
+		return locale{
+			words: merge(
+				words(unit, 1, "zéro", "un", "deux", "trois", "quatre", "cinq", "six", "sept", "huit", "neuf"),
+				words(teen, 1, "", "", "", "", "", "", "", "", "", "", "dix", "onze", "douze", "treize", "quatorze", "quinze", "seize"),
+				words(tens, 10, "", "", "vingt", "trente", "quarante", "cinquante", "soixante"),
+				map[string]numWord{
+					"une":           {unit, 1},
+					"dix-sept":      {teen, 17},
+					"dix-huit":      {teen, 18},
+					"dix-neuf":      {teen, 19},
+					"quatre-vingt":  {tens, 80},
+					"quatre-vingts": {tens, 80},
+					"cent":          {hundred, 100},
+					"cents":         {hundred, 100},
+					"mille":         {scale, 1e3},
+					"million":       {scale, 1e6},
+					"millions":      {scale, 1e6},
+					"milliard":      {scale, 1e9},
+					"milliards":     {scale, 1e9},
+					"et":            {conj, 0},
+				},
+			),
+			conjAfter: map[kind]bool{tens: true},
+			tensTeen:  map[int64]bool{60: true, 80: true},
+			bareScale: true,
+			ordinals:  map[string]int64{"premier": 1, "première": 1},
+			ordinal:   frenchOrdinal,
+			suffix: func(n int64) string {
+				if n == 1 {
+					return "1er"
+				}
+				return strconv.FormatInt(n, 10) + "e"
+			},
+			minus:      flags("moins"),
+			point:      "virgule",
+			group:      " ",
+			decimal:    ",",
+			percent:    []string{"pour", "cent"},
+			percentSep: " ",
+			currencies: map[string]currency{
+				"euro": {"€", flags("centime", "centimes")}, "euros": {"€", flags("centime", "centimes")},
+				"dollar": {"$", flags("cent", "cents")}, "dollars": {"$", flags("cent", "cents")},
+			},
+			symbolAfter: true,
+			minorConj:   "et",
+			months:      names(monthOrder["fr"]...),
+			date: func(month string, day, year int64) string {
+				s := strconv.FormatInt(day, 10) + " " + month
+				if day == 1 {
+					s = "1er " + month
+				}
+				if year > 0 {
+					s += " " + strconv.FormatInt(year, 10)
+				}
+				return s
+			},
+			datePattern: dayFirst,
+			hours:       flags("heure", "heures"),
+			clock: func(hour, minute int64, _ string) string {
+				s := strconv.FormatInt(hour, 10) + " h"
+				if minute > 0 {
+					s += " " + pad2(minute)
+				}
+				return s
+			},
+		}
+	},
+	"es-ES": func() locale {
+		return locale{
+			words: merge(
+				words(unit, 1, "cero", "uno", "dos", "tres", "cuatro", "cinco", "seis", "siete", "ocho", "nueve"),
+				words(teen, 1, "", "", "", "", "", "", "", "", "", "", "diez", "once", "doce", "trece", "catorce", "quince", "dieciséis", "diecisiete", "dieciocho", "diecinueve",
+					"veinte", "veintiuno", "veintidós", "veintitrés", "veinticuatro", "veinticinco", "veintiséis", "veintisiete", "veintiocho", "veintinueve"),
+				words(tens, 10, "", "", "", "treinta", "cuarenta", "cincuenta", "sesenta", "setenta", "ochenta", "noventa"),
+				words(hundreds, 100, "", "", "doscientos", "trescientos", "cuatrocientos", "quinientos", "seiscientos", "setecientos", "ochocientos", "novecientos"),
+				map[string]numWord{
+					"un":       {unit, 1},
+					"una":      {unit, 1},
+					"veintiún": {teen, 21},
+					"cien":     {hundreds, 100},
+					"ciento":   {hundreds, 100},
+					"mil":      {scale, 1e3},
+					"millón":   {scale, 1e6},
+					"millones": {scale, 1e6},
+					"y":        {conj, 0},
+				},
+			),
+			conjAfter: map[kind]bool{tens: true},
+			bareScale: true,
+			ordinals: map[string]int64{
+				"primero": 1, "primer": 1, "primera": 1, "segundo": 2, "tercero": 3, "tercer": 3,
+				"quinto": 5, "sexto": 6, "séptimo": 7, "octavo": 8, "noveno": 9, "décimo": 10,
+			},
+			suffix:     func(n int64) string { return strconv.FormatInt(n, 10) + ".º" },
+			minus:      flags("menos"),
+			point:      "coma",
+			group:      ".",
+			decimal:    ",",
+			percent:    []string{"por", "ciento"},
+			percentSep: " ",
+			currencies: map[string]currency{
+				"euro": {"€", flags("céntimo", "céntimos")}, "euros": {"€", flags("céntimo", "céntimos")},
+				"dólar": {"$", flags("centavo", "centavos")}, "dólares": {"$", flags("centavo", "centavos")},
+			},
+			symbolAfter: true,
+			minorConj:   "con",
+			months:      names(monthOrder["es"]...),
+			date: func(month string, day, year int64) string {
+				s := strconv.FormatInt(day, 10) + " de " + month
+				if year > 0 {
+					s += " de " + strconv.FormatInt(year, 10)
+				}
+				return s
+			},
+			datePattern: dayOfMonth,
+			dateOf:      "de",
+		}
+	},
+}
+
+// byLanguage is the locale of segments that report only a language.
+var byLanguage = map[string]string{"en": "en-US", "fr": "fr-FR", "es": "es-ES"}
+
+// frenchOrdinal parses "deuxième", "cinquième", "vingt-et-unième" and the
+// like from the cardinal they are built on.
+func frenchOrdinal(l *locale, w string) (int64, bool) {
+	stem, ok := strings.CutSuffix(w, "ième")
+	if !ok || stem == "" {
+		return 0, false
+	}
+	for _, c := range []string{stem, stem + "e", strings.TrimSuffix(stem, "u"), strings.TrimSuffix(stem, "v") + "f"} {
+		if v, ok := l.number(c); ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// Locales returns the names of the locales with rules, sorted.
+func Locales() []string {
+	out := make([]string, 0, len(locales))
+	for name := range locales {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,249 @@
+// Package langid identifies the spoken language of a stream.
+//
+// The Stage inspects the first Window of audio it sees, asks an Identifier
+// which language it is, and reports the answer once. Guesses below the
+// confidence threshold fall back to a default language, since a recognizer
+// forced into the wrong language does worse than one left on its default.
+// The pipeline places the stage after voice activity detection, so the
+// window only counts speech.
+package langid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// ErrUnsupported is returned by identifiers that cannot tell languages apart
+// with the loaded model, such as English-only recognizers.
+var ErrUnsupported = errors.New("langid: language identification not supported")
+
+// Guess is one candidate language.
+type Guess struct {
+	// Language is an ISO 639-1 code.
+	Language string
+	// Confidence in [0, 1].
+	Confidence float32
+}
+
+// Identifier guesses the language of speech.
+type Identifier interface {
+	// Identify returns candidate languages for mono audio, most likely
+	// first.
+	Identify(ctx context.Context, fr audio.Frame) ([]Guess, error)
+}
+
+// Detection reports the language chosen for a stream.
+type Detection struct {
+	// Language is the language the stream is recognized in from now on:
+	// the best guess, or the fallback when that was not confident enough.
+	// It is empty if identification failed without a fallback.
+	Language string
+	// Confidence of the best guess.
+	Confidence float32
+	// Guesses are the candidates, most likely first.
+	Guesses []Guess
+	// Fallback reports that Language is Config.Fallback rather than the
+	// best guess.
+	Fallback bool
+	// Err is set when identification failed.
+	Err error
+	// Offset is the stream time identification was run at.
+	Offset time.Duration
+}
+
+// Config configures the stage. Zero values select the defaults.
+type Config struct {
+	// Identifier, if nil, is the recognizer, when it can identify
+	// languages itself.
+	Identifier Identifier
+	// Window is how much audio is inspected. Defaults to 3s. Streams whose
+	// first utterance ends sooner are identified at its end, given at
+	// least MinSpeech.
+	Window time.Duration
+	// MinSpeech is the least audio identification runs on. Defaults to 1s.
+	MinSpeech time.Duration
+	// Threshold is the confidence below which Fallback is used instead of
+	// the best guess. Defaults to 0.5.
+	Threshold float32
+	// Fallback is the language used for unconfident guesses and failures.
+	// Empty leaves the recognizer on its configured language.
+	Fallback string
+	// Languages, if set, restricts guesses to these languages.
+	Languages []string
+	// OnDetect is called once per stream with the outcome.
+	OnDetect func(Detection)
+}
+
+func (c *Config) setDefaults() error {
+	if c.Window == 0 {
+		c.Window = 3 * time.Second
+	}
+	if c.MinSpeech == 0 {
+		c.MinSpeech = time.Second
+	}
+	if c.Threshold == 0 {
+		c.Threshold = 0.5
+	}
+	switch {
+	case c.Window < 0 || c.MinSpeech < 0:
+		return errors.New("langid: negative duration")
+	case c.MinSpeech > c.Window:
+		return fmt.Errorf("langid: min speech %v exceeds window %v", c.MinSpeech, c.Window)
+	case c.Threshold < 0 || c.Threshold > 1:
+		return fmt.Errorf("langid: threshold %v out of [0, 1]", c.Threshold)
+	}
+	return nil
+}
+
+// Stage buffers the start of a stream and identifies its language in the
+// background. Audio passes through unchanged.
+type Stage struct {
+	ctx   context.Context
+	cfg   Config
+	apply func(Detection) error
+
+	buf    []int16
+	format audio.Format
+	start  time.Duration
+	end    time.Duration
+	done   bool           // identification started
+	pass   [1]audio.Frame // returned by Process, reused
+
+	mu  sync.Mutex
+	det *Detection
+}
+
+// New creates a stage for one stream. apply is called with the detection
+// before OnDetect, so the caller can switch its recognizer first; an error
+// it returns is reported in Detection.Err. Both run on a background
+// goroutine.
+func New(ctx context.Context, cfg Config, apply func(Detection) error) (*Stage, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	if cfg.Identifier == nil {
+		return nil, errors.New("langid: no identifier")
+	}
+	langs := make([]string, len(cfg.Languages))
+	for i, l := range cfg.Languages {
+		langs[i] = strings.ToLower(l)
+	}
+	cfg.Languages = langs
+	return &Stage{ctx: ctx, cfg: cfg, apply: apply}, nil
+}
+
+// Process implements audio.Stage.
+func (s *Stage) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if s.done || fr.Len() == 0 {
+		s.pass[0] = fr
+		return s.pass[:], nil
+	}
+	if s.buf == nil {
+		s.format = audio.Format{SampleRate: fr.Format.SampleRate, Channels: 1}
+		s.start = fr.Offset
+	}
+	s.buf = appendMono(s.buf, fr)
+	s.end = fr.Offset + fr.Duration()
+	if s.format.Duration(len(s.buf)) >= s.cfg.Window {
+		s.identify()
+	}
+	s.pass[0] = fr
+	return s.pass[:], nil
+}
+
+// Cut marks the end of an utterance: identification runs on what has been
+// heard if it is enough.
+func (s *Stage) Cut() {
+	if !s.done && s.format.Duration(len(s.buf)) >= s.cfg.MinSpeech {
+		s.identify()
+	}
+}
+
+// Detection returns the outcome, once identification has finished.
+func (s *Stage) Detection() (Detection, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.det == nil {
+		return Detection{}, false
+	}
+	return *s.det, true
+}
+
+func (s *Stage) identify() {
+	s.done = true
+	fr := audio.Frame{Format: s.format, Data: s.buf, Offset: s.start}
+	at := s.end
+	s.buf = nil
+	go func() {
+		guesses, err := s.cfg.Identifier.Identify(s.ctx, fr)
+		if s.ctx.Err() != nil {
+			return
+		}
+		det := s.decide(guesses, err)
+		det.Offset = at
+		if s.apply != nil && det.Language != "" {
+			if err := s.apply(det); err != nil && det.Err == nil {
+				det.Err = err
+			}
+		}
+		s.mu.Lock()
+		s.det = &det
+		s.mu.Unlock()
+		if s.cfg.OnDetect != nil {
+			s.cfg.OnDetect(det)
+		}
+	}()
+}
+
+// decide picks the language from the guesses.
+func (s *Stage) decide(guesses []Guess, err error) Detection {
+	if len(s.cfg.Languages) > 0 {
+		var kept []Guess
+		var total float32
+		for _, g := range guesses {
+			for _, l := range s.cfg.Languages {
+				if g.Language == l {
+					kept = append(kept, g)
+					total += g.Confidence
+				}
+			}
+		}
+		// Renormalize, so the threshold applies among the candidates.
+		for i := range kept {
+			if total > 0 {
+				kept[i].Confidence /= total
+			}
+		}
+		guesses = kept
+	}
+	det := Detection{Guesses: guesses, Err: err}
+	if len(guesses) > 0 {
+		det.Language, det.Confidence = guesses[0].Language, guesses[0].Confidence
+	}
+	if err != nil || len(guesses) == 0 || det.Confidence < s.cfg.Threshold {
+		det.Language, det.Fallback = s.cfg.Fallback, s.cfg.Fallback != ""
+	}
+	return det
+}
+
+// appendMono appends the samples of fr to buf, averaging channels.
+func appendMono(buf []int16, fr audio.Frame) []int16 {
+	ch := fr.Format.Channels
+	if ch <= 1 {
+		return append(buf, fr.Data...)
+	}
+	for i := 0; i+ch <= len(fr.Data); i += ch {
+		var sum int
+		for _, v := range fr.Data[i : i+ch] {
+			sum += int(v)
+		}
+		buf = append(buf, int16(sum/ch))
+	}
+	return buf
+}
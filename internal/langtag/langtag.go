@@ -0,0 +1,13 @@
+// Package langtag handles the language tags of segments and configs, such as
+// "en-US" or "fr_FR", for the text stages keyed by language.
+package langtag
+
+import "strings"
+
+// Base returns the ISO 639-1 code of a language tag: "en" for "en-US" and
+// "en_us".
+func Base(tag string) string {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	base, _, _ = strings.Cut(base, "_")
+	return base
+}
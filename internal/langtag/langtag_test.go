@@ -0,0 +1,18 @@
+package langtag
+
+import "testing"
+
+func TestBase(t *testing.T) {
+	for _, tc := range []struct{ tag, want string }{
+		{"en", "en"},
+		{"en-US", "en"},
+		{"fr_FR", "fr"},
+		{" ES-mx ", "es"},
+		{"zh-Hant-TW", "zh"},
+		{"", ""},
+	} {
+		if got := Base(tc.tag); got != tc.want {
+			t.Errorf("Base(%q) = %q, want %q", tc.tag, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Package logging defines the logger voxa and its backends write to.
+//
+// Logger is the leveled, structured subset of *slog.Logger, so an slog
+// logger can be passed as is and other logging libraries need only a thin
+// adapter. Arguments are slog-style key-value pairs. Components that
+// receive no logger stay silent.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Logger writes leveled, structured log records. *slog.Logger implements
+// it.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Nop returns a logger that discards everything.
+func Nop() Logger { return nop{} }
+
+type nop struct{}
+
+func (nop) Debug(string, ...any) {}
+func (nop) Info(string, ...any)  {}
+func (nop) Warn(string, ...any)  {}
+func (nop) Error(string, ...any) {}
+
+// OrNop returns l, or a discarding logger if l is nil.
+func OrNop(l Logger) Logger {
+	if l == nil {
+		return nop{}
+	}
+	return l
+}
+
+// With returns a logger that adds args to every record of l, such as the
+// session a stream belongs to. Loggers with a With method of their own,
+// like *slog.Logger, use it. A nil l yields a discarding logger.
+func With(l Logger, args ...any) Logger {
+	switch l := l.(type) {
+	case nil:
+		return nop{}
+	case nop:
+		return l
+	case *slog.Logger:
+		return l.With(args...)
+	case interface{ With(args ...any) Logger }:
+		return l.With(args...)
+	case *with:
+		return &with{l: l.l, args: append(append([]any(nil), l.args...), args...)}
+	}
+	return &with{l: l, args: args}
+}
+
+// with adds fields to a logger that cannot do so itself.
+type with struct {
+	l    Logger
+	args []any
+}
+
+func (w *with) Debug(msg string, args ...any) { w.l.Debug(msg, w.join(args)...) }
+func (w *with) Info(msg string, args ...any)  { w.l.Info(msg, w.join(args)...) }
+func (w *with) Warn(msg string, args ...any)  { w.l.Warn(msg, w.join(args)...) }
+func (w *with) Error(msg string, args ...any) { w.l.Error(msg, w.join(args)...) }
+
+func (w *with) join(args []any) []any {
+	return append(append(make([]any, 0, len(w.args)+len(args)), w.args...), args...)
+}
+
+// New creates an slog logger writing to w at the named level ("debug",
+// "info", "warn" or "error") in the named format ("text" or "json"), as
+// selected by command-line flags.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("logging: bad level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("logging: bad format %q", format)
+}
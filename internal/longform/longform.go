@@ -0,0 +1,387 @@
+// Package longform splits long recordings into overlapping windows that
+// can be transcribed in parallel, and stitches their transcripts back into
+// one.
+//
+// A Splitter reads the source once, running voice activity detection on
+// it, and cuts it close to every Config.Window in the longest pause of the
+// second half of the window, so that cuts fall between sentences. A source
+// without pauses is cut anyway at one and a half windows. Every window owns
+// the audio between two cuts, and carries Config.Overlap of audio either
+// side of it, so the recognizer hears the words at its edges in context.
+//
+// Stitch keeps of every window the segments it owns, by their midpoint,
+// trims the words of segments straddling a cut, and drops the words the
+// next window repeats at the start of its first segment. Cuts depend only
+// on the audio, so the same recording is always cut, and its transcript
+// timed, the same way however the windows are scheduled. It also lets a
+// run interrupted midway resume from the windows it had transcribed, its
+// Checkpoints, as long as it cuts the same windows.
+package longform
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/vad"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Defaults.
+const (
+	DefaultWindow  = 5 * time.Minute
+	DefaultOverlap = 5 * time.Second
+	DefaultWorkers = 4
+	// MinWindow bounds Config.Window.
+	MinWindow = 10 * time.Second
+)
+
+// Config tunes the splitting of a recording.
+type Config struct {
+	// Window is the length windows are cut close to. Defaults to
+	// DefaultWindow.
+	Window time.Duration
+	// Overlap is the audio a window carries past its cuts, at most a
+	// quarter of Window. Defaults to DefaultOverlap.
+	Overlap time.Duration
+	// Workers is the number of windows transcribed in parallel. Defaults
+	// to DefaultWorkers.
+	Workers int
+	// VAD finds the pauses windows are cut in; its OnEvent is not called.
+	VAD vad.Config
+	// Resume holds windows transcribed by an earlier run over the same
+	// audio, which are not transcribed again. A checkpoint whose window is
+	// not cut the same way, as when Window or Overlap changed, is ignored.
+	Resume []Checkpoint
+	// OnCheckpoint, if set, is called with every window once transcribed,
+	// one at a time in the order windows complete.
+	OnCheckpoint func(Checkpoint)
+}
+
+// Checkpoint is a window transcribed.
+type Checkpoint struct {
+	Index int
+	// Start and End delimit the window; see Window.
+	Start, End time.Duration
+	// Segments are the final segments of the window.
+	Segments []stt.Segment
+}
+
+// Resumes reports whether cp is the transcript of w.
+func (cp Checkpoint) Resumes(w Window) bool {
+	return cp.Index == w.Index && cp.Start == w.Start && cp.End == w.End
+}
+
+func (c *Config) setDefaults() error {
+	if c.Window == 0 {
+		c.Window = DefaultWindow
+	}
+	if c.Overlap == 0 {
+		c.Overlap = DefaultOverlap
+	}
+	if c.Workers == 0 {
+		c.Workers = DefaultWorkers
+	}
+	switch {
+	case c.Window < MinWindow:
+		return fmt.Errorf("longform: window %v shorter than %v", c.Window, MinWindow)
+	case c.Overlap < 0 || c.Overlap > c.Window/4:
+		return fmt.Errorf("longform: overlap %v out of [0, %v]", c.Overlap, c.Window/4)
+	case c.Workers < 0:
+		return fmt.Errorf("longform: negative workers %d", c.Workers)
+	}
+	return nil
+}
+
+// Window is a stretch of the source to transcribe.
+type Window struct {
+	// Index numbers the windows of a source from 0.
+	Index int
+	// Start and End delimit the source time the window owns.
+	Start, End time.Duration
+	// Audio is the audio of the window, overlap included, timed on the
+	// source clock. It belongs to the caller.
+	Audio audio.Frame
+}
+
+// gap is a pause found by the VAD.
+type gap struct{ start, end time.Duration }
+
+// Splitter cuts a source into windows.
+type Splitter struct {
+	cfg    Config
+	src    audio.Reader
+	format audio.Format
+	det    *vad.Detector
+	events []vad.Event
+
+	pcm   []int16 // interleaved, from base
+	base  int     // samples per channel before pcm
+	pos   int     // samples per channel read
+	start int     // of the window being gathered
+	cut   int     // its end once found, or -1
+	open  int     // start of the pause under way, or -1
+	gaps  []gap
+	next  int // index of the next window
+	eof   bool
+	tail  bool // the last window has been returned
+}
+
+// NewSplitter returns a splitter reading src.
+func NewSplitter(cfg Config, src audio.Reader) (*Splitter, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	format := src.Format()
+	if format.SampleRate <= 0 || format.Channels <= 0 {
+		return nil, fmt.Errorf("longform: bad format %+v", format)
+	}
+	s := &Splitter{cfg: cfg, src: src, format: format, cut: -1, open: 0}
+	vc := cfg.VAD
+	vc.OnEvent = func(ev vad.Event) { s.events = append(s.events, ev) }
+	det, err := vad.New(vc)
+	if err != nil {
+		return nil, fmt.Errorf("longform: %w", err)
+	}
+	s.det = det
+	return s, nil
+}
+
+// Next returns the next window, or io.EOF once the source has been
+// covered.
+func (s *Splitter) Next() (Window, error) {
+	for {
+		if s.cut >= 0 && (s.eof || s.pos >= s.cut+s.samples(s.cfg.Overlap)) {
+			return s.emit(s.cut), nil
+		}
+		if s.eof {
+			if s.tail {
+				return Window{}, io.EOF
+			}
+			s.tail = true
+			if s.pos > s.start {
+				return s.emit(s.pos), nil
+			}
+			return Window{}, io.EOF
+		}
+		if err := s.read(); err != nil {
+			return Window{}, err
+		}
+		if s.cut < 0 {
+			s.findCut()
+		}
+	}
+}
+
+// read appends the next frame of the source and runs the VAD over it.
+func (s *Splitter) read() error {
+	fr, err := s.src.ReadFrame()
+	if err == io.EOF {
+		s.eof = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fr.Release()
+	if fr.Format != s.format {
+		return fmt.Errorf("longform: frame in %+v from a %+v source", fr.Format, s.format)
+	}
+	s.pcm = append(s.pcm, fr.Data...)
+	fr.Offset = s.format.Duration(s.pos)
+	s.pos += fr.Len()
+	if _, err := s.det.Process(fr); err != nil {
+		return fmt.Errorf("longform: %w", err)
+	}
+	for _, ev := range s.events {
+		at := s.format.Samples(ev.Offset)
+		switch ev.Type {
+		case vad.SpeechStart:
+			if s.open >= 0 && at > s.open {
+				s.gaps = append(s.gaps, gap{s.format.Duration(s.open), ev.Offset})
+			}
+			s.open = -1
+		case vad.SpeechEnd:
+			s.open = at
+		}
+	}
+	s.events = s.events[:0]
+	return nil
+}
+
+// findCut sets the end of the window being gathered once it has reached
+// Window: the middle of the longest pause of its second half, the latest
+// of equal ones, or where it reaches one and a half windows without one.
+func (s *Splitter) findCut() {
+	window := s.samples(s.cfg.Window)
+	if s.pos-s.start < window {
+		return
+	}
+	gaps := s.gaps
+	if s.open >= 0 {
+		gaps = append(gaps[:len(gaps):len(gaps)], gap{s.format.Duration(s.open), s.format.Duration(s.pos)})
+	}
+	best, length := -1, time.Duration(-1)
+	for _, g := range gaps {
+		mid := s.format.Samples((g.start + g.end) / 2)
+		if mid < s.start+window/2 || mid > s.pos {
+			continue
+		}
+		if d := g.end - g.start; d >= length {
+			best, length = mid, d
+		}
+	}
+	if best < 0 && s.pos-s.start >= window+window/2 {
+		best = s.pos
+	}
+	s.cut = best
+}
+
+// emit returns the window ending at end, and starts the next one there.
+func (s *Splitter) emit(end int) Window {
+	ov := s.samples(s.cfg.Overlap)
+	from := max(0, s.start-ov)
+	to := min(s.pos, end+ov)
+	ch := s.format.Channels
+	data := make([]int16, (to-from)*ch)
+	copy(data, s.pcm[(from-s.base)*ch:(to-s.base)*ch])
+	w := Window{
+		Index: s.next,
+		Start: s.format.Duration(s.start),
+		End:   s.format.Duration(end),
+		Audio: audio.Frame{Format: s.format, Data: data, Offset: s.format.Duration(from)},
+	}
+	s.next++
+	s.start, s.cut = end, -1
+	// Keep the overlap of the next window, and the pauses after its start.
+	if keep := max(0, s.start-ov); keep > s.base {
+		s.pcm = s.pcm[:copy(s.pcm, s.pcm[(keep-s.base)*ch:])]
+		s.base = keep
+	}
+	i := 0
+	for i < len(s.gaps) && s.gaps[i].end <= w.End {
+		i++
+	}
+	s.gaps = s.gaps[:copy(s.gaps, s.gaps[i:])]
+	return w
+}
+
+func (s *Splitter) samples(d time.Duration) int { return s.format.Samples(d) }
+
+// Workers returns the number of windows to transcribe in parallel.
+func (s *Splitter) Workers() int { return s.cfg.Workers }
+
+// Stitch merges the final segments of every window, in window order, into
+// the transcript of the source: segs[i] are the final segments of
+// windows[i], as returned by Next. Utterances are numbered from 1.
+func Stitch(windows []Window, segs [][]stt.Segment) []stt.Segment {
+	var out []stt.Segment
+	for i, w := range windows {
+		first := true
+		for _, seg := range segs[i] {
+			seg, ok := own(seg, w, i == len(windows)-1)
+			if !ok {
+				continue
+			}
+			if first && len(out) > 0 {
+				if seg, ok = dedupe(out[len(out)-1], seg); !ok {
+					continue
+				}
+			}
+			first = false
+			out = append(out, seg)
+		}
+	}
+	for i := range out {
+		out[i].UtteranceID = strconv.Itoa(i + 1)
+	}
+	return out
+}
+
+// own returns the part of seg window w owns, reporting whether there is
+// any. The last window owns everything after its start.
+func own(seg stt.Segment, w Window, last bool) (stt.Segment, bool) {
+	in := func(start, end time.Duration) bool {
+		mid := (start + end) / 2
+		return mid >= w.Start && (last || mid < w.End)
+	}
+	if len(seg.Words) == 0 {
+		return seg, in(seg.Start, seg.End)
+	}
+	var words []stt.Word
+	for _, wd := range seg.Words {
+		if in(wd.Start, wd.End) {
+			words = append(words, wd)
+		}
+	}
+	switch len(words) {
+	case 0:
+		return seg, false
+	case len(seg.Words):
+		return seg, true
+	}
+	return withWords(seg, words), true
+}
+
+// dedupe drops from next the words it repeats of prev, the last segment of
+// the window before, when the two overlap in time, reporting whether any
+// word is left.
+func dedupe(prev, next stt.Segment) (stt.Segment, bool) {
+	if next.Start >= prev.End {
+		return next, true
+	}
+	a, b := tokens(prev.Text), tokens(next.Text)
+	n := 0
+	for k := min(len(a), len(b)); k > 0; k-- {
+		if equal(a[len(a)-k:], b[:k]) {
+			n = k
+			break
+		}
+	}
+	switch {
+	case n == 0:
+		return next, true
+	case n == len(b):
+		return next, false
+	case len(next.Words) == len(b):
+		return withWords(next, next.Words[n:]), true
+	}
+	next.Text = strings.Join(strings.Fields(next.Text)[n:], " ")
+	return next, true
+}
+
+// withWords returns seg narrowed to words, its text rebuilt from them.
+func withWords(seg stt.Segment, words []stt.Word) stt.Segment {
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.Text
+	}
+	seg.Words = words
+	seg.Text = strings.Join(texts, " ")
+	seg.Start, seg.End = words[0].Start, words[len(words)-1].End
+	return seg
+}
+
+// tokens splits text into words compared without case or punctuation.
+func tokens(text string) []string {
+	fields := strings.Fields(text)
+	for i, f := range fields {
+		fields[i] = strings.ToLower(strings.TrimFunc(f, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}))
+	}
+	return fields
+}
+
+func equal(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,354 @@
+// Package metrics instruments the pipeline for Prometheus.
+//
+// A Metrics value is a prometheus.Collector: register it with a registry
+// and serve the registry over HTTP. It tracks, for every stage of the audio
+// path, the frames processed and the time spent on them; the time spent
+// on segments by the transcript stages; the latency budget breaches of
+// stages, and the work skipped and streams refused over them; the depth of
+// the queues between stages and to clients, and the items they drop; the
+// latency and count of recognizer results; alerts by rule; barge-ins; and errors by
+// component. All methods are safe on a nil *Metrics, so uninstrumented
+// pipelines need no checks.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// Namespace prefixes every metric name.
+const Namespace = "voxa"
+
+// Metrics holds the pipeline metrics.
+type Metrics struct {
+	frames     *prometheus.CounterVec
+	stageTime  *prometheus.HistogramVec
+	segTime    *prometheus.HistogramVec
+	breaches   *prometheus.CounterVec
+	skipped    *prometheus.CounterVec
+	shed       *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	queue      *prometheus.GaugeVec
+	dropped    *prometheus.CounterVec
+	sttLatency *prometheus.HistogramVec
+	segments   *prometheus.CounterVec
+	alerts     *prometheus.CounterVec
+	bargeIns   prometheus.Counter
+	streams    prometheus.Gauge
+	streamsAll prometheus.Counter
+	deferred   prometheus.Counter
+}
+
+var _ prometheus.Collector = (*Metrics)(nil)
+
+// New creates the metrics. Register the result to export them.
+func New() *Metrics {
+	return &Metrics{
+		frames: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "stage_frames_total",
+			Help:      "Audio frames processed by each pipeline stage.",
+		}, []string{"stage"}),
+		stageTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "stage_duration_seconds",
+			Help:      "Time each pipeline stage spends on one frame.",
+			Buckets:   prometheus.ExponentialBuckets(1e-6, 4, 10), // 1µs to 260ms
+		}, []string{"stage"}),
+		segTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "segment_stage_duration_seconds",
+			Help:      "Time each transcript stage spends on one segment.",
+			Buckets:   prometheus.ExponentialBuckets(1e-5, 4, 10), // 10µs to 2.6s
+		}, []string{"stage"}),
+		breaches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "stage_budget_breaches_total",
+			Help:      "Times the average latency of a stage went over its budget.",
+		}, []string{"stage"}),
+		skipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "stage_skipped_total",
+			Help:      "Frames and segments a stage over its latency budget was skipped for.",
+		}, []string{"stage"}),
+		shed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "streams_shed_total",
+			Help:      "Streams refused while a stage was over its latency budget.",
+		}, []string{"stage"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "errors_total",
+			Help:      "Errors by pipeline component.",
+		}, []string{"component"}),
+		queue: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "queue_depth",
+			Help:      "Items waiting in each queue, summed over streams.",
+		}, []string{"queue"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "queue_dropped_total",
+			Help:      "Items discarded by the overflow policy of a full queue.",
+		}, []string{"queue", "policy"}),
+		sttLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "stt_latency_seconds",
+			Help:      "Time from the audio of a hypothesis reaching the recognizer to the hypothesis.",
+			Buckets:   prometheus.ExponentialBuckets(0.025, 2, 10), // 25ms to 12.8s
+		}, []string{"provider", "result"}),
+		segments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "stt_segments_total",
+			Help:      "Transcript segments produced by the recognizer.",
+		}, []string{"provider", "result"}),
+		alerts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "alerts_total",
+			Help:      "Alerts raised by each alert rule.",
+		}, []string{"rule"}),
+		bargeIns: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "barge_ins_total",
+			Help:      "Replies cut short by the user speaking over them.",
+		}),
+		streams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "streams_active",
+			Help:      "Pipeline streams currently open.",
+		}),
+		streamsAll: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "streams_total",
+			Help:      "Pipeline streams opened.",
+		}),
+		deferred: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "streams_deferred_total",
+			Help:      "Streams whose transcription was deferred while the recognizer was down.",
+		}),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.frames, m.stageTime, m.segTime, m.breaches, m.skipped, m.shed, m.errors, m.queue, m.dropped, m.sttLatency, m.segments, m.alerts, m.bargeIns, m.streams, m.streamsAll, m.deferred,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// Stage instruments an audio stage under name. It returns st itself when m
+// is nil.
+func (m *Metrics) Stage(name string, st audio.Stage) audio.Stage {
+	if m == nil {
+		return st
+	}
+	return &stage{
+		Stage:  st,
+		frames: m.frames.WithLabelValues(name),
+		time:   m.stageTime.WithLabelValues(name),
+		errors: m.errors.WithLabelValues(name),
+	}
+}
+
+type stage struct {
+	audio.Stage
+	frames prometheus.Counter
+	time   prometheus.Observer
+	errors prometheus.Counter
+}
+
+func (s *stage) Process(fr audio.Frame) ([]audio.Frame, error) {
+	start := time.Now()
+	out, err := s.Stage.Process(fr)
+	s.time.Observe(time.Since(start).Seconds())
+	s.frames.Inc()
+	if err != nil {
+		s.errors.Inc()
+	}
+	return out, err
+}
+
+// SegmentStage records that transcript stage took d on one segment.
+func (m *Metrics) SegmentStage(stage string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.segTime.WithLabelValues(stage).Observe(d.Seconds())
+}
+
+// Breach counts stage going over its latency budget.
+func (m *Metrics) Breach(stage string) {
+	if m == nil {
+		return
+	}
+	m.breaches.WithLabelValues(stage).Inc()
+}
+
+// Skipped counts a frame or segment stage was skipped for, over its
+// latency budget.
+func (m *Metrics) Skipped(stage string) {
+	if m == nil {
+		return
+	}
+	m.skipped.WithLabelValues(stage).Inc()
+}
+
+// Shed counts a stream refused while stage was over its latency budget.
+func (m *Metrics) Shed(stage string) {
+	if m == nil {
+		return
+	}
+	m.shed.WithLabelValues(stage).Inc()
+}
+
+// Error counts an error of component.
+func (m *Metrics) Error(component string) {
+	if m == nil {
+		return
+	}
+	m.errors.WithLabelValues(component).Inc()
+}
+
+// Queued adds n, which may be negative, to the depth of queue.
+func (m *Metrics) Queued(queue string, n int) {
+	if m == nil {
+		return
+	}
+	m.queue.WithLabelValues(queue).Add(float64(n))
+}
+
+// Dropped counts an item discarded from queue by its overflow policy.
+func (m *Metrics) Dropped(queue, policy string) {
+	if m == nil {
+		return
+	}
+	m.dropped.WithLabelValues(queue, policy).Inc()
+}
+
+// Segment records a segment from provider, produced latency after its
+// audio reached the recognizer. A negative latency is not observed.
+func (m *Metrics) Segment(provider string, final bool, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	result := "partial"
+	if final {
+		result = "final"
+	}
+	m.segments.WithLabelValues(provider, result).Inc()
+	if latency >= 0 {
+		m.sttLatency.WithLabelValues(provider, result).Observe(latency.Seconds())
+	}
+}
+
+// Alert counts an alert raised by rule.
+func (m *Metrics) Alert(rule string) {
+	if m == nil {
+		return
+	}
+	m.alerts.WithLabelValues(rule).Inc()
+}
+
+// BargeIn counts a reply interrupted by the user speaking.
+func (m *Metrics) BargeIn() {
+	if m == nil {
+		return
+	}
+	m.bargeIns.Inc()
+}
+
+// Deferred counts a stream whose transcription was deferred.
+func (m *Metrics) Deferred() {
+	if m == nil {
+		return
+	}
+	m.deferred.Inc()
+}
+
+// StreamOpened counts a new stream; call the returned function when it
+// ends.
+func (m *Metrics) StreamOpened() (closed func()) {
+	if m == nil {
+		return func() {}
+	}
+	m.streams.Inc()
+	m.streamsAll.Inc()
+	var once sync.Once
+	return func() { once.Do(m.streams.Dec) }
+}
+
+// Clock remembers when the audio written to a recognizer got there, so
+// the latency of its results can be measured. It keeps a bounded history.
+type Clock struct {
+	mu    sync.Mutex
+	marks []mark
+	at    time.Duration // audio written so far
+}
+
+type mark struct {
+	at   time.Duration // recognizer time at the end of a write
+	wall time.Time
+}
+
+// history bounds how far back Latency can look.
+const history = 2 * time.Minute
+
+// Wrote records that d more audio reached the recognizer now.
+func (c *Clock) Wrote(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.at += d
+	c.marks = append(c.marks, mark{at: c.at, wall: time.Now()})
+	if old := c.marks[0]; c.at-old.at > history {
+		i := sort.Search(len(c.marks), func(i int) bool { return c.at-c.marks[i].at <= history })
+		c.marks = append(c.marks[:0], c.marks[i:]...)
+	}
+}
+
+// Latency returns how long ago the audio up to end, in recognizer time,
+// was written; -1 if that is unknown. A zero end measures from the last
+// write.
+func (c *Clock) Latency(end time.Duration) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.marks) == 0 {
+		return -1
+	}
+	i := len(c.marks) - 1
+	if end > 0 {
+		i = sort.Search(len(c.marks), func(i int) bool { return c.marks[i].at >= end })
+		if i == len(c.marks) {
+			i-- // rounding: the hypothesis ends past the audio
+		}
+		if i == 0 && end < c.marks[0].at-history {
+			return -1
+		}
+	}
+	return time.Since(c.marks[i].wall)
+}
+
+// Written returns the audio written so far.
+func (c *Clock) Written() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.at
+}
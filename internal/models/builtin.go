@@ -0,0 +1,75 @@
+package models
+
+import "strings"
+
+// Builtin is the manifest of the models voxa knows of: the ggml models of
+// whisper.cpp, unquantized and quantized, and a few Piper voices,
+// downloaded from Hugging Face. Its entries carry no checksums; the first
+// pull pins them.
+var Builtin = &Manifest{Models: builtin()}
+
+func builtin() []Model {
+	var out []Model
+	for _, name := range []string{
+		"tiny", "tiny.en", "base", "base.en", "small", "small.en",
+		"medium", "medium.en", "large-v3", "large-v3-turbo",
+	} {
+		file := "ggml-" + name + ".bin"
+		desc := "whisper " + name + ", multilingual"
+		if strings.HasSuffix(name, ".en") {
+			desc = "whisper " + strings.TrimSuffix(name, ".en") + ", English only"
+		}
+		out = append(out, Model{
+			Name:        "ggml-" + name,
+			Version:     "1",
+			Backend:     "whisper",
+			Description: desc,
+			Files:       []File{{Name: file, URL: "https://huggingface.co/ggerganov/whisper.cpp/resolve/main/" + file}},
+		})
+	}
+	// The quantized variants whisper.cpp publishes; q5_1 for the small
+	// models, q5_0 for the large ones.
+	for _, v := range []struct{ name, quants string }{
+		{"tiny", "q5_1 q8_0"}, {"tiny.en", "q5_1 q8_0"},
+		{"base", "q5_1 q8_0"}, {"base.en", "q5_1 q8_0"},
+		{"small", "q5_1 q8_0"}, {"small.en", "q5_1 q8_0"},
+		{"medium", "q5_0 q8_0"}, {"medium.en", "q5_0 q8_0"},
+		{"large-v3", "q5_0"}, {"large-v3-turbo", "q5_0 q8_0"},
+	} {
+		for _, q := range strings.Fields(v.quants) {
+			file := "ggml-" + v.name + "-" + q + ".bin"
+			out = append(out, Model{
+				Name:         "ggml-" + v.name + "-" + q,
+				Version:      "1",
+				Backend:      "whisper",
+				Description:  "whisper " + v.name + ", quantized " + q,
+				Base:         "ggml-" + v.name,
+				Quantization: q,
+				Files:        []File{{Name: file, URL: "https://huggingface.co/ggerganov/whisper.cpp/resolve/main/" + file}},
+			})
+		}
+	}
+	for _, voice := range []struct{ lang, locale, speaker, quality string }{
+		{"en", "en_US", "lessac", "medium"},
+		{"en", "en_US", "amy", "medium"},
+		{"en", "en_GB", "alan", "medium"},
+		{"fr", "fr_FR", "siwis", "medium"},
+		{"de", "de_DE", "thorsten", "medium"},
+		{"es", "es_ES", "davefx", "medium"},
+	} {
+		name := voice.locale + "-" + voice.speaker + "-" + voice.quality
+		base := "https://huggingface.co/rhasspy/piper-voices/resolve/v1.0.0/" +
+			voice.lang + "/" + voice.locale + "/" + voice.speaker + "/" + voice.quality + "/" + name
+		out = append(out, Model{
+			Name:        name,
+			Version:     "1.0.0",
+			Backend:     "piper",
+			Description: "piper voice " + voice.speaker + ", " + voice.locale,
+			Files: []File{
+				{Name: name + ".onnx", URL: base + ".onnx"},
+				{Name: name + ".onnx.json", URL: base + ".onnx.json"},
+			},
+		})
+	}
+	return out
+}
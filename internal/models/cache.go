@@ -0,0 +1,95 @@
+package models
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Cache shares the models loaded in a process between their users, so
+// that backends started again on an unchanged model, as voxad does on a
+// reload, reuse the copy already in memory, while a model pulled at a new
+// version, or a file replaced in place, loads afresh next to the old one.
+// A model is unloaded once the last user releasing it is done, the
+// sessions running on it having ended. The zero value is ready to use; a
+// Cache is safe for concurrent use.
+type Cache[K comparable, T any] struct {
+	mu sync.Mutex
+	m  map[cacheKey[K]]*cached[T]
+}
+
+// cacheKey identifies a load: the key of the caller and the file as it
+// was on disk.
+type cacheKey[K comparable] struct {
+	key  K
+	size int64
+	mod  time.Time
+}
+
+type cached[T any] struct {
+	ready  chan struct{} // closed once loaded
+	v      T
+	err    error
+	refs   int
+	unload func(T)
+}
+
+// Acquire returns the model of key, loaded from the file at path by load
+// unless a user holds it already, and a release func to call once done
+// with it. The model is unloaded by unload when the last of its users
+// releases it. Loads of different models run concurrently.
+func (c *Cache[K, T]) Acquire(key K, path string, load func() (T, error), unload func(T)) (T, func(), error) {
+	k := cacheKey[K]{key: key}
+	if fi, err := os.Stat(path); err == nil {
+		k.size, k.mod = fi.Size(), fi.ModTime()
+	}
+	c.mu.Lock()
+	if c.m == nil {
+		c.m = map[cacheKey[K]]*cached[T]{}
+	}
+	e := c.m[k]
+	if e == nil {
+		e = &cached[T]{ready: make(chan struct{}), unload: unload}
+		c.m[k] = e
+		c.mu.Unlock()
+		e.v, e.err = load()
+		c.mu.Lock()
+		if e.err != nil {
+			delete(c.m, k)
+		}
+		close(e.ready)
+	}
+	e.refs++
+	c.mu.Unlock()
+	<-e.ready
+	if e.err != nil {
+		c.mu.Lock()
+		e.refs--
+		c.mu.Unlock()
+		var zero T
+		return zero, nil, e.err
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			c.mu.Lock()
+			e.refs--
+			last := e.refs == 0
+			if last {
+				delete(c.m, k)
+			}
+			c.mu.Unlock()
+			if last {
+				e.unload(e.v)
+			}
+		})
+	}
+	return e.v, release, nil
+}
+
+// Loaded returns how many models c holds.
+func (c *Cache[K, T]) Loaded() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.m)
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.bin")
+	if err := os.WriteFile(path, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var c Cache[string, *int]
+	loads, unloads := 0, 0
+	acquire := func() (*int, func()) {
+		t.Helper()
+		v, release, err := c.Acquire(path, path, func() (*int, error) {
+			loads++
+			n := loads
+			return &n, nil
+		}, func(*int) { unloads++ })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v, release
+	}
+
+	old, releaseOld := acquire()
+	same, releaseSame := acquire()
+	if same != old || loads != 1 {
+		t.Fatalf("unchanged model loaded %d times, want once", loads)
+	}
+	// A pull replacing the file loads it anew next to the old version.
+	if err := os.WriteFile(path, []byte("v2 "), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	cur, releaseCur := acquire()
+	if cur == old || c.Loaded() != 2 {
+		t.Fatalf("replaced model not loaded anew: %d loaded", c.Loaded())
+	}
+	releaseOld()
+	releaseOld()
+	if unloads != 0 {
+		t.Fatal("model unloaded while in use")
+	}
+	releaseSame()
+	if unloads != 1 || c.Loaded() != 1 {
+		t.Fatalf("old model: %d unloads, %d loaded, want it unloaded once", unloads, c.Loaded())
+	}
+	releaseCur()
+	if unloads != 2 || c.Loaded() != 0 {
+		t.Fatalf("%d unloads, %d loaded, want every model unloaded", unloads, c.Loaded())
+	}
+}
@@ -0,0 +1,256 @@
+// Package models manages the model files of the local backends, the ggml
+// models of whisper and the voices of piper, in a directory: it downloads
+// the models of a manifest, verifies them against their checksums, and
+// resolves model names to the files the backends load.
+//
+// A model is installed under Dir/name/version. Pulling one records the
+// version and the SHA-256 of its files in Dir/models.lock, which pins it:
+// lookups by name return the pinned version, and pulling the model again
+// verifies the files against the lock, so a manifest entry without a
+// checksum, as those of the built-in manifest, is trusted on first use
+// like a go.sum line. Downloads resume from the partial file a failed one
+// leaves behind.
+//
+// A quantized variant of a model names it as its Base. Backends given the
+// quantization auto load the installed variant whose kernels suit the CPU
+// best, as the simd package detects it; see Select.
+package models
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// EnvDir is the environment variable overriding DefaultDir.
+const EnvDir = "VOXA_MODELS"
+
+// ErrNotInstalled is returned for models that have not been pulled.
+var ErrNotInstalled = errors.New("models: not installed")
+
+// Model is a model of a manifest, at one version.
+type Model struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Backend is the provider that loads the model: whisper, piper or
+	// onnx.
+	Backend     string `json:"backend"`
+	Description string `json:"description,omitempty"`
+	// Base and Quantization, on a quantized variant of a model, name the
+	// model and the quantization: q8_0 or q5_1 for ggml, int8 for ONNX.
+	// Backends asked for the quantization auto load the variant of Base
+	// that suits the CPU best; see Select.
+	Base         string `json:"base,omitempty"`
+	Quantization string `json:"quantization,omitempty"`
+	// Files are downloaded into the model's directory; the first is the
+	// one the backend is given.
+	Files []File `json:"files"`
+}
+
+// File is a file of a model.
+type File struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// SHA256 is the hex checksum of the file; empty trusts the first
+	// download and pins its checksum.
+	SHA256 string `json:"sha256,omitempty"`
+	// Size, if known, is the length of the file in bytes.
+	Size int64 `json:"size,omitempty"`
+}
+
+// Ref returns name@version.
+func (m Model) Ref() string { return m.Name + "@" + m.Version }
+
+// Manifest lists the models that can be pulled.
+type Manifest struct {
+	Models []Model `json:"models"`
+}
+
+// LoadManifest reads a JSON manifest from path.
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("models: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("models: %s: %w", path, err)
+	}
+	for _, mod := range m.Models {
+		if err := mod.validate(); err != nil {
+			return nil, fmt.Errorf("models: %s: %w", path, err)
+		}
+	}
+	return &m, nil
+}
+
+func (m Model) validate() error {
+	switch {
+	case m.Name == "" || strings.ContainsAny(m.Name, `@/\`):
+		return fmt.Errorf("bad model name %q", m.Name)
+	case m.Version == "" || strings.ContainsAny(m.Version, `@/\`):
+		return fmt.Errorf("%s: bad version %q", m.Name, m.Version)
+	case len(m.Files) == 0:
+		return fmt.Errorf("%s: no files", m.Ref())
+	case (m.Base == "") != (m.Quantization == ""):
+		return fmt.Errorf("%s: base and quantization go together", m.Ref())
+	case m.Quantization == "auto" || m.Quantization == "none":
+		return fmt.Errorf("%s: bad quantization %q", m.Ref(), m.Quantization)
+	}
+	for _, f := range m.Files {
+		if f.Name == "" || f.Name != filepath.Base(f.Name) || f.URL == "" {
+			return fmt.Errorf("%s: bad file %q", m.Ref(), f.Name)
+		}
+		if f.SHA256 != "" && len(f.SHA256) != 64 {
+			return fmt.Errorf("%s: %s: bad sha256 %q", m.Ref(), f.Name, f.SHA256)
+		}
+	}
+	return nil
+}
+
+// Merge returns the models of m, overridden and extended by those of
+// other with the same name and version.
+func (m *Manifest) Merge(other *Manifest) *Manifest {
+	out := &Manifest{Models: slices.Clone(m.Models)}
+	for _, mod := range other.Models {
+		i := slices.IndexFunc(out.Models, func(o Model) bool { return o.Ref() == mod.Ref() })
+		if i < 0 {
+			out.Models = append(out.Models, mod)
+		} else {
+			out.Models[i] = mod
+		}
+	}
+	return out
+}
+
+// Find returns the model of ref, name or name@version. Without a version,
+// it is the last version of the name listed.
+func (m *Manifest) Find(ref string) (Model, error) {
+	name, version, _ := strings.Cut(ref, "@")
+	for _, mod := range slices.Backward(m.Models) {
+		if mod.Name == name && (version == "" || mod.Version == version) {
+			return mod, nil
+		}
+	}
+	return Model{}, fmt.Errorf("models: %q is not in the manifest", ref)
+}
+
+// DefaultDir returns $VOXA_MODELS, or the voxa/models directory of the
+// user's cache directory.
+func DefaultDir() string {
+	if d := os.Getenv(EnvDir); d != "" {
+		return d
+	}
+	d, err := os.UserCacheDir()
+	if err != nil {
+		d = os.TempDir()
+	}
+	return filepath.Join(d, "voxa", "models")
+}
+
+// Lock is the models installed in a directory.
+type Lock struct {
+	Models map[string]Locked `json:"models"` // by name
+}
+
+// Locked is an installed model.
+type Locked struct {
+	Version      string            `json:"version"`
+	Backend      string            `json:"backend"`
+	Base         string            `json:"base,omitempty"`
+	Quantization string            `json:"quantization,omitempty"`
+	Files        []string          `json:"files"`
+	SHA256       map[string]string `json:"sha256"` // by file name
+}
+
+// Store is a directory of models. It is safe for concurrent use within a
+// process.
+type Store struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// Open returns the store of dir, DefaultDir if empty.
+func Open(dir string) *Store {
+	return &Store{Dir: cmp.Or(dir, DefaultDir())}
+}
+
+// LockPath returns the lock file of the store, rewritten by every pull.
+func (s *Store) LockPath() string { return filepath.Join(s.Dir, "models.lock") }
+
+// Lock reads the lock of the store, empty if nothing was pulled.
+func (s *Store) Lock() (*Lock, error) {
+	l := &Lock{Models: map[string]Locked{}}
+	b, err := os.ReadFile(s.LockPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(b, l)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("models: %s: %w", s.LockPath(), err)
+	}
+	if l.Models == nil {
+		l.Models = map[string]Locked{}
+	}
+	return l, nil
+}
+
+// writeLock replaces the lock of the store.
+func (s *Store) writeLock(l *Lock) error {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.LockPath() + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("models: %w", err)
+	}
+	if err := os.Rename(tmp, s.LockPath()); err != nil {
+		return fmt.Errorf("models: %w", err)
+	}
+	return nil
+}
+
+// dir returns the directory of a model version.
+func (s *Store) dir(name, version string) string {
+	return filepath.Join(s.Dir, name, version)
+}
+
+// Path returns the file the backend loads of the installed model name, at
+// its pinned version.
+func (s *Store) Path(name string) (string, error) {
+	l, err := s.Lock()
+	if err != nil {
+		return "", err
+	}
+	m, ok := l.Models[name]
+	if !ok || len(m.Files) == 0 {
+		return "", fmt.Errorf("%w: %s (run voxa models pull %s)", ErrNotInstalled, name, name)
+	}
+	return filepath.Join(s.dir(name, m.Version), m.Files[0]), nil
+}
+
+// Resolve returns the file a backend loads for its model option: model
+// itself if it is a file, else the file of the model of that name
+// installed in the default store. Other paths are returned as they are,
+// for the backend to report.
+func Resolve(model string) string {
+	if model == "" || strings.ContainsAny(model, `/\`) {
+		return model
+	}
+	if _, err := os.Stat(model); err == nil {
+		return model
+	}
+	if p, err := Open("").Path(model); err == nil {
+		return p
+	}
+	return model
+}
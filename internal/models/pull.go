@@ -0,0 +1,200 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Progress is told of the bytes of a file downloaded so far, out of total
+// if known, 0 otherwise.
+type Progress func(file string, done, total int64)
+
+// Pull downloads the files of m that are missing or damaged, verifies
+// them, and pins m as the installed version of its name. A file fails
+// verification if its checksum differs from that of the manifest, or of
+// the lock when m is the version pinned and the manifest has none.
+func (s *Store) Pull(ctx context.Context, m Model, progress Progress) error {
+	if err := m.validate(); err != nil {
+		return fmt.Errorf("models: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lock, err := s.Lock()
+	if err != nil {
+		return err
+	}
+	pinned := lock.Models[m.Name]
+	dir := s.dir(m.Name, m.Version)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("models: %w", err)
+	}
+	entry := Locked{Version: m.Version, Backend: m.Backend, Base: m.Base, Quantization: m.Quantization, SHA256: map[string]string{}}
+	for _, f := range m.Files {
+		want := f.SHA256
+		if locked := pinned.SHA256[f.Name]; pinned.Version == m.Version && locked != "" {
+			if want != "" && want != locked {
+				return fmt.Errorf("models: %s: %s: manifest checksum %s differs from the lock's %s", m.Ref(), f.Name, want, locked)
+			}
+			want = locked
+		}
+		sum, err := fetch(ctx, filepath.Join(dir, f.Name), f, want, progress)
+		if err != nil {
+			return fmt.Errorf("models: %s: %s: %w", m.Ref(), f.Name, err)
+		}
+		entry.Files = append(entry.Files, f.Name)
+		entry.SHA256[f.Name] = sum
+	}
+	lock.Models[m.Name] = entry
+	return s.writeLock(lock)
+}
+
+// Verify checks the files of the installed model name against the lock.
+func (s *Store) Verify(name string) error {
+	lock, err := s.Lock()
+	if err != nil {
+		return err
+	}
+	m, ok := lock.Models[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotInstalled, name)
+	}
+	for _, f := range m.Files {
+		sum, err := sumFile(filepath.Join(s.dir(name, m.Version), f))
+		if err != nil {
+			return fmt.Errorf("models: %s@%s: %w", name, m.Version, err)
+		}
+		if sum != m.SHA256[f] {
+			return fmt.Errorf("models: %s@%s: %s: checksum %s, want %s", name, m.Version, f, sum, m.SHA256[f])
+		}
+	}
+	return nil
+}
+
+// fetch makes path the file f, checksummed want if set, downloading what
+// is missing into path.part, and returns its checksum.
+func fetch(ctx context.Context, path string, f File, want string, progress Progress) (string, error) {
+	if sum, err := sumFile(path); err == nil && (want == "" || sum == want) {
+		return sum, nil
+	}
+	part := path + ".part"
+	out, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	h := sha256.New()
+	have, err := io.Copy(h, out)
+	if err != nil {
+		return "", err
+	}
+	if f.Size > 0 && have > f.Size {
+		// Not a part of this file: start it over.
+		if err := out.Truncate(0); err != nil {
+			return "", err
+		}
+		if _, err := out.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		h.Reset()
+		have = 0
+	}
+	if have, err = download(ctx, out, h, have, f, progress); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if f.Size > 0 && have != f.Size {
+		if have > f.Size {
+			_ = os.Remove(part) // a short one is resumed
+		}
+		return "", fmt.Errorf("downloaded %d bytes, want %d", have, f.Size)
+	}
+	if want != "" && sum != want {
+		_ = os.Remove(part)
+		return "", fmt.Errorf("checksum %s, want %s", sum, want)
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return sum, os.Rename(part, path)
+}
+
+// download appends the bytes of f past have to out and h, and returns the
+// length of the file. A server ignoring the range starts it over.
+func download(ctx context.Context, out *os.File, h hash.Hash, have int64, f File, progress Progress) (int64, error) {
+	if f.Size > 0 && have == f.Size {
+		return have, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return have, err
+	}
+	if have > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(have, 10)+"-")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return have, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && have > 0:
+		return have, nil // the partial file is whole
+	case resp.StatusCode == http.StatusOK:
+		if err := out.Truncate(0); err != nil {
+			return 0, err
+		}
+		if _, err := out.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		h.Reset()
+		have = 0
+	case resp.StatusCode != http.StatusPartialContent:
+		return have, fmt.Errorf("GET %s: %s", f.URL, resp.Status)
+	}
+	total := f.Size
+	if total == 0 && resp.ContentLength >= 0 {
+		total = have + resp.ContentLength
+	}
+	w := io.MultiWriter(out, h)
+	buf := make([]byte, 1<<20)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return have, werr
+			}
+			have += int64(n)
+			if progress != nil {
+				progress(f.Name, have, total)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return have, nil
+		}
+		if err != nil {
+			return have, err
+		}
+	}
+}
+
+func sumFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sha(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPull(t *testing.T) {
+	data := bytes.Repeat([]byte("voxa model weights "), 1000)
+	for _, tc := range []struct {
+		name        string
+		part        []byte // left by an earlier pull
+		ignoreRange bool
+		sha256      string
+		ranges      []string // the Range headers requested
+		err         string
+	}{
+		{name: "fresh", ranges: []string{""}},
+		{name: "resumed", part: data[:100], ranges: []string{"bytes=100-"}},
+		{name: "range ignored", part: data[:100], ignoreRange: true, ranges: []string{"bytes=100-"}},
+		{name: "part whole", part: data, ranges: nil},
+		{name: "oversize part", part: append(slices.Clone(data), "and more"...), ranges: []string{""}},
+		{name: "damaged part", part: []byte("garbage"), ranges: []string{"bytes=7-"}, err: "checksum"},
+		{name: "checksum mismatch", sha256: sha([]byte("other")), ranges: []string{""}, err: "checksum"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				ranges []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				mu.Unlock()
+				if tc.ignoreRange {
+					w.Write(data)
+					return
+				}
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+			}))
+			defer srv.Close()
+			s := Open(t.TempDir())
+			m := Model{Name: "tiny", Version: "1", Backend: "whisper", Files: []File{
+				{Name: "model.bin", URL: srv.URL, SHA256: cmp.Or(tc.sha256, sha(data)), Size: int64(len(data))},
+			}}
+			path := filepath.Join(s.dir(m.Name, m.Version), "model.bin")
+			if tc.part != nil {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path+".part", tc.part, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var done int64
+			err := s.Pull(context.Background(), m, func(_ string, n, total int64) {
+				if total != int64(len(data)) {
+					t.Errorf("progress out of %d, want %d", total, len(data))
+				}
+				done = n
+			})
+			if !slices.Equal(ranges, tc.ranges) {
+				t.Errorf("ranges requested %q, want %q", ranges, tc.ranges)
+			}
+			if _, perr := os.Stat(path + ".part"); !errors.Is(perr, os.ErrNotExist) {
+				t.Errorf("part left behind: %v", perr)
+			}
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Pull: %v, want a %s error", err, tc.err)
+				}
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("file installed despite the error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("file of %d bytes (%v), want the %d downloaded", len(got), err, len(data))
+			}
+			if tc.ranges != nil && done != int64(len(data)) {
+				t.Errorf("progress stopped at %d of %d", done, len(data))
+			}
+			if err := s.Verify(m.Name); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
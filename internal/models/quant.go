@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jmarc101/voxa/internal/simd"
+)
+
+// Quantizations returns the quantizations of the models of backend, best
+// first for the kernels of level; "" is the model itself, unquantized.
+//
+// ggml runs q8_0 fastest where it has integer dot products, and q5 where
+// memory bandwidth rather than arithmetic bounds it. ONNX Runtime runs
+// int8 models faster than float ones on every level.
+func Quantizations(backend string, level simd.Level) []string {
+	switch backend {
+	case "whisper":
+		if level == simd.Generic || level == simd.NEON {
+			return []string{"q5_1", "q5_0", "q8_0", ""}
+		}
+		return []string{"q8_0", "q5_1", "q5_0", ""}
+	case "onnx":
+		return []string{"int8", ""}
+	}
+	return []string{""}
+}
+
+// Variant returns the name of the installed variant of base quantized as
+// quantization, "" being base itself. With "auto", it is the installed
+// variant of base best for the CPU, base if none is.
+func (s *Store) Variant(backend, base, quantization string) (string, error) {
+	l, err := s.Lock()
+	if err != nil {
+		return "", err
+	}
+	installed := func(q string) (string, bool) {
+		if q == "" {
+			_, ok := l.Models[base]
+			return base, ok
+		}
+		for _, name := range slices.Sorted(maps.Keys(l.Models)) {
+			if m := l.Models[name]; m.Base == base && m.Quantization == q {
+				return name, true
+			}
+		}
+		return "", false
+	}
+	if quantization != "auto" {
+		if name, ok := installed(quantization); ok {
+			return name, nil
+		}
+		hint := base
+		if v, ok := Builtin.variant(base, quantization); ok {
+			hint = v.Name
+		}
+		what := base
+		if quantization != "" {
+			what = quantization + " variant of " + base
+		}
+		return "", fmt.Errorf("%w: %s (run voxa models pull %s)", ErrNotInstalled, what, hint)
+	}
+	for _, q := range Quantizations(backend, simd.Best()) {
+		if name, ok := installed(q); ok {
+			return name, nil
+		}
+	}
+	return base, nil
+}
+
+// variant returns the model of m quantizing base as quantization.
+func (m *Manifest) variant(base, quantization string) (Model, bool) {
+	for _, mod := range m.Models {
+		if mod.Base == base && mod.Quantization == quantization {
+			return mod, true
+		}
+	}
+	return Model{}, false
+}
+
+// Select is Resolve for a backend given a quantization option: empty or
+// "none" loads model as named; "auto" the installed variant of model best
+// for the CPU, or model itself; any other the installed variant of model
+// quantized so, failing if there is none. Files are loaded as they are,
+// whatever the quantization.
+func Select(backend, model, quantization string) (string, error) {
+	if quantization == "" || quantization == "none" || model == "" || strings.ContainsAny(model, `/\`) {
+		return Resolve(model), nil
+	}
+	if _, err := os.Stat(model); err == nil {
+		return model, nil
+	}
+	name, err := Open("").Variant(backend, model, quantization)
+	if err != nil {
+		return "", err
+	}
+	return Resolve(name), nil
+}
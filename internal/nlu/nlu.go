@@ -0,0 +1,54 @@
+// Package nlu turns final transcripts into intents.
+//
+// A Parser maps an utterance to an Intent: what the user wants, with the
+// parameters (slots) they gave. Patterns is the built-in rule-based parser;
+// statistical or remote NLU backends plug in by implementing Parser.
+package nlu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Intent is what an utterance asks for.
+type Intent struct {
+	// Name of the matched intent, as declared.
+	Name string
+	// Slots holds the extracted parameters by slot name.
+	Slots map[string]string
+	// Confidence in [0, 1]. Rule-based matches always report 1.
+	Confidence float32
+	// Text is the utterance the intent was parsed from.
+	Text string
+}
+
+// Parser recognizes intents.
+type Parser interface {
+	// Parse returns the intent of text. It reports false if no intent
+	// matches; that is not an error.
+	Parse(ctx context.Context, text string) (Intent, bool, error)
+}
+
+// Definition declares an intent for Patterns.
+type Definition struct {
+	// Name is reported in Intent.Name.
+	Name string `json:"name"`
+	// Patterns are the phrasings that trigger the intent; see Patterns for
+	// the syntax.
+	Patterns []string `json:"patterns"`
+	// Values restricts slots to the listed values, matched ignoring case.
+	// The slot reports the value as listed here, so the list doubles as
+	// canonical spelling.
+	Values map[string][]string `json:"values,omitempty"`
+}
+
+// Load reads intent definitions from a JSON array.
+func Load(r io.Reader) ([]Definition, error) {
+	var defs []Definition
+	if err := json.NewDecoder(r).Decode(&defs); err != nil {
+		return nil, fmt.Errorf("nlu: load intents: %w", err)
+	}
+	return defs, nil
+}
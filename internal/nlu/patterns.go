@@ -0,0 +1,362 @@
+package nlu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Patterns is a rule-based Parser. A pattern is a sequence of words matched
+// against the whole utterance, ignoring case and punctuation:
+//
+//	set a timer for {minutes:number} minutes
+//	[please] (turn | switch) on the {device}
+//	play {song} [by {artist}]
+//
+// [...] is optional and (a | b) is a choice; both nest. {name} is a slot
+// of one or more words, {name:word} a slot of exactly one word and
+// {name:number} a number, written in digits or in English words ("twenty
+// five"), reported in digits. When several patterns match, the one with the
+// most literal words wins, so specific phrasings beat catch-alls; ties go
+// to the earlier definition.
+type Patterns struct {
+	rules []rule
+}
+
+type rule struct {
+	intent string
+	seq    []elem
+	values map[string][]string
+}
+
+// elem is one element of a compiled pattern.
+type elem struct {
+	word string   // literal word
+	alts [][]elem // choice; an optional group is a choice with an empty branch
+	slot string   // slot name
+	kind string   // "text", "word" or "number" for slots
+}
+
+// NewPatterns compiles intent definitions.
+func NewPatterns(defs []Definition) (*Patterns, error) {
+	p := &Patterns{}
+	for _, d := range defs {
+		if d.Name == "" {
+			return nil, errors.New("nlu: intent without a name")
+		}
+		if len(d.Patterns) == 0 {
+			return nil, fmt.Errorf("nlu: intent %s has no patterns", d.Name)
+		}
+		for _, src := range d.Patterns {
+			seq, err := compile(src)
+			if err != nil {
+				return nil, fmt.Errorf("nlu: intent %s: pattern %q: %w", d.Name, src, err)
+			}
+			p.rules = append(p.rules, rule{intent: d.Name, seq: seq, values: d.Values})
+		}
+	}
+	return p, nil
+}
+
+// Parse implements Parser.
+func (p *Patterns) Parse(_ context.Context, text string) (Intent, bool, error) {
+	orig := tokenize(text)
+	toks := make([]string, len(orig))
+	for i, t := range orig {
+		toks[i] = strings.ToLower(t)
+	}
+	var (
+		best      Intent
+		bestScore = -1
+	)
+	for _, r := range p.rules {
+		m := matcher{toks: toks, orig: orig, values: r.values, slots: map[string]string{}}
+		if score, ok := m.match(r.seq, 0, 0, nil); ok && score > bestScore {
+			best = Intent{Name: r.intent, Slots: m.result, Confidence: 1, Text: text}
+			bestScore = score
+		}
+	}
+	return best, bestScore >= 0, nil
+}
+
+// tokenize splits text into words, dropping punctuation. Apostrophes and
+// decimal points inside words are kept.
+func tokenize(text string) []string {
+	rs := []rune(text)
+	var b strings.Builder
+	for i, r := range rs {
+		inner := i > 0 && i+1 < len(rs) && unicode.IsLetter(rs[i-1]) && unicode.IsLetter(rs[i+1])
+		digit := i > 0 && i+1 < len(rs) && unicode.IsDigit(rs[i-1]) && unicode.IsDigit(rs[i+1])
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case (r == '\'' || r == '’') && inner:
+			b.WriteRune('\'')
+		case (r == '.' || r == ',') && digit:
+			if r == '.' {
+				b.WriteRune(r)
+			}
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
+
+// compile parses a pattern.
+func compile(src string) ([]elem, error) {
+	c := &compiler{toks: lexPattern(src)}
+	seq, err := c.seq()
+	if err != nil {
+		return nil, err
+	}
+	if c.pos < len(c.toks) {
+		return nil, fmt.Errorf("unexpected %q", c.toks[c.pos])
+	}
+	return seq, nil
+}
+
+// lexPattern splits a pattern into words and the tokens [ ] ( ) | {slot}.
+func lexPattern(src string) []string {
+	var toks []string
+	for i := 0; i < len(src); {
+		switch ch := src[i]; {
+		case ch == ' ' || ch == '\t':
+			i++
+		case strings.IndexByte("[]()|", ch) >= 0:
+			toks = append(toks, src[i:i+1])
+			i++
+		case ch == '{':
+			j := strings.IndexByte(src[i:], '}')
+			if j < 0 {
+				return append(toks, src[i:])
+			}
+			toks = append(toks, src[i:i+j+1])
+			i += j + 1
+		default:
+			j := i
+			for j < len(src) && strings.IndexByte(" \t[]()|{", src[j]) < 0 {
+				j++
+			}
+			for _, w := range tokenize(src[i:j]) {
+				toks = append(toks, strings.ToLower(w))
+			}
+			i = j
+		}
+	}
+	return toks
+}
+
+type compiler struct {
+	toks []string
+	pos  int
+}
+
+// seq parses elements up to a closing bracket or a "|".
+func (c *compiler) seq() ([]elem, error) {
+	var seq []elem
+	for c.pos < len(c.toks) {
+		tok := c.toks[c.pos]
+		switch {
+		case tok == "]" || tok == ")" || tok == "|":
+			return seq, nil
+		case tok == "[" || tok == "(":
+			c.pos++
+			alts, err := c.alts()
+			if err != nil {
+				return nil, err
+			}
+			want := map[string]string{"[": "]", "(": ")"}[tok]
+			if c.pos >= len(c.toks) || c.toks[c.pos] != want {
+				return nil, fmt.Errorf("missing %q", want)
+			}
+			c.pos++
+			if tok == "[" {
+				alts = append(alts, nil)
+			}
+			seq = append(seq, elem{alts: alts})
+		case strings.HasPrefix(tok, "{"):
+			if !strings.HasSuffix(tok, "}") {
+				return nil, errors.New("unclosed slot")
+			}
+			name, kind, _ := strings.Cut(tok[1:len(tok)-1], ":")
+			name, kind = strings.TrimSpace(name), strings.TrimSpace(kind)
+			if kind == "" {
+				kind = "text"
+			}
+			if name == "" {
+				return nil, errors.New("slot without a name")
+			}
+			if kind != "text" && kind != "word" && kind != "number" {
+				return nil, fmt.Errorf("slot %s: unknown type %q", name, kind)
+			}
+			seq = append(seq, elem{slot: name, kind: kind})
+			c.pos++
+		default:
+			seq = append(seq, elem{word: tok})
+			c.pos++
+		}
+	}
+	return seq, nil
+}
+
+// alts parses "|"-separated sequences.
+func (c *compiler) alts() ([][]elem, error) {
+	var alts [][]elem
+	for {
+		seq, err := c.seq()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, seq)
+		if c.pos >= len(c.toks) || c.toks[c.pos] != "|" {
+			return alts, nil
+		}
+		c.pos++
+	}
+}
+
+// matcher matches one rule against an utterance by backtracking.
+type matcher struct {
+	toks, orig []string
+	values     map[string][]string
+	slots      map[string]string
+	result     map[string]string
+}
+
+// match matches seq at toks[i:], then the continuation rest (the elements
+// following an enclosing group, innermost first). score counts the literal
+// words matched so far.
+func (m *matcher) match(seq []elem, i, score int, rest [][]elem) (int, bool) {
+	if len(seq) == 0 {
+		if len(rest) > 0 {
+			return m.match(rest[0], i, score, rest[1:])
+		}
+		if i != len(m.toks) {
+			return 0, false
+		}
+		m.result = make(map[string]string, len(m.slots))
+		for k, v := range m.slots {
+			m.result[k] = v
+		}
+		return score, true
+	}
+	e, next := seq[0], seq[1:]
+	switch {
+	case e.word != "":
+		if i < len(m.toks) && m.toks[i] == e.word {
+			return m.match(next, i+1, score+1, rest)
+		}
+		return 0, false
+	case e.alts != nil:
+		for _, alt := range e.alts {
+			if s, ok := m.match(alt, i, score, append([][]elem{next}, rest...)); ok {
+				return s, true
+			}
+		}
+		return 0, false
+	}
+	// Slots are lazy: the shortest span that lets the rest match wins.
+	for j := i + 1; j <= len(m.toks); j++ {
+		if e.kind == "word" && j > i+1 {
+			break
+		}
+		v, ok := m.slotValue(e, i, j)
+		if !ok {
+			continue
+		}
+		prev, had := m.slots[e.slot]
+		m.slots[e.slot] = v
+		if s, ok := m.match(next, j, score, rest); ok {
+			return s, true
+		}
+		if had {
+			m.slots[e.slot] = prev
+		} else {
+			delete(m.slots, e.slot)
+		}
+	}
+	return 0, false
+}
+
+// slotValue returns the value of slot e spanning toks[i:j], if it is valid.
+func (m *matcher) slotValue(e elem, i, j int) (string, bool) {
+	if e.kind == "number" {
+		return parseNumber(m.toks[i:j])
+	}
+	if vals, ok := m.values[e.slot]; ok {
+		span := strings.Join(m.toks[i:j], " ")
+		for _, v := range vals {
+			if strings.EqualFold(strings.Join(tokenize(v), " "), span) {
+				return v, true
+			}
+		}
+		return "", false
+	}
+	return strings.Join(m.orig[i:j], " "), true
+}
+
+var (
+	units = map[string]int{
+		"zero": 0, "oh": 0, "a": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+		"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11,
+		"twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15,
+		"sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19,
+	}
+	tens = map[string]int{
+		"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50,
+		"sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	}
+	scales = map[string]int{"hundred": 100, "thousand": 1000, "million": 1000000}
+)
+
+// parseNumber reads a number written in digits ("42", "2.5") or English
+// words ("two hundred and five").
+func parseNumber(toks []string) (string, bool) {
+	if len(toks) == 1 {
+		if _, err := strconv.ParseFloat(toks[0], 64); err == nil {
+			return toks[0], true
+		}
+	}
+	total, cur, words := 0, 0, 0
+	for k, t := range toks {
+		switch n, ok := units[t]; {
+		case ok:
+			// "a" only counts as a number before a scale: "a hundred".
+			if t == "a" && (k+1 >= len(toks) || scales[toks[k+1]] == 0) {
+				return "", false
+			}
+			if (n < 10 && cur%10 != 0) || (n >= 10 && cur%100 != 0) {
+				return "", false // "five six", "twenty twelve"
+			}
+			cur += n
+		case tens[t] > 0:
+			if cur%100 != 0 {
+				return "", false
+			}
+			cur += tens[t]
+		case scales[t] > 0:
+			if cur == 0 {
+				cur = 1
+			}
+			if scales[t] == 100 {
+				cur *= 100
+			} else {
+				total += cur * scales[t]
+				cur = 0
+			}
+		case t == "and" && k > 0 && k+1 < len(toks):
+			continue
+		default:
+			return "", false
+		}
+		words++
+	}
+	if words == 0 {
+		return "", false
+	}
+	return strconv.Itoa(total + cur), true
+}
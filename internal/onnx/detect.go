@@ -0,0 +1,177 @@
+package onnx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/vad"
+	"github.com/jmarc101/voxa/internal/wakeword"
+)
+
+// VAD is a vad.Model whose output, for every window, is the probability
+// that it is speech, as that of Silero VAD. A frame is speech if the last
+// window run by the end of it is.
+type VAD struct {
+	m         *Model
+	threshold float32
+}
+
+var _ vad.Model = (*VAD)(nil)
+
+// NewVAD loads a VAD model, windows defaulting to 512 samples. Threshold
+// is the probability above which a window is speech; it defaults to 0.5.
+func NewVAD(cfg Config, threshold float64) (*VAD, error) {
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("onnx: threshold %v out of range [0, 1]", threshold)
+	}
+	if threshold == 0 {
+		threshold = 0.5
+	}
+	m, err := Load(cfg, 512)
+	if err != nil {
+		return nil, err
+	}
+	return &VAD{m: m, threshold: float32(threshold)}, nil
+}
+
+// Close unloads the model.
+func (v *VAD) Close() error { return v.m.Close() }
+
+// NewClassifier implements vad.Model.
+func (v *VAD) NewClassifier(format audio.Format) (vad.Classifier, error) {
+	feed, err := v.m.NewFeeder(format)
+	if err != nil {
+		return nil, err
+	}
+	return &classifier{v: v, run: v.m.NewRunner(), feed: feed}, nil
+}
+
+type classifier struct {
+	v    *VAD
+	run  *Runner
+	feed *Feeder
+	prob float32
+}
+
+func (c *classifier) Speech(fr audio.Frame) (bool, error) {
+	err := c.feed.Push(fr, func(w []float32) error {
+		out, err := c.run.Run(w)
+		if err != nil {
+			return err
+		}
+		if len(out.Data) == 0 {
+			return fmt.Errorf("onnx: %s: empty output", c.v.m.cfg.Output)
+		}
+		c.prob = out.Data[0]
+		return nil
+	})
+	return c.prob >= c.v.threshold, err
+}
+
+// WakeWord is a wakeword.Model whose output, for every window, holds a
+// score in [0, 1] per wake phrase. A phrase is detected once its score
+// reaches the threshold, and not again until a window has passed.
+type WakeWord struct {
+	m         *Model
+	phrases   []string
+	threshold float32
+}
+
+var _ wakeword.Model = (*WakeWord)(nil)
+
+// NewWakeWord loads a wake word model scoring phrases, in the order of its
+// output. Windows default to a second every 100ms; threshold defaults to
+// 0.5.
+func NewWakeWord(cfg Config, phrases []string, threshold float64) (*WakeWord, error) {
+	if len(phrases) == 0 {
+		return nil, fmt.Errorf("onnx: no wake phrases")
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("onnx: threshold %v out of range [0, 1]", threshold)
+	}
+	if threshold == 0 {
+		threshold = 0.5
+	}
+	rate := cfg.SampleRate
+	if rate == 0 {
+		rate = 16000
+	}
+	if cfg.Window == 0 {
+		cfg.Window = rate
+	}
+	if cfg.Hop == 0 {
+		cfg.Hop = max(1, cfg.Window/10)
+	}
+	m, err := Load(cfg, rate)
+	if err != nil {
+		return nil, err
+	}
+	return &WakeWord{m: m, phrases: phrases, threshold: float32(threshold)}, nil
+}
+
+// Close unloads the model.
+func (w *WakeWord) Close() error { return w.m.Close() }
+
+// NewSpotter implements wakeword.Model.
+func (w *WakeWord) NewSpotter(sampleRate int) (wakeword.Spotter, error) {
+	feed, err := w.m.NewFeeder(audio.Format{SampleRate: sampleRate, Channels: 1})
+	if err != nil {
+		return nil, err
+	}
+	return &spotter{w: w, run: w.m.NewRunner(), feed: feed}, nil
+}
+
+type spotter struct {
+	w     *WakeWord
+	run   *Runner
+	feed  *Feeder
+	muted time.Duration // detections are ignored until this offset
+}
+
+func (s *spotter) Detect(fr audio.Frame) ([]wakeword.Detection, error) {
+	if fr.Format.Channels > 1 {
+		fr = audio.Frame{Format: audio.Format{SampleRate: fr.Format.SampleRate, Channels: 1}, Data: mono(fr), Offset: fr.Offset}
+	}
+	end := fr.Offset + fr.Duration()
+	var dets []wakeword.Detection
+	err := s.feed.Push(fr, func(win []float32) error {
+		out, err := s.run.Run(win)
+		if err != nil {
+			return err
+		}
+		if len(out.Data) < len(s.w.phrases) {
+			return fmt.Errorf("onnx: %s: %d scores for %d phrases", s.w.m.cfg.Output, len(out.Data), len(s.w.phrases))
+		}
+		if end < s.muted {
+			return nil
+		}
+		best := -1
+		for i := range s.w.phrases {
+			if out.Data[i] >= s.w.threshold && (best < 0 || out.Data[i] > out.Data[best]) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			dets = append(dets, wakeword.Detection{Phrase: s.w.phrases[best], Offset: end, Score: float64(out.Data[best])})
+			cfg := s.w.m.cfg
+			s.muted = end + time.Duration(cfg.Window)*time.Second/time.Duration(cfg.SampleRate)
+		}
+		return nil
+	})
+	return dets, err
+}
+
+// mono averages the channels of fr.
+func mono(fr audio.Frame) []int16 {
+	ch := fr.Format.Channels
+	out := make([]int16, fr.Len())
+	for i := range out {
+		var sum int
+		for c := range ch {
+			sum += int(fr.Data[i*ch+c])
+		}
+		out[i] = int16(sum / ch)
+	}
+	return out
+}
@@ -0,0 +1,358 @@
+// Package onnx runs ONNX models through ONNX Runtime
+// (https://onnxruntime.ai), so that voice activity detection, wake words
+// and small speech recognizers can use models voxa does not wrap one by
+// one: a Config maps the model's tensors, and the adapters of this package
+// and of stt/onnx turn its outputs into speech probabilities, wake word
+// scores and transcripts.
+//
+// Audio is fed to the input tensor as float32 mono samples in [-1, 1],
+// resampled to the model's rate, shaped [1, samples], or with
+// FeaturesMFCC as MFCC frames shaped [1, frames, coefficients]. Recurrent
+// state tensors, such as those of Silero VAD, are fed back from one run to
+// the next, and constant inputs such as a sample rate are passed as int64
+// scalars.
+//
+// ONNX Runtime is bound through cgo. Build with `-tags onnxruntime` and the
+// library installed where pkg-config finds it (libonnxruntime.pc); without
+// the tag the backends are still available but fail to load with
+// ErrNoRuntime.
+package onnx
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/dsp"
+	"github.com/jmarc101/voxa/internal/models"
+)
+
+// ErrNoRuntime is returned when voxa was built without ONNX Runtime.
+var ErrNoRuntime = errors.New("onnx: built without ONNX Runtime (rebuild with -tags onnxruntime)")
+
+// Features is what the input tensor is fed.
+type Features int
+
+const (
+	// FeaturesPCM feeds the samples themselves.
+	FeaturesPCM Features = iota
+	// FeaturesMFCC feeds the 12 MFCCs of every 10ms of the window.
+	FeaturesMFCC
+)
+
+// ParseFeatures parses pcm or mfcc; empty is pcm.
+func ParseFeatures(s string) (Features, error) {
+	switch s {
+	case "", "pcm":
+		return FeaturesPCM, nil
+	case "mfcc":
+		return FeaturesMFCC, nil
+	}
+	return 0, fmt.Errorf("onnx: unknown features %q (want pcm or mfcc)", s)
+}
+
+// State is a recurrent tensor, zero at the start of a stream and fed back
+// from Output to Input after every run.
+type State struct {
+	Input, Output string
+	Shape         []int64
+}
+
+// Config maps the tensors of a model.
+type Config struct {
+	// Model is the .onnx file, or the name of a model installed with voxa
+	// models pull.
+	Model string
+	// Quantization selects the installed variant of Model to load, such as
+	// int8, or auto for the one that suits the CPU best; see
+	// models.Select. Empty loads Model as named.
+	Quantization string
+	// Input names the tensor audio is fed to. Defaults to "input".
+	Input string
+	// Output names the tensor read. Defaults to "output".
+	Output string
+	// SampleRate is the rate the model takes audio at. Defaults to 16000.
+	SampleRate int
+	// Window is the samples fed per run, at SampleRate; recognizers run
+	// on whole utterances and ignore it. Defaults to the adapter's.
+	Window int
+	// Hop is the samples between the starts of two runs. Defaults to
+	// Window; less overlaps them, as wake word models need.
+	Hop      int
+	Features Features
+	States   []State
+	// Constants are int64 scalar inputs, such as {"sr": 16000}.
+	Constants map[string]int64
+	// Threads bounds the threads of a run. Defaults to 1.
+	Threads int
+}
+
+// ParseOptions reads a Config from provider options: "model",
+// "quantization", "input", "output", "sample_rate", "window", "hop", "features", "threads",
+// "states" as input:output:AxBxC entries separated by commas, and
+// "constants" as name=value entries separated by commas.
+func ParseOptions(opts map[string]string) (Config, error) {
+	cfg := Config{
+		Model:        opts["model"],
+		Quantization: opts["quantization"],
+		Input:        opts["input"],
+		Output:       opts["output"],
+	}
+	for name, dst := range map[string]*int{
+		"sample_rate": &cfg.SampleRate, "window": &cfg.Window, "hop": &cfg.Hop, "threads": &cfg.Threads,
+	} {
+		if v := opts[name]; v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return cfg, fmt.Errorf("bad %s %q", name, v)
+			}
+			*dst = n
+		}
+	}
+	var err error
+	if cfg.Features, err = ParseFeatures(opts["features"]); err != nil {
+		return cfg, err
+	}
+	if v := opts["states"]; v != "" {
+		for _, e := range strings.Split(v, ",") {
+			parts := strings.Split(strings.TrimSpace(e), ":")
+			if len(parts) != 3 {
+				return cfg, fmt.Errorf("bad state %q, want input:output:shape", e)
+			}
+			st := State{Input: parts[0], Output: parts[1]}
+			for _, d := range strings.Split(parts[2], "x") {
+				n, err := strconv.ParseInt(d, 10, 64)
+				if err != nil || n <= 0 {
+					return cfg, fmt.Errorf("bad state shape %q", parts[2])
+				}
+				st.Shape = append(st.Shape, n)
+			}
+			cfg.States = append(cfg.States, st)
+		}
+	}
+	if v := opts["constants"]; v != "" {
+		cfg.Constants = map[string]int64{}
+		for _, e := range strings.Split(v, ",") {
+			name, val, ok := strings.Cut(strings.TrimSpace(e), "=")
+			n, err := strconv.ParseInt(val, 10, 64)
+			if !ok || err != nil {
+				return cfg, fmt.Errorf("bad constant %q, want name=value", e)
+			}
+			cfg.Constants[name] = n
+		}
+	}
+	return cfg, nil
+}
+
+// withDefaults fills in the defaults of c, window being the adapter's.
+func (c Config) withDefaults(window int) Config {
+	if c.Input == "" {
+		c.Input = "input"
+	}
+	if c.Output == "" {
+		c.Output = "output"
+	}
+	if c.SampleRate == 0 {
+		c.SampleRate = 16000
+	}
+	if c.Window == 0 {
+		c.Window = window
+	}
+	if c.Hop == 0 {
+		c.Hop = c.Window
+	}
+	if c.Threads == 0 {
+		c.Threads = 1
+	}
+	return c
+}
+
+// Validate checks the mappings of c.
+func (c Config) Validate() error {
+	switch {
+	case c.Model == "":
+		return errors.New("onnx: model is required")
+	case c.SampleRate < 0 || c.Window < 0 || c.Hop < 0 || c.Threads < 0:
+		return errors.New("onnx: negative size")
+	case c.Hop > c.Window && c.Window > 0:
+		return fmt.Errorf("onnx: hop %d above window %d", c.Hop, c.Window)
+	}
+	for _, st := range c.States {
+		if st.Input == "" || st.Output == "" || len(st.Shape) == 0 {
+			return fmt.Errorf("onnx: bad state %q", st.Input)
+		}
+	}
+	return nil
+}
+
+// Tensor is a float32 tensor.
+type Tensor struct {
+	Shape []int64
+	Data  []float32
+}
+
+// Model is a loaded model, run by every stream using it. It is safe for
+// concurrent use.
+type Model struct {
+	cfg     Config
+	sess    session
+	release func() // of sess, in sessions
+}
+
+// session is a model loaded in the runtime. run is safe for concurrent
+// use.
+type session interface {
+	run(floats map[string]Tensor, ints map[string]int64, outputs []string) ([]Tensor, error)
+	close()
+}
+
+// Load loads the model of cfg, window being the samples per run of the
+// adapter unless cfg sets them.
+func Load(cfg Config, window int) (*Model, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg = cfg.withDefaults(window)
+	model, err := models.Select("onnx", cfg.Model, cfg.Quantization)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Model = model
+	key := sessionKey{cfg.Model, cfg.Threads}
+	sess, release, err := sessions.Acquire(key, cfg.Model, func() (session, error) {
+		return openSession(key.path, key.threads)
+	}, session.close)
+	if err != nil {
+		return nil, err
+	}
+	return &Model{cfg: cfg, sess: sess, release: release}, nil
+}
+
+// sessions holds the models loaded in the process, shared by the Models
+// loading the same file on as many threads.
+var sessions models.Cache[sessionKey, session]
+
+type sessionKey struct {
+	path    string
+	threads int
+}
+
+// Config returns the mappings of m, with the defaults filled in.
+func (m *Model) Config() Config { return m.cfg }
+
+// Close unloads the model once no other Model of the process uses it.
+func (m *Model) Close() error {
+	m.release()
+	return nil
+}
+
+// Runner runs a model on one stream, carrying its states from one run to
+// the next. It is not safe for concurrent use.
+type Runner struct {
+	m       *Model
+	states  map[string]Tensor // by input name
+	outputs []string
+	buf     []float64
+}
+
+// NewRunner returns a runner with zero states.
+func (m *Model) NewRunner() *Runner {
+	r := &Runner{m: m, outputs: []string{m.cfg.Output}}
+	r.Reset()
+	for _, st := range m.cfg.States {
+		r.outputs = append(r.outputs, st.Output)
+	}
+	return r
+}
+
+// Reset zeroes the states, as at the start of a stream.
+func (r *Runner) Reset() {
+	r.states = make(map[string]Tensor, len(r.m.cfg.States))
+	for _, st := range r.m.cfg.States {
+		n := int64(1)
+		for _, d := range st.Shape {
+			n *= d
+		}
+		r.states[st.Input] = Tensor{Shape: st.Shape, Data: make([]float32, n)}
+	}
+}
+
+// Run feeds samples, at the model's rate, and returns the output tensor.
+func (r *Runner) Run(samples []float32) (Tensor, error) {
+	cfg := r.m.cfg
+	in := map[string]Tensor{cfg.Input: {Shape: []int64{1, int64(len(samples))}, Data: samples}}
+	if cfg.Features == FeaturesMFCC {
+		r.buf = r.buf[:0]
+		for _, s := range samples {
+			r.buf = append(r.buf, float64(s))
+		}
+		mfcc := dsp.NewMFCC(dsp.MFCCConfig{SampleRate: cfg.SampleRate}) // windows overlap
+		frames := mfcc.Push(r.buf)
+		t := Tensor{Shape: []int64{1, int64(len(frames)), int64(mfcc.Dim())}}
+		for _, f := range frames {
+			for _, v := range f {
+				t.Data = append(t.Data, float32(v))
+			}
+		}
+		in[cfg.Input] = t
+	}
+	for name, t := range r.states {
+		in[name] = t
+	}
+	out, err := r.m.sess.run(in, cfg.Constants, r.outputs)
+	if err != nil {
+		return Tensor{}, fmt.Errorf("onnx: %w", err)
+	}
+	for i, st := range cfg.States {
+		r.states[st.Input] = out[i+1]
+	}
+	return out[0], nil
+}
+
+// Feeder cuts audio into the windows a model runs on: mono, at its rate,
+// Window samples every Hop. It is not safe for concurrent use.
+type Feeder struct {
+	conv        *audio.Converter // nil if the audio is in the model's format
+	window, hop int
+	buf         []float32
+}
+
+// NewFeeder returns a feeder of audio in from.
+func (m *Model) NewFeeder(from audio.Format) (*Feeder, error) {
+	f := &Feeder{window: m.cfg.Window, hop: m.cfg.Hop}
+	to := audio.Format{SampleRate: m.cfg.SampleRate, Channels: 1}
+	if from != to {
+		var err error
+		if f.conv, err = audio.NewConverter(from, to, audio.QualityMedium); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Push adds fr and calls run with every window it completes, in order.
+// The window is only valid during the call.
+func (f *Feeder) Push(fr audio.Frame, run func(window []float32) error) error {
+	frames := []audio.Frame{fr}
+	if f.conv != nil {
+		var err error
+		if frames, err = f.conv.Process(fr); err != nil {
+			return err
+		}
+	}
+	for _, c := range frames {
+		for _, s := range c.Data {
+			f.buf = append(f.buf, float32(s)/32768)
+		}
+	}
+	for len(f.buf) >= f.window {
+		if err := run(f.buf[:f.window]); err != nil {
+			return err
+		}
+		f.buf = slices.Delete(f.buf, 0, f.hop)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+//go:build cgo && onnxruntime
+
+package onnx
+
+/*
+#cgo pkg-config: libonnxruntime
+#include <stdlib.h>
+#include <string.h>
+#include <onnxruntime_c_api.h>
+
+// The C API is a table of function pointers, which cgo cannot call; these
+// helpers call them, turning an OrtStatus into a malloc'ed message.
+
+static const OrtApi *voxa_api(void) { return OrtGetApiBase()->GetApi(ORT_API_VERSION); }
+
+static char *voxa_err(OrtStatus *st) {
+	if (st == NULL) return NULL;
+	char *msg = strdup(voxa_api()->GetErrorMessage(st));
+	voxa_api()->ReleaseStatus(st);
+	return msg;
+}
+
+static OrtEnv *voxa_env;
+
+static char *voxa_open(const char *path, int threads, OrtSession **out) {
+	const OrtApi *a = voxa_api();
+	char *err;
+	if (voxa_env == NULL && (err = voxa_err(a->CreateEnv(ORT_LOGGING_LEVEL_WARNING, "voxa", &voxa_env))) != NULL) return err;
+	OrtSessionOptions *opts;
+	if ((err = voxa_err(a->CreateSessionOptions(&opts))) != NULL) return err;
+	err = voxa_err(a->SetIntraOpNumThreads(opts, threads));
+	if (err == NULL) err = voxa_err(a->CreateSession(voxa_env, path, opts, out));
+	a->ReleaseSessionOptions(opts);
+	return err;
+}
+
+static char *voxa_tensor(void *data, size_t bytes, const int64_t *shape, size_t rank, ONNXTensorElementDataType type, OrtValue **out) {
+	const OrtApi *a = voxa_api();
+	OrtMemoryInfo *mem;
+	char *err = voxa_err(a->CreateCpuMemoryInfo(OrtArenaAllocator, OrtMemTypeDefault, &mem));
+	if (err != NULL) return err;
+	err = voxa_err(a->CreateTensorWithDataAsOrtValue(mem, data, bytes, shape, rank, type, out));
+	a->ReleaseMemoryInfo(mem);
+	return err;
+}
+
+static char *voxa_run(OrtSession *s, const char **in_names, OrtValue **in, size_t n_in, const char **out_names, size_t n_out, OrtValue **out) {
+	return voxa_err(voxa_api()->Run(s, NULL, in_names, (const OrtValue *const *)in, n_in, out_names, n_out, out));
+}
+
+// voxa_output describes a float output: its rank, up to max dimensions,
+// and its data.
+static char *voxa_output(OrtValue *v, int64_t *dims, size_t max, size_t *rank, float **data) {
+	const OrtApi *a = voxa_api();
+	OrtTensorTypeAndShapeInfo *info;
+	char *err = voxa_err(a->GetTensorTypeAndShape(v, &info));
+	if (err != NULL) return err;
+	ONNXTensorElementDataType type;
+	err = voxa_err(a->GetTensorElementType(info, &type));
+	if (err == NULL && type != ONNX_TENSOR_ELEMENT_DATA_TYPE_FLOAT) err = strdup("output is not a float tensor");
+	if (err == NULL) err = voxa_err(a->GetDimensionsCount(info, rank));
+	if (err == NULL && *rank > max) err = strdup("output has too many dimensions");
+	if (err == NULL) err = voxa_err(a->GetDimensions(info, dims, *rank));
+	a->ReleaseTensorTypeAndShapeInfo(info);
+	if (err == NULL) err = voxa_err(a->GetTensorMutableData(v, (void **)data));
+	return err;
+}
+
+static void voxa_release_value(OrtValue *v) { if (v != NULL) voxa_api()->ReleaseValue(v); }
+static void voxa_release_session(OrtSession *s) { voxa_api()->ReleaseSession(s); }
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+// maxRank bounds the dimensions of the outputs read.
+const maxRank = 8
+
+type libSession struct {
+	s *C.OrtSession
+}
+
+// openMu guards the creation of the runtime's environment.
+var openMu sync.Mutex
+
+func openSession(path string, threads int) (session, error) {
+	openMu.Lock()
+	defer openMu.Unlock()
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	var s *C.OrtSession
+	if err := status(C.voxa_open(cpath, C.int(threads), &s)); err != nil {
+		return nil, err
+	}
+	return &libSession{s: s}, nil
+}
+
+// status turns a message from the helpers into an error, freeing it.
+func status(msg *C.char) error {
+	if msg == nil {
+		return nil
+	}
+	defer C.free(unsafe.Pointer(msg))
+	return errors.New(C.GoString(msg))
+}
+
+func (l *libSession) run(floats map[string]Tensor, ints map[string]int64, outputs []string) ([]Tensor, error) {
+	// The runtime reads inputs during Run, so they live in C memory,
+	// freed with the names once it returns.
+	var allocs []unsafe.Pointer
+	alloc := func(n int) unsafe.Pointer {
+		p := C.malloc(C.size_t(max(n, 1)))
+		allocs = append(allocs, p)
+		return p
+	}
+	defer func() {
+		for _, p := range allocs {
+			C.free(p)
+		}
+	}()
+	n := len(floats) + len(ints)
+	names := unsafe.Slice((**C.char)(alloc(n*int(unsafe.Sizeof((*C.char)(nil))))), n)
+	values := unsafe.Slice((**C.OrtValue)(alloc(n*int(unsafe.Sizeof((*C.OrtValue)(nil))))), n)
+	clear(values)
+	defer func() {
+		for _, v := range values {
+			C.voxa_release_value(v)
+		}
+	}()
+	i := 0
+	add := func(name string, data unsafe.Pointer, bytes int, shape []int64, typ C.ONNXTensorElementDataType) error {
+		names[i] = C.CString(name)
+		allocs = append(allocs, unsafe.Pointer(names[i]))
+		rank := len(shape)
+		if rank > maxRank {
+			return errors.New(name + ": too many dimensions")
+		}
+		dims := (*[maxRank]C.int64_t)(alloc(maxRank * 8))
+		for j, d := range shape {
+			dims[j] = C.int64_t(d)
+		}
+		err := status(C.voxa_tensor(data, C.size_t(bytes), &dims[0], C.size_t(rank), typ, &values[i]))
+		i++
+		return err
+	}
+	for name, t := range floats {
+		data := alloc(4 * len(t.Data))
+		copy(unsafe.Slice((*float32)(data), len(t.Data)), t.Data)
+		if err := add(name, data, 4*len(t.Data), t.Shape, C.ONNX_TENSOR_ELEMENT_DATA_TYPE_FLOAT); err != nil {
+			return nil, err
+		}
+	}
+	for name, v := range ints {
+		data := alloc(8)
+		*(*int64)(data) = v
+		if err := add(name, data, 8, nil, C.ONNX_TENSOR_ELEMENT_DATA_TYPE_INT64); err != nil {
+			return nil, err
+		}
+	}
+
+	m := len(outputs)
+	outNames := unsafe.Slice((**C.char)(alloc(m*int(unsafe.Sizeof((*C.char)(nil))))), m)
+	for j, name := range outputs {
+		outNames[j] = C.CString(name)
+		allocs = append(allocs, unsafe.Pointer(outNames[j]))
+	}
+	outs := unsafe.Slice((**C.OrtValue)(alloc(m*int(unsafe.Sizeof((*C.OrtValue)(nil))))), m)
+	clear(outs)
+	defer func() {
+		for _, v := range outs {
+			C.voxa_release_value(v)
+		}
+	}()
+	if err := status(C.voxa_run(l.s, &names[0], &values[0], C.size_t(n), &outNames[0], C.size_t(m), &outs[0])); err != nil {
+		return nil, err
+	}
+
+	result := make([]Tensor, m)
+	for j, v := range outs {
+		var (
+			dims [maxRank]C.int64_t
+			rank C.size_t
+			data *C.float
+		)
+		if err := status(C.voxa_output(v, &dims[0], maxRank, &rank, &data)); err != nil {
+			return nil, errors.New(outputs[j] + ": " + err.Error())
+		}
+		t := Tensor{Shape: make([]int64, rank)}
+		size := 1
+		for k := range t.Shape {
+			t.Shape[k] = int64(dims[k])
+			size *= int(dims[k])
+		}
+		t.Data = append([]float32(nil), unsafe.Slice((*float32)(unsafe.Pointer(data)), size)...)
+		result[j] = t
+	}
+	return result, nil
+}
+
+func (l *libSession) close() { C.voxa_release_session(l.s) }
@@ -0,0 +1,5 @@
+//go:build !(cgo && onnxruntime)
+
+package onnx
+
+func openSession(string, int) (session, error) { return nil, ErrNoRuntime }
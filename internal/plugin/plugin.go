@@ -0,0 +1,156 @@
+// Package plugin lets deployments add their own processing to the pipeline
+// without forking it: audio stages, which see the audio on its way to the
+// recognizer, and transcript stages, which rewrite segments before anything
+// downstream sees them.
+//
+// Stages are registered by name, like STT and TTS providers, and selected
+// from configuration. They can be compiled in, loaded from a Go plugin with
+// Open, run as an external process by the "exec" transcript stage (see
+// package plugin/process), or run sandboxed in a WebAssembly module by the
+// "wasm" transcript stage (see package plugin/wasm).
+package plugin
+
+import (
+	"context"
+	"fmt"
+	goplugin "plugin"
+	"sort"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Config selects a registered stage and passes it options.
+type Config struct {
+	// Name is the name the stage was registered under.
+	Name string
+	// Options are stage-specific settings.
+	Options map[string]string
+	// Logger receives the stage's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Option returns the named option or def when unset.
+func (c Config) Option(name, def string) string {
+	if v, ok := c.Options[name]; ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// Audio is an audio processing plugin. It is instantiated once per
+// pipeline and creates a stage for every stream, since stages keep state.
+// Plugins that hold resources implement io.Closer.
+type Audio interface {
+	// NewStage returns the stage of a stream whose audio reaches it in
+	// format, mono at the recognizer's rate.
+	NewStage(format audio.Format) (audio.Stage, error)
+}
+
+// Transcript is a transcript processing plugin. Process is called for every
+// segment of every stream, partial and final, and may rewrite it in place;
+// an error ends the stream. It must be safe for concurrent use. Plugins
+// that hold resources implement io.Closer.
+type Transcript interface {
+	Process(ctx context.Context, seg *stt.Segment) error
+}
+
+// AudioFactory builds an audio plugin from its configuration.
+type AudioFactory func(cfg Config) (Audio, error)
+
+// TranscriptFactory builds a transcript plugin from its configuration.
+type TranscriptFactory func(cfg Config) (Transcript, error)
+
+var (
+	registryMu  sync.RWMutex
+	audios      = make(map[string]AudioFactory)
+	transcripts = make(map[string]TranscriptFactory)
+)
+
+// RegisterAudio makes an audio plugin available under name. It is meant to
+// be called from the plugin's init function and panics if name is already
+// taken or factory is nil.
+func RegisterAudio(name string, factory AudioFactory) {
+	register(audios, "RegisterAudio", name, factory)
+}
+
+// RegisterTranscript makes a transcript plugin available under name. It is
+// meant to be called from the plugin's init function and panics if name is
+// already taken or factory is nil.
+func RegisterTranscript(name string, factory TranscriptFactory) {
+	register(transcripts, "RegisterTranscript", name, factory)
+}
+
+func register[F AudioFactory | TranscriptFactory](m map[string]F, fn, name string, factory F) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("plugin: " + fn + " factory is nil")
+	}
+	if _, dup := m[name]; dup {
+		panic("plugin: " + fn + " called twice for " + name)
+	}
+	m[name] = factory
+}
+
+// NewAudio instantiates the audio plugin selected by cfg.Name.
+func NewAudio(cfg Config) (Audio, error) {
+	registryMu.RLock()
+	factory, ok := audios[cfg.Name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("plugin: unknown audio stage %q (registered: %v)", cfg.Name, AudioNames())
+	}
+	p, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("plugin: %s: %w", cfg.Name, err)
+	}
+	return p, nil
+}
+
+// NewTranscript instantiates the transcript plugin selected by cfg.Name.
+func NewTranscript(cfg Config) (Transcript, error) {
+	registryMu.RLock()
+	factory, ok := transcripts[cfg.Name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("plugin: unknown transcript stage %q (registered: %v)", cfg.Name, TranscriptNames())
+	}
+	p, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("plugin: %s: %w", cfg.Name, err)
+	}
+	return p, nil
+}
+
+// AudioNames returns the sorted names of the registered audio plugins.
+func AudioNames() []string { return names(audios) }
+
+// TranscriptNames returns the sorted names of the registered transcript
+// plugins.
+func TranscriptNames() []string { return names(transcripts) }
+
+func names[F any](m map[string]F) []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]string, 0, len(m))
+	for name := range m {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Open loads the Go plugin at path, whose init functions register its
+// stages through voxa.RegisterAudioPlugin and voxa.RegisterTranscriptPlugin.
+// The plugin must be built with -buildmode=plugin by the same toolchain
+// and against the same voxa version as the program loading it. Opening a
+// plugin twice is harmless.
+func Open(path string) error {
+	if _, err := goplugin.Open(path); err != nil {
+		return fmt.Errorf("plugin: %w", err)
+	}
+	return nil
+}
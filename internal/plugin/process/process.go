@@ -0,0 +1,211 @@
+// Package process runs transcript plugins as external processes, so they
+// can be written in any language, keep their code out of the voxa binary
+// and crash without taking the pipeline down with them.
+//
+// The process is started once per pipeline. It reads one JSON request per
+// line on stdin and writes one JSON reply per line on stdout, in any order,
+// matching replies to requests by id:
+//
+//	→ {"id":7,"segment":{"utterance_id":"u1","revision":2,"text":"call me at five","final":true,...}}
+//	← {"id":7,"segment":{"text":"call me at 5","words":[...]}}
+//	← {"id":7,"error":"dictionary unavailable"}
+//
+// Requests and replies follow the plugin.Request and plugin.Reply schema: a
+// reply replaces the text of the segment and, if it lists as many words,
+// the text of each word, and an error reply fails the segment. Whatever the
+// process writes to stderr is logged.
+package process
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/plugin"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Name is the name the plugin registers under.
+const Name = "exec"
+
+func init() {
+	plugin.RegisterTranscript(Name, func(cfg plugin.Config) (plugin.Transcript, error) {
+		timeout, err := time.ParseDuration(cfg.Option("timeout", "2s"))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("bad timeout %q", cfg.Options["timeout"])
+		}
+		return New(Config{
+			Command: strings.Fields(cfg.Option("command", "")),
+			Timeout: timeout,
+			Logger:  cfg.Logger,
+		})
+	})
+}
+
+// Config configures the process.
+type Config struct {
+	// Command is the program and its arguments; the program is looked up in
+	// $PATH if not a path.
+	Command []string
+	// Timeout bounds the wait for a reply. Defaults to 2s.
+	Timeout time.Duration
+	// Logger receives the plugin's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Plugin is a running plugin process.
+type Plugin struct {
+	cfg   Config
+	cmd   *exec.Cmd
+	done  chan struct{} // closed once the process has exited
+	stdin io.WriteCloser
+
+	mu      sync.Mutex // serializes requests and guards the fields below
+	next    uint64
+	pending map[uint64]chan plugin.Reply
+	err     error // why the process exited
+}
+
+// New starts the process.
+func New(cfg Config) (*Plugin, error) {
+	if len(cfg.Command) == 0 {
+		return nil, errors.New("command is required")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	cmd := exec.Command(cfg.Command[0], cfg.Command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("exec: %w", err)
+	}
+	p := &Plugin{cfg: cfg, cmd: cmd, done: make(chan struct{}), stdin: stdin, pending: make(map[uint64]chan plugin.Reply)}
+	p.cfg.Logger = logging.With(cfg.Logger, "pid", cmd.Process.Pid)
+	p.cfg.Logger.Debug("plugin process started", "command", cfg.Command[0])
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		p.logStderr(stderr)
+	}()
+	go p.read(stdout, logged)
+	return p, nil
+}
+
+// Process implements plugin.Transcript.
+func (p *Plugin) Process(ctx context.Context, seg *stt.Segment) error {
+	ch := make(chan plugin.Reply, 1)
+	p.mu.Lock()
+	if p.err != nil {
+		p.mu.Unlock()
+		return p.err
+	}
+	p.next++
+	id := p.next
+	p.pending[id] = ch
+	b, err := json.Marshal(plugin.NewRequest(id, seg))
+	if err == nil {
+		_, err = p.stdin.Write(append(b, '\n'))
+	}
+	p.mu.Unlock()
+	defer p.forget(id)
+	if err != nil {
+		return fmt.Errorf("exec: write request: %w", err)
+	}
+
+	t := time.NewTimer(p.cfg.Timeout)
+	defer t.Stop()
+	select {
+	case r := <-ch:
+		if err := r.Apply(seg); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return fmt.Errorf("exec: no reply within %v", p.cfg.Timeout)
+	case <-p.done:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.err
+	}
+}
+
+func (p *Plugin) forget(id uint64) {
+	p.mu.Lock()
+	delete(p.pending, id)
+	p.mu.Unlock()
+}
+
+// read dispatches replies until the process closes stdout, then reaps it
+// once its stderr has been logged.
+func (p *Plugin) read(stdout io.Reader, logged <-chan struct{}) {
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var r plugin.Reply
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			p.cfg.Logger.Warn("bad plugin reply", "error", err)
+			continue
+		}
+		p.mu.Lock()
+		ch, ok := p.pending[r.ID]
+		p.mu.Unlock()
+		if ok {
+			select {
+			case ch <- r:
+			default: // a second reply to the same request
+			}
+		}
+	}
+	<-logged
+	err := p.cmd.Wait()
+	if err == nil {
+		err = errors.New("exited")
+	}
+	p.mu.Lock()
+	p.err = fmt.Errorf("exec: plugin process %w", err)
+	p.mu.Unlock()
+	close(p.done)
+	p.cfg.Logger.Debug("plugin process ended", "error", err)
+}
+
+func (p *Plugin) logStderr(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		p.cfg.Logger.Info("plugin stderr", "line", sc.Text())
+	}
+}
+
+// Close ends the process: closing its stdin asks it to exit, and it is
+// killed if it has not within the timeout.
+func (p *Plugin) Close() error {
+	_ = p.stdin.Close()
+	select {
+	case <-p.done:
+	case <-time.After(p.cfg.Timeout):
+		_ = p.cmd.Process.Kill()
+		<-p.done
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Package wasm runs transcript plugins as WebAssembly modules in an
+// embedded runtime (wazero), so post-processing such as redaction,
+// formatting or custom punctuation can be written in any language that
+// targets WASI and shipped as one portable .wasm file, without native code
+// in the voxa process.
+//
+// A module is a WASI command run once per segment: it reads a
+// plugin.Request as JSON on stdin, writes a plugin.Reply as JSON on stdout
+// and exits. Every run starts from a fresh instance, so segments cannot
+// leak into one another, and it sees no files, network or environment. Its
+// memory, run time and output are capped; a run exceeding a limit fails
+// the segment. What the module writes to stderr is logged.
+package wasm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/plugin"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Name is the name the plugin registers under.
+const Name = "wasm"
+
+func init() {
+	plugin.RegisterTranscript(Name, func(cfg plugin.Config) (plugin.Transcript, error) {
+		mem, err := strconv.Atoi(cfg.Option("memory_mb", "64"))
+		if err != nil || mem <= 0 {
+			return nil, fmt.Errorf("bad memory_mb %q", cfg.Options["memory_mb"])
+		}
+		timeout, err := time.ParseDuration(cfg.Option("timeout", "250ms"))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("bad timeout %q", cfg.Options["timeout"])
+		}
+		return New(Config{
+			Module:      cfg.Option("module", ""),
+			MemoryLimit: mem << 20,
+			Timeout:     timeout,
+			Logger:      cfg.Logger,
+		})
+	})
+}
+
+// Config configures the runtime.
+type Config struct {
+	// Module is the .wasm file.
+	Module string
+	// MemoryLimit caps the linear memory of a run, in bytes, rounded up to
+	// 64KiB pages. Defaults to 64MiB.
+	MemoryLimit int
+	// Timeout caps the run time of a segment. Defaults to 250ms.
+	Timeout time.Duration
+	// MaxReply caps the size of a reply. Defaults to 1MiB.
+	MaxReply int
+	// Logger receives the plugin's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Plugin runs a compiled module.
+type Plugin struct {
+	cfg Config
+	rt  wazero.Runtime
+	mod wazero.CompiledModule
+}
+
+const pageSize = 64 << 10
+
+// New compiles the module.
+func New(cfg Config) (*Plugin, error) {
+	if cfg.Module == "" {
+		return nil, errors.New("module is required")
+	}
+	if cfg.MemoryLimit == 0 {
+		cfg.MemoryLimit = 64 << 20
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 250 * time.Millisecond
+	}
+	if cfg.MaxReply == 0 {
+		cfg.MaxReply = 1 << 20
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	code, err := os.ReadFile(cfg.Module)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32((cfg.MemoryLimit+pageSize-1)/pageSize)).
+		// Interrupts runs over their timeout.
+		WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		_ = rt.Close(ctx)
+		return nil, fmt.Errorf("wasm: %w", err)
+	}
+	mod, err := rt.CompileModule(ctx, code)
+	if err != nil {
+		_ = rt.Close(ctx)
+		return nil, fmt.Errorf("wasm: %s: %w", cfg.Module, err)
+	}
+	return &Plugin{cfg: cfg, rt: rt, mod: mod}, nil
+}
+
+// Process implements plugin.Transcript.
+func (p *Plugin) Process(ctx context.Context, seg *stt.Segment) error {
+	in, err := json.Marshal(plugin.NewRequest(1, seg))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+	out := &capped{max: p.cfg.MaxReply}
+	stderr := &capped{max: 4 << 10, truncate: true}
+	m, err := p.rt.InstantiateModule(ctx, p.mod, wazero.NewModuleConfig().
+		WithName(""). // instances run concurrently
+		WithStdin(bytes.NewReader(in)).
+		WithStdout(out).
+		WithStderr(stderr))
+	if m != nil {
+		_ = m.Close(context.WithoutCancel(ctx))
+	}
+	p.logStderr(seg, stderr)
+	var exit *sys.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 0 {
+		err = nil
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("wasm: run exceeded %v", p.cfg.Timeout)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case out.over:
+		return fmt.Errorf("wasm: reply larger than %d bytes", p.cfg.MaxReply)
+	case err != nil:
+		return fmt.Errorf("wasm: %w", err)
+	}
+	var r plugin.Reply
+	if err := json.Unmarshal(out.buf.Bytes(), &r); err != nil {
+		return fmt.Errorf("wasm: bad reply: %w", err)
+	}
+	if err := r.Apply(seg); err != nil {
+		return fmt.Errorf("wasm: %w", err)
+	}
+	return nil
+}
+
+func (p *Plugin) logStderr(seg *stt.Segment, stderr *capped) {
+	sc := bufio.NewScanner(&stderr.buf)
+	for sc.Scan() {
+		p.cfg.Logger.Info("plugin stderr", "utterance", seg.UtteranceID, "line", sc.Text())
+	}
+}
+
+// Close releases the runtime.
+func (p *Plugin) Close() error {
+	return p.rt.Close(context.Background())
+}
+
+// capped is a buffer holding at most max bytes. Writes beyond fail, or with
+// truncate are dropped.
+type capped struct {
+	buf      bytes.Buffer
+	max      int
+	truncate bool
+	over     bool
+}
+
+func (c *capped) Write(b []byte) (int, error) {
+	if c.buf.Len()+len(b) > c.max {
+		c.over = true
+		if c.truncate {
+			c.buf.Write(b[:c.max-c.buf.Len()])
+			return len(b), nil
+		}
+		return 0, errors.New("output limit reached")
+	}
+	return c.buf.Write(b)
+}
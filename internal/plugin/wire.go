@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"errors"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Wire schema of the plugins that run outside the process (see packages
+// plugin/process and plugin/wasm): a Request carries a segment as JSON and
+// the plugin answers with a Reply.
+
+// Request asks an external plugin to process a segment.
+type Request struct {
+	// ID matches the Reply to the Request.
+	ID      uint64       `json:"id"`
+	Segment *WireSegment `json:"segment"`
+}
+
+// Reply is an external plugin's answer to a Request.
+type Reply struct {
+	ID uint64 `json:"id"`
+	// Segment, if set, carries the rewritten text.
+	Segment *WireSegment `json:"segment,omitempty"`
+	// Error, if set, fails the segment.
+	Error string `json:"error,omitempty"`
+}
+
+// WireSegment is a transcript segment in the wire schema.
+type WireSegment struct {
+	UtteranceID string     `json:"utterance_id,omitempty"`
+	Revision    int        `json:"revision,omitempty"`
+	Text        string     `json:"text"`
+	Stability   float32    `json:"stability,omitempty"`
+	Final       bool       `json:"final,omitempty"`
+	Speaker     string     `json:"speaker,omitempty"`
+	StartMS     int64      `json:"start_ms,omitempty"`
+	EndMS       int64      `json:"end_ms,omitempty"`
+	Confidence  float32    `json:"confidence,omitempty"`
+	Words       []WireWord `json:"words,omitempty"`
+	Language    string     `json:"language,omitempty"`
+}
+
+// WireWord is one aligned word in the wire schema.
+type WireWord struct {
+	Text    string `json:"text"`
+	StartMS int64  `json:"start_ms,omitempty"`
+	EndMS   int64  `json:"end_ms,omitempty"`
+}
+
+// NewRequest wraps seg in a Request.
+func NewRequest(id uint64, seg *stt.Segment) Request {
+	w := &WireSegment{
+		UtteranceID: seg.UtteranceID,
+		Revision:    seg.Revision,
+		Text:        seg.Text,
+		Stability:   seg.Stability,
+		Final:       seg.Final,
+		Speaker:     seg.Speaker,
+		StartMS:     seg.Start.Milliseconds(),
+		EndMS:       seg.End.Milliseconds(),
+		Confidence:  seg.Confidence,
+		Language:    seg.Language,
+	}
+	for _, wd := range seg.Words {
+		w.Words = append(w.Words, WireWord{Text: wd.Text, StartMS: wd.Start.Milliseconds(), EndMS: wd.End.Milliseconds()})
+	}
+	return Request{ID: id, Segment: w}
+}
+
+// Apply rewrites seg as r says: its text and, if r lists as many words, the
+// text of each word. Timing and the other fields are kept.
+func (r Reply) Apply(seg *stt.Segment) error {
+	if r.Error != "" {
+		return errors.New(r.Error)
+	}
+	if r.Segment == nil {
+		return nil
+	}
+	seg.Text = r.Segment.Text
+	if len(r.Segment.Words) == len(seg.Words) {
+		for i := range seg.Words {
+			seg.Words[i].Text = r.Segment.Words[i].Text
+		}
+	}
+	return nil
+}
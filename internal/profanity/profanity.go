@@ -0,0 +1,181 @@
+// Package profanity filters offensive words out of transcripts. Words are
+// matched against a built-in list of common English profanity plus any
+// patterns the deployment adds; each match is masked ("f***"), dropped, or
+// tagged so downstream consumers can render it as they see fit.
+package profanity
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Mode selects what the filter does with a match.
+type Mode int
+
+// Filter modes. The zero Mode leaves the choice to the filter's owner; New
+// takes it as Mask.
+const (
+	// Mask keeps the first letter of the word and stars the rest: "f***".
+	Mask Mode = iota + 1
+	// Drop removes the word.
+	Drop
+	// Tag keeps the word and wraps it: "<profanity>word</profanity>".
+	Tag
+)
+
+// String returns the name accepted by ParseMode.
+func (m Mode) String() string {
+	switch m {
+	case Mask:
+		return "mask"
+	case Drop:
+		return "drop"
+	case Tag:
+		return "tag"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ParseMode returns the mode named s: "mask", "drop" or "tag".
+func ParseMode(s string) (Mode, error) {
+	for _, m := range []Mode{Mask, Drop, Tag} {
+		if s == m.String() {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown profanity mode %q", s)
+}
+
+// builtin is the default word list, as patterns over whole words.
+var builtin = []string{
+	`(?:mother)?fuck\w*`,
+	`(?:bull|horse)?shit\w*`,
+	`bitch\w*`,
+	`bastards?`,
+	`cunts?`,
+	`dick(?:head)?s?`,
+	`cocks?(?:suck\w*)?`,
+	`pricks?`,
+	`twats?`,
+	`wank\w*`,
+	`(?:ass|arse)holes?`,
+	`jackass\w*`,
+	`piss\w*`,
+	`goddamn\w*`,
+	`sluts?`,
+	`whores?`,
+}
+
+// Config configures a Filter.
+type Config struct {
+	// Mode is what happens to a matched word. Defaults to Mask.
+	Mode Mode
+	// Patterns are regular expressions filtered in addition to the built-in
+	// list. They are matched case-insensitively against whole words.
+	Patterns []string
+	// Allow lists words never filtered even when a pattern matches them,
+	// such as names.
+	Allow []string
+}
+
+// Filter rewrites transcripts. It is safe for concurrent use.
+type Filter struct {
+	mode  Mode
+	re    *regexp.Regexp
+	allow map[string]bool
+}
+
+// New compiles the filter.
+func New(cfg Config) (*Filter, error) {
+	if cfg.Mode == 0 {
+		cfg.Mode = Mask
+	}
+	if _, err := ParseMode(cfg.Mode.String()); err != nil {
+		return nil, fmt.Errorf("profanity: %w", err)
+	}
+	alts := append([]string(nil), builtin...)
+	for _, p := range cfg.Patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("profanity: bad pattern %q: %w", p, err)
+		}
+		alts = append(alts, "(?:"+p+")")
+	}
+	f := &Filter{
+		mode:  cfg.Mode,
+		re:    regexp.MustCompile(`(?i)\b(?:` + strings.Join(alts, "|") + `)\b`),
+		allow: make(map[string]bool, len(cfg.Allow)),
+	}
+	for _, w := range cfg.Allow {
+		f.allow[strings.ToLower(w)] = true
+	}
+	return f, nil
+}
+
+// Clean returns text with its profanity filtered.
+func (f *Filter) Clean(text string) string {
+	out := f.re.ReplaceAllStringFunc(text, f.replace)
+	if f.mode == Drop && out != text {
+		out = tidy(out)
+	}
+	return out
+}
+
+// Process filters the text and words of seg. It implements
+// plugin.Transcript, so the filter runs like any other transcript stage.
+func (f *Filter) Process(_ context.Context, seg *stt.Segment) error {
+	seg.Text = f.Clean(seg.Text)
+	if seg.Words == nil {
+		return nil
+	}
+	// The words may be shared with the recognizer, so they are copied.
+	words := make([]stt.Word, 0, len(seg.Words))
+	for _, w := range seg.Words {
+		w.Text = f.Clean(w.Text)
+		if f.mode == Drop && !hasLetters(w.Text) {
+			continue
+		}
+		words = append(words, w)
+	}
+	seg.Words = words
+	return nil
+}
+
+func (f *Filter) replace(word string) string {
+	if f.allow[strings.ToLower(word)] {
+		return word
+	}
+	switch f.mode {
+	case Drop:
+		return ""
+	case Tag:
+		return "<profanity>" + word + "</profanity>"
+	}
+	_, n := utf8.DecodeRuneInString(word)
+	return word[:n] + strings.Repeat("*", utf8.RuneCountInString(word)-1)
+}
+
+var (
+	spaces        = regexp.MustCompile(`\s{2,}`)
+	spacedPunct   = regexp.MustCompile(`\s+([,.;:!?])`)
+	leadingPunct  = regexp.MustCompile(`^[\s,;:]+`)
+	danglingComma = regexp.MustCompile(`[,;:]+([,.;:!?])`)
+)
+
+// tidy repairs the spacing and punctuation left by dropped words.
+func tidy(s string) string {
+	s = spaces.ReplaceAllString(s, " ")
+	s = spacedPunct.ReplaceAllString(s, "$1")
+	s = danglingComma.ReplaceAllString(s, "$1")
+	s = leadingPunct.ReplaceAllString(s, "")
+	return strings.TrimSpace(s)
+}
+
+func hasLetters(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+}
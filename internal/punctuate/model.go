@@ -0,0 +1,77 @@
+package punctuate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// HTTPModel is a Model served over HTTP, such as a small token
+// classification model behind a thin web server. It POSTs
+//
+//	{"language": "en", "words": ["so", "what", "now"]}
+//
+// to its endpoint and takes a response with one token per word:
+//
+//	{"tokens": [{"mark": ","}, {"capitalize": true}, {"mark": "?"}]}
+type HTTPModel struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPModel returns a model served at endpoint, an http or https URL.
+// A nil client selects http.DefaultClient; calls are bounded by
+// Config.Timeout.
+func NewHTTPModel(endpoint string, client *http.Client) (*HTTPModel, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("punctuate: model %q is not an http or https URL", endpoint)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPModel{endpoint: endpoint, client: client}, nil
+}
+
+type modelRequest struct {
+	Language string   `json:"language"`
+	Words    []string `json:"words"`
+}
+
+type modelResponse struct {
+	Tokens []Token `json:"tokens"`
+}
+
+// Punctuate implements Model.
+func (m *HTTPModel) Punctuate(ctx context.Context, lang string, words []string) ([]Token, error) {
+	body, err := json.Marshal(modelRequest{Language: lang, Words: words})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(msg))}
+	}
+	var out modelResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return out.Tokens, nil
+}
@@ -0,0 +1,295 @@
+// Package punctuate restores the punctuation, capitalization and sentence
+// boundaries that local recognizers leave out of their transcripts.
+//
+// A Restorer rewrites the segments of the languages it is configured for,
+// unless the recognizer punctuated them already. Its rules split an
+// utterance into sentences at the pauses between its words, when the
+// recognizer times them; capitalize every sentence and the words always
+// written capitalized, such as "I" in English; put a comma before
+// conjunctions such as "but"; and end a sentence opening with an
+// interrogative with a question mark, and any other with a period. A final
+// segment ends a sentence, while a partial gets no mark at its end since
+// the utterance goes on. Spanish questions open with "¿", and French puts
+// a narrow space before "?" and "!".
+//
+// An optional Model, such as a small token classifier served over HTTP,
+// punctuates final segments instead, in any language it knows; the rules
+// take over for partials, which come too often to wait on it, and whenever
+// it fails.
+package punctuate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jmarc101/voxa/internal/langtag"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Defaults.
+const (
+	defaultLanguage = "en"
+	defaultPause    = 600 * time.Millisecond
+	defaultTimeout  = time.Second
+)
+
+// Model predicts the punctuation of a transcript.
+type Model interface {
+	// Punctuate returns the punctuation of words spoken in lang, an ISO
+	// 639-1 code: one Token per word.
+	Punctuate(ctx context.Context, lang string, words []string) ([]Token, error)
+}
+
+// Token is the punctuation of one word.
+type Token struct {
+	// Mark follows the word, such as "," "." or "?"; empty for none.
+	Mark string `json:"mark,omitempty"`
+	// Capitalize upper-cases the first letter of the word.
+	Capitalize bool `json:"capitalize,omitempty"`
+}
+
+// Config configures a Restorer.
+type Config struct {
+	// Language is the ISO 639-1 code of segments that report no language.
+	// Defaults to "en".
+	Language string
+	// Languages are restored; segments in others pass through unchanged.
+	// Defaults to every language with rules, see Languages. With a Model,
+	// languages without rules may be listed: their partials pass through.
+	Languages []string
+	// Words are extra words always written as given, such as names and
+	// brands, by language: {"en": {"Voxa", "iPhone"}}.
+	Words map[string][]string
+	// Pause is the silence between two timed words that ends a sentence.
+	// Defaults to 600 milliseconds.
+	Pause time.Duration
+	// Model, if set, punctuates final segments.
+	Model Model
+	// Timeout bounds a call to the model. Defaults to a second.
+	Timeout time.Duration
+	// Logger receives model failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// Restorer punctuates transcripts. It is safe for concurrent use.
+type Restorer struct {
+	cfg   Config
+	log   logging.Logger
+	langs map[string]*rules            // nil for a language only the model knows
+	words map[string]map[string]string // Config.Words by lowercase word
+}
+
+// New validates cfg.
+func New(cfg Config) (*Restorer, error) {
+	switch {
+	case cfg.Pause < 0:
+		return nil, fmt.Errorf("punctuate: negative pause %v", cfg.Pause)
+	case cfg.Timeout < 0:
+		return nil, fmt.Errorf("punctuate: negative timeout %v", cfg.Timeout)
+	}
+	if cfg.Language == "" {
+		cfg.Language = defaultLanguage
+	}
+	if cfg.Pause == 0 {
+		cfg.Pause = defaultPause
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	cfg.Language = langtag.Base(cfg.Language)
+	if len(cfg.Languages) == 0 {
+		cfg.Languages = Languages()
+	}
+	r := &Restorer{cfg: cfg, log: logging.OrNop(cfg.Logger), langs: map[string]*rules{}, words: map[string]map[string]string{}}
+	for _, l := range cfg.Languages {
+		l = langtag.Base(l)
+		if l == "" {
+			return nil, errors.New("punctuate: empty language")
+		}
+		rl := languages[l]
+		if rl == nil && cfg.Model == nil {
+			return nil, fmt.Errorf("punctuate: no rules for language %q (have %v) and no model", l, Languages())
+		}
+		r.langs[l] = rl
+	}
+	for l, words := range cfg.Words {
+		base := langtag.Base(l)
+		if _, ok := r.langs[base]; !ok {
+			return nil, fmt.Errorf("punctuate: words for language %q, which is not restored", l)
+		}
+		m := r.words[base]
+		if m == nil {
+			m = make(map[string]string, len(words))
+			r.words[base] = m
+		}
+		for _, w := range words {
+			if strings.TrimSpace(w) == "" {
+				return nil, fmt.Errorf("punctuate: empty word for language %q", l)
+			}
+			m[lower(w)] = w
+		}
+	}
+	return r, nil
+}
+
+// punctuated matches the punctuation of text that already has some, but
+// not decimal points and the like.
+var punctuated = regexp.MustCompile(`[.,?!;:…](?:\s|$)|[¿¡]`)
+
+// Process punctuates the text and words of seg. It implements
+// plugin.Transcript, so the restorer runs like any other transcript stage.
+func (r *Restorer) Process(ctx context.Context, seg *stt.Segment) error {
+	lang := r.cfg.Language
+	if seg.Language != "" {
+		lang = langtag.Base(seg.Language)
+	}
+	rl, ok := r.langs[lang]
+	if !ok || punctuated.MatchString(seg.Text) {
+		return nil
+	}
+	words := strings.Fields(seg.Text)
+	if len(words) == 0 {
+		return nil
+	}
+	var toks []Token
+	if r.cfg.Model != nil && seg.Final {
+		toks = r.predict(ctx, lang, words)
+	}
+	if toks == nil {
+		if rl == nil {
+			return nil
+		}
+		toks = r.rules(rl, words, gaps(seg, len(words)), seg.Final)
+	}
+	out := render(rl, r.words[lang], words, toks)
+	seg.Text = strings.Join(out, " ")
+	if len(seg.Words) == len(words) {
+		// The words may be shared with the recognizer, so they are copied.
+		timed := make([]stt.Word, len(words))
+		for i, w := range seg.Words {
+			w.Text = out[i]
+			timed[i] = w
+		}
+		seg.Words = timed
+	}
+	return nil
+}
+
+// predict asks the model, returning nil if it fails.
+func (r *Restorer) predict(ctx context.Context, lang string, words []string) []Token {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+	toks, err := r.cfg.Model.Punctuate(ctx, lang, words)
+	if err == nil && len(toks) != len(words) {
+		err = fmt.Errorf("%d tokens for %d words", len(toks), len(words))
+	}
+	if err != nil {
+		r.log.Warn("punctuation model failed, applying rules", "language", lang, "error", err)
+		return nil
+	}
+	return toks
+}
+
+// gaps returns the silence after each of the n words of seg, or nil if
+// they are not timed.
+func gaps(seg *stt.Segment, n int) []time.Duration {
+	if len(seg.Words) != n {
+		return nil
+	}
+	g := make([]time.Duration, n)
+	for i := 0; i+1 < n; i++ {
+		g[i] = seg.Words[i+1].Start - seg.Words[i].End
+	}
+	return g
+}
+
+// rules punctuates words by the rules of a language.
+func (r *Restorer) rules(rl *rules, words []string, gaps []time.Duration, final bool) []Token {
+	toks := make([]Token, len(words))
+	start := 0 // the first word of the sentence
+	end := func(i int) {
+		toks[i].Mark = "."
+		if rl.questions[lower(words[start])] ||
+			(start+1 < len(words) && rl.questions[lower(words[start])+" "+lower(words[start+1])]) {
+			toks[i].Mark = "?"
+		}
+		start = i + 1
+	}
+	for i, w := range words {
+		if i == start {
+			toks[i].Capitalize = true
+		} else if rl.commas[lower(w)] {
+			toks[i-1].Mark = ","
+		}
+		if gaps != nil && i+1 < len(words) && gaps[i] >= r.cfg.Pause {
+			end(i)
+		}
+	}
+	if final {
+		end(len(words) - 1)
+	}
+	return toks
+}
+
+// render applies toks to words, writing those in extra as given. rl may be
+// nil for a language the model knows but the rules do not.
+func render(rl *rules, extra map[string]string, words []string, toks []Token) []string {
+	if rl == nil {
+		rl = &rules{}
+	}
+	out := make([]string, len(words))
+	open := true // words[i] starts a sentence
+	for i, w := range words {
+		if p, ok := extra[lower(w)]; ok {
+			w = p
+		} else if p, ok := rl.proper[lower(w)]; ok {
+			w = p
+		} else if toks[i].Capitalize {
+			w = capitalize(w)
+		}
+		if open && rl.invert && question(toks[i:]) {
+			w = "¿" + w
+		}
+		mark := toks[i].Mark
+		if rl.spaced && (mark == "?" || mark == "!") {
+			w += "\u202f" // narrow no-break space
+		}
+		out[i] = w + mark
+		open = mark == "." || mark == "?" || mark == "!"
+	}
+	return out
+}
+
+// question reports whether the sentence starting with toks[0] ends with a
+// question mark.
+func question(toks []Token) bool {
+	for _, t := range toks {
+		switch t.Mark {
+		case "?":
+			return true
+		case ".", "!":
+			return false
+		}
+	}
+	return false
+}
+
+func capitalize(w string) string {
+	for i, c := range w {
+		if unicode.IsLetter(c) {
+			return w[:i] + string(unicode.ToUpper(c)) + w[i+utf8.RuneLen(c):]
+		}
+	}
+	return w
+}
+
+func lower(w string) string {
+	return strings.ToLower(strings.Trim(w, `"'`))
+}
@@ -0,0 +1,89 @@
+package punctuate
+
+import "sort"
+
+// rules is the rule set of one language. Words are lowercase.
+type rules struct {
+	// questions open a sentence that ends with a question mark: single
+	// words, or pairs of words separated by a space.
+	questions map[string]bool
+	// commas follow a comma when they do not start a sentence.
+	commas map[string]bool
+	// proper are always capitalized, as written.
+	proper map[string]string
+	// invert opens questions with "¿".
+	invert bool
+	// spaced marks are preceded by a space, as French typography has
+	// it for "?" and "!".
+	spaced bool
+}
+
+func set(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
+
+func proper(words ...string) map[string]string {
+	m := make(map[string]string, len(words))
+	for _, w := range words {
+		m[lower(w)] = w
+	}
+	return m
+}
+
+var languages = map[string]*rules{
+	"en": {
+		questions: set("what", "who", "whom", "whose", "where", "when", "why", "how", "which",
+			"is", "are", "am", "was", "were", "do", "does", "did", "can", "could", "will",
+			"would", "should", "shall", "may", "might", "have", "has", "had", "isn't",
+			"aren't", "don't", "doesn't", "didn't", "can't", "won't", "wouldn't", "shouldn't"),
+		commas: set("but", "however", "although", "though", "because", "unless", "which"),
+		proper: proper("I", "I'm", "I'll", "I've", "I'd",
+			"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday",
+			"January", "February", "April", "June", "July", "August", "September", "October",
+			"November", "December", "English", "French", "Spanish", "German"),
+	},
+	"fr": {
+		questions: set("qui", "que", "quoi", "qu'est-ce", "où", "quand", "pourquoi", "comment",
+			"combien", "quel", "quelle", "quels", "quelles", "est-ce", "lequel", "laquelle"),
+		commas: set("mais", "donc", "car", "puisque", "parce"),
+		spaced: true,
+	},
+	"es": {
+		questions: set("qué", "quién", "quiénes", "dónde", "cuándo", "cómo", "cuál", "cuáles",
+			"cuánto", "cuánta", "cuántos", "cuántas", "por qué", "adónde"),
+		commas: set("pero", "aunque", "porque", "sino"),
+		proper: proper("Dios"),
+		invert: true,
+	},
+	"de": {
+		questions: set("was", "wer", "wen", "wem", "wessen", "wo", "wohin", "woher", "wann",
+			"warum", "wieso", "weshalb", "wie", "welche", "welcher", "welches", "ist", "sind",
+			"hast", "habt", "kannst", "können", "bist", "gibt"),
+		commas: set("aber", "dass", "weil", "ob", "obwohl", "sondern", "wenn"),
+	},
+	"it": {
+		questions: set("che", "chi", "cosa", "dove", "quando", "perché", "come", "quale",
+			"quali", "quanto", "quanti"),
+		commas: set("ma", "però", "perché", "quindi"),
+	},
+	"pt": {
+		questions: set("que", "quem", "onde", "quando", "por que", "como", "qual",
+			"quais", "quanto", "quantos"),
+		commas: set("mas", "porém", "porque", "pois"),
+	},
+}
+
+// Languages returns the ISO 639-1 codes of the languages with rules,
+// sorted.
+func Languages() []string {
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
@@ -0,0 +1,257 @@
+// Package queue provides the bounded queues placed between pipeline stages.
+//
+// A Queue holds at most Size items. What happens to a push when it is full
+// is set by its Policy: Block makes the producer wait, passing backpressure
+// upstream; DropOldest discards the item that has waited longest, favouring
+// fresh audio; DropNewest discards the incoming one. Dropped items and the
+// queue depth are reported to metrics under the queue's name.
+//
+// Queue locks a mutex per operation and wakes its waiters through a
+// condition variable. Ring keeps the same contract without locks, for the
+// audio path, where it sits between a writer and a stage running in step
+// with it.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/metrics"
+)
+
+// Policy selects what a full queue does with a push.
+type Policy int
+
+// Overflow policies. The zero Policy leaves the choice to the queue's
+// owner; New takes it as Block.
+const (
+	// Block waits for room.
+	Block Policy = iota + 1
+	// DropOldest discards the oldest queued item to make room.
+	DropOldest
+	// DropNewest discards the item being pushed.
+	DropNewest
+)
+
+// String returns the name accepted by ParsePolicy.
+func (p Policy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop-oldest"
+	case DropNewest:
+		return "drop-newest"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicy returns the policy named s: "block", "drop-oldest" or
+// "drop-newest".
+func ParsePolicy(s string) (Policy, error) {
+	for _, p := range []Policy{Block, DropOldest, DropNewest} {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown overflow policy %q", s)
+}
+
+// ErrClosed is returned by Push after Close.
+var ErrClosed = errors.New("queue: closed")
+
+// Options configures a Queue.
+type Options[T any] struct {
+	// Name labels the queue in metrics.
+	Name string
+	// Size is the number of items the queue holds. It must be positive.
+	Size int
+	// Policy applies when the queue is full. Defaults to Block.
+	Policy Policy
+	// Keep, if set, marks items that must never be dropped, such as control
+	// markers. Pushing one into a full queue that drops items drops the
+	// oldest other item instead, or waits when there is none.
+	Keep func(T) bool
+	// Release, if set, is called with every item the queue discards, so
+	// its resources can be recycled.
+	Release func(T)
+	// Metrics, if set, receives the queue depth and the dropped items.
+	Metrics *metrics.Metrics
+}
+
+// Queue is a bounded FIFO queue, safe for concurrent use.
+type Queue[T any] struct {
+	opts Options[T]
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	ring    []T // Size slots; the items start at head
+	head, n int
+	dropped int
+	closed  bool
+}
+
+// New creates a queue.
+func New[T any](opts Options[T]) (*Queue[T], error) {
+	if opts.Size <= 0 {
+		return nil, fmt.Errorf("queue %s: size must be positive, got %d", opts.Name, opts.Size)
+	}
+	if opts.Policy == 0 {
+		opts.Policy = Block
+	}
+	if opts.Policy < Block || opts.Policy > DropNewest {
+		return nil, fmt.Errorf("queue %s: unknown overflow policy %d", opts.Name, int(opts.Policy))
+	}
+	q := &Queue[T]{opts: opts, ring: make([]T, opts.Size)}
+	q.cond = sync.NewCond(&q.mu)
+	return q, nil
+}
+
+// Push appends v. When the queue is full, v or an older item is dropped,
+// or Push waits for room until ctx is done, as set by the policy. It
+// returns ErrClosed after Close, and ctx's error if it gave up waiting.
+func (q *Queue[T]) Push(ctx context.Context, v T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	keep := q.opts.Keep != nil && q.opts.Keep(v)
+	for !q.closed && q.n == len(q.ring) {
+		if q.opts.Policy == DropNewest && !keep {
+			q.drop(v)
+			return nil
+		}
+		if q.opts.Policy != Block {
+			if i := q.droppable(); i >= 0 {
+				q.drop(q.remove(i))
+				q.opts.Metrics.Queued(q.opts.Name, -1)
+				break
+			}
+		}
+		if err := q.wait(ctx); err != nil {
+			return err
+		}
+	}
+	if q.closed {
+		return ErrClosed
+	}
+	*q.at(q.n) = v
+	q.n++
+	q.opts.Metrics.Queued(q.opts.Name, 1)
+	q.cond.Broadcast()
+	return nil
+}
+
+// droppable returns the index of the oldest item that may be dropped, or
+// -1; q.mu must be held.
+func (q *Queue[T]) droppable() int {
+	for i := range q.n {
+		if q.opts.Keep == nil || !q.opts.Keep(*q.at(i)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// at returns the slot of the i-th oldest item; q.mu must be held.
+func (q *Queue[T]) at(i int) *T {
+	return &q.ring[(q.head+i)%len(q.ring)]
+}
+
+// remove takes out the i-th oldest item; q.mu must be held.
+func (q *Queue[T]) remove(i int) T {
+	var zero T
+	v := *q.at(i)
+	if i == 0 {
+		*q.at(0) = zero
+		q.head = (q.head + 1) % len(q.ring)
+		q.n--
+		return v
+	}
+	for ; i < q.n-1; i++ {
+		*q.at(i) = *q.at(i + 1)
+	}
+	*q.at(q.n - 1) = zero
+	q.n--
+	return v
+}
+
+// drop counts and releases a dropped item; q.mu must be held.
+func (q *Queue[T]) drop(v T) {
+	q.dropped++
+	q.opts.Metrics.Dropped(q.opts.Name, q.opts.Policy.String())
+	q.release(v)
+}
+
+func (q *Queue[T]) release(v T) {
+	if q.opts.Release != nil {
+		q.opts.Release(v)
+	}
+}
+
+// wait blocks until the queue changes or ctx is done; q.mu must be held.
+func (q *Queue[T]) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+	q.cond.Wait()
+	stop()
+	return ctx.Err()
+}
+
+// Pop removes and returns the oldest item, waiting for one. It returns
+// false once the queue is closed and empty, or when ctx is done.
+func (q *Queue[T]) Pop(ctx context.Context) (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.n == 0 {
+		if q.closed || q.wait(ctx) != nil {
+			var zero T
+			return zero, false
+		}
+	}
+	v := q.remove(0)
+	q.opts.Metrics.Queued(q.opts.Name, -1)
+	q.cond.Broadcast()
+	return v, true
+}
+
+// Len returns the number of queued items.
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+// Dropped returns how many items the overflow policy discarded.
+func (q *Queue[T]) Dropped() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// Close stops further pushes and wakes the waiting ones. Items already
+// queued can still be popped.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// Discard drops the queued items without counting them, for consumers that
+// gave up.
+func (q *Queue[T]) Discard() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.opts.Metrics.Queued(q.opts.Name, -q.n)
+	for q.n > 0 {
+		q.release(q.remove(0))
+	}
+	q.cond.Broadcast()
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPushCancelWhileFull(t *testing.T) {
+	q, err := New(Options[int]{Name: "test", Size: 1, Policy: Block})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- q.Push(ctx, 2) }()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Push = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Push still blocked after cancel")
+	}
+	if n := q.Len(); n != 1 {
+		t.Errorf("Len = %d, want 1", n)
+	}
+}
+
+func TestPopCancelWhileEmpty(t *testing.T) {
+	q, err := New(Options[int]{Name: "test", Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	okc := make(chan bool, 1)
+	go func() {
+		_, ok := q.Pop(ctx)
+		okc <- ok
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case ok := <-okc:
+		if ok {
+			t.Error("Pop returned a value from an empty queue")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pop still blocked after cancel")
+	}
+}
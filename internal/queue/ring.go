@@ -0,0 +1,296 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// Ring is a bounded lock-free FIFO queue for the streaming path, safe for
+// concurrent use. It takes the Options of a Queue, with one difference: a
+// push into a full ring that drops items waits when the oldest one must be
+// kept, as it cannot drop the items behind it.
+//
+// Pushes and pops claim slots with atomic operations alone, so a producer
+// and a consumer running in step never take a lock, and waiters are only
+// woken when they sleep. PopBatch hands the consumer every item queued in
+// one go: a stage that fell behind catches up in a single wake-up instead
+// of one per item.
+type Ring[T any] struct {
+	opts  Options[T]
+	slots []slot[T]
+	// head and tail count the items ever popped and pushed; item i lives
+	// in slots[i%len(slots)].
+	head, tail atomic.Uint64
+	dropped    atomic.Int64
+	closed     atomic.Bool
+	done       chan struct{} // closed by Close
+
+	// Sleeping poppers and pushers, and the channels that wake them.
+	poppers, pushers atomic.Int32
+	ready, room      chan struct{}
+}
+
+// slot is a cell of a Ring. Its seq is the position of the item it holds
+// plus one once filled, or the position of the next item it takes once
+// emptied, as in Vyukov's bounded queue.
+type slot[T any] struct {
+	seq  atomic.Uint64
+	keep atomic.Bool
+	v    T
+}
+
+// spins is how many times a waiter yields before it sleeps.
+const spins = 8
+
+// NewRing creates a ring.
+func NewRing[T any](opts Options[T]) (*Ring[T], error) {
+	if opts.Size <= 0 {
+		return nil, fmt.Errorf("queue %s: size must be positive, got %d", opts.Name, opts.Size)
+	}
+	if opts.Policy == 0 {
+		opts.Policy = Block
+	}
+	if opts.Policy < Block || opts.Policy > DropNewest {
+		return nil, fmt.Errorf("queue %s: unknown overflow policy %d", opts.Name, int(opts.Policy))
+	}
+	r := &Ring[T]{
+		opts: opts,
+		// A single slot could not tell a filled cell from one freed for
+		// the next lap, so rings of one item have two.
+		slots: make([]slot[T], max(opts.Size, 2)),
+		done:  make(chan struct{}),
+		ready: make(chan struct{}, 1),
+		room:  make(chan struct{}, 1),
+	}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r, nil
+}
+
+// enqueue appends v unless the ring is full.
+func (r *Ring[T]) enqueue(v T, keep bool) bool {
+	size := uint64(len(r.slots))
+	for {
+		pos := r.tail.Load()
+		if pos-r.head.Load() >= uint64(r.opts.Size) {
+			return false
+		}
+		s := &r.slots[pos%size]
+		switch seq := s.seq.Load(); {
+		case seq == pos:
+			if !r.tail.CompareAndSwap(pos, pos+1) {
+				continue
+			}
+			s.v = v
+			s.keep.Store(keep)
+			s.seq.Store(pos + 1)
+			return true
+		case seq < pos:
+			return false // the slot still holds the item a lap behind
+		}
+		// Another push took pos; retry with the new tail.
+	}
+}
+
+// dequeue takes the oldest item. With droppable, it leaves one that must
+// be kept; ok is false if none was taken.
+func (r *Ring[T]) dequeue(droppable bool) (v T, ok bool) {
+	size := uint64(len(r.slots))
+	for {
+		pos := r.head.Load()
+		s := &r.slots[pos%size]
+		switch seq := s.seq.Load(); {
+		case seq == pos+1:
+			if droppable && s.keep.Load() && s.seq.Load() == pos+1 {
+				return v, false
+			}
+			if !r.head.CompareAndSwap(pos, pos+1) {
+				continue
+			}
+			v = s.v
+			var zero T
+			s.v = zero
+			s.seq.Store(pos + size)
+			return v, true
+		case seq < pos+1:
+			return v, false // empty
+		}
+	}
+}
+
+// Push appends v, following the overflow policy when the ring is full. It
+// returns ErrClosed after Close, and ctx's error if it gave up waiting.
+func (r *Ring[T]) Push(ctx context.Context, v T) error {
+	keep := r.opts.Keep != nil && r.opts.Keep(v)
+	for {
+		if r.closed.Load() {
+			return ErrClosed
+		}
+		if r.enqueue(v, keep) {
+			r.opts.Metrics.Queued(r.opts.Name, 1)
+			wake(r.ready, &r.poppers)
+			return nil
+		}
+		if r.opts.Policy == DropNewest && !keep {
+			r.drop(v)
+			return nil
+		}
+		if r.opts.Policy == DropOldest {
+			if old, ok := r.dequeue(true); ok {
+				r.opts.Metrics.Queued(r.opts.Name, -1)
+				r.drop(old)
+				continue
+			}
+		}
+		if err := r.sleep(ctx, r.room, &r.pushers, func() bool { return r.Len() < r.opts.Size }); err != nil {
+			return err
+		}
+	}
+}
+
+// Pop removes and returns the oldest item, waiting for one. It returns
+// false once the ring is closed and empty, or when ctx is done.
+func (r *Ring[T]) Pop(ctx context.Context) (T, bool) {
+	for {
+		if v, ok := r.dequeue(false); ok {
+			r.popped(1)
+			return v, true
+		}
+		if r.wait(ctx) != nil {
+			var zero T
+			return zero, false
+		}
+	}
+}
+
+// PopBatch appends the queued items to dst, waiting for one: as many as
+// fit in the capacity of dst, or all of them if it is full. It returns
+// false once the ring is closed and empty, or when ctx is done.
+func (r *Ring[T]) PopBatch(ctx context.Context, dst []T) ([]T, bool) {
+	limit := cap(dst) - len(dst)
+	if limit <= 0 {
+		limit = r.opts.Size
+	}
+	for {
+		n := 0
+		for ; n < limit; n++ {
+			v, ok := r.dequeue(false)
+			if !ok {
+				break
+			}
+			dst = append(dst, v)
+		}
+		if n > 0 {
+			r.popped(n)
+			return dst, true
+		}
+		if r.wait(ctx) != nil {
+			return dst, false
+		}
+	}
+}
+
+// popped accounts for n popped items, waking a pusher waiting for room.
+func (r *Ring[T]) popped(n int) {
+	r.opts.Metrics.Queued(r.opts.Name, -n)
+	wake(r.room, &r.pushers)
+}
+
+// wait sleeps until an item may have been pushed. It returns ErrClosed
+// once the ring is closed and empty, and ctx's error when it is done.
+func (r *Ring[T]) wait(ctx context.Context) error {
+	if r.closed.Load() && r.Len() == 0 {
+		return ErrClosed
+	}
+	return r.sleep(ctx, r.ready, &r.poppers, func() bool { return r.Len() > 0 || r.closed.Load() })
+}
+
+// sleep waits on ch, counted in waiters, unless ready already holds once
+// the waiter is counted, which rules out a missed wake-up: the other side
+// changes the ring before counting waiters. A waiter passes the wake-up on
+// to the next one.
+func (r *Ring[T]) sleep(ctx context.Context, ch chan struct{}, waiters *atomic.Int32, ready func() bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// The other side usually acts within microseconds: yielding a few
+	// times first spares the wake-up.
+	for range spins {
+		runtime.Gosched()
+		if ready() {
+			return nil
+		}
+	}
+	waiters.Add(1)
+	if ready() {
+		waiters.Add(-1)
+		return nil
+	}
+	select {
+	case <-ch:
+	case <-r.done:
+	case <-ctx.Done():
+	}
+	if waiters.Add(-1) > 0 {
+		wake(ch, waiters)
+	}
+	return ctx.Err()
+}
+
+// wake wakes a waiter on ch, if there is one.
+func wake(ch chan struct{}, waiters *atomic.Int32) {
+	if waiters.Load() > 0 {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// drop counts and releases a dropped item.
+func (r *Ring[T]) drop(v T) {
+	r.dropped.Add(1)
+	r.opts.Metrics.Dropped(r.opts.Name, r.opts.Policy.String())
+	if r.opts.Release != nil {
+		r.opts.Release(v)
+	}
+}
+
+// Len returns the number of queued items.
+func (r *Ring[T]) Len() int {
+	head := r.head.Load()
+	return int(r.tail.Load() - head)
+}
+
+// Dropped returns how many items the overflow policy discarded.
+func (r *Ring[T]) Dropped() int { return int(r.dropped.Load()) }
+
+// Close stops further pushes and wakes the waiting ones. Items already
+// queued can still be popped.
+func (r *Ring[T]) Close() {
+	if r.closed.CompareAndSwap(false, true) {
+		close(r.done)
+	}
+}
+
+// Discard drops the queued items without counting them, for consumers that
+// gave up.
+func (r *Ring[T]) Discard() {
+	n := 0
+	for {
+		v, ok := r.dequeue(false)
+		if !ok {
+			break
+		}
+		n++
+		if r.opts.Release != nil {
+			r.opts.Release(v)
+		}
+	}
+	if n > 0 {
+		r.popped(n)
+	}
+}
@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRingOrderAcrossLaps(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 50} {
+		r, err := NewRing(Options[int]{Name: "test", Size: size})
+		if err != nil {
+			t.Fatal(err)
+		}
+		const n = 1000
+		go func() {
+			for i := range n {
+				if err := r.Push(context.Background(), i); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			r.Close()
+		}()
+		var got []int
+		batch := make([]int, 0, size)
+		for {
+			var ok bool
+			if batch, ok = r.PopBatch(context.Background(), batch[:0]); !ok {
+				break
+			}
+			if len(batch) > size {
+				t.Fatalf("size %d: batch of %d", size, len(batch))
+			}
+			got = append(got, batch...)
+		}
+		if len(got) != n {
+			t.Fatalf("size %d: popped %d items, want %d", size, len(got), n)
+		}
+		for i, v := range got {
+			if v != i {
+				t.Fatalf("size %d: item %d is %d", size, i, v)
+			}
+		}
+	}
+}
+
+func TestRingDropOldestKeeps(t *testing.T) {
+	var released []int
+	r, err := NewRing(Options[int]{
+		Name:    "test",
+		Size:    3,
+		Policy:  DropOldest,
+		Keep:    func(v int) bool { return v < 0 },
+		Release: func(v int) { released = append(released, v) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []int{1, 2, 3, 4, 5} {
+		if err := r.Push(context.Background(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := r.Dropped(); d != 2 || len(released) != 2 || released[0] != 1 || released[1] != 2 {
+		t.Fatalf("Dropped = %d, released %v, want 2 and [1 2]", d, released)
+	}
+	// A kept item at the head of a full ring makes pushes wait.
+	r.Discard()
+	for _, v := range []int{-1, 6, 7} {
+		if err := r.Push(context.Background(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Push(ctx, 8); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Push behind a kept item = %v, want context.DeadlineExceeded", err)
+	}
+	if v, _ := r.Pop(context.Background()); v != -1 {
+		t.Fatalf("Pop = %d, want the kept -1", v)
+	}
+}
+
+func TestRingCloseWakesWaiters(t *testing.T) {
+	r, err := NewRing(Options[int]{Name: "test", Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Push(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := r.Push(context.Background(), 2); !errors.Is(err, ErrClosed) {
+			t.Errorf("blocked Push = %v, want ErrClosed", err)
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	r.Close()
+	wg.Wait()
+	if v, ok := r.Pop(context.Background()); !ok || v != 1 {
+		t.Fatalf("Pop after Close = %d, %v; want the queued 1", v, ok)
+	}
+	if _, ok := r.Pop(context.Background()); ok {
+		t.Fatal("Pop returned a value from a closed, empty ring")
+	}
+}
+
+// BenchmarkHandoff measures passing items from a producer to a consumer
+// goroutine, one by one through a Queue and in batches through a Ring.
+func BenchmarkHandoff(b *testing.B) {
+	b.Run("queue", func(b *testing.B) {
+		q, _ := New(Options[int]{Name: "bench", Size: 64})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				if _, ok := q.Pop(context.Background()); !ok {
+					return
+				}
+			}
+		}()
+		for i := range b.N {
+			_ = q.Push(context.Background(), i)
+		}
+		q.Close()
+		<-done
+	})
+	b.Run("ring", func(b *testing.B) {
+		r, _ := NewRing(Options[int]{Name: "bench", Size: 64})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			batch := make([]int, 0, 64)
+			for {
+				var ok bool
+				if batch, ok = r.PopBatch(context.Background(), batch[:0]); !ok {
+					return
+				}
+			}
+		}()
+		for i := range b.N {
+			_ = r.Push(context.Background(), i)
+		}
+		r.Close()
+		<-done
+	})
+}
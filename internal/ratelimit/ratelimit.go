@@ -0,0 +1,183 @@
+// Package ratelimit holds the calls made to a provider's API to the rate
+// and the concurrency its account allows, so that bursts wait on the
+// client instead of being throttled by the provider, whose errors would
+// otherwise cascade into retries and failovers.
+//
+// A Limiter is a token bucket, refilled at QPS calls per second and
+// holding up to Burst, and a cap on the calls in flight. Limiters are
+// shared by key: every provider, fallback and pipeline of the process
+// limited under the same key, such as that of one cloud account, draws on
+// the same Limiter.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrLimited is returned for calls that would have waited longer than
+// Config.MaxWait.
+var ErrLimited = errors.New("ratelimit: provider rate limit reached")
+
+// Config is the limits of a provider account.
+type Config struct {
+	// Key names the account the limits apply to, conventionally
+	// provider/account; limits of the same key are shared. Provider
+	// wrappers default it to the provider name.
+	Key string
+	// QPS is the calls per second made on average. 0 is unlimited.
+	QPS float64
+	// Burst is how many calls may be made at once above QPS. Defaults to
+	// QPS, rounded up.
+	Burst int
+	// Concurrency bounds the calls in flight, such as the open streams of
+	// a recognizer. 0 is unlimited.
+	Concurrency int
+	// MaxWait, if set, fails the calls that would wait longer than this
+	// for the limits with ErrLimited, rather than until their context is
+	// done, so that they can fail over.
+	MaxWait time.Duration
+}
+
+// Validate checks the limits of c.
+func (c Config) Validate() error {
+	name := "ratelimit"
+	if c.Key != "" {
+		name += ": " + c.Key
+	}
+	switch {
+	case c.QPS < 0 || c.Burst < 0 || c.Concurrency < 0:
+		return fmt.Errorf("%s: negative limit", name)
+	case c.MaxWait < 0:
+		return fmt.Errorf("%s: negative max wait %v", name, c.MaxWait)
+	case c.QPS == 0 && c.Concurrency == 0:
+		return fmt.Errorf("%s: no qps or concurrency", name)
+	}
+	return nil
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   = map[string]*Limiter{}
+)
+
+// Shared returns the limiter of cfg.Key, created on first use. A cfg whose
+// limits differ from those of the limiter, as after a configuration
+// reload, replaces it; the calls in flight on the old one are not counted
+// against the new.
+func Shared(cfg Config) (*Limiter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if l := shared[cfg.Key]; l != nil && l.cfg == withDefaults(cfg) {
+		return l, nil
+	}
+	l := New(cfg)
+	shared[cfg.Key] = l
+	return l, nil
+}
+
+// Limiter holds calls to a Config. It is safe for concurrent use.
+type Limiter struct {
+	cfg Config
+
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+	inflight int
+	freed    chan struct{} // closed when a call in flight ends
+}
+
+// New returns a limiter of cfg, which must be valid, shared with no one.
+func New(cfg Config) *Limiter {
+	cfg = withDefaults(cfg)
+	return &Limiter{cfg: cfg, tokens: float64(cfg.Burst), last: time.Now(), freed: make(chan struct{})}
+}
+
+func withDefaults(cfg Config) Config {
+	if cfg.Burst == 0 && cfg.QPS > 0 {
+		cfg.Burst = max(1, int(math.Ceil(cfg.QPS)))
+	}
+	return cfg
+}
+
+// Config returns the limits of l.
+func (l *Limiter) Config() Config { return l.cfg }
+
+// Acquire waits until a call may be made, or for ctx to be done. The call
+// holds a slot of the concurrency until release is called; release may be
+// called more than once.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	var deadline <-chan time.Time
+	if l.cfg.MaxWait > 0 {
+		t := time.NewTimer(l.cfg.MaxWait)
+		defer t.Stop()
+		deadline = t.C
+	}
+	for {
+		wait, freed := l.take()
+		if wait == 0 && freed == nil {
+			var once sync.Once
+			return func() { once.Do(l.release) }, nil
+		}
+		if err := l.wait(ctx, wait, freed, deadline); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait waits for d, or for freed if set, failing once deadline or ctx is
+// done first.
+func (l *Limiter) wait(ctx context.Context, d time.Duration, freed <-chan struct{}, deadline <-chan time.Time) error {
+	var tick <-chan time.Time
+	if freed == nil {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		tick = t.C
+	}
+	select {
+	case <-tick:
+	case <-freed:
+	case <-deadline:
+		return fmt.Errorf("%w: %s", ErrLimited, l.cfg.Key)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// take takes a token and a slot for a call, or returns how long until a
+// token is available, or a channel closed when a slot frees.
+func (l *Limiter) take() (time.Duration, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cfg.Concurrency > 0 && l.inflight >= l.cfg.Concurrency {
+		return 0, l.freed
+	}
+	if l.cfg.QPS > 0 {
+		now := time.Now()
+		l.tokens = min(float64(l.cfg.Burst), l.tokens+now.Sub(l.last).Seconds()*l.cfg.QPS)
+		l.last = now
+		if l.tokens < 1 {
+			return max(time.Duration((1-l.tokens)/l.cfg.QPS*float64(time.Second)), time.Millisecond), nil
+		}
+		l.tokens--
+	}
+	l.inflight++
+	return 0, nil
+}
+
+// release ends a call in flight.
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
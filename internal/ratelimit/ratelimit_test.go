@@ -0,0 +1,195 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// elapse moves the bucket of l d into the future.
+func elapse(l *Limiter, d time.Duration) {
+	l.mu.Lock()
+	l.last = l.last.Add(-d)
+	l.mu.Unlock()
+}
+
+// inflight returns the calls l counts in flight.
+func inflight(l *Limiter) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+func TestRefill(t *testing.T) {
+	// A MaxWait shorter than any refill refuses instead of waiting.
+	l := New(Config{Key: "test", QPS: 2, Burst: 3, MaxWait: time.Millisecond})
+	allowed := func(n int) {
+		t.Helper()
+		for i := range n {
+			release, err := l.Acquire(context.Background())
+			if err != nil {
+				t.Fatalf("call %d: %v", i+1, err)
+			}
+			release()
+		}
+	}
+	refused := func(maxWait time.Duration) {
+		t.Helper()
+		if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrLimited) {
+			t.Fatalf("call over the rate: %v, want %v", err, ErrLimited)
+		}
+		if wait, _ := l.take(); wait <= 0 || wait > maxWait {
+			t.Errorf("call over the rate: wait %v, want up to %v", wait, maxWait)
+		}
+	}
+	allowed(3) // the burst
+	refused(500 * time.Millisecond)
+	elapse(l, 500*time.Millisecond) // one call more
+	allowed(1)
+	refused(500 * time.Millisecond)
+	elapse(l, 400*time.Millisecond) // not quite another
+	refused(100 * time.Millisecond)
+	elapse(l, time.Hour) // refilled to the burst, no more
+	allowed(3)
+	refused(500 * time.Millisecond)
+
+	// Without a MaxWait, a call waits for the next token.
+	l = New(Config{QPS: 50, Burst: 1})
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 15*time.Millisecond {
+		t.Errorf("second call waited %v, want about 20ms", waited)
+	}
+}
+
+func TestMaxWait(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     Config
+		timeout time.Duration // of the context of the call
+		want    error
+	}{
+		{"rate", Config{QPS: 1, MaxWait: 20 * time.Millisecond}, time.Second, ErrLimited},
+		{"concurrency", Config{Concurrency: 1, MaxWait: 20 * time.Millisecond}, time.Second, ErrLimited},
+		{"context first", Config{Concurrency: 1, MaxWait: time.Second}, 20 * time.Millisecond, context.DeadlineExceeded},
+		{"no max wait", Config{QPS: 1}, 20 * time.Millisecond, context.DeadlineExceeded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := New(tc.cfg)
+			if _, err := l.Acquire(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			start := time.Now()
+			_, err := l.Acquire(ctx)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Acquire = %v, want %v", err, tc.want)
+			}
+			if waited := time.Since(start); waited > 500*time.Millisecond {
+				t.Errorf("refused after %v, want after 20ms", waited)
+			}
+			if n := inflight(l); n != 1 {
+				t.Errorf("%d calls in flight, want the 1 let through", n)
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	l := New(Config{Concurrency: 1})
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		release func()
+		err     error
+	}
+	waiter := make(chan result)
+	go func() {
+		release, err := l.Acquire(context.Background())
+		waiter <- result{release, err}
+	}()
+	select {
+	case r := <-waiter:
+		t.Fatalf("call let through with the slot taken: %v", r.err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	r := <-waiter
+	if r.err != nil {
+		t.Fatalf("waiter woken with %v", r.err)
+	}
+	release() // once only: the slot stays the waiter's
+	if n := inflight(l); n != 1 {
+		t.Errorf("%d calls in flight, want 1", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("call with the slot taken: %v, want it waiting", err)
+	}
+	r.release()
+	r.release()
+	if n := inflight(l); n != 0 {
+		t.Errorf("%d calls in flight, want none", n)
+	}
+}
+
+func TestShared(t *testing.T) {
+	a, err := Shared(Config{Key: "test/a", QPS: 2.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		same bool
+	}{
+		{"same limits", Config{Key: "test/a", QPS: 2.5}, true},
+		{"the default burst", Config{Key: "test/a", QPS: 2.5, Burst: 3}, true},
+		{"another key", Config{Key: "test/b", QPS: 2.5}, false},
+		{"another rate", Config{Key: "test/a", QPS: 5}, false},
+		{"another max wait", Config{Key: "test/a", QPS: 2.5, MaxWait: time.Second}, false},
+	} {
+		l, err := Shared(tc.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (l == a) != tc.same {
+			t.Errorf("%s: shared %v, want %v", tc.name, l == a, tc.same)
+		}
+		// Restore the limits of a for the next case.
+		if a, err = Shared(Config{Key: "test/a", QPS: 2.5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A replaced limiter leaves the calls in flight on the old one.
+	old, err := Shared(Config{Key: "test/c", Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Shared(Config{Key: "test/c", Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l == old || inflight(l) != 0 || l.Config().Concurrency != 2 {
+		t.Errorf("reloaded limiter %+v with %d in flight, want a new one with none", l.Config(), inflight(l))
+	}
+
+	for _, cfg := range []Config{{Key: "test/d"}, {Key: "test/d", QPS: -1}, {Key: "test/d", Concurrency: 1, MaxWait: -1}} {
+		if _, err := Shared(cfg); err == nil {
+			t.Errorf("Shared(%+v): no error", cfg)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+// Package redact scrubs personal data from transcripts: payment card
+// numbers, social security numbers, phone numbers, email addresses and any
+// entity a deployment describes with a pattern. Candidates are found by
+// regular expression and, where the format has one, confirmed by a checksum
+// or validity rule (Luhn for cards, the SSA's allocation rules for SSNs)
+// so order numbers and the like survive.
+//
+// Every redacted span is replaced by a marker naming its entity, such as
+// "[CREDIT_CARD]", and reported in stt.Segment.Redactions with its offsets
+// in the original text. Numbers must be written as digits, as recognizers
+// with inverse text normalization produce them; digits spelled out as
+// words are not detected.
+package redact
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Entity is a kind of personal data.
+type Entity string
+
+// Built-in entities.
+const (
+	// CreditCard is a payment card number of 13 to 19 digits passing the
+	// Luhn check.
+	CreditCard Entity = "credit_card"
+	// SSN is a US social security number.
+	SSN Entity = "ssn"
+	// Phone is a phone number of 10 to 15 digits.
+	Phone Entity = "phone"
+	// Email is an email address.
+	Email Entity = "email"
+)
+
+// Entities returns the built-in entities, in the order they are detected.
+// A span matching several is redacted as the first.
+func Entities() []Entity {
+	return []Entity{Email, CreditCard, SSN, Phone}
+}
+
+// Marker returns the text that replaces a span of entity e.
+func Marker(e Entity) string {
+	return "[" + strings.ToUpper(string(e)) + "]"
+}
+
+type detector struct {
+	entity Entity
+	re     *regexp.Regexp
+	valid  func(match string) bool // nil accepts every match
+}
+
+var builtin = map[Entity]detector{
+	Email: {
+		re: regexp.MustCompile(`\b[\w.+-]+@[\w-]+(?:\.[\w-]+)+\b`),
+	},
+	CreditCard: {
+		re:    regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid: func(m string) bool { return luhn(digits(m)) },
+	},
+	SSN: {
+		re:    regexp.MustCompile(`\b\d{3}[ -]?\d{2}[ -]?\d{4}\b`),
+		valid: func(m string) bool { return validSSN(digits(m)) },
+	},
+	Phone: {
+		// International numbers with a + prefix, or North American ones.
+		re: regexp.MustCompile(`\+\d{1,3}(?:[ .-]?\d{1,4}){2,5}\b|(?:\+?\b1[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`),
+		valid: func(m string) bool {
+			n := len(digits(m))
+			return n >= 10 && n <= 15
+		},
+	},
+}
+
+// validLen returns the length of the longest valid prefix of match ending
+// with a group of its digits, or -1 if there is none. Patterns take up the
+// digits said after a number along with it, as the first group of an SSN
+// following a card number, which then no longer passes its check.
+func (d detector) validLen(match string) int {
+	if d.valid == nil {
+		return len(match)
+	}
+	for n := len(match); n > 0; n = strings.LastIndexAny(match[:n], " -") {
+		if d.valid(match[:n]) {
+			return n
+		}
+	}
+	return -1
+}
+
+// Config configures a Redactor.
+type Config struct {
+	// Entities are the built-in entities to redact. Empty selects all of
+	// them.
+	Entities []Entity
+	// Patterns adds entities of the deployment's own, as regular
+	// expressions of the text to redact keyed by entity name. They are
+	// detected after the built-in ones.
+	Patterns map[string]string
+}
+
+// Redactor rewrites transcripts. It is safe for concurrent use.
+type Redactor struct {
+	detectors []detector
+}
+
+// New compiles the redactor.
+func New(cfg Config) (*Redactor, error) {
+	entities := cfg.Entities
+	if len(entities) == 0 {
+		entities = Entities()
+	}
+	r := &Redactor{}
+	for _, e := range entities {
+		d, ok := builtin[e]
+		if !ok {
+			return nil, fmt.Errorf("redact: unknown entity %q (built in: %v)", e, Entities())
+		}
+		d.entity = e
+		r.detectors = append(r.detectors, d)
+	}
+	// Custom patterns are applied in name order, so redaction is
+	// deterministic.
+	names := make([]string, 0, len(cfg.Patterns))
+	for name := range cfg.Patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re, err := regexp.Compile(cfg.Patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("redact: bad pattern for %s: %w", name, err)
+		}
+		r.detectors = append(r.detectors, detector{entity: Entity(name), re: re})
+	}
+	return r, nil
+}
+
+// Redact returns text with its personal data replaced by markers, and the
+// spans replaced.
+func (r *Redactor) Redact(text string) (string, []stt.Redaction) {
+	var spans []stt.Redaction
+	for _, d := range r.detectors {
+		for _, loc := range d.re.FindAllStringIndex(text, -1) {
+			n := d.validLen(text[loc[0]:loc[1]])
+			if n < 0 {
+				continue
+			}
+			loc[1] = loc[0] + n
+			if !overlaps(spans, loc[0], loc[1]) {
+				spans = append(spans, stt.Redaction{Entity: string(d.entity), Start: loc[0], End: loc[1]})
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return text, nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	var b strings.Builder
+	last := 0
+	for i := range spans {
+		b.WriteString(text[last:spans[i].Start])
+		spans[i].At = b.Len()
+		b.WriteString(Marker(Entity(spans[i].Entity)))
+		last = spans[i].End
+	}
+	b.WriteString(text[last:])
+	return b.String(), spans
+}
+
+// Process redacts the text and words of seg and records the redactions.
+// It implements plugin.Transcript, so the redactor runs like any other
+// transcript stage.
+func (r *Redactor) Process(_ context.Context, seg *stt.Segment) error {
+	text, spans := r.Redact(seg.Text)
+	if spans == nil {
+		return nil
+	}
+	seg.Words = redactWords(seg.Text, seg.Words, spans, r)
+	seg.Text = text
+	seg.Redactions = append(seg.Redactions, spans...)
+	return nil
+}
+
+// redactWords replaces the words covering a redacted span of text by one
+// word holding its marker and spanning their times. Words that cannot be
+// located in text, because the recognizer normalized them differently, are
+// redacted on their own.
+func redactWords(text string, words []stt.Word, spans []stt.Redaction, r *Redactor) []stt.Word {
+	if len(words) == 0 {
+		return words
+	}
+	// The words may be shared with the recognizer, so they are copied.
+	out := make([]stt.Word, 0, len(words))
+	pos := 0
+	merging := -1 // index in spans of the span the last word of out covers
+	for _, w := range words {
+		i := strings.Index(text[pos:], w.Text)
+		if i < 0 || w.Text == "" {
+			w.Text, _ = r.Redact(w.Text)
+			out = append(out, w)
+			merging = -1
+			continue
+		}
+		start, end := pos+i, pos+i+len(w.Text)
+		pos = end
+		s := covering(spans, start, end)
+		switch {
+		case s < 0:
+			out = append(out, w)
+		case s == merging:
+			out[len(out)-1].End = w.End
+		default:
+			w.Text = Marker(Entity(spans[s].Entity))
+			out = append(out, w)
+		}
+		merging = s
+	}
+	return out
+}
+
+func overlaps(spans []stt.Redaction, start, end int) bool {
+	return covering(spans, start, end) >= 0
+}
+
+// covering returns the index of the span overlapping [start, end), or -1.
+func covering(spans []stt.Redaction, start, end int) int {
+	for i, s := range spans {
+		if start < s.End && s.Start < end {
+			return i
+		}
+	}
+	return -1
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// luhn reports whether the digits pass the Luhn checksum carried by
+// payment card numbers, and are as many as a card has.
+func luhn(ds string) bool {
+	if len(ds) < 13 || len(ds) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range len(ds) {
+		d := int(ds[len(ds)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validSSN reports whether the nine digits were ever issuable as a social
+// security number: no area 000, 666 or 900-999, no group 00 and no serial
+// 0000.
+func validSSN(ds string) bool {
+	if len(ds) != 9 {
+		return false
+	}
+	area, group, serial := ds[:3], ds[3:5], ds[5:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
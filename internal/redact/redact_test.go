@@ -0,0 +1,179 @@
+package redact
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+func TestLuhn(t *testing.T) {
+	for _, tc := range []struct {
+		number string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"4111-1111-1111-1111", true},
+		{"378282246310005", true},       // 15 digits
+		{"6011111111111117", true},      // 16 digits
+		{"4222222222222", true},         // 13 digits, the fewest
+		{"4111111111111112", false},     // check digit off by one
+		{"4111111111111121", false},     // two digits swapped
+		{"411111111116", false},         // 12 digits, too few
+		{"41111111111111111113", false}, // 20 digits, too many
+	} {
+		if got := luhn(digits(tc.number)); got != tc.want {
+			t.Errorf("luhn(%q) = %v, want %v", tc.number, got, tc.want)
+		}
+	}
+}
+
+func TestValidSSN(t *testing.T) {
+	for _, tc := range []struct {
+		ssn  string
+		want bool
+	}{
+		{"123-45-6789", true},
+		{"123 45 6789", true},
+		{"665-45-6789", true},
+		{"899-45-6789", true},
+		{"000-45-6789", false},
+		{"666-45-6789", false},
+		{"900-45-6789", false},
+		{"987-65-4321", false},
+		{"999-45-6789", false},
+		{"123-00-6789", false},
+		{"123-45-0000", false},
+		{"123-45-678", false},
+	} {
+		if got := validSSN(digits(tc.ssn)); got != tc.want {
+			t.Errorf("validSSN(%q) = %v, want %v", tc.ssn, got, tc.want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	r, err := New(Config{Patterns: map[string]string{"order": `\bORD-\d+\b`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		in, want string
+		entities []string
+	}{
+		{"my card is 4111 1111 1111 1111 thanks", "my card is [CREDIT_CARD] thanks", []string{"credit_card"}},
+		{"my card is 4111-1111-1111-1111", "my card is [CREDIT_CARD]", []string{"credit_card"}},
+		{"order 4111 1111 1111 1112 shipped", "order 4111 1111 1111 1112 shipped", nil},
+		{"ssn 123-45-6789 on file", "ssn [SSN] on file", []string{"ssn"}},
+		{"ssn 000-45-6789 666-45-6789 912-45-6789", "ssn 000-45-6789 666-45-6789 912-45-6789", nil},
+		{"call (415) 555-0100 or +44 20 7946 0958", "call [PHONE] or [PHONE]", []string{"phone", "phone"}},
+		{"mail bob.smith+x@example.co.uk now", "mail [EMAIL] now", []string{"email"}},
+		{"ref ORD-1234 for 123-45-6789", "ref [ORDER] for [SSN]", []string{"order", "ssn"}},
+		{"card 4111 1111 1111 1111 123-45-6789", "card [CREDIT_CARD] [SSN]", []string{"credit_card", "ssn"}},
+		{"card 4111 1111 1111 1111 12 items", "card [CREDIT_CARD] 12 items", []string{"credit_card"}},
+		{"nothing to see in 12345", "nothing to see in 12345", nil},
+	} {
+		got, spans := r.Redact(tc.in)
+		if got != tc.want {
+			t.Errorf("Redact(%q)\n got %q\nwant %q", tc.in, got, tc.want)
+		}
+		var entities []string
+		for _, s := range spans {
+			entities = append(entities, s.Entity)
+			if marker := Marker(Entity(s.Entity)); !strings.HasPrefix(got[s.At:], marker) {
+				t.Errorf("Redact(%q): %s marker not at %d of %q", tc.in, s.Entity, s.At, got)
+			}
+			if orig := tc.in[s.Start:s.End]; strings.Contains(got, orig) {
+				t.Errorf("Redact(%q): %q left in the text", tc.in, orig)
+			}
+		}
+		if !slices.Equal(entities, tc.entities) {
+			t.Errorf("Redact(%q) entities %v, want %v", tc.in, entities, tc.entities)
+		}
+	}
+}
+
+func TestProcessWords(t *testing.T) {
+	r, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	// timed returns the words of text, each 100ms long after a 100ms gap.
+	timed := func(text string) []stt.Word {
+		var words []stt.Word
+		for i, w := range strings.Fields(text) {
+			words = append(words, stt.Word{Text: w, Start: ms(200 * i), End: ms(200*i + 100), Confidence: 0.9})
+		}
+		return words
+	}
+	for _, tc := range []struct {
+		name  string
+		text  string
+		words []stt.Word
+		want  []stt.Word
+	}{
+		{
+			name:  "span across words",
+			text:  "card 4111 1111 1111 1111 ok",
+			words: timed("card 4111 1111 1111 1111 ok"),
+			want: []stt.Word{
+				{Text: "card", Start: 0, End: ms(100), Confidence: 0.9},
+				{Text: "[CREDIT_CARD]", Start: ms(200), End: ms(900), Confidence: 0.9},
+				{Text: "ok", Start: ms(1000), End: ms(1100), Confidence: 0.9},
+			},
+		},
+		{
+			name:  "adjacent spans kept apart",
+			text:  "4111111111111111 123-45-6789",
+			words: timed("4111111111111111 123-45-6789"),
+			want: []stt.Word{
+				{Text: "[CREDIT_CARD]", Start: 0, End: ms(100), Confidence: 0.9},
+				{Text: "[SSN]", Start: ms(200), End: ms(300), Confidence: 0.9},
+			},
+		},
+		{
+			name:  "span inside a word",
+			text:  "ssn:123-45-6789.",
+			words: timed("ssn:123-45-6789."),
+			want:  []stt.Word{{Text: "[SSN]", Start: 0, End: ms(100), Confidence: 0.9}},
+		},
+		{
+			name: "word not in the text",
+			text: "write to bob@example.com",
+			words: []stt.Word{
+				{Text: "write", Start: 0, End: ms(100)},
+				{Text: "to", Start: ms(200), End: ms(300)},
+				{Text: "Bob@Example.com", Start: ms(400), End: ms(900)},
+			},
+			want: []stt.Word{
+				{Text: "write", Start: 0, End: ms(100)},
+				{Text: "to", Start: ms(200), End: ms(300)},
+				{Text: "[EMAIL]", Start: ms(400), End: ms(900)},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			orig := slices.Clone(tc.words)
+			seg := &stt.Segment{Text: tc.text, Words: tc.words, Final: true}
+			if err := r.Process(context.Background(), seg); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(seg.Words, tc.want) {
+				t.Errorf("words\n got %+v\nwant %+v", seg.Words, tc.want)
+			}
+			if !slices.Equal(tc.words, orig) {
+				t.Errorf("the recognizer's words were changed: %+v", tc.words)
+			}
+			for _, w := range seg.Words {
+				if strings.ContainsAny(w.Text, "0123456789@") {
+					t.Errorf("word %q left unredacted", w.Text)
+				}
+			}
+		})
+	}
+}
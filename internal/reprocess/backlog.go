@@ -0,0 +1,141 @@
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// Defaults of BacklogConfig.
+const (
+	DefaultBacklogInterval = time.Minute
+	DefaultBacklogWindow   = 7 * 24 * time.Hour
+)
+
+// backlogPage is how many sessions deferred lists at a time.
+const backlogPage = 100
+
+// BacklogConfig configures a Backlog.
+type BacklogConfig struct {
+	// Name is the backlog the sessions were deferred to; see
+	// voxa.DegradedConfig.Backlog. Defaults to voxa.DefaultBacklog.
+	Name string
+	// Queue transcribes the sessions, with its pipeline, whose recognizer
+	// is checked before every job; see voxa.Pipeline.CheckRecognizer.
+	Queue *Queue
+	// Interval is how often the backlog looks for deferred sessions, and
+	// how long ago they must have ended, for their archives to be
+	// uploaded. Defaults to DefaultBacklogInterval.
+	Interval time.Duration
+	// Window is how long ago the sessions looked for may have started.
+	// Defaults to DefaultBacklogWindow.
+	Window time.Duration
+	// Logger receives what the backlog does. Nil discards it.
+	Logger logging.Logger
+}
+
+// Backlog transcribes the sessions deferred in degraded mode once the
+// recognizer is back.
+type Backlog struct {
+	cfg BacklogConfig
+	log logging.Logger
+}
+
+// NewBacklog validates cfg.
+func NewBacklog(cfg BacklogConfig) (*Backlog, error) {
+	switch {
+	case cfg.Queue == nil:
+		return nil, errors.New("reprocess: backlog without a queue")
+	case cfg.Interval < 0:
+		return nil, fmt.Errorf("reprocess: negative backlog interval %v", cfg.Interval)
+	case cfg.Window < 0:
+		return nil, fmt.Errorf("reprocess: negative backlog window %v", cfg.Window)
+	}
+	if cfg.Name == "" {
+		cfg.Name = voxa.DefaultBacklog
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultBacklogInterval
+	}
+	if cfg.Window == 0 {
+		cfg.Window = DefaultBacklogWindow
+	}
+	return &Backlog{cfg: cfg, log: logging.With(logging.OrNop(cfg.Logger), "backlog", cfg.Name)}, nil
+}
+
+// Run goes through the backlog every interval until ctx is done: if
+// sessions are deferred to it and the recognizer is back, it submits them
+// as one job and waits for it. Sessions the job fails on stay deferred and
+// are tried again on a later round.
+func (b *Backlog) Run(ctx context.Context) {
+	t := time.NewTicker(b.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		b.round(ctx)
+	}
+}
+
+func (b *Backlog) round(ctx context.Context) {
+	ids, err := b.deferred(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			b.log.Warn("listing deferred sessions failed", "error", err)
+		}
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := b.cfg.Queue.cfg.Pipeline.CheckRecognizer(ctx); err != nil {
+		b.log.Debug("recognizer still down", "sessions", len(ids), "error", err)
+		return
+	}
+	b.log.Info("recognizer back, transcribing deferred sessions", "sessions", len(ids))
+	j, err := b.cfg.Queue.Submit(Query{Sessions: ids})
+	if err != nil {
+		b.log.Warn("submitting deferred sessions failed", "error", err)
+		return
+	}
+	select {
+	case <-j.Done():
+	case <-ctx.Done():
+		j.Cancel()
+		<-j.Done()
+		return
+	}
+	p := j.Progress()
+	b.log.Info("deferred sessions transcribed", "done", p.Done, "skipped", p.Skipped, "failed", p.Failed)
+}
+
+// deferred lists the IDs of the sessions deferred to the backlog that
+// ended an interval ago or more, paging through the store.
+func (b *Backlog) deferred(ctx context.Context) ([]string, error) {
+	now := time.Now()
+	var ids []string
+	page := voxa.TranscriptQuery{Since: now.Add(-b.cfg.Window), Limit: backlogPage}
+	for {
+		sessions, err := b.cfg.Queue.cfg.Transcripts.Sessions(ctx, page)
+		if err != nil {
+			return nil, fmt.Errorf("reprocess: %w", err)
+		}
+		for _, s := range sessions {
+			if s.Metadata[voxa.DeferredKey] == b.cfg.Name && !s.Ended.IsZero() && now.Sub(s.Ended) >= b.cfg.Interval {
+				ids = append(ids, s.ID)
+			}
+		}
+		if len(sessions) < page.Limit {
+			return ids, nil
+		}
+		last := sessions[len(sessions)-1]
+		page.Before, page.BeforeID = last.Started, last.ID
+	}
+}
@@ -0,0 +1,135 @@
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/stttest"
+)
+
+// sessionStore is a transcript store of sessions without archives,
+// listing them as the SQL stores do.
+type sessionStore struct {
+	voxa.TranscriptStore
+	sessions []voxa.StoredSession
+
+	mu       sync.Mutex
+	limits   []int               // of the queries listed
+	metadata map[string][]string // the metadata set, by session
+}
+
+func (s *sessionStore) Sessions(_ context.Context, q voxa.TranscriptQuery) ([]voxa.StoredSession, error) {
+	s.mu.Lock()
+	s.limits = append(s.limits, q.Limit)
+	s.mu.Unlock()
+	if q.Limit <= 0 {
+		q.Limit = voxa.DefaultTranscriptLimit
+	}
+	all := slices.Clone(s.sessions)
+	slices.SortFunc(all, func(a, b voxa.StoredSession) int {
+		if c := b.Started.Compare(a.Started); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	var out []voxa.StoredSession
+	for _, sess := range all {
+		before := q.Before.IsZero() || sess.Started.Before(q.Before) ||
+			(q.BeforeID != "" && sess.Started.Equal(q.Before) && sess.ID > q.BeforeID)
+		if before && !sess.Started.Before(q.Since) && len(out) < q.Limit {
+			out = append(out, sess)
+		}
+	}
+	return out, nil
+}
+
+func (s *sessionStore) Archives(context.Context, string) ([]voxa.StoredArchive, error) {
+	return nil, nil
+}
+
+func (s *sessionStore) SetMetadata(_ context.Context, id string, md map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range md {
+		s.metadata[id] = append(s.metadata[id], k+"="+v)
+	}
+	return nil
+}
+
+func TestBacklogRound(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	ts := &sessionStore{metadata: map[string][]string{}}
+	deferred := func(backlog string) map[string]string { return map[string]string{voxa.DeferredKey: backlog} }
+	var want []string
+	// More deferred sessions than a page, all started in the same instant.
+	for i := range 2*backlogPage + 50 {
+		id := fmt.Sprintf("s%03d", i)
+		ts.sessions = append(ts.sessions, voxa.StoredSession{ID: id, Started: now.Add(-time.Hour), Ended: now.Add(-time.Hour), Metadata: deferred(voxa.DefaultBacklog)})
+		want = append(want, id)
+	}
+	ts.sessions = append(ts.sessions,
+		voxa.StoredSession{ID: "other backlog", Started: now.Add(-time.Hour), Ended: now.Add(-time.Hour), Metadata: deferred("night")},
+		voxa.StoredSession{ID: "not deferred", Started: now.Add(-time.Hour), Ended: now.Add(-time.Hour)},
+		voxa.StoredSession{ID: "ended just now", Started: now.Add(-time.Hour), Ended: now, Metadata: deferred(voxa.DefaultBacklog)},
+		voxa.StoredSession{ID: "running", Started: now.Add(-time.Hour), Metadata: deferred(voxa.DefaultBacklog)},
+		voxa.StoredSession{ID: "past the window", Started: now.Add(-48 * time.Hour), Ended: now.Add(-47 * time.Hour), Metadata: deferred(voxa.DefaultBacklog)},
+	)
+
+	rec := stttest.New()
+	p, err := voxa.NewPipeline(voxa.Config{Recognizer: rec.Config()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	storage, err := voxa.NewArchiveDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := New(Config{Transcripts: ts, Archive: storage, Pipeline: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	b, err := NewBacklog(BacklogConfig{Queue: q, Interval: time.Minute, Window: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec.FailOpen(errors.New("still down"))
+	b.round(context.Background())
+	if jobs := q.Jobs(); len(jobs) != 0 {
+		t.Fatalf("%d jobs submitted with the recognizer down", len(jobs))
+	}
+
+	b.round(context.Background())
+	jobs := q.Jobs()
+	if len(jobs) != 1 {
+		t.Fatalf("%d jobs submitted, want 1", len(jobs))
+	}
+	if p := jobs[0].Progress(); p.State != Done || p.Sessions != len(want) || p.Skipped != len(want) {
+		t.Errorf("job %+v, want %d sessions skipped for lack of audio", p, len(want))
+	}
+	if !slices.Equal(jobs[0].query.Sessions, want) {
+		t.Errorf("sessions submitted\n got %v\nwant %v", jobs[0].query.Sessions, want)
+	}
+	for _, id := range want {
+		if md := ts.metadata[id]; !slices.Equal(md, []string{voxa.DeferredKey + "="}) {
+			t.Errorf("metadata of %s set to %v, want it undeferred", id, md)
+		}
+	}
+	if len(ts.metadata) != len(want) {
+		t.Errorf("metadata set on %d sessions, want %d", len(ts.metadata), len(want))
+	}
+	for _, limit := range ts.limits {
+		if limit != backlogPage {
+			t.Errorf("listed %d sessions at a time, want %d", limit, backlogPage)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+// Package reprocess transcribes archived session audio again, typically
+// with a newer model or another provider, and stores each result as a new
+// version of the session's transcript.
+//
+// A Queue runs jobs one after the other in the background. A job selects
+// stored sessions by the time they started, or by ID, reads back the files
+// their streams were archived in (see voxa.Config.Archive), feeds the
+// parts of each stream in order through a pipeline, and adds the final
+// segments to the transcript store as the next version. Sessions without
+// archived audio are skipped. Job.Progress reports how far a job has got.
+//
+// A Backlog picks up the sessions deferred in degraded mode (see
+// voxa.Config.Degraded) and submits them to a queue once the recognizer
+// is back.
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// Config configures a Queue.
+type Config struct {
+	// Transcripts lists the sessions with their archives, and receives the
+	// new versions.
+	Transcripts voxa.TranscriptStore
+	// Archive is the storage the audio was archived in.
+	Archive voxa.ArchiveStorage
+	// Pipeline transcribes the audio. It must neither store transcripts
+	// nor archive audio itself.
+	Pipeline *voxa.Pipeline
+	// Provider and Label are recorded with every version made; see
+	// voxa.TranscriptVersion.
+	Provider, Label string
+	// Workers is the number of sessions of a job transcribed in parallel.
+	// Defaults to 1.
+	Workers int
+	// Logger receives per-session failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// Query selects the sessions of a job.
+type Query struct {
+	// Since and Until, if set, select sessions started in [Since, Until).
+	Since, Until time.Time
+	// Sessions, if set, selects these sessions instead, whenever they
+	// started.
+	Sessions []string
+}
+
+// State is how far a job has got.
+type State int
+
+const (
+	// Queued jobs wait for the jobs submitted before them.
+	Queued State = iota + 1
+	// Running jobs are transcribing sessions.
+	Running
+	// Done jobs went through all their sessions, some of which may have
+	// failed.
+	Done
+	// Failed jobs could not list their sessions.
+	Failed
+	// Canceled jobs were stopped by Job.Cancel or Queue.Close.
+	Canceled
+)
+
+func (s State) String() string {
+	switch s {
+	case Queued:
+		return "queued"
+	case Running:
+		return "running"
+	case Done:
+		return "done"
+	case Failed:
+		return "failed"
+	case Canceled:
+		return "canceled"
+	}
+	return "State(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Progress is a snapshot of a job.
+type Progress struct {
+	State State
+	// Sessions counts the sessions selected, once they are listed.
+	Sessions int
+	// Done, Skipped and Failed count the sessions transcribed, passed over
+	// for lack of archived audio, and failed.
+	Done, Skipped, Failed int
+	// Audio is the archived audio of the sessions gone through or under
+	// way, and Processed how much of it has been transcribed.
+	Audio, Processed time.Duration
+	// Started and Ended are zero until the job starts and ends.
+	Started, Ended time.Time
+	// Err is why the job failed or was canceled.
+	Err error
+}
+
+// Job is a submitted reprocessing job.
+type Job struct {
+	id     int
+	query  Query
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	progress Progress
+}
+
+// ID numbers the jobs of a queue from 1.
+func (j *Job) ID() int { return j.id }
+
+// Progress returns how far the job has got.
+func (j *Job) Progress() Progress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress
+}
+
+// Done is closed when the job has ended.
+func (j *Job) Done() <-chan struct{} { return j.done }
+
+// Cancel stops the job, or drops it if it has not started. Versions
+// already stored are kept.
+func (j *Job) Cancel() { j.cancel() }
+
+func (j *Job) update(fn func(*Progress)) {
+	j.mu.Lock()
+	fn(&j.progress)
+	j.mu.Unlock()
+}
+
+// Queue runs jobs in the order they were submitted. It is safe for
+// concurrent use.
+type Queue struct {
+	cfg  Config
+	log  logging.Logger
+	jobs chan *Job
+	done chan struct{}
+
+	mu     sync.Mutex
+	all    []*Job
+	closed bool
+}
+
+// New validates cfg and starts the queue.
+func New(cfg Config) (*Queue, error) {
+	switch {
+	case cfg.Transcripts == nil:
+		return nil, errors.New("reprocess: no transcript store")
+	case cfg.Archive == nil:
+		return nil, errors.New("reprocess: no archive storage")
+	case cfg.Pipeline == nil:
+		return nil, errors.New("reprocess: no pipeline")
+	case cfg.Pipeline.Transcripts() != nil:
+		return nil, errors.New("reprocess: the pipeline stores transcripts itself")
+	case cfg.Workers < 0:
+		return nil, fmt.Errorf("reprocess: %d workers", cfg.Workers)
+	}
+	if cfg.Workers == 0 {
+		cfg.Workers = 1
+	}
+	q := &Queue{cfg: cfg, log: logging.OrNop(cfg.Logger), jobs: make(chan *Job, 64), done: make(chan struct{})}
+	go q.run()
+	return q, nil
+}
+
+// Submit queues a job for the sessions q selects.
+func (q *Queue) Submit(query Query) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, errors.New("reprocess: queue closed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Job{id: len(q.all) + 1, query: query, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	j.progress.State = Queued
+	select {
+	case q.jobs <- j:
+	default:
+		cancel()
+		return nil, errors.New("reprocess: too many jobs queued")
+	}
+	q.all = append(q.all, j)
+	return j, nil
+}
+
+// Jobs lists the jobs submitted, oldest first.
+func (q *Queue) Jobs() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]*Job(nil), q.all...)
+}
+
+// Close cancels the jobs queued or running and waits for them to end.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		for _, j := range q.all {
+			j.Cancel()
+		}
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+	<-q.done
+	return nil
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for j := range q.jobs {
+		q.runJob(j)
+	}
+}
+
+// runJob goes through the sessions of j with the configured workers.
+func (q *Queue) runJob(j *Job) {
+	defer close(j.done)
+	defer j.cancel()
+	end := func(s State, err error) {
+		j.update(func(p *Progress) {
+			p.State, p.Err, p.Ended = s, err, time.Now()
+			if p.Started.IsZero() {
+				p.Started = p.Ended
+			}
+		})
+	}
+	if err := j.ctx.Err(); err != nil {
+		end(Canceled, err)
+		return
+	}
+	j.update(func(p *Progress) { p.State, p.Started = Running, time.Now() })
+	ids, err := q.sessions(j.ctx, j.query)
+	if err != nil {
+		if j.ctx.Err() != nil {
+			end(Canceled, j.ctx.Err())
+		} else {
+			end(Failed, err)
+		}
+		return
+	}
+	j.update(func(p *Progress) { p.Sessions = len(ids) })
+
+	todo := make(chan string)
+	var wg sync.WaitGroup
+	for range min(q.cfg.Workers, max(len(ids), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range todo {
+				q.session(j, id)
+			}
+		}()
+	}
+feed:
+	for _, id := range ids {
+		select {
+		case todo <- id:
+		case <-j.ctx.Done():
+			break feed
+		}
+	}
+	close(todo)
+	wg.Wait()
+	if err := j.ctx.Err(); err != nil {
+		end(Canceled, err)
+		return
+	}
+	end(Done, nil)
+}
+
+// sessions lists the IDs of the sessions q selects, paging through the
+// store.
+func (q *Queue) sessions(ctx context.Context, query Query) ([]string, error) {
+	if len(query.Sessions) > 0 {
+		return query.Sessions, nil
+	}
+	var ids []string
+	page := voxa.TranscriptQuery{Since: query.Since, Before: query.Until}
+	for {
+		sessions, err := q.cfg.Transcripts.Sessions(ctx, page)
+		if err != nil {
+			return nil, fmt.Errorf("reprocess: %w", err)
+		}
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+		if len(sessions) < voxa.DefaultTranscriptLimit {
+			// Sessions started in the same instant as the last of a page
+			// are missed, as with any paging by time.
+			return ids, nil
+		}
+		page.Before = sessions[len(sessions)-1].Started
+	}
+}
+
+// session transcribes session id into a new version, counting the outcome
+// in the progress of j.
+func (q *Queue) session(j *Job, id string) {
+	log := logging.With(q.log, "session", id)
+	archives, err := q.cfg.Transcripts.Archives(j.ctx, id)
+	if err != nil {
+		if j.ctx.Err() == nil {
+			log.Warn("listing archives failed", "error", err)
+			j.update(func(p *Progress) { p.Failed++ })
+		}
+		return
+	}
+	if len(archives) == 0 {
+		q.undefer(j, log, id)
+		j.update(func(p *Progress) { p.Skipped++ })
+		return
+	}
+	var audioLen time.Duration
+	for _, a := range archives {
+		audioLen += a.Duration
+	}
+	j.update(func(p *Progress) { p.Audio += audioLen })
+
+	var segs []voxa.Segment
+	var processed time.Duration
+	for _, parts := range streams(archives) {
+		s, err := q.stream(j, id, parts, func(d time.Duration) {
+			processed += d
+			j.update(func(p *Progress) { p.Processed += d })
+		})
+		segs = append(segs, s...)
+		if err != nil {
+			// Leave the audio not transcribed out of the totals, so they
+			// add up once the job is over.
+			j.update(func(p *Progress) { p.Audio -= audioLen - processed })
+			if j.ctx.Err() == nil {
+				log.Warn("transcribing archived audio failed", "error", err)
+				j.update(func(p *Progress) { p.Failed++ })
+			}
+			return
+		}
+	}
+	v, err := q.cfg.Transcripts.AddVersion(j.ctx, id, voxa.TranscriptVersion{
+		Provider: q.cfg.Provider,
+		Label:    q.cfg.Label,
+		Created:  time.Now(),
+	}, segs)
+	if err != nil {
+		if j.ctx.Err() == nil {
+			log.Warn("storing transcript version failed", "error", err)
+			j.update(func(p *Progress) { p.Failed++ })
+		}
+		return
+	}
+	log.Info("transcribed archived audio", "version", v.Number, "segments", v.Segments)
+	q.undefer(j, log, id)
+	j.update(func(p *Progress) { p.Done++ })
+}
+
+// undefer removes the mark of a session deferred in degraded mode, now
+// that it has been through a job; see voxa.DeferredKey.
+func (q *Queue) undefer(j *Job, log logging.Logger, id string) {
+	if err := q.cfg.Transcripts.SetMetadata(j.ctx, id, map[string]string{voxa.DeferredKey: ""}); err != nil && j.ctx.Err() == nil {
+		log.Warn("storing session metadata failed", "error", err)
+	}
+}
+
+// stream transcribes the parts of one stream as a single pipeline stream
+// and returns its final segments. Progress reports the audio written.
+func (q *Queue) stream(j *Job, id string, parts []voxa.StoredArchive, progress func(time.Duration)) ([]voxa.Segment, error) {
+	var (
+		s    *voxa.Stream
+		segs []voxa.Segment
+		done chan struct{}
+		base time.Duration // offset of the part being read in the stream
+	)
+	defer func() {
+		if s != nil {
+			_ = s.Close()
+			<-done
+		}
+	}()
+	for _, part := range parts {
+		rc, err := q.cfg.Archive.Open(j.ctx, part.Name)
+		if err != nil {
+			return nil, fmt.Errorf("reprocess: open %s: %w", part.Name, err)
+		}
+		src, err := audio.NewReader(rc)
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("reprocess: %s: %w", part.Name, err)
+		}
+		if s == nil {
+			if s, err = q.cfg.Pipeline.NewStream(j.ctx, src.Format(), voxa.StreamOptions{SessionID: id, Priority: voxa.PriorityBatch}); err != nil {
+				closeReader(src, rc)
+				return nil, err
+			}
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				for seg := range s.Results() {
+					if seg.Final {
+						segs = append(segs, seg)
+					}
+				}
+			}()
+		} else if src.Format() != s.Format() {
+			closeReader(src, rc)
+			return nil, fmt.Errorf("reprocess: %s is in %+v, its stream in %+v", part.Name, src.Format(), s.Format())
+		}
+		n, err := copyPart(j.ctx, src, s, base, progress)
+		closeReader(src, rc)
+		if err != nil {
+			return nil, fmt.Errorf("reprocess: %s: %w", part.Name, err)
+		}
+		base += n
+	}
+	err := s.Close()
+	<-done
+	if err == nil {
+		err = s.Err()
+	}
+	s = nil
+	return segs, err
+}
+
+// copyPart writes the frames of src to s, shifted by base, and returns the
+// audio written.
+func copyPart(ctx context.Context, src audio.Reader, s *voxa.Stream, base time.Duration, progress func(time.Duration)) (time.Duration, error) {
+	var n time.Duration
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		fr, err := src.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		fr.Offset += base
+		d := fr.Duration()
+		err = s.WriteFrame(fr)
+		fr.Release()
+		if err != nil {
+			return n, err
+		}
+		n += d
+		progress(d)
+	}
+}
+
+func closeReader(src audio.Reader, rc io.Closer) {
+	if c, ok := src.(io.Closer); ok {
+		_ = c.Close()
+	}
+	_ = rc.Close()
+}
+
+// streams groups archives by stream, in the order the streams started,
+// each with its parts in order.
+func streams(archives []voxa.StoredArchive) [][]voxa.StoredArchive {
+	index := map[string]int{}
+	var out [][]voxa.StoredArchive
+	for _, a := range archives {
+		i, ok := index[a.Stream]
+		if !ok {
+			i = len(out)
+			index[a.Stream] = i
+			out = append(out, nil)
+		}
+		out[i] = append(out[i], a)
+	}
+	for _, parts := range out {
+		sort.Slice(parts, func(i, k int) bool { return parts[i].Part < parts[k].Part })
+	}
+	sort.SliceStable(out, func(i, k int) bool { return out[i][0].Started.Before(out[k][0].Started) })
+	return out
+}
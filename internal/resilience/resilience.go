@@ -0,0 +1,286 @@
+// Package resilience keeps transient backend failures from ending the work
+// that depends on the backend.
+//
+// A Policy retries calls that fail transiently, waiting an exponentially
+// growing, jittered delay between attempts, and counts consecutive failures
+// in a circuit breaker. Once the breaker opens, calls fail fast with
+// ErrOpen until a cooldown has passed and a probe call succeeds, so callers
+// can switch to a fallback backend instead of piling up on a failing one.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// ErrOpen is returned for calls rejected by an open circuit breaker.
+var ErrOpen = errors.New("resilience: circuit breaker open")
+
+// Config tunes a Policy. Zero values select the defaults.
+type Config struct {
+	// Attempts is how many times a call is tried in all. Defaults to 3;
+	// 1 disables retries.
+	Attempts int
+	// Backoff is the wait before the first retry, doubling with every
+	// further one. Defaults to 200ms.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between attempts. Defaults to 5s.
+	MaxBackoff time.Duration
+	// Jitter randomizes every wait by up to this fraction of it, so that
+	// clients failing together do not retry together. Defaults to 0.2;
+	// negative disables it.
+	Jitter float64
+	// Threshold is how many consecutive transient failures open the
+	// breaker. Defaults to 5; negative disables the breaker.
+	Threshold int
+	// Cooldown is how long an open breaker rejects calls before letting
+	// one through to probe the backend. Defaults to 30s.
+	Cooldown time.Duration
+}
+
+func (c *Config) setDefaults() error {
+	if c.Attempts == 0 {
+		c.Attempts = 3
+	}
+	if c.Backoff == 0 {
+		c.Backoff = 200 * time.Millisecond
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = 5 * time.Second
+	}
+	if c.Jitter == 0 {
+		c.Jitter = 0.2
+	}
+	if c.Threshold == 0 {
+		c.Threshold = 5
+	}
+	if c.Cooldown == 0 {
+		c.Cooldown = 30 * time.Second
+	}
+	switch {
+	case c.Attempts < 0:
+		return fmt.Errorf("resilience: negative attempt count %d", c.Attempts)
+	case c.Backoff < 0 || c.MaxBackoff < 0:
+		return fmt.Errorf("resilience: negative backoff %v", min(c.Backoff, c.MaxBackoff))
+	case c.Jitter > 1:
+		return fmt.Errorf("resilience: jitter %v above 1", c.Jitter)
+	case c.Cooldown < 0:
+		return fmt.Errorf("resilience: negative cooldown %v", c.Cooldown)
+	}
+	return nil
+}
+
+// ParseConfig reads a Config from key=value options, as given on the
+// command line: "attempts", "backoff", "max_backoff", "jitter",
+// "threshold" and "cooldown". Durations use time.ParseDuration syntax.
+func ParseConfig(opts map[string]string) (Config, error) {
+	var c Config
+	for k, v := range opts {
+		var err error
+		switch k {
+		case "attempts":
+			c.Attempts, err = strconv.Atoi(v)
+		case "backoff":
+			c.Backoff, err = time.ParseDuration(v)
+		case "max_backoff":
+			c.MaxBackoff, err = time.ParseDuration(v)
+		case "jitter":
+			c.Jitter, err = strconv.ParseFloat(v, 64)
+		case "threshold":
+			c.Threshold, err = strconv.Atoi(v)
+		case "cooldown":
+			c.Cooldown, err = time.ParseDuration(v)
+		default:
+			return c, fmt.Errorf("resilience: unknown option %q", k)
+		}
+		if err != nil {
+			return c, fmt.Errorf("resilience: bad %s %q", k, v)
+		}
+	}
+	return c, nil
+}
+
+// Policy retries calls to one backend and guards it with a circuit
+// breaker. It is safe for concurrent use; every call to the backend should
+// go through the same Policy so that the breaker sees all of them.
+type Policy struct {
+	cfg Config
+	log logging.Logger
+
+	mu       sync.Mutex
+	failures int       // consecutive transient failures
+	opened   time.Time // when the breaker last opened; zero while closed
+	probing  bool      // a call is probing the backend through the open breaker
+}
+
+// New validates cfg. The policy logs retries and breaker changes to log,
+// which may be nil.
+func New(cfg Config, log logging.Logger) (*Policy, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	return &Policy{cfg: cfg, log: logging.OrNop(log)}, nil
+}
+
+// Do calls fn until it succeeds, fails with an error that is not
+// Transient, or has been tried Config.Attempts times, and returns its last
+// error. It returns ErrOpen without calling fn while the breaker is open,
+// and ctx's error if ctx ends between attempts.
+func (p *Policy) Do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := range p.cfg.Attempts {
+		if attempt > 0 {
+			if p.Open() {
+				return fmt.Errorf("%w after: %w", ErrOpen, err)
+			}
+			p.log.Warn("backend call failed, retrying", "attempt", attempt+1, "error", err)
+			t := time.NewTimer(p.Backoff(attempt))
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			}
+		}
+		if !p.allow() {
+			if err != nil {
+				return fmt.Errorf("%w after: %w", ErrOpen, err)
+			}
+			return ErrOpen
+		}
+		err = fn()
+		p.Observe(err)
+		if err == nil || !Transient(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// Backoff returns how long to wait before the given retry, counting the
+// first retry as 1.
+func (p *Policy) Backoff(retry int) time.Duration {
+	d := p.cfg.Backoff
+	for i := 1; i < retry && d < p.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, p.cfg.MaxBackoff)
+	if p.cfg.Jitter > 0 && d > 0 {
+		d += time.Duration((2*rand.Float64() - 1) * p.cfg.Jitter * float64(d))
+	}
+	return d
+}
+
+// Attempts returns how many times a call is tried in all.
+func (p *Policy) Attempts() int { return p.cfg.Attempts }
+
+// allow reports whether a call may go to the backend. While the breaker
+// is open it lets a single probe through once the cooldown has passed.
+func (p *Policy) allow() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.opened.IsZero() {
+		return true
+	}
+	if p.probing || time.Since(p.opened) < p.cfg.Cooldown {
+		return false
+	}
+	p.probing = true
+	return true
+}
+
+// Observe records the outcome of a call made outside Do, such as the
+// failure of a stream opened through it. Transient failures count towards
+// opening the breaker; any other outcome shows the backend is up, and
+// closes it.
+func (p *Policy) Observe(err error) {
+	if p.cfg.Threshold < 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	wasProbing := p.probing
+	p.probing = false
+	if err == nil || !Transient(err) {
+		if !p.opened.IsZero() {
+			p.log.Info("circuit breaker closed")
+		}
+		p.failures = 0
+		p.opened = time.Time{}
+		return
+	}
+	p.failures++
+	if wasProbing || p.opened.IsZero() && p.failures >= p.cfg.Threshold {
+		p.log.Warn("circuit breaker opened", "failures", p.failures, "cooldown", p.cfg.Cooldown, "error", err)
+		p.opened = time.Now()
+	}
+}
+
+// Open reports whether the breaker is open, rejecting calls.
+func (p *Policy) Open() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.opened.IsZero()
+}
+
+// Transient reports whether err is a failure worth retrying: the backend
+// being unreachable, overloaded or failing on its side, as opposed to
+// rejecting the request or the caller giving up. Errors can opt in by
+// implementing interface{ Transient() bool }.
+func Transient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrOpen) {
+		return false
+	}
+	var t interface{ Transient() bool }
+	if errors.As(err, &t) {
+		return t.Transient()
+	}
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		switch s.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.DeadlineExceeded:
+			return true
+		}
+		return false
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// HTTPError is an unsuccessful response from an HTTP backend.
+type HTTPError struct {
+	// StatusCode is the response status, e.g. 503.
+	StatusCode int
+	// Status is the status line, e.g. "503 Service Unavailable".
+	Status string
+	// Body is the start of the response body, which usually explains the
+	// failure.
+	Body string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return e.Status
+	}
+	return e.Status + ": " + e.Body
+}
+
+// Transient reports whether the status is worth retrying: a server error,
+// a timeout or throttling.
+func (e *HTTPError) Transient() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout
+}
@@ -0,0 +1,180 @@
+// Package retention deletes sessions once they are older than their tenant
+// keeps them, and single sessions on request, everywhere they left a
+// trace: their transcripts and summary in the transcript store, their audio
+// in the archive storage, and their conversation state in the session
+// store.
+//
+// A Purger looks for expired sessions at Config.Interval, by the time they
+// started. Tenants are the names of the API keys sessions were opened
+// with, as the transcript store records them; each can keep its sessions
+// for its own time. Audio is deleted before the transcript that lists it,
+// so a session whose audio could not all be deleted is tried again.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/archive"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/session"
+	"github.com/jmarc101/voxa/internal/store"
+)
+
+// DefaultInterval is the Interval of configs setting none.
+const DefaultInterval = time.Hour
+
+// Config configures a Purger.
+type Config struct {
+	// MaxAge is how long sessions are kept from their start, unless their
+	// tenant has a time of its own. Zero keeps them.
+	MaxAge time.Duration
+	// Tenants are the times the sessions of tenants are kept, by the name
+	// of their API key, zero keeping them.
+	Tenants map[string]time.Duration
+	// Interval is how often expired sessions are looked for. Defaults to
+	// DefaultInterval.
+	Interval time.Duration
+	// OnPurge, if set, is called with every session deleted for its age.
+	OnPurge func(store.Session)
+}
+
+// Stores are where sessions leave traces. Transcripts is required.
+type Stores struct {
+	Transcripts store.TranscriptStore
+	Archive     archive.Storage
+	Sessions    session.Store
+}
+
+// Delete removes session id from the stores: its archived audio, its
+// conversation state, and its transcript with the list of its archives.
+// It returns store.ErrNotFound if the transcript store does not know the
+// session, having still forgotten its conversation state.
+func (s Stores) Delete(ctx context.Context, id string) error {
+	archives, err := s.Transcripts.Archives(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(archives) > 0 && s.Archive == nil {
+		return fmt.Errorf("retention: session %s has archives but no archive storage is configured", id)
+	}
+	var errs []error
+	for _, a := range archives {
+		if err := s.Archive.Delete(ctx, a.Name); err != nil {
+			errs = append(errs, fmt.Errorf("retention: delete archive %s: %w", a.Name, err))
+		}
+	}
+	if s.Sessions != nil {
+		if err := s.Sessions.Delete(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("retention: delete conversation state: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		// The transcript lists the archives left to delete.
+		return errors.Join(errs...)
+	}
+	return s.Transcripts.DeleteSession(ctx, id)
+}
+
+// Purger deletes expired sessions.
+type Purger struct {
+	cfg    Config
+	stores Stores
+	log    logging.Logger
+	oldest time.Duration // shortest time sessions are kept, 0 if none
+}
+
+// New validates cfg.
+func New(cfg Config, stores Stores, log logging.Logger) (*Purger, error) {
+	if stores.Transcripts == nil {
+		return nil, errors.New("retention: no transcript store")
+	}
+	if cfg.MaxAge < 0 || cfg.Interval < 0 {
+		return nil, errors.New("retention: negative duration")
+	}
+	p := &Purger{cfg: cfg, stores: stores, log: logging.OrNop(log), oldest: cfg.MaxAge}
+	for tenant, age := range cfg.Tenants {
+		switch {
+		case tenant == "":
+			return nil, errors.New("retention: empty tenant name")
+		case age < 0:
+			return nil, fmt.Errorf("retention: tenant %s: negative duration", tenant)
+		case age > 0 && (p.oldest == 0 || age < p.oldest):
+			p.oldest = age
+		}
+	}
+	if p.cfg.Interval == 0 {
+		p.cfg.Interval = DefaultInterval
+	}
+	return p, nil
+}
+
+// maxAge returns how long the sessions of tenant are kept, 0 for ever.
+func (p *Purger) maxAge(tenant string) time.Duration {
+	if age, ok := p.cfg.Tenants[tenant]; ok {
+		return age
+	}
+	return p.cfg.MaxAge
+}
+
+// Run purges at every interval until ctx is done, logging failures.
+func (p *Purger) Run(ctx context.Context) {
+	if p.oldest == 0 {
+		return
+	}
+	t := time.NewTicker(p.cfg.Interval)
+	defer t.Stop()
+	for {
+		if n, err := p.Purge(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			p.log.Warn("purging expired sessions failed", "deleted", n, "error", err)
+		} else if n > 0 {
+			p.log.Info("purged expired sessions", "deleted", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// pageSize is how many sessions Purge lists at once.
+const pageSize = 500
+
+// Purge deletes the sessions expired at now and returns how many. A
+// session failing to delete does not stop the others.
+func (p *Purger) Purge(ctx context.Context, now time.Time) (int, error) {
+	if p.oldest == 0 {
+		return 0, nil
+	}
+	var n int
+	var errs []error
+	q := store.Query{Before: now.Add(-p.oldest), Limit: pageSize}
+	for {
+		sessions, err := p.stores.Transcripts.Sessions(ctx, q)
+		if err != nil {
+			return n, errors.Join(append(errs, err)...)
+		}
+		for _, sess := range sessions {
+			age := p.maxAge(sess.Tenant)
+			if age == 0 || now.Sub(sess.Started) < age {
+				continue
+			}
+			switch err := p.stores.Delete(ctx, sess.ID); {
+			case err == nil:
+				n++
+				if p.cfg.OnPurge != nil {
+					p.cfg.OnPurge(sess)
+				}
+			case !errors.Is(err, store.ErrNotFound): // deleted meanwhile
+				errs = append(errs, fmt.Errorf("session %s: %w", sess.ID, err))
+			}
+		}
+		if len(sessions) < q.Limit || ctx.Err() != nil {
+			return n, errors.Join(append(errs, ctx.Err())...)
+		}
+		q.Before = sessions[len(sessions)-1].Started
+	}
+}
@@ -0,0 +1,99 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/archive"
+	"github.com/jmarc101/voxa/internal/session"
+	"github.com/jmarc101/voxa/internal/store"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+func TestPurgeByTenant(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ts, err := store.NewSQLite(filepath.Join(dir, "transcripts.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	audio, err := archive.NewDir(filepath.Join(dir, "audio"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv := session.NewMemory()
+	now := time.Now()
+	day := 24 * time.Hour
+	for _, s := range []struct {
+		id, tenant string
+		age        time.Duration
+	}{
+		{"old", "", 40 * day},
+		{"recent", "", 10 * day},
+		{"acme-old", "acme", 10 * day},
+		{"acme-recent", "acme", 3 * day},
+		{"forever", "keep", 400 * day},
+	} {
+		if err := ts.StartSession(ctx, s.id, s.tenant, now.Add(-s.age)); err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.AddSegment(ctx, s.id, stt.Segment{Text: "hello " + s.id, Final: true}); err != nil {
+			t.Fatal(err)
+		}
+		name := s.id + ".wav"
+		if err := audio.Put(ctx, name, strings.NewReader("RIFF"), 4); err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.AddArchive(ctx, s.id, store.Archive{Name: name, Stream: "1", Part: 1, Started: now.Add(-s.age)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := conv.Set(ctx, s.id, session.Values{"turns": []byte("1")}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := New(Config{MaxAge: 30 * day, Tenants: map[string]time.Duration{"acme": 7 * day, "keep": 0}},
+		Stores{Transcripts: ts, Archive: audio, Sessions: conv}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := p.Purge(ctx, now)
+	if err != nil || n != 2 {
+		t.Fatalf("purged %d sessions (%v), want 2", n, err)
+	}
+	for id, kept := range map[string]bool{"old": false, "recent": true, "acme-old": false, "acme-recent": true, "forever": true} {
+		_, err := ts.Session(ctx, id)
+		if got := err == nil; got != kept {
+			t.Errorf("session %s kept = %v, want %v", id, got, kept)
+		}
+		_, err = os.Stat(filepath.Join(dir, "audio", id+".wav"))
+		if got := err == nil; got != kept {
+			t.Errorf("audio of %s kept = %v, want %v", id, got, kept)
+		}
+		_, err = conv.Get(ctx, id)
+		if got := err == nil; got != kept {
+			t.Errorf("conversation of %s kept = %v, want %v", id, got, kept)
+		}
+	}
+	if hits, err := ts.Search(ctx, store.SearchQuery{Text: "hello"}); err != nil || len(hits) != 3 {
+		t.Errorf("search found %d segments (%v), want those of the 3 sessions left", len(hits), err)
+	}
+
+	// Forgetting a session on request.
+	stores := Stores{Transcripts: ts, Archive: audio, Sessions: conv}
+	if err := stores.Delete(ctx, "forever"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.Session(ctx, "forever"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("deleted session still stored: %v", err)
+	}
+	if err := stores.Delete(ctx, "forever"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("deleting again: got %v, want ErrNotFound", err)
+	}
+}
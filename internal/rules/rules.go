@@ -0,0 +1,149 @@
+// Package rules spots keywords and patterns in transcripts, for alerting
+// on phrases such as "cancel my account" while a call is still live.
+//
+// A Rule matches a transcript when any of its keywords or patterns does.
+// Keywords are phrases matched case-insensitively on whole words, with any
+// spaces or punctuation between their words, so "cancel my account" is
+// spotted in "I want to Cancel, my account." but not in "cancel
+// myaccount". Patterns are RE2 regular expressions, matched
+// case-insensitively anywhere in the transcript.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Rule declares what to spot.
+type Rule struct {
+	// Name identifies the rule in its matches.
+	Name string
+	// Keywords are phrases matched on whole words.
+	Keywords []string
+	// Patterns are regular expressions.
+	Patterns []string
+	// Partials also matches partial transcripts, so an alert goes out as
+	// soon as the phrase is heard rather than at the end of the utterance.
+	Partials bool
+}
+
+// Match is a rule matching a transcript.
+type Match struct {
+	// Rule is the index of the rule in those passed to New.
+	Rule int
+	// Text is the matched text, as transcribed.
+	Text string
+	// Start and End are the byte offsets of Text in the transcript.
+	Start, End int
+}
+
+// Engine matches transcripts against rules. It is safe for concurrent use.
+type Engine struct {
+	rules []rule
+}
+
+type rule struct {
+	partials bool
+	res      []*regexp.Regexp
+	// keyword marks the res of keywords, which match the characters
+	// around the phrase too; the phrase is their group 1.
+	keyword []bool
+}
+
+// New compiles rules. Every rule needs a unique name and something to
+// match.
+func New(rules []Rule) (*Engine, error) {
+	e := &Engine{rules: make([]rule, len(rules))}
+	names := make(map[string]bool, len(rules))
+	for i, r := range rules {
+		switch {
+		case strings.TrimSpace(r.Name) == "":
+			return nil, fmt.Errorf("rules: rule %d has no name", i)
+		case names[r.Name]:
+			return nil, fmt.Errorf("rules: duplicate rule %q", r.Name)
+		case len(r.Keywords) == 0 && len(r.Patterns) == 0:
+			return nil, fmt.Errorf("rules: rule %q has no keyword or pattern", r.Name)
+		}
+		names[r.Name] = true
+		c := &e.rules[i]
+		c.partials = r.Partials
+		for _, k := range r.Keywords {
+			re, err := keyword(k)
+			if err != nil {
+				return nil, fmt.Errorf("rules: rule %q: %w", r.Name, err)
+			}
+			c.res, c.keyword = append(c.res, re), append(c.keyword, true)
+		}
+		for _, p := range r.Patterns {
+			if p == "" {
+				return nil, fmt.Errorf("rules: rule %q: empty pattern", r.Name)
+			}
+			re, err := regexp.Compile("(?i)" + p)
+			if err != nil {
+				return nil, fmt.Errorf("rules: rule %q: bad pattern %q: %w", r.Name, p, err)
+			}
+			c.res, c.keyword = append(c.res, re), append(c.keyword, false)
+		}
+	}
+	return e, nil
+}
+
+// keyword compiles a phrase into a pattern of its words, separated by
+// anything but letters and digits and bounded by the same or the ends of
+// the text. The phrase itself is group 1. RE2 has no lookaround, and its
+// \b only knows ASCII, so the boundaries are matched as characters.
+func keyword(phrase string) (*regexp.Regexp, error) {
+	ws := strings.FieldsFunc(phrase, separator)
+	if len(ws) == 0 {
+		return nil, fmt.Errorf("keyword %q has no words", phrase)
+	}
+	for i, w := range ws {
+		ws[i] = regexp.QuoteMeta(w)
+	}
+	return regexp.Compile(`(?i)(?:^|[^\pL\pN])(` + strings.Join(ws, `[^\pL\pN]+`) + `)(?:[^\pL\pN]|$)`)
+}
+
+func separator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Match returns the first match of every rule in text, in the order of the
+// rules. Rules without Partials are skipped unless final is set.
+func (e *Engine) Match(text string, final bool) []Match {
+	var out []Match
+	for i, r := range e.rules {
+		if !final && !r.partials {
+			continue
+		}
+		if m, ok := r.match(text); ok {
+			m.Rule = i
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// match returns the earliest match of r in text.
+func (r *rule) match(text string) (Match, bool) {
+	best := Match{Start: -1}
+	for i, re := range r.res {
+		loc := re.FindStringSubmatchIndex(text)
+		if loc == nil {
+			continue
+		}
+		start, end := loc[0], loc[1]
+		if r.keyword[i] {
+			start, end = loc[2], loc[3]
+		}
+		if best.Start < 0 || start < best.Start {
+			best.Start, best.End = start, end
+		}
+	}
+	if best.Start < 0 {
+		return Match{}, false
+	}
+	best.Text = text[best.Start:best.End]
+	return best, true
+}
@@ -0,0 +1,184 @@
+package sentiment
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// lexicon holds the sentiment rules of a language.
+type lexicon struct {
+	// valence of words, from -4 to 4.
+	valence map[string]float64
+	// boosters scale the valence of a word one or two words after them.
+	boosters map[string]float64
+	// negators flip the valence of a word up to three words after them.
+	negators map[string]bool
+	// negSuffix, if set, negates words ending with it, as "n't" does.
+	negSuffix string
+	// contrast words shift the weight of an utterance after them.
+	contrast map[string]bool
+}
+
+// The weights of VADER.
+const (
+	negation = -0.74
+	before   = 0.5 // of a contrast
+	after    = 1.5
+	emphasis = 0.292 // per exclamation mark, up to maxEmphasis
+	alpha    = 15    // normalizes sums of valences into (-1, 1)
+
+	maxEmphasis = 4
+)
+
+// score returns the polarity of text, in (-1, 1).
+func (l *lexicon) score(text string) float64 {
+	ws := words(text)
+	vals := make([]float64, len(ws))
+	contrast := -1
+	for i, w := range ws {
+		if l.contrast[w] {
+			contrast = i
+			continue
+		}
+		v, ok := l.valence[w]
+		if !ok {
+			continue
+		}
+		for j := i - 1; j >= 0 && j >= i-3; j-- {
+			if b, ok := l.boosters[ws[j]]; ok && j >= i-2 {
+				v *= b
+			}
+			if l.negated(ws[j]) {
+				v *= negation
+			}
+		}
+		vals[i] = v
+	}
+	var sum float64
+	for i, v := range vals {
+		switch {
+		case contrast < 0:
+		case i < contrast:
+			v *= before
+		default:
+			v *= after
+		}
+		sum += v
+	}
+	if sum != 0 {
+		sum += math.Copysign(emphasis*float64(min(strings.Count(text, "!"), maxEmphasis)), sum)
+	}
+	return sum / math.Sqrt(sum*sum+alpha)
+}
+
+func (l *lexicon) negated(w string) bool {
+	return l.negators[w] || (l.negSuffix != "" && strings.HasSuffix(w, l.negSuffix))
+}
+
+// Languages returns the ISO 639-1 codes of the languages sentiment analysis
+// has a lexicon for.
+func Languages() []string {
+	out := make([]string, 0, len(lexicons))
+	for l := range lexicons {
+		out = append(out, l)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func set(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
+
+var lexicons = map[string]*lexicon{
+	"en": {
+		valence: map[string]float64{
+			"good": 1.9, "great": 3.1, "excellent": 3.2, "amazing": 2.8, "awesome": 3.1,
+			"fantastic": 2.6, "wonderful": 2.7, "perfect": 2.7, "nice": 1.8, "fine": 0.8,
+			"happy": 2.7, "glad": 2, "pleased": 1.9, "love": 3.2, "enjoy": 2.2,
+			"thanks": 1.9, "thank": 1.5, "appreciate": 1.7, "helpful": 1.8, "easy": 1.9,
+			"fast": 1.2, "quick": 1, "best": 3.2, "better": 1.9, "resolved": 1.5,
+			"fixed": 1, "works": 1.2, "working": 0.8, "recommend": 1.5, "satisfied": 1.8,
+			"friendly": 2.2, "polite": 1.5, "clear": 1.2, "sure": 1.3,
+			"yes": 0.8, "okay": 0.9, "ok": 0.9, "brilliant": 2.8, "lovely": 2.8,
+			"bad": -2.5, "terrible": -2.1, "awful": -2, "horrible": -2.5, "worst": -3.1,
+			"worse": -2.1, "poor": -2.1, "hate": -2.7, "angry": -2.3, "upset": -1.6,
+			"annoyed": -1.6, "annoying": -1.7, "frustrated": -2, "frustrating": -1.9,
+			"disappointed": -2.3, "disappointing": -2.2, "unacceptable": -2.7, "ridiculous": -1.5,
+			"useless": -1.8, "broken": -1.7, "wrong": -2.1, "problem": -1.7, "problems": -1.7,
+			"issue": -0.8, "issues": -0.8, "error": -1.4, "fail": -2.4, "failed": -2.3,
+			"slow": -0.9, "late": -0.9, "waiting": -0.6, "wait": -0.4, "cancel": -1,
+			"complaint": -1.5, "complain": -1.5, "rude": -2, "sorry": -0.3, "unfortunately": -1.4,
+			"confused": -1.3, "confusing": -1.2, "difficult": -1.5, "impossible": -1.5,
+			"stupid": -2.4, "sucks": -1.5, "scam": -2.5, "refuse": -1.2,
+			"mess": -1.5, "nightmare": -2.9, "lost": -1.3, "expensive": -0.9,
+		},
+		boosters: map[string]float64{
+			"very": 1.3, "really": 1.3, "so": 1.3, "extremely": 1.5, "incredibly": 1.5,
+			"absolutely": 1.4, "totally": 1.3, "completely": 1.3, "super": 1.3, "too": 1.2,
+			"quite": 1.1, "slightly": 0.7, "somewhat": 0.7, "kinda": 0.7, "barely": 0.6,
+			"little": 0.8, "bit": 0.8, "fairly": 0.8,
+		},
+		negators:  set("not", "no", "never", "neither", "nor", "nothing", "none", "nobody", "without", "hardly", "cannot", "cant", "dont", "wont", "isnt"),
+		negSuffix: "n't",
+		contrast:  set("but", "however", "although", "though"),
+	},
+	"fr": {
+		valence: map[string]float64{
+			"bon": 1.9, "bonne": 1.9, "bien": 1.6, "super": 2.5, "génial": 3, "géniale": 3,
+			"excellent": 3.2, "excellente": 3.2, "parfait": 2.7, "parfaite": 2.7, "formidable": 2.8,
+			"merveilleux": 2.7, "content": 2, "contente": 2, "heureux": 2.7, "heureuse": 2.7,
+			"ravi": 2.4, "ravie": 2.4, "merci": 1.9, "aimable": 2, "gentil": 2.2, "gentille": 2.2,
+			"rapide": 1.2, "facile": 1.9, "meilleur": 3, "mieux": 1.9, "résolu": 1.5,
+			"réglé": 1.5, "satisfait": 1.8, "satisfaite": 1.8, "aime": 2.5, "adore": 3,
+			"recommande": 1.5, "agréable": 2.2, "clair": 1.2, "oui": 0.8, "impeccable": 2.7,
+			"mauvais": -2.5, "mauvaise": -2.5, "mal": -1.8, "nul": -2.3, "nulle": -2.3,
+			"horrible": -2.5, "terrible": -2.1, "pire": -3.1, "déçu": -2.3, "déçue": -2.3,
+			"décevant": -2.2, "inacceptable": -2.7, "ridicule": -1.5, "énervé": -2, "énervée": -2,
+			"fâché": -2.1, "fâchée": -2.1, "furieux": -3, "furieuse": -3, "problème": -1.7,
+			"problèmes": -1.7, "panne": -1.7, "erreur": -1.4, "lent": -0.9, "lente": -0.9,
+			"retard": -1, "attente": -0.6, "annuler": -1, "plainte": -1.5, "impoli": -2,
+			"malheureusement": -1.4, "difficile": -1.5, "impossible": -1.5, "cassé": -1.7,
+			"inutile": -1.8, "arnaque": -2.5, "déteste": -2.7, "cher": -0.9,
+		},
+		boosters: map[string]float64{
+			"très": 1.3, "vraiment": 1.3, "trop": 1.2, "tellement": 1.4, "extrêmement": 1.5,
+			"totalement": 1.3, "complètement": 1.3, "absolument": 1.4,
+			"assez": 0.8, "peu": 0.7, "légèrement": 0.7, "plutôt": 0.9,
+		},
+		negators: set("pas", "jamais", "rien", "aucun", "aucune", "personne", "ni", "sans", "guère"),
+		contrast: set("mais", "cependant", "pourtant", "toutefois"),
+	},
+	"es": {
+		valence: map[string]float64{
+			"bueno": 1.9, "buena": 1.9, "bien": 1.6, "genial": 3, "excelente": 3.2,
+			"perfecto": 2.7, "perfecta": 2.7, "maravilloso": 2.7, "maravillosa": 2.7, "fantástico": 2.6,
+			"contento": 2, "contenta": 2, "feliz": 2.7, "encantado": 2.4, "encantada": 2.4,
+			"gracias": 1.9, "amable": 2.2, "rápido": 1.2, "rápida": 1.2, "fácil": 1.9,
+			"mejor": 1.9, "resuelto": 1.5, "solucionado": 1.5, "satisfecho": 1.8, "satisfecha": 1.8,
+			"encanta": 3, "gusta": 1.8, "recomiendo": 1.5, "agradable": 2.2, "claro": 1.2,
+			"sí": 0.8, "estupendo": 2.8, "estupenda": 2.8,
+			"malo": -2.5, "mala": -2.5, "mal": -1.8, "horrible": -2.5, "terrible": -2.1,
+			"peor": -2.1, "pésimo": -3.1, "pésima": -3.1, "decepcionado": -2.3, "decepcionada": -2.3,
+			"inaceptable": -2.7, "ridículo": -1.5, "enfadado": -2.1, "enfadada": -2.1,
+			"enojado": -2.1, "enojada": -2.1, "molesto": -1.6, "molesta": -1.6, "frustrado": -2,
+			"frustrada": -2, "problema": -1.7, "problemas": -1.7, "error": -1.4, "lento": -0.9,
+			"lenta": -0.9, "retraso": -1, "espera": -0.6, "cancelar": -1, "queja": -1.5,
+			"grosero": -2, "grosera": -2, "desafortunadamente": -1.4, "difícil": -1.5,
+			"imposible": -1.5, "roto": -1.7, "rota": -1.7, "inútil": -1.8, "estafa": -2.5,
+			"odio": -2.7, "caro": -0.9,
+		},
+		boosters: map[string]float64{
+			"muy": 1.3, "realmente": 1.3, "tan": 1.3, "demasiado": 1.2, "extremadamente": 1.5,
+			"súper": 1.3, "totalmente": 1.3, "completamente": 1.3, "absolutamente": 1.4,
+			"bastante": 1.1, "poco": 0.7, "algo": 0.8, "ligeramente": 0.7,
+		},
+		negators: set("no", "nunca", "jamás", "nada", "ningún", "ninguna", "nadie", "ni", "sin", "tampoco"),
+		contrast: set("pero", "aunque", "sino"),
+	},
+}
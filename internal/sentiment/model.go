@@ -0,0 +1,81 @@
+package sentiment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// HTTPModel is a Model served over HTTP, such as a sentiment classifier
+// behind a thin web server. It POSTs
+//
+//	{"language": "en", "text": "thanks, that was quick"}
+//
+// to its endpoint and takes a response with the polarity in [-1, 1]:
+//
+//	{"score": 0.62}
+type HTTPModel struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPModel returns a model served at endpoint, an http or https URL.
+// A nil client selects http.DefaultClient; calls are bounded by
+// Config.Timeout.
+func NewHTTPModel(endpoint string, client *http.Client) (*HTTPModel, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("sentiment: model %q is not an http or https URL", endpoint)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPModel{endpoint: endpoint, client: client}, nil
+}
+
+type modelRequest struct {
+	Language string `json:"language"`
+	Text     string `json:"text"`
+}
+
+type modelResponse struct {
+	Score *float64 `json:"score"`
+}
+
+// Score implements Model.
+func (m *HTTPModel) Score(ctx context.Context, lang, text string) (float64, error) {
+	body, err := json.Marshal(modelRequest{Language: lang, Text: text})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(msg))}
+	}
+	var out modelResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+	if out.Score == nil {
+		return 0, errors.New("decode response: no score")
+	}
+	return *out.Score, nil
+}
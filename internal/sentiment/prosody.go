@@ -0,0 +1,174 @@
+package sentiment
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/dsp"
+)
+
+// Prosody describes how an utterance was spoken.
+type Prosody struct {
+	// Voiced is how much of the utterance was voiced speech.
+	Voiced time.Duration
+	// Loudness is the mean level of the voiced speech, in dBFS.
+	Loudness float64
+	// Pitch is the mean fundamental frequency of the voiced speech, in Hz,
+	// and PitchRange its standard deviation in semitones.
+	Pitch, PitchRange float64
+	// Arousal is how activated the voice sounds compared with the earlier
+	// utterances of the stream, in (0, 1); 0.5 is as usual.
+	Arousal float64
+}
+
+// pitchWindow is the span pitch is estimated over, as the diarizer does.
+const pitchWindow = 40 * time.Millisecond
+
+// minVoiced is the least voiced speech an utterance is measured from.
+const minVoiced = 300 * time.Millisecond
+
+// The scales of loudness, pitch and pitch range over which arousal moves
+// by one logit, and their baseline before the stream's first utterance.
+const (
+	loudnessScale   = 6 // dB
+	pitchScale      = 3 // semitones
+	rangeScale      = 1.5
+	defaultLoudness = -26.0
+	defaultRange    = 2.0
+	// baselineWeight is how much each utterance moves the baseline.
+	baselineWeight = 0.2
+)
+
+// Tracker is the prosody stage of one stream. It watches the speech frames
+// on their way to the recognizer, like the diarizer, and measures every
+// utterance the pipeline marks with Cut. Process and Cut are called from
+// the audio path; Take may be called concurrently.
+type Tracker struct {
+	rate int
+	buf  []float64
+	tail []float64
+	pass [1]audio.Frame
+
+	n      int // voiced frames of the current utterance
+	voiced time.Duration
+	level  float64 // sums over the voiced frames
+	logf   float64
+	logf2  float64
+	base   *Prosody // baseline of the stream's earlier utterances
+
+	mu      sync.Mutex
+	current *Prosody  // the current utterance so far
+	closed  []Prosody // cut utterances not yet taken
+}
+
+// NewTracker creates a tracker for mono audio at sampleRate.
+func NewTracker(sampleRate int) (*Tracker, error) {
+	if sampleRate <= 0 {
+		return nil, errors.New("sentiment: sample rate must be positive")
+	}
+	return &Tracker{rate: sampleRate}, nil
+}
+
+// Process measures fr and passes it through unchanged.
+func (t *Tracker) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if fr.Format.Channels != 1 {
+		return nil, fmt.Errorf("sentiment: need mono audio, got %d channels", fr.Format.Channels)
+	}
+	t.buf = dsp.Float(t.buf, fr.Data)
+	t.tail = append(t.tail, t.buf...)
+	if n := int(int64(t.rate) * int64(pitchWindow) / int64(time.Second)); len(t.tail) >= n {
+		t.tail = t.tail[len(t.tail)-n:]
+		if f0, ok := dsp.Pitch(t.tail, t.rate); ok {
+			semis := 12 * math.Log2(f0)
+			t.n++
+			t.voiced += fr.Duration()
+			t.level += dsp.DBFS(dsp.RMS(t.buf))
+			t.logf += semis
+			t.logf2 += semis * semis
+			if t.voiced >= minVoiced {
+				p := t.measure()
+				t.mu.Lock()
+				t.current = &p
+				t.mu.Unlock()
+			}
+		}
+	}
+	t.pass[0] = fr
+	return t.pass[:], nil
+}
+
+// Cut marks the end of an utterance, which becomes the baseline of the
+// next ones. Utterances with too little voiced speech are ignored, so Cut
+// may be called redundantly.
+func (t *Tracker) Cut() {
+	t.tail = t.tail[:0]
+	if t.voiced < minVoiced {
+		t.reset()
+		return
+	}
+	p := t.measure()
+	if t.base == nil {
+		b := p
+		t.base = &b
+	} else {
+		t.base.Loudness += baselineWeight * (p.Loudness - t.base.Loudness)
+		t.base.Pitch *= math.Pow(p.Pitch/t.base.Pitch, baselineWeight)
+		t.base.PitchRange += baselineWeight * (p.PitchRange - t.base.PitchRange)
+	}
+	t.reset()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = append(t.closed, p)
+	t.current = nil
+}
+
+func (t *Tracker) reset() {
+	t.n, t.voiced, t.level, t.logf, t.logf2 = 0, 0, 0, 0, 0
+}
+
+// Take returns the prosody of the oldest utterance whose final segment has
+// not been analyzed yet and forgets it. If every cut utterance has been
+// taken it returns the current one without consuming anything. It reports
+// false when there is neither.
+func (t *Tracker) Take() (Prosody, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.closed) > 0 {
+		p := t.closed[0]
+		t.closed = t.closed[1:]
+		return p, true
+	}
+	if t.current != nil {
+		return *t.current, true
+	}
+	return Prosody{}, false
+}
+
+// measure returns the prosody of the current utterance so far.
+func (t *Tracker) measure() Prosody {
+	n := float64(t.n)
+	mean := t.logf / n
+	p := Prosody{
+		Voiced:     t.voiced,
+		Loudness:   t.level / n,
+		Pitch:      math.Exp2(mean / 12),
+		PitchRange: math.Sqrt(max(t.logf2/n-mean*mean, 0)),
+	}
+	var logit float64
+	if t.base == nil {
+		// The first utterance only has the voice of people at large to go
+		// by: its pitch is compared with nothing.
+		logit = (p.Loudness-defaultLoudness)/loudnessScale + (p.PitchRange-defaultRange)/rangeScale
+	} else {
+		logit = (p.Loudness-t.base.Loudness)/loudnessScale +
+			12*math.Log2(p.Pitch/t.base.Pitch)/pitchScale +
+			(p.PitchRange-t.base.PitchRange)/rangeScale
+	}
+	p.Arousal = 1 / (1 + math.Exp(-logit))
+	return p
+}
@@ -0,0 +1,215 @@
+// Package sentiment tags final transcript segments with their sentiment,
+// and optionally the emotion the voice carries, for call-quality analytics.
+//
+// An Analyzer scores the polarity of a segment's text from -1, negative, to
+// 1, positive. Its rules look words up in a valence lexicon of the
+// language, in the manner of VADER: words before them such as "very" or
+// "slightly" scale them, negations such as "not" flip and soften them, and
+// after a contrast such as "but" words count more than before it. An
+// optional Model, such as a text classifier served over HTTP, scores
+// segments instead, in any language it knows; the rules take over whenever
+// it fails.
+//
+// With prosody, a Tracker per stream measures the loudness and pitch of
+// every utterance against the speaker's earlier ones. How aroused the voice
+// sounds, together with the polarity of the words, places the utterance on
+// the circumplex of emotions: aroused and negative is "angry", aroused and
+// positive "happy", subdued and negative "sad", subdued and positive
+// "calm".
+package sentiment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jmarc101/voxa/internal/langtag"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Defaults.
+const (
+	defaultLanguage = "en"
+	defaultTimeout  = time.Second
+	// neutral bounds the scores labelled neutral, as VADER does.
+	neutral = 0.05
+	// aroused and subdued bound the arousal of emotional voices.
+	aroused = 0.65
+	subdued = 0.35
+)
+
+// Emotions.
+const (
+	Neutral = "neutral"
+	Angry   = "angry"
+	Happy   = "happy"
+	Sad     = "sad"
+	Calm    = "calm"
+)
+
+// Model scores the sentiment of a transcript.
+type Model interface {
+	// Score returns the polarity of text spoken in lang, an ISO 639-1
+	// code, in [-1, 1].
+	Score(ctx context.Context, lang, text string) (float64, error)
+}
+
+// Config configures an Analyzer.
+type Config struct {
+	// Language is the ISO 639-1 code of segments that report no language.
+	// Defaults to "en".
+	Language string
+	// Languages are analyzed; segments in others pass through untagged.
+	// Defaults to every language with a lexicon, see Languages. With a
+	// Model, languages without one may be listed.
+	Languages []string
+	// Model, if set, scores segments instead of the lexicon.
+	Model Model
+	// Timeout bounds a call to the model. Defaults to a second.
+	Timeout time.Duration
+	// Prosody also analyzes the audio of every utterance, for the arousal
+	// and emotion of segments. The pipeline reads it and runs a Tracker
+	// per stream.
+	Prosody bool
+	// Logger receives model failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// Analyzer tags segments with their sentiment. It is safe for concurrent
+// use.
+type Analyzer struct {
+	cfg   Config
+	log   logging.Logger
+	langs map[string]*lexicon // nil for a language only the model knows
+}
+
+// New validates cfg.
+func New(cfg Config) (*Analyzer, error) {
+	if cfg.Timeout < 0 {
+		return nil, fmt.Errorf("sentiment: negative timeout %v", cfg.Timeout)
+	}
+	if cfg.Language == "" {
+		cfg.Language = defaultLanguage
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	cfg.Language = langtag.Base(cfg.Language)
+	if len(cfg.Languages) == 0 {
+		cfg.Languages = Languages()
+	}
+	a := &Analyzer{cfg: cfg, log: logging.OrNop(cfg.Logger), langs: map[string]*lexicon{}}
+	for _, l := range cfg.Languages {
+		l = langtag.Base(l)
+		if l == "" {
+			return nil, errors.New("sentiment: empty language")
+		}
+		lex := lexicons[l]
+		if lex == nil && cfg.Model == nil {
+			return nil, fmt.Errorf("sentiment: no lexicon for language %q (have %v) and no model", l, Languages())
+		}
+		a.langs[l] = lex
+	}
+	return a, nil
+}
+
+// Prosody reports whether the analyzer wants the prosody of utterances.
+func (a *Analyzer) Prosody() bool { return a.cfg.Prosody }
+
+// Analyze sets the sentiment of a final segment, taking in p, the prosody
+// of its utterance, if known. Segments in languages not analyzed, and
+// those without words, are left untagged.
+func (a *Analyzer) Analyze(ctx context.Context, seg *stt.Segment, p *Prosody) {
+	lang := a.cfg.Language
+	if seg.Language != "" {
+		lang = langtag.Base(seg.Language)
+	}
+	lex, ok := a.langs[lang]
+	if !ok || strings.TrimSpace(seg.Text) == "" {
+		return
+	}
+	score, ok := 0.0, false
+	if a.cfg.Model != nil {
+		score, ok = a.predict(ctx, lang, seg.Text)
+	}
+	if !ok {
+		if lex == nil {
+			return
+		}
+		score = lex.score(seg.Text)
+	}
+	s := &stt.Sentiment{Label: label(score), Score: float32(score)}
+	if p != nil {
+		s.Arousal = float32(p.Arousal)
+		s.Emotion = emotion(score, p.Arousal)
+	}
+	seg.Sentiment = s
+}
+
+// predict asks the model, reporting false if it fails.
+func (a *Analyzer) predict(ctx context.Context, lang, text string) (float64, bool) {
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	defer cancel()
+	score, err := a.cfg.Model.Score(ctx, lang, text)
+	if err == nil && (math.IsNaN(score) || score < -1 || score > 1) {
+		err = fmt.Errorf("score %v out of [-1, 1]", score)
+	}
+	if err != nil {
+		a.log.Warn("sentiment model failed, applying the lexicon", "language", lang, "error", err)
+		return 0, false
+	}
+	return score, true
+}
+
+func label(score float64) string {
+	switch {
+	case score >= neutral:
+		return stt.SentimentPositive
+	case score <= -neutral:
+		return stt.SentimentNegative
+	}
+	return stt.SentimentNeutral
+}
+
+// emotion places a polarity and an arousal on the circumplex.
+func emotion(score, arousal float64) string {
+	switch {
+	case arousal >= aroused && score <= -neutral:
+		return Angry
+	case arousal >= aroused && score >= neutral:
+		return Happy
+	case arousal <= subdued && score <= -neutral:
+		return Sad
+	case arousal <= subdued && score >= neutral:
+		return Calm
+	}
+	return Neutral
+}
+
+// words splits text into lowercase words, without the punctuation around
+// them. Elided articles and pronouns are split off, so the French "n'est"
+// is "n'" and "est".
+func words(text string) []string {
+	var out []string
+	for _, f := range strings.Fields(strings.ToLower(text)) {
+		f = strings.TrimFunc(f, func(c rune) bool { return unicode.IsPunct(c) && c != '\'' })
+		f = strings.Trim(f, "'")
+		for {
+			i := strings.IndexByte(f, '\'')
+			if i <= 0 || i > 2 {
+				break
+			}
+			out = append(out, f[:i+1])
+			f = f[i+1:]
+		}
+		if f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
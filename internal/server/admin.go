@@ -0,0 +1,128 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/auth"
+	"github.com/jmarc101/voxa/internal/cost"
+)
+
+// AdminHandler serves the admin API as JSON, to the admin keys of a:
+//
+//	GET    /v1/admin/usage            → WireUsageList
+//	GET    /v1/admin/sessions         → WireActiveSessions
+//	GET    /v1/admin/sessions/{id}    → WireActiveSession, or 404
+//	DELETE /v1/admin/sessions/{id}    terminates a session: 204, or 404
+//	GET    /v1/admin/costs[?key=]     → WireCosts, of one key with key; 404
+//	                                    unless the server bills usage
+//
+// Mount it on /v1/admin/.
+func (s *Server) AdminHandler(a *auth.Authenticator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/admin/usage", func(w http.ResponseWriter, r *http.Request) {
+		list := WireUsageList{Keys: []WireUsage{}}
+		for _, u := range a.Usage() {
+			list.Keys = append(list.Keys, WireUsage{
+				Key:             u.Key,
+				Admin:           u.Admin,
+				Requests:        u.Requests,
+				Throttled:       u.Throttled,
+				Sessions:        u.Sessions,
+				SessionsTotal:   u.SessionsTotal,
+				SessionsRefused: u.SessionsRefused,
+				SessionSeconds:  u.SessionSeconds,
+				Rate:            u.Rate,
+				Burst:           u.Burst,
+				MaxSessions:     u.MaxSessions,
+			})
+		}
+		writeJSON(w, list)
+	})
+	mux.HandleFunc("GET /v1/admin/sessions", func(w http.ResponseWriter, r *http.Request) {
+		list := WireActiveSessions{Sessions: []WireActiveSession{}}
+		for _, sess := range s.sessions.List() {
+			list.Sessions = append(list.Sessions, s.wireActiveSession(sess))
+		}
+		writeJSON(w, list)
+	})
+	mux.HandleFunc("GET /v1/admin/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v1/admin/sessions/")
+		sess, ok := s.sessions.Get(id)
+		if !ok {
+			http.Error(w, "no active session "+strconv.Quote(id), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, s.wireActiveSession(sess))
+	})
+	mux.HandleFunc("DELETE /v1/admin/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v1/admin/sessions/")
+		if id == "" || !s.sessions.Cancel(id) {
+			http.Error(w, "no active session "+strconv.Quote(id), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /v1/admin/costs", func(w http.ResponseWriter, r *http.Request) {
+		l := s.sessions.ledger
+		if l == nil {
+			http.Error(w, "usage is not billed", http.StatusNotFound)
+			return
+		}
+		key := r.URL.Query().Get("key")
+		costs := WireCosts{Currency: l.Currency(), Keys: []WireAccount{}, Sessions: []WireAccount{}}
+		for _, a := range l.Keys() {
+			if key == "" || a.Key == key {
+				costs.Keys = append(costs.Keys, wireAccount(a))
+			}
+		}
+		for _, a := range l.Sessions(key) {
+			costs.Sessions = append(costs.Sessions, wireAccount(a))
+		}
+		writeJSON(w, costs)
+	})
+	return a.AdminHTTP(mux)
+}
+
+func wireAccount(a cost.Account) WireAccount {
+	wa := WireAccount{Key: a.Key, SessionID: a.Session, Cost: a.Cost, Providers: []WireCharge{}}
+	if !a.Ended.IsZero() {
+		wa.Ended = &a.Ended
+	}
+	for _, c := range a.Charges {
+		wa.Providers = append(wa.Providers, WireCharge{
+			Provider:     c.Provider,
+			AudioSeconds: c.Audio.Seconds(),
+			Characters:   c.Characters,
+			Cost:         c.Cost,
+		})
+	}
+	return wa
+}
+
+func (s *Server) wireActiveSession(sess Session) WireActiveSession {
+	ws := WireActiveSession{
+		SessionID:  sess.ID,
+		Kind:       string(sess.Kind),
+		Transport:  sess.Transport,
+		Peer:       sess.Peer,
+		Started:    sess.Started,
+		DurationMS: time.Since(sess.Started).Milliseconds(),
+		Key:        sess.Key,
+	}
+	if sess.Stream != nil {
+		st := sess.Stream.Stats()
+		ws.Provider, ws.AudioMS, ws.Segments = st.Provider, st.Audio.Milliseconds(), st.Segments
+		if st.Latency >= 0 {
+			ms := st.Latency.Milliseconds()
+			ws.LatencyMS = &ms
+		}
+	}
+	if l := s.sessions.ledger; l != nil {
+		_, c := l.Price(sess.meter.Usage())
+		ws.Cost = &c
+	}
+	return ws
+}
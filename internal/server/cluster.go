@@ -0,0 +1,302 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/auth"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/session"
+)
+
+// Cluster defaults.
+const (
+	defaultLeaseTTL     = 15 * time.Second
+	defaultResumeWindow = 5 * time.Minute
+)
+
+// RouteHeader carries the routing token of a node: in the started event,
+// as gRPC header metadata and as an HTTP response header of the WebSocket
+// handshake. Clients send it back when reconnecting, as metadata or a
+// header of the same name, for the load balancer to route on.
+const RouteHeader = "voxa-route"
+
+// resumeKey is the stored value holding the resume point of a session, in
+// milliseconds, and ownerKey the one naming the API key it was opened with.
+const (
+	resumeKey = "resume_ms"
+	ownerKey  = "key"
+)
+
+// ErrNotOwner refuses to resume a session opened with another API key.
+var ErrNotOwner = errors.New("session was opened with another API key")
+
+// ClusterConfig configures a Cluster.
+type ClusterConfig struct {
+	// Node names this node uniquely in the cluster, and is the routing
+	// token given to its clients.
+	Node string
+	// Leases record which node runs each live session.
+	Leases session.Leases
+	// Store keeps the resume point of every live session.
+	Store session.Store
+	// LeaseTTL is how long a node may fail to renew its leases before its
+	// sessions are taken over. Defaults to 15 seconds.
+	LeaseTTL time.Duration
+	// ResumeWindow is how long a session that was cut off stays
+	// resumable. Defaults to 5 minutes.
+	ResumeWindow time.Duration
+	// Logger receives lease failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// Cluster lets several voxad nodes behind a load balancer share the
+// transcription sessions of gRPC and WebSocket clients, through a store
+// such as Redis:
+//
+//   - A session started on a node is leased to it, and the lease renewed
+//     while it runs. Its started event carries the node's routing token,
+//     which the client sends back as voxa-route when it reconnects, so a
+//     load balancer hashing on it picks the same node.
+//   - A session whose ID is leased to another node is refused with that
+//     node's token: codes.Unavailable with a voxa-route trailer over gRPC,
+//     an error event with the route over WebSocket.
+//   - As a session runs, the end of its last final segment is saved as its
+//     resume point. When its node dies or drains mid-session, the client
+//     reconnects with the same session ID to any node, which takes the
+//     session over once the lease is free: the stream's timeline resumes
+//     at the point given in the started event, and the client resends its
+//     audio from there. Only the API key that opened the session, or an
+//     admin key, may resume it.
+//
+// A session the client ends is forgotten. Twilio, RTP and WebRTC calls
+// are bound to their connection and stay on their node.
+type Cluster struct {
+	cfg ClusterConfig
+	log logging.Logger
+}
+
+// NewCluster validates cfg.
+func NewCluster(cfg ClusterConfig) (*Cluster, error) {
+	switch {
+	case cfg.Node == "":
+		return nil, errors.New("server: cluster node has no name")
+	case cfg.Leases == nil || cfg.Store == nil:
+		return nil, errors.New("server: cluster has no store")
+	case cfg.LeaseTTL < 0:
+		return nil, fmt.Errorf("server: negative lease TTL %v", cfg.LeaseTTL)
+	case cfg.ResumeWindow < 0:
+		return nil, fmt.Errorf("server: negative resume window %v", cfg.ResumeWindow)
+	}
+	if cfg.LeaseTTL == 0 {
+		cfg.LeaseTTL = defaultLeaseTTL
+	}
+	if cfg.ResumeWindow == 0 {
+		cfg.ResumeWindow = defaultResumeWindow
+	}
+	return &Cluster{cfg: cfg, log: logging.With(logging.OrNop(cfg.Logger), "node", cfg.Node)}, nil
+}
+
+// Join makes s a node of c. It must be called before s serves.
+func (s *Server) Join(c *Cluster) { s.cluster = c }
+
+// MovedError refuses a session that is live on another node.
+type MovedError struct {
+	Session string
+	// Node is the routing token of the node running the session.
+	Node string
+}
+
+func (e *MovedError) Error() string {
+	return fmt.Sprintf("session %q is live on node %q", e.Session, e.Node)
+}
+
+// route returns the routing token of this node, empty outside a cluster.
+func (c *Cluster) route() string {
+	if c == nil {
+		return ""
+	}
+	return c.cfg.Node
+}
+
+// claim leases session id to this node and loads its resume point. It
+// fails with a *MovedError if another node runs the session, and with
+// ErrNotOwner if it was opened with another API key than that of ctx, unless
+// that is an admin key. lost is called
+// if the lease is taken by another node while the session runs. Outside a
+// cluster it returns a nil claim, which does nothing.
+func (c *Cluster) claim(ctx context.Context, id string, lost func()) (*claim, error) {
+	if c == nil {
+		return nil, nil
+	}
+	holder, err := c.cfg.Leases.Acquire(ctx, id, c.cfg.Node, c.cfg.LeaseTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: lease session: %w", err)
+	}
+	if holder != c.cfg.Node {
+		return nil, &MovedError{Session: id, Node: holder}
+	}
+	cl := &claim{c: c, id: id, log: logging.With(c.log, "session", id), lost: lost,
+		wake: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	k := auth.FromContext(ctx)
+	if k != nil {
+		cl.owner = k.Name()
+	}
+	v, err := c.cfg.Store.Get(ctx, id)
+	switch {
+	case err == nil:
+		var owner string
+		if raw, ok := v[ownerKey]; ok && json.Unmarshal(raw, &owner) == nil && owner != cl.owner {
+			if k == nil || !k.Admin() {
+				_ = c.cfg.Leases.Release(context.WithoutCancel(ctx), id, c.cfg.Node)
+				return nil, ErrNotOwner
+			}
+			cl.owner = owner
+		}
+		var ms int64
+		if raw, ok := v[resumeKey]; ok && json.Unmarshal(raw, &ms) == nil && ms > 0 {
+			cl.resume = time.Duration(ms) * time.Millisecond
+			cl.log.Info("session resumed", "resume", cl.resume)
+		}
+	case !errors.Is(err, session.ErrNotFound):
+		_ = c.cfg.Leases.Release(context.WithoutCancel(ctx), id, c.cfg.Node)
+		return nil, fmt.Errorf("cluster: load session: %w", err)
+	}
+	cl.end, cl.saved = cl.resume, cl.resume
+	go cl.run()
+	return cl, nil
+}
+
+// claim is the lease of this node on one live session.
+type claim struct {
+	c      *Cluster
+	id     string
+	owner  string // the API key of the session
+	log    logging.Logger
+	resume time.Duration // where the session resumed
+	lost   func()
+	wake   chan struct{} // signalled when end moves
+	stop   chan struct{}
+	done   chan struct{}
+	// Owned by run, then by release.
+	saved     time.Duration // resume point last stored
+	takenOver bool
+
+	mu  sync.Mutex
+	end time.Duration // of the last final segment
+}
+
+// offset returns where the stream of the session starts in session time.
+func (cl *claim) offset() time.Duration {
+	if cl == nil {
+		return 0
+	}
+	return cl.resume
+}
+
+// final records the delivery of seg, moving the resume point past it if
+// it is final.
+func (cl *claim) final(seg voxa.Segment) {
+	if cl == nil || !seg.Final {
+		return
+	}
+	cl.mu.Lock()
+	moved := seg.End > cl.end
+	if moved {
+		cl.end = seg.End
+	}
+	cl.mu.Unlock()
+	if moved {
+		select {
+		case cl.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run renews the lease and saves the resume point as it moves, until
+// release.
+func (cl *claim) run() {
+	defer close(cl.done)
+	t := time.NewTicker(cl.c.cfg.LeaseTTL / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			err := cl.c.cfg.Leases.Renew(context.Background(), cl.id, cl.c.cfg.Node, cl.c.cfg.LeaseTTL)
+			if errors.Is(err, session.ErrLeaseLost) {
+				cl.log.Warn("session taken over by another node")
+				cl.takenOver = true
+				cl.lost()
+				return
+			}
+			if err != nil {
+				// Retried on the next tick, until the lease expires.
+				cl.log.Warn("renew session lease", "error", err)
+			}
+		case <-cl.wake:
+			cl.save(false)
+		case <-cl.stop:
+			return
+		}
+	}
+}
+
+// save stores the resume point if it moved since it was last stored, or
+// regardless with force, restarting the resume window.
+func (cl *claim) save(force bool) {
+	cl.mu.Lock()
+	end := cl.end
+	cl.mu.Unlock()
+	if end == 0 || (end == cl.saved && !force) {
+		return
+	}
+	raw, _ := json.Marshal(end.Milliseconds())
+	owner, _ := json.Marshal(cl.owner)
+	v := session.Values{resumeKey: raw, ownerKey: owner}
+	if err := cl.c.cfg.Store.Set(context.Background(), cl.id, v, cl.c.cfg.ResumeWindow); err != nil {
+		cl.log.Warn("save resume point", "error", err)
+		return
+	}
+	cl.saved = end
+}
+
+// release gives up the lease once the session has ended, keeping its
+// resume point if it is resumable and forgetting it otherwise. A session
+// taken over by another node is left to it.
+func (cl *claim) release(resumable bool) {
+	if cl == nil {
+		return
+	}
+	close(cl.stop)
+	<-cl.done
+	if cl.takenOver {
+		return
+	}
+	ctx := context.Background()
+	if resumable {
+		cl.save(true)
+	} else if err := cl.c.cfg.Store.Delete(ctx, cl.id); err != nil {
+		cl.log.Warn("forget resume point", "error", err)
+	}
+	if err := cl.c.cfg.Leases.Release(ctx, cl.id, cl.c.cfg.Node); err != nil {
+		cl.log.Warn("release session lease", "error", err)
+	}
+}
+
+// cutOff reports whether the input of session sess stopped with err before
+// its client ended it: its connection broke or failed, or the session was
+// terminated or stopped by a drain. Such a session stays resumable.
+func cutOff(ctx context.Context, sess *Session, err error) bool {
+	select {
+	case <-sess.Stopping():
+		return true
+	default:
+		return err != nil || ctx.Err() != nil
+	}
+}
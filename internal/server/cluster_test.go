@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/auth"
+	"github.com/jmarc101/voxa/internal/session"
+)
+
+func newCluster(t *testing.T, node string, mem *session.Memory) *Cluster {
+	t.Helper()
+	c, err := NewCluster(ClusterConfig{Node: node, Leases: mem, Store: mem, LeaseTTL: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClaim(t *testing.T) {
+	mem := session.NewMemory()
+	n1, n2 := newCluster(t, "n1", mem), newCluster(t, "n2", mem)
+	authn := newAuthenticator(t, "a", "b")
+	as := func(key string) context.Context {
+		return auth.NewContext(context.Background(), authn.Authenticate(key))
+	}
+	ignore := func() {}
+
+	cl, err := n1.claim(as("a"), "s1", ignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl.offset() != 0 {
+		t.Errorf("new session resumes at %v", cl.offset())
+	}
+	var moved *MovedError
+	if _, err := n2.claim(as("a"), "s1", ignore); !errors.As(err, &moved) || moved.Node != "n1" {
+		t.Fatalf("claim of a session live on n1: %v, want it moved to n1", err)
+	}
+	cl.final(voxa.Segment{Final: true, End: 1500 * time.Millisecond})
+	cl.final(voxa.Segment{End: 9 * time.Second}) // a partial
+	cl.release(true)
+
+	for _, tc := range []struct {
+		key  string
+		want error
+	}{
+		{"b", ErrNotOwner},
+		{"a", nil},
+		{"admin", nil},
+	} {
+		cl, err := n2.claim(as(tc.key), "s1", ignore)
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%s resuming the session of a: %v, want %v", tc.key, err, tc.want)
+		}
+		if err != nil {
+			continue
+		}
+		if cl.offset() != 1500*time.Millisecond {
+			t.Errorf("%s resumed at %v, want 1.5s", tc.key, cl.offset())
+		}
+		cl.release(true)
+	}
+	// The owner is kept when an admin key resumes the session.
+	if _, err := n1.claim(as("b"), "s1", ignore); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("b resuming after admin: %v, want %v", err, ErrNotOwner)
+	}
+
+	cl, err = n1.claim(as("a"), "s1", ignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.release(false) // ended by the client
+	if cl, err = n2.claim(as("b"), "s1", ignore); err != nil || cl.offset() != 0 {
+		t.Fatalf("claim of an ended session: %v at %v, want a new session", err, cl.offset())
+	}
+	cl.release(false)
+	if _, err := mem.Get(context.Background(), "s1"); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("ended session still stored: %v", err)
+	}
+
+	var none *Cluster
+	if cl, err := none.claim(as("a"), "s1", ignore); cl != nil || err != nil || cl.offset() != 0 {
+		t.Errorf("claim outside a cluster: %v, %v", cl, err)
+	}
+}
+
+func TestClaimLost(t *testing.T) {
+	mem := session.NewMemory()
+	n1 := newCluster(t, "n1", mem)
+	lost := make(chan struct{})
+	cl, err := n1.claim(context.Background(), "s1", func() { close(lost) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.final(voxa.Segment{Final: true, End: time.Second})
+	ctx := context.Background()
+	for _, err := mem.Get(ctx, "s1"); err != nil; _, err = mem.Get(ctx, "s1") {
+		time.Sleep(time.Millisecond) // saved in the background
+	}
+
+	// n2 takes the lease over, such as after it expired.
+	if err := mem.Release(ctx, "s1", "n1"); err != nil {
+		t.Fatal(err)
+	}
+	if holder, err := mem.Acquire(ctx, "s1", "n2", time.Minute); err != nil || holder != "n2" {
+		t.Fatalf("n2 taking the lease: %q, %v", holder, err)
+	}
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("lease lost unnoticed")
+	}
+	cl.release(false)
+	if holder, _ := mem.Acquire(ctx, "s1", "n3", time.Minute); holder != "n2" {
+		t.Errorf("lease of n2 released by n1: held by %q", holder)
+	}
+	if _, err := mem.Get(ctx, "s1"); err != nil {
+		t.Errorf("resume point of the session n2 took over forgotten: %v", err)
+	}
+}
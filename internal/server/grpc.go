@@ -0,0 +1,499 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jmarc101/voxa"
+	speechv1 "github.com/jmarc101/voxa/api/gen/voxa/speech/v1"
+	voxadv1 "github.com/jmarc101/voxa/api/gen/voxa/voxad/v1"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/vad"
+	"github.com/jmarc101/voxa/internal/auth"
+	"github.com/jmarc101/voxa/internal/export"
+)
+
+type (
+	transcribeStream = grpc.BidiStreamingServer[voxadv1.TranscribeRequest, voxadv1.TranscribeResponse]
+	synthesizeStream = grpc.BidiStreamingServer[voxadv1.SynthesizeRequest, voxadv1.SynthesizeResponse]
+)
+
+// Server implements the voxad gRPC service on top of a shared pipeline.
+type Server struct {
+	voxadv1.UnimplementedVoxadServer
+
+	mu       sync.Mutex
+	cur      *generation // see Reload
+	sessions *Sessions
+	cluster  *Cluster // see Join
+}
+
+// New creates a Server running sessions on p and tts, or on the profiles
+// they select. tts may be nil, in which case Synthesize reports
+// codes.Unavailable.
+func New(p *voxa.Pipeline, tts voxa.Synthesizer, profiles map[string]Profile) *Server {
+	return &Server{cur: newGeneration(p, tts, profiles), sessions: NewSessions()}
+}
+
+// Sessions returns the active session table.
+func (s *Server) Sessions() *Sessions { return s.sessions }
+
+// Register adds the service to a gRPC server.
+func (s *Server) Register(g *grpc.Server) {
+	voxadv1.RegisterVoxadServer(g, s)
+}
+
+// priorities maps the priorities of TranscribeConfig to the pipeline's.
+var priorities = map[voxadv1.Priority]voxa.Priority{
+	voxadv1.Priority_PRIORITY_UNSPECIFIED: voxa.PriorityNormal,
+	voxadv1.Priority_PRIORITY_INTERACTIVE: voxa.PriorityInteractive,
+	voxadv1.Priority_PRIORITY_BATCH:       voxa.PriorityBatch,
+}
+
+// endpointingConfig returns the endpointing of pb, nil if unset.
+func endpointingConfig(pb *voxadv1.Endpointing) *voxa.EndpointingConfig {
+	if pb == nil {
+		return nil
+	}
+	return &voxa.EndpointingConfig{
+		MinSilence:    pb.GetMinSilence().AsDuration(),
+		FinishSilence: pb.GetFinishSilence().AsDuration(),
+		MaxUtterance:  pb.GetMaxUtterance().AsDuration(),
+	}
+}
+
+// Transcribe implements voxadv1.VoxadServer.
+func (s *Server) Transcribe(stream transcribeStream) (err error) {
+	g, err := s.acquire(stream.Context(), rpcProfile(stream.Context()))
+	if err != nil {
+		return profileStatus(err)
+	}
+	defer s.release(g)
+	ctx, span := s.startSpan(stream.Context(), "voxad.Transcribe", grpcCarrier(stream.Context()), rpcAttributes("Transcribe")...)
+	defer func() { endSpan(span, err) }()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	cfg := first.GetConfig()
+	if cfg == nil {
+		return status.Error(codes.InvalidArgument, "first message must be a TranscribeConfig")
+	}
+	if cfg.GetSampleRate() <= 0 {
+		return status.Error(codes.InvalidArgument, "sample_rate must be positive")
+	}
+	var phrases []voxa.Phrase
+	for _, p := range cfg.GetPhrases() {
+		phrases = append(phrases, voxa.Phrase{Text: p.GetText(), Boost: p.GetBoost()})
+	}
+	if err := checkPhrases(phrases); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	prio, ok := priorities[cfg.GetPriority()]
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "unknown priority %v", cfg.GetPriority())
+	}
+	endpointing := endpointingConfig(cfg.GetEndpointing())
+	if err := checkOverrides(ctx, cfg.GetLanguage(), cfg.GetModel(), endpointing); err != nil {
+		if errors.Is(err, auth.ErrNotAllowed) {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, sess, err := s.sessions.Start(ctx, cfg.GetSessionId(), KindTranscribe, "grpc", peerAddr(stream.Context()))
+	if err != nil {
+		return startStatus(err, codes.AlreadyExists)
+	}
+	defer s.sessions.End(sess.ID)
+	span.SetAttributes(attribute.String("voxa.session_id", sess.ID))
+	_, ended := s.logSession(sess)
+	defer func() { ended(err) }()
+	cl, err := s.cluster.claim(ctx, sess.ID, func() { s.sessions.Cancel(sess.ID) })
+	if err != nil {
+		return claimStatus(stream, err)
+	}
+	cut := true // unless the client ends the session
+	defer func() { cl.release(cut) }()
+	if route := s.cluster.route(); route != "" {
+		if err := stream.SetHeader(metadata.Pairs(RouteHeader, route)); err != nil {
+			return err
+		}
+	}
+
+	// Responses come from the result pump and from VAD callbacks on the
+	// receive path; gRPC streams need sends serialized.
+	var sendMu sync.Mutex
+	send := func(resp *voxadv1.TranscribeResponse) error {
+		resp.SessionId = sess.ID
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return stream.Send(resp)
+	}
+	started := &voxadv1.SessionStarted{SessionId: sess.ID, Route: s.cluster.route()}
+	if d := cl.offset(); d > 0 {
+		started.Resume = durationpb.New(d)
+	}
+	if err := send(&voxadv1.TranscribeResponse{Event: &voxadv1.TranscribeResponse_Started{Started: started}}); err != nil {
+		return err
+	}
+
+	format := audio.Format{SampleRate: int(cfg.GetSampleRate()), Channels: 1}
+	var playback *voxa.Playback // for Synthesize calls to barge in on
+	if cfg.GetVad() {
+		playback = voxa.NewPlayback()
+	}
+	vs, err := g.pipeline.NewStream(ctx, format, voxa.StreamOptions{
+		DisableVAD:  !cfg.GetVad(),
+		Playback:    playback,
+		SessionID:   sess.ID,
+		Offset:      cl.offset(),
+		Phrases:     phrases,
+		Priority:    sessionPriority(ctx, prio),
+		Language:    cfg.GetLanguage(),
+		Model:       cfg.GetModel(),
+		Endpointing: endpointing,
+		Tenant:      sess.Key,
+		OnVAD: func(ev voxa.VADEvent) {
+			_ = send(&voxadv1.TranscribeResponse{Event: &voxadv1.TranscribeResponse_Vad{Vad: vadEventPB(ev)}})
+		},
+		OnLanguage: func(det voxa.LanguageDetection) {
+			pb := &voxadv1.LanguageDetected{Language: det.Language, Confidence: det.Confidence, Fallback: det.Fallback}
+			if det.Err != nil {
+				pb.Error = det.Err.Error()
+			}
+			_ = send(&voxadv1.TranscribeResponse{Event: &voxadv1.TranscribeResponse_Language{Language: pb}})
+		},
+		OnDeferred: func(err error) {
+			_ = send(&voxadv1.TranscribeResponse{Event: &voxadv1.TranscribeResponse_Deferred{Deferred: &voxadv1.TranscriptionDeferred{Error: err.Error()}}})
+		},
+		OnIntent: func(in voxa.Intent) {
+			_ = send(&voxadv1.TranscribeResponse{Event: &voxadv1.TranscribeResponse_Intent{Intent: &voxadv1.Intent{
+				Name: in.Name, Slots: in.Slots, Confidence: in.Confidence, Text: in.Text,
+			}}})
+		},
+	})
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "open pipeline: %v", err)
+	}
+	s.sessions.Attach(sess.ID, vs)
+
+	done := make(chan error, 1)
+	go func() {
+		var sendErr error
+		for seg := range vs.Results() {
+			if sendErr == nil {
+				sendErr = send(&voxadv1.TranscribeResponse{Event: &voxadv1.TranscribeResponse_Segment{Segment: export.SegmentProto(seg)}})
+				if sendErr == nil {
+					cl.final(seg)
+				}
+			}
+		}
+		done <- sendErr
+	}()
+
+	err = s.feed(ctx, stream, vs, sess.Stopping())
+	cut = cutOff(ctx, sess, err)
+	if cerr := vs.Close(); err == nil {
+		err = cerr
+	}
+	sendErr := <-done
+	switch {
+	case err != nil:
+		return err
+	case sendErr != nil:
+		return sendErr
+	case ctx.Err() != nil:
+		return status.Error(codes.Canceled, "session terminated")
+	}
+	if err := vs.Err(); err != nil {
+		return status.Errorf(codes.Unavailable, "recognizer: %v", err)
+	}
+	return nil
+}
+
+// feed forwards client requests into the pipeline stream until the client
+// sends END, half-closes, the session is terminated, or stop is closed.
+func (s *Server) feed(ctx context.Context, stream transcribeStream, vs *voxa.Stream, stop <-chan struct{}) error {
+	reqs := make(chan *voxadv1.TranscribeRequest)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-stop:
+			return nil
+		case err := <-errc:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case req := <-reqs:
+			switch p := req.GetPayload().(type) {
+			case *voxadv1.TranscribeRequest_Audio:
+				if _, err := vs.Write(p.Audio.GetData()); err != nil {
+					return status.Errorf(codes.Unavailable, "write audio: %v", err)
+				}
+			case *voxadv1.TranscribeRequest_Control:
+				switch p.Control {
+				case speechv1.ControlType_FLUSH:
+					if err := vs.Flush(); err != nil {
+						return status.Errorf(codes.Unavailable, "flush: %v", err)
+					}
+				case speechv1.ControlType_END:
+					return nil
+				}
+			case *voxadv1.TranscribeRequest_Config:
+				return status.Error(codes.InvalidArgument, "config may only be sent once")
+			}
+		}
+	}
+}
+
+// Synthesize implements voxadv1.VoxadServer.
+func (s *Server) Synthesize(stream synthesizeStream) (err error) {
+	g, err := s.acquire(stream.Context(), rpcProfile(stream.Context()))
+	if err != nil {
+		return profileStatus(err)
+	}
+	defer s.release(g)
+	if g.tts == nil {
+		return status.Error(codes.Unavailable, "synthesis is not configured")
+	}
+	ctx, span := s.startSpan(stream.Context(), "voxad.Synthesize", grpcCarrier(stream.Context()), rpcAttributes("Synthesize")...)
+	defer func() { endSpan(span, err) }()
+
+	ctx, sess, err := s.sessions.Start(ctx, "", KindSynthesize, "grpc", peerAddr(stream.Context()))
+	if err != nil {
+		return startStatus(err, codes.Internal)
+	}
+	defer s.sessions.End(sess.ID)
+	span.SetAttributes(attribute.String("voxa.session_id", sess.ID))
+	log, ended := s.logSession(sess)
+	defer func() { ended(err) }()
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		log.Debug("synthesis requested", "utterance", req.GetUtteranceId(), "chars", len(req.GetText()))
+		var playback *voxa.Playback
+		if id := req.GetBargeInSession(); id != "" {
+			if playback, err = s.playback(sess, id); err != nil {
+				return err
+			}
+		}
+		if err := s.synthesize(ctx, g.tts, stream, req, playback); err != nil {
+			return err
+		}
+	}
+}
+
+// playback returns the Playback of transcription id, which synthesis
+// session sess asks to be interrupted by. Sessions of other API keys are
+// not found.
+func (s *Server) playback(sess *Session, id string) (*voxa.Playback, error) {
+	t, ok := s.sessions.Get(id)
+	if !ok || t.Kind != KindTranscribe || t.Key != sess.Key {
+		return nil, status.Errorf(codes.NotFound, "no transcription session %q to barge in on", id)
+	}
+	if t.Stream == nil || t.Stream.Playback() == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "transcription session %q does not detect speech; enable its vad", id)
+	}
+	return t.Stream.Playback(), nil
+}
+
+// synthesize speaks one request on tts, sending audio chunks as they are
+// produced, in a voxa.tts span. With playback, the audio stops when its
+// transcription hears the user speak.
+func (s *Server) synthesize(ctx context.Context, tts voxa.Synthesizer, stream synthesizeStream, req *voxadv1.SynthesizeRequest, playback *voxa.Playback) (err error) {
+	ctx, span := s.tracer().Start(ctx, "voxa.tts", trace.WithAttributes(
+		attribute.String("voxa.utterance_id", req.GetUtteranceId()),
+		attribute.Int("voxa.text_length", len(req.GetText())),
+	))
+	defer func() { endSpan(span, err) }()
+
+	out, err := tts.Synthesize(ctx, voxa.SynthesisRequest{UtteranceID: req.GetUtteranceId(), Text: req.GetText()})
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "synthesize: %v", err)
+	}
+	if playback != nil {
+		out = playback.Play(out)
+	}
+	defer out.Close()
+	for seq := int64(0); ; seq++ {
+		fr, err := out.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			span.SetAttributes(attribute.Int64("voxa.chunks", seq))
+			return nil
+		}
+		if errors.Is(err, voxa.ErrBargeIn) {
+			span.AddEvent("barge_in")
+			span.SetAttributes(attribute.Int64("voxa.chunks", seq))
+			return stream.Send(&voxadv1.SynthesizeResponse{
+				Audio:       &speechv1.AudioChunk{UtteranceId: req.GetUtteranceId(), Seq: seq, EmitTime: timestamppb.Now()},
+				Interrupted: true,
+			})
+		}
+		if seq == 0 && err == nil {
+			span.AddEvent("first_audio")
+		}
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "synthesize: %v", err)
+		}
+		err = stream.Send(&voxadv1.SynthesizeResponse{Audio: &speechv1.AudioChunk{
+			UtteranceId: req.GetUtteranceId(),
+			Seq:         seq,
+			Data:        fr.Bytes(),
+			EmitTime:    timestamppb.Now(),
+		}})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func vadEventPB(ev voxa.VADEvent) *voxadv1.VadEvent {
+	t := voxadv1.VadEventType_VAD_EVENT_TYPE_UNSPECIFIED
+	switch ev.Type {
+	case vad.SpeechStart:
+		t = voxadv1.VadEventType_SPEECH_START
+	case vad.SpeechEnd:
+		t = voxadv1.VadEventType_SPEECH_END
+	}
+	return &voxadv1.VadEvent{Type: t, Offset: durationpb.New(ev.Offset)}
+}
+
+// EventProto encodes ev as a voxadv1.Event, as published by event sinks.
+func EventProto(ev voxa.Event) ([]byte, error) {
+	pb := &voxadv1.Event{Type: eventTypePB(ev.Type), SessionId: ev.SessionID, Time: timestamppb.New(ev.Time)}
+	if d := ev.WakeWord; d != nil {
+		pb.WakeWord = &voxadv1.WakeWord{Phrase: d.Phrase, Offset: durationpb.New(d.Offset), Score: d.Score}
+	}
+	if ev.Segment != nil {
+		pb.Segment = export.SegmentProto(*ev.Segment)
+	}
+	if in := ev.Intent; in != nil {
+		pb.Intent = &voxadv1.Intent{Name: in.Name, Slots: in.Slots, Confidence: in.Confidence, Text: in.Text}
+	}
+	if a := ev.Alert; a != nil {
+		pb.Alert = &voxadv1.Alert{Rule: a.Rule, Match: a.Match}
+	}
+	if d := ev.DTMF; d != nil {
+		pb.Dtmf = &voxadv1.Dtmf{Key: d.Key, Offset: durationpb.New(d.Offset)}
+	}
+	if d := ev.AMD; d != nil {
+		pb.Amd = &voxadv1.Amd{Result: d.Result.String(), Reason: d.Reason, Offset: durationpb.New(d.Offset), Words: int32(d.Words)}
+	}
+	if ev.Err != nil {
+		pb.Error = ev.Err.Error()
+	}
+	return proto.Marshal(pb)
+}
+
+func eventTypePB(t voxa.EventType) voxadv1.EventType {
+	switch t {
+	case voxa.EventWakeWord:
+		return voxadv1.EventType_WAKE_WORD
+	case voxa.EventFinal:
+		return voxadv1.EventType_FINAL
+	case voxa.EventIntent:
+		return voxadv1.EventType_INTENT
+	case voxa.EventPartial:
+		return voxadv1.EventType_PARTIAL
+	case voxa.EventSessionStart:
+		return voxadv1.EventType_SESSION_START
+	case voxa.EventSessionEnd:
+		return voxadv1.EventType_SESSION_END
+	case voxa.EventAlert:
+		return voxadv1.EventType_ALERT
+	case voxa.EventDTMF:
+		return voxadv1.EventType_DTMF
+	case voxa.EventAMD:
+		return voxadv1.EventType_AMD
+	case voxa.EventDeferred:
+		return voxadv1.EventType_DEFERRED
+	}
+	return voxadv1.EventType_EVENT_TYPE_UNSPECIFIED
+}
+
+// startStatus is the status of a session that did not start for err,
+// code unless the API key has no sessions left or the server is draining.
+// claimStatus maps a failed cluster claim to a gRPC status, telling a
+// client of a session live on another node where it runs.
+func claimStatus(stream grpc.ServerStream, err error) error {
+	if errors.Is(err, ErrNotOwner) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	var moved *MovedError
+	if errors.As(err, &moved) {
+		stream.SetTrailer(metadata.Pairs(RouteHeader, moved.Node))
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+func startStatus(err error, code codes.Code) error {
+	switch {
+	case errors.Is(err, auth.ErrSessionQuota):
+		code = codes.ResourceExhausted
+	case errors.Is(err, ErrDraining):
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
+
+// rpcProfile returns the profile the client of an RPC asked for in its
+// metadata, if any.
+func rpcProfile(ctx context.Context) string {
+	if v := metadata.ValueFromIncomingContext(ctx, ProfileHeader); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func profileStatus(err error) error {
+	if errors.Is(err, auth.ErrNotAllowed) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/g711"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// TwilioNotice is a recording notice played to callers when their call
+// starts, for jurisdictions requiring them to be told they are recorded.
+// Until Twilio reports it played to the end, the audio of the call is
+// neither transcribed nor archived, and the times of the transcripts count
+// from its end. Twilio only plays audio sent back on a bidirectional
+// stream, a <Connect><Stream>.
+type TwilioNotice struct {
+	// Text is spoken by the synthesizer of the server.
+	Text string
+	// File is played instead of Text: an audio file in any format voxa
+	// decodes.
+	File string
+	// Timeout is how much longer than the notice Twilio may take to report
+	// it played before the call is given up, none of it transcribed.
+	// Defaults to DefaultNoticeTimeout.
+	Timeout time.Duration
+}
+
+// DefaultNoticeTimeout is the default TwilioNotice.Timeout.
+const DefaultNoticeTimeout = 10 * time.Second
+
+// Consent states of a call given a notice, recorded as it changes in the
+// metadata of the sessions of its tracks, under MetadataConsent, and
+// posted as TwilioConsent events.
+const (
+	// ConsentPending is a notice playing; nothing is transcribed yet.
+	ConsentPending = "pending"
+	// ConsentNotified is a notice played to the end, the call being
+	// transcribed from then on.
+	ConsentNotified = "notified"
+	// ConsentHungUp is a call that ended before its notice did, none of it
+	// transcribed.
+	ConsentHungUp = "hung_up"
+	// ConsentFailed is a notice Twilio did not report played in time; the
+	// call is not transcribed.
+	ConsentFailed = "failed"
+)
+
+// Session metadata keys of the consent of a call.
+const (
+	// MetadataConsent holds the consent state of the call.
+	MetadataConsent = "consent"
+	// MetadataConsentAt holds when the call reached it, in RFC 3339.
+	MetadataConsentAt = "consent_at"
+)
+
+// noticeMark names the mark sent after the notice, which Twilio echoes
+// once the audio before it has played.
+const noticeMark = "voxa-notice"
+
+// noticeChunk is the audio sent in one media message, a second.
+const noticeChunk = 8000
+
+// loadNotice returns the µ-law audio of n, synthesized on tts for text.
+func loadNotice(n TwilioNotice, tts voxa.Synthesizer) ([]byte, error) {
+	var src interface {
+		Format() audio.Format
+		ReadFrame() (audio.Frame, error)
+	}
+	switch {
+	case n.File != "" && n.Text != "":
+		return nil, errors.New("twilio: notice has both a text and a file")
+	case n.File != "":
+		f, err := audio.Open(n.File)
+		if err != nil {
+			return nil, fmt.Errorf("twilio: notice: %w", err)
+		}
+		defer f.Close()
+		src = f
+	case n.Text != "":
+		if tts == nil {
+			return nil, errors.New("twilio: notice text needs a synthesizer")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		s, err := tts.Synthesize(ctx, voxa.SynthesisRequest{UtteranceID: "notice", Text: n.Text})
+		if err != nil {
+			return nil, fmt.Errorf("twilio: notice: %w", err)
+		}
+		defer s.Close()
+		src = s
+	default:
+		return nil, errors.New("twilio: notice has no text or file")
+	}
+	clip := audio.Frame{Format: src.Format()}
+	for {
+		fr, err := src.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("twilio: notice: %w", err)
+		}
+		clip.Data = append(clip.Data, fr.Data...)
+	}
+	if len(clip.Data) == 0 {
+		return nil, errors.New("twilio: notice is silent")
+	}
+	clip, err := audio.Resample(clip, twilioFormat, audio.QualityHigh)
+	if err != nil {
+		return nil, fmt.Errorf("twilio: notice: %w", err)
+	}
+	return g711.EncodeULaw(nil, clip.Data), nil
+}
+
+// twilioReply is a message sent back to Twilio.
+type twilioReply struct {
+	Event     string       `json:"event"`
+	StreamSID string       `json:"streamSid"`
+	Media     *twilioMedia `json:"media,omitempty"`
+	Mark      *twilioMark  `json:"mark,omitempty"`
+}
+
+type twilioMedia struct {
+	Payload string `json:"payload"`
+}
+
+type twilioMark struct {
+	Name string `json:"name"`
+}
+
+// playNotice sends the notice on stream sid, and the mark after it.
+func (h *twilioHandler) playNotice(ctx context.Context, conn *websocket.Conn, sid string) error {
+	send := func(m twilioReply) error {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return conn.Write(ctx, websocket.MessageText, b)
+	}
+	for b := h.notice; len(b) > 0; {
+		n := min(len(b), noticeChunk)
+		m := twilioReply{Event: "media", StreamSID: sid, Media: &twilioMedia{Payload: base64.StdEncoding.EncodeToString(b[:n])}}
+		if err := send(m); err != nil {
+			return fmt.Errorf("send notice: %w", err)
+		}
+		b = b[n:]
+	}
+	if err := send(twilioReply{Event: "mark", StreamSID: sid, Mark: &twilioMark{Name: noticeMark}}); err != nil {
+		return fmt.Errorf("send notice: %w", err)
+	}
+	return nil
+}
+
+// noticeDeadline is when Twilio is to have reported the notice played, if
+// it was sent at t.
+func (h *twilioHandler) noticeDeadline(t time.Time) time.Time {
+	timeout := h.cfg.Notice.Timeout
+	if timeout <= 0 {
+		timeout = DefaultNoticeTimeout
+	}
+	return t.Add(twilioFormat.Duration(len(h.notice)) + timeout)
+}
+
+// consent records that the call of tracks reached state.
+func consent(log logging.Logger, names []string, tracks map[string]*twilioTrack, state string) {
+	md := map[string]string{MetadataConsent: state, MetadataConsentAt: time.Now().UTC().Format(time.RFC3339)}
+	for _, name := range names {
+		t := tracks[name]
+		if t == nil || t.done {
+			continue
+		}
+		if err := t.vs.SetMetadata(md); err != nil {
+			log.Warn("recording consent failed", "session", t.sess.ID, "error", err)
+		}
+		ev := t.ev
+		ev.Event, ev.Consent = TwilioConsent, state
+		t.out.push(ev)
+	}
+	log.Info("recording notice", "consent", state)
+}
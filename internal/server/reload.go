@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/auth"
+)
+
+// ErrUnknownProfile is reported to clients asking for a profile the server
+// does not have.
+var ErrUnknownProfile = errors.New("server: unknown profile")
+
+// ProfileHeader is the gRPC metadata key clients select a profile with. On
+// the WebSocket endpoint it is the profile query parameter of the
+// handshake, and on Twilio Media Streams the profile custom parameter.
+const ProfileHeader = "voxa-profile"
+
+// Profile is a pipeline and synthesizer sessions may run on instead of the
+// default ones, selected by their API key or by the client; see
+// auth.KeyConfig.Profiles.
+type Profile struct {
+	Pipeline *voxa.Pipeline
+	// Synthesizer may be nil.
+	Synthesizer voxa.Synthesizer
+}
+
+// backends are what a session runs on.
+type backends struct {
+	pipeline *voxa.Pipeline
+	tts      voxa.Synthesizer
+}
+
+// generation is the pipelines and synthesizers sessions start on between
+// two reloads. Sessions keep the generation they started on until they end.
+type generation struct {
+	backends                     // of the default profile
+	profiles map[string]backends // by name
+	active   int                 // sessions running on it; guarded by Server.mu
+	retired  bool                // replaced by Reload
+	drained  chan struct{}       // closed once retired with no session left
+}
+
+func newGeneration(p *voxa.Pipeline, tts voxa.Synthesizer, profiles map[string]Profile) *generation {
+	g := &generation{backends: backends{p, tts}, profiles: map[string]backends{}, drained: make(chan struct{})}
+	for name, pr := range profiles {
+		g.profiles[name] = backends{pr.Pipeline, pr.Synthesizer}
+	}
+	return g
+}
+
+// held is a generation held for a session, with the backends of the
+// profile the session runs on.
+type held struct {
+	backends
+	gen *generation
+}
+
+// Reload makes sessions started from now on use p and tts, which may be
+// nil, or the profiles. Sessions already running carry on with the
+// previous pipelines and synthesizers; the returned channel is closed once
+// the last of them has ended, when the caller may close those.
+func (s *Server) Reload(p *voxa.Pipeline, tts voxa.Synthesizer, profiles map[string]Profile) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.cur
+	s.cur = newGeneration(p, tts, profiles)
+	old.retired = true
+	if old.active == 0 {
+		close(old.drained)
+	}
+	return old.drained
+}
+
+// current returns the generation new sessions start on.
+func (s *Server) current() *generation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur
+}
+
+// acquire holds the current generation for a session opened in ctx until
+// release, returning the backends of profile. An empty profile is the
+// first of the API key's profiles, or the default one. A key may only ask
+// for its own profiles.
+func (s *Server) acquire(ctx context.Context, profile string) (held, error) {
+	if k := auth.FromContext(ctx); k != nil {
+		switch {
+		case profile == "":
+			profile = k.DefaultProfile()
+		case !k.AllowsProfile(profile):
+			return held{}, fmt.Errorf("%w: profile %q", auth.ErrNotAllowed, profile)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.cur.backends
+	if profile != "" {
+		var ok bool
+		if b, ok = s.cur.profiles[profile]; !ok {
+			return held{}, fmt.Errorf("%w %q", ErrUnknownProfile, profile)
+		}
+	}
+	s.cur.active++
+	return held{backends: b, gen: s.cur}, nil
+}
+
+func (s *Server) release(h held) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := h.gen
+	g.active--
+	if g.retired && g.active == 0 {
+		close(g.drained)
+	}
+}
+
+// Synthesizer returns a synthesizer speaking on the synthesizer of the
+// current generation, for components outliving reloads: that of the
+// profile of the API key in the request context, or the default one. Each
+// stream holds its generation until closed, and requests fail while no
+// synthesizer is configured.
+func (s *Server) Synthesizer() voxa.Synthesizer { return currentSynthesizer{s} }
+
+type currentSynthesizer struct{ s *Server }
+
+func (c currentSynthesizer) Synthesize(ctx context.Context, req voxa.SynthesisRequest) (voxa.SynthesisStream, error) {
+	h, err := c.s.acquire(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	if h.tts == nil {
+		c.s.release(h)
+		return nil, errors.New("server: no synthesizer configured")
+	}
+	out, err := h.tts.Synthesize(ctx, req)
+	if err != nil {
+		c.s.release(h)
+		return nil, err
+	}
+	return &heldStream{SynthesisStream: out, release: func() { c.s.release(h) }}, nil
+}
+
+// heldStream releases its generation when closed.
+type heldStream struct {
+	voxa.SynthesisStream
+	once    sync.Once
+	release func()
+}
+
+func (h *heldStream) Close() error {
+	err := h.SynthesisStream.Close()
+	h.once.Do(h.release)
+	return err
+}
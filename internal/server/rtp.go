@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/ingest/rtp"
+)
+
+// RTPOpener opens the streams of calls received as RTP, from phones or
+// WebRTC peers, on the current pipeline, registering each call as a
+// transcription session over transport, rtp or webrtc, so it is listed and
+// can be terminated like any other.
+func (s *Server) RTPOpener(transport string) rtp.Opener {
+	return func(ctx context.Context, c rtp.Call, f audio.Format) (*voxa.Stream, func(), error) {
+		g, err := s.acquire(ctx, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx, sess, err := s.sessions.Start(ctx, c.SessionID, KindTranscribe, transport, c.Peer.String())
+		if err != nil {
+			s.release(g)
+			return nil, nil, err
+		}
+		done := func() {
+			s.sessions.End(sess.ID)
+			s.release(g)
+		}
+		vs, err := g.pipeline.NewStream(ctx, f, voxa.StreamOptions{SessionID: sess.ID, Tenant: sess.Key})
+		if err != nil {
+			done()
+			return nil, nil, err
+		}
+		s.sessions.Attach(sess.ID, vs)
+		return vs, done, nil
+	}
+}
@@ -0,0 +1,279 @@
+// Package server implements voxad, the daemon exposing the voxa pipeline
+// over the network.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/auth"
+	"github.com/jmarc101/voxa/internal/cost"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// Kind is what a session is doing.
+type Kind string
+
+const (
+	// KindTranscribe sessions stream audio in and transcripts out.
+	KindTranscribe Kind = "transcribe"
+	// KindSynthesize sessions stream text in and audio out.
+	KindSynthesize Kind = "synthesize"
+)
+
+// ErrDraining fails the sessions started once the server drains.
+var ErrDraining = errors.New("server is shutting down")
+
+// Session describes one active client stream.
+type Session struct {
+	ID   string
+	Kind Kind
+	// Transport is how the client connected: grpc, websocket, twilio, rtp
+	// or webrtc.
+	Transport string
+	Peer      string
+	Started   time.Time
+	// Key names the API key the session was opened with, if any.
+	Key string
+	// Stream is the pipeline stream of a transcription, once attached.
+	Stream *voxa.Stream
+
+	meter   *cost.Meter // with a ledger, see Server.Bill
+	cancel  context.CancelFunc
+	quota   func()        // returns the session to the key's quota
+	stop    chan struct{} // see Stopping
+	stopped bool
+}
+
+// Stopping returns a channel closed when the session should stop taking
+// input and end once it has delivered what it has, because the server is
+// draining.
+func (sess *Session) Stopping() <-chan struct{} { return sess.stop }
+
+// Sessions tracks the active sessions so concurrent clients stay isolated
+// and can be listed or terminated.
+type Sessions struct {
+	mu       sync.Mutex
+	m        map[string]*Session
+	draining bool
+	ended    chan struct{} // closed and replaced when a session ends
+	ledger   *cost.Ledger
+}
+
+// NewSessions creates an empty session table.
+func NewSessions() *Sessions {
+	return &Sessions{m: make(map[string]*Session), ended: make(chan struct{})}
+}
+
+// Bill meters the provider usage of the sessions started from now on, the
+// audio streamed to recognizers and the characters synthesized, and charges
+// it to l, by session and API key, when they end. It must be called
+// before the server serves.
+func (s *Server) Bill(l *cost.Ledger) { s.sessions.ledger = l }
+
+// Start registers a session and returns a context that is cancelled when
+// the session is terminated. An empty id gets a random one. A session opened
+// with an API key counts against its quota, failing with
+// auth.ErrSessionQuota if it is used up. Once the table drains, Start fails
+// with ErrDraining. The caller must call End when the session finishes.
+func (s *Sessions) Start(ctx context.Context, id string, kind Kind, transport, peer string) (context.Context, *Session, error) {
+	if id == "" {
+		id = newID()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return nil, nil, ErrDraining
+	}
+	if _, dup := s.m[id]; dup {
+		return nil, nil, fmt.Errorf("session %q already active", id)
+	}
+	sess := &Session{ID: id, Kind: kind, Transport: transport, Peer: peer, Started: time.Now(), quota: func() {}, stop: make(chan struct{})}
+	if k := auth.FromContext(ctx); k != nil {
+		end, err := k.OpenSession()
+		if err != nil {
+			return nil, nil, err
+		}
+		sess.Key, sess.quota = k.Name(), end
+	}
+	if s.ledger != nil {
+		sess.meter = cost.NewMeter()
+		ctx = cost.NewContext(ctx, sess.meter)
+	}
+	ctx, sess.cancel = context.WithCancel(ctx)
+	s.m[id] = sess
+	return ctx, sess, nil
+}
+
+// sessionPriority returns the priority a session opened in ctx asking for
+// p gets, lowered to the most its API key allows.
+func sessionPriority(ctx context.Context, p voxa.Priority) voxa.Priority {
+	if k := auth.FromContext(ctx); k != nil {
+		return k.Priority(p)
+	}
+	return p
+}
+
+// Attach records the pipeline stream of session id.
+func (s *Sessions) Attach(id string, vs *voxa.Stream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.m[id]; ok {
+		sess.Stream = vs
+		if s.draining {
+			s.stopWhenIdle(sess)
+		}
+	}
+}
+
+// Drain fails the sessions started from now on with ErrDraining and stops
+// every transcription at its next utterance boundary, see
+// voxa.Stream.Idle: through Stopping, its transport stops taking audio, and
+// the session ends once the rest is transcribed. Synthesis sessions and
+// calls received over RTP run on until they end or are terminated.
+func (s *Sessions) Drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining = true
+	for _, sess := range s.m {
+		s.stopWhenIdle(sess)
+	}
+}
+
+// stopWhenIdle closes the Stopping channel of sess once its stream is
+// between utterances. s.mu is held.
+func (s *Sessions) stopWhenIdle(sess *Session) {
+	if sess.Stream == nil {
+		return // attached later, or not a transcription
+	}
+	idle := sess.Stream.Idle()
+	go func() {
+		<-idle
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !sess.stopped {
+			sess.stopped = true
+			close(sess.stop)
+		}
+	}()
+}
+
+// Wait waits for every session to end, or for ctx to be done.
+func (s *Sessions) Wait(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		n, ended := len(s.m), s.ended
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ended:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// CancelAll terminates every session, returning how many there were.
+func (s *Sessions) CancelAll() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range s.m {
+		sess.cancel()
+	}
+	return len(s.m)
+}
+
+// End removes a session and releases its context.
+func (s *Sessions) End(id string) {
+	s.mu.Lock()
+	sess, ok := s.m[id]
+	delete(s.m, id)
+	if ok {
+		close(s.ended)
+		s.ended = make(chan struct{})
+	}
+	s.mu.Unlock()
+	if ok {
+		sess.cancel()
+		sess.quota()
+		if s.ledger != nil {
+			s.ledger.Charge(sess.Key, sess.ID, sess.meter.Usage())
+		}
+	}
+}
+
+// Cancel terminates a session. It reports whether the session existed.
+func (s *Sessions) Cancel(id string) bool {
+	s.mu.Lock()
+	sess, ok := s.m[id]
+	s.mu.Unlock()
+	if ok {
+		sess.cancel()
+	}
+	return ok
+}
+
+// Get returns a snapshot of one session.
+func (s *Sessions) Get(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.m[id]
+	if !ok {
+		return Session{}, false
+	}
+	return *sess, true
+}
+
+// List returns a snapshot of the active sessions, oldest first.
+func (s *Sessions) List() []Session {
+	s.mu.Lock()
+	out := make([]Session, 0, len(s.m))
+	for _, sess := range s.m {
+		out = append(out, *sess)
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+	return out
+}
+
+// Len returns the number of active sessions.
+func (s *Sessions) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.m)
+}
+
+// logSession logs the start of sess on the pipeline's logger and returns a
+// logger carrying the session ID, and a function logging the end of the
+// session with the error it ended with.
+func (s *Server) logSession(sess *Session) (logging.Logger, func(err error)) {
+	log := logging.With(s.current().pipeline.Logger(), "session", sess.ID)
+	log.Info("session started", "kind", sess.Kind, "peer", sess.Peer, "transport", sess.Transport, "key", sess.Key)
+	return log, func(err error) {
+		attrs := []any{"duration", time.Since(sess.Started)}
+		if l := s.sessions.ledger; l != nil {
+			_, c := l.Price(sess.meter.Usage())
+			attrs = append(attrs, "cost", c)
+		}
+		if err != nil {
+			log.Warn("session ended", append(attrs, "error", err)...)
+			return
+		}
+		log.Info("session ended", attrs...)
+	}
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
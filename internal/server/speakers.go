@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// maxEnrollment bounds the body of an enrollment: some ten minutes of
+// 16kHz PCM.
+const maxEnrollment = 20 << 20
+
+// errNoSpeakers is returned when the pipeline identifies no speakers.
+var errNoSpeakers = errors.New("speaker identification is not enabled")
+
+// SpeakersHandler serves speaker enrollment as JSON:
+//
+//	GET    /v1/speakers         → WireSpeakerList
+//	POST   /v1/speakers/{name}  enrolls the speech of an audio file in the
+//	                            body, adding to an existing voiceprint:
+//	                            WireVoiceprint, or 400 without enough speech
+//	DELETE /v1/speakers/{name}  forgets a speaker: 204, or 404
+//
+// The audio file may be in any format audio.Open reads; it should hold the
+// speaker alone. Mount it on both paths. It answers 501 unless the pipeline
+// identifies speakers.
+func (s *Server) SpeakersHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/speakers", func(w http.ResponseWriter, r *http.Request) {
+		reg := s.current().pipeline.Speakers()
+		if reg == nil {
+			http.Error(w, errNoSpeakers.Error(), http.StatusNotImplemented)
+			return
+		}
+		list := WireSpeakerList{Speakers: []WireVoiceprint{}}
+		for _, v := range reg.List() {
+			list.Speakers = append(list.Speakers, wireVoiceprint(v))
+		}
+		writeJSON(w, list)
+	})
+	mux.HandleFunc("POST /v1/speakers/{name}", func(w http.ResponseWriter, r *http.Request) {
+		reg := s.current().pipeline.Speakers()
+		if reg == nil {
+			http.Error(w, errNoSpeakers.Error(), http.StatusNotImplemented)
+			return
+		}
+		src, err := audio.NewReader(http.MaxBytesReader(w, r.Body, maxEnrollment))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if c, ok := src.(interface{ Close() error }); ok {
+			defer c.Close()
+		}
+		v, err := reg.Enroll(r.PathValue("name"), src)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(w, "recording larger than "+strconv.Itoa(maxEnrollment)+" bytes", http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, voxa.ErrSpeakerTooShort), errors.Is(err, voxa.ErrSpeakerName):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, wireVoiceprint(v))
+	})
+	mux.HandleFunc("DELETE /v1/speakers/{name}", func(w http.ResponseWriter, r *http.Request) {
+		reg := s.current().pipeline.Speakers()
+		if reg == nil {
+			http.Error(w, errNoSpeakers.Error(), http.StatusNotImplemented)
+			return
+		}
+		err := reg.Remove(r.PathValue("name"))
+		switch {
+		case errors.Is(err, voxa.ErrSpeakerNotFound):
+			http.Error(w, "no speaker "+strconv.Quote(r.PathValue("name")), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func wireVoiceprint(v voxa.Voiceprint) WireVoiceprint {
+	return WireVoiceprint{Name: v.Name, SpeechMS: v.Speech.Milliseconds(), Enrolled: v.Enrolled, Updated: v.Updated}
+}
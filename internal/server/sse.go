@@ -0,0 +1,306 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// SSE defaults.
+const (
+	defaultSSEBuffer    = 256
+	defaultSSERetention = time.Minute
+	// sseHeartbeat is how often an idle stream gets a comment, so proxies
+	// keep the connection open.
+	sseHeartbeat = 15 * time.Second
+	// sseRetry is the reconnection delay suggested to clients.
+	sseRetry = 2 * time.Second
+)
+
+// EventHubConfig configures an EventHub.
+type EventHubConfig struct {
+	// Buffer is how many of the latest events of a session are kept for
+	// clients resuming a stream. Defaults to 256.
+	Buffer int
+	// Retention is how long the events of an ended session are kept.
+	// Defaults to a minute.
+	Retention time.Duration
+	// Logger receives encoding failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// EventHub is an event sink serving the events of live sessions as
+// server-sent events, for clients that cannot use WebSockets:
+//
+//	GET /v1/sessions/{session_id}/events[?events=final,intent]  → text/event-stream
+//
+// Every event is a WireEvent JSON document, with the event type as the SSE
+// event name and its number within the session as the SSE ID. A client
+// reconnecting with Last-Event-ID (or ?last_event_id=N) gets the events it
+// missed first, as far as the buffer goes back. A session may be
+// subscribed to before it starts; the stream ends after its session_end
+// event, and a client resuming past it is answered 204, which tells
+// EventSource to stop reconnecting. Keys other than admin keys only stream
+// the sessions they opened: those of other keys answer 404, and a stream
+// opened ahead of a session another key starts ends when it does. Mount it
+// on /v1/sessions/.
+type EventHub struct {
+	cfg EventHubConfig
+	log logging.Logger
+
+	mu       sync.Mutex
+	sessions map[string]*sessionEvents
+}
+
+// sessionEvents are the buffered events and subscribers of a session.
+type sessionEvents struct {
+	ring  []sseEvent // the latest events, oldest first
+	next  int        // ID of the next event
+	ended bool
+	subs  map[*subscriber]bool
+	timer *time.Timer // forgets an ended session
+	// started is set once the session starts, owner to the key it was
+	// opened with.
+	started bool
+	owner   string
+}
+
+type sseEvent struct {
+	id   int
+	typ  voxa.EventType
+	data []byte
+}
+
+type subscriber struct {
+	ch     chan sseEvent // closed if it falls behind, is forgotten or refused
+	tenant string        // see tenant
+}
+
+// NewEventHub validates cfg.
+func NewEventHub(cfg EventHubConfig) (*EventHub, error) {
+	switch {
+	case cfg.Buffer < 0:
+		return nil, fmt.Errorf("server: negative event buffer %d", cfg.Buffer)
+	case cfg.Retention < 0:
+		return nil, fmt.Errorf("server: negative event retention %v", cfg.Retention)
+	}
+	if cfg.Buffer == 0 {
+		cfg.Buffer = defaultSSEBuffer
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = defaultSSERetention
+	}
+	return &EventHub{cfg: cfg, log: logging.OrNop(cfg.Logger), sessions: map[string]*sessionEvents{}}, nil
+}
+
+// Publish implements voxa.EventSink.
+func (h *EventHub) Publish(ev voxa.Event) {
+	data, err := EventMessage(ev)
+	if err != nil {
+		h.log.Warn("encode event", "event", ev.Type, "session", ev.SessionID, "error", err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	se := h.sessions[ev.SessionID]
+	if se == nil {
+		se = &sessionEvents{next: 1, subs: map[*subscriber]bool{}}
+		h.sessions[ev.SessionID] = se
+	}
+	if se.ended {
+		if ev.Type != voxa.EventSessionStart {
+			return
+		}
+		// The ID is reused: start over.
+		se.timer.Stop()
+		se.ring, se.ended = se.ring[:0], false
+	}
+	if ev.Type == voxa.EventSessionStart {
+		se.started, se.owner = true, ev.Tenant
+		for sub := range se.subs {
+			if sub.tenant != "" && sub.tenant != se.owner {
+				// Subscribed ahead to the session of another key.
+				close(sub.ch)
+				delete(se.subs, sub)
+			}
+		}
+	}
+	e := sseEvent{id: se.next, typ: ev.Type, data: data}
+	se.next++
+	if len(se.ring) == h.cfg.Buffer {
+		se.ring = append(se.ring[:0], se.ring[1:]...)
+	}
+	se.ring = append(se.ring, e)
+	for sub := range se.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			// The client resumes from the buffer when it reconnects.
+			close(sub.ch)
+			delete(se.subs, sub)
+		}
+	}
+	if ev.Type == voxa.EventSessionEnd {
+		se.ended = true
+		id := ev.SessionID
+		se.timer = time.AfterFunc(h.cfg.Retention, func() { h.forget(id, se) })
+	}
+}
+
+// forget drops an ended session unless it started again.
+func (h *EventHub) forget(id string, se *sessionEvents) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sessions[id] == se && se.ended {
+		for sub := range se.subs {
+			close(sub.ch)
+		}
+		delete(h.sessions, id)
+	}
+}
+
+// subscribe returns the buffered events of session id after last and a
+// subscription to the ones to come, nil if the session has ended, for
+// tenant, as tenant returns it. ok is false if the session is another
+// tenant's. The subscription must be cancelled.
+func (h *EventHub) subscribe(id, tenant string, last int) (missed []sseEvent, sub *subscriber, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	se := h.sessions[id]
+	if se != nil && se.started && tenant != "" && se.owner != tenant {
+		return nil, nil, false
+	}
+	if se == nil {
+		se = &sessionEvents{next: 1, subs: map[*subscriber]bool{}}
+		h.sessions[id] = se
+	}
+	for _, e := range se.ring {
+		if e.id > last {
+			missed = append(missed, e)
+		}
+	}
+	if se.ended {
+		return missed, nil, true
+	}
+	sub = &subscriber{ch: make(chan sseEvent, h.cfg.Buffer), tenant: tenant}
+	se.subs[sub] = true
+	return missed, sub, true
+}
+
+func (h *EventHub) cancel(id string, sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	se := h.sessions[id]
+	if se == nil || !se.subs[sub] {
+		return
+	}
+	delete(se.subs, sub)
+	if len(se.subs) == 0 && len(se.ring) == 0 {
+		// Subscribed to a session that never started.
+		delete(h.sessions, id)
+	}
+}
+
+// ServeHTTP streams the events of a session.
+func (h *EventHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/v1/sessions/"), "/events")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("last_event_id")
+	}
+	lastID, err := queryInt(last)
+	if err != nil {
+		http.Error(w, "bad last event ID "+strconv.Quote(last), http.StatusBadRequest)
+		return
+	}
+	var types map[voxa.EventType]bool
+	if v := r.URL.Query().Get("events"); v != "" {
+		types = map[voxa.EventType]bool{}
+		for _, name := range strings.Split(v, ",") {
+			t, err := voxa.ParseEventType(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			types[t] = true
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	missed, sub, ok := h.subscribe(id, tenant(r.Context()), lastID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if sub == nil && len(missed) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if sub != nil {
+		defer h.cancel(id, sub)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	send := func(e sseEvent) error {
+		if types != nil && !types[e.typ] {
+			return nil
+		}
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.id, e.typ, e.data)
+		return err
+	}
+	for _, e := range missed {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+	if sub == nil {
+		return
+	}
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-sub.ch:
+			if !ok {
+				return // fell behind, forgotten or refused
+			}
+			if err := send(e); err != nil {
+				return
+			}
+			flusher.Flush()
+			if e.typ == voxa.EventSessionEnd {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/auth"
+)
+
+func TestEventHubByKey(t *testing.T) {
+	h, err := NewEventHub(EventHubConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authn := newAuthenticator(t, "a", "b")
+	get := func(key, id string) (int, string) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/sessions/"+id+"/events", nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		authn.HTTP(h).ServeHTTP(w, r)
+		return w.Code, w.Body.String()
+	}
+	h.Publish(voxa.Event{Type: voxa.EventSessionStart, SessionID: "s1", Tenant: "a"})
+	h.Publish(voxa.Event{Type: voxa.EventSessionEnd, SessionID: "s1"})
+	for _, tc := range []struct {
+		key  string
+		want int
+	}{
+		{"a", http.StatusOK},
+		{"b", http.StatusNotFound},
+		{"admin", http.StatusOK},
+	} {
+		code, body := get(tc.key, "s1")
+		if code != tc.want {
+			t.Errorf("%s streaming the session of a: %d %q, want %d", tc.key, code, body, tc.want)
+		}
+		if code == http.StatusOK && !strings.Contains(body, "event: session_end") {
+			t.Errorf("%s streaming the session of a: %q, want its events", tc.key, body)
+		}
+	}
+
+	// b subscribes ahead of a session a then starts.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), authn.Authenticate("b"))))
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/v1/sessions/s2/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	for subscribed := false; !subscribed; time.Sleep(time.Millisecond) {
+		h.mu.Lock()
+		subscribed = h.sessions["s2"] != nil && len(h.sessions["s2"].subs) == 1
+		h.mu.Unlock()
+	}
+	h.Publish(voxa.Event{Type: voxa.EventSessionStart, SessionID: "s2", Tenant: "a"})
+	h.Publish(voxa.Event{Type: voxa.EventSessionEnd, SessionID: "s2"})
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "event:") {
+		t.Errorf("b streamed the session of a it subscribed to ahead: %q", body)
+	}
+	if code, _ := get("b", "s2"); code != http.StatusNotFound {
+		t.Errorf("b resuming the session of a: %d, want 404", code)
+	}
+}
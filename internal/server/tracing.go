@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jmarc101/voxa"
+)
+
+// metadataCarrier reads propagated trace context from gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) { metadata.MD(c).Set(key, value) }
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// grpcCarrier returns the incoming metadata of an RPC as a carrier.
+func grpcCarrier(ctx context.Context) propagation.TextMapCarrier {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return metadataCarrier(md)
+}
+
+func (s *Server) tracer() trace.Tracer {
+	return s.current().pipeline.TracerProvider().Tracer(voxa.TracerName)
+}
+
+// startSpan starts the server span of a session, continuing the trace the
+// client propagated in carrier, if any. The pipeline's utterance traces
+// become its children.
+func (s *Server) startSpan(ctx context.Context, name string, carrier propagation.TextMapCarrier, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	return s.tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func rpcAttributes(method string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", "voxa.voxad.v1.Voxad"),
+		attribute.String("rpc.method", method),
+	}
+}
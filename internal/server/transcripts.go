@@ -0,0 +1,517 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jmarc101/voxa"
+	voxadv1 "github.com/jmarc101/voxa/api/gen/voxa/voxad/v1"
+	"github.com/jmarc101/voxa/internal/auth"
+	"github.com/jmarc101/voxa/internal/export"
+	"github.com/jmarc101/voxa/internal/sink"
+)
+
+// errNoTranscripts is returned when the pipeline stores no transcripts.
+var errNoTranscripts = errors.New("transcript storage is not enabled")
+
+// transcripts returns the store of the current pipeline, or nil.
+func (s *Server) transcripts() voxa.TranscriptStore {
+	return s.current().pipeline.Transcripts()
+}
+
+// ListTranscripts implements voxadv1.VoxadServer.
+func (s *Server) ListTranscripts(ctx context.Context, req *voxadv1.ListTranscriptsRequest) (*voxadv1.ListTranscriptsResponse, error) {
+	ts := s.transcripts()
+	if ts == nil {
+		return nil, status.Error(codes.FailedPrecondition, errNoTranscripts.Error())
+	}
+	q := voxa.TranscriptQuery{Limit: int(req.GetLimit()), Tenant: tenant(ctx)}
+	if req.GetBefore() != nil {
+		q.Before = req.GetBefore().AsTime()
+	}
+	sessions, err := ts.Sessions(ctx, q)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	resp := &voxadv1.ListTranscriptsResponse{}
+	for _, sess := range sessions {
+		resp.Sessions = append(resp.Sessions, storedSessionPB(sess))
+	}
+	return resp, nil
+}
+
+// GetTranscript implements voxadv1.VoxadServer.
+func (s *Server) GetTranscript(ctx context.Context, req *voxadv1.GetTranscriptRequest) (*voxadv1.Transcript, error) {
+	ts := s.transcripts()
+	if ts == nil {
+		return nil, status.Error(codes.FailedPrecondition, errNoTranscripts.Error())
+	}
+	t, err := transcript(ctx, ts, req.GetSessionId(), int(req.GetVersion()))
+	switch {
+	case errors.Is(err, voxa.ErrTranscriptNotFound):
+		return nil, status.Errorf(codes.NotFound, "no transcript for session %q", req.GetSessionId())
+	case errors.Is(err, errNoVersion):
+		return nil, status.Errorf(codes.NotFound, "session %q has no version %d", req.GetSessionId(), req.GetVersion())
+	case err != nil:
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return transcriptPB(t, int(req.GetVersion())), nil
+}
+
+// transcriptPB converts t, the given version of a stored transcript.
+func transcriptPB(t storedTranscript, version int) *voxadv1.Transcript {
+	pb := &voxadv1.Transcript{Session: storedSessionPB(t.session), Version: int32(version)}
+	for _, seg := range t.segments {
+		pb.Segments = append(pb.Segments, export.SegmentProto(seg.Segment))
+	}
+	for _, v := range t.versions {
+		pb.Versions = append(pb.Versions, &voxadv1.TranscriptVersion{
+			Version:  int32(v.Number),
+			Provider: v.Provider,
+			Label:    v.Label,
+			Created:  timestamppb.New(v.Created),
+			Segments: int32(v.Segments),
+		})
+	}
+	if sum := t.summary; sum != nil {
+		pb.Summary = &voxadv1.TranscriptSummary{
+			Text:        sum.Text,
+			ActionItems: sum.ActionItems,
+			Label:       sum.Label,
+			Created:     timestamppb.New(sum.Created),
+			Segments:    int32(sum.Segments),
+		}
+	}
+	return pb
+}
+
+// SearchTranscripts implements voxadv1.VoxadServer.
+func (s *Server) SearchTranscripts(ctx context.Context, req *voxadv1.SearchTranscriptsRequest) (*voxadv1.SearchTranscriptsResponse, error) {
+	ts := s.transcripts()
+	if ts == nil {
+		return nil, status.Error(codes.FailedPrecondition, errNoTranscripts.Error())
+	}
+	q := voxa.TranscriptSearch{Text: req.GetQuery(), Session: req.GetSessionId(), Tenant: tenant(ctx), Limit: int(req.GetLimit())}
+	if req.GetSince() != nil {
+		q.Since = req.GetSince().AsTime()
+	}
+	if req.GetUntil() != nil {
+		q.Until = req.GetUntil().AsTime()
+	}
+	hits, err := ts.Search(ctx, q)
+	switch {
+	case errors.Is(err, voxa.ErrEmptyTranscriptSearch):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	resp := &voxadv1.SearchTranscriptsResponse{}
+	for _, h := range hits {
+		resp.Hits = append(resp.Hits, &voxadv1.SearchHit{
+			SessionId: h.Session,
+			Segment:   export.SegmentProto(h.Segment.Segment),
+			Snippet:   h.Snippet,
+			Score:     h.Score,
+			Added:     timestamppb.New(h.Added),
+			Version:   int32(h.Version),
+		})
+	}
+	return resp, nil
+}
+
+// DeleteSession implements voxadv1.VoxadServer.
+func (s *Server) DeleteSession(ctx context.Context, req *voxadv1.DeleteSessionRequest) (*voxadv1.DeleteSessionResponse, error) {
+	switch err := s.deleteSession(ctx, req.GetSessionId()); {
+	case errors.Is(err, errNoTranscripts), errors.Is(err, errSessionRunning):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, voxa.ErrTranscriptNotFound):
+		return nil, status.Errorf(codes.NotFound, "no transcript for session %q", req.GetSessionId())
+	case err != nil:
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &voxadv1.DeleteSessionResponse{}, nil
+}
+
+// errSessionRunning is returned for deleting a running session.
+var errSessionRunning = errors.New("session is running; terminate it first")
+
+// tenant returns the tenant whose stored sessions the key of ctx is limited
+// to: its own name, or empty for admin keys and servers without keys.
+func tenant(ctx context.Context) string {
+	if k := auth.FromContext(ctx); k != nil && !k.Admin() {
+		return k.Name()
+	}
+	return ""
+}
+
+// ownSession returns stored session id if the key of ctx may see it. The
+// sessions of other tenants are as unknown, failing with
+// voxa.ErrTranscriptNotFound.
+func ownSession(ctx context.Context, ts voxa.TranscriptStore, id string) (voxa.StoredSession, error) {
+	sess, err := ts.Session(ctx, id)
+	if err != nil {
+		return voxa.StoredSession{}, err
+	}
+	if t := tenant(ctx); t != "" && sess.Tenant != t {
+		return voxa.StoredSession{}, voxa.ErrTranscriptNotFound
+	}
+	return sess, nil
+}
+
+// deleteSession forgets stored session id. Keys other than admin keys may
+// only delete their own sessions.
+func (s *Server) deleteSession(ctx context.Context, id string) error {
+	ts := s.transcripts()
+	if ts == nil {
+		return errNoTranscripts
+	}
+	if _, ok := s.sessions.Get(id); ok {
+		return errSessionRunning
+	}
+	if _, err := ownSession(ctx, ts, id); err != nil {
+		return err
+	}
+	var by string
+	if k := auth.FromContext(ctx); k != nil {
+		by = k.Name()
+	}
+	p := s.current().pipeline
+	if err := p.DeleteSession(ctx, id); err != nil {
+		return err
+	}
+	p.Logger().Info("session deleted", "session", id, "key", by)
+	return nil
+}
+
+// errNoVersion is returned for versions a session does not have.
+var errNoVersion = errors.New("no such version")
+
+// storedTranscript is a version of the transcript of a session.
+type storedTranscript struct {
+	session  voxa.StoredSession
+	segments []voxa.StoredSegment
+	versions []voxa.TranscriptVersion
+	summary  *voxa.StoredSummary // if summarized
+}
+
+// transcript returns the given version of the transcript of session id,
+// if the key of ctx may see it.
+func transcript(ctx context.Context, ts voxa.TranscriptStore, id string, version int) (storedTranscript, error) {
+	var t storedTranscript
+	var err error
+	if t.session, err = ownSession(ctx, ts, id); err != nil {
+		return t, err
+	}
+	if t.versions, err = ts.Versions(ctx, id); err != nil {
+		return t, err
+	}
+	if version < 0 || version > len(t.versions) {
+		return t, errNoVersion
+	}
+	if t.segments, err = ts.Segments(ctx, id, version); err != nil {
+		return t, err
+	}
+	switch sum, err := ts.Summary(ctx, id); {
+	case err == nil:
+		t.summary = &sum
+	case !errors.Is(err, voxa.ErrTranscriptNotFound):
+		return t, err
+	}
+	return t, nil
+}
+
+func storedSessionPB(sess voxa.StoredSession) *voxadv1.StoredSession {
+	pb := &voxadv1.StoredSession{
+		SessionId: sess.ID,
+		Started:   timestamppb.New(sess.Started),
+		Segments:  int32(sess.Segments),
+		Versions:  int32(sess.Versions),
+		Tenant:    sess.Tenant,
+		Metadata:  sess.Metadata,
+	}
+	if !sess.Ended.IsZero() {
+		pb.Ended = timestamppb.New(sess.Ended)
+	}
+	return pb
+}
+
+// TranscriptsHandler serves the stored transcripts as JSON:
+//
+//	GET  /v1/transcripts[?limit=N&before=RFC3339]  → {"sessions":[WireSession...]}
+//	GET  /v1/transcripts/{session_id}[?version=N]  → WireTranscript
+//	GET  /v1/transcripts/{session_id}/summary      → WireSummary
+//	POST /v1/transcripts/{session_id}/summary      → WireSummary, summarized again
+//	DELETE /v1/transcripts/{session_id}            → 204, the session forgotten
+//
+// A transcript is also served with format=ndjson as its WireSegments, one
+// per line, and with format=protobuf as a binary voxadv1.Transcript, as
+// GetTranscript returns it.
+//
+// Deleting a session removes its transcripts, summary, archived audio and
+// conversation state; see voxa.Pipeline.DeleteSession. Running sessions
+// are refused with 409.
+//
+// Keys other than admin keys only see, summarize and delete the sessions
+// they opened; those of other tenants answer 404 as unknown ones do.
+//
+// Mount it on both paths. It answers 501 unless the pipeline stores
+// transcripts, and summarizes again only if it summarizes sessions.
+func (s *Server) TranscriptsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/transcripts"), "/")
+		id, summary := strings.CutSuffix(id, "/summary")
+		allow := http.MethodGet
+		switch {
+		case summary:
+			allow += ", " + http.MethodPost
+		case id != "":
+			allow += ", " + http.MethodDelete
+		}
+		if r.Method != http.MethodGet && !strings.Contains(allow, r.Method) {
+			w.Header().Set("Allow", allow)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ts := s.transcripts()
+		if ts == nil {
+			http.Error(w, errNoTranscripts.Error(), http.StatusNotImplemented)
+			return
+		}
+		if summary {
+			s.summary(w, r, ts, id)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			switch err := s.deleteSession(r.Context(), id); {
+			case errors.Is(err, errSessionRunning):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, voxa.ErrTranscriptNotFound):
+				http.Error(w, "no transcript for session "+strconv.Quote(id), http.StatusNotFound)
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+		if id == "" {
+			s.listTranscripts(w, r, ts)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "ndjson" && format != "protobuf" {
+			http.Error(w, "bad format "+strconv.Quote(format)+", want json, ndjson or protobuf", http.StatusBadRequest)
+			return
+		}
+		version, err := queryInt(r.URL.Query().Get("version"))
+		if err != nil {
+			http.Error(w, "bad version "+strconv.Quote(r.URL.Query().Get("version")), http.StatusBadRequest)
+			return
+		}
+		t, err := transcript(r.Context(), ts, id, version)
+		switch {
+		case errors.Is(err, voxa.ErrTranscriptNotFound):
+			http.Error(w, "no transcript for session "+strconv.Quote(id), http.StatusNotFound)
+			return
+		case errors.Is(err, errNoVersion):
+			http.Error(w, "session "+strconv.Quote(id)+" has no version "+strconv.Itoa(version), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		switch format {
+		case "ndjson":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			enc := json.NewEncoder(w)
+			for _, seg := range t.segments {
+				if enc.Encode(wireSegment(seg.Segment)) != nil {
+					return
+				}
+			}
+			return
+		case "protobuf":
+			b, err := proto.Marshal(transcriptPB(t, version))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", sink.Protobuf)
+			_, _ = w.Write(b)
+			return
+		}
+		wt := WireTranscript{Session: wireSession(t.session), Version: version, Segments: []*WireSegment{}, Versions: []WireVersion{}}
+		for _, seg := range t.segments {
+			wt.Segments = append(wt.Segments, wireSegment(seg.Segment))
+		}
+		for _, v := range t.versions {
+			wt.Versions = append(wt.Versions, WireVersion{Version: v.Number, Provider: v.Provider, Label: v.Label, Created: v.Created, Segments: v.Segments})
+		}
+		if t.summary != nil {
+			wt.Summary = wireSummary(*t.summary)
+		}
+		writeJSON(w, wt)
+	})
+}
+
+// summary serves the summary of session id, making it first for POST.
+func (s *Server) summary(w http.ResponseWriter, r *http.Request, ts voxa.TranscriptStore, id string) {
+	var sum voxa.StoredSummary
+	_, err := ownSession(r.Context(), ts, id)
+	missing, failed := "no summary for session ", http.StatusServiceUnavailable
+	switch {
+	case err != nil:
+		missing = "no transcript for session "
+	case r.Method == http.MethodPost:
+		sum, err = s.current().pipeline.Summarize(r.Context(), id)
+		missing = "no transcript for session "
+		failed = http.StatusBadGateway // the model failing is the likelier cause
+	default:
+		sum, err = ts.Summary(r.Context(), id)
+	}
+	switch {
+	case errors.Is(err, voxa.ErrSummariesDisabled):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	case errors.Is(err, voxa.ErrTranscriptNotFound):
+		http.Error(w, missing+strconv.Quote(id), http.StatusNotFound)
+	case errors.Is(err, voxa.ErrNothingToSummarize):
+		http.Error(w, "session "+strconv.Quote(id)+" has nothing to summarize", http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), failed)
+	default:
+		writeJSON(w, wireSummary(sum))
+	}
+}
+
+func wireSummary(sum voxa.StoredSummary) *WireSummary {
+	ws := &WireSummary{Text: sum.Text, ActionItems: sum.ActionItems, Label: sum.Label, Created: sum.Created, Segments: sum.Segments}
+	if ws.ActionItems == nil {
+		ws.ActionItems = []string{}
+	}
+	return ws
+}
+
+func (s *Server) listTranscripts(w http.ResponseWriter, r *http.Request, ts voxa.TranscriptStore) {
+	q := voxa.TranscriptQuery{Tenant: tenant(r.Context())}
+	v := r.URL.Query()
+	var err error
+	if q.Limit, err = queryInt(v.Get("limit")); err != nil {
+		http.Error(w, "bad limit "+strconv.Quote(v.Get("limit")), http.StatusBadRequest)
+		return
+	}
+	if q.Before, err = queryTime(v.Get("before")); err != nil {
+		http.Error(w, "bad before "+strconv.Quote(v.Get("before"))+", want RFC 3339", http.StatusBadRequest)
+		return
+	}
+	sessions, err := ts.Sessions(r.Context(), q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	list := WireSessionList{Sessions: []WireSession{}}
+	for _, sess := range sessions {
+		list.Sessions = append(list.Sessions, wireSession(sess))
+	}
+	writeJSON(w, list)
+}
+
+// SearchHandler serves transcript searches as JSON:
+//
+//	GET /v1/search?q=QUERY[&session=ID&since=RFC3339&until=RFC3339&limit=N]  → WireSearchResults
+//
+// Keys other than admin keys only search the sessions they opened. It
+// answers 501 unless the pipeline stores transcripts.
+func (s *Server) SearchHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ts := s.transcripts()
+		if ts == nil {
+			http.Error(w, errNoTranscripts.Error(), http.StatusNotImplemented)
+			return
+		}
+		v := r.URL.Query()
+		q := voxa.TranscriptSearch{Text: v.Get("q"), Session: v.Get("session"), Tenant: tenant(r.Context())}
+		var err error
+		if q.Limit, err = queryInt(v.Get("limit")); err != nil {
+			http.Error(w, "bad limit "+strconv.Quote(v.Get("limit")), http.StatusBadRequest)
+			return
+		}
+		for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+			if *t, err = queryTime(v.Get(name)); err != nil {
+				http.Error(w, "bad "+name+" "+strconv.Quote(v.Get(name))+", want RFC 3339", http.StatusBadRequest)
+				return
+			}
+		}
+		hits, err := ts.Search(r.Context(), q)
+		switch {
+		case errors.Is(err, voxa.ErrEmptyTranscriptSearch):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, WireSearchResults{Hits: WireHits(hits)})
+	})
+}
+
+// WireHits converts search hits to their JSON form.
+func WireHits(hits []voxa.TranscriptHit) []WireHit {
+	out := make([]WireHit, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, WireHit{
+			SessionID: h.Session,
+			Segment:   wireSegment(h.Segment.Segment),
+			Snippet:   h.Snippet,
+			Score:     h.Score,
+			Added:     h.Added,
+			Version:   h.Version,
+		})
+	}
+	return out
+}
+
+func queryInt(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err == nil && n < 0 {
+		err = errors.New("negative")
+	}
+	return n, err
+}
+
+func queryTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
+
+func wireSession(sess voxa.StoredSession) WireSession {
+	ws := WireSession{SessionID: sess.ID, Tenant: sess.Tenant, Started: sess.Started, Segments: sess.Segments, Versions: sess.Versions, Metadata: sess.Metadata}
+	if !sess.Ended.IsZero() {
+		ws.Ended = &sess.Ended
+	}
+	return ws
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
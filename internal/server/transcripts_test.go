@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	voxadv1 "github.com/jmarc101/voxa/api/gen/voxa/voxad/v1"
+	"github.com/jmarc101/voxa/internal/auth"
+	"github.com/jmarc101/voxa/internal/stt"
+	"github.com/jmarc101/voxa/stttest"
+)
+
+// summarizer counts the summaries it is asked for.
+type summarizer struct{ calls int }
+
+func (s *summarizer) Summarize(context.Context, []stt.Segment) (voxa.Summary, error) {
+	s.calls++
+	return voxa.Summary{Text: "a chat about the weather"}, nil
+}
+
+// newAuthenticator returns an authenticator of the keys named, an admin
+// key among them, whose secrets are their names.
+func newAuthenticator(t *testing.T, names ...string) *auth.Authenticator {
+	t.Helper()
+	cfg := auth.Config{Keys: []auth.KeyConfig{{Name: "admin", Admin: true, SHA256: secretHash("admin")}}}
+	for _, name := range names {
+		cfg.Keys = append(cfg.Keys, auth.KeyConfig{Name: name, SHA256: secretHash(name)})
+	}
+	a, err := auth.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func secretHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTranscriptServer returns a server storing a session of tenant a and
+// one of tenant b, both about the weather.
+func newTranscriptServer(t *testing.T) (*Server, *summarizer) {
+	t.Helper()
+	ctx := context.Background()
+	ts, err := voxa.NewSQLiteTranscriptStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tenant := range []string{"a", "b"} {
+		id := "session-" + tenant
+		if err := ts.StartSession(ctx, id, tenant, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.AddSegment(ctx, id, stt.Segment{UtteranceID: "1", Text: "the weather is fine", Final: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum := &summarizer{}
+	p, err := voxa.NewPipeline(voxa.Config{
+		Recognizer:  stttest.New().Config(),
+		Transcripts: ts,
+		Summary:     &voxa.SummaryConfig{Summarizer: sum},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return New(p, nil, nil), sum
+}
+
+func TestTranscriptsByTenant(t *testing.T) {
+	s, sum := newTranscriptServer(t)
+	authn := newAuthenticator(t, "a", "b")
+	mux := http.NewServeMux()
+	mux.Handle("/v1/transcripts", authn.HTTP(s.TranscriptsHandler()))
+	mux.Handle("/v1/transcripts/", authn.HTTP(s.TranscriptsHandler()))
+	mux.Handle("/v1/search", authn.HTTP(s.SearchHandler()))
+	do := func(key, method, path string) (int, string) {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code, w.Body.String()
+	}
+	sessions := func(key, path string) []string {
+		t.Helper()
+		code, body := do(key, http.MethodGet, path)
+		if code != http.StatusOK {
+			t.Fatalf("%s GET %s: %d %s", key, path, code, body)
+		}
+		var resp struct {
+			Sessions []struct {
+				SessionID string `json:"session_id"`
+			}
+			Hits []struct {
+				SessionID string `json:"session_id"`
+			}
+		}
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range resp.Sessions {
+			ids = append(ids, s.SessionID)
+		}
+		for _, h := range resp.Hits {
+			ids = append(ids, h.SessionID)
+		}
+		return ids
+	}
+
+	for _, tc := range []struct {
+		key, path string
+		want      string
+	}{
+		{"b", "/v1/transcripts", "session-b"},
+		{"b", "/v1/search?q=weather", "session-b"},
+		{"b", "/v1/search?q=weather&session=session-a", ""},
+		{"a", "/v1/search?q=weather", "session-a"},
+		{"admin", "/v1/transcripts", "session-a session-b"},
+		{"admin", "/v1/search?q=weather", "session-a session-b"},
+	} {
+		ids := sessions(tc.key, tc.path)
+		slices.Sort(ids)
+		if got := strings.Join(ids, " "); got != tc.want {
+			t.Errorf("%s GET %s: sessions %q, want %q", tc.key, tc.path, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		key, method, path string
+		want              int
+	}{
+		{"b", http.MethodGet, "/v1/transcripts/session-a", http.StatusNotFound},
+		{"b", http.MethodGet, "/v1/transcripts/session-a?format=ndjson", http.StatusNotFound},
+		{"b", http.MethodGet, "/v1/transcripts/session-a/summary", http.StatusNotFound},
+		{"b", http.MethodPost, "/v1/transcripts/session-a/summary", http.StatusNotFound},
+		{"b", http.MethodDelete, "/v1/transcripts/session-a", http.StatusNotFound},
+		{"b", http.MethodGet, "/v1/transcripts/session-b", http.StatusOK},
+		{"a", http.MethodGet, "/v1/transcripts/session-a", http.StatusOK},
+		{"admin", http.MethodGet, "/v1/transcripts/session-b", http.StatusOK},
+	} {
+		if code, body := do(tc.key, tc.method, tc.path); code != tc.want {
+			t.Errorf("%s %s %s: %d %s, want %d", tc.key, tc.method, tc.path, code, body, tc.want)
+		}
+	}
+	if sum.calls != 0 {
+		t.Errorf("summarized %d times for another tenant", sum.calls)
+	}
+	if code, body := do("a", http.MethodPost, "/v1/transcripts/session-a/summary"); code != http.StatusOK || sum.calls != 1 {
+		t.Errorf("a summarizing its own session: %d %s, %d summaries", code, body, sum.calls)
+	}
+	if code, _ := do("a", http.MethodGet, "/v1/transcripts/session-a"); code != http.StatusOK {
+		t.Errorf("session-a gone after b deleted it: %d", code)
+	}
+}
+
+func TestTranscriptRPCsByTenant(t *testing.T) {
+	s, _ := newTranscriptServer(t)
+	authn := newAuthenticator(t, "a", "b")
+	ctx := auth.NewContext(context.Background(), authn.Authenticate("b"))
+
+	list, err := s.ListTranscripts(ctx, &voxadv1.ListTranscriptsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetSessions()) != 1 || list.GetSessions()[0].GetSessionId() != "session-b" {
+		t.Errorf("b lists %v, want session-b only", list.GetSessions())
+	}
+	search, err := s.SearchTranscripts(ctx, &voxadv1.SearchTranscriptsRequest{Query: "weather"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(search.GetHits()) != 1 || search.GetHits()[0].GetSessionId() != "session-b" {
+		t.Errorf("b finds %v, want session-b only", search.GetHits())
+	}
+	if _, err := s.GetTranscript(ctx, &voxadv1.GetTranscriptRequest{SessionId: "session-a"}); err == nil {
+		t.Error("b read the transcript of session-a")
+	}
+	if _, err := s.DeleteSession(ctx, &voxadv1.DeleteSessionRequest{SessionId: "session-a"}); err == nil {
+		t.Error("b deleted session-a")
+	}
+	admin := auth.NewContext(context.Background(), authn.Authenticate("admin"))
+	if list, err := s.ListTranscripts(admin, &voxadv1.ListTranscriptsRequest{}); err != nil || len(list.GetSessions()) != 2 {
+		t.Errorf("admin lists %v, %v; want both sessions", list.GetSessions(), err)
+	}
+}
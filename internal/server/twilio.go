@@ -0,0 +1,475 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/g711"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// maxTwilioMessage bounds a Twilio message; media messages carry 20ms of
+// audio.
+const maxTwilioMessage = 64 << 10
+
+// callbackQueueSize bounds the events of a call waiting to be posted.
+const callbackQueueSize = 256
+
+// TwilioConfig configures TwilioHandler.
+type TwilioConfig struct {
+	// Callback is the http or https URL events are posted to, as
+	// WireTwilioEvent.
+	Callback string
+	// AuthToken, if set, is the Twilio auth token requests are checked
+	// against: connections without a valid X-Twilio-Signature are refused.
+	AuthToken string
+	// URL is the URL Twilio is given in <Stream>, which the signature
+	// covers. Defaults to the wss:// URL of the request, which is wrong
+	// behind a proxy that rewrites it.
+	URL string
+	// Partials posts partial segments too, not just finals.
+	Partials bool
+	// Notice, if set, is played to callers before their call is
+	// transcribed.
+	Notice *TwilioNotice
+	// Retry tunes how failed posts are retried.
+	Retry resilience.Config
+	// Client posts the events. Defaults to a client with a 10 second
+	// timeout.
+	Client *http.Client
+}
+
+// TwilioHandler serves Twilio Media Streams: a <Stream> pointed at it
+// has each of its tracks transcribed as a session of its own, named
+// "twilio-" and the call SID, with "-outbound" appended for the outbound
+// track, and the events of the call posted to cfg.Callback as they
+// happen.
+func (s *Server) TwilioHandler(cfg TwilioConfig) (http.Handler, error) {
+	u, err := url.Parse(cfg.Callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("twilio: callback %q is not an http or https URL", cfg.Callback)
+	}
+	log := logging.With(s.current().pipeline.Logger(), "transport", "twilio")
+	retry, err := resilience.New(cfg.Retry, log)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	h := &twilioHandler{s: s, cfg: cfg, retry: retry, log: log}
+	if cfg.Notice != nil {
+		if h.notice, err = loadNotice(*cfg.Notice, s.current().tts); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+type twilioHandler struct {
+	s      *Server
+	cfg    TwilioConfig
+	retry  *resilience.Policy
+	log    logging.Logger
+	notice []byte // µ-law, with cfg.Notice
+}
+
+func (h *twilioHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.AuthToken != "" && !h.signed(r) {
+		http.Error(w, "invalid X-Twilio-Signature", http.StatusForbidden)
+		return
+	}
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return // Accept already wrote the HTTP error
+	}
+	conn.SetReadLimit(maxTwilioMessage)
+	ctx, span := h.s.startSpan(r.Context(), "voxad.Twilio", propagation.HeaderCarrier(r.Header),
+		attribute.String("http.route", r.URL.Path))
+	err = h.serve(ctx, conn, r.RemoteAddr)
+	endSpan(span, err)
+	switch {
+	case err == nil:
+		conn.Close(websocket.StatusNormalClosure, "")
+	case websocket.CloseStatus(err) != -1:
+		// Twilio closed the socket.
+	default:
+		h.log.Warn("twilio stream failed", "peer", r.RemoteAddr, "error", err)
+		conn.Close(websocket.StatusInternalError, truncate(err.Error(), 120))
+	}
+}
+
+// signed reports whether r carries the signature Twilio computes for its
+// WebSocket requests: the base64 HMAC-SHA1, keyed with the auth token, of
+// the full URL.
+func (h *twilioHandler) signed(r *http.Request) bool {
+	want := h.cfg.URL
+	if want == "" {
+		want = "wss://" + r.Host + r.URL.RequestURI()
+	}
+	mac := hmac.New(sha1.New, []byte(h.cfg.AuthToken))
+	mac.Write([]byte(want))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(sig), []byte(r.Header.Get("X-Twilio-Signature"))) == 1
+}
+
+// twilioMessage is a message of the Media Streams protocol.
+type twilioMessage struct {
+	Event     string `json:"event"`
+	StreamSID string `json:"streamSid"`
+	Start     *struct {
+		AccountSID       string            `json:"accountSid"`
+		CallSID          string            `json:"callSid"`
+		StreamSID        string            `json:"streamSid"`
+		Tracks           []string          `json:"tracks"`
+		CustomParameters map[string]string `json:"customParameters"`
+		MediaFormat      struct {
+			Encoding   string `json:"encoding"`
+			SampleRate int    `json:"sampleRate"`
+			Channels   int    `json:"channels"`
+		} `json:"mediaFormat"`
+	} `json:"start"`
+	Media *struct {
+		Track   string `json:"track"`
+		Payload string `json:"payload"`
+	} `json:"media"`
+	DTMF *struct {
+		Digit string `json:"digit"`
+	} `json:"dtmf"`
+	Mark *twilioMark `json:"mark"`
+}
+
+// twilioFormat is the only audio format Media Streams send.
+var twilioFormat = audio.Format{SampleRate: 8000, Channels: 1}
+
+func (h *twilioHandler) serve(ctx context.Context, conn *websocket.Conn, remote string) error {
+	// Twilio sends "connected", then "start".
+	var msg twilioMessage
+	for msg.Event != "start" {
+		msg = twilioMessage{}
+		if err := readJSON(ctx, conn, &msg); err != nil {
+			return err
+		}
+		if msg.Event != "connected" && msg.Event != "start" {
+			return fmt.Errorf("unexpected %q message before start", msg.Event)
+		}
+	}
+	st := msg.Start
+	switch {
+	case st == nil || st.CallSID == "":
+		return errors.New("start message without a call SID")
+	case st.MediaFormat.Encoding != "audio/x-mulaw" || st.MediaFormat.SampleRate != twilioFormat.SampleRate || st.MediaFormat.Channels != twilioFormat.Channels:
+		return fmt.Errorf("unsupported media format %s at %dHz, %d channels",
+			st.MediaFormat.Encoding, st.MediaFormat.SampleRate, st.MediaFormat.Channels)
+	}
+	base := WireTwilioEvent{AccountSID: st.AccountSID, CallSID: st.CallSID, StreamSID: st.StreamSID, Parameters: st.CustomParameters}
+	if base.StreamSID == "" {
+		base.StreamSID = msg.StreamSID
+	}
+	log := logging.With(h.log, "call_sid", st.CallSID)
+	out := h.newPoster(log)
+	defer out.close()
+
+	names := st.Tracks
+	if len(names) == 0 {
+		names = []string{"inbound"}
+	}
+	tracks := map[string]*twilioTrack{}
+	defer func() {
+		for _, name := range names {
+			if t := tracks[name]; t != nil {
+				t.end(nil)
+			}
+		}
+	}()
+	for _, name := range names {
+		if tracks[name] != nil {
+			continue
+		}
+		t, err := h.openTrack(ctx, base, name, remote, out)
+		if err != nil {
+			return err
+		}
+		tracks[name] = t
+	}
+
+	// With a notice, the audio is dropped until Twilio has played it.
+	var state string
+	var deadline time.Time
+	if h.notice != nil {
+		state = ConsentPending
+		consent(log, names, tracks, state)
+		defer func() {
+			if state == ConsentPending {
+				consent(log, names, tracks, ConsentHungUp)
+			}
+		}()
+		if err := h.playNotice(ctx, conn, base.StreamSID); err != nil {
+			return err
+		}
+		deadline = h.noticeDeadline(time.Now())
+	}
+
+	var pcm []int16
+	for {
+		if stopping(tracks) {
+			return nil // the deferred ends transcribe the rest
+		}
+		if state == ConsentPending && time.Now().After(deadline) {
+			state = ConsentFailed
+			consent(log, names, tracks, state)
+		}
+		msg = twilioMessage{}
+		if err := readJSON(ctx, conn, &msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		switch msg.Event {
+		case "media":
+			if msg.Media == nil || (state != "" && state != ConsentNotified) {
+				continue
+			}
+			name := msg.Media.Track
+			if name == "" {
+				name = "inbound"
+			}
+			t := tracks[name]
+			if t == nil || t.done {
+				continue
+			}
+			payload, err := base64.StdEncoding.DecodeString(msg.Media.Payload)
+			if err != nil {
+				return fmt.Errorf("bad media payload: %w", err)
+			}
+			pcm = g711.DecodeULaw(pcm[:0], payload)
+			t.write(pcm)
+		case "dtmf":
+			// The pipeline detects the keys itself if it can, with their
+			// time, and on every track.
+			if msg.DTMF != nil && !h.s.current().pipeline.DetectsDTMF() {
+				ev := base
+				ev.Event, ev.Digit = TwilioDTMF, msg.DTMF.Digit
+				out.push(ev)
+			}
+		case "mark":
+			if msg.Mark != nil && msg.Mark.Name == noticeMark && state == ConsentPending {
+				state = ConsentNotified
+				consent(log, names, tracks, state)
+			}
+		case "stop":
+			return nil
+		}
+	}
+}
+
+// stopping reports whether the sessions of every track are to stop.
+func stopping(tracks map[string]*twilioTrack) bool {
+	for _, t := range tracks {
+		select {
+		case <-t.sess.Stopping():
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// twilioTrack is the session transcribing one track of a call.
+type twilioTrack struct {
+	h       *twilioHandler
+	g       held
+	sess    *Session
+	vs      *voxa.Stream
+	ev      WireTwilioEvent
+	out     *poster
+	ended   func(error)
+	results chan struct{}
+	written int  // samples
+	done    bool // once the track has ended
+}
+
+func (h *twilioHandler) openTrack(ctx context.Context, base WireTwilioEvent, name, remote string, out *poster) (*twilioTrack, error) {
+	id := "twilio-" + base.CallSID
+	if name != "inbound" {
+		id += "-" + name
+	}
+	g, err := h.s.acquire(ctx, base.Parameters["profile"])
+	if err != nil {
+		return nil, err
+	}
+	ctx, sess, err := h.s.sessions.Start(ctx, id, KindTranscribe, "twilio", remote)
+	if err != nil {
+		h.s.release(g)
+		return nil, err
+	}
+	_, ended := h.s.logSession(sess)
+	ev := base
+	ev.Track, ev.SessionID = name, sess.ID
+	t := &twilioTrack{h: h, g: g, sess: sess, ev: ev, out: out, ended: ended, results: make(chan struct{})}
+	t.vs, err = g.pipeline.NewStream(ctx, twilioFormat, voxa.StreamOptions{
+		SessionID: sess.ID,
+		Tenant:    sess.Key,
+		OnDTMF: func(d voxa.DTMFDigit) {
+			e := ev
+			e.Event, e.Digit, e.OffsetMS = TwilioDTMF, d.Key, d.Offset.Milliseconds()
+			out.push(e)
+		},
+		OnAMD: func(d voxa.AMDDecision) {
+			e := ev
+			e.Event, e.AMD = TwilioAMD, wireAMD(d)
+			out.push(e)
+		},
+		OnDeferred: func(err error) {
+			e := ev
+			e.Event, e.Error = TwilioDeferred, err.Error()
+			out.push(e)
+		},
+	})
+	if err != nil {
+		close(t.results)
+		t.end(err)
+		return nil, err
+	}
+	h.s.sessions.Attach(sess.ID, t.vs)
+	started := ev
+	started.Event = TwilioStarted
+	out.push(started)
+	go func() {
+		defer close(t.results)
+		for seg := range t.vs.Results() {
+			if !seg.Final && !h.cfg.Partials {
+				continue
+			}
+			e := ev
+			e.Event, e.Segment = TwilioSegment, wireSegment(seg)
+			out.push(e)
+		}
+	}()
+	return t, nil
+}
+
+// write transcribes pcm; a failure ends the track, and the rest of its
+// audio is ignored.
+func (t *twilioTrack) write(pcm []int16) {
+	fr := audio.Frame{Format: twilioFormat, Data: pcm, Offset: twilioFormat.Duration(t.written)}
+	t.written += len(pcm)
+	if err := t.vs.WriteFrame(fr); err != nil {
+		t.end(err)
+	}
+}
+
+// end closes the stream of the track once its last segments are posted,
+// and ends its session.
+func (t *twilioTrack) end(err error) {
+	if t.done {
+		return
+	}
+	t.done = true
+	if t.vs != nil {
+		if cerr := t.vs.Close(); err == nil {
+			err = cerr
+		}
+		<-t.results
+		if err == nil {
+			err = t.vs.Err()
+		}
+	}
+	ev := t.ev
+	ev.Event = TwilioEnded
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	t.out.push(ev)
+	t.ended(err)
+	t.h.s.sessions.End(t.sess.ID)
+	t.h.s.release(t.g)
+}
+
+// poster posts the events of a call to the callback, in order, retrying
+// those that fail. Events are dropped when the callback falls too far
+// behind.
+type poster struct {
+	h     *twilioHandler
+	log   logging.Logger
+	mu    sync.Mutex
+	queue chan WireTwilioEvent
+	done  chan struct{}
+}
+
+func (h *twilioHandler) newPoster(log logging.Logger) *poster {
+	p := &poster{h: h, log: log, queue: make(chan WireTwilioEvent, callbackQueueSize), done: make(chan struct{})}
+	go p.run()
+	return p
+}
+
+func (p *poster) push(ev WireTwilioEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case p.queue <- ev:
+	default:
+		p.log.Warn("callback falling behind, event dropped", "event", ev.Event, "session", ev.SessionID)
+	}
+}
+
+// close waits for the events queued to be posted.
+func (p *poster) close() {
+	p.mu.Lock()
+	close(p.queue)
+	p.mu.Unlock()
+	<-p.done
+}
+
+func (p *poster) run() {
+	defer close(p.done)
+	for ev := range p.queue {
+		body, err := json.Marshal(ev)
+		if err == nil {
+			err = p.h.retry.Do(context.Background(), func() error { return p.post(body) })
+		}
+		if err != nil {
+			p.log.Warn("posting to callback failed", "event", ev.Event, "session", ev.SessionID, "error", err)
+		}
+	}
+}
+
+func (p *poster) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, p.h.cfg.Callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.h.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(msg))}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
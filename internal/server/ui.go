@@ -0,0 +1,43 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/jmarc101/voxa/internal/auth"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// UIHandler serves a web page for trying voxad out and watching it, with
+// nothing to install: it captures the microphone and streams it over the
+// WebSocket protocol of /v1/transcribe, showing partials as they come,
+// and lists the active sessions with a graph of their latency, polled
+// from:
+//
+//	GET /ui/sessions  → WireActiveSessions, without keys, peers or costs
+//
+// The page itself is public; with keys configured in a, it asks for one
+// and sends it as api_key, which the sessions need, as does
+// /v1/transcribe. Mount it on /ui/.
+func (s *Server) UIHandler(a *auth.Authenticator) http.Handler {
+	static, _ := fs.Sub(uiFiles, "ui")
+	var sessions http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list := WireActiveSessions{Sessions: []WireActiveSession{}}
+		for _, sess := range s.sessions.List() {
+			ws := s.wireActiveSession(sess)
+			ws.Key, ws.Peer, ws.Cost = "", "", nil
+			list.Sessions = append(list.Sessions, ws)
+		}
+		writeJSON(w, list)
+	})
+	if a != nil {
+		sessions = a.HTTP(sessions)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /ui/sessions", sessions)
+	mux.Handle("GET /ui/", http.StripPrefix("/ui/", http.FileServerFS(static)))
+	return mux
+}
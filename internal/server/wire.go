@@ -0,0 +1,570 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio/endpoint"
+	"github.com/jmarc101/voxa/internal/audio/vad"
+	"github.com/jmarc101/voxa/internal/auth"
+)
+
+// WebSocket wire schema.
+//
+// A browser opens ws://<voxad>/v1/transcribe and exchanges:
+//
+//	client → server  text    ClientMessage{type:"start"}      exactly once, first
+//	client → server  binary  PCM16 little-endian mono audio    any chunk size
+//	client → server  text    ClientMessage{type:"flush"|"end"}
+//	server → client  text    ServerMessage                     JSON events
+//
+// A profile query parameter, as in /v1/transcribe?profile=acme, runs the
+// session on that server profile rather than the API key's; see Profile.
+//
+// Partial segments follow the full-replace protocol: a newer partial for the
+// same utterance_id replaces the previous one, so the server may coalesce
+// partials when the client reads slowly. Finals are never dropped.
+
+// Client message types.
+const (
+	MsgStart = "start"
+	MsgFlush = "flush"
+	MsgEnd   = "end"
+)
+
+// Server message types.
+const (
+	MsgStarted  = "started"
+	MsgSegment  = "segment"
+	MsgVAD      = "vad"
+	MsgIntent   = "intent"
+	MsgLanguage = "language"
+	MsgDeferred = "deferred"
+	MsgError    = "error"
+)
+
+// ClientMessage is a JSON control message from the client.
+type ClientMessage struct {
+	// Type is one of MsgStart, MsgFlush or MsgEnd.
+	Type string `json:"type"`
+	// SessionID optionally names the session (start only).
+	SessionID string `json:"session_id,omitempty"`
+	// SampleRate of the binary audio frames in Hz (start only).
+	SampleRate int `json:"sample_rate,omitempty"`
+	// VAD enables voice activity gating (start only).
+	VAD bool `json:"vad,omitempty"`
+	// Phrases bias recognition toward words and phrases the audio is
+	// likely to contain (start only).
+	Phrases []WirePhrase `json:"phrases,omitempty"`
+	// Priority is interactive, normal (the default) or batch: how the
+	// session's recognition is scheduled against the others' on a
+	// saturated server (start only).
+	Priority string `json:"priority,omitempty"`
+	// Language is the ISO 639-1 code of the language spoken, in place of
+	// the server's, for recognizers that take one (start only).
+	Language string `json:"language,omitempty"`
+	// Model names the model of the recognizer's provider to transcribe
+	// with, in place of the server's (start only).
+	Model string `json:"model,omitempty"`
+	// Endpointing, if set, decides where utterances end in place of the
+	// server's endpointing (start only).
+	Endpointing *WireEndpointing `json:"endpointing,omitempty"`
+}
+
+// WireEndpointing is the endpointing a session asks for; see
+// voxa.EndpointingConfig. Zero fields keep their defaults.
+type WireEndpointing struct {
+	MinSilenceMS    int64 `json:"min_silence_ms,omitempty"`
+	FinishSilenceMS int64 `json:"finish_silence_ms,omitempty"`
+	MaxUtteranceMS  int64 `json:"max_utterance_ms,omitempty"`
+}
+
+func (e *WireEndpointing) config() *voxa.EndpointingConfig {
+	if e == nil {
+		return nil
+	}
+	return &voxa.EndpointingConfig{
+		MinSilence:    time.Duration(e.MinSilenceMS) * time.Millisecond,
+		FinishSilence: time.Duration(e.FinishSilenceMS) * time.Millisecond,
+		MaxUtterance:  time.Duration(e.MaxUtteranceMS) * time.Millisecond,
+	}
+}
+
+// WirePhrase is a word or phrase recognition should favour.
+type WirePhrase struct {
+	Text string `json:"text"`
+	// Boost weights the phrase relative to the others; zero means 1.
+	Boost float32 `json:"boost,omitempty"`
+}
+
+// maxPhrases bounds the phrases a session may bring.
+const maxPhrases = 1000
+
+// checkPhrases validates the phrases a client opened a session with.
+func checkPhrases(ps []voxa.Phrase) error {
+	if len(ps) > maxPhrases {
+		return fmt.Errorf("more than %d phrases", maxPhrases)
+	}
+	for _, p := range ps {
+		switch {
+		case strings.TrimSpace(p.Text) == "":
+			return errors.New("empty phrase")
+		case p.Boost < 0:
+			return fmt.Errorf("phrase %q has a negative boost", p.Text)
+		}
+	}
+	return nil
+}
+
+// Bounds of the endpointing sessions may ask for.
+const (
+	minEndpointSilence = 100 * time.Millisecond
+	maxEndpointSilence = 10 * time.Second
+	minMaxUtterance    = time.Second
+)
+
+var (
+	languageCode = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+	modelName    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]{0,127}$`)
+)
+
+// checkOverrides validates the language, model and endpointing a session
+// opened in ctx asks for, the language and model against its API key.
+func checkOverrides(ctx context.Context, lang, model string, ep *voxa.EndpointingConfig) error {
+	switch {
+	case lang != "" && !languageCode.MatchString(lang):
+		return fmt.Errorf("language %q is not an ISO 639-1 code", lang)
+	case model != "" && !modelName.MatchString(model):
+		return fmt.Errorf("bad model name %q", model)
+	}
+	if k := auth.FromContext(ctx); k != nil {
+		switch {
+		case lang != "" && !k.AllowsLanguage(lang):
+			return fmt.Errorf("%w: language %q", auth.ErrNotAllowed, lang)
+		case model != "" && !k.AllowsModel(model):
+			return fmt.Errorf("%w: model %q", auth.ErrNotAllowed, model)
+		}
+	}
+	if ep == nil {
+		return nil
+	}
+	for _, d := range []struct {
+		name string
+		v    time.Duration
+	}{{"min_silence", ep.MinSilence}, {"finish_silence", ep.FinishSilence}} {
+		if d.v != 0 && (d.v < minEndpointSilence || d.v > maxEndpointSilence) {
+			return fmt.Errorf("endpointing %s %v is not within [%v, %v]", d.name, d.v, minEndpointSilence, maxEndpointSilence)
+		}
+	}
+	if ep.MaxUtterance != 0 && ep.MaxUtterance < minMaxUtterance {
+		return fmt.Errorf("endpointing max_utterance %v is below %v", ep.MaxUtterance, minMaxUtterance)
+	}
+	if _, err := endpoint.New(*ep, vad.Config{}); err != nil {
+		return errors.New(strings.TrimPrefix(err.Error(), "endpoint: "))
+	}
+	return nil
+}
+
+// ServerMessage is a JSON event sent to the client.
+type ServerMessage struct {
+	// Type is one of MsgStarted, MsgSegment, MsgVAD, MsgIntent, MsgLanguage,
+	// MsgDeferred or MsgError.
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	// Segment is set for MsgSegment.
+	Segment *WireSegment `json:"segment,omitempty"`
+	// VAD is set for MsgVAD.
+	VAD *WireVAD `json:"vad,omitempty"`
+	// Intent is set for MsgIntent, sent before the final segment it was
+	// recognized in.
+	Intent *WireIntent `json:"intent,omitempty"`
+	// Language is set for MsgLanguage.
+	Language *WireLanguage `json:"language,omitempty"`
+	// Error is set for MsgError, after which the server closes the socket,
+	// and for MsgDeferred to why the recognizer is down.
+	Error string `json:"error,omitempty"`
+	// Route is the routing token of the node serving the session, for
+	// MsgStarted when voxad runs as a cluster; for the MsgError refusing a
+	// session live on another node, it is the token of that node.
+	Route string `json:"route,omitempty"`
+	// ResumeMS is set for the MsgStarted of a session resuming one that
+	// was cut off: the end of the last final segment delivered before,
+	// from where the client resends its audio.
+	ResumeMS int64 `json:"resume_ms,omitempty"`
+	// Dropped counts partial segments coalesced away since the previous
+	// message because the client was reading too slowly.
+	Dropped int `json:"dropped,omitempty"`
+}
+
+// WireSegment is a transcript segment on the wire.
+type WireSegment struct {
+	UtteranceID string  `json:"utterance_id"`
+	Revision    int     `json:"revision"`
+	Text        string  `json:"text"`
+	Stability   float32 `json:"stability"`
+	Final       bool    `json:"final"`
+	Speaker     string  `json:"speaker,omitempty"`
+	// StartMS and EndMS delimit the utterance in session time.
+	StartMS    int64      `json:"start_ms"`
+	EndMS      int64      `json:"end_ms"`
+	Confidence float32    `json:"confidence,omitempty"`
+	Words      []WireWord `json:"words,omitempty"`
+	// Translations maps language codes to the translated text of a final.
+	Translations map[string]string `json:"translations,omitempty"`
+	Language     string            `json:"language,omitempty"`
+	// Provider names the STT backend that recognized the segment, when the
+	// server fails over between several.
+	Provider string `json:"provider,omitempty"`
+	// Redactions lists the spans of Text replaced by markers.
+	Redactions []WireRedaction `json:"redactions,omitempty"`
+	// Sentiment is the tone of a final, when the server analyzes it.
+	Sentiment *WireSentiment `json:"sentiment,omitempty"`
+}
+
+// WireSentiment is the tone of a segment; see voxa.Sentiment. Emotion and
+// arousal are only set when the server analyzes the voice.
+type WireSentiment struct {
+	Label   string  `json:"label"`
+	Score   float32 `json:"score"`
+	Emotion string  `json:"emotion,omitempty"`
+	Arousal float32 `json:"arousal,omitempty"`
+}
+
+// WireRedaction is a redacted span on the wire; offsets are in bytes.
+type WireRedaction struct {
+	Entity string `json:"entity"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	At     int    `json:"at"`
+}
+
+// WireWord is one aligned word on the wire.
+type WireWord struct {
+	Text       string  `json:"text"`
+	StartMS    int64   `json:"start_ms"`
+	EndMS      int64   `json:"end_ms"`
+	Confidence float32 `json:"confidence,omitempty"`
+}
+
+// WireVAD is a voice activity transition on the wire.
+type WireVAD struct {
+	// Event is "speech-start" or "speech-end".
+	Event string `json:"event"`
+	// OffsetMS is the stream time of the transition in milliseconds.
+	OffsetMS int64 `json:"offset_ms"`
+}
+
+// WireIntent is a recognized intent on the wire.
+type WireIntent struct {
+	Name       string            `json:"name"`
+	Slots      map[string]string `json:"slots,omitempty"`
+	Confidence float32           `json:"confidence"`
+	Text       string            `json:"text"`
+}
+
+// WireLanguage is the outcome of language identification on the wire.
+type WireLanguage struct {
+	Language   string  `json:"language"`
+	Confidence float32 `json:"confidence"`
+	Fallback   bool    `json:"fallback,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Stored transcript schema, served over HTTP by TranscriptsHandler.
+
+// WireSession is a stored session.
+type WireSession struct {
+	SessionID string `json:"session_id"`
+	// Tenant is the name of the API key the session was opened with, if
+	// any.
+	Tenant  string    `json:"tenant,omitempty"`
+	Started time.Time `json:"started"`
+	// Ended is absent while the session is running.
+	Ended *time.Time `json:"ended,omitempty"`
+	// Segments counts the segments of the live transcript.
+	Segments int `json:"segments"`
+	// Versions counts the versions made again from archived audio.
+	Versions int `json:"versions"`
+	// Metadata is what transports recorded about the session, such as
+	// the consent of a caller to being recorded.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// WireSessionList lists stored sessions, most recently started first.
+type WireSessionList struct {
+	Sessions []WireSession `json:"sessions"`
+}
+
+// WireTranscript is a stored session and the final segments of one
+// version of its transcript, 0 being the one made live.
+type WireTranscript struct {
+	Session  WireSession    `json:"session"`
+	Version  int            `json:"version"`
+	Segments []*WireSegment `json:"segments"`
+	// Versions lists the versions made again from archived audio, oldest
+	// first.
+	Versions []WireVersion `json:"versions"`
+	// Summary is the summary of the session, once it has been summarized.
+	Summary *WireSummary `json:"summary,omitempty"`
+}
+
+// WireSummary is the summary of a stored session.
+type WireSummary struct {
+	Text        string    `json:"text"`
+	ActionItems []string  `json:"action_items"`
+	Label       string    `json:"label,omitempty"`
+	Created     time.Time `json:"created"`
+	// Segments counts the segments of the transcript summarized.
+	Segments int `json:"segments"`
+}
+
+// WireVersion is a transcript made again from archived audio.
+type WireVersion struct {
+	Version  int       `json:"version"`
+	Provider string    `json:"provider"`
+	Label    string    `json:"label,omitempty"`
+	Created  time.Time `json:"created"`
+	Segments int       `json:"segments"`
+}
+
+// WireSearchResults are the hits of a transcript search, most relevant
+// first.
+type WireSearchResults struct {
+	Hits []WireHit `json:"hits"`
+}
+
+// WireHit is a stored segment matching a search; its start_ms and end_ms
+// place it in the session's recording.
+type WireHit struct {
+	SessionID string       `json:"session_id"`
+	Segment   *WireSegment `json:"segment"`
+	// Snippet is the text around the match, with matching words between
+	// ** marks.
+	Snippet string    `json:"snippet"`
+	Score   float64   `json:"score"`
+	Added   time.Time `json:"added"`
+	// Version is the version of the transcript the segment belongs to.
+	Version int `json:"version"`
+}
+
+// Twilio callback schema.
+//
+// TwilioHandler POSTs a WireTwilioEvent as JSON to its callback URL for
+// every event of a call, in order: "started" once per track when its
+// transcription starts, "segment" for its segments, "dtmf" for the keys
+// pressed, "amd" for who answered the call with
+// voxa.Config.AnsweringMachine, "consent" per track as a recording notice
+// starts and ends, with TwilioConfig.Notice, and "ended" once per track when the stream
+// stops, with the error that ended the track, if any. Keys are those Twilio reports, or
+// with voxa.Config.DTMF those detected in the audio of the track, with
+// their time.
+
+// Twilio callback event types.
+const (
+	TwilioStarted  = "started"
+	TwilioSegment  = "segment"
+	TwilioDTMF     = "dtmf"
+	TwilioAMD      = "amd"
+	TwilioConsent  = "consent"
+	TwilioDeferred = "deferred"
+	TwilioEnded    = "ended"
+)
+
+// WireTwilioEvent is an event of a Twilio call.
+type WireTwilioEvent struct {
+	// Event is one of TwilioStarted, TwilioSegment, TwilioDTMF, TwilioAMD,
+	// TwilioConsent, TwilioDeferred or TwilioEnded.
+	Event      string `json:"event"`
+	AccountSID string `json:"account_sid"`
+	CallSID    string `json:"call_sid"`
+	StreamSID  string `json:"stream_sid"`
+	// Track is "inbound", the caller, or "outbound", what Twilio plays
+	// them.
+	Track string `json:"track,omitempty"`
+	// SessionID names the session of the track.
+	SessionID string `json:"session_id,omitempty"`
+	// Parameters are the custom parameters of the <Stream>.
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// Segment is set for TwilioSegment.
+	Segment *WireSegment `json:"segment,omitempty"`
+	// Digit is set for TwilioDTMF.
+	Digit string `json:"digit,omitempty"`
+	// OffsetMS is the session time at which the tone of a TwilioDTMF
+	// started, for keys detected in the audio.
+	OffsetMS int64 `json:"offset_ms,omitempty"`
+	// AMD is set for TwilioAMD.
+	AMD *WireAMD `json:"amd,omitempty"`
+	// Consent is set for TwilioConsent, to one of the Consent states.
+	Consent string `json:"consent,omitempty"`
+	// Error is set for a TwilioEnded of a track that failed, and for
+	// TwilioDeferred to why the recognizer is down.
+	Error string `json:"error,omitempty"`
+}
+
+// Event wire schema.
+//
+// The events of voxa.Config.Sinks are published to message brokers as
+// WireEvent JSON documents, one per event, or as voxadv1.Event protobuf
+// messages of the same content.
+
+// WireEvent is a pipeline event on the wire.
+type WireEvent struct {
+	// Type is "wake_word", "final", "intent", "partial", "session_start",
+	// "session_end", "alert", "dtmf", "amd" or "deferred"; see
+	// voxa.EventType.
+	Type      string    `json:"type"`
+	SessionID string    `json:"session_id"`
+	Time      time.Time `json:"time"`
+	// WakeWord is set for wake_word.
+	WakeWord *WireWakeWord `json:"wake_word,omitempty"`
+	// Segment is set for final and partial, and for intent and alert to
+	// the segment the intent was recognized in or the rule matched.
+	Segment *WireSegment `json:"segment,omitempty"`
+	// Intent is set for intent.
+	Intent *WireIntent `json:"intent,omitempty"`
+	// Alert is set for alert.
+	Alert *WireAlert `json:"alert,omitempty"`
+	// DTMF is set for dtmf.
+	DTMF *WireDTMF `json:"dtmf,omitempty"`
+	// AMD is set for amd.
+	AMD *WireAMD `json:"amd,omitempty"`
+	// Error is set for session_end if the session failed, and for
+	// deferred to why the recognizer is down.
+	Error string `json:"error,omitempty"`
+}
+
+// WireAlert is an alert rule matching a segment on the wire.
+type WireAlert struct {
+	Rule  string `json:"rule"`
+	Match string `json:"match"`
+}
+
+// WireDTMF is a telephone keypad key pressed on the wire.
+type WireDTMF struct {
+	// Key is "0" to "9", "*", "#", or "A" to "D".
+	Key string `json:"key"`
+	// OffsetMS is the session time at which the tone started.
+	OffsetMS int64 `json:"offset_ms"`
+}
+
+// WireAMD is who answered a call on the wire.
+type WireAMD struct {
+	// Result is "human", "machine", "music" or "unknown".
+	Result string `json:"result"`
+	// Reason is what decided; see voxa.AMDDecision.
+	Reason string `json:"reason"`
+	// OffsetMS is the session time at which the decision was made.
+	OffsetMS int64 `json:"offset_ms"`
+	Words    int   `json:"words"`
+}
+
+// WireWakeWord is a detected wake word on the wire.
+type WireWakeWord struct {
+	Phrase string `json:"phrase"`
+	// OffsetMS is the session time at which the phrase ended.
+	OffsetMS int64   `json:"offset_ms"`
+	Score    float64 `json:"score"`
+}
+
+// Admin API schema.
+//
+// AdminHandler answers with these documents for API keys with admin
+// rights.
+
+// WireUsageList is the usage of every API key, by name.
+type WireUsageList struct {
+	Keys []WireUsage `json:"keys"`
+}
+
+// WireUsage is the usage of an API key since the server started; see
+// auth.Usage.
+type WireUsage struct {
+	Key             string  `json:"key"`
+	Admin           bool    `json:"admin,omitempty"`
+	Requests        int64   `json:"requests"`
+	Throttled       int64   `json:"throttled"`
+	Sessions        int     `json:"sessions"`
+	SessionsTotal   int64   `json:"sessions_total"`
+	SessionsRefused int64   `json:"sessions_refused"`
+	SessionSeconds  float64 `json:"session_seconds"`
+	// Limits of the key, omitted where there is none.
+	Rate        float64 `json:"rate,omitempty"`
+	Burst       int     `json:"burst,omitempty"`
+	MaxSessions int     `json:"max_sessions,omitempty"`
+}
+
+// WireActiveSessions are the sessions open, oldest first.
+type WireActiveSessions struct {
+	Sessions []WireActiveSession `json:"sessions"`
+}
+
+// WireActiveSession is a session open now.
+type WireActiveSession struct {
+	SessionID string `json:"session_id"`
+	// Kind is "transcribe" or "synthesize".
+	Kind string `json:"kind"`
+	// Transport is "grpc", "websocket", "twilio", "rtp" or "webrtc".
+	Transport  string    `json:"transport"`
+	Peer       string    `json:"peer"`
+	Started    time.Time `json:"started"`
+	DurationMS int64     `json:"duration_ms"`
+	// Key names the API key the session was opened with.
+	Key string `json:"key,omitempty"`
+	// Transcriptions also have the stats of their stream; see
+	// voxa.StreamStats. LatencyMS is omitted until known.
+	Provider  string `json:"provider,omitempty"`
+	LatencyMS *int64 `json:"latency_ms,omitempty"`
+	AudioMS   int64  `json:"audio_ms,omitempty"`
+	Segments  int    `json:"segments,omitempty"`
+	// Cost is the cost of the session's provider usage so far, when the
+	// server bills usage.
+	Cost *float64 `json:"cost,omitempty"`
+}
+
+// WireCosts is the cost of the provider usage of every API key since the
+// server started, and of the latest sessions to end; see cost.Ledger.
+type WireCosts struct {
+	Currency string        `json:"currency,omitempty"`
+	Keys     []WireAccount `json:"keys"`
+	Sessions []WireAccount `json:"sessions"`
+}
+
+// WireAccount is the usage charged to an API key, empty for sessions
+// opened without one, or to one of its sessions.
+type WireAccount struct {
+	Key       string       `json:"key"`
+	SessionID string       `json:"session_id,omitempty"`
+	Ended     *time.Time   `json:"ended,omitempty"`
+	Cost      float64      `json:"cost"`
+	Providers []WireCharge `json:"providers"`
+}
+
+// WireCharge is the usage of one provider, priced.
+type WireCharge struct {
+	Provider     string  `json:"provider"`
+	AudioSeconds float64 `json:"audio_seconds,omitempty"`
+	Characters   int64   `json:"characters,omitempty"`
+	Cost         float64 `json:"cost"`
+}
+
+// Speaker enrollment schema, served over HTTP by SpeakersHandler.
+
+// WireSpeakerList lists the enrolled speakers by name.
+type WireSpeakerList struct {
+	Speakers []WireVoiceprint `json:"speakers"`
+}
+
+// WireVoiceprint is an enrolled speaker; the embedding itself is not
+// served.
+type WireVoiceprint struct {
+	Name string `json:"name"`
+	// SpeechMS is how much speech was enrolled.
+	SpeechMS int64     `json:"speech_ms"`
+	Enrolled time.Time `json:"enrolled"`
+	Updated  time.Time `json:"updated"`
+}
@@ -0,0 +1,464 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/auth"
+)
+
+// maxAudioMessage bounds a single binary message (one second of 48kHz
+// audio), protecting the server from clients that never chunk.
+const maxAudioMessage = 96000
+
+// outboxSize bounds the events queued for a slow client.
+const outboxSize = 64
+
+// writeTimeout bounds a single event write to a client.
+const writeTimeout = 5 * time.Second
+
+// WebSocketHandler serves the transcription protocol described in wire.go.
+// originPatterns lists the extra origins allowed to connect (see
+// websocket.AcceptOptions); same-origin requests are always accepted.
+func (s *Server) WebSocketHandler(originPatterns []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := s.cluster.route(); route != "" {
+			w.Header().Set(RouteHeader, route)
+		}
+		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: originPatterns})
+		if err != nil {
+			return // Accept already wrote the HTTP error
+		}
+		conn.SetReadLimit(maxAudioMessage)
+		ctx, span := s.startSpan(r.Context(), "voxad.WebSocket", propagation.HeaderCarrier(r.Header),
+			attribute.String("http.route", r.URL.Path))
+		err = s.serveWebSocket(ctx, conn, r.RemoteAddr, r.URL.Query().Get("profile"))
+		endSpan(span, err)
+		switch {
+		case err == nil:
+			conn.Close(websocket.StatusNormalClosure, "")
+		case websocket.CloseStatus(err) != -1:
+			// The client closed the socket.
+		case errors.Is(err, auth.ErrNotAllowed), errors.Is(err, ErrUnknownProfile), errors.Is(err, ErrNotOwner):
+			conn.Close(websocket.StatusPolicyViolation, truncate(err.Error(), 120))
+		case errors.Is(err, auth.ErrSessionQuota), errors.As(err, new(*MovedError)):
+			conn.Close(websocket.StatusTryAgainLater, truncate(err.Error(), 120))
+		case errors.Is(err, ErrDraining):
+			conn.Close(websocket.StatusServiceRestart, err.Error())
+		default:
+			conn.Close(websocket.StatusInternalError, truncate(err.Error(), 120))
+		}
+	})
+}
+
+func (s *Server) serveWebSocket(ctx context.Context, conn *websocket.Conn, remote, profile string) (err error) {
+	g, err := s.acquire(ctx, profile)
+	if err != nil {
+		return err
+	}
+	defer s.release(g)
+	var start ClientMessage
+	if err := readJSON(ctx, conn, &start); err != nil {
+		return err
+	}
+	if start.Type != MsgStart {
+		return fmt.Errorf("first message must be %q, got %q", MsgStart, start.Type)
+	}
+	if start.SampleRate <= 0 {
+		return errors.New("sample_rate must be positive")
+	}
+	var phrases []voxa.Phrase
+	for _, p := range start.Phrases {
+		phrases = append(phrases, voxa.Phrase{Text: p.Text, Boost: p.Boost})
+	}
+	if err := checkPhrases(phrases); err != nil {
+		return err
+	}
+	prio, err := voxa.ParsePriority(start.Priority)
+	if err != nil {
+		return err
+	}
+	endpointing := start.Endpointing.config()
+	if err := checkOverrides(ctx, start.Language, start.Model, endpointing); err != nil {
+		return err
+	}
+
+	ctx, sess, err := s.sessions.Start(ctx, start.SessionID, KindTranscribe, "websocket", remote)
+	if err != nil {
+		return err
+	}
+	defer s.sessions.End(sess.ID)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("voxa.session_id", sess.ID))
+	log, ended := s.logSession(sess)
+	defer func() { ended(err) }()
+
+	out := newOutbox(outboxSize, g.pipeline.Metrics())
+	writerDone := make(chan error, 1)
+	go func() { writerDone <- out.drain(ctx, conn, sess.ID) }()
+	defer func() {
+		out.close()
+		<-writerDone
+	}()
+	cl, err := s.cluster.claim(ctx, sess.ID, func() { s.sessions.Cancel(sess.ID) })
+	if err != nil {
+		var moved *MovedError
+		if errors.As(err, &moved) {
+			out.push(ServerMessage{Type: MsgError, Error: err.Error(), Route: moved.Node})
+		}
+		return err
+	}
+	cut := true // unless the client ends the session
+	defer func() { cl.release(cut) }()
+	out.push(ServerMessage{Type: MsgStarted, Route: s.cluster.route(), ResumeMS: cl.offset().Milliseconds()})
+
+	format := audio.Format{SampleRate: start.SampleRate, Channels: 1}
+	vs, err := g.pipeline.NewStream(ctx, format, voxa.StreamOptions{
+		DisableVAD:  !start.VAD,
+		SessionID:   sess.ID,
+		Offset:      cl.offset(),
+		Phrases:     phrases,
+		Priority:    sessionPriority(ctx, prio),
+		Language:    start.Language,
+		Model:       start.Model,
+		Endpointing: endpointing,
+		Tenant:      sess.Key,
+		OnVAD: func(ev voxa.VADEvent) {
+			out.push(ServerMessage{Type: MsgVAD, VAD: &WireVAD{Event: ev.Type.String(), OffsetMS: ev.Offset.Milliseconds()}})
+		},
+		OnLanguage: func(det voxa.LanguageDetection) {
+			wl := &WireLanguage{Language: det.Language, Confidence: det.Confidence, Fallback: det.Fallback}
+			if det.Err != nil {
+				wl.Error = det.Err.Error()
+			}
+			out.push(ServerMessage{Type: MsgLanguage, Language: wl})
+		},
+		OnDeferred: func(err error) {
+			out.push(ServerMessage{Type: MsgDeferred, Error: err.Error()})
+		},
+		OnIntent: func(in voxa.Intent) {
+			out.push(ServerMessage{Type: MsgIntent, Intent: &WireIntent{Name: in.Name, Slots: in.Slots, Confidence: in.Confidence, Text: in.Text}})
+		},
+	})
+	if err != nil {
+		log.Error("open pipeline", "error", err)
+		out.push(ServerMessage{Type: MsgError, Error: err.Error()})
+		return nil
+	}
+	s.sessions.Attach(sess.ID, vs)
+
+	results := make(chan struct{})
+	go func() {
+		defer close(results)
+		for seg := range vs.Results() {
+			out.push(ServerMessage{Type: MsgSegment, Segment: wireSegment(seg)})
+			cl.final(seg)
+		}
+	}()
+
+	err = feedWebSocket(ctx, conn, vs, sess.Stopping())
+	cut = cutOff(ctx, sess, err)
+	if cerr := vs.Close(); err == nil {
+		err = cerr
+	}
+	<-results
+	if err == nil {
+		err = vs.Err()
+	}
+	if err != nil && websocket.CloseStatus(err) == -1 {
+		log.Warn("stream failed", "error", err)
+		out.push(ServerMessage{Type: MsgError, Error: err.Error()})
+		return nil
+	}
+	return err
+}
+
+// feedWebSocket forwards client messages into the pipeline until the client
+// sends "end" or goes away, or stop is closed. Reading waits for the
+// pipeline to take the audio, so a recognizer that falls behind pushes back
+// on the client through TCP flow control instead of growing server-side
+// buffers, unless the pipeline buffer is set to drop audio.
+func feedWebSocket(ctx context.Context, conn *websocket.Conn, vs *voxa.Stream, stop <-chan struct{}) error {
+	type message struct {
+		typ  websocket.MessageType
+		data []byte
+		err  error
+	}
+	// Reads are cancelled only with the session: cancelling one closes
+	// the connection, which must stay open for the last results.
+	msgs := make(chan message)
+	go func() {
+		for {
+			typ, data, err := conn.Read(ctx)
+			select {
+			case msgs <- message{typ, data, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		var m message
+		select {
+		case m = <-msgs:
+		case <-stop:
+			return nil
+		}
+		typ, data, err := m.typ, m.data, m.err
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if typ == websocket.MessageBinary {
+			if _, err := vs.Write(data); err != nil {
+				return err
+			}
+			continue
+		}
+		var msg ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return fmt.Errorf("bad control message: %w", err)
+		}
+		switch msg.Type {
+		case MsgFlush:
+			if err := vs.Flush(); err != nil {
+				return err
+			}
+		case MsgEnd:
+			return nil
+		default:
+			return fmt.Errorf("unexpected %q message", msg.Type)
+		}
+	}
+}
+
+func readJSON(ctx context.Context, conn *websocket.Conn, v any) error {
+	typ, data, err := conn.Read(ctx)
+	if err != nil {
+		return err
+	}
+	if typ != websocket.MessageText {
+		return errors.New("expected a text message")
+	}
+	return json.Unmarshal(data, v)
+}
+
+// outbox queues events for one client. When the client reads too slowly
+// queued partials are replaced by newer ones for the same utterance; if the
+// queue is still full the oldest partial is dropped. Finals, VAD, language,
+// intent and error events are always delivered.
+type outbox struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []ServerMessage
+	max     int
+	dropped int
+	closed  bool
+	metrics *voxa.Metrics
+	depth   int // queue length last reported to metrics
+}
+
+func newOutbox(max int, m *voxa.Metrics) *outbox {
+	o := &outbox{max: max, metrics: m}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+func isPartial(m ServerMessage) bool {
+	return m.Type == MsgSegment && !m.Segment.Final
+}
+
+func (o *outbox) push(m ServerMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	if isPartial(m) {
+		for i := len(o.queue) - 1; i >= 0; i-- {
+			if q := o.queue[i]; isPartial(q) && q.Segment.UtteranceID == m.Segment.UtteranceID {
+				o.queue[i] = m
+				o.drop()
+				o.cond.Signal()
+				return
+			}
+		}
+	}
+	if len(o.queue) >= o.max {
+		for i, q := range o.queue {
+			if isPartial(q) {
+				o.queue = append(o.queue[:i], o.queue[i+1:]...)
+				o.drop()
+				break
+			}
+		}
+	}
+	o.queue = append(o.queue, m)
+	o.report()
+	o.cond.Signal()
+}
+
+// drop counts a discarded partial; o.mu must be held.
+func (o *outbox) drop() {
+	o.dropped++
+	o.metrics.Dropped("ws_outbox", "drop-partials")
+}
+
+// report updates the queue depth metric; o.mu must be held.
+func (o *outbox) report() {
+	o.metrics.Queued("ws_outbox", len(o.queue)-o.depth)
+	o.depth = len(o.queue)
+}
+
+// discard drops the events still queued, for writers that gave up.
+func (o *outbox) discard() {
+	o.mu.Lock()
+	o.queue = nil
+	o.report()
+	o.mu.Unlock()
+}
+
+func (o *outbox) close() {
+	o.mu.Lock()
+	o.closed = true
+	o.cond.Signal()
+	o.mu.Unlock()
+}
+
+// next blocks for the next event. It returns false once the outbox is
+// closed and empty.
+func (o *outbox) next() (ServerMessage, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for len(o.queue) == 0 && !o.closed {
+		o.cond.Wait()
+	}
+	if len(o.queue) == 0 {
+		return ServerMessage{}, false
+	}
+	m := o.queue[0]
+	o.queue = o.queue[1:]
+	o.report()
+	m.Dropped, o.dropped = o.dropped, 0
+	return m, true
+}
+
+// drain writes queued events to conn until the outbox is closed.
+func (o *outbox) drain(ctx context.Context, conn *websocket.Conn, sessionID string) error {
+	// Writes must outlive the session context so the final events and
+	// errors still reach the client after the pipeline shuts down.
+	ctx = context.WithoutCancel(ctx)
+	defer o.discard()
+	for {
+		m, ok := o.next()
+		if !ok {
+			return nil
+		}
+		m.SessionID = sessionID
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		wctx, cancel := context.WithTimeout(ctx, writeTimeout)
+		err = conn.Write(wctx, websocket.MessageText, b)
+		cancel()
+		if err != nil {
+			// Keep draining so pushers never block on a dead client.
+			for _, ok := o.next(); ok; _, ok = o.next() {
+			}
+			return err
+		}
+	}
+}
+
+// SegmentMessage encodes seg as a segment event of the WebSocket protocol,
+// as sent on the data channels of WebRTC peers.
+func SegmentMessage(sessionID string, seg voxa.Segment) ([]byte, error) {
+	return json.Marshal(ServerMessage{Type: MsgSegment, SessionID: sessionID, Segment: wireSegment(seg)})
+}
+
+// EventMessage encodes ev as a WireEvent, as published by event sinks.
+func EventMessage(ev voxa.Event) ([]byte, error) {
+	we := WireEvent{Type: ev.Type.String(), SessionID: ev.SessionID, Time: ev.Time}
+	if d := ev.WakeWord; d != nil {
+		we.WakeWord = &WireWakeWord{Phrase: d.Phrase, OffsetMS: d.Offset.Milliseconds(), Score: d.Score}
+	}
+	if ev.Segment != nil {
+		we.Segment = wireSegment(*ev.Segment)
+	}
+	if in := ev.Intent; in != nil {
+		we.Intent = &WireIntent{Name: in.Name, Slots: in.Slots, Confidence: in.Confidence, Text: in.Text}
+	}
+	if a := ev.Alert; a != nil {
+		we.Alert = &WireAlert{Rule: a.Rule, Match: a.Match}
+	}
+	if d := ev.DTMF; d != nil {
+		we.DTMF = &WireDTMF{Key: d.Key, OffsetMS: d.Offset.Milliseconds()}
+	}
+	if d := ev.AMD; d != nil {
+		we.AMD = wireAMD(*d)
+	}
+	if ev.Err != nil {
+		we.Error = ev.Err.Error()
+	}
+	return json.Marshal(we)
+}
+
+func wireAMD(d voxa.AMDDecision) *WireAMD {
+	return &WireAMD{Result: d.Result.String(), Reason: d.Reason, OffsetMS: d.Offset.Milliseconds(), Words: d.Words}
+}
+
+func wireSegment(seg voxa.Segment) *WireSegment {
+	ws := &WireSegment{
+		UtteranceID:  seg.UtteranceID,
+		Revision:     seg.Revision,
+		Text:         seg.Text,
+		Stability:    seg.Stability,
+		Final:        seg.Final,
+		Speaker:      seg.Speaker,
+		StartMS:      seg.Start.Milliseconds(),
+		EndMS:        seg.End.Milliseconds(),
+		Confidence:   seg.Confidence,
+		Translations: seg.Translations,
+		Language:     seg.Language,
+		Provider:     seg.Provider,
+	}
+	for _, w := range seg.Words {
+		ws.Words = append(ws.Words, WireWord{
+			Text:       w.Text,
+			StartMS:    w.Start.Milliseconds(),
+			EndMS:      w.End.Milliseconds(),
+			Confidence: w.Confidence,
+		})
+	}
+	for _, r := range seg.Redactions {
+		ws.Redactions = append(ws.Redactions, WireRedaction(r))
+	}
+	if st := seg.Sentiment; st != nil {
+		ws.Sentiment = &WireSentiment{Label: st.Label, Score: st.Score, Emotion: st.Emotion, Arousal: st.Arousal}
+	}
+	return ws
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
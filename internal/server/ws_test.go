@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/stttest"
+)
+
+// dropped returns the items m counted as dropped from queue.
+func dropped(t *testing.T, m *voxa.Metrics, queue string) float64 {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n float64
+	for _, f := range families {
+		if f.GetName() != "voxa_queue_dropped_total" {
+			continue
+		}
+		for _, c := range f.GetMetric() {
+			for _, l := range c.GetLabel() {
+				if l.GetName() == "queue" && l.GetValue() == queue {
+					n += c.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return n
+}
+
+func partial(utterance, text string) ServerMessage {
+	return ServerMessage{Type: MsgSegment, Segment: &WireSegment{UtteranceID: utterance, Text: text}}
+}
+
+func TestOutboxDropMetric(t *testing.T) {
+	m := voxa.NewMetrics()
+	o := newOutbox(2, m)
+	o.push(partial("u1", "hel"))
+	o.push(partial("u2", "wor"))
+	o.push(partial("u3", "fo"))  // full: u1 goes
+	o.push(partial("u3", "foo")) // replaces the one of u3
+	if o.dropped != 2 {
+		t.Errorf("dropped %d, want 2", o.dropped)
+	}
+	if n := dropped(t, m, "ws_outbox"); n != 2 {
+		t.Errorf("metric counts %v dropped, want 2", n)
+	}
+}
+
+func final(utterance, text string) ServerMessage {
+	return ServerMessage{Type: MsgSegment, Segment: &WireSegment{UtteranceID: utterance, Text: text, Final: true}}
+}
+
+// describe sums m up as its type, the utterance and text of a segment
+// and the partials dropped before it.
+func describe(m ServerMessage) string {
+	d := m.Type
+	if seg := m.Segment; seg != nil {
+		d = "partial"
+		if seg.Final {
+			d = "final"
+		}
+		d += " " + seg.UtteranceID + " " + seg.Text
+	}
+	if m.Dropped > 0 {
+		d += fmt.Sprintf(" (%d dropped)", m.Dropped)
+	}
+	return d
+}
+
+func TestOutbox(t *testing.T) {
+	vad := ServerMessage{Type: MsgVAD, VAD: &WireVAD{Event: "speech-start"}}
+	failed := ServerMessage{Type: MsgError, Error: "boom"}
+	for _, tc := range []struct {
+		name   string
+		pushes []ServerMessage
+		want   []string
+	}{
+		{
+			name:   "partial replaced by one of its utterance",
+			pushes: []ServerMessage{partial("u1", "turn"), partial("u2", "hi"), partial("u1", "turn on")},
+			want:   []string{"partial u1 turn on (1 dropped)", "partial u2 hi"},
+		},
+		{
+			name:   "oldest partial dropped when full",
+			pushes: []ServerMessage{partial("u1", "a"), partial("u2", "b"), partial("u3", "c"), partial("u4", "d")},
+			want:   []string{"partial u2 b (1 dropped)", "partial u3 c", "partial u4 d"},
+		},
+		{
+			name:   "partial dropped behind the events kept",
+			pushes: []ServerMessage{final("u1", "done"), partial("u2", "a"), vad, partial("u3", "b")},
+			want:   []string{"final u1 done (1 dropped)", "vad", "partial u3 b"},
+		},
+		{
+			name:   "finals, VAD events and errors never dropped",
+			pushes: []ServerMessage{final("u1", "a"), vad, final("u2", "b"), failed, final("u3", "c"), partial("u4", "d")},
+			want:   []string{"final u1 a", "vad", "final u2 b", "error", "final u3 c", "partial u4 d"},
+		},
+		{
+			name:   "final not replaced by a partial",
+			pushes: []ServerMessage{final("u1", "a"), partial("u1", "a b")},
+			want:   []string{"final u1 a", "partial u1 a b"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newOutbox(3, nil)
+			for _, m := range tc.pushes {
+				o.push(m)
+			}
+			o.close()
+			o.push(final("late", "after close"))
+			var got []string
+			for m, ok := o.next(); ok; m, ok = o.next() {
+				got = append(got, describe(m))
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("delivered\n got %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOutboxDroppedCarried(t *testing.T) {
+	o := newOutbox(2, nil)
+	o.push(partial("u1", "a"))
+	o.push(partial("u1", "a b"))
+	o.push(partial("u1", "a b c"))
+	if m, _ := o.next(); m.Dropped != 2 {
+		t.Errorf("first message after 2 coalesced: %s", describe(m))
+	}
+	o.push(partial("u2", "x"))
+	if m, _ := o.next(); m.Dropped != 0 {
+		t.Errorf("count carried twice: %s", describe(m))
+	}
+}
+
+func TestWebSocketRoundTrip(t *testing.T) {
+	rec := stttest.New(stttest.Utterance("1", "turn on the lights", 200*time.Millisecond, time.Second))
+	p, err := voxa.NewPipeline(voxa.Config{Recognizer: rec.Config()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	srv := httptest.NewServer(New(p, nil, nil).WebSocketHandler(nil))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/transcribe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	send := func(m ClientMessage) {
+		t.Helper()
+		b, _ := json.Marshal(m)
+		if err := conn.Write(ctx, websocket.MessageText, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(ClientMessage{Type: MsgStart, SessionID: "call-1", SampleRate: 16000})
+	// 1.2s of silence, in 100ms chunks.
+	for range 12 {
+		if err := conn.Write(ctx, websocket.MessageBinary, make([]byte, 2*1600)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(ClientMessage{Type: MsgEnd})
+
+	var (
+		got      []string
+		partials int // delivered or dropped
+	)
+	for {
+		_, data, err := conn.Read(ctx)
+		if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
+			break
+		}
+		if err != nil {
+			t.Fatalf("after %q: %v", got, err)
+		}
+		var m ServerMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.SessionID != "call-1" {
+			t.Errorf("%s message of session %q, want call-1", m.Type, m.SessionID)
+		}
+		partials, m.Dropped = partials+m.Dropped, 0
+		if m.Segment != nil && !m.Segment.Final {
+			// Partials may be coalesced by a slow reader.
+			partials++
+			continue
+		}
+		got = append(got, describe(m))
+	}
+	if want := []string{"started", "final 1 turn on the lights"}; !slices.Equal(got, want) {
+		t.Errorf("messages\n got %q\nwant %q", got, want)
+	}
+	if partials != 3 {
+		t.Errorf("%d partials delivered or dropped, want 3", partials)
+	}
+	streams := rec.Streams()
+	if len(streams) != 1 || streams[0].Written() != 1200*time.Millisecond || !streams[0].Closed() {
+		t.Fatalf("recognizer streams %v, want 1 of 1.2s, closed", streams)
+	}
+}
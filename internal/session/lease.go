@@ -0,0 +1,122 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrLeaseLost is returned by Leases.Renew when the lease expired and
+// another node may have taken it.
+var ErrLeaseLost = errors.New("session: lease lost")
+
+// Leases record which node of a cluster runs each live stream, so that
+// replicas behind a load balancer agree on where a session is served and
+// can take it over once its node stops renewing. Implementations must be
+// safe for concurrent use.
+type Leases interface {
+	// Acquire takes or renews the lease on stream id for node, for ttl,
+	// and returns node. If another node holds it, it returns that node
+	// instead and leaves the lease alone.
+	Acquire(ctx context.Context, id, node string, ttl time.Duration) (string, error)
+	// Renew extends the lease node holds on stream id by ttl, or fails
+	// with ErrLeaseLost.
+	Renew(ctx context.Context, id, node string, ttl time.Duration) error
+	// Release gives up the lease node holds on stream id. Releasing a
+	// lease held by another node, or by none, is not an error.
+	Release(ctx context.Context, id, node string) error
+}
+
+type lease struct {
+	node    string
+	expires time.Time
+}
+
+// Acquire implements Leases.
+func (s *Memory) Acquire(_ context.Context, id, node string, ttl time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if l, ok := s.leases[id]; ok && l.node != node && now.Before(l.expires) {
+		return l.node, nil
+	}
+	if s.leases == nil {
+		s.leases = make(map[string]lease)
+	}
+	s.leases[id] = lease{node: node, expires: now.Add(ttl)}
+	return node, nil
+}
+
+// Renew implements Leases.
+func (s *Memory) Renew(_ context.Context, id, node string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	l, ok := s.leases[id]
+	if !ok || l.node != node || !now.Before(l.expires) {
+		return ErrLeaseLost
+	}
+	s.leases[id] = lease{node: node, expires: now.Add(ttl)}
+	return nil
+}
+
+// Release implements Leases.
+func (s *Memory) Release(_ context.Context, id, node string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.leases[id]; ok && l.node == node {
+		delete(s.leases, id)
+	}
+	return nil
+}
+
+// The lease scripts run atomically on the server. A lease is the key
+// prefix + "lease:" + stream ID, holding the node name.
+const (
+	acquireScript = `local h = redis.call("GET", KEYS[1])
+if h and h ~= ARGV[1] then return h end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return ARGV[1]`
+	renewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end
+return 0`
+	releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+return redis.call("DEL", KEYS[1]) end
+return 0`
+)
+
+// Acquire implements Leases.
+func (r *Redis) Acquire(ctx context.Context, id, node string, ttl time.Duration) (string, error) {
+	reply, err := r.eval(ctx, acquireScript, id, node, ttl)
+	if err != nil {
+		return "", err
+	}
+	holder, ok := reply.([]byte)
+	if !ok {
+		return "", errors.New("redis: unexpected lease reply")
+	}
+	return string(holder), nil
+}
+
+// Renew implements Leases.
+func (r *Redis) Renew(ctx context.Context, id, node string, ttl time.Duration) error {
+	reply, err := r.eval(ctx, renewScript, id, node, ttl)
+	if err != nil {
+		return err
+	}
+	if n, _ := reply.(int64); n != 1 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Release implements Leases.
+func (r *Redis) Release(ctx context.Context, id, node string) error {
+	_, err := r.eval(ctx, releaseScript, id, node, 0)
+	return err
+}
+
+func (r *Redis) eval(ctx context.Context, script, id, node string, ttl time.Duration) (any, error) {
+	return r.do(ctx, "EVAL", script, "1", r.prefix+"lease:"+id, node, strconv.FormatInt(ttl.Milliseconds(), 10))
+}
@@ -0,0 +1,89 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Store and Leases, for a single node. Sessions
+// are lost on restart.
+type Memory struct {
+	mu     sync.Mutex
+	m      map[string]entry
+	leases map[string]lease
+	now    func() time.Time
+	sweep  time.Time // next time expired entries are purged
+}
+
+type entry struct {
+	values  Values
+	expires time.Time
+}
+
+// sweepInterval bounds how long expired sessions linger in memory.
+const sweepInterval = time.Minute
+
+// NewMemory creates an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{m: make(map[string]entry), now: time.Now}
+}
+
+// Get implements Store.
+func (s *Memory) Get(_ context.Context, id string) (Values, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.m[id]
+	if !ok || !s.now().Before(e.expires) {
+		return nil, ErrNotFound
+	}
+	v := make(Values, len(e.values))
+	for k, val := range e.values {
+		v[k] = val
+	}
+	return v, nil
+}
+
+// Set implements Store.
+func (s *Memory) Set(_ context.Context, id string, v Values, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.After(s.sweep) {
+		for k, e := range s.m {
+			if !now.Before(e.expires) {
+				delete(s.m, k)
+			}
+		}
+		for k, l := range s.leases {
+			if !now.Before(l.expires) {
+				delete(s.leases, k)
+			}
+		}
+		s.sweep = now.Add(sweepInterval)
+	}
+	s.m[id] = entry{values: v, expires: now.Add(ttl)}
+	return nil
+}
+
+// Delete implements Store.
+func (s *Memory) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	delete(s.m, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of live sessions.
+func (s *Memory) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	now := s.now()
+	for _, e := range s.m {
+		if now.Before(e.expires) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,238 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redis is a Store and Leases backed by a Redis server, so sessions
+// survive restarts and are shared between voxad replicas. Every session is
+// one key holding its JSON values, expired by Redis itself.
+//
+// The client speaks the RESP protocol over a single connection, which is
+// enough for session traffic; commands are serialized and the connection
+// is re-established after errors.
+type Redis struct {
+	addr     string
+	user     string
+	password string
+	db       int
+	prefix   string
+
+	mu   sync.Mutex
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// redisTimeout bounds commands whose context has no deadline.
+const redisTimeout = 5 * time.Second
+
+// NewRedis creates a store for a redis://[user:password@]host[:port][/db]
+// URL. Keys are named prefix + session ID; prefix defaults to
+// "voxa:session:". No connection is made until the first command.
+func NewRedis(rawURL, prefix string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("session: redis url: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("session: redis url scheme %q, want redis", u.Scheme)
+	}
+	if prefix == "" {
+		prefix = "voxa:session:"
+	}
+	r := &Redis{addr: u.Host, prefix: prefix}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		if pw, ok := u.User.Password(); ok {
+			r.user, r.password = u.User.Username(), pw
+		} else {
+			// redis://secret@host is the legacy password-only form.
+			r.password = u.User.Username()
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("session: redis database %q", db)
+		}
+	}
+	return r, nil
+}
+
+// Get implements Store.
+func (r *Redis) Get(ctx context.Context, id string) (Values, error) {
+	reply, err := r.do(ctx, "GET", r.prefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNotFound
+	}
+	var v Values
+	if err := json.Unmarshal(reply.([]byte), &v); err != nil {
+		return nil, fmt.Errorf("redis: corrupt session %s: %w", id, err)
+	}
+	return v, nil
+}
+
+// Set implements Store.
+func (r *Redis) Set(ctx context.Context, id string, v Values, ttl time.Duration) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = r.do(ctx, "SET", r.prefix+id, string(b), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Delete implements Store.
+func (r *Redis) Delete(ctx context.Context, id string) error {
+	_, err := r.do(ctx, "DEL", r.prefix+id)
+	return err
+}
+
+// Close closes the connection.
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.rw = nil, nil
+	return err
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// do runs one command and returns its reply: nil, a string (status),
+// []byte (bulk string), int64 or []any.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		if err := r.dial(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := r.roundTrip(ctx, args)
+	var rerr redisError
+	if err != nil && !errors.As(err, &rerr) {
+		// The connection is in an unknown state.
+		_ = r.conn.Close()
+		r.conn, r.rw = nil, nil
+	}
+	return reply, err
+}
+
+func (r *Redis) dial(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	r.conn = conn
+	r.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	var setup [][]string
+	switch {
+	case r.user != "":
+		setup = append(setup, []string{"AUTH", r.user, r.password})
+	case r.password != "":
+		setup = append(setup, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	for _, cmd := range setup {
+		if _, err := r.roundTrip(ctx, cmd); err != nil {
+			_ = conn.Close()
+			r.conn, r.rw = nil, nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Redis) roundTrip(ctx context.Context, args []string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	_ = r.conn.SetDeadline(deadline)
+	fmt.Fprintf(r.rw, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(r.rw, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := r.rw.Flush(); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readReply(r.rw.Reader)
+}
+
+func readReply(br *bufio.Reader) (any, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	body := line[1:]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(br); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
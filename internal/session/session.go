@@ -0,0 +1,152 @@
+// Package session keeps per-conversation state across turns.
+//
+// A conversation, identified by a session ID, spans any number of audio
+// streams: a voice assistant opens one per turn. Handlers load the
+// conversation's Session, read and write its values, and save it back to a
+// Store, which forgets it once it has been idle for the TTL. Values are
+// stored as JSON, so any Store can hold any serializable type.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Store.Get for unknown or expired sessions.
+var ErrNotFound = errors.New("session: not found")
+
+// Values is the stored form of a session: JSON values by key.
+type Values map[string]json.RawMessage
+
+// Store persists sessions. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	// Get returns the values of session id, or ErrNotFound.
+	Get(ctx context.Context, id string) (Values, error)
+	// Set replaces the values of session id and expires them after ttl.
+	Set(ctx context.Context, id string, v Values, ttl time.Duration) error
+	// Delete forgets session id. Deleting an unknown session is not an
+	// error.
+	Delete(ctx context.Context, id string) error
+}
+
+// DefaultTTL is how long an idle session is kept when the Manager is not
+// told otherwise.
+const DefaultTTL = 30 * time.Minute
+
+// Manager loads and saves sessions from a Store.
+type Manager struct {
+	store Store
+	ttl   time.Duration
+}
+
+// NewManager creates a manager. A zero ttl selects DefaultTTL.
+func NewManager(store Store, ttl time.Duration) *Manager {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Manager{store: store, ttl: ttl}
+}
+
+// Load returns session id, empty if it does not exist yet.
+func (m *Manager) Load(ctx context.Context, id string) (*Session, error) {
+	v, err := m.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		v, err = Values{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session: load %s: %w", id, err)
+	}
+	return &Session{ID: id, values: v}, nil
+}
+
+// Save writes s back and restarts its TTL.
+func (m *Manager) Save(ctx context.Context, s *Session) error {
+	s.mu.Lock()
+	v := make(Values, len(s.values))
+	for k, val := range s.values {
+		v[k] = val
+	}
+	s.mu.Unlock()
+	if err := m.store.Set(ctx, s.ID, v, m.ttl); err != nil {
+		return fmt.Errorf("session: save %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// Delete ends session id.
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	if err := m.store.Delete(ctx, id); err != nil {
+		return fmt.Errorf("session: delete %s: %w", id, err)
+	}
+	return nil
+}
+
+// Session is the state of one conversation. It is safe for concurrent use;
+// changes are local until the Manager saves it.
+type Session struct {
+	ID string
+
+	mu     sync.Mutex
+	values Values
+}
+
+// Get decodes the value stored under key into v. It reports false if the
+// key is not set.
+func (s *Session) Get(key string, v any) (bool, error) {
+	s.mu.Lock()
+	raw, ok := s.values[key]
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return true, fmt.Errorf("session: %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Set stores v under key.
+func (s *Session) Set(key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("session: %s: %w", key, err)
+	}
+	s.mu.Lock()
+	s.values[key] = raw
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	delete(s.values, key)
+	s.mu.Unlock()
+}
+
+// Keys returns the keys that are set, sorted.
+func (s *Session) Keys() []string {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	s.mu.Unlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// NewID returns a random session ID.
+func NewID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,183 @@
+// Package simd detects the vector extensions of the CPU voxa runs on, so
+// the local backends can pick the quantized model variants whose kernels
+// run best on it.
+//
+// The kernels themselves are those of the engines: ggml for whisper.cpp,
+// MLAS for ONNX Runtime. Both dispatch on the CPU as well, but a model
+// quantized for the wrong one wastes them: q8_0 ggml models run at speed
+// only with integer dot products (AVX2, AVX-512, NEON with dotprod), while
+// a Raspberry Pi 4 or an old server decodes q5 models faster for their
+// lower memory traffic. The Level of the CPU drives that choice; setting
+// $VOXA_SIMD to a lower level forces the kernels of that level, to compare
+// them or to work around a faulty extension.
+package simd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/cpu"
+)
+
+// EnvLevel is the environment variable capping the detected level.
+const EnvLevel = "VOXA_SIMD"
+
+// Level is the best kernel family a CPU runs.
+type Level int
+
+const (
+	// Generic is scalar code, or vector extensions voxa does not tell
+	// apart.
+	Generic Level = iota
+	// NEON is ARM Advanced SIMD, as on a Raspberry Pi 4.
+	NEON
+	// NEONDot is NEON with the int8 dot product instructions of ARMv8.2,
+	// as on a Raspberry Pi 5 or Graviton.
+	NEONDot
+	// AVX2 is AVX2 with FMA.
+	AVX2
+	// AVX512 is AVX-512 with byte, word and vector length extensions.
+	AVX512
+)
+
+var levelNames = [...]string{"generic", "neon", "neon-dotprod", "avx2", "avx512"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the name of a level, as String returns it.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("simd: unknown level %q (want %s)", s, strings.Join(levelNames[:], ", "))
+}
+
+// Features are the extensions of a CPU that quantized kernels use.
+type Features struct {
+	AVX, AVX2, FMA, F16C bool
+	// AVX512 is AVX-512 F, BW and VL.
+	AVX512 bool
+	// VNNI is AVX-512 VNNI or AVX-VNNI, int8 dot products on x86.
+	VNNI bool
+	NEON bool
+	// DotProd is the int8 dot products of ARMv8.2.
+	DotProd bool
+}
+
+// Detect returns the features of the CPU.
+var Detect = sync.OnceValue(func() Features {
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		x := cpu.X86
+		return Features{
+			AVX:  x.HasAVX,
+			AVX2: x.HasAVX2,
+			FMA:  x.HasFMA,
+			// x/sys/cpu does not report F16C, which every AVX2 CPU has.
+			F16C:   x.HasAVX2,
+			AVX512: x.HasAVX512F && x.HasAVX512BW && x.HasAVX512VL,
+			VNNI:   x.HasAVX512VNNI || x.HasAVXVNNI,
+		}
+	case "arm64":
+		a := cpu.ARM64
+		return Features{NEON: a.HasASIMD, DotProd: a.HasASIMDDP}
+	}
+	return Features{}
+})
+
+// Supports reports whether the CPU of f runs the kernels of l.
+func (f Features) Supports(l Level) bool {
+	switch l {
+	case Generic:
+		return true
+	case NEON:
+		return f.NEON
+	case NEONDot:
+		return f.NEON && f.DotProd
+	case AVX2:
+		return f.AVX2 && f.FMA
+	case AVX512:
+		return f.AVX512 && f.AVX2 && f.FMA
+	}
+	return false
+}
+
+// Level returns the best level f supports.
+func (f Features) Level() Level {
+	for l := AVX512; l > Generic; l-- {
+		if f.Supports(l) {
+			return l
+		}
+	}
+	return Generic
+}
+
+// String lists the features f has, e.g. "avx2 fma f16c".
+func (f Features) String() string {
+	var names []string
+	for _, e := range f.named() {
+		if e.has {
+			names = append(names, strings.ToLower(e.name))
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, " ")
+}
+
+type feature struct {
+	name string
+	has  bool
+}
+
+// named returns the features of f by the names engines report them under.
+func (f Features) named() []feature {
+	return []feature{
+		{"AVX", f.AVX}, {"AVX2", f.AVX2}, {"FMA", f.FMA}, {"F16C", f.F16C}, {"AVX512", f.AVX512},
+		{"VNNI", f.VNNI}, {"NEON", f.NEON}, {"DOTPROD", f.DotProd},
+	}
+}
+
+// Lacks returns which of names f does not have, of those it knows: AVX, AVX2,
+// FMA, F16C, AVX512, VNNI (also as AVX512_VNNI or AVX_VNNI), NEON and
+// DOTPROD, case insensitive. An engine built for features the CPU lacks
+// dies of an illegal instruction, so backends check its build against
+// them before loading it.
+func (f Features) Lacks(names ...string) []string {
+	var out []string
+	for _, name := range names {
+		n := strings.ToUpper(name)
+		if n == "AVX512_VNNI" || n == "AVX_VNNI" {
+			n = "VNNI"
+		}
+		for _, e := range f.named() {
+			if e.name == n && !e.has {
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+// Best returns the level of the CPU, capped by $VOXA_SIMD if it names a
+// level the CPU supports.
+var Best = sync.OnceValue(func() Level {
+	f := Detect()
+	if v := os.Getenv(EnvLevel); v != "" {
+		if l, err := ParseLevel(v); err == nil && f.Supports(l) {
+			return l
+		}
+	}
+	return f.Level()
+})
@@ -0,0 +1,118 @@
+// Package kafka publishes pipeline events to a Kafka topic, for
+// deployments whose transcripts feed stream processing.
+//
+// Every event is a record keyed by its session ID, so the events of a
+// session land on one partition, in order; partitions are picked as the
+// Java client does (murmur2), so producers in other languages agree. The
+// value is the event, encoded by Config.Encode as server.WireEvent JSON or
+// a voxadv1.Event protobuf, and the headers are:
+//
+//	voxa-event     the event type, such as final or session_end
+//	voxa-id        an ID unique to the event, to deduplicate on
+//	content-type   Config.ContentType
+//
+// Publishing is asynchronous: events are queued and written in batches, and
+// dropped if the brokers fall too far behind.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/sink"
+)
+
+// Config configures a Sink.
+type Config struct {
+	// Brokers are the bootstrap brokers, as host:port.
+	Brokers []string
+	// Topic is the topic events are published to. It must exist.
+	Topic string
+	// Events are the event types published. Defaults to all.
+	Events []voxa.EventType
+	// Encode encodes the record values.
+	Encode func(voxa.Event) ([]byte, error)
+	// ContentType is the media type of the encoding, sink.JSON or
+	// sink.Protobuf.
+	ContentType string
+	// QueueSize bounds the events waiting to be published. Defaults to
+	// sink.DefaultQueueSize.
+	QueueSize int
+	// Logger receives failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// Sink publishes events to Kafka. It is safe for concurrent use.
+type Sink struct {
+	cfg   Config
+	w     *kafka.Writer
+	batch *sink.Batcher
+}
+
+// New validates cfg and starts publishing. Brokers are not contacted until
+// the first event.
+func New(cfg Config) (*Sink, error) {
+	switch {
+	case len(cfg.Brokers) == 0:
+		return nil, errors.New("kafka: no brokers")
+	case cfg.Topic == "":
+		return nil, errors.New("kafka: no topic")
+	}
+	log := logging.With(logging.OrNop(cfg.Logger), "topic", cfg.Topic)
+	s := &Sink{cfg: cfg}
+	s.w = &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     kafka.Murmur2Balancer{},
+		BatchTimeout: 10 * time.Millisecond,
+		RequiredAcks: kafka.RequireAll,
+		ErrorLogger: kafka.LoggerFunc(func(msg string, args ...any) {
+			log.Warn("kafka: " + fmt.Sprintf(msg, args...))
+		}),
+	}
+	b, err := sink.NewBatcher(sink.BatcherConfig{
+		Events:    cfg.Events,
+		Encode:    cfg.Encode,
+		Flush:     s.flush,
+		QueueSize: cfg.QueueSize,
+		Logger:    log,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kafka: %w", err)
+	}
+	s.batch = b
+	return s, nil
+}
+
+// Publish queues ev.
+func (s *Sink) Publish(ev voxa.Event) { s.batch.Publish(ev) }
+
+func (s *Sink) flush(ctx context.Context, batch []sink.Message) error {
+	records := make([]kafka.Message, len(batch))
+	for i, m := range batch {
+		records[i] = kafka.Message{
+			Key:   []byte(m.Event.SessionID),
+			Value: m.Body,
+			Time:  m.Event.Time,
+			Headers: []kafka.Header{
+				{Key: "voxa-event", Value: []byte(m.Event.Type.String())},
+				{Key: "voxa-id", Value: []byte(m.ID)},
+				{Key: "content-type", Value: []byte(s.cfg.ContentType)},
+			},
+		}
+	}
+	return s.w.WriteMessages(ctx, records...)
+}
+
+// Close publishes the events queued, for up to 5 seconds, and closes the
+// connections.
+func (s *Sink) Close() error {
+	s.batch.Close()
+	return s.w.Close()
+}
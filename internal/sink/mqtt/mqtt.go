@@ -0,0 +1,356 @@
+// Package mqtt bridges voxa to an MQTT broker, for home automation such as
+// Home Assistant to react to what is said without glue code of its own.
+//
+// A Bridge is an event sink: the events of every stream are published,
+// encoded by Config.Encode, under the topic prefix (voxa by default):
+//
+//	voxa/wake_word        a wake word heard
+//	voxa/transcript       a final transcript
+//	voxa/intent/<name>    an intent recognized, before its transcript
+//	voxa/alert/<rule>     an alert raised by a rule the bridge is an
+//	                      action of; see voxa.AlertRule
+//	voxa/dtmf             a telephone keypad key pressed
+//	voxa/amd              who answered a call
+//	voxa/deferred         a transcription deferred, the recognizer
+//	                      being down; see voxa.DegradedConfig
+//	voxa/status           "online" or "offline", retained; the broker
+//	                      publishes "offline" if the bridge goes away
+//
+// Once given a synthesizer by Speak, the bridge also speaks: text
+// published to voxa/tts/say, plain or as JSON {"text", "voice", "id"}, is
+// synthesized and the audio published as a WAV file to
+// voxa/tts/audio/<id>, or the failure to voxa/tts/error/<id>. The ID
+// defaults to a random one.
+//
+// Levels of topics taken from names and IDs have "/", "+" and "#" replaced
+// by "_". The bridge reconnects on its own; events published while the
+// broker is unreachable are dropped.
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/tts"
+)
+
+const (
+	// queueSize bounds the messages waiting to be published.
+	queueSize = 256
+	// maxSpeaking bounds the TTS requests synthesized at once; more are
+	// refused.
+	maxSpeaking = 4
+	// publishTimeout bounds the wait for the broker to take a message.
+	publishTimeout = 10 * time.Second
+	// closeTimeout bounds the wait for in-flight work on Close.
+	closeTimeout = time.Second
+)
+
+// Config configures a Bridge.
+type Config struct {
+	// Broker is the broker URL: tcp://, ssl://, ws:// or wss://host:port.
+	Broker string
+	// ClientID defaults to "voxa-" and a random suffix.
+	ClientID string
+	Username string
+	Password string
+	// Prefix is the first level of every topic. Defaults to "voxa".
+	Prefix string
+	// QoS of the messages published and the subscription: 0, 1 or 2.
+	QoS byte
+	// Encode encodes the events published.
+	Encode func(voxa.Event) ([]byte, error)
+	// Logger receives connection changes and failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// Bridge publishes events to an MQTT broker and answers TTS requests. It is
+// safe for concurrent use.
+type Bridge struct {
+	cfg    Config
+	log    logging.Logger
+	client paho.Client
+	ctx    context.Context // cancelled on Close, aborting synthesis
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	queue    chan message
+	closed   bool
+	tts      voxa.Synthesizer // set by Speak
+	speaking chan struct{}    // a token per request being synthesized
+	wg       sync.WaitGroup
+	done     chan struct{}
+}
+
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// ttsRequest is a TTS request published as JSON.
+type ttsRequest struct {
+	Text  string `json:"text"`
+	Voice string `json:"voice,omitempty"`
+	ID    string `json:"id,omitempty"`
+}
+
+// New validates cfg and connects to the broker in the background,
+// retrying until it is reachable.
+func New(cfg Config) (*Bridge, error) {
+	u, err := url.Parse(cfg.Broker)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("mqtt: broker: %w", err)
+	case u.Scheme != "tcp" && u.Scheme != "ssl" && u.Scheme != "ws" && u.Scheme != "wss" || u.Host == "":
+		return nil, fmt.Errorf("mqtt: broker %q is not a tcp://, ssl://, ws:// or wss:// URL", cfg.Broker)
+	case cfg.QoS > 2:
+		return nil, fmt.Errorf("mqtt: QoS %d, want 0, 1 or 2", cfg.QoS)
+	case cfg.Encode == nil:
+		return nil, errors.New("mqtt: no event encoder")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "voxa"
+	}
+	if strings.ContainsAny(cfg.Prefix, "+#") || strings.HasSuffix(cfg.Prefix, "/") {
+		return nil, fmt.Errorf("mqtt: invalid topic prefix %q", cfg.Prefix)
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "voxa-" + newID()
+	}
+	b := &Bridge{
+		cfg:      cfg,
+		log:      logging.With(logging.OrNop(cfg.Logger), "broker", u.Redacted()),
+		queue:    make(chan message, queueSize),
+		speaking: make(chan struct{}, maxSpeaking),
+		done:     make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetWill(b.topic("status"), "offline", 1, true).
+		SetConnectRetry(true).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(b.connected).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			b.log.Warn("mqtt connection lost", "error", err)
+		})
+	b.client = paho.NewClient(opts)
+	b.client.Connect()
+	go b.run()
+	return b, nil
+}
+
+// connected announces the bridge and subscribes to TTS requests, on every
+// connection.
+func (b *Bridge) connected(c paho.Client) {
+	b.log.Info("mqtt connected")
+	// Straight to the client: the status must beat queued events.
+	c.Publish(b.topic("status"), 1, true, "online")
+	b.mu.Lock()
+	speak := b.tts != nil
+	b.mu.Unlock()
+	if speak {
+		b.subscribe()
+	}
+}
+
+// Speak answers the TTS requests published to <prefix>/tts/say with tts
+// from now on.
+func (b *Bridge) Speak(tts voxa.Synthesizer) {
+	b.mu.Lock()
+	first := b.tts == nil
+	b.tts = tts
+	b.mu.Unlock()
+	if first && b.client.IsConnectionOpen() {
+		b.subscribe()
+	}
+}
+
+func (b *Bridge) subscribe() {
+	t := b.client.Subscribe(b.topic("tts/say"), b.cfg.QoS, func(_ paho.Client, m paho.Message) {
+		b.say(m.Payload())
+	})
+	go func() {
+		if t.WaitTimeout(publishTimeout) && t.Error() != nil {
+			b.log.Warn("mqtt subscription failed", "topic", b.topic("tts/say"), "error", t.Error())
+		}
+	}()
+}
+
+// Publish queues ev to be published, dropping it if the broker is falling
+// behind.
+func (b *Bridge) Publish(ev voxa.Event) {
+	var topic string
+	switch ev.Type {
+	case voxa.EventWakeWord:
+		topic = b.topic("wake_word")
+	case voxa.EventFinal:
+		topic = b.topic("transcript")
+	case voxa.EventIntent:
+		topic = b.topic("intent", ev.Intent.Name)
+	case voxa.EventAlert:
+		topic = b.topic("alert", ev.Alert.Rule)
+	case voxa.EventDTMF:
+		topic = b.topic("dtmf")
+	case voxa.EventAMD:
+		topic = b.topic("amd")
+	case voxa.EventDeferred:
+		topic = b.topic("deferred")
+	default:
+		return
+	}
+	payload, err := b.cfg.Encode(ev)
+	if err != nil {
+		b.log.Warn("encode event", "event", ev.Type, "session", ev.SessionID, "error", err)
+		return
+	}
+	if !b.push(message{topic: topic, payload: payload}) {
+		b.log.Warn("mqtt falling behind, event dropped", "event", ev.Type, "session", ev.SessionID)
+	}
+}
+
+// push queues m, reporting false if it was dropped.
+func (b *Bridge) push(m message) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	select {
+	case b.queue <- m:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Bridge) run() {
+	defer close(b.done)
+	for m := range b.queue {
+		t := b.client.Publish(m.topic, b.cfg.QoS, false, m.payload)
+		if !t.WaitTimeout(publishTimeout) {
+			b.log.Warn("mqtt publish timed out", "topic", m.topic)
+		} else if err := t.Error(); err != nil {
+			b.log.Warn("mqtt publish failed", "topic", m.topic, "error", err)
+		}
+	}
+}
+
+// say synthesizes the TTS request in payload in the background.
+func (b *Bridge) say(payload []byte) {
+	req := ttsRequest{Text: string(payload)}
+	if strings.HasPrefix(strings.TrimSpace(req.Text), "{") {
+		req = ttsRequest{}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			b.log.Warn("bad TTS request", "error", err)
+			return
+		}
+	}
+	if req.ID == "" {
+		req.ID = newID()
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		b.fail(req.ID, errors.New("no text"))
+		return
+	}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	select {
+	case b.speaking <- struct{}{}:
+	default:
+		b.mu.Unlock()
+		b.fail(req.ID, fmt.Errorf("too many requests, %d being spoken", maxSpeaking))
+		return
+	}
+	b.wg.Add(1)
+	tts := b.tts
+	b.mu.Unlock()
+	go func() {
+		defer b.wg.Done()
+		defer func() { <-b.speaking }()
+		wav, err := b.synthesize(tts, req)
+		if err != nil {
+			b.fail(req.ID, err)
+			return
+		}
+		if !b.push(message{topic: b.topic("tts/audio", req.ID), payload: wav}) {
+			b.log.Warn("mqtt falling behind, speech dropped", "id", req.ID)
+		}
+	}()
+}
+
+// synthesize speaks req into a WAV file.
+func (b *Bridge) synthesize(s voxa.Synthesizer, req ttsRequest) ([]byte, error) {
+	out, err := s.Synthesize(b.ctx, voxa.SynthesisRequest{UtteranceID: req.ID, Text: req.Text, Voice: req.Voice})
+	if err != nil {
+		return nil, err
+	}
+	fr, err := tts.ReadAll(out)
+	if err != nil {
+		return nil, err
+	}
+	return audio.AppendWAV(nil, fr.Format, fr.Data), nil
+}
+
+// fail reports a failed TTS request.
+func (b *Bridge) fail(id string, err error) {
+	b.log.Warn("TTS request failed", "id", id, "error", err)
+	b.push(message{topic: b.topic("tts/error", id), payload: []byte(err.Error())})
+}
+
+// Close aborts TTS requests, publishes the events queued and the offline
+// status, and disconnects.
+func (b *Bridge) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+	b.cancel()
+	b.wg.Wait()
+	// Nothing is pushed once closed is set.
+	close(b.queue)
+	<-b.done
+	if b.client.IsConnectionOpen() {
+		b.client.Publish(b.topic("status"), 1, true, "offline").WaitTimeout(closeTimeout)
+	}
+	b.client.Disconnect(uint(closeTimeout.Milliseconds()))
+	return nil
+}
+
+// topic joins the prefix, base and the levels, escaped.
+func (b *Bridge) topic(base string, levels ...string) string {
+	t := b.cfg.Prefix + "/" + base
+	for _, l := range levels {
+		t += "/" + strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(l)
+	}
+	return t
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
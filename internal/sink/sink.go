@@ -0,0 +1,182 @@
+// Package sink holds what the event sinks publishing to message brokers
+// share: a Batcher queues the events of a pipeline, encoded, and hands them
+// to the broker client in batches, in order, from one goroutine, so the
+// streams publishing them never wait on the broker.
+//
+// The sinks themselves live in the packages below: mqtt, webhook, kafka
+// and nats.
+package sink
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+const (
+	// DefaultQueueSize bounds the events waiting to be published.
+	DefaultQueueSize = 4096
+	// maxBatch bounds the messages flushed at once.
+	maxBatch = 256
+	// drainTimeout bounds the time Close spends publishing the queue.
+	drainTimeout = 5 * time.Second
+)
+
+// Content types of the encodings.
+const (
+	JSON     = "application/json"
+	Protobuf = "application/protobuf"
+)
+
+// Message is an encoded event.
+type Message struct {
+	// ID is unique to the event, for brokers and consumers to deduplicate
+	// on.
+	ID    string
+	Event voxa.Event
+	Body  []byte
+}
+
+// BatcherConfig configures a Batcher.
+type BatcherConfig struct {
+	// Events are the event types published. Defaults to all.
+	Events []voxa.EventType
+	// Encode encodes the events.
+	Encode func(voxa.Event) ([]byte, error)
+	// Flush publishes a batch of messages: it has failed for them all if
+	// it returns an error. ctx ends when Close gives up draining.
+	Flush func(ctx context.Context, batch []Message) error
+	// QueueSize bounds the events waiting to be published; more are
+	// dropped. Defaults to DefaultQueueSize.
+	QueueSize int
+	// Logger receives dropped events and failed flushes. Nil discards
+	// them.
+	Logger logging.Logger
+}
+
+// Batcher queues events for a broker client. It is safe for concurrent
+// use.
+type Batcher struct {
+	cfg    BatcherConfig
+	log    logging.Logger
+	events map[voxa.EventType]bool // nil for all
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	queue  chan Message
+	closed bool
+}
+
+// NewBatcher validates cfg and starts flushing.
+func NewBatcher(cfg BatcherConfig) (*Batcher, error) {
+	switch {
+	case cfg.Encode == nil:
+		return nil, errors.New("sink: no event encoder")
+	case cfg.Flush == nil:
+		return nil, errors.New("sink: no flush")
+	case cfg.QueueSize < 0:
+		return nil, fmt.Errorf("sink: negative queue size %d", cfg.QueueSize)
+	}
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	b := &Batcher{
+		cfg:   cfg,
+		log:   logging.OrNop(cfg.Logger),
+		done:  make(chan struct{}),
+		queue: make(chan Message, cfg.QueueSize),
+	}
+	if len(cfg.Events) > 0 {
+		b.events = map[voxa.EventType]bool{}
+		for _, t := range cfg.Events {
+			b.events[t] = true
+		}
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	go b.run()
+	return b, nil
+}
+
+// Publish queues ev, dropping it if the broker is falling behind.
+func (b *Batcher) Publish(ev voxa.Event) {
+	if b.events != nil && !b.events[ev.Type] {
+		return
+	}
+	body, err := b.cfg.Encode(ev)
+	if err != nil {
+		b.log.Warn("encode event", "event", ev.Type, "session", ev.SessionID, "error", err)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	select {
+	case b.queue <- Message{ID: newID(), Event: ev, Body: body}:
+	default:
+		b.log.Warn("broker falling behind, event dropped", "event", ev.Type, "session", ev.SessionID)
+	}
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+	batch := make([]Message, 0, maxBatch)
+	for m := range b.queue {
+		if b.ctx.Err() != nil {
+			continue // given up on by Close
+		}
+		batch = append(batch[:0], m)
+	fill:
+		for len(batch) < maxBatch {
+			select {
+			case m, ok := <-b.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, m)
+			default:
+				break fill
+			}
+		}
+		if err := b.cfg.Flush(b.ctx, batch); err != nil {
+			b.log.Warn("publishing events failed", "events", len(batch), "error", err)
+		}
+	}
+}
+
+// Close stops taking events and publishes the queue for up to 5 seconds.
+func (b *Batcher) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+	t := time.NewTimer(drainTimeout)
+	defer t.Stop()
+	select {
+	case <-b.done:
+	case <-t.C:
+		b.log.Warn("closed with events unpublished", "events", len(b.queue))
+	}
+	b.cancel()
+	<-b.done
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,373 @@
+// Package webhook delivers pipeline events to an HTTP endpoint, so services
+// receive transcripts without holding a streaming connection open.
+//
+// A Sink POSTs every event, encoded by Config.Encode, to Config.URL, one at
+// a time and in order, with the headers:
+//
+//	X-Voxa-Event       the event type, such as final or intent
+//	X-Voxa-Delivery    an ID unique to the event, the same on redelivery
+//	X-Voxa-Timestamp   the Unix time the request was signed at
+//	X-Voxa-Signature   sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">,
+//	                   keyed with Config.Secret, if set
+//
+// Delivery is at least once: an event is retried with exponential backoff
+// until the endpoint answers 2xx, and stays at the head of the queue while
+// the endpoint is down, so receivers should deduplicate on the delivery
+// ID. Only a 4xx other than 408 or 429 gives up on an event, as rejected.
+// With Config.Spool the queue is kept on disk and survives restarts;
+// otherwise the events still queued when the sink is closed are lost.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+const (
+	// defaultQueueSize bounds the events waiting for delivery.
+	defaultQueueSize = 1024
+	// drainTimeout bounds the time Close spends delivering the queue.
+	drainTimeout = 5 * time.Second
+	// spoolExt names the files of the spool.
+	spoolExt = ".event"
+)
+
+// Config configures a Sink.
+type Config struct {
+	// URL is the endpoint events are POSTed to.
+	URL string
+	// Secret, if set, signs every request.
+	Secret string
+	// Events are the event types delivered. Defaults to final and intent.
+	Events []voxa.EventType
+	// Encode encodes the request bodies, as JSON.
+	Encode func(voxa.Event) ([]byte, error)
+	// Retry tunes the retries of a delivery; after the last, the event is
+	// tried again after the longest backoff.
+	Retry resilience.Config
+	// QueueSize bounds the events waiting for delivery; more are dropped.
+	// Defaults to 1024.
+	QueueSize int
+	// Spool, if set, is a directory the queue is kept in until delivered.
+	// Events found there on New are delivered first.
+	Spool string
+	// Client posts the events. Defaults to a client with a 10 second
+	// timeout.
+	Client *http.Client
+	// Logger receives delivery failures. Nil discards them.
+	Logger logging.Logger
+}
+
+// Sink delivers events to a webhook. It is safe for concurrent use.
+type Sink struct {
+	cfg    Config
+	log    logging.Logger
+	retry  *resilience.Policy
+	events map[voxa.EventType]bool
+	ctx    context.Context // cancelled once Close gives up draining
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	queue  []delivery
+	wake   chan struct{} // signalled when the queue grows or closes
+	seq    int64         // of the last spool file
+	closed bool
+}
+
+// delivery is an event waiting to be delivered.
+type delivery struct {
+	id    string
+	event string
+	body  []byte
+	file  string // in the spool, if any
+}
+
+// New validates cfg, loads its spool and starts delivering.
+func New(cfg Config) (*Sink, error) {
+	u, err := url.Parse(cfg.URL)
+	switch {
+	case cfg.URL == "":
+		return nil, errors.New("webhook: no URL")
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		return nil, fmt.Errorf("webhook: %q is not an http or https URL", cfg.URL)
+	case cfg.Encode == nil:
+		return nil, errors.New("webhook: no event encoder")
+	case cfg.QueueSize < 0:
+		return nil, fmt.Errorf("webhook: negative queue size %d", cfg.QueueSize)
+	}
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if len(cfg.Events) == 0 {
+		cfg.Events = []voxa.EventType{voxa.EventFinal, voxa.EventIntent}
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	log := logging.With(logging.OrNop(cfg.Logger), "webhook", u.Redacted())
+	retry, err := resilience.New(cfg.Retry, log)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: %w", err)
+	}
+	s := &Sink{
+		cfg:    cfg,
+		log:    log,
+		retry:  retry,
+		events: map[voxa.EventType]bool{},
+		done:   make(chan struct{}),
+		wake:   make(chan struct{}, 1),
+	}
+	for _, t := range cfg.Events {
+		s.events[t] = true
+	}
+	if cfg.Spool != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run()
+	return s, nil
+}
+
+// load queues the deliveries left in the spool, oldest first.
+func (s *Sink) load() error {
+	if err := os.MkdirAll(s.cfg.Spool, 0o755); err != nil {
+		return fmt.Errorf("webhook: spool: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(s.cfg.Spool, "*"+spoolExt))
+	if err != nil {
+		return fmt.Errorf("webhook: spool: %w", err)
+	}
+	slices.Sort(files) // by sequence number
+	for _, f := range files {
+		d, err := readSpool(f)
+		if err != nil {
+			s.log.Warn("unreadable spooled event skipped", "file", f, "error", err)
+			continue
+		}
+		s.queue = append(s.queue, d)
+		seq, _, _ := strings.Cut(filepath.Base(f), "-")
+		if n, err := strconv.ParseInt(seq, 10, 64); err == nil {
+			s.seq = max(s.seq, n)
+		}
+	}
+	if len(s.queue) > 0 {
+		s.log.Info("redelivering spooled events", "events", len(s.queue))
+	}
+	return nil
+}
+
+// Publish queues ev for delivery, writing it to the spool if there is one.
+// It drops ev if the queue is full.
+func (s *Sink) Publish(ev voxa.Event) {
+	if !s.events[ev.Type] {
+		return
+	}
+	body, err := s.cfg.Encode(ev)
+	if err != nil {
+		s.log.Warn("encode event", "event", ev.Type, "session", ev.SessionID, "error", err)
+		return
+	}
+	d := delivery{id: newID(), event: ev.Type.String(), body: body}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if len(s.queue) >= s.cfg.QueueSize {
+		s.log.Warn("webhook falling behind, event dropped", "event", ev.Type, "session", ev.SessionID)
+		return
+	}
+	if s.cfg.Spool != "" {
+		s.seq = max(s.seq+1, time.Now().UnixNano())
+		d.file = filepath.Join(s.cfg.Spool, fmt.Sprintf("%019d-%s%s", s.seq, d.id, spoolExt))
+		if err := writeSpool(d); err != nil {
+			s.log.Warn("spool event, delivering from memory", "event", ev.Type, "session", ev.SessionID, "error", err)
+			d.file = ""
+		}
+	}
+	s.queue = append(s.queue, d)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending returns how many events are waiting for delivery.
+func (s *Sink) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-s.wake:
+			case <-s.ctx.Done():
+				return
+			}
+			continue
+		}
+		d := s.queue[0]
+		s.mu.Unlock()
+
+		err := s.retry.Do(s.ctx, func() error { return s.post(d) })
+		var he *resilience.HTTPError
+		switch {
+		case err == nil:
+		case errors.As(err, &he) && he.StatusCode/100 == 4 && he.StatusCode != http.StatusRequestTimeout && he.StatusCode != http.StatusTooManyRequests:
+			s.log.Warn("webhook rejected event, dropped", "event", d.event, "delivery", d.id, "error", err)
+		case s.ctx.Err() != nil:
+			return
+		default:
+			// Keep the event at the head of the queue and try again later.
+			s.log.Warn("webhook delivery failed, will retry", "event", d.event, "delivery", d.id, "error", err)
+			t := time.NewTimer(max(s.retry.Backoff(s.retry.Attempts()), time.Second))
+			select {
+			case <-t.C:
+			case <-s.ctx.Done():
+				t.Stop()
+				return
+			}
+			continue
+		}
+		if d.file != "" {
+			if err := os.Remove(d.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+				s.log.Warn("remove spooled event", "file", d.file, "error", err)
+			}
+		}
+		s.mu.Lock()
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+	}
+}
+
+// post makes one delivery attempt.
+func (s *Sink) post(d delivery) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Voxa-Event", d.event)
+	req.Header.Set("X-Voxa-Delivery", d.id)
+	req.Header.Set("X-Voxa-Timestamp", ts)
+	if s.cfg.Secret != "" {
+		req.Header.Set("X-Voxa-Signature", Sign(s.cfg.Secret, ts, d.body))
+	}
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(msg))}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Sign returns the X-Voxa-Signature of body sent at timestamp, for
+// receivers to check requests with.
+func Sign(secret, timestamp string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(timestamp))
+	m.Write([]byte("."))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// Close stops taking events and delivers the queue for up to 5 seconds.
+// Events still queued then stay in the spool, if any, for the next run.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	t := time.NewTimer(drainTimeout)
+	defer t.Stop()
+	select {
+	case <-s.done:
+	case <-t.C:
+		if n := s.Pending(); n > 0 {
+			s.log.Warn("webhook closed with events undelivered", "events", n, "spooled", s.cfg.Spool != "")
+		}
+	}
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// A spool file holds the delivery ID and event type on its first line,
+// then the body.
+
+func writeSpool(d delivery) error {
+	tmp := d.file + ".tmp"
+	b := append([]byte(d.id+" "+d.event+"\n"), d.body...)
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.file)
+}
+
+func readSpool(file string) (delivery, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return delivery{}, err
+	}
+	line, body, ok := bytes.Cut(b, []byte("\n"))
+	if !ok {
+		return delivery{}, errors.New("no header")
+	}
+	id, event, ok := strings.Cut(string(line), " ")
+	if !ok {
+		return delivery{}, errors.New("bad header")
+	}
+	return delivery{id: id, event: event, body: body, file: file}, nil
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+func TestSign(t *testing.T) {
+	// printf '%s' '1700000000.{"session":"s1"}' | openssl dgst -sha256 -hmac shh
+	want := "sha256=9168bae288ac6d55fb0754f64fad693ded5107aeb2572ceae01177dbcb1a4cb1"
+	if got := Sign("shh", "1700000000", []byte(`{"session":"s1"}`)); got != want {
+		t.Errorf("Sign\n got %s\nwant %s", got, want)
+	}
+}
+
+// request is a request the endpoint received.
+type request struct {
+	at     time.Time
+	header http.Header
+	body   string
+}
+
+// endpoint is a webhook receiver answering with the statuses of replies in
+// turn, then 200.
+type endpoint struct {
+	*httptest.Server
+	mu       sync.Mutex
+	replies  []int
+	requests []request
+}
+
+func newEndpoint(t *testing.T, replies ...int) *endpoint {
+	e := &endpoint{replies: replies}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.requests = append(e.requests, request{time.Now(), r.Header.Clone(), string(body)})
+		status := http.StatusOK
+		if len(e.replies) > 0 {
+			status, e.replies = e.replies[0], e.replies[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *endpoint) received() []request {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.requests)
+}
+
+// send delivers a final event for each session and closes the sink.
+func send(t *testing.T, e *endpoint, secret string, sessions ...string) {
+	t.Helper()
+	s, err := New(Config{
+		URL:    e.URL,
+		Secret: secret,
+		Encode: func(ev voxa.Event) ([]byte, error) { return json.Marshal(map[string]string{"session": ev.SessionID}) },
+		Retry:  resilience.Config{Attempts: 3, Backoff: 20 * time.Millisecond, Jitter: -1, Threshold: -1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range sessions {
+		s.Publish(voxa.Event{Type: voxa.EventFinal, SessionID: id})
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignedRequests(t *testing.T) {
+	e := newEndpoint(t)
+	send(t, e, "shh", "s1")
+	reqs := e.received()
+	if len(reqs) != 1 {
+		t.Fatalf("%d requests, want 1", len(reqs))
+	}
+	r := reqs[0]
+	if r.body != `{"session":"s1"}` || r.header.Get("X-Voxa-Event") != "final" || r.header.Get("X-Voxa-Delivery") == "" {
+		t.Errorf("request %q with headers %v", r.body, r.header)
+	}
+	ts := r.header.Get("X-Voxa-Timestamp")
+	if sec, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(sec, 0)).Abs() > time.Minute {
+		t.Errorf("timestamp %q, want the time now", ts)
+	}
+	if got, want := r.header.Get("X-Voxa-Signature"), Sign("shh", ts, []byte(r.body)); got != want {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+
+	e = newEndpoint(t)
+	send(t, e, "", "s1")
+	if reqs = e.received(); len(reqs) != 1 {
+		t.Fatalf("without a secret: %d requests, want 1", len(reqs))
+	}
+	if sig := reqs[0].header.Get("X-Voxa-Signature"); sig != "" {
+		t.Errorf("without a secret: signature %q", sig)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		replies []int
+		want    []int // requests per event, in order
+	}{
+		{"delivered", nil, []int{1, 1}},
+		{"5xx retried", []int{503, 500}, []int{3, 1}},
+		{"5xx past the attempts", []int{503, 503, 503}, []int{4, 1}},
+		{"408 retried", []int{408}, []int{2, 1}},
+		{"429 retried", []int{429}, []int{2, 1}},
+		{"4xx dropped", []int{400}, []int{1, 1}},
+		{"403 dropped", []int{403}, []int{1, 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newEndpoint(t, tc.replies...)
+			send(t, e, "", "s1", "s2")
+			reqs := e.received()
+			var got []int
+			ids := map[string]string{} // delivery ID by session
+			for i, r := range reqs {
+				var body struct{ Session string }
+				if err := json.Unmarshal([]byte(r.body), &body); err != nil {
+					t.Fatal(err)
+				}
+				if i == 0 || reqs[i-1].body != r.body {
+					got = append(got, 0)
+				}
+				got[len(got)-1]++
+				id := r.header.Get("X-Voxa-Delivery")
+				if prev, ok := ids[body.Session]; ok && prev != id {
+					t.Errorf("%s redelivered as %s, was %s", body.Session, id, prev)
+				}
+				ids[body.Session] = id
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("requests per event %v, want %v", got, tc.want)
+			}
+			if ids["s1"] == ids["s2"] {
+				t.Errorf("both events delivered as %s", ids["s1"])
+			}
+			// The waits double from Config.Retry.Backoff.
+			for i := 1; i < len(reqs) && reqs[i].body == reqs[0].body; i++ {
+				if wait, want := reqs[i].at.Sub(reqs[i-1].at), 20*time.Millisecond<<(i-1); wait < want {
+					t.Errorf("retry %d after %v, want at least %v", i, wait, want)
+				}
+			}
+		})
+	}
+}
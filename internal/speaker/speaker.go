@@ -0,0 +1,323 @@
+// Package speaker identifies speakers against enrolled voiceprints, so
+// diarized transcripts say "Alice" rather than "S1".
+//
+// A Registry holds one voiceprint per enrolled speaker: the embedding of
+// their speech, computed as the diarize package computes those of live
+// speakers. Enrolling a speaker again with more audio refines their
+// voiceprint, weighted by how much speech each recording held. The
+// registry implements diarize.Identifier: a diarized speaker is named after
+// the enrolled one most similar to them, if that similarity reaches the
+// Threshold.
+//
+// Similarity is 1/(1+d) for the embedding distance d the diarizer clusters
+// by, so it lies in (0, 1] and the default threshold of 0.5 accepts the
+// distance at which the diarizer merges speech into a known speaker.
+package speaker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/vad"
+	"github.com/jmarc101/voxa/internal/diarize"
+)
+
+// Defaults.
+const (
+	defaultThreshold = 0.5
+	// sampleRate is the rate enrollment audio is embedded at, the one
+	// recognizers take.
+	sampleRate = 16000
+	// minSpeech is the least speech a recording must hold to enroll, so
+	// a voiceprint averages a few windows.
+	minSpeech = 3 * time.Second
+	maxName   = 64
+)
+
+// Errors.
+var (
+	// ErrNotFound is returned for speakers who are not enrolled.
+	ErrNotFound = errors.New("speaker: not enrolled")
+	// ErrTooShort is returned when a recording holds too little speech to
+	// enroll.
+	ErrTooShort = errors.New("speaker: recording holds too little speech")
+	// ErrBadName is returned for names that are empty, too long, or hold
+	// control characters or surrounding spaces.
+	ErrBadName = errors.New("speaker: bad name")
+)
+
+// Config configures a Registry.
+type Config struct {
+	// File, if set, is the JSON file voiceprints are kept in, created on
+	// the first enrollment. Otherwise they are kept in memory only.
+	File string
+	// Threshold is the similarity, in (0, 1], from which a speaker is
+	// identified as an enrolled one. Higher names fewer speakers, and
+	// fewer wrongly. Defaults to 0.5.
+	Threshold float64
+}
+
+// Voiceprint is an enrolled speaker.
+type Voiceprint struct {
+	Name string `json:"name"`
+	// Embedding is the speaker embedding of their enrolled speech.
+	Embedding []float64 `json:"embedding"`
+	// Speech is how much speech the voiceprint was computed from.
+	Speech time.Duration `json:"speech"`
+	// Enrolled is when the speaker was first enrolled, Updated when the
+	// voiceprint last changed.
+	Enrolled time.Time `json:"enrolled"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Registry holds the voiceprints of enrolled speakers. It is safe for
+// concurrent use.
+type Registry struct {
+	cfg Config
+
+	mu     sync.RWMutex
+	prints map[string]*Voiceprint
+}
+
+// New validates cfg and loads the voiceprints in cfg.File, if it exists.
+func New(cfg Config) (*Registry, error) {
+	if cfg.Threshold == 0 {
+		cfg.Threshold = defaultThreshold
+	}
+	if cfg.Threshold < 0 || cfg.Threshold > 1 {
+		return nil, fmt.Errorf("speaker: threshold %v out of (0, 1]", cfg.Threshold)
+	}
+	r := &Registry{cfg: cfg, prints: map[string]*Voiceprint{}}
+	if cfg.File == "" {
+		return r, nil
+	}
+	b, err := os.ReadFile(cfg.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("speaker: %w", err)
+	}
+	var prints []*Voiceprint
+	if err := json.Unmarshal(b, &prints); err != nil {
+		return nil, fmt.Errorf("speaker: %s: %w", cfg.File, err)
+	}
+	for _, v := range prints {
+		r.prints[v.Name] = v
+	}
+	return r, nil
+}
+
+// Enroll adds the speech in src, a recording of the named speaker alone, to
+// their voiceprint, enrolling them if they are not yet. Silence is cut out;
+// at least three seconds of speech must remain. It returns the updated
+// voiceprint.
+func (r *Registry) Enroll(name string, src audio.Reader) (Voiceprint, error) {
+	if err := checkName(name); err != nil {
+		return Voiceprint{}, err
+	}
+	pcm, err := speech(src)
+	if err != nil {
+		return Voiceprint{}, err
+	}
+	dur := audio.Format{SampleRate: sampleRate, Channels: 1}.Duration(len(pcm))
+	if dur < minSpeech {
+		return Voiceprint{}, fmt.Errorf("%w: %v, need %v", ErrTooShort, dur.Round(10*time.Millisecond), minSpeech)
+	}
+	e, err := diarize.Embed(diarize.Config{}, pcm, sampleRate)
+	if err != nil {
+		return Voiceprint{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	v := &Voiceprint{Name: name, Embedding: e, Speech: dur, Enrolled: now, Updated: now}
+	if old, ok := r.prints[name]; ok && len(old.Embedding) == len(e) {
+		// Weigh the recordings by their speech.
+		w := float64(old.Speech) / float64(old.Speech+dur)
+		for i := range e {
+			v.Embedding[i] = w*old.Embedding[i] + (1-w)*e[i]
+		}
+		v.Speech += old.Speech
+		v.Enrolled = old.Enrolled
+	}
+	prev := r.prints[name]
+	r.prints[name] = v
+	if err := r.save(); err != nil {
+		if prev != nil {
+			r.prints[name] = prev
+		} else {
+			delete(r.prints, name)
+		}
+		return Voiceprint{}, err
+	}
+	return *v, nil
+}
+
+// Remove forgets the named speaker.
+func (r *Registry) Remove(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.prints[name]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.prints, name)
+	if err := r.save(); err != nil {
+		r.prints[name] = v
+		return err
+	}
+	return nil
+}
+
+// List returns the enrolled speakers by name.
+func (r *Registry) List() []Voiceprint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Voiceprint, 0, len(r.prints))
+	for _, v := range r.prints {
+		out = append(out, *v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Identify returns the enrolled speaker most similar to embedding, if they
+// are similar enough. It implements diarize.Identifier.
+func (r *Registry) Identify(embedding []float64) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	best, sim := "", 0.0
+	for name, v := range r.prints {
+		if s := Similarity(embedding, v.Embedding); s > sim || (s == sim && name < best) {
+			best, sim = name, s
+		}
+	}
+	if best == "" || sim < r.cfg.Threshold {
+		return "", false
+	}
+	return best, true
+}
+
+// Similarity returns the similarity of two speaker embeddings, in (0, 1],
+// or 0 if they do not have the same length.
+func Similarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return 1 / (1 + math.Sqrt(sum))
+}
+
+// save writes the voiceprints to the file, if any. r.mu must be held. The
+// file is replaced in one step, so a crash leaves the old one.
+func (r *Registry) save() error {
+	if r.cfg.File == "" {
+		return nil
+	}
+	prints := make([]*Voiceprint, 0, len(r.prints))
+	for _, v := range r.prints {
+		prints = append(prints, v)
+	}
+	sort.Slice(prints, func(i, j int) bool { return prints[i].Name < prints[j].Name })
+	b, err := json.MarshalIndent(prints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("speaker: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.cfg.File), ".voxa-*")
+	if err != nil {
+		return fmt.Errorf("speaker: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), r.cfg.File)
+	}
+	if err != nil {
+		return fmt.Errorf("speaker: %w", err)
+	}
+	return nil
+}
+
+func checkName(name string) error {
+	switch {
+	case len(name) > maxName:
+		return fmt.Errorf("%w: longer than %d bytes", ErrBadName, maxName)
+	case name == "" || strings.TrimSpace(name) != name || strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return fmt.Errorf("%w %q", ErrBadName, name)
+	}
+	return nil
+}
+
+// speech reads src as mono audio at sampleRate and returns its speech,
+// cut out by voice activity detection.
+func speech(src audio.Reader) ([]int16, error) {
+	target := audio.Format{SampleRate: sampleRate, Channels: 1}
+	var conv *audio.Converter
+	if src.Format() != target {
+		c, err := audio.NewConverter(src.Format(), target, audio.QualityMedium)
+		if err != nil {
+			return nil, fmt.Errorf("speaker: %w", err)
+		}
+		conv = c
+	}
+	det, err := vad.New(vad.Config{})
+	if err != nil {
+		return nil, err
+	}
+	var pending, out []int16
+	step := target.Samples(audio.FrameDuration)
+	keep := func(frames []audio.Frame) {
+		for _, fr := range frames {
+			pending = append(pending, fr.Data...)
+		}
+		for len(pending) >= step {
+			fr := audio.Frame{Format: target, Data: pending[:step]}
+			if det.IsSpeech(fr) {
+				out = append(out, fr.Data...)
+			}
+			pending = pending[step:]
+		}
+	}
+	for {
+		fr, err := src.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("speaker: read audio: %w", err)
+		}
+		if conv == nil {
+			keep([]audio.Frame{fr})
+			continue
+		}
+		frames, err := conv.Process(fr)
+		if err != nil {
+			return nil, fmt.Errorf("speaker: %w", err)
+		}
+		keep(frames)
+	}
+	if conv != nil {
+		keep(conv.Flush())
+	}
+	return out, nil
+}
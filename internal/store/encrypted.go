@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/envelope"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// ErrEncryptedSearch is returned by the Search of an encrypted store, whose
+// database cannot index text it only holds encrypted.
+var ErrEncryptedSearch = errors.New("store: transcripts are encrypted and cannot be searched")
+
+// sealedPrefix marks text holding encrypted fields.
+const sealedPrefix = "voxa-sealed:"
+
+// Encrypted is a TranscriptStore keeping the text of segments and
+// summaries encrypted in another: the text, words and translations of a
+// segment, and the text and action items of a summary, are sealed together
+// into the text the store holds. Times, speakers, languages and the
+// sessions themselves stay in the clear, for listing and paging. Text
+// stored before encryption was turned on is still read back, as it is.
+type Encrypted struct {
+	ts  TranscriptStore
+	env *envelope.Envelope
+}
+
+// Encrypt returns a TranscriptStore encrypting transcripts with env before
+// storing them in ts. It implements io.Closer, closing ts if it is one.
+func Encrypt(ts TranscriptStore, env *envelope.Envelope) *Encrypted {
+	return &Encrypted{ts: ts, env: env}
+}
+
+// sealedSegment is what is sealed of a segment.
+type sealedSegment struct {
+	Text         string            `json:"text"`
+	Words        []stt.Word        `json:"words,omitempty"`
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+// sealedSummary is what is sealed of a summary.
+type sealedSummary struct {
+	Text        string   `json:"text"`
+	ActionItems []string `json:"action_items,omitempty"`
+}
+
+func (e *Encrypted) seal(ctx context.Context, v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	if b, err = e.env.Seal(ctx, b); err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// open unseals text into v, reporting false for text in the clear.
+func (e *Encrypted) open(ctx context.Context, text string, v any) (bool, error) {
+	b64, ok := strings.CutPrefix(text, sealedPrefix)
+	if !ok {
+		return false, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return false, envelope.ErrCorrupt
+	}
+	if b, err = e.env.Open(ctx, b); err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+func (e *Encrypted) sealSegment(ctx context.Context, seg stt.Segment) (stt.Segment, error) {
+	text, err := e.seal(ctx, sealedSegment{Text: seg.Text, Words: seg.Words, Translations: seg.Translations})
+	seg.Text, seg.Words, seg.Translations = text, nil, nil
+	return seg, err
+}
+
+func (e *Encrypted) openSegment(ctx context.Context, seg *stt.Segment) error {
+	var s sealedSegment
+	ok, err := e.open(ctx, seg.Text, &s)
+	if ok {
+		seg.Text, seg.Words, seg.Translations = s.Text, s.Words, s.Translations
+	}
+	return err
+}
+
+// StartSession implements TranscriptStore.
+func (e *Encrypted) StartSession(ctx context.Context, id, tenant string, t time.Time) error {
+	return e.ts.StartSession(ctx, id, tenant, t)
+}
+
+// EndSession implements TranscriptStore.
+func (e *Encrypted) EndSession(ctx context.Context, id string, t time.Time) error {
+	return e.ts.EndSession(ctx, id, t)
+}
+
+// AddSegment implements TranscriptStore.
+func (e *Encrypted) AddSegment(ctx context.Context, id string, seg stt.Segment) error {
+	seg, err := e.sealSegment(ctx, seg)
+	if err != nil {
+		return err
+	}
+	return e.ts.AddSegment(ctx, id, seg)
+}
+
+// Session implements TranscriptStore.
+func (e *Encrypted) Session(ctx context.Context, id string) (Session, error) {
+	return e.ts.Session(ctx, id)
+}
+
+// Sessions implements TranscriptStore.
+func (e *Encrypted) Sessions(ctx context.Context, q Query) ([]Session, error) {
+	return e.ts.Sessions(ctx, q)
+}
+
+// Segments implements TranscriptStore.
+func (e *Encrypted) Segments(ctx context.Context, id string, version int) ([]Segment, error) {
+	segs, err := e.ts.Segments(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	for i := range segs {
+		if err := e.openSegment(ctx, &segs[i].Segment); err != nil {
+			return nil, err
+		}
+	}
+	return segs, nil
+}
+
+// AddVersion implements TranscriptStore.
+func (e *Encrypted) AddVersion(ctx context.Context, id string, v Version, segs []stt.Segment) (Version, error) {
+	sealed := make([]stt.Segment, len(segs))
+	for i, seg := range segs {
+		var err error
+		if sealed[i], err = e.sealSegment(ctx, seg); err != nil {
+			return Version{}, err
+		}
+	}
+	return e.ts.AddVersion(ctx, id, v, sealed)
+}
+
+// Versions implements TranscriptStore.
+func (e *Encrypted) Versions(ctx context.Context, id string) ([]Version, error) {
+	return e.ts.Versions(ctx, id)
+}
+
+// AddArchive implements TranscriptStore.
+func (e *Encrypted) AddArchive(ctx context.Context, id string, a Archive) error {
+	return e.ts.AddArchive(ctx, id, a)
+}
+
+// Archives implements TranscriptStore.
+func (e *Encrypted) Archives(ctx context.Context, id string) ([]Archive, error) {
+	return e.ts.Archives(ctx, id)
+}
+
+// Search implements TranscriptStore, failing with ErrEncryptedSearch.
+func (e *Encrypted) Search(ctx context.Context, q SearchQuery) ([]Hit, error) {
+	return nil, ErrEncryptedSearch
+}
+
+// SetSummary implements TranscriptStore.
+func (e *Encrypted) SetSummary(ctx context.Context, id string, s Summary) error {
+	text, err := e.seal(ctx, sealedSummary{Text: s.Text, ActionItems: s.ActionItems})
+	if err != nil {
+		return err
+	}
+	s.Text, s.ActionItems = text, nil
+	return e.ts.SetSummary(ctx, id, s)
+}
+
+// Summary implements TranscriptStore.
+func (e *Encrypted) Summary(ctx context.Context, id string) (Summary, error) {
+	s, err := e.ts.Summary(ctx, id)
+	if err != nil {
+		return s, err
+	}
+	var sealed sealedSummary
+	ok, err := e.open(ctx, s.Text, &sealed)
+	if err != nil {
+		return Summary{}, err
+	}
+	if ok {
+		s.Text, s.ActionItems = sealed.Text, sealed.ActionItems
+	}
+	return s, nil
+}
+
+// SetMetadata implements TranscriptStore. Metadata is stored in clear,
+// like the times and tenants of sessions.
+func (e *Encrypted) SetMetadata(ctx context.Context, id string, md map[string]string) error {
+	return e.ts.SetMetadata(ctx, id, md)
+}
+
+// DeleteSession implements TranscriptStore.
+func (e *Encrypted) DeleteSession(ctx context.Context, id string) error {
+	return e.ts.DeleteSession(ctx, id)
+}
+
+// Close closes the store encrypted into.
+func (e *Encrypted) Close() error {
+	if c, ok := e.ts.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
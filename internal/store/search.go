@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchQuery selects segments by their text.
+//
+// Text holds words that must all appear in a segment, in any order and
+// case. A "quoted phrase" must appear as written, and a word ending in *
+// matches any word it starts, so "refund*" finds "refunded".
+type SearchQuery struct {
+	Text string
+	// Session, if set, restricts the search to one session.
+	Session string
+	// Tenant, if set, restricts the search to the sessions of that tenant.
+	Tenant string
+	// Since and Until, if set, restrict the search to segments stored in
+	// [Since, Until).
+	Since, Until time.Time
+	// Limit bounds the hits returned. Defaults to 100.
+	Limit int
+}
+
+// Hit is a segment matching a search.
+type Hit struct {
+	Segment
+	// Snippet is the part of the text around the match, with the matching
+	// words between SnippetStart and SnippetEnd.
+	Snippet string
+	// Score ranks the hits of one search: higher is more relevant.
+	Score float64
+}
+
+// The marks around matching words in Hit.Snippet.
+const (
+	SnippetStart = "**"
+	SnippetEnd   = "**"
+)
+
+// ErrEmptySearch is returned by Search for a query without any word.
+var ErrEmptySearch = errors.New("store: search has no words")
+
+// Search returns the segments matching q, most relevant first.
+func (s *SQL) Search(ctx context.Context, q SearchQuery) ([]Hit, error) {
+	terms := parseSearch(q.Text)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	query, args := s.d.search, []any{s.d.match(terms)}
+	if q.Session != "" {
+		query += ` AND g.session_id = ?`
+		args = append(args, q.Session)
+	}
+	if q.Tenant != "" {
+		query += ` AND g.session_id IN (SELECT id FROM voxa_sessions WHERE tenant = ?)`
+		args = append(args, q.Tenant)
+	}
+	if !q.Since.IsZero() {
+		query += ` AND g.added_at >= ?`
+		args = append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		query += ` AND g.added_at < ?`
+		args = append(args, q.Until.UTC())
+	}
+	query += ` ORDER BY score DESC, g.id LIMIT ?`
+	args = append(args, q.Limit)
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, s.wrap(err)
+	}
+	defer rows.Close()
+	var out []Hit
+	for rows.Next() {
+		var h Hit
+		if h.Segment, err = s.scanSegment(rows, &h.Snippet, &h.Score); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, s.wrap(rows.Err())
+}
+
+// term is a word or phrase of a search.
+type term struct {
+	words  []string // lower case letters and digits only
+	prefix bool     // the last word is a prefix
+}
+
+// parseSearch splits a query into terms. Punctuation separates words, so a
+// term like "don't" is the phrase "don t", as the indexes see it.
+func parseSearch(q string) []term {
+	split := func(s string) []string {
+		return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+	}
+	var terms []term
+	for q != "" {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		var tok string
+		prefix := false
+		if strings.HasPrefix(q, `"`) {
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				end = len(q) - 1
+			}
+			tok, q = q[1:end+1], q[min(end+2, len(q)):]
+		} else {
+			end := strings.IndexFunc(q, unicode.IsSpace)
+			if end < 0 {
+				end = len(q)
+			}
+			tok, q = q[:end], q[end:]
+			prefix = strings.HasSuffix(tok, "*")
+		}
+		if words := split(tok); len(words) > 0 {
+			terms = append(terms, term{words: words, prefix: prefix})
+		}
+	}
+	return terms
+}
+
+// fts5Match renders terms as an SQLite FTS5 query.
+func fts5Match(terms []term) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = `"` + strings.Join(t.words, " ") + `"`
+		if t.prefix {
+			parts[i] += "*"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// tsqueryMatch renders terms as a PostgreSQL tsquery.
+func tsqueryMatch(terms []term) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		words := make([]string, len(t.words))
+		for j, w := range t.words {
+			words[j] = "'" + w + "'"
+		}
+		if t.prefix {
+			words[len(words)-1] += ":*"
+		}
+		parts[i] = strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, " & ")
+}
@@ -0,0 +1,533 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// SQL is a TranscriptStore in a SQL database. The SQLite and PostgreSQL
+// stores share it and differ only in their schema, placeholders and
+// full-text search.
+//
+// Sessions are rows of voxa_sessions and segments rows of voxa_segments,
+// with words, translations and redactions as JSON text, so transcripts
+// can also be queried with plain SQL. Summaries are rows of
+// voxa_summaries, with their action items as a JSON array.
+type SQL struct {
+	db *sql.DB
+	d  dialect
+}
+
+// dialect is what a database needs to differ in.
+type dialect struct {
+	name string
+	// schema creates the tables if they do not exist.
+	schema []string
+	// numbered selects $1, $2... placeholders instead of ?.
+	numbered bool
+	// search selects segmentColumns, a snippet and a score from the
+	// segments matching the query in its one placeholder.
+	search string
+	// match renders a parsed search as the dialect's query syntax.
+	match func([]term) string
+	// hasColumn counts the columns of the table in its first placeholder
+	// named as in its second.
+	hasColumn string
+}
+
+// columns were added to the schema after its first release, and are added
+// to the tables of databases created before.
+var columns = []struct{ table, name, def string }{
+	{"voxa_segments", "version", "INTEGER NOT NULL DEFAULT 0"},
+	{"voxa_sessions", "tenant", "TEXT NOT NULL DEFAULT ''"},
+	{"voxa_sessions", "metadata", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// sqlTimeout bounds statements whose context has no deadline.
+const sqlTimeout = 5 * time.Second
+
+// openSQL connects to the database and creates the schema.
+func openSQL(driver, dsn string, d dialect) (*SQL, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("store: %s: %w", d.name, err)
+	}
+	s := &SQL{db: db, d: d}
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	for _, stmt := range d.schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("store: %s: create schema: %w", d.name, err)
+		}
+	}
+	for _, c := range columns {
+		var n int
+		err := db.QueryRowContext(ctx, s.rebind(d.hasColumn), c.table, c.name).Scan(&n)
+		if err == nil && n == 0 {
+			_, err = db.ExecContext(ctx, `ALTER TABLE `+c.table+` ADD COLUMN `+c.name+` `+c.def)
+		}
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("store: %s: add %s.%s: %w", d.name, c.table, c.name, err)
+		}
+	}
+	return s, nil
+}
+
+// StartSession implements TranscriptStore.
+func (s *SQL) StartSession(ctx context.Context, id, tenant string, t time.Time) error {
+	return s.exec(ctx, `INSERT INTO voxa_sessions (id, tenant, started_at) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET ended_at = NULL`, id, tenant, t.UTC())
+}
+
+// EndSession implements TranscriptStore.
+func (s *SQL) EndSession(ctx context.Context, id string, t time.Time) error {
+	return s.exec(ctx, `UPDATE voxa_sessions SET ended_at = ? WHERE id = ?`, t.UTC(), id)
+}
+
+// AddSegment implements TranscriptStore.
+func (s *SQL) AddSegment(ctx context.Context, id string, seg stt.Segment) error {
+	args, err := segmentArgs(id, seg, 0)
+	if err != nil {
+		return err
+	}
+	return s.exec(ctx, insertSegment, args...)
+}
+
+const insertSegment = `INSERT INTO voxa_segments
+	(session_id, utterance_id, text, speaker, language, provider, start_ms, end_ms, confidence, words, translations, redactions, added_at, version)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// segmentArgs returns the values of insertSegment.
+func segmentArgs(id string, seg stt.Segment, version int) ([]any, error) {
+	words, err := marshalJSON(wordsJSON(seg.Words))
+	if err != nil {
+		return nil, err
+	}
+	translations, err := marshalJSON(seg.Translations)
+	if err != nil {
+		return nil, err
+	}
+	redactions, err := marshalJSON(redactionsJSON(seg.Redactions))
+	if err != nil {
+		return nil, err
+	}
+	return []any{id, seg.UtteranceID, seg.Text, seg.Speaker, seg.Language, seg.Provider,
+		seg.Start.Milliseconds(), seg.End.Milliseconds(), seg.Confidence,
+		words, translations, redactions, time.Now().UTC(), version}, nil
+}
+
+// AddVersion implements TranscriptStore. The version and its segments are
+// stored in one transaction, so a version is never seen half made.
+func (s *SQL) AddVersion(ctx context.Context, id string, v Version, segs []stt.Segment) (Version, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Version{}, s.wrap(err)
+	}
+	defer tx.Rollback()
+	if err := tx.QueryRowContext(ctx, s.rebind(`SELECT COALESCE(MAX(version), 0) + 1
+		FROM voxa_versions WHERE session_id = ?`), id).Scan(&v.Number); err != nil {
+		return Version{}, s.wrap(err)
+	}
+	v.Created, v.Segments = time.Now().UTC(), len(segs)
+	if _, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO voxa_versions
+		(session_id, version, provider, label, created_at) VALUES (?, ?, ?, ?, ?)`),
+		id, v.Number, v.Provider, v.Label, v.Created); err != nil {
+		return Version{}, s.wrap(err)
+	}
+	for _, seg := range segs {
+		args, err := segmentArgs(id, seg, v.Number)
+		if err != nil {
+			return Version{}, err
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(insertSegment), args...); err != nil {
+			return Version{}, s.wrap(err)
+		}
+	}
+	return v, s.wrap(tx.Commit())
+}
+
+// Versions implements TranscriptStore.
+func (s *SQL) Versions(ctx context.Context, id string) ([]Version, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT v.version, v.provider, v.label, v.created_at,
+		(SELECT COUNT(*) FROM voxa_segments g WHERE g.session_id = v.session_id AND g.version = v.version)
+		FROM voxa_versions v WHERE v.session_id = ? ORDER BY v.version`), id)
+	if err != nil {
+		return nil, s.wrap(err)
+	}
+	defer rows.Close()
+	var out []Version
+	for rows.Next() {
+		var v Version
+		if err := rows.Scan(&v.Number, &v.Provider, &v.Label, &v.Created, &v.Segments); err != nil {
+			return nil, s.wrap(err)
+		}
+		out = append(out, v)
+	}
+	return out, s.wrap(rows.Err())
+}
+
+// AddArchive implements TranscriptStore.
+func (s *SQL) AddArchive(ctx context.Context, id string, a Archive) error {
+	return s.exec(ctx, `INSERT INTO voxa_archives (session_id, name, stream, part, started_at, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (session_id, name) DO UPDATE SET stream = excluded.stream, part = excluded.part,
+			started_at = excluded.started_at, duration_ms = excluded.duration_ms`,
+		id, a.Name, a.Stream, a.Part, a.Started.UTC(), a.Duration.Milliseconds())
+}
+
+// Archives implements TranscriptStore.
+func (s *SQL) Archives(ctx context.Context, id string) ([]Archive, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT name, stream, part, started_at, duration_ms
+		FROM voxa_archives WHERE session_id = ? ORDER BY started_at, stream, part`), id)
+	if err != nil {
+		return nil, s.wrap(err)
+	}
+	defer rows.Close()
+	var out []Archive
+	for rows.Next() {
+		var a Archive
+		var ms int64
+		if err := rows.Scan(&a.Name, &a.Stream, &a.Part, &a.Started, &ms); err != nil {
+			return nil, s.wrap(err)
+		}
+		a.Duration = time.Duration(ms) * time.Millisecond
+		out = append(out, a)
+	}
+	return out, s.wrap(rows.Err())
+}
+
+// SetSummary implements TranscriptStore.
+func (s *SQL) SetSummary(ctx context.Context, id string, sum Summary) error {
+	items, err := marshalJSON(sum.ActionItems)
+	if err != nil {
+		return err
+	}
+	return s.exec(ctx, `INSERT INTO voxa_summaries (session_id, text, action_items, label, segments, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (session_id) DO UPDATE SET text = excluded.text, action_items = excluded.action_items,
+			label = excluded.label, segments = excluded.segments, created_at = excluded.created_at`,
+		id, sum.Text, items, sum.Label, sum.Segments, sum.Created.UTC())
+}
+
+// Summary implements TranscriptStore.
+func (s *SQL) Summary(ctx context.Context, id string) (Summary, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var sum Summary
+	var items string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT text, action_items, label, segments, created_at
+		FROM voxa_summaries WHERE session_id = ?`), id).Scan(&sum.Text, &items, &sum.Label, &sum.Segments, &sum.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Summary{}, ErrNotFound
+	}
+	if err != nil {
+		return Summary{}, s.wrap(err)
+	}
+	if err := unmarshalJSON(items, &sum.ActionItems); err != nil {
+		return Summary{}, fmt.Errorf("store: summary of %s: %w", id, err)
+	}
+	return sum, nil
+}
+
+// DeleteSession implements TranscriptStore, in one transaction.
+func (s *SQL) DeleteSession(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.wrap(err)
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM voxa_sessions WHERE id = ?`), id)
+	if err != nil {
+		return s.wrap(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return s.wrap(err)
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	for _, table := range []string{"voxa_segments", "voxa_versions", "voxa_archives", "voxa_summaries"} {
+		if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM `+table+` WHERE session_id = ?`), id); err != nil {
+			return s.wrap(err)
+		}
+	}
+	return s.wrap(tx.Commit())
+}
+
+// SetMetadata implements TranscriptStore, in one transaction.
+func (s *SQL) SetMetadata(ctx context.Context, id string, md map[string]string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.wrap(err)
+	}
+	defer tx.Rollback()
+	var stored string
+	err = tx.QueryRowContext(ctx, s.rebind(`SELECT metadata FROM voxa_sessions WHERE id = ?`), id).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return s.wrap(err)
+	}
+	merged := map[string]string{}
+	if err := unmarshalJSON(stored, &merged); err != nil {
+		return err
+	}
+	for k, v := range md {
+		if v == "" {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	if stored, err = marshalJSON(merged); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(`UPDATE voxa_sessions SET metadata = ? WHERE id = ?`), stored, id); err != nil {
+		return s.wrap(err)
+	}
+	return s.wrap(tx.Commit())
+}
+
+const selectSessions = `SELECT s.id, s.tenant, s.metadata, s.started_at, s.ended_at,
+	(SELECT COUNT(*) FROM voxa_segments g WHERE g.session_id = s.id AND g.version = 0),
+	(SELECT COUNT(*) FROM voxa_versions v WHERE v.session_id = s.id)
+	FROM voxa_sessions s`
+
+// Session implements TranscriptStore.
+func (s *SQL) Session(ctx context.Context, id string) (Session, error) {
+	sessions, err := s.sessions(ctx, selectSessions+` WHERE s.id = ?`, id)
+	if err != nil {
+		return Session{}, err
+	}
+	if len(sessions) == 0 {
+		return Session{}, ErrNotFound
+	}
+	return sessions[0], nil
+}
+
+// Sessions implements TranscriptStore.
+func (s *SQL) Sessions(ctx context.Context, q Query) ([]Session, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	query, args := selectSessions+` WHERE 1 = 1`, []any{}
+	switch {
+	case !q.Before.IsZero() && q.BeforeID != "":
+		query += ` AND (s.started_at < ? OR (s.started_at = ? AND s.id > ?))`
+		args = append(args, q.Before.UTC(), q.Before.UTC(), q.BeforeID)
+	case !q.Before.IsZero():
+		query += ` AND s.started_at < ?`
+		args = append(args, q.Before.UTC())
+	}
+	if !q.Since.IsZero() {
+		query += ` AND s.started_at >= ?`
+		args = append(args, q.Since.UTC())
+	}
+	if q.Tenant != "" {
+		query += ` AND s.tenant = ?`
+		args = append(args, q.Tenant)
+	}
+	query += ` ORDER BY s.started_at DESC, s.id LIMIT ?`
+	return s.sessions(ctx, query, append(args, q.Limit)...)
+}
+
+func (s *SQL) sessions(ctx context.Context, query string, args ...any) ([]Session, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, s.wrap(err)
+	}
+	defer rows.Close()
+	var out []Session
+	for rows.Next() {
+		var sess Session
+		var md string
+		var ended sql.NullTime
+		if err := rows.Scan(&sess.ID, &sess.Tenant, &md, &sess.Started, &ended, &sess.Segments, &sess.Versions); err != nil {
+			return nil, s.wrap(err)
+		}
+		if err := unmarshalJSON(md, &sess.Metadata); err != nil {
+			return nil, err
+		}
+		sess.Ended = ended.Time
+		out = append(out, sess)
+	}
+	return out, s.wrap(rows.Err())
+}
+
+// Segments implements TranscriptStore.
+func (s *SQL) Segments(ctx context.Context, id string, version int) ([]Segment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+segmentColumns+`
+		FROM voxa_segments g WHERE g.session_id = ? AND g.version = ? ORDER BY g.id`), id, version)
+	if err != nil {
+		return nil, s.wrap(err)
+	}
+	defer rows.Close()
+	var out []Segment
+	for rows.Next() {
+		seg, err := s.scanSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, seg)
+	}
+	return out, s.wrap(rows.Err())
+}
+
+// segmentColumns are the columns scanSegment reads, of voxa_segments as g.
+const segmentColumns = `g.session_id, g.utterance_id, g.text, g.speaker, g.language, g.provider,
+	g.start_ms, g.end_ms, g.confidence, g.words, g.translations, g.redactions, g.added_at, g.version`
+
+// scanSegment reads segmentColumns, followed by the columns in extra.
+func (s *SQL) scanSegment(rows *sql.Rows, extra ...any) (Segment, error) {
+	var seg Segment
+	var start, end int64
+	var words, translations, redactions string
+	dest := []any{&seg.Session, &seg.UtteranceID, &seg.Text, &seg.Speaker, &seg.Language, &seg.Provider,
+		&start, &end, &seg.Confidence, &words, &translations, &redactions, &seg.Added, &seg.Version}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return Segment{}, s.wrap(err)
+	}
+	seg.Final, seg.Stability = true, 1
+	seg.Start, seg.End = time.Duration(start)*time.Millisecond, time.Duration(end)*time.Millisecond
+	var ws []wordJSON
+	var rs []redactionJSON
+	if err := errors.Join(
+		unmarshalJSON(words, &ws),
+		unmarshalJSON(translations, &seg.Translations),
+		unmarshalJSON(redactions, &rs),
+	); err != nil {
+		return Segment{}, fmt.Errorf("store: segment of %s: %w", seg.Session, err)
+	}
+	for _, w := range ws {
+		seg.Words = append(seg.Words, stt.Word{
+			Text:       w.Text,
+			Start:      time.Duration(w.StartMS) * time.Millisecond,
+			End:        time.Duration(w.EndMS) * time.Millisecond,
+			Confidence: w.Confidence,
+		})
+	}
+	for _, r := range rs {
+		seg.Redactions = append(seg.Redactions, stt.Redaction(r))
+	}
+	return seg, nil
+}
+
+// Close closes the database.
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQL) exec(ctx context.Context, query string, args ...any) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, s.rebind(query), args...)
+	return s.wrap(err)
+}
+
+// rebind rewrites the ? placeholders of query for the dialect.
+func (s *SQL) rebind(query string) string {
+	if !s.d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+func (s *SQL) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("store: %s: %w", s.d.name, err)
+}
+
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, sqlTimeout)
+}
+
+// wordJSON and redactionJSON are the stored forms of words and
+// redactions.
+type wordJSON struct {
+	Text       string  `json:"text"`
+	StartMS    int64   `json:"start_ms"`
+	EndMS      int64   `json:"end_ms"`
+	Confidence float32 `json:"confidence,omitempty"`
+}
+
+type redactionJSON struct {
+	Entity string `json:"entity"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	At     int    `json:"at"`
+}
+
+func wordsJSON(words []stt.Word) []wordJSON {
+	var out []wordJSON
+	for _, w := range words {
+		out = append(out, wordJSON{Text: w.Text, StartMS: w.Start.Milliseconds(), EndMS: w.End.Milliseconds(), Confidence: w.Confidence})
+	}
+	return out
+}
+
+func redactionsJSON(rs []stt.Redaction) []redactionJSON {
+	var out []redactionJSON
+	for _, r := range rs {
+		out = append(out, redactionJSON(r))
+	}
+	return out
+}
+
+// marshalJSON encodes v, or returns "" for empty values so columns stay
+// readable.
+func marshalJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("store: %w", err)
+	}
+	if s := string(b); s != "null" && s != "{}" && s != "[]" {
+		return s, nil
+	}
+	return "", nil
+}
+
+func unmarshalJSON(s string, v any) error {
+	if s == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
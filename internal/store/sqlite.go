@@ -0,0 +1,99 @@
+package store
+
+import (
+	// The pure Go SQLite driver, so voxad needs no cgo.
+	_ "modernc.org/sqlite"
+)
+
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS voxa_sessions (
+		id TEXT PRIMARY KEY,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS voxa_sessions_started ON voxa_sessions (started_at)`,
+	`CREATE TABLE IF NOT EXISTS voxa_segments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id TEXT NOT NULL,
+		utterance_id TEXT NOT NULL,
+		text TEXT NOT NULL,
+		speaker TEXT NOT NULL,
+		language TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		start_ms INTEGER NOT NULL,
+		end_ms INTEGER NOT NULL,
+		confidence REAL NOT NULL,
+		words TEXT NOT NULL,
+		translations TEXT NOT NULL,
+		redactions TEXT NOT NULL,
+		added_at DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS voxa_segments_session ON voxa_segments (session_id, id)`,
+	`CREATE TABLE IF NOT EXISTS voxa_versions (
+		session_id TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		provider TEXT NOT NULL,
+		label TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (session_id, version)
+	)`,
+	`CREATE TABLE IF NOT EXISTS voxa_archives (
+		session_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		stream TEXT NOT NULL,
+		part INTEGER NOT NULL,
+		started_at DATETIME NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		PRIMARY KEY (session_id, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS voxa_summaries (
+		session_id TEXT PRIMARY KEY,
+		text TEXT NOT NULL,
+		action_items TEXT NOT NULL,
+		label TEXT NOT NULL,
+		segments INTEGER NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	// The full-text index of the segments, kept up to date by triggers;
+	// segments are never updated, only deleted with their session.
+	`CREATE VIRTUAL TABLE IF NOT EXISTS voxa_segments_fts USING fts5(
+		text, content='voxa_segments', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS voxa_segments_fts_insert AFTER INSERT ON voxa_segments BEGIN
+		INSERT INTO voxa_segments_fts (rowid, text) VALUES (new.id, new.text);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS voxa_segments_fts_delete AFTER DELETE ON voxa_segments BEGIN
+		INSERT INTO voxa_segments_fts (voxa_segments_fts, rowid, text) VALUES ('delete', old.id, old.text);
+	END`,
+	// Indexes the segments of databases that predate the index.
+	`INSERT INTO voxa_segments_fts (voxa_segments_fts) SELECT 'rebuild'
+		WHERE (SELECT COUNT(*) FROM voxa_segments) != (SELECT COUNT(*) FROM voxa_segments_fts_docsize)`,
+}
+
+const sqliteSearch = `SELECT ` + segmentColumns + `,
+	snippet(voxa_segments_fts, 0, '` + SnippetStart + `', '` + SnippetEnd + `', '…', 12),
+	-bm25(voxa_segments_fts) AS score
+	FROM voxa_segments_fts JOIN voxa_segments g ON g.id = voxa_segments_fts.rowid
+	WHERE voxa_segments_fts MATCH ?`
+
+// NewSQLite opens, creating it if needed, the SQLite database at path. The
+// database runs in WAL mode, so transcripts can be read while sessions
+// write to it, and searches use an FTS5 index. Deleted sessions are
+// overwritten on disk rather than left in free pages.
+func NewSQLite(path string) (*SQL, error) {
+	s, err := openSQL("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=secure_delete(on)&_time_format=sqlite",
+		dialect{
+			name:      "sqlite",
+			schema:    sqliteSchema,
+			search:    sqliteSearch,
+			match:     fts5Match,
+			hasColumn: `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`,
+		})
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has a single writer; one connection serializes access
+	// instead of failing on a busy database.
+	s.db.SetMaxOpenConns(1)
+	return s, nil
+}
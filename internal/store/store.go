@@ -0,0 +1,167 @@
+// Package store persists transcripts, so what was said in a session can be
+// looked up after the session has ended.
+//
+// A TranscriptStore records every session with its start and end, and the
+// final segments of its streams with their times, speaker labels,
+// languages, words and translations. Partial hypotheses are not stored.
+// It also lists the files a session's audio was archived in, so the
+// session can be transcribed again, each time into a new version of its
+// transcript, and keeps the summary of a session once it has been
+// summarized.
+//
+// SQLite suits a single voxad; PostgreSQL lets replicas share one
+// database. Both index the text of segments for full-text search.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// ErrNotFound is returned by TranscriptStore.Session for unknown sessions.
+var ErrNotFound = errors.New("store: not found")
+
+// TranscriptStore persists transcripts. Implementations must be safe for
+// concurrent use; those holding connections implement io.Closer.
+type TranscriptStore interface {
+	// StartSession records that session id of tenant, the name of its API
+	// key if any, started at t. Starting a known session again, as when a
+	// conversation spans several streams, marks it running and keeps its
+	// first start and tenant.
+	StartSession(ctx context.Context, id, tenant string, t time.Time) error
+	// EndSession records that session id ended at t.
+	EndSession(ctx context.Context, id string, t time.Time) error
+	// AddSegment appends a final segment to session id.
+	AddSegment(ctx context.Context, id string, seg stt.Segment) error
+	// Session returns session id, or ErrNotFound.
+	Session(ctx context.Context, id string) (Session, error)
+	// Sessions lists sessions, most recently started first.
+	Sessions(ctx context.Context, q Query) ([]Session, error)
+	// Segments returns the segments of a version of the transcript of
+	// session id in the order they were added, version 0 being the one
+	// made live; none for unknown sessions or versions.
+	Segments(ctx context.Context, id string, version int) ([]Segment, error)
+	// AddVersion stores segs as the next version of the transcript of
+	// session id, made from its audio again, and returns the version with
+	// its number set.
+	AddVersion(ctx context.Context, id string, v Version, segs []stt.Segment) (Version, error)
+	// Versions lists the versions of the transcript of session id made
+	// after the live one, oldest first.
+	Versions(ctx context.Context, id string) ([]Version, error)
+	// AddArchive records that a part of the audio of session id was
+	// archived.
+	AddArchive(ctx context.Context, id string, a Archive) error
+	// Archives lists the archived audio of session id, in the order it
+	// was recorded.
+	Archives(ctx context.Context, id string) ([]Archive, error)
+	// Search returns the segments matching q, most relevant first.
+	Search(ctx context.Context, q SearchQuery) ([]Hit, error)
+	// SetSummary stores the summary of session id, replacing any earlier
+	// one.
+	SetSummary(ctx context.Context, id string, s Summary) error
+	// Summary returns the summary of session id, or ErrNotFound if it has
+	// none.
+	Summary(ctx context.Context, id string) (Summary, error)
+	// SetMetadata merges md into the metadata of session id, an empty
+	// value removing its key, or returns ErrNotFound.
+	SetMetadata(ctx context.Context, id string, md map[string]string) error
+	// DeleteSession deletes session id with every version of its
+	// transcript, its summary and the list of its archives, or returns
+	// ErrNotFound. The archives themselves are the caller's to delete.
+	DeleteSession(ctx context.Context, id string) error
+}
+
+// Session is a stored session.
+type Session struct {
+	ID string
+	// Tenant is the name of the API key the session was opened with, if
+	// any.
+	Tenant  string
+	Started time.Time
+	// Ended is zero while the session is running, or if voxad stopped
+	// before it ended.
+	Ended time.Time
+	// Segments counts the segments of the live transcript.
+	Segments int
+	// Versions counts the versions made after the live transcript.
+	Versions int
+	// Metadata is what transports recorded about the session, such as
+	// the consent of a caller to being recorded.
+	Metadata map[string]string
+}
+
+// Segment is a stored final segment.
+type Segment struct {
+	stt.Segment
+	// Session is the ID of the session the segment belongs to.
+	Session string
+	// Added is when the segment was stored.
+	Added time.Time
+	// Version is the version of the transcript the segment belongs to; 0
+	// for the live transcript.
+	Version int
+}
+
+// Version is a transcript of a session made again from its archived
+// audio.
+type Version struct {
+	// Number counts the versions of a session from 1.
+	Number int
+	// Provider is the STT provider that made the version.
+	Provider string
+	// Label describes how the version was made, e.g. the model.
+	Label   string
+	Created time.Time
+	// Segments counts the segments of the version.
+	Segments int
+}
+
+// Summary is the summary of the transcript of a session.
+type Summary struct {
+	Text string
+	// ActionItems are the follow-ups agreed on in the session, if any.
+	ActionItems []string
+	// Label describes how the summary was made, e.g. the model.
+	Label   string
+	Created time.Time
+	// Segments counts the segments of the transcript summarized.
+	Segments int
+}
+
+// Archive is a file of archived session audio.
+type Archive struct {
+	// Name locates the file in the archive storage.
+	Name string
+	// Stream tells apart the streams of a session; the parts of one
+	// stream are numbered from 1 and follow each other without a gap.
+	Stream string
+	Part   int
+	// Started is when the recording of the part started.
+	Started time.Time
+	// Duration is the audio in the part.
+	Duration time.Duration
+}
+
+// Query selects sessions.
+type Query struct {
+	// Before, if set, selects sessions started before it, for paging.
+	Before time.Time
+	// BeforeID, with Before, also selects the sessions started at Before
+	// whose ID sorts after it: set to the last session of a page, Before
+	// and BeforeID select the sessions listed after it, even those
+	// started in the same instant.
+	BeforeID string
+	// Since, if set, selects sessions started at or after it.
+	Since time.Time
+	// Tenant, if set, selects the sessions of that tenant only.
+	Tenant string
+	// Limit bounds the sessions returned. Defaults to 100.
+	Limit int
+}
+
+// DefaultLimit is the number of sessions Sessions returns when the query
+// sets none.
+const DefaultLimit = 100
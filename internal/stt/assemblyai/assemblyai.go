@@ -0,0 +1,465 @@
+// Package assemblyai is a speech-to-text backend for the AssemblyAI
+// Universal-Streaming API (wss://streaming.assemblyai.com/v3/ws).
+//
+// AssemblyAI recognizes speech in turns, each sent again whole as it
+// grows, so a turn is an utterance and its updates are partials: the turn
+// ending (end_of_turn), on a pause or when ForceEndpoint is sent, is the
+// final, once formatted with punctuation and casing.
+//
+// The API takes audio in messages of 50ms to 1s, which streams buffer
+// writes into. It has no keepalive message: streams send 50ms of silence
+// while no audio is written, so the session outlives the silences the
+// pipeline gates, and keep the timings of the transcripts on the clock of
+// the audio written by taking it out again. Connections dropped or closed
+// by the service restarting fail their stream with a transient error,
+// which the stt resilience of Config.Resilience reconnects, with the
+// audio not finalized yet replayed. Phrases are sent as keyterms; see
+// stt.PhraseBiaser.
+package assemblyai
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// ProviderName is the name the backend registers under. Its options are
+// "endpoint", "api_key" (defaulting to $ASSEMBLYAI_API_KEY), "model",
+// "format_turns" and "keepalive".
+const ProviderName = "assemblyai"
+
+// DefaultEndpoint is the public streaming endpoint.
+const DefaultEndpoint = "wss://streaming.assemblyai.com/v3/ws"
+
+// Bounds of the audio a message may carry.
+const (
+	minChunk = 50 * time.Millisecond
+	maxChunk = time.Second
+)
+
+func init() {
+	stt.Register(ProviderName, func(cfg stt.Config) (stt.Provider, error) {
+		format, err := strconv.ParseBool(cfg.Option("format_turns", "true"))
+		if err != nil {
+			return nil, fmt.Errorf("bad format_turns %q", cfg.Options["format_turns"])
+		}
+		c := Config{
+			Endpoint:    cfg.Option("endpoint", DefaultEndpoint),
+			APIKey:      cfg.Option("api_key", os.Getenv("ASSEMBLYAI_API_KEY")),
+			Model:       cfg.Option("model", ""),
+			FormatTurns: format,
+			Logger:      cfg.Logger,
+		}
+		if v := cfg.Option("keepalive", ""); v != "" {
+			if c.KeepAlive, err = time.ParseDuration(v); err != nil || c.KeepAlive <= 0 {
+				return nil, fmt.Errorf("bad keepalive %q", v)
+			}
+		}
+		return New(c)
+	})
+}
+
+// Config configures the client.
+type Config struct {
+	Endpoint string
+	APIKey   string
+	// Model is the speech model, e.g. "universal-streaming-multilingual";
+	// empty uses the service default, English.
+	Model string
+	// FormatTurns waits for every turn to be punctuated and cased before
+	// finalizing it.
+	FormatTurns bool
+	// KeepAlive is how long a stream goes without audio before sending
+	// silence. Defaults to 5s.
+	KeepAlive time.Duration
+	// Logger receives the client's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Provider opens streams against AssemblyAI.
+type Provider struct {
+	cfg Config
+}
+
+var (
+	_ stt.Provider        = (*Provider)(nil)
+	_ stt.PhraseBiaser    = (*Provider)(nil)
+	_ stt.StreamOverrider = (*Provider)(nil)
+)
+
+// New validates cfg.
+func New(cfg Config) (*Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("api_key is required")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = 5 * time.Second
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	return &Provider{cfg: cfg}, nil
+}
+
+// BiasesPhrases implements stt.PhraseBiaser.
+func (p *Provider) BiasesPhrases() bool { return true }
+
+// Overrides implements stt.StreamOverrider: streams may ask for a speech
+// model. Languages are those of the model, recognized as spoken.
+func (p *Provider) Overrides() (language, model bool) { return false, true }
+
+// NewStream implements stt.Provider.
+func (p *Provider) NewStream(ctx context.Context, cfg stt.StreamConfig) (stt.StreamingRecognizer, error) {
+	if cfg.SampleRate <= 0 {
+		return nil, errors.New("assemblyai: sample rate required")
+	}
+	q := url.Values{
+		"sample_rate":  {strconv.Itoa(cfg.SampleRate)},
+		"encoding":     {"pcm_s16le"},
+		"format_turns": {strconv.FormatBool(p.cfg.FormatTurns)},
+	}
+	if cfg.Endpointing > 0 {
+		// Turns the model is unsure have ended end after that much
+		// silence, as the pipeline's do.
+		q.Set("max_turn_silence", strconv.FormatInt(cfg.Endpointing.Milliseconds(), 10))
+	}
+	if model := cmp.Or(cfg.Model, p.cfg.Model); model != "" {
+		q.Set("speech_model", model)
+	}
+	if len(cfg.Phrases) > 0 {
+		terms := make([]string, len(cfg.Phrases))
+		for i, ph := range cfg.Phrases {
+			terms[i] = ph.Text
+		}
+		b, _ := json.Marshal(terms)
+		q.Set("keyterms_prompt", string(b))
+	}
+	conn, resp, err := websocket.Dial(ctx, p.cfg.Endpoint+"?"+q.Encode(), &websocket.DialOptions{
+		HTTPHeader: map[string][]string{"Authorization": {p.cfg.APIKey}},
+	})
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("assemblyai: %w", &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return nil, fmt.Errorf("assemblyai: dial: %w", err)
+	}
+	conn.SetReadLimit(1 << 20)
+	log := cfg.Logger
+	if log == nil {
+		log = p.cfg.Logger
+	}
+	uid := cfg.UtteranceID
+	if uid == "" {
+		uid = newUtteranceID()
+	}
+	log.Debug("assemblyai stream opened", "utterance", uid, "sample_rate", cfg.SampleRate)
+	format := audio.Format{SampleRate: cfg.SampleRate, Channels: 1}
+	s := &stream{
+		ctx:     ctx,
+		conn:    conn,
+		log:     log,
+		format:  format,
+		min:     2 * format.Samples(minChunk),
+		max:     2 * format.Samples(maxChunk),
+		last:    time.Now(),
+		first:   uid,
+		results: make(chan stt.Segment, 16),
+		done:    make(chan struct{}),
+	}
+	go s.recv(p.cfg.FormatTurns)
+	go s.keepAlive(p.cfg.KeepAlive)
+	return s, nil
+}
+
+// stream implements stt.StreamingRecognizer on one connection.
+type stream struct {
+	ctx    context.Context
+	conn   *websocket.Conn
+	log    logging.Logger
+	format audio.Format
+
+	mu       sync.Mutex // guards sends and the fields below
+	closed   bool
+	pending  []byte // audio not sent yet, shorter than min
+	min, max int    // bytes of audio a message carries
+	last     time.Time
+	sent     int       // bytes of audio sent, silence included
+	silences []silence // sent by keepAlive, in order
+
+	first   string // the ID of the first utterance
+	results chan stt.Segment
+	err     error
+	done    chan struct{} // closed once recv returns
+}
+
+// silence is keepalive audio sent at a point of the session's clock.
+type silence struct{ at, d time.Duration }
+
+func (s *stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, stt.ErrClosed
+	}
+	s.pending = append(s.pending, p...)
+	for len(s.pending) >= s.min {
+		n := min(len(s.pending), s.max)
+		n -= n % 2
+		if err := s.send(websocket.MessageBinary, s.pending[:n]); err != nil {
+			return 0, fmt.Errorf("assemblyai: send audio: %w", err)
+		}
+		s.pending = s.pending[n:]
+	}
+	if len(s.pending) == 0 {
+		s.pending = nil
+	}
+	return len(p), nil
+}
+
+// Flush forces the end of the turn. Audio still buffered, less than 50ms,
+// goes with the next one.
+func (s *stream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return stt.ErrClosed
+	}
+	return s.control("ForceEndpoint")
+}
+
+// Close sends the audio still buffered, padded to the shortest message
+// with silence, and ends the session.
+func (s *stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if len(s.pending) > 0 {
+		p := append(s.pending, make([]byte, s.min-len(s.pending))...)
+		if err := s.send(websocket.MessageBinary, p); err != nil {
+			return fmt.Errorf("assemblyai: send audio: %w", err)
+		}
+		s.pending = nil
+	}
+	return s.control("Terminate")
+}
+
+func (s *stream) Results() <-chan stt.Segment { return s.results }
+
+func (s *stream) Err() error { return s.err }
+
+// control sends the message of type t. s.mu must be held.
+func (s *stream) control(t string) error {
+	if err := s.send(websocket.MessageText, []byte(`{"type":"`+t+`"}`)); err != nil {
+		return fmt.Errorf("assemblyai: send %s: %w", t, err)
+	}
+	return nil
+}
+
+// send must be called with s.mu held.
+func (s *stream) send(typ websocket.MessageType, p []byte) error {
+	if err := s.conn.Write(s.ctx, typ, p); err != nil {
+		return &connError{err}
+	}
+	if typ == websocket.MessageBinary {
+		s.sent += len(p)
+	}
+	s.last = time.Now()
+	return nil
+}
+
+// keepAlive sends silence whenever the stream has sent nothing for d,
+// until it ends.
+func (s *stream) keepAlive(d time.Duration) {
+	t := time.NewTicker(d / 4)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+		}
+		s.mu.Lock()
+		if !s.closed && time.Since(s.last) >= d {
+			at := s.format.Duration(s.sent / 2)
+			if err := s.send(websocket.MessageBinary, make([]byte, s.min)); err != nil {
+				s.log.Debug("assemblyai keepalive failed", "error", err)
+			} else {
+				s.silences = append(s.silences, silence{at: at, d: s.format.Duration(s.min / 2)})
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// clock maps t, on the clock of the session, onto that of the audio
+// written, without the silence sent before it.
+func (s *stream) clock(t time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var skip time.Duration
+	for _, sl := range s.silences {
+		if sl.at >= t {
+			break
+		}
+		skip += min(sl.d, t-sl.at)
+	}
+	return t - skip
+}
+
+// message is a message of the API.
+type message struct {
+	Type       string `json:"type"`
+	TurnOrder  int    `json:"turn_order"`
+	Formatted  bool   `json:"turn_is_formatted"`
+	EndOfTurn  bool   `json:"end_of_turn"`
+	Transcript string `json:"transcript"`
+	Words      []struct {
+		Text       string  `json:"text"`
+		Start      int64   `json:"start"` // ms
+		End        int64   `json:"end"`
+		Confidence float32 `json:"confidence"`
+		Final      bool    `json:"word_is_final"`
+	} `json:"words"`
+	Error string `json:"error"`
+}
+
+// recv pumps turns into s.results until the session ends or the stream's
+// context is done.
+func (s *stream) recv(formatted bool) {
+	defer close(s.results)
+	defer close(s.done)
+	defer s.conn.CloseNow()
+
+	ids := map[int]string{}
+	revs := map[int]int{}
+	stab := map[int]*stt.Stabilizer{}
+	next := s.first
+	for {
+		typ, b, err := s.conn.Read(s.ctx)
+		if err != nil {
+			switch {
+			case s.ctx.Err() != nil:
+				s.log.Debug("assemblyai stream cancelled")
+			case websocket.CloseStatus(err) == websocket.StatusNormalClosure:
+				s.log.Debug("assemblyai stream ended")
+			default:
+				s.err = fmt.Errorf("assemblyai: recv: %w", &connError{err})
+				s.log.Error("assemblyai stream failed", "error", err)
+			}
+			return
+		}
+		if typ != websocket.MessageText {
+			continue
+		}
+		var m message
+		if err := json.Unmarshal(b, &m); err != nil {
+			s.err = fmt.Errorf("assemblyai: bad message: %w", err)
+			return
+		}
+		switch m.Type {
+		case "Turn":
+		case "Termination":
+			s.log.Debug("assemblyai session terminated")
+			continue
+		case "Error":
+			s.err = fmt.Errorf("assemblyai: %s", m.Error)
+			s.log.Error("assemblyai error", "error", m.Error)
+			return
+		default:
+			continue
+		}
+		if m.Transcript == "" && len(m.Words) == 0 {
+			continue
+		}
+		uid, ok := ids[m.TurnOrder]
+		if !ok {
+			uid, next = next, newUtteranceID()
+			ids[m.TurnOrder] = uid
+			stab[m.TurnOrder] = &stt.Stabilizer{}
+		}
+		final := m.EndOfTurn && (m.Formatted || !formatted)
+		revs[m.TurnOrder]++
+		seg := stt.Segment{UtteranceID: uid, Revision: revs[m.TurnOrder], Final: final, Text: m.Transcript}
+		var words []string
+		var conf float32
+		for _, w := range m.Words {
+			seg.Words = append(seg.Words, stt.Word{
+				Text:       w.Text,
+				Start:      s.clock(time.Duration(w.Start) * time.Millisecond),
+				End:        s.clock(time.Duration(w.End) * time.Millisecond),
+				Confidence: w.Confidence,
+			})
+			words = append(words, w.Text)
+			conf += w.Confidence
+		}
+		if n := len(seg.Words); n > 0 {
+			seg.Start, seg.End = seg.Words[0].Start, seg.Words[n-1].End
+			seg.Confidence = conf / float32(n)
+		}
+		if final {
+			seg.Stability = 1
+			s.log.Debug("assemblyai final", "utterance", uid, "revisions", revs[m.TurnOrder])
+			delete(ids, m.TurnOrder)
+			delete(revs, m.TurnOrder)
+			delete(stab, m.TurnOrder)
+		} else {
+			// The transcript holds the final words only, the words the
+			// tentative last one too.
+			if !m.EndOfTurn && len(words) > 0 {
+				seg.Text = strings.Join(words, " ")
+			}
+			seg.Stability = stab[m.TurnOrder].Score(seg.Text)
+		}
+		select {
+		case s.results <- seg:
+		case <-s.ctx.Done():
+			s.log.Debug("assemblyai stream cancelled")
+			return
+		}
+	}
+}
+
+// connError is a failure of the connection, transient when it dropped or
+// the service closed it for being restarted or overloaded, so that the
+// stream is worth reconnecting.
+type connError struct{ err error }
+
+func (e *connError) Error() string { return e.err.Error() }
+
+func (e *connError) Unwrap() error { return e.err }
+
+func (e *connError) Transient() bool {
+	switch websocket.CloseStatus(e.err) {
+	case -1, websocket.StatusGoingAway, websocket.StatusAbnormalClosure, websocket.StatusInternalError,
+		websocket.StatusServiceRestart, websocket.StatusTryAgainLater:
+		return !errors.Is(e.err, context.Canceled) && !errors.Is(e.err, context.DeadlineExceeded)
+	}
+	return false
+}
+
+func newUtteranceID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,270 @@
+// Package batch coalesces concurrent inference requests into batches.
+//
+// Local model backends serve many streams at once, but running every
+// request on its own leaves an accelerator idle between them. A Scheduler
+// queues requests and hands them to the backend in groups: a batch starts
+// as soon as a request arrives and closes when MaxBatch requests are
+// queued or MaxWait has passed, whichever comes first.
+//
+// Requests carry a priority. A closing batch takes the queued requests of
+// the highest priority first, in the order they came within one, so when
+// the backend is saturated, low priority work waits for the rest instead
+// of every request slowing down alike.
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned for requests submitted to, or still queued in, a
+// closed Scheduler.
+var ErrClosed = errors.New("batch: scheduler closed")
+
+// Config tunes a Scheduler. Zero values select the defaults.
+type Config struct {
+	// MaxBatch is the most requests run together. Defaults to 8.
+	MaxBatch int
+	// MaxWait is how long the first request of a batch waits for others to
+	// join it. Defaults to 10ms.
+	MaxWait time.Duration
+	// Workers is the number of batches run concurrently. Defaults to 1.
+	Workers int
+}
+
+func (c *Config) setDefaults() error {
+	if c.MaxBatch == 0 {
+		c.MaxBatch = 8
+	}
+	if c.MaxWait == 0 {
+		c.MaxWait = 10 * time.Millisecond
+	}
+	if c.Workers == 0 {
+		c.Workers = 1
+	}
+	switch {
+	case c.MaxBatch < 0:
+		return fmt.Errorf("batch: negative batch size %d", c.MaxBatch)
+	case c.MaxWait < 0:
+		return fmt.Errorf("batch: negative wait %v", c.MaxWait)
+	case c.Workers < 0:
+		return fmt.Errorf("batch: negative worker count %d", c.Workers)
+	}
+	return nil
+}
+
+// Func runs one batch. It returns one response per request, in order; an
+// error fails every request of the batch.
+type Func[Req, Resp any] func(reqs []Req) ([]Resp, error)
+
+// Scheduler batches requests for a Func.
+type Scheduler[Req, Resp any] struct {
+	cfg  Config
+	run  Func[Req, Resp]
+	wake chan struct{} // signalled as requests are queued
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+
+	mu     sync.Mutex
+	queue  []*call[Req, Resp] // by priority, highest first, then arrival
+	closed bool
+}
+
+type call[Req, Resp any] struct {
+	ctx      context.Context
+	priority int
+	req      Req
+	resp     Resp
+	err      error
+	ready    chan struct{}
+}
+
+// New starts a scheduler.
+func New[Req, Resp any](cfg Config, run Func[Req, Resp]) (*Scheduler[Req, Resp], error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	s := &Scheduler[Req, Resp]{
+		cfg:  cfg,
+		run:  run,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	s.wg.Add(cfg.Workers)
+	for range cfg.Workers {
+		go s.work()
+	}
+	return s, nil
+}
+
+// Do queues req at priority 0 and waits for its response; see
+// DoPriority.
+func (s *Scheduler[Req, Resp]) Do(ctx context.Context, req Req) (Resp, error) {
+	return s.DoPriority(ctx, 0, req)
+}
+
+// DoPriority queues req at priority, higher running first, and waits for
+// its response. Cancelling ctx abandons the request; if its batch has not
+// started yet, it is left out of it.
+func (s *Scheduler[Req, Resp]) DoPriority(ctx context.Context, priority int, req Req) (Resp, error) {
+	c := &call[Req, Resp]{ctx: ctx, priority: priority, req: req, ready: make(chan struct{})}
+	var zero Resp
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if !s.push(c) {
+		return zero, ErrClosed
+	}
+	select {
+	case <-c.ready:
+		return c.resp, c.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-s.done:
+		// Raced with Close: the request either made it into a last batch
+		// or is stranded in the queue.
+		s.wg.Wait()
+		select {
+		case <-c.ready:
+			return c.resp, c.err
+		default:
+			return zero, ErrClosed
+		}
+	}
+}
+
+// Close stops the workers once the batches being run finish. Queued
+// requests fail with ErrClosed.
+func (s *Scheduler[Req, Resp]) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.once.Do(func() { close(s.done) })
+	s.wg.Wait()
+	s.mu.Lock()
+	queued := s.queue
+	s.queue = nil
+	s.mu.Unlock()
+	for _, c := range queued {
+		c.err = ErrClosed
+		close(c.ready)
+	}
+}
+
+// push queues c behind the calls of its priority and above, and reports
+// whether the scheduler is still open.
+func (s *Scheduler[Req, Resp]) push(c *call[Req, Resp]) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	i, _ := slices.BinarySearchFunc(s.queue, c.priority, func(q *call[Req, Resp], p int) int {
+		if q.priority >= p {
+			return -1
+		}
+		return 1
+	})
+	s.queue = slices.Insert(s.queue, i, c)
+	s.signal()
+	return true
+}
+
+// take moves up to MaxBatch queued calls to batch, highest priority first.
+func (s *Scheduler[Req, Resp]) take(batch []*call[Req, Resp]) []*call[Req, Resp] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(len(s.queue), s.cfg.MaxBatch)
+	batch = append(batch, s.queue[:n]...)
+	s.queue = slices.Delete(s.queue, 0, n)
+	if len(s.queue) > 0 {
+		s.signal() // for the other workers
+	}
+	return batch
+}
+
+func (s *Scheduler[Req, Resp]) queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+func (s *Scheduler[Req, Resp]) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work collects and runs batches until the scheduler is closed.
+func (s *Scheduler[Req, Resp]) work() {
+	defer s.wg.Done()
+	batch := make([]*call[Req, Resp], 0, s.cfg.MaxBatch)
+	timer := time.NewTimer(s.cfg.MaxWait)
+	timer.Stop()
+	for {
+		for s.queued() == 0 {
+			select {
+			case <-s.wake:
+			case <-s.done:
+				return
+			}
+		}
+		timer.Reset(s.cfg.MaxWait)
+	fill:
+		for s.queued() < s.cfg.MaxBatch {
+			select {
+			case <-s.wake:
+			case <-timer.C:
+				break fill
+			case <-s.done:
+				timer.Stop()
+				return
+			}
+		}
+		timer.Stop()
+		batch = s.take(batch)
+		if len(batch) > 0 {
+			s.exec(batch)
+		}
+		clear(batch)
+		batch = batch[:0]
+	}
+}
+
+// exec runs the live requests of batch and delivers their responses.
+func (s *Scheduler[Req, Resp]) exec(batch []*call[Req, Resp]) {
+	live := batch[:0:0]
+	for _, c := range batch {
+		if c.ctx.Err() != nil {
+			c.err = c.ctx.Err()
+			close(c.ready)
+			continue
+		}
+		live = append(live, c)
+	}
+	if len(live) == 0 {
+		return
+	}
+	reqs := make([]Req, len(live))
+	for i, c := range live {
+		reqs[i] = c.req
+	}
+	resps, err := s.run(reqs)
+	if err == nil && len(resps) != len(reqs) {
+		err = fmt.Errorf("batch: %d responses for %d requests", len(resps), len(reqs))
+	}
+	for i, c := range live {
+		if err != nil {
+			c.err = err
+		} else {
+			c.resp = resps[i]
+		}
+		close(c.ready)
+	}
+}
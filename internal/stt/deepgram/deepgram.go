@@ -0,0 +1,452 @@
+// Package deepgram is a speech-to-text backend for the Deepgram live
+// streaming API (wss://api.deepgram.com/v1/listen).
+//
+// Deepgram finalizes a transcript piece by piece as the audio goes by
+// (is_final), and the end of an utterance when it hears a pause
+// (speech_final) or is asked to (Finalize). A stream folds the pieces of
+// an utterance into hypotheses of the whole of it, as the full-replace
+// protocol needs: partials carry the pieces finalized so far followed by
+// the interim one, and the final all of them.
+//
+// Deepgram closes connections that receive nothing for ten seconds, as
+// when the pipeline gates silence, so streams send KeepAlive messages
+// while no audio is written. Connections dropped or closed by the
+// service restarting fail their stream with a transient error, which
+// the stt resilience of Config.Resilience reconnects, with the audio not
+// finalized yet replayed. Phrases are sent as keyterms, or keywords on
+// models before Nova-3; see stt.PhraseBiaser.
+package deepgram
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// ProviderName is the name the backend registers under. Its options are
+// "endpoint", "api_key" (defaulting to $DEEPGRAM_API_KEY), "model",
+// "language", "endpointing" (the pause ending an utterance) and
+// "keepalive".
+const ProviderName = "deepgram"
+
+// DefaultEndpoint is the public live streaming endpoint.
+const DefaultEndpoint = "wss://api.deepgram.com/v1/listen"
+
+func init() {
+	stt.Register(ProviderName, func(cfg stt.Config) (stt.Provider, error) {
+		c := Config{
+			Endpoint: cfg.Option("endpoint", DefaultEndpoint),
+			APIKey:   cfg.Option("api_key", os.Getenv("DEEPGRAM_API_KEY")),
+			Model:    cfg.Option("model", "nova-3"),
+			Language: cfg.Option("language", "en"),
+			Logger:   cfg.Logger,
+		}
+		for name, d := range map[string]*time.Duration{"endpointing": &c.Endpointing, "keepalive": &c.KeepAlive} {
+			if v := cfg.Option(name, ""); v != "" {
+				var err error
+				if *d, err = time.ParseDuration(v); err != nil || *d <= 0 {
+					return nil, fmt.Errorf("bad %s %q", name, v)
+				}
+			}
+		}
+		return New(c)
+	})
+}
+
+// Config configures the client.
+type Config struct {
+	Endpoint string
+	APIKey   string
+	// Model is the Deepgram model, e.g. "nova-3".
+	Model string
+	// Language is the code of the spoken language, or "multi" for the
+	// multilingual models.
+	Language string
+	// Endpointing is the pause ending an utterance, unless the pipeline
+	// endpoints streams itself. Defaults to 300ms.
+	Endpointing time.Duration
+	// KeepAlive is how long a stream goes without audio before sending a
+	// KeepAlive message. Defaults to 4s.
+	KeepAlive time.Duration
+	// Logger receives the client's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Provider opens streams against Deepgram.
+type Provider struct {
+	cfg Config
+}
+
+var (
+	_ stt.Provider        = (*Provider)(nil)
+	_ stt.PhraseBiaser    = (*Provider)(nil)
+	_ stt.StreamOverrider = (*Provider)(nil)
+)
+
+// New validates cfg.
+func New(cfg Config) (*Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("api_key is required")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+	if cfg.Model == "" {
+		cfg.Model = "nova-3"
+	}
+	if cfg.Endpointing == 0 {
+		cfg.Endpointing = 300 * time.Millisecond
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = 4 * time.Second
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	return &Provider{cfg: cfg}, nil
+}
+
+// BiasesPhrases implements stt.PhraseBiaser.
+func (p *Provider) BiasesPhrases() bool { return true }
+
+// Overrides implements stt.StreamOverrider: streams may ask for any
+// language and model.
+func (p *Provider) Overrides() (language, model bool) { return true, true }
+
+// NewStream implements stt.Provider.
+func (p *Provider) NewStream(ctx context.Context, cfg stt.StreamConfig) (stt.StreamingRecognizer, error) {
+	if cfg.SampleRate <= 0 {
+		return nil, errors.New("deepgram: sample rate required")
+	}
+	model, lang := cmp.Or(cfg.Model, p.cfg.Model), cmp.Or(cfg.Language, p.cfg.Language)
+	q := url.Values{
+		"model":           {model},
+		"encoding":        {"linear16"},
+		"sample_rate":     {strconv.Itoa(cfg.SampleRate)},
+		"channels":        {"1"},
+		"interim_results": {"true"},
+		"punctuate":       {"true"},
+		"smart_format":    {"true"},
+		"endpointing":     {strconv.FormatInt(cmp.Or(cfg.Endpointing, p.cfg.Endpointing).Milliseconds(), 10)},
+	}
+	if lang != "" {
+		q.Set("language", lang)
+	}
+	for _, ph := range cfg.Phrases {
+		if strings.HasPrefix(model, "nova-3") {
+			q.Add("keyterm", ph.Text)
+			continue
+		}
+		boost := ph.Boost
+		if boost == 0 {
+			boost = 1
+		}
+		q.Add("keywords", ph.Text+":"+strconv.FormatFloat(float64(boost), 'g', -1, 32))
+	}
+	conn, resp, err := websocket.Dial(ctx, p.cfg.Endpoint+"?"+q.Encode(), &websocket.DialOptions{
+		HTTPHeader: map[string][]string{"Authorization": {"Token " + p.cfg.APIKey}},
+	})
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("deepgram: %w", &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: resp.Header.Get("dg-error")})
+		}
+		return nil, fmt.Errorf("deepgram: dial: %w", err)
+	}
+	conn.SetReadLimit(1 << 20)
+	log := cfg.Logger
+	if log == nil {
+		log = p.cfg.Logger
+	}
+	uid := cfg.UtteranceID
+	if uid == "" {
+		uid = newUtteranceID()
+	}
+	log.Debug("deepgram stream opened", "utterance", uid, "model", p.cfg.Model, "sample_rate", cfg.SampleRate,
+		"request", resp.Header.Get("dg-request-id"))
+	s := &stream{
+		ctx:     ctx,
+		conn:    conn,
+		log:     log,
+		utt:     uid,
+		last:    time.Now(),
+		results: make(chan stt.Segment, 16),
+		done:    make(chan struct{}),
+	}
+	go s.recv()
+	go s.keepAlive(p.cfg.KeepAlive)
+	return s, nil
+}
+
+// stream implements stt.StreamingRecognizer on one connection.
+type stream struct {
+	ctx  context.Context
+	conn *websocket.Conn
+	log  logging.Logger
+
+	mu     sync.Mutex // guards sends and the fields below
+	closed bool
+	last   time.Time // of the last message sent
+
+	utt     string // read by recv only
+	results chan stt.Segment
+	err     error
+	done    chan struct{} // closed once recv returns
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, stt.ErrClosed
+	}
+	if err := s.send(websocket.MessageBinary, p); err != nil {
+		return 0, fmt.Errorf("deepgram: send audio: %w", err)
+	}
+	return len(p), nil
+}
+
+// Flush asks Deepgram to finalize the audio it has, which ends the
+// utterance.
+func (s *stream) Flush() error {
+	return s.control("Finalize", false)
+}
+
+func (s *stream) Close() error {
+	return s.control("CloseStream", true)
+}
+
+func (s *stream) Results() <-chan stt.Segment { return s.results }
+
+func (s *stream) Err() error { return s.err }
+
+// control sends the control message of type t; closing marks the stream
+// closed.
+func (s *stream) control(t string, closing bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		if closing {
+			return nil
+		}
+		return stt.ErrClosed
+	}
+	s.closed = closing
+	if err := s.send(websocket.MessageText, []byte(`{"type":"`+t+`"}`)); err != nil {
+		return fmt.Errorf("deepgram: send %s: %w", t, err)
+	}
+	return nil
+}
+
+// send must be called with s.mu held.
+func (s *stream) send(typ websocket.MessageType, p []byte) error {
+	if err := s.conn.Write(s.ctx, typ, p); err != nil {
+		return &connError{err}
+	}
+	s.last = time.Now()
+	return nil
+}
+
+// keepAlive sends a KeepAlive message whenever the stream has sent
+// nothing for d, until it ends.
+func (s *stream) keepAlive(d time.Duration) {
+	t := time.NewTicker(d / 4)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+		}
+		s.mu.Lock()
+		if !s.closed && time.Since(s.last) >= d {
+			if err := s.send(websocket.MessageText, []byte(`{"type":"KeepAlive"}`)); err != nil {
+				s.log.Debug("deepgram keepalive failed", "error", err)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// message is a message of the API; only results are used.
+type message struct {
+	Type         string  `json:"type"`
+	Start        float64 `json:"start"`
+	Duration     float64 `json:"duration"`
+	IsFinal      bool    `json:"is_final"`
+	SpeechFinal  bool    `json:"speech_final"`
+	FromFinalize bool    `json:"from_finalize"`
+	Channel      struct {
+		Alternatives []struct {
+			Transcript string  `json:"transcript"`
+			Confidence float32 `json:"confidence"`
+			Words      []struct {
+				Word           string  `json:"word"`
+				PunctuatedWord string  `json:"punctuated_word"`
+				Start          float64 `json:"start"`
+				End            float64 `json:"end"`
+				Confidence     float32 `json:"confidence"`
+			} `json:"words"`
+		} `json:"alternatives"`
+	} `json:"channel"`
+}
+
+// utterance is the state of the utterance being recognized.
+type utterance struct {
+	pieces     []string // finalized by Deepgram
+	words      []stt.Word
+	confidence float32 // sum over pieces
+	start, end time.Duration
+	revision   int
+	stab       stt.Stabilizer
+}
+
+// recv pumps results into s.results until the connection ends or the
+// stream's context is done.
+func (s *stream) recv() {
+	defer close(s.results)
+	defer close(s.done)
+	defer s.conn.CloseNow()
+
+	var u utterance
+	for {
+		typ, b, err := s.conn.Read(s.ctx)
+		if err != nil {
+			switch {
+			case s.ctx.Err() != nil:
+				s.log.Debug("deepgram stream cancelled")
+			case websocket.CloseStatus(err) == websocket.StatusNormalClosure:
+				// Deepgram finalizes what it has before closing.
+				if len(u.pieces) > 0 {
+					s.emit(&u, true, "", 0, nil)
+				}
+				s.log.Debug("deepgram stream ended")
+			default:
+				s.err = fmt.Errorf("deepgram: recv: %w", &connError{err})
+				s.log.Error("deepgram stream failed", "error", err)
+			}
+			return
+		}
+		if typ != websocket.MessageText {
+			continue
+		}
+		var m message
+		if err := json.Unmarshal(b, &m); err != nil {
+			s.err = fmt.Errorf("deepgram: bad message: %w", err)
+			return
+		}
+		if m.Type != "Results" || len(m.Channel.Alternatives) == 0 {
+			continue
+		}
+		alt := m.Channel.Alternatives[0]
+		var words []stt.Word
+		for _, w := range alt.Words {
+			text := w.PunctuatedWord
+			if text == "" {
+				text = w.Word
+			}
+			words = append(words, stt.Word{Text: text, Start: seconds(w.Start), End: seconds(w.End), Confidence: w.Confidence})
+		}
+		if alt.Transcript != "" {
+			if len(u.pieces) == 0 && u.revision == 0 {
+				u.start = seconds(m.Start)
+			}
+			u.end = seconds(m.Start + m.Duration)
+		}
+		switch {
+		case m.IsFinal:
+			if alt.Transcript != "" {
+				u.pieces = append(u.pieces, alt.Transcript)
+				u.words = append(u.words, words...)
+				u.confidence += alt.Confidence
+			}
+			if (m.SpeechFinal || m.FromFinalize) && len(u.pieces) > 0 {
+				if !s.emit(&u, true, "", 0, nil) {
+					return
+				}
+			} else if alt.Transcript != "" && !s.emit(&u, false, "", alt.Confidence, nil) {
+				return
+			}
+		case alt.Transcript != "":
+			if !s.emit(&u, false, alt.Transcript, alt.Confidence, words) {
+				return
+			}
+		}
+	}
+}
+
+// emit delivers a hypothesis of u: the pieces finalized so far followed by
+// interim, or, if final, the pieces alone, after which u starts over on a
+// new utterance. It returns false if nobody reads any more.
+func (s *stream) emit(u *utterance, final bool, interim string, confidence float32, words []stt.Word) bool {
+	text := strings.Join(append(u.pieces[:len(u.pieces):len(u.pieces)], interim), " ")
+	text = strings.TrimSpace(text)
+	u.revision++
+	seg := stt.Segment{
+		UtteranceID: s.utt,
+		Revision:    u.revision,
+		Text:        text,
+		Final:       final,
+		Start:       u.start,
+		End:         u.end,
+		Confidence:  confidence,
+		Words:       append(u.words[:len(u.words):len(u.words)], words...),
+	}
+	if final {
+		seg.Stability = 1
+		seg.Confidence = u.confidence / float32(len(u.pieces))
+		s.log.Debug("deepgram final", "utterance", s.utt, "revisions", u.revision)
+		*u = utterance{}
+		s.utt = newUtteranceID()
+	} else {
+		seg.Stability = u.stab.Score(text)
+	}
+	select {
+	case s.results <- seg:
+		return true
+	case <-s.ctx.Done():
+		s.log.Debug("deepgram stream cancelled")
+		return false
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// connError is a failure of the connection, transient when it dropped or
+// the service closed it for being restarted or overloaded, so that the
+// stream is worth reconnecting.
+type connError struct{ err error }
+
+func (e *connError) Error() string { return e.err.Error() }
+
+func (e *connError) Unwrap() error { return e.err }
+
+func (e *connError) Transient() bool {
+	switch websocket.CloseStatus(e.err) {
+	case -1, websocket.StatusGoingAway, websocket.StatusAbnormalClosure, websocket.StatusInternalError,
+		websocket.StatusServiceRestart, websocket.StatusTryAgainLater:
+		return !errors.Is(e.err, context.Canceled) && !errors.Is(e.err, context.DeadlineExceeded)
+	}
+	return false
+}
+
+func newUtteranceID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
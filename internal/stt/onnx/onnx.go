@@ -0,0 +1,438 @@
+// Package onnx is a local speech-to-text backend running CTC models, such
+// as wav2vec2, HuBERT or Citrinet exports, through ONNX Runtime; see
+// package internal/onnx for the runtime and the tensor mappings.
+//
+// The model takes the audio of a whole utterance, 16kHz by default, and
+// outputs logits shaped [1, frames, tokens] or [frames, tokens], decoded
+// greedily: the best token of every frame, repeats collapsed and blanks
+// dropped. Tokens come from a vocabulary file, one token per line in id
+// order, or a JSON object of tokens to ids as Hugging Face ships with
+// wav2vec2 models. The word delimiter token, "|" by default, and the
+// SentencePiece "▁" prefix separate words.
+//
+// Like whisper, the recognizer decodes utterances once flushed, so it
+// emits final segments only, with word timings from the frames.
+package onnx
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	onnxrt "github.com/jmarc101/voxa/internal/onnx"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// ProviderName is the name the backend registers under. Its options are
+// those of onnx.ParseOptions, "vocab", "blank", "word_delimiter" and
+// "normalize".
+const ProviderName = "onnx"
+
+func init() {
+	stt.Register(ProviderName, func(cfg stt.Config) (stt.Provider, error) {
+		mc, err := onnxrt.ParseOptions(cfg.Options)
+		if err != nil {
+			return nil, err
+		}
+		c := Config{
+			Model:         mc,
+			Vocab:         cfg.Option("vocab", ""),
+			Blank:         -1,
+			WordDelimiter: cfg.Option("word_delimiter", "|"),
+			Logger:        cfg.Logger,
+		}
+		if v := cfg.Option("blank", ""); v != "" {
+			if c.Blank, err = strconv.Atoi(v); err != nil || c.Blank < 0 {
+				return nil, fmt.Errorf("bad blank %q", v)
+			}
+		}
+		if v := cfg.Option("normalize", ""); v != "" {
+			if c.Normalize, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("bad normalize %q", v)
+			}
+		}
+		return New(c)
+	})
+}
+
+// Config configures the recognizer.
+type Config struct {
+	Model onnxrt.Config
+	// Vocab is the vocabulary file.
+	Vocab string
+	// Blank is the id of the CTC blank. Negative defaults to the id of
+	// "<pad>" or "<blank>" if the vocabulary has one, else 0.
+	Blank int
+	// WordDelimiter is the token between words.
+	WordDelimiter string
+	// Normalize scales each utterance to zero mean and unit variance, as
+	// wav2vec2 models expect.
+	Normalize bool
+	// Logger receives the engine's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Recognizer runs a loaded model; its streams decode concurrently.
+type Recognizer struct {
+	cfg    Config
+	model  *onnxrt.Model
+	tokens []string
+	format audio.Format
+}
+
+var (
+	_ stt.Provider       = (*Recognizer)(nil)
+	_ stt.FormatRequirer = (*Recognizer)(nil)
+)
+
+// New loads the model and its vocabulary.
+func New(cfg Config) (*Recognizer, error) {
+	if cfg.Vocab == "" {
+		return nil, errors.New("vocab is required")
+	}
+	tokens, err := loadVocab(cfg.Vocab)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Blank < 0 {
+		cfg.Blank = 0
+		for i, t := range tokens {
+			if t == "<pad>" || t == "<blank>" {
+				cfg.Blank = i
+				break
+			}
+		}
+	}
+	if cfg.Blank >= len(tokens) {
+		return nil, fmt.Errorf("blank %d outside the vocabulary of %d tokens", cfg.Blank, len(tokens))
+	}
+	m, err := onnxrt.Load(cfg.Model, 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	r := &Recognizer{
+		cfg:    cfg,
+		model:  m,
+		tokens: tokens,
+		format: audio.Format{SampleRate: m.Config().SampleRate, Channels: 1},
+	}
+	cfg.Logger.Info("onnx model loaded", "model", m.Config().Model, "tokens", len(tokens))
+	return r, nil
+}
+
+// loadVocab reads tokens by id.
+func loadVocab(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, ".json") {
+		var ids map[string]int
+		if err := json.Unmarshal(b, &ids); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		tokens := make([]string, len(ids))
+		for t, id := range ids {
+			if id < 0 || id >= len(ids) {
+				return nil, fmt.Errorf("%s: token %q has id %d out of range", path, t, id)
+			}
+			tokens[id] = t
+		}
+		return tokens, nil
+	}
+	tokens := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	for i, t := range tokens {
+		tokens[i] = strings.TrimRight(t, "\r")
+	}
+	return tokens, nil
+}
+
+// RequiredFormat implements stt.FormatRequirer.
+func (r *Recognizer) RequiredFormat() audio.Format { return r.format }
+
+// Close unloads the model.
+func (r *Recognizer) Close() error { return r.model.Close() }
+
+// NewStream implements stt.Provider.
+func (r *Recognizer) NewStream(ctx context.Context, cfg stt.StreamConfig) (stt.StreamingRecognizer, error) {
+	if cfg.SampleRate != r.format.SampleRate {
+		return nil, fmt.Errorf("onnx: model takes %d Hz audio, got %d", r.format.SampleRate, cfg.SampleRate)
+	}
+	id := cfg.UtteranceID
+	if id == "" {
+		id = newUtteranceID()
+	}
+	s := &stream{
+		ctx:     ctx,
+		r:       r,
+		log:     cmp.Or(cfg.Logger, r.cfg.Logger),
+		cur:     &utterance{id: id},
+		wake:    make(chan struct{}, 1),
+		results: make(chan stt.Segment, 16),
+	}
+	go s.run()
+	return s, nil
+}
+
+// utterance is the audio of one utterance.
+type utterance struct {
+	id    string
+	start time.Duration
+	pcm   []float32
+}
+
+type stream struct {
+	ctx     context.Context
+	r       *Recognizer
+	log     logging.Logger
+	wake    chan struct{}
+	results chan stt.Segment
+	err     error
+
+	mu      sync.Mutex // guards the fields below
+	cur     *utterance
+	queue   []*utterance // flushed, awaiting their decode
+	written int          // samples
+	closed  bool
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, stt.ErrClosed
+	}
+	for i := 0; i+1 < len(p); i += 2 {
+		v := int16(uint16(p[i]) | uint16(p[i+1])<<8)
+		s.cur.pcm = append(s.cur.pcm, float32(v)/32768)
+	}
+	s.written += len(p) / 2
+	return len(p), nil
+}
+
+// cut queues the current utterance and starts one named next.
+func (s *stream) cut(next string) {
+	if len(s.cur.pcm) > 0 {
+		s.queue = append(s.queue, s.cur)
+	}
+	s.cur = &utterance{id: next, start: s.r.format.Duration(s.written)}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *stream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return stt.ErrClosed
+	}
+	s.cut(newUtteranceID())
+	return nil
+}
+
+func (s *stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.cut("")
+	s.closed = true
+	return nil
+}
+
+func (s *stream) Results() <-chan stt.Segment { return s.results }
+
+func (s *stream) Err() error { return s.err }
+
+// run decodes the flushed utterances until the stream is closed and
+// drained, or ctx is done.
+func (s *stream) run() {
+	defer close(s.results)
+	for {
+		select {
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return
+		}
+		s.mu.Lock()
+		queue, closed := s.queue, s.closed
+		s.queue = nil
+		s.mu.Unlock()
+		for _, u := range queue {
+			seg, err := s.decode(u)
+			if err != nil {
+				if s.ctx.Err() == nil {
+					s.err = fmt.Errorf("onnx: decode: %w", err)
+					s.log.Error("onnx decode failed", "utterance", u.id, "error", err)
+				}
+				return
+			}
+			if seg.Text == "" {
+				continue
+			}
+			select {
+			case s.results <- seg:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// decode transcribes u.
+func (s *stream) decode(u *utterance) (stt.Segment, error) {
+	pcm := u.pcm
+	if s.r.cfg.Normalize {
+		pcm = normalize(pcm)
+	}
+	start := time.Now()
+	out, err := s.r.model.NewRunner().Run(pcm)
+	if err != nil {
+		return stt.Segment{}, err
+	}
+	vocab := len(s.r.tokens)
+	if len(out.Shape) < 2 || int(out.Shape[len(out.Shape)-1]) != vocab {
+		return stt.Segment{}, fmt.Errorf("logits shaped %v, want [..., frames, %d]", out.Shape, vocab)
+	}
+	frames := len(out.Data) / vocab
+	heard := s.r.format.Duration(len(u.pcm))
+	seg := s.r.greedy(out.Data, frames, heard)
+	seg.UtteranceID = u.id
+	seg.Revision = 1
+	seg.Final = true
+	seg.Stability = 1
+	seg.Start += u.start
+	seg.End += u.start
+	for i := range seg.Words {
+		seg.Words[i].Start += u.start
+		seg.Words[i].End += u.start
+	}
+	s.log.Debug("onnx decoded", "utterance", u.id, "audio", heard, "took", time.Since(start))
+	return seg, nil
+}
+
+// greedy decodes logits of frames spanning heard, with times from the
+// start of the utterance.
+func (r *Recognizer) greedy(logits []float32, frames int, heard time.Duration) stt.Segment {
+	vocab := len(r.tokens)
+	stride := heard / time.Duration(max(frames, 1))
+	var (
+		seg                stt.Segment
+		words              []stt.Word
+		word               strings.Builder
+		wordStart, wordEnd time.Duration
+		conf               float64
+		n                  int
+		prev               = -1
+	)
+	endWord := func() {
+		if word.Len() > 0 {
+			words = append(words, stt.Word{Text: word.String(), Start: wordStart, End: wordEnd})
+			word.Reset()
+		}
+	}
+	for f := range frames {
+		row := logits[f*vocab : (f+1)*vocab]
+		best := 0
+		for i, v := range row {
+			if v > row[best] {
+				best = i
+			}
+		}
+		if best == prev || best == r.cfg.Blank {
+			prev = best
+			continue
+		}
+		prev = best
+		conf += softmax(row, best)
+		n++
+		tok := r.tokens[best]
+		at := time.Duration(f) * stride
+		if tok == r.cfg.WordDelimiter {
+			endWord()
+			continue
+		}
+		if rest, ok := strings.CutPrefix(tok, "▁"); ok {
+			endWord()
+			tok = rest
+		}
+		if strings.HasPrefix(tok, "<") && strings.HasSuffix(tok, ">") {
+			continue // special tokens such as <unk> or <s>
+		}
+		if word.Len() == 0 {
+			wordStart = at
+		}
+		word.WriteString(tok)
+		wordEnd = at + stride
+	}
+	endWord()
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.Text
+	}
+	seg.Text = strings.Join(texts, " ")
+	seg.Words = words
+	if len(words) > 0 {
+		seg.Start, seg.End = words[0].Start, words[len(words)-1].End
+	}
+	if n > 0 {
+		seg.Confidence = float32(conf / float64(n))
+	}
+	return seg
+}
+
+// softmax returns the probability of token i of logits row.
+func softmax(row []float32, i int) float64 {
+	peak := float64(row[i])
+	var sum float64
+	for _, v := range row {
+		sum += math.Exp(float64(v) - peak)
+	}
+	return 1 / sum
+}
+
+// normalize returns pcm scaled to zero mean and unit variance.
+func normalize(pcm []float32) []float32 {
+	if len(pcm) == 0 {
+		return pcm
+	}
+	var mean, sq float64
+	for _, v := range pcm {
+		mean += float64(v)
+	}
+	mean /= float64(len(pcm))
+	for _, v := range pcm {
+		sq += (float64(v) - mean) * (float64(v) - mean)
+	}
+	std := math.Sqrt(sq/float64(len(pcm))) + 1e-7
+	out := make([]float32, len(pcm))
+	for i, v := range pcm {
+		out[i] = float32((float64(v) - mean) / std)
+	}
+	return out
+}
+
+func newUtteranceID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
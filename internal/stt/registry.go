@@ -0,0 +1,129 @@
+package stt
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/ratelimit"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// Config selects a registered provider and passes it backend options.
+type Config struct {
+	// Provider is the name the backend was registered under.
+	Provider string
+	// Options are backend-specific settings, e.g. "addr" or "model".
+	Options map[string]string
+	// Logger receives the backend's log records. Nil discards them.
+	Logger logging.Logger
+	// Resilience, if set, retries the backend's transient failures,
+	// reconnecting streams that fail mid-utterance, and opens a circuit
+	// breaker when they persist.
+	Resilience *resilience.Config
+	// LatencySLO, if set, is how late a segment may arrive after the end of
+	// its audio was written. A stream whose backend is slower fails over to
+	// the next provider of the chain.
+	LatencySLO time.Duration
+	// ReplayWindow, if set, bounds the audio a stream keeps to replay into
+	// the stream replacing its backend's when it fails or falls behind:
+	// the audio since the last final, up to this, which defaults to
+	// DefaultReplayWindow. Utterances running longer lose their start
+	// when the backend fails. It implies Resilience, with the defaults
+	// unless set; fallbacks use the window of the chain's first provider.
+	ReplayWindow time.Duration
+	// RateLimit, if set, holds the streams opened on the backend, and its
+	// language identifications, to the limits of its account, shared with
+	// every provider limited under the same key. Streams wait for the
+	// limits rather than be throttled by the backend.
+	RateLimit *ratelimit.Config
+	// Fallbacks, if set, are the providers a stream fails over to, in
+	// order, when the one it is on fails beyond what Resilience recovers
+	// or breaches its LatencySLO; new streams skip providers whose breaker
+	// is open. A stream stays on the provider it failed over to. Fallbacks
+	// imply Resilience, with the defaults unless set, use their own
+	// Resilience, LatencySLO and RateLimit, and inherit Logger when they
+	// have none.
+	// Their own Fallbacks are ignored.
+	Fallbacks []Config
+}
+
+// Option returns the named option or def when unset.
+func (c Config) Option(name, def string) string {
+	if v, ok := c.Options[name]; ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// Factory builds a provider from its configuration.
+type Factory func(cfg Config) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available under name. It is meant to be called
+// from the backend's init function and panics if name is already taken or
+// factory is nil.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("stt: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("stt: Register called twice for provider " + name)
+	}
+	registry[name] = factory
+}
+
+// New instantiates the provider selected by cfg.Provider, wrapped to
+// retry and fail over as cfg.Resilience, cfg.LatencySLO, cfg.ReplayWindow
+// and cfg.Fallbacks ask, and to keep to cfg.RateLimit.
+func New(cfg Config) (Provider, error) {
+	p, err := build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Resilience == nil && cfg.LatencySLO == 0 && cfg.ReplayWindow == 0 && len(cfg.Fallbacks) == 0 {
+		return p, nil
+	}
+	return newResilient(cfg, p)
+}
+
+// build instantiates the provider selected by cfg.Provider alone, within
+// its rate limit and metering its streams; see cost.Meter.
+func build(cfg Config) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Provider]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("stt: unknown provider %q (registered: %v)", cfg.Provider, Providers())
+	}
+	p, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("stt: %s: %w", cfg.Provider, err)
+	}
+	w, err := wrap(cfg, p)
+	if err != nil {
+		closeProvider(p)
+		return nil, fmt.Errorf("stt: %s: %w", cfg.Provider, err)
+	}
+	return w, nil
+}
+
+// Providers returns the sorted names of the registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,629 @@
+package stt
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// DefaultReplayWindow bounds the audio a resilient stream keeps to replay
+// into a new backend stream, unless Config.ReplayWindow says otherwise:
+// the current utterance, unless it runs longer.
+const DefaultReplayWindow = 30 * time.Second
+
+// replayLead is the audio before the last final replayed too, so the new
+// backend stream hears the word its audio resumes on from its start; the
+// results repeating the final are dropped.
+const replayLead = 500 * time.Millisecond
+
+// resilient is a Provider retrying the failures of a chain of others and
+// failing over along it, per Config.Resilience, Config.LatencySLO and
+// Config.Fallbacks.
+type resilient struct {
+	chain  []link
+	log    logging.Logger
+	slo    bool          // some link has a latency SLO
+	window time.Duration // of the replay
+}
+
+// link is one provider of a chain, guarded by its own policy.
+type link struct {
+	name   string
+	p      Provider
+	policy *resilience.Policy
+	slo    time.Duration
+}
+
+func newResilient(cfg Config, primary Provider) (Provider, error) {
+	if cfg.ReplayWindow < 0 {
+		return nil, fmt.Errorf("stt: negative replay window %v", cfg.ReplayWindow)
+	}
+	r := &resilient{log: logging.OrNop(cfg.Logger), window: cmp.Or(cfg.ReplayWindow, DefaultReplayWindow)}
+	for i, c := range append([]Config{cfg}, cfg.Fallbacks...) {
+		p := primary
+		if i > 0 {
+			if c.Logger == nil {
+				c.Logger = logging.With(cfg.Logger, "fallback", c.Provider)
+			}
+			var err error
+			if p, err = build(c); err != nil {
+				_ = r.Close()
+				return nil, err
+			}
+		}
+		var rc resilience.Config
+		if c.Resilience != nil {
+			rc = *c.Resilience
+		}
+		policy, err := resilience.New(rc, logging.With(c.Logger, "provider", c.Provider))
+		if err != nil {
+			closeProvider(p)
+			_ = r.Close()
+			return nil, fmt.Errorf("stt: %s: %w", c.Provider, err)
+		}
+		r.chain = append(r.chain, link{name: c.Provider, p: p, policy: policy, slo: c.LatencySLO})
+		r.slo = r.slo || c.LatencySLO > 0
+	}
+	rate := 0
+	for _, l := range r.chain {
+		got := requiredRate(l.p)
+		if got > 0 && rate > 0 && got != rate {
+			_ = r.Close()
+			return nil, fmt.Errorf("stt: fallback %s takes %d Hz audio, the providers before it %d Hz", l.name, got, rate)
+		}
+		rate = max(rate, got)
+	}
+	return r, nil
+}
+
+func requiredRate(p Provider) int {
+	if r, ok := p.(FormatRequirer); ok {
+		return r.RequiredFormat().SampleRate
+	}
+	return 0
+}
+
+func closeProvider(p Provider) {
+	if c, ok := p.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// RequiredFormat implements FormatRequirer with the format of whichever
+// providers of the chain require one.
+func (r *resilient) RequiredFormat() audio.Format {
+	f := audio.Format{Channels: 1}
+	for _, l := range r.chain {
+		f.SampleRate = max(f.SampleRate, requiredRate(l.p))
+	}
+	return f
+}
+
+// BiasesPhrases implements PhraseBiaser: a stream may fail over to any
+// provider of the chain, so every one must bias.
+func (r *resilient) BiasesPhrases() bool {
+	for _, l := range r.chain {
+		if b, ok := l.p.(PhraseBiaser); !ok || !b.BiasesPhrases() {
+			return false
+		}
+	}
+	return true
+}
+
+// Overrides implements StreamOverrider. Languages must be overridden by
+// every provider of the chain; models name those of the primary, which
+// alone is asked for one, fallbacks recognizing with their own.
+func (r *resilient) Overrides() (language, model bool) {
+	language = true
+	for i, l := range r.chain {
+		o, ok := l.p.(StreamOverrider)
+		if !ok {
+			return false, model
+		}
+		lang, m := o.Overrides()
+		language = language && lang
+		if i == 0 {
+			model = m
+		}
+	}
+	return language, model
+}
+
+// Close closes the providers that need it.
+func (r *resilient) Close() error {
+	var errs []error
+	for _, l := range r.chain {
+		if c, ok := l.p.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewStream implements Provider. The stream survives failures of its
+// backend: it reconnects, or fails over to the next provider of the chain,
+// and replays the audio written since the last final, from a little before
+// it. The new backend stream carries on with the utterance the failed one
+// was hypothesizing, under its ID, and with what it repeats of the audio
+// already finalized dropped from its results.
+func (r *resilient) NewStream(ctx context.Context, cfg StreamConfig) (StreamingRecognizer, error) {
+	log := logging.OrNop(cfg.Logger)
+	if cfg.Logger == nil {
+		log = r.log
+	}
+	rec, cur, err := r.open(ctx, cfg, log, 0)
+	if err != nil {
+		return nil, err
+	}
+	s := &resilientStream{
+		r:       r,
+		ctx:     ctx,
+		cfg:     cfg,
+		log:     log,
+		results: make(chan Segment),
+		rec:     rec,
+		cur:     cur,
+		revs:    make(map[string]int),
+	}
+	go s.forward()
+	return s, nil
+}
+
+// open starts a backend stream on the first provider of the chain, from
+// from on, that accepts one through its policy.
+func (r *resilient) open(ctx context.Context, cfg StreamConfig, log logging.Logger, from int) (StreamingRecognizer, int, error) {
+	var err error
+	for i := from; i < len(r.chain); i++ {
+		l := &r.chain[i]
+		var rec StreamingRecognizer
+		c := cfg
+		if i > 0 {
+			c.Model = ""
+		}
+		err = l.policy.Do(ctx, func() error {
+			var err error
+			rec, err = l.p.NewStream(ctx, c)
+			return err
+		})
+		if err == nil {
+			return rec, i, nil
+		}
+		if ctx.Err() != nil {
+			return nil, i, err
+		}
+		if i+1 < len(r.chain) {
+			log.Warn("recognizer unavailable, failing over", "provider", l.name, "next", r.chain[i+1].name, "error", err)
+		}
+	}
+	return nil, len(r.chain) - 1, err
+}
+
+// resilientStream forwards to a backend stream, replacing it when it fails
+// or is too slow. Write, Flush and Close do not report backend failures:
+// the audio is kept for replay, and Err reports the failure if the stream
+// cannot be recovered.
+type resilientStream struct {
+	r       *resilient
+	ctx     context.Context
+	cfg     StreamConfig
+	log     logging.Logger
+	results chan Segment
+
+	mu      sync.Mutex
+	rec     StreamingRecognizer // current backend stream
+	cur     int                 // index of rec's provider in the chain
+	broken  bool                // rec failed; it is not written to any more
+	werr    error               // why rec's writes failed
+	base    time.Duration       // stream time rec started at
+	replay  []byte              // audio since the last final
+	start   int                 // bytes written before replay[0]
+	flushes []int               // byte offsets of flushes not finalized yet
+	written []stamp             // when the replayed audio reached rec, with an SLO
+	retries int                 // reconnections since the last final
+	final   time.Duration       // End of the last final
+	cut     time.Duration       // rec's results before it repeat finals
+	open    string              // the utterance of the last partial, if not final
+	revs    map[string]int      // last revision of the utterances not final
+	lang    string
+	closed  bool
+	err     error
+}
+
+// stamp records when the audio up to a byte offset was written.
+type stamp struct {
+	end int
+	at  time.Time
+}
+
+func (s *resilientStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrClosed
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.keep(p)
+	if !s.broken {
+		if _, err := s.rec.Write(p); err != nil {
+			s.fail(err)
+		}
+	}
+	return len(p), nil
+}
+
+func (s *resilientStream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.flushes = append(s.flushes, s.start+len(s.replay))
+	if !s.broken {
+		if err := s.rec.Flush(); err != nil {
+			s.fail(err)
+		}
+	}
+	return nil
+}
+
+func (s *resilientStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if !s.broken {
+		// Otherwise the stream replacing rec is closed once it has
+		// caught up.
+		if err := s.rec.Close(); err != nil {
+			s.fail(err)
+		}
+	}
+	return nil
+}
+
+func (s *resilientStream) Results() <-chan Segment { return s.results }
+
+func (s *resilientStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// SetLanguage implements LanguageSetter for backends that support it, now
+// and after reconnecting.
+func (s *resilientStream) SetLanguage(lang string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lang = lang
+	if ls, ok := s.rec.(LanguageSetter); ok && !s.broken {
+		return ls.SetLanguage(lang)
+	}
+	return nil
+}
+
+// fail stops writing to rec, whose results are expected to end with the
+// failure. s.mu must be held.
+func (s *resilientStream) fail(err error) {
+	s.log.Debug("recognizer stream write failed", "error", err)
+	s.broken = true
+	if s.werr == nil {
+		s.werr = err
+	}
+}
+
+// keep adds p to the replay buffer. s.mu must be held.
+func (s *resilientStream) keep(p []byte) {
+	s.replay = append(s.replay, p...)
+	if s.r.slo {
+		s.written = append(s.written, stamp{end: s.start + len(s.replay), at: time.Now()})
+	}
+	if over := len(s.replay) - s.bytes(s.r.window); over > 0 {
+		s.drop(over)
+	}
+}
+
+// drop removes the first n bytes of the replay buffer. s.mu must be held.
+func (s *resilientStream) drop(n int) {
+	if n <= 0 {
+		return
+	}
+	n = min(n, len(s.replay))
+	s.replay = s.replay[n:]
+	s.start += n
+	for len(s.flushes) > 0 && s.flushes[0] < s.start {
+		s.flushes = s.flushes[1:]
+	}
+	for len(s.written) > 0 && s.written[0].end <= s.start {
+		s.written = s.written[1:]
+	}
+}
+
+// finalized forgets the audio up to replayLead before end, a final's End
+// on the stream's clock, along with the flush that produced it. s.mu must
+// be held.
+func (s *resilientStream) finalized(end time.Duration) {
+	if len(s.flushes) > 0 {
+		s.flushes = s.flushes[1:]
+	}
+	s.drop(s.bytes(end-replayLead) - s.start)
+	s.retries = 0
+	s.final, s.cut = end, 0
+}
+
+// dedup drops what seg, a result of a backend stream started at base,
+// repeats of the audio finalized before it replaced a failed one: whole
+// segments within it, or else the words starting in it. Segments without
+// times are kept. s.mu must be held.
+func (s *resilientStream) dedup(seg Segment, base time.Duration) (Segment, bool) {
+	cut := s.cut - base
+	if cut <= 0 || seg.End == 0 || seg.Start >= cut {
+		return seg, true
+	}
+	if seg.End <= cut {
+		return seg, false
+	}
+	i := 0
+	for i < len(seg.Words) && seg.Words[i].Start < cut {
+		i++
+	}
+	switch {
+	case i == 0:
+		return seg, true
+	case i == len(seg.Words):
+		return seg, false
+	}
+	seg.Words = slices.Clone(seg.Words[i:])
+	text := make([]string, len(seg.Words))
+	for j, w := range seg.Words {
+		text[j] = w.Text
+	}
+	seg.Text = strings.Join(text, " ")
+	seg.Start = seg.Words[0].Start
+	return seg, true
+}
+
+// revise numbers seg after the revisions forwarded of its utterance, which
+// a backend stream continuing it after a failure counts from 1 again, and
+// tracks the utterance left open. s.mu must be held.
+func (s *resilientStream) revise(seg Segment) Segment {
+	seg.Revision = max(seg.Revision, s.revs[seg.UtteranceID]+1)
+	if seg.Final {
+		delete(s.revs, seg.UtteranceID)
+		if s.open == seg.UtteranceID {
+			s.open = ""
+		}
+		return seg
+	}
+	s.revs[seg.UtteranceID] = seg.Revision
+	s.open = seg.UtteranceID
+	return seg
+}
+
+// late reports by how much a segment ending at end misses slo. s.mu must
+// be held.
+func (s *resilientStream) late(end, slo time.Duration) time.Duration {
+	if slo <= 0 {
+		return 0
+	}
+	off := s.bytes(end)
+	i := sort.Search(len(s.written), func(i int) bool { return s.written[i].end >= off })
+	if i == len(s.written) {
+		return 0
+	}
+	return max(time.Since(s.written[i].at)-slo, 0)
+}
+
+// bytes returns the size of d of audio.
+func (s *resilientStream) bytes(d time.Duration) int {
+	return 2 * int(int64(s.cfg.SampleRate)*int64(d)/int64(time.Second))
+}
+
+// forward relays the results of the backend streams in turn, until one
+// ends for good.
+func (s *resilientStream) forward() {
+	defer close(s.results)
+	for {
+		s.mu.Lock()
+		rec, base, cur := s.rec, s.base, s.cur
+		s.mu.Unlock()
+		l := &s.r.chain[cur]
+		last := cur+1 == len(s.r.chain)
+
+		var slow time.Duration
+		for seg := range rec.Results() {
+			s.mu.Lock()
+			seg, ok := s.dedup(seg, base)
+			if !ok {
+				s.mu.Unlock()
+				continue
+			}
+			seg = s.revise(shift(seg, base))
+			seg.Provider = l.name
+			slow = s.late(seg.End, l.slo)
+			if seg.Final {
+				s.finalized(seg.End)
+			}
+			s.mu.Unlock()
+			select {
+			case s.results <- seg:
+			case <-s.ctx.Done():
+			}
+			if slow > 0 && !last && s.ctx.Err() == nil {
+				break
+			}
+		}
+		if slow > 0 && !last && s.ctx.Err() == nil {
+			s.log.Warn("recognizer too slow, failing over", "provider", l.name,
+				"next", s.r.chain[cur+1].name, "late", slow, "slo", l.slo)
+			s.abandon(rec)
+			if err := s.reconnect(cur+1, 0); err != nil {
+				s.end(err)
+				return
+			}
+			continue
+		}
+
+		err := rec.Err()
+		s.mu.Lock()
+		if err == nil {
+			err = s.werr
+		}
+		s.mu.Unlock()
+		l.policy.Observe(err)
+		if err == nil || s.ctx.Err() != nil {
+			s.end(err)
+			return
+		}
+		next, retry := cur, 0
+		switch {
+		case resilience.Transient(err) && s.retries+1 < l.policy.Attempts():
+			s.retries++
+			retry = s.retries
+			s.log.Warn("recognizer stream failed, reconnecting", "provider", l.name, "error", err, "retry", retry)
+		case !last:
+			next++
+			s.log.Warn("recognizer stream failed, failing over", "provider", l.name,
+				"next", s.r.chain[next].name, "error", err)
+		default:
+			s.end(err)
+			return
+		}
+		if rerr := s.reconnect(next, retry); rerr != nil {
+			s.end(fmt.Errorf("stt: reconnect after %v: %w", err, rerr))
+			return
+		}
+	}
+}
+
+// end records why the stream ended.
+func (s *resilientStream) end(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// abandon stops using rec, closing it and discarding its remaining
+// results.
+func (s *resilientStream) abandon(rec StreamingRecognizer) {
+	s.mu.Lock()
+	s.broken = true
+	closed := s.closed
+	s.mu.Unlock()
+	go func() {
+		if !closed {
+			_ = rec.Close()
+		}
+		for range rec.Results() {
+		}
+	}()
+}
+
+// reconnect replaces the backend stream with one on the provider at from
+// in the chain, or after it, and replays the audio not finalized yet. For
+// a retry, it first backs off as the provider's policy says.
+func (s *resilientStream) reconnect(from, retry int) error {
+	s.mu.Lock()
+	s.broken = true
+	s.mu.Unlock()
+	if retry > 0 {
+		t := time.NewTimer(s.r.chain[from].policy.Backoff(retry))
+		select {
+		case <-t.C:
+		case <-s.ctx.Done():
+			t.Stop()
+			return s.ctx.Err()
+		}
+	}
+	s.mu.Lock()
+	cfg := s.cfg
+	// The new stream continues the utterance left open, if any; the
+	// backend names the others.
+	cfg.UtteranceID = s.open
+	s.mu.Unlock()
+	rec, cur, err := s.r.open(s.ctx, cfg, s.log, from)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur != s.cur {
+		s.retries = 0
+	}
+	s.rec, s.cur, s.broken, s.werr = rec, cur, false, nil
+	s.cut = s.final
+	s.base = audio.Format{SampleRate: s.cfg.SampleRate, Channels: 1}.Duration(s.start / 2)
+	if ls, ok := rec.(LanguageSetter); ok && s.lang != "" {
+		if err := ls.SetLanguage(s.lang); err != nil {
+			s.log.Warn("restoring recognizer language failed", "language", s.lang, "error", err)
+		}
+	}
+	// The replayed audio reaches the new backend now.
+	now := time.Now()
+	for i := range s.written {
+		s.written[i].at = now
+	}
+	// Replay, flushing where the caller did.
+	off := 0
+	for _, f := range s.flushes {
+		if err := s.write(s.replay[off : f-s.start]); err != nil {
+			return nil
+		}
+		if err := rec.Flush(); err != nil {
+			s.fail(err)
+			return nil
+		}
+		off = f - s.start
+	}
+	if err := s.write(s.replay[off:]); err != nil {
+		return nil
+	}
+	if s.closed {
+		if err := rec.Close(); err != nil {
+			s.fail(err)
+		}
+	}
+	return nil
+}
+
+// write replays p into rec. s.mu must be held.
+func (s *resilientStream) write(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	_, err := s.rec.Write(p)
+	if err != nil {
+		s.fail(err)
+	}
+	return err
+}
+
+// shift moves seg from the clock of a backend stream started at base onto
+// the stream's clock.
+func shift(seg Segment, base time.Duration) Segment {
+	if base == 0 {
+		return seg
+	}
+	seg.Start += base
+	seg.End += base
+	for i := range seg.Words {
+		seg.Words[i].Start += base
+		seg.Words[i].End += base
+	}
+	return seg
+}
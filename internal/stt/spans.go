@@ -0,0 +1,47 @@
+package stt
+
+import "strings"
+
+// Span is N consecutive words of the text of a segment and what a
+// transcript stage rewrites them as, such as their written form or a
+// vocabulary phrase.
+type Span struct {
+	N    int
+	Text string
+}
+
+// RewriteSpans sets the text of seg to spans, which cover its words in
+// order. If seg has as many timed words as the spans cover, the words of
+// every span are merged into one, from the start of the first to the end
+// of the last, with their average confidence; otherwise the words are left
+// alone, as they cannot be told apart.
+func RewriteSpans(seg *Segment, spans []Span) {
+	out := make([]string, len(spans))
+	n := 0
+	for i, sp := range spans {
+		out[i] = sp.Text
+		n += sp.N
+	}
+	seg.Text = strings.Join(out, " ")
+	if len(seg.Words) != n {
+		return
+	}
+	// The words may be shared with the recognizer, so they are copied.
+	timed := make([]Word, 0, len(spans))
+	i := 0
+	for _, sp := range spans {
+		w := seg.Words[i]
+		w.Text = sp.Text
+		if sp.N > 1 {
+			w.End = seg.Words[i+sp.N-1].End
+			var conf float32
+			for _, x := range seg.Words[i : i+sp.N] {
+				conf += x.Confidence
+			}
+			w.Confidence = conf / float32(sp.N)
+		}
+		timed = append(timed, w)
+		i += sp.N
+	}
+	seg.Words = timed
+}
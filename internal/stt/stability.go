@@ -0,0 +1,33 @@
+package stt
+
+import "strings"
+
+// Stabilizer estimates the stability of partial hypotheses for backends that
+// do not report one.
+//
+// It uses the longest common word prefix between consecutive hypotheses of
+// the same utterance: words that survived a re-decode are unlikely to change
+// again, while the volatile tail keeps being rewritten.
+type Stabilizer struct {
+	prev []string
+}
+
+// Score returns the stability of text given the previous hypothesis and
+// remembers text for the next call.
+func (s *Stabilizer) Score(text string) float32 {
+	words := strings.Fields(text)
+	defer func() { s.prev = words }()
+	if len(words) == 0 || len(s.prev) == 0 {
+		return 0
+	}
+	n := 0
+	for n < len(words) && n < len(s.prev) && words[n] == s.prev[n] {
+		n++
+	}
+	return float32(n) / float32(len(words))
+}
+
+// Reset forgets the previous hypothesis. Call it when an utterance ends.
+func (s *Stabilizer) Reset() {
+	s.prev = nil
+}
@@ -0,0 +1,268 @@
+// Package stt defines the speech-to-text contracts shared by every recognizer
+// backend: the streaming recognizer interface and the transcript types it
+// produces.
+//
+// Recognition follows the full-replace protocol described in
+// internal/audio/streaming_asr.md: for a given utterance, every partial
+// Segment is a complete hypothesis that replaces the previous one, and the
+// final Segment freezes it.
+package stt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// ErrClosed is returned when writing to a recognizer that has been closed.
+var ErrClosed = errors.New("stt: recognizer closed")
+
+// Segment is a recognized span of speech for one utterance.
+//
+// While Final is false the segment is an interim hypothesis: consumers should
+// replace any earlier segment with the same UtteranceID instead of appending
+// to it.
+type Segment struct {
+	// UtteranceID identifies the utterance this segment belongs to.
+	UtteranceID string
+	// Revision increases by one for every hypothesis emitted for the utterance.
+	Revision int
+	// Text is the full hypothesis for the utterance so far.
+	Text string
+	// Stability estimates how likely Text is to stay unchanged, in [0, 1].
+	// Final segments always report 1.
+	Stability float32
+	// Final reports whether this is the committed transcript of the utterance.
+	Final bool
+	// Speaker labels who said the utterance ("S1", "S2", ...) when the
+	// pipeline runs diarization, or names them once they match an enrolled
+	// voiceprint. It is empty otherwise, and on early partials before
+	// enough speech has been heard.
+	Speaker string
+	// Start and End delimit the utterance heard so far. Recognizers report
+	// them as offsets from the start of the audio written to the stream;
+	// the pipeline shifts them onto the source's clock.
+	Start, End time.Duration
+	// Confidence of the hypothesis in [0, 1]; zero if the backend does not
+	// estimate it.
+	Confidence float32
+	// Words aligns the hypothesis word by word, when the backend supports
+	// it. Word times are on the same clock as Start and End.
+	Words []Word
+	// Language is the ISO 639-1 code of the spoken language, when the
+	// backend or the pipeline's language identification knows it.
+	Language string
+	// Translations holds the text of a final segment in other languages,
+	// keyed by ISO 639-1 code, when the pipeline translates transcripts.
+	Translations map[string]string
+	// Provider names the backend that recognized the segment when the
+	// recognizer can fail over between several (see Config.Fallbacks).
+	Provider string
+	// Redactions lists the spans of Text replaced by markers when the
+	// pipeline redacts personal data, in order.
+	Redactions []Redaction
+	// Sentiment is the tone of a final segment when the pipeline analyzes
+	// it.
+	Sentiment *Sentiment
+}
+
+// Sentiment is the tone of a segment.
+type Sentiment struct {
+	// Label is SentimentPositive, SentimentNegative or SentimentNeutral.
+	Label string
+	// Score is the polarity of the text, from -1, negative, to 1,
+	// positive.
+	Score float32
+	// Emotion and Arousal are only set when the pipeline also analyzes
+	// the voice. Emotion combines how aroused it sounds with the polarity
+	// of the words: "angry", "happy", "sad", "calm" or "neutral". Arousal
+	// is in (0, 1), 0.5 being the speaker's usual.
+	Emotion string
+	Arousal float32
+}
+
+// Sentiment labels.
+const (
+	SentimentPositive = "positive"
+	SentimentNegative = "negative"
+	SentimentNeutral  = "neutral"
+)
+
+// Redaction records a span of a segment's text that was redacted.
+type Redaction struct {
+	// Entity is the kind of data removed, e.g. "credit_card".
+	Entity string
+	// Start and End are the byte offsets of the removed span in the text
+	// as it was before redaction.
+	Start, End int
+	// At is the byte offset in Text of the marker that replaced the span.
+	At int
+}
+
+// Word is one recognized word with its alignment.
+type Word struct {
+	Text       string
+	Start, End time.Duration
+	// Confidence in [0, 1]; zero if unknown.
+	Confidence float32
+}
+
+// StreamingRecognizer transcribes a single audio stream incrementally.
+//
+// Audio is PCM16 little-endian in the format the stream was opened with and
+// can be pushed with Write or, for channel-based producers, with Feed.
+// Hypotheses are delivered on Results as soon as the backend produces them.
+type StreamingRecognizer interface {
+	io.Writer
+
+	// Results returns the channel hypotheses are delivered on. It is closed
+	// once the stream has ended, after which Err reports why.
+	Results() <-chan Segment
+	// Flush finalizes the current utterance; a final Segment follows on
+	// Results and a new utterance starts with the next Write.
+	Flush() error
+	// Close finalizes any pending utterance and ends the stream. Remaining
+	// hypotheses are still delivered on Results before it is closed.
+	Close() error
+	// Err returns the error that terminated the stream, if any. It is only
+	// meaningful once Results has been closed.
+	Err() error
+}
+
+// StreamConfig describes the audio a stream will receive.
+type StreamConfig struct {
+	// UtteranceID names the first utterance. Backends generate one if empty.
+	UtteranceID string
+	// SampleRate of the PCM16 mono audio, in Hz.
+	SampleRate int
+	// Logger, if set, receives the stream's log records in place of the
+	// provider's logger, carrying fields such as the session.
+	Logger logging.Logger
+	// Phrases bias recognition toward words and phrases the audio is likely
+	// to contain, such as product names, on providers implementing
+	// PhraseBiaser. Others ignore them.
+	Phrases []Phrase
+	// Priority ranks the stream against the others sharing the backend.
+	Priority Priority
+	// Language, if set, is the language to recognize, as an ISO 639-1 code,
+	// in place of the provider's, and Model the model to recognize it
+	// with, as the provider names them; see StreamOverrider.
+	Language string
+	Model    string
+	// Endpointing, if set, is the pause the pipeline ends utterances on,
+	// flushing the stream. Backends ending utterances on pauses themselves
+	// wait that long instead of their own default, so that both agree.
+	Endpointing time.Duration
+}
+
+// Priority ranks the streams competing for the capacity of a backend, such
+// as the decodes of a shared GPU: backends scheduling work run that of
+// higher priorities first, so live conversations are not held up by
+// offline jobs. The zero value is PriorityNormal.
+type Priority int
+
+const (
+	// PriorityBatch is for offline work, such as transcribing archived
+	// audio again, which waits for the others.
+	PriorityBatch Priority = -1
+	// PriorityNormal is for live streams no one waits on turn by turn.
+	PriorityNormal Priority = 0
+	// PriorityInteractive is for streams someone is waiting to hear back
+	// on, such as voice assistants.
+	PriorityInteractive Priority = 1
+)
+
+// String returns the name accepted by ParsePriority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityBatch:
+		return "batch"
+	case PriorityNormal:
+		return "normal"
+	case PriorityInteractive:
+		return "interactive"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// ParsePriority returns the priority named "interactive", "normal" or
+// "batch"; empty is PriorityNormal.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	for _, p := range []Priority{PriorityInteractive, PriorityNormal, PriorityBatch} {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("stt: unknown priority %q (want interactive, normal or batch)", s)
+}
+
+// Phrase is a word or phrase recognition should favour.
+type Phrase struct {
+	Text string
+	// Boost weights how strongly the phrase is favoured relative to the
+	// others; zero means 1. Backends map it onto whatever biasing they
+	// have.
+	Boost float32
+}
+
+// PhraseBiaser is implemented by providers whose backend biases
+// recognition toward StreamConfig.Phrases. The pipeline corrects the
+// transcripts of other providers against the phrases instead.
+type PhraseBiaser interface {
+	BiasesPhrases() bool
+}
+
+// StreamOverrider is implemented by providers whose streams honour
+// StreamConfig.Language or StreamConfig.Model. The pipeline refuses streams
+// asking for what their provider cannot do rather than ignoring it.
+type StreamOverrider interface {
+	Overrides() (language, model bool)
+}
+
+// Provider opens streaming recognition sessions against a backend.
+type Provider interface {
+	// NewStream starts a recognition stream. Cancelling ctx aborts it.
+	NewStream(ctx context.Context, cfg StreamConfig) (StreamingRecognizer, error)
+}
+
+// LanguageSetter is implemented by recognizer streams whose language can be
+// changed mid-stream, such as after language identification. The change
+// applies to audio not decoded yet.
+type LanguageSetter interface {
+	SetLanguage(lang string) error
+}
+
+// FormatRequirer is implemented by providers whose backend only accepts
+// one audio format. The pipeline converts other sources before their audio
+// reaches the stream. A zero SampleRate accepts any rate.
+type FormatRequirer interface {
+	RequiredFormat() audio.Format
+}
+
+// Feed copies audio chunks from ch into r until ch is closed or ctx is done,
+// then closes r. It returns the first write error.
+func Feed(ctx context.Context, r StreamingRecognizer, ch <-chan []byte) error {
+	for {
+		select {
+		case <-ctx.Done():
+			_ = r.Close()
+			return ctx.Err()
+		case chunk, ok := <-ch:
+			if !ok {
+				return r.Close()
+			}
+			if _, err := r.Write(chunk); err != nil {
+				_ = r.Close()
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+//go:build cgo && whisper
+
+package whisper
+
+/*
+#cgo pkg-config: whisper
+#include <stdlib.h>
+#include <whisper.h>
+
+// A decode polls its abort flag, which Go raises when the decode's context
+// is done.
+static bool voxa_aborted(void *flag) { return __atomic_load_n((int *)flag, __ATOMIC_RELAXED) != 0; }
+static void voxa_abort(int *flag) { __atomic_store_n(flag, 1, __ATOMIC_RELAXED); }
+static void voxa_set_abort(struct whisper_full_params *p, int *flag) {
+	*flag = 0;
+	p->abort_callback = voxa_aborted;
+	p->abort_callback_user_data = flag;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"unsafe"
+
+	"github.com/jmarc101/voxa/internal/langid"
+)
+
+type libModel struct {
+	ctx *C.struct_whisper_context
+}
+
+func loadModel(path string) (model, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	ctx := C.whisper_init_from_file_with_params(cpath, C.whisper_context_default_params())
+	if ctx == nil {
+		return nil, fmt.Errorf("whisper: cannot load model %s", path)
+	}
+	return &libModel{ctx: ctx}, nil
+}
+
+func buildFeatures() []string {
+	return enabledFeatures(C.GoString(C.whisper_print_system_info()))
+}
+
+func validLanguage(code string) bool {
+	c := C.CString(code)
+	defer C.free(unsafe.Pointer(c))
+	return C.whisper_lang_id(c) >= 0
+}
+
+func (m *libModel) multilingual() bool {
+	return C.whisper_is_multilingual(m.ctx) != 0
+}
+
+func (m *libModel) newDecoder() (decoder, error) {
+	st := C.whisper_init_state(m.ctx)
+	if st == nil {
+		return nil, errors.New("whisper: cannot allocate decoder state")
+	}
+	return &libDecoder{ctx: m.ctx, st: st}, nil
+}
+
+func (m *libModel) close() {
+	if m.ctx != nil {
+		C.whisper_free(m.ctx)
+		m.ctx = nil
+	}
+}
+
+type libDecoder struct {
+	ctx *C.struct_whisper_context
+	st  *C.struct_whisper_state
+}
+
+// centis converts whisper.cpp timestamps, in 10 ms units.
+func centis(t C.int64_t) time.Duration {
+	return time.Duration(t) * 10 * time.Millisecond
+}
+
+func (d *libDecoder) decode(ctx context.Context, pcm []float32, opts decodeOptions) ([]segment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	lang := C.CString(opts.language)
+	defer C.free(unsafe.Pointer(lang))
+	params := C.whisper_full_default_params(C.WHISPER_SAMPLING_GREEDY)
+	params.n_threads = C.int(opts.threads)
+	params.language = lang
+	params.translate = C.bool(opts.translate)
+	// Every decode covers a whole utterance; carrying text over from the
+	// previous one only helps hallucinations along.
+	params.no_context = true
+	if opts.prompt != "" {
+		prompt := C.CString(opts.prompt)
+		defer C.free(unsafe.Pointer(prompt))
+		params.initial_prompt = prompt
+	}
+	params.token_timestamps = true
+	params.suppress_blank = true
+	params.print_progress = false
+	params.print_realtime = false
+	params.print_timestamps = false
+	params.print_special = false
+
+	flag := (*C.int)(C.malloc(C.sizeof_int))
+	C.voxa_set_abort(&params, flag)
+	aborted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		C.voxa_abort(flag)
+		close(aborted)
+	})
+	defer func() {
+		if !stop() {
+			<-aborted // the flag must outlive the store
+		}
+		C.free(unsafe.Pointer(flag))
+	}()
+
+	if rc := C.whisper_full_with_state(d.ctx, d.st, params, (*C.float)(unsafe.Pointer(&pcm[0])), C.int(len(pcm))); rc != 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("whisper_full failed (%d)", int(rc))
+	}
+	language := C.GoString(C.whisper_lang_str(C.whisper_full_lang_id_from_state(d.st)))
+	eot := C.whisper_token_eot(d.ctx)
+	n := int(C.whisper_full_n_segments_from_state(d.st))
+	segs := make([]segment, 0, n)
+	for i := 0; i < n; i++ {
+		ci := C.int(i)
+		seg := segment{
+			text:  C.GoString(C.whisper_full_get_segment_text_from_state(d.st, ci)),
+			start: centis(C.whisper_full_get_segment_t0_from_state(d.st, ci)),
+			end:   centis(C.whisper_full_get_segment_t1_from_state(d.st, ci)),
+
+			language: language,
+		}
+		for j := 0; j < int(C.whisper_full_n_tokens_from_state(d.st, ci)); j++ {
+			td := C.whisper_full_get_token_data_from_state(d.st, ci, C.int(j))
+			if td.id >= eot {
+				continue // timestamps and other special tokens
+			}
+			seg.tokens = append(seg.tokens, token{
+				text:  C.GoString(C.whisper_full_get_token_text_from_state(d.ctx, d.st, ci, C.int(j))),
+				start: centis(td.t0),
+				end:   centis(td.t1),
+				p:     float32(td.p),
+			})
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+func (d *libDecoder) identify(pcm []float32, threads int) ([]langid.Guess, error) {
+	if rc := C.whisper_pcm_to_mel_with_state(d.ctx, d.st, (*C.float)(unsafe.Pointer(&pcm[0])), C.int(len(pcm)), C.int(threads)); rc != 0 {
+		return nil, fmt.Errorf("whisper: mel spectrogram failed (%d)", int(rc))
+	}
+	probs := make([]C.float, int(C.whisper_lang_max_id())+1)
+	if rc := C.whisper_lang_auto_detect_with_state(d.ctx, d.st, 0, C.int(threads), &probs[0]); rc < 0 {
+		return nil, fmt.Errorf("whisper: language detection failed (%d)", int(rc))
+	}
+	guesses := make([]langid.Guess, len(probs))
+	for id, p := range probs {
+		guesses[id] = langid.Guess{Language: C.GoString(C.whisper_lang_str(C.int(id))), Confidence: float32(p)}
+	}
+	sort.Slice(guesses, func(i, j int) bool { return guesses[i].Confidence > guesses[j].Confidence })
+	return guesses, nil
+}
+
+func (d *libDecoder) close() {
+	if d.st != nil {
+		C.whisper_free_state(d.st)
+		d.st = nil
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(cgo && whisper)
+
+package whisper
+
+func loadModel(string) (model, error) { return nil, ErrNoEngine }
+
+func validLanguage(string) bool { return false }
+
+func buildFeatures() []string { return nil }
@@ -0,0 +1,811 @@
+// Package whisper is a local speech-to-text backend running whisper.cpp
+// (https://github.com/ggerganov/whisper.cpp) in process, so no audio leaves
+// the machine.
+//
+// whisper.cpp is bound through cgo. Build with `-tags whisper` and the
+// library installed where pkg-config finds it (whisper.pc); without the tag
+// the provider is still registered but fails to load with ErrNoEngine.
+// Models are the ggml files whisper.cpp ships conversion scripts for, e.g.
+// ggml-base.en.bin, given by path or by the name of a model installed with
+// voxa models pull, e.g. ggml-base.en. With the quantization auto, the
+// installed variant of the model whose kernels suit the CPU loads instead,
+// q5 on a Raspberry Pi 4 and q8_0 where the CPU has integer dot products.
+//
+// Whisper decodes whole utterances rather than streaming, so the recognizer
+// buffers each utterance and re-decodes it periodically for partials. The
+// final decode runs on Flush, on Close, or once an utterance reaches the
+// 30 second window Whisper was trained on.
+//
+// By default every stream decodes on its own whisper.cpp state. Under load,
+// set MaxBatch to route the decodes of all streams through a batch.Scheduler
+// instead: they run in batches on a fixed pool of MaxBatch states, which
+// keeps the GPU fed back to back and bounds memory whatever the number of
+// streams. whisper.cpp has no call that decodes several inputs at once, so
+// the items of a batch run concurrently, one per state. Batches take the
+// decodes of the streams of the highest stt.Priority first, so batch jobs
+// wait while interactive streams saturate the pool.
+//
+// Multilingual models also identify languages: Recognizer implements
+// langid.Identifier, and streams implement stt.LanguageSetter so the
+// pipeline can switch them to the language it identified.
+//
+// The phrases a stream is opened with go in the initial prompt of its
+// decodes, which biases Whisper toward them; see stt.PhraseBiaser.
+package whisper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/langid"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/models"
+	"github.com/jmarc101/voxa/internal/simd"
+	"github.com/jmarc101/voxa/internal/stt"
+	"github.com/jmarc101/voxa/internal/stt/batch"
+)
+
+// ProviderName is the name the backend registers under. Its options are
+// "model", "quantization", "language", "threads", "translate",
+// "partial_interval", "max_batch", "max_batch_wait" and "max_backlog" (0
+// removes the bound).
+const ProviderName = "whisper"
+
+// SampleRate is the only rate Whisper models take.
+const SampleRate = 16000
+
+// ErrNoEngine is returned when voxa was built without whisper.cpp.
+var ErrNoEngine = errors.New("whisper: built without whisper.cpp (rebuild with -tags whisper)")
+
+// maxUtterance is the longest audio Whisper decodes in one window; longer
+// utterances are finalized and continued in a new one.
+const maxUtterance = 30 * time.Second
+
+// minDecode is the shortest input whisper.cpp accepts; shorter utterances
+// are padded with silence.
+const minDecode = 1010 * time.Millisecond
+
+func init() {
+	stt.Register(ProviderName, func(cfg stt.Config) (stt.Provider, error) {
+		c := Config{
+			Model:        cfg.Option("model", ""),
+			Quantization: cfg.Option("quantization", ""),
+			Language:     cfg.Option("language", ""),
+			Logger:       cfg.Logger,
+		}
+		if v := cfg.Option("threads", ""); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("bad threads %q", v)
+			}
+			c.Threads = n
+		}
+		if v := cfg.Option("translate", ""); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("bad translate %q", v)
+			}
+			c.Translate = b
+		}
+		if v := cfg.Option("partial_interval", ""); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("bad partial_interval %q", v)
+			}
+			c.PartialInterval = d
+			if d == 0 {
+				c.PartialInterval = -1
+			}
+		}
+		if v := cfg.Option("max_batch", ""); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("bad max_batch %q", v)
+			}
+			c.MaxBatch = n
+		}
+		if v := cfg.Option("max_batch_wait", ""); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("bad max_batch_wait %q", v)
+			}
+			c.MaxBatchWait = d
+		}
+		if v := cfg.Option("max_backlog", ""); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("bad max_backlog %q", v)
+			}
+			c.MaxBacklog = d
+			if d == 0 {
+				c.MaxBacklog = -1
+			}
+		}
+		return New(c)
+	})
+}
+
+// Config configures the engine.
+type Config struct {
+	// Model is the path of the ggml model file, or the name of an
+	// installed model; see models.Resolve.
+	Model string
+	// Quantization selects the installed variant of Model to load: q8_0,
+	// q5_1 or q5_0, or auto for the one whose kernels suit the CPU best,
+	// Model itself if none is installed. Empty loads Model as named.
+	Quantization string
+	// Language is the spoken language as an ISO 639-1 code ("en", "fr").
+	// Empty or "auto" detects it per utterance, which multilingual models
+	// only support; English-only models (*.en) default to "en".
+	Language string
+	// Threads is the number of CPU threads each decode uses. Defaults to
+	// the number of CPUs, at most 8.
+	Threads int
+	// Translate outputs English text whatever the spoken language.
+	Translate bool
+	// PartialInterval is how much new audio triggers a partial re-decode.
+	// Defaults to 1s; negative disables partials, so only finals are
+	// emitted.
+	PartialInterval time.Duration
+	// MaxBatch, if above 1, decodes all streams in shared batches of at
+	// most this many utterances instead of on a state per stream.
+	MaxBatch int
+	// MaxBatchWait is how long a decode waits for others to batch with.
+	// Defaults to 10ms; only used with MaxBatch.
+	MaxBatchWait time.Duration
+	// MaxBacklog bounds the flushed audio of a stream awaiting its final
+	// decode. Writes past it wait for the decoder, so a model slower than
+	// real time pushes back on the pipeline instead of buffering without
+	// end. Defaults to 2 minutes; negative removes the bound.
+	MaxBacklog time.Duration
+	// Logger receives the engine's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Recognizer runs a loaded model. Streams share the model weights but keep
+// their own decoder state, so they decode concurrently; with batching they
+// share a pool of states instead.
+type Recognizer struct {
+	cfg     Config
+	model   model
+	release func()                           // of model, in loaded
+	sched   *batch.Scheduler[job, []segment] // nil without batching
+	pool    []decoder                        // states batches run on
+
+	lidMu sync.Mutex
+	lid   decoder // state for Identify, allocated on first use
+}
+
+var (
+	_ langid.Identifier  = (*Recognizer)(nil)
+	_ stt.LanguageSetter = (*stream)(nil)
+)
+
+var (
+	_ stt.Provider        = (*Recognizer)(nil)
+	_ stt.FormatRequirer  = (*Recognizer)(nil)
+	_ stt.PhraseBiaser    = (*Recognizer)(nil)
+	_ stt.StreamOverrider = (*Recognizer)(nil)
+)
+
+// New loads the model.
+func New(cfg Config) (*Recognizer, error) {
+	if cfg.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	path, err := models.Select(ProviderName, cfg.Model, cfg.Quantization)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Model = path
+	if lacks := simd.Detect().Lacks(buildFeatures()...); len(lacks) > 0 {
+		return nil, fmt.Errorf("whisper.cpp was built for %s, which this CPU lacks (rebuild it with GGML_NATIVE=OFF)", strings.Join(lacks, ", "))
+	}
+	if cfg.Threads == 0 {
+		cfg.Threads = min(runtime.NumCPU(), 8)
+	}
+	if cfg.Threads < 0 {
+		return nil, fmt.Errorf("bad thread count %d", cfg.Threads)
+	}
+	if cfg.PartialInterval == 0 {
+		cfg.PartialInterval = time.Second
+	}
+	if cfg.MaxBacklog == 0 {
+		cfg.MaxBacklog = 2 * time.Minute
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	m, release, err := loaded.Acquire(cfg.Model, cfg.Model, func() (model, error) { return loadModel(cfg.Model) }, model.close)
+	if err != nil {
+		return nil, err
+	}
+	switch lang := strings.ToLower(cfg.Language); {
+	case !m.multilingual() && (lang == "" || lang == "en"):
+		cfg.Language = "en"
+	case !m.multilingual():
+		release()
+		return nil, fmt.Errorf("model %s is English-only, cannot recognize %q", cfg.Model, cfg.Language)
+	case lang == "" || lang == "auto":
+		cfg.Language = "auto"
+	case !validLanguage(lang):
+		release()
+		return nil, fmt.Errorf("unknown language %q", cfg.Language)
+	default:
+		cfg.Language = lang
+	}
+	r := &Recognizer{cfg: cfg, model: m, release: release}
+	if cfg.MaxBatch > 1 {
+		if err := r.startBatching(); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	cfg.Logger.Info("whisper model loaded", "model", cfg.Model, "language", cfg.Language,
+		"threads", cfg.Threads, "max_batch", cfg.MaxBatch, "simd", simd.Best())
+	return r, nil
+}
+
+// startBatching allocates the state pool and starts the scheduler.
+func (r *Recognizer) startBatching() error {
+	for range r.cfg.MaxBatch {
+		d, err := r.model.newDecoder()
+		if err != nil {
+			return err
+		}
+		r.pool = append(r.pool, d)
+	}
+	sched, err := batch.New(batch.Config{MaxBatch: r.cfg.MaxBatch, MaxWait: r.cfg.MaxBatchWait}, r.decodeBatch)
+	if err != nil {
+		return err
+	}
+	r.sched = sched
+	return nil
+}
+
+// job is one decode request of a batch.
+type job struct {
+	ctx  context.Context // of the requesting stream
+	pcm  []float32
+	opts decodeOptions
+}
+
+// decodeBatch decodes a batch of utterances, one per pooled state.
+func (r *Recognizer) decodeBatch(jobs []job) ([][]segment, error) {
+	out := make([][]segment, len(jobs))
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i], errs[i] = r.pool[i].decode(j.ctx, j.pcm, j.opts)
+			if j.ctx.Err() != nil {
+				errs[i] = nil // aborted; its stream is gone, the others are fine
+			}
+		}()
+	}
+	wg.Wait()
+	return out, errors.Join(errs...)
+}
+
+func (r *Recognizer) options(prompt string) decodeOptions {
+	return decodeOptions{language: r.cfg.Language, threads: r.cfg.Threads, translate: r.cfg.Translate, prompt: prompt}
+}
+
+// maxPrompt bounds the prompt built from phrases: Whisper conditions on at
+// most 224 tokens of it, about this many bytes of English.
+const maxPrompt = 600
+
+// prompt lists phrases in the initial prompt of decodes, which biases
+// Whisper toward them and their spelling. Whisper has no weights, so the
+// phrases boosted most come first and those past maxPrompt are left out.
+func prompt(phrases []stt.Phrase) string {
+	ps := slices.Clone(phrases)
+	boost := func(p stt.Phrase) float32 {
+		if p.Boost == 0 {
+			return 1
+		}
+		return p.Boost
+	}
+	sort.SliceStable(ps, func(i, j int) bool { return boost(ps[i]) > boost(ps[j]) })
+	var b strings.Builder
+	for _, p := range ps {
+		t := strings.TrimSpace(p.Text)
+		if t == "" {
+			continue
+		}
+		if b.Len()+len(t)+2 > maxPrompt {
+			break
+		}
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+// BiasesPhrases implements stt.PhraseBiaser: phrases prompt every decode.
+func (r *Recognizer) BiasesPhrases() bool { return true }
+
+// Overrides implements stt.StreamOverrider: streams may ask for a
+// language, which multilingual models recognize. The model is the one
+// loaded.
+func (r *Recognizer) Overrides() (language, model bool) { return true, false }
+
+// RequiredFormat implements stt.FormatRequirer: Whisper takes 16 kHz mono.
+func (r *Recognizer) RequiredFormat() audio.Format {
+	return audio.Format{SampleRate: SampleRate, Channels: 1}
+}
+
+// Identify implements langid.Identifier with the model's own language
+// detection, on up to the first 30 seconds of fr.
+func (r *Recognizer) Identify(ctx context.Context, fr audio.Frame) ([]langid.Guess, error) {
+	if !r.model.multilingual() {
+		return nil, langid.ErrUnsupported
+	}
+	if fr.Format.SampleRate != SampleRate || fr.Format.Channels != 1 {
+		return nil, fmt.Errorf("whisper: identify: format %+v, want 16 kHz mono", fr.Format)
+	}
+	pcm := make([]float32, min(fr.Len(), format.Samples(maxUtterance)))
+	for i := range pcm {
+		pcm[i] = float32(fr.Data[i]) / 32768
+	}
+	if len(pcm) < format.Samples(minDecode) {
+		pcm = append(pcm, make([]float32, format.Samples(minDecode)-len(pcm))...)
+	}
+	r.lidMu.Lock()
+	defer r.lidMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if r.lid == nil {
+		d, err := r.model.newDecoder()
+		if err != nil {
+			return nil, err
+		}
+		r.lid = d
+	}
+	return r.lid.identify(pcm, r.cfg.Threads)
+}
+
+// Close releases the model, freed once no other recognizer uses it. Streams
+// must be closed first.
+func (r *Recognizer) Close() error {
+	if r.sched != nil {
+		r.sched.Close()
+	}
+	for _, d := range r.pool {
+		d.close()
+	}
+	if r.lid != nil {
+		r.lid.close()
+	}
+	r.release()
+	return nil
+}
+
+// NewStream implements stt.Provider.
+func (r *Recognizer) NewStream(ctx context.Context, cfg stt.StreamConfig) (stt.StreamingRecognizer, error) {
+	if cfg.SampleRate != 0 && cfg.SampleRate != SampleRate {
+		return nil, fmt.Errorf("whisper: sample rate %d, want %d", cfg.SampleRate, SampleRate)
+	}
+	opts := r.options(prompt(cfg.Phrases))
+	if cfg.Language != "" {
+		if opts.language = strings.ToLower(cfg.Language); !recognizes(r.model, opts.language) {
+			return nil, fmt.Errorf("whisper: cannot recognize language %q with this model", cfg.Language)
+		}
+	}
+	var dec decoder = &queued{sched: r.sched, priority: int(cfg.Priority)}
+	if r.sched == nil {
+		var err error
+		if dec, err = r.model.newDecoder(); err != nil {
+			return nil, err
+		}
+	}
+	uid := cfg.UtteranceID
+	if uid == "" {
+		uid = newUtteranceID()
+	}
+	log := cfg.Logger
+	if log == nil {
+		log = r.cfg.Logger
+	}
+	s := &stream{
+		ctx:      ctx,
+		log:      log,
+		model:    r.model,
+		opts:     opts,
+		interval: r.cfg.PartialInterval,
+		dec:      dec,
+		cur:      &utterance{id: uid},
+		wake:     make(chan struct{}, 1),
+		results:  make(chan stt.Segment, 16),
+	}
+	if r.cfg.MaxBacklog > 0 {
+		s.maxBacklog = format.Samples(r.cfg.MaxBacklog)
+	}
+	s.room = sync.NewCond(&s.mu)
+	go s.run()
+	return s, nil
+}
+
+// loaded holds the models of the recognizers of the process, so that
+// recognizers of the same model file share it, each decoding on states of
+// its own.
+var loaded models.Cache[string, model]
+
+// model is a loaded whisper.cpp context.
+type model interface {
+	multilingual() bool
+	newDecoder() (decoder, error)
+	close()
+}
+
+// decoder is one decoding state.
+type decoder interface {
+	// decode transcribes pcm; it gives up early once ctx is done.
+	decode(ctx context.Context, pcm []float32, opts decodeOptions) ([]segment, error)
+	// identify returns the language probabilities of the start of pcm,
+	// most likely first.
+	identify(pcm []float32, threads int) ([]langid.Guess, error)
+	close()
+}
+
+type decodeOptions struct {
+	language  string
+	threads   int
+	translate bool
+	prompt    string // initial prompt, empty for none
+}
+
+// queued is the decoder of streams on a batching recognizer.
+type queued struct {
+	sched    *batch.Scheduler[job, []segment]
+	priority int // of the stream
+}
+
+func (q *queued) decode(ctx context.Context, pcm []float32, opts decodeOptions) ([]segment, error) {
+	return q.sched.DoPriority(ctx, q.priority, job{ctx: ctx, pcm: pcm, opts: opts})
+}
+
+func (q *queued) identify([]float32, int) ([]langid.Guess, error) {
+	return nil, langid.ErrUnsupported
+}
+
+func (q *queued) close() {}
+
+// segment is one segment of a decode, with times relative to its input.
+type segment struct {
+	text       string
+	start, end time.Duration
+	tokens     []token
+	language   string // as decoded, detected if the decode asked "auto"
+}
+
+// token is one text token with its time span and probability.
+type token struct {
+	text       string
+	start, end time.Duration
+	p          float32
+}
+
+// utterance is the audio of one utterance, buffered for decoding.
+type utterance struct {
+	id      string
+	start   time.Duration // stream time of the first sample
+	pcm     []float32
+	decoded int // samples covered by the last partial
+	rev     int
+	stab    stt.Stabilizer
+}
+
+// stream implements stt.StreamingRecognizer. Writes buffer audio; one
+// goroutine decodes, so Write only waits for the model when the backlog is
+// full.
+type stream struct {
+	ctx        context.Context
+	model      model
+	interval   time.Duration // PartialInterval
+	maxBacklog int           // samples; 0 is unbounded
+	dec        decoder
+	log        logging.Logger
+
+	mu      sync.Mutex // guards the fields below
+	room    *sync.Cond // signalled as the backlog shrinks
+	opts    decodeOptions
+	cur     *utterance
+	queue   []*utterance // flushed, awaiting their final decode
+	backlog int          // samples in queue
+	written int          // samples written so far
+	closed  bool
+	stopped bool // run returned
+
+	wake    chan struct{}
+	results chan stt.Segment
+	err     error
+}
+
+var format = audio.Format{SampleRate: SampleRate, Channels: 1}
+
+func (s *stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.maxBacklog > 0 && s.backlog >= s.maxBacklog && !s.closed && !s.stopped {
+		s.room.Wait()
+	}
+	if s.closed || s.stopped {
+		return 0, stt.ErrClosed
+	}
+	limit := format.Samples(maxUtterance)
+	for i := 0; i+1 < len(p); i += 2 {
+		v := int16(uint16(p[i]) | uint16(p[i+1])<<8)
+		s.cur.pcm = append(s.cur.pcm, float32(v)/32768)
+		s.written++
+		if len(s.cur.pcm) >= limit {
+			s.cut(newUtteranceID())
+		}
+	}
+	s.signal()
+	return len(p), nil
+}
+
+// cut queues the current utterance for its final decode and starts the
+// next one. Callers hold mu.
+func (s *stream) cut(next string) {
+	if len(s.cur.pcm) > 0 {
+		s.queue = append(s.queue, s.cur)
+		s.backlog += len(s.cur.pcm)
+	}
+	s.cur = &utterance{id: next, start: format.Duration(s.written)}
+}
+
+func (s *stream) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *stream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return stt.ErrClosed
+	}
+	s.cut(newUtteranceID())
+	s.signal()
+	return nil
+}
+
+func (s *stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.cut("")
+	s.closed = true
+	s.room.Broadcast()
+	s.signal()
+	return nil
+}
+
+// SetLanguage implements stt.LanguageSetter. Utterances decoded from now on,
+// including the current one, are recognized in lang.
+func (s *stream) SetLanguage(lang string) error {
+	lang = strings.ToLower(lang)
+	if !recognizes(s.model, lang) {
+		return fmt.Errorf("whisper: cannot recognize language %q with this model", lang)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.language = lang
+	s.log.Debug("whisper language set", "language", lang)
+	return nil
+}
+
+// recognizes reports whether m recognizes lang, a lower-case code or auto.
+func recognizes(m model, lang string) bool {
+	return lang == "auto" || validLanguage(lang) && (m.multilingual() || lang == "en")
+}
+
+func (s *stream) Results() <-chan stt.Segment { return s.results }
+
+func (s *stream) Err() error { return s.err }
+
+// run decodes until the stream is closed and drained, or ctx is done.
+func (s *stream) run() {
+	defer close(s.results)
+	defer s.dec.close()
+	defer func() {
+		s.mu.Lock()
+		s.stopped = true
+		s.room.Broadcast()
+		s.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return
+		}
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		cur, pcm := s.cur, s.cur.pcm
+		opts := s.opts
+		closed := s.closed
+		s.mu.Unlock()
+
+		for _, u := range queue {
+			if !s.decode(u, u.pcm, true, opts) {
+				return
+			}
+			s.mu.Lock()
+			s.backlog -= len(u.pcm)
+			s.room.Broadcast()
+			s.mu.Unlock()
+		}
+		if closed {
+			return
+		}
+		// Write only appends, so the snapshot of the current utterance can
+		// be read without the lock.
+		if s.interval > 0 && len(pcm)-cur.decoded >= format.Samples(s.interval) {
+			cur.decoded = len(pcm)
+			if !s.decode(cur, pcm, false, opts) {
+				return
+			}
+		}
+	}
+}
+
+// decode transcribes pcm and emits the result for u. It reports false if
+// the stream must stop.
+func (s *stream) decode(u *utterance, pcm []float32, final bool, opts decodeOptions) bool {
+	heard := format.Duration(len(pcm))
+	if len(pcm) < format.Samples(minDecode) {
+		pcm = append(pcm[:len(pcm):len(pcm)], make([]float32, format.Samples(minDecode)-len(pcm))...)
+	}
+	start := time.Now()
+	segs, err := s.dec.decode(s.ctx, pcm, opts)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.err = fmt.Errorf("whisper: decode: %w", err)
+			s.log.Error("whisper decode failed", "utterance", u.id, "error", err)
+		}
+		return false
+	}
+	s.log.Debug("whisper decoded", "utterance", u.id, "final", final,
+		"audio", heard, "took", time.Since(start))
+	seg := transcript(segs, u.start)
+	if final && seg.Text == "" && u.rev == 0 {
+		return true // silence; nothing to commit or retract
+	}
+	u.rev++
+	seg.UtteranceID = u.id
+	seg.Revision = u.rev
+	seg.Final = final
+	if final {
+		seg.Stability = 1
+	} else {
+		seg.Stability = u.stab.Score(seg.Text)
+	}
+	// Padding is silence; nothing said in it extends past the audio.
+	if end := u.start + heard; seg.End == seg.Start || seg.End > end {
+		seg.End = end
+		for i := range seg.Words {
+			seg.Words[i].Start = min(seg.Words[i].Start, end)
+			seg.Words[i].End = min(seg.Words[i].End, end)
+		}
+	}
+	select {
+	case s.results <- seg:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// transcript joins the segments of one decode into an utterance hypothesis
+// on the stream clock. Non-speech annotations such as "[BLANK_AUDIO]" or
+// "(music)" are dropped.
+func transcript(segs []segment, offset time.Duration) stt.Segment {
+	var (
+		seg   = stt.Segment{Start: offset, End: offset}
+		texts []string
+		sum   float32
+		n     int
+	)
+	for _, sg := range segs {
+		text := strings.TrimSpace(sg.text)
+		if text == "" || annotation(text) {
+			continue
+		}
+		if len(texts) == 0 {
+			seg.Start = offset + sg.start
+			seg.Language = sg.language
+		}
+		seg.End = offset + sg.end
+		texts = append(texts, text)
+		for _, w := range words(sg.tokens) {
+			if annotation(w.Text) {
+				continue
+			}
+			w.Start += offset
+			w.End += offset
+			seg.Words = append(seg.Words, w)
+		}
+		for _, t := range sg.tokens {
+			sum += t.p
+			n++
+		}
+	}
+	seg.Text = strings.Join(texts, " ")
+	if n > 0 {
+		seg.Confidence = sum / float32(n)
+	}
+	return seg
+}
+
+// words merges tokens into words: a token starting with a space starts a
+// new word. A word's confidence is its least likely token.
+func words(toks []token) []stt.Word {
+	var ws []stt.Word
+	for _, t := range toks {
+		if t.text == "" {
+			continue
+		}
+		if len(ws) == 0 || strings.HasPrefix(t.text, " ") {
+			ws = append(ws, stt.Word{Start: t.start, End: t.end, Confidence: t.p})
+		}
+		w := &ws[len(ws)-1]
+		w.Text += t.text
+		w.End = max(w.End, t.end)
+		w.Confidence = min(w.Confidence, t.p)
+	}
+	out := ws[:0]
+	for _, w := range ws {
+		if w.Text = strings.TrimSpace(w.Text); w.Text != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// annotation reports whether text is a bracketed non-speech marker.
+func annotation(text string) bool {
+	return len(text) >= 2 && (text[0] == '[' && text[len(text)-1] == ']' ||
+		text[0] == '(' && text[len(text)-1] == ')' ||
+		text[0] == '*' && text[len(text)-1] == '*')
+}
+
+func newUtteranceID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// enabledFeatures returns the CPU features whisper_print_system_info
+// reports whisper.cpp built with, as "AVX2 = 1 | FMA = 1 | ...".
+func enabledFeatures(info string) []string {
+	var out []string
+	for _, f := range strings.FieldsFunc(info, func(r rune) bool { return r == '|' || r == ':' }) {
+		name, on, ok := strings.Cut(f, "=")
+		if ok && strings.TrimSpace(on) == "1" {
+			out = append(out, strings.TrimSpace(name))
+		}
+	}
+	return out
+}
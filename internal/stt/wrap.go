@@ -0,0 +1,145 @@
+package stt
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/cost"
+	"github.com/jmarc101/voxa/internal/langid"
+	"github.com/jmarc101/voxa/internal/ratelimit"
+)
+
+// wrapped is a Provider accounting its use: its streams are opened within
+// the limits of its account, per Config.RateLimit, opening one taking from
+// the rate and holding a slot of the concurrency until it is closed, and
+// the audio written to them is recorded on the cost.Meter of their
+// context, if any.
+type wrapped struct {
+	p     Provider
+	name  string
+	limit *ratelimit.Limiter // nil without Config.RateLimit
+}
+
+// wrap wraps p, built from cfg, keeping the optional interfaces of p.
+func wrap(cfg Config, p Provider) (Provider, error) {
+	w := &wrapped{p: p, name: cfg.Provider}
+	if cfg.RateLimit != nil {
+		rc := *cfg.RateLimit
+		if rc.Key == "" {
+			rc.Key = cfg.Provider
+		}
+		var err error
+		if w.limit, err = ratelimit.Shared(rc); err != nil {
+			return nil, err
+		}
+	}
+	if id, ok := p.(langid.Identifier); ok {
+		return &wrappedIdentifier{wrapped: w, id: id}, nil
+	}
+	return w, nil
+}
+
+// acquire waits for the limits, if any.
+func (w *wrapped) acquire(ctx context.Context) (release func(), err error) {
+	if w.limit == nil {
+		return func() {}, nil
+	}
+	return w.limit.Acquire(ctx)
+}
+
+func (w *wrapped) NewStream(ctx context.Context, cfg StreamConfig) (StreamingRecognizer, error) {
+	release, err := w.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := w.p.NewStream(ctx, cfg)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	m := cost.FromContext(ctx)
+	if w.limit == nil && m == nil {
+		return rec, nil
+	}
+	return &wrappedStream{StreamingRecognizer: rec, release: release, meter: m, name: w.name, rate: cfg.SampleRate}, nil
+}
+
+// RequiredFormat implements FormatRequirer with the format of the provider,
+// if it requires one.
+func (w *wrapped) RequiredFormat() audio.Format {
+	if r, ok := w.p.(FormatRequirer); ok {
+		return r.RequiredFormat()
+	}
+	return audio.Format{}
+}
+
+// BiasesPhrases implements PhraseBiaser for providers that do.
+func (w *wrapped) BiasesPhrases() bool {
+	b, ok := w.p.(PhraseBiaser)
+	return ok && b.BiasesPhrases()
+}
+
+// Overrides implements StreamOverrider with what the provider overrides.
+func (w *wrapped) Overrides() (language, model bool) {
+	if o, ok := w.p.(StreamOverrider); ok {
+		return o.Overrides()
+	}
+	return false, false
+}
+
+// Close closes the provider if it needs it.
+func (w *wrapped) Close() error {
+	if c, ok := w.p.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// wrappedIdentifier is a wrapped provider that identifies languages, each
+// call taking from the limits as a stream does.
+type wrappedIdentifier struct {
+	*wrapped
+	id langid.Identifier
+}
+
+func (w *wrappedIdentifier) Identify(ctx context.Context, fr audio.Frame) ([]langid.Guess, error) {
+	release, err := w.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return w.id.Identify(ctx, fr)
+}
+
+// wrappedStream meters the audio written to it, and returns its slot of
+// the concurrency once closed.
+type wrappedStream struct {
+	StreamingRecognizer
+	release func()
+	meter   *cost.Meter
+	name    string
+	rate    int
+}
+
+func (s *wrappedStream) Write(p []byte) (int, error) {
+	n, err := s.StreamingRecognizer.Write(p)
+	if s.meter != nil && s.rate > 0 {
+		s.meter.Audio(s.name, time.Duration(int64(n/2)*int64(time.Second)/int64(s.rate)))
+	}
+	return n, err
+}
+
+func (s *wrappedStream) Close() error {
+	defer s.release()
+	return s.StreamingRecognizer.Close()
+}
+
+// SetLanguage implements LanguageSetter for backends that support it.
+func (s *wrappedStream) SetLanguage(lang string) error {
+	if ls, ok := s.StreamingRecognizer.(LanguageSetter); ok {
+		return ls.SetLanguage(lang)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package openai is a summarization backend for the chat completions API
+// of OpenAI, which most self-hosted model servers also speak: Ollama, vLLM
+// and the llama.cpp server among them, so transcripts need not leave the
+// network.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/summarize"
+)
+
+// ProviderName is the name the backend registers under. Its options are
+// "endpoint", "model", "api_key" and "temperature".
+const ProviderName = "openai"
+
+// Defaults.
+const (
+	DefaultEndpoint = "https://api.openai.com/v1"
+	DefaultModel    = "gpt-4o-mini"
+)
+
+func init() {
+	summarize.Register(ProviderName, func(cfg summarize.Config) (summarize.LLM, error) {
+		c := Config{
+			Endpoint: cfg.Option("endpoint", DefaultEndpoint),
+			Model:    cfg.Option("model", DefaultModel),
+			APIKey:   cfg.Option("api_key", os.Getenv("OPENAI_API_KEY")),
+			Logger:   cfg.Logger,
+		}
+		if t := cfg.Option("temperature", ""); t != "" {
+			v, err := strconv.ParseFloat(t, 64)
+			if err != nil || v < 0 || v > 2 {
+				return nil, fmt.Errorf("bad temperature %q, want a number in [0, 2]", t)
+			}
+			c.Temperature = &v
+		}
+		return New(c)
+	})
+}
+
+// Config configures the client.
+type Config struct {
+	// Endpoint is the base URL of the API, up to and including its version
+	// path: https://api.openai.com/v1, or http://localhost:11434/v1 for a
+	// local Ollama.
+	Endpoint string
+	// Model is the model asked.
+	Model string
+	// APIKey is sent as a bearer token, if set; local servers need none.
+	APIKey string
+	// Temperature, if set, is sent with every request; servers default it.
+	Temperature *float64
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Logger receives the client's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Client calls a chat completions API.
+type Client struct {
+	cfg Config
+}
+
+// New validates cfg.
+func New(cfg Config) (*Client, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+	if !strings.HasPrefix(cfg.Endpoint, "http://") && !strings.HasPrefix(cfg.Endpoint, "https://") {
+		return nil, fmt.Errorf("bad endpoint %q", cfg.Endpoint)
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	if cfg.Model == "" {
+		cfg.Model = DefaultModel
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	return &Client{cfg: cfg}, nil
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type request struct {
+	Model       string    `json:"model"`
+	Messages    []message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+}
+
+type response struct {
+	Choices []struct {
+		Message message `json:"message"`
+	} `json:"choices"`
+}
+
+// Complete implements summarize.LLM (POST /chat/completions).
+func (c *Client) Complete(ctx context.Context, system, prompt string) (string, error) {
+	b, err := json.Marshal(request{
+		Model:       c.cfg.Model,
+		Messages:    []message{{Role: "system", Content: system}, {Role: "user", Content: prompt}},
+		Temperature: c.cfg.Temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.Endpoint+"/chat/completions", bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+	start := time.Now()
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("openai: %w", err)
+	}
+	defer resp.Body.Close()
+	c.cfg.Logger.Debug("openai request", "model", c.cfg.Model, "chars", len(prompt),
+		"status", resp.StatusCode, "took", time.Since(start))
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("openai: %w", &resilience.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(msg))})
+	}
+	var out response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&out); err != nil {
+		return "", fmt.Errorf("openai: bad response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", errors.New("openai: no choice in response")
+	}
+	return out.Choices[0].Message.Content, nil
+}
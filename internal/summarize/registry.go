@@ -0,0 +1,78 @@
+package summarize
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/logging"
+)
+
+// Config selects a registered provider and passes it backend options.
+type Config struct {
+	// Provider is the name the backend was registered under.
+	Provider string
+	// Options are backend-specific settings, e.g. "endpoint", "model" or
+	// "api_key".
+	Options map[string]string
+	// Logger receives the backend's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Option returns the named option or def when unset.
+func (c Config) Option(name, def string) string {
+	if v, ok := c.Options[name]; ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// Factory builds an LLM backend from its configuration.
+type Factory func(cfg Config) (LLM, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available under name. It is meant to be called
+// from the backend's init function and panics if name is already taken or
+// factory is nil.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("summarize: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("summarize: Register called twice for provider " + name)
+	}
+	registry[name] = factory
+}
+
+// New instantiates the provider selected by cfg.Provider.
+func New(cfg Config) (LLM, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Provider]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("summarize: unknown provider %q (registered: %v)", cfg.Provider, Providers())
+	}
+	p, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("summarize: %s: %w", cfg.Provider, err)
+	}
+	return p, nil
+}
+
+// Providers returns the sorted names of the registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
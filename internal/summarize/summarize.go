@@ -0,0 +1,189 @@
+// Package summarize summarizes the transcripts of sessions once they have
+// ended, for call notes and follow-ups without anyone reading the whole
+// conversation.
+//
+// A Summarizer is a post-session stage: it receives the final segments of
+// a session and returns a summary with the action items agreed on. A Stage
+// is one asking a large language model; models are reached through
+// backends registered by name, like translators.
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Summary is what a Summarizer makes of a transcript.
+type Summary struct {
+	Text string
+	// ActionItems are the follow-ups agreed on, if any.
+	ActionItems []string
+}
+
+// Summarizer summarizes the transcripts of completed sessions.
+type Summarizer interface {
+	// Summarize summarizes transcript, the final segments of a session in
+	// order.
+	Summarize(ctx context.Context, transcript []stt.Segment) (Summary, error)
+}
+
+// LLM is a large language model behind a backend.
+type LLM interface {
+	// Complete returns the model's reply to prompt, following the system
+	// instructions.
+	Complete(ctx context.Context, system, prompt string) (string, error)
+}
+
+// DefaultInstructions are the system instructions of a Stage that sets
+// none. The reply is parsed as the JSON object they describe.
+const DefaultInstructions = `You summarize transcripts of conversations, such as customer calls and meetings.
+Reply with a JSON object and nothing else:
+{"summary": "a few sentences on what was discussed and decided", "action_items": ["one follow-up per item, naming who does it when the transcript says"]}
+Write in the language of the transcript. Leave action_items empty when nothing was agreed. Do not invent facts the transcript does not state.`
+
+// DefaultMaxChars bounds the transcript sent to the model of a Stage that
+// sets no bound.
+const DefaultMaxChars = 32000
+
+// StageConfig configures a Stage.
+type StageConfig struct {
+	// Provider and Options select the backend, as in Config.
+	Provider string
+	Options  map[string]string
+	// Instructions replace DefaultInstructions, such as to ask for a
+	// summary in a house style. The reply must still be the JSON object
+	// they describe, or plain text taken as the summary.
+	Instructions string
+	// MaxChars bounds the transcript sent to the model; the middle of
+	// longer ones is left out. Defaults to DefaultMaxChars.
+	MaxChars int
+	// Logger receives the backend's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// ErrEmpty is returned for transcripts without any text.
+var ErrEmpty = errors.New("summarize: empty transcript")
+
+// Stage summarizes transcripts with a language model. It is safe for
+// concurrent use if its backend is.
+type Stage struct {
+	llm          LLM
+	instructions string
+	maxChars     int
+}
+
+// NewStage instantiates the configured backend.
+func NewStage(cfg StageConfig) (*Stage, error) {
+	if cfg.MaxChars < 0 {
+		return nil, fmt.Errorf("summarize: negative max chars %d", cfg.MaxChars)
+	}
+	if cfg.MaxChars == 0 {
+		cfg.MaxChars = DefaultMaxChars
+	}
+	if strings.TrimSpace(cfg.Instructions) == "" {
+		cfg.Instructions = DefaultInstructions
+	}
+	llm, err := New(Config{Provider: cfg.Provider, Options: cfg.Options, Logger: cfg.Logger})
+	if err != nil {
+		return nil, err
+	}
+	return &Stage{llm: llm, instructions: cfg.Instructions, maxChars: cfg.MaxChars}, nil
+}
+
+// Summarize implements Summarizer.
+func (s *Stage) Summarize(ctx context.Context, transcript []stt.Segment) (Summary, error) {
+	text := Render(transcript)
+	if text == "" {
+		return Summary{}, ErrEmpty
+	}
+	reply, err := s.llm.Complete(ctx, s.instructions, "Transcript:\n\n"+shorten(text, s.maxChars))
+	if err != nil {
+		return Summary{}, fmt.Errorf("summarize: %w", err)
+	}
+	sum, err := parse(reply)
+	if err != nil {
+		return Summary{}, fmt.Errorf("summarize: %w", err)
+	}
+	return sum, nil
+}
+
+// Render writes a transcript as the model reads it: a line per segment,
+// with its start time and speaker when known.
+//
+//	[01:25] S1: I'd like to move my appointment to Friday.
+func Render(transcript []stt.Segment) string {
+	var b strings.Builder
+	for _, seg := range transcript {
+		text := strings.TrimSpace(seg.Text)
+		if text == "" {
+			continue
+		}
+		t := seg.Start.Truncate(time.Second)
+		fmt.Fprintf(&b, "[%02d:%02d] ", int(t.Minutes()), int(t.Seconds())%60)
+		if seg.Speaker != "" {
+			b.WriteString(seg.Speaker + ": ")
+		}
+		b.WriteString(text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// shorten leaves the middle out of text longer than n bytes, keeping whole
+// lines at both ends: openings and conclusions matter most to a summary.
+func shorten(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	const gap = "[…]\n"
+	head := text[:n/2]
+	if i := strings.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i+1]
+	}
+	tail := text[len(text)-n/2:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+	return head + gap + tail
+}
+
+type reply struct {
+	Summary     *string  `json:"summary"`
+	ActionItems []string `json:"action_items"`
+}
+
+// parse reads the summary out of a reply: the JSON object of the
+// instructions, possibly in a code fence or among other text, or else the
+// whole reply as plain text.
+func parse(text string) (Summary, error) {
+	text = strings.TrimSpace(text)
+	if i, j := strings.IndexByte(text, '{'), strings.LastIndexByte(text, '}'); i >= 0 && j > i {
+		var r reply
+		if err := json.Unmarshal([]byte(text[i:j+1]), &r); err == nil && r.Summary != nil {
+			sum := Summary{Text: strings.TrimSpace(*r.Summary)}
+			for _, a := range r.ActionItems {
+				if a = strings.TrimSpace(a); a != "" {
+					sum.ActionItems = append(sum.ActionItems, a)
+				}
+			}
+			if sum.Text == "" {
+				return Summary{}, errors.New("empty summary")
+			}
+			return sum, nil
+		}
+	}
+	switch {
+	case text == "":
+		return Summary{}, errors.New("empty reply")
+	case strings.HasPrefix(text, "{") || strings.HasPrefix(text, "```"):
+		return Summary{}, errors.New("reply is not the JSON object asked for")
+	}
+	return Summary{Text: text}, nil
+}
@@ -0,0 +1,460 @@
+package tn
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// number is a written number.
+type number struct {
+	neg       bool
+	int, frac string // digits, frac empty for an integer
+}
+
+func (n number) value() float64 {
+	s := n.int
+	if n.frac != "" {
+		s += "." + n.frac
+	}
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// integer returns the value of an integer, false if n has decimals or is
+// too long to be read as a number.
+func (n number) integer() (int64, bool) {
+	if n.frac != "" || len(n.int) > 15 {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(n.int, 10, 64)
+	return v, err == nil
+}
+
+// numberRE matches a number at the start of a word, with the group
+// separators between groups of three digits.
+func numberRE(group, decimal string) *regexp.Regexp {
+	g, d := regexp.QuoteMeta(group), regexp.QuoteMeta(decimal)
+	return regexp.MustCompile(`^([-−]?)(\d{1,3}(?:` + g + `\d{3})+|\d+)(?:` + d + `(\d+))?`)
+}
+
+// parse reads the number s starts with, returning what follows it.
+func (l *locale) parse(s string) (number, string, bool) {
+	m := l.numberRE.FindStringSubmatch(s)
+	if m == nil {
+		return number{}, s, false
+	}
+	return number{neg: m[1] != "", int: strings.ReplaceAll(m[2], l.group, ""), frac: m[3]}, s[len(m[0]):], true
+}
+
+var threeDigits = regexp.MustCompile(`^\d{3}`)
+
+// numberAt reads the number ts starts with, over the k tokens it spans,
+// returning what follows it in the last. French writes the groups of
+// numbers apart: "1 024".
+func (l *locale) numberAt(ts []token) (n number, k int, rest string, ok bool) {
+	n, rest, ok = l.parse(ts[0].core)
+	if !ok {
+		return n, 0, rest, false
+	}
+	k = 1
+	if l.group == " " && n.frac == "" && rest == "" && len(n.int) <= 3 {
+		for k < len(ts) && tight(ts[k-1:], 2) && threeDigits.MatchString(ts[k].core) {
+			next, r, _ := l.parse(ts[k].core)
+			if len(next.int) != 3 {
+				break
+			}
+			n.int += next.int
+			n.frac, rest = next.frac, r
+			k++
+			if n.frac != "" || rest != "" {
+				break
+			}
+		}
+	}
+	return n, k, rest, true
+}
+
+// spell reads n as a number.
+func (l *locale) spell(n number) string {
+	s := l.plain(n.int)
+	if n.frac != "" {
+		s += " " + l.point + " "
+		switch zeros := len(n.frac) - len(strings.TrimLeft(n.frac, "0")); {
+		case l.digitDecimals || len(n.frac) > 3 || zeros == len(n.frac):
+			s += l.digits(n.frac)
+		default:
+			tail := n.frac[zeros:]
+			if zeros > 0 {
+				s += l.digits(n.frac[:zeros]) + " "
+			}
+			s += l.plain(tail)
+		}
+	}
+	if n.neg {
+		s = l.minus + " " + s
+	}
+	return s
+}
+
+// count reads n before a noun of the given gender, followed by the noun
+// in the singular or plural.
+func (l *locale) count(n number, fem bool, one, many string) string {
+	s := l.spell(n)
+	if v, ok := n.integer(); ok {
+		s = l.counted(v, fem)
+		if n.neg {
+			s = l.minus + " " + s
+		}
+	}
+	if l.plural(n.value()) {
+		return s + " " + many
+	}
+	return s + " " + one
+}
+
+func matchAbbrev(l *locale, ts []token, _ string) match {
+	t := ts[0]
+	if t.core == "&" {
+		return match{k: 1, text: l.and}
+	}
+	key, a, ok := t.core, abbrev{}, false
+	if strings.HasPrefix(t.trail, ".") {
+		a, ok = l.abbrevs[key+"."]
+	}
+	if !ok {
+		if a, ok = l.abbrevs[key]; !ok {
+			return match{}
+		}
+		key = ""
+	}
+	if a.beforeNumber && (len(ts) < 2 || !startsDigit(ts[1].core) || ts[1].lead != "" || strings.Trim(t.trail, ".") != "") {
+		return match{}
+	}
+	m := match{k: 1, text: a.spoken}
+	if key != "" {
+		m.eat = dot(ts, 1, a.title || a.beforeNumber)
+	}
+	return m
+}
+
+func startsDigit(s string) bool {
+	c, _ := utf8.DecodeRuneInString(s)
+	return c >= '0' && c <= '9'
+}
+
+var (
+	isoDate       = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
+	numericDate   = regexp.MustCompile(`^(\d{1,2})([/.])(\d{1,2})[/.](\d{4}|\d{2})$`)
+	shortDate     = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})$`)
+	dayRE         = regexp.MustCompile(`^(\d{1,2})(st|nd|rd|th)?$`)
+	yearRE        = regexp.MustCompile(`^\d{4}$`)
+	decadeRE      = regexp.MustCompile(`^(\d{3}0)s$`)
+	shortDecadeRE = regexp.MustCompile(`^([1-9]0)s$`)
+)
+
+func atoi(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+func validDate(month, day int64) bool {
+	return month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
+
+func matchDate(l *locale, ts []token, prev string) match {
+	core := ts[0].core
+	if m := isoDate.FindStringSubmatch(core); m != nil {
+		if month, day := atoi(m[2]), atoi(m[3]); validDate(month, day) {
+			return match{k: 1, text: l.date(l, month, day, atoi(m[1]))}
+		}
+		return match{}
+	}
+	if m := numericDate.FindStringSubmatch(core); m != nil && (m[2] == "/" || l.dayFirst) {
+		a, b, year := atoi(m[1]), atoi(m[3]), atoi(m[4])
+		if len(m[4]) == 2 {
+			year += 2000
+			if year > 2069 {
+				year -= 100
+			}
+		}
+		month, day := a, b
+		if l.dayFirst {
+			month, day = b, a
+		}
+		if validDate(month, day) {
+			return match{k: 1, text: l.date(l, month, day, year)}
+		}
+		return match{}
+	}
+	if m := shortDate.FindStringSubmatch(core); m != nil && l.dateWords[prev] {
+		month, day := atoi(m[1]), atoi(m[2])
+		if l.dayFirst {
+			month, day = day, month
+		}
+		if validDate(month, day) {
+			return match{k: 1, text: l.date(l, month, day, 0)}
+		}
+		return match{}
+	}
+	if l.year != nil {
+		y := ""
+		if m := decadeRE.FindStringSubmatch(core); m != nil {
+			y = l.year(atoi(m[1]))
+		}
+		// The '90s, the 90s.
+		short := strings.HasSuffix(ts[0].lead, "'") || strings.HasSuffix(ts[0].lead, "‘") || strings.HasSuffix(ts[0].lead, "’")
+		if m := shortDecadeRE.FindStringSubmatch(core); m != nil && (short || prev == "the") {
+			y = l.cardinal(atoi(m[1]))
+		}
+		if y != "" {
+			if strings.HasSuffix(y, "y") {
+				y = strings.TrimSuffix(y, "y") + "ie"
+			}
+			return match{k: 1, text: y + "s", bare: short}
+		}
+		if yearRE.MatchString(core) && l.yearWords[prev] {
+			if y := atoi(core); y >= 1000 && y < 3000 {
+				return match{k: 1, text: l.year(y)}
+			}
+		}
+	}
+	if l.monthNames == nil || len(ts) < 2 {
+		return match{}
+	}
+	month, name, eat := l.month(ts[0])
+	if month > 0 && ts[0].trail[eat:] == "" && ts[1].lead == "" {
+		// March 14[, 2024] or March 2024.
+		if d := dayRE.FindStringSubmatch(ts[1].core); d != nil && validDate(month, atoi(d[1])) {
+			year, k := l.yearAfter(ts, 2)
+			return match{k: k, text: l.date(l, month, atoi(d[1]), year)}
+		}
+		if yearRE.MatchString(ts[1].core) {
+			return match{k: 2, text: name + " " + l.year(atoi(ts[1].core))}
+		}
+		return match{}
+	}
+	// 14 March[ 2024].
+	d := dayRE.FindStringSubmatch(core)
+	if d == nil || ts[0].trail != "" || ts[1].lead != "" {
+		return match{}
+	}
+	if month, _, eat := l.month(ts[1]); month > 0 && validDate(month, atoi(d[1])) {
+		year, k := l.yearAfter(ts, 2)
+		m := match{k: k, text: l.date(l, month, atoi(d[1]), year)}
+		if k == 2 && eat > 0 {
+			m.eat = dot(ts, 2, false)
+		}
+		return m
+	}
+	return match{}
+}
+
+// month returns the month t names, or 0, its name and the length of the
+// period of an abbreviation such as "Jan.".
+func (l *locale) month(t token) (int64, string, int) {
+	if m := l.monthNames[t.core]; m > 0 {
+		return int64(m), l.months[m-1], 0
+	}
+	if strings.HasPrefix(t.trail, ".") {
+		if m := l.monthNames[t.core+"."]; m > 0 {
+			return int64(m), l.months[m-1], 1
+		}
+	}
+	return 0, "", 0
+}
+
+// yearAfter returns the year at ts[i], after a date and maybe a comma, and
+// the tokens the date spans.
+func (l *locale) yearAfter(ts []token, i int) (int64, int) {
+	if i < len(ts) && strings.TrimPrefix(ts[i-1].trail, ",") == "" && ts[i].lead == "" && yearRE.MatchString(ts[i].core) {
+		return atoi(ts[i].core), i + 1
+	}
+	return 0, i
+}
+
+var (
+	clockRE   = regexp.MustCompile(`(?i)^(\d{1,2}):(\d{2})(?:([ap])\.?m\.?)?$`)
+	hourAMPM  = regexp.MustCompile(`(?i)^(\d{1,2})([ap])\.?m\.?$`)
+	ampmRE    = regexp.MustCompile(`(?i)^([ap])\.?m\.?$`)
+	hourRE    = regexp.MustCompile(`^\d{1,2}$`)
+	minuteRE  = regexp.MustCompile(`^\d{2}$`)
+	hourMarks = map[string]*regexp.Regexp{"h": regexp.MustCompile(`^(\d{1,2})h(\d{2})?$`)}
+)
+
+func matchTime(l *locale, ts []token, _ string) match {
+	core := ts[0].core
+	var hour, minute int64
+	ampm, k := "", 1
+	switch m := clockRE.FindStringSubmatch(core); {
+	case m != nil:
+		hour, minute, ampm = atoi(m[1]), atoi(m[2]), m[3]
+	case hourAMPM.MatchString(core):
+		m := hourAMPM.FindStringSubmatch(core)
+		hour, ampm = atoi(m[1]), m[2]
+	case l.hourMark != "" && hourMarks[l.hourMark].MatchString(core):
+		m := hourMarks[l.hourMark].FindStringSubmatch(core)
+		hour, minute = atoi(m[1]), atoi(m[2])
+	case hourRE.MatchString(core) && tight(ts, 2) && l.hourMark != "" && ts[1].core == l.hourMark:
+		// 15 h 30
+		hour, k = atoi(core), 2
+		if tight(ts, 3) && minuteRE.MatchString(ts[2].core) {
+			minute, k = atoi(ts[2].core), 3
+		}
+	case hourRE.MatchString(core) && tight(ts, 2) && ampmRE.MatchString(ts[1].core):
+		hour = atoi(core)
+	default:
+		return match{}
+	}
+	if ampm == "" && tight(ts, k+1) && ampmRE.MatchString(ts[k].core) {
+		ampm = ampmRE.FindStringSubmatch(ts[k].core)[1]
+		k++
+	}
+	if ampm != "" {
+		ampm = strings.ToLower(ampm) + "m"
+		if hour < 1 || hour > 12 {
+			return match{}
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return match{}
+	}
+	m := match{k: k, text: l.clock(l, hour, minute, ampm)}
+	if ampm != "" {
+		m.eat = dot(ts, k, false)
+	}
+	return m
+}
+
+var moneyPrefix = regexp.MustCompile(`^([-−]?)(US\$|[$€£¥])(.+)$`)
+
+func matchMoney(l *locale, ts []token, _ string) match {
+	if m := moneyPrefix.FindStringSubmatch(ts[0].core); m != nil {
+		cur, ok := l.currencies[strings.TrimPrefix(m[2], "US")]
+		if m[2] == "US$" {
+			cur, ok = l.currencies["USD"]
+		}
+		n, rest, parsed := l.parse(m[3])
+		if !ok || !parsed || rest != "" || n.neg {
+			return match{}
+		}
+		n.neg = m[1] != ""
+		return match{k: 1, text: l.money(n, cur)}
+	}
+	n, k, rest, ok := l.numberAt(ts)
+	if !ok {
+		return match{}
+	}
+	if rest == "" && tight(ts, k+1) {
+		rest = ts[k].core
+		k++
+	}
+	cur, ok := l.currencies[rest]
+	if !ok {
+		return match{}
+	}
+	return match{k: k, text: l.money(n, cur)}
+}
+
+// money reads an amount of cur: its units and subunits when written with
+// two decimals.
+func (l *locale) money(n number, cur money) string {
+	var s string
+	if len(n.frac) == 2 && cur.minorOne != "" {
+		units, cents := n, number{int: n.frac}
+		units.frac, units.neg = "", false
+		var ws []string
+		if units.int != "0" || cents.int == "00" {
+			ws = append(ws, l.count(units, cur.fem, cur.one, cur.many))
+		}
+		if cents.int != "00" {
+			cents.int = strings.TrimPrefix(cents.int, "0")
+			if len(ws) > 0 {
+				ws = append(ws, l.moneyConj)
+			}
+			ws = append(ws, l.count(cents, false, cur.minorOne, cur.minorMany))
+		}
+		s = strings.Join(ws, " ")
+		if n.neg {
+			s = l.minus + " " + s
+		}
+		return s
+	}
+	return l.count(n, cur.fem, cur.one, cur.many)
+}
+
+func matchMeasure(l *locale, ts []token, _ string) match {
+	n, k, rest, ok := l.numberAt(ts)
+	if !ok {
+		return match{}
+	}
+	if rest == "" {
+		if !tight(ts, k+1) {
+			return match{}
+		}
+		rest = ts[k].core
+		k++
+	}
+	u, ok := l.units[rest]
+	if !ok {
+		return match{}
+	}
+	return match{k: k, text: l.count(n, u.fem, u.one, u.many)}
+}
+
+func matchPercent(l *locale, ts []token, _ string) match {
+	n, k, rest, ok := l.numberAt(ts)
+	if !ok {
+		return match{}
+	}
+	if rest == "" && tight(ts, k+1) {
+		rest = ts[k].core
+		k++
+	}
+	if rest != "%" {
+		return match{}
+	}
+	return match{k: k, text: l.spell(n) + " " + l.percent}
+}
+
+func matchOrdinal(l *locale, ts []token, _ string) match {
+	m := l.ordinalRE.FindStringSubmatch(ts[0].core)
+	if m == nil || len(m[1]) > 15 {
+		return match{}
+	}
+	n := atoi(m[1])
+	if n < 1 {
+		return match{}
+	}
+	fem := l.femOrdinal != nil && l.femOrdinal(m[2])
+	return match{k: 1, text: l.ordinal(n, fem)}
+}
+
+func matchDecimal(l *locale, ts []token, _ string) match {
+	n, k, rest, ok := l.numberAt(ts)
+	if !ok || rest != "" || n.frac == "" {
+		return match{}
+	}
+	return match{k: k, text: l.spell(n)}
+}
+
+var (
+	rangeRE = regexp.MustCompile(`^(\d+)[-–](\d+)$`)
+	hashRE  = regexp.MustCompile(`^#(\d+)$`)
+)
+
+func matchCardinal(l *locale, ts []token, _ string) match {
+	core := ts[0].core
+	if m := rangeRE.FindStringSubmatch(core); m != nil {
+		return match{k: 1, text: l.plain(m[1]) + " " + l.to + " " + l.plain(m[2])}
+	}
+	if m := hashRE.FindStringSubmatch(core); m != nil {
+		return match{k: 1, text: l.number + " " + l.plain(m[1])}
+	}
+	n, k, rest, ok := l.numberAt(ts)
+	if !ok || rest != "" || n.frac != "" {
+		return match{}
+	}
+	return match{k: k, text: l.spell(n)}
+}
@@ -0,0 +1,127 @@
+package tn
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Lexicon maps written forms to how they are spoken: "kubectl" to "cube
+// control", "SQL" to "sequel". A form is one word or several, matched as
+// whole words. Forms written in lower case match in any case; the others
+// only as written, so "US" is not "us".
+type Lexicon map[string]string
+
+var (
+	lexiconsMu sync.RWMutex
+	lexicons   = map[string]Lexicon{}
+)
+
+// RegisterLexicon makes lex available to every Normalizer under name, for
+// Config.Lexicons to apply. It is meant to be called from an init function
+// and panics if name is already taken or lex is empty.
+func RegisterLexicon(name string, lex Lexicon) {
+	lexiconsMu.Lock()
+	defer lexiconsMu.Unlock()
+	if len(lex) == 0 {
+		panic("tn: RegisterLexicon lexicon is empty")
+	}
+	if _, dup := lexicons[name]; dup {
+		panic("tn: RegisterLexicon called twice for lexicon " + name)
+	}
+	c := make(Lexicon, len(lex))
+	for k, v := range lex {
+		c[k] = v
+	}
+	lexicons[name] = c
+}
+
+// Lexicons returns the sorted names of the registered lexicons.
+func Lexicons() []string {
+	lexiconsMu.RLock()
+	defer lexiconsMu.RUnlock()
+	names := make([]string, 0, len(lexicons))
+	for name := range lexicons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lexicon is the entries a Normalizer applies, by their words joined with
+// single spaces.
+type lexicon struct {
+	exact map[string]string
+	fold  map[string]string // by lower-case form
+	words int               // most words in a form
+}
+
+// compile merges the registered lexicons names and then own.
+func compile(names []string, own Lexicon) (*lexicon, error) {
+	lex := &lexicon{exact: map[string]string{}, fold: map[string]string{}}
+	add := func(from string, l Lexicon) error {
+		for form, spoken := range l {
+			ws := strings.Fields(form)
+			if len(ws) == 0 || strings.TrimSpace(spoken) == "" {
+				return fmt.Errorf("tn: lexicon %s: empty entry %q: %q", from, form, spoken)
+			}
+			key := strings.Join(ws, " ")
+			if key == strings.ToLower(key) {
+				lex.fold[key] = spoken
+			} else {
+				lex.exact[key] = spoken
+			}
+			lex.words = max(lex.words, len(ws))
+		}
+		return nil
+	}
+	lexiconsMu.RLock()
+	defer lexiconsMu.RUnlock()
+	for _, name := range names {
+		l, ok := lexicons[name]
+		if !ok {
+			return nil, fmt.Errorf("tn: unknown lexicon %q (registered: %v)", name, Lexicons())
+		}
+		if err := add(name, l); err != nil {
+			return nil, err
+		}
+	}
+	if err := add("of the config", own); err != nil {
+		return nil, err
+	}
+	return lex, nil
+}
+
+// match returns the longest entry ts starts with. The words of a form are
+// not separated by punctuation; a form ending in a period, as "approx.",
+// takes it from the trail of its last word.
+func (lex *lexicon) match(ts []token) match {
+	for k := min(lex.words, len(ts)); k > 0; k-- {
+		if !tight(ts, k) {
+			continue
+		}
+		ws := make([]string, k)
+		for i := range k {
+			ws[i] = ts[i].core
+		}
+		form := strings.Join(ws, " ")
+		if spoken, ok := lex.lookup(form); ok {
+			return match{k: k, text: spoken}
+		}
+		if strings.HasPrefix(ts[k-1].trail, ".") {
+			if spoken, ok := lex.lookup(form + "."); ok {
+				return match{k: k, text: spoken, eat: dot(ts, k, false)}
+			}
+		}
+	}
+	return match{}
+}
+
+func (lex *lexicon) lookup(form string) (string, bool) {
+	if s, ok := lex.exact[form]; ok {
+		return s, true
+	}
+	s, ok := lex.fold[strings.ToLower(form)]
+	return s, ok
+}
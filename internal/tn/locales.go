@@ -0,0 +1,785 @@
+package tn
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// locale is the rule set of one locale.
+type locale struct {
+	// cardinal spells out n >= 0.
+	cardinal func(n int64) string
+	// ordinal spells out the ordinal of n >= 1, in the feminine if fem.
+	ordinal func(n int64, fem bool) string
+	// ordinalRE matches written ordinals: the digits, then the suffix.
+	ordinalRE *regexp.Regexp
+	// femOrdinal reports whether an ordinal suffix is feminine.
+	femOrdinal func(suffix string) bool
+	// counted spells out n >= 0 before a noun, in the feminine if fem:
+	// Spanish "un kilómetro", French "une heure".
+	counted func(n int64, fem bool) string
+	// year spells out a year; nil reads it as a cardinal.
+	year func(n int64) string
+	// group and decimal separate the digits of written numbers.
+	group, decimal string
+	// digitDecimals reads the decimals digit by digit, as English does,
+	// rather than as a number.
+	digitDecimals bool
+	point, minus  string
+	percent       string
+	to            string // between the ends of a range: "10-20"
+	and           string // "&"
+	number        string // "#1"
+	// plural reports whether a count of v takes the plural.
+	plural     func(v float64) bool
+	currencies map[string]money // by symbol and ISO code
+	moneyConj  string           // between the units and subunits
+	units      map[string]unit  // by symbol
+	abbrevs    map[string]abbrev
+	months     []string
+	// monthNames maps the names of months, as written in text, to their
+	// number, for reading dates written with them; nil when the locale
+	// reads them well as they are.
+	monthNames map[string]int
+	// dayFirst reads numeric dates as day/month/year.
+	dayFirst bool
+	// date spells out a date; year is 0 when not given.
+	date func(l *locale, month, day, year int64) string
+	// clock spells out a time; ampm is "am", "pm" or empty.
+	clock func(l *locale, hour, minute int64, ampm string) string
+	// hourMark is the letter written between hours and minutes, as in
+	// French "15h30"; empty if the locale writes none.
+	hourMark string
+	// yearWords are the words after which a four-digit number is a year.
+	yearWords map[string]bool
+	// dateWords are the words after which a day and month, as "3/14", are
+	// a date rather than a fraction.
+	dateWords map[string]bool
+
+	numberRE *regexp.Regexp // set by New
+}
+
+// money is a currency the locale names.
+type money struct {
+	one, many           string
+	minorOne, minorMany string // empty without a subunit
+	fem                 bool
+}
+
+// unit is a unit of measure the locale names.
+type unit struct {
+	one, many string
+	fem       bool
+}
+
+// abbrev is an abbreviation the locale expands.
+type abbrev struct {
+	spoken string
+	// title abbreviations precede a name, so their period never ends a
+	// sentence.
+	title bool
+	// beforeNumber abbreviations only expand before a number: "No. 5".
+	beforeNumber bool
+}
+
+func split(n int64) (q, r int64) { return n / 10, n % 10 }
+
+// English.
+
+var (
+	enSmall = [...]string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	enTens   = [...]string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	enScales = []struct {
+		v    int64
+		name string
+	}{{1e12, "trillion"}, {1e9, "billion"}, {1e6, "million"}, {1e3, "thousand"}}
+)
+
+func enBelow100(n int64) string {
+	if n < 20 {
+		return enSmall[n]
+	}
+	t, u := split(n)
+	if u == 0 {
+		return enTens[t]
+	}
+	return enTens[t] + "-" + enSmall[u]
+}
+
+// englishCardinal spells out numbers, with "and" before the tens as
+// British English says them if and is set: "one hundred and five".
+func englishCardinal(and bool) func(int64) string {
+	below1000 := func(n int64) string {
+		h, r := n/100, n%100
+		var ws []string
+		if h > 0 {
+			ws = append(ws, enSmall[h], "hundred")
+			if r > 0 && and {
+				ws = append(ws, "and")
+			}
+		}
+		if r > 0 || h == 0 {
+			ws = append(ws, enBelow100(r))
+		}
+		return strings.Join(ws, " ")
+	}
+	return func(n int64) string {
+		if n == 0 {
+			return "zero"
+		}
+		var ws []string
+		for _, s := range enScales {
+			if n >= s.v {
+				ws = append(ws, below1000(n/s.v), s.name)
+				n %= s.v
+			}
+		}
+		if n > 0 {
+			if and && len(ws) > 0 && n < 100 {
+				ws = append(ws, "and")
+			}
+			ws = append(ws, below1000(n))
+		}
+		return strings.Join(ws, " ")
+	}
+}
+
+var enOrdinalWords = map[string]string{
+	"one": "first", "two": "second", "three": "third", "five": "fifth",
+	"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+}
+
+// englishOrdinal makes an ordinal of the last word of a cardinal.
+func englishOrdinal(cardinal func(int64) string) func(int64, bool) string {
+	return func(n int64, _ bool) string {
+		c := cardinal(n)
+		cut := strings.LastIndexAny(c, " -") + 1
+		head, last := c[:cut], c[cut:]
+		switch {
+		case enOrdinalWords[last] != "":
+			last = enOrdinalWords[last]
+		case strings.HasSuffix(last, "y"):
+			last = strings.TrimSuffix(last, "y") + "ieth"
+		default:
+			last += "th"
+		}
+		return head + last
+	}
+}
+
+// englishYear reads years in pairs: "nineteen ninety", "twenty twenty-four".
+func englishYear(cardinal func(int64) string) func(int64) string {
+	return func(y int64) string {
+		if y < 1000 || y > 9999 || y >= 2000 && y < 2010 || y%1000 == 0 {
+			return cardinal(y)
+		}
+		hi, lo := y/100, y%100
+		switch {
+		case lo == 0:
+			return cardinal(hi) + " hundred"
+		case lo < 10:
+			return cardinal(hi) + " oh " + cardinal(lo)
+		}
+		return cardinal(hi) + " " + cardinal(lo)
+	}
+}
+
+func englishClock(l *locale, hour, minute int64, ampm string) string {
+	s := l.cardinal(hour)
+	switch {
+	case minute == 0 && ampm == "" && hour <= 12:
+		s += " o'clock"
+	case minute == 0 && ampm == "":
+		s += " hundred"
+	case minute > 0 && minute < 10:
+		s += " oh " + l.cardinal(minute)
+	case minute > 0:
+		s += " " + l.cardinal(minute)
+	}
+	switch ampm {
+	case "am":
+		s += " a m"
+	case "pm":
+		s += " p m"
+	}
+	return s
+}
+
+var englishUnits = map[string]unit{
+	"KB": {"kilobyte", "kilobytes", false}, "kB": {"kilobyte", "kilobytes", false},
+	"MB": {"megabyte", "megabytes", false}, "GB": {"gigabyte", "gigabytes", false},
+	"TB": {"terabyte", "terabytes", false}, "PB": {"petabyte", "petabytes", false},
+	"KiB": {"kibibyte", "kibibytes", false}, "MiB": {"mebibyte", "mebibytes", false},
+	"GiB": {"gibibyte", "gibibytes", false}, "TiB": {"tebibyte", "tebibytes", false},
+	"kbps": {"kilobit per second", "kilobits per second", false}, "Kbps": {"kilobit per second", "kilobits per second", false},
+	"Mbps": {"megabit per second", "megabits per second", false}, "Gbps": {"gigabit per second", "gigabits per second", false},
+	"Hz": {"hertz", "hertz", false}, "kHz": {"kilohertz", "kilohertz", false},
+	"MHz": {"megahertz", "megahertz", false}, "GHz": {"gigahertz", "gigahertz", false},
+	"mm": {"millimeter", "millimeters", false}, "cm": {"centimeter", "centimeters", false},
+	"m": {"meter", "meters", false}, "km": {"kilometer", "kilometers", false},
+	"mi": {"mile", "miles", false}, "ft": {"foot", "feet", false}, "yd": {"yard", "yards", false},
+	"mg": {"milligram", "milligrams", false}, "g": {"gram", "grams", false}, "kg": {"kilogram", "kilograms", false},
+	"lb": {"pound", "pounds", false}, "lbs": {"pound", "pounds", false}, "oz": {"ounce", "ounces", false},
+	"ml": {"milliliter", "milliliters", false}, "mL": {"milliliter", "milliliters", false},
+	"l": {"liter", "liters", false}, "L": {"liter", "liters", false},
+	"ms": {"millisecond", "milliseconds", false}, "s": {"second", "seconds", false},
+	"min": {"minute", "minutes", false}, "h": {"hour", "hours", false},
+	"hr": {"hour", "hours", false}, "hrs": {"hour", "hours", false},
+	"km/h": {"kilometer per hour", "kilometers per hour", false}, "mph": {"mile per hour", "miles per hour", false},
+	"m/s": {"meter per second", "meters per second", false},
+	"°C":  {"degree Celsius", "degrees Celsius", false}, "°F": {"degree Fahrenheit", "degrees Fahrenheit", false},
+	"°": {"degree", "degrees", false},
+	"W": {"watt", "watts", false}, "kW": {"kilowatt", "kilowatts", false}, "MW": {"megawatt", "megawatts", false},
+	"kWh": {"kilowatt hour", "kilowatt hours", false}, "V": {"volt", "volts", false},
+	"mAh": {"milliamp hour", "milliamp hours", false},
+}
+
+var englishAbbrevs = map[string]abbrev{
+	"Mr.": {"Mister", true, false}, "Mrs.": {"Missus", true, false}, "Ms.": {"Miz", true, false},
+	"Dr.": {"Doctor", true, false}, "Prof.": {"Professor", true, false},
+	"Jr.": {"Junior", false, false}, "Sr.": {"Senior", false, false},
+	"Mt.": {"Mount", true, false}, "Ave.": {"Avenue", false, false},
+	"vs.": {"versus", false, false}, "etc.": {"et cetera", false, false},
+	"e.g.": {"for example", false, false}, "i.e.": {"that is", false, false},
+	"approx.": {"approximately", false, false},
+	"No.":     {"number", false, true}, "no.": {"number", false, true},
+}
+
+var englishMonthNames = func() map[string]int {
+	m := map[string]int{}
+	for i, name := range monthOrder["en"] {
+		m[name] = i + 1
+		if len(name) > 3 {
+			m[name[:3]] = i + 1
+			m[name[:3]+"."] = i + 1
+		}
+	}
+	m["Sept"], m["Sept."] = 9, 9
+	return m
+}()
+
+func english(and bool) locale {
+	cardinal := englishCardinal(and)
+	return locale{
+		cardinal:      cardinal,
+		ordinal:       englishOrdinal(cardinal),
+		ordinalRE:     regexp.MustCompile(`^(\d+)(st|nd|rd|th)$`),
+		counted:       func(n int64, _ bool) string { return cardinal(n) },
+		year:          englishYear(cardinal),
+		group:         ",",
+		decimal:       ".",
+		digitDecimals: true,
+		point:         "point",
+		minus:         "minus",
+		percent:       "percent",
+		to:            "to",
+		and:           "and",
+		number:        "number",
+		plural:        func(v float64) bool { return v != 1 },
+		currencies: map[string]money{
+			"$": {"dollar", "dollars", "cent", "cents", false}, "USD": {"US dollar", "US dollars", "cent", "cents", false},
+			"€": {"euro", "euros", "cent", "cents", false}, "EUR": {"euro", "euros", "cent", "cents", false},
+			"£": {"pound", "pounds", "penny", "pence", false}, "GBP": {"pound", "pounds", "penny", "pence", false},
+			"¥": {"yen", "yen", "", "", false}, "JPY": {"yen", "yen", "", "", false},
+		},
+		moneyConj:  "and",
+		units:      englishUnits,
+		abbrevs:    englishAbbrevs,
+		months:     monthOrder["en"],
+		monthNames: englishMonthNames,
+		clock:      englishClock,
+		yearWords:  set("in", "since", "from", "until", "till", "by", "of", "year", "circa", "before", "after"),
+		dateWords:  set("on", "since", "from", "until", "till", "by", "before", "after"),
+	}
+}
+
+// French.
+
+var (
+	frSmall = [...]string{"zéro", "un", "deux", "trois", "quatre", "cinq", "six", "sept", "huit", "neuf",
+		"dix", "onze", "douze", "treize", "quatorze", "quinze", "seize", "dix-sept", "dix-huit", "dix-neuf"}
+	frTens = [...]string{"", "dix", "vingt", "trente", "quarante", "cinquante", "soixante"}
+)
+
+func frBelow100(n int64) string {
+	switch {
+	case n < 20:
+		return frSmall[n]
+	case n < 70:
+		t, u := split(n)
+		switch u {
+		case 0:
+			return frTens[t]
+		case 1:
+			return frTens[t] + " et un"
+		}
+		return frTens[t] + "-" + frSmall[u]
+	case n < 80:
+		if n == 71 {
+			return "soixante et onze"
+		}
+		return "soixante-" + frSmall[n-60]
+	case n == 80:
+		return "quatre-vingts"
+	}
+	return "quatre-vingt-" + frSmall[n-80]
+}
+
+// frBelow1000 spells out n < 1000; "cents" and "quatre-vingts" lose their
+// s unless final.
+func frBelow1000(n int64, final bool) string {
+	h, r := n/100, n%100
+	var s string
+	switch {
+	case h == 1:
+		s = "cent"
+	case h > 1:
+		s = frSmall[h] + " cent"
+		if r == 0 && final {
+			s += "s"
+		}
+	}
+	if r > 0 {
+		t := frBelow100(r)
+		if r == 80 && !final {
+			t = "quatre-vingt"
+		}
+		s = strings.TrimSpace(s + " " + t)
+	}
+	return s
+}
+
+func frenchCardinal(n int64) string {
+	if n == 0 {
+		return "zéro"
+	}
+	var ws []string
+	for _, s := range []struct {
+		v          int64
+		one, other string
+	}{{1e12, "billion", "billions"}, {1e9, "milliard", "milliards"}, {1e6, "million", "millions"}} {
+		if q := n / s.v; q > 0 {
+			name := s.other
+			if q == 1 {
+				name = s.one
+			}
+			ws = append(ws, frBelow1000(q, true), name)
+			n %= s.v
+		}
+	}
+	if q := n / 1000; q > 0 {
+		if q > 1 {
+			ws = append(ws, frBelow1000(q, false))
+		}
+		ws = append(ws, "mille")
+		n %= 1000
+	}
+	if n > 0 {
+		ws = append(ws, frBelow1000(n, true))
+	}
+	return strings.Join(ws, " ")
+}
+
+func frenchOrdinal(n int64, fem bool) string {
+	if n == 1 {
+		if fem {
+			return "première"
+		}
+		return "premier"
+	}
+	c := strings.TrimSuffix(frenchCardinal(n), "s")
+	switch {
+	case strings.HasSuffix(c, "cinq"):
+		c += "u"
+	case strings.HasSuffix(c, "neuf"):
+		c = strings.TrimSuffix(c, "f") + "v"
+	case strings.HasSuffix(c, "e"):
+		c = strings.TrimSuffix(c, "e")
+	}
+	return c + "ième"
+}
+
+// frenchCounted puts "un" in the feminine before feminine nouns.
+func frenchCounted(n int64, fem bool) string {
+	c := frenchCardinal(n)
+	if fem && (c == "un" || strings.HasSuffix(c, " un") || strings.HasSuffix(c, "-un")) {
+		c += "e"
+	}
+	return c
+}
+
+func frenchClock(l *locale, hour, minute int64, _ string) string {
+	s := frenchCounted(hour, true) + " heure"
+	if hour > 1 {
+		s += "s"
+	}
+	if minute > 0 {
+		s += " " + frenchCounted(minute, true)
+	}
+	return s
+}
+
+var frenchUnits = map[string]unit{
+	"Ko": {"kilooctet", "kilooctets", false}, "Mo": {"mégaoctet", "mégaoctets", false},
+	"Go": {"gigaoctet", "gigaoctets", false}, "To": {"téraoctet", "téraoctets", false},
+	"KB": {"kilooctet", "kilooctets", false}, "kB": {"kilooctet", "kilooctets", false},
+	"MB": {"mégaoctet", "mégaoctets", false}, "GB": {"gigaoctet", "gigaoctets", false},
+	"TB":   {"téraoctet", "téraoctets", false},
+	"Mbps": {"mégabit par seconde", "mégabits par seconde", false}, "Gbps": {"gigabit par seconde", "gigabits par seconde", false},
+	"Hz": {"hertz", "hertz", false}, "kHz": {"kilohertz", "kilohertz", false},
+	"MHz": {"mégahertz", "mégahertz", false}, "GHz": {"gigahertz", "gigahertz", false},
+	"mm": {"millimètre", "millimètres", false}, "cm": {"centimètre", "centimètres", false},
+	"m": {"mètre", "mètres", false}, "km": {"kilomètre", "kilomètres", false},
+	"mg": {"milligramme", "milligrammes", false}, "g": {"gramme", "grammes", false}, "kg": {"kilogramme", "kilogrammes", false},
+	"t":  {"tonne", "tonnes", true},
+	"ml": {"millilitre", "millilitres", false}, "mL": {"millilitre", "millilitres", false},
+	"cl": {"centilitre", "centilitres", false}, "l": {"litre", "litres", false}, "L": {"litre", "litres", false},
+	"ms": {"milliseconde", "millisecondes", true}, "s": {"seconde", "secondes", true},
+	"min":  {"minute", "minutes", true},
+	"km/h": {"kilomètre heure", "kilomètres heure", false}, "m/s": {"mètre par seconde", "mètres par seconde", false},
+	"°C": {"degré Celsius", "degrés Celsius", false}, "°F": {"degré Fahrenheit", "degrés Fahrenheit", false},
+	"°": {"degré", "degrés", false},
+	"W": {"watt", "watts", false}, "kW": {"kilowatt", "kilowatts", false}, "kWh": {"kilowattheure", "kilowattheures", false},
+	"V": {"volt", "volts", false}, "mAh": {"milliampère-heure", "milliampères-heures", false},
+}
+
+var frenchAbbrevs = map[string]abbrev{
+	"M.": {"Monsieur", true, false}, "MM.": {"Messieurs", true, false},
+	"Mme": {"Madame", true, false}, "Mmes": {"Mesdames", true, false},
+	"Mlle": {"Mademoiselle", true, false}, "Dr": {"Docteur", true, false},
+	"Pr": {"Professeur", true, false}, "Me": {"Maître", true, false},
+	"etc.": {"et cetera", false, false}, "env.": {"environ", false, false},
+	"cf.": {"confer", false, false},
+	"n°":  {"numéro", false, true}, "N°": {"numéro", false, true},
+}
+
+var french = locale{
+	cardinal:   frenchCardinal,
+	ordinal:    frenchOrdinal,
+	ordinalRE:  regexp.MustCompile(`^(\d+)(er|re|ère|e|ème)$`),
+	femOrdinal: func(s string) bool { return s == "re" || s == "ère" },
+	counted:    frenchCounted,
+	group:      " ",
+	decimal:    ",",
+	point:      "virgule",
+	minus:      "moins",
+	percent:    "pour cent",
+	to:         "à",
+	and:        "et",
+	number:     "numéro",
+	plural:     func(v float64) bool { return v >= 2 },
+	currencies: map[string]money{
+		"€": {"euro", "euros", "centime", "centimes", false}, "EUR": {"euro", "euros", "centime", "centimes", false},
+		"$": {"dollar", "dollars", "cent", "cents", false}, "USD": {"dollar américain", "dollars américains", "cent", "cents", false},
+		"£": {"livre", "livres", "penny", "pence", true}, "GBP": {"livre sterling", "livres sterling", "penny", "pence", true},
+		"¥": {"yen", "yens", "", "", false}, "JPY": {"yen", "yens", "", "", false},
+	},
+	moneyConj: "et",
+	units:     frenchUnits,
+	abbrevs:   frenchAbbrevs,
+	months:    monthOrder["fr"],
+	dayFirst:  true,
+	date: func(l *locale, month, day, year int64) string {
+		s := l.cardinal(day)
+		if day == 1 {
+			s = "premier"
+		}
+		s += " " + l.months[month-1]
+		if year > 0 {
+			s += " " + l.cardinal(year)
+		}
+		return s
+	},
+	clock:     frenchClock,
+	hourMark:  "h",
+	dateWords: set("le", "du", "au", "depuis", "dès", "avant", "après"),
+}
+
+// Spanish.
+
+var (
+	esSmall = [...]string{"cero", "uno", "dos", "tres", "cuatro", "cinco", "seis", "siete", "ocho", "nueve",
+		"diez", "once", "doce", "trece", "catorce", "quince", "dieciséis", "diecisiete", "dieciocho", "diecinueve",
+		"veinte", "veintiuno", "veintidós", "veintitrés", "veinticuatro", "veinticinco", "veintiséis", "veintisiete", "veintiocho", "veintinueve"}
+	esTens     = [...]string{"", "", "", "treinta", "cuarenta", "cincuenta", "sesenta", "setenta", "ochenta", "noventa"}
+	esHundreds = [...]string{"", "ciento", "doscientos", "trescientos", "cuatrocientos", "quinientos", "seiscientos", "setecientos", "ochocientos", "novecientos"}
+	esOrdinals = [...]string{"", "primero", "segundo", "tercero", "cuarto", "quinto", "sexto", "séptimo", "octavo", "noveno", "décimo"}
+)
+
+func esBelow1000(n int64) string {
+	if n == 100 {
+		return "cien"
+	}
+	h, r := n/100, n%100
+	var ws []string
+	if h > 0 {
+		ws = append(ws, esHundreds[h])
+	}
+	switch {
+	case r == 0 && h > 0:
+	case r < 30:
+		ws = append(ws, esSmall[r])
+	default:
+		t, u := split(r)
+		ws = append(ws, esTens[t])
+		if u > 0 {
+			ws = append(ws, "y", esSmall[u])
+		}
+	}
+	return strings.Join(ws, " ")
+}
+
+// esApocope shortens a final "uno" before a noun: "veintiún mil".
+func esApocope(s string) string {
+	switch {
+	case strings.HasSuffix(s, "veintiuno"):
+		return strings.TrimSuffix(s, "uno") + "ún"
+	case s == "uno" || strings.HasSuffix(s, " uno"):
+		return strings.TrimSuffix(s, "o")
+	}
+	return s
+}
+
+func esBelowMillion(n int64) string {
+	q, r := n/1000, n%1000
+	var ws []string
+	switch {
+	case q == 1:
+		ws = append(ws, "mil")
+	case q > 1:
+		ws = append(ws, esApocope(esBelow1000(q)), "mil")
+	}
+	if r > 0 || q == 0 {
+		ws = append(ws, esBelow1000(r))
+	}
+	return strings.Join(ws, " ")
+}
+
+func spanishCardinal(n int64) string {
+	var ws []string
+	for _, s := range []struct {
+		v          int64
+		one, other string
+	}{{1e12, "un billón", "billones"}, {1e6, "un millón", "millones"}} {
+		switch q := n / s.v; {
+		case q == 1:
+			ws = append(ws, s.one)
+		case q > 1:
+			ws = append(ws, esApocope(esBelowMillion(q)), s.other)
+		}
+		n %= s.v
+	}
+	if n > 0 || len(ws) == 0 {
+		ws = append(ws, esBelowMillion(n))
+	}
+	return strings.Join(ws, " ")
+}
+
+func spanishOrdinal(n int64, fem bool) string {
+	if n >= int64(len(esOrdinals)) {
+		return spanishCardinal(n)
+	}
+	s := esOrdinals[n]
+	if fem {
+		s = strings.TrimSuffix(s, "o") + "a"
+	}
+	return s
+}
+
+// spanishCounted shortens "uno" before masculine nouns and makes it "una"
+// before feminine ones.
+func spanishCounted(n int64, fem bool) string {
+	c := spanishCardinal(n)
+	if fem {
+		if c == "uno" || strings.HasSuffix(c, "uno") {
+			return strings.TrimSuffix(c, "o") + "a"
+		}
+		return c
+	}
+	return esApocope(c)
+}
+
+func spanishClock(l *locale, hour, minute int64, ampm string) string {
+	s := spanishCounted(hour, true)
+	if minute == 0 {
+		s += " en punto"
+	} else {
+		s += " y " + l.cardinal(minute)
+	}
+	switch ampm {
+	case "am":
+		s += " de la mañana"
+	case "pm":
+		s += " de la tarde"
+	}
+	return s
+}
+
+var spanishUnits = map[string]unit{
+	"KB": {"kilobyte", "kilobytes", false}, "kB": {"kilobyte", "kilobytes", false},
+	"MB": {"megabyte", "megabytes", false}, "GB": {"gigabyte", "gigabytes", false},
+	"TB":   {"terabyte", "terabytes", false},
+	"Mbps": {"megabit por segundo", "megabits por segundo", false}, "Gbps": {"gigabit por segundo", "gigabits por segundo", false},
+	"Hz": {"hercio", "hercios", false}, "kHz": {"kilohercio", "kilohercios", false},
+	"MHz": {"megahercio", "megahercios", false}, "GHz": {"gigahercio", "gigahercios", false},
+	"mm": {"milímetro", "milímetros", false}, "cm": {"centímetro", "centímetros", false},
+	"m": {"metro", "metros", false}, "km": {"kilómetro", "kilómetros", false},
+	"mg": {"miligramo", "miligramos", false}, "g": {"gramo", "gramos", false}, "kg": {"kilogramo", "kilogramos", false},
+	"t":  {"tonelada", "toneladas", true},
+	"ml": {"mililitro", "mililitros", false}, "mL": {"mililitro", "mililitros", false},
+	"l": {"litro", "litros", false}, "L": {"litro", "litros", false},
+	"ms": {"milisegundo", "milisegundos", false}, "s": {"segundo", "segundos", false},
+	"min": {"minuto", "minutos", false}, "h": {"hora", "horas", true},
+	"km/h": {"kilómetro por hora", "kilómetros por hora", false}, "m/s": {"metro por segundo", "metros por segundo", false},
+	"°C": {"grado Celsius", "grados Celsius", false}, "°F": {"grado Fahrenheit", "grados Fahrenheit", false},
+	"°": {"grado", "grados", false},
+	"W": {"vatio", "vatios", false}, "kW": {"kilovatio", "kilovatios", false}, "kWh": {"kilovatio hora", "kilovatios hora", false},
+	"V": {"voltio", "voltios", false}, "mAh": {"miliamperio hora", "miliamperios hora", false},
+}
+
+var spanishAbbrevs = map[string]abbrev{
+	"Sr.": {"señor", true, false}, "Sra.": {"señora", true, false}, "Srta.": {"señorita", true, false},
+	"Dr.": {"doctor", true, false}, "Dra.": {"doctora", true, false},
+	"Ud.": {"usted", false, false}, "Uds.": {"ustedes", false, false},
+	"etc.": {"etcétera", false, false}, "aprox.": {"aproximadamente", false, false},
+	"núm.": {"número", false, true}, "n.º": {"número", false, true},
+}
+
+var spanish = locale{
+	cardinal:   spanishCardinal,
+	ordinal:    spanishOrdinal,
+	ordinalRE:  regexp.MustCompile(`^(\d+)\.?(º|ª|°)$`),
+	femOrdinal: func(s string) bool { return s == "ª" },
+	counted:    spanishCounted,
+	group:      ".",
+	decimal:    ",",
+	point:      "coma",
+	minus:      "menos",
+	percent:    "por ciento",
+	to:         "a",
+	and:        "y",
+	number:     "número",
+	plural:     func(v float64) bool { return v != 1 },
+	currencies: map[string]money{
+		"€": {"euro", "euros", "céntimo", "céntimos", false}, "EUR": {"euro", "euros", "céntimo", "céntimos", false},
+		"$": {"dólar", "dólares", "centavo", "centavos", false}, "USD": {"dólar estadounidense", "dólares estadounidenses", "centavo", "centavos", false},
+		"£": {"libra", "libras", "penique", "peniques", true}, "GBP": {"libra esterlina", "libras esterlinas", "penique", "peniques", true},
+		"¥": {"yen", "yenes", "", "", false}, "JPY": {"yen", "yenes", "", "", false},
+	},
+	moneyConj: "con",
+	units:     spanishUnits,
+	abbrevs:   spanishAbbrevs,
+	months:    monthOrder["es"],
+	dayFirst:  true,
+	date: func(l *locale, month, day, year int64) string {
+		s := l.cardinal(day) + " de " + l.months[month-1]
+		if year > 0 {
+			s += " de " + l.cardinal(year)
+		}
+		return s
+	},
+	clock:     spanishClock,
+	dateWords: set("el", "del", "al", "desde", "hasta", "antes", "después"),
+}
+
+// monthOrder are the names of the months, as written, by language.
+var monthOrder = map[string][]string{
+	"en": {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	"fr": {"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	"es": {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+}
+
+var locales = map[string]func() locale{
+	"en-US": func() locale {
+		l := english(false)
+		l.date = func(l *locale, month, day, year int64) string {
+			s := l.months[month-1] + " " + l.ordinal(day, false)
+			if year > 0 {
+				s += ", " + l.year(year)
+			}
+			return s
+		}
+		return l
+	},
+	"en-GB": func() locale {
+		l := english(true)
+		l.dayFirst = true
+		l.date = func(l *locale, month, day, year int64) string {
+			s := "the " + l.ordinal(day, false) + " of " + l.months[month-1]
+			if year > 0 {
+				s += " " + l.year(year)
+			}
+			return s
+		}
+		return l
+	},
+	"fr-FR": func() locale { return french },
+	"es-ES": func() locale { return spanish },
+}
+
+// byLanguage is the locale of a bare language.
+var byLanguage = map[string]string{"en": "en-US", "fr": "fr-FR", "es": "es-ES"}
+
+// Locales returns the names of the locales with rules, sorted.
+func Locales() []string {
+	out := make([]string, 0, len(locales))
+	for name := range locales {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// canonical returns the name of a locale as Locales has it: "en-GB" for
+// "en_gb", and the default locale of a bare language.
+func canonical(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	lang, region, ok := strings.Cut(tag, "-")
+	if !ok {
+		if name, ok := byLanguage[strings.ToLower(lang)]; ok {
+			return name
+		}
+		return tag
+	}
+	return strings.ToLower(lang) + "-" + strings.ToUpper(region)
+}
+
+func set(ws ...string) map[string]bool {
+	m := make(map[string]bool, len(ws))
+	for _, w := range ws {
+		m[w] = true
+	}
+	return m
+}
+
+// digits spells out s digit by digit.
+func (l *locale) digits(s string) string {
+	ws := make([]string, 0, len(s))
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			ws = append(ws, l.cardinal(int64(c-'0')))
+		}
+	}
+	return strings.Join(ws, " ")
+}
+
+// plain spells out the unsigned integer of digits s, digit by digit if it
+// has leading zeros or is too long to be read as a number.
+func (l *locale) plain(s string) string {
+	if len(s) > 1 && s[0] == '0' || len(s) > 15 {
+		return l.digits(s)
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return l.cardinal(n)
+}
+
+// yearOf spells out a year.
+func (l *locale) yearOf(n int64) string {
+	if l.year != nil {
+		return l.year(n)
+	}
+	return l.cardinal(n)
+}
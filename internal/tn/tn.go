@@ -0,0 +1,395 @@
+// Package tn normalizes the text given to synthesizers, the reverse of what
+// the itn package does to transcripts: it writes out the numbers, amounts
+// of money, measures, dates, times and abbreviations of the text the way
+// they are read aloud, so that "1024MB" is spoken "one thousand twenty-four
+// megabytes" rather than "one zero two four M B", and "Dr. Smith arrives
+// on 3/14 at 3:30 PM" reads "Doctor Smith arrives on March fourteenth at
+// three thirty p m".
+//
+// A Normalizer reads text by the rules of a locale, which fix the number
+// words, the separators of written numbers, the names of currencies and
+// units and the order of dates: en-US reads "3/4/2025" as March fourth
+// where en-GB reads the third of April. Each entity class can be turned off,
+// leaving its written form to the synthesizer.
+//
+// Lexicons override how words are spoken, for the product names, acronyms
+// and jargon of a domain: RegisterLexicon makes one available by name to
+// every Normalizer, and Config.Lexicon adds entries of its own. Lexicon
+// entries take precedence over the rules.
+//
+// SSML documents keep their markup: only their text is normalized, in
+// the locale of the xml:lang of its element if any, and the contents of
+// <say-as>, <sub> and <phoneme>, which already say how they are read, are
+// left alone.
+package tn
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultLocale applies when Config.Locale is empty.
+const defaultLocale = "en-US"
+
+// Class is an entity class.
+type Class string
+
+// Entity classes.
+const (
+	// Cardinal numbers: "1,024" is "one thousand twenty-four", "10-20"
+	// "ten to twenty" and "#3" "number three".
+	Cardinal Class = "cardinal"
+	// Ordinal numbers: "21st" is "twenty-first".
+	Ordinal Class = "ordinal"
+	// Decimal numbers: "3.14" is "three point one four".
+	Decimal Class = "decimal"
+	// Percent: "50%" is "fifty percent".
+	Percent Class = "percent"
+	// Currency: "$20.50" is "twenty dollars and fifty cents".
+	Currency Class = "currency"
+	// Measure: numbers with a unit, "1024MB" is "one thousand twenty-four
+	// megabytes" and "25°C" "twenty-five degrees Celsius".
+	Measure Class = "measure"
+	// Date: "2024-03-14" and "March 14" are "March fourteenth", and years
+	// are read as years: "in 1990" is "in nineteen ninety".
+	Date Class = "date"
+	// Time: times of day, "3:30 PM" is "three thirty p m".
+	Time Class = "time"
+	// Abbreviation: "Dr." is "Doctor" and "&" is "and".
+	Abbreviation Class = "abbreviation"
+)
+
+// Classes returns every entity class.
+func Classes() []Class {
+	return []Class{Cardinal, Ordinal, Decimal, Percent, Currency, Measure, Date, Time, Abbreviation}
+}
+
+// Config configures a Normalizer.
+type Config struct {
+	// Locale selects the rules among Locales. A bare language selects its
+	// default locale, "en" being "en-US". Defaults to "en-US". Elements
+	// of SSML documents with an xml:lang use the rules of their language
+	// instead, and are left as they are in a language without rules.
+	Locale string
+	// Disable lists the entity classes left as written.
+	Disable []Class
+	// Lexicons names registered lexicons to apply, later ones overriding
+	// the entries of earlier ones; see RegisterLexicon.
+	Lexicons []string
+	// Lexicon holds entries of its own, overriding those of Lexicons.
+	Lexicon Lexicon
+}
+
+// Normalizer writes text out as it is spoken. It is safe for concurrent
+// use.
+type Normalizer struct {
+	def     *locale
+	locales map[string]*locale // by name
+	off     map[Class]bool
+	lex     *lexicon
+}
+
+// New validates cfg.
+func New(cfg Config) (*Normalizer, error) {
+	if cfg.Locale == "" {
+		cfg.Locale = defaultLocale
+	}
+	name := canonical(cfg.Locale)
+	if _, ok := locales[name]; !ok {
+		return nil, fmt.Errorf("tn: no rules for locale %q (have %v)", cfg.Locale, Locales())
+	}
+	n := &Normalizer{locales: map[string]*locale{}, off: map[Class]bool{}}
+	for _, c := range cfg.Disable {
+		if !known(c) {
+			return nil, fmt.Errorf("tn: unknown entity class %q (have %v)", c, Classes())
+		}
+		n.off[c] = true
+	}
+	if len(n.off) == len(Classes()) && len(cfg.Lexicons) == 0 && len(cfg.Lexicon) == 0 {
+		return nil, errors.New("tn: every entity class is disabled and there is no lexicon")
+	}
+	for name, build := range locales {
+		l := build()
+		l.numberRE = numberRE(l.group, l.decimal)
+		n.locales[name] = &l
+	}
+	n.def = n.locales[name]
+	var err error
+	if n.lex, err = compile(cfg.Lexicons, cfg.Lexicon); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func known(c Class) bool {
+	for _, k := range Classes() {
+		if c == k {
+			return true
+		}
+	}
+	return false
+}
+
+// localeOf returns the rules of a language tag, nil if there are none.
+func (n *Normalizer) localeOf(tag string) *locale {
+	if l := n.locales[canonical(tag)]; l != nil {
+		return l
+	}
+	base, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(tag, "_", "-")), "-")
+	return n.locales[byLanguage[base]]
+}
+
+// Normalize returns text as it is spoken: plain text, or an SSML document
+// when it starts with <speak>, whose markup is kept.
+func (n *Normalizer) Normalize(text string) string {
+	if strings.HasPrefix(strings.TrimSpace(text), "<speak") {
+		return n.ssml(text)
+	}
+	out, _ := n.normalize(n.def, text)
+	return out
+}
+
+// skipped are the SSML elements whose contents say how they are read.
+var skipped = map[string]bool{"say-as": true, "sub": true, "phoneme": true}
+
+var (
+	tagName = regexp.MustCompile(`^</?\s*([A-Za-z][\w:.-]*)`)
+	xmlLang = regexp.MustCompile(`\bxml:lang\s*=\s*["']([^"']*)["']`)
+	escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// ssml normalizes the text of an SSML document. Malformed markup is
+// copied as it is.
+func (n *Normalizer) ssml(doc string) string {
+	type elem struct {
+		name string
+		l    *locale // nil in a language without rules
+		skip bool
+	}
+	stack := []elem{{l: n.def}}
+	var b strings.Builder
+	for doc != "" {
+		top := stack[len(stack)-1]
+		i := strings.IndexByte(doc, '<')
+		if i < 0 {
+			i = len(doc)
+		}
+		if text := doc[:i]; text != "" {
+			if top.skip || top.l == nil {
+				b.WriteString(text)
+			} else if out, changed := n.normalize(top.l, html.UnescapeString(text)); changed {
+				b.WriteString(escaper.Replace(out))
+			} else {
+				b.WriteString(text)
+			}
+		}
+		doc = doc[i:]
+		if doc == "" {
+			break
+		}
+		end := markupEnd(doc)
+		tag := doc[:end]
+		b.WriteString(tag)
+		doc = doc[end:]
+		m := tagName.FindStringSubmatch(tag)
+		switch {
+		case m == nil:
+			// A comment, CDATA section or processing instruction.
+		case strings.HasPrefix(tag, "</"):
+			for j := len(stack) - 1; j > 0; j-- {
+				if stack[j].name == m[1] {
+					stack = stack[:j]
+					break
+				}
+			}
+		case !strings.HasSuffix(tag, "/>"):
+			e := elem{name: m[1], l: top.l, skip: top.skip || skipped[localName(m[1])]}
+			if lang := xmlLang.FindStringSubmatch(tag); lang != nil {
+				e.l = n.localeOf(lang[1])
+			}
+			stack = append(stack, e)
+		}
+	}
+	return b.String()
+}
+
+// markupEnd returns the length of the markup doc starts with.
+func markupEnd(doc string) int {
+	for _, d := range []struct{ open, close string }{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}} {
+		if strings.HasPrefix(doc, d.open) {
+			if i := strings.Index(doc, d.close); i >= 0 {
+				return i + len(d.close)
+			}
+			return len(doc)
+		}
+	}
+	if i := strings.IndexByte(doc, '>'); i >= 0 {
+		return i + 1
+	}
+	return len(doc)
+}
+
+func localName(name string) string {
+	if _, local, ok := strings.Cut(name, ":"); ok {
+		return local
+	}
+	return name
+}
+
+// token is a word of a text and the punctuation around it.
+type token struct {
+	start, end        int // in the text, the punctuation included
+	lead, core, trail string
+}
+
+const (
+	leading  = `"'([{¿¡“‘«`
+	trailing = `.,;:!?"')]}”’»…`
+)
+
+// tokenize returns the words of text, split at spaces.
+func tokenize(text string) []token {
+	var ts []token
+	start := -1
+	add := func(end int) {
+		f := text[start:end]
+		core := strings.TrimLeft(f, leading)
+		lead := f[:len(f)-len(core)]
+		trimmed := strings.TrimRight(core, trailing)
+		ts = append(ts, token{start: start, end: end, lead: lead, core: trimmed, trail: core[len(trimmed):]})
+		start = -1
+	}
+	for i, c := range text {
+		switch {
+		case unicode.IsSpace(c) && start >= 0:
+			add(i)
+		case !unicode.IsSpace(c) && start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		add(len(text))
+	}
+	return ts
+}
+
+// match is an entity found at a token: the k tokens it spans, from the
+// core of the first to that of the last, and how it is spoken. eat bytes
+// of the trail of the last token are dropped, as the period of "Dr.", and
+// the lead of the first if bare, as the apostrophe of "'90s".
+type match struct {
+	k    int
+	text string
+	eat  int
+	bare bool
+}
+
+// matchers find the entities of each class at the start of ts, the text
+// left. prev is the lower-case word before, if no punctuation separates
+// it.
+var matchers = []struct {
+	class Class
+	match func(l *locale, ts []token, prev string) match
+}{
+	{Abbreviation, matchAbbrev},
+	{Date, matchDate},
+	{Time, matchTime},
+	{Currency, matchMoney},
+	{Measure, matchMeasure},
+	{Percent, matchPercent},
+	{Ordinal, matchOrdinal},
+	{Decimal, matchDecimal},
+	{Cardinal, matchCardinal},
+}
+
+// normalize writes text out by the rules of l, reporting whether it
+// changed. The spaces between the words are kept.
+func (n *Normalizer) normalize(l *locale, text string) (string, bool) {
+	ts := tokenize(text)
+	var b strings.Builder
+	pos, changed := 0, false
+	for i := 0; i < len(ts); {
+		t := ts[i]
+		rest := ts[i:]
+		best := n.lex.match(rest)
+		if best.k == 0 && !opaque(t.core) {
+			prev := ""
+			if i > 0 && ts[i-1].trail == "" && t.lead == "" {
+				prev = strings.ToLower(ts[i-1].core)
+			}
+			for _, m := range matchers {
+				if n.off[m.class] {
+					continue
+				}
+				if got := m.match(l, rest, prev); got.k > best.k {
+					best = got
+				}
+			}
+		}
+		if best.k == 0 {
+			i++
+			continue
+		}
+		last := ts[i+best.k-1]
+		b.WriteString(text[pos:t.start])
+		if !best.bare {
+			b.WriteString(t.lead)
+		}
+		b.WriteString(best.text)
+		b.WriteString(last.trail[best.eat:])
+		pos = last.end
+		changed = true
+		i += best.k
+	}
+	if !changed {
+		return text, false
+	}
+	b.WriteString(text[pos:])
+	return b.String(), true
+}
+
+// opaque reports whether a word is a URL or an address, read as written.
+func opaque(core string) bool {
+	return strings.Contains(core, "://") || strings.Contains(core, "@") || strings.HasPrefix(core, "www.")
+}
+
+// tight reports whether the first k tokens of ts are not separated by
+// punctuation.
+func tight(ts []token, k int) bool {
+	if len(ts) < k {
+		return false
+	}
+	for j := 0; j < k-1; j++ {
+		if ts[j].trail != "" || ts[j+1].lead != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// dot returns how much of the trail of ts[k-1] to drop for an
+// abbreviation ending it with a period: the period, unless it may also end
+// a sentence, at the end of the text or before a capital, which titles
+// never do.
+func dot(ts []token, k int, title bool) int {
+	if !strings.HasPrefix(ts[k-1].trail, ".") {
+		return 0
+	}
+	if ts[k-1].trail != "." {
+		return 1 // "e.g.," keeps its comma
+	}
+	if !title {
+		if k == len(ts) {
+			return 0
+		}
+		if c, _ := utf8.DecodeRuneInString(ts[k].lead + ts[k].core); unicode.IsUpper(c) {
+			return 0
+		}
+	}
+	return 1
+}
@@ -0,0 +1,37 @@
+package tn
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	lex := Lexicon{"kubectl": "cube control", "SQL": "sequel", "New York": "the big apple"}
+	for _, tc := range []struct {
+		locale, in, want string
+	}{
+		{"en-US", "The file is 1024MB, about 1,048,576 KB.",
+			"The file is one thousand twenty-four megabytes, about one million forty-eight thousand five hundred seventy-six kilobytes."},
+		{"en-US", "Dr. Smith arrives on 3/14 at 3:30 PM, e.g. tomorrow.",
+			"Doctor Smith arrives on March fourteenth at three thirty p m, for example tomorrow."},
+		{"en-US", "It costs $20.50 or €5 & is 50% off; 21st place, -3.5°C, in 1990 and the 1980s or '70s.",
+			"It costs twenty dollars and fifty cents or five euros and is fifty percent off; twenty-first place, minus three point five degrees Celsius, in nineteen ninety and the nineteen eighties or seventies."},
+		{"en-US", "Meet me March 3, 2024 at 9am. Call No. 5 or #12, pages 10-20, 007 etc.",
+			"Meet me March third, twenty twenty-four at nine a m. Call number five or number twelve, pages ten to twenty, zero zero seven et cetera."},
+		{"en-US", "Run kubectl on sql and SQL in New York, see https://x.io/1024MB.",
+			"Run cube control on sql and sequel in the big apple, see https://x.io/1024MB."},
+		{"en-GB", "On 3/4/2025 we paid £105 for 1005 items, 14 March 2024.",
+			"On the third of April twenty twenty-five we paid one hundred and five pounds for one thousand and five items, the fourteenth of March twenty twenty-four."},
+		{"fr-FR", "M. Dupont paie 1 024,50 € le 14/03/2024 à 15h30, soit 21 % et 80 km.",
+			"Monsieur Dupont paie mille vingt-quatre euros et cinquante centimes le quatorze mars deux mille vingt-quatre à quinze heures trente, soit vingt et un pour cent et quatre-vingts kilomètres."},
+		{"es-ES", "El Sr. García pagó 21 € el 1/2/2024 a las 15:30 (1 h).",
+			"El señor García pagó veintiún euros el uno de febrero de dos mil veinticuatro a las quince y treinta (una hora)."},
+		{"en-US", `<speak>It is 5 km &amp; <say-as interpret-as="characters">123</say-as> <lang xml:lang="fr-FR">21 km</lang></speak>`,
+			`<speak>It is five kilometers and <say-as interpret-as="characters">123</say-as> <lang xml:lang="fr-FR">vingt et un kilomètres</lang></speak>`},
+	} {
+		n, err := New(Config{Locale: tc.locale, Lexicon: lex})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := n.Normalize(tc.in); got != tc.want {
+			t.Errorf("%s: Normalize(%q)\n got %q\nwant %q", tc.locale, tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package libretranslate is a translation backend for LibreTranslate
+// (https://libretranslate.com), an open-source machine translation server
+// that can be self-hosted, so transcripts need not leave the network.
+package libretranslate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/translate"
+)
+
+// ProviderName is the name the backend registers under. Its options are
+// "endpoint" and "api_key".
+const ProviderName = "libretranslate"
+
+// DefaultEndpoint is where a local LibreTranslate server listens.
+const DefaultEndpoint = "http://localhost:5000"
+
+func init() {
+	translate.Register(ProviderName, func(cfg translate.Config) (translate.Translator, error) {
+		return New(Config{
+			Endpoint: cfg.Option("endpoint", DefaultEndpoint),
+			APIKey:   cfg.Option("api_key", os.Getenv("LIBRETRANSLATE_API_KEY")),
+			Logger:   cfg.Logger,
+		})
+	})
+}
+
+// Config configures the client.
+type Config struct {
+	// Endpoint is the server's base URL.
+	Endpoint string
+	// APIKey is required by servers that enforce keys, like the public one.
+	APIKey string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Logger receives the client's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Translator calls a LibreTranslate server.
+type Translator struct {
+	cfg Config
+}
+
+// New validates cfg.
+func New(cfg Config) (*Translator, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+	if !strings.HasPrefix(cfg.Endpoint, "http://") && !strings.HasPrefix(cfg.Endpoint, "https://") {
+		return nil, fmt.Errorf("bad endpoint %q", cfg.Endpoint)
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	return &Translator{cfg: cfg}, nil
+}
+
+// Translate implements translate.Translator (POST /translate).
+func (t *Translator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	if source == "" {
+		source = "auto"
+	}
+	req := map[string]string{"q": text, "source": source, "target": target, "format": "text"}
+	if t.cfg.APIKey != "" {
+		req["api_key"] = t.cfg.APIKey
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.Endpoint+"/translate", bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	resp, err := t.cfg.HTTPClient.Do(hreq)
+	if err != nil {
+		return "", fmt.Errorf("libretranslate: %w", err)
+	}
+	defer resp.Body.Close()
+	t.cfg.Logger.Debug("libretranslate request", "source", source, "target", target,
+		"chars", len(text), "status", resp.StatusCode, "took", time.Since(start))
+	var out struct {
+		TranslatedText string `json:"translatedText"`
+		Error          string `json:"error"`
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("libretranslate: %w", err)
+	}
+	if err := json.Unmarshal(body, &out); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("libretranslate: bad response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := out.Error
+		if msg == "" {
+			msg = string(bytes.TrimSpace(body[:min(len(body), 1024)]))
+		}
+		return "", fmt.Errorf("libretranslate: %s: %s", resp.Status, msg)
+	}
+	if out.Error != "" {
+		return "", errors.New("libretranslate: " + out.Error)
+	}
+	return out.TranslatedText, nil
+}
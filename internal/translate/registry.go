@@ -0,0 +1,110 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/ratelimit"
+)
+
+// Config selects a registered provider and passes it backend options.
+type Config struct {
+	// Provider is the name the backend was registered under.
+	Provider string
+	// Options are backend-specific settings, e.g. "endpoint" or "api_key".
+	Options map[string]string
+	// Logger receives the backend's log records. Nil discards them.
+	Logger logging.Logger
+	// RateLimit, if set, holds the translations of the backend to the
+	// limits of its account, shared with every provider limited under the
+	// same key.
+	RateLimit *ratelimit.Config
+}
+
+// Option returns the named option or def when unset.
+func (c Config) Option(name, def string) string {
+	if v, ok := c.Options[name]; ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// Factory builds a translator from its configuration.
+type Factory func(cfg Config) (Translator, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available under name. It is meant to be called
+// from the backend's init function and panics if name is already taken or
+// factory is nil.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("translate: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("translate: Register called twice for provider " + name)
+	}
+	registry[name] = factory
+}
+
+// New instantiates the provider selected by cfg.Provider.
+func New(cfg Config) (Translator, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Provider]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("translate: unknown provider %q (registered: %v)", cfg.Provider, Providers())
+	}
+	p, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("translate: %s: %w", cfg.Provider, err)
+	}
+	if cfg.RateLimit != nil {
+		rc := *cfg.RateLimit
+		if rc.Key == "" {
+			rc.Key = cfg.Provider
+		}
+		l, err := ratelimit.Shared(rc)
+		if err != nil {
+			return nil, fmt.Errorf("translate: %s: %w", cfg.Provider, err)
+		}
+		p = &limited{t: p, limit: l}
+	}
+	return p, nil
+}
+
+// Providers returns the sorted names of the registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// limited is a Translator keeping to the limits of its account, per
+// Config.RateLimit.
+type limited struct {
+	t     Translator
+	limit *ratelimit.Limiter
+}
+
+func (l *limited) Translate(ctx context.Context, text, source, target string) (string, error) {
+	release, err := l.limit.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return l.t.Translate(ctx, text, source, target)
+}
@@ -0,0 +1,112 @@
+// Package translate translates transcripts as they are produced, so one
+// pipeline can caption speech in several languages.
+//
+// A Translator turns text from one language into another through a
+// backend; backends register by name like recognizers do. A Stage
+// applies one to final segments, filling Segment.Translations.
+package translate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/ratelimit"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Translator translates text through a backend.
+type Translator interface {
+	// Translate returns text translated from source into target. Languages
+	// are ISO 639-1 codes; an empty source asks the backend to detect it.
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+// StageConfig configures transcript translation in a pipeline.
+type StageConfig struct {
+	// Provider and Options select the backend, as in Config.
+	Provider string
+	Options  map[string]string
+	// Source is the spoken language; empty uses Segment.Language, or lets
+	// the backend detect it if that is unknown too.
+	Source string
+	// Targets are the languages to translate into.
+	Targets []string
+	// OnError is called when a segment could not be translated into some
+	// target. The segment is delivered without that translation.
+	OnError func(seg stt.Segment, target string, err error)
+	// Logger receives the backend's log records. Nil discards them.
+	Logger logging.Logger
+	// RateLimit, if set, holds the backend to the limits of its account,
+	// as in Config.
+	RateLimit *ratelimit.Config
+}
+
+// Stage translates final segments into every target language.
+type Stage struct {
+	tr      Translator
+	source  string
+	targets []string
+	onError func(stt.Segment, string, error)
+}
+
+// NewStage instantiates the configured backend.
+func NewStage(cfg StageConfig) (*Stage, error) {
+	if len(cfg.Targets) == 0 {
+		return nil, errors.New("translate: no target language")
+	}
+	targets := make([]string, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		if t = strings.ToLower(strings.TrimSpace(t)); t == "" {
+			return nil, errors.New("translate: empty target language")
+		}
+		targets[i] = t
+	}
+	tr, err := New(Config{Provider: cfg.Provider, Options: cfg.Options, Logger: cfg.Logger, RateLimit: cfg.RateLimit})
+	if err != nil {
+		return nil, err
+	}
+	return &Stage{tr: tr, source: strings.ToLower(cfg.Source), targets: targets, onError: cfg.OnError}, nil
+}
+
+// Translate fills seg.Translations with the text of a final segment in
+// every target language, translating into all of them concurrently.
+// Partial segments and targets equal to the source are left alone.
+func (s *Stage) Translate(ctx context.Context, seg *stt.Segment) {
+	if !seg.Final || strings.TrimSpace(seg.Text) == "" {
+		return
+	}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	source := s.source
+	if source == "" {
+		source = strings.ToLower(seg.Language)
+	}
+	for _, target := range s.targets {
+		if target == source {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			text, err := s.tr.Translate(ctx, seg.Text, source, target)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if s.onError != nil {
+					s.onError(*seg, target, err)
+				}
+				return
+			}
+			if seg.Translations == nil {
+				seg.Translations = make(map[string]string, len(s.targets))
+			}
+			seg.Translations[target] = text
+		}()
+	}
+	wg.Wait()
+}
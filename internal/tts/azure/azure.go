@@ -0,0 +1,226 @@
+// Package azure is a text-to-speech backend for the Azure AI Speech REST
+// API (POST /cognitiveservices/v1 on the tts.speech.microsoft.com host of
+// a region).
+//
+// The API streams raw PCM16 mono, so audio is available while the
+// utterance is still being rendered. It takes SSML natively: plain text is
+// wrapped in a document speaking with the voice of the request, and
+// documents are forwarded with their content put in that voice, unless
+// they choose voices themselves.
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/tts"
+)
+
+// ProviderName is the name the backend registers under.
+const ProviderName = "azure"
+
+// DefaultVoice is a multilingual neural voice offered in every region.
+const DefaultVoice = "en-US-AvaMultilingualNeural"
+
+func init() {
+	tts.Register(ProviderName, func(cfg tts.Config) (tts.Synthesizer, error) {
+		rate, err := strconv.Atoi(cfg.Option("sample_rate", "24000"))
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("bad sample_rate %q", cfg.Options["sample_rate"])
+		}
+		return New(Config{
+			Region:     cfg.Option("region", os.Getenv("AZURE_SPEECH_REGION")),
+			Endpoint:   cfg.Option("endpoint", ""),
+			APIKey:     cfg.Option("api_key", os.Getenv("AZURE_SPEECH_KEY")),
+			Language:   cfg.Option("language", "en-US"),
+			Voice:      cfg.Option("voice", DefaultVoice),
+			SampleRate: rate,
+			Logger:     cfg.Logger,
+		})
+	})
+}
+
+// Config configures the client.
+type Config struct {
+	// Region is the Azure region of the Speech resource, e.g. "eastus".
+	Region string
+	// Endpoint overrides the https://{region}.tts.speech.microsoft.com
+	// host, for sovereign clouds and containers.
+	Endpoint string
+	// APIKey is a key of the Speech resource.
+	APIKey string
+	// Language is the xml:lang of the documents built for plain text.
+	Language string
+	// Voice is the default voice short name, e.g. "en-US-JennyNeural".
+	Voice string
+	// SampleRate of the PCM output: 8000, 16000, 24000 or 48000.
+	SampleRate int
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Logger receives the client's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Synthesizer calls the Azure API.
+type Synthesizer struct {
+	cfg    Config
+	format audio.Format
+}
+
+// New validates cfg.
+func New(cfg Config) (*Synthesizer, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("api_key is required")
+	}
+	if cfg.Endpoint == "" {
+		if cfg.Region == "" {
+			return nil, errors.New("region or endpoint is required")
+		}
+		cfg.Endpoint = "https://" + cfg.Region + ".tts.speech.microsoft.com"
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	switch cfg.SampleRate {
+	case 0:
+		cfg.SampleRate = 24000
+	case 8000, 16000, 24000, 48000:
+	default:
+		return nil, fmt.Errorf("sample_rate %d is not one of 8000, 16000, 24000 or 48000", cfg.SampleRate)
+	}
+	if cfg.Language == "" {
+		cfg.Language = "en-US"
+	}
+	if cfg.Voice == "" {
+		cfg.Voice = DefaultVoice
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	return &Synthesizer{cfg: cfg, format: audio.Format{SampleRate: cfg.SampleRate, Channels: 1}}, nil
+}
+
+// Synthesize implements tts.Synthesizer.
+func (s *Synthesizer) Synthesize(ctx context.Context, req tts.Request) (tts.Stream, error) {
+	voice := s.cfg.Voice
+	if req.Voice != "" {
+		voice = req.Voice
+	}
+	doc, err := s.document(req.Text, voice)
+	if err != nil {
+		return nil, err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint+"/cognitiveservices/v1", strings.NewReader(doc))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/ssml+xml")
+	hreq.Header.Set("X-Microsoft-OutputFormat", fmt.Sprintf("raw-%dkhz-16bit-mono-pcm", s.cfg.SampleRate/1000))
+	hreq.Header.Set("User-Agent", "voxa")
+	start := time.Now()
+	resp, err := s.do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	s.cfg.Logger.Debug("azure synthesis", "utterance", req.UtteranceID, "voice", voice, "took", time.Since(start))
+	return tts.NewPCMStream(s.format, resp.Body), nil
+}
+
+// document returns the SSML document speaking text with voice.
+func (s *Synthesizer) document(text, voice string) (string, error) {
+	var inner string
+	if tts.IsSSML(text) {
+		// Azure wants every word inside a <voice>, and its own namespaces.
+		if strings.Contains(text, "<voice") {
+			return text, nil
+		}
+		open := strings.IndexByte(text, '>')
+		end := strings.LastIndex(text, "</speak>")
+		if open < 0 || end < open {
+			return "", errors.New("azure: malformed SSML document")
+		}
+		inner = text[open+1 : end]
+	} else {
+		var b strings.Builder
+		if err := xml.EscapeText(&b, []byte(text)); err != nil {
+			return "", err
+		}
+		inner = b.String()
+	}
+	var b strings.Builder
+	b.WriteString(`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xmlns:mstts="https://www.w3.org/2001/mstts" xml:lang="`)
+	_ = xml.EscapeText(&b, []byte(s.cfg.Language))
+	b.WriteString(`"><voice name="`)
+	_ = xml.EscapeText(&b, []byte(voice))
+	b.WriteString(`">`)
+	b.WriteString(inner)
+	b.WriteString(`</voice></speak>`)
+	return b.String(), nil
+}
+
+// do authenticates hreq and sends it, failing unless the API answers 200.
+func (s *Synthesizer) do(hreq *http.Request) (*http.Response, error) {
+	hreq.Header.Set("Ocp-Apim-Subscription-Key", s.cfg.APIKey)
+	resp, err := s.cfg.HTTPClient.Do(hreq)
+	if err != nil {
+		return nil, fmt.Errorf("azure: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("azure: %w", &resilience.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(bytes.TrimSpace(msg)),
+		})
+	}
+	return resp, nil
+}
+
+// Voices implements tts.VoiceLister with the voices of the region.
+func (s *Synthesizer) Voices(ctx context.Context) ([]tts.Voice, error) {
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.Endpoint+"/cognitiveservices/voices/list", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out []struct {
+		ShortName           string   `json:"ShortName"`
+		DisplayName         string   `json:"DisplayName"`
+		Gender              string   `json:"Gender"`
+		Locale              string   `json:"Locale"`
+		SecondaryLocaleList []string `json:"SecondaryLocaleList"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("azure: decode voices: %w", err)
+	}
+	voices := make([]tts.Voice, len(out))
+	for i, v := range out {
+		voices[i] = tts.Voice{
+			ID:        v.ShortName,
+			Name:      v.DisplayName,
+			Languages: append([]string{v.Locale}, v.SecondaryLocaleList...),
+		}
+		switch g := strings.ToLower(v.Gender); g {
+		case "female", "male", "neutral":
+			voices[i].Gender = g
+		}
+	}
+	return voices, nil
+}
@@ -0,0 +1,469 @@
+package tts
+
+import (
+	"bytes"
+	"cmp"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/archive"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// CacheConfig sizes the cache of a Synthesizer; see Config.Cache.
+type CacheConfig struct {
+	// MaxBytes bounds the audio kept in memory, the least recently spoken
+	// being evicted first. Defaults to 64MiB.
+	MaxBytes int64
+	// TTL, if set, is how long audio is served from the cache once
+	// synthesized; older audio is synthesized again.
+	TTL time.Duration
+	// Dir, if set, keeps the audio in files under it too, surviving
+	// restarts, up to DirMaxBytes, which defaults to 1GiB.
+	Dir         string
+	DirMaxBytes int64
+	// S3, if set instead of Dir, keeps the audio in a bucket, shared by
+	// every process caching in it. Objects past TTL are ignored; removing
+	// them, and bounding the bucket, is left to its lifecycle rules.
+	S3 *archive.S3Config
+}
+
+// Validate checks the sizes and tiers of c.
+func (c CacheConfig) Validate() error {
+	switch {
+	case c.MaxBytes < 0 || c.DirMaxBytes < 0:
+		return errors.New("tts: cache: negative size")
+	case c.TTL < 0:
+		return fmt.Errorf("tts: cache: negative ttl %v", c.TTL)
+	case c.Dir != "" && c.S3 != nil:
+		return errors.New("tts: cache: both dir and s3")
+	}
+	return nil
+}
+
+// Cache defaults.
+const (
+	defaultCacheBytes    = 64 << 20
+	defaultCacheDirBytes = 1 << 30
+	// maxCacheEntry bounds the audio of one request that is cached, about
+	// 5 minutes at 24kHz.
+	maxCacheEntry = 16 << 20
+)
+
+// cached is a Synthesizer serving the audio of requests it has already
+// spoken from memory, then from its store, rather than from the backend.
+// Audio is addressed by the provider, its options, the voice and the text,
+// so changing the voice or the format of a provider misses. Only the audio
+// of streams read to the end is cached, and not that of a fallback, whose
+// voice is not the provider's.
+type cached struct {
+	s      Synthesizer
+	prefix string // of the keys: provider and options
+	ttl    time.Duration
+	store  cacheStore // nil keeps memory only
+	log    logging.Logger
+
+	mu  sync.Mutex
+	mem *lru[*cacheEntry]
+
+	wg sync.WaitGroup // store writes
+}
+
+// cacheEntry is the audio of one request.
+type cacheEntry struct {
+	format audio.Format
+	stored time.Time
+	pcm    []byte
+}
+
+// cacheStore is the second tier of a cache. get returns errCacheMiss for
+// audio it does not have.
+type cacheStore interface {
+	get(ctx context.Context, key string) ([]byte, error)
+	put(ctx context.Context, key string, b []byte) error
+}
+
+var errCacheMiss = errors.New("tts: cache miss")
+
+func newCached(cfg Config, s Synthesizer) (*cached, error) {
+	cc := *cfg.Cache
+	if err := cc.Validate(); err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", cfg.Provider)
+	for _, k := range slices.Sorted(maps.Keys(cfg.Options)) {
+		fmt.Fprintf(h, "%s=%s\x00", k, cfg.Options[k])
+	}
+	c := &cached{
+		s:      s,
+		prefix: string(h.Sum(nil)),
+		ttl:    cc.TTL,
+		log:    logging.OrNop(cfg.Logger),
+		mem:    newLRU[*cacheEntry](cmp.Or(cc.MaxBytes, defaultCacheBytes)),
+	}
+	var err error
+	switch {
+	case cc.Dir != "":
+		c.store, err = openDirCache(cc.Dir, cmp.Or(cc.DirMaxBytes, defaultCacheDirBytes))
+	case cc.S3 != nil:
+		var b *archive.S3
+		if b, err = archive.NewS3(*cc.S3); err == nil {
+			c.store = s3Cache{b}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tts: cache: %w", err)
+	}
+	return c, nil
+}
+
+// key addresses the audio of req.
+func (c *cached) key(req Request) string {
+	h := sha256.New()
+	io.WriteString(h, c.prefix)
+	fmt.Fprintf(h, "%s\x00%s", req.Voice, req.Text)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Synthesize implements Synthesizer.
+func (c *cached) Synthesize(ctx context.Context, req Request) (Stream, error) {
+	key := c.key(req)
+	if e := c.lookup(ctx, key); e != nil {
+		return NewPCMStream(e.format, io.NopCloser(bytes.NewReader(e.pcm))), nil
+	}
+	st, err := c.s.Synthesize(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := st.(*primed); ok && p.fallback {
+		return st, nil
+	}
+	return &filling{Stream: st, c: c, key: key, format: st.Format()}, nil
+}
+
+// lookup returns the audio of key from memory or the store, nil if
+// neither has it.
+func (c *cached) lookup(ctx context.Context, key string) *cacheEntry {
+	c.mu.Lock()
+	e, ok := c.mem.get(key)
+	if ok && c.expired(e) {
+		c.mem.remove(key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if ok || c.store == nil {
+		return e
+	}
+	b, err := c.store.get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, errCacheMiss) {
+			c.log.Warn("synthesis cache unavailable", "error", err)
+		}
+		return nil
+	}
+	if e, err = decodeCacheEntry(b); err != nil {
+		c.log.Warn("synthesis cache entry unreadable", "key", key, "error", err)
+		return nil
+	}
+	if c.expired(e) {
+		return nil
+	}
+	c.mu.Lock()
+	c.mem.add(key, int64(len(e.pcm)), e)
+	c.mu.Unlock()
+	return e
+}
+
+func (c *cached) expired(e *cacheEntry) bool {
+	return c.ttl > 0 && time.Since(e.stored) > c.ttl
+}
+
+// add caches e under key, writing it to the store in the background.
+func (c *cached) add(key string, e *cacheEntry) {
+	c.mu.Lock()
+	c.mem.add(key, int64(len(e.pcm)), e)
+	c.mu.Unlock()
+	if c.store == nil {
+		return
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		if err := c.store.put(context.Background(), key, e.encode()); err != nil {
+			c.log.Warn("synthesis cache write failed", "key", key, "error", err)
+		}
+	}()
+}
+
+// Close waits for the store writes, and closes the synthesizer if it needs
+// it.
+func (c *cached) Close() error {
+	c.wg.Wait()
+	if cl, ok := c.s.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// filling is a Stream recording its audio, to cache it once read to the
+// end.
+type filling struct {
+	Stream
+	c      *cached
+	key    string
+	format audio.Format
+	pcm    []byte
+	done   bool // cached or given up on
+}
+
+func (s *filling) ReadFrame() (audio.Frame, error) {
+	fr, err := s.Stream.ReadFrame()
+	switch {
+	case s.done:
+	case err == nil && len(s.pcm)+2*len(fr.Data) <= maxCacheEntry:
+		s.pcm = audio.AppendPCM16(s.pcm, fr.Data)
+	case errors.Is(err, io.EOF) && len(s.pcm) > 0:
+		s.done = true
+		s.c.add(s.key, &cacheEntry{format: s.format, stored: time.Now(), pcm: s.pcm})
+	default:
+		s.done, s.pcm = true, nil
+	}
+	return fr, err
+}
+
+// Entries are stored as a header, then the audio as PCM16.
+const (
+	cacheMagic     = "VXC1"
+	cacheHeaderLen = len(cacheMagic) + 8 + 4 + 2 // synthesis time, rate, channels
+)
+
+func (e *cacheEntry) encode() []byte {
+	b := make([]byte, 0, cacheHeaderLen+len(e.pcm))
+	b = append(b, cacheMagic...)
+	b = binary.LittleEndian.AppendUint64(b, uint64(e.stored.UnixNano()))
+	b = binary.LittleEndian.AppendUint32(b, uint32(e.format.SampleRate))
+	b = binary.LittleEndian.AppendUint16(b, uint16(e.format.Channels))
+	return append(b, e.pcm...)
+}
+
+func decodeCacheEntry(b []byte) (*cacheEntry, error) {
+	if len(b) < cacheHeaderLen || string(b[:len(cacheMagic)]) != cacheMagic {
+		return nil, errors.New("not a cache entry")
+	}
+	h := b[len(cacheMagic):]
+	e := &cacheEntry{
+		stored: time.Unix(0, int64(binary.LittleEndian.Uint64(h))),
+		format: audio.Format{
+			SampleRate: int(binary.LittleEndian.Uint32(h[8:])),
+			Channels:   int(binary.LittleEndian.Uint16(h[12:])),
+		},
+		pcm: b[cacheHeaderLen:],
+	}
+	if e.format.SampleRate <= 0 || e.format.Channels <= 0 {
+		return nil, fmt.Errorf("bad format %d Hz, %d channels", e.format.SampleRate, e.format.Channels)
+	}
+	return e, nil
+}
+
+// cacheName is the name key is stored under, in a directory by its first
+// byte so that none holds too many.
+func cacheName(key string) string {
+	return key[:2] + "/" + key + ".pcm"
+}
+
+// dirCache is a cacheStore of files under a directory, bounded in size,
+// the least recently used being removed first. The files of a previous run
+// are indexed on opening, by modification time; reading one touches it.
+type dirCache struct {
+	root string
+
+	mu    sync.Mutex
+	index *lru[struct{}]
+}
+
+func openDirCache(root string, max int64) (*dirCache, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	type file struct {
+		key  string
+		size int64
+		mod  time.Time
+	}
+	var files []file
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".pcm") {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, file{strings.TrimSuffix(d.Name(), ".pcm"), info.Size(), info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.Before(files[j].mod) })
+	d := &dirCache{root: root, index: newLRU[struct{}](max)}
+	for _, f := range files {
+		d.evict(d.index.add(f.key, f.size, struct{}{}))
+	}
+	return d, nil
+}
+
+func (d *dirCache) path(key string) string {
+	return filepath.Join(d.root, filepath.FromSlash(cacheName(key)))
+}
+
+func (d *dirCache) get(_ context.Context, key string) ([]byte, error) {
+	d.mu.Lock()
+	_, ok := d.index.get(key)
+	d.mu.Unlock()
+	if !ok {
+		return nil, errCacheMiss
+	}
+	b, err := os.ReadFile(d.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		d.mu.Lock()
+		d.index.remove(key)
+		d.mu.Unlock()
+		return nil, errCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	_ = os.Chtimes(d.path(key), now, now)
+	return b, nil
+}
+
+func (d *dirCache) put(_ context.Context, key string, b []byte) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	d.mu.Lock()
+	evicted := d.index.add(key, int64(len(b)), struct{}{})
+	d.mu.Unlock()
+	d.evict(evicted)
+	return nil
+}
+
+// evict removes the files of keys.
+func (d *dirCache) evict(keys []string) {
+	for _, key := range keys {
+		_ = os.Remove(d.path(key))
+	}
+}
+
+// s3Cache is a cacheStore in a bucket.
+type s3Cache struct{ b *archive.S3 }
+
+func (s s3Cache) get(ctx context.Context, key string) ([]byte, error) {
+	rc, err := s.b.Open(ctx, cacheName(key))
+	var herr *resilience.HTTPError
+	if errors.As(err, &herr) && herr.StatusCode == http.StatusNotFound {
+		return nil, errCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, int64(cacheHeaderLen+maxCacheEntry)))
+}
+
+func (s s3Cache) put(ctx context.Context, key string, b []byte) error {
+	return s.b.Put(ctx, cacheName(key), bytes.NewReader(b), int64(len(b)))
+}
+
+// lru indexes values by key, bounded by the sum of their sizes, the least
+// recently used being evicted first. It is not safe for concurrent use.
+type lru[V any] struct {
+	max   int64
+	size  int64
+	items map[string]*list.Element
+	order list.List // of *lruItem[V], most recently used first
+}
+
+type lruItem[V any] struct {
+	key  string
+	size int64
+	v    V
+}
+
+func newLRU[V any](max int64) *lru[V] {
+	return &lru[V]{max: max, items: map[string]*list.Element{}}
+}
+
+// get returns the value of key, marking it used.
+func (l *lru[V]) get(key string) (V, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(*lruItem[V]).v, true
+}
+
+// add sets the value of key, and returns the keys evicted to make room,
+// which include key itself if its value is larger than the whole.
+func (l *lru[V]) add(key string, size int64, v V) (evicted []string) {
+	l.remove(key)
+	if size > l.max {
+		return []string{key}
+	}
+	l.items[key] = l.order.PushFront(&lruItem[V]{key: key, size: size, v: v})
+	l.size += size
+	for l.size > l.max {
+		it := l.order.Back().Value.(*lruItem[V])
+		l.remove(it.key)
+		evicted = append(evicted, it.key)
+	}
+	return evicted
+}
+
+// remove forgets key.
+func (l *lru[V]) remove(key string) {
+	if el, ok := l.items[key]; ok {
+		l.size -= el.Value.(*lruItem[V]).size
+		l.order.Remove(el)
+		delete(l.items, key)
+	}
+}
@@ -0,0 +1,190 @@
+// Package elevenlabs is a text-to-speech backend for the ElevenLabs API
+// (POST /v1/text-to-speech/{voice}/stream).
+//
+// The API streams raw PCM16 mono, so audio is available while the
+// utterance is still being rendered. It does not understand SSML:
+// documents are rendered span by span with tts.Render.
+package elevenlabs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/tts"
+)
+
+// ProviderName is the name the backend registers under.
+const ProviderName = "elevenlabs"
+
+// DefaultEndpoint is the public API, which paths are appended to.
+const DefaultEndpoint = "https://api.elevenlabs.io"
+
+// DefaultVoice is Rachel, one of the premade voices every account has.
+const DefaultVoice = "21m00Tcm4TlvDq8ikWAM"
+
+func init() {
+	tts.Register(ProviderName, func(cfg tts.Config) (tts.Synthesizer, error) {
+		rate, err := strconv.Atoi(cfg.Option("sample_rate", "24000"))
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("bad sample_rate %q", cfg.Options["sample_rate"])
+		}
+		return New(Config{
+			Endpoint:   cfg.Option("endpoint", DefaultEndpoint),
+			APIKey:     cfg.Option("api_key", os.Getenv("ELEVENLABS_API_KEY")),
+			Model:      cfg.Option("model", "eleven_flash_v2_5"),
+			Voice:      cfg.Option("voice", DefaultVoice),
+			SampleRate: rate,
+			Logger:     cfg.Logger,
+		})
+	})
+}
+
+// Config configures the client.
+type Config struct {
+	Endpoint string
+	APIKey   string
+	Model    string
+	// Voice is the ID of the default voice.
+	Voice string
+	// SampleRate of the PCM output: 8000, 16000, 22050, 24000 or 44100.
+	SampleRate int
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Logger receives the client's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Synthesizer calls the ElevenLabs API.
+type Synthesizer struct {
+	cfg    Config
+	format audio.Format
+}
+
+// New validates cfg.
+func New(cfg Config) (*Synthesizer, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("api_key is required")
+	}
+	switch cfg.SampleRate {
+	case 0:
+		cfg.SampleRate = 24000
+	case 8000, 16000, 22050, 24000, 44100:
+	default:
+		return nil, fmt.Errorf("sample_rate %d is not one of 8000, 16000, 22050, 24000 or 44100", cfg.SampleRate)
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	if cfg.Voice == "" {
+		cfg.Voice = DefaultVoice
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	return &Synthesizer{cfg: cfg, format: audio.Format{SampleRate: cfg.SampleRate, Channels: 1}}, nil
+}
+
+// Synthesize implements tts.Synthesizer.
+func (s *Synthesizer) Synthesize(ctx context.Context, req tts.Request) (tts.Stream, error) {
+	voice := s.cfg.Voice
+	if req.Voice != "" {
+		voice = req.Voice
+	}
+	spans, err := tts.Spans(req.Text)
+	if err != nil {
+		return nil, err
+	}
+	return tts.Render(ctx, s.format, spans, func(ctx context.Context, text string) (tts.Stream, error) {
+		return s.speak(ctx, req.UtteranceID, text, voice)
+	}), nil
+}
+
+func (s *Synthesizer) speak(ctx context.Context, uid, text, voice string) (tts.Stream, error) {
+	b, err := json.Marshal(map[string]string{"text": text, "model_id": s.cfg.Model})
+	if err != nil {
+		return nil, err
+	}
+	u := s.cfg.Endpoint + "/v1/text-to-speech/" + url.PathEscape(voice) + "/stream?output_format=pcm_" + strconv.Itoa(s.cfg.SampleRate)
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	resp, err := s.do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	s.cfg.Logger.Debug("elevenlabs synthesis", "utterance", uid, "voice", voice, "took", time.Since(start))
+	return tts.NewPCMStream(s.format, resp.Body), nil
+}
+
+// do authenticates hreq and sends it, failing unless the API answers 200.
+func (s *Synthesizer) do(hreq *http.Request) (*http.Response, error) {
+	hreq.Header.Set("xi-api-key", s.cfg.APIKey)
+	resp, err := s.cfg.HTTPClient.Do(hreq)
+	if err != nil {
+		return nil, fmt.Errorf("elevenlabs: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("elevenlabs: %w", &resilience.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(bytes.TrimSpace(msg)),
+		})
+	}
+	return resp, nil
+}
+
+// Voices implements tts.VoiceLister with the voices of the account: the
+// premade ones and those it cloned or added from the library.
+func (s *Synthesizer) Voices(ctx context.Context) ([]tts.Voice, error) {
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.Endpoint+"/v1/voices", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Voices []struct {
+			VoiceID string            `json:"voice_id"`
+			Name    string            `json:"name"`
+			Labels  map[string]string `json:"labels"`
+		} `json:"voices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("elevenlabs: decode voices: %w", err)
+	}
+	voices := make([]tts.Voice, len(out.Voices))
+	for i, v := range out.Voices {
+		voices[i] = tts.Voice{ID: v.VoiceID, Name: v.Name}
+		if lang := v.Labels["language"]; lang != "" {
+			voices[i].Languages = []string{lang}
+		}
+		switch g := strings.ToLower(v.Labels["gender"]); g {
+		case "female", "male", "neutral":
+			voices[i].Gender = g
+		}
+	}
+	return voices, nil
+}
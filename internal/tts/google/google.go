@@ -0,0 +1,223 @@
+// Package google is a text-to-speech backend for the Google Cloud
+// Text-to-Speech REST API (texttospeech.googleapis.com/v1).
+//
+// SSML documents are forwarded unchanged; Google supports the full
+// specification.
+package google
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/tts"
+)
+
+// ProviderName is the name the backend registers under.
+const ProviderName = "google"
+
+// DefaultEndpoint is the public API endpoint.
+const DefaultEndpoint = "https://texttospeech.googleapis.com/v1/text:synthesize"
+
+// DefaultVoicesEndpoint is the public endpoint listing the voices.
+const DefaultVoicesEndpoint = "https://texttospeech.googleapis.com/v1/voices"
+
+func init() {
+	tts.Register(ProviderName, func(cfg tts.Config) (tts.Synthesizer, error) {
+		rate, err := strconv.Atoi(cfg.Option("sample_rate", "24000"))
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("bad sample_rate %q", cfg.Options["sample_rate"])
+		}
+		return New(Config{
+			Endpoint:       cfg.Option("endpoint", DefaultEndpoint),
+			VoicesEndpoint: cfg.Option("voices_endpoint", DefaultVoicesEndpoint),
+			APIKey:         cfg.Option("api_key", os.Getenv("GOOGLE_API_KEY")),
+			Token:          cfg.Option("token", ""),
+			Language:       cfg.Option("language", "en-US"),
+			Voice:          cfg.Option("voice", ""),
+			SampleRate:     rate,
+			Logger:         cfg.Logger,
+		})
+	})
+}
+
+// Config configures the client.
+type Config struct {
+	Endpoint       string
+	VoicesEndpoint string
+	// APIKey or Token (an OAuth2 access token) authenticates requests.
+	APIKey string
+	Token  string
+	// Language is the BCP-47 code voices are selected from.
+	Language string
+	// Voice is the default voice name, e.g. "en-US-Neural2-C".
+	Voice string
+	// SampleRate requested for the LINEAR16 output.
+	SampleRate int
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Logger receives the client's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Synthesizer calls the Google API.
+type Synthesizer struct {
+	cfg Config
+}
+
+// New validates cfg.
+func New(cfg Config) (*Synthesizer, error) {
+	if cfg.APIKey == "" && cfg.Token == "" {
+		return nil, errors.New("api_key or token is required")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+	if cfg.VoicesEndpoint == "" {
+		cfg.VoicesEndpoint = DefaultVoicesEndpoint
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	return &Synthesizer{cfg: cfg}, nil
+}
+
+type request struct {
+	Input struct {
+		Text string `json:"text,omitempty"`
+		SSML string `json:"ssml,omitempty"`
+	} `json:"input"`
+	Voice struct {
+		LanguageCode string `json:"languageCode"`
+		Name         string `json:"name,omitempty"`
+	} `json:"voice"`
+	AudioConfig struct {
+		AudioEncoding   string `json:"audioEncoding"`
+		SampleRateHertz int    `json:"sampleRateHertz,omitempty"`
+	} `json:"audioConfig"`
+}
+
+// Synthesize implements tts.Synthesizer. The API is unary, so the stream
+// becomes readable once the whole utterance has been rendered.
+func (s *Synthesizer) Synthesize(ctx context.Context, req tts.Request) (tts.Stream, error) {
+	var body request
+	if tts.IsSSML(req.Text) {
+		body.Input.SSML = req.Text
+	} else {
+		body.Input.Text = req.Text
+	}
+	body.Voice.LanguageCode = s.cfg.Language
+	body.Voice.Name = s.cfg.Voice
+	if req.Voice != "" {
+		body.Voice.Name = req.Voice
+	}
+	body.AudioConfig.AudioEncoding = "LINEAR16"
+	body.AudioConfig.SampleRateHertz = s.cfg.SampleRate
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	resp, err := s.do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	s.cfg.Logger.Debug("google synthesis", "utterance", req.UtteranceID, "took", time.Since(start))
+
+	var out struct {
+		AudioContent string `json:"audioContent"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("google: decode response: %w", err)
+	}
+	wav, err := base64.StdEncoding.DecodeString(out.AudioContent)
+	if err != nil {
+		return nil, fmt.Errorf("google: decode audio: %w", err)
+	}
+	// LINEAR16 responses carry a WAV header.
+	r, err := audio.NewWAVReader(bytes.NewReader(wav))
+	if err != nil {
+		return nil, fmt.Errorf("google: %w", err)
+	}
+	return tts.NopCloser(r), nil
+}
+
+// do authenticates hreq and sends it, failing unless the API answers 200.
+func (s *Synthesizer) do(hreq *http.Request) (*http.Response, error) {
+	if s.cfg.Token != "" {
+		hreq.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	} else {
+		hreq.Header.Set("X-Goog-Api-Key", s.cfg.APIKey)
+	}
+	resp, err := s.cfg.HTTPClient.Do(hreq)
+	if err != nil {
+		return nil, fmt.Errorf("google: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("google: %w", &resilience.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(bytes.TrimSpace(msg)),
+		})
+	}
+	return resp, nil
+}
+
+// Voices implements tts.VoiceLister with the voices of Config.Language.
+func (s *Synthesizer) Voices(ctx context.Context) ([]tts.Voice, error) {
+	u := s.cfg.VoicesEndpoint
+	if s.cfg.Language != "" {
+		u += "?" + url.Values{"languageCode": {s.cfg.Language}}.Encode()
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Voices []struct {
+			LanguageCodes []string `json:"languageCodes"`
+			Name          string   `json:"name"`
+			SSMLGender    string   `json:"ssmlGender"`
+		} `json:"voices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("google: decode voices: %w", err)
+	}
+	voices := make([]tts.Voice, len(out.Voices))
+	for i, v := range out.Voices {
+		voices[i] = tts.Voice{ID: v.Name, Languages: v.LanguageCodes}
+		switch v.SSMLGender {
+		case "FEMALE", "MALE", "NEUTRAL":
+			voices[i].Gender = strings.ToLower(v.SSMLGender)
+		}
+	}
+	return voices, nil
+}
@@ -0,0 +1,35 @@
+package tts
+
+import (
+	"context"
+	"io"
+
+	"github.com/jmarc101/voxa/internal/tn"
+)
+
+// Normalized returns a Synthesizer speaking on s the text of requests as n
+// writes it out, so that numbers, units, dates and abbreviations are read
+// aloud rather than spelled; see the tn package. Closing the Synthesizer
+// closes s if it is an io.Closer.
+func Normalized(s Synthesizer, n *tn.Normalizer) Synthesizer {
+	return &normalized{s: s, n: n}
+}
+
+type normalized struct {
+	s Synthesizer
+	n *tn.Normalizer
+}
+
+// Synthesize implements Synthesizer.
+func (c *normalized) Synthesize(ctx context.Context, req Request) (Stream, error) {
+	req.Text = c.n.Normalize(req.Text)
+	return c.s.Synthesize(ctx, req)
+}
+
+// Close closes the wrapped synthesizer if it needs it.
+func (c *normalized) Close() error {
+	if cl, ok := c.s.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Package openai is a text-to-speech backend for the OpenAI speech API
+// (POST /v1/audio/speech).
+//
+// The API streams raw PCM16 mono at 24kHz, so audio is available while the
+// utterance is still being rendered. It does not understand SSML: documents
+// are rendered span by span with tts.Render.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/tts"
+)
+
+// ProviderName is the name the backend registers under.
+const ProviderName = "openai"
+
+// DefaultEndpoint is the public API endpoint.
+const DefaultEndpoint = "https://api.openai.com/v1/audio/speech"
+
+// SampleRate of the "pcm" response format.
+const SampleRate = 24000
+
+func init() {
+	tts.Register(ProviderName, func(cfg tts.Config) (tts.Synthesizer, error) {
+		return New(Config{
+			Endpoint: cfg.Option("endpoint", DefaultEndpoint),
+			APIKey:   cfg.Option("api_key", os.Getenv("OPENAI_API_KEY")),
+			Model:    cfg.Option("model", "gpt-4o-mini-tts"),
+			Voice:    cfg.Option("voice", "alloy"),
+			Logger:   cfg.Logger,
+		})
+	})
+}
+
+// Config configures the client.
+type Config struct {
+	Endpoint string
+	APIKey   string
+	Model    string
+	// Voice is the default voice, e.g. "alloy".
+	Voice string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Logger receives the client's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Synthesizer calls the OpenAI API.
+type Synthesizer struct {
+	cfg Config
+}
+
+// New validates cfg.
+func New(cfg Config) (*Synthesizer, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("api_key is required")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	return &Synthesizer{cfg: cfg}, nil
+}
+
+var format = audio.Format{SampleRate: SampleRate, Channels: 1}
+
+// Synthesize implements tts.Synthesizer.
+func (s *Synthesizer) Synthesize(ctx context.Context, req tts.Request) (tts.Stream, error) {
+	voice := s.cfg.Voice
+	if req.Voice != "" {
+		voice = req.Voice
+	}
+	spans, err := tts.Spans(req.Text)
+	if err != nil {
+		return nil, err
+	}
+	return tts.Render(ctx, format, spans, func(ctx context.Context, text string) (tts.Stream, error) {
+		return s.speak(ctx, req.UtteranceID, text, voice)
+	}), nil
+}
+
+func (s *Synthesizer) speak(ctx context.Context, uid, text, voice string) (tts.Stream, error) {
+	b, err := json.Marshal(map[string]string{
+		"model":           s.cfg.Model,
+		"input":           text,
+		"voice":           voice,
+		"response_format": "pcm",
+	})
+	if err != nil {
+		return nil, err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hreq.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	start := time.Now()
+	resp, err := s.cfg.HTTPClient.Do(hreq)
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	s.cfg.Logger.Debug("openai synthesis", "utterance", uid, "status", resp.StatusCode, "took", time.Since(start))
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("openai: %w", &resilience.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(bytes.TrimSpace(msg)),
+		})
+	}
+	return tts.NewPCMStream(format, resp.Body), nil
+}
+
+// voices are the built-in voices of the speech API, which has no endpoint
+// listing them. They speak every language the model does.
+var voices = []string{"alloy", "ash", "ballad", "coral", "echo", "fable", "nova", "onyx", "sage", "shimmer", "verse"}
+
+// Voices implements tts.VoiceLister.
+func (s *Synthesizer) Voices(context.Context) ([]tts.Voice, error) {
+	out := make([]tts.Voice, len(voices))
+	for i, v := range voices {
+		out[i] = tts.Voice{ID: v}
+	}
+	return out, nil
+}
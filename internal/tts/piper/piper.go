@@ -0,0 +1,161 @@
+// Package piper is a local text-to-speech backend running the Piper neural
+// TTS engine (https://github.com/rhasspy/piper) as a subprocess.
+//
+// Piper reads plain text on stdin and, with --output-raw, writes PCM16 mono
+// audio at the voice model's sample rate to stdout. SSML is rendered with
+// tts.Render: one Piper run per span, pauses inserted as silence.
+package piper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/models"
+	"github.com/jmarc101/voxa/internal/tts"
+)
+
+// ProviderName is the name the backend registers under.
+const ProviderName = "piper"
+
+// DefaultSampleRate is the rate of Piper's medium-quality voices.
+const DefaultSampleRate = 22050
+
+func init() {
+	tts.Register(ProviderName, func(cfg tts.Config) (tts.Synthesizer, error) {
+		rate, err := strconv.Atoi(cfg.Option("sample_rate", strconv.Itoa(DefaultSampleRate)))
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("bad sample_rate %q", cfg.Options["sample_rate"])
+		}
+		return New(Config{
+			Binary:     cfg.Option("binary", "piper"),
+			Model:      cfg.Option("model", ""),
+			SampleRate: rate,
+			Logger:     cfg.Logger,
+		})
+	})
+}
+
+// Config configures the engine.
+type Config struct {
+	// Binary is the piper executable, looked up in $PATH if not a path.
+	Binary string
+	// Model is the .onnx voice model, whose .onnx.json config must sit
+	// next to it, or the name of a voice installed with voxa models pull,
+	// e.g. en_US-lessac-medium.
+	Model string
+	// SampleRate must match the model's audio.sample_rate.
+	SampleRate int
+	// Logger receives the engine's log records. Nil discards them.
+	Logger logging.Logger
+}
+
+// Synthesizer runs Piper for every request.
+type Synthesizer struct {
+	cfg Config
+}
+
+// New checks that the binary and model are configured.
+func New(cfg Config) (*Synthesizer, error) {
+	if cfg.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	cfg.Model = models.Resolve(cfg.Model)
+	if cfg.Binary == "" {
+		cfg.Binary = "piper"
+	}
+	if cfg.SampleRate == 0 {
+		cfg.SampleRate = DefaultSampleRate
+	}
+	if _, err := exec.LookPath(cfg.Binary); err != nil {
+		return nil, err
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	return &Synthesizer{cfg: cfg}, nil
+}
+
+func (s *Synthesizer) format() audio.Format {
+	return audio.Format{SampleRate: s.cfg.SampleRate, Channels: 1}
+}
+
+// Synthesize implements tts.Synthesizer. req.Voice, if set, is the speaker
+// id of a multi-speaker model.
+func (s *Synthesizer) Synthesize(ctx context.Context, req tts.Request) (tts.Stream, error) {
+	spans, err := tts.Spans(req.Text)
+	if err != nil {
+		return nil, err
+	}
+	return tts.Render(ctx, s.format(), spans, func(ctx context.Context, text string) (tts.Stream, error) {
+		return s.run(ctx, req.UtteranceID, text, req.Voice)
+	}), nil
+}
+
+func (s *Synthesizer) run(ctx context.Context, uid, text, speaker string) (tts.Stream, error) {
+	args := []string{"--model", s.cfg.Model, "--output-raw"}
+	if speaker != "" {
+		args = append(args, "--speaker", speaker)
+	}
+	cmd := exec.CommandContext(ctx, s.cfg.Binary, args...)
+	// Piper synthesizes one utterance per input line.
+	cmd.Stdin = strings.NewReader(strings.ReplaceAll(text, "\n", " ") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("piper: %w", err)
+	}
+	log := logging.With(s.cfg.Logger, "utterance", uid, "pid", cmd.Process.Pid)
+	log.Debug("piper started")
+	return tts.NewPCMStream(s.format(), &process{cmd: cmd, stdout: stdout, stderr: &stderr, log: log}), nil
+}
+
+// process is the stdout of a running Piper, reporting its exit status at EOF.
+type process struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+	log    logging.Logger
+	done   bool
+}
+
+func (p *process) Read(b []byte) (int, error) {
+	n, err := p.stdout.Read(b)
+	if errors.Is(err, io.EOF) {
+		if werr := p.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (p *process) wait() error {
+	if p.done {
+		return nil
+	}
+	p.done = true
+	if err := p.cmd.Wait(); err != nil {
+		p.log.Warn("piper failed", "error", err, "stderr", strings.TrimSpace(p.stderr.String()))
+		return fmt.Errorf("piper: %w: %s", err, strings.TrimSpace(p.stderr.String()))
+	}
+	p.log.Debug("piper finished")
+	return nil
+}
+
+// Close stops Piper if it is still running.
+func (p *process) Close() error {
+	if !p.done && p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	_ = p.wait()
+	return nil
+}
@@ -0,0 +1,159 @@
+package tts
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/ratelimit"
+	"github.com/jmarc101/voxa/internal/resilience"
+	"github.com/jmarc101/voxa/internal/tn"
+)
+
+// Config selects a registered provider and passes it backend options.
+type Config struct {
+	// Provider is the name the backend was registered under.
+	Provider string
+	// Options are backend-specific settings, e.g. "addr" or "model".
+	Options map[string]string
+	// Logger receives the backend's log records. Nil discards them.
+	Logger logging.Logger
+	// Resilience, if set, retries the backend's transient failures,
+	// including streams failing before their first frame, and opens a
+	// circuit breaker when they persist.
+	Resilience *resilience.Config
+	// RateLimit, if set, holds the syntheses of the backend to the limits
+	// of its account, shared with every provider limited under the same
+	// key. Syntheses wait for the limits rather than be throttled by the
+	// backend.
+	RateLimit *ratelimit.Config
+	// Fallbacks, if set, are the providers that speak, in order, when the
+	// ones before them fail beyond what Resilience recovers or have their
+	// breaker open. Fallbacks imply Resilience, with the defaults unless
+	// set, use their own Resilience and RateLimit, and inherit Logger when
+	// they have none. Their own Fallbacks are ignored.
+	Fallbacks []Config
+	// Cache, if set, serves the audio of requests already spoken, such as
+	// the prompts of an assistant, from memory and then from a directory
+	// or bucket, rather than synthesizing it again. Cached audio costs
+	// nothing and takes from no rate limit. Fallbacks ignore it.
+	Cache *CacheConfig
+	// Sentences, if set, synthesizes plain text one sentence at a time, so
+	// the first is heard while the rest is synthesized; see BySentence.
+	// Fallbacks ignore it: it applies to the chain as a whole.
+	Sentences bool
+	// Normalization, if set, writes out the numbers, units, dates and
+	// abbreviations of requests the way they are spoken before any
+	// backend, cache or sentence splitting sees them; see Normalized.
+	// Fallbacks ignore it.
+	Normalization *tn.Config
+}
+
+// Option returns the named option or def when unset.
+func (c Config) Option(name, def string) string {
+	if v, ok := c.Options[name]; ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// Factory builds a synthesizer from its configuration.
+type Factory func(cfg Config) (Synthesizer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available under name. It is meant to be called
+// from the backend's init function and panics if name is already taken or
+// factory is nil.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("tts: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("tts: Register called twice for provider " + name)
+	}
+	registry[name] = factory
+}
+
+// New instantiates the provider selected by cfg.Provider, wrapped to
+// retry and fail over as cfg.Resilience and cfg.Fallbacks ask, to keep to
+// cfg.RateLimit, to cache as cfg.Cache does, to speak by sentence as
+// cfg.Sentences does and to normalize text as cfg.Normalization does. With
+// both Cache and Sentences, sentences are cached one by one.
+func New(cfg Config) (Synthesizer, error) {
+	var norm *tn.Normalizer
+	if cfg.Normalization != nil {
+		var err error
+		if norm, err = tn.New(*cfg.Normalization); err != nil {
+			return nil, err
+		}
+	}
+	p, err := build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Resilience != nil || len(cfg.Fallbacks) > 0 {
+		if p, err = newResilient(cfg, p); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Cache != nil {
+		c, err := newCached(cfg, p)
+		if err != nil {
+			if cl, ok := p.(io.Closer); ok {
+				_ = cl.Close()
+			}
+			return nil, err
+		}
+		p = c
+	}
+	if cfg.Sentences {
+		p = BySentence(p)
+	}
+	if norm != nil {
+		p = Normalized(p, norm)
+	}
+	return p, nil
+}
+
+// build instantiates the provider selected by cfg.Provider alone, within
+// its rate limit and metering its syntheses; see cost.Meter.
+func build(cfg Config) (Synthesizer, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Provider]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("tts: unknown provider %q (registered: %v)", cfg.Provider, Providers())
+	}
+	p, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("tts: %s: %w", cfg.Provider, err)
+	}
+	w, err := wrap(cfg, p)
+	if err != nil {
+		if c, ok := p.(io.Closer); ok {
+			_ = c.Close()
+		}
+		return nil, fmt.Errorf("tts: %s: %w", cfg.Provider, err)
+	}
+	return w, nil
+}
+
+// Providers returns the sorted names of the registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,130 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// resilient is a Synthesizer retrying the failures of a chain of others
+// and failing over along it, per Config.Resilience and Config.Fallbacks.
+type resilient struct {
+	chain []link
+	log   logging.Logger
+}
+
+// link is one provider of a chain, guarded by its own policy.
+type link struct {
+	name   string
+	s      Synthesizer
+	policy *resilience.Policy
+}
+
+func newResilient(cfg Config, primary Synthesizer) (Synthesizer, error) {
+	r := &resilient{log: logging.OrNop(cfg.Logger)}
+	for i, c := range append([]Config{cfg}, cfg.Fallbacks...) {
+		s := primary
+		if i > 0 {
+			if c.Logger == nil {
+				c.Logger = logging.With(cfg.Logger, "fallback", c.Provider)
+			}
+			var err error
+			if s, err = build(c); err != nil {
+				_ = r.Close()
+				return nil, err
+			}
+		}
+		var rc resilience.Config
+		if c.Resilience != nil {
+			rc = *c.Resilience
+		}
+		policy, err := resilience.New(rc, logging.With(c.Logger, "provider", c.Provider))
+		if err != nil {
+			if cl, ok := s.(io.Closer); ok {
+				_ = cl.Close()
+			}
+			_ = r.Close()
+			return nil, fmt.Errorf("tts: %s: %w", c.Provider, err)
+		}
+		r.chain = append(r.chain, link{name: c.Provider, s: s, policy: policy})
+	}
+	return r, nil
+}
+
+// Close closes the providers that need it.
+func (r *resilient) Close() error {
+	var errs []error
+	for _, l := range r.chain {
+		if c, ok := l.s.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Synthesize implements Synthesizer on the first provider of the chain
+// that succeeds. Backends commonly fail only once the audio is read, so an
+// attempt lasts until the first frame arrives; after that, failures are
+// the caller's, as the audio has started playing.
+func (r *resilient) Synthesize(ctx context.Context, req Request) (Stream, error) {
+	var err error
+	for i := range r.chain {
+		l := &r.chain[i]
+		var st Stream
+		err = l.policy.Do(ctx, func() error {
+			var err error
+			st, err = prime(ctx, l.s, req)
+			if err == nil && i > 0 {
+				st.(*primed).fallback = true
+			}
+			return err
+		})
+		if err == nil {
+			return st, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if i+1 < len(r.chain) {
+			r.log.Warn("synthesizer unavailable, failing over", "utterance", req.UtteranceID,
+				"provider", l.name, "next", r.chain[i+1].name, "error", err)
+		}
+	}
+	return nil, err
+}
+
+// prime starts synthesizing req on s and waits for the first frame.
+func prime(ctx context.Context, s Synthesizer, req Request) (Stream, error) {
+	st, err := s.Synthesize(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	fr, err := st.ReadFrame()
+	if err != nil && !errors.Is(err, io.EOF) {
+		_ = st.Close()
+		return nil, err
+	}
+	return &primed{Stream: st, first: fr, err: err}, nil
+}
+
+// primed is a Stream whose first frame, or io.EOF, has been read ahead.
+type primed struct {
+	Stream
+	first    audio.Frame
+	err      error
+	read     bool
+	fallback bool // spoken by a fallback rather than the provider
+}
+
+func (p *primed) ReadFrame() (audio.Frame, error) {
+	if !p.read {
+		p.read = true
+		return p.first, p.err
+	}
+	return p.Stream.ReadFrame()
+}
@@ -0,0 +1,247 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// minSentence is the length under which a sentence is spoken together with
+// the next one: a backend call per "Hi." costs more in latency and prosody
+// than it saves.
+const minSentence = 16
+
+// Sentences splits plain text into the sentences it is synthesized in by a
+// Synthesizer returned from BySentence. A sentence ends at a terminal mark
+// followed by a space and an upper-case letter, digit or quote, or at a
+// line break, so "e.g. this" and "3.5 km" stay whole. Sentences shorter
+// than a few words are joined to the next.
+func Sentences(text string) []string {
+	var (
+		out   []string
+		start int
+	)
+	emit := func(end int) {
+		s := strings.Join(strings.Fields(text[start:end]), " ")
+		start = end
+		if s == "" {
+			return
+		}
+		if n := len(out); n > 0 && len(out[n-1]) < minSentence {
+			sep := " "
+			if r, _ := utf8.DecodeLastRuneInString(out[n-1]); wide(r) {
+				sep = ""
+			}
+			out[n-1] += sep + s
+			return
+		}
+		out = append(out, s)
+	}
+	for i, r := range text {
+		switch {
+		case r == '\n':
+			emit(i + 1)
+		case terminal(r):
+			j := i + utf8.RuneLen(r)
+			for j < len(text) {
+				c, n := utf8.DecodeRuneInString(text[j:])
+				if !strings.ContainsRune(`.!?…"'”’)`, c) {
+					break
+				}
+				j += n
+			}
+			rest := text[j:]
+			trimmed := strings.TrimLeft(rest, " \t")
+			if len(trimmed) == len(rest) && !wide(r) {
+				continue // no space after the mark: 3.5, example.com
+			}
+			if next, _ := utf8.DecodeRuneInString(trimmed); trimmed != "" && !opens(next) && !wide(r) {
+				continue
+			}
+			emit(j)
+		}
+	}
+	emit(len(text))
+	return out
+}
+
+// terminal reports whether r ends sentences.
+func terminal(r rune) bool {
+	return strings.ContainsRune(".!?…。！？", r)
+}
+
+// wide reports whether r is a full-width terminal mark, which Chinese and
+// Japanese follow with no space.
+func wide(r rune) bool {
+	return strings.ContainsRune("。！？", r)
+}
+
+// opens reports whether r can start a sentence.
+func opens(r rune) bool {
+	return unicode.IsUpper(r) || unicode.IsDigit(r) || strings.ContainsRune(`"'“‘¿¡(`, r) ||
+		unicode.IsLetter(r) && !unicode.IsLower(r) // scripts without case
+}
+
+// BySentence returns a Synthesizer speaking plain text on s one sentence at
+// a time, see Sentences, so that playback starts as soon as the first
+// sentence is synthesized instead of once the backend has rendered the
+// whole text. The next sentence is synthesized while the current one is
+// read. SSML documents are passed through whole. Closing the Synthesizer
+// closes s if it is an io.Closer.
+func BySentence(s Synthesizer) Synthesizer {
+	return &bySentence{s: s}
+}
+
+type bySentence struct {
+	s Synthesizer
+}
+
+// Close closes the wrapped synthesizer if it needs it.
+func (c *bySentence) Close() error {
+	if cl, ok := c.s.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// Synthesize implements Synthesizer. It returns once the first sentence has
+// been requested, failing as the backend does.
+func (c *bySentence) Synthesize(ctx context.Context, req Request) (Stream, error) {
+	if IsSSML(req.Text) {
+		return c.s.Synthesize(ctx, req)
+	}
+	parts := Sentences(req.Text)
+	if len(parts) <= 1 {
+		return c.s.Synthesize(ctx, req)
+	}
+	first, err := c.s.Synthesize(ctx, c.sentence(req, parts[0], 0))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	st := &sentenceStream{cur: first, format: first.Format(), next: make(chan part), cancel: cancel}
+	st.wg.Add(1)
+	go st.prefetch(ctx, c.s, req, parts[1:], c.sentence)
+	return st, nil
+}
+
+// sentence is the request for sentence i of req.
+func (c *bySentence) sentence(req Request, text string, i int) Request {
+	req.Text = text
+	if req.UtteranceID != "" {
+		req.UtteranceID = fmt.Sprintf("%s.%d", req.UtteranceID, i)
+	}
+	return req
+}
+
+// part is a sentence being synthesized, or the failure to start it.
+type part struct {
+	s   Stream
+	err error
+}
+
+// sentenceStream reads the sentences of a request one after the other,
+// stamping continuous offsets on their frames. Close may be called while a
+// read is blocked, to cut playback short.
+type sentenceStream struct {
+	format audio.Format
+	next   chan part // unbuffered: one sentence is synthesized ahead
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	offset time.Duration
+
+	mu     sync.Mutex
+	cur    Stream
+	closed bool
+}
+
+// prefetch starts synthesizing every sentence as soon as the previous one
+// starts being read.
+func (st *sentenceStream) prefetch(ctx context.Context, s Synthesizer, req Request, parts []string, mk func(Request, string, int) Request) {
+	defer st.wg.Done()
+	defer close(st.next)
+	for i, text := range parts {
+		out, err := s.Synthesize(ctx, mk(req, text, i+1))
+		select {
+		case st.next <- part{out, err}:
+		case <-ctx.Done():
+			if out != nil {
+				_ = out.Close()
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (st *sentenceStream) Format() audio.Format { return st.format }
+
+func (st *sentenceStream) ReadFrame() (audio.Frame, error) {
+	for {
+		st.mu.Lock()
+		cur, closed := st.cur, st.closed
+		st.mu.Unlock()
+		switch {
+		case closed:
+			return audio.Frame{}, ErrClosed
+		case cur == nil:
+			return audio.Frame{}, io.EOF
+		}
+		fr, err := cur.ReadFrame()
+		if err == nil {
+			fr.Offset = st.offset
+			st.offset += fr.Duration()
+			return fr, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			return audio.Frame{}, err
+		}
+		_ = cur.Close()
+		p, ok := <-st.next
+		if ok && p.err != nil {
+			return audio.Frame{}, p.err
+		}
+		st.mu.Lock()
+		if st.closed {
+			st.mu.Unlock()
+			if ok {
+				_ = p.s.Close()
+			}
+			return audio.Frame{}, ErrClosed
+		}
+		st.cur = p.s // nil once every sentence has been read
+		st.mu.Unlock()
+		if ok && p.s.Format() != st.format {
+			return audio.Frame{}, fmt.Errorf("tts: sentence format %+v differs from %+v", p.s.Format(), st.format)
+		}
+	}
+}
+
+// Close aborts the sentences still to come and closes the current one.
+func (st *sentenceStream) Close() error {
+	st.mu.Lock()
+	if st.closed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.closed = true
+	cur := st.cur
+	st.mu.Unlock()
+	st.cancel()
+	var err error
+	if cur != nil {
+		err = cur.Close()
+	}
+	st.wg.Wait()
+	return err
+}
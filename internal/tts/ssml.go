@@ -0,0 +1,281 @@
+package tts
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// SSML subset.
+//
+// Backends with native SSML support receive the document unchanged. The
+// others go through Spans, which understands:
+//
+//	<speak>, <p>, <s>          structure; paragraphs and sentences end with a pause
+//	<break time strength>      silence ("250ms", "1s" or a strength keyword)
+//	<sub alias>                speaks alias instead of the contents
+//	<audio>                    skipped, only its fallback text is spoken
+//
+// Every other element (<prosody>, <emphasis>, <say-as>, <voice>, ...) is
+// transparent: its text is spoken with the engine's defaults.
+
+// Span is a piece of text to speak followed by a pause.
+type Span struct {
+	Text  string
+	Pause time.Duration
+}
+
+// Pauses SSML assigns to break strengths, and to the end of <s> and <p>.
+var breakStrength = map[string]time.Duration{
+	"none":     0,
+	"x-weak":   100 * time.Millisecond,
+	"weak":     250 * time.Millisecond,
+	"medium":   400 * time.Millisecond,
+	"strong":   750 * time.Millisecond,
+	"x-strong": 1200 * time.Millisecond,
+}
+
+// IsSSML reports whether text is an SSML document rather than plain text.
+func IsSSML(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "<speak")
+}
+
+// Spans splits text into the pieces an engine without SSML support should
+// speak. Plain text is a single span.
+func Spans(text string) ([]Span, error) {
+	if !IsSSML(text) {
+		return []Span{{Text: strings.TrimSpace(text)}}, nil
+	}
+	var (
+		spans []Span
+		cur   strings.Builder
+		skip  int // depth inside <sub>, whose contents are replaced
+	)
+	flush := func(pause time.Duration) {
+		if t := strings.Join(strings.Fields(cur.String()), " "); t != "" {
+			spans = append(spans, Span{Text: t})
+		}
+		cur.Reset()
+		if pause > 0 {
+			if len(spans) == 0 {
+				spans = append(spans, Span{})
+			}
+			spans[len(spans)-1].Pause += pause
+		}
+	}
+
+	d := xml.NewDecoder(strings.NewReader(text))
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tts: ssml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "break":
+				pause, err := breakPause(t)
+				if err != nil {
+					return nil, err
+				}
+				flush(pause)
+			case "sub":
+				if skip == 0 {
+					cur.WriteString(attr(t, "alias"))
+				}
+				skip++
+			case "audio":
+				// Only the fallback content is spoken.
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "sub":
+				skip--
+			case "s":
+				flush(breakStrength["weak"])
+			case "p":
+				flush(breakStrength["strong"])
+			}
+		case xml.CharData:
+			if skip == 0 {
+				cur.Write(t)
+			}
+		}
+	}
+	flush(0)
+	return spans, nil
+}
+
+// PlainText returns the words of text with all markup removed.
+func PlainText(text string) (string, error) {
+	spans, err := Spans(text)
+	if err != nil {
+		return "", err
+	}
+	words := make([]string, 0, len(spans))
+	for _, sp := range spans {
+		if sp.Text != "" {
+			words = append(words, sp.Text)
+		}
+	}
+	return strings.Join(words, " "), nil
+}
+
+func breakPause(el xml.StartElement) (time.Duration, error) {
+	if v := attr(el, "time"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("tts: ssml: bad break time %q", v)
+		}
+		return d, nil
+	}
+	s := attr(el, "strength")
+	if s == "" {
+		s = "medium"
+	}
+	d, ok := breakStrength[s]
+	if !ok {
+		return 0, fmt.Errorf("tts: ssml: bad break strength %q", s)
+	}
+	return d, nil
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// SpeakFunc synthesizes one span of plain text.
+type SpeakFunc func(ctx context.Context, text string) (Stream, error)
+
+// Render speaks spans one after another through speak, inserting the
+// pauses as silence, and presents the result as a single stream. Spans are
+// synthesized lazily, so the first audio is available as soon as the first
+// span starts producing it.
+func Render(ctx context.Context, format audio.Format, spans []Span, speak SpeakFunc) Stream {
+	ctx, cancel := context.WithCancel(ctx)
+	return &spanStream{ctx: ctx, cancel: cancel, format: format, spans: spans, speak: speak}
+}
+
+type spanStream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	format audio.Format
+	spans  []Span
+	speak  SpeakFunc
+
+	silence int // samples of pause left before the next span
+	offset  int
+
+	mu     sync.Mutex // guards cur and closed against Close
+	cur    Stream     // stream of spans[0] while it is being read
+	closed bool
+}
+
+// current returns the stream being read, and whether s has been closed.
+func (s *spanStream) current() (Stream, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur, s.closed
+}
+
+// setCurrent makes cur the stream being read, unless s has been closed,
+// in which case cur is closed.
+func (s *spanStream) setCurrent(cur Stream) bool {
+	s.mu.Lock()
+	closed := s.closed
+	if !closed {
+		s.cur = cur
+	}
+	s.mu.Unlock()
+	if closed && cur != nil {
+		_ = cur.Close()
+	}
+	return !closed
+}
+
+func (s *spanStream) Format() audio.Format { return s.format }
+
+func (s *spanStream) ReadFrame() (audio.Frame, error) {
+	for {
+		cur, closed := s.current()
+		if closed {
+			return audio.Frame{}, ErrClosed
+		}
+		if err := s.ctx.Err(); err != nil {
+			return audio.Frame{}, err
+		}
+		if s.silence > 0 {
+			n := min(s.silence, s.format.Samples(audio.FrameDuration))
+			s.silence -= n
+			return s.frame(make([]int16, n*s.format.Channels)), nil
+		}
+		if cur != nil {
+			fr, err := cur.ReadFrame()
+			if err == nil {
+				if fr.Format != s.format {
+					return audio.Frame{}, fmt.Errorf("tts: span audio is %+v, want %+v", fr.Format, s.format)
+				}
+				return s.frame(fr.Data), nil
+			}
+			_ = cur.Close()
+			s.setCurrent(nil)
+			if !errors.Is(err, io.EOF) {
+				return audio.Frame{}, err
+			}
+			s.silence = s.format.Samples(s.spans[0].Pause)
+			s.spans = s.spans[1:]
+			continue
+		}
+		if len(s.spans) == 0 {
+			return audio.Frame{}, io.EOF
+		}
+		if s.spans[0].Text == "" {
+			s.silence = s.format.Samples(s.spans[0].Pause)
+			s.spans = s.spans[1:]
+			continue
+		}
+		cur, err := s.speak(s.ctx, s.spans[0].Text)
+		if err != nil {
+			return audio.Frame{}, err
+		}
+		if !s.setCurrent(cur) {
+			return audio.Frame{}, ErrClosed
+		}
+	}
+}
+
+func (s *spanStream) frame(data []int16) audio.Frame {
+	fr := audio.Frame{Format: s.format, Data: data, Offset: s.format.Duration(s.offset)}
+	s.offset += fr.Len()
+	return fr
+}
+
+func (s *spanStream) Close() error {
+	s.mu.Lock()
+	cur, closed := s.cur, s.closed
+	s.closed = true
+	s.mu.Unlock()
+	if closed {
+		return nil
+	}
+	s.cancel()
+	if cur != nil {
+		return cur.Close()
+	}
+	return nil
+}
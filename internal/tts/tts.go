@@ -0,0 +1,133 @@
+// Package tts defines the text-to-speech contracts shared by every synthesis
+// backend: the Synthesizer interface, the audio stream it produces and the
+// SSML subset backends understand.
+//
+// Synthesis is streaming: a Stream yields PCM16 frames as soon as the
+// backend produces them, so playback can start before the whole utterance
+// has been rendered.
+package tts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// ErrClosed is returned when reading from a stream that has been closed.
+var ErrClosed = errors.New("tts: stream closed")
+
+// Request is one utterance to speak.
+type Request struct {
+	// UtteranceID correlates the request with logs and playback.
+	UtteranceID string
+	// Text is plain text, or an SSML document when it starts with <speak>
+	// (see IsSSML). Backends without native SSML support render the
+	// subset described in ssml.go.
+	Text string
+	// Voice selects a backend-specific voice; empty uses the backend
+	// default.
+	Voice string
+}
+
+// Stream is synthesized audio for one request. ReadFrame returns io.EOF
+// once the utterance has been fully delivered.
+type Stream interface {
+	audio.Reader
+	// Close aborts synthesis and releases the stream. It is safe to call
+	// after io.EOF, and from another goroutine while ReadFrame is blocked,
+	// to cut the audio short.
+	Close() error
+}
+
+// Synthesizer speaks text through a backend.
+type Synthesizer interface {
+	// Synthesize starts speaking req. Cancelling ctx aborts the stream.
+	Synthesize(ctx context.Context, req Request) (Stream, error)
+}
+
+// NewPCMStream returns a Stream reading raw little-endian PCM16 audio in
+// format from rc, framed in audio.FrameDuration chunks. Closing the stream
+// closes rc.
+func NewPCMStream(format audio.Format, rc io.ReadCloser) Stream {
+	return &pcmStream{format: format, rc: rc}
+}
+
+type pcmStream struct {
+	format audio.Format
+	rc     io.ReadCloser
+	buf    []byte
+	offset int
+
+	closeOnce sync.Once
+	closed    atomic.Bool
+}
+
+func (s *pcmStream) Format() audio.Format { return s.format }
+
+func (s *pcmStream) ReadFrame() (audio.Frame, error) {
+	if s.closed.Load() {
+		return audio.Frame{}, ErrClosed
+	}
+	size := 2 * s.format.Channels * s.format.Samples(audio.FrameDuration)
+	if cap(s.buf) < size {
+		s.buf = make([]byte, size)
+	}
+	b := s.buf[:size]
+	n, err := io.ReadFull(s.rc, b)
+	n -= n % (2 * s.format.Channels)
+	if n == 0 {
+		if err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		return audio.Frame{}, err
+	}
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return audio.Frame{}, err
+	}
+	fr := audio.Frame{
+		Format: s.format,
+		Data:   audio.DecodePCM16(nil, b[:n]),
+		Offset: s.format.Duration(s.offset),
+	}
+	s.offset += fr.Len()
+	return fr, nil
+}
+
+func (s *pcmStream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+		err = s.rc.Close()
+	})
+	return err
+}
+
+// ReadAll drains s and returns its audio as one frame, then closes s.
+func ReadAll(s Stream) (audio.Frame, error) {
+	defer s.Close()
+	out := audio.Frame{Format: s.Format()}
+	for {
+		fr, err := s.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out.Data = append(out.Data, fr.Data...)
+	}
+}
+
+// NopCloser turns r into a Stream whose Close does nothing, for audio that
+// is already fully in memory.
+func NopCloser(r audio.Reader) Stream {
+	return nopCloser{r}
+}
+
+type nopCloser struct{ audio.Reader }
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,58 @@
+package tts
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoVoices is returned by Voices for synthesizers that cannot list their
+// voices.
+var ErrNoVoices = errors.New("tts: provider does not list voices")
+
+// Voice is a voice a backend speaks with.
+type Voice struct {
+	// ID is what Request.Voice and the "voice" option take.
+	ID string
+	// Name is the display name, when the backend has one besides the ID.
+	Name string
+	// Languages are the BCP-47 codes of the languages the voice speaks,
+	// empty when the backend does not say.
+	Languages []string
+	// Gender is "female", "male" or "neutral", or empty when unknown.
+	Gender string
+}
+
+// VoiceLister is implemented by synthesizers whose backend can list the
+// voices it offers.
+type VoiceLister interface {
+	Voices(ctx context.Context) ([]Voice, error)
+}
+
+// Voices lists the voices s speaks with, via the providers built by New:
+// those of the provider selected, rather than its fallbacks, whose voice
+// IDs a request could not use anyway. It returns ErrNoVoices if s is no
+// VoiceLister.
+func Voices(ctx context.Context, s Synthesizer) ([]Voice, error) {
+	l, ok := s.(VoiceLister)
+	if !ok {
+		return nil, ErrNoVoices
+	}
+	return l.Voices(ctx)
+}
+
+// Voices implements VoiceLister for providers that do.
+func (w *wrapped) Voices(ctx context.Context) ([]Voice, error) { return Voices(ctx, w.s) }
+
+// Voices implements VoiceLister with the voices of the primary provider.
+func (r *resilient) Voices(ctx context.Context) ([]Voice, error) {
+	return Voices(ctx, r.chain[0].s)
+}
+
+// Voices implements VoiceLister for providers that do.
+func (c *cached) Voices(ctx context.Context) ([]Voice, error) { return Voices(ctx, c.s) }
+
+// Voices implements VoiceLister for providers that do.
+func (c *bySentence) Voices(ctx context.Context) ([]Voice, error) { return Voices(ctx, c.s) }
+
+// Voices implements VoiceLister for providers that do.
+func (c *normalized) Voices(ctx context.Context) ([]Voice, error) { return Voices(ctx, c.s) }
@@ -0,0 +1,85 @@
+package tts
+
+import (
+	"context"
+	"io"
+	"unicode/utf8"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/cost"
+	"github.com/jmarc101/voxa/internal/ratelimit"
+)
+
+// wrapped is a Synthesizer accounting its use: syntheses keep to the
+// limits of its account, per Config.RateLimit, each taking from the rate
+// and holding a slot of the concurrency until its stream ends or is
+// closed, and the characters of the requests it speaks are recorded on the
+// cost.Meter of their context, if any.
+type wrapped struct {
+	s     Synthesizer
+	name  string
+	limit *ratelimit.Limiter // nil without Config.RateLimit
+}
+
+// wrap wraps s, built from cfg.
+func wrap(cfg Config, s Synthesizer) (Synthesizer, error) {
+	w := &wrapped{s: s, name: cfg.Provider}
+	if cfg.RateLimit != nil {
+		rc := *cfg.RateLimit
+		if rc.Key == "" {
+			rc.Key = cfg.Provider
+		}
+		var err error
+		if w.limit, err = ratelimit.Shared(rc); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (w *wrapped) Synthesize(ctx context.Context, req Request) (Stream, error) {
+	release := func() {}
+	if w.limit != nil {
+		var err error
+		if release, err = w.limit.Acquire(ctx); err != nil {
+			return nil, err
+		}
+	}
+	st, err := w.s.Synthesize(ctx, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	cost.FromContext(ctx).Characters(w.name, utf8.RuneCountInString(req.Text))
+	if w.limit == nil {
+		return st, nil
+	}
+	return &limitedStream{Stream: st, release: release}, nil
+}
+
+// Close closes the synthesizer if it needs it.
+func (w *wrapped) Close() error {
+	if c, ok := w.s.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// limitedStream returns its slot of the concurrency once it ends.
+type limitedStream struct {
+	Stream
+	release func()
+}
+
+func (s *limitedStream) ReadFrame() (audio.Frame, error) {
+	fr, err := s.Stream.ReadFrame()
+	if err != nil {
+		s.release()
+	}
+	return fr, err
+}
+
+func (s *limitedStream) Close() error {
+	defer s.release()
+	return s.Stream.Close()
+}
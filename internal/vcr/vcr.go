@@ -0,0 +1,642 @@
+// Package vcr records the traffic between provider adapters and their
+// backends to fixture files, and replays it in tests, so adapters can be
+// tested against real backend behaviour without credentials or network
+// access.
+//
+// A Cassette wraps the two transports adapters use: HTTP, as an
+// http.RoundTripper for the HTTPClient of the cloud backends, and gRPC, as
+// client interceptors for the dial options of the sidecars. Recording
+// passes calls through to the backend and saves them on Close; replaying
+// answers them from the fixture alone, in the order they were recorded, and
+// fails calls it has no recording of. Streaming RPCs replay every response
+// once the messages sent before it in the recording have been sent again,
+// or the client has finished sending, so adapters see the responses
+// interleaved with their requests as the backend sent them.
+//
+// Fixtures are JSON: HTTP bodies are kept as text when they are, protobuf
+// messages as their canonical JSON. Credentials are never recorded:
+// request headers are left out, and the query parameters in SecretParams
+// are cut from URLs. Rerecord fixtures by running the tests with the
+// environment variable in EnvMode set to "record".
+package vcr
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"unicode/utf8"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Mode is what a cassette does with calls.
+type Mode int
+
+const (
+	// Replay answers calls from the fixture, which must exist.
+	Replay Mode = iota
+	// Record passes calls through to the backend and saves them to the
+	// fixture on Close, replacing it.
+	Record
+	// Auto replays the fixture if it exists and records it otherwise.
+	Auto
+)
+
+// EnvMode is the environment variable ModeFromEnv reads: "record",
+// "replay" or "auto".
+const EnvMode = "VOXA_VCR"
+
+// ModeFromEnv returns the mode set by EnvMode, Replay if it is unset or
+// unknown.
+func ModeFromEnv() Mode {
+	switch os.Getenv(EnvMode) {
+	case "record":
+		return Record
+	case "auto":
+		return Auto
+	}
+	return Replay
+}
+
+// SecretParams are the URL query parameters never recorded.
+var SecretParams = []string{"key", "api_key", "access_token", "token"}
+
+// Options configures a cassette.
+type Options struct {
+	Mode Mode
+	// CompareRequests fails replayed calls whose requests differ from those
+	// recorded: HTTP bodies and gRPC messages sent. Otherwise calls are
+	// only matched by method and URL. JSON object fields named in
+	// IgnoreFields, at any depth, are left out of the comparison, for
+	// timestamps and generated IDs.
+	CompareRequests bool
+	IgnoreFields    []string
+}
+
+// Cassette records or replays the calls of one fixture file.
+type Cassette struct {
+	path      string
+	opts      Options
+	recording bool
+
+	mu     sync.Mutex
+	fix    fixture
+	used   []bool
+	closed bool
+}
+
+// fixture is the file format.
+type fixture struct {
+	Interactions []*interaction `json:"interactions"`
+}
+
+// interaction is one HTTP exchange or gRPC call.
+type interaction struct {
+	HTTP *httpExchange `json:"http,omitempty"`
+	GRPC *grpcCall     `json:"grpc,omitempty"`
+}
+
+type httpExchange struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody *body       `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        *body       `json:"body,omitempty"`
+}
+
+// body is an HTTP body, kept as text when it is valid UTF-8.
+type body struct {
+	Text   *string `json:"text,omitempty"`
+	Base64 string  `json:"base64,omitempty"`
+}
+
+func newBody(b []byte) *body {
+	if len(b) == 0 {
+		return nil
+	}
+	if utf8.Valid(b) && !bytes.ContainsRune(b, 0) {
+		s := string(b)
+		return &body{Text: &s}
+	}
+	return &body{Base64: base64.StdEncoding.EncodeToString(b)}
+}
+
+func (b *body) bytes() []byte {
+	switch {
+	case b == nil:
+		return nil
+	case b.Text != nil:
+		return []byte(*b.Text)
+	}
+	out, _ := base64.StdEncoding.DecodeString(b.Base64)
+	return out
+}
+
+type grpcCall struct {
+	Method string       `json:"method"`
+	Events []*grpcEvent `json:"events"`
+}
+
+// grpcEvent is one step of a call, in the order the client saw them.
+// Exactly one field is set.
+type grpcEvent struct {
+	Send      json.RawMessage `json:"send,omitempty"`
+	Recv      json.RawMessage `json:"recv,omitempty"`
+	CloseSend bool            `json:"close_send,omitempty"`
+	// Status ends the call.
+	Status *grpcStatus `json:"status,omitempty"`
+}
+
+type grpcStatus struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message,omitempty"`
+}
+
+func (s *grpcStatus) err() error {
+	if s.Code == codes.OK {
+		return nil
+	}
+	return status.Error(s.Code, s.Message)
+}
+
+// Open opens the cassette of the fixture at path.
+func Open(path string, opts Options) (*Cassette, error) {
+	c := &Cassette{path: path, opts: opts}
+	b, err := os.ReadFile(path)
+	switch {
+	case opts.Mode == Record, opts.Mode == Auto && errors.Is(err, fs.ErrNotExist):
+		c.recording = true
+		return c, nil
+	case err != nil:
+		return nil, fmt.Errorf("vcr: %w", err)
+	}
+	if err := json.Unmarshal(b, &c.fix); err != nil {
+		return nil, fmt.Errorf("vcr: %s: %w", path, err)
+	}
+	c.used = make([]bool, len(c.fix.Interactions))
+	return c, nil
+}
+
+// Recording reports whether the cassette records.
+func (c *Cassette) Recording() bool { return c.recording }
+
+// Close saves the fixture when recording. When replaying, it reports the
+// recorded calls that were not made again.
+func (c *Cassette) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if !c.recording {
+		if n := len(c.used) - count(c.used); n > 0 {
+			return fmt.Errorf("vcr: %s: %d recorded calls were not made", c.path, n)
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(c.fix, "", "  ")
+	if err != nil {
+		return fmt.Errorf("vcr: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("vcr: %w", err)
+	}
+	if err := os.WriteFile(c.path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("vcr: %w", err)
+	}
+	return nil
+}
+
+func count(used []bool) int {
+	n := 0
+	for _, u := range used {
+		if u {
+			n++
+		}
+	}
+	return n
+}
+
+// record appends an interaction.
+func (c *Cassette) record(in *interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fix.Interactions = append(c.fix.Interactions, in)
+}
+
+// take returns the first recorded interaction not replayed yet that match
+// accepts, and marks it replayed.
+func (c *Cassette) take(match func(*interaction) bool) *interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, in := range c.fix.Interactions {
+		if !c.used[i] && match(in) {
+			c.used[i] = true
+			return in
+		}
+	}
+	return nil
+}
+
+// sameJSON reports whether two JSON documents are equal once the ignored
+// fields are dropped, or, if either is not JSON, whether they are the same
+// bytes.
+func (c *Cassette) sameJSON(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(c.strip(va), c.strip(vb))
+}
+
+func (c *Cassette) strip(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if slices.Contains(c.opts.IgnoreFields, k) {
+				delete(v, k)
+			} else {
+				v[k] = c.strip(e)
+			}
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = c.strip(e)
+		}
+	}
+	return v
+}
+
+// redact cuts the SecretParams from u.
+func redact(u *url.URL) string {
+	r := *u
+	q := r.Query()
+	for _, p := range SecretParams {
+		q.Del(p)
+	}
+	r.RawQuery = q.Encode()
+	r.User = nil
+	return r.String()
+}
+
+// HTTPClient returns a client whose transport is Transport(nil).
+func (c *Cassette) HTTPClient() *http.Client {
+	return &http.Client{Transport: c.Transport(nil)}
+}
+
+// Transport returns a round tripper recording the exchanges of next, which
+// defaults to http.DefaultTransport, or replaying them.
+func (c *Cassette) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper{c, next}
+}
+
+type roundTripper struct {
+	c    *Cassette
+	next http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+	u := redact(req.URL)
+	if rt.c.recording {
+		resp, err := rt.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		rt.c.record(&interaction{HTTP: &httpExchange{
+			Method:      req.Method,
+			URL:         u,
+			RequestBody: newBody(reqBody),
+			Status:      resp.StatusCode,
+			Header:      resp.Header,
+			Body:        newBody(b),
+		}})
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		return resp, nil
+	}
+
+	in := rt.c.take(func(in *interaction) bool {
+		return in.HTTP != nil && in.HTTP.Method == req.Method && in.HTTP.URL == u
+	})
+	if in == nil {
+		return nil, fmt.Errorf("vcr: no recording of %s %s", req.Method, u)
+	}
+	ex := in.HTTP
+	if rt.c.opts.CompareRequests && !rt.c.sameJSON(ex.RequestBody.bytes(), reqBody) {
+		return nil, fmt.Errorf("vcr: %s %s: request body differs from the recording", req.Method, u)
+	}
+	b := ex.Body.bytes()
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", ex.Status, http.StatusText(ex.Status)),
+		StatusCode:    ex.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        ex.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}, nil
+}
+
+// DialOptions returns the gRPC dial options recording or replaying the
+// calls of a client connection.
+func (c *Cassette) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(c.unary),
+		grpc.WithStreamInterceptor(c.stream),
+	}
+}
+
+func marshal(m any) (json.RawMessage, error) {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("vcr: %T is not a protobuf message", m)
+	}
+	return protojson.Marshal(pm)
+}
+
+func unmarshal(b json.RawMessage, m any) error {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Errorf("vcr: %T is not a protobuf message", m)
+	}
+	return protojson.Unmarshal(b, pm)
+}
+
+func statusOf(err error) *grpcStatus {
+	st := status.Convert(err)
+	return &grpcStatus{Code: st.Code(), Message: st.Message()}
+}
+
+func (c *Cassette) unary(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	sent, err := marshal(req)
+	if err != nil {
+		return err
+	}
+	if c.recording {
+		call := &grpcCall{Method: method, Events: []*grpcEvent{{Send: sent}}}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			got, merr := marshal(reply)
+			if merr != nil {
+				return merr
+			}
+			call.Events = append(call.Events, &grpcEvent{Recv: got})
+		}
+		call.Events = append(call.Events, &grpcEvent{Status: statusOf(err)})
+		c.record(&interaction{GRPC: call})
+		return err
+	}
+
+	in := c.take(func(in *interaction) bool { return in.GRPC != nil && in.GRPC.Method == method })
+	if in == nil {
+		return status.Errorf(codes.Unavailable, "vcr: no recording of %s", method)
+	}
+	for _, ev := range in.GRPC.Events {
+		switch {
+		case ev.Send != nil:
+			if c.opts.CompareRequests && !c.sameJSON(ev.Send, sent) {
+				return status.Errorf(codes.InvalidArgument, "vcr: %s: request differs from the recording", method)
+			}
+		case ev.Recv != nil:
+			if err := unmarshal(ev.Recv, reply); err != nil {
+				return err
+			}
+		case ev.Status != nil:
+			return ev.Status.err()
+		}
+	}
+	return nil
+}
+
+func (c *Cassette) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if c.recording {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, err
+		}
+		call := &grpcCall{Method: method}
+		c.record(&interaction{GRPC: call})
+		return &recordingStream{ClientStream: cs, c: c, call: call}, nil
+	}
+	in := c.take(func(in *interaction) bool { return in.GRPC != nil && in.GRPC.Method == method })
+	if in == nil {
+		return nil, status.Errorf(codes.Unavailable, "vcr: no recording of %s", method)
+	}
+	return newReplayStream(ctx, c, in.GRPC), nil
+}
+
+// recordingStream records the messages of a live stream.
+type recordingStream struct {
+	grpc.ClientStream
+	c    *Cassette
+	call *grpcCall
+	done bool
+}
+
+func (s *recordingStream) add(ev *grpcEvent) {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	if !s.done {
+		s.call.Events = append(s.call.Events, ev)
+		s.done = ev.Status != nil
+	}
+}
+
+func (s *recordingStream) SendMsg(m any) error {
+	b, err := marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := s.ClientStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.add(&grpcEvent{Send: b})
+	return nil
+}
+
+func (s *recordingStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err == nil {
+		s.add(&grpcEvent{CloseSend: true})
+	}
+	return err
+}
+
+func (s *recordingStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case errors.Is(err, io.EOF):
+		s.add(&grpcEvent{Status: &grpcStatus{Code: codes.OK}})
+	case err != nil:
+		s.add(&grpcEvent{Status: statusOf(err)})
+	default:
+		b, merr := marshal(m)
+		if merr != nil {
+			return merr
+		}
+		s.add(&grpcEvent{Recv: b})
+	}
+	return err
+}
+
+// replayStream plays a recorded stream back.
+type replayStream struct {
+	ctx    context.Context
+	c      *Cassette
+	call   *grpcCall
+	sends  []json.RawMessage // recorded, in order
+	change chan struct{}     // closed and replaced on every send
+
+	mu         sync.Mutex
+	sent       int
+	closedSend bool
+	next       int // event of the next response
+	err        error
+}
+
+func newReplayStream(ctx context.Context, c *Cassette, call *grpcCall) *replayStream {
+	s := &replayStream{ctx: ctx, c: c, call: call, change: make(chan struct{})}
+	for _, ev := range call.Events {
+		if ev.Send != nil {
+			s.sends = append(s.sends, ev.Send)
+		}
+	}
+	return s
+}
+
+func (s *replayStream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (s *replayStream) Trailer() metadata.MD         { return metadata.MD{} }
+func (s *replayStream) Context() context.Context     { return s.ctx }
+
+func (s *replayStream) SendMsg(m any) error {
+	b, err := marshal(m)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closedSend {
+		return errors.New("vcr: send after CloseSend")
+	}
+	if s.c.opts.CompareRequests {
+		if s.sent >= len(s.sends) {
+			return status.Errorf(codes.InvalidArgument, "vcr: %s: more messages sent than recorded", s.call.Method)
+		}
+		if !s.c.sameJSON(s.sends[s.sent], b) {
+			return status.Errorf(codes.InvalidArgument, "vcr: %s: message %d differs from the recording", s.call.Method, s.sent+1)
+		}
+	}
+	s.sent++
+	s.notify()
+	return nil
+}
+
+func (s *replayStream) CloseSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closedSend = true
+	s.notify()
+	return nil
+}
+
+// notify wakes RecvMsg; it must be called with s.mu held.
+func (s *replayStream) notify() {
+	close(s.change)
+	s.change = make(chan struct{})
+}
+
+// RecvMsg returns the next recorded response once the messages sent before
+// it in the recording have been sent, or the client has stopped sending.
+func (s *replayStream) RecvMsg(m any) error {
+	for {
+		s.mu.Lock()
+		if s.err != nil {
+			err := s.err
+			s.mu.Unlock()
+			return err
+		}
+		i := s.next
+		for i < len(s.call.Events) && s.call.Events[i].Recv == nil && s.call.Events[i].Status == nil {
+			i++
+		}
+		if s.sent >= s.sendsBefore(i) || s.closedSend {
+			s.next = i + 1
+			var ev *grpcEvent
+			if i < len(s.call.Events) {
+				ev = s.call.Events[i]
+			}
+			var err error
+			switch {
+			case ev == nil:
+				err = errors.New("vcr: recording ends without a status")
+			case ev.Recv != nil:
+				err = unmarshal(ev.Recv, m)
+			case ev.Status.Code == codes.OK:
+				err = io.EOF
+			default:
+				err = ev.Status.err()
+			}
+			if ev == nil || ev.Status != nil {
+				s.err = err
+			}
+			s.mu.Unlock()
+			return err
+		}
+		change := s.change
+		s.mu.Unlock()
+		select {
+		case <-change:
+		case <-s.ctx.Done():
+			return status.FromContextError(s.ctx.Err()).Err()
+		}
+	}
+}
+
+// sendsBefore counts the messages sent before event i.
+func (s *replayStream) sendsBefore(i int) int {
+	n := 0
+	for _, ev := range s.call.Events[:i] {
+		if ev.Send != nil {
+			n++
+		}
+	}
+	return n
+}
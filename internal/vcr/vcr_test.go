@@ -0,0 +1,170 @@
+package vcr_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	speechv1 "github.com/jmarc101/voxa/api/gen/voxa/speech/v1"
+	"github.com/jmarc101/voxa/internal/clients/asr"
+	"github.com/jmarc101/voxa/internal/stt"
+	"github.com/jmarc101/voxa/internal/vcr"
+)
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprintf(w, "%s\x00%s", r.URL.Path, b)
+	}))
+	path := filepath.Join(t.TempDir(), "http.json")
+	get := func(c *vcr.Cassette, body string) string {
+		t.Helper()
+		resp, err := c.HTTPClient().Post(srv.URL+"/speak?key=secret", "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	rec, err := vcr.Open(path, vcr.Options{Mode: vcr.Record})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{get(rec, "hello"), get(rec, "world")}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	play, err := vcr.Open(path, vcr.Options{CompareRequests: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, body := range []string{"hello", "world"} {
+		if got := get(play, body); got != want[i] {
+			t.Errorf("replay %d = %q, want %q", i, got, want[i])
+		}
+	}
+	if err := play.Close(); err != nil {
+		t.Error(err)
+	}
+
+	play, _ = vcr.Open(path, vcr.Options{CompareRequests: true})
+	if _, err := play.HTTPClient().Post(srv.URL+"/speak", "text/plain", strings.NewReader("changed")); err == nil {
+		t.Error("replaying a changed request succeeded")
+	}
+}
+
+// echoASR answers every audio chunk with a partial of its size, and the end
+// of the stream with a final.
+type echoASR struct {
+	speechv1.UnimplementedAsrServer
+}
+
+func (echoASR) StreamingRecognize(rpc grpc.BidiStreamingServer[speechv1.StreamingRecognizeRequest, speechv1.StreamingRecognizeResponse]) error {
+	n := 0
+	for {
+		req, err := rpc.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if a := req.GetAudio(); a != nil {
+			n += len(a.GetData())
+			if err := rpc.Send(&speechv1.StreamingRecognizeResponse{
+				UtteranceId: "u1",
+				Type:        speechv1.ResponseType_PARTIAL,
+				Result:      &speechv1.StreamingRecognizeResponse_PartialTranscript{PartialTranscript: &speechv1.Transcript{Text: fmt.Sprint(n)}},
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.GetControl().GetType() == speechv1.ControlType_END {
+			if err := rpc.Send(&speechv1.StreamingRecognizeResponse{
+				UtteranceId: "u1",
+				Type:        speechv1.ResponseType_FINAL,
+				Result:      &speechv1.StreamingRecognizeResponse_FinalTranscript{FinalTranscript: &speechv1.Transcript{Text: fmt.Sprintf("%d bytes", n)}},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func transcribe(t *testing.T, addr string, c *vcr.Cassette) []string {
+	t.Helper()
+	client, err := asr.Dial(addr, c.DialOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	s, err := client.NewStream(context.Background(), stt.StreamConfig{UtteranceID: "u1", SampleRate: 16000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if _, err := s.Write(make([]byte, 640)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for seg := range s.Results() {
+		texts = append(texts, seg.Text)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return texts
+}
+
+func TestGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	speechv1.RegisterAsrServer(srv, echoASR{})
+	go srv.Serve(lis)
+	path := filepath.Join(t.TempDir(), "asr.json")
+
+	rec, err := vcr.Open(path, vcr.Options{Mode: vcr.Record})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := transcribe(t, lis.Addr().String(), rec)
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Stop()
+	if strings.Join(want, ",") != "640,1280,1920,1920 bytes" {
+		t.Fatalf("recorded %q", want)
+	}
+
+	play, err := vcr.Open(path, vcr.Options{CompareRequests: true, IgnoreFields: []string{"emitTime"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transcribe(t, lis.Addr().String(), play); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("replayed %q, want %q", got, want)
+	}
+	if err := play.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,311 @@
+// Package vocab corrects transcripts against a custom vocabulary: the
+// product names, jargon and other phrases a recognizer that cannot be
+// biased toward them keeps mangling.
+//
+// A Corrector replaces every span of words that comes close enough to one
+// of its phrases with the phrase as written. Spans are compared both by
+// their letters, which catches misspellings such as "voxxa" for "Voxa",
+// and by a rough phonetic key, which catches sound-alikes split into other
+// words such as "cooper netties" for "Kubernetes". Spaces and punctuation
+// do not count, and a span may hold one word more or less than its phrase.
+// How close is close enough is a bound on the edit distance over the length
+// of the phrase, the Tolerance, scaled by the phrase's boost.
+package vocab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Defaults.
+const (
+	defaultTolerance = 0.25
+	maxTolerance     = 0.5
+	// maxWords bounds the words of a phrase, and so of the spans compared
+	// with it.
+	maxWords = 6
+)
+
+// Config configures a Corrector.
+type Config struct {
+	// Phrases are the vocabulary. Their boost, on recognizers that take
+	// phrases, weights them relative to each other; here it scales
+	// Tolerance.
+	Phrases []stt.Phrase
+	// Tolerance is how far a span of words must come to a phrase to be
+	// corrected to it: below this edit distance over the length of the
+	// phrase, in letters, or half of it between their phonetic keys.
+	// Defaults to 0.25, so a ten-letter phrase is corrected from two
+	// letters off while phrases of four letters or less only get their
+	// case fixed. A phrase's boost multiplies it, up to 0.5.
+	Tolerance float64
+	// Always corrects transcripts even when the recognizer biases toward
+	// the phrases itself, which does not guarantee their spelling. The
+	// pipeline reads it; a Corrector always corrects.
+	Always bool
+}
+
+// Corrector rewrites transcripts toward a vocabulary. It is safe for
+// concurrent use.
+type Corrector struct {
+	cfg     Config
+	phrases []phrase
+}
+
+// phrase is a vocabulary entry, ready for comparing.
+type phrase struct {
+	text    string
+	letters string // lowercase letters and digits
+	key     string // phonetic key of letters
+	words   int
+	tol     float64
+}
+
+// New validates cfg.
+func New(cfg Config) (*Corrector, error) {
+	if cfg.Tolerance < 0 || cfg.Tolerance >= 1 {
+		return nil, fmt.Errorf("vocab: tolerance %v out of [0, 1)", cfg.Tolerance)
+	}
+	if cfg.Tolerance == 0 {
+		cfg.Tolerance = defaultTolerance
+	}
+	c := &Corrector{cfg: cfg}
+	if err := c.add(cfg.Phrases); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// With returns a Corrector for the phrases of c and more, such as those of
+// one stream.
+func (c *Corrector) With(more []stt.Phrase) (*Corrector, error) {
+	if len(more) == 0 {
+		return c, nil
+	}
+	out := &Corrector{cfg: c.cfg, phrases: c.phrases[:len(c.phrases):len(c.phrases)]}
+	out.cfg.Phrases = append(c.cfg.Phrases[:len(c.cfg.Phrases):len(c.cfg.Phrases)], more...)
+	if err := out.add(more); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Corrector) add(phrases []stt.Phrase) error {
+	for _, p := range phrases {
+		text := strings.Join(strings.Fields(p.Text), " ")
+		switch {
+		case text == "":
+			return errors.New("vocab: empty phrase")
+		case p.Boost < 0:
+			return fmt.Errorf("vocab: phrase %q: negative boost %v", text, p.Boost)
+		}
+		words := strings.Count(text, " ") + 1
+		if words > maxWords {
+			return fmt.Errorf("vocab: phrase %q: more than %d words", text, maxWords)
+		}
+		letters := fold(text)
+		if letters == "" {
+			return fmt.Errorf("vocab: phrase %q has no letters", text)
+		}
+		boost := float64(p.Boost)
+		if boost == 0 {
+			boost = 1
+		}
+		c.phrases = append(c.phrases, phrase{
+			text:    text,
+			letters: letters,
+			key:     key(letters),
+			words:   words,
+			tol:     min(c.cfg.Tolerance*boost, maxTolerance),
+		})
+	}
+	return nil
+}
+
+// Process corrects the text and words of seg. It implements
+// plugin.Transcript, so the corrector runs like any other transcript
+// stage.
+func (c *Corrector) Process(_ context.Context, seg *stt.Segment) error {
+	fields := strings.Fields(seg.Text)
+	type word struct{ lead, trail, letters string }
+	ws := make([]word, len(fields))
+	for i, f := range fields {
+		core := strings.TrimLeftFunc(f, unicode.IsPunct)
+		trimmed := strings.TrimRightFunc(core, unicode.IsPunct)
+		ws[i] = word{f[:len(f)-len(core)], core[len(trimmed):], fold(trimmed)}
+	}
+	var spans []stt.Span
+	changed := false
+	for i := 0; i < len(fields); {
+		// A span never crosses punctuation.
+		end := i + 1
+		for end < len(fields) && end-i <= maxWords && ws[end-1].trail == "" && ws[end].lead == "" {
+			end++
+		}
+		var best *phrase
+		n, score := 0, 2.0
+		for w := 1; w <= end-i; w++ {
+			var letters strings.Builder
+			for _, x := range ws[i : i+w] {
+				letters.WriteString(x.letters)
+			}
+			if letters.Len() == 0 {
+				continue
+			}
+			p, s := c.match(letters.String(), w)
+			if p == nil || s >= score {
+				continue
+			}
+			// A word the phrase does not need is left to the words around
+			// it: "is" stays out of "is kubernetes".
+			if rest := letters.String()[len(ws[i].letters):]; w > 1 && rest != "" && p.score(rest, w-1) <= s {
+				continue
+			}
+			best, n, score = p, w, s
+		}
+		if best == nil {
+			spans = append(spans, stt.Span{N: 1, Text: fields[i]})
+			i++
+			continue
+		}
+		text := ws[i].lead + best.text + ws[i+n-1].trail
+		if n > 1 || text != fields[i] {
+			changed = true
+		}
+		spans = append(spans, stt.Span{N: n, Text: text})
+		i += n
+	}
+	if !changed {
+		return nil
+	}
+	stt.RewriteSpans(seg, spans)
+	return nil
+}
+
+// match returns the phrase closest to a span of words with the given
+// letters, and its score, or nil if none is within tolerance.
+func (c *Corrector) match(letters string, words int) (*phrase, float64) {
+	var best *phrase
+	score := 2.0
+	for i := range c.phrases {
+		p := &c.phrases[i]
+		if s := p.score(letters, words); s < score {
+			best, score = p, s
+		}
+	}
+	return best, score
+}
+
+// score returns the distance of a span of words with the given letters to
+// p over the length of p, or 2 if it is not within tolerance.
+func (p *phrase) score(letters string, words int) float64 {
+	if words < p.words-1 || words > p.words+1 {
+		return 2
+	}
+	k := key(letters)
+	n, nk := utf8.RuneCountInString(p.letters), utf8.RuneCountInString(p.key)
+	limit := int(p.tol * float64(n))
+	if abs(utf8.RuneCountInString(letters)-n) > limit && abs(utf8.RuneCountInString(k)-nk) > limit/2 {
+		return 2
+	}
+	s := float64(distance(letters, p.letters)) / float64(n)
+	if s >= p.tol && nk > 0 {
+		// Keys lose information, so they must come twice as close.
+		if ks := float64(distance(k, p.key)) / float64(nk); ks < p.tol/2 {
+			s = ks
+		}
+	}
+	if s >= p.tol {
+		return 2
+	}
+	return s
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// fold returns the lowercase letters and digits of s.
+func fold(s string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(s) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// key returns a rough phonetic key of folded letters: letters that sound
+// alike map to one, vowels to "a", and repeats collapse, so "cooper
+// netties" and "kubernetes" share "kaparnatas".
+func key(letters string) string {
+	var single strings.Builder
+	var last rune
+	for _, c := range letters {
+		if c != last {
+			single.WriteRune(c)
+			last = c
+		}
+	}
+	var b strings.Builder
+	last = 0
+	for i, c := range keyDigraphs.Replace(single.String()) {
+		switch c {
+		case 'c', 'q', 'g':
+			c = 'k'
+		case 'b':
+			c = 'p'
+		case 'd':
+			c = 't'
+		case 'v':
+			c = 'f'
+		case 'z':
+			c = 's'
+		case 'a', 'e', 'i', 'o', 'u', 'y':
+			c = 'a'
+		case 'h':
+			if i > 0 {
+				continue
+			}
+		}
+		if c != last {
+			b.WriteRune(c)
+			last = c
+		}
+	}
+	return b.String()
+}
+
+var keyDigraphs = strings.NewReplacer("ph", "f", "ck", "k", "qu", "kw", "wh", "w", "x", "ks")
+
+// distance is the Levenshtein distance between a and b, in runes.
+func distance(sa, sb string) int {
+	a, b := []rune(sa), []rune(sb)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
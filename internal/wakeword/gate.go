@@ -0,0 +1,102 @@
+package wakeword
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// Config configures a Gate.
+type Config struct {
+	// Words are registered on the built-in TemplateSpotter by New.
+	Words []Word
+	// Model, if set, spots the wake words with a trained model, such as an
+	// ONNX one, rather than the TemplateSpotter; Words are then ignored.
+	Model Model
+	// ListenWindow bounds how long the gate stays open after a detection if
+	// nothing re-arms it. Defaults to 8s.
+	ListenWindow time.Duration
+	// OnDetect, if set, is called synchronously for every detection.
+	OnDetect func(Detection)
+}
+
+// Gate is an audio.Stage that blocks audio until a wake word is heard.
+// It is not safe for concurrent use.
+type Gate struct {
+	cfg     Config
+	spotter Spotter
+	open    bool
+	until   time.Duration
+	pass    [1]audio.Frame // returned by Process, reused
+}
+
+var _ audio.Stage = (*Gate)(nil)
+
+// Model spots wake words with a trained model.
+type Model interface {
+	// NewSpotter returns a spotter of one stream of audio at sampleRate.
+	NewSpotter(sampleRate int) (Spotter, error)
+}
+
+// New creates a Gate backed by a TemplateSpotter with cfg.Words registered,
+// or by a spotter of cfg.Model.
+func New(cfg Config, sampleRate int) (*Gate, error) {
+	if cfg.Model != nil {
+		s, err := cfg.Model.NewSpotter(sampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("wakeword: %w", err)
+		}
+		return NewGate(s, cfg), nil
+	}
+	s := NewTemplateSpotter(sampleRate)
+	for _, w := range cfg.Words {
+		if err := s.Register(w); err != nil {
+			return nil, err
+		}
+	}
+	return NewGate(s, cfg), nil
+}
+
+// NewGate creates a Gate around any Spotter. cfg.Words is ignored.
+func NewGate(s Spotter, cfg Config) *Gate {
+	if cfg.ListenWindow == 0 {
+		cfg.ListenWindow = 8 * time.Second
+	}
+	return &Gate{cfg: cfg, spotter: s}
+}
+
+// Spotter returns the engine, e.g. to register more words on a
+// TemplateSpotter.
+func (g *Gate) Spotter() Spotter { return g.spotter }
+
+// Open reports whether audio is currently being forwarded.
+func (g *Gate) Open() bool { return g.open }
+
+// Rearm closes the gate so the next turn needs a new wake word. The
+// pipeline calls it when an utterance ends.
+func (g *Gate) Rearm() { g.open = false }
+
+// Process forwards fr only while the gate is open. The audio of the wake
+// phrase itself is not forwarded.
+func (g *Gate) Process(fr audio.Frame) ([]audio.Frame, error) {
+	if g.open {
+		if fr.Offset < g.until {
+			g.pass[0] = fr
+			return g.pass[:], nil
+		}
+		g.open = false
+	}
+	hits, err := g.spotter.Detect(fr)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hits {
+		if g.cfg.OnDetect != nil {
+			g.cfg.OnDetect(h)
+		}
+		g.open = true
+		g.until = h.Offset + g.cfg.ListenWindow
+	}
+	return nil, nil
+}
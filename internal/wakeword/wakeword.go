@@ -0,0 +1,326 @@
+// Package wakeword gates the audio path behind one or more wake phrases.
+//
+// A Spotter scans the continuous mic stream for keywords. The built-in
+// TemplateSpotter needs no model files: it compares MFCC features of the
+// live audio with a few enrolled recordings of each phrase using subsequence
+// dynamic time warping. Model-based engines (Porcupine, a Python sidecar)
+// plug in through the same interface.
+//
+// Gate is the pipeline stage: it forwards nothing until a wake word is
+// detected, then opens for a listening window until it is re-armed.
+package wakeword
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/dsp"
+)
+
+// Detection reports a wake word hit.
+type Detection struct {
+	Phrase string
+	// Offset is the stream time at which the phrase ended.
+	Offset time.Duration
+	// Score is the match quality in [0, 1]; higher is better.
+	Score float64
+}
+
+// Spotter is a keyword-spotting engine running on the raw audio stream.
+type Spotter interface {
+	// Detect consumes fr and returns the wake words that ended in it.
+	Detect(fr audio.Frame) ([]Detection, error)
+}
+
+// Word is a wake phrase to listen for.
+type Word struct {
+	Phrase string
+	// Sensitivity in (0, 1]; higher accepts looser matches and so more
+	// false alarms. Defaults to 0.5.
+	Sensitivity float64
+	// Templates are enrolled recordings of the phrase.
+	Templates []Template
+}
+
+// Template is the feature sequence of one enrolled recording.
+type Template struct {
+	rate     int
+	features [][]float64
+}
+
+// NewTemplate computes a template from a recording of the wake phrase. The
+// recording should be trimmed to the phrase itself.
+func NewTemplate(r audio.Reader) (Template, error) {
+	f := r.Format()
+	m := dsp.NewMFCC(dsp.MFCCConfig{SampleRate: f.SampleRate})
+	var feats [][]float64
+	var buf []float64
+	for {
+		fr, err := r.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Template{}, err
+		}
+		buf = mono(buf, fr)
+		feats = append(feats, m.Push(buf)...)
+	}
+	if len(feats) < 10 {
+		return Template{}, errors.New("wakeword: template recording too short")
+	}
+	return Template{rate: f.SampleRate, features: normalize(feats)}, nil
+}
+
+// Duration returns the length of the enrolled phrase.
+func (t Template) Duration() time.Duration {
+	return time.Duration(len(t.features)) * 10 * time.Millisecond
+}
+
+// TemplateSpotter is the built-in template-matching Spotter. Words can be
+// registered and removed while it is running.
+type TemplateSpotter struct {
+	rate int
+	mfcc *dsp.MFCC
+
+	mu      sync.Mutex
+	words   map[string]Word
+	history [][]float64 // recent feature vectors, oldest first
+	maxLen  int         // history length needed by the longest template
+	hops    int
+	muted   time.Duration // refractory: ignore hits until this offset
+	peak    *Detection    // best hit of the match in progress
+	buf     []float64
+}
+
+// checkEvery is how many 10ms hops pass between template comparisons.
+const checkEvery = 3
+
+// refractory suppresses repeated hits on the same utterance.
+const refractory = time.Second
+
+// NewTemplateSpotter creates an empty spotter for audio at sampleRate.
+func NewTemplateSpotter(sampleRate int) *TemplateSpotter {
+	return &TemplateSpotter{
+		rate:  sampleRate,
+		mfcc:  dsp.NewMFCC(dsp.MFCCConfig{SampleRate: sampleRate}),
+		words: make(map[string]Word),
+	}
+}
+
+// Register adds or replaces a wake word.
+func (s *TemplateSpotter) Register(w Word) error {
+	if w.Phrase == "" {
+		return errors.New("wakeword: empty phrase")
+	}
+	if len(w.Templates) == 0 {
+		return fmt.Errorf("wakeword: %q has no templates", w.Phrase)
+	}
+	if w.Sensitivity == 0 {
+		w.Sensitivity = 0.5
+	}
+	if w.Sensitivity < 0 || w.Sensitivity > 1 {
+		return fmt.Errorf("wakeword: %q sensitivity %v out of range (0, 1]", w.Phrase, w.Sensitivity)
+	}
+	for _, t := range w.Templates {
+		if t.rate != s.rate {
+			return fmt.Errorf("wakeword: %q template is %d Hz, spotter runs at %d Hz", w.Phrase, t.rate, s.rate)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.words[w.Phrase] = w
+	s.resize()
+	return nil
+}
+
+// Unregister removes a wake word.
+func (s *TemplateSpotter) Unregister(phrase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.words, phrase)
+	s.resize()
+}
+
+// Words returns the registered phrases.
+func (s *TemplateSpotter) Words() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.words))
+	for p := range s.words {
+		out = append(out, p)
+	}
+	return out
+}
+
+func (s *TemplateSpotter) resize() {
+	s.maxLen = 0
+	for _, w := range s.words {
+		for _, t := range w.Templates {
+			s.maxLen = max(s.maxLen, len(t.features)*3/2)
+		}
+	}
+}
+
+// Detect implements Spotter.
+func (s *TemplateSpotter) Detect(fr audio.Frame) ([]Detection, error) {
+	if fr.Format.SampleRate != s.rate {
+		return nil, fmt.Errorf("wakeword: frame is %d Hz, spotter runs at %d Hz", fr.Format.SampleRate, s.rate)
+	}
+	s.buf = mono(s.buf, fr)
+	feats := s.mfcc.Push(s.buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hits []Detection
+	end := fr.Offset + fr.Duration()
+	for _, f := range feats {
+		s.history = append(s.history, f)
+		if over := len(s.history) - s.maxLen; over > 0 {
+			s.history = s.history[over:]
+		}
+		s.hops++
+		if s.hops%checkEvery != 0 || end < s.muted {
+			continue
+		}
+		// Report a match at its peak: keep following it while the score
+		// improves, so the hit lands on the end of the phrase.
+		d, ok := s.best()
+		if ok && (s.peak == nil || d.Score >= s.peak.Score) {
+			d.Offset = end
+			s.peak = &d
+			continue
+		}
+		if s.peak != nil {
+			hits = append(hits, *s.peak)
+			s.muted = end + refractory
+			s.history = s.history[:0]
+			s.peak = nil
+		}
+	}
+	return hits, nil
+}
+
+// best returns the best-scoring word whose distance is under its threshold.
+func (s *TemplateSpotter) best() (Detection, bool) {
+	var hit Detection
+	found := false
+	for _, w := range s.words {
+		threshold := 1 + 2*w.Sensitivity
+		for _, t := range w.Templates {
+			n := len(t.features) * 3 / 2
+			if len(s.history) < len(t.features) {
+				continue
+			}
+			// Normalize with the statistics of the most recent template-length
+			// span, where the phrase would be, not the slack around it.
+			cand := s.history[max(0, len(s.history)-n):]
+			mean, std := stats(cand[len(cand)-len(t.features):])
+			dist := subsequenceDTW(t.features, apply(cand, mean, std))
+			if dist >= threshold {
+				continue
+			}
+			score := 1 - dist/threshold
+			if !found || score > hit.Score {
+				hit, found = Detection{Phrase: w.Phrase, Score: score}, true
+			}
+		}
+	}
+	return hit, found
+}
+
+// subsequenceDTW returns the per-frame cost of the best alignment of the
+// whole template against a suffix of cand that ends at its last frame.
+func subsequenceDTW(tmpl, cand [][]float64) float64 {
+	prev := make([]float64, len(cand))
+	cur := make([]float64, len(cand))
+	for j := range cand {
+		prev[j] = dist(tmpl[0], cand[j]) // free start anywhere in cand
+	}
+	for i := 1; i < len(tmpl); i++ {
+		cur[0] = prev[0] + dist(tmpl[i], cand[0])
+		for j := 1; j < len(cand); j++ {
+			cur[j] = dist(tmpl[i], cand[j]) + min(prev[j], cur[j-1], prev[j-1])
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(cand)-1] / float64(len(tmpl))
+}
+
+func dist(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// normalize applies cepstral mean and variance normalization, which removes
+// the channel (microphone, room, level) from the comparison.
+func normalize(feats [][]float64) [][]float64 {
+	if len(feats) == 0 {
+		return nil
+	}
+	mean, std := stats(feats)
+	return apply(feats, mean, std)
+}
+
+// stats returns the per-dimension mean and standard deviation of feats.
+func stats(feats [][]float64) (mean, std []float64) {
+	dim := len(feats[0])
+	mean = make([]float64, dim)
+	for _, f := range feats {
+		for i, v := range f {
+			mean[i] += v
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(len(feats))
+	}
+	std = make([]float64, dim)
+	for _, f := range feats {
+		for i, v := range f {
+			std[i] += (v - mean[i]) * (v - mean[i])
+		}
+	}
+	for i := range std {
+		std[i] = math.Sqrt(std[i]/float64(len(feats))) + 1e-6
+	}
+	return mean, std
+}
+
+func apply(feats [][]float64, mean, std []float64) [][]float64 {
+	dim := len(mean)
+	out := make([][]float64, len(feats))
+	for k, f := range feats {
+		row := make([]float64, dim)
+		for i, v := range f {
+			row[i] = (v - mean[i]) / std[i]
+		}
+		out[k] = row
+	}
+	return out
+}
+
+func mono(dst []float64, fr audio.Frame) []float64 {
+	ch := fr.Format.Channels
+	n := fr.Len()
+	if cap(dst) < n {
+		dst = make([]float64, n)
+	}
+	dst = dst[:n]
+	for i := range dst {
+		var sum float64
+		for c := 0; c < ch; c++ {
+			sum += float64(fr.Data[i*ch+c])
+		}
+		dst[i] = sum / float64(ch) / 32768
+	}
+	return dst
+}
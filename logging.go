@@ -0,0 +1,8 @@
+package voxa
+
+import "github.com/jmarc101/voxa/internal/logging"
+
+// Logger receives voxa's leveled, structured log records; arguments are
+// slog-style key-value pairs. *slog.Logger implements it, so slog.Default()
+// can be passed as is.
+type Logger = logging.Logger
@@ -0,0 +1,157 @@
+package voxa
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/longform"
+)
+
+// LongFormConfig configures TranscribeLong: the length of the windows a
+// recording is cut into, their overlap and how many are transcribed at
+// once, and the checkpoints it resumes from.
+type LongFormConfig = longform.Config
+
+// LongFormCheckpoint is a window of a recording transcribed by
+// TranscribeLong, from which a later run over the same recording resumes;
+// see LongFormConfig.Resume.
+type LongFormCheckpoint = longform.Checkpoint
+
+// TranscribeLong transcribes a long recording, of hours, in windows cut
+// in its pauses and transcribed in parallel on streams of their own, then
+// stitches their final segments into one transcript whose times count
+// from the start of src. Windows overlap, so no word is lost at a cut, and
+// the words heard twice are kept once. The transcript only depends on the
+// audio, not on the order the windows complete in; utterances are numbered
+// from 1. Windows of cfg.Resume are not transcribed again, and
+// cfg.OnCheckpoint records every window transcribed, for a run cut short
+// to resume from.
+//
+// Every window runs through the audio stages and transcript processing of
+// the pipeline, which should neither store transcripts nor archive audio:
+// each window is a stream, ending with a session of its own. Speaker labels
+// of diarization are per window, but for enrolled voices.
+func (p *Pipeline) TranscribeLong(ctx context.Context, src audio.Reader, cfg LongFormConfig) ([]Segment, error) {
+	split, err := longform.NewSplitter(cfg, src)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resume := map[int]longform.Checkpoint{}
+	for _, cp := range cfg.Resume {
+		resume[cp.Index] = cp
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		windows []longform.Window
+		segs    [][]Segment
+		first   error
+		resumed int
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if first == nil {
+			first = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+	todo := make(chan longform.Window)
+	for range split.Workers() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for w := range todo {
+				finals, err := p.transcribeWindow(ctx, w)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				mu.Lock()
+				segs[w.Index] = finals
+				if cfg.OnCheckpoint != nil {
+					cfg.OnCheckpoint(longform.Checkpoint{Index: w.Index, Start: w.Start, End: w.End, Segments: finals})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for ctx.Err() == nil {
+		w, err := split.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			fail(err)
+			break
+		}
+		cp, done := resume[w.Index]
+		if done = done && cp.Resumes(w); done {
+			resumed++
+		}
+		mu.Lock()
+		windows, segs = append(windows, longform.Window{Index: w.Index, Start: w.Start, End: w.End}), append(segs, nil)
+		if done {
+			segs[w.Index] = cp.Segments
+		}
+		mu.Unlock()
+		if done {
+			continue
+		}
+		select {
+		case todo <- w:
+		case <-ctx.Done():
+		}
+	}
+	close(todo)
+	wg.Wait()
+	if first == nil {
+		first = ctx.Err()
+	}
+	if first != nil {
+		return nil, first
+	}
+	p.cfg.Logger.Debug("long-form transcription stitched", "windows", len(windows), "resumed", resumed)
+	return longform.Stitch(windows, segs), nil
+}
+
+// transcribeWindow returns the final segments of the audio of w.
+func (p *Pipeline) transcribeWindow(ctx context.Context, w longform.Window) ([]Segment, error) {
+	fr := w.Audio
+	s, err := p.NewStream(ctx, fr.Format, StreamOptions{Offset: fr.Offset})
+	if err != nil {
+		return nil, err
+	}
+	var finals []Segment
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for seg := range s.Results() {
+			if seg.Final {
+				finals = append(finals, seg)
+			}
+		}
+	}()
+	n := fr.Format.Samples(audio.FrameDuration) * fr.Format.Channels
+	for i := 0; i < len(fr.Data) && err == nil; i += n {
+		err = s.WriteFrame(audio.Frame{
+			Format: fr.Format,
+			Data:   fr.Data[i:min(i+n, len(fr.Data))],
+			Offset: fr.Offset + fr.Format.Duration(i/fr.Format.Channels),
+		})
+	}
+	if cerr := s.Close(); err == nil {
+		err = cerr
+	}
+	<-done
+	if err == nil {
+		err = s.Err()
+	}
+	return finals, err
+}
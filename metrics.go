@@ -0,0 +1,11 @@
+package voxa
+
+import "github.com/jmarc101/voxa/internal/metrics"
+
+// Metrics instruments a pipeline for Prometheus: per-stage frame counts and
+// processing times, queue depths, recognizer latency and errors. It is a
+// prometheus.Collector; set it in Config.Metrics and register it.
+type Metrics = metrics.Metrics
+
+// NewMetrics creates pipeline metrics.
+func NewMetrics() *Metrics { return metrics.New() }
@@ -0,0 +1,28 @@
+package voxa
+
+import "github.com/jmarc101/voxa/internal/itn"
+
+// NormalizationConfig configures inverse text normalization; see
+// Config.Normalization.
+type NormalizationConfig = itn.Config
+
+// NormalizationClass is a class of entities inverse text normalization
+// writes in written form, which NormalizationConfig.Disable may turn off.
+type NormalizationClass = itn.Class
+
+// Normalization entity classes.
+const (
+	NormalizeCardinal = itn.Cardinal
+	NormalizeOrdinal  = itn.Ordinal
+	NormalizeDecimal  = itn.Decimal
+	NormalizePercent  = itn.Percent
+	NormalizeCurrency = itn.Currency
+	NormalizeDate     = itn.Date
+	NormalizeTime     = itn.Time
+)
+
+// NormalizationLocales returns the locales inverse text normalization has
+// rules for.
+func NormalizationLocales() []string {
+	return itn.Locales()
+}
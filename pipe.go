@@ -0,0 +1,110 @@
+package voxa
+
+import (
+	"context"
+	"io"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/export"
+)
+
+// AudioReader produces the frames of an audio source until it returns
+// io.EOF, as Pipeline.Run reads them.
+type AudioReader = audio.Reader
+
+// NewAudioReader decodes the audio file src holds, WAV, FLAC, MP3 or Ogg
+// Opus, recognized by content. It does not need to seek, so src may be a
+// pipe, such as the standard input of arecord | voxa transcribe -.
+func NewAudioReader(src io.Reader) (AudioReader, error) {
+	return audio.NewReader(src)
+}
+
+// NewPCMReader reads src as headerless little-endian PCM16 in format f, as
+// arecord -t raw or ffmpeg -f s16le write it.
+func NewPCMReader(src io.Reader, f AudioFormat) (AudioReader, error) {
+	return audio.NewPCMReader(src, f)
+}
+
+// ParseAudioFormat parses a format written rate or rate:channels, such as
+// 16000 or 48000:2.
+func ParseAudioFormat(s string) (AudioFormat, error) {
+	return audio.ParseFormat(s)
+}
+
+// SegmentLine is one line of the NDJSON a SegmentWriter writes.
+type SegmentLine = export.Line
+
+// SegmentWriter writes segments to an io.Writer as they come, one JSON
+// SegmentLine per line (NDJSON). It is safe for concurrent use.
+type SegmentWriter = export.NDJSONWriter
+
+// NewSegmentWriter writes the final segments to w, and with partials the
+// partial ones too. Its Write method takes a Segment.
+func NewSegmentWriter(w io.Writer, partials bool) *SegmentWriter {
+	return export.NewNDJSONWriter(w, partials)
+}
+
+// SegmentProtoWriter writes segments to an io.Writer as they come, as
+// length-delimited voxa.voxad.v1 Event protobuf messages of type FINAL or
+// PARTIAL. It is safe for concurrent use.
+type SegmentProtoWriter = export.ProtoWriter
+
+// NewSegmentProtoWriter writes the final segments to w, and with partials
+// the partial ones too. Its Write method takes a Segment.
+func NewSegmentProtoWriter(w io.Writer, partials bool) *SegmentProtoWriter {
+	return export.NewProtoWriter(w, partials)
+}
+
+// PipeOptions configures Pipeline.Pipe.
+type PipeOptions struct {
+	// Format, if set, is that of headerless PCM16 input; otherwise the
+	// input is an audio file, recognized by content.
+	Format *AudioFormat
+	// Partials writes the partial segments too, not only the finals.
+	Partials bool
+	// Protobuf writes the segments as a SegmentProtoWriter does rather
+	// than as NDJSON.
+	Protobuf bool
+}
+
+// Pipe transcribes the audio read from src and writes its segments to dst
+// as NDJSON or protobuf, until src is exhausted or ctx is done. It stops as soon as
+// writing fails, for instance once the reader of a pipe has gone, and
+// returns that error.
+func (p *Pipeline) Pipe(ctx context.Context, dst io.Writer, src io.Reader, opts PipeOptions) error {
+	var (
+		r   AudioReader
+		err error
+	)
+	if opts.Format != nil {
+		r, err = NewPCMReader(src, *opts.Format)
+	} else {
+		r, err = NewAudioReader(src)
+	}
+	if err != nil {
+		return err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var w interface {
+		Write(Segment) error
+		Err() error
+	}
+	if opts.Protobuf {
+		w = NewSegmentProtoWriter(dst, opts.Partials)
+	} else {
+		w = NewSegmentWriter(dst, opts.Partials)
+	}
+	err = p.Run(ctx, r, func(seg Segment) {
+		if w.Write(seg) != nil {
+			cancel()
+		}
+	})
+	if werr := w.Err(); werr != nil {
+		return werr
+	}
+	return err
+}
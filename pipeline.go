@@ -0,0 +1,1471 @@
+package voxa
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jmarc101/voxa/internal/archive"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/audio/aec"
+	"github.com/jmarc101/voxa/internal/audio/agc"
+	"github.com/jmarc101/voxa/internal/audio/amd"
+	"github.com/jmarc101/voxa/internal/audio/beam"
+	"github.com/jmarc101/voxa/internal/audio/capture"
+	"github.com/jmarc101/voxa/internal/audio/denoise"
+	"github.com/jmarc101/voxa/internal/audio/dtmf"
+	"github.com/jmarc101/voxa/internal/audio/endpoint"
+	"github.com/jmarc101/voxa/internal/audio/vad"
+	"github.com/jmarc101/voxa/internal/budget"
+	"github.com/jmarc101/voxa/internal/clients/asr"
+	"github.com/jmarc101/voxa/internal/diarize"
+	"github.com/jmarc101/voxa/internal/itn"
+	"github.com/jmarc101/voxa/internal/langid"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/metrics"
+	"github.com/jmarc101/voxa/internal/profanity"
+	"github.com/jmarc101/voxa/internal/punctuate"
+	"github.com/jmarc101/voxa/internal/redact"
+	"github.com/jmarc101/voxa/internal/rules"
+	"github.com/jmarc101/voxa/internal/sentiment"
+	"github.com/jmarc101/voxa/internal/session"
+	"github.com/jmarc101/voxa/internal/stt"
+	"github.com/jmarc101/voxa/internal/translate"
+	"github.com/jmarc101/voxa/internal/vocab"
+	"github.com/jmarc101/voxa/internal/wakeword"
+)
+
+// RecognizerConfig selects a registered STT provider by name.
+type RecognizerConfig = stt.Config
+
+// DenoiseConfig configures the noise suppression stage.
+type DenoiseConfig = denoise.Config
+
+// EchoConfig configures the acoustic echo cancellation stage.
+type EchoConfig = aec.Config
+
+// GainConfig configures the automatic gain control stage.
+type GainConfig = agc.Config
+
+// DTMFConfig configures the detection of telephone keypad tones.
+type DTMFConfig = dtmf.Config
+
+// DTMFDigit is a telephone keypad key pressed.
+type DTMFDigit = dtmf.Digit
+
+// AMDConfig configures answering machine detection.
+type AMDConfig = amd.Config
+
+// AMDDecision is who answered a call: see AMDResult.
+type AMDDecision = amd.Decision
+
+// AMDResult is who answered a call.
+type AMDResult = amd.Result
+
+// Answering machine detection results.
+const (
+	AMDUnknown = amd.Unknown
+	AMDHuman   = amd.Human
+	AMDMachine = amd.Machine
+	AMDMusic   = amd.Music
+)
+
+// BeamformConfig configures how the channels of a microphone array are
+// mixed down to mono.
+type BeamformConfig = beam.Config
+
+// VADConfig configures the voice activity detection stage.
+type VADConfig = vad.Config
+
+// VADEvent is a speech-start or speech-end transition.
+type VADEvent = vad.Event
+
+// EndpointingConfig configures where utterances end.
+type EndpointingConfig = endpoint.Config
+
+// Endpoint is the end of an utterance, with the reason it ended.
+type Endpoint = endpoint.Endpoint
+
+// ResampleQuality selects the accuracy of automatic format conversion.
+type ResampleQuality = audio.Quality
+
+// DiarizationConfig configures speaker diarization.
+type DiarizationConfig = diarize.Config
+
+// SpeakerTurn is a stretch of a stream attributed to one speaker.
+type SpeakerTurn = diarize.Turn
+
+// LanguageIDConfig configures language identification.
+type LanguageIDConfig = langid.Config
+
+// LanguageDetection reports the language identified for a stream.
+type LanguageDetection = langid.Detection
+
+// WakeWordConfig configures the wake word gate.
+type WakeWordConfig = wakeword.Config
+
+// WakeWord is a phrase the wake word gate listens for.
+type WakeWord = wakeword.Word
+
+// WakeWordDetection reports a wake word hit.
+type WakeWordDetection = wakeword.Detection
+
+// Config configures a Pipeline.
+type Config struct {
+	// Recognizer selects the STT backend from the provider registry.
+	// It defaults to the ASR sidecar.
+	Recognizer RecognizerConfig
+	// EchoCancellation, if set, removes what the device itself plays from
+	// the audio of every stream, before any other stage, so a voice
+	// assistant speaking through the speaker next to its microphone does
+	// not transcribe itself and hears the user speak over it. The audio
+	// played is taken from the Players of OpenPlayer, and from
+	// Pipeline.EchoReference for applications playing it themselves.
+	// Streams pass unchanged while nothing plays.
+	EchoCancellation *EchoConfig
+	// DTMF, if set, detects the keypad keys pressed on phone calls from
+	// their in-band tones, for IVR menus, calling StreamOptions.OnDTMF and
+	// publishing an EventDTMF for every one, with their time.
+	DTMF *DTMFConfig
+	// Denoise, if set, suppresses background noise before any other stage
+	// but echo cancellation and DTMF detection sees the audio, adding
+	// denoise.Latency of delay.
+	Denoise *DenoiseConfig
+	// AnsweringMachine, if set, tells from the first seconds of every
+	// stream whether a person, an answering machine or hold music answered
+	// the call, for outbound dialers to engage only with people, calling
+	// StreamOptions.OnAMD and publishing an EventAMD with the decision.
+	AnsweringMachine *AMDConfig
+	// GainControl, if set, brings speech to a steady level after Denoise,
+	// so that quiet talkers are not dropped by the VAD and loud ones do
+	// not clip, with a limiter keeping peaks under full scale.
+	GainControl *GainConfig
+	// AudioPlugins are custom audio stages, run in order after GainControl
+	// and before the wake word gate and VAD; see RegisterAudioPlugin.
+	AudioPlugins []PluginConfig
+	// VAD, if set, gates the audio on voice activity: silence is not sent
+	// to the recognizer and every speech-end finalizes the utterance.
+	VAD *VADConfig
+	// Endpointing, if set, decides where utterances end, in place of the
+	// speech-ends of the VAD and of the recognizer's own endpointing:
+	// after a pause of MinSilence, a shorter one after a falling pitch, or
+	// at MaxUtterance, the recognizer being finalized there whatever the
+	// provider. Recognizers ending utterances on pauses themselves are
+	// told to wait MinSilence. Speech is told from silence with the VAD's
+	// settings, and VAD is not required.
+	Endpointing *EndpointingConfig
+	// WakeWord, if set, blocks audio until one of its words is heard. With
+	// VAD enabled the gate re-arms at the end of every utterance.
+	WakeWord *WakeWordConfig
+	// Diarization, if set, labels every segment with its speaker. It
+	// works on utterances, so it is most useful together with VAD.
+	Diarization *DiarizationConfig
+	// Speakers, if set, labels the diarized speakers who match a voiceprint
+	// enrolled in it with their name, instead of "S1", "S2" and so on.
+	// Speakers may be enrolled while streams run. It requires Diarization.
+	Speakers *SpeakerRegistry
+	// LanguageID, if set, identifies the language from the start of every
+	// stream, switches recognizers that support it (stt.LanguageSetter)
+	// to it and reports it in Segment.Language. Without an Identifier the
+	// recognizer must identify languages itself.
+	LanguageID *LanguageIDConfig
+	// ResampleQuality is used when a source's format differs from the one
+	// the recognizer requires. Defaults to audio.QualityMedium.
+	ResampleQuality ResampleQuality
+	// InputDevice selects the microphone Listen captures from, by ID or
+	// name (see capture.FindDevice). Empty selects the system default.
+	InputDevice string
+	// InputChannels is how many channels Listen captures, for microphone
+	// arrays. Defaults to one, or with Beamforming to every channel of
+	// InputDevice.
+	InputChannels int
+	// Beamforming, if set, mixes sources of several channels, such as
+	// microphone arrays, down to the mono stream of the recognizer with a
+	// beamformer, by default steering on the talker, instead of averaging
+	// the channels. Mono sources are unaffected.
+	Beamforming *BeamformConfig
+	// OutputDevice selects, by ID or name, the device synthesized speech is
+	// played on; see Pipeline.OpenPlayer. Empty selects the system
+	// default.
+	OutputDevice string
+	// Sessions, if set, keeps conversation state across streams; see
+	// StreamOptions.SessionID and OnTurn.
+	Sessions SessionStore
+	// SessionTTL is how long an idle conversation is kept. Defaults to
+	// 30 minutes.
+	SessionTTL time.Duration
+	// OnTurn, if set with Sessions, is called for every final segment with
+	// the stream's session, which is saved afterwards. It runs on the
+	// segment delivery path, so later segments wait for it. An error ends
+	// delivery and is reported by Stream.Err.
+	OnTurn func(ctx context.Context, sess *Session, seg Segment) error
+	// TranscriptPlugins rewrite every segment, partial and final, in order,
+	// before it is translated, parsed for intents or delivered; see
+	// RegisterTranscriptPlugin. A plugin failing ends the stream.
+	TranscriptPlugins []PluginConfig
+	// Vocabulary, if set, biases recognition toward its phrases, such as
+	// product names, on recognizers that take phrases; see
+	// stt.PhraseBiaser. The transcripts of other recognizers are corrected
+	// against the phrases instead, before the transcript plugins.
+	Vocabulary *VocabularyConfig
+	// Punctuation, if set, restores the punctuation, capitalization and
+	// sentence boundaries of unpunctuated segments, after the transcript
+	// plugins.
+	Punctuation *PunctuationConfig
+	// Normalization, if set, writes the numbers, dates, times, amounts and
+	// percentages of every segment in written form, after punctuation, so
+	// "twenty five dollars" reads "$25".
+	Normalization *NormalizationConfig
+	// Profanity, if set, filters offensive words out of every segment after
+	// the transcript plugins, punctuation and normalization, so nothing
+	// downstream sees them.
+	Profanity *ProfanityConfig
+	// Redaction, if set, replaces personal data in every segment with
+	// markers, after the profanity filter, and records what it replaced in
+	// Segment.Redactions. Nothing downstream, including translation, the
+	// turn hook and the session history, sees the original text.
+	Redaction *RedactionConfig
+	// Transcripts, if set, records every stream as a session, under its
+	// session ID, with its final segments once they have been processed;
+	// see Pipeline.Transcripts for reading them back. A stream reusing a
+	// session ID adds to that session. Failures to store are logged and
+	// counted, and do not end the stream.
+	Transcripts TranscriptStore
+	// Summary, if set with Transcripts, summarizes the transcript of every
+	// session when a stream of it ends, in the background, and stores the
+	// summary with it; see Pipeline.Summarize. Failures are logged and
+	// counted.
+	Summary *SummaryConfig
+	// Archive, if set, records the audio written to every stream, as
+	// written, in files named after its session, so it can be transcribed
+	// again later. Failures to record or upload are logged and counted,
+	// and do not end the stream. Pipeline.Close waits for the uploads.
+	// With Transcripts, the files are listed with their session, for
+	// the reprocess package to transcribe them again.
+	Archive *ArchiveConfig
+	// Retention, if set with Transcripts, deletes sessions once they are
+	// older than their tenant keeps them, with their transcripts, archived
+	// audio and conversation state, in the background until Close; see
+	// StreamOptions.Tenant and Pipeline.DeleteSession.
+	Retention *RetentionConfig
+	// Degraded, if set with Archive and Transcripts, keeps streams going
+	// when their recognizer is down, deferring their transcription; see
+	// DegradedConfig.
+	Degraded *DegradedConfig
+	// Intents, if set, parses every final segment; matches are reported to
+	// OnIntent and StreamOptions.OnIntent before the segment is delivered.
+	Intents IntentParser
+	// OnIntent is called for every intent recognized on any stream.
+	OnIntent func(ctx context.Context, in Intent)
+	// Translation, if set, translates every final segment into the target
+	// languages before it is delivered; see Segment.Translations.
+	Translation *TranslationConfig
+	// Sentiment, if set, tags every final segment with its sentiment
+	// before it is delivered; see Segment.Sentiment. With Prosody, the
+	// audio of every utterance is measured for the emotion of its voice,
+	// which is most useful together with VAD.
+	Sentiment *SentimentConfig
+	// Sinks receive the events of every stream: its start and end, wake
+	// words, partial and final segments and intents. Finals reach them
+	// after storage, before the turn hook.
+	Sinks []EventSink
+	// Alerts spot keywords and patterns in the segments of every stream,
+	// after the transcript stages, publishing an EventAlert to the actions
+	// of a rule the first time it matches an utterance. Alerts on finals
+	// go out before translation and intents.
+	Alerts []AlertRule
+	// Metrics, if set, is updated by every stream of the pipeline. Register
+	// it with a Prometheus registry to export it.
+	Metrics *Metrics
+	// Budgets, if set, hold stages to latency budgets, by the names they
+	// are measured under: the audio stages, including beamform and
+	// convert, per frame; vocabulary, the transcript stages, sentiment,
+	// translation, intents, transcripts and turns per segment. A stage
+	// whose average latency over all streams exceeds its budget is logged
+	// and counted, and with BudgetSkip or BudgetShed skipped or made to
+	// refuse new streams for the cooldown; see StageBudget.
+	Budgets map[string]StageBudget
+	// Priority is the priority of the streams whose StreamOptions set
+	// none, such as PriorityBatch for pipelines transcribing recordings.
+	Priority Priority
+	// TracerProvider receives a trace per utterance: a voxa.utterance span
+	// with children for VAD, recognition, translation, intent parsing,
+	// storage and the turn hook. Spans are children of the span in the
+	// stream's context, if any. Defaults to the global provider.
+	TracerProvider trace.TracerProvider
+	// Buffer, if set, queues the audio written to every stream and runs its
+	// stages and recognizer on a goroutine of the stream, so a slow stage
+	// holds up writers only once the buffer is full; its Overflow policy
+	// decides what happens then. Without it, writes process the audio
+	// before returning.
+	Buffer *BufferConfig
+	// Logger receives the pipeline's log records and those of the backends
+	// whose configs set none. Records of a stream carry its session ID, so
+	// concurrent streams can be told apart. Nil discards them.
+	Logger Logger
+}
+
+// Pipeline turns an audio source into transcript segments.
+type Pipeline struct {
+	cfg      Config
+	rec      stt.Provider
+	input    string         // resolved InputDevice ID
+	channels int            // captured by Listen
+	output   *AudioDevice   // resolved OutputDevice
+	echo     *aec.Reference // with Config.EchoCancellation
+	sessions *session.Manager
+	trans    *translate.Stage
+	sent     *sentiment.Analyzer
+	rules    *rules.Engine // with Config.Alerts
+	archive  *archive.Archiver
+	audio    []namedAudio
+	post     []namedTranscript
+	vocab    *vocab.Corrector           // with Config.Vocabulary
+	correct  bool                       // transcripts are corrected against phrases
+	budgets  map[string]*budget.Tracker // by stage, with Config.Budgets
+	bus      *bus                       // see Subscribe
+
+	summaries   sync.WaitGroup // running summarizeLater
+	summarizing chan struct{}  // bounds them to maxSummarizing
+
+	stopPurge context.CancelFunc // with Config.Retention
+	purging   sync.WaitGroup
+}
+
+// NewPipeline instantiates the configured backends. Providers are looked up
+// by name in the stt registry, so any backend registered with stt.Register
+// can be selected from configuration alone.
+func NewPipeline(cfg Config) (*Pipeline, error) {
+	if cfg.Recognizer.Provider == "" {
+		cfg.Recognizer.Provider = asr.ProviderName
+	}
+	cfg.Logger = logging.OrNop(cfg.Logger)
+	if cfg.Recognizer.Logger == nil {
+		cfg.Recognizer.Logger = logging.With(cfg.Logger, "stt", cfg.Recognizer.Provider)
+	}
+	if cfg.EchoCancellation != nil {
+		c, err := aec.New(*cfg.EchoCancellation, 16000, aec.NewReference())
+		if err != nil {
+			return nil, err
+		}
+		c.Close()
+	}
+	if cfg.Beamforming != nil {
+		if _, err := beam.New(*cfg.Beamforming, audio.Format{SampleRate: 16000, Channels: beam.MaxChannels}); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.DTMF != nil {
+		if _, err := dtmf.New(*cfg.DTMF, 16000); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Denoise != nil {
+		if _, err := denoise.New(*cfg.Denoise, 16000); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.AnsweringMachine != nil {
+		if _, err := amd.New(*cfg.AnsweringMachine, 16000); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.GainControl != nil {
+		if _, err := agc.New(*cfg.GainControl, 16000); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.VAD != nil {
+		if _, err := vad.New(*cfg.VAD); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Endpointing != nil {
+		if _, err := endpoint.New(*cfg.Endpointing, vad.Config{}); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Diarization != nil {
+		if _, err := diarize.New(*cfg.Diarization, 16000); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Speakers != nil && cfg.Diarization == nil {
+		return nil, errors.New("voxa: speaker identification requires diarization")
+	}
+	if sc := cfg.Summary; sc != nil {
+		switch {
+		case sc.Summarizer == nil:
+			return nil, errors.New("voxa: summaries need a summarizer")
+		case cfg.Transcripts == nil:
+			return nil, errors.New("voxa: summaries require transcripts")
+		case sc.Timeout < 0:
+			return nil, fmt.Errorf("voxa: negative summary timeout %v", sc.Timeout)
+		}
+		c := *sc
+		if c.Timeout == 0 {
+			c.Timeout = 2 * time.Minute
+		}
+		cfg.Summary = &c
+	}
+	if cfg.Retention != nil && cfg.Transcripts == nil {
+		return nil, errors.New("voxa: retention requires transcripts")
+	}
+	if dc := cfg.Degraded; dc != nil {
+		if cfg.Archive == nil || cfg.Transcripts == nil {
+			return nil, errors.New("voxa: degraded mode requires an archive and transcripts")
+		}
+		c := *dc
+		c.Backlog = cmp.Or(c.Backlog, DefaultBacklog)
+		cfg.Degraded = &c
+	}
+	purger, err := newPurger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	budgets, err := newBudgets(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pipeline{cfg: cfg, budgets: budgets, summarizing: make(chan struct{}, maxSummarizing)}
+	p.bus = &bus{log: cfg.Logger, metrics: cfg.Metrics}
+	if cfg.EchoCancellation != nil {
+		p.echo = aec.NewReference()
+	}
+	if cfg.Sessions != nil {
+		p.sessions = session.NewManager(cfg.Sessions, cfg.SessionTTL)
+	}
+	if cfg.Translation != nil {
+		t, err := newTranslationStage(*cfg.Translation, cfg.Metrics, cfg.Logger)
+		if err != nil {
+			return nil, err
+		}
+		p.trans = t
+	}
+	if cfg.Archive != nil {
+		a, err := newArchiver(*cfg.Archive, cfg.Transcripts, cfg.Metrics, cfg.Logger)
+		if err != nil {
+			return nil, err
+		}
+		p.archive = a
+	}
+	if err := p.openPlugins(); err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+	if cfg.Vocabulary != nil {
+		c, err := vocab.New(*cfg.Vocabulary)
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.vocab = c
+	}
+	if cfg.Punctuation != nil {
+		pc := *cfg.Punctuation
+		if pc.Logger == nil {
+			pc.Logger = p.cfg.Logger
+		}
+		r, err := punctuate.New(pc)
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.post = append(p.post, namedTranscript{name: "punctuation", Transcript: r})
+	}
+	if cfg.Sentiment != nil {
+		sc := *cfg.Sentiment
+		if sc.Logger == nil {
+			sc.Logger = p.cfg.Logger
+		}
+		a, err := sentiment.New(sc)
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.sent = a
+	}
+	if cfg.Normalization != nil {
+		n, err := itn.New(*cfg.Normalization)
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.post = append(p.post, namedTranscript{name: "normalization", Transcript: n})
+	}
+	if cfg.Profanity != nil {
+		f, err := profanity.New(*cfg.Profanity)
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.post = append(p.post, namedTranscript{name: "profanity", Transcript: f})
+	}
+	if cfg.Redaction != nil {
+		r, err := redact.New(*cfg.Redaction)
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.post = append(p.post, namedTranscript{name: "redaction", Transcript: r})
+	}
+	if len(cfg.Alerts) > 0 {
+		r, err := newAlerts(cfg.Alerts)
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.rules = r
+	}
+	// Pinned devices must exist, so a misconfigured deployment fails at
+	// startup rather than on the first session.
+	if cfg.InputDevice != "" {
+		d, err := capture.FindDevice(cfg.InputDevice, false)
+		if err != nil {
+			_ = p.Close()
+			return nil, fmt.Errorf("voxa: %w", err)
+		}
+		p.input = d.ID
+		if cfg.Beamforming != nil && d.InputChannels > 0 {
+			p.channels = d.InputChannels
+		}
+	}
+	if cfg.InputChannels > 0 {
+		p.channels = cfg.InputChannels
+	}
+	p.channels = max(p.channels, 1)
+	if cfg.OutputDevice != "" {
+		d, err := capture.FindDevice(cfg.OutputDevice, true)
+		if err != nil {
+			_ = p.Close()
+			return nil, fmt.Errorf("voxa: %w", err)
+		}
+		p.output = &d
+	}
+	rec, err := stt.New(cfg.Recognizer)
+	if err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+	p.rec = rec
+	b, ok := rec.(stt.PhraseBiaser)
+	p.correct = !ok || !b.BiasesPhrases() || (cfg.Vocabulary != nil && cfg.Vocabulary.Always)
+	if cfg.LanguageID != nil && cfg.LanguageID.Identifier == nil {
+		if _, ok := rec.(langid.Identifier); !ok {
+			_ = p.Close()
+			return nil, fmt.Errorf("voxa: recognizer %s cannot identify languages; set LanguageID.Identifier", cfg.Recognizer.Provider)
+		}
+	}
+	if purger != nil {
+		var ctx context.Context
+		ctx, p.stopPurge = context.WithCancel(context.Background())
+		p.purging.Add(1)
+		go func() {
+			defer p.purging.Done()
+			purger.Run(ctx)
+		}()
+	}
+	return p, nil
+}
+
+// StreamOptions customizes a single stream.
+type StreamOptions struct {
+	// DisableVAD skips the VAD stage even if the pipeline configures one.
+	DisableVAD bool
+	// OnVAD is called for every VAD transition of this stream, after the
+	// pipeline's own VAD callback.
+	OnVAD func(VADEvent)
+	// OnEndpoint is called for every utterance end of this stream, with
+	// Config.Endpointing, after the pipeline's own callback.
+	OnEndpoint func(Endpoint)
+	// OnWakeWord is called for every wake word detection of this stream,
+	// after the pipeline's own callback.
+	OnWakeWord func(WakeWordDetection)
+	// OnDTMF is called for every keypad key pressed on this stream, with
+	// Config.DTMF, after the pipeline's own callback.
+	OnDTMF func(DTMFDigit)
+	// OnAMD is called with the answering machine detection of this stream,
+	// with Config.AnsweringMachine, after the pipeline's own callback.
+	OnAMD func(AMDDecision)
+	// SessionID names the conversation the stream belongs to, for
+	// Config.OnTurn. Streams without one start a new conversation.
+	SessionID string
+	// OnIntent is called for every intent recognized on this stream, after
+	// the pipeline's own callback.
+	OnIntent func(Intent)
+	// OnLanguage is called when the language of this stream has been
+	// identified, after the pipeline's own callback.
+	OnLanguage func(LanguageDetection)
+	// OnDeferred is called with the recognizer failure when the
+	// transcription of this stream is deferred, with Config.Degraded,
+	// after the pipeline's own callback.
+	OnDeferred func(error)
+	// Offset is where the audio written through Write starts in session
+	// time, for a stream resuming one that was cut off: segment and VAD
+	// times count from it.
+	Offset time.Duration
+	// Phrases add to the phrases of Config.Vocabulary for this stream,
+	// such as the names of the people on a call.
+	Phrases []Phrase
+	// Playback, if set, is the reply being played to the speaker of this
+	// stream, which speech detected by the VAD interrupts; see Playback.
+	// Without the VAD stage, nothing interrupts it.
+	Playback *Playback
+	// Priority ranks the stream against the others sharing a recognizer
+	// backend that schedules work, such as a batching whisper recognizer
+	// or the sidecar: when it is saturated, the decodes of higher
+	// priorities run first. PriorityNormal, the zero value, takes
+	// Config.Priority.
+	Priority Priority
+	// Language, if set, is the language the stream is recognized in, as an
+	// ISO 639-1 code, in place of the recognizer's, and Config.LanguageID
+	// does not identify it. Model, if set, names the model of the
+	// recognizer's provider to recognize the stream with. NewStream fails
+	// if the recognizer cannot take them; see stt.StreamOverrider.
+	Language string
+	Model    string
+	// Endpointing, if set, replaces Config.Endpointing for the stream.
+	Endpointing *EndpointingConfig
+	// Tenant, if set, is whom the session is for, as the name of its API
+	// key. It is stored with the transcript, whose retention depends on
+	// it; see RetentionConfig.
+	Tenant string
+}
+
+// Stream is one audio stream running through the pipeline: frames written
+// to it pass the audio stages and reach the recognizer, and transcript
+// segments come back on Results. Writes must come from one goroutine.
+type Stream struct {
+	ctx      context.Context
+	in       *buffer // with Config.Buffer
+	session  string
+	log      Logger
+	onIntent func(Intent)
+	format   audio.Format
+	rec      stt.StreamingRecognizer
+	tape     *archive.Recorder // with Config.Archive
+	conv     *audio.Converter  // when the source needs converting
+	front    int               // stages bringing the source to the recognizer format
+	stages   []audio.Stage
+	diar     *diarize.Diarizer
+	prosody  *sentiment.Tracker
+	lang     *langid.Stage
+	language string          // asked for in StreamOptions.Language
+	tenant   string          // StreamOptions.Tenant
+	store    TranscriptStore // Config.Transcripts, for SetMetadata
+	post     []namedTranscript
+	sinks    []EventSink
+	bus      *bus
+	rules    *rules.Engine  // with Config.Alerts
+	playback *Playback      // see StreamOptions.Playback
+	echo     *aec.Canceller // with Config.EchoCancellation
+	alerts   []AlertRule
+	alerted  struct { // see alert
+		utterance string
+		rules     map[int]bool // that fired on it
+	}
+	clock    timeline
+	metrics  *metrics.Metrics
+	budgets  map[string]*budget.Tracker
+	provider string
+	latency  metrics.Clock
+	statsMu  sync.Mutex
+	stats    StreamStats     // but Audio, which latency tracks
+	speaking bool            // an utterance has partials but no final yet
+	idle     []chan struct{} // see Idle
+	relayed  bool            // the last segment has been handled
+	ended    func()          // counts the stream as closed in metrics
+	trace    *utterances
+	results  <-chan Segment
+	offset   int   // samples written through Write, for frame offsets
+	err      error // OnTurn failure, set before results is closed
+
+	onDeferred func(error) // with Config.Degraded
+
+	// Scratch space of the write path, reused so steady streaming does not
+	// allocate.
+	one     [1]audio.Frame
+	scratch [2][]audio.Frame
+	pcm     []byte
+}
+
+// vocabulary returns the phrases of a stream with the extra phrases more,
+// and its transcript stages: those of the pipeline, after a corrector for
+// the phrases when the recognizer does not bias toward them.
+func (p *Pipeline) vocabulary(more []Phrase) ([]Phrase, []namedTranscript, error) {
+	var phrases []Phrase
+	if p.cfg.Vocabulary != nil {
+		phrases = p.cfg.Vocabulary.Phrases
+	}
+	phrases = append(slices.Clip(phrases), more...)
+	if !p.correct || len(phrases) == 0 {
+		return phrases, p.post, nil
+	}
+	var c *vocab.Corrector
+	var err error
+	if p.vocab != nil {
+		c, err = p.vocab.With(more)
+	} else {
+		c, err = vocab.New(vocab.Config{Phrases: more})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return phrases, append([]namedTranscript{{name: "vocabulary", Transcript: c}}, p.post...), nil
+}
+
+// NewStream opens a stream for audio in the given format. Sources in a
+// format the recognizer cannot take are down-mixed to mono, by the
+// beamformer of Config.Beamforming if set, and resampled before any other
+// stage sees them.
+func (p *Pipeline) NewStream(ctx context.Context, format audio.Format, opts StreamOptions) (*Stream, error) {
+	if name, ok := p.shedding(); ok {
+		p.cfg.Metrics.Shed(name)
+		return nil, fmt.Errorf("%w: %s", ErrOverBudget, name)
+	}
+	target := p.recognizerFormat(format)
+	var bf *beam.Beamformer
+	mono := format
+	if p.cfg.Beamforming != nil && format.Channels > 1 {
+		b, err := beam.New(*p.cfg.Beamforming, format)
+		if err != nil {
+			return nil, fmt.Errorf("voxa: %w", err)
+		}
+		bf, mono = b, b.Format()
+	}
+	var conv *audio.Converter
+	if target != mono {
+		c, err := audio.NewConverter(mono, target, p.cfg.ResampleQuality)
+		if err != nil {
+			return nil, fmt.Errorf("voxa: %w", err)
+		}
+		conv = c
+	}
+	id := opts.SessionID
+	if id == "" {
+		id = session.NewID()
+	}
+	log := logging.With(p.cfg.Logger, "session", id)
+	phrases, post, err := p.vocabulary(opts.Phrases)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Priority == PriorityNormal {
+		opts.Priority = p.cfg.Priority
+	}
+	if opts.Language != "" || opts.Model != "" {
+		var lang, model bool
+		if o, ok := p.rec.(stt.StreamOverrider); ok {
+			lang, model = o.Overrides()
+		}
+		switch {
+		case opts.Language != "" && !lang:
+			return nil, fmt.Errorf("voxa: recognizer %s cannot be asked for a language", p.cfg.Recognizer.Provider)
+		case opts.Model != "" && !model:
+			return nil, fmt.Errorf("voxa: recognizer %s cannot be asked for a model", p.cfg.Recognizer.Provider)
+		}
+	}
+	var endpointing time.Duration
+	if ep := cmp.Or(opts.Endpointing, p.cfg.Endpointing); ep != nil {
+		endpointing = cmp.Or(ep.MinSilence, endpoint.DefaultMinSilence)
+	}
+	rec, err := p.rec.NewStream(ctx, stt.StreamConfig{
+		SampleRate:  target.SampleRate,
+		Logger:      logging.With(p.cfg.Recognizer.Logger, "session", id),
+		Phrases:     phrases,
+		Priority:    opts.Priority,
+		Language:    opts.Language,
+		Model:       opts.Model,
+		Endpointing: endpointing,
+	})
+	if err != nil && (p.cfg.Degraded == nil || ctx.Err() != nil) {
+		log.Error("recognizer stream failed", "error", err)
+		return nil, err
+	}
+	var degraded *deferring
+	if p.cfg.Degraded != nil {
+		degraded = newDeferring(ctx, rec, err)
+		rec = degraded
+	}
+	s := &Stream{ctx: ctx, session: id, log: log, onIntent: opts.OnIntent, format: format, rec: rec, conv: conv, post: post, sinks: p.cfg.Sinks,
+		bus: p.bus, rules: p.rules, alerts: p.cfg.Alerts, playback: opts.Playback}
+	s.metrics, s.budgets, s.provider = p.cfg.Metrics, p.budgets, p.cfg.Recognizer.Provider
+	s.stats = StreamStats{Provider: s.provider, Latency: -1}
+	s.offset = format.Samples(opts.Offset)
+	s.language, s.tenant, s.store = opts.Language, opts.Tenant, p.cfg.Transcripts
+	s.trace = &utterances{tracer: p.tracer(), parent: ctx}
+	if p.cfg.Degraded != nil {
+		s.onDeferred = chain(p.cfg.Degraded.OnDeferred, opts.OnDeferred)
+	}
+	if s.stages, _, err = p.stages(s, target, opts); err != nil {
+		_ = rec.Close()
+		s.closeEcho()
+		return nil, err
+	}
+	var front []audio.Stage
+	if bf != nil {
+		front = append(front, s.budgeted("beamform", s.metrics.Stage("beamform", bf)))
+	}
+	if conv != nil {
+		front = append(front, s.budgeted("convert", s.metrics.Stage("convert", conv)))
+	}
+	s.stages, s.front = append(front, s.stages...), len(front)
+	if p.archive != nil {
+		if s.tape, err = p.archive.Record(id, format); err != nil {
+			_ = rec.Close()
+			s.closeEcho()
+			return nil, err
+		}
+	}
+	if p.cfg.Buffer != nil {
+		if s.in, err = newBuffer(*p.cfg.Buffer, p.cfg.Metrics); err != nil {
+			_ = rec.Close()
+			s.closeEcho()
+			return nil, err
+		}
+		go s.in.drain(s)
+	}
+	s.ended = s.metrics.StreamOpened()
+	p.startTranscript(s)
+	s.publish(Event{Type: EventSessionStart, Tenant: s.tenant})
+	if degraded != nil {
+		degraded.start(func(err error) { s.deferred(p.cfg.Degraded.Backlog, err) })
+	}
+	s.results = s.relay(rec.Results(), p.final, func() {
+		p.endTranscript(s)
+		if p.cfg.Summary != nil {
+			p.summarizeLater(s)
+		}
+		s.publish(Event{Type: EventSessionEnd, Err: s.Err()})
+	})
+	log.Debug("stream opened", "sample_rate", format.SampleRate, "channels", format.Channels,
+		"provider", p.cfg.Recognizer.Provider)
+	return s, nil
+}
+
+// recognizerFormat returns the format audio must reach the recognizer in:
+// mono, at the backend's required rate if it has one.
+func (p *Pipeline) recognizerFormat(src audio.Format) audio.Format {
+	f := audio.Format{SampleRate: src.SampleRate, Channels: 1}
+	if r, ok := p.rec.(stt.FormatRequirer); ok {
+		req := r.RequiredFormat()
+		if req.SampleRate > 0 {
+			f.SampleRate = req.SampleRate
+		}
+	}
+	return f
+}
+
+// stages builds the per-stream audio stages for audio in format, and
+// returns them with their names. Stages keep state, so every stream gets
+// its own instances.
+func (p *Pipeline) stages(s *Stream, format audio.Format, opts StreamOptions) ([]audio.Stage, []string, error) {
+	var (
+		stages []audio.Stage
+		names  []string
+	)
+	add := func(name string, st audio.Stage) {
+		stages = append(stages, s.budgeted(name, p.cfg.Metrics.Stage(name, st)))
+		names = append(names, name)
+	}
+	if p.cfg.EchoCancellation != nil {
+		c, err := aec.New(*p.cfg.EchoCancellation, format.SampleRate, p.echo)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.echo = c
+		add("aec", c)
+	}
+	if p.cfg.DTMF != nil {
+		cfg := *p.cfg.DTMF
+		cfg.OnDigit = chain(func(dg dtmf.Digit) {
+			s.log.Info("dtmf", "key", dg.Key, "offset", dg.Offset)
+			s.publish(Event{Type: EventDTMF, DTMF: &dg})
+		}, cfg.OnDigit, opts.OnDTMF)
+		d, err := dtmf.New(cfg, format.SampleRate)
+		if err != nil {
+			return nil, nil, err
+		}
+		add("dtmf", d)
+	}
+	if p.cfg.Denoise != nil {
+		d, err := denoise.New(*p.cfg.Denoise, format.SampleRate)
+		if err != nil {
+			return nil, nil, err
+		}
+		add("denoise", d)
+	}
+	if p.cfg.AnsweringMachine != nil {
+		cfg := *p.cfg.AnsweringMachine
+		cfg.OnDecision = chain(func(dc amd.Decision) {
+			s.log.Info("answered", "by", dc.Result.String(), "reason", dc.Reason, "offset", dc.Offset)
+			s.publish(Event{Type: EventAMD, AMD: &dc})
+		}, cfg.OnDecision, opts.OnAMD)
+		d, err := amd.New(cfg, format.SampleRate)
+		if err != nil {
+			return nil, nil, err
+		}
+		add("amd", d)
+	}
+	if p.cfg.GainControl != nil {
+		g, err := agc.New(*p.cfg.GainControl, format.SampleRate)
+		if err != nil {
+			return nil, nil, err
+		}
+		add("agc", g)
+	}
+	for _, a := range p.audio {
+		st, err := a.NewStage(format)
+		if err != nil {
+			return nil, nil, fmt.Errorf("voxa: plugin %s: %w", a.name, err)
+		}
+		add(a.name, st)
+	}
+	var gate *wakeword.Gate
+	if p.cfg.WakeWord != nil {
+		cfg := *p.cfg.WakeWord
+		cfg.OnDetect = chain(func(det wakeword.Detection) {
+			s.log.Info("wake word", "phrase", det.Phrase, "score", det.Score)
+			s.publish(Event{Type: EventWakeWord, WakeWord: &det})
+		}, cfg.OnDetect, opts.OnWakeWord)
+		g, err := wakeword.New(cfg, format.SampleRate)
+		if err != nil {
+			return nil, nil, err
+		}
+		gate = g
+		add("wakeword", g)
+	}
+	ept := cmp.Or(opts.Endpointing, p.cfg.Endpointing)
+	if ept != nil {
+		cfg := *ept
+		cfg.OnEndpoint = chain(func(ep endpoint.Endpoint) {
+			s.log.Debug("endpoint", "reason", ep.Reason, "offset", ep.Offset, "length", ep.Length)
+			_ = s.flush()
+			if gate != nil {
+				gate.Rearm()
+			}
+		}, cfg.OnEndpoint, opts.OnEndpoint)
+		var detect vad.Config
+		if p.cfg.VAD != nil {
+			detect = *p.cfg.VAD
+			detect.OnEvent = nil
+		}
+		e, err := endpoint.New(cfg, detect)
+		if err != nil {
+			return nil, nil, err
+		}
+		add("endpoint", e)
+	}
+	if p.cfg.VAD != nil && !opts.DisableVAD {
+		cfg := *p.cfg.VAD
+		if ept != nil {
+			// The gate stays open over the pauses the endpointer times.
+			cfg.Hangover = max(cfg.Hangover, cmp.Or(ept.MinSilence, endpoint.DefaultMinSilence))
+		}
+		cfg.OnEvent = chain(func(ev vad.Event) {
+			s.log.Debug("vad", "event", ev.Type.String(), "offset", ev.Offset)
+			s.publish(Event{Type: EventVAD, VAD: &ev})
+			s.trace.speech(ev.Type == vad.SpeechStart)
+			if ev.Type == vad.SpeechStart && s.playback != nil && s.playback.Interrupt() {
+				s.metrics.BargeIn()
+				s.log.Info("barge-in, reply interrupted", "offset", ev.Offset)
+			}
+			if ev.Type == vad.SpeechEnd && ept == nil {
+				_ = s.flush()
+				if gate != nil {
+					gate.Rearm()
+				}
+			}
+		}, cfg.OnEvent, opts.OnVAD)
+		d, err := vad.New(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		add("vad", d)
+	}
+	if p.cfg.LanguageID != nil && opts.Language == "" {
+		cfg := *p.cfg.LanguageID
+		if cfg.Identifier == nil {
+			cfg.Identifier = p.rec.(langid.Identifier)
+		}
+		cfg.OnDetect = chain(func(det langid.Detection) {
+			if det.Err != nil {
+				s.log.Warn("language identification failed", "error", det.Err, "fallback", det.Language)
+				return
+			}
+			s.log.Info("language identified", "language", det.Language, "confidence", det.Confidence,
+				"fallback", det.Fallback)
+		}, cfg.OnDetect, opts.OnLanguage)
+		l, err := langid.New(s.ctx, cfg, func(det langid.Detection) error {
+			if ls, ok := s.rec.(stt.LanguageSetter); ok {
+				return ls.SetLanguage(det.Language)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		s.lang = l
+		add("langid", l)
+	}
+	if p.cfg.Diarization != nil {
+		dcfg := *p.cfg.Diarization
+		if p.cfg.Speakers != nil {
+			dcfg.Identifier = p.cfg.Speakers
+		}
+		d, err := diarize.New(dcfg, format.SampleRate)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.diar = d
+		add("diarize", d)
+	}
+	if p.sent != nil && p.sent.Prosody() {
+		t, err := sentiment.NewTracker(format.SampleRate)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.prosody = t
+		add("prosody", t)
+	}
+	return stages, names, nil
+}
+
+// relay forwards segments from the recognizer, moving their times onto the
+// source clock and, with diarization, setting their Speaker. Partials get
+// the speaker of the utterance they belong to so far; every final consumes
+// one diarized utterance. Finals go through final before they are
+// forwarded; if it fails, or the stream's context ends, the remaining
+// segments are drained and dropped. closed runs once the last segment has
+// been handled.
+func (s *Stream) relay(in <-chan Segment, final func(context.Context, *Stream, *Segment) error, closed func()) <-chan Segment {
+	out := make(chan Segment)
+	go func() {
+		defer close(out)
+		defer func() {
+			s.utterance(false, true)
+			closed()
+			if err := s.rec.Err(); err != nil {
+				s.metrics.Error("stt")
+				s.log.Error("recognizer failed", "error", err)
+			}
+			s.log.Debug("stream closed")
+			s.trace.close(s.rec.Err())
+			s.ended()
+		}()
+		for seg := range in {
+			if s.err == nil {
+				s.err = s.ctx.Err()
+			}
+			if s.err != nil {
+				continue
+			}
+			provider := s.provider
+			if seg.Provider != "" {
+				provider = seg.Provider // failed over
+			}
+			latency := s.latency.Latency(seg.End)
+			s.metrics.Segment(provider, seg.Final, latency)
+			s.statsMu.Lock()
+			s.stats.Provider, s.stats.Segments = provider, s.stats.Segments+1
+			if latency >= 0 {
+				s.stats.Latency = latency
+			}
+			s.statsMu.Unlock()
+			seg = s.clock.remap(seg)
+			if s.lang != nil && seg.Language == "" {
+				if det, ok := s.lang.Detection(); ok {
+					seg.Language = det.Language
+				}
+			}
+			if seg.Language == "" {
+				seg.Language = s.language
+			}
+			if s.diar != nil {
+				if seg.Final {
+					seg.Speaker = s.diar.Take()
+				} else {
+					seg.Speaker = s.diar.Peek()
+				}
+			}
+			if s.err = s.process(&seg); s.err != nil {
+				s.metrics.Error("plugin")
+				s.log.Error("transcript plugin failed", "utterance", seg.UtteranceID, "error", s.err)
+				s.publish(Event{Type: EventError, Segment: &seg, Err: s.err})
+				continue
+			}
+			s.alert(&seg)
+			if !seg.Final {
+				s.trace.partial(seg)
+				s.publish(Event{Type: EventPartial, Segment: &seg})
+				s.deliver(out, seg)
+				s.utterance(true, false)
+				continue
+			}
+			utt := s.trace.final(seg)
+			s.log.Debug("final segment", "utterance", seg.UtteranceID, "chars", len(seg.Text),
+				"speaker", seg.Speaker, "language", seg.Language)
+			if s.err = final(utt.ctx, s, &seg); s.err != nil {
+				s.metrics.Error("final")
+				s.log.Error("final segment processing failed", "utterance", seg.UtteranceID, "error", s.err)
+				s.publish(Event{Type: EventError, Segment: &seg, Err: s.err})
+				utt.end(s.err)
+				continue
+			}
+			s.deliver(out, seg)
+			utt.end(s.err)
+			s.utterance(false, false)
+		}
+		if s.err == nil {
+			// The recognizer may stop on its own when the context ends.
+			s.err = s.ctx.Err()
+		}
+	}()
+	return out
+}
+
+// utterance records whether an utterance is in progress, and whether the
+// stream has ended, waking Idle's waiters when neither.
+func (s *Stream) utterance(speaking, relayed bool) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	s.speaking, s.relayed = speaking, s.relayed || relayed
+	if !speaking {
+		for _, ch := range s.idle {
+			close(ch)
+		}
+		s.idle = nil
+	}
+}
+
+// Idle returns a channel closed once the stream is between utterances: at
+// once if no utterance is in progress or the stream has ended, otherwise
+// when the final segment of the one in progress has been delivered on
+// Results. Servers shutting down stop streams there, so no one is cut off
+// mid-sentence.
+func (s *Stream) Idle() <-chan struct{} {
+	ch := make(chan struct{})
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if s.speaking && !s.relayed {
+		s.idle = append(s.idle, ch)
+	} else {
+		close(ch)
+	}
+	return ch
+}
+
+// deliver sends seg on out unless the stream's context ends first, which
+// ends delivery: the remaining segments are drained.
+func (s *Stream) deliver(out chan<- Segment, seg Segment) {
+	select {
+	case out <- seg:
+	case <-s.ctx.Done():
+		s.err = s.ctx.Err()
+	}
+}
+
+// chain returns a callback invoking every non-nil fn in order.
+func chain[T any](fns ...func(T)) func(T) {
+	return func(v T) {
+		for _, fn := range fns {
+			if fn != nil {
+				fn(v)
+			}
+		}
+	}
+}
+
+// Format returns the audio format the stream expects.
+func (s *Stream) Format() audio.Format { return s.format }
+
+// WriteFrame runs fr through the audio stages and on to the recognizer.
+// With Config.Buffer it queues a copy of fr instead, and returns the error
+// that stopped processing, if any. fr may be reused once it returns.
+func (s *Stream) WriteFrame(fr audio.Frame) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.record(fr)
+	if s.in != nil {
+		return s.queue(fr.Clone())
+	}
+	s.one[0] = fr
+	return s.run(s.one[:], s.stages)
+}
+
+// queue hands fr, from the frame pool, to the buffer.
+func (s *Stream) queue(fr audio.Frame) error {
+	err := s.in.push(s.ctx, input{frame: fr})
+	if err != nil {
+		fr.Release()
+	}
+	return err
+}
+
+// run passes frames through stages and writes the result to the recognizer,
+// in one write. Each stage's output lands in one of two scratch slices,
+// alternately, as the next stage's input.
+func (s *Stream) run(frames []audio.Frame, stages []audio.Stage) error {
+	for i, st := range stages {
+		next := s.scratch[i%2][:0]
+		for _, f := range frames {
+			out, err := st.Process(f)
+			if err != nil {
+				return err
+			}
+			next = append(next, out...)
+		}
+		s.scratch[i%2] = next
+		frames = next
+	}
+	if len(frames) == 0 {
+		return nil
+	}
+	s.pcm = s.pcm[:0]
+	for _, f := range frames {
+		s.clock.add(f)
+		s.pcm = audio.AppendPCM16(s.pcm, f.Data)
+	}
+	if _, err := s.rec.Write(s.pcm); err != nil {
+		s.metrics.Error("stt")
+		return err
+	}
+	for _, f := range frames {
+		s.latency.Wrote(f.Duration())
+	}
+	s.trace.wrote()
+	return nil
+}
+
+// Write accepts raw little-endian PCM16 audio in the stream format.
+func (s *Stream) Write(p []byte) (int, error) {
+	fr := audio.Frame{
+		Format: s.format,
+		Data:   audio.DecodePCM16(audio.GetSamples(len(p)/2), p),
+		Offset: s.format.Duration(s.offset),
+	}
+	s.offset += fr.Len()
+	var err error
+	if s.in != nil {
+		s.record(fr)
+		err = s.queue(fr)
+	} else {
+		err = s.WriteFrame(fr)
+		fr.Release()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush finalizes the current utterance. With Config.Buffer it takes
+// effect once the audio written before it has been processed.
+func (s *Stream) Flush() error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if s.in != nil {
+		return s.in.push(s.ctx, input{flush: true})
+	}
+	return s.flush()
+}
+
+func (s *Stream) flush() error {
+	if s.lang != nil {
+		s.lang.Cut()
+	}
+	if s.diar != nil {
+		s.diar.Cut()
+	}
+	if s.prosody != nil {
+		s.prosody.Cut()
+	}
+	s.trace.cut()
+	return s.rec.Flush()
+}
+
+// closeEcho detaches the echo canceller of s from the pipeline.
+func (s *Stream) closeEcho() {
+	if s.echo != nil {
+		s.echo.Close()
+	}
+}
+
+// Close ends the stream. Remaining segments are still delivered on Results
+// before it is closed.
+func (s *Stream) Close() error {
+	s.closeTape()
+	s.closeEcho()
+	if s.in != nil {
+		if err := s.in.close(); err != nil {
+			_ = s.rec.Close()
+			return err
+		}
+	}
+	if s.conv != nil {
+		// The resampler holds back the last few milliseconds.
+		if err := s.run(s.conv.Flush(), s.stages[s.front:]); err != nil {
+			_ = s.rec.Close()
+			return err
+		}
+	}
+	if s.diar != nil {
+		s.diar.Cut()
+	}
+	if s.prosody != nil {
+		s.prosody.Cut()
+	}
+	s.trace.cut()
+	return s.rec.Close()
+}
+
+// Results returns the channel segments are delivered on.
+func (s *Stream) Results() <-chan Segment { return s.results }
+
+// Turns returns who spoke when so far. It is empty unless the pipeline
+// runs diarization.
+func (s *Stream) Turns() []SpeakerTurn {
+	if s.diar == nil {
+		return nil
+	}
+	return s.diar.Turns()
+}
+
+// Err reports why the stream ended, once Results is closed: the failure
+// of the recognizer or of the processing of a final segment, or the end
+// of the stream's context.
+func (s *Stream) Err() error {
+	if err := s.rec.Err(); err != nil {
+		return err
+	}
+	return s.err
+}
+
+// SessionID returns the conversation the stream belongs to.
+func (s *Stream) SessionID() string { return s.session }
+
+// Playback returns StreamOptions.Playback, or nil.
+func (s *Stream) Playback() *Playback { return s.playback }
+
+// StreamStats describe a stream so far.
+type StreamStats struct {
+	// Provider is the STT provider of the latest segment, the fallback the
+	// stream failed over to if any; the configured one before the first.
+	Provider string
+	// Latency is the time from the audio of the latest segment reaching
+	// the recognizer to the segment; -1 until known.
+	Latency time.Duration
+	// Audio is the audio that reached the recognizer.
+	Audio time.Duration
+	// Segments counts the segments the recognizer produced, partial and
+	// final.
+	Segments int
+}
+
+// Stats returns the stats of the stream. It is safe to call concurrently
+// with writes.
+func (s *Stream) Stats() StreamStats {
+	s.statsMu.Lock()
+	st := s.stats
+	s.statsMu.Unlock()
+	st.Audio = s.latency.Written()
+	return st
+}
+
+// Run streams src through the pipeline until src is exhausted or ctx is
+// done, calling fn for every segment in order. Frames read from src are
+// released to the frame pool once written.
+func (p *Pipeline) Run(ctx context.Context, src audio.Reader, fn func(Segment)) error {
+	stream, err := p.NewStream(ctx, src.Format(), StreamOptions{})
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for seg := range stream.Results() {
+			fn(seg)
+		}
+	}()
+
+	err = pump(ctx, src, stream)
+	if cerr := stream.Close(); err == nil {
+		err = cerr
+	}
+	<-done
+	if err == nil {
+		err = stream.Err()
+	}
+	return err
+}
+
+// pump copies frames from src into s until EOF or ctx is done. Sources
+// closed because of ctx may report EOF.
+func pump(ctx context.Context, src audio.Reader, s *Stream) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fr, err := src.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		err = s.WriteFrame(fr)
+		fr.Release()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// TracerProvider returns the provider the pipeline traces to.
+func (p *Pipeline) TracerProvider() trace.TracerProvider {
+	if p.cfg.TracerProvider != nil {
+		return p.cfg.TracerProvider
+	}
+	return otel.GetTracerProvider()
+}
+
+// Logger returns the logger the pipeline writes to.
+func (p *Pipeline) Logger() Logger { return p.cfg.Logger }
+
+// DetectsDTMF reports whether the pipeline detects keypad tones; see
+// Config.DTMF.
+func (p *Pipeline) DetectsDTMF() bool { return p.cfg.DTMF != nil }
+
+// Metrics returns the metrics the pipeline updates, or nil.
+func (p *Pipeline) Metrics() *Metrics { return p.cfg.Metrics }
+
+// Close releases the backends, once the archive has uploaded the audio of
+// the streams closed and their sessions have been summarized, and ends the
+// subscriptions once their handlers have had the events waiting.
+func (p *Pipeline) Close() error {
+	if p.stopPurge != nil {
+		p.stopPurge()
+		p.purging.Wait()
+	}
+	p.summaries.Wait()
+	p.bus.close()
+	var errs []error
+	if c, ok := p.rec.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	if p.archive != nil {
+		errs = append(errs, p.archive.Close())
+	}
+	return errors.Join(append(errs, p.closePlugins())...)
+}
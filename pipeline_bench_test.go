@@ -0,0 +1,214 @@
+package voxa_test
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// discard is a recognizer that drops its audio, so benchmarks measure the
+// pipeline alone.
+type discard struct{ results chan stt.Segment }
+
+func (d *discard) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discard) Results() <-chan stt.Segment { return d.results }
+func (d *discard) Flush() error                { return nil }
+func (d *discard) Close() error                { close(d.results); return nil }
+func (d *discard) Err() error                  { return nil }
+
+// discardProvider takes 16kHz audio, like most backends, so sources at
+// other rates are resampled.
+type discardProvider struct{}
+
+func (discardProvider) NewStream(context.Context, stt.StreamConfig) (stt.StreamingRecognizer, error) {
+	return &discard{results: make(chan stt.Segment)}, nil
+}
+
+func (discardProvider) RequiredFormat() audio.Format {
+	return audio.Format{SampleRate: 16000, Channels: 1}
+}
+
+// clocked is a recognizer timing the audio it receives against the
+// times the benchmark wrote it at: chunk i, of len(chunk) bytes starting
+// with i as a little-endian uint32, was written at sent[i]. Chunks dropped
+// on the way are not timed.
+type clocked struct {
+	discard
+	chunk   int
+	sent    []time.Time
+	pending []byte // of a chunk split across writes
+	latency []time.Duration
+}
+
+func (c *clocked) Write(p []byte) (int, error) {
+	now := time.Now()
+	n := len(p)
+	if len(c.pending) > 0 {
+		p = append(c.pending, p...)
+	}
+	for ; len(p) >= c.chunk; p = p[c.chunk:] {
+		c.latency = append(c.latency, now.Sub(c.sent[binary.LittleEndian.Uint32(p)]))
+	}
+	c.pending = append(c.pending[:0], p...)
+	return n, nil
+}
+
+// clockedProvider hands out the one clocked recognizer of a benchmark.
+type clockedProvider struct{ rec *clocked }
+
+func (c clockedProvider) NewStream(context.Context, stt.StreamConfig) (stt.StreamingRecognizer, error) {
+	return c.rec, nil
+}
+
+var (
+	benchMu     sync.Mutex
+	benchClocks *clocked // of the running latency benchmark
+)
+
+func init() {
+	stt.Register("bench-discard", func(stt.Config) (stt.Provider, error) { return discardProvider{}, nil })
+	stt.Register("bench-clocked", func(stt.Config) (stt.Provider, error) {
+		benchMu.Lock()
+		defer benchMu.Unlock()
+		return clockedProvider{benchClocks}, nil
+	})
+}
+
+// pcm returns a 20ms chunk of a 16kHz tone as PCM16 bytes.
+func pcm() []byte {
+	samples := make([]int16, 320)
+	for i := range samples {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*220*float64(i)/16000))
+	}
+	return audio.AppendPCM16(nil, samples)
+}
+
+// BenchmarkStreamWrite measures the write path of a stream, from PCM bytes
+// to the recognizer, and reports its throughput as a multiple of real
+// time. Steady streaming should not allocate per chunk.
+func BenchmarkStreamWrite(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		cfg  voxa.Config
+	}{
+		{"plain", voxa.Config{}},
+		{"vad", voxa.Config{VAD: &voxa.VADConfig{}}},
+		{"resample", voxa.Config{}},
+		{"buffered", voxa.Config{Buffer: &voxa.BufferConfig{}}},
+		{"stages", voxa.Config{
+			VAD:         &voxa.VADConfig{},
+			Denoise:     &voxa.DenoiseConfig{},
+			GainControl: &voxa.GainConfig{},
+			Endpointing: &voxa.EndpointingConfig{},
+		}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			cfg := bc.cfg
+			cfg.Recognizer.Provider = "bench-discard"
+			p, err := voxa.NewPipeline(cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer p.Close()
+			format := audio.Format{SampleRate: 16000, Channels: 1}
+			if bc.name == "resample" {
+				format.SampleRate = 48000
+			}
+			s, err := p.NewStream(context.Background(), format, voxa.StreamOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			go func() {
+				for range s.Results() {
+				}
+			}()
+			chunk := pcm()
+			b.SetBytes(int64(len(chunk)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := s.Write(chunk); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			heard := time.Duration(b.N) * format.Duration(len(chunk)/2)
+			b.ReportMetric(heard.Seconds()/b.Elapsed().Seconds(), "x-realtime")
+			if err := s.Close(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+// BenchmarkStreamLatency measures the latency the pipeline adds to audio,
+// from Write to the recognizer, and reports its median and 99th
+// percentile. The streaming path should keep the p99 well under 50ms, even
+// with a buffer the writer fills as fast as it can.
+func BenchmarkStreamLatency(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		cfg  voxa.Config
+	}{
+		{"plain", voxa.Config{}},
+		{"vad", voxa.Config{VAD: &voxa.VADConfig{}}},
+		{"buffered", voxa.Config{Buffer: &voxa.BufferConfig{}}},
+		{"buffered-drop-oldest", voxa.Config{Buffer: &voxa.BufferConfig{Overflow: voxa.OverflowDropOldest}}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			chunk := pcm()
+			rec := &clocked{
+				discard: discard{results: make(chan stt.Segment)},
+				chunk:   len(chunk),
+				sent:    make([]time.Time, b.N),
+				latency: make([]time.Duration, 0, b.N),
+			}
+			benchMu.Lock()
+			benchClocks = rec
+			benchMu.Unlock()
+			cfg := bc.cfg
+			cfg.Recognizer.Provider = "bench-clocked"
+			p, err := voxa.NewPipeline(cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer p.Close()
+			s, err := p.NewStream(context.Background(), audio.Format{SampleRate: 16000, Channels: 1}, voxa.StreamOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			go func() {
+				for range s.Results() {
+				}
+			}()
+			b.SetBytes(int64(len(chunk)))
+			b.ResetTimer()
+			for i := range b.N {
+				binary.LittleEndian.PutUint32(chunk, uint32(i))
+				rec.sent[i] = time.Now()
+				if _, err := s.Write(chunk); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := s.Close(); err != nil {
+				b.Fatal(err)
+			}
+			b.StopTimer()
+			lat := rec.latency
+			if len(lat) == 0 {
+				return // all dropped: nothing to report
+			}
+			slices.Sort(lat)
+			b.ReportMetric(float64(lat[len(lat)/2].Microseconds()), "p50-µs")
+			b.ReportMetric(float64(lat[len(lat)*99/100].Microseconds()), "p99-µs")
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package voxa
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/tts"
+)
+
+// SpeakBySentence returns a Synthesizer speaking plain text on s one
+// sentence at a time, so a reply starts playing as soon as its first
+// sentence is synthesized; see SynthesizerConfig.Sentences, which does the
+// same for NewSynthesizer.
+func SpeakBySentence(s Synthesizer) Synthesizer {
+	return tts.BySentence(s)
+}
+
+// ErrBargeIn is returned by the streams of a Playback that the user spoke
+// over.
+var ErrBargeIn = errors.New("voxa: playback interrupted by barge-in")
+
+// Playback tracks the reply a voice assistant is playing to the speaker of
+// a stream, so that the stream can cut it short when they speak over it: a
+// barge-in. Set it as StreamOptions.Playback and play synthesized audio
+// through Play, to a Player for instance; when the VAD of the stream
+// detects speech, the audio stops, and the stream, which has been
+// listening all along, transcribes what the user says. The stream must
+// keep being fed while the reply plays, with the reply cancelled out of
+// the microphone audio by Config.EchoCancellation, the device or the
+// client, or the reply itself is taken for the user.
+//
+// A Playback is safe for concurrent use.
+type Playback struct {
+	mu  sync.Mutex
+	cur *playing
+}
+
+// NewPlayback returns a Playback with nothing playing.
+func NewPlayback() *Playback {
+	return &Playback{}
+}
+
+// Play makes out the reply being played, interrupting the one before it if
+// it is still playing, and returns the stream to read its audio from
+// instead of out. Once interrupted, the stream fails with ErrBargeIn and
+// out is closed, aborting its synthesis. The reply ends when its stream
+// reaches io.EOF, fails or is closed.
+func (p *Playback) Play(out SynthesisStream) SynthesisStream {
+	pl := &playing{SynthesisStream: out, p: p}
+	p.mu.Lock()
+	prev := p.cur
+	p.cur = pl
+	p.mu.Unlock()
+	if prev != nil {
+		prev.interrupt()
+	}
+	return pl
+}
+
+// Interrupt stops the reply playing, reporting whether there was one.
+func (p *Playback) Interrupt() bool {
+	p.mu.Lock()
+	pl := p.cur
+	p.cur = nil
+	p.mu.Unlock()
+	if pl == nil {
+		return false
+	}
+	pl.interrupt()
+	return true
+}
+
+// Playing reports whether a reply is playing. Audio the application has
+// read but its device has not played yet does not count.
+func (p *Playback) Playing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cur != nil
+}
+
+// end forgets pl if it is the reply playing.
+func (p *Playback) end(pl *playing) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cur == pl {
+		p.cur = nil
+	}
+}
+
+// playing is a reply played through a Playback.
+type playing struct {
+	SynthesisStream
+	p *Playback
+
+	mu          sync.Mutex
+	interrupted bool
+}
+
+func (pl *playing) interrupt() {
+	pl.mu.Lock()
+	pl.interrupted = true
+	pl.mu.Unlock()
+	_ = pl.SynthesisStream.Close()
+}
+
+func (pl *playing) isInterrupted() bool {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.interrupted
+}
+
+func (pl *playing) ReadFrame() (audio.Frame, error) {
+	if pl.isInterrupted() {
+		return audio.Frame{}, ErrBargeIn
+	}
+	fr, err := pl.SynthesisStream.ReadFrame()
+	if err != nil {
+		// Closing the stream under a blocked read fails it however the
+		// backend fails reads on closed streams.
+		if pl.isInterrupted() {
+			return audio.Frame{}, ErrBargeIn
+		}
+		pl.p.end(pl)
+	}
+	return fr, err
+}
+
+func (pl *playing) Close() error {
+	pl.p.end(pl)
+	return pl.SynthesisStream.Close()
+}
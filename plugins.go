@@ -0,0 +1,125 @@
+package voxa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/plugin"
+
+	// Bundled plugins, selectable by name.
+	_ "github.com/jmarc101/voxa/internal/plugin/process"
+	_ "github.com/jmarc101/voxa/internal/plugin/wasm"
+)
+
+// PluginConfig selects a registered plugin by name.
+type PluginConfig = plugin.Config
+
+// AudioPlugin creates a custom audio stage for every stream; see
+// Config.AudioPlugins.
+type AudioPlugin = plugin.Audio
+
+// TranscriptPlugin rewrites segments; see Config.TranscriptPlugins.
+type TranscriptPlugin = plugin.Transcript
+
+// AudioStage processes the audio of one stream.
+type AudioStage = audio.Stage
+
+// AudioFrame is a chunk of PCM audio.
+type AudioFrame = audio.Frame
+
+// AudioFormat describes PCM audio.
+type AudioFormat = audio.Format
+
+// RegisterAudioPlugin makes an audio plugin available under name, for
+// Config.AudioPlugins. It is meant to be called from the plugin's init
+// function and panics if name is already taken.
+func RegisterAudioPlugin(name string, factory func(PluginConfig) (AudioPlugin, error)) {
+	plugin.RegisterAudio(name, factory)
+}
+
+// RegisterTranscriptPlugin makes a transcript plugin available under name,
+// for Config.TranscriptPlugins. It is meant to be called from the plugin's
+// init function and panics if name is already taken. Besides those, "exec"
+// runs a plugin as an external process and "wasm" runs one as a sandboxed
+// WebAssembly module; see packages plugin/process and plugin/wasm.
+func RegisterTranscriptPlugin(name string, factory func(PluginConfig) (TranscriptPlugin, error)) {
+	plugin.RegisterTranscript(name, factory)
+}
+
+// OpenPlugin loads a Go plugin built with -buildmode=plugin, whose init
+// functions register audio or transcript plugins. It must be built with
+// the same toolchain and voxa version as the program.
+func OpenPlugin(path string) error {
+	return plugin.Open(path)
+}
+
+// namedAudio is an audio plugin with the name its stages are measured
+// under.
+type namedAudio struct {
+	name string
+	plugin.Audio
+}
+
+// namedTranscript is a transcript stage with the name it is measured
+// under.
+type namedTranscript struct {
+	name string
+	plugin.Transcript
+}
+
+// openPlugins instantiates Config.AudioPlugins and Config.TranscriptPlugins.
+func (p *Pipeline) openPlugins() error {
+	for _, cfg := range p.cfg.AudioPlugins {
+		if cfg.Logger == nil {
+			cfg.Logger = logging.With(p.cfg.Logger, "plugin", cfg.Name)
+		}
+		a, err := plugin.NewAudio(cfg)
+		if err != nil {
+			return err
+		}
+		p.audio = append(p.audio, namedAudio{name: cfg.Name, Audio: a})
+	}
+	for _, cfg := range p.cfg.TranscriptPlugins {
+		if cfg.Logger == nil {
+			cfg.Logger = logging.With(p.cfg.Logger, "plugin", cfg.Name)
+		}
+		t, err := plugin.NewTranscript(cfg)
+		if err != nil {
+			return err
+		}
+		p.post = append(p.post, namedTranscript{name: cfg.Name, Transcript: t})
+	}
+	return nil
+}
+
+// closePlugins closes the plugins that need it.
+func (p *Pipeline) closePlugins() error {
+	var errs []error
+	for _, a := range p.audio {
+		if c, ok := a.Audio.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	for _, t := range p.post {
+		if c, ok := t.Transcript.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// process runs the transcript plugins on seg, then the built-in transcript
+// filters.
+func (s *Stream) process(seg *Segment) error {
+	for _, t := range s.post {
+		err := s.step(s.ctx, t.name, "", func(ctx context.Context) error { return t.Process(ctx, seg) })
+		if err != nil {
+			return fmt.Errorf("voxa: plugin: %w", err)
+		}
+	}
+	return nil
+}
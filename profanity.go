@@ -0,0 +1,24 @@
+package voxa
+
+import "github.com/jmarc101/voxa/internal/profanity"
+
+// ProfanityConfig configures the profanity filter; see Config.Profanity.
+type ProfanityConfig = profanity.Config
+
+// ProfanityMode selects what the profanity filter does with a match.
+type ProfanityMode = profanity.Mode
+
+// Profanity filter modes.
+const (
+	// ProfanityMask stars all but the first letter: "f***".
+	ProfanityMask = profanity.Mask
+	// ProfanityDrop removes the word.
+	ProfanityDrop = profanity.Drop
+	// ProfanityTag wraps the word in <profanity></profanity>.
+	ProfanityTag = profanity.Tag
+)
+
+// ParseProfanityMode returns the mode named "mask", "drop" or "tag".
+func ParseProfanityMode(s string) (ProfanityMode, error) {
+	return profanity.ParseMode(s)
+}
@@ -0,0 +1,27 @@
+package voxa
+
+import "github.com/jmarc101/voxa/internal/punctuate"
+
+// PunctuationConfig configures punctuation restoration; see
+// Config.Punctuation.
+type PunctuationConfig = punctuate.Config
+
+// PunctuationModel predicts the punctuation of final segments for
+// punctuation restoration, in place of its rules.
+type PunctuationModel = punctuate.Model
+
+// PunctuationToken is the punctuation a PunctuationModel predicts for one
+// word.
+type PunctuationToken = punctuate.Token
+
+// NewHTTPPunctuationModel returns a PunctuationModel served at an http or
+// https endpoint; see punctuate.HTTPModel for the protocol.
+func NewHTTPPunctuationModel(endpoint string) (PunctuationModel, error) {
+	return punctuate.NewHTTPModel(endpoint, nil)
+}
+
+// PunctuationLanguages returns the ISO 639-1 codes of the languages
+// punctuation restoration has rules for.
+func PunctuationLanguages() []string {
+	return punctuate.Languages()
+}
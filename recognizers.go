@@ -0,0 +1,9 @@
+package voxa
+
+import (
+	// Bundled recognition backends, selectable by provider name.
+	_ "github.com/jmarc101/voxa/internal/stt/assemblyai"
+	_ "github.com/jmarc101/voxa/internal/stt/deepgram"
+	_ "github.com/jmarc101/voxa/internal/stt/onnx"
+	_ "github.com/jmarc101/voxa/internal/stt/whisper"
+)
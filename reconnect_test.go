@@ -0,0 +1,82 @@
+package voxa_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/stt"
+	"github.com/jmarc101/voxa/stttest"
+)
+
+// dropped is a provider link going down, worth reconnecting.
+type dropped struct{}
+
+func (dropped) Error() string   { return "connection dropped" }
+func (dropped) Transient() bool { return true }
+
+func TestReconnectReplaysAndDedups(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	word := func(text string, start, end int) voxa.Word {
+		return voxa.Word{Text: text, Start: ms(start), End: ms(end)}
+	}
+	rec := stttest.New(
+		append(stttest.Utterance("1", "turn on the lights", ms(200), ms(1000)),
+			stttest.Partial(ms(1400), "2", "and the"),
+			stttest.Fail(ms(1500), dropped{})),
+		// The replacement hears the audio from 500ms before the final on,
+		// last word of it included.
+		stttest.Script{{At: ms(1300), Segment: voxa.Segment{
+			Text: "lights and the fan", Final: true, Start: ms(300), End: ms(1300),
+			Words: []voxa.Word{word("lights", 300, 500), word("and", 600, 800), word("the", 800, 1000), word("fan", 1000, 1300)},
+		}}},
+	)
+	cfg := rec.Config()
+	cfg.Resilience = &voxa.ResilienceConfig{Backoff: time.Millisecond}
+	p, err := stt.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.NewStream(context.Background(), stt.StreamConfig{SampleRate: 16000, UtteranceID: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		chunk := make([]byte, 2*1600)
+		for range 20 {
+			if _, err := s.Write(chunk); err != nil {
+				t.Error(err)
+			}
+		}
+		_ = s.Close()
+	}()
+	var finals []voxa.Segment
+	var partial voxa.Segment
+	for seg := range s.Results() {
+		if seg.Final {
+			finals = append(finals, seg)
+		} else if seg.UtteranceID == "2" {
+			partial = seg
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(finals) != 2 {
+		t.Fatalf("got %d finals, want 2: %+v", len(finals), finals)
+	}
+	got := finals[1]
+	if got.UtteranceID != "2" || got.Text != "and the fan" || got.Start != ms(1100) || got.End != ms(1800) {
+		t.Errorf("after reconnecting got %s %q %v-%v, want 2 \"and the fan\" 1.1s-1.8s", got.UtteranceID, got.Text, got.Start, got.End)
+	}
+	if got.Revision <= partial.Revision {
+		t.Errorf("final revision %d does not follow the partial's %d", got.Revision, partial.Revision)
+	}
+	if n := len(rec.Streams()); n != 2 {
+		t.Fatalf("%d streams opened, want 2", n)
+	}
+	if a := rec.Streams()[1].Written(); a != 1500*time.Millisecond {
+		t.Errorf("replacement got %v of audio, want 1.5s", a)
+	}
+}
@@ -0,0 +1,25 @@
+package voxa
+
+import (
+	"github.com/jmarc101/voxa/internal/redact"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// RedactionConfig configures the redaction of personal data; see
+// Config.Redaction.
+type RedactionConfig = redact.Config
+
+// PIIEntity is a kind of personal data the redactor detects.
+type PIIEntity = redact.Entity
+
+// Built-in PII entities.
+const (
+	PIICreditCard = redact.CreditCard
+	PIISSN        = redact.SSN
+	PIIPhone      = redact.Phone
+	PIIEmail      = redact.Email
+)
+
+// Redaction records a span of a segment's text replaced by a marker such
+// as "[SSN]"; see Segment.Redactions.
+type Redaction = stt.Redaction
@@ -0,0 +1,34 @@
+package voxa
+
+import (
+	"github.com/jmarc101/voxa/internal/ratelimit"
+	"github.com/jmarc101/voxa/internal/resilience"
+)
+
+// ResilienceConfig tunes the retries and circuit breaker guarding a
+// backend, set as RecognizerConfig.Resilience or
+// SynthesizerConfig.Resilience. Providers listed as Fallbacks take over,
+// in order, from those that fail or have their breaker open.
+type ResilienceConfig = resilience.Config
+
+// ErrCircuitOpen is returned for calls to a backend whose circuit breaker
+// is open, when there is no fallback left.
+var ErrCircuitOpen = resilience.ErrOpen
+
+// ParseResilienceConfig reads a ResilienceConfig from key=value options:
+// "attempts", "backoff", "max_backoff", "jitter", "threshold" and
+// "cooldown".
+func ParseResilienceConfig(opts map[string]string) (ResilienceConfig, error) {
+	return resilience.ParseConfig(opts)
+}
+
+// RateLimitConfig holds a provider to the request rate and concurrency of
+// its account, set as the RateLimit of a RecognizerConfig,
+// SynthesizerConfig or TranslationConfig. Providers limited under the same
+// Key, which defaults to the provider name, share the limits, across
+// pipelines too.
+type RateLimitConfig = ratelimit.Config
+
+// ErrRateLimited is returned for calls that would have waited longer than
+// the MaxWait of their provider's RateLimit.
+var ErrRateLimited = ratelimit.ErrLimited
@@ -0,0 +1,47 @@
+package voxa
+
+import (
+	"context"
+
+	"github.com/jmarc101/voxa/internal/retention"
+)
+
+// RetentionConfig sets how long sessions are kept, overall and per
+// tenant; see Config.Retention.
+type RetentionConfig = retention.Config
+
+// DefaultRetentionInterval is how often expired sessions are looked for
+// when RetentionConfig.Interval is unset.
+const DefaultRetentionInterval = retention.DefaultInterval
+
+// newPurger returns the purger of cfg.Retention, nil without it.
+func newPurger(cfg Config) (*retention.Purger, error) {
+	if cfg.Retention == nil {
+		return nil, nil
+	}
+	return retention.New(*cfg.Retention, stores(cfg), cfg.Logger)
+}
+
+func stores(cfg Config) retention.Stores {
+	s := retention.Stores{Transcripts: cfg.Transcripts, Sessions: cfg.Sessions}
+	if cfg.Archive != nil {
+		s.Archive = cfg.Archive.Storage
+	}
+	return s
+}
+
+// DeleteSession forgets session id wherever the pipeline keeps it: its
+// archived audio, its transcript with every version and its summary, and
+// its conversation state, as for a request to be forgotten. The session
+// should not be running. It fails with ErrTranscriptNotFound if
+// transcripts are stored but not that of the session, having still
+// forgotten its conversation state.
+func (p *Pipeline) DeleteSession(ctx context.Context, id string) error {
+	if p.cfg.Transcripts == nil {
+		if p.cfg.Sessions != nil {
+			return p.cfg.Sessions.Delete(ctx, id)
+		}
+		return nil
+	}
+	return stores(p.cfg).Delete(ctx, id)
+}
@@ -0,0 +1,44 @@
+package voxa
+
+import (
+	"github.com/jmarc101/voxa/internal/sentiment"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// Sentiment is the tone of a final segment; see Segment.Sentiment.
+type Sentiment = stt.Sentiment
+
+// Sentiment labels.
+const (
+	SentimentPositive = stt.SentimentPositive
+	SentimentNegative = stt.SentimentNegative
+	SentimentNeutral  = stt.SentimentNeutral
+)
+
+// Emotions of Sentiment.Emotion.
+const (
+	EmotionNeutral = sentiment.Neutral
+	EmotionAngry   = sentiment.Angry
+	EmotionHappy   = sentiment.Happy
+	EmotionSad     = sentiment.Sad
+	EmotionCalm    = sentiment.Calm
+)
+
+// SentimentConfig configures sentiment analysis; see Config.Sentiment.
+type SentimentConfig = sentiment.Config
+
+// SentimentModel scores the sentiment of final segments for sentiment
+// analysis, in place of its lexicon.
+type SentimentModel = sentiment.Model
+
+// NewHTTPSentimentModel returns a SentimentModel served at an http or
+// https endpoint; see sentiment.HTTPModel for the protocol.
+func NewHTTPSentimentModel(endpoint string) (SentimentModel, error) {
+	return sentiment.NewHTTPModel(endpoint, nil)
+}
+
+// SentimentLanguages returns the ISO 639-1 codes of the languages sentiment
+// analysis has a lexicon for.
+func SentimentLanguages() []string {
+	return sentiment.Languages()
+}
@@ -25,9 +25,10 @@ class AsrEngine:
             audio_path: Path to the audio file to transcribe
 
         Returns:
-            List of segment dictionaries with transcription results
+            List of segment dictionaries with transcription results,
+            including word-level timestamps and confidences
         """
-        segments, info = self._model.transcribe(audio_path, language="en", word_timestamps=False)
+        segments, info = self._model.transcribe(audio_path, language="en", word_timestamps=True)
 
         result = []
         for i, seg in enumerate(segments, start=1):
@@ -39,6 +40,17 @@ class AsrEngine:
                 "avg_logprob": float(seg.avg_logprob),
                 "confidence": math.exp(float(seg.avg_logprob)),
                 "language": info.language,
+                # Per-word alignment (seconds) for karaoke/subtitle clients;
+                # maps onto voxa.speech.v1.Word.
+                "words": [
+                    {
+                        "text": w.word.strip(),
+                        "start_time": w.start,
+                        "end_time": w.end,
+                        "confidence": float(w.probability),
+                    }
+                    for w in (seg.words or [])
+                ],
             })
 
         return result
@@ -0,0 +1,71 @@
+package voxa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmarc101/voxa/internal/session"
+)
+
+// SessionStore persists conversation state between streams.
+type SessionStore = session.Store
+
+// Session is the state of one conversation: JSON-serializable values by
+// key.
+type Session = session.Session
+
+// NewMemorySessionStore returns a store that keeps sessions in process
+// memory.
+func NewMemorySessionStore() SessionStore {
+	return session.NewMemory()
+}
+
+// NewRedisSessionStore returns a store that keeps sessions in Redis, at a
+// redis://[user:password@]host[:port][/db] URL.
+func NewRedisSessionStore(url string) (SessionStore, error) {
+	return session.NewRedis(url, "")
+}
+
+// errNoSessions is returned by the session methods of a Pipeline without
+// Config.Sessions.
+var errNoSessions = errors.New("voxa: pipeline has no session store")
+
+// LoadSession returns conversation id, empty if it does not exist yet.
+func (p *Pipeline) LoadSession(ctx context.Context, id string) (*Session, error) {
+	if p.sessions == nil {
+		return nil, errNoSessions
+	}
+	return p.sessions.Load(ctx, id)
+}
+
+// SaveSession writes sess back to the store and restarts its TTL.
+func (p *Pipeline) SaveSession(ctx context.Context, sess *Session) error {
+	if p.sessions == nil {
+		return errNoSessions
+	}
+	return p.sessions.Save(ctx, sess)
+}
+
+// EndSession forgets conversation id.
+func (p *Pipeline) EndSession(ctx context.Context, id string) error {
+	if p.sessions == nil {
+		return errNoSessions
+	}
+	return p.sessions.Delete(ctx, id)
+}
+
+// turn runs Config.OnTurn for a final segment of s.
+func (p *Pipeline) turn(ctx context.Context, s *Stream, seg Segment) error {
+	if p.sessions == nil || p.cfg.OnTurn == nil {
+		return nil
+	}
+	sess, err := p.sessions.Load(ctx, s.session)
+	if err != nil {
+		return err
+	}
+	if err := p.cfg.OnTurn(ctx, sess, seg); err != nil {
+		return fmt.Errorf("voxa: turn handler: %w", err)
+	}
+	return p.sessions.Save(ctx, sess)
+}
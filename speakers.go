@@ -0,0 +1,30 @@
+package voxa
+
+import (
+	"github.com/jmarc101/voxa/internal/speaker"
+)
+
+// SpeakerRegistry holds the voiceprints of enrolled speakers; see
+// Config.Speakers.
+type SpeakerRegistry = speaker.Registry
+
+// SpeakersConfig configures a SpeakerRegistry.
+type SpeakersConfig = speaker.Config
+
+// Voiceprint is an enrolled speaker.
+type Voiceprint = speaker.Voiceprint
+
+// Errors of SpeakerRegistry.
+var (
+	ErrSpeakerNotFound = speaker.ErrNotFound
+	ErrSpeakerTooShort = speaker.ErrTooShort
+	ErrSpeakerName     = speaker.ErrBadName
+)
+
+// NewSpeakerRegistry opens a registry, loading the voiceprints of its file.
+func NewSpeakerRegistry(cfg SpeakersConfig) (*SpeakerRegistry, error) {
+	return speaker.New(cfg)
+}
+
+// Speakers returns Config.Speakers, nil if speakers are not identified.
+func (p *Pipeline) Speakers() *SpeakerRegistry { return p.cfg.Speakers }
@@ -0,0 +1,59 @@
+package voxa
+
+import "github.com/jmarc101/voxa/internal/tn"
+
+// SpeechNormalizationConfig configures the normalization of the text
+// synthesizers speak, which writes out numbers, units, dates and
+// abbreviations the way they are read aloud; see
+// SynthesizerConfig.Normalization.
+type SpeechNormalizationConfig = tn.Config
+
+// SpeechNormalizationClass is a class of entities speech normalization
+// writes out, which SpeechNormalizationConfig.Disable may turn off.
+type SpeechNormalizationClass = tn.Class
+
+// Speech normalization entity classes.
+const (
+	SpeakCardinal     = tn.Cardinal
+	SpeakOrdinal      = tn.Ordinal
+	SpeakDecimal      = tn.Decimal
+	SpeakPercent      = tn.Percent
+	SpeakCurrency     = tn.Currency
+	SpeakMeasure      = tn.Measure
+	SpeakDate         = tn.Date
+	SpeakTime         = tn.Time
+	SpeakAbbreviation = tn.Abbreviation
+)
+
+// SpeechNormalizer writes text out the way it is spoken, as synthesizers
+// configured with SynthesizerConfig.Normalization do before speaking it.
+type SpeechNormalizer = tn.Normalizer
+
+// NewSpeechNormalizer returns a normalizer by the rules of cfg.Locale, for
+// text synthesized elsewhere.
+func NewSpeechNormalizer(cfg SpeechNormalizationConfig) (*SpeechNormalizer, error) {
+	return tn.New(cfg)
+}
+
+// SpeechNormalizationLocales returns the locales speech normalization has
+// rules for.
+func SpeechNormalizationLocales() []string {
+	return tn.Locales()
+}
+
+// Lexicon maps the written forms of domain terms, such as product names
+// and acronyms, to how they are spoken; entries take precedence over the
+// rules of speech normalization.
+type Lexicon = tn.Lexicon
+
+// RegisterLexicon makes lex available under name to
+// SpeechNormalizationConfig.Lexicons. It is meant to be called from an
+// init function, or a plugin, and panics if name is already taken.
+func RegisterLexicon(name string, lex Lexicon) {
+	tn.RegisterLexicon(name, lex)
+}
+
+// Lexicons returns the names of the registered lexicons.
+func Lexicons() []string {
+	return tn.Lexicons()
+}
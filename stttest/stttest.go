@@ -0,0 +1,409 @@
+// Package stttest provides a scriptable speech-to-text provider for tests of
+// programs built on voxa, which runs without a backend or network access.
+//
+// A Provider plays a Script on every stream it opens: events delivering
+// partial and final segments, or failing the stream, each once a given
+// amount of audio has been written to the stream or when it is flushed.
+// Events are tied to the audio rather than to the clock, so a test writing
+// the same audio always sees the same transcript, however fast it runs;
+// Event.Delay adds wall-clock latency on top for tests of timeouts and
+// failover. Streams record what they were sent for assertions.
+//
+//	rec := stttest.New(stttest.Utterance("1", "turn on the lights", 200*time.Millisecond, time.Second))
+//	p, err := voxa.NewPipeline(voxa.Config{Recognizer: rec.Config()})
+package stttest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/stt"
+)
+
+// ProviderName is the name Config selects providers by.
+const ProviderName = "stttest"
+
+// Event is a scripted step of a stream. Events happen in order, each
+// waiting for the ones before it.
+type Event struct {
+	// At is the audio written to the stream after which the event happens.
+	At time.Duration
+	// Flush holds the event, in place of At, until the stream is next
+	// flushed or closed.
+	Flush bool
+	// Delay is the time the event takes to happen once due.
+	Delay time.Duration
+	// Segment is delivered on Results. An empty UtteranceID takes the one
+	// of StreamConfig, or "1"; a zero Revision counts the segments of the
+	// utterance from 1, and finals have a Stability of 1.
+	Segment voxa.Segment
+	// Err, if set, fails the stream instead: Results is closed and Err,
+	// Write and Flush return it.
+	Err error
+}
+
+// Script is the events of a stream.
+type Script []Event
+
+// Partial returns an event delivering a partial hypothesis of utterance id
+// once at audio has been written.
+func Partial(at time.Duration, id, text string) Event {
+	return Event{At: at, Segment: voxa.Segment{UtteranceID: id, Text: text, End: at}}
+}
+
+// Final returns an event delivering the final transcript of utterance id
+// once at audio has been written.
+func Final(at time.Duration, id, text string) Event {
+	return Event{At: at, Segment: voxa.Segment{UtteranceID: id, Text: text, Final: true, End: at}}
+}
+
+// Fail returns an event failing the stream with err once at audio has been
+// written.
+func Fail(at time.Duration, err error) Event {
+	return Event{At: at, Err: err}
+}
+
+// Utterance returns the events of utterance id spoken between start and
+// end: a partial growing by one word at every word's end, then the final
+// with the words aligned evenly over the utterance.
+func Utterance(id, text string, start, end time.Duration) Script {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return Script{{At: end, Segment: voxa.Segment{UtteranceID: id, Final: true, Start: start, End: end}}}
+	}
+	n := len(fields)
+	words := make([]voxa.Word, n)
+	for i, f := range fields {
+		words[i] = voxa.Word{Text: f, Start: start + (end-start)*time.Duration(i)/time.Duration(n), End: start + (end-start)*time.Duration(i+1)/time.Duration(n)}
+	}
+	var s Script
+	for i := range n - 1 {
+		s = append(s, Event{At: words[i].End, Segment: voxa.Segment{
+			UtteranceID: id,
+			Text:        strings.Join(fields[:i+1], " "),
+			Stability:   float32(i+1) / float32(n),
+			Start:       start,
+			End:         words[i].End,
+		}})
+	}
+	return append(s, Event{At: end, Segment: voxa.Segment{
+		UtteranceID: id,
+		Text:        strings.Join(fields, " "),
+		Final:       true,
+		Start:       start,
+		End:         end,
+		Words:       words,
+	}})
+}
+
+// Provider opens scripted streams. The zero value is not usable; see New.
+type Provider struct {
+	mu       sync.Mutex
+	scripts  []Script
+	openErrs []error
+	streams  []*Stream
+	id       string
+}
+
+// New returns a provider whose streams play scripts in the order they are
+// opened, streams past the last script playing the last one again. With
+// no script, streams never deliver anything.
+func New(scripts ...Script) *Provider {
+	return &Provider{scripts: scripts}
+}
+
+// FailOpen makes the next calls to NewStream return errs, one each in
+// order. Nil errors open streams as usual.
+func (p *Provider) FailOpen(errs ...error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.openErrs = append(p.openErrs, errs...)
+}
+
+// NewStream opens a stream playing the next script.
+func (p *Provider) NewStream(ctx context.Context, cfg voxa.StreamConfig) (voxa.StreamingRecognizer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.openErrs) > 0 {
+		err := p.openErrs[0]
+		p.openErrs = p.openErrs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	var script Script
+	if len(p.scripts) > 0 {
+		script = p.scripts[min(len(p.streams), len(p.scripts)-1)]
+	}
+	s := newStream(ctx, cfg, script)
+	p.streams = append(p.streams, s)
+	return s, nil
+}
+
+// Streams returns the streams opened so far, in order.
+func (p *Provider) Streams() []*Stream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*Stream(nil), p.streams...)
+}
+
+var (
+	registryMu sync.Mutex
+	providers  = map[string]*Provider{}
+)
+
+func init() {
+	stt.Register(ProviderName, func(cfg stt.Config) (stt.Provider, error) {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		id := cfg.Option("id", "")
+		p, ok := providers[id]
+		if !ok {
+			return nil, fmt.Errorf("no provider %q; select one with Provider.Config", id)
+		}
+		return p, nil
+	})
+}
+
+// Config returns the recognizer configuration selecting p, for
+// voxa.Config.Recognizer.
+func (p *Provider) Config() voxa.RecognizerConfig {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if p.id == "" {
+		p.id = strconv.Itoa(len(providers) + 1)
+		providers[p.id] = p
+	}
+	return voxa.RecognizerConfig{Provider: ProviderName, Options: map[string]string{"id": p.id}}
+}
+
+// Stream is a scripted recognition stream.
+type Stream struct {
+	cfg     voxa.StreamConfig
+	script  Script
+	results chan voxa.Segment
+	wake    chan struct{}
+
+	mu        sync.Mutex
+	audio     []byte
+	flushes   int
+	closed    bool
+	next      int     // of script
+	queue     []Event // due, not delivered yet
+	revisions map[string]int
+	err       error
+}
+
+func newStream(ctx context.Context, cfg voxa.StreamConfig, script Script) *Stream {
+	s := &Stream{
+		cfg:       cfg,
+		script:    script,
+		results:   make(chan voxa.Segment),
+		wake:      make(chan struct{}, 1),
+		revisions: map[string]int{},
+	}
+	go s.run(ctx)
+	return s
+}
+
+// Write records p and makes the events it reaches due.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, stt.ErrClosed
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.audio = append(s.audio, p...)
+	s.release(false)
+	return len(p), nil
+}
+
+// Results returns the channel segments are delivered on.
+func (s *Stream) Results() <-chan voxa.Segment { return s.results }
+
+// Flush makes the next event held for a flush due.
+func (s *Stream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return stt.ErrClosed
+	}
+	if s.err != nil {
+		return s.err
+	}
+	s.flushes++
+	s.release(true)
+	return nil
+}
+
+// Close makes the events held for flushes due, and ends the stream once
+// they have happened. Events scripted past the audio written never happen.
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		for s.release(true) {
+		}
+		s.signal()
+	}
+	return nil
+}
+
+// Err returns the error that failed the stream, if any.
+func (s *Stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Config returns the configuration the stream was opened with.
+func (s *Stream) Config() voxa.StreamConfig { return s.cfg }
+
+// Audio returns the audio written to the stream.
+func (s *Stream) Audio() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte(nil), s.audio...)
+}
+
+// Written returns the duration of the audio written to the stream.
+func (s *Stream) Written() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.written()
+}
+
+func (s *Stream) written() time.Duration {
+	if s.cfg.SampleRate <= 0 {
+		return 0
+	}
+	return time.Duration(len(s.audio)/2) * time.Second / time.Duration(s.cfg.SampleRate)
+}
+
+// Flushes returns the number of times the stream was flushed.
+func (s *Stream) Flushes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushes
+}
+
+// Closed reports whether the stream was closed.
+func (s *Stream) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// release queues the events due, the first one held for a flush included
+// if flushed, and reports whether it released one.
+func (s *Stream) release(flushed bool) bool {
+	n, written := len(s.queue), s.written()
+	for s.next < len(s.script) {
+		ev := s.script[s.next]
+		if ev.Flush {
+			if !flushed {
+				break
+			}
+			flushed = false
+		} else if ev.At > written {
+			break
+		}
+		s.queue = append(s.queue, ev)
+		s.next++
+	}
+	if len(s.queue) > n {
+		s.signal()
+		return true
+	}
+	return false
+}
+
+func (s *Stream) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the events due until the stream ends.
+func (s *Stream) run(ctx context.Context) {
+	defer close(s.results)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-s.wake:
+				continue
+			case <-ctx.Done():
+				s.fail(ctx.Err())
+				return
+			}
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		if ev.Delay > 0 {
+			t := time.NewTimer(ev.Delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				s.fail(ctx.Err())
+				return
+			}
+		}
+		if ev.Err != nil {
+			s.fail(ev.Err)
+			return
+		}
+		select {
+		case s.results <- s.segment(ev.Segment):
+		case <-ctx.Done():
+			s.fail(ctx.Err())
+			return
+		}
+	}
+}
+
+// segment fills in the fields of seg the script left out.
+func (s *Stream) segment(seg voxa.Segment) voxa.Segment {
+	if seg.UtteranceID == "" {
+		seg.UtteranceID = s.cfg.UtteranceID
+		if seg.UtteranceID == "" {
+			seg.UtteranceID = "1"
+		}
+	}
+	s.mu.Lock()
+	s.revisions[seg.UtteranceID]++
+	if seg.Revision == 0 {
+		seg.Revision = s.revisions[seg.UtteranceID]
+	}
+	s.mu.Unlock()
+	if seg.Final && seg.Stability == 0 {
+		seg.Stability = 1
+	}
+	return seg
+}
+
+func (s *Stream) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
@@ -0,0 +1,119 @@
+package stttest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/stttest"
+)
+
+var format = voxa.AudioFormat{SampleRate: 16000, Channels: 1}
+
+// run writes d of silence through a pipeline on rec, 20ms at a time, and
+// returns the segments it delivers.
+func run(t *testing.T, rec *stttest.Provider, d time.Duration) ([]voxa.Segment, error) {
+	t.Helper()
+	p, err := voxa.NewPipeline(voxa.Config{Recognizer: rec.Config()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	vs, err := p.NewStream(context.Background(), format, voxa.StreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var segs []voxa.Segment
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for seg := range vs.Results() {
+			segs = append(segs, seg)
+		}
+	}()
+	chunk := make([]byte, 2*format.Samples(20*time.Millisecond))
+	for written := time.Duration(0); written < d; written += 20 * time.Millisecond {
+		if _, err := vs.Write(chunk); err != nil {
+			break
+		}
+	}
+	err = vs.Close()
+	<-done
+	if err == nil {
+		err = vs.Err()
+	}
+	return segs, err
+}
+
+func TestUtterance(t *testing.T) {
+	rec := stttest.New(stttest.Utterance("1", "turn on the lights", 200*time.Millisecond, time.Second))
+	segs, err := run(t, rec, 1200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{"turn", "turn on", "turn on the", "turn on the lights"}
+	if len(segs) != len(texts) {
+		t.Fatalf("got %d segments, want %d: %+v", len(segs), len(texts), segs)
+	}
+	for i, seg := range segs {
+		if seg.Text != texts[i] || seg.Revision != i+1 || seg.Final != (i == len(texts)-1) {
+			t.Errorf("segment %d = %q revision %d final %v", i, seg.Text, seg.Revision, seg.Final)
+		}
+	}
+	if last := segs[len(segs)-1]; last.Stability != 1 || len(last.Words) != 4 {
+		t.Errorf("final = %+v", last)
+	}
+	if got := rec.Streams()[0].Written(); got != 1200*time.Millisecond {
+		t.Errorf("stream heard %v, want 1.2s", got)
+	}
+}
+
+func TestEventsWaitForAudio(t *testing.T) {
+	rec := stttest.New(stttest.Script{
+		stttest.Final(500*time.Millisecond, "1", "hello"),
+		stttest.Final(2*time.Second, "2", "never heard"),
+	})
+	segs, err := run(t, rec, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 1 || segs[0].Text != "hello" {
+		t.Fatalf("got %+v, want only hello", segs)
+	}
+}
+
+func TestFail(t *testing.T) {
+	boom := errors.New("backend down")
+	rec := stttest.New(stttest.Script{
+		stttest.Partial(100*time.Millisecond, "1", "hel"),
+		stttest.Fail(300*time.Millisecond, boom),
+	})
+	segs, err := run(t, rec, time.Second)
+	if !errors.Is(err, boom) {
+		t.Fatalf("err = %v, want %v", err, boom)
+	}
+	if len(segs) != 1 {
+		t.Errorf("got %d segments before failing, want 1", len(segs))
+	}
+}
+
+func TestFailOpen(t *testing.T) {
+	boom := errors.New("unavailable")
+	rec := stttest.New()
+	rec.FailOpen(boom)
+	p, err := voxa.NewPipeline(voxa.Config{Recognizer: rec.Config()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if _, err := p.NewStream(context.Background(), format, voxa.StreamOptions{}); !errors.Is(err, boom) {
+		t.Fatalf("first stream: err = %v, want %v", err, boom)
+	}
+	vs, err := p.NewStream(context.Background(), format, voxa.StreamOptions{})
+	if err != nil {
+		t.Fatalf("second stream: %v", err)
+	}
+	vs.Close()
+}
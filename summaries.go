@@ -0,0 +1,122 @@
+package voxa
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/store"
+	"github.com/jmarc101/voxa/internal/stt"
+	"github.com/jmarc101/voxa/internal/summarize"
+	"github.com/jmarc101/voxa/internal/summarize/openai"
+)
+
+// Summarizer summarizes the transcripts of completed sessions; see
+// Config.Summary.
+type Summarizer = summarize.Summarizer
+
+// Summary is what a Summarizer makes of a transcript.
+type Summary = summarize.Summary
+
+// SummarizerConfig configures a summarizer asking a large language model:
+// the backend, defaulting to the OpenAI chat completions API that local
+// model servers such as Ollama also speak, and the instructions.
+type SummarizerConfig = summarize.StageConfig
+
+// StoredSummary is the summary of a session recorded by a
+// TranscriptStore.
+type StoredSummary = store.Summary
+
+// NewSummarizer instantiates the language model backend selected by
+// cfg.Provider, defaulting to "openai", the one built in.
+func NewSummarizer(cfg SummarizerConfig) (Summarizer, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = openai.ProviderName
+	}
+	return summarize.NewStage(cfg)
+}
+
+// SummaryConfig configures the summaries of completed sessions.
+type SummaryConfig struct {
+	// Summarizer writes the summaries, such as one from NewSummarizer.
+	Summarizer Summarizer
+	// Label is recorded with every summary, e.g. the model; see
+	// StoredSummary.
+	Label string
+	// Timeout bounds a summary. Defaults to two minutes.
+	Timeout time.Duration
+}
+
+// maxSummarizing bounds the sessions summarized at once; more wait.
+const maxSummarizing = 4
+
+// Errors of Pipeline.Summarize.
+var (
+	ErrSummariesDisabled  = errors.New("voxa: sessions are not summarized")
+	ErrNothingToSummarize = errors.New("voxa: nothing to summarize")
+)
+
+// Summarize summarizes the live transcript of stored session id now and
+// stores the summary, replacing any earlier one. Sessions are summarized
+// on their own when their streams end; this is for those whose summary
+// failed, or that ended before summaries were enabled. It returns
+// ErrSummariesDisabled without Config.Summary, ErrTranscriptNotFound for
+// unknown sessions and ErrNothingToSummarize for those without any text.
+func (p *Pipeline) Summarize(ctx context.Context, id string) (StoredSummary, error) {
+	if p.cfg.Summary == nil {
+		return StoredSummary{}, ErrSummariesDisabled
+	}
+	ts := p.cfg.Transcripts
+	stored, err := ts.Segments(ctx, id, 0)
+	if err != nil {
+		return StoredSummary{}, err
+	}
+	if len(stored) == 0 {
+		if _, err := ts.Session(ctx, id); err != nil {
+			return StoredSummary{}, err
+		}
+		return StoredSummary{}, ErrNothingToSummarize
+	}
+	segs := make([]stt.Segment, len(stored))
+	for i, seg := range stored {
+		segs[i] = seg.Segment
+	}
+	sctx, cancel := context.WithTimeout(ctx, p.cfg.Summary.Timeout)
+	defer cancel()
+	sum, err := p.cfg.Summary.Summarizer.Summarize(sctx, segs)
+	if errors.Is(err, summarize.ErrEmpty) {
+		return StoredSummary{}, ErrNothingToSummarize
+	}
+	if err != nil {
+		return StoredSummary{}, err
+	}
+	out := StoredSummary{
+		Text:        sum.Text,
+		ActionItems: sum.ActionItems,
+		Label:       p.cfg.Summary.Label,
+		Created:     time.Now().UTC(),
+		Segments:    len(segs),
+	}
+	return out, ts.SetSummary(ctx, id, out)
+}
+
+// summarizeLater summarizes the session of s in the background, once its
+// transcript has been stored. Pipeline.Close waits for it.
+func (p *Pipeline) summarizeLater(s *Stream) {
+	p.summaries.Add(1)
+	go func() {
+		defer p.summaries.Done()
+		p.summarizing <- struct{}{}
+		defer func() { <-p.summarizing }()
+		start := time.Now()
+		_, err := p.Summarize(context.WithoutCancel(s.ctx), s.session)
+		switch {
+		case errors.Is(err, ErrNothingToSummarize):
+		case err != nil:
+			s.metrics.Error("summary")
+			s.log.Warn("summarizing session failed", "error", err)
+		default:
+			s.log.Debug("session summarized", "took", time.Since(start))
+		}
+	}()
+}
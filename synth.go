@@ -0,0 +1,57 @@
+package voxa
+
+import (
+	"context"
+
+	ttsclient "github.com/jmarc101/voxa/internal/clients/tts"
+	"github.com/jmarc101/voxa/internal/tts"
+
+	// Bundled synthesis backends, selectable by provider name.
+	_ "github.com/jmarc101/voxa/internal/tts/azure"
+	_ "github.com/jmarc101/voxa/internal/tts/elevenlabs"
+	_ "github.com/jmarc101/voxa/internal/tts/google"
+	_ "github.com/jmarc101/voxa/internal/tts/openai"
+	_ "github.com/jmarc101/voxa/internal/tts/piper"
+)
+
+// Synthesizer speaks text through a TTS backend.
+type Synthesizer = tts.Synthesizer
+
+// SynthesisRequest is one utterance to speak, as plain text or SSML.
+type SynthesisRequest = tts.Request
+
+// SynthesisStream is the audio of one utterance, readable as it is
+// synthesized.
+type SynthesisStream = tts.Stream
+
+// SynthesizerConfig selects a registered TTS provider by name.
+type SynthesizerConfig = tts.Config
+
+// SynthesisCacheConfig sizes the cache of the audio a synthesizer has
+// spoken; see SynthesizerConfig.Cache.
+type SynthesisCacheConfig = tts.CacheConfig
+
+// NewSynthesizer instantiates the TTS backend selected by cfg.Provider,
+// defaulting to the TTS sidecar. Besides the sidecar, "piper" (local),
+// "google", "openai", "elevenlabs" and "azure" are built in; see their
+// packages for options.
+func NewSynthesizer(cfg SynthesizerConfig) (Synthesizer, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = ttsclient.ProviderName
+	}
+	return tts.New(cfg)
+}
+
+// Voice is a voice a synthesizer speaks with; its ID selects it in
+// SynthesisRequest.Voice.
+type Voice = tts.Voice
+
+// ErrNoVoices is returned by Voices for providers that cannot list their
+// voices, such as piper, whose model is its only voice.
+var ErrNoVoices = tts.ErrNoVoices
+
+// Voices lists the voices s, built by NewSynthesizer, speaks with: those
+// the account or region of its provider offers.
+func Voices(ctx context.Context, s Synthesizer) ([]Voice, error) {
+	return tts.Voices(ctx, s)
+}
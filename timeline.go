@@ -0,0 +1,77 @@
+package voxa
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// timeline maps positions in the audio written to a recognizer back to the
+// source's clock. Stages such as VAD drop audio, so the recognizer hears a
+// compacted stream: without the mapping, transcript times would drift
+// earlier with every pause.
+type timeline struct {
+	mu     sync.Mutex
+	chunks []chunk       // runs of contiguous source audio, by rec
+	pos    time.Duration // recognizer time written so far
+}
+
+// chunk is a run of audio that is contiguous on both clocks.
+type chunk struct {
+	rec, src time.Duration
+}
+
+// add records that fr was written to the recognizer.
+func (t *timeline) add(fr audio.Frame) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := len(t.chunks); n == 0 || t.chunks[n-1].src+(t.pos-t.chunks[n-1].rec) != fr.Offset {
+		t.chunks = append(t.chunks, chunk{rec: t.pos, src: fr.Offset})
+	}
+	t.pos += fr.Duration()
+}
+
+// start maps a recognizer time that begins a span.
+func (t *timeline) start(d time.Duration) time.Duration {
+	return t.at(d, false)
+}
+
+// end maps a recognizer time that ends a span: at a chunk boundary it
+// belongs to the chunk before, not the one after the gap.
+func (t *timeline) end(d time.Duration) time.Duration {
+	return t.at(d, true)
+}
+
+func (t *timeline) at(d time.Duration, end bool) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := sort.Search(len(t.chunks), func(i int) bool {
+		if end {
+			return t.chunks[i].rec >= d
+		}
+		return t.chunks[i].rec > d
+	}) - 1
+	if i < 0 {
+		return d
+	}
+	return t.chunks[i].src + d - t.chunks[i].rec
+}
+
+// remap moves seg's times from the recognizer's clock to the source's.
+func (t *timeline) remap(seg Segment) Segment {
+	if seg.End == 0 && len(seg.Words) == 0 {
+		return seg
+	}
+	seg.Start, seg.End = t.start(seg.Start), t.end(seg.End)
+	if len(seg.Words) > 0 {
+		words := make([]Word, len(seg.Words))
+		for i, w := range seg.Words {
+			w.Start, w.End = t.start(w.Start), t.end(w.End)
+			words[i] = w
+		}
+		seg.Words = words
+	}
+	return seg
+}
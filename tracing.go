@@ -0,0 +1,199 @@
+package voxa
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of voxa's spans.
+const TracerName = "github.com/jmarc101/voxa"
+
+func (p *Pipeline) tracer() trace.Tracer {
+	return p.TracerProvider().Tracer(TracerName)
+}
+
+// utterance is the trace of one utterance: a voxa.utterance span from its
+// first audio to the delivery of its final segment, with children for the
+// speech VAD heard, recognition, and the processing of the final.
+type utterance struct {
+	ctx  context.Context // carries span
+	span trace.Span
+	vad  trace.Span // open while VAD reports speech
+	stt  trace.Span
+}
+
+// utterances tracks the utterances of a stream. Audio opens them on the
+// write path; their finals close them on the delivery path, in order.
+type utterances struct {
+	tracer trace.Tracer
+	parent context.Context
+
+	mu      sync.Mutex
+	cur     *utterance   // receiving audio
+	pending []*utterance // flushed, awaiting their final
+}
+
+// open returns the current utterance, starting one if needed; u.mu must
+// be held.
+func (u *utterances) open() *utterance {
+	if u.cur == nil {
+		ctx, span := u.tracer.Start(u.parent, "voxa.utterance")
+		utt := &utterance{ctx: ctx, span: span}
+		_, utt.stt = u.tracer.Start(ctx, "voxa.stt")
+		u.cur = utt
+	}
+	return u.cur
+}
+
+// speech records a VAD transition.
+func (u *utterances) speech(start bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if start {
+		utt := u.open()
+		if utt.vad == nil {
+			_, utt.vad = u.tracer.Start(utt.ctx, "voxa.vad")
+		}
+	} else if u.cur != nil && u.cur.vad != nil {
+		u.cur.vad.End()
+		u.cur.vad = nil
+	}
+}
+
+// wrote records audio reaching the recognizer.
+func (u *utterances) wrote() {
+	u.mu.Lock()
+	u.open()
+	u.mu.Unlock()
+}
+
+// cut records a flush: the current utterance waits for its final.
+func (u *utterances) cut() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.cur == nil {
+		return
+	}
+	if u.cur.vad != nil {
+		u.cur.vad.End()
+		u.cur.vad = nil
+	}
+	u.cur.stt.AddEvent("flush")
+	u.pending = append(u.pending, u.cur)
+	u.cur = nil
+}
+
+// oldest returns the utterance the recognizer is finishing; u.mu must be
+// held.
+func (u *utterances) oldest() *utterance {
+	if len(u.pending) > 0 {
+		return u.pending[0]
+	}
+	return u.cur
+}
+
+// partial records a partial hypothesis.
+func (u *utterances) partial(seg Segment) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if utt := u.oldest(); utt != nil {
+		utt.stt.AddEvent("partial", trace.WithAttributes(attribute.Int("voxa.revision", seg.Revision)))
+	}
+}
+
+// final ends recognition of the oldest utterance and returns it, for the
+// processing of the final segment. The recognizer may finalize on its own,
+// without a flush.
+func (u *utterances) final(seg Segment) *utterance {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	utt := u.oldest()
+	switch {
+	case utt == nil:
+		// A final without audio, such as one replayed by the backend.
+		ctx, span := u.tracer.Start(u.parent, "voxa.utterance")
+		utt = &utterance{ctx: ctx, span: span}
+	case len(u.pending) > 0:
+		u.pending = u.pending[1:]
+	default:
+		u.cur = nil
+	}
+	if utt.vad != nil {
+		utt.vad.End()
+	}
+	utt.span.SetAttributes(segmentAttributes(seg)...)
+	if utt.stt != nil {
+		utt.stt.SetAttributes(
+			attribute.Int("voxa.revisions", seg.Revision),
+			attribute.Float64("voxa.confidence", float64(seg.Confidence)),
+		)
+		utt.stt.End()
+	}
+	return utt
+}
+
+// end closes the utterance once its final has been delivered, or failed.
+func (utt *utterance) end(err error) {
+	if err != nil {
+		utt.span.RecordError(err)
+		utt.span.SetStatus(codes.Error, err.Error())
+	}
+	utt.span.End()
+}
+
+// close ends the utterances that will not get a final, once the stream is
+// over.
+func (u *utterances) close(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.cur != nil {
+		u.pending = append(u.pending, u.cur)
+		u.cur = nil
+	}
+	for _, utt := range u.pending {
+		if utt.vad != nil {
+			utt.vad.End()
+		}
+		if err != nil {
+			utt.stt.RecordError(err)
+			utt.stt.SetStatus(codes.Error, err.Error())
+		}
+		utt.stt.End()
+		utt.span.End()
+	}
+	u.pending = nil
+}
+
+func segmentAttributes(seg Segment) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("voxa.utterance_id", seg.UtteranceID),
+		attribute.Int("voxa.text_length", len(seg.Text)),
+		attribute.Int64("voxa.audio_ms", (seg.End - seg.Start).Milliseconds()),
+	}
+	if seg.Speaker != "" {
+		attrs = append(attrs, attribute.String("voxa.speaker", seg.Speaker))
+	}
+	if seg.Language != "" {
+		attrs = append(attrs, attribute.String("voxa.language", seg.Language))
+	}
+	if seg.Provider != "" {
+		attrs = append(attrs, attribute.String("voxa.provider", seg.Provider))
+	}
+	return attrs
+}
+
+// span runs fn in a child span of ctx named name, recording its error.
+func span(ctx context.Context, tracer trace.Tracer, name string, fn func(context.Context) error) error {
+	ctx, sp := tracer.Start(ctx, name)
+	defer sp.End()
+	err := fn(ctx)
+	if err != nil {
+		sp.RecordError(err)
+		sp.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
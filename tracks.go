@@ -0,0 +1,156 @@
+package voxa
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/jmarc101/voxa/internal/audio"
+)
+
+// TracksConfig configures TranscribeTracks.
+type TracksConfig struct {
+	// Labels name the channels of the recording in order, such as "agent"
+	// and "customer" for a call recorded with the agent on the left. They
+	// become the Speaker of the segments of their channel; channels past
+	// them are labelled by number: ch1, ch2 and so on.
+	Labels []string
+}
+
+// track is a channel of a recording transcribed by TranscribeTracks.
+type track struct {
+	label  string
+	s      *Stream
+	frames chan audio.Frame
+	finals []Segment
+	err    error // writing failed
+}
+
+// TranscribeTracks transcribes every channel of a recording on a stream of
+// its own, in parallel, such as the agent and the customer of a stereo
+// call recording, then merges their final segments into one transcript in
+// the order they start. Segments carry the label of their channel as
+// Speaker, in place of the speaker labels of diarization, which separate
+// channels make unneeded, and utterances are numbered from 1 in transcript
+// order. A mono recording is a single track.
+func (p *Pipeline) TranscribeTracks(ctx context.Context, src audio.Reader, cfg TracksConfig) ([]Segment, error) {
+	f := src.Format()
+	if len(cfg.Labels) > f.Channels {
+		return nil, fmt.Errorf("voxa: %d track labels for %d channels", len(cfg.Labels), f.Channels)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	mono := audio.Format{SampleRate: f.SampleRate, Channels: 1}
+	tracks := make([]*track, f.Channels)
+	for i := range tracks {
+		t := &track{label: "ch" + strconv.Itoa(i+1), frames: make(chan audio.Frame, 8)}
+		if i < len(cfg.Labels) {
+			t.label = cfg.Labels[i]
+		}
+		s, err := p.NewStream(ctx, mono, StreamOptions{})
+		if err != nil {
+			cancel()
+			for _, t := range tracks[:i] {
+				_ = t.s.Close()
+				for range t.s.Results() {
+				}
+			}
+			return nil, err
+		}
+		t.s, tracks[i] = s, t
+	}
+
+	var writers, readers sync.WaitGroup
+	for _, t := range tracks {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for seg := range t.s.Results() {
+				if seg.Final {
+					t.finals = append(t.finals, seg)
+				}
+			}
+		}()
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for fr := range t.frames {
+				if t.err == nil {
+					if t.err = t.s.WriteFrame(fr); t.err != nil {
+						cancel()
+					}
+				}
+				fr.Release()
+			}
+		}()
+	}
+	err := split(ctx, src, tracks)
+	for _, t := range tracks {
+		close(t.frames)
+	}
+	writers.Wait()
+	for _, t := range tracks {
+		if cerr := t.s.Close(); err == nil {
+			err = cerr
+		}
+	}
+	readers.Wait()
+	// A track failing to write cancels the others: its error comes first.
+	for _, t := range tracks {
+		if t.err != nil {
+			err = t.err
+			break
+		}
+	}
+	for _, t := range tracks {
+		err = cmp.Or(err, t.s.Err())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Segment
+	for _, t := range tracks {
+		for _, seg := range t.finals {
+			seg.Speaker = t.label
+			out = append(out, seg)
+		}
+	}
+	// Tracks are appended in channel order, so the stable sort puts the
+	// left channel first when two utterances start at once.
+	slices.SortStableFunc(out, func(a, b Segment) int { return cmp.Compare(a.Start, b.Start) })
+	for i := range out {
+		out[i].UtteranceID = strconv.Itoa(i + 1)
+	}
+	p.cfg.Logger.Debug("tracks merged", "tracks", len(tracks), "utterances", len(out))
+	return out, nil
+}
+
+// split hands every channel of the frames of src to its track, until src
+// is exhausted or ctx is done.
+func split(ctx context.Context, src audio.Reader, tracks []*track) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fr, err := src.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		for i, t := range tracks {
+			select {
+			case t.frames <- fr.Channel(i):
+			case <-ctx.Done():
+			}
+		}
+		fr.Release()
+	}
+}
@@ -0,0 +1,82 @@
+package voxa_test
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/stttest"
+)
+
+// stereo is a recording of n frames holding left on the left channel and
+// right on the right.
+type stereo struct {
+	n, read     int
+	left, right int16
+}
+
+func (stereo) Format() audio.Format { return audio.Format{SampleRate: 16000, Channels: 2} }
+
+func (s *stereo) ReadFrame() (audio.Frame, error) {
+	if s.read == s.n {
+		return audio.Frame{}, io.EOF
+	}
+	data := make([]int16, 2*320)
+	for i := 0; i < len(data); i += 2 {
+		data[i], data[i+1] = s.left, s.right
+	}
+	s.read++
+	return audio.Frame{Format: s.Format(), Data: data, Offset: time.Duration(s.read-1) * audio.FrameDuration}, nil
+}
+
+func TestTranscribeTracks(t *testing.T) {
+	rec := stttest.New(
+		append(stttest.Utterance("1", "how can I help", 200*time.Millisecond, time.Second),
+			stttest.Utterance("2", "thanks", 1800*time.Millisecond, 2200*time.Millisecond)...),
+		stttest.Utterance("1", "my card was declined", 1100*time.Millisecond, 1700*time.Millisecond),
+	)
+	p, err := voxa.NewPipeline(voxa.Config{Recognizer: rec.Config()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	segs, err := p.TranscribeTracks(context.Background(), &stereo{n: 150, left: 1000, right: -1000}, voxa.TracksConfig{Labels: []string{"agent", "customer"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ id, speaker, text string }{
+		{"1", "agent", "how can I help"},
+		{"2", "customer", "my card was declined"},
+		{"3", "agent", "thanks"},
+	}
+	if len(segs) != len(want) {
+		t.Fatalf("got %d segments, want %d: %+v", len(segs), len(want), segs)
+	}
+	for i, w := range want {
+		if s := segs[i]; s.UtteranceID != w.id || s.Speaker != w.speaker || s.Text != w.text {
+			t.Errorf("segment %d = %s %s %q, want %s %s %q", i, s.UtteranceID, s.Speaker, s.Text, w.id, w.speaker, w.text)
+		}
+	}
+	for i, v := range []int16{1000, -1000} {
+		a := rec.Streams()[i].Audio()
+		if len(a) != 2*150*320 || int16(binary.LittleEndian.Uint16(a)) != v {
+			t.Errorf("track %d got %d bytes starting with %d, want %d of %d", i, len(a), int16(binary.LittleEndian.Uint16(a)), 2*150*320, v)
+		}
+	}
+}
+
+func TestTranscribeTracksLabels(t *testing.T) {
+	p, err := voxa.NewPipeline(voxa.Config{Recognizer: stttest.New().Config()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	_, err = p.TranscribeTracks(context.Background(), &stereo{n: 1}, voxa.TracksConfig{Labels: []string{"a", "b", "c"}})
+	if err == nil {
+		t.Fatal("three labels for two channels accepted")
+	}
+}
@@ -0,0 +1,108 @@
+package voxa
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmarc101/voxa/internal/store"
+)
+
+// TranscriptStore persists the sessions of a pipeline with their final
+// segments; see Config.Transcripts.
+type TranscriptStore = store.TranscriptStore
+
+// StoredSession is a session recorded by a TranscriptStore.
+type StoredSession = store.Session
+
+// StoredSegment is a final segment recorded by a TranscriptStore.
+type StoredSegment = store.Segment
+
+// TranscriptVersion is a transcript of a stored session made again from
+// its archived audio.
+type TranscriptVersion = store.Version
+
+// StoredArchive is a file of archived audio recorded by a TranscriptStore.
+type StoredArchive = store.Archive
+
+// TranscriptQuery selects stored sessions.
+type TranscriptQuery = store.Query
+
+// DefaultTranscriptLimit is the number of sessions TranscriptStore.Sessions
+// returns when the query sets none.
+const DefaultTranscriptLimit = store.DefaultLimit
+
+// TranscriptSearch selects stored segments by their text; see
+// store.SearchQuery for the query syntax.
+type TranscriptSearch = store.SearchQuery
+
+// TranscriptHit is a stored segment matching a search.
+type TranscriptHit = store.Hit
+
+// ErrTranscriptNotFound is returned by TranscriptStore.Session for unknown
+// sessions.
+var ErrTranscriptNotFound = store.ErrNotFound
+
+// ErrEmptyTranscriptSearch is returned by TranscriptStore.Search for a
+// query without any word.
+var ErrEmptyTranscriptSearch = store.ErrEmptySearch
+
+// NewSQLiteTranscriptStore opens, creating it if needed, a transcript
+// store in the SQLite database at path.
+func NewSQLiteTranscriptStore(path string) (TranscriptStore, error) {
+	return store.NewSQLite(path)
+}
+
+// NewPostgresTranscriptStore connects to a transcript store in the
+// PostgreSQL database at a postgres:// URL, creating its tables if needed.
+func NewPostgresTranscriptStore(url string) (TranscriptStore, error) {
+	return store.NewPostgres(url)
+}
+
+// Transcripts returns Config.Transcripts, nil if transcripts are not
+// stored.
+func (p *Pipeline) Transcripts() TranscriptStore { return p.cfg.Transcripts }
+
+// startTranscript records the start of the session of s.
+func (p *Pipeline) startTranscript(s *Stream) {
+	if p.cfg.Transcripts == nil {
+		return
+	}
+	if err := p.cfg.Transcripts.StartSession(s.ctx, s.session, s.tenant, time.Now()); err != nil {
+		s.metrics.Error("store")
+		s.log.Warn("storing transcript failed", "error", err)
+	}
+}
+
+// storeSegment records a final segment of s.
+func (p *Pipeline) storeSegment(ctx context.Context, s *Stream, seg Segment) {
+	if err := p.cfg.Transcripts.AddSegment(ctx, s.session, seg); err != nil {
+		s.metrics.Error("store")
+		s.log.Warn("storing transcript failed", "utterance", seg.UtteranceID, "error", err)
+	}
+}
+
+// endTranscript records the end of the session of s, once its last segment
+// has been stored.
+func (p *Pipeline) endTranscript(s *Stream) {
+	if p.cfg.Transcripts == nil {
+		return
+	}
+	if err := p.cfg.Transcripts.EndSession(context.WithoutCancel(s.ctx), s.session, time.Now()); err != nil {
+		s.metrics.Error("store")
+		s.log.Warn("storing transcript failed", "error", err)
+	}
+}
+
+// SetMetadata merges md into the metadata stored with the session of s,
+// an empty value removing its key; see TranscriptStore.SetMetadata. It
+// does nothing if transcripts are not stored.
+func (s *Stream) SetMetadata(md map[string]string) error {
+	if s.store == nil {
+		return nil
+	}
+	if err := s.store.SetMetadata(context.WithoutCancel(s.ctx), s.session, md); err != nil {
+		s.metrics.Error("store")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package voxa
+
+import (
+	"github.com/jmarc101/voxa/internal/logging"
+	"github.com/jmarc101/voxa/internal/metrics"
+	"github.com/jmarc101/voxa/internal/stt"
+	"github.com/jmarc101/voxa/internal/translate"
+	"github.com/jmarc101/voxa/internal/translate/libretranslate"
+)
+
+// Translator translates text through a backend.
+type Translator = translate.Translator
+
+// TranslatorConfig selects a registered translation provider by name.
+type TranslatorConfig = translate.Config
+
+// TranslationConfig configures transcript translation: the backend,
+// defaulting to LibreTranslate, the spoken language and the languages to
+// caption in.
+type TranslationConfig = translate.StageConfig
+
+// NewTranslator instantiates the translation backend selected by
+// cfg.Provider, defaulting to "libretranslate", the one built in.
+func NewTranslator(cfg TranslatorConfig) (Translator, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = libretranslate.ProviderName
+	}
+	return translate.New(cfg)
+}
+
+func newTranslationStage(cfg TranslationConfig, m *metrics.Metrics, log logging.Logger) (*translate.Stage, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = libretranslate.ProviderName
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log
+	}
+	onError := cfg.OnError
+	cfg.OnError = func(seg stt.Segment, target string, err error) {
+		m.Error("translate")
+		log.Warn("translation failed", "utterance", seg.UtteranceID, "target", target, "error", err)
+		if onError != nil {
+			onError(seg, target, err)
+		}
+	}
+	return translate.NewStage(cfg)
+}
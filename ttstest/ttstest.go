@@ -0,0 +1,213 @@
+// Package ttstest provides a scriptable text-to-speech synthesizer for
+// tests of programs built on voxa, which runs without a backend or network
+// access.
+//
+// A Synthesizer answers every request with the next scripted Response, or
+// with a tone as long as the text once the script has run out, so the same
+// requests always produce the same audio. Responses can fail synthesis or
+// the stream midway, and pace their frames in wall-clock time for tests of
+// playback and barge-in. The synthesizer records the requests it was sent
+// for assertions.
+//
+//	synth := ttstest.New(ttstest.Response{Duration: 2 * time.Second})
+//	s, err := synth.Synthesize(ctx, voxa.SynthesisRequest{Text: "Hello"})
+package ttstest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/internal/audio"
+	"github.com/jmarc101/voxa/internal/tts"
+)
+
+// ProviderName is the name Config selects synthesizers by.
+const ProviderName = "ttstest"
+
+// Format is the format of the audio synthesized.
+var Format = voxa.AudioFormat{SampleRate: 16000, Channels: 1}
+
+// WordDuration is the length of a word of the default tone.
+const WordDuration = 250 * time.Millisecond
+
+// Response is the scripted answer to a request.
+type Response struct {
+	// Audio is the mono PCM16 audio spoken, in Format. If nil, the response
+	// is a 440Hz tone lasting Duration, or WordDuration per word of the
+	// text if Duration is zero too.
+	Audio    []int16
+	Duration time.Duration
+	// Delay is the time before the first frame, and FrameDelay the time
+	// between frames; zero delivers the audio as fast as it is read.
+	Delay, FrameDelay time.Duration
+	// Err, if set, is returned by Synthesize.
+	Err error
+	// StreamErr, if set, fails the stream once FailAfter of audio has been
+	// read.
+	StreamErr error
+	FailAfter time.Duration
+}
+
+// Synthesizer speaks scripted responses. The zero value is not usable; see
+// New.
+type Synthesizer struct {
+	mu        sync.Mutex
+	responses []Response
+	requests  []voxa.SynthesisRequest
+	id        string
+}
+
+// New returns a synthesizer answering requests with responses, in order,
+// and with the default tone once they have run out.
+func New(responses ...Response) *Synthesizer {
+	return &Synthesizer{responses: responses}
+}
+
+// Script queues more responses after those not used yet.
+func (s *Synthesizer) Script(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, responses...)
+}
+
+// Requests returns the requests synthesized so far, in order.
+func (s *Synthesizer) Requests() []voxa.SynthesisRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]voxa.SynthesisRequest(nil), s.requests...)
+}
+
+// Synthesize speaks the next response to req.
+func (s *Synthesizer) Synthesize(ctx context.Context, req voxa.SynthesisRequest) (voxa.SynthesisStream, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	var r Response
+	if len(s.responses) > 0 {
+		r = s.responses[0]
+		s.responses = s.responses[1:]
+	}
+	s.mu.Unlock()
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	pcm := r.Audio
+	if pcm == nil {
+		d := r.Duration
+		if d == 0 {
+			d = WordDuration * time.Duration(words(req.Text))
+		}
+		pcm = tone(Format.Samples(d))
+	}
+	return &stream{ctx: ctx, r: r, pcm: pcm, closed: make(chan struct{})}, nil
+}
+
+// words counts the words of text, SSML tags aside.
+func words(text string) int {
+	if tts.IsSSML(text) {
+		if plain, err := tts.PlainText(text); err == nil {
+			text = plain
+		}
+	}
+	return len(strings.Fields(text))
+}
+
+// tone returns n samples of a 440Hz tone at a quarter of full scale.
+func tone(n int) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		pcm[i] = int16(8192 * math.Sin(2*math.Pi*440*float64(i)/float64(Format.SampleRate)))
+	}
+	return pcm
+}
+
+var (
+	registryMu   sync.Mutex
+	synthesizers = map[string]*Synthesizer{}
+)
+
+func init() {
+	tts.Register(ProviderName, func(cfg tts.Config) (tts.Synthesizer, error) {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		id := cfg.Option("id", "")
+		s, ok := synthesizers[id]
+		if !ok {
+			return nil, fmt.Errorf("no synthesizer %q; select one with Synthesizer.Config", id)
+		}
+		return s, nil
+	})
+}
+
+// Config returns the configuration selecting s, for voxa.NewSynthesizer.
+func (s *Synthesizer) Config() voxa.SynthesizerConfig {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if s.id == "" {
+		s.id = strconv.Itoa(len(synthesizers) + 1)
+		synthesizers[s.id] = s
+	}
+	return voxa.SynthesizerConfig{Provider: ProviderName, Options: map[string]string{"id": s.id}}
+}
+
+// stream reads a response out in audio.FrameDuration frames.
+type stream struct {
+	ctx    context.Context
+	r      Response
+	pcm    []int16
+	pos    int
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (st *stream) Format() voxa.AudioFormat { return Format }
+
+func (st *stream) ReadFrame() (voxa.AudioFrame, error) {
+	select {
+	case <-st.closed:
+		return voxa.AudioFrame{}, tts.ErrClosed
+	default:
+	}
+	if st.r.StreamErr != nil && st.pos >= Format.Samples(st.r.FailAfter) {
+		return voxa.AudioFrame{}, st.r.StreamErr
+	}
+	if st.pos >= len(st.pcm) {
+		return voxa.AudioFrame{}, io.EOF
+	}
+	wait := st.r.FrameDelay
+	if st.pos == 0 {
+		wait = st.r.Delay
+	}
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-st.closed:
+			return voxa.AudioFrame{}, tts.ErrClosed
+		case <-st.ctx.Done():
+			return voxa.AudioFrame{}, st.ctx.Err()
+		}
+	}
+	if err := st.ctx.Err(); err != nil {
+		return voxa.AudioFrame{}, err
+	}
+	end := min(len(st.pcm), st.pos+Format.Samples(audio.FrameDuration))
+	if st.r.StreamErr != nil {
+		end = min(end, Format.Samples(st.r.FailAfter))
+	}
+	fr := voxa.AudioFrame{Format: Format, Data: append([]int16(nil), st.pcm[st.pos:end]...), Offset: Format.Duration(st.pos)}
+	st.pos = end
+	return fr, nil
+}
+
+func (st *stream) Close() error {
+	st.once.Do(func() { close(st.closed) })
+	return nil
+}
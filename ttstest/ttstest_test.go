@@ -0,0 +1,72 @@
+package ttstest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jmarc101/voxa"
+	"github.com/jmarc101/voxa/ttstest"
+)
+
+// read returns the audio of s and the error that ended it, io.EOF aside.
+func read(s voxa.SynthesisStream) (time.Duration, error) {
+	defer s.Close()
+	var n int
+	for {
+		fr, err := s.ReadFrame()
+		if err == io.EOF {
+			return ttstest.Format.Duration(n), nil
+		}
+		if err != nil {
+			return ttstest.Format.Duration(n), err
+		}
+		n += fr.Len()
+	}
+}
+
+func TestResponses(t *testing.T) {
+	boom := errors.New("voice unavailable")
+	cut := errors.New("connection reset")
+	synth := ttstest.New(
+		ttstest.Response{Duration: time.Second},
+		ttstest.Response{Err: boom},
+		ttstest.Response{Duration: time.Second, StreamErr: cut, FailAfter: 300 * time.Millisecond},
+	)
+	s, err := voxa.NewSynthesizer(synth.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	st, err := s.Synthesize(ctx, voxa.SynthesisRequest{Text: "one"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := read(st); err != nil || d != time.Second {
+		t.Errorf("first response: %v of audio, err = %v", d, err)
+	}
+	if _, err := s.Synthesize(ctx, voxa.SynthesisRequest{Text: "two"}); !errors.Is(err, boom) {
+		t.Errorf("second response: err = %v, want %v", err, boom)
+	}
+	st, err = s.Synthesize(ctx, voxa.SynthesisRequest{Text: "three"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := read(st); !errors.Is(err, cut) || d != 300*time.Millisecond {
+		t.Errorf("third response: %v of audio, err = %v", d, err)
+	}
+	// Past the script, the tone lasts as long as the words.
+	st, err = s.Synthesize(ctx, voxa.SynthesisRequest{Text: "<speak>four <break time=\"1s\"/> five</speak>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := read(st); err != nil || d != 2*ttstest.WordDuration {
+		t.Errorf("default response: %v of audio, err = %v", d, err)
+	}
+	if got := len(synth.Requests()); got != 4 {
+		t.Errorf("recorded %d requests, want 4", got)
+	}
+}
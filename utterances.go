@@ -0,0 +1,34 @@
+package voxa
+
+import "github.com/jmarc101/voxa/internal/audio/silence"
+
+// UtteranceConfig configures TrimSilence and NewUtteranceSplitter: the
+// VAD telling speech from silence, the audio kept around speech, and the
+// lengths of the utterances split.
+type UtteranceConfig = silence.Config
+
+// DefaultUtterancePad is the default UtteranceConfig.Pad.
+const DefaultUtterancePad = silence.DefaultPad
+
+// UtteranceSplitter splits a recording into utterances; see
+// NewUtteranceSplitter.
+type UtteranceSplitter = silence.Splitter
+
+// ErrNoSpeech is returned by TrimSilence for audio without speech.
+var ErrNoSpeech = silence.ErrNoSpeech
+
+// TrimSilence returns clip without the silence before its first speech
+// and after its last, but for UtteranceConfig.Pad, as when preparing
+// datasets. The frame returned shares the data of clip.
+func TrimSilence(clip AudioFrame, cfg UtteranceConfig) (AudioFrame, error) {
+	return silence.Trim(clip, cfg)
+}
+
+// NewUtteranceSplitter returns a splitter reading src once and returning
+// its utterances one at a time, with the audio around their speech, and
+// cut in their pauses to UtteranceConfig.MaxLength: chunks the size
+// recognizers are trained on, from recordings of any length. It runs
+// outside any pipeline.
+func NewUtteranceSplitter(src AudioReader, cfg UtteranceConfig) (*UtteranceSplitter, error) {
+	return silence.NewSplitter(src, cfg)
+}
@@ -0,0 +1,12 @@
+package voxa
+
+import (
+	"github.com/jmarc101/voxa/internal/stt"
+	"github.com/jmarc101/voxa/internal/vocab"
+)
+
+// Phrase is a word or phrase recognition should favour, with its boost.
+type Phrase = stt.Phrase
+
+// VocabularyConfig configures the custom vocabulary; see Config.Vocabulary.
+type VocabularyConfig = vocab.Config
@@ -0,0 +1,45 @@
+// Package voxa is the application-facing API of the voxa voice assistant.
+//
+// It re-exports the core speech types from the internal packages so programs
+// built on voxa only need this import for the common cases.
+package voxa
+
+import "github.com/jmarc101/voxa/internal/stt"
+
+// StreamingRecognizer transcribes one audio stream incrementally, emitting
+// interim hypotheses with stability scores followed by a final transcript.
+// See stt.StreamingRecognizer for the full contract.
+type StreamingRecognizer = stt.StreamingRecognizer
+
+// Segment is a recognized span of speech, either an interim hypothesis or
+// the final transcript of an utterance.
+type Segment = stt.Segment
+
+// Word is one recognized word with its start, end and confidence.
+type Word = stt.Word
+
+// StreamConfig describes the audio a recognition stream will receive.
+type StreamConfig = stt.StreamConfig
+
+// RecognizerProvider opens recognition streams against a backend.
+type RecognizerProvider = stt.Provider
+
+// Priority ranks a stream against the others sharing the recognizer's
+// backend; see StreamOptions.Priority.
+type Priority = stt.Priority
+
+// Stream priorities.
+const (
+	// PriorityInteractive is for streams someone is waiting to hear back
+	// on, such as voice assistants; they go first.
+	PriorityInteractive = stt.PriorityInteractive
+	// PriorityNormal is the default.
+	PriorityNormal = stt.PriorityNormal
+	// PriorityBatch is for offline work, such as transcribing archived
+	// audio again, which waits for the others.
+	PriorityBatch = stt.PriorityBatch
+)
+
+// ParsePriority returns the priority named "interactive", "normal" or
+// "batch"; empty is PriorityNormal.
+func ParsePriority(s string) (Priority, error) { return stt.ParsePriority(s) }